@@ -5,6 +5,20 @@ import (
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
+)
+
+// SeverityLevel of a trace or exception. The zero value is the default of the item:
+// Warning for reports and Error for exceptions.
+type SeverityLevel int
+
+const (
+	DefaultSeverity SeverityLevel = iota
+	Verbose
+	Information
+	Warning
+	Error
+	Critical
 )
 
 // Application trace/log structure
@@ -13,6 +27,9 @@ type Report struct {
 	Context          string
 	AppVersion       string
 	CustomDimensions map[string]string
+	Severity         SeverityLevel
+	// SamplingPercentage overrides the SamplingPercentage of the AIConfig for this item when non-zero.
+	SamplingPercentage float64
 }
 
 // Application event structure
@@ -20,6 +37,24 @@ type Event struct {
 	EventName  string
 	ResourceID string
 	Properties map[string]string
+	// Measurements are numeric values of the event, e.g. a duration or count.
+	Measurements map[string]float64
+	// SamplingPercentage overrides the SamplingPercentage of the AIConfig for this item when non-zero.
+	SamplingPercentage float64
+}
+
+// Application exception structure
+type Exception struct {
+	// Error is the panic value or error. Strings, errors and Stringers are reported by their message.
+	Error            interface{}
+	Context          string
+	AppVersion       string
+	CustomDimensions map[string]string
+	Severity         SeverityLevel
+	// Stack is the callstack of the exception. The callstack of the TrackException caller is used if it is empty.
+	Stack []*contracts.StackFrame
+	// SamplingPercentage overrides the SamplingPercentage of the AIConfig for this item when non-zero.
+	SamplingPercentage float64
 }
 
 // Application metrics structure
@@ -40,6 +75,9 @@ type AIConfig struct {
 	GetEnvRetryCount             int
 	GetEnvRetryWaitTimeInSecs    int
 	DebugMode                    bool
+	// SamplingPercentage is the percentage of reports, events and exceptions which are sent, 100 if unset.
+	// Metrics are never sampled.
+	SamplingPercentage float64
 }
 
 // TelmetryHandle holds appinsight handles and metadata
@@ -51,6 +89,7 @@ type telemetryHandle struct {
 	client                       appinsights.TelemetryClient
 	disableMetadataRefreshThread bool
 	refreshTimeout               int
+	samplingPercentage           float64
 	rwmutex                      sync.RWMutex
 }
 
@@ -65,6 +104,9 @@ type TelemetryHandle interface {
 	// TrackEvent function sends events to appinsights resource. It overrides a few of the existing columns
 	// with app information.
 	TrackEvent(aiEvent Event)
+	// TrackException function sends an exception with its callstack to appinsights resource. It overrides
+	// a few of the existing columns with app information and for rest it uses custom dimension
+	TrackException(exception Exception)
 	// Close - should be called for each NewAITelemetry call. Will release resources acquired
 	Close(timeout int)
 	// Flush - forces the current queue to be sent
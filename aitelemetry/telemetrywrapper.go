@@ -2,8 +2,10 @@ package aitelemetry
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
@@ -11,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/processlock"
 	"github.com/Azure/azure-container-networking/store"
 	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
 )
 
 const (
@@ -26,16 +29,23 @@ const (
 	versionStr                       = "AppVersion"
 	azurePublicCloudStr              = "AzurePublicCloud"
 	hostNameKey                      = "hostname"
+	samplingPercentageStr            = "SamplingPercentage"
 	defaultTimeout                   = 10
 	defaultBatchIntervalInSecs       = 15
 	defaultBatchSizeInBytes          = 32768
 	defaultGetEnvRetryCount          = 5
 	defaultGetEnvRetryWaitTimeInSecs = 3
 	defaultRefreshTimeoutInSecs      = 10
+	defaultSamplingPercentage        = 100
 )
 
 var debugMode bool
 
+// randPercentage returns a random percentage in [0, 100) to sample items.
+var randPercentage = func() float64 {
+	return rand.Float64() * 100 //nolint:gosec // sampling doesn't need a secure random
+}
+
 func setAIConfigDefaults(config *AIConfig) {
 	if config.RefreshTimeout == 0 {
 		config.RefreshTimeout = defaultRefreshTimeoutInSecs
@@ -56,6 +66,48 @@ func setAIConfigDefaults(config *AIConfig) {
 	if config.GetEnvRetryWaitTimeInSecs == 0 {
 		config.GetEnvRetryWaitTimeInSecs = defaultGetEnvRetryWaitTimeInSecs
 	}
+
+	if config.SamplingPercentage == 0 {
+		config.SamplingPercentage = defaultSamplingPercentage
+	}
+}
+
+// aiSeverity returns the appinsights severity level, or def for the DefaultSeverity.
+func (s SeverityLevel) aiSeverity(def contracts.SeverityLevel) contracts.SeverityLevel {
+	switch s {
+	case Verbose:
+		return appinsights.Verbose
+	case Information:
+		return appinsights.Information
+	case Warning:
+		return appinsights.Warning
+	case Error:
+		return appinsights.Error
+	case Critical:
+		return appinsights.Critical
+	default:
+		return def
+	}
+}
+
+// sample returns whether an item with the sampling percentage override should be sent,
+// and the sampling percentage it was sent at.
+func (th *telemetryHandle) sample(override float64) (bool, float64) {
+	percentage := th.samplingPercentage
+	if override != 0 {
+		percentage = override
+	}
+	if percentage <= 0 || percentage >= defaultSamplingPercentage {
+		return percentage > 0, percentage
+	}
+	return randPercentage() < percentage, percentage
+}
+
+// setSamplingPercentage records the sampling percentage of items which are sampled, so that counts can be scaled back.
+func setSamplingPercentage(properties map[string]string, percentage float64) {
+	if percentage < defaultSamplingPercentage {
+		properties[samplingPercentageStr] = strconv.FormatFloat(percentage, 'f', -1, 64)
+	}
 }
 
 func messageListener() appinsights.DiagnosticsMessageListener {
@@ -188,6 +240,7 @@ func NewAITelemetry(
 		diagListener:                 messageListener(),
 		disableMetadataRefreshThread: aiConfig.DisableMetadataRefreshThread,
 		refreshTimeout:               aiConfig.RefreshTimeout,
+		samplingPercentage:           aiConfig.SamplingPercentage,
 	}
 
 	if th.disableMetadataRefreshThread {
@@ -202,8 +255,13 @@ func NewAITelemetry(
 // TrackLog function sends report (trace) to appinsights resource. It overrides few of the existing columns with app information
 // and for rest it uses custom dimesion
 func (th *telemetryHandle) TrackLog(report Report) {
+	send, samplingPercentage := th.sample(report.SamplingPercentage)
+	if !send {
+		return
+	}
+
 	// Initialize new trace message
-	trace := appinsights.NewTraceTelemetry(report.Message, appinsights.Warning)
+	trace := appinsights.NewTraceTelemetry(report.Message, report.Severity.aiSeverity(appinsights.Warning))
 
 	// will be empty if cns used as telemetry service for cni
 	if th.appVersion == "" {
@@ -223,6 +281,7 @@ func (th *telemetryHandle) TrackLog(report Report) {
 	}
 
 	trace.Properties[appNameStr] = th.appName
+	setSamplingPercentage(trace.Properties, samplingPercentage)
 
 	// Acquire read lock to read metadata
 	th.rwmutex.RLock()
@@ -249,6 +308,11 @@ func (th *telemetryHandle) TrackLog(report Report) {
 // TrackEvent function sends events to appinsights resource. It overrides a few of the existing columns
 // with app information.
 func (th *telemetryHandle) TrackEvent(event Event) {
+	send, samplingPercentage := th.sample(event.SamplingPercentage)
+	if !send {
+		return
+	}
+
 	// Initialize new event message
 	aiEvent := appinsights.NewEventTelemetry(event.EventName)
 	// OperationId => resourceID (e.g.: NCID)
@@ -261,6 +325,10 @@ func (th *telemetryHandle) TrackEvent(event Event) {
 		}
 	}
 
+	for key, value := range event.Measurements {
+		aiEvent.Measurements[key] = value
+	}
+
 	// Acquire read lock to read metadata
 	th.rwmutex.RLock()
 	metadata := th.metadata
@@ -286,9 +354,92 @@ func (th *telemetryHandle) TrackEvent(event Event) {
 	aiEvent.Properties[osStr] = runtime.GOOS
 	aiEvent.Properties[appNameStr] = th.appName
 	aiEvent.Properties[versionStr] = th.appVersion
+	setSamplingPercentage(aiEvent.Properties, samplingPercentage)
 	th.client.Track(aiEvent)
 }
 
+// TrackException function sends an exception with its callstack to appinsights resource. It overrides
+// a few of the existing columns with app information and for rest it uses custom dimension
+func (th *telemetryHandle) TrackException(exception Exception) {
+	send, samplingPercentage := th.sample(exception.SamplingPercentage)
+	if !send {
+		return
+	}
+
+	// Initialize new exception
+	aiException := appinsights.NewExceptionTelemetry(exception.Error)
+	aiException.Frames = exception.Stack
+	if len(aiException.Frames) == 0 {
+		// skip GetCallstack and TrackException, the callstack starts at the caller
+		aiException.Frames = appinsights.GetCallstack(2)
+	}
+	aiException.SeverityLevel = exception.Severity.aiSeverity(appinsights.Error)
+
+	// will be empty if cns used as telemetry service for cni
+	appVersion := th.appVersion
+	if appVersion == "" {
+		appVersion = exception.AppVersion
+	}
+
+	aiException.Tags.User().SetAuthUserId(runtime.GOOS)
+	aiException.Tags.Operation().SetId(exception.Context)
+	aiException.Tags.Operation().SetParentId(appVersion)
+	aiException.Tags.Application().SetVer(appVersion)
+	aiException.Properties[hostNameKey], _ = os.Hostname()
+
+	// copy app specified custom dimension
+	for key, value := range exception.CustomDimensions {
+		aiException.Properties[key] = value
+	}
+
+	// Acquire read lock to read metadata
+	th.rwmutex.RLock()
+	metadata := th.metadata
+	th.rwmutex.RUnlock()
+
+	// Check if metadata is populated
+	if metadata.SubscriptionID != "" {
+		aiException.Tags.User().SetAccountId(metadata.SubscriptionID)
+		aiException.Tags.User().SetId(metadata.VMName)
+		aiException.Tags.Session().SetId(metadata.VMID)
+		aiException.Properties[locationStr] = metadata.Location
+		aiException.Properties[resourceGroupStr] = metadata.ResourceGroupName
+		aiException.Properties[vmSizeStr] = metadata.VMSize
+		aiException.Properties[osVersionStr] = metadata.OSVersion
+		aiException.Properties[vmIDStr] = metadata.VMID
+	}
+
+	aiException.Properties[osStr] = runtime.GOOS
+	aiException.Properties[appNameStr] = th.appName
+	aiException.Properties[versionStr] = appVersion
+	setSamplingPercentage(aiException.Properties, samplingPercentage)
+	th.client.Track(aiException)
+}
+
+// TrackPanic recovers a panic and sends it as a Critical exception with the callstack of the panic, then
+// flushes the telemetry queue. If rethrow is set, it panics again with the recovered value.
+// It must be deferred directly, e.g. defer aitelemetry.TrackPanic(th, "cns", true)
+func TrackPanic(th TelemetryHandle, context string, rethrow bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if th != nil {
+		th.TrackException(Exception{
+			Error:    r,
+			Context:  context,
+			Severity: Critical,
+			// skip GetCallstack and TrackPanic, the callstack starts at the panic
+			Stack:              appinsights.GetCallstack(2),
+			SamplingPercentage: defaultSamplingPercentage,
+		})
+		th.Flush()
+	}
+	if rethrow {
+		panic(r)
+	}
+}
+
 // TrackMetric function sends metric to appinsights resource. It overrides few of the existing columns with app information
 // and for rest it uses custom dimesion
 func (th *telemetryHandle) TrackMetric(metric Metric) {
@@ -1,6 +1,7 @@
 package aitelemetry
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,6 +13,8 @@ import (
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -147,6 +150,17 @@ func TestTrackEvent(t *testing.T) {
 	th.TrackEvent(event)
 }
 
+func TestTrackException(t *testing.T) {
+	exception := Exception{
+		Error:            errors.New("test"),
+		Context:          "10a",
+		CustomDimensions: map[string]string{"dim1": "col1"},
+		Severity:         Critical,
+	}
+
+	th.TrackException(exception)
+}
+
 func TestFlush(t *testing.T) {
 	th.Flush()
 }
@@ -176,3 +190,130 @@ func TestClosewithoutSend(t *testing.T) {
 
 	thtest.Close(10)
 }
+
+type recordingChannel struct {
+	appinsights.TelemetryChannel
+	flushes int
+}
+
+func (c *recordingChannel) Flush() {
+	c.flushes++
+}
+
+type recordingClient struct {
+	appinsights.TelemetryClient
+	channel *recordingChannel
+	items   []appinsights.Telemetry
+}
+
+func (c *recordingClient) Track(item appinsights.Telemetry) {
+	c.items = append(c.items, item)
+}
+
+func (c *recordingClient) Channel() appinsights.TelemetryChannel {
+	return c.channel
+}
+
+func newRecordingHandle(samplingPercentage float64) (*telemetryHandle, *recordingClient) {
+	client := &recordingClient{channel: &recordingChannel{}}
+	return &telemetryHandle{
+		appName:            "testapp",
+		appVersion:         "v1.0.26",
+		client:             client,
+		samplingPercentage: samplingPercentage,
+	}, client
+}
+
+func TestSampling(t *testing.T) {
+	defer func(f func() float64) { randPercentage = f }(randPercentage)
+	randPercentage = func() float64 { return 30 }
+
+	tests := []struct {
+		name     string
+		config   float64
+		override float64
+		sent     bool
+		property string
+	}{
+		{name: "not sampled", config: 100, sent: true},
+		{name: "sampled in", config: 50, sent: true, property: "50"},
+		{name: "sampled out", config: 20, sent: false},
+		{name: "override samples in", config: 20, override: 40.5, sent: true, property: "40.5"},
+		{name: "override disables sampling", config: 20, override: 100, sent: true},
+		{name: "override drops", config: 100, override: -1, sent: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			th, client := newRecordingHandle(tt.config)
+			th.TrackLog(Report{Message: "test", SamplingPercentage: tt.override})
+			th.TrackEvent(Event{EventName: "test", SamplingPercentage: tt.override})
+			th.TrackException(Exception{Error: "test", SamplingPercentage: tt.override})
+			if !tt.sent {
+				require.Empty(t, client.items)
+				return
+			}
+			require.Len(t, client.items, 3)
+			for _, item := range client.items {
+				property, ok := item.GetProperties()[samplingPercentageStr]
+				require.Equal(t, tt.property != "", ok)
+				require.Equal(t, tt.property, property)
+			}
+		})
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	th, client := newRecordingHandle(100)
+	th.TrackLog(Report{Message: "default"})
+	th.TrackLog(Report{Message: "info", Severity: Information})
+	th.TrackException(Exception{Error: "default"})
+	th.TrackException(Exception{Error: "critical", Severity: Critical})
+
+	require.Len(t, client.items, 4)
+	require.Equal(t, appinsights.Warning, client.items[0].(*appinsights.TraceTelemetry).SeverityLevel)
+	require.Equal(t, appinsights.Information, client.items[1].(*appinsights.TraceTelemetry).SeverityLevel)
+	require.Equal(t, appinsights.Error, client.items[2].(*appinsights.ExceptionTelemetry).SeverityLevel)
+	require.Equal(t, appinsights.Critical, client.items[3].(*appinsights.ExceptionTelemetry).SeverityLevel)
+}
+
+func TestTrackExceptionCallstack(t *testing.T) {
+	th, client := newRecordingHandle(100)
+	th.TrackException(Exception{Error: errors.New("test"), Context: "10a", CustomDimensions: map[string]string{"dim1": "col1"}})
+
+	require.Len(t, client.items, 1)
+	exception := client.items[0].(*appinsights.ExceptionTelemetry)
+	require.Equal(t, "col1", exception.Properties["dim1"])
+	require.Equal(t, "testapp", exception.Properties[appNameStr])
+	require.Equal(t, "10a", exception.Tags.Operation().GetId())
+	require.NotEmpty(t, exception.Frames)
+	require.Equal(t, "TestTrackExceptionCallstack", exception.Frames[0].Method)
+}
+
+func TestTrackPanic(t *testing.T) {
+	th, client := newRecordingHandle(1)
+
+	func() {
+		defer TrackPanic(th, "10a", false)
+		panic("test panic")
+	}()
+
+	require.Len(t, client.items, 1)
+	require.Equal(t, 1, client.channel.flushes)
+	exception := client.items[0].(*appinsights.ExceptionTelemetry)
+	require.Equal(t, "test panic", exception.Error)
+	require.Equal(t, appinsights.Critical, exception.SeverityLevel)
+	require.Equal(t, "10a", exception.Tags.Operation().GetId())
+
+	require.PanicsWithValue(t, "rethrown", func() {
+		defer TrackPanic(th, "10a", true)
+		panic("rethrown")
+	})
+	require.Len(t, client.items, 2)
+
+	// no panic to recover
+	func() {
+		defer TrackPanic(th, "10a", true)
+	}()
+	require.Len(t, client.items, 2)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/Azure/azure-container-networking/azure-ipam/internal/buildinfo"
 	"github.com/Azure/azure-container-networking/azure-ipam/ipconfig"
 	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
@@ -30,6 +31,8 @@ type IPAMPlugin struct {
 type cnsClient interface {
 	RequestIPAddress(context.Context, cns.IPConfigRequest) (*cns.IPConfigResponse, error)
 	ReleaseIPAddress(context.Context, cns.IPConfigRequest) error
+	RequestIPs(context.Context, cns.IPConfigsRequest) (*cns.IPConfigsResponse, error)
+	ReleaseIPs(context.Context, cns.IPConfigsRequest) error
 }
 
 // NewPlugin constructs a new IPAM plugin instance with given logger and CNS client
@@ -61,41 +64,50 @@ func (p *IPAMPlugin) CmdAdd(args *cniSkel.CmdArgs) error {
 	}
 	p.logger.Debug("Parsed network config", zap.Any("netconf", nwCfg))
 
-	// Create ip config request from args
-	req, err := ipconfig.CreateIPConfigReq(args)
+	// Create ip configs request from args
+	req, err := ipconfig.CreateIPConfigsReq(args)
 	if err != nil {
-		p.logger.Error("Failed to create CNS IP config request", zap.Error(err))
-		return cniTypes.NewError(ErrCreateIPConfigRequest, err.Error(), "failed to create CNS IP config request")
+		p.logger.Error("Failed to create CNS IP configs request", zap.Error(err))
+		return cniTypes.NewError(ErrCreateIPConfigRequest, err.Error(), "failed to create CNS IP configs request")
 	}
-	p.logger.Debug("Created CNS IP config request", zap.Any("request", req))
+	p.logger.Debug("Created CNS IP configs request", zap.Any("request", req))
 
 	p.logger.Debug("Making request to CNS")
 	// if this fails, the caller plugin should execute again with cmdDel before returning error.
 	// https://www.cni.dev/docs/spec/#delegated-plugin-execution-procedure
-	resp, err := p.cnsClient.RequestIPAddress(context.TODO(), req)
+	resp, err := p.requestIPs(req)
 	if err != nil {
-		p.logger.Error("Failed to request IP address from CNS", zap.Error(err), zap.Any("request", req))
-		return cniTypes.NewError(ErrRequestIPConfigFromCNS, err.Error(), "failed to request IP address from CNS")
+		p.logger.Error("Failed to request IP addresses from CNS", zap.Error(err), zap.Any("request", req))
+		return cniTypes.NewError(ErrRequestIPConfigFromCNS, err.Error(), "failed to request IP addresses from CNS")
 	}
-	p.logger.Debug("Received CNS IP config response", zap.Any("response", resp))
+	p.logger.Debug("Received CNS IP configs response", zap.Any("response", resp))
 
-	// Get Pod IP and gateway IP from ip config response
-	podIPNet, err := ipconfig.ProcessIPConfigResp(resp)
+	// Get Pod IPs of all IP families from ip configs response
+	podIPNets, err := ipconfig.ProcessIPConfigsResp(resp)
 	if err != nil {
-		p.logger.Error("Failed to interpret CNS IPConfigResponse", zap.Error(err), zap.Any("response", resp))
-		return cniTypes.NewError(ErrProcessIPConfigResponse, err.Error(), "failed to interpret CNS IPConfigResponse")
+		p.logger.Error("Failed to interpret CNS IPConfigsResponse", zap.Error(err), zap.Any("response", resp))
+		// roll back the IPs of all families, so that a pod with a missing family doesn't hold the others
+		if releaseErr := p.releaseIPs(req); releaseErr != nil {
+			p.logger.Error("Failed to roll back IP addresses in CNS", zap.Error(releaseErr), zap.Any("request", req))
+		}
+		return cniTypes.NewError(ErrProcessIPConfigResponse, err.Error(), "failed to interpret CNS IPConfigsResponse")
 	}
-	p.logger.Debug("Parsed pod IP", zap.String("podIPNet", podIPNet.String()))
 
 	cniResult := &types100.Result{
-		IPs: []*types100.IPConfig{
-			{
-				Address: net.IPNet{
-					IP:   net.ParseIP(podIPNet.Addr().String()),
-					Mask: net.CIDRMask(podIPNet.Bits(), 32), // nolint
-				},
+		IPs: make([]*types100.IPConfig, 0, len(podIPNets)),
+	}
+	for _, podIPNet := range podIPNets {
+		p.logger.Debug("Parsed pod IP", zap.String("podIPNet", podIPNet.String()))
+		bits := 128 // nolint
+		if podIPNet.Addr().Is4() {
+			bits = 32
+		}
+		cniResult.IPs = append(cniResult.IPs, &types100.IPConfig{
+			Address: net.IPNet{
+				IP:   net.ParseIP(podIPNet.Addr().String()),
+				Mask: net.CIDRMask(podIPNet.Bits(), bits),
 			},
-		},
+		})
 	}
 
 	// Get versioned result
@@ -121,19 +133,19 @@ func (p *IPAMPlugin) CmdAdd(args *cniSkel.CmdArgs) error {
 func (p *IPAMPlugin) CmdDel(args *cniSkel.CmdArgs) error {
 	p.logger.Info("DEL called", zap.Any("args", args))
 
-	// Create ip config request from args
-	req, err := ipconfig.CreateIPConfigReq(args)
+	// Create ip configs request from args
+	req, err := ipconfig.CreateIPConfigsReq(args)
 	if err != nil {
-		p.logger.Error("Failed to create CNS IP config request", zap.Error(err))
-		return cniTypes.NewError(cniTypes.ErrTryAgainLater, err.Error(), "failed to create CNS IP config request")
+		p.logger.Error("Failed to create CNS IP configs request", zap.Error(err))
+		return cniTypes.NewError(cniTypes.ErrTryAgainLater, err.Error(), "failed to create CNS IP configs request")
 	}
-	p.logger.Debug("Created CNS IP config request", zap.Any("request", req))
+	p.logger.Debug("Created CNS IP configs request", zap.Any("request", req))
 
 	p.logger.Debug("Making request to CNS")
 	// cnsClient enforces it own timeout
-	if err := p.cnsClient.ReleaseIPAddress(context.TODO(), req); err != nil {
-		p.logger.Error("Failed to release IP address from CNS", zap.Error(err), zap.Any("request", req))
-		return cniTypes.NewError(cniTypes.ErrTryAgainLater, err.Error(), "failed to release IP address from CNS")
+	if err := p.releaseIPs(req); err != nil {
+		p.logger.Error("Failed to release IP addresses from CNS", zap.Error(err), zap.Any("request", req))
+		return cniTypes.NewError(cniTypes.ErrTryAgainLater, err.Error(), "failed to release IP addresses from CNS")
 	}
 
 	p.logger.Info("DEL success")
@@ -147,6 +159,45 @@ func (p *IPAMPlugin) CmdCheck(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// requestIPs requests the IPs of all IP families of the pod in one CNS call.
+// CNS versions without RequestIPs only give the pod a single IP, which is requested with RequestIPAddress.
+func (p *IPAMPlugin) requestIPs(req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+	resp, err := p.cnsClient.RequestIPs(context.TODO(), req)
+	if err == nil {
+		return resp, nil
+	}
+	if !cnscli.IsUnsupportedAPI(err) {
+		return nil, errors.Wrap(err, "failed to request IPs")
+	}
+
+	p.logger.Info("RequestIPs not supported by CNS, falling back to RequestIPAddress")
+	singleResp, err := p.cnsClient.RequestIPAddress(context.TODO(), ipconfig.IPConfigReq(req))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request IP address")
+	}
+	return &cns.IPConfigsResponse{
+		PodIPInfo: []cns.PodIpInfo{singleResp.PodIpInfo},
+		Response:  singleResp.Response,
+	}, nil
+}
+
+// releaseIPs releases the IPs of all IP families of the pod, with ReleaseIPAddress for CNS versions without ReleaseIPs.
+func (p *IPAMPlugin) releaseIPs(req cns.IPConfigsRequest) error {
+	err := p.cnsClient.ReleaseIPs(context.TODO(), req)
+	if err == nil {
+		return nil
+	}
+	if !cnscli.IsUnsupportedAPI(err) {
+		return errors.Wrap(err, "failed to release IPs")
+	}
+
+	p.logger.Info("ReleaseIPs not supported by CNS, falling back to ReleaseIPAddress")
+	if err := p.cnsClient.ReleaseIPAddress(context.TODO(), ipconfig.IPConfigReq(req)); err != nil {
+		return errors.Wrap(err, "failed to release IP address")
+	}
+	return nil
+}
+
 // Parse network config from given byte array
 func parseNetConf(b []byte) (*cniTypes.NetConf, error) {
 	netConf := &cniTypes.NetConf{}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/Azure/azure-container-networking/azure-ipam/logger"
 	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
@@ -17,12 +19,16 @@ import (
 )
 
 var (
-	errFoo                   = errors.New("err")
-	loggerCfg *logger.Config = &logger.Config{}
+	errFoo                           = errors.New("err")
+	errUnsupportedAPI                = &cnscli.CNSClientError{Code: types.UnsupportedAPI, Err: errors.New("unsupported API")}
+	loggerCfg         *logger.Config = &logger.Config{}
 )
 
 // MOckCNSClient is a mock implementation of the CNSClient interface
-type MockCNSClient struct{}
+type MockCNSClient struct {
+	// released are the InfraContainerIDs whose IPs were released
+	released []string
+}
 
 func (c *MockCNSClient) RequestIPAddress(ctx context.Context, ipconfig cns.IPConfigRequest) (*cns.IPConfigResponse, error) {
 	switch ipconfig.InfraContainerID {
@@ -90,10 +96,66 @@ func (c *MockCNSClient) ReleaseIPAddress(ctx context.Context, ipconfig cns.IPCon
 	case "failRequestCNSReleaseIPArgs":
 		return errFoo
 	default:
+		c.released = append(c.released, ipconfig.InfraContainerID)
+		return nil
+	}
+}
+
+func (c *MockCNSClient) RequestIPs(ctx context.Context, ipconfig cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+	switch ipconfig.InfraContainerID {
+	case "failRequestCNSArgs":
+		return nil, errFoo
+	case "unsupportedRequestIPsArgs":
+		return nil, errUnsupportedAPI
+	case "failProcessCNSResp":
+		return &cns.IPConfigsResponse{
+			PodIPInfo: []cns.PodIpInfo{
+				testPodIPInfo("10.0.1.10", 24),
+				testPodIPInfo("fd00::1::10", 64), // invalid ip address
+			},
+		}, nil
+	case "happyDualStackArgs":
+		return &cns.IPConfigsResponse{
+			PodIPInfo: []cns.PodIpInfo{
+				testPodIPInfo("10.0.1.10", 24),
+				testPodIPInfo("fd00::10", 64),
+			},
+		}, nil
+	default:
+		return &cns.IPConfigsResponse{
+			PodIPInfo: []cns.PodIpInfo{
+				testPodIPInfo("10.0.1.10", 24),
+			},
+		}, nil
+	}
+}
+
+func (c *MockCNSClient) ReleaseIPs(ctx context.Context, ipconfig cns.IPConfigsRequest) error {
+	switch ipconfig.InfraContainerID {
+	case "failRequestCNSReleaseIPArgs":
+		return errFoo
+	case "unsupportedReleaseIPsArgs":
+		return errUnsupportedAPI
+	default:
+		c.released = append(c.released, ipconfig.InfraContainerID)
 		return nil
 	}
 }
 
+func testPodIPInfo(ip string, prefixLength uint8) cns.PodIpInfo {
+	return cns.PodIpInfo{
+		PodIPConfig: cns.IPSubnet{
+			IPAddress:    ip,
+			PrefixLength: prefixLength,
+		},
+		NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+			IPSubnet: cns.IPSubnet{
+				PrefixLength: prefixLength,
+			},
+		},
+	}
+}
+
 // cniResultsWriter is a helper struct to write CNI results to a byte array
 type cniResultsWriter struct {
 	result *types100.Result
@@ -112,10 +174,11 @@ const (
 )
 
 type scenario struct {
-	name    string
-	args    *cniSkel.CmdArgs
-	want    *types100.Result
-	wantErr bool
+	name         string
+	args         *cniSkel.CmdArgs
+	want         *types100.Result
+	wantErr      bool
+	wantReleased []string
 }
 
 // build args for tests
@@ -168,15 +231,56 @@ func TestCmdAdd(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Happy dual-stack CNI add",
+			args: buildArgs("happyDualStackArgs", happyPodArgs, happyNetConfByteArr),
+			want: &types100.Result{
+				CNIVersion: "1.0.0",
+				IPs: []*types100.IPConfig{
+					{
+						Address: net.IPNet{
+							IP:   net.IPv4(10, 0, 1, 10),
+							Mask: net.CIDRMask(24, 32),
+						},
+					},
+					{
+						Address: net.IPNet{
+							IP:   net.ParseIP("fd00::10"),
+							Mask: net.CIDRMask(64, 128),
+						},
+					},
+				},
+				DNS: cniTypes.DNS{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "CNI add with CNS without RequestIPs",
+			args: buildArgs("unsupportedRequestIPsArgs", happyPodArgs, happyNetConfByteArr),
+			want: &types100.Result{
+				CNIVersion: "1.0.0",
+				IPs: []*types100.IPConfig{
+					{
+						Address: net.IPNet{
+							IP:   net.IPv4(10, 0, 1, 10),
+							Mask: net.CIDRMask(24, 32),
+						},
+					},
+				},
+				DNS: cniTypes.DNS{},
+			},
+			wantErr: false,
+		},
 		{
 			name:    "Fail request CNS ipconfig during CmdAdd",
 			args:    buildArgs("failRequestCNSArgs", happyPodArgs, happyNetConfByteArr),
 			wantErr: true,
 		},
 		{
-			name:    "Fail process CNS response during CmdAdd",
-			args:    buildArgs("failProcessCNSResp", happyPodArgs, happyNetConfByteArr),
-			wantErr: true,
+			name:         "Fail process CNS response during CmdAdd",
+			args:         buildArgs("failProcessCNSResp", happyPodArgs, happyNetConfByteArr),
+			wantErr:      true,
+			wantReleased: []string{"failProcessCNSResp"},
 		},
 		{
 			name:    "Fail parse netconf during CmdAdd",
@@ -210,6 +314,7 @@ func TestCmdAdd(t *testing.T) {
 			if tt.want != nil {
 				require.Equal(t, tt.want, writer.result)
 			}
+			require.Equal(t, tt.wantReleased, mockCNSClient.released)
 		})
 	}
 }
@@ -231,6 +336,11 @@ func TestCmdDel(t *testing.T) {
 			args:    buildArgs("happyArgs", happyPodArgs, happyNetConfByteArr),
 			wantErr: false,
 		},
+		{
+			name:    "CNI del with CNS without ReleaseIPs",
+			args:    buildArgs("unsupportedReleaseIPsArgs", happyPodArgs, happyNetConfByteArr),
+			wantErr: false,
+		},
 		{
 			name:    "Fail request CNS release IP during CmdDel",
 			args:    buildArgs("failRequestCNSReleaseIPArgs", happyPodArgs, happyNetConfByteArr),
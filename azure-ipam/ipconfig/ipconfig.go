@@ -11,11 +11,11 @@ import (
 	"github.com/pkg/errors"
 )
 
-// CreateIPConfigReq creates an IPConfigRequest from the given CNI args.
-func CreateIPConfigReq(args *cniSkel.CmdArgs) (cns.IPConfigRequest, error) {
+// CreateIPConfigsReq creates an IPConfigsRequest from the given CNI args.
+func CreateIPConfigsReq(args *cniSkel.CmdArgs) (cns.IPConfigsRequest, error) {
 	podConf, err := parsePodConf(args.Args)
 	if err != nil {
-		return cns.IPConfigRequest{}, errors.Wrapf(err, "failed to parse pod config from CNI args")
+		return cns.IPConfigsRequest{}, errors.Wrapf(err, "failed to parse pod config from CNI args")
 	}
 
 	podInfo := cns.KubernetesPodInfo{
@@ -25,10 +25,10 @@ func CreateIPConfigReq(args *cniSkel.CmdArgs) (cns.IPConfigRequest, error) {
 
 	orchestratorContext, err := json.Marshal(podInfo)
 	if err != nil {
-		return cns.IPConfigRequest{}, errors.Wrapf(err, "failed to marshal podInfo to JSON")
+		return cns.IPConfigsRequest{}, errors.Wrapf(err, "failed to marshal podInfo to JSON")
 	}
 
-	req := cns.IPConfigRequest{
+	req := cns.IPConfigsRequest{
 		PodInterfaceID:      args.ContainerID,
 		InfraContainerID:    args.ContainerID,
 		OrchestratorContext: orchestratorContext,
@@ -38,19 +38,38 @@ func CreateIPConfigReq(args *cniSkel.CmdArgs) (cns.IPConfigRequest, error) {
 	return req, nil
 }
 
-// ProcessIPConfigResp processes the IPConfigResponse from the CNS.
-func ProcessIPConfigResp(resp *cns.IPConfigResponse) (*netip.Prefix, error) {
-	podCIDR := fmt.Sprintf(
-		"%s/%d",
-		resp.PodIpInfo.PodIPConfig.IPAddress,
-		resp.PodIpInfo.NetworkContainerPrimaryIPConfig.IPSubnet.PrefixLength,
-	)
-	podIPNet, err := netip.ParsePrefix(podCIDR)
-	if err != nil {
-		return nil, errors.Wrapf(err, "cns returned invalid pod CIDR %q", podCIDR)
+// IPConfigReq returns the single IP IPConfigRequest for the IPConfigsRequest, for CNS versions without RequestIPs.
+func IPConfigReq(req cns.IPConfigsRequest) cns.IPConfigRequest {
+	return cns.IPConfigRequest{
+		PodInterfaceID:      req.PodInterfaceID,
+		InfraContainerID:    req.InfraContainerID,
+		OrchestratorContext: req.OrchestratorContext,
+		Ifname:              req.Ifname,
+	}
+}
+
+// ProcessIPConfigsResp processes the IPConfigsResponse from the CNS.
+// It returns the pod IP of every IP family, and fails if any of them is invalid.
+func ProcessIPConfigsResp(resp *cns.IPConfigsResponse) ([]netip.Prefix, error) {
+	if len(resp.PodIPInfo) == 0 {
+		return nil, errors.New("cns returned no pod IPs")
+	}
+
+	podIPNets := make([]netip.Prefix, 0, len(resp.PodIPInfo))
+	for i := range resp.PodIPInfo {
+		podCIDR := fmt.Sprintf(
+			"%s/%d",
+			resp.PodIPInfo[i].PodIPConfig.IPAddress,
+			resp.PodIPInfo[i].NetworkContainerPrimaryIPConfig.IPSubnet.PrefixLength,
+		)
+		podIPNet, err := netip.ParsePrefix(podCIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cns returned invalid pod CIDR %q", podCIDR)
+		}
+		podIPNets = append(podIPNets, podIPNet)
 	}
 
-	return &podIPNet, nil
+	return podIPNets, nil
 }
 
 type k8sPodEnvArgs struct {
@@ -2,8 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 )
@@ -18,6 +23,8 @@ type PodNetworkInterfaceInfo struct {
 
 type AzureCNIState struct {
 	ContainerInterfaces map[string]PodNetworkInterfaceInfo
+	// FailureRecords are the last failures of CNI commands, by container ID.
+	FailureRecords map[string]FailureRecord `json:",omitempty"`
 }
 
 func (a *AzureCNIState) PrintResult() error {
@@ -35,3 +42,180 @@ func (a *AzureCNIState) PrintResult() error {
 
 	return nil
 }
+
+// Keys of CNI_ARGS which filter the endpoints of GET_ENDPOINT_STATE.
+const (
+	FilterArgPodNamespace = "K8S_POD_NAMESPACE"
+	FilterArgPodName      = "K8S_POD_NAME"
+	FilterArgNetwork      = "NETWORK"
+	FilterArgIP           = "IP"
+
+	// DefaultNetwork is the network whose endpoints are returned when no network is given.
+	DefaultNetwork = "azure"
+)
+
+var (
+	ErrInvalidFilterArg = errors.New("invalid endpoint state filter argument")
+	ErrUnknownFilterArg = errors.New("unknown endpoint state filter argument")
+)
+
+// EndpointStateFilter selects the endpoints returned by GET_ENDPOINT_STATE.
+// Empty fields match all endpoints.
+type EndpointStateFilter struct {
+	PodNamespace string
+	PodName      string
+	// Network is the network of the endpoints, DefaultNetwork if empty.
+	Network string
+	// IP matches the endpoints which have the address, in any IP family.
+	IP net.IP
+}
+
+// ParseEndpointStateFilter parses a filter from CNI_ARGS, e.g. "K8S_POD_NAMESPACE=default;K8S_POD_NAME=nginx".
+func ParseEndpointStateFilter(args string) (EndpointStateFilter, error) {
+	var f EndpointStateFilter
+	for _, pair := range strings.Split(args, ";") {
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return f, fmt.Errorf("%w: %q", ErrInvalidFilterArg, pair)
+		}
+		switch key {
+		case FilterArgPodNamespace:
+			f.PodNamespace = value
+		case FilterArgPodName:
+			f.PodName = value
+		case FilterArgNetwork:
+			f.Network = value
+		case FilterArgIP:
+			if f.IP = net.ParseIP(value); f.IP == nil {
+				return f, fmt.Errorf("%w: %q is not an IP address", ErrInvalidFilterArg, value)
+			}
+		default:
+			return f, fmt.Errorf("%w: %q", ErrUnknownFilterArg, key)
+		}
+	}
+	return f, nil
+}
+
+// Args formats the filter as CNI_ARGS, the inverse of ParseEndpointStateFilter.
+func (f EndpointStateFilter) Args() string {
+	var pairs []string
+	if f.PodNamespace != "" {
+		pairs = append(pairs, FilterArgPodNamespace+"="+f.PodNamespace)
+	}
+	if f.PodName != "" {
+		pairs = append(pairs, FilterArgPodName+"="+f.PodName)
+	}
+	if f.Network != "" {
+		pairs = append(pairs, FilterArgNetwork+"="+f.Network)
+	}
+	if f.IP != nil {
+		pairs = append(pairs, FilterArgIP+"="+f.IP.String())
+	}
+	return strings.Join(pairs, ";")
+}
+
+// NetworkOrDefault returns the network of the filter, or DefaultNetwork.
+func (f EndpointStateFilter) NetworkOrDefault() string {
+	if f.Network == "" {
+		return DefaultNetwork
+	}
+	return f.Network
+}
+
+// Matches returns true if the endpoint matches the pod and IP of the filter.
+// The network is matched when getting the endpoints.
+func (f EndpointStateFilter) Matches(info PodNetworkInterfaceInfo) bool {
+	if f.PodNamespace != "" && f.PodNamespace != info.PodNamespace {
+		return false
+	}
+	if f.PodName != "" && f.PodName != info.PodName {
+		return false
+	}
+	if f.IP == nil {
+		return true
+	}
+	for i := range info.IPAddresses {
+		if info.IPAddresses[i].IP.Equal(f.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the state of the endpoints which match the filter.
+func (a *AzureCNIState) Filter(f EndpointStateFilter) *AzureCNIState {
+	filtered := &AzureCNIState{
+		ContainerInterfaces: make(map[string]PodNetworkInterfaceInfo),
+	}
+	for id, info := range a.ContainerInterfaces {
+		if f.Matches(info) {
+			filtered.ContainerInterfaces[id] = info
+		}
+	}
+	// failed commands may not have an IP, so failures only match filters on the pod.
+	if f.IP != nil {
+		return filtered
+	}
+	for id := range a.FailureRecords {
+		record := a.FailureRecords[id]
+		if f.Matches(PodNetworkInterfaceInfo{PodName: record.PodName, PodNamespace: record.PodNamespace}) {
+			if filtered.FailureRecords == nil {
+				filtered.FailureRecords = make(map[string]FailureRecord)
+			}
+			filtered.FailureRecords[id] = record
+		}
+	}
+	return filtered
+}
+
+// EndpointStateEventType is the kind of change of an endpoint.
+type EndpointStateEventType string
+
+const (
+	EndpointAdded    EndpointStateEventType = "ADDED"
+	EndpointModified EndpointStateEventType = "MODIFIED"
+	EndpointDeleted  EndpointStateEventType = "DELETED"
+)
+
+// EndpointStateEvent is a change of the state of an endpoint, streamed as a JSON line by GET_ENDPOINT_STATE in watch mode.
+type EndpointStateEvent struct {
+	Type       EndpointStateEventType
+	EndpointID string
+	// Endpoint is the new state of the endpoint, or its last state if it was deleted.
+	Endpoint PodNetworkInterfaceInfo
+}
+
+// DiffEndpointState returns the changes from the old state to the new state, ordered by endpoint ID.
+// A nil old state is empty, so every endpoint of the new state is added.
+func DiffEndpointState(oldState, newState *AzureCNIState) []EndpointStateEvent {
+	var oldEndpoints, newEndpoints map[string]PodNetworkInterfaceInfo
+	if oldState != nil {
+		oldEndpoints = oldState.ContainerInterfaces
+	}
+	if newState != nil {
+		newEndpoints = newState.ContainerInterfaces
+	}
+
+	var events []EndpointStateEvent
+	for id, info := range newEndpoints {
+		oldInfo, ok := oldEndpoints[id]
+		switch {
+		case !ok:
+			events = append(events, EndpointStateEvent{Type: EndpointAdded, EndpointID: id, Endpoint: info})
+		case !reflect.DeepEqual(oldInfo, info):
+			events = append(events, EndpointStateEvent{Type: EndpointModified, EndpointID: id, Endpoint: info})
+		}
+	}
+	for id, info := range oldEndpoints {
+		if _, ok := newEndpoints[id]; !ok {
+			events = append(events, EndpointStateEvent{Type: EndpointDeleted, EndpointID: id, Endpoint: info})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EndpointID < events[j].EndpointID
+	})
+	return events
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpointStateFilter(t *testing.T) {
+	f, err := ParseEndpointStateFilter("K8S_POD_NAMESPACE=default;K8S_POD_NAME=nginx;NETWORK=azure-l1vh;IP=10.0.0.4")
+	require.NoError(t, err)
+	require.Equal(t, EndpointStateFilter{
+		PodNamespace: "default",
+		PodName:      "nginx",
+		Network:      "azure-l1vh",
+		IP:           net.ParseIP("10.0.0.4"),
+	}, f)
+	require.Equal(t, "K8S_POD_NAMESPACE=default;K8S_POD_NAME=nginx;NETWORK=azure-l1vh;IP=10.0.0.4", f.Args())
+
+	f, err = ParseEndpointStateFilter("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultNetwork, f.NetworkOrDefault())
+
+	_, err = ParseEndpointStateFilter("IP=10.0.0")
+	require.ErrorIs(t, err, ErrInvalidFilterArg)
+	_, err = ParseEndpointStateFilter("K8S_POD_NAME")
+	require.ErrorIs(t, err, ErrInvalidFilterArg)
+	_, err = ParseEndpointStateFilter("K8S_POD_UID=1234")
+	require.ErrorIs(t, err, ErrUnknownFilterArg)
+}
+
+func TestFilter(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.0.0.4/24")
+	v4.IP = net.ParseIP("10.0.0.4")
+	_, v6, _ := net.ParseCIDR("fd00::4/64")
+	v6.IP = net.ParseIP("fd00::4")
+	state := &AzureCNIState{
+		ContainerInterfaces: map[string]PodNetworkInterfaceInfo{
+			"ep1": {PodName: "nginx", PodNamespace: "default", IPAddresses: []net.IPNet{*v4, *v6}},
+			"ep2": {PodName: "nginx", PodNamespace: "kube-system"},
+			"ep3": {PodName: "coredns", PodNamespace: "kube-system"},
+		},
+		FailureRecords: map[string]FailureRecord{
+			"c1": {PodName: "nginx", PodNamespace: "default"},
+			"c2": {PodName: "coredns", PodNamespace: "kube-system"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		filter       EndpointStateFilter
+		want         []string
+		wantFailures []string
+	}{
+		{name: "no filter", want: []string{"ep1", "ep2", "ep3"}, wantFailures: []string{"c1", "c2"}},
+		{name: "namespace", filter: EndpointStateFilter{PodNamespace: "kube-system"}, want: []string{"ep2", "ep3"}, wantFailures: []string{"c2"}},
+		{name: "pod", filter: EndpointStateFilter{PodNamespace: "kube-system", PodName: "nginx"}, want: []string{"ep2"}},
+		{name: "ipv6", filter: EndpointStateFilter{IP: net.ParseIP("fd00::4")}, want: []string{"ep1"}},
+		{name: "no match", filter: EndpointStateFilter{IP: net.ParseIP("10.0.0.5")}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := state.Filter(tt.filter)
+			got := []string{}
+			for id := range filtered.ContainerInterfaces {
+				got = append(got, id)
+			}
+			require.ElementsMatch(t, tt.want, got)
+			gotFailures := []string{}
+			for id := range filtered.FailureRecords {
+				gotFailures = append(gotFailures, id)
+			}
+			require.ElementsMatch(t, tt.wantFailures, gotFailures)
+		})
+	}
+}
+
+func TestDiffEndpointState(t *testing.T) {
+	oldState := &AzureCNIState{
+		ContainerInterfaces: map[string]PodNetworkInterfaceInfo{
+			"ep1": {PodName: "a", ContainerID: "c1"},
+			"ep2": {PodName: "b", ContainerID: "c2"},
+			"ep3": {PodName: "c", ContainerID: "c3"},
+		},
+	}
+	newState := &AzureCNIState{
+		ContainerInterfaces: map[string]PodNetworkInterfaceInfo{
+			"ep1": {PodName: "a", ContainerID: "c1"},
+			"ep2": {PodName: "b", ContainerID: "c2-restarted"},
+			"ep4": {PodName: "d", ContainerID: "c4"},
+		},
+	}
+
+	require.Equal(t, []EndpointStateEvent{
+		{Type: EndpointModified, EndpointID: "ep2", Endpoint: newState.ContainerInterfaces["ep2"]},
+		{Type: EndpointDeleted, EndpointID: "ep3", Endpoint: oldState.ContainerInterfaces["ep3"]},
+		{Type: EndpointAdded, EndpointID: "ep4", Endpoint: newState.ContainerInterfaces["ep4"]},
+	}, DiffEndpointState(oldState, newState))
+
+	require.Len(t, DiffEndpointState(nil, oldState), 3)
+	require.Empty(t, DiffEndpointState(oldState, oldState))
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FailureRecord is a snapshot of the environment of a failed CNI command, kept in the state of the plugin
+// and returned by GET_ENDPOINT_STATE, so that failures can be investigated without searching the logs.
+type FailureRecord struct {
+	Command      string
+	ContainerID  string
+	PodName      string `json:",omitempty"`
+	PodNamespace string `json:",omitempty"`
+	Timestamp    time.Time
+	Error        string
+	// Env are the CNI_ environment variables of the command.
+	Env map[string]string
+	// Config is the network configuration from stdin, with the values of sensitive fields redacted.
+	Config json.RawMessage `json:",omitempty"`
+	// Versions are the versions of the kernel and of the dataplane components of the node.
+	Versions map[string]string `json:",omitempty"`
+	// LogLines are the last lines logged by the command, or logged with its container ID by earlier commands.
+	LogLines []string `json:",omitempty"`
+}
+
+// CrashReport is a snapshot of the environment of a CNI command which panicked, written to a file of its own
+// since the state of the plugin may not be usable after the panic.
+type CrashReport struct {
+	Command   string
+	Version   string
+	Timestamp time.Time
+	Panic     string
+	Stack     string
+	// Env are the CNI_ environment variables of the command.
+	Env map[string]string
+	// Config is the network configuration from stdin, with the values of sensitive fields redacted.
+	Config json.RawMessage `json:",omitempty"`
+	// LogLines are the last lines logged by the command.
+	LogLines []string `json:",omitempty"`
+}
+
+// InvocationStatus is the progress of an in-progress CNI command, written to a file of its own so that it can be read
+// while the command holds the lock of the plugin state.
+type InvocationStatus struct {
+	PID           int
+	Command       string
+	ContainerID   string `json:",omitempty"`
+	PodName       string `json:",omitempty"`
+	PodNamespace  string `json:",omitempty"`
+	CorrelationID string `json:",omitempty"`
+	StartTime     time.Time
+	// Phase is the phase the command is in, see the telemetry phases, or empty between phases.
+	Phase          string `json:",omitempty"`
+	PhaseStartTime time.Time
+	// PhaseDurationsMs is the time spent in each completed phase of the command.
+	PhaseDurationsMs map[string]int64 `json:",omitempty"`
+}
@@ -20,19 +20,33 @@ const (
 	CmdUpdate = "UPDATE"
 	// CmdVersion - CNI VERSION command.
 	CmdVersion = "VERSION"
+	// CmdGC - CNI GC command.
+	CmdGC = "GC"
+	// CmdStatus - CNI STATUS command.
+	CmdStatus = "STATUS"
 
 	// nonstandard CNI spec command, used to dump CNI state to stdout
 	CmdGetEndpointsState = "GET_ENDPOINT_STATE"
+	// nonstandard IPAM plugin command, used to dump the stale addresses in use to stdout
+	CmdGetStaleAddresses = "GET_STALE_ADDRESSES"
+	// nonstandard IPAM plugin command, used to dump, and reclaim, the addresses in use by containers which no longer exist
+	CmdGetLeakedAddresses = "GET_LEAKED_ADDRESSES"
 
 	// CNI errors.
 	ErrRuntime = 100
+	// ErrRequestedIPUnavailable is returned when the static IP requested for the pod isn't in the address pool,
+	// or is in use by another pod.
+	ErrRequestedIPUnavailable = 112
+	// ErrPluginNotAvailable is returned by STATUS when the plugin can't service ADD requests.
+	ErrPluginNotAvailable = 50
 
 	// DefaultVersion is the CNI version used when no version is specified in a network config file.
 	defaultVersion = "0.2.0"
 )
 
-// Supported CNI versions.
-var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0"}
+// Supported CNI versions. The results are emitted in the version of the network config, so the runtimes of the older
+// versions get the results they expect.
+var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0", "1.1.0"}
 
 // CNI contract.
 type PluginApi interface {
@@ -40,4 +54,6 @@ type PluginApi interface {
 	Get(args *cniSkel.CmdArgs) error
 	Delete(args *cniSkel.CmdArgs) error
 	Update(args *cniSkel.CmdArgs) error
+	GC(args *cniSkel.CmdArgs) error
+	Status(args *cniSkel.CmdArgs) error
 }
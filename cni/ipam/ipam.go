@@ -5,8 +5,11 @@ package ipam
 
 import (
 	"encoding/json"
+	"errors"
 	"net"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/common"
@@ -29,6 +32,9 @@ var ipv4DefaultRouteDstPrefix = net.IPNet{
 type ipamPlugin struct {
 	*cni.Plugin
 	am ipam.AddressManager
+	// OnStaleAddresses, if set, is called with the addresses in use which the address source no longer assigns to
+	// the node, once they're detected.
+	OnStaleAddresses func([]ipam.StaleAddress)
 }
 
 // NewPlugin creates a new ipamPlugin object.
@@ -95,6 +101,7 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 	if err != nil {
 		return nil, err
 	}
+	log.SetRotation(nwCfg.LogRotation.RotationConfig())
 
 	log.Printf("[cni-ipam] Read network configuration %+v.", nwCfg)
 
@@ -105,6 +112,9 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 		nwCfg.IPAM.Environment = common.OptEnvironmentAzure
 	}
 	plugin.SetOption(common.OptEnvironment, nwCfg.IPAM.Environment)
+	plugin.SetOption(common.OptIpamSources, nwCfg.IPAM.Sources)
+	plugin.SetOption(common.OptIpamResyncAddInterval, nwCfg.IPAM.ResyncAddInterval)
+	plugin.SetOption(common.OptIpamQueryFailoverUrls, nwCfg.IPAM.QueryFailoverUrls)
 
 	// Set query interval.
 	if nwCfg.IPAM.QueryInterval != "" {
@@ -112,6 +122,10 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 		plugin.SetOption(common.OptIpamQueryInterval, i)
 	}
 
+	// Cache the query result on disk so that concurrent invocations, and the telemetry of the network plugin, don't
+	// all query wireserver.
+	plugin.SetOption(common.OptIpamQueryCacheFile, platform.CNIInterfaceInfoCachePath)
+
 	err = plugin.am.StartSource(plugin.Options)
 	if err != nil {
 		return nil, err
@@ -125,6 +139,36 @@ func (plugin *ipamPlugin) Configure(stdinData []byte) (*cni.NetworkConfig, error
 	return nwCfg, nil
 }
 
+// PrintStaleAddresses writes the addresses in use which the address source no longer assigns to the node to stdout.
+func (plugin *ipamPlugin) PrintStaleAddresses() error {
+	b, err := json.MarshalIndent(plugin.am.StaleAddresses(), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// write result to stdout to be captured by caller
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+// PrintLeakedAddresses writes the addresses in use by containers which aren't live to stdout, once they've been allocated
+// for gracePeriod, and releases them unless dryRun is set.
+func (plugin *ipamPlugin) PrintLeakedAddresses(liveContainerIDs map[string]struct{}, gracePeriod time.Duration, dryRun bool) error {
+	leaked, err := plugin.am.ReclaimLeakedAddresses(liveContainerIDs, gracePeriod, dryRun)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(leaked, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// write result to stdout to be captured by caller
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
 //
 // CNI implementation
 // https://github.com/containernetworking/cni/blob/master/SPEC.md
@@ -186,6 +230,12 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	// Allocate an address for the endpoint.
 	address, err := plugin.am.RequestAddress(nwCfg.IPAM.AddrSpace, nwCfg.IPAM.Subnet, nwCfg.IPAM.Address, options)
 	if err != nil {
+		if errors.Is(err, ipam.ErrAddressNotFound) || errors.Is(err, ipam.ErrAddressInUse) {
+			// the requested static IP can't be assigned, tell it apart so that the pod isn't retried as is
+			err = plugin.Error(cniTypes.NewError(cni.ErrRequestedIPUnavailable,
+				"Failed to allocate requested address "+nwCfg.IPAM.Address, err.Error()))
+			return err
+		}
 		err = plugin.Errorf("Failed to allocate address: %v", err)
 		return err
 	}
@@ -200,6 +250,10 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 
 	log.Printf("[cni-ipam] Allocated address %v.", address)
 
+	if stale := plugin.am.NewStaleAddresses(); len(stale) > 0 && plugin.OnStaleAddresses != nil {
+		plugin.OnStaleAddresses(stale)
+	}
+
 	// Parse IP address.
 	ipAddress, err := platform.ConvertStringToIPNet(address)
 	if err != nil {
@@ -293,3 +347,42 @@ func (plugin *ipamPlugin) Delete(args *cniSkel.CmdArgs) error {
 func (plugin *ipamPlugin) Update(args *cniSkel.CmdArgs) error {
 	return nil
 }
+
+// GC handles CNI GC commands. The addresses in use by the containers which aren't valid attachments are released.
+// The addresses allocated by older versions, which didn't record the allocation time, are released by the next GC.
+func (plugin *ipamPlugin) GC(args *cniSkel.CmdArgs) error {
+	var err error
+
+	log.Printf("[cni-ipam] Processing GC command with args {Path:%v StdinData:%s}.", args.Path, args.StdinData)
+
+	defer func() { log.Printf("[cni-ipam] GC command completed with err:%v.", err) }()
+
+	// Parse network configuration from stdin.
+	nwCfg, err := plugin.Configure(args.StdinData)
+	if err != nil {
+		err = plugin.Errorf("Failed to parse network configuration: %v", err)
+		return err
+	}
+
+	valid := make(map[string]struct{}, len(nwCfg.ValidAttachments))
+	for _, attachment := range nwCfg.ValidAttachments {
+		valid[attachment.ContainerID] = struct{}{}
+	}
+
+	leaked, err := plugin.am.ReclaimLeakedAddresses(valid, 0, false)
+	if err != nil {
+		err = plugin.Errorf("Failed to release addresses: %v", err)
+		return err
+	}
+	log.Printf("[cni-ipam] Released %d addresses of stale attachments.", len(leaked))
+
+	return nil
+}
+
+// Status handles CNI STATUS commands. The plugin is available once its address source is started.
+func (plugin *ipamPlugin) Status(args *cniSkel.CmdArgs) error {
+	if _, err := plugin.Configure(args.StdinData); err != nil {
+		return plugin.Error(cniTypes.NewError(cni.ErrPluginNotAvailable, "Failed to start address source", err.Error()))
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ package ipam
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -13,11 +14,13 @@ import (
 	"time"
 
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/common"
 )
 
@@ -220,6 +223,28 @@ var (
 			})
 		})
 
+		Describe("Test IPAM GC", func() {
+			Context("When the container of an address is not a valid attachment", func() {
+				It("Release the address", func() {
+					arg.StdinData = []byte(`{
+						"cniVersion": "1.1.0",
+						"ipam": {"type": "internal", "subnet": "10.0.0.0/16"},
+						"cni.dev/valid-attachments": [{"containerID": "live-container", "ifname": "eth0"}]
+					}`)
+					err = plugin.GC(arg)
+					Expect(err).ShouldNot(HaveOccurred())
+
+					// the pool is exhausted, only the address released by the GC can be requested again
+					arg.StdinData = getStdinData("0.4.0", "10.0.0.0/16", "")
+					arg.ContainerID = endpointID1
+					err = plugin.Add(arg)
+					Expect(err).ShouldNot(HaveOccurred())
+
+					arg.ContainerID = ""
+				})
+			})
+		})
+
 		Describe("Test IPAM DELETE", func() {
 			Context("Delete when only container id is given", func() {
 				It("Deleted", func() {
@@ -246,6 +271,18 @@ var (
 				})
 			})
 
+			Context("When an address not in the pool is requested", func() {
+				It("Fail with the requested IP unavailable code", func() {
+					arg.StdinData = getStdinData("0.4.0", "10.0.0.0/16", "10.0.1.4")
+					err = plugin.Add(arg)
+					Expect(err).Should(HaveOccurred())
+
+					var cniErr *cniTypes.Error
+					Expect(errors.As(err, &cniErr)).To(BeTrue())
+					Expect(cniErr.Code).To(Equal(uint(cni.ErrRequestedIPUnavailable)))
+				})
+			})
+
 			Context("When pool is in use", func() {
 				It("Fail to request pool", func() {
 					arg.StdinData = getStdinData("0.4.0", "", "")
@@ -4,17 +4,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/ipam"
 	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/crictl"
+	ipamapi "github.com/Azure/azure-container-networking/ipam"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/telemetry"
+	utilexec "k8s.io/utils/exec"
 )
 
 const (
 	name = "azure-vnet-ipam"
+
+	// envLeakGracePeriod is how long an address must have been allocated before GET_LEAKED_ADDRESSES reports it, so
+	// that the containers being created while the pod sandboxes are listed aren't reported.
+	envLeakGracePeriod     = "LEAK_GRACE_PERIOD"
+	defaultLeakGracePeriod = 30 * time.Minute
+	// envReclaimLeaked releases the addresses reported by GET_LEAKED_ADDRESSES if it's true, they're only reported otherwise.
+	envReclaimLeaked = "RECLAIM_LEAKED_ADDRESSES"
+	crictlTimeout    = 30 * time.Second
 )
 
 // Version is populated by make during build.
@@ -40,6 +55,7 @@ func main() {
 		fmt.Printf("Failed to create IPAM plugin, err:%v.\n", err)
 		os.Exit(1)
 	}
+	ipamPlugin.OnStaleAddresses = reportStaleAddresses
 
 	if err := ipamPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
 		fmt.Printf("Failed to initialize key-value store of ipam plugin, err:%v.\n", err)
@@ -62,7 +78,15 @@ func main() {
 		panic("ipam plugin fatal error")
 	}
 
-	err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
+	switch os.Getenv(cni.Cmd) {
+	case cni.CmdGetStaleAddresses:
+		// used to dump the stale addresses
+		err = ipamPlugin.PrintStaleAddresses()
+	case cni.CmdGetLeakedAddresses:
+		err = printLeakedAddresses(ipamPlugin)
+	default:
+		err = ipamPlugin.Execute(cni.PluginApi(ipamPlugin))
+	}
 
 	ipamPlugin.Stop()
 
@@ -70,3 +94,58 @@ func main() {
 		panic("ipam plugin fatal error")
 	}
 }
+
+type leakedAddressPrinter interface {
+	PrintLeakedAddresses(liveContainerIDs map[string]struct{}, gracePeriod time.Duration, dryRun bool) error
+}
+
+// printLeakedAddresses prints the addresses in use by containers which aren't pod sandboxes of the container runtime,
+// listed with crictl (which honors CONTAINER_RUNTIME_ENDPOINT), and reclaims them if RECLAIM_LEAKED_ADDRESSES is true.
+func printLeakedAddresses(plugin leakedAddressPrinter) error {
+	gracePeriod := defaultLeakGracePeriod
+	if v := os.Getenv(envLeakGracePeriod); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", envLeakGracePeriod, v, err)
+		}
+		gracePeriod = d
+	}
+	reclaim, _ := strconv.ParseBool(os.Getenv(envReclaimLeaked))
+
+	ctx, cancel := context.WithTimeout(context.Background(), crictlTimeout)
+	defer cancel()
+	live, err := crictl.New(utilexec.New(), "").PodSandboxIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if reclaim && len(live) == 0 {
+		// every address in use would be reclaimed, the runtime is more likely restarting than running no pod
+		log.Printf("[cni-ipam] Not reclaiming leaked addresses since the container runtime has no pod sandbox")
+		reclaim = false
+	}
+	return plugin.PrintLeakedAddresses(live, gracePeriod, !reclaim)
+}
+
+// reportStaleAddresses reports the addresses in use which are no longer assigned to the node to the telemetry process,
+// if it is running, since the pods using them lost their connectivity.
+func reportStaleAddresses(stale []ipamapi.StaleAddress) {
+	tb := telemetry.NewTelemetryBuffer()
+	tb.ClientName = name
+	tb.ClientVersion = version
+	if err := tb.Connect(); err != nil {
+		log.Errorf("Cannot connect to telemetry service:%v", err)
+		return
+	}
+	defer tb.Close()
+
+	for _, addr := range stale {
+		telemetry.SendCNIEvent(tb, &telemetry.CNIReport{
+			Name:          name,
+			Version:       version,
+			Context:       "AzureCNIIpam",
+			ContainerName: addr.ContainerID,
+			EventMessage: fmt.Sprintf("Address %s of pool %s is in use but no longer assigned to the node",
+				addr.Address, addr.Pool),
+		})
+	}
+}
@@ -5,8 +5,13 @@ package cni
 
 import (
 	"encoding/json"
+	"net"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
+
+	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network/policy"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 )
@@ -31,6 +36,40 @@ type PortMapping struct {
 type RuntimeConfig struct {
 	PortMappings []PortMapping    `json:"portMappings,omitempty"`
 	DNS          RuntimeDNSConfig `json:"dns,omitempty"`
+	Bandwidth    *BandwidthEntry  `json:"bandwidth,omitempty"`
+	// IPs are the static IPs requested for the pod with the ips capability, optionally in CIDR notation.
+	IPs []string `json:"ips,omitempty"`
+	// PodAnnotations are the annotations of the pod, passed by containerd with the io.kubernetes.cri.pod-annotations
+	// capability.
+	PodAnnotations map[string]string `json:"io.kubernetes.cri.pod-annotations,omitempty"`
+}
+
+// BandwidthEntry is the bandwidth capability passed by the runtime from the kubernetes.io/ingress-bandwidth
+// and kubernetes.io/egress-bandwidth pod annotations. Rates are in bits per second and bursts in bits.
+// https://www.cni.dev/plugins/current/meta/bandwidth/
+type BandwidthEntry struct {
+	IngressRate  uint64 `json:"ingressRate,omitempty"`
+	IngressBurst uint64 `json:"ingressBurst,omitempty"`
+	EgressRate   uint64 `json:"egressRate,omitempty"`
+	EgressBurst  uint64 `json:"egressBurst,omitempty"`
+}
+
+var errInvalidBandwidth = errors.New("invalid bandwidth")
+
+// Validate checks that every limited direction has both a rate and a burst.
+func (bw *BandwidthEntry) Validate() error {
+	if (bw.IngressRate == 0) != (bw.IngressBurst == 0) {
+		return errors.Wrapf(errInvalidBandwidth, "ingress rate %d and burst %d must be set together", bw.IngressRate, bw.IngressBurst)
+	}
+	if (bw.EgressRate == 0) != (bw.EgressBurst == 0) {
+		return errors.Wrapf(errInvalidBandwidth, "egress rate %d and burst %d must be set together", bw.EgressRate, bw.EgressBurst)
+	}
+	return nil
+}
+
+// IsZero returns true if no direction is limited.
+func (bw *BandwidthEntry) IsZero() bool {
+	return bw == nil || (bw.IngressRate == 0 && bw.EgressRate == 0)
 }
 
 // https://github.com/kubernetes/kubernetes/blob/master/pkg/kubelet/dockershim/network/cni/cni.go#L104
@@ -48,6 +87,14 @@ type IPAM struct {
 	Subnet        string `json:"subnet,omitempty"`
 	Address       string `json:"ipAddress,omitempty"`
 	QueryInterval string `json:"queryInterval,omitempty"`
+	// QueryFailoverUrls are queried in order for the interface info of the node when the query URL fails.
+	QueryFailoverUrls []string `json:"queryFailoverUrls,omitempty"`
+	// Sources lists the address sources to fall back to in order, e.g. ["imds", "azure"].
+	// Overrides Environment when set.
+	Sources []string `json:"sources,omitempty"`
+	// ResyncAddInterval is the number of ADDs after which the address source is queried again bypassing its cache,
+	// to detect the secondary IPs removed at the Azure control plane. 10 if unset, negative disables the resync.
+	ResyncAddInterval int `json:"resyncAddInterval,omitempty"`
 }
 
 // NetworkConfig represents Azure CNI plugin network configuration.
@@ -72,18 +119,143 @@ type NetworkConfig struct {
 	EnableExactMatchForPodName    bool            `json:"enableExactMatchForPodName,omitempty"`
 	DisableHairpinOnHostInterface bool            `json:"disableHairpinOnHostInterface,omitempty"`
 	DisableIPTableLock            bool            `json:"disableIPTableLock,omitempty"`
+	EnableSourceRouting           bool            `json:"enableSourceRouting,omitempty"`
 	CNSUrl                        string          `json:"cnsurl,omitempty"`
 	ExecutionMode                 string          `json:"executionMode,omitempty"`
 	IPAM                          IPAM            `json:"ipam,omitempty"`
 	DNS                           cniTypes.DNS    `json:"dns,omitempty"`
 	RuntimeConfig                 RuntimeConfig   `json:"runtimeConfig,omitempty"`
 	WindowsSettings               WindowsSettings `json:"windowsSettings,omitempty"`
+	NodeLocalDNS                  *NodeLocalDNS   `json:"nodeLocalDNS,omitempty"`
+	NodeLocalNAT                  *NodeLocalNAT   `json:"nodeLocalNAT,omitempty"`
+	VnetBlock                     *VnetBlock      `json:"vnetBlock,omitempty"`
+	LogRotation                   *LogRotation    `json:"logRotation,omitempty"`
+	Mirror                        *Mirror         `json:"mirror,omitempty"`
 	AdditionalArgs                []KVPair        `json:"AdditionalArgs,omitempty"`
+	// EnableStatusFile writes the current phase of the command to a status file while it's in progress, which
+	// `azure-vnet --status` reads to show what a stuck command is waiting on.
+	EnableStatusFile bool `json:"enableStatusFile,omitempty"`
+	// ValidAttachments are the attachments the runtime still uses, only set for GC. The state of the others is deleted.
+	ValidAttachments []cniTypes.GCAttachment `json:"cni.dev/valid-attachments,omitempty"`
 }
 
 type WindowsSettings struct {
 	EnableLoopbackDSR           bool `json:"enableLoopbackDSR,omitempty"`
 	HnsTimeoutDurationInSeconds int  `json:"hnsTimeoutDurationInSeconds,omitempty"`
+	// OutboundNATExceptions are the CIDRs, e.g. of on-prem networks, the traffic to which isn't SNATed by the
+	// OutBoundNAT policy of the endpoints.
+	OutboundNATExceptions []string `json:"outboundNATExceptions,omitempty"`
+	// AcceleratedNetworking backs the endpoints of the pods with an SR-IOV virtual function.
+	AcceleratedNetworking *AcceleratedNetworking `json:"acceleratedNetworking,omitempty"`
+}
+
+// AcceleratedNetworkingAnnotation is the pod annotation requesting ("true") or opting out of ("false") an endpoint
+// backed by an SR-IOV virtual function, overriding AcceleratedNetworking.Enabled. It needs the
+// io.kubernetes.cri.pod-annotations capability. Windows only.
+const AcceleratedNetworkingAnnotation = "cni.azure.com/accelerated-networking"
+
+// AcceleratedNetworking backs the HNS endpoints of the pods with an SR-IOV virtual function of the host NIC, so that
+// their traffic bypasses the synthetic vmNIC path. The datapath an endpoint was created with is reported in the
+// "datapath" field of the ADD result. HNS v2 only, the endpoints of HNS v1 always use the synthetic datapath.
+type AcceleratedNetworking struct {
+	// Enabled accelerates the endpoints of all the pods which aren't annotated with
+	// AcceleratedNetworkingAnnotation=false. Otherwise, only the pods annotated with it =true are.
+	Enabled bool `json:"enabled,omitempty"`
+	// DisableFallback fails the ADD of a pod whose endpoint can't be accelerated, e.g. as the VM has no virtual
+	// function left, instead of creating it with the synthetic datapath.
+	DisableFallback bool `json:"disableFallback,omitempty"`
+}
+
+// The modes of NodeLocalDNS.
+const (
+	NodeLocalDNSDisabled = "disabled"
+	NodeLocalDNSEnabled  = "enabled"
+	// NodeLocalDNSAuto uses the cache on the nodes where its IP is assigned to a host interface.
+	NodeLocalDNSAuto = "auto"
+	// DefaultNodeLocalDNSIP is the link-local IP NodeLocal DNSCache listens on by default.
+	DefaultNodeLocalDNSIP = "169.254.20.10"
+)
+
+// NodeLocalDNS configures the pods to resolve names through a DNS cache on their node, e.g. NodeLocal DNSCache,
+// which listens on a link-local IP of the host. The cache becomes the first DNS server of the pods, and the traffic
+// to its IP is routed to the host.
+type NodeLocalDNS struct {
+	// Mode is disabled, enabled or auto. Empty means disabled.
+	Mode string `json:"mode,omitempty"`
+	// IP is the IP of the cache, DefaultNodeLocalDNSIP if empty.
+	IP string `json:"ip,omitempty"`
+}
+
+// The scopes of NodeLocalNAT.
+const (
+	NodeLocalNATScopePod       = "pod"
+	NodeLocalNATScopeNamespace = "namespace"
+)
+
+// NodeLocalNAT allocates each pod a node-local IP from a dedicated range, e.g. for the host to SNAT the egress
+// traffic of the pod or of its namespace to. The IP is returned in the result as an additional address, on an
+// interface named after its scope.
+type NodeLocalNAT struct {
+	// Subnet is the range of the IPs, empty disables them.
+	Subnet string `json:"subnet,omitempty"`
+	// Scope is pod, the default, for an IP per pod assigned to its interface, or namespace for an IP shared by the
+	// pods of the namespace, which isn't assigned to their interfaces.
+	Scope string `json:"scope,omitempty"`
+}
+
+// VnetBlock configures the host datapath of the vnet-block mode, where the IPs of the pods are from a block of the
+// VNet routed to the node and the host routes them to the pods through /32 routes, like in the transparent mode.
+type VnetBlock struct {
+	// DisableProxyARP stops the host veths from answering the ARP requests of the pods.
+	DisableProxyARP bool `json:"disableProxyARP,omitempty"`
+	// ProxyNDP answers the neighbor solicitations for the IPv6 of the pods on the host primary interface.
+	ProxyNDP bool `json:"proxyNDP,omitempty"`
+	// DisableRPFilter disables the reverse path filter on the host veths.
+	DisableRPFilter bool `json:"disableRPFilter,omitempty"`
+}
+
+const (
+	// MirrorAnnotation is the pod annotation requesting its traffic to be mirrored for the duration of its value,
+	// e.g. 30m, from the ADD of the pod. It needs the io.kubernetes.cri.pod-annotations capability.
+	MirrorAnnotation = "cni.azure.com/mirror-duration"
+	// DefaultMirrorMaxDurationMinutes caps the duration of the mirrors if the netconf doesn't.
+	DefaultMirrorMaxDurationMinutes = 60
+)
+
+// Mirror mirrors the traffic of the pods annotated with MirrorAnnotation to a collector interface of the host, e.g. an
+// ERSPAN or VXLAN tunnel to a capture appliance, for debugging. The mirrors are removed once they expire, by the next
+// ADD or DEL of the network, or with the pod. Linux only.
+type Mirror struct {
+	// CollectorInterface is the host interface the traffic is mirrored to, empty disables the mirrors.
+	CollectorInterface string `json:"collectorInterface,omitempty"`
+	// MaxDurationMinutes caps the duration requested by the pods, DefaultMirrorMaxDurationMinutes if zero.
+	MaxDurationMinutes int `json:"maxDurationMinutes,omitempty"`
+}
+
+// LogRotation configures the rotation of the log files of the plugins. Zero sizes and counts keep the defaults.
+type LogRotation struct {
+	MaxFileSizeMB int `json:"maxFileSizeMB,omitempty"`
+	MaxFileCount  int `json:"maxFileCount,omitempty"`
+	// MaxAgeHours rotates the log file once its first entry is older, zero only rotates it on size.
+	MaxAgeHours int  `json:"maxAgeHours,omitempty"`
+	Compress    bool `json:"compress,omitempty"`
+	// MaxTotalSizeMB prunes the oldest rotated log files above this total size, zero only prunes them on count.
+	MaxTotalSizeMB int `json:"maxTotalSizeMB,omitempty"`
+}
+
+// RotationConfig returns the rotation config of the log package, the defaults if r is nil.
+func (r *LogRotation) RotationConfig() log.RotationConfig {
+	const mb = 1024 * 1024
+	if r == nil {
+		return log.RotationConfig{}
+	}
+	return log.RotationConfig{
+		MaxFileSize:  r.MaxFileSizeMB * mb,
+		MaxFileCount: r.MaxFileCount,
+		MaxAge:       time.Duration(r.MaxAgeHours) * time.Hour,
+		Compress:     r.Compress,
+		MaxTotalSize: int64(r.MaxTotalSizeMB) * mb,
+	}
 }
 
 type K8SPodEnvArgs struct {
@@ -91,6 +263,7 @@ type K8SPodEnvArgs struct {
 	K8S_POD_NAMESPACE          cniTypes.UnmarshallableString `json:"K8S_POD_NAMESPACE,omitempty"`
 	K8S_POD_NAME               cniTypes.UnmarshallableString `json:"K8S_POD_NAME,omitempty"`
 	K8S_POD_INFRA_CONTAINER_ID cniTypes.UnmarshallableString `json:"K8S_POD_INFRA_CONTAINER_ID,omitempty"`
+	K8S_POD_IP                 cniTypes.UnmarshallableString `json:"K8S_POD_IP,omitempty"`
 }
 
 // ParseCniArgs unmarshals cni arguments.
@@ -104,6 +277,44 @@ func ParseCniArgs(args string) (*K8SPodEnvArgs, error) {
 	return &podCfg, nil
 }
 
+// ErrInvalidRequestedIP is returned when a static IP requested for the pod isn't an IP.
+var ErrInvalidRequestedIP = errors.New("invalid requested IP")
+
+// RequestedIPs returns the static IPs requested for the pod: the ips capability of the runtime config,
+// or else the K8S_POD_IP CNI arg. The prefix length of IPs in CIDR notation is ignored, as the IP must
+// belong to the subnet of the pool anyway. Returns nil if no IP is requested.
+func RequestedIPs(nwCfg *NetworkConfig, args string) ([]net.IP, error) {
+	var requested []string
+	if nwCfg != nil {
+		requested = nwCfg.RuntimeConfig.IPs
+	}
+	if len(requested) == 0 {
+		podCfg, err := ParseCniArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		if podCfg.K8S_POD_IP != "" {
+			requested = []string{string(podCfg.K8S_POD_IP)}
+		}
+	}
+
+	ips := make([]net.IP, 0, len(requested))
+	for _, s := range requested {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			var err error
+			if ip, _, err = net.ParseCIDR(s); err != nil {
+				return nil, errors.Wrapf(ErrInvalidRequestedIP, "%q", s)
+			}
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		return nil, nil
+	}
+	return ips, nil
+}
+
 // ParseNetworkConfig unmarshals network configuration from bytes.
 func ParseNetworkConfig(b []byte) (*NetworkConfig, error) {
 	nwCfg := NetworkConfig{}
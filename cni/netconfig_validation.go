@@ -0,0 +1,318 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni/util"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+type schemaKind string
+
+const (
+	kindString schemaKind = "string"
+	kindBool   schemaKind = "boolean"
+	kindNumber schemaKind = "number"
+	kindObject schemaKind = "object"
+	kindArray  schemaKind = "array"
+	kindAny    schemaKind = "any"
+)
+
+// schema describes a JSON value of the network configuration.
+type schema struct {
+	kind schemaKind
+	// enum lists the valid values of a string, if set. An empty string is always valid.
+	enum []string
+	// fields are the known fields of an object. Unknown fields are invalid unless the object is open.
+	fields map[string]*schema
+	open   bool
+	// elem is the schema of the elements of an array.
+	elem *schema
+	// goos is the only OS supporting the value, if set. Empty values are valid on every OS.
+	goos string
+}
+
+func stringSchema(enum ...string) *schema { return &schema{kind: kindString, enum: enum} }
+
+func objectSchema(fields map[string]*schema) *schema {
+	return &schema{kind: kindObject, fields: fields}
+}
+
+func arraySchema(elem *schema) *schema { return &schema{kind: kindArray, elem: elem} }
+
+var (
+	boolSchema   = &schema{kind: kindBool}
+	numberSchema = &schema{kind: kindNumber}
+	anySchema    = &schema{kind: kindAny}
+	openSchema   = &schema{kind: kindObject, open: true}
+)
+
+// networkConfigSchema is the schema of the azure-vnet network configuration (see NetworkConfig).
+// Field names match case-insensitively, like they are unmarshaled.
+var networkConfigSchema = objectSchema(map[string]*schema{
+	"cniVersion":                    stringSchema(),
+	"name":                          stringSchema(),
+	"type":                          stringSchema(),
+	"mode":                          stringSchema("bridge", "tunnel", "transparent", "transparent-vlan", "vnet-block"),
+	"master":                        stringSchema(),
+	"adapterName":                   stringSchema(),
+	"bridge":                        stringSchema(),
+	"logLevel":                      stringSchema(),
+	"logTarget":                     stringSchema(),
+	"infraVnetAddressSpace":         stringSchema(),
+	"ipv6Mode":                      stringSchema("ipv6nat"),
+	"serviceCidrs":                  stringSchema(),
+	"vnetCidrs":                     stringSchema(),
+	"podNamespaceForDualNetwork":    arraySchema(stringSchema()),
+	"ipsToRouteViaHost":             arraySchema(stringSchema()),
+	"multiTenancy":                  boolSchema,
+	"enableSnatOnHost":              boolSchema,
+	"enableExactMatchForPodName":    boolSchema,
+	"disableHairpinOnHostInterface": boolSchema,
+	"disableIPTableLock":            boolSchema,
+	"enableSourceRouting":           {kind: kindBool, goos: "linux"},
+	"cnsurl":                        stringSchema(),
+	"executionMode":                 stringSchema(string(util.Default), string(util.Baremetal), string(util.V4Swift)),
+	"ipam": objectSchema(map[string]*schema{
+		"mode":              stringSchema(string(util.V4Overlay), string(util.DualStackOverlay)),
+		"type":              stringSchema("azure-vnet-ipam", "azure-vnet-ipamv6", "azure-cns"),
+		"environment":       stringSchema(),
+		"addressSpace":      stringSchema(),
+		"subnet":            stringSchema(),
+		"ipAddress":         stringSchema(),
+		"queryInterval":     stringSchema(),
+		"queryFailoverUrls": arraySchema(stringSchema()),
+		"sources":           arraySchema(stringSchema()),
+		"resyncAddInterval": numberSchema,
+	}),
+	"dns": objectSchema(map[string]*schema{
+		"nameservers": arraySchema(stringSchema()),
+		"domain":      stringSchema(),
+		"search":      arraySchema(stringSchema()),
+		"options":     arraySchema(stringSchema()),
+	}),
+	// the runtime passes the values of all capabilities, not only those of this plugin
+	"runtimeConfig": {kind: kindObject, open: true, fields: map[string]*schema{
+		"portMappings": arraySchema(objectSchema(map[string]*schema{
+			"hostPort":      numberSchema,
+			"containerPort": numberSchema,
+			"protocol":      stringSchema(),
+			"hostIP":        stringSchema(),
+		})),
+		"dns": objectSchema(map[string]*schema{
+			"servers":  arraySchema(stringSchema()),
+			"searches": arraySchema(stringSchema()),
+			"options":  arraySchema(stringSchema()),
+		}),
+		"bandwidth": objectSchema(map[string]*schema{
+			"ingressRate":  numberSchema,
+			"ingressBurst": numberSchema,
+			"egressRate":   numberSchema,
+			"egressBurst":  numberSchema,
+		}),
+		"ips": arraySchema(stringSchema()),
+	}},
+	"windowsSettings": {kind: kindObject, goos: "windows", fields: map[string]*schema{
+		"enableLoopbackDSR":           boolSchema,
+		"hnsTimeoutDurationInSeconds": numberSchema,
+		"outboundNATExceptions":       arraySchema(stringSchema()),
+		"acceleratedNetworking": objectSchema(map[string]*schema{
+			"enabled":         boolSchema,
+			"disableFallback": boolSchema,
+		}),
+	}},
+	"nodeLocalDNS": objectSchema(map[string]*schema{
+		"mode": stringSchema(NodeLocalDNSDisabled, NodeLocalDNSEnabled, NodeLocalDNSAuto),
+		"ip":   stringSchema(),
+	}),
+	"nodeLocalNAT": {kind: kindObject, goos: "linux", fields: map[string]*schema{
+		"subnet": stringSchema(),
+		"scope":  stringSchema(NodeLocalNATScopePod, NodeLocalNATScopeNamespace),
+	}},
+	"vnetBlock": {kind: kindObject, goos: "linux", fields: map[string]*schema{
+		"disableProxyARP": boolSchema,
+		"proxyNDP":        boolSchema,
+		"disableRPFilter": boolSchema,
+	}},
+	"mirror": {kind: kindObject, goos: "linux", fields: map[string]*schema{
+		"collectorInterface": stringSchema(),
+		"maxDurationMinutes": numberSchema,
+	}},
+	"logRotation": objectSchema(map[string]*schema{
+		"maxFileSizeMB":  numberSchema,
+		"maxFileCount":   numberSchema,
+		"maxAgeHours":    numberSchema,
+		"compress":       boolSchema,
+		"maxTotalSizeMB": numberSchema,
+	}),
+	"AdditionalArgs": arraySchema(objectSchema(map[string]*schema{
+		"name":  stringSchema(),
+		"value": anySchema,
+	})),
+	"enableStatusFile": boolSchema,
+	// set by the runtime for every plugin
+	"capabilities": openSchema,
+	"prevResult":   anySchema,
+	"args":         anySchema,
+})
+
+// ValidateNetworkConfig checks the network configuration against the schema of the azure-vnet netconf.
+// It returns a CNI error listing every invalid or unknown field, so that a bad netconf fails early with
+// an actionable message instead of deep in ADD.
+func ValidateNetworkConfig(b []byte) error {
+	problems, err := validateNetworkConfig(b, runtime.GOOS)
+	if err != nil {
+		return cniTypes.NewError(cniTypes.ErrDecodingFailure, "failed to decode network configuration", err.Error())
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return cniTypes.NewError(cniTypes.ErrInvalidNetworkConfig, "invalid network configuration", strings.Join(problems, "; "))
+}
+
+// validateNetworkConfig returns the problems of the network configuration on the OS, sorted by field.
+func validateNetworkConfig(b []byte, goos string) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err //nolint:wrapcheck // wrapped in a CNI error
+	}
+	var problems []string
+	networkConfigSchema.validate("", v, goos, &problems)
+	sort.Strings(problems)
+	return problems, nil
+}
+
+func (s *schema) validate(path string, v interface{}, goos string, problems *[]string) {
+	name := path
+	if name == "" {
+		name = "netconf"
+	}
+
+	if s.goos != "" && s.goos != goos && !isEmptyJSON(v) {
+		*problems = append(*problems, fmt.Sprintf("%s: only supported on %s", name, s.goos))
+		return
+	}
+
+	if v == nil || s.kind == kindAny {
+		return
+	}
+
+	switch s.kind {
+	case kindString:
+		str, ok := v.(string)
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: must be a %s, got %s", name, s.kind, jsonKind(v)))
+			return
+		}
+		if str != "" && len(s.enum) > 0 && !contains(s.enum, str) {
+			*problems = append(*problems, fmt.Sprintf("%s: %q must be one of [%s]", name, str, strings.Join(s.enum, ", ")))
+		}
+	case kindBool:
+		if _, ok := v.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: must be a %s, got %s", name, s.kind, jsonKind(v)))
+		}
+	case kindNumber:
+		if _, ok := v.(float64); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: must be a %s, got %s", name, s.kind, jsonKind(v)))
+		}
+	case kindArray:
+		elems, ok := v.([]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: must be an %s, got %s", name, s.kind, jsonKind(v)))
+			return
+		}
+		for i, elem := range elems {
+			s.elem.validate(fmt.Sprintf("%s[%d]", path, i), elem, goos, problems)
+		}
+	case kindObject:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: must be an %s, got %s", name, s.kind, jsonKind(v)))
+			return
+		}
+		for key, value := range obj {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			field := s.field(key)
+			if field == nil {
+				if !s.open {
+					*problems = append(*problems, fmt.Sprintf("%s: unknown field", fieldPath))
+				}
+				continue
+			}
+			field.validate(fieldPath, value, goos, problems)
+		}
+	}
+}
+
+// field returns the schema of the field of an object, matching its name case-insensitively.
+func (s *schema) field(key string) *schema {
+	if field, ok := s.fields[key]; ok {
+		return field
+	}
+	for name, field := range s.fields {
+		if strings.EqualFold(name, key) {
+			return field
+		}
+	}
+	return nil
+}
+
+func jsonKind(v interface{}) schemaKind {
+	switch v.(type) {
+	case string:
+		return kindString
+	case bool:
+		return kindBool
+	case float64:
+		return kindNumber
+	case []interface{}:
+		return kindArray
+	default:
+		return kindObject
+	}
+}
+
+// isEmptyJSON returns whether the value is null, a zero value, or a collection of empty values,
+// as serialized for unset fields of the NetworkConfig.
+func isEmptyJSON(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		for _, value := range v {
+			if !isEmptyJSON(value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package cni
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the shipped conflists must pass validation on their OS
+func TestValidateShippedConflists(t *testing.T) {
+	conflists, err := filepath.Glob("*.conflist")
+	require.NoError(t, err)
+	require.NotEmpty(t, conflists)
+
+	for _, conflist := range conflists {
+		b, err := os.ReadFile(conflist)
+		require.NoError(t, err)
+		var list struct {
+			CNIVersion string                   `json:"cniVersion"`
+			Name       string                   `json:"name"`
+			Plugins    []map[string]interface{} `json:"plugins"`
+		}
+		require.NoError(t, json.Unmarshal(b, &list), conflist)
+
+		goos := "linux"
+		if strings.Contains(conflist, "windows") {
+			goos = "windows"
+		}
+		for _, plugin := range list.Plugins {
+			if plugin["type"] != "azure-vnet" {
+				continue
+			}
+			// the runtime passes the name and version of the list to every plugin
+			plugin["cniVersion"] = list.CNIVersion
+			plugin["name"] = list.Name
+			netconf, err := json.Marshal(plugin)
+			require.NoError(t, err)
+			problems, err := validateNetworkConfig(netconf, goos)
+			require.NoError(t, err)
+			assert.Empty(t, problems, conflist)
+		}
+	}
+}
+
+func TestValidateNetworkConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		netconf  string
+		goos     string
+		problems []string
+	}{
+		{
+			name:    "valid",
+			netconf: `{"cniVersion":"0.3.0","name":"azure","type":"azure-vnet","mode":"transparent","ipam":{"type":"azure-cns","mode":"v4overlay"},"runtimeConfig":{"portMappings":[{"hostPort":80,"containerPort":8080,"protocol":"tcp"}],"mac":"00:00:00:00:00:00"}}`,
+			goos:    "linux",
+		},
+		{
+			name:    "every problem is listed",
+			netconf: `{"type":"azure-vnet","mode":"l2bridge","enableSnat":true,"multiTenancy":"true","ipam":{"type":"host-local","mode":""},"runtimeConfig":{"bandwidth":{"ingressRate":"1M"}},"AdditionalArgs":[{"Name":"EndpointPolicy","Value":{}},{"Name":1}]}`,
+			goos:    "linux",
+			problems: []string{
+				`AdditionalArgs[1].Name: must be a string, got number`,
+				`enableSnat: unknown field`,
+				`ipam.type: "host-local" must be one of [azure-vnet-ipam, azure-vnet-ipamv6, azure-cns]`,
+				`mode: "l2bridge" must be one of [bridge, tunnel, transparent, transparent-vlan, vnet-block]`,
+				`multiTenancy: must be a boolean, got string`,
+				`runtimeConfig.bandwidth.ingressRate: must be a number, got string`,
+			},
+		},
+		{
+			name:     "log rotation",
+			netconf:  `{"type":"azure-vnet","logRotation":{"maxFileSizeMB":5,"maxFileCount":10,"maxAgeHours":24,"compress":"yes"}}`,
+			goos:     "linux",
+			problems: []string{`logRotation.compress: must be a boolean, got string`},
+		},
+		{
+			name:     "windows settings on linux",
+			netconf:  `{"type":"azure-vnet","windowsSettings":{"enableLoopbackDSR":true}}`,
+			goos:     "linux",
+			problems: []string{`windowsSettings: only supported on windows`},
+		},
+		{
+			name:    "empty windows settings on linux",
+			netconf: `{"type":"azure-vnet","windowsSettings":{"enableLoopbackDSR":false}}`,
+			goos:    "linux",
+		},
+		{
+			name:     "windows settings on windows",
+			netconf:  `{"type":"azure-vnet","windowsSettings":{"enableLoopbackDSR":true,"hnsTimeout":10}}`,
+			goos:     "windows",
+			problems: []string{`windowsSettings.hnsTimeout: unknown field`},
+		},
+		{
+			name:     "accelerated networking",
+			netconf:  `{"type":"azure-vnet","windowsSettings":{"acceleratedNetworking":{"enabled":true,"disableFallback":"no"}}}`,
+			goos:     "windows",
+			problems: []string{`windowsSettings.acceleratedNetworking.disableFallback: must be a boolean, got string`},
+		},
+		{
+			name:     "node-local NAT",
+			netconf:  `{"type":"azure-vnet","nodeLocalNAT":{"subnet":"169.254.100.0/24","scope":"node"}}`,
+			goos:     "linux",
+			problems: []string{`nodeLocalNAT.scope: "node" must be one of [pod, namespace]`},
+		},
+		{
+			name:     "vnet block",
+			netconf:  `{"type":"azure-vnet","mode":"vnet-block","vnetBlock":{"disableProxyARP":true,"proxyNDP":"yes"}}`,
+			goos:     "linux",
+			problems: []string{`vnetBlock.proxyNDP: must be a boolean, got string`},
+		},
+		{
+			name:     "mirror",
+			netconf:  `{"type":"azure-vnet","mirror":{"collectorInterface":"erspan0","maxDurationMinutes":"30"}}`,
+			goos:     "linux",
+			problems: []string{`mirror.maxDurationMinutes: must be a number, got string`},
+		},
+		{
+			name:     "mirror on windows",
+			netconf:  `{"type":"azure-vnet","mirror":{"collectorInterface":"erspan0"}}`,
+			goos:     "windows",
+			problems: []string{`mirror: only supported on linux`},
+		},
+		{
+			name:     "node-local NAT on windows",
+			netconf:  `{"type":"azure-vnet","nodeLocalNAT":{"subnet":"169.254.100.0/24"}}`,
+			goos:     "windows",
+			problems: []string{`nodeLocalNAT: only supported on linux`},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := validateNetworkConfig([]byte(tt.netconf), tt.goos)
+			require.NoError(t, err)
+			assert.Equal(t, tt.problems, problems)
+		})
+	}
+}
+
+func TestValidateNetworkConfigError(t *testing.T) {
+	err := ValidateNetworkConfig([]byte(`{"type":"azure-vnet","mode":"l2bridge","foo":1}`))
+	var cniErr *cniTypes.Error
+	require.True(t, errors.As(err, &cniErr))
+	assert.Equal(t, uint(cniTypes.ErrInvalidNetworkConfig), cniErr.Code)
+	assert.Equal(t, `foo: unknown field; mode: "l2bridge" must be one of [bridge, tunnel, transparent, transparent-vlan, vnet-block]`, cniErr.Details)
+
+	err = ValidateNetworkConfig([]byte(`{"type":`))
+	require.True(t, errors.As(err, &cniErr))
+	assert.Equal(t, uint(cniTypes.ErrDecodingFailure), cniErr.Code)
+
+	require.NoError(t, ValidateNetworkConfig([]byte(`{"type":"azure-vnet"}`)))
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// datapathResultKey is the field of the ADD result reporting the datapath of the endpoint. The runtimes ignore it as
+// an unknown field.
+const datapathResultKey = "datapath"
+
+var errInvalidAcceleratedNetworking = errors.New("invalid accelerated networking annotation")
+
+// acceleratedNetworkingInfo returns the acceleration of the endpoint of the pod, requested by the netconf or by the
+// cni.AcceleratedNetworkingAnnotation of the pod, which overrides the netconf. It returns nil if neither requests it.
+func acceleratedNetworkingInfo(nwCfg *cni.NetworkConfig) (*network.AcceleratedNetworkingInfo, error) {
+	var enabled, disableFallback bool
+	if cfg := nwCfg.WindowsSettings.AcceleratedNetworking; cfg != nil {
+		enabled, disableFallback = cfg.Enabled, cfg.DisableFallback
+	}
+	if value, ok := nwCfg.RuntimeConfig.PodAnnotations[cni.AcceleratedNetworkingAnnotation]; ok {
+		var err error
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return nil, errors.Wrapf(errInvalidAcceleratedNetworking, "%s=%q", cni.AcceleratedNetworkingAnnotation, value)
+		}
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return &network.AcceleratedNetworkingInfo{DisableFallback: disableFallback}, nil
+}
+
+// printResult prints the ADD result to w, with the datapath of the endpoint if acceleration was requested for it.
+func printResult(w io.Writer, res cniTypes.Result, datapath string) error {
+	if datapath == "" {
+		return res.PrintTo(w) //nolint:wrapcheck // passthrough
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal result")
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return errors.Wrap(err, "failed to unmarshal result")
+	}
+	if fields[datapathResultKey], err = json.Marshal(datapath); err != nil {
+		return errors.Wrap(err, "failed to marshal datapath")
+	}
+	if b, err = json.MarshalIndent(fields, "", "    "); err != nil {
+		return errors.Wrap(err, "failed to marshal result")
+	}
+	_, err = w.Write(b)
+	return errors.Wrap(err, "failed to write result")
+}
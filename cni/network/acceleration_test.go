@@ -0,0 +1,86 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceleratedNetworkingInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		accelerated *cni.AcceleratedNetworking
+		annotations map[string]string
+		want        *network.AcceleratedNetworkingInfo
+		wantErr     bool
+	}{
+		{
+			name: "not requested",
+		},
+		{
+			name:        "enabled by netconf",
+			accelerated: &cni.AcceleratedNetworking{Enabled: true, DisableFallback: true},
+			want:        &network.AcceleratedNetworkingInfo{DisableFallback: true},
+		},
+		{
+			name:        "annotated",
+			annotations: map[string]string{cni.AcceleratedNetworkingAnnotation: "true"},
+			want:        &network.AcceleratedNetworkingInfo{},
+		},
+		{
+			name:        "opted out",
+			accelerated: &cni.AcceleratedNetworking{Enabled: true},
+			annotations: map[string]string{cni.AcceleratedNetworkingAnnotation: "false"},
+		},
+		{
+			name:        "invalid annotation",
+			annotations: map[string]string{cni.AcceleratedNetworkingAnnotation: "sometimes"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{
+				WindowsSettings: cni.WindowsSettings{AcceleratedNetworking: tt.accelerated},
+				RuntimeConfig:   cni.RuntimeConfig{PodAnnotations: tt.annotations},
+			}
+			got, err := acceleratedNetworkingInfo(nwCfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.240.0.5/16")
+	res := &cniTypesCurr.Result{
+		CNIVersion: "1.0.0",
+		IPs:        []*cniTypesCurr.IPConfig{{Address: *ipNet}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, printResult(&out, res, ""))
+	require.NotContains(t, out.String(), datapathResultKey)
+
+	out.Reset()
+	require.NoError(t, printResult(&out, res, network.DatapathSynthetic))
+	var printed struct {
+		cniTypesCurr.Result
+		Datapath string `json:"datapath"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	require.Equal(t, network.DatapathSynthetic, printed.Datapath)
+	require.Equal(t, "1.0.0", printed.CNIVersion)
+	require.Len(t, printed.IPs, 1)
+}
@@ -13,4 +13,6 @@ type cnsclient interface {
 	ReleaseIPs(ctx context.Context, ipconfig cns.IPConfigsRequest) error
 	GetNetworkContainer(ctx context.Context, orchestratorContext []byte) (*cns.GetNetworkContainerResponse, error)
 	GetAllNetworkContainers(ctx context.Context, orchestratorContext []byte) ([]cns.GetNetworkContainerResponse, error)
+	GetPendingReleaseIPs(ctx context.Context) ([]cns.PendingReleaseIPConfig, error)
+	ConfirmPendingRelease(ctx context.Context, ipConfigIDs []string) ([]cns.PendingReleaseIPConfig, error)
 }
@@ -0,0 +1,75 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// crashReportPattern matches the files of the crash reports in their directory.
+	crashReportPattern = "azure-vnet-crash-*.json"
+	// maxCrashReports bounds the crash reports kept in their directory, the oldest are removed first.
+	maxCrashReports = 10
+)
+
+// cniEnvVars are the environment variables of a CNI command which are kept in a crash report.
+var cniEnvVars = []string{"CNI_COMMAND", "CNI_CONTAINERID", "CNI_NETNS", "CNI_IFNAME", "CNI_ARGS", "CNI_PATH"}
+
+// NewCrashReport returns the report of a panic of the command with the network configuration from stdin.
+func NewCrashReport(recovered interface{}, stack []byte, version string, stdinData []byte) api.CrashReport {
+	env := make(map[string]string, len(cniEnvVars))
+	for _, name := range cniEnvVars {
+		env[name] = os.Getenv(name)
+	}
+
+	return api.CrashReport{
+		Command:   env["CNI_COMMAND"],
+		Version:   version,
+		Timestamp: time.Now().UTC(),
+		Panic:     fmt.Sprintf("%v", recovered),
+		Stack:     string(stack),
+		Env:       env,
+		Config:    sanitizeNetworkConfig(stdinData),
+		LogLines:  recentLogLines(log.GetLogFileName(), "", os.Getpid()),
+	}
+}
+
+// WriteCrashReport writes the report to a new file in the directory and returns its path. The oldest reports
+// beyond maxCrashReports are removed, so that a plugin which keeps panicking doesn't fill the disk.
+func WriteCrashReport(dir string, report *api.CrashReport) (string, error) {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal crash report")
+	}
+
+	name := fmt.Sprintf("azure-vnet-crash-%s-%d.json", report.Timestamp.Format("20060102T150405.000"), os.Getpid())
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return "", errors.Wrap(err, "failed to write crash report")
+	}
+
+	removeOldCrashReports(dir, maxCrashReports)
+	return path, nil
+}
+
+func removeOldCrashReports(dir string, max int) {
+	paths, err := filepath.Glob(filepath.Join(dir, crashReportPattern))
+	if err != nil || len(paths) <= max {
+		return
+	}
+	// the names start with the timestamp of the report
+	sort.Strings(paths)
+	for _, path := range paths[:len(paths)-max] {
+		if err := os.Remove(path); err != nil {
+			log.Errorf("[cni-net] Failed to remove crash report %s: %v", path, err)
+		}
+	}
+}
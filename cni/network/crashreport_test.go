@@ -0,0 +1,57 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	t.Setenv("CNI_COMMAND", "ADD")
+	t.Setenv("CNI_ARGS", "K8S_POD_NAME=pod;K8S_POD_NAMESPACE=ns")
+	dir := t.TempDir()
+
+	report := NewCrashReport("boom", []byte("goroutine 1 [running]:"), "v1.0.0",
+		[]byte(`{"name":"azure","ipam":{"type":"azure-cns","apiToken":"t0k3n"}}`))
+	path, err := WriteCrashReport(dir, &report)
+	require.NoError(t, err)
+	require.Equal(t, dir, filepath.Dir(path))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written api.CrashReport
+	require.NoError(t, json.Unmarshal(b, &written))
+	require.Equal(t, "ADD", written.Command)
+	require.Equal(t, "boom", written.Panic)
+	require.Equal(t, "goroutine 1 [running]:", written.Stack)
+	require.Equal(t, "K8S_POD_NAME=pod;K8S_POD_NAMESPACE=ns", written.Env["CNI_ARGS"])
+	require.NotContains(t, string(written.Config), "t0k3n")
+	require.Contains(t, string(written.Config), "azure-cns")
+}
+
+func TestWriteCrashReportRemovesOldest(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxCrashReports+2; i++ {
+		report := api.CrashReport{Panic: fmt.Sprint(i), Timestamp: start.Add(time.Duration(i) * time.Second)}
+		_, err := WriteCrashReport(dir, &report)
+		require.NoError(t, err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, crashReportPattern))
+	require.NoError(t, err)
+	require.Len(t, paths, maxCrashReports)
+
+	// the two oldest reports are removed
+	b, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	var oldest api.CrashReport
+	require.NoError(t, json.Unmarshal(b, &oldest))
+	require.Equal(t, "2", oldest.Panic)
+}
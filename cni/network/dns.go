@@ -0,0 +1,49 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/cni"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+)
+
+// resolveDNS returns the DNS settings of the pods of the network, resolved field by field: each of the nameservers,
+// search domains and options comes from the first of these sources which sets it
+//  1. the dns capability of the runtimeConfig, which the runtime sets from the DNS config of the pod,
+//  2. the dns of the network configuration, which overrides the DNS of all the pods of the network,
+//  3. the DNS returned by IPAM, if any.
+//
+// The domain isn't part of the dns capability, so it comes from the network configuration or IPAM only.
+// On Windows the settings are applied to the HNS endpoint, while on Linux they are returned in the result, for the
+// runtime to write the resolv.conf of the pod.
+func resolveDNS(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result) cniTypes.DNS {
+	var dns cniTypes.DNS
+	if result != nil {
+		dns = result.DNS
+	}
+
+	if len(nwCfg.DNS.Nameservers) > 0 {
+		dns.Nameservers = nwCfg.DNS.Nameservers
+	}
+	if nwCfg.DNS.Domain != "" {
+		dns.Domain = nwCfg.DNS.Domain
+	}
+	if len(nwCfg.DNS.Search) > 0 {
+		dns.Search = nwCfg.DNS.Search
+	}
+	if len(nwCfg.DNS.Options) > 0 {
+		dns.Options = nwCfg.DNS.Options
+	}
+
+	runtimeDNS := nwCfg.RuntimeConfig.DNS
+	if len(runtimeDNS.Servers) > 0 {
+		dns.Nameservers = runtimeDNS.Servers
+	}
+	if len(runtimeDNS.Searches) > 0 {
+		dns.Search = runtimeDNS.Searches
+	}
+	if len(runtimeDNS.Options) > 0 {
+		dns.Options = runtimeDNS.Options
+	}
+
+	return dns
+}
@@ -0,0 +1,71 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDNS(t *testing.T) {
+	ipamResult := &cniTypesCurr.Result{
+		DNS: cniTypes.DNS{Nameservers: []string{"168.63.129.16"}, Domain: "ipam.internal"},
+	}
+	netconfDNS := cniTypes.DNS{
+		Nameservers: []string{"10.0.0.10"},
+		Search:      []string{"svc.cluster.local"},
+		Options:     []string{"ndots:2"},
+	}
+	runtimeDNS := cni.RuntimeDNSConfig{
+		Servers:  []string{"10.0.0.53"},
+		Searches: []string{"default.svc.cluster.local", "svc.cluster.local"},
+	}
+
+	tests := []struct {
+		name   string
+		nwCfg  cni.NetworkConfig
+		result *cniTypesCurr.Result
+		want   cniTypes.DNS
+	}{
+		{
+			name:   "ipam",
+			result: ipamResult,
+			want:   ipamResult.DNS,
+		},
+		{
+			name:   "netconf overrides ipam",
+			nwCfg:  cni.NetworkConfig{DNS: netconfDNS},
+			result: ipamResult,
+			want: cniTypes.DNS{
+				Nameservers: []string{"10.0.0.10"},
+				Domain:      "ipam.internal",
+				Search:      []string{"svc.cluster.local"},
+				Options:     []string{"ndots:2"},
+			},
+		},
+		{
+			name:   "runtime overrides netconf by field",
+			nwCfg:  cni.NetworkConfig{DNS: netconfDNS, RuntimeConfig: cni.RuntimeConfig{DNS: runtimeDNS}},
+			result: ipamResult,
+			want: cniTypes.DNS{
+				Nameservers: []string{"10.0.0.53"},
+				Domain:      "ipam.internal",
+				Search:      []string{"default.svc.cluster.local", "svc.cluster.local"},
+				Options:     []string{"ndots:2"},
+			},
+		},
+		{
+			name:  "runtime without ipam",
+			nwCfg: cni.NetworkConfig{RuntimeConfig: cni.RuntimeConfig{DNS: cni.RuntimeDNSConfig{Options: []string{"ndots:5"}}}},
+			want:  cniTypes.DNS{Options: []string{"ndots:5"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, resolveDNS(&tt.nwCfg, tt.result))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package network
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/pkg/errors"
+)
+
+// passthroughPolicyData is the data of a passthrough policy. On Windows, it's an HNS v2 endpoint policy.
+type passthroughPolicyData struct {
+	Type     string
+	Settings json.RawMessage `json:",omitempty"`
+}
+
+// getPassthroughEndpointPolicies converts the policies of NCs, which CNS returns for the CNI to apply verbatim
+// to the endpoint, to passthrough policies. Duplicates, e.g. from the IPv4 and IPv6 configs of the same NC, are dropped.
+func getPassthroughEndpointPolicies(ncPolicies []cns.NetworkContainerRequestPolicies) ([]policy.Policy, error) {
+	var policies []policy.Policy
+	seen := make(map[string]struct{}, len(ncPolicies))
+	for _, ncPolicy := range ncPolicies {
+		data, err := json.Marshal(passthroughPolicyData{Type: ncPolicy.Type, Settings: ncPolicy.Settings})
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s endpoint policy from CNS", ncPolicy.Type)
+		}
+		if _, ok := seen[string(data)]; ok {
+			continue
+		}
+		seen[string(data)] = struct{}{}
+		policies = append(policies, policy.Policy{
+			Type: policy.PassthroughEndpointPolicy,
+			Data: data,
+		})
+	}
+	return policies, nil
+}
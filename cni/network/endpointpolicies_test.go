@@ -0,0 +1,77 @@
+package network
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPassthroughEndpointPolicies(t *testing.T) {
+	route := cns.NetworkContainerRequestPolicies{
+		Type:         "SDNRoute",
+		EndpointType: cns.PodEndpointType,
+		Settings:     json.RawMessage(`{"DestinationPrefix":"10.0.0.0/8","NeedEncap":true}`),
+	}
+	acl := cns.NetworkContainerRequestPolicies{
+		Type:         "ACL",
+		EndpointType: cns.PodEndpointType,
+		Settings:     json.RawMessage(`{"Action":"Block","Direction":"Out"}`),
+	}
+
+	tests := []struct {
+		name       string
+		ncPolicies []cns.NetworkContainerRequestPolicies
+		want       []policy.Policy
+		wantErr    bool
+	}{
+		{
+			name: "no policies",
+		},
+		{
+			name:       "policies are passed through",
+			ncPolicies: []cns.NetworkContainerRequestPolicies{route, acl},
+			want: []policy.Policy{
+				{
+					Type: policy.PassthroughEndpointPolicy,
+					Data: json.RawMessage(`{"Type":"SDNRoute","Settings":{"DestinationPrefix":"10.0.0.0/8","NeedEncap":true}}`),
+				},
+				{
+					Type: policy.PassthroughEndpointPolicy,
+					Data: json.RawMessage(`{"Type":"ACL","Settings":{"Action":"Block","Direction":"Out"}}`),
+				},
+			},
+		},
+		{
+			name:       "duplicates from the ipv4 and ipv6 configs of an NC are dropped",
+			ncPolicies: []cns.NetworkContainerRequestPolicies{route, route},
+			want: []policy.Policy{
+				{
+					Type: policy.PassthroughEndpointPolicy,
+					Data: json.RawMessage(`{"Type":"SDNRoute","Settings":{"DestinationPrefix":"10.0.0.0/8","NeedEncap":true}}`),
+				},
+			},
+		},
+		{
+			name: "invalid settings",
+			ncPolicies: []cns.NetworkContainerRequestPolicies{
+				{Type: "ACL", EndpointType: cns.PodEndpointType, Settings: json.RawMessage(`{invalid`)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getPassthroughEndpointPolicies(tt.ncPolicies)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
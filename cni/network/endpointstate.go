@@ -0,0 +1,98 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+)
+
+// DefaultWatchInterval is how often the endpoint state is read in watch mode.
+const DefaultWatchInterval = time.Second
+
+// GetEndpointState returns the state of the endpoints which match the filter.
+func (plugin *NetPlugin) GetEndpointState(filter api.EndpointStateFilter) (*api.AzureCNIState, error) {
+	state, err := plugin.GetAllEndpointState(filter.NetworkOrDefault())
+	if err != nil {
+		return nil, err
+	}
+	return state.Filter(filter), nil
+}
+
+// WatchEndpointState writes the changes of the state of the endpoints which match the filter to w as JSON lines,
+// until the context is done. The endpoints of the first read are written as added.
+// The store must not be locked by the caller: it's locked only while the state is read,
+// so that CNI commands can run in between.
+func (plugin *NetPlugin) WatchEndpointState(
+	ctx context.Context,
+	config *common.PluginConfig,
+	filter api.EndpointStateFilter,
+	interval time.Duration,
+	w io.Writer,
+) error {
+	return watchEndpointState(ctx, interval, func() (*api.AzureCNIState, error) {
+		return plugin.readEndpointState(config, filter)
+	}, w)
+}
+
+// readEndpointState reloads the network manager from the store and returns the filtered endpoint state.
+func (plugin *NetPlugin) readEndpointState(config *common.PluginConfig, filter api.EndpointStateFilter) (*api.AzureCNIState, error) {
+	if err := plugin.Plugin.InitializeKeyValueStore(config); err != nil {
+		return nil, errors.Wrap(err, "failed to lock store")
+	}
+	defer func() {
+		if err := plugin.Plugin.UninitializeKeyValueStore(); err != nil {
+			log.Errorf("Failed to unlock store: %v", err)
+		}
+	}()
+
+	// a new network manager, since restoring into the current one would keep deleted endpoints
+	nm, err := network.NewNetworkManager(netlink.NewNetlink(), platform.NewExecClient(), &netio.NetIO{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create network manager")
+	}
+	if err := nm.Initialize(config, false); err != nil {
+		return nil, errors.Wrap(err, "failed to restore network manager")
+	}
+	plugin.nm = nm
+
+	return plugin.GetEndpointState(filter)
+}
+
+// watchEndpointState reads the state every interval and writes its changes to w as JSON lines, until ctx is done.
+// Failed reads are logged and retried on the next tick.
+func watchEndpointState(ctx context.Context, interval time.Duration, read func() (*api.AzureCNIState, error), w io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(w)
+	var last *api.AzureCNIState
+	for {
+		state, err := read()
+		if err != nil {
+			log.Errorf("Failed to read endpoint state, retrying in %v: %v", interval, err)
+		} else {
+			for _, event := range api.DiffEndpointState(last, state) {
+				if err := enc.Encode(event); err != nil {
+					return errors.Wrap(err, "failed to write endpoint state event")
+				}
+			}
+			last = state
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchEndpointState(t *testing.T) {
+	states := []*api.AzureCNIState{
+		{ContainerInterfaces: map[string]api.PodNetworkInterfaceInfo{
+			"ep1": {PodName: "a"},
+		}},
+		nil, // failed read
+		{ContainerInterfaces: map[string]api.PodNetworkInterfaceInfo{
+			"ep1": {PodName: "a"},
+		}},
+		{ContainerInterfaces: map[string]api.PodNetworkInterfaceInfo{
+			"ep2": {PodName: "b"},
+		}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reads := 0
+	read := func() (*api.AzureCNIState, error) {
+		defer func() { reads++ }()
+		if reads == len(states)-1 {
+			cancel()
+		}
+		if states[reads] == nil {
+			return nil, errors.New("failed to lock store")
+		}
+		return states[reads], nil
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, watchEndpointState(ctx, time.Millisecond, read, &buf))
+	require.Equal(t, len(states), reads)
+
+	var events []api.EndpointStateEvent
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event api.EndpointStateEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.Equal(t, []api.EndpointStateEvent{
+		{Type: api.EndpointAdded, EndpointID: "ep1", Endpoint: api.PodNetworkInterfaceInfo{PodName: "a"}},
+		{Type: api.EndpointDeleted, EndpointID: "ep1", Endpoint: api.PodNetworkInterfaceInfo{PodName: "a"}},
+		{Type: api.EndpointAdded, EndpointID: "ep2", Endpoint: api.PodNetworkInterfaceInfo{PodName: "b"}},
+	}, events)
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Azure/azure-container-networking/cni"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// Plugin specific CNI error codes. The CNI spec reserves codes below 100 for well-known errors.
+const (
+	// ErrCodeIPAMExhausted is returned when there is no free IP for the pod, until the IP pool grows.
+	ErrCodeIPAMExhausted uint = 110
+	// ErrCodeHNSFailure is returned when an HNS call to program the network or endpoint failed.
+	ErrCodeHNSFailure uint = 111
+	// ErrCodeRequestedIPUnavailable is returned when the static IP requested for the pod isn't in the subnet or NC,
+	// or is in use by another pod. The IPAM plugins return it too.
+	ErrCodeRequestedIPUnavailable uint = cni.ErrRequestedIPUnavailable
+)
+
+// Failure classes of CNI commands. Errors wrapping them are returned with the CNI error code of the class,
+// instead of the generic cni.ErrRuntime, so that the runtime and operators can tell them apart.
+var (
+	ErrIPAMExhausted  = errors.New("no IP available for the pod")
+	ErrCNSUnreachable = errors.New("cns is unreachable")
+	ErrHNSFailure     = errors.New("hns call failed")
+	ErrNetnsNotFound  = errors.New("network namespace of the container does not exist")
+	// ErrRequestedIPUnavailable is returned when the static IP requested for the pod can't be assigned to it.
+	ErrRequestedIPUnavailable = errors.New("requested IP is unavailable for the pod")
+)
+
+// errorClass is the CNI error code and the machine-readable details of a failure class.
+type errorClass struct {
+	code uint
+	// Reason is a stable name of the failure class.
+	Reason string `json:"reason"`
+	// Retriable is true if retrying the command can succeed without any change to the pod or node.
+	Retriable bool `json:"retriable"`
+}
+
+var (
+	ipamExhaustedClass  = errorClass{code: ErrCodeIPAMExhausted, Reason: "IPAMExhausted", Retriable: true}
+	cnsUnreachableClass = errorClass{code: cniTypes.ErrTryAgainLater, Reason: "CNSUnreachable", Retriable: true}
+	hnsFailureClass     = errorClass{code: ErrCodeHNSFailure, Reason: "HNSFailure", Retriable: true}
+	netnsNotFoundClass  = errorClass{code: cniTypes.ErrUnknownContainer, Reason: "NetnsNotFound", Retriable: false}
+	// retrying can't succeed until the IP is released by the pod which holds it, so retries are up to the operator
+	requestedIPUnavailableClass = errorClass{code: ErrCodeRequestedIPUnavailable, Reason: "RequestedIPUnavailable", Retriable: false}
+	invalidRequestedIPClass     = errorClass{code: cniTypes.ErrInvalidEnvironmentVariables, Reason: "InvalidRequestedIP", Retriable: false}
+)
+
+// classifyError returns the failure class of the error chain.
+func classifyError(err error) (errorClass, bool) {
+	var (
+		cnsErr *cnscli.CNSClientError
+		cniErr *cniTypes.Error
+	)
+	switch {
+	case errors.Is(err, ErrIPAMExhausted),
+		errors.As(err, &cnsErr) && cnsErr.Code == types.AddressUnavailable:
+		return ipamExhaustedClass, true
+	case errors.Is(err, ErrCNSUnreachable):
+		return cnsUnreachableClass, true
+	case errors.Is(err, ErrHNSFailure):
+		return hnsFailureClass, true
+	case errors.Is(err, ErrNetnsNotFound):
+		return netnsNotFoundClass, true
+	case errors.Is(err, ErrRequestedIPUnavailable),
+		errors.As(err, &cnsErr) && cnsErr.Code == types.DesiredIPUnavailable,
+		errors.As(err, &cniErr) && cniErr.Code == ErrCodeRequestedIPUnavailable:
+		return requestedIPUnavailableClass, true
+	case errors.Is(err, cni.ErrInvalidRequestedIP):
+		return invalidRequestedIPClass, true
+	default:
+		return errorClass{}, false
+	}
+}
+
+// toCNIError converts the error of a CNI command to a CNI error with the code of its failure class,
+// and the class as JSON details. Errors of no known class are returned unchanged.
+func toCNIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	class, ok := classifyError(err)
+	if !ok {
+		return err
+	}
+	details, _ := json.Marshal(class)
+	return cniTypes.NewError(class.code, err.Error(), string(details))
+}
+
+// wrapCNSError marks errors of CNS requests which didn't get a response with ErrCNSUnreachable.
+func wrapCNSError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%w: %w", ErrCNSUnreachable, err)
+	}
+	return err
+}
+
+// Error creates and logs a structured CNI error, with the code of the failure class of the error.
+func (plugin *NetPlugin) Error(err error) *cniTypes.Error {
+	return plugin.Plugin.Error(toCNIError(err))
+}
+
+// Errorf creates and logs a CNI error according to a format specifier, with the code of the failure class
+// of the errors wrapped with %w.
+func (plugin *NetPlugin) Errorf(format string, args ...interface{}) *cniTypes.Error {
+	return plugin.Error(fmt.Errorf(format, args...)) //nolint:goerr113 // the format wraps the errors
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToCNIError(t *testing.T) {
+	urlErr := &url.Error{Op: "Post", URL: "http://localhost:10090", Err: errors.New("connection refused")}
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    uint
+		wantDetails string
+	}{
+		{
+			name:        "ipam exhausted",
+			err:         fmt.Errorf("failed to allocate pool: %w", ErrIPAMExhausted),
+			wantCode:    ErrCodeIPAMExhausted,
+			wantDetails: `{"reason":"IPAMExhausted","retriable":true}`,
+		},
+		{
+			name: "cns returned address unavailable",
+			err: pkgerrors.Wrap(&cnscli.CNSClientError{
+				Code: types.AddressUnavailable,
+				Err:  errors.New("not enough IPs available"),
+			}, "failed to get IP address from CNS"),
+			wantCode:    ErrCodeIPAMExhausted,
+			wantDetails: `{"reason":"IPAMExhausted","retriable":true}`,
+		},
+		{
+			name:        "cns unreachable",
+			err:         pkgerrors.Wrap(wrapCNSError(urlErr), "failed to get IP address from CNS"),
+			wantCode:    cniTypes.ErrTryAgainLater,
+			wantDetails: `{"reason":"CNSUnreachable","retriable":true}`,
+		},
+		{
+			name:        "hns failure",
+			err:         fmt.Errorf("Failed to create endpoint: %w", fmt.Errorf("%w: %w", ErrHNSFailure, errors.New("hcn error"))),
+			wantCode:    ErrCodeHNSFailure,
+			wantDetails: `{"reason":"HNSFailure","retriable":true}`,
+		},
+		{
+			name:        "netns not found",
+			err:         fmt.Errorf("Failed to create endpoint: %w", ErrNetnsNotFound),
+			wantCode:    cniTypes.ErrUnknownContainer,
+			wantDetails: `{"reason":"NetnsNotFound","retriable":false}`,
+		},
+		{
+			name:        "requested ip not in subnet",
+			err:         fmt.Errorf("Failed to allocate pool: %w", ErrRequestedIPUnavailable),
+			wantCode:    ErrCodeRequestedIPUnavailable,
+			wantDetails: `{"reason":"RequestedIPUnavailable","retriable":false}`,
+		},
+		{
+			name: "cns returned desired ip unavailable",
+			err: pkgerrors.Wrap(&cnscli.CNSClientError{
+				Code: types.DesiredIPUnavailable,
+				Err:  errors.New("desired IP is unavailable"),
+			}, "failed to get IP address from CNS"),
+			wantCode:    ErrCodeRequestedIPUnavailable,
+			wantDetails: `{"reason":"RequestedIPUnavailable","retriable":false}`,
+		},
+		{
+			name:        "ipam plugin returned requested ip unavailable",
+			err:         fmt.Errorf("Failed to allocate pool: %w", cniTypes.NewError(ErrCodeRequestedIPUnavailable, "Failed to allocate requested address", "")),
+			wantCode:    ErrCodeRequestedIPUnavailable,
+			wantDetails: `{"reason":"RequestedIPUnavailable","retriable":false}`,
+		},
+		{
+			name:        "invalid requested ip",
+			err:         fmt.Errorf("failed to get requested IPs: %w", cni.ErrInvalidRequestedIP),
+			wantCode:    cniTypes.ErrInvalidEnvironmentVariables,
+			wantDetails: `{"reason":"InvalidRequestedIP","retriable":false}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var cniErr *cniTypes.Error
+			require.ErrorAs(t, toCNIError(tt.err), &cniErr)
+			require.Equal(t, tt.wantCode, cniErr.Code)
+			require.Equal(t, tt.err.Error(), cniErr.Msg)
+			require.JSONEq(t, tt.wantDetails, cniErr.Details)
+		})
+	}
+}
+
+func TestToCNIErrorUnchanged(t *testing.T) {
+	require.NoError(t, toCNIError(nil))
+
+	err := errors.New("unknown failure")
+	require.Equal(t, err, toCNIError(err))
+
+	cniErr := cniTypes.NewError(cniTypes.ErrInvalidNetworkConfig, "invalid config", "")
+	require.Equal(t, cniErr, toCNIError(cniErr))
+
+	cnsErr := &cnscli.CNSClientError{Code: types.FailedToAllocateIPConfig, Err: errors.New("failed")}
+	require.Equal(t, cnsErr, toCNIError(cnsErr))
+}
+
+func TestWrapCNSError(t *testing.T) {
+	require.ErrorIs(t, wrapCNSError(&url.Error{Op: "Post", URL: "http://localhost:10090", Err: errors.New("timeout")}), ErrCNSUnreachable)
+
+	err := &cnscli.CNSClientError{Code: types.UnexpectedError, Err: errors.New("failed")}
+	require.Equal(t, err, wrapCNSError(err))
+}
@@ -0,0 +1,191 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/store"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+const (
+	// failureRecordsKey is the key of the failure records in the store of the plugin.
+	failureRecordsKey = "FailureRecords"
+	// maxFailureRecords bounds the store, the oldest records are dropped first.
+	maxFailureRecords = 20
+	// maxFailureLogLines is the number of log lines kept in a record.
+	maxFailureLogLines = 50
+	// failureLogTailBytes is how much of the end of the log file is searched for the lines of a record.
+	failureLogTailBytes = 256 * 1024
+	// redactedValue replaces the values of the sensitive fields of the network configuration.
+	redactedValue = "REDACTED"
+)
+
+// sensitiveConfigKeys are the substrings of the names of the network configuration fields whose values are redacted.
+var sensitiveConfigKeys = []string{"token", "secret", "password", "credential", "cert"}
+
+// recordFailure stores a snapshot of the environment of a failed command, replacing the previous failure
+// of the container. Failing to record is only logged, so that the error of the command is returned.
+func (plugin *NetPlugin) recordFailure(command string, args *cniSkel.CmdArgs, cmdErr error) {
+	if plugin.Store == nil {
+		return
+	}
+
+	record := newFailureRecord(command, args, cmdErr, log.GetLogFileName(), os.Getpid())
+	records, err := readFailureRecords(plugin.Store)
+	if err != nil {
+		log.Errorf("[cni-net] Failed to read failure records: %v", err)
+		return
+	}
+	records[args.ContainerID] = record
+	trimFailureRecords(records, maxFailureRecords)
+
+	if err := plugin.Store.Write(failureRecordsKey, records); err != nil {
+		log.Errorf("[cni-net] Failed to record failure of %s for container %s: %v", command, args.ContainerID, err)
+		return
+	}
+	log.Printf("[cni-net] Recorded failure of %s for container %s", command, args.ContainerID)
+}
+
+// readFailureRecords returns the failure records in the store, by container ID.
+func readFailureRecords(kvs store.KeyValueStore) (map[string]api.FailureRecord, error) {
+	records := make(map[string]api.FailureRecord)
+	if err := kvs.Read(failureRecordsKey, &records); err != nil &&
+		!errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+		return nil, errors.Wrap(err, "failed to read store")
+	}
+	return records, nil
+}
+
+// trimFailureRecords drops the oldest records until at most max are left.
+func trimFailureRecords(records map[string]api.FailureRecord, max int) {
+	if len(records) <= max {
+		return
+	}
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return records[ids[i]].Timestamp.Before(records[ids[j]].Timestamp)
+	})
+	for _, id := range ids[:len(ids)-max] {
+		delete(records, id)
+	}
+}
+
+func newFailureRecord(command string, args *cniSkel.CmdArgs, cmdErr error, logFileName string, pid int) api.FailureRecord {
+	record := api.FailureRecord{
+		Command:     command,
+		ContainerID: args.ContainerID,
+		Timestamp:   time.Now().UTC(),
+		Error:       cmdErr.Error(),
+		Env: map[string]string{
+			"CNI_COMMAND":     command,
+			"CNI_CONTAINERID": args.ContainerID,
+			"CNI_NETNS":       args.Netns,
+			"CNI_IFNAME":      args.IfName,
+			"CNI_ARGS":        args.Args,
+			"CNI_PATH":        args.Path,
+		},
+		Config:   sanitizeNetworkConfig(args.StdinData),
+		Versions: getPlatformVersions(),
+		LogLines: recentLogLines(logFileName, args.ContainerID, pid),
+	}
+
+	if podCfg, err := cni.ParseCniArgs(args.Args); err == nil {
+		record.PodName = string(podCfg.K8S_POD_NAME)
+		record.PodNamespace = string(podCfg.K8S_POD_NAMESPACE)
+	}
+
+	return record
+}
+
+// sanitizeNetworkConfig returns the network configuration with the values of its sensitive fields redacted,
+// or nil if it isn't JSON.
+func sanitizeNetworkConfig(stdinData []byte) json.RawMessage {
+	var config interface{}
+	if err := json.Unmarshal(stdinData, &config); err != nil {
+		return nil
+	}
+	b, err := json.Marshal(redactSensitiveValues(config))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func redactSensitiveValues(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isSensitiveConfigKey(key) {
+				value[key] = redactedValue
+			} else {
+				value[key] = redactSensitiveValues(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redactSensitiveValues(value[i])
+		}
+	}
+	return v
+}
+
+func isSensitiveConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveConfigKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// recentLogLines returns the last lines of the end of the log file which were logged by the process,
+// or which mention the container, so that the earlier attempts of the same container are included.
+func recentLogLines(logFileName, containerID string, pid int) []string {
+	f, err := os.Open(logFileName)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > failureLogTailBytes {
+		if _, err := f.Seek(-failureLogTailBytes, io.SeekEnd); err != nil {
+			return nil
+		}
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	pidTag := fmt.Sprintf("[%d] ", pid)
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(tail))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), failureLogTailBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, pidTag) || (containerID != "" && strings.Contains(line, containerID)) {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > maxFailureLogLines {
+		lines = lines[len(lines)-maxFailureLogLines:]
+	}
+	return lines
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/store"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+var errMockFailure = errors.New("mock failure")
+
+func TestSanitizeNetworkConfig(t *testing.T) {
+	config := `{"name":"azure","ipam":{"type":"azure-cns","apiToken":"t0k3n"},` +
+		`"AdditionalArgs":[{"name":"EndpointPolicy","value":{"ClientSecret":"s3cr3t"}}]}`
+
+	sanitized := string(sanitizeNetworkConfig([]byte(config)))
+	require.NotContains(t, sanitized, "t0k3n")
+	require.NotContains(t, sanitized, "s3cr3t")
+	require.Contains(t, sanitized, `"apiToken":"REDACTED"`)
+	require.Contains(t, sanitized, `"ClientSecret":"REDACTED"`)
+	require.Contains(t, sanitized, `"type":"azure-cns"`)
+
+	require.Nil(t, sanitizeNetworkConfig([]byte("not json")))
+}
+
+func TestTrimFailureRecords(t *testing.T) {
+	now := time.Now()
+	records := map[string]api.FailureRecord{}
+	for i := 0; i < 5; i++ {
+		records[fmt.Sprintf("c%d", i)] = api.FailureRecord{Timestamp: now.Add(time.Duration(i) * time.Second)}
+	}
+
+	trimFailureRecords(records, 3)
+	require.Len(t, records, 3)
+	require.NotContains(t, records, "c0")
+	require.NotContains(t, records, "c1")
+	require.Contains(t, records, "c4")
+}
+
+func TestRecentLogLines(t *testing.T) {
+	logFileName := filepath.Join(t.TempDir(), "azure-vnet.log")
+	lines := []string{
+		"2023/01/01 00:00:00 [100] [cni-net] Processing ADD command with args {ContainerID:abcd1234}",
+		"2023/01/01 00:00:01 [100] [cni-net] Failed to allocate pool",
+		"2023/01/01 00:00:02 [200] [cni-net] Processing ADD command with args {ContainerID:efgh5678}",
+		"2023/01/01 00:00:03 [300] [cni-net] Processing ADD command with args {ContainerID:abcd1234}",
+		"2023/01/01 00:00:04 [300] [cni-net] Failed to create endpoint",
+	}
+	require.NoError(t, os.WriteFile(logFileName, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	got := recentLogLines(logFileName, "abcd1234", 300)
+	require.Equal(t, []string{lines[0], lines[3], lines[4]}, got, "lines of the process and of the container should be returned")
+
+	require.Nil(t, recentLogLines(filepath.Join(t.TempDir(), "missing.log"), "abcd1234", 300))
+}
+
+func TestRecordFailure(t *testing.T) {
+	plugin := GetTestResources()
+	plugin.Store = store.NewMockStore("")
+
+	args := &cniSkel.CmdArgs{
+		ContainerID: "abcd1234",
+		Netns:       "/var/run/netns/test",
+		IfName:      "eth0",
+		Args:        "K8S_POD_NAMESPACE=default;K8S_POD_NAME=nginx",
+		StdinData:   []byte(`{"name":"azure","type":"azure-vnet"}`),
+	}
+	plugin.recordFailure(CNI_ADD, args, errMockFailure)
+
+	state, err := plugin.GetAllEndpointState("azure")
+	require.NoError(t, err)
+	require.Len(t, state.FailureRecords, 1)
+
+	record := state.FailureRecords["abcd1234"]
+	require.Equal(t, CNI_ADD, record.Command)
+	require.Equal(t, errMockFailure.Error(), record.Error)
+	require.Equal(t, "nginx", record.PodName)
+	require.Equal(t, "default", record.PodNamespace)
+	require.Equal(t, "/var/run/netns/test", record.Env["CNI_NETNS"])
+	require.JSONEq(t, `{"name":"azure","type":"azure-vnet"}`, string(record.Config))
+
+	// a later failure of the same container replaces its record
+	plugin.recordFailure(CNI_DEL, args, errMockFailure)
+	state, err = plugin.GetAllEndpointState("azure")
+	require.NoError(t, err)
+	require.Len(t, state.FailureRecords, 1)
+	require.Equal(t, CNI_DEL, state.FailureRecords["abcd1234"].Command)
+}
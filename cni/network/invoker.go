@@ -5,6 +5,8 @@ import (
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/Azure/azure-container-networking/telemetry"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 )
@@ -20,6 +22,13 @@ type IPAMInvoker interface {
 	Delete(address *net.IPNet, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, options map[string]interface{}) error
 }
 
+// pendingReleaseConfirmer is implemented by IPAMInvokers whose IPAM source waits for the CNI to confirm
+// that the IPs it is releasing from the node are no longer used by any endpoint.
+type pendingReleaseConfirmer interface {
+	// ConfirmPendingRelease confirms the release of the pending IPs which aren't in endpointIPs.
+	ConfirmPendingRelease(endpointIPs map[string]struct{}) error
+}
+
 type IPAMAddConfig struct {
 	nwCfg   *cni.NetworkConfig
 	args    *cniSkel.CmdArgs
@@ -31,4 +40,41 @@ type IPAMAddResult struct {
 	ipv6Result       *cniTypesCurr.Result
 	ncResponse       *cns.GetNetworkContainerResponse
 	hostSubnetPrefix net.IPNet
+	// endpointPolicies are the policies from CNS which are applied verbatim to the endpoint.
+	endpointPolicies []policy.Policy
+	// homeAz is the home availability zone of the node returned by CNS, 0 if it isn't known.
+	homeAz uint
+}
+
+// timedIPAMInvoker records the time spent in the wrapped IPAMInvoker as the IPAM phase of the CNI report.
+type timedIPAMInvoker struct {
+	IPAMInvoker
+	report *telemetry.CNIReport
+}
+
+func newTimedIPAMInvoker(invoker IPAMInvoker, report *telemetry.CNIReport) IPAMInvoker {
+	return &timedIPAMInvoker{IPAMInvoker: invoker, report: report}
+}
+
+func (t *timedIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, error) {
+	defer t.report.TrackPhase(telemetry.PhaseIPAM)()
+	result, err := t.IPAMInvoker.Add(addConfig)
+	if result.homeAz != 0 {
+		t.report.HomeAz = result.homeAz
+	}
+	return result, err //nolint:wrapcheck // passthrough
+}
+
+func (t *timedIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs, options map[string]interface{}) error {
+	defer t.report.TrackPhase(telemetry.PhaseIPAM)()
+	return t.IPAMInvoker.Delete(address, nwCfg, args, options) //nolint:wrapcheck // passthrough
+}
+
+func (t *timedIPAMInvoker) ConfirmPendingRelease(endpointIPs map[string]struct{}) error {
+	confirmer, ok := t.IPAMInvoker.(pendingReleaseConfirmer)
+	if !ok {
+		return nil
+	}
+	defer t.report.TrackPhase(telemetry.PhaseIPAM)()
+	return confirmer.ConfirmPendingRelease(endpointIPs) //nolint:wrapcheck // passthrough
 }
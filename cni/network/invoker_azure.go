@@ -56,6 +56,15 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 		addConfig.nwCfg.IPAM.Subnet = invoker.nwInfo.Subnets[0].Prefix.String()
 	}
 
+	requestedIPv4, requestedIPv6, err := invoker.requestedIPs(addConfig)
+	if err != nil {
+		return addResult, invoker.plugin.Errorf("Failed to get requested IPs: %w", err)
+	}
+	if requestedIPv4 != "" {
+		// the IPAM plugin reserves the requested address if it's free, and fails otherwise
+		addConfig.nwCfg.IPAM.Address = requestedIPv4
+	}
+
 	// Call into IPAM plugin to allocate an address pool for the network.
 	addResult.ipv4Result, err = invoker.plugin.DelegateAdd(addConfig.nwCfg.IPAM.Type, addConfig.nwCfg)
 
@@ -63,7 +72,7 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 		invoker.deleteIpamState()
 	}
 	if err != nil {
-		err = invoker.plugin.Errorf("Failed to allocate pool: %v", err)
+		err = invoker.plugin.Errorf("Failed to allocate pool: %w", err)
 		return addResult, err
 	}
 
@@ -83,6 +92,7 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 		nwCfg6 := *addConfig.nwCfg
 		nwCfg6.IPAM.Environment = common.OptEnvironmentIPv6NodeIpam
 		nwCfg6.IPAM.Type = ipamV6
+		nwCfg6.IPAM.Address = requestedIPv6
 
 		if len(invoker.nwInfo.Subnets) > 1 {
 			// ipv6 is the second subnet of the slice
@@ -91,7 +101,7 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 
 		addResult.ipv6Result, err = invoker.plugin.DelegateAdd(nwCfg6.IPAM.Type, &nwCfg6)
 		if err != nil {
-			err = invoker.plugin.Errorf("Failed to allocate v6 pool: %v", err)
+			err = invoker.plugin.Errorf("Failed to allocate v6 pool: %w", err)
 		}
 	}
 
@@ -100,6 +110,37 @@ func (invoker *AzureIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, er
 	return addResult, err
 }
 
+// requestedIPs returns the static IPv4 and IPv6 requested for the pod, if any, after checking that they are
+// in the subnets of the network.
+func (invoker *AzureIPAMInvoker) requestedIPs(addConfig IPAMAddConfig) (ipv4, ipv6 string, err error) {
+	var args string
+	if addConfig.args != nil {
+		args = addConfig.args.Args
+	}
+	ips, err := cni.RequestedIPs(addConfig.nwCfg, args)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, ip := range ips {
+		// ipv6 is the second subnet of the network
+		requested, subnetIndex := &ipv4, 0
+		if ip.To4() == nil {
+			requested, subnetIndex = &ipv6, 1
+		}
+		if *requested != "" {
+			return "", "", fmt.Errorf("%w: more than one IP of the family of %s", cni.ErrInvalidRequestedIP, ip)
+		}
+		if len(invoker.nwInfo.Subnets) > subnetIndex {
+			if subnet := invoker.nwInfo.Subnets[subnetIndex].Prefix; !subnet.Contains(ip) {
+				return "", "", fmt.Errorf("%w: %s is not in the subnet %s", ErrRequestedIPUnavailable, ip, subnet.String())
+			}
+		}
+		*requested = ip.String()
+	}
+	return ipv4, ipv6, nil
+}
+
 func (invoker *AzureIPAMInvoker) deleteIpamState() {
 	cniStateExists, err := platform.CheckIfFileExists(platform.CNIStateFilePath)
 	if err != nil {
@@ -0,0 +1,10 @@
+//go:build !minimal
+
+package network
+
+import "github.com/Azure/azure-container-networking/network"
+
+// newAzureIPAMInvoker returns the invoker of the azure-vnet-ipam plugin.
+func newAzureIPAMInvoker(plugin *NetPlugin, nwInfo *network.NetworkInfo) (IPAMInvoker, error) {
+	return NewAzureIpamInvoker(plugin, nwInfo), nil
+}
@@ -0,0 +1,15 @@
+//go:build minimal
+
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/pkg/errors"
+)
+
+var errIPAMNotSupported = errors.New("IPAM type is not supported by this build of the plugin")
+
+// newAzureIPAMInvoker fails, since the minimal build only supports azure-cns IPAM.
+func newAzureIPAMInvoker(*NetPlugin, *network.NetworkInfo) (IPAMInvoker, error) {
+	return nil, errors.Wrapf(errIPAMNotSupported, "only %s IPAM is supported", network.AzureCNS)
+}
@@ -0,0 +1,16 @@
+//go:build minimal
+
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinimalBuildOnlySupportsCNSIPAM(t *testing.T) {
+	invoker, err := newAzureIPAMInvoker(&NetPlugin{}, &network.NetworkInfo{})
+	require.ErrorIs(t, err, errIPAMNotSupported)
+	require.Nil(t, invoker)
+}
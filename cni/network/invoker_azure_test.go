@@ -67,7 +67,7 @@ func (d *del) DelegateDel(pluginName string, nwCfg *cni.NetworkConfig) error {
 func (m *mockDelegatePlugin) Errorf(format string, args ...interface{}) *cniTypes.Error {
 	return &cniTypes.Error{
 		Code:    1,
-		Msg:     fmt.Sprintf(format, args...),
+		Msg:     fmt.Errorf(format, args...).Error(),
 		Details: "",
 	}
 }
@@ -422,3 +422,76 @@ func TestRemoveIpamState_Add(t *testing.T) {
 		})
 	}
 }
+
+func TestAzureIPAMInvoker_AddRequestedIPs(t *testing.T) {
+	tests := []struct {
+		name      string
+		nwInfo    *network.NetworkInfo
+		nwCfg     *cni.NetworkConfig
+		cniArgs   string
+		wantIPv4  string
+		wantIPv6  string
+		wantErrIs error
+	}{
+		{
+			name:     "K8S_POD_IP",
+			nwInfo:   getNwInfo("10.0.0.0/24", ""),
+			nwCfg:    &cni.NetworkConfig{},
+			cniArgs:  "K8S_POD_IP=10.0.0.5",
+			wantIPv4: "10.0.0.5",
+		},
+		{
+			name:   "ips capability dualstack",
+			nwInfo: getNwInfo("10.0.0.0/24", "2001:db8:abcd:12::/64"),
+			nwCfg: &cni.NetworkConfig{
+				IPV6Mode:      network.IPV6Nat,
+				RuntimeConfig: cni.RuntimeConfig{IPs: []string{"10.0.0.5/24", "2001:db8:abcd:12::5/64"}},
+			},
+			wantIPv4: "10.0.0.5",
+			wantIPv6: "2001:db8:abcd:12::5",
+		},
+		{
+			name:      "not in subnet",
+			nwInfo:    getNwInfo("10.0.0.0/24", ""),
+			nwCfg:     &cni.NetworkConfig{},
+			cniArgs:   "K8S_POD_IP=10.0.1.5",
+			wantErrIs: ErrRequestedIPUnavailable,
+		},
+		{
+			name:   "two ipv4",
+			nwInfo: getNwInfo("10.0.0.0/24", ""),
+			nwCfg: &cni.NetworkConfig{
+				RuntimeConfig: cni.RuntimeConfig{IPs: []string{"10.0.0.5", "10.0.0.6"}},
+			},
+			wantErrIs: cni.ErrInvalidRequestedIP,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &AzureIPAMInvoker{
+				nwInfo: tt.nwInfo,
+			}
+			addConfig := IPAMAddConfig{nwCfg: tt.nwCfg, args: &cniSkel.CmdArgs{Args: tt.cniArgs}}
+			ipv4, ipv6, err := invoker.requestedIPs(addConfig)
+			if tt.wantErrIs != nil {
+				require.ErrorIs(t, err, tt.wantErrIs)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantIPv4, ipv4)
+			require.Equal(t, tt.wantIPv6, ipv6)
+
+			// the requested IPv4 is passed to the IPAM plugin
+			invoker.plugin = &mockDelegatePlugin{
+				add: add{
+					resultsIPv4: getResult(tt.wantIPv4 + "/24"),
+					resultsIPv6: getResult("2001:db8:abcd:12::5/64"),
+				},
+			}
+			_, err = invoker.Add(addConfig)
+			require.Nil(t, err) // use Nil since *cniTypes.Error is not of type Error
+			require.Equal(t, tt.wantIPv4, tt.nwCfg.IPAM.Address)
+		})
+	}
+}
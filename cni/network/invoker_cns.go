@@ -10,6 +10,7 @@ import (
 	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/cns"
 	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
@@ -32,6 +33,8 @@ type CNSIPAMInvoker struct {
 	cnsClient     cnsclient
 	executionMode util.ExecutionMode
 	ipamMode      util.IpamMode
+	// correlationID is the ID of the CNI invocation sent in the requests to CNS, if set.
+	correlationID string
 }
 
 type IPResultInfo struct {
@@ -44,16 +47,22 @@ type IPResultInfo struct {
 	hostGateway        string
 }
 
-func NewCNSInvoker(podName, namespace string, cnsClient cnsclient, executionMode util.ExecutionMode, ipamMode util.IpamMode) *CNSIPAMInvoker {
+func NewCNSInvoker(podName, namespace string, cnsClient cnsclient, executionMode util.ExecutionMode, ipamMode util.IpamMode, correlationID string) *CNSIPAMInvoker {
 	return &CNSIPAMInvoker{
 		podName:       podName,
 		podNamespace:  namespace,
 		cnsClient:     cnsClient,
 		executionMode: executionMode,
 		ipamMode:      ipamMode,
+		correlationID: correlationID,
 	}
 }
 
+// context returns the context of the requests to CNS.
+func (invoker *CNSIPAMInvoker) context() context.Context {
+	return acn.WithCorrelationID(context.Background(), invoker.correlationID)
+}
+
 // Add uses the requestipconfig API in cns, and returns ipv4 and a nil ipv6 as CNS doesn't support IPv6 yet
 func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, error) {
 	// Parse Pod arguments.
@@ -72,14 +81,23 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		return IPAMAddResult{}, errEmptyCNIArgs
 	}
 
+	requestedIPs, err := cni.RequestedIPs(addConfig.nwCfg, addConfig.args.Args)
+	if err != nil {
+		return IPAMAddResult{}, errors.Wrap(err, "failed to get requested IPs")
+	}
+
 	ipconfigs := cns.IPConfigsRequest{
 		OrchestratorContext: orchestratorContext,
 		PodInterfaceID:      GetEndpointID(addConfig.args),
 		InfraContainerID:    addConfig.args.ContainerID,
 	}
+	// CNS assigns the requested IPs if they are free IPs of its NCs, and fails otherwise
+	for _, ip := range requestedIPs {
+		ipconfigs.DesiredIPAddresses = append(ipconfigs.DesiredIPAddresses, ip.String())
+	}
 
 	log.Printf("Requesting IP for pod %+v using ipconfigs %+v", podInfo, ipconfigs)
-	response, err := invoker.cnsClient.RequestIPs(context.TODO(), ipconfigs)
+	response, err := invoker.cnsClient.RequestIPs(invoker.context(), ipconfigs)
 	if err != nil {
 		if cnscli.IsUnsupportedAPI(err) {
 			// If RequestIPs is not supported by CNS, use RequestIPAddress API
@@ -89,28 +107,35 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 				PodInterfaceID:      GetEndpointID(addConfig.args),
 				InfraContainerID:    addConfig.args.ContainerID,
 			}
+			// the older API takes a single IP, so only the first requested IP is honored
+			if len(requestedIPs) > 0 {
+				ipconfig.DesiredIPAddress = requestedIPs[0].String()
+			}
 
-			res, errRequestIP := invoker.cnsClient.RequestIPAddress(context.TODO(), ipconfig)
+			res, errRequestIP := invoker.cnsClient.RequestIPAddress(invoker.context(), ipconfig)
 			if errRequestIP != nil {
 				// if the old API fails as well then we just return the error
 				log.Errorf("Failed to request IP address from CNS using RequestIPAddress with infracontainerid %s. error: %v", ipconfig.InfraContainerID, errRequestIP)
-				return IPAMAddResult{}, errors.Wrap(errRequestIP, "Failed to get IP address from CNS")
+				return IPAMAddResult{}, errors.Wrap(wrapCNSError(errRequestIP), "Failed to get IP address from CNS")
 			}
 			response = &cns.IPConfigsResponse{
 				Response: res.Response,
 				PodIPInfo: []cns.PodIpInfo{
 					res.PodIpInfo,
 				},
+				HomeAz: res.HomeAz,
 			}
 		} else {
 			log.Printf("Failed to get IP address from CNS with error %v, response: %v", err, response)
-			return IPAMAddResult{}, errors.Wrap(err, "Failed to get IP address from CNS")
+			return IPAMAddResult{}, errors.Wrap(wrapCNSError(err), "Failed to get IP address from CNS")
 		}
 	}
 
-	addResult := IPAMAddResult{}
+	addResult := IPAMAddResult{homeAz: response.HomeAz}
+	var ncPolicies []cns.NetworkContainerRequestPolicies
 
 	for i := 0; i < len(response.PodIPInfo); i++ {
+		ncPolicies = append(ncPolicies, response.PodIPInfo[i].EndpointPolicies...)
 		info := IPResultInfo{
 			podIPAddress:       response.PodIPInfo[i].PodIPConfig.IPAddress,
 			ncSubnetPrefix:     response.PodIPInfo[i].NetworkContainerPrimaryIPConfig.IPSubnet.PrefixLength,
@@ -206,6 +231,10 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		}
 	}
 
+	if addResult.endpointPolicies, err = getPassthroughEndpointPolicies(ncPolicies); err != nil {
+		return IPAMAddResult{}, err
+	}
+
 	return addResult, nil
 }
 
@@ -293,7 +322,7 @@ func (invoker *CNSIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConf
 		log.Printf("CNS invoker called with empty IP address")
 	}
 
-	if err := invoker.cnsClient.ReleaseIPs(context.TODO(), ipConfigs); err != nil {
+	if err := invoker.cnsClient.ReleaseIPs(invoker.context(), ipConfigs); err != nil {
 		if cnscli.IsUnsupportedAPI(err) {
 			// If ReleaseIPs is not supported by CNS, use ReleaseIPAddress API
 			log.Errorf("ReleaseIPs not supported by CNS. Invoking ReleaseIPAddress API. Request: %v", ipConfigs)
@@ -303,16 +332,51 @@ func (invoker *CNSIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConf
 				InfraContainerID:    args.ContainerID,
 			}
 
-			if err = invoker.cnsClient.ReleaseIPAddress(context.TODO(), ipConfig); err != nil {
+			if err = invoker.cnsClient.ReleaseIPAddress(invoker.context(), ipConfig); err != nil {
 				// if the old API fails as well then we just return the error
 				log.Errorf("Failed to release IP address from CNS using ReleaseIPAddress with infracontainerid %s. error: %v", ipConfigs.InfraContainerID, err)
-				return errors.Wrap(err, fmt.Sprintf("failed to release IP %v using ReleaseIPAddress with err ", ipConfig.DesiredIPAddress)+"%w")
+				return errors.Wrap(wrapCNSError(err), fmt.Sprintf("failed to release IP %v using ReleaseIPAddress with err ", ipConfig.DesiredIPAddress)+"%w")
 			}
 		} else {
 			log.Errorf("Failed to release IP address with infracontainerid %s from CNS error: %v", ipConfigs.InfraContainerID, err)
-			return errors.Wrap(err, fmt.Sprintf("failed to release IP %v using ReleaseIPs with err ", ipConfigs.DesiredIPAddresses)+"%w")
+			return errors.Wrap(wrapCNSError(err), fmt.Sprintf("failed to release IP %v using ReleaseIPs with err ", ipConfigs.DesiredIPAddresses)+"%w")
 		}
 	}
 
 	return nil
 }
+
+// ConfirmPendingRelease confirms to CNS the release of the IPs it is removing from the node, except for the
+// IPs in endpointIPs which are still used by endpoints. CNS keeps those IPs in the NodeNetworkConfig until
+// they are confirmed, so that they aren't handed to another node while a terminating pod still uses them.
+func (invoker *CNSIPAMInvoker) ConfirmPendingRelease(endpointIPs map[string]struct{}) error {
+	pending, err := invoker.cnsClient.GetPendingReleaseIPs(invoker.context())
+	if err != nil {
+		if cnscli.IsUnsupportedAPI(err) {
+			// CNS doesn't wait for release confirmation
+			return nil
+		}
+		return errors.Wrap(err, "failed to get pending release IPs from CNS")
+	}
+
+	ids := []string{}
+	for _, ipConfig := range pending {
+		if ipConfig.Confirmed {
+			continue
+		}
+		if _, inUse := endpointIPs[ipConfig.IPAddress]; inUse {
+			log.Printf("Not confirming release of IP %s, it is used by an endpoint", ipConfig.IPAddress)
+			continue
+		}
+		ids = append(ids, ipConfig.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := invoker.cnsClient.ConfirmPendingRelease(invoker.context(), ids); err != nil {
+		return errors.Wrap(err, "failed to confirm pending release IPs to CNS")
+	}
+	log.Printf("Confirmed release of %d IPs to CNS", len(ids))
+	return nil
+}
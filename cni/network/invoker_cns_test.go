@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -10,6 +11,9 @@ import (
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/cns"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/cns/types"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/network"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
@@ -289,6 +293,7 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 		args           args
 		wantIpv4Result *cniTypesCurr.Result
 		wantIpv6Result *cniTypesCurr.Result
+		wantHomeAz     uint
 		wantErr        bool
 	}{
 		{
@@ -326,6 +331,7 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 								ReturnCode: 0,
 								Message:    "",
 							},
+							HomeAz: 2,
 						},
 						err: nil,
 					},
@@ -356,6 +362,7 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 				},
 			},
 			wantIpv6Result: nil,
+			wantHomeAz:     2,
 			wantErr:        false,
 		},
 		{
@@ -495,6 +502,7 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 			fmt.Printf("want:%+v\nrest:%+v\n", tt.wantIpv4Result, ipamAddResult.ipv4Result)
 			require.Equalf(tt.wantIpv4Result, ipamAddResult.ipv4Result, "incorrect ipv4 response")
 			require.Equalf(tt.wantIpv6Result, ipamAddResult.ipv6Result, "incorrect ipv6 response")
+			require.Equal(tt.wantHomeAz, ipamAddResult.homeAz, "incorrect home az")
 		})
 	}
 }
@@ -1019,6 +1027,75 @@ func TestReleaseIPAPIsFail(t *testing.T) {
 	}
 }
 
+func TestCNSIPAMInvoker_ConfirmPendingRelease(t *testing.T) {
+	require := require.New(t) //nolint further usage of require without passing t
+	unsupportedAPIs := map[cnsAPIName]struct{}{PendingRelease: {}}
+
+	tests := []struct {
+		name          string
+		cnsClient     *MockCNSClient
+		endpointIPs   map[string]struct{}
+		wantConfirmed []string
+		wantErr       bool
+	}{
+		{
+			name: "confirm pending IPs not used by endpoints",
+			cnsClient: &MockCNSClient{
+				pendingRelease: pendingReleaseHandler{
+					result: []cns.PendingReleaseIPConfig{
+						{ID: "id1", IPAddress: "10.0.0.1"},
+						{ID: "id2", IPAddress: "10.0.0.2"},
+						{ID: "id3", IPAddress: "10.0.0.3", Confirmed: true},
+					},
+				},
+			},
+			endpointIPs:   map[string]struct{}{"10.0.0.2": {}},
+			wantConfirmed: []string{"id1"},
+		},
+		{
+			name: "no pending IPs",
+			cnsClient: &MockCNSClient{
+				pendingRelease: pendingReleaseHandler{},
+			},
+			endpointIPs: map[string]struct{}{},
+		},
+		{
+			name: "unsupported by CNS",
+			cnsClient: &MockCNSClient{
+				unsupportedAPIs: unsupportedAPIs,
+			},
+			endpointIPs: map[string]struct{}{},
+		},
+		{
+			name: "CNS error",
+			cnsClient: &MockCNSClient{
+				pendingRelease: pendingReleaseHandler{
+					err: errors.New("failed"),
+				},
+			},
+			endpointIPs: map[string]struct{}{},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &CNSIPAMInvoker{
+				podName:      testPodInfo.PodName,
+				podNamespace: testPodInfo.PodNamespace,
+				cnsClient:    tt.cnsClient,
+			}
+			err := invoker.ConfirmPendingRelease(tt.endpointIPs)
+			if tt.wantErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.wantConfirmed, tt.cnsClient.pendingRelease.confirmedIPConfigIDs)
+		})
+	}
+}
+
 func Test_setHostOptions(t *testing.T) {
 	require := require.New(t) //nolint further usage of require without passing t
 	type args struct {
@@ -1115,3 +1192,119 @@ func Test_setHostOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestCNSIPAMInvoker_AddRequestedIPs(t *testing.T) {
+	requestedIPsRequest := getTestIPConfigsRequest()
+	requestedIPsRequest.DesiredIPAddresses = []string{"10.0.1.10"}
+	result := &cns.IPConfigsResponse{
+		PodIPInfo: []cns.PodIpInfo{
+			{
+				PodIPConfig: cns.IPSubnet{
+					IPAddress:    "10.0.1.10",
+					PrefixLength: 24,
+				},
+				NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+					IPSubnet: cns.IPSubnet{
+						IPAddress:    "10.0.1.0",
+						PrefixLength: 24,
+					},
+					GatewayIPAddress: "10.0.1.1",
+				},
+				HostPrimaryIPInfo: cns.HostIPInfo{
+					Gateway:   "10.0.0.1",
+					PrimaryIP: "10.0.0.4",
+					Subnet:    "10.0.0.0/24",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		nwCfg    *cni.NetworkConfig
+		cniArgs  string
+		err      error
+		wantCode uint
+	}{
+		{
+			name:    "K8S_POD_IP",
+			nwCfg:   &cni.NetworkConfig{},
+			cniArgs: "K8S_POD_IP=10.0.1.10",
+		},
+		{
+			name: "ips capability",
+			nwCfg: &cni.NetworkConfig{
+				RuntimeConfig: cni.RuntimeConfig{IPs: []string{"10.0.1.10/24"}},
+			},
+			cniArgs: "K8S_POD_IP=10.0.1.11",
+		},
+		{
+			name:     "cns returns desired ip unavailable",
+			nwCfg:    &cni.NetworkConfig{},
+			cniArgs:  "K8S_POD_IP=10.0.1.10",
+			err:      &cnscli.CNSClientError{Code: types.DesiredIPUnavailable, Err: errors.New("desired IP is unavailable")},
+			wantCode: ErrCodeRequestedIPUnavailable,
+		},
+		{
+			name:     "invalid ip",
+			nwCfg:    &cni.NetworkConfig{},
+			cniArgs:  "K8S_POD_IP=10.0.1",
+			wantCode: cniTypes.ErrInvalidEnvironmentVariables,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &CNSIPAMInvoker{
+				podName:      testPodInfo.PodName,
+				podNamespace: testPodInfo.PodNamespace,
+				cnsClient: &MockCNSClient{
+					require: require.New(t),
+					requestIPs: requestIPsHandler{
+						ipconfigArgument: requestedIPsRequest,
+						result:           result,
+						err:              tt.err,
+					},
+				},
+			}
+			args := &cniSkel.CmdArgs{
+				ContainerID: "testcontainerid",
+				Netns:       "testnetns",
+				IfName:      "testifname",
+				Args:        tt.cniArgs,
+			}
+			ipamAddResult, err := invoker.Add(IPAMAddConfig{nwCfg: tt.nwCfg, args: args, options: map[string]interface{}{}})
+			if tt.wantCode != 0 {
+				var cniErr *cniTypes.Error
+				require.ErrorAs(t, toCNIError(err), &cniErr)
+				require.Equal(t, tt.wantCode, cniErr.Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "10.0.1.10/24", ipamAddResult.ipv4Result.IPs[0].Address.String())
+		})
+	}
+}
+
+// correlatedCNSClient records the correlation IDs of the contexts of the requests to CNS.
+type correlatedCNSClient struct {
+	cnsclient
+	correlationIDs []string
+}
+
+func (c *correlatedCNSClient) GetPendingReleaseIPs(ctx context.Context) ([]cns.PendingReleaseIPConfig, error) {
+	c.correlationIDs = append(c.correlationIDs, acn.CorrelationIDFromContext(ctx))
+	return []cns.PendingReleaseIPConfig{{ID: "id", IPAddress: "10.0.0.4"}}, nil
+}
+
+func (c *correlatedCNSClient) ConfirmPendingRelease(ctx context.Context, _ []string) ([]cns.PendingReleaseIPConfig, error) {
+	c.correlationIDs = append(c.correlationIDs, acn.CorrelationIDFromContext(ctx))
+	return nil, nil
+}
+
+func TestCNSIPAMInvokerCorrelationID(t *testing.T) {
+	client := &correlatedCNSClient{}
+	invoker := NewCNSInvoker(testPodInfo.PodName, testPodInfo.PodNamespace, client, util.Default, util.V4Overlay, "correlation-id")
+	require.NoError(t, invoker.ConfirmPendingRelease(map[string]struct{}{}))
+	require.Equal(t, []string{"correlation-id", "correlation-id"}, client.correlationIDs)
+}
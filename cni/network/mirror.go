@@ -0,0 +1,57 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/pkg/errors"
+)
+
+var errInvalidMirrorDuration = errors.New("invalid mirror duration")
+
+// mirrorInfo returns the mirror of the traffic of the pod, or nil if the netconf has no collector interface or the pod
+// isn't annotated with cni.MirrorAnnotation. The duration of the annotation is capped by the max duration of the
+// netconf, and starts now.
+func mirrorInfo(nwCfg *cni.NetworkConfig, now time.Time) (*network.MirrorInfo, error) {
+	cfg := nwCfg.Mirror
+	if cfg == nil || cfg.CollectorInterface == "" {
+		return nil, nil
+	}
+	value, ok := nwCfg.RuntimeConfig.PodAnnotations[cni.MirrorAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return nil, errors.Wrapf(errInvalidMirrorDuration, "%s=%q", cni.MirrorAnnotation, value)
+	}
+	maxMinutes := cfg.MaxDurationMinutes
+	if maxMinutes <= 0 {
+		maxMinutes = cni.DefaultMirrorMaxDurationMinutes
+	}
+	if maxDuration := time.Duration(maxMinutes) * time.Minute; duration > maxDuration {
+		log.Printf("[cni-net] Capping the mirror duration %s to %s", duration, maxDuration)
+		duration = maxDuration
+	}
+
+	return &network.MirrorInfo{
+		CollectorInterface: cfg.CollectorInterface,
+		Expiry:             now.Add(duration),
+	}, nil
+}
+
+// removeExpiredMirrors stops mirroring the traffic of the endpoints of the network whose mirror expired. The mirrors
+// are best effort, so that a failure doesn't fail the command.
+func (plugin *NetPlugin) removeExpiredMirrors(networkID string) {
+	removed, err := plugin.nm.RemoveExpiredMirrors(networkID, time.Now())
+	if err != nil {
+		log.Errorf("[cni-net] Failed to remove expired mirrors of network %s: %v", networkID, err)
+	}
+	if len(removed) > 0 {
+		logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Removed the expired mirrors of endpoints %v.", removed))
+	}
+}
@@ -0,0 +1,67 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorInfo(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		mirror      *cni.Mirror
+		annotations map[string]string
+		want        *network.MirrorInfo
+		wantErr     bool
+	}{
+		{
+			name:        "no collector",
+			annotations: map[string]string{cni.MirrorAnnotation: "10m"},
+		},
+		{
+			name:   "not annotated",
+			mirror: &cni.Mirror{CollectorInterface: "erspan0"},
+		},
+		{
+			name:        "annotated",
+			mirror:      &cni.Mirror{CollectorInterface: "erspan0"},
+			annotations: map[string]string{cni.MirrorAnnotation: "10m"},
+			want:        &network.MirrorInfo{CollectorInterface: "erspan0", Expiry: now.Add(10 * time.Minute)},
+		},
+		{
+			name:        "capped by default",
+			mirror:      &cni.Mirror{CollectorInterface: "erspan0"},
+			annotations: map[string]string{cni.MirrorAnnotation: "24h"},
+			want:        &network.MirrorInfo{CollectorInterface: "erspan0", Expiry: now.Add(cni.DefaultMirrorMaxDurationMinutes * time.Minute)},
+		},
+		{
+			name:        "capped by netconf",
+			mirror:      &cni.Mirror{CollectorInterface: "erspan0", MaxDurationMinutes: 5},
+			annotations: map[string]string{cni.MirrorAnnotation: "10m"},
+			want:        &network.MirrorInfo{CollectorInterface: "erspan0", Expiry: now.Add(5 * time.Minute)},
+		},
+		{
+			name:        "invalid duration",
+			mirror:      &cni.Mirror{CollectorInterface: "erspan0"},
+			annotations: map[string]string{cni.MirrorAnnotation: "forever"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{Mirror: tt.mirror, RuntimeConfig: cni.RuntimeConfig{PodAnnotations: tt.annotations}}
+			got, err := mirrorInfo(nwCfg, now)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
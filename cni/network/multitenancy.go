@@ -216,6 +216,9 @@ func (m *Multitenancy) GetAllNetworkContainers(
 		ipamResults[i].ncResponse = &ncResponses[i]
 		ipamResults[i].hostSubnetPrefix = hostSubnetPrefixes[i]
 		ipamResults[i].ipv4Result = convertToCniResult(ipamResults[i].ncResponse, ifName)
+		if ipamResults[i].endpointPolicies, err = getPassthroughEndpointPolicies(ncResponses[i].EndpointPolicies); err != nil {
+			return []IPAMAddResult{}, err
+		}
 	}
 
 	return ipamResults, err
@@ -0,0 +1,31 @@
+package network
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+)
+
+// ErrMultitenancyNotSupported is returned for multitenant network configurations by builds without multitenancy.
+var ErrMultitenancyNotSupported = errors.New("multitenancy is not supported by this build of the plugin")
+
+// DisabledMultitenancy is the MultitenancyClient of builds without multitenancy. It rejects multitenant pods.
+type DisabledMultitenancy struct{}
+
+func (DisabledMultitenancy) Init(cnsclient, netioshim) {}
+
+func (DisabledMultitenancy) DetermineSnatFeatureOnHost(string, string) (snatForDNS, snatOnHost bool, err error) {
+	return false, false, ErrMultitenancyNotSupported
+}
+
+func (DisabledMultitenancy) GetAllNetworkContainers(context.Context, *cni.NetworkConfig, string, string, string) ([]IPAMAddResult, error) {
+	return nil, ErrMultitenancyNotSupported
+}
+
+func (DisabledMultitenancy) SetupRoutingForMultitenancy(*cni.NetworkConfig, *cns.GetNetworkContainerResponse, *cniTypesCurr.Result,
+	*network.EndpointInfo, *cniTypesCurr.Result) {
+}
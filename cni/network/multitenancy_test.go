@@ -66,12 +66,22 @@ type getAllNetworkContainersConfigurationHandler struct {
 	err                 error
 }
 
+type pendingReleaseHandler struct {
+	// arguments
+	confirmedIPConfigIDs []string
+
+	// results
+	result []cns.PendingReleaseIPConfig
+	err    error
+}
+
 type cnsAPIName string
 
 const (
 	GetAllNetworkContainers cnsAPIName = "GetAllNetworkContainers"
 	RequestIPs              cnsAPIName = "RequestIPs"
 	ReleaseIPs              cnsAPIName = "ReleaseIPs"
+	PendingRelease          cnsAPIName = "PendingRelease"
 )
 
 var (
@@ -90,6 +100,7 @@ type MockCNSClient struct {
 	releaseIPs                           releaseIPsHandler
 	getNetworkContainerConfiguration     getNetworkContainerConfigurationHandler
 	getAllNetworkContainersConfiguration getAllNetworkContainersConfigurationHandler
+	pendingRelease                       pendingReleaseHandler
 }
 
 func (c *MockCNSClient) RequestIPAddress(_ context.Context, ipconfig cns.IPConfigRequest) (*cns.IPConfigResponse, error) {
@@ -155,6 +166,27 @@ func (c *MockCNSClient) GetAllNetworkContainers(ctx context.Context, orchestrato
 	return c.getAllNetworkContainersConfiguration.returnResponse, c.getAllNetworkContainersConfiguration.err
 }
 
+func (c *MockCNSClient) GetPendingReleaseIPs(_ context.Context) ([]cns.PendingReleaseIPConfig, error) {
+	if _, isUnsupported := c.unsupportedAPIs[PendingRelease]; isUnsupported {
+		e := &client.CNSClientError{}
+		e.Code = types.UnsupportedAPI
+		e.Err = errUnsupportedAPI
+		return nil, e
+	}
+	return c.pendingRelease.result, c.pendingRelease.err
+}
+
+func (c *MockCNSClient) ConfirmPendingRelease(_ context.Context, ipConfigIDs []string) ([]cns.PendingReleaseIPConfig, error) {
+	if _, isUnsupported := c.unsupportedAPIs[PendingRelease]; isUnsupported {
+		e := &client.CNSClientError{}
+		e.Code = types.UnsupportedAPI
+		e.Err = errUnsupportedAPI
+		return nil, e
+	}
+	c.pendingRelease.confirmedIPConfigIDs = append(c.pendingRelease.confirmedIPConfigIDs, ipConfigIDs...)
+	return c.pendingRelease.result, c.pendingRelease.err
+}
+
 func defaultIPNet() *net.IPNet {
 	_, defaultIPNet, _ := net.ParseCIDR("0.0.0.0/0")
 	return defaultIPNet
@@ -842,3 +874,14 @@ func TestGetMultiTenancyCNIResultNotFound(t *testing.T) {
 		})
 	}
 }
+
+func TestDisabledMultitenancy(t *testing.T) {
+	var client MultitenancyClient = DisabledMultitenancy{}
+	client.Init(nil, nil)
+
+	_, _, err := client.DetermineSnatFeatureOnHost("", "")
+	require.ErrorIs(t, err, ErrMultitenancyNotSupported)
+
+	_, err = client.GetAllNetworkContainers(context.TODO(), &cni.NetworkConfig{MultiTenancy: true}, "pod", "ns", "eth0")
+	require.ErrorIs(t, err, ErrMultitenancyNotSupported)
+}
@@ -31,12 +31,14 @@ import (
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	cniVers "github.com/containernetworking/cni/pkg/version"
 	"github.com/pkg/errors"
 )
 
 const (
 	dockerNetworkOption = "com.docker.network.generic"
 	OpModeTransparent   = "transparent"
+	OpModeVnetBlock     = "vnet-block"
 	// Supported IP version. Currently support only IPv4
 	ipamV6                = "azure-vnet-ipamv6"
 	defaultRequestTimeout = 15 * time.Second
@@ -73,6 +75,10 @@ type NetPlugin struct {
 	tb                 *telemetry.TelemetryBuffer
 	nnsClient          NnsClient
 	multitenancyClient MultitenancyClient
+	// correlationID is the ID of the CNI invocation sent in the requests to CNS and NNS, if set.
+	correlationID string
+	// status is the status file of the invocation, if set.
+	status *StatusFile
 }
 
 type PolicyArgs struct {
@@ -134,6 +140,21 @@ func (plugin *NetPlugin) SetCNIReport(report *telemetry.CNIReport, tb *telemetry
 	plugin.tb = tb
 }
 
+// SetCorrelationID sets the ID of the CNI invocation sent in the requests to CNS and NNS.
+func (plugin *NetPlugin) SetCorrelationID(id string) {
+	plugin.correlationID = id
+}
+
+// SetStatusFile sets the status file of the CNI invocation, which the commands enable if their network configuration does.
+func (plugin *NetPlugin) SetStatusFile(status *StatusFile) {
+	plugin.status = status
+}
+
+// context returns the context of the requests of the CNI invocation to CNS and NNS.
+func (plugin *NetPlugin) context() context.Context {
+	return common.WithCorrelationID(context.Background(), plugin.correlationID)
+}
+
 // Starts the plugin.
 func (plugin *NetPlugin) Start(config *common.PluginConfig) error {
 	// Initialize base plugin.
@@ -199,6 +220,16 @@ func (plugin *NetPlugin) GetAllEndpointState(networkid string) (*api.AzureCNISta
 		st.ContainerInterfaces[id] = info
 	}
 
+	if plugin.Store != nil {
+		records, err := readFailureRecords(plugin.Store)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			st.FailureRecords = records
+		}
+	}
+
 	return &st, nil
 }
 
@@ -291,6 +322,14 @@ func SetCustomDimensions(cniMetric *telemetry.AIMetric, nwCfg *cni.NetworkConfig
 	}
 }
 
+// setPhaseDimensions adds the time spent in each phase of the operation to the metric.
+func (plugin *NetPlugin) setPhaseDimensions(cniMetric *telemetry.AIMetric) {
+	if d := network.NetnsDuration(); d > 0 {
+		plugin.report.SetPhaseDuration(telemetry.PhaseNetns, d)
+	}
+	plugin.report.SetPhaseDimensions(cniMetric)
+}
+
 func (plugin *NetPlugin) setCNIReportDetails(nwCfg *cni.NetworkConfig, opType, msg string) {
 	plugin.report.OperationType = opType
 	plugin.report.SubContext = fmt.Sprintf("%+v", nwCfg)
@@ -320,7 +359,16 @@ func addNatIPV6SubnetInfo(nwCfg *cni.NetworkConfig,
 // https://github.com/containernetworking/cni/blob/master/SPEC.md
 
 // Add handles CNI add commands.
+// Failures of a known class are returned with the CNI error code of the class, see toCNIError.
 func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
+	err := plugin.add(args)
+	if err != nil {
+		plugin.recordFailure(CNI_ADD, args, err)
+	}
+	return toCNIError(err)
+}
+
+func (plugin *NetPlugin) add(args *cniSkel.CmdArgs) error {
 	var (
 		ipamAddResult    IPAMAddResult
 		ipamAddResults   []IPAMAddResult
@@ -329,6 +377,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		enableSnatForDNS bool
 		k8sPodName       string
 		cniMetric        telemetry.AIMetric
+		nodeLocalNAT     *network.NodeLocalNATInfo
 	)
 
 	startTime := time.Now()
@@ -336,15 +385,22 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Processing ADD command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v StdinData:%s}.",
 		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path, args.StdinData))
 
+	// Validate the network configuration before acting on any of it.
+	if err := cni.ValidateNetworkConfig(args.StdinData); err != nil {
+		return plugin.Error(err)
+	}
+
 	// Parse network configuration from stdin.
 	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
 	if err != nil {
 		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
 		return err
 	}
+	log.SetRotation(nwCfg.LogRotation.RotationConfig())
 
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
 	plugin.setCNIReportDetails(nwCfg, CNI_ADD, "")
+	plugin.status.Enable(nwCfg, args)
 
 	defer func() {
 		operationTimeMs := time.Since(startTime).Milliseconds()
@@ -355,6 +411,10 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			CustomDimensions: make(map[string]string),
 		}
 		SetCustomDimensions(&cniMetric, nwCfg, err)
+		plugin.setPhaseDimensions(&cniMetric)
+		if plugin.report.Datapath != "" {
+			cniMetric.Metric.CustomDimensions[telemetry.DatapathStr] = plugin.report.Datapath
+		}
 		telemetry.SendCNIMetric(&cniMetric, plugin.tb)
 
 		// Add Interfaces to result.
@@ -370,8 +430,12 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		if ipamAddResult.ipv6Result != nil {
 			ipamAddResult.ipv4Result.IPs = append(ipamAddResult.ipv4Result.IPs, ipamAddResult.ipv6Result.IPs...)
 		}
+		setContainerInterface(ipamAddResult.ipv4Result, len(ipamAddResult.ipv4Result.Interfaces)-1, args.Netns, nwCfg.CNIVersion)
 
 		addSnatInterface(nwCfg, ipamAddResult.ipv4Result)
+		if err == nil {
+			addNodeLocalNATToResult(ipamAddResult.ipv4Result, nodeLocalNAT)
+		}
 
 		// Convert result to the requested CNI version.
 		res, vererr := ipamAddResult.ipv4Result.GetAsVersion(nwCfg.CNIVersion)
@@ -382,7 +446,9 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 
 		if err == nil && res != nil {
 			// Output the result to stdout.
-			res.Print()
+			if printErr := printResult(os.Stdout, res, plugin.report.Datapath); printErr != nil {
+				log.Errorf("Failed to print result: %v", printErr)
+			}
 		}
 
 		log.Printf("[cni-net] ADD command completed for pod %v with IPs:%+v err:%v.", k8sPodName, ipamAddResult.ipv4Result.IPs, err)
@@ -414,7 +480,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	if nwCfg.ExecutionMode == string(util.Baremetal) {
 		var res *nnscontracts.ConfigureContainerNetworkingResponse
 		log.Printf("Baremetal mode. Calling vnet agent for ADD")
-		res, err = plugin.nnsClient.AddContainerNetworking(context.Background(), k8sPodName, args.Netns)
+		res, err = plugin.nnsClient.AddContainerNetworking(plugin.context(), k8sPodName, args.Netns)
 
 		if err == nil {
 			ipamAddResult.ipv4Result = convertNnsToCniResult(res, args.IfName, k8sPodName, "AddContainerNetworking")
@@ -431,6 +497,11 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	nodeLocalNAT, err = nodeLocalNATInfo(nwCfg.NodeLocalNAT)
+	if err != nil {
+		return plugin.Errorf("Failed to get node-local NAT config: %v", err)
+	}
+
 	cnsClient, err := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to create cns client with error: %w", err)
@@ -446,7 +517,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			return fmt.Errorf("%w", err)
 		}
 
-		ipamAddResults, err = plugin.multitenancyClient.GetAllNetworkContainers(context.TODO(), nwCfg, k8sPodName, k8sNamespace, args.IfName)
+		ipamAddResults, err = plugin.multitenancyClient.GetAllNetworkContainers(plugin.context(), nwCfg, k8sPodName, k8sNamespace, args.IfName)
 		if err != nil {
 			err = fmt.Errorf("GetAllNetworkContainers failed for podname %s namespace %s. error: %w", k8sPodName, k8sNamespace, err)
 			log.Printf("%+v", err)
@@ -485,6 +556,12 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 
 		if nwInfoErr == nil {
 			log.Printf("[cni-net] Found network %v with subnet %v.", networkID, nwInfo.Subnets[0].Prefix.String())
+
+			if err = plugin.repairNetwork(networkID, nwCfg); err != nil {
+				return err
+			}
+			plugin.removeExpiredMirrors(networkID)
+
 			nwInfo.IPAMType = nwCfg.IPAM.Type
 			options = nwInfo.Options
 
@@ -505,11 +582,14 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		if plugin.ipamInvoker == nil {
 			switch nwCfg.IPAM.Type {
 			case network.AzureCNS:
-				plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode))
+				plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode), plugin.correlationID)
 
 			default:
-				plugin.ipamInvoker = NewAzureIpamInvoker(plugin, &nwInfo)
+				if plugin.ipamInvoker, err = newAzureIPAMInvoker(plugin, &nwInfo); err != nil {
+					return err
+				}
 			}
+			plugin.ipamInvoker = newTimedIPAMInvoker(plugin.ipamInvoker, plugin.report)
 		}
 
 		ipamAddConfig := IPAMAddConfig{nwCfg: nwCfg, args: args, options: options}
@@ -550,7 +630,7 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			azIpamResult:     azIpamResult,
 			args:             args,
 			nwInfo:           &nwInfo,
-			policies:         policies,
+			policies:         append(policies, ipamAddResult.endpointPolicies...),
 			endpointID:       endpointID,
 			k8sPodName:       k8sPodName,
 			k8sNamespace:     k8sNamespace,
@@ -558,6 +638,10 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			enableSnatForDNS: enableSnatForDNS,
 			natInfo:          natInfo,
 		}
+		// the pod gets a single node-local NAT IP, allocated to its first endpoint
+		if i == 0 {
+			createEndpointInternalOpt.nodeLocalNAT = nodeLocalNAT
+		}
 
 		var epInfo network.EndpointInfo
 		epInfo, err = plugin.createEndpointInternal(&createEndpointInternalOpt)
@@ -565,6 +649,10 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 			log.Errorf("Endpoint creation failed:%w", err)
 			return err
 		}
+		// the datapath of the pod is the one of its first endpoint
+		if i == 0 {
+			plugin.report.Datapath = epInfo.Datapath
+		}
 
 		sendEvent(plugin, fmt.Sprintf("CNI ADD succeeded : IP:%+v, VlanID: %v, podname %v, namespace %v numendpoints:%d",
 			ipamAddResult.ipv4Result.IPs, epInfo.Data[network.VlanIDKey], k8sPodName, k8sNamespace, plugin.nm.GetNumberOfEndpoints("", nwCfg.Name)))
@@ -573,6 +661,35 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// repairNetwork rebuilds the host configuration of an existing network if it went missing, like the bridge after a
+// node reboot, and reports the rebuild to telemetry.
+func (plugin *NetPlugin) repairNetwork(networkID string, nwCfg *cni.NetworkConfig) error {
+	repaired, err := plugin.nm.RepairNetwork(networkID)
+	if err != nil {
+		return fmt.Errorf("failed to repair network %s: %w", networkID, err)
+	}
+
+	if !repaired {
+		return nil
+	}
+
+	logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Rebuilt missing host configuration of network %v.", networkID))
+
+	cniMetric := telemetry.AIMetric{
+		Metric: aitelemetry.Metric{
+			Name:             telemetry.CNINetworkRepairedMetricStr,
+			Value:            1.0,
+			AppVersion:       plugin.Version,
+			CustomDimensions: map[string]string{telemetry.CNINetworkModeStr: nwCfg.Mode},
+		},
+	}
+	if err := telemetry.SendCNIMetric(&cniMetric, plugin.tb); err != nil {
+		log.Errorf("Couldn't send network repaired metric: %v", err)
+	}
+
+	return nil
+}
+
 // cleanup allocated ipv4 and ipv6 addresses if they exist
 func (plugin *NetPlugin) cleanupAllocationOnError(
 	result, resultV6 *cniTypesCurr.Result,
@@ -616,7 +733,9 @@ func (plugin *NetPlugin) createNetworkInternal(
 		return nwInfo, err
 	}
 
+	stopDNSTimer := plugin.report.TrackPhase(telemetry.PhaseDNS)
 	nwDNSInfo, err := getNetworkDNSSettings(ipamAddConfig.nwCfg, ipamAddResult.ipv4Result)
+	stopDNSTimer()
 	if err != nil {
 		err = plugin.Errorf("Failed to getDNSSettings: %v", err)
 		return nwInfo, err
@@ -651,9 +770,11 @@ func (plugin *NetPlugin) createNetworkInternal(
 	}
 	setNetworkOptions(ipamAddResult.ncResponse, &nwInfo)
 
+	stopDataplaneTimer := plugin.report.TrackPhase(telemetry.PhaseDataplane)
 	err = plugin.nm.CreateNetwork(&nwInfo)
+	stopDataplaneTimer()
 	if err != nil {
-		err = plugin.Errorf("createNetworkInternal: Failed to create network: %v", err)
+		err = plugin.Errorf("createNetworkInternal: Failed to create network: %w", dataplaneError(err, ""))
 	}
 
 	return nwInfo, err
@@ -711,16 +832,44 @@ type createEndpointInternalOpt struct {
 	enableInfraVnet  bool
 	enableSnatForDNS bool
 	natInfo          []policy.NATInfo
+	nodeLocalNAT     *network.NodeLocalNATInfo
 }
 
 func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt) (network.EndpointInfo, error) {
 	epInfo := network.EndpointInfo{}
 
+	stopDNSTimer := plugin.report.TrackPhase(telemetry.PhaseDNS)
 	epDNSInfo, err := getEndpointDNSSettings(opt.nwCfg, opt.result, opt.k8sNamespace)
+	stopDNSTimer()
 	if err != nil {
 		err = plugin.Errorf("Failed to getEndpointDNSSettings: %v", err)
 		return epInfo, err
 	}
+	ipsToRouteViaHost := opt.nwCfg.IPsToRouteViaHost
+	localDNSIP, err := nodeLocalDNSServer(opt.nwCfg.NodeLocalDNS)
+	if err != nil {
+		err = plugin.Errorf("Failed to get node-local DNS cache: %v", err)
+		return epInfo, err
+	}
+	if localDNSIP != nil {
+		log.Printf("[cni-net] Using node-local DNS cache %s", localDNSIP)
+		epDNSInfo = withNodeLocalDNS(epDNSInfo, localDNSIP)
+		ipsToRouteViaHost = withIPRoutedViaHost(ipsToRouteViaHost, localDNSIP)
+		localDNSPolicies, err := getNodeLocalDNSPolicies(localDNSIP)
+		if err != nil {
+			err = plugin.Errorf("Failed to get node-local DNS cache policies: %v", err)
+			return epInfo, err
+		}
+		opt.policies = append(opt.policies, localDNSPolicies...)
+	}
+	addDNSToResult(opt.nwCfg, opt.result, epDNSInfo)
+	// the exceptions are added before the endpoint policies, which have their own OutBoundNAT policy for IPv6
+	opt.policies, err = addOutboundNATExceptions(opt.nwCfg, opt.policies)
+	if err != nil {
+		err = plugin.Errorf("Failed to add outbound NAT exceptions: %v", err)
+		return epInfo, err
+	}
+
 	policyArgs := PolicyArgs{
 		nwInfo:    opt.nwInfo,
 		nwCfg:     opt.nwCfg,
@@ -734,8 +883,15 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 
 	opt.policies = append(opt.policies, endpointPolicies...)
 
+	if bw := opt.nwCfg.RuntimeConfig.Bandwidth; bw != nil {
+		if err = bw.Validate(); err != nil {
+			err = plugin.Errorf("Failed to validate bandwidth: %v", err)
+			return epInfo, err
+		}
+	}
+
 	vethName := fmt.Sprintf("%s.%s", opt.k8sNamespace, opt.k8sPodName)
-	if opt.nwCfg.Mode != OpModeTransparent {
+	if opt.nwCfg.Mode != OpModeTransparent && opt.nwCfg.Mode != OpModeVnetBlock {
 		// this mechanism of using only namespace and name is not unique for different incarnations of POD/container.
 		// IT will result in unpredictable behavior if API server decides to
 		// reorder DELETE and ADD call for new incarnation of same POD.
@@ -750,7 +906,7 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		Data:               make(map[string]interface{}),
 		DNS:                epDNSInfo,
 		Policies:           opt.policies,
-		IPsToRouteViaHost:  opt.nwCfg.IPsToRouteViaHost,
+		IPsToRouteViaHost:  ipsToRouteViaHost,
 		EnableSnatOnHost:   opt.nwCfg.EnableSnatOnHost,
 		EnableMultiTenancy: opt.nwCfg.MultiTenancy,
 		EnableInfraVnet:    opt.enableInfraVnet,
@@ -762,10 +918,23 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		VnetCidrs:          opt.nwCfg.VnetCidrs,
 		ServiceCidrs:       opt.nwCfg.ServiceCidrs,
 		NATInfo:            opt.natInfo,
+		// the route tables are only programmed on Linux
+		EnableSourceRouting: opt.nwCfg.EnableSourceRouting,
+		NodeLocalNAT:        opt.nodeLocalNAT,
+		VnetBlock:           vnetBlockInfo(opt.nwCfg),
 	}
 
 	epPolicies := getPoliciesFromRuntimeCfg(opt.nwCfg)
 	epInfo.Policies = append(epInfo.Policies, epPolicies...)
+	epInfo.Bandwidth = getBandwidthInfo(opt.nwCfg)
+	if epInfo.Mirror, err = mirrorInfo(opt.nwCfg, time.Now()); err != nil {
+		err = plugin.Errorf("Failed to get traffic mirror: %v", err)
+		return epInfo, err
+	}
+	if epInfo.AcceleratedNetworking, err = acceleratedNetworkingInfo(opt.nwCfg); err != nil {
+		err = plugin.Errorf("Failed to get accelerated networking: %v", err)
+		return epInfo, err
+	}
 
 	// Populate addresses.
 	for _, ipconfig := range opt.result.IPs {
@@ -803,14 +972,31 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 
 	// Create the endpoint.
 	logAndSendEvent(plugin, fmt.Sprintf("[cni-net] Creating endpoint %s.", epInfo.PrettyString()))
+	stopDataplaneTimer := plugin.report.TrackPhase(telemetry.PhaseDataplane)
 	err = plugin.nm.CreateEndpoint(cnsclient, opt.nwInfo.Id, &epInfo)
+	stopDataplaneTimer()
 	if err != nil {
-		err = plugin.Errorf("Failed to create endpoint: %v", err)
+		err = plugin.Errorf("Failed to create endpoint: %w", dataplaneError(err, opt.args.Netns))
 	}
 
 	return epInfo, err
 }
 
+// setContainerInterface sets the sandbox of the container interface at the index of the result, and points the IPs
+// without an interface to it, for the runtimes of CNI 1.0.0 and later which find the IPs of the pod this way. The
+// results of the older versions are left as they were.
+func setContainerInterface(result *cniTypesCurr.Result, index int, netns, cniVersion string) {
+	if ok, err := cniVers.GreaterThanOrEqualTo(cniVersion, "1.0.0"); err != nil || !ok {
+		return
+	}
+	result.Interfaces[index].Sandbox = netns
+	for _, ipConfig := range result.IPs {
+		if ipConfig.Interface == nil {
+			ipConfig.Interface = cniTypesCurr.Int(index)
+		}
+	}
+}
+
 // Get handles CNI Get commands.
 func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 	var (
@@ -831,6 +1017,9 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 			Name: args.IfName,
 		}
 		result.Interfaces = append(result.Interfaces, iface)
+		if nwCfg != nil {
+			setContainerInterface(&result, len(result.Interfaces)-1, args.Netns, nwCfg.CNIVersion)
+		}
 
 		// Convert result to the requested CNI version.
 		res, vererr := result.GetAsVersion(nwCfg.CNIVersion)
@@ -852,6 +1041,7 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
 		return err
 	}
+	log.SetRotation(nwCfg.LogRotation.RotationConfig())
 
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
@@ -877,10 +1067,14 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	// the results of CNI 1.0.0 and later point the IPs to the container interface, as ADD does
+	isCNIV1, _ := cniVers.GreaterThanOrEqualTo(nwCfg.CNIVersion, "1.0.0")
 	for _, ipAddresses := range epInfo.IPAddresses {
 		ipConfig := &cniTypesCurr.IPConfig{
-			Interface: &epInfo.IfIndex,
-			Address:   ipAddresses,
+			Address: ipAddresses,
+		}
+		if !isCNIV1 {
+			ipConfig.Interface = &epInfo.IfIndex
 		}
 
 		if epInfo.Gateways != nil {
@@ -900,8 +1094,111 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// GC handles CNI GC commands. The endpoints of the attachments which aren't valid anymore are deleted as by a DEL,
+// which releases their IPs, and the GC is delegated to the IPAM plugin for the IPs of containers without an endpoint.
+// CNS reclaims the IPs it assigned on its own.
+func (plugin *NetPlugin) GC(args *cniSkel.CmdArgs) error {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil {
+		return plugin.Errorf("Failed to parse network configuration: %v", err)
+	}
+	log.Printf("[cni-net] Processing GC command with %d valid attachments.", len(nwCfg.ValidAttachments))
+
+	if nwCfg.MultiTenancy {
+		// the endpoints of a multitenant container are spread over networks named after its NCs
+		log.Printf("[cni-net] GC is not supported in multitenancy mode.")
+		return nil
+	}
+
+	networkID, err := plugin.getNetworkName("", nil, nwCfg)
+	if err != nil {
+		return plugin.Errorf("Failed to extract network name from network config. error: %v", err)
+	}
+	endpoints, err := plugin.nm.GetAllEndpoints(networkID)
+	if err != nil && !errors.Is(err, store.ErrStoreEmpty) && !network.IsNetworkNotFoundError(err) {
+		return plugin.Errorf("Failed to get endpoints of network %s: %v", networkID, err)
+	}
+
+	valid := make(map[cniTypes.GCAttachment]struct{}, len(nwCfg.ValidAttachments))
+	for _, attachment := range nwCfg.ValidAttachments {
+		valid[attachment] = struct{}{}
+	}
+	// the IPAM invoker is created for the pod of each DEL
+	ipamInvoker := plugin.ipamInvoker
+	defer func() { plugin.ipamInvoker = ipamInvoker }()
+	for _, ep := range endpoints {
+		if _, ok := valid[cniTypes.GCAttachment{ContainerID: ep.ContainerID, IfName: ep.IfName}]; ok || ep.ContainerID == "" {
+			continue
+		}
+		log.Printf("[cni-net] Deleting endpoint %s of stale attachment {ContainerID:%s IfName:%s}.", ep.Id, ep.ContainerID, ep.IfName)
+		plugin.ipamInvoker = ipamInvoker
+		delArgs := &cniSkel.CmdArgs{
+			ContainerID: ep.ContainerID,
+			Netns:       ep.NetNsPath,
+			IfName:      ep.IfName,
+			Args:        fmt.Sprintf("K8S_POD_NAMESPACE=%s;K8S_POD_NAME=%s", ep.PODNameSpace, ep.PODName),
+			Path:        args.Path,
+			StdinData:   args.StdinData,
+		}
+		if delErr := plugin.Delete(delArgs); delErr != nil {
+			// the other endpoints are still collected, the runtime retries the GC later
+			log.Printf("[cni-net] Failed to delete endpoint %s: %v", ep.Id, delErr)
+			err = delErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if nwCfg.IPAM.Type != "" && nwCfg.IPAM.Type != network.AzureCNS {
+		if err = plugin.DelegateGC(nwCfg.IPAM.Type, nwCfg); err != nil {
+			return plugin.Errorf("Failed to collect IPAM garbage: %v", err)
+		}
+	}
+	return nil
+}
+
+// Status handles CNI STATUS commands. The plugin is available if its IPAM is: CNS must be reachable and not draining
+// the IPs of the node, and the STATUS of the other IPAM plugins is delegated to them.
+func (plugin *NetPlugin) Status(args *cniSkel.CmdArgs) error {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil {
+		return plugin.Errorf("Failed to parse network configuration: %v", err)
+	}
+
+	switch nwCfg.IPAM.Type {
+	case network.AzureCNS:
+		cnsClient, err := cnscli.New("", defaultRequestTimeout)
+		if err != nil {
+			return plugin.Errorf("Failed to create cns client: %v", err)
+		}
+		status, err := cnsClient.GetDrainStatus(plugin.context())
+		if err != nil {
+			return plugin.Error(cniTypes.NewError(cni.ErrPluginNotAvailable, "CNS is not reachable", err.Error()))
+		}
+		if status.Draining {
+			return plugin.Error(cniTypes.NewError(cni.ErrPluginNotAvailable, "CNS is draining the IPs of the node", ""))
+		}
+	case "":
+	default:
+		if err := plugin.DelegateStatus(nwCfg.IPAM.Type, nwCfg); err != nil {
+			return plugin.Error(cniTypes.NewError(cni.ErrPluginNotAvailable, "IPAM plugin is not available", err.Error()))
+		}
+	}
+	return nil
+}
+
 // Delete handles CNI delete commands.
+// Failures of a known class are returned with the CNI error code of the class, see toCNIError.
 func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
+	err := plugin.delete(args)
+	if err != nil {
+		plugin.recordFailure(CNI_DEL, args, err)
+	}
+	return toCNIError(err)
+}
+
+func (plugin *NetPlugin) delete(args *cniSkel.CmdArgs) error {
 	var (
 		err          error
 		nwCfg        *cni.NetworkConfig
@@ -927,6 +1224,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 		err = plugin.Errorf("[cni-net] Failed to parse network configuration: %v", err)
 		return err
 	}
+	log.SetRotation(nwCfg.LogRotation.RotationConfig())
 
 	// Parse Pod arguments.
 	if k8sPodName, k8sNamespace, err = plugin.getPodInfo(args.Args); err != nil {
@@ -934,6 +1232,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 	}
 
 	plugin.setCNIReportDetails(nwCfg, CNI_DEL, "")
+	plugin.status.Enable(nwCfg, args)
 	plugin.report.ContainerName = k8sPodName + ":" + k8sNamespace
 
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
@@ -947,6 +1246,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 			CustomDimensions: make(map[string]string),
 		}
 		SetCustomDimensions(&cniMetric, nwCfg, err)
+		plugin.setPhaseDimensions(&cniMetric)
 		telemetry.SendCNIMetric(&cniMetric, plugin.tb)
 	}
 
@@ -959,7 +1259,7 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 
 		// schedule send metric before attempting delete
 		defer sendMetricFunc()
-		_, err = plugin.nnsClient.DeleteContainerNetworking(context.Background(), k8sPodName, args.Netns)
+		_, err = plugin.nnsClient.DeleteContainerNetworking(plugin.context(), k8sPodName, args.Netns)
 		if err != nil {
 			return fmt.Errorf("nnsClient.DeleteContainerNetworking failed with err %w", err)
 		}
@@ -973,11 +1273,14 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 				log.Printf("[cni-net] failed to create cns client:%v", cnsErr)
 				return errors.Wrap(cnsErr, "failed to create cns client")
 			}
-			plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode))
+			plugin.ipamInvoker = NewCNSInvoker(k8sPodName, k8sNamespace, cnsClient, util.ExecutionMode(nwCfg.ExecutionMode), util.IpamMode(nwCfg.IPAM.Mode), plugin.correlationID)
 
 		default:
-			plugin.ipamInvoker = NewAzureIpamInvoker(plugin, &nwInfo)
+			if plugin.ipamInvoker, err = newAzureIPAMInvoker(plugin, &nwInfo); err != nil {
+				return err
+			}
 		}
+		plugin.ipamInvoker = newTimedIPAMInvoker(plugin.ipamInvoker, plugin.report)
 	}
 
 	// Loop through all the networks that are created for the given Netns. In case of multi-nic scenario ( currently supported
@@ -1015,10 +1318,19 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 			err = nil
 			return err
 		}
+		plugin.removeExpiredMirrors(networkID)
 
 		endpointID := GetEndpointID(args)
 		// Query the endpoint.
-		if epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID); err != nil {
+		epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID)
+		if err != nil {
+			if rebuiltID, ok := plugin.rebuiltEndpointID(networkID, args); ok {
+				log.Printf("[cni-net] Found rebuilt endpoint %s for endpointID: %s", rebuiltID, endpointID)
+				endpointID = rebuiltID
+				epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID)
+			}
+		}
+		if err != nil {
 			log.Printf("[cni-net] GetEndpoint for endpointID: %s returns: %v", endpointID, err)
 			if !nwCfg.MultiTenancy {
 				// attempt to release address associated with this Endpoint id
@@ -1037,7 +1349,10 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 		defer sendMetricFunc() //nolint:gocritic
 		logAndSendEvent(plugin, fmt.Sprintf("Deleting endpoint:%v", endpointID))
 		// Delete the endpoint.
-		if err = plugin.nm.DeleteEndpoint(networkID, endpointID); err != nil {
+		stopDataplaneTimer := plugin.report.TrackPhase(telemetry.PhaseDataplane)
+		err = plugin.nm.DeleteEndpoint(networkID, endpointID)
+		stopDataplaneTimer()
+		if err != nil {
 			// return a retriable error so the container runtime will retry this DEL later
 			// the implementation of this function returns nil if the endpoint doens't exist, so
 			// we don't have to check that here
@@ -1062,11 +1377,40 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 			}
 		}
 	}
+
+	if !nwCfg.MultiTenancy {
+		plugin.confirmPendingRelease(networkID)
+	}
 	sendEvent(plugin, fmt.Sprintf("CNI DEL succeeded : Released ip %+v podname %v namespace %v", nwCfg.IPAM.Address, k8sPodName, k8sNamespace))
 
 	return err
 }
 
+// confirmPendingRelease tells the IPAM source which of the IPs it is releasing from the node are no longer
+// used by any endpoint of the network. This is best effort: failures are logged and don't fail the DEL.
+func (plugin *NetPlugin) confirmPendingRelease(networkID string) {
+	confirmer, ok := plugin.ipamInvoker.(pendingReleaseConfirmer)
+	if !ok {
+		return
+	}
+
+	endpoints, err := plugin.nm.GetAllEndpoints(networkID)
+	if err != nil {
+		log.Printf("[cni-net] Failed to get endpoints of network %s to confirm IP release: %v", networkID, err)
+		return
+	}
+	endpointIPs := map[string]struct{}{}
+	for _, ep := range endpoints {
+		for i := range ep.IPAddresses {
+			endpointIPs[ep.IPAddresses[i].IP.String()] = struct{}{}
+		}
+	}
+
+	if err := confirmer.ConfirmPendingRelease(endpointIPs); err != nil {
+		log.Printf("[cni-net] Failed to confirm IP release: %v", err)
+	}
+}
+
 // Update handles CNI update commands.
 // Update is only supported for multitenancy and to update routes.
 func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
@@ -1091,11 +1435,13 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
 		return err
 	}
+	log.SetRotation(nwCfg.LogRotation.RotationConfig())
 
 	log.Printf("[cni-net] Read network configuration %+v.", nwCfg)
 
 	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
 	plugin.setCNIReportDetails(nwCfg, CNI_UPDATE, "")
+	plugin.status.Enable(nwCfg, args)
 
 	defer func() {
 		operationTimeMs := time.Since(startTime).Milliseconds()
@@ -1106,6 +1452,7 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 			CustomDimensions: make(map[string]string),
 		}
 		SetCustomDimensions(&cniMetric, nwCfg, err)
+		plugin.setPhaseDimensions(&cniMetric)
 		telemetry.SendCNIMetric(&cniMetric, plugin.tb)
 
 		if result == nil {
@@ -1186,7 +1533,7 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		return plugin.Errorf(err.Error())
 	}
 
-	if targetNetworkConfig, err = cnsclient.GetNetworkContainer(context.TODO(), orchestratorContext); err != nil {
+	if targetNetworkConfig, err = cnsclient.GetNetworkContainer(plugin.context(), orchestratorContext); err != nil {
 		log.Printf("GetNetworkContainer failed with %v", err)
 		return plugin.Errorf(err.Error())
 	}
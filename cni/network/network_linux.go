@@ -1,14 +1,19 @@
 package network
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/policy"
+	"github.com/Azure/azure-container-networking/platform"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
@@ -97,37 +102,65 @@ func setupInfraVnetRoutingForMultitenancy(
 }
 
 func getNetworkDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result) (network.DNSInfo, error) {
-	var nwDNS network.DNSInfo
-
-	if len(nwCfg.DNS.Nameservers) > 0 {
-		nwDNS = network.DNSInfo{
-			Servers: nwCfg.DNS.Nameservers,
-			Suffix:  nwCfg.DNS.Domain,
-		}
-	} else {
-		nwDNS = network.DNSInfo{
-			Suffix:  result.DNS.Domain,
-			Servers: result.DNS.Nameservers,
-		}
-	}
-
-	return nwDNS, nil
+	dns := resolveDNS(nwCfg, result)
+	return network.DNSInfo{
+		Servers: dns.Nameservers,
+		Suffix:  dns.Domain,
+		Options: dns.Options,
+	}, nil
 }
 
 func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, _ string) (network.DNSInfo, error) {
 	return getNetworkDNSSettings(nwCfg, result)
 }
 
+// addDNSToResult returns the DNS settings of the pod in the result, for the runtime to write its resolv.conf. The
+// nameservers are the ones of the endpoint, which include the node-local DNS cache if any.
+func addDNSToResult(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, epDNS network.DNSInfo) {
+	if result == nil {
+		return
+	}
+	dns := resolveDNS(nwCfg, result)
+	dns.Nameservers = epDNS.Servers
+	result.DNS = dns
+}
+
 func getEndpointPolicies(PolicyArgs) ([]policy.Policy, error) {
 	return nil, nil
 }
 
+// addOutboundNATExceptions is a dummy function for Linux platform, the exceptions are a Windows setting.
+func addOutboundNATExceptions(_ *cni.NetworkConfig, policies []policy.Policy) ([]policy.Policy, error) {
+	return policies, nil
+}
+
+// getNodeLocalDNSPolicies is a dummy function for Linux platform, the node-local DNS cache is routed via the host
+// by the routes of transparent mode, and by IPsToRouteViaHost in bridge mode.
+func getNodeLocalDNSPolicies(net.IP) ([]policy.Policy, error) {
+	return nil, nil
+}
+
 // getPoliciesFromRuntimeCfg returns network policies from network config.
 // getPoliciesFromRuntimeCfg is a dummy function for Linux platform.
 func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	return nil
 }
 
+// getBandwidthInfo returns the bandwidth limits of the endpoint from the runtime config.
+func getBandwidthInfo(nwCfg *cni.NetworkConfig) *network.BandwidthInfo {
+	bw := nwCfg.RuntimeConfig.Bandwidth
+	if bw.IsZero() {
+		return nil
+	}
+
+	return &network.BandwidthInfo{
+		IngressRate:  bw.IngressRate,
+		IngressBurst: bw.IngressBurst,
+		EgressRate:   bw.EgressRate,
+		EgressBurst:  bw.EgressBurst,
+	}
+}
+
 func addIPV6EndpointPolicy(nwInfo network.NetworkInfo) (policy.Policy, error) {
 	return policy.Policy{}, nil
 }
@@ -150,3 +183,28 @@ func (plugin *NetPlugin) isDualNicFeatureSupported(netNs string) bool {
 func getOverlayGateway(_ *net.IPNet) (net.IP, error) {
 	return net.ParseIP("169.254.1.1"), nil
 }
+
+// dataplaneError classifies errors of programming the network or endpoint. The network namespace
+// of the container not existing means the container is gone.
+func dataplaneError(err error, netns string) error {
+	var pathErr *fs.PathError
+	if netns != "" && errors.As(err, &pathErr) && pathErr.Path == netns && errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrNetnsNotFound, err)
+	}
+	return err
+}
+
+// getPlatformVersions returns the versions of the kernel and of iptables for failure records.
+func getPlatformVersions() map[string]string {
+	versions := make(map[string]string)
+	p := platform.NewExecClient()
+	for name, cmd := range map[string]string{"kernel": "uname -r", "iptables": "iptables --version"} {
+		out, err := p.ExecuteCommand(cmd)
+		if err != nil {
+			versions[name] = "unknown: " + err.Error()
+			continue
+		}
+		versions[name] = strings.TrimSpace(out)
+	}
+	return versions
+}
@@ -4,10 +4,15 @@
 package network
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/network"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -161,3 +166,39 @@ func TestAddSnatForDns(t *testing.T) {
 		})
 	}
 }
+
+func TestDataplaneErrorNetnsNotFound(t *testing.T) {
+	netns := filepath.Join(t.TempDir(), "netns")
+	_, err := os.Open(netns)
+	require.ErrorIs(t, dataplaneError(err, netns), ErrNetnsNotFound)
+
+	// errors of other paths are not classified
+	require.NotErrorIs(t, dataplaneError(err, "/var/run/netns/other"), ErrNetnsNotFound)
+	err = errors.New("failed to create veth pair")
+	require.Equal(t, err, dataplaneError(err, netns))
+}
+
+func TestAddDNSToResult(t *testing.T) {
+	nwCfg := &cni.NetworkConfig{
+		RuntimeConfig: cni.RuntimeConfig{
+			DNS: cni.RuntimeDNSConfig{
+				Servers:  []string{"10.0.0.10"},
+				Searches: []string{"default.svc.cluster.local"},
+				Options:  []string{"ndots:5"},
+			},
+		},
+	}
+	result := &current.Result{DNS: cniTypes.DNS{Nameservers: []string{"168.63.129.16"}}}
+
+	epDNS, err := getEndpointDNSSettings(nwCfg, result, "default")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.10"}, epDNS.Servers)
+	require.Equal(t, []string{"ndots:5"}, epDNS.Options)
+
+	// the nameservers of the endpoint, e.g. with the node-local DNS cache, are returned
+	epDNS.Servers = append([]string{"169.254.20.10"}, epDNS.Servers...)
+	addDNSToResult(nwCfg, result, epDNS)
+	require.Equal(t, []string{"169.254.20.10", "10.0.0.10"}, result.DNS.Nameservers)
+	require.Equal(t, []string{"default.svc.cluster.local"}, result.DNS.Search)
+	require.Equal(t, []string{"ndots:5"}, result.DNS.Options)
+}
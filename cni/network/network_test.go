@@ -17,6 +17,9 @@ import (
 	"github.com/Azure/azure-container-networking/nns"
 	"github.com/Azure/azure-container-networking/telemetry"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypes040 "github.com/containernetworking/cni/pkg/types/040"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,15 +41,7 @@ func TestMain(m *testing.M) {
 		Mode:              "bridge",
 		Master:            eth0IfName,
 		IPsToRouteViaHost: []string{"169.254.20.10"},
-		IPAM: struct {
-			Mode          string `json:"mode,omitempty"`
-			Type          string `json:"type"`
-			Environment   string `json:"environment,omitempty"`
-			AddrSpace     string `json:"addressSpace,omitempty"`
-			Subnet        string `json:"subnet,omitempty"`
-			Address       string `json:"ipAddress,omitempty"`
-			QueryInterval string `json:"queryInterval,omitempty"`
-		}{
+		IPAM: cni.IPAM{
 			Type: "azure-cns",
 		},
 	}
@@ -1103,3 +1098,56 @@ func TestGetPodSubnetNatInfo(t *testing.T) {
 		require.Empty(t, natInfo, "linux podsubnet natInfo should be empty")
 	}
 }
+
+func TestSetContainerInterface(t *testing.T) {
+	newResult := func() *cniTypesCurr.Result {
+		return &cniTypesCurr.Result{
+			Interfaces: []*cniTypesCurr.Interface{{Name: "eth0"}},
+			IPs: []*cniTypesCurr.IPConfig{
+				{Address: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+				{Address: net.IPNet{IP: net.ParseIP("169.254.100.4"), Mask: net.CIDRMask(32, 32)}, Interface: cniTypesCurr.Int(1)},
+			},
+		}
+	}
+
+	// the results of the versions before 1.0.0 are unchanged
+	result := newResult()
+	setContainerInterface(result, 0, "/var/run/netns/test", "0.3.0")
+	require.Equal(t, newResult(), result)
+
+	result = newResult()
+	setContainerInterface(result, 0, "/var/run/netns/test", "1.0.0")
+	require.Equal(t, "/var/run/netns/test", result.Interfaces[0].Sandbox)
+	require.Equal(t, cniTypesCurr.Int(0), result.IPs[0].Interface)
+	require.Equal(t, cniTypesCurr.Int(1), result.IPs[1].Interface)
+
+	// the IPs are the same in the results of the older versions
+	converted, err := result.GetAsVersion("0.3.1")
+	require.NoError(t, err)
+	require.Len(t, converted.(*cniTypes040.Result).IPs, 2)
+}
+
+func TestPluginGC(t *testing.T) {
+	plugin := GetTestResources()
+	for _, containerID := range []string{"live-container", "stale-container"} {
+		addArgs := &cniSkel.CmdArgs{
+			StdinData:   nwCfg.Serialize(),
+			ContainerID: containerID,
+			Netns:       containerID,
+			Args:        fmt.Sprintf("K8S_POD_NAME=%v;K8S_POD_NAMESPACE=%v", containerID, "test-pod-ns"),
+			IfName:      eth0IfName,
+		}
+		require.NoError(t, plugin.Add(addArgs))
+	}
+
+	gcCfg := nwCfg
+	gcCfg.ValidAttachments = []cniTypes.GCAttachment{{ContainerID: "live-container", IfName: eth0IfName}}
+	require.NoError(t, plugin.GC(&cniSkel.CmdArgs{StdinData: gcCfg.Serialize()}))
+
+	endpoints, err := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	for _, ep := range endpoints {
+		assert.Equal(t, "live-container", ep.ContainerID)
+	}
+}
@@ -32,6 +32,8 @@ var (
 	win1903Version = 18362
 )
 
+const bitsPerByte = 8
+
 /* handleConsecutiveAdd handles consecutive add calls for infrastructure containers on Windows platform.
  * This is a temporary work around for issue #57253 of Kubernetes.
  * We can delete this if statement once they fix it.
@@ -186,58 +188,61 @@ func setupInfraVnetRoutingForMultitenancy(
 }
 
 func getNetworkDNSSettings(nwCfg *cni.NetworkConfig, _ *cniTypesCurr.Result) (network.DNSInfo, error) {
-	var nwDNS network.DNSInfo
-
-	// use custom dns if present
-	nwDNS = getCustomDNS(nwCfg)
-	if len(nwDNS.Servers) > 0 || nwDNS.Suffix != "" {
-		return nwDNS, nil
+	if err := validateDNSConfig(nwCfg); err != nil {
+		return network.DNSInfo{}, err
 	}
 
-	if (len(nwCfg.DNS.Search) == 0) != (len(nwCfg.DNS.Nameservers) == 0) {
-		err := fmt.Errorf("Wrong DNS configuration: %+v", nwCfg.DNS)
-		return nwDNS, err
-	}
+	dns := resolveDNS(nwCfg, nil)
+	return network.DNSInfo{
+		Servers: dns.Nameservers,
+		Suffix:  dnsSuffix(nwCfg, dns, ""),
+		Options: dns.Options,
+	}, nil
+}
 
-	nwDNS = network.DNSInfo{
-		Servers: nwCfg.DNS.Nameservers,
+func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, namespace string) (network.DNSInfo, error) {
+	if err := validateDNSConfig(nwCfg); err != nil {
+		return network.DNSInfo{}, err
 	}
 
-	return nwDNS, nil
+	dns := resolveDNS(nwCfg, result)
+	return network.DNSInfo{
+		Servers: dns.Nameservers,
+		Suffix:  dnsSuffix(nwCfg, dns, namespace),
+		Options: dns.Options,
+	}, nil
 }
 
-func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Result, namespace string) (network.DNSInfo, error) {
-	var epDNS network.DNSInfo
-
-	// use custom dns if present
-	epDNS = getCustomDNS(nwCfg)
-	if len(epDNS.Servers) > 0 || epDNS.Suffix != "" {
-		return epDNS, nil
+// validateDNSConfig checks that the network configuration sets the search domains and nameservers together, unless
+// the runtime sets the DNS of the pod.
+func validateDNSConfig(nwCfg *cni.NetworkConfig) error {
+	runtimeDNS := nwCfg.RuntimeConfig.DNS
+	if len(runtimeDNS.Servers) > 0 || len(runtimeDNS.Searches) > 0 {
+		return nil
 	}
-
 	if (len(nwCfg.DNS.Search) == 0) != (len(nwCfg.DNS.Nameservers) == 0) {
-		err := fmt.Errorf("Wrong DNS configuration: %+v", nwCfg.DNS)
-		return epDNS, err
+		return fmt.Errorf("Wrong DNS configuration: %+v", nwCfg.DNS)
 	}
+	return nil
+}
 
-	if len(nwCfg.DNS.Search) > 0 {
-		epDNS = network.DNSInfo{
-			Servers: nwCfg.DNS.Nameservers,
-			Suffix:  namespace + "." + strings.Join(nwCfg.DNS.Search, ","),
-			Options: nwCfg.DNS.Options,
-		}
-	} else {
-		epDNS = network.DNSInfo{
-			Servers: result.DNS.Nameservers,
-			Suffix:  result.DNS.Domain,
-			Options: nwCfg.DNS.Options,
-		}
+// dnsSuffix returns the DNS suffix of the HNS network or endpoint: the comma separated search domains, else the
+// domain. The search domains of the network configuration are relative to the namespace of the pod, if any, while the
+// ones of the runtime are used as is.
+func dnsSuffix(nwCfg *cni.NetworkConfig, dns cniTypes.DNS, namespace string) string {
+	if len(dns.Search) == 0 {
+		return dns.Domain
 	}
-
-	return epDNS, nil
+	suffix := strings.Join(dns.Search, ",")
+	if namespace != "" && len(nwCfg.RuntimeConfig.DNS.Searches) == 0 {
+		suffix = namespace + "." + suffix
+	}
+	return suffix
 }
 
-// getPoliciesFromRuntimeCfg returns network policies from network config.
+// addDNSToResult is a dummy function for Windows platform, the DNS settings are applied to the HNS endpoint.
+func addDNSToResult(*cni.NetworkConfig, *cniTypesCurr.Result, network.DNSInfo) {}
+
 func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	log.Printf("[net] RuntimeConfigs: %+v", nwCfg.RuntimeConfig)
 	var policies []policy.Policy
@@ -276,9 +281,39 @@ func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 		policies = append(policies, policy)
 	}
 
+	if bw := nwCfg.RuntimeConfig.Bandwidth; !bw.IsZero() {
+		if bw.IngressRate > 0 {
+			log.Printf("[net] Ingress bandwidth limit %d bit/s is not supported on Windows, ignoring it", bw.IngressRate)
+		}
+
+		if bw.EgressRate > 0 {
+			rawPolicy, _ := json.Marshal(&hnsv2.QosPolicySetting{
+				MaximumOutgoingBandwidthInBytes: bw.EgressRate / bitsPerByte,
+			})
+
+			hnsv2Policy, _ := json.Marshal(&hnsv2.EndpointPolicy{
+				Type:     hnsv2.QOS,
+				Settings: rawPolicy,
+			})
+
+			policy := policy.Policy{
+				Type: policy.EndpointPolicy,
+				Data: hnsv2Policy,
+			}
+			log.Printf("[net] Creating QoS policy: %+v", policy)
+
+			policies = append(policies, policy)
+		}
+	}
+
 	return policies
 }
 
+// getBandwidthInfo returns nil, the bandwidth limits are programmed as a QoS policy by getPoliciesFromRuntimeCfg.
+func getBandwidthInfo(_ *cni.NetworkConfig) *network.BandwidthInfo {
+	return nil
+}
+
 func getEndpointPolicies(args PolicyArgs) ([]policy.Policy, error) {
 	var policies []policy.Policy
 
@@ -301,6 +336,43 @@ func getEndpointPolicies(args PolicyArgs) ([]policy.Policy, error) {
 	return policies, nil
 }
 
+// addOutboundNATExceptions adds the outbound NAT exceptions of the network config to the OutBoundNAT policy
+// of the endpoint, so that the traffic to them isn't SNATed.
+func addOutboundNATExceptions(nwCfg *cni.NetworkConfig, policies []policy.Policy) ([]policy.Policy, error) {
+	exceptions := nwCfg.WindowsSettings.OutboundNATExceptions
+	if len(exceptions) == 0 {
+		return policies, nil
+	}
+
+	for _, exception := range exceptions {
+		if _, _, err := net.ParseCIDR(exception); err != nil {
+			return nil, errors.Wrapf(err, "invalid outbound NAT exception %s", exception)
+		}
+	}
+
+	log.Printf("[net] Adding outbound NAT exceptions %v", exceptions)
+	policies, err := policy.AddOutBoundNATExceptions(policies, exceptions)
+	return policies, errors.Wrap(err, "failed to add outbound NAT exceptions")
+}
+
+// getNodeLocalDNSPolicies returns the ROUTE policy sending the traffic to the node-local DNS cache to the host,
+// instead of the gateway of the subnet which can't reach the link-local IP of the cache.
+func getNodeLocalDNSPolicies(ip net.IP) ([]policy.Policy, error) {
+	bits := net.IPv4len * 8
+	if ip.To4() == nil {
+		bits = net.IPv6len * 8
+	}
+	data, err := json.Marshal(policy.KVPairRoute{
+		Type:              policy.RoutePolicy,
+		DestinationPrefix: (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String(),
+		NeedEncap:         true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal node-local DNS route policy")
+	}
+	return []policy.Policy{{Type: policy.EndpointPolicy, Data: data}}, nil
+}
+
 func getLoopbackDSRPolicy(args PolicyArgs) ([]policy.Policy, error) {
 	var policies []policy.Policy
 	for _, config := range args.ipconfigs {
@@ -349,19 +421,6 @@ func getIPV6EndpointPolicy(nwInfo *network.NetworkInfo) (policy.Policy, error) {
 	return eppolicy, nil
 }
 
-func getCustomDNS(nwCfg *cni.NetworkConfig) network.DNSInfo {
-	var search string
-	if len(nwCfg.RuntimeConfig.DNS.Searches) > 0 {
-		search = strings.Join(nwCfg.RuntimeConfig.DNS.Searches, ",")
-	}
-
-	return network.DNSInfo{
-		Servers: nwCfg.RuntimeConfig.DNS.Servers,
-		Suffix:  search,
-		Options: nwCfg.RuntimeConfig.DNS.Options,
-	}
-}
-
 func determineWinVer() {
 	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE)
 	if err == nil {
@@ -425,3 +484,29 @@ func getOverlayGateway(podsubnet *net.IPNet) (net.IP, error) {
 
 	return ncgw, nil
 }
+
+// dataplaneError classifies errors of programming the network or endpoint, which are HNS calls on Windows.
+func dataplaneError(err error, _ string) error {
+	return fmt.Errorf("%w: %w", ErrHNSFailure, err)
+}
+
+// getPlatformVersions returns the Windows build and the HNS version for failure records.
+func getPlatformVersions() map[string]string {
+	versions := make(map[string]string)
+
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE)
+	if err == nil {
+		defer k.Close()
+		if build, _, err := k.GetStringValue("CurrentBuild"); err == nil {
+			versions["windows"] = build
+		}
+	}
+
+	if globals, err := hnsv2.GetGlobals(); err == nil {
+		versions["hns"] = fmt.Sprintf("%d.%d", globals.Version.Major, globals.Version.Minor)
+	} else {
+		versions["hns"] = "unknown: " + err.Error()
+	}
+
+	return versions
+}
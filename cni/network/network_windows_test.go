@@ -15,6 +15,7 @@ import (
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -315,6 +316,54 @@ func TestDSRPolciy(t *testing.T) {
 	}
 }
 
+func TestAddOutboundNATExceptions(t *testing.T) {
+	netconfPolicies := []policy.Policy{
+		{
+			Type: policy.EndpointPolicy,
+			Data: []byte(`{"Type": "OutBoundNAT", "ExceptionList": ["10.240.0.0/16"]}`),
+		},
+	}
+
+	tests := []struct {
+		name           string
+		exceptions     []string
+		wantExceptions []string
+		wantErr        bool
+	}{
+		{
+			name:           "no exceptions",
+			wantExceptions: []string{"10.240.0.0/16"},
+		},
+		{
+			name:           "exceptions added to the netconf policy",
+			exceptions:     []string{"192.168.0.0/16", "172.16.0.0/12"},
+			wantExceptions: []string{"10.240.0.0/16", "192.168.0.0/16", "172.16.0.0/12"},
+		},
+		{
+			name:       "invalid exception",
+			exceptions: []string{"192.168.0.0"},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{WindowsSettings: cni.WindowsSettings{OutboundNATExceptions: tt.exceptions}}
+			policies, err := addOutboundNATExceptions(nwCfg, netconfPolicies)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, policies, 1)
+			exceptions, err := policy.GetOutBoundNatExceptionList(policies[0])
+			require.NoError(t, err)
+			require.Equal(t, tt.wantExceptions, exceptions)
+		})
+	}
+}
+
 func TestGetNetworkNameFromCNS(t *testing.T) {
 	plugin, _ := cni.NewPlugin("name", "0.3.0")
 	tests := []struct {
@@ -512,3 +561,36 @@ func TestGetNetworkNameFromCNS(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNodeLocalDNSPolicies(t *testing.T) {
+	policies, err := getNodeLocalDNSPolicies(net.ParseIP("169.254.20.10"))
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	require.Equal(t, policy.EndpointPolicy, policies[0].Type)
+	require.JSONEq(t, `{"Type":"ROUTE","DestinationPrefix":"169.254.20.10/32","NeedEncap":true}`, string(policies[0].Data))
+}
+
+func TestGetEndpointDNSSettings(t *testing.T) {
+	netconfDNS := cniTypes.DNS{Nameservers: []string{"10.0.0.10"}, Search: []string{"svc.cluster.local"}}
+	runtimeDNS := cni.RuntimeDNSConfig{Servers: []string{"10.0.0.53"}, Searches: []string{"default.svc.cluster.local", "svc.cluster.local"}}
+	result := &cniTypesCurr.Result{DNS: cniTypes.DNS{Nameservers: []string{"168.63.129.16"}, Domain: "ipam.internal"}}
+
+	// the search domains of the network configuration are relative to the namespace
+	epDNS, err := getEndpointDNSSettings(&cni.NetworkConfig{DNS: netconfDNS}, result, "default")
+	require.NoError(t, err)
+	require.Equal(t, network.DNSInfo{Servers: []string{"10.0.0.10"}, Suffix: "default.svc.cluster.local"}, epDNS)
+
+	// the ones of the runtime aren't
+	nwCfg := &cni.NetworkConfig{DNS: netconfDNS, RuntimeConfig: cni.RuntimeConfig{DNS: runtimeDNS}}
+	epDNS, err = getEndpointDNSSettings(nwCfg, result, "default")
+	require.NoError(t, err)
+	require.Equal(t, network.DNSInfo{Servers: []string{"10.0.0.53"}, Suffix: "default.svc.cluster.local,svc.cluster.local"}, epDNS)
+
+	// the domain of IPAM is used without search domains
+	epDNS, err = getEndpointDNSSettings(&cni.NetworkConfig{}, result, "default")
+	require.NoError(t, err)
+	require.Equal(t, network.DNSInfo{Servers: []string{"168.63.129.16"}, Suffix: "ipam.internal"}, epDNS)
+
+	_, err = getEndpointDNSSettings(&cni.NetworkConfig{DNS: cniTypes.DNS{Search: []string{"svc.cluster.local"}}}, result, "default")
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/pkg/errors"
+)
+
+var errInvalidNodeLocalDNSIP = errors.New("invalid node-local DNS IP")
+
+// hostInterfaceAddrs returns the addresses of the host interfaces.
+var hostInterfaceAddrs = net.InterfaceAddrs
+
+// nodeLocalDNSServer returns the IP of the node-local DNS cache the pods use, or nil if they don't use one.
+// In auto mode, the cache is detected by its IP being assigned to a host interface, e.g. the nodelocaldns dummy
+// interface on Linux. A failed detection falls back to the DNS servers of the cluster.
+func nodeLocalDNSServer(cfg *cni.NodeLocalDNS) (net.IP, error) {
+	if cfg == nil || cfg.Mode == "" || cfg.Mode == cni.NodeLocalDNSDisabled {
+		return nil, nil
+	}
+
+	ipStr := cfg.IP
+	if ipStr == "" {
+		ipStr = cni.DefaultNodeLocalDNSIP
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return nil, errors.Wrapf(errInvalidNodeLocalDNSIP, "%q", ipStr)
+	}
+	if cfg.Mode != cni.NodeLocalDNSAuto {
+		return ip, nil
+	}
+
+	addrs, err := hostInterfaceAddrs()
+	if err != nil {
+		log.Printf("[cni-net] Failed to list host addresses to detect node-local DNS cache %s: %v", ip, err)
+		return nil, nil
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return ip, nil
+		}
+	}
+	log.Printf("[cni-net] Node-local DNS cache %s not detected on the host", ip)
+	return nil, nil
+}
+
+// withNodeLocalDNS makes the node-local DNS cache the first DNS server, keeping the other servers as fallbacks.
+func withNodeLocalDNS(dns network.DNSInfo, ip net.IP) network.DNSInfo {
+	servers := make([]string, 0, len(dns.Servers)+1)
+	servers = append(servers, ip.String())
+	for _, server := range dns.Servers {
+		if server != ip.String() {
+			servers = append(servers, server)
+		}
+	}
+	dns.Servers = servers
+	return dns
+}
+
+// withIPRoutedViaHost returns the IPs routed via the host including ip, without modifying ips.
+func withIPRoutedViaHost(ips []string, ip net.IP) []string {
+	for _, routed := range ips {
+		if routed == ip.String() {
+			return ips
+		}
+	}
+	return append(append(make([]string, 0, len(ips)+1), ips...), ip.String())
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeLocalDNSServer(t *testing.T) {
+	defer func(addrs func() ([]net.Addr, error)) { hostInterfaceAddrs = addrs }(hostInterfaceAddrs)
+	hostAddrs := []net.Addr{&net.IPNet{IP: net.ParseIP("10.240.0.4"), Mask: net.CIDRMask(16, 32)}}
+	hostInterfaceAddrs = func() ([]net.Addr, error) { return hostAddrs, nil }
+
+	tests := []struct {
+		name    string
+		cfg     *cni.NodeLocalDNS
+		want    string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "empty", cfg: &cni.NodeLocalDNS{}},
+		{name: "disabled", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSDisabled}},
+		{name: "enabled", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSEnabled}, want: cni.DefaultNodeLocalDNSIP},
+		{name: "enabled with IP", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSEnabled, IP: "169.254.0.10"}, want: "169.254.0.10"},
+		{name: "invalid IP", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSEnabled, IP: "nodelocaldns"}, wantErr: true},
+		{name: "auto not detected", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSAuto}},
+		{name: "auto detected", cfg: &cni.NodeLocalDNS{Mode: cni.NodeLocalDNSAuto, IP: "10.240.0.4"}, want: "10.240.0.4"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := nodeLocalDNSServer(tt.cfg)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidNodeLocalDNSIP)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == "" {
+				require.Nil(t, ip)
+				return
+			}
+			require.Equal(t, tt.want, ip.String())
+		})
+	}
+}
+
+func TestWithNodeLocalDNS(t *testing.T) {
+	ip := net.ParseIP(cni.DefaultNodeLocalDNSIP)
+	dns := withNodeLocalDNS(network.DNSInfo{Servers: []string{"10.0.0.10", cni.DefaultNodeLocalDNSIP}, Suffix: "svc.cluster.local"}, ip)
+	require.Equal(t, []string{cni.DefaultNodeLocalDNSIP, "10.0.0.10"}, dns.Servers)
+	require.Equal(t, "svc.cluster.local", dns.Suffix)
+
+	configured := []string{"10.1.0.1"}
+	routed := withIPRoutedViaHost(configured, ip)
+	require.Equal(t, []string{"10.1.0.1", cni.DefaultNodeLocalDNSIP}, routed)
+	require.Equal(t, []string{"10.1.0.1"}, configured)
+	require.Equal(t, routed, withIPRoutedViaHost(routed, ip))
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/pkg/errors"
+)
+
+// nodeLocalNATInterfacePrefix prefixes the scope of the node-local NAT IP in the name of its interface in the result.
+const nodeLocalNATInterfacePrefix = "nodelocalnat-"
+
+var errInvalidNodeLocalNATSubnet = errors.New("invalid node-local NAT subnet")
+
+// nodeLocalNATInfo returns the node-local NAT IP the network manager allocates to the endpoint, or nil if the pods
+// don't get one.
+func nodeLocalNATInfo(cfg *cni.NodeLocalNAT) (*network.NodeLocalNATInfo, error) {
+	if cfg == nil || cfg.Subnet == "" {
+		return nil, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(cfg.Subnet)
+	if err != nil {
+		return nil, errors.Wrapf(errInvalidNodeLocalNATSubnet, "%q", cfg.Subnet)
+	}
+	return &network.NodeLocalNATInfo{
+		Subnet:       *subnet,
+		PerNamespace: cfg.Scope == cni.NodeLocalNATScopeNamespace,
+	}, nil
+}
+
+// addNodeLocalNATToResult adds the node-local NAT IP of the endpoint to the result, as an address of an interface
+// named after the scope of the IP, e.g. nodelocalnat-pod. Like the SNAT interface, the interface has no sandbox, so
+// that the runtime doesn't report the IP as an IP of the pod.
+func addNodeLocalNATToResult(result *cniTypesCurr.Result, natInfo *network.NodeLocalNATInfo) {
+	if result == nil || natInfo == nil || natInfo.IP == nil {
+		return
+	}
+
+	scope := cni.NodeLocalNATScopePod
+	if natInfo.PerNamespace {
+		scope = cni.NodeLocalNATScopeNamespace
+	}
+	index := len(result.Interfaces)
+	result.Interfaces = append(result.Interfaces, &cniTypesCurr.Interface{Name: nodeLocalNATInterfacePrefix + scope})
+
+	bits := net.IPv4len * 8
+	if natInfo.IP.To4() == nil {
+		bits = net.IPv6len * 8
+	}
+	result.IPs = append(result.IPs, &cniTypesCurr.IPConfig{
+		Interface: &index,
+		Address:   net.IPNet{IP: natInfo.IP, Mask: net.CIDRMask(bits, bits)},
+	})
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeLocalNATInfo(t *testing.T) {
+	natInfo, err := nodeLocalNATInfo(nil)
+	require.NoError(t, err)
+	require.Nil(t, natInfo)
+
+	natInfo, err = nodeLocalNATInfo(&cni.NodeLocalNAT{})
+	require.NoError(t, err)
+	require.Nil(t, natInfo)
+
+	natInfo, err = nodeLocalNATInfo(&cni.NodeLocalNAT{Subnet: "169.254.100.7/24"})
+	require.NoError(t, err)
+	require.Equal(t, "169.254.100.0/24", natInfo.Subnet.String())
+	require.False(t, natInfo.PerNamespace)
+
+	natInfo, err = nodeLocalNATInfo(&cni.NodeLocalNAT{Subnet: "169.254.100.0/24", Scope: cni.NodeLocalNATScopeNamespace})
+	require.NoError(t, err)
+	require.True(t, natInfo.PerNamespace)
+
+	_, err = nodeLocalNATInfo(&cni.NodeLocalNAT{Subnet: "169.254.100.0"})
+	require.ErrorIs(t, err, errInvalidNodeLocalNATSubnet)
+}
+
+func TestAddNodeLocalNATToResult(t *testing.T) {
+	podIP := &cniTypesCurr.IPConfig{Address: net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}}
+	result := &cniTypesCurr.Result{
+		Interfaces: []*cniTypesCurr.Interface{{Name: "eth0"}},
+		IPs:        []*cniTypesCurr.IPConfig{podIP},
+	}
+
+	addNodeLocalNATToResult(result, &network.NodeLocalNATInfo{})
+	require.Len(t, result.IPs, 1, "no IP should be added when none was allocated")
+
+	addNodeLocalNATToResult(result, &network.NodeLocalNATInfo{PerNamespace: true, IP: net.ParseIP("169.254.100.1")})
+	require.Len(t, result.Interfaces, 2)
+	require.Equal(t, "nodelocalnat-namespace", result.Interfaces[1].Name)
+	require.Empty(t, result.Interfaces[1].Sandbox)
+	require.Len(t, result.IPs, 2)
+	require.Equal(t, podIP, result.IPs[0], "the pod IP should stay first")
+	require.Equal(t, "169.254.100.1/32", result.IPs[1].Address.String())
+	require.Equal(t, 1, *result.IPs[1].Interface)
+}
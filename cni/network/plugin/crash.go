@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/network"
+	"github.com/Azure/azure-container-networking/log"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+)
+
+// stdinRecorder keeps a copy of the standard input which is passed through to the plugin, so that the network
+// configuration is in the crash report whichever step of the command panics.
+type stdinRecorder struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *stdinRecorder) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.buf.Write(p) //nolint:wrapcheck // writing to a buffer doesn't fail
+}
+
+func (r *stdinRecorder) Bytes() []byte {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	return append([]byte(nil), r.buf.Bytes()...)
+}
+
+// recordStdin replaces the standard input with a pipe which the standard input is copied to as it's read, so that the
+// commands which don't read it, e.g. a watch of the endpoint state, aren't blocked.
+func recordStdin() *stdinRecorder {
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Errorf("Failed to record stdin, the crash reports won't have the network configuration: %v", err)
+		return nil
+	}
+
+	recorder := &stdinRecorder{}
+	stdin := os.Stdin
+	os.Stdin = r
+	go func() {
+		// the recorder is written first, so that it has what the plugin read when it panics
+		_, _ = io.Copy(io.MultiWriter(recorder, w), stdin)
+		w.Close()
+	}()
+	return recorder
+}
+
+// executeWithRecovery runs the command, and turns a panic into a crash report and a CNI error on stdout, instead of
+// exiting without a result.
+func executeWithRecovery(stdin *stdinRecorder) (err error) {
+	onPanic := func(recovered interface{}, stack []byte) error {
+		return handlePanic(recovered, stack, stdin.Bytes())
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = onPanic(r, debug.Stack())
+		}
+	}()
+	return rootExecute(onPanic)
+}
+
+// handlePanic writes the crash report of the panic to the log directory, reports the crash to telemetry,
+// and prints the CNI error of the command.
+func handlePanic(recovered interface{}, stack, stdinData []byte) error {
+	log.Errorf("Recovered panic: %v\n%s", recovered, stack)
+
+	report := network.NewCrashReport(recovered, stack, version, stdinData)
+	details := "no crash report"
+	path, err := network.WriteCrashReport(log.GetLogDirectory(), &report)
+	if err != nil {
+		log.Errorf("Failed to write crash report: %v", err)
+	} else {
+		log.Printf("Wrote crash report to %s", path)
+		details = "crash report: " + path
+	}
+
+	reportCrash(fmt.Sprintf("[%d] %s panicked: %v, %s", os.Getpid(), report.Command, recovered, details))
+
+	cniErr := &cniTypes.Error{
+		Code:    cni.ErrRuntime,
+		Msg:     fmt.Sprintf("%s panicked: %v", name, recovered),
+		Details: details,
+	}
+	if err := cniErr.Print(); err != nil {
+		log.Errorf("Failed to print CNI error: %v", err)
+	}
+	return cniErr
+}
@@ -4,22 +4,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
 	"time"
 
-	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cni/api"
 	"github.com/Azure/azure-container-networking/cni/network"
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/nns"
-	"github.com/Azure/azure-container-networking/platform"
-	"github.com/Azure/azure-container-networking/store"
 	"github.com/Azure/azure-container-networking/telemetry"
 	"github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
@@ -27,12 +27,14 @@ import (
 )
 
 const (
-	hostNetAgentURL                 = "http://168.63.129.16/machine/plugins?comp=netagent&type=cnireport"
-	ipamQueryURL                    = "http://168.63.129.16/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
-	pluginName                      = "CNI"
-	telemetryNumRetries             = 5
-	telemetryWaitTimeInMilliseconds = 200
-	name                            = "azure-vnet"
+	hostNetAgentURL = "http://168.63.129.16/machine/plugins?comp=netagent&type=cnireport"
+	pluginName      = "CNI"
+	name            = "azure-vnet"
+
+	optWatch        = "watch"
+	optWatchAlias   = "w"
+	optRebuildState = "rebuild-state"
+	optStatus       = "status"
 )
 
 // Version is populated by make during build.
@@ -47,6 +49,25 @@ var args = common.ArgumentList{
 		Type:         "bool",
 		DefaultValue: false,
 	},
+	{
+		Name:         optWatch,
+		Shorthand:    optWatchAlias,
+		Description:  "With CNI_COMMAND=" + cni.CmdGetEndpointsState + ", stream endpoint state changes as JSON lines until interrupted",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         optRebuildState,
+		Description:  "Add the endpoints found on the host for the network configuration on stdin to the state, and print their IDs",
+		Type:         "bool",
+		DefaultValue: false,
+	},
+	{
+		Name:         optStatus,
+		Description:  "Print the current phase of the in-progress commands whose network configuration enables the status file",
+		Type:         "bool",
+		DefaultValue: false,
+	},
 }
 
 // Prints version information.
@@ -137,7 +158,7 @@ func printCNIError(msg string) {
 	cniErr.Print()
 }
 
-func rootExecute() error {
+func rootExecute(onPanic func(recovered interface{}, stack []byte) error) error {
 	var (
 		config common.PluginConfig
 		tb     *telemetry.TelemetryBuffer
@@ -162,53 +183,42 @@ func rootExecute() error {
 		name,
 		&config,
 		&nns.GrpcClient{},
-		&network.Multitenancy{},
+		newMultitenancyClient(),
 	)
 	if err != nil {
 		printCNIError(fmt.Sprintf("Failed to create network plugin, err:%v.\n", err))
 		return errors.Wrap(err, "Create plugin error")
 	}
+	netPlugin.Plugin.OnPanic = onPanic
 
 	// Check CNI_COMMAND value
 	cniCmd := os.Getenv(cni.Cmd)
 
 	if cniCmd != cni.CmdVersion {
+		// correlate the logs, the report and the requests to CNS and NNS of the invocation
+		correlationID := common.NewCorrelationID()
+		log.SetCorrelationID(correlationID)
+		cniReport.CorrelationID = correlationID
+		netPlugin.SetCorrelationID(correlationID)
+
 		log.Printf("CNI_COMMAND environment variable set to %s", cniCmd)
 
-		cniReport.GetReport(pluginName, version, ipamQueryURL)
+		// the phases are recorded from the start, so that the status file shows a command waiting for the lock
+		status := network.NewStatusFile(log.GetLogDirectory(), cniCmd, correlationID)
+		cniReport.PhaseObserver = status.ObservePhase
+		netPlugin.SetStatusFile(status)
+		defer status.Close()
 
-		var upTime time.Time
-		upTime, err = platform.GetLastRebootTime()
-		if err == nil {
-			cniReport.VMUptime = upTime.Format("2006-01-02 15:04:05")
-		}
+		getReport(cniReport)
 
 		// CNI Acquires lock
-		if err = netPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
+		stopLockTimer := cniReport.TrackPhase(telemetry.PhaseLockWait)
+		err = netPlugin.Plugin.InitializeKeyValueStore(&config)
+		stopLockTimer()
+		if err != nil {
 			printCNIError(fmt.Sprintf("Failed to initialize key-value store of network plugin: %v", err))
 
-			tb = telemetry.NewTelemetryBuffer()
-			if tberr := tb.Connect(); tberr != nil {
-				log.Errorf("Cannot connect to telemetry service:%v", tberr)
-				return errors.Wrap(err, "lock acquire error")
-			}
-
-			reportPluginError(reportManager, tb, err)
-
-			if errors.Is(err, store.ErrTimeoutLockingStore) {
-				var cniMetric telemetry.AIMetric
-				cniMetric.Metric = aitelemetry.Metric{
-					Name:             telemetry.CNILockTimeoutStr,
-					Value:            1.0,
-					CustomDimensions: make(map[string]string),
-				}
-				sendErr := telemetry.SendCNIMetric(&cniMetric, tb)
-				if sendErr != nil {
-					log.Errorf("Couldn't send cnilocktimeout metric: %v", sendErr)
-				}
-			}
-
-			tb.Close()
+			reportLockError(reportManager, err)
 			return errors.Wrap(err, "lock acquire error")
 		}
 
@@ -216,16 +226,11 @@ func rootExecute() error {
 			if errUninit := netPlugin.Plugin.UninitializeKeyValueStore(); errUninit != nil {
 				log.Errorf("Failed to uninitialize key-value store of network plugin, err:%v.\n", errUninit)
 			}
-
-			if recover() != nil {
-				os.Exit(1)
-			}
 		}()
 
 		// Start telemetry process if not already started. This should be done inside lock, otherwise multiple process
 		// end up creating/killing telemetry process results in undesired state.
-		tb = telemetry.NewTelemetryBuffer()
-		tb.ConnectToTelemetryService(telemetryNumRetries, telemetryWaitTimeInMilliseconds)
+		tb = startTelemetry()
 		defer tb.Close()
 
 		netPlugin.SetCNIReport(cniReport, tb)
@@ -236,14 +241,37 @@ func rootExecute() error {
 		if err = netPlugin.Start(&config); err != nil {
 			printCNIError(fmt.Sprintf("Failed to start network plugin, err:%v.\n", err))
 			reportPluginError(reportManager, tb, err)
-			panic("network plugin start fatal error")
+			return errors.Wrap(err, "Start plugin error")
+		}
+
+		if common.GetArg(optRebuildState).(bool) {
+			return errors.Wrap(rebuildState(netPlugin), "Rebuild state error")
 		}
 
 		// used to dump state
 		if cniCmd == cni.CmdGetEndpointsState {
-			log.Printf("Retrieving state")
+			var filter api.EndpointStateFilter
+			filter, err = api.ParseEndpointStateFilter(os.Getenv("CNI_ARGS"))
+			if err != nil {
+				log.Errorf("Failed to parse endpoint state filter, err:%v.\n", err)
+				return errors.Wrap(err, "Parse endpoint state filter error")
+			}
+
+			if common.GetArg(optWatch).(bool) {
+				log.Printf("Watching state with filter %+v", filter)
+				// the store is locked only while reading the state, so that CNI commands aren't blocked while watching
+				if err = netPlugin.Plugin.UninitializeKeyValueStore(); err != nil {
+					return errors.Wrap(err, "unlock store error")
+				}
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer cancel()
+				err = netPlugin.WatchEndpointState(ctx, &config, filter, network.DefaultWatchInterval, os.Stdout)
+				return errors.Wrap(err, "Watch cni state error")
+			}
+
+			log.Printf("Retrieving state with filter %+v", filter)
 			var simpleState *api.AzureCNIState
-			simpleState, err = netPlugin.GetAllEndpointState("azure")
+			simpleState, err = netPlugin.GetEndpointState(filter)
 			if err != nil {
 				log.Errorf("Failed to get Azure CNI state, err:%v.\n", err)
 				return errors.Wrap(err, "Get all endpoints error")
@@ -278,6 +306,40 @@ func rootExecute() error {
 	return errors.Wrap(err, "Execute netplugin failure")
 }
 
+// rebuildState adds the endpoints found on the host for the network configuration read from stdin to the state, for
+// nodes whose state file was lost, and prints the IDs of the endpoints added.
+func rebuildState(netPlugin *network.NetPlugin) error {
+	stdinData, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return errors.Wrap(err, "failed to read network configuration from stdin")
+	}
+	nwCfg, err := cni.ParseNetworkConfig(stdinData)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse network configuration")
+	}
+
+	added, err := netPlugin.RebuildState(nwCfg)
+	if err != nil {
+		log.Errorf("Failed to rebuild state, err:%v.\n", err)
+		return err
+	}
+	log.Printf("Rebuilt %d endpoints of network %s", len(added), nwCfg.Name)
+	for _, id := range added {
+		fmt.Println(id)
+	}
+	return nil
+}
+
+// printStatus prints the status of the in-progress commands, without the lock of the state which they may hold.
+func printStatus() error {
+	now := time.Now().UTC()
+	statuses, err := network.ReadStatusFiles(log.GetLogDirectory(), now)
+	if err != nil {
+		return errors.Wrap(err, "failed to read status files")
+	}
+	return errors.Wrap(network.PrintStatus(os.Stdout, statuses, now), "failed to print status")
+}
+
 // Main is the entry point for CNI network plugin.
 func main() {
 	// Initialize and parse command line arguments.
@@ -296,7 +358,17 @@ func main() {
 		return
 	}
 
-	err := rootExecute()
+	if common.GetArg(optStatus).(bool) {
+		err := printStatus()
+		log.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err := executeWithRecovery(recordStdin())
 
 	log.Close()
 	if err != nil {
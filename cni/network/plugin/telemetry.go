@@ -0,0 +1,90 @@
+//go:build !minimal
+
+package main
+
+import (
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/cni/network"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/Azure/azure-container-networking/telemetry"
+	"github.com/pkg/errors"
+)
+
+const (
+	ipamQueryURL                    = "http://168.63.129.16/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
+	telemetryNumRetries             = 5
+	telemetryWaitTimeInMilliseconds = 200
+)
+
+func newMultitenancyClient() network.MultitenancyClient {
+	return &network.Multitenancy{}
+}
+
+// getReport fills in the system, OS and interface details of the report.
+func getReport(cniReport *telemetry.CNIReport) {
+	cniReport.GetReport(pluginName, version, ipamQueryURL)
+
+	upTime, err := platform.GetLastRebootTime()
+	if err == nil {
+		cniReport.VMUptime = upTime.Format("2006-01-02 15:04:05")
+	}
+}
+
+// newTelemetryBuffer creates a telemetry buffer identifying the plugin to the telemetry process.
+func newTelemetryBuffer() *telemetry.TelemetryBuffer {
+	tb := telemetry.NewTelemetryBuffer()
+	tb.ClientName = pluginName
+	tb.ClientVersion = version
+	return tb
+}
+
+// startTelemetry starts the telemetry process if not already started, and connects to it.
+func startTelemetry() *telemetry.TelemetryBuffer {
+	tb := newTelemetryBuffer()
+	tb.ConnectToTelemetryService(telemetryNumRetries, telemetryWaitTimeInMilliseconds)
+	return tb
+}
+
+// reportLockError reports a failure to initialize the store to the telemetry process, if it is running.
+func reportLockError(reportManager *telemetry.ReportManager, err error) {
+	tb := newTelemetryBuffer()
+	if tberr := tb.Connect(); tberr != nil {
+		log.Errorf("Cannot connect to telemetry service:%v", tberr)
+		return
+	}
+	defer tb.Close()
+
+	reportPluginError(reportManager, tb, err)
+
+	if errors.Is(err, store.ErrTimeoutLockingStore) {
+		var cniMetric telemetry.AIMetric
+		cniMetric.Metric = aitelemetry.Metric{
+			Name:             telemetry.CNILockTimeoutStr,
+			Value:            1.0,
+			CustomDimensions: make(map[string]string),
+		}
+		sendErr := telemetry.SendCNIMetric(&cniMetric, tb)
+		if sendErr != nil {
+			log.Errorf("Couldn't send cnilocktimeout metric: %v", sendErr)
+		}
+	}
+}
+
+// reportCrash sends an event of a panic of the plugin to the telemetry process, if it is running.
+func reportCrash(msg string) {
+	tb := newTelemetryBuffer()
+	if err := tb.Connect(); err != nil {
+		log.Errorf("Cannot connect to telemetry service:%v", err)
+		return
+	}
+	defer tb.Close()
+
+	telemetry.SendCNIEvent(tb, &telemetry.CNIReport{
+		Name:         pluginName,
+		Version:      version,
+		Context:      "AzureCNI",
+		EventMessage: msg,
+	})
+}
@@ -0,0 +1,29 @@
+//go:build minimal
+
+package main
+
+import (
+	"github.com/Azure/azure-container-networking/cni/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+)
+
+// The minimal build has no telemetry process and no multitenancy, for a smaller binary and faster invocations.
+
+func newMultitenancyClient() network.MultitenancyClient {
+	return network.DisabledMultitenancy{}
+}
+
+// getReport only fills in the name and version of the report, since the other details are only used by telemetry.
+func getReport(cniReport *telemetry.CNIReport) {
+	cniReport.Name = pluginName
+	cniReport.Version = version
+}
+
+// startTelemetry returns a telemetry buffer which isn't connected, so reports are dropped.
+func startTelemetry() *telemetry.TelemetryBuffer {
+	return telemetry.NewTelemetryBuffer()
+}
+
+func reportLockError(*telemetry.ReportManager, error) {}
+
+func reportCrash(string) {}
@@ -0,0 +1,44 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+var errRebuildMultitenancy = errors.New("the state of multitenant networks can't be rebuilt")
+
+// RebuildState adds the endpoints of the network of nwCfg found on the host to the state, for nodes whose state file
+// was lost, so that DEL can release their IPs and remove their host configuration. Returns the IDs of the endpoints
+// added. The store must be locked by the caller.
+func (plugin *NetPlugin) RebuildState(nwCfg *cni.NetworkConfig) ([]string, error) {
+	if nwCfg.MultiTenancy {
+		return nil, errRebuildMultitenancy
+	}
+
+	nwInfo := &network.NetworkInfo{
+		Id:               nwCfg.Name,
+		Mode:             nwCfg.Mode,
+		MasterIfName:     nwCfg.Master,
+		BridgeName:       nwCfg.Bridge,
+		EnableSnatOnHost: nwCfg.EnableSnatOnHost,
+	}
+	added, err := plugin.nm.RebuildState(nwInfo)
+	return added, errors.Wrapf(err, "failed to rebuild the state of network %s", nwCfg.Name)
+}
+
+// rebuiltEndpointID returns the ID of the endpoint in the netns and interface of the container which RebuildState
+// added without a container ID, as on Linux, where it can't be recovered from the host.
+func (plugin *NetPlugin) rebuiltEndpointID(networkID string, args *cniSkel.CmdArgs) (string, bool) {
+	eps, err := plugin.nm.GetAllEndpoints(networkID)
+	if err != nil {
+		return "", false
+	}
+	for id, ep := range eps {
+		if ep.ContainerID == "" && ep.NetNsPath != "" && ep.NetNsPath == args.Netns && ep.IfName == args.IfName {
+			return id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,33 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	acnnetwork "github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuiltEndpointID(t *testing.T) {
+	plugin := GetTestResources()
+	nm := plugin.nm.(*acnnetwork.MockNetworkManager)
+	nm.TestEndpointInfoMap["12345678-eth0"] = &acnnetwork.EndpointInfo{Id: "12345678-eth0", ContainerID: "12345678abcd", IfName: "eth0", NetNsPath: "/var/run/netns/ns1"}
+	nm.TestEndpointInfoMap["ns2-eth0"] = &acnnetwork.EndpointInfo{Id: "ns2-eth0", IfName: "eth0", NetNsPath: "/var/run/netns/ns2"}
+
+	id, ok := plugin.rebuiltEndpointID("azure", &cniSkel.CmdArgs{ContainerID: "abcdef123456", Netns: "/var/run/netns/ns2", IfName: "eth0"})
+	require.True(t, ok)
+	require.Equal(t, "ns2-eth0", id)
+
+	// endpoints added on ADD have a container ID
+	_, ok = plugin.rebuiltEndpointID("azure", &cniSkel.CmdArgs{ContainerID: "abcdef123456", Netns: "/var/run/netns/ns1", IfName: "eth0"})
+	require.False(t, ok)
+	_, ok = plugin.rebuiltEndpointID("azure", &cniSkel.CmdArgs{ContainerID: "abcdef123456", Netns: "/var/run/netns/ns2", IfName: "eth1"})
+	require.False(t, ok)
+}
+
+func TestRebuildStateMultitenancy(t *testing.T) {
+	plugin := GetTestResources()
+	_, err := plugin.RebuildState(&cni.NetworkConfig{Name: "azure", MultiTenancy: true})
+	require.ErrorIs(t, err, errRebuildMultitenancy)
+}
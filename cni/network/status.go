@@ -0,0 +1,200 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/log"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/pkg/errors"
+)
+
+const (
+	// statusFilePattern matches the status files of the in-progress commands in their directory.
+	statusFilePattern = "azure-vnet-status-*.json"
+	// maxStatusAge is how long a status file is shown, older files were left behind by killed commands and are removed.
+	maxStatusAge = time.Hour
+)
+
+// StatusFile writes the progress of the command to a file of its own while it's in progress, so that
+// `azure-vnet --status` shows what a stuck command is waiting on. It's enabled by the network configuration, the
+// phases tracked before, e.g. the wait for the lock of the state, are written once it is.
+type StatusFile struct {
+	sync.Mutex
+	path    string
+	enabled bool
+	status  api.InvocationStatus
+	// phases are the phases in progress with their start time, the current one last.
+	phases []phaseStart
+}
+
+type phaseStart struct {
+	phase string
+	start time.Time
+}
+
+// NewStatusFile returns the status file of the command of the process in the directory.
+func NewStatusFile(dir, command, correlationID string) *StatusFile {
+	pid := os.Getpid()
+	now := time.Now().UTC()
+	return &StatusFile{
+		path: filepath.Join(dir, fmt.Sprintf("azure-vnet-status-%d.json", pid)),
+		status: api.InvocationStatus{
+			PID:            pid,
+			Command:        command,
+			CorrelationID:  correlationID,
+			StartTime:      now,
+			PhaseStartTime: now,
+		},
+	}
+}
+
+// ObservePhase records that the phase started or ended, see telemetry.CNIReport.PhaseObserver.
+func (s *StatusFile) ObservePhase(phase string, started bool) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now().UTC()
+	if started {
+		s.phases = append(s.phases, phaseStart{phase: phase, start: now})
+	} else {
+		for i := len(s.phases) - 1; i >= 0; i-- {
+			if s.phases[i].phase != phase {
+				continue
+			}
+			if s.status.PhaseDurationsMs == nil {
+				s.status.PhaseDurationsMs = make(map[string]int64)
+			}
+			s.status.PhaseDurationsMs[phase] += now.Sub(s.phases[i].start).Milliseconds()
+			s.phases = append(s.phases[:i], s.phases[i+1:]...)
+			break
+		}
+	}
+
+	s.status.Phase, s.status.PhaseStartTime = "", now
+	if n := len(s.phases); n > 0 {
+		s.status.Phase, s.status.PhaseStartTime = s.phases[n-1].phase, s.phases[n-1].start
+	}
+	s.write()
+}
+
+// Enable starts writing the status file of the command of the container if the network configuration enables it.
+func (s *StatusFile) Enable(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) {
+	if s == nil || !nwCfg.EnableStatusFile {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	s.enabled = true
+	s.status.ContainerID = args.ContainerID
+	if podCfg, err := cni.ParseCniArgs(args.Args); err == nil {
+		s.status.PodName = string(podCfg.K8S_POD_NAME)
+		s.status.PodNamespace = string(podCfg.K8S_POD_NAMESPACE)
+	}
+	s.write()
+}
+
+// Close removes the status file once the command is done.
+func (s *StatusFile) Close() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.enabled {
+		return
+	}
+	s.enabled = false
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		log.Errorf("[cni-net] Failed to remove status file %s: %v", s.path, err)
+	}
+}
+
+// write replaces the status file, through a temporary file so that it's never read half written.
+// The lock must be held by the caller. Failing to write is only logged, the command goes on.
+func (s *StatusFile) write() {
+	if !s.enabled {
+		return
+	}
+	b, err := json.Marshal(&s.status)
+	if err != nil {
+		log.Errorf("[cni-net] Failed to marshal status: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		log.Errorf("[cni-net] Failed to write status file %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Errorf("[cni-net] Failed to replace status file %s: %v", s.path, err)
+	}
+}
+
+// ReadStatusFiles returns the status of the in-progress commands in the directory, the oldest first. The files
+// older than maxStatusAge are removed.
+func ReadStatusFiles(dir string, now time.Time) ([]api.InvocationStatus, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, statusFilePattern))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list status files")
+	}
+
+	statuses := make([]api.InvocationStatus, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			// the command finished since the files were listed
+			continue
+		}
+		var status api.InvocationStatus
+		if err := json.Unmarshal(b, &status); err != nil {
+			log.Errorf("[cni-net] Failed to parse status file %s: %v", path, err)
+			continue
+		}
+		if now.Sub(status.StartTime) > maxStatusAge {
+			if err := os.Remove(path); err != nil {
+				log.Errorf("[cni-net] Failed to remove stale status file %s: %v", path, err)
+			}
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartTime.Before(statuses[j].StartTime)
+	})
+	return statuses, nil
+}
+
+// PrintStatus prints a table of the status of the in-progress commands.
+func PrintStatus(w io.Writer, statuses []api.InvocationStatus, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:gomnd // padding of the columns
+	fmt.Fprintln(tw, "PID\tCOMMAND\tCONTAINER\tPOD\tPHASE\tIN PHASE\tELAPSED")
+	for i := range statuses {
+		status := &statuses[i]
+		pod, phase := "-", "-"
+		if status.PodName != "" {
+			pod = status.PodNamespace + "/" + status.PodName
+		}
+		if status.Phase != "" {
+			phase = status.Phase
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", status.PID, status.Command, status.ContainerID, pod, phase,
+			now.Sub(status.PhaseStartTime).Round(time.Millisecond), now.Sub(status.StartTime).Round(time.Millisecond))
+	}
+	return errors.Wrap(tw.Flush(), "failed to print status")
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/api"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusFile(t *testing.T) {
+	dir := t.TempDir()
+	status := NewStatusFile(dir, "ADD", "correlation")
+	report := &telemetry.CNIReport{PhaseObserver: status.ObservePhase}
+
+	// the phases are tracked before the status file is enabled, but nothing is written
+	report.TrackPhase(telemetry.PhaseLockWait)()
+	stopIPAM := report.TrackPhase(telemetry.PhaseIPAM)
+	statuses, err := ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+
+	status.Enable(&cni.NetworkConfig{}, &cniSkel.CmdArgs{ContainerID: "container"})
+	statuses, err = ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+
+	args := &cniSkel.CmdArgs{ContainerID: "container", Args: "K8S_POD_NAME=pod;K8S_POD_NAMESPACE=ns"}
+	status.Enable(&cni.NetworkConfig{EnableStatusFile: true}, args)
+	statuses, err = ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, os.Getpid(), statuses[0].PID)
+	require.Equal(t, "ADD", statuses[0].Command)
+	require.Equal(t, "container", statuses[0].ContainerID)
+	require.Equal(t, "pod", statuses[0].PodName)
+	require.Equal(t, "correlation", statuses[0].CorrelationID)
+	require.Equal(t, telemetry.PhaseIPAM, statuses[0].Phase)
+	require.Contains(t, statuses[0].PhaseDurationsMs, telemetry.PhaseLockWait)
+
+	// the current phase is the innermost one in progress
+	stopDataplane := report.TrackPhase(telemetry.PhaseDataplane)
+	stopIPAM()
+	statuses, err = ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, telemetry.PhaseDataplane, statuses[0].Phase)
+	stopDataplane()
+	statuses, err = ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, statuses[0].Phase)
+
+	var out bytes.Buffer
+	require.NoError(t, PrintStatus(&out, statuses, time.Now()))
+	require.Contains(t, out.String(), "ns/pod")
+
+	// the file is removed once the command is done
+	status.Close()
+	statuses, err = ReadStatusFiles(dir, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, statuses)
+}
+
+func TestReadStatusFilesRemovesStale(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, start := range []time.Time{now.Add(-2 * maxStatusAge), now.Add(-time.Minute), now.Add(-2 * time.Minute)} {
+		b, err := json.Marshal(api.InvocationStatus{PID: i, StartTime: start})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "azure-vnet-status-"+string(rune('a'+i))+".json"), b, 0o600))
+	}
+
+	statuses, err := ReadStatusFiles(dir, now)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	// the oldest command is first
+	require.Equal(t, 2, statuses[0].PID)
+	require.Equal(t, 1, statuses[1].PID)
+	_, err = os.Stat(filepath.Join(dir, "azure-vnet-status-a.json"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,22 @@
+package network
+
+import (
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+)
+
+// vnetBlockInfo returns the host configuration of the endpoints in the vnet-block mode, nil in the other modes.
+func vnetBlockInfo(nwCfg *cni.NetworkConfig) *network.VnetBlockInfo {
+	if nwCfg.Mode != OpModeVnetBlock {
+		return nil
+	}
+	cfg := nwCfg.VnetBlock
+	if cfg == nil {
+		return &network.VnetBlockInfo{}
+	}
+	return &network.VnetBlockInfo{
+		DisableProxyARP: cfg.DisableProxyARP,
+		ProxyNDP:        cfg.ProxyNDP,
+		DisableRPFilter: cfg.DisableRPFilter,
+	}
+}
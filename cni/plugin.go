@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"runtime/debug"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
@@ -28,6 +29,9 @@ var errEmptyContent = errors.New("read content is zero bytes")
 type Plugin struct {
 	*common.Plugin
 	version string
+	// OnPanic, if set, handles a panic of a command instead of Execute printing it as a CNI error,
+	// and returns the error of the command.
+	OnPanic func(recovered interface{}, stack []byte) error
 }
 
 // NewPlugin creates a new CNI plugin.
@@ -62,6 +66,11 @@ func (plugin *Plugin) Execute(api PluginApi) (err error) {
 	// Recover from panics and convert them to CNI errors.
 	defer func() {
 		if r := recover(); r != nil {
+			if plugin.OnPanic != nil {
+				err = plugin.OnPanic(r, debug.Stack())
+				return
+			}
+
 			buf := make([]byte, 1<<12)
 			len := runtime.Stack(buf, false)
 
@@ -81,7 +90,13 @@ func (plugin *Plugin) Execute(api PluginApi) (err error) {
 	pluginInfo := cniVers.PluginSupports(supportedVersions...)
 
 	// Parse args and call the appropriate cmd handler.
-	cniErr := cniSkel.PluginMainWithError(api.Add, api.Get, api.Delete, pluginInfo, plugin.version)
+	cniErr := cniSkel.PluginMainFuncsWithError(cniSkel.CNIFuncs{
+		Add:    api.Add,
+		Check:  api.Get,
+		Del:    api.Delete,
+		GC:     api.GC,
+		Status: api.Status,
+	}, pluginInfo, plugin.version)
 	if cniErr != nil {
 		cniErr.Print()
 		return cniErr
@@ -130,6 +145,40 @@ func (plugin *Plugin) DelegateDel(pluginName string, nwCfg *NetworkConfig) error
 	return nil
 }
 
+// DelegateGC calls the given plugin's GC command.
+func (plugin *Plugin) DelegateGC(pluginName string, nwCfg *NetworkConfig) error {
+	var err error
+
+	log.Printf("[cni] Calling plugin %v GC.", pluginName)
+	defer func() { log.Printf("[cni] Plugin %v returned err:%v.", pluginName, err) }()
+
+	os.Setenv(Cmd, CmdGC)
+
+	err = cniInvoke.DelegateGC(context.TODO(), pluginName, nwCfg.Serialize(), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to delegate: %v", err)
+	}
+
+	return nil
+}
+
+// DelegateStatus calls the given plugin's STATUS command.
+func (plugin *Plugin) DelegateStatus(pluginName string, nwCfg *NetworkConfig) error {
+	var err error
+
+	log.Printf("[cni] Calling plugin %v STATUS.", pluginName)
+	defer func() { log.Printf("[cni] Plugin %v returned err:%v.", pluginName, err) }()
+
+	os.Setenv(Cmd, CmdStatus)
+
+	err = cniInvoke.DelegateStatus(context.TODO(), pluginName, nwCfg.Serialize(), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to delegate: %v", err)
+	}
+
+	return nil
+}
+
 // Error creates and logs a structured CNI error.
 func (plugin *Plugin) Error(err error) *cniTypes.Error {
 	var cniErr *cniTypes.Error
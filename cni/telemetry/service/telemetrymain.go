@@ -4,14 +4,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/telemetry"
 )
 
@@ -22,9 +26,15 @@ const (
 	defaultBatchIntervalInSecs        = 15
 	defaultGetEnvRetryCount           = 2
 	defaultGetEnvRetryWaitTimeInSecs  = 3
+	defaultDiskUsageReportIntervalSec = 300
+	defaultStatsAddress               = "127.0.0.1:10095"
+	statsReadHeaderTimeout            = 5 * time.Second
 	pluginName                        = "AzureCNI"
 	azureVnetTelemetry                = "azure-vnet-telemetry"
 	configExtension                   = ".config"
+	cniIpamStateFile                  = "azure-vnet-ipam.json"
+	bytesInMB                         = 1024 * 1024
+	optDumpRecent                     = "dump-recent"
 )
 
 var version string
@@ -76,6 +86,12 @@ var args = acn.ArgumentList{
 		Type:         "string",
 		DefaultValue: telemetry.CniInstallDir,
 	},
+	{
+		Name:         optDumpRecent,
+		Description:  "Print the last reports received by the running telemetry service",
+		Type:         "bool",
+		DefaultValue: false,
+	},
 }
 
 // Prints description and version information.
@@ -108,6 +124,52 @@ func setTelemetryDefaults(config *telemetry.TelemetryConfig) {
 	if config.GetEnvRetryWaitTimeInSecs == 0 {
 		config.GetEnvRetryWaitTimeInSecs = defaultGetEnvRetryWaitTimeInSecs
 	}
+
+	if config.DiskUsageReportIntervalInSecs == 0 {
+		config.DiskUsageReportIntervalInSecs = defaultDiskUsageReportIntervalSec
+	}
+
+	if config.StatsAddress == "" {
+		config.StatsAddress = defaultStatsAddress
+	}
+
+	switch config.Exporter {
+	case telemetry.ExporterAppInsights, telemetry.ExporterOTLP, telemetry.ExporterBoth:
+	default:
+		if config.Exporter != "" {
+			log.Logf("[Telemetry] Unknown exporter %q, sending to %s", config.Exporter, telemetry.ExporterAppInsights)
+		}
+		config.Exporter = telemetry.ExporterAppInsights
+	}
+}
+
+// dumpRecent prints the last reports received by the running telemetry service as JSON.
+func dumpRecent() error {
+	tb := telemetry.NewTelemetryBuffer()
+	defer tb.Close()
+
+	reports, err := tb.DumpRecent()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// serveStats serves the per-client stats of the telemetry service on the local address until it fails.
+func serveStats(tb *telemetry.TelemetryBuffer, address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/stats", tb.StatsHandler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: statsReadHeaderTimeout,
+	}
+	log.Logf("[Telemetry] Serving client stats on http://%s/stats", address)
+	if err := server.ListenAndServe(); err != nil {
+		log.Logf("[Telemetry] Client stats endpoint failed: %v", err)
+	}
 }
 
 func main() {
@@ -128,6 +190,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	if acn.GetArg(optDumpRecent).(bool) {
+		if err = dumpRecent(); err != nil {
+			fmt.Printf("Failed to dump the recent reports: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	log.SetName(azureVnetTelemetry)
 	log.SetLevel(logLevel)
 	err = log.SetTargetLogDirectory(logTarget, logDirectory)
@@ -156,6 +226,13 @@ func main() {
 	setTelemetryDefaults(&config)
 
 	log.Logf("Config after setting defaults %+v", config)
+	log.SetRotation(log.RotationConfig{
+		MaxFileSize:  config.LogMaxFileSizeInMB * bytesInMB,
+		MaxFileCount: config.LogMaxFileCount,
+		MaxAge:       time.Duration(config.LogMaxAgeInHours) * time.Hour,
+		Compress:     config.LogCompress,
+		MaxTotalSize: int64(config.LogMaxTotalSizeInMB) * bytesInMB,
+	})
 
 	// Cleaning up orphan socket if present
 	tbtemp := telemetry.NewTelemetryBuffer()
@@ -163,6 +240,8 @@ func main() {
 
 	for {
 		tb = telemetry.NewTelemetryBuffer()
+		tb.PipeSecurityDescriptor = config.PipeSecurityDescriptor
+		tb.RecentReportsCount = config.RecentReportsCount
 
 		log.Logf("[Telemetry] Starting telemetry server")
 		err = tb.StartServer()
@@ -187,11 +266,43 @@ func main() {
 		GetEnvRetryWaitTimeInSecs:    config.GetEnvRetryWaitTimeInSecs,
 	}
 
-	err = telemetry.CreateAITelemetryHandle(aiConfig, config.DisableAll, config.DisableTrace, config.DisableMetric)
-	log.Printf("[Telemetry] AI Handle creation status:%v", err)
+	if telemetry.ExportsToAppInsights(config.Exporter) {
+		err = telemetry.CreateAITelemetryHandle(aiConfig, config.DisableAll, config.DisableMetric, config.DisableTrace)
+		log.Printf("[Telemetry] AI Handle creation status:%v", err)
+	}
+
+	if telemetry.ExportsToOTLP(config.Exporter) {
+		otlpConfig := telemetry.OTLPConfig{
+			Endpoint:       config.OTLPEndpoint,
+			Headers:        config.OTLPHeaders,
+			ServiceName:    pluginName,
+			ServiceVersion: version,
+			BatchInterval:  time.Duration(config.BatchIntervalInSecs) * time.Second,
+		}
+		err = telemetry.CreateOTLPExporter(otlpConfig, config.DisableAll, config.DisableMetric, config.DisableTrace)
+		log.Printf("[Telemetry] OTLP exporter to %s creation status:%v", config.OTLPEndpoint, err)
+	}
 	log.Logf("[Telemetry] Report to host for an interval of %d seconds", config.ReportToHostIntervalInSeconds)
-	tb.PushData(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if !config.DisableDiskUsageReport {
+		paths := telemetry.DiskUsagePaths{
+			LogDirectory:  log.GetLogDirectory(),
+			StateFiles:    []string{platform.CNIStateFilePath, filepath.Join(filepath.Dir(platform.CNIStateFilePath), cniIpamStateFile)},
+			LockDirectory: platform.CNILockPath,
+		}
+		log.Logf("[Telemetry] Report disk usage of %+v every %d seconds", paths, config.DiskUsageReportIntervalInSecs)
+		go telemetry.ReportDiskUsage(ctx, paths, time.Duration(config.DiskUsageReportIntervalInSecs)*time.Second, version)
+	}
+
+	if !config.DisableStatsEndpoint {
+		go serveStats(tb, config.StatsAddress)
+	}
+
+	tb.PushData(ctx)
+	cancel()
 	telemetry.CloseAITelemetryHandle()
+	telemetry.CloseOTLPExporter()
 
 	log.Close()
 }
@@ -7,17 +7,25 @@ import (
 	"github.com/Azure/azure-container-networking/log"
 )
 
-// deleteRulesNotExistInMap deletes rules from nat Ebtable if rule was not in stateRules after a certain number of iterations.
-func (networkMonitor *NetworkMonitor) deleteRulesNotExistInMap(chainRules map[string]string, stateRules map[string]string) {
-	table := ebtables.Nat
-	action := ebtables.Delete
+// deleteRulesNotExistInMap deletes the endpoint chains whose jump rule was not in stateRules after a certain number
+// of iterations. Only the rules owned by endpoints, which are in endpoint chains, are deleted, so the rules created
+// by other agents are never removed.
+func (networkMonitor *NetworkMonitor) deleteRulesNotExistInMap(
+	chainRules map[string]string,
+	stateRules map[string]string,
+	deleteEndpointChain func(chain string) error) {
 
 	for rule, chain := range chainRules {
+		endpointChain, owned := ebtables.EndpointChainOfJump(rule)
+		if !owned {
+			continue
+		}
+
 		if _, ok := stateRules[rule]; !ok {
 			if itr, ok := networkMonitor.DeleteRulesToBeValidated[rule]; ok && itr > 0 {
-				buf := fmt.Sprintf("[monitor] Deleting Ebtable rule as it didn't exist in state for %d iterations chain %v rule %v", itr, chain, rule)
-				if err := ebtables.SetEbRule(table, action, chain, rule); err != nil {
-					buf = fmt.Sprintf("[monitor] Error while deleting ebtable rule %v", err)
+				buf := fmt.Sprintf("[monitor] Deleting endpoint chain %v as it didn't exist in state for %d iterations chain %v rule %v", endpointChain, itr, chain, rule)
+				if err := deleteEndpointChain(endpointChain); err != nil {
+					buf = fmt.Sprintf("[monitor] Error while deleting endpoint chain %v", err)
 				}
 
 				log.Printf(buf)
@@ -76,10 +84,12 @@ func (networkMonitor *NetworkMonitor) CreateRequiredL2Rules(
 	return nil
 }
 
-// RemoveInvalidL2Rules removes rules that should not be in nat ebtable based on state.
+// RemoveInvalidL2Rules removes the endpoint chains that should not be in nat ebtable based on state.
+// deleteEndpointChain deletes a chain with the other entries owned by its endpoint.
 func (networkMonitor *NetworkMonitor) RemoveInvalidL2Rules(
 	currentEbtableRulesMap map[string]string,
-	currentStateRulesMap map[string]string) error {
+	currentStateRulesMap map[string]string,
+	deleteEndpointChain func(chain string) error) error {
 
 	for rule := range networkMonitor.DeleteRulesToBeValidated {
 		if _, ok := currentEbtableRulesMap[rule]; !ok {
@@ -87,7 +97,7 @@ func (networkMonitor *NetworkMonitor) RemoveInvalidL2Rules(
 		}
 	}
 
-	networkMonitor.deleteRulesNotExistInMap(currentEbtableRulesMap, currentStateRulesMap)
+	networkMonitor.deleteRulesNotExistInMap(currentEbtableRulesMap, currentStateRulesMap, deleteEndpointChain)
 
 	return nil
 }
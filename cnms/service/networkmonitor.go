@@ -24,6 +24,7 @@ const (
 	// Service name.
 	name                            = "azure-cnimonitor"
 	pluginName                      = "azure-vnet"
+	telemetryClientName             = "azure-cnms"
 	DEFAULT_TIMEOUT_IN_SECS         = "10"
 	telemetryNumRetries             = 5
 	telemetryWaitTimeInMilliseconds = 200
@@ -139,6 +140,8 @@ func main() {
 	}
 
 	tb := telemetry.NewTelemetryBuffer()
+	tb.ClientName = telemetryClientName
+	tb.ClientVersion = version
 	tb.ConnectToTelemetryService(telemetryNumRetries, telemetryWaitTimeInMilliseconds)
 	defer tb.Close()
 
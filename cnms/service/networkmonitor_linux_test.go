@@ -17,6 +17,7 @@ const (
 var stateMapkey []string
 
 func TestMain(m *testing.M) {
+	stateMapkey = append(stateMapkey, "-j "+ebtables.EndpointChain("endpoint1"))
 	stateMapkey = append(stateMapkey, "-p ARP -i eth0 --arp-op Reply -j dnat --to-dst ff:ff:ff:ff:ff:ff --dnat-target ACCEPT")
 	stateMapkey = append(stateMapkey, "-p ARP --arp-op Request --arp-ip-dst 10.240.0.6 -j arpreply --arpreply-mac cc:ad:1d:4e:e5:f1")
 	stateMapkey = append(stateMapkey, "-p IPv4 -i eth0 --ip-dst 10.240.0.6 -j dnat --to-dst cc:ad:1d:4e:e5:f1 --dnat-target ACCEPT")
@@ -59,7 +60,7 @@ func TestAddMissingRule(t *testing.T) {
 		t.Fatalf("Expected AddRulesToBeValidated length to be 1 but got %v", len(netMonitor.AddRulesToBeValidated))
 	}
 
-	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap)
+	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap, deleteChainNotExpected(t))
 	if len(netMonitor.DeleteRulesToBeValidated) != 0 {
 		t.Fatalf("Expected DeleteRulesToBeValidated length to be 0 but got %v", len(netMonitor.DeleteRulesToBeValidated))
 	}
@@ -76,6 +77,13 @@ func TestAddMissingRule(t *testing.T) {
 	}
 }
 
+func deleteChainNotExpected(t *testing.T) func(string) error {
+	return func(chain string) error {
+		t.Fatalf("Expected no endpoint chain to be deleted but got %v", chain)
+		return nil
+	}
+}
+
 func TestDeleteInvalidRule(t *testing.T) {
 	netMonitor := &cnms.NetworkMonitor{
 		AddRulesToBeValidated:    make(map[string]int),
@@ -97,19 +105,49 @@ func TestDeleteInvalidRule(t *testing.T) {
 		t.Fatalf("Expected AddRulesToBeValidated length to be 0 but got %v", len(netMonitor.AddRulesToBeValidated))
 	}
 
-	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap)
+	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap, deleteChainNotExpected(t))
 	if len(netMonitor.DeleteRulesToBeValidated) != 1 {
 		t.Fatalf("Expected DeleteRulesToBeValidated length to be 1 but got %v", len(netMonitor.DeleteRulesToBeValidated))
 	}
 
-	for key, value := range netMonitor.AddRulesToBeValidated {
+	for key, value := range netMonitor.DeleteRulesToBeValidated {
 		if key != stateMapkey[0] {
 			t.Fatalf("Expected %v but got %v", stateMapkey[0], value)
 		}
 	}
 
-	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap)
+	var deleted []string
+	netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap, func(chain string) error {
+		deleted = append(deleted, chain)
+		return nil
+	})
 	if len(netMonitor.DeleteRulesToBeValidated) != 0 {
 		t.Fatalf("Expected DeleteRulesToBeValidated length to be 0 but got %v", len(netMonitor.DeleteRulesToBeValidated))
 	}
+	if len(deleted) != 1 || deleted[0] != ebtables.EndpointChain("endpoint1") {
+		t.Fatalf("Expected endpoint chain of endpoint1 to be deleted but got %v", deleted)
+	}
+}
+
+func TestKeepRuleOfOtherAgent(t *testing.T) {
+	netMonitor := &cnms.NetworkMonitor{
+		AddRulesToBeValidated:    make(map[string]int),
+		DeleteRulesToBeValidated: make(map[string]int),
+		CNIReport:                &telemetry.CNIReport{},
+	}
+
+	currentStateRulesMap := addStateRulesToMap()
+	currentEbTableRulesMap := make(map[string]string)
+
+	for key, value := range currentStateRulesMap {
+		currentEbTableRulesMap[key] = value
+	}
+	currentEbTableRulesMap["-p IPv4 -i eth0 --ip-dst 10.240.0.7 -j dnat --to-dst cc:ad:1d:4e:e5:f2 --dnat-target ACCEPT"] = ebtables.PreRouting
+
+	for i := 0; i < 3; i++ {
+		netMonitor.RemoveInvalidL2Rules(currentEbTableRulesMap, currentStateRulesMap, deleteChainNotExpected(t))
+		if len(netMonitor.DeleteRulesToBeValidated) != 0 {
+			t.Fatalf("Expected DeleteRulesToBeValidated length to be 0 but got %v", len(netMonitor.DeleteRulesToBeValidated))
+		}
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/pkg/errors"
@@ -17,6 +18,8 @@ const (
 	SetOrchestratorType                      = "/network/setorchestratortype"
 	GetHomeAz                                = "/homeaz"
 	CreateOrUpdateNetworkContainer           = "/network/createorupdatenetworkcontainer"
+	PatchNetworkContainer                    = "/network/patchnetworkcontainer"
+	GetOperationStatus                       = "/network/getoperationstatus"
 	DeleteNetworkContainer                   = "/network/deletenetworkcontainer"
 	PublishNetworkContainer                  = "/network/publishnetworkcontainer"
 	UnpublishNetworkContainer                = "/network/unpublishnetworkcontainer"
@@ -30,9 +33,14 @@ const (
 	RequestIPConfigs                         = "/network/requestipconfigs"
 	ReleaseIPConfig                          = "/network/releaseipconfig"
 	ReleaseIPConfigs                         = "/network/releaseipconfigs"
+	ReserveIPConfig                          = "/network/reserveipconfig"
+	UnreserveIPConfig                        = "/network/unreserveipconfig"
 	PathDebugIPAddresses                     = "/debug/ipaddresses"
 	PathDebugPodContext                      = "/debug/podcontext"
 	PathDebugRestData                        = "/debug/restdata"
+	PathDebugSnapshot                        = "/debug/snapshot"
+	GetPodContextByIP                        = "/network/podcontextbyip"
+	GetPodContextsByIP                       = "/network/podcontextsbyip"
 	NumberOfCPUCores                         = NumberOfCPUCoresPath
 	NMAgentSupportedAPIs                     = NmAgentSupportedApisPath
 )
@@ -90,6 +98,7 @@ type CreateNetworkContainerRequest struct {
 	IPConfiguration            IPConfiguration
 	SecondaryIPConfigs         map[string]SecondaryIPConfig // uuid is key
 	MultiTenancyInfo           MultiTenancyInfo
+	TenantID                   string     // Tenant of a multitenant NC.
 	CnetAddressSpace           []IPSubnet // To setup SNAT (should include service endpoint vips).
 	Routes                     []Route
 	AllowHostToNCCommunication bool
@@ -101,12 +110,89 @@ type CreateNetworkContainerRequest struct {
 func (req *CreateNetworkContainerRequest) String() string {
 	return fmt.Sprintf("CreateNetworkContainerRequest"+
 		"{Version: %s, NetworkContainerType: %s, NetworkContainerid: %s, PrimaryInterfaceIdentifier: %s, "+
-		"LocalIPConfiguration: %+v, IPConfiguration: %+v, SecondaryIPConfigs: %+v, MultitenancyInfo: %+v, "+
+		"LocalIPConfiguration: %+v, IPConfiguration: %+v, SecondaryIPConfigs: %+v, MultitenancyInfo: %+v, TenantID: %s, "+
 		"AllowHostToNCCommunication: %t, AllowNCToHostCommunication: %t}",
 		req.Version, req.NetworkContainerType, req.NetworkContainerid, req.PrimaryInterfaceIdentifier, req.LocalIPConfiguration,
-		req.IPConfiguration, req.SecondaryIPConfigs, req.MultiTenancyInfo, req.AllowHostToNCCommunication, req.AllowNCToHostCommunication)
+		req.IPConfiguration, req.SecondaryIPConfigs, req.MultiTenancyInfo, req.TenantID, req.AllowHostToNCCommunication,
+		req.AllowNCToHostCommunication)
 }
 
+// ErrNCVersionMismatch is returned when patching an NC whose version isn't the base version of the patch.
+var ErrNCVersionMismatch = errors.New("NC version is not the base version of the patch")
+
+// PatchNetworkContainerRequest conveys the changes to an NC since its BaseVersion, instead of the whole NC, so that
+// updating a large NC doesn't resend and reprogram all of its secondary IPs.
+type PatchNetworkContainerRequest struct {
+	NetworkContainerid        string                       // Mandatory input.
+	BaseVersion               string                       // Version of the NC the patch applies to.
+	Version                   string                       // Version of the NC once patched.
+	AddedSecondaryIPConfigs   map[string]SecondaryIPConfig // uuid is key
+	RemovedSecondaryIPConfigs []string                     // uuids
+}
+
+// PatchNetworkContainerResponse specifies response of patching a network container.
+type PatchNetworkContainerResponse struct {
+	Response Response
+}
+
+// OperationState is the state of an asynchronous operation.
+type OperationState string
+
+const (
+	OperationPending   OperationState = "Pending"
+	OperationSucceeded OperationState = "Succeeded"
+	OperationFailed    OperationState = "Failed"
+)
+
+// OperationStatus is the status of an asynchronous operation on a network container.
+type OperationStatus struct {
+	OperationID        string
+	NetworkContainerID string
+	State              OperationState
+	// ResponseCode is the result of the operation once it is no longer pending.
+	ResponseCode types.ResponseCode
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+// GetOperationStatusRequest specifies the asynchronous operation to get the status of.
+type GetOperationStatusRequest struct {
+	OperationID string
+}
+
+// GetOperationStatusResponse specifies the status of an asynchronous operation.
+type GetOperationStatusResponse struct {
+	Response  Response
+	Operation OperationStatus
+}
+
+// Patch returns a copy of the NC with the patch applied. Removing a secondary IP the NC doesn't have is a no-op, so
+// that a patch can be resent.
+func (req *CreateNetworkContainerRequest) Patch(patch *PatchNetworkContainerRequest) (CreateNetworkContainerRequest, error) {
+	if req.Version != patch.BaseVersion {
+		return CreateNetworkContainerRequest{}, errors.Wrapf(ErrNCVersionMismatch, "NC %s has version %s, patch base version %s",
+			req.NetworkContainerid, req.Version, patch.BaseVersion)
+	}
+	patched := *req
+	patched.Version = patch.Version
+	patched.SecondaryIPConfigs = make(map[string]SecondaryIPConfig, len(req.SecondaryIPConfigs)+len(patch.AddedSecondaryIPConfigs))
+	for id, ipConfig := range req.SecondaryIPConfigs {
+		patched.SecondaryIPConfigs[id] = ipConfig
+	}
+	for _, id := range patch.RemovedSecondaryIPConfigs {
+		delete(patched.SecondaryIPConfigs, id)
+	}
+	for id, ipConfig := range patch.AddedSecondaryIPConfigs {
+		patched.SecondaryIPConfigs[id] = ipConfig
+	}
+	return patched, nil
+}
+
+// PodEndpointType is the EndpointType of NetworkContainerRequestPolicies which CNS doesn't interpret: they're returned
+// with the IP configs of the NC, and the CNI applies them verbatim to the endpoint of the Pod. On Windows, the Type and
+// Settings are those of an HNS endpoint policy. This lets DNC roll out new datapath policies without CNI changes.
+const PodEndpointType = "Pod"
+
 // NetworkContainerRequestPolicies - specifies policies associated with create network request
 type NetworkContainerRequestPolicies struct {
 	Type         string
@@ -114,6 +200,17 @@ type NetworkContainerRequestPolicies struct {
 	Settings     json.RawMessage
 }
 
+// PodEndpointPolicies returns the policies of the NC which the CNI applies to the endpoints of Pods.
+func (req *CreateNetworkContainerRequest) PodEndpointPolicies() []NetworkContainerRequestPolicies {
+	var policies []NetworkContainerRequestPolicies
+	for _, policy := range req.EndpointPolicies {
+		if strings.EqualFold(policy.EndpointType, PodEndpointType) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
 // ConfigureContainerNetworkingRequest - specifies request to attach/detach container to network.
 type ConfigureContainerNetworkingRequest struct {
 	Containerid        string
@@ -391,12 +488,16 @@ type GetNetworkContainerResponse struct {
 	Response                   Response
 	AllowHostToNCCommunication bool
 	AllowNCToHostCommunication bool
+	// EndpointPolicies are the policies of the NC which the CNI applies verbatim to the endpoint of the Pod.
+	EndpointPolicies []NetworkContainerRequestPolicies `json:",omitempty"`
 }
 
 type PodIpInfo struct {
 	PodIPConfig                     IPSubnet
 	NetworkContainerPrimaryIPConfig IPConfiguration
 	HostPrimaryIPInfo               HostIPInfo
+	// EndpointPolicies are the policies of the NC which the CNI applies verbatim to the endpoint of the Pod.
+	EndpointPolicies []NetworkContainerRequestPolicies `json:",omitempty"`
 }
 
 type HostIPInfo struct {
@@ -420,18 +521,82 @@ type IPConfigsRequest struct {
 	InfraContainerID    string          `json:"infraContainerID"`
 	OrchestratorContext json.RawMessage `json:"orchestratorContext"`
 	Ifname              string          `json:"ifname"` // Used by delegated IPAM
+	// IPFamilies restricts the IPs assigned to the Pod to the NCs of these IP families, all NCs if empty.
+	IPFamilies []IPFamily `json:"ipFamilies,omitempty"`
+}
+
+// IPFamily is the IP family of an IP address or an IP pool.
+type IPFamily string
+
+const (
+	IPv4Family IPFamily = "ipv4"
+	IPv6Family IPFamily = "ipv6"
+)
+
+// IPFamilyOf returns the IP family of the IP address or CIDR, or "" if it isn't one.
+func IPFamilyOf(address string) IPFamily {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(address); err != nil {
+			return ""
+		}
+	}
+	if ip.To4() != nil {
+		return IPv4Family
+	}
+	return IPv6Family
 }
 
 // IPConfigResponse is used in CNS IPAM mode as a response to CNI ADD
 type IPConfigResponse struct {
 	PodIpInfo PodIpInfo
 	Response  Response
+	// HomeAz is the home availability zone of the node, omitted if it isn't known.
+	HomeAz uint `json:",omitempty"`
 }
 
 // IPConfigsResponse is used in CNS IPAM mode to return a slice of IP configs as a response to CNI ADD
 type IPConfigsResponse struct {
 	PodIPInfo []PodIpInfo `json:"podIPInfo"`
 	Response  Response    `json:"response"`
+	// HomeAz is the home availability zone of the node, omitted if it isn't known.
+	HomeAz uint `json:"homeAz,omitempty"`
+}
+
+// DefaultIPReservationTTL is how long an IP reservation is kept unused if the request doesn't set a TTL.
+const DefaultIPReservationTTL = 5 * time.Minute
+
+// ReserveIPConfigRequest reserves IPs for a Pod before its sandbox is created, so that CNS assigns them to the Pod on
+// its next IP request. Reserving IPs for a Pod which already has a reservation replaces it.
+type ReserveIPConfigRequest struct {
+	// PodName and PodNamespace identify the Pod, whose infra container doesn't exist yet.
+	PodName      string `json:"podName"`
+	PodNamespace string `json:"podNamespace"`
+	// DesiredIPAddresses are the IPs to reserve. If empty, an available IP of each NC is reserved.
+	DesiredIPAddresses []string `json:"desiredIPAddresses,omitempty"`
+	// TTLInSeconds is how long the reservation is kept if the Pod doesn't request IPs, DefaultIPReservationTTL if zero.
+	TTLInSeconds int `json:"ttlInSeconds,omitempty"`
+}
+
+// IPReservation is the reservation of IPs for a Pod.
+type IPReservation struct {
+	PodName      string    `json:"podName"`
+	PodNamespace string    `json:"podNamespace"`
+	IPAddresses  []string  `json:"ipAddresses"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// ReserveIPConfigResponse is the response to a ReserveIPConfigRequest.
+type ReserveIPConfigResponse struct {
+	Reservation IPReservation `json:"reservation"`
+	Response    Response      `json:"response"`
+}
+
+// UnreserveIPConfigRequest releases the IPs reserved for a Pod which weren't assigned to it.
+type UnreserveIPConfigRequest struct {
+	PodName      string `json:"podName"`
+	PodNamespace string `json:"podNamespace"`
 }
 
 // GetIPAddressesRequest is used in CNS IPAM mode to get the states of IPConfigs
@@ -458,6 +623,40 @@ type GetPodContextResponse struct {
 	Response   Response
 }
 
+// GetPodContextByIPRequest is used to look up the pod an IP is assigned to.
+type GetPodContextByIPRequest struct {
+	IP string
+}
+
+// GetPodContextsByIPRequest is used to look up the pods a batch of IPs are assigned to.
+type GetPodContextsByIPRequest struct {
+	IPs []string
+}
+
+// PodIPContext describes the pod and network container an IP is assigned to.
+type PodIPContext struct {
+	IP                 string
+	PodName            string
+	PodNamespace       string
+	InfraContainerID   string
+	InterfaceID        string
+	NetworkContainerID string
+}
+
+// GetPodContextByIPResponse is the response to a GetPodContextByIPRequest.
+// The ReturnCode is NotFound if the IP is not assigned to a pod.
+type GetPodContextByIPResponse struct {
+	PodIPContext PodIPContext
+	Response     Response
+}
+
+// GetPodContextsByIPResponse is the response to a GetPodContextsByIPRequest.
+// IPs which are not assigned to a pod are omitted from the PodIPContexts.
+type GetPodContextsByIPResponse struct {
+	PodIPContexts []PodIPContext
+	Response      Response
+}
+
 // IPAddressState Only used in the GetIPConfig API to return IPs that match a filter
 type IPAddressState struct {
 	IPAddress string
@@ -105,3 +105,43 @@ func TestNewPodInfoFromIPConfigsRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestPodEndpointPolicies(t *testing.T) {
+	apipa := NetworkContainerRequestPolicies{Type: "ACL", EndpointType: "APIPA"}
+	pod := NetworkContainerRequestPolicies{Type: "ACL", EndpointType: "pod"}
+	req := CreateNetworkContainerRequest{EndpointPolicies: []NetworkContainerRequestPolicies{apipa, pod}}
+	assert.Equal(t, []NetworkContainerRequestPolicies{pod}, req.PodEndpointPolicies())
+}
+
+func TestPatchNetworkContainer(t *testing.T) {
+	nc := &CreateNetworkContainerRequest{
+		NetworkContainerid: "nc",
+		Version:            "1",
+		SecondaryIPConfigs: map[string]SecondaryIPConfig{
+			"kept":    {IPAddress: "10.0.0.1", NCVersion: 1},
+			"removed": {IPAddress: "10.0.0.2", NCVersion: 1},
+		},
+	}
+	patch := &PatchNetworkContainerRequest{
+		NetworkContainerid:        "nc",
+		BaseVersion:               "1",
+		Version:                   "2",
+		AddedSecondaryIPConfigs:   map[string]SecondaryIPConfig{"added": {IPAddress: "10.0.0.3", NCVersion: 2}},
+		RemovedSecondaryIPConfigs: []string{"removed", "unknown"},
+	}
+
+	patched, err := nc.Patch(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", patched.Version)
+	assert.Equal(t, map[string]SecondaryIPConfig{
+		"kept":  {IPAddress: "10.0.0.1", NCVersion: 1},
+		"added": {IPAddress: "10.0.0.3", NCVersion: 2},
+	}, patched.SecondaryIPConfigs)
+	// the patched NC is a copy
+	assert.Equal(t, "1", nc.Version)
+	assert.Len(t, nc.SecondaryIPConfigs, 2)
+	assert.Contains(t, nc.SecondaryIPConfigs, "removed")
+
+	_, err = patched.Patch(patch)
+	assert.ErrorIs(t, err, ErrNCVersionMismatch)
+}
@@ -32,6 +32,10 @@ const (
 	CreateHostNCApipaEndpointPath = "/network/createhostncapipaendpoint"
 	DeleteHostNCApipaEndpointPath = "/network/deletehostncapipaendpoint"
 	NmAgentSupportedApisPath      = "/network/nmagentsupportedapis"
+	DrainPath                     = "/network/drain"
+	PendingReleasePath            = "/network/ipam/pendingrelease"
+	HealthzPath                   = "/healthz"
+	ReadyzPath                    = "/readyz"
 	V1Prefix                      = "/v0.1"
 	V2Prefix                      = "/v0.2"
 )
@@ -48,6 +52,7 @@ type HTTPService interface {
 	GetPendingReleaseIPConfigs() []IPConfigurationStatus
 	GetPodIPConfigState() map[string]IPConfigurationStatus
 	MarkIPAsPendingRelease(numberToMark int) (map[string]IPConfigurationStatus, error)
+	MarkIPFamilyAsPendingRelease(numberToMark int, family IPFamily) (map[string]IPConfigurationStatus, error)
 }
 
 // This is used for KubernetesCRD orchestrator Type where NC has multiple ips.
@@ -275,6 +280,10 @@ type IPAMPoolMonitor interface {
 	Start(ctx context.Context) error
 	Update(nnc *v1alpha.NodeNetworkConfig) error
 	GetStateSnapshot() IpamPoolMonitorStateSnapshot
+	SetDraining(ctx context.Context, drain bool) error
+	IsDraining() bool
+	ConfirmRelease(ipConfigIDs []string)
+	IsReleaseConfirmed(ipConfigID string) bool
 }
 
 // IpamPoolMonitorStateSnapshot struct to expose state values for IPAMPoolMonitor struct
@@ -338,6 +347,61 @@ type NmAgentSupportedApisResponse struct {
 	SupportedApis []string
 }
 
+// DrainRequest starts or stops draining the IP pool of the node.
+type DrainRequest struct {
+	Drain bool
+}
+
+// DrainStatus is the progress of draining the IP pool of the node.
+// The pool is released once no IPs are assigned to Pods, and the node is drained once the pool is empty.
+type DrainStatus struct {
+	Draining        bool
+	AssignedIPCount int
+	PoolSize        int
+	Drained         bool
+}
+
+// DrainResponse is the response to a DrainRequest, or to a GET of the DrainPath.
+type DrainResponse struct {
+	Response Response
+	Status   DrainStatus
+}
+
+// PendingReleaseIPConfig is an IP which CNS is releasing from the node.
+// Confirmed is set once the CNI has confirmed that no endpoint on the node uses the IP.
+type PendingReleaseIPConfig struct {
+	ID        string
+	IPAddress string
+	Confirmed bool
+}
+
+// PendingReleaseRequest confirms that no endpoint on the node uses the PendingRelease IPs with the IDs,
+// so that CNS can release them from the NodeNetworkConfig.
+type PendingReleaseRequest struct {
+	ConfirmedIPConfigIDs []string
+}
+
+// PendingReleaseResponse is the response to a PendingReleaseRequest, or to a GET of the PendingReleasePath.
+type PendingReleaseResponse struct {
+	Response  Response
+	IPConfigs []PendingReleaseIPConfig
+}
+
+// DependencyHealth is the result of the health check of a dependency of CNS.
+type DependencyHealth struct {
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// HealthResponse is the response to a GET of the HealthzPath or ReadyzPath.
+// CNS is healthy if all the checked dependencies are.
+type HealthResponse struct {
+	Healthy      bool               `json:"healthy"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
 type HomeAzResponse struct {
 	IsSupported bool `json:"isSupported"`
 	HomeAz      uint `json:"homeAz"`
@@ -0,0 +1,235 @@
+// Package bootstrap registers the Node with DNC, or the orchestrator provided registration endpoint,
+// and creates the initial NodeNetworkConfig if it does not exist, so that standalone swift deployments
+// do not depend on an external bootstrap component.
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/avast/retry-go/v3"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Phase is the progress of the bootstrap.
+type Phase string
+
+const (
+	PhasePending     Phase = "Pending"
+	PhaseRegistering Phase = "Registering"
+	PhaseCreatingNNC Phase = "CreatingNNC"
+	PhaseSucceeded   Phase = "Succeeded"
+	PhaseFailed      Phase = "Failed"
+)
+
+var phases = []Phase{PhasePending, PhaseRegistering, PhaseCreatingNNC, PhaseSucceeded, PhaseFailed}
+
+const (
+	defaultAttempts = 10
+	defaultDelay    = 5 * time.Second
+)
+
+var ErrRegistrationFailed = errors.New("node registration failed")
+
+// Status is a snapshot of the bootstrap progress.
+type Status struct {
+	Phase      Phase
+	Attempts   int
+	LastError  string
+	NNCCreated bool
+}
+
+type nncClient interface {
+	Get(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error)
+	Create(context.Context, *v1alpha.NodeNetworkConfig) error
+}
+
+// Options configures the Bootstrapper.
+type Options struct {
+	// RegistrationURL is the DNC or orchestrator provided endpoint the Node registers with.
+	// Registration is skipped if it is empty.
+	RegistrationURL string
+	// InitialIPCount is the RequestedIPCount of the NodeNetworkConfig created if it does not exist.
+	InitialIPCount int64
+	// Attempts is the number of tries for each step. Defaults to 10.
+	Attempts uint
+	// Delay is the initial delay between tries, backing off exponentially. Defaults to 5 seconds.
+	Delay time.Duration
+}
+
+// Bootstrapper registers the Node and creates its NodeNetworkConfig on first start.
+type Bootstrapper struct {
+	sync.Mutex
+	httpc  *http.Client
+	nnccli nncClient
+	node   *corev1.Node
+	nncKey types.NamespacedName
+	opts   Options
+	status Status
+}
+
+// New creates a Bootstrapper for the passed Node and NodeNetworkConfig key.
+func New(httpc *http.Client, nnccli nncClient, node *corev1.Node, nncKey types.NamespacedName, opts Options) *Bootstrapper {
+	if opts.Attempts == 0 {
+		opts.Attempts = defaultAttempts
+	}
+	if opts.Delay == 0 {
+		opts.Delay = defaultDelay
+	}
+	b := &Bootstrapper{
+		httpc:  httpc,
+		nnccli: nnccli,
+		node:   node,
+		nncKey: nncKey,
+		opts:   opts,
+	}
+	b.setPhase(PhasePending)
+	return b
+}
+
+// Status returns the current bootstrap status.
+func (b *Bootstrapper) Status() Status {
+	b.Lock()
+	defer b.Unlock()
+	return b.status
+}
+
+// Run registers the Node, if a RegistrationURL is set, and then creates the NodeNetworkConfig if it does not exist.
+// Each step is retried with backoff, and the returned error is the last error of the failed step.
+func (b *Bootstrapper) Run(ctx context.Context) error {
+	if b.opts.RegistrationURL != "" {
+		b.setPhase(PhaseRegistering)
+		if err := b.retry(ctx, b.register); err != nil {
+			b.setPhase(PhaseFailed)
+			return errors.Wrap(err, "failed to register node")
+		}
+	}
+
+	b.setPhase(PhaseCreatingNNC)
+	if err := b.retry(ctx, b.ensureNNC); err != nil {
+		b.setPhase(PhaseFailed)
+		return errors.Wrap(err, "failed to ensure nnc")
+	}
+
+	b.setPhase(PhaseSucceeded)
+	return nil
+}
+
+func (b *Bootstrapper) retry(ctx context.Context, f func(context.Context) error) error {
+	return retry.Do(func() error {
+		err := f(ctx)
+		b.recordAttempt(err)
+		return err
+	}, retry.Context(ctx), retry.Attempts(b.opts.Attempts), retry.Delay(b.opts.Delay), retry.LastErrorOnly(true))
+}
+
+func (b *Bootstrapper) register(ctx context.Context) error {
+	req := cns.NodeRegisterRequest{
+		NumCores:             runtime.NumCPU(),
+		NmAgentSupportedApis: []string{},
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(req); err != nil {
+		return retry.Unrecoverable(errors.Wrap(err, "failed to encode register request"))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.opts.RegistrationURL, &body)
+	if err != nil {
+		return retry.Unrecoverable(errors.Wrap(err, "failed to build register request"))
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpc.Do(httpReq)
+	if err != nil {
+		return errors.Wrap(err, "register request failed")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		logger.Printf("[Bootstrap] Registered node %s with %s", b.node.Name, b.opts.RegistrationURL)
+	case http.StatusConflict:
+		logger.Printf("[Bootstrap] Node %s is already registered with %s", b.node.Name, b.opts.RegistrationURL)
+	default:
+		return errors.Wrapf(ErrRegistrationFailed, "registration endpoint replied with http status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *Bootstrapper) ensureNNC(ctx context.Context) error {
+	_, err := b.nnccli.Get(ctx, b.nncKey)
+	if err == nil {
+		logger.Printf("[Bootstrap] NNC %s already exists", b.nncKey)
+		return nil
+	}
+	if !apierrors.IsNotFound(errors.Cause(err)) {
+		return err
+	}
+
+	nnc := &v1alpha.NodeNetworkConfig{}
+	nnc.Name = b.nncKey.Name
+	nnc.Namespace = b.nncKey.Namespace
+	nnc.Spec.RequestedIPCount = b.opts.InitialIPCount
+	// the Node owns the NNC so that it is garbage collected with the Node, and the NNC reconciler
+	// uses the controller reference to verify that the NNC was made for this Node.
+	if err := ctrlutil.SetControllerReference(b.node, nnc, nodenetworkconfig.Scheme); err != nil {
+		return retry.Unrecoverable(errors.Wrap(err, "failed to set nnc controller reference"))
+	}
+
+	if err := b.nnccli.Create(ctx, nnc); err != nil {
+		if apierrors.IsAlreadyExists(errors.Cause(err)) {
+			return nil
+		}
+		return err
+	}
+
+	logger.Printf("[Bootstrap] Created NNC %s requesting %d IPs", b.nncKey, b.opts.InitialIPCount)
+	b.Lock()
+	b.status.NNCCreated = true
+	b.Unlock()
+	return nil
+}
+
+func (b *Bootstrapper) recordAttempt(err error) {
+	b.Lock()
+	defer b.Unlock()
+	b.status.Attempts++
+	if err != nil {
+		b.status.LastError = err.Error()
+		logger.Errorf("[Bootstrap] %s attempt %d failed: %v", b.status.Phase, b.status.Attempts, err)
+		return
+	}
+	b.status.LastError = ""
+}
+
+func (b *Bootstrapper) setPhase(phase Phase) {
+	b.Lock()
+	defer b.Unlock()
+	b.status.Phase = phase
+	logger.Printf("[Bootstrap] Phase %s", phase)
+	for _, p := range phases {
+		v := 0.0
+		if p == phase {
+			v = 1
+		}
+		bootstrapPhase.WithLabelValues(string(p)).Set(v)
+	}
+}
+
+func (s Status) String() string {
+	return fmt.Sprintf("phase: %s, attempts: %d, nnc created: %t, last error: %q", s.Phase, s.Attempts, s.NNCCreated, s.LastError)
+}
@@ -0,0 +1,115 @@
+package bootstrap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func init() {
+	logger.InitLogger("testlogs", 0, 0, "./")
+}
+
+type fakeNNCClient struct {
+	nnc       *v1alpha.NodeNetworkConfig
+	getErrs   []error
+	createErr error
+}
+
+func (f *fakeNNCClient) Get(_ context.Context, key types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+	if len(f.getErrs) > 0 {
+		err := f.getErrs[0]
+		f.getErrs = f.getErrs[1:]
+		return nil, err
+	}
+	if f.nnc == nil {
+		return nil, errors.Wrap(apierrors.NewNotFound(schema.GroupResource{}, key.Name), "failed to get nnc")
+	}
+	return f.nnc, nil
+}
+
+func (f *fakeNNCClient) Create(_ context.Context, nnc *v1alpha.NodeNetworkConfig) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.nnc = nnc
+	return nil
+}
+
+var (
+	testNode   = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", UID: "uid"}}
+	testNNCKey = types.NamespacedName{Namespace: "kube-system", Name: "node"}
+)
+
+func TestRunCreatesNNC(t *testing.T) {
+	var registered int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registered++
+		// fail the first try to exercise the retry
+		if registered == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	cli := &fakeNNCClient{}
+	b := New(srv.Client(), cli, testNode, testNNCKey, Options{RegistrationURL: srv.URL, InitialIPCount: 16, Delay: 1})
+	require.NoError(t, b.Run(context.Background()))
+
+	assert.Equal(t, 2, registered)
+	require.NotNil(t, cli.nnc)
+	assert.Equal(t, int64(16), cli.nnc.Spec.RequestedIPCount)
+	require.Len(t, cli.nnc.OwnerReferences, 1)
+	assert.Equal(t, testNode.UID, cli.nnc.OwnerReferences[0].UID)
+
+	status := b.Status()
+	assert.Equal(t, PhaseSucceeded, status.Phase)
+	assert.True(t, status.NNCCreated)
+	assert.Equal(t, 3, status.Attempts)
+	assert.Empty(t, status.LastError)
+}
+
+func TestRunExistingNNC(t *testing.T) {
+	cli := &fakeNNCClient{nnc: &v1alpha.NodeNetworkConfig{}}
+	b := New(http.DefaultClient, cli, testNode, testNNCKey, Options{Delay: 1})
+	require.NoError(t, b.Run(context.Background()))
+	assert.False(t, b.Status().NNCCreated)
+}
+
+func TestRunFails(t *testing.T) {
+	errAPIServer := errors.New("apiserver unavailable")
+	cli := &fakeNNCClient{getErrs: []error{errAPIServer, errAPIServer, errAPIServer}}
+	b := New(http.DefaultClient, cli, testNode, testNNCKey, Options{Attempts: 3, Delay: 1})
+	require.ErrorIs(t, b.Run(context.Background()), errAPIServer)
+
+	status := b.Status()
+	assert.Equal(t, PhaseFailed, status.Phase)
+	assert.Equal(t, 3, status.Attempts)
+	assert.Equal(t, errAPIServer.Error(), status.LastError)
+}
+
+func TestRunRegistrationFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	cli := &fakeNNCClient{}
+	b := New(srv.Client(), cli, testNode, testNNCKey, Options{RegistrationURL: srv.URL, Attempts: 2, Delay: 1})
+	require.ErrorIs(t, b.Run(context.Background()), ErrRegistrationFailed)
+	assert.Nil(t, cli.nnc)
+}
@@ -0,0 +1,20 @@
+package bootstrap
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var bootstrapPhase = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "cns_bootstrap_phase",
+		Help: "Current phase of the CNS node bootstrap. 1 for the current phase, 0 otherwise.",
+	},
+	[]string{"phase"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		bootstrapPhase,
+	)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/restserver"
 	"github.com/Azure/azure-container-networking/cns/types"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/pkg/errors"
 )
 
@@ -33,12 +34,19 @@ var clientPaths = []string{
 	cns.RequestIPConfigs,
 	cns.ReleaseIPConfig,
 	cns.ReleaseIPConfigs,
+	cns.ReserveIPConfig,
+	cns.UnreserveIPConfig,
 	cns.PathDebugIPAddresses,
 	cns.PathDebugPodContext,
 	cns.PathDebugRestData,
+	cns.GetPodContextByIP,
+	cns.GetPodContextsByIP,
+	cns.DrainPath,
+	cns.PendingReleasePath,
 	cns.UnpublishNetworkContainer,
 	cns.PublishNetworkContainer,
 	cns.CreateOrUpdateNetworkContainer,
+	cns.PatchNetworkContainer,
 	cns.SetOrchestratorType,
 	cns.NumberOfCPUCores,
 	cns.NMAgentSupportedAPIs,
@@ -69,13 +77,28 @@ func New(baseURL string, requestTimeout time.Duration) (*Client, error) {
 	}
 
 	return &Client{
-		client: &http.Client{
-			Timeout: requestTimeout,
+		client: correlatedClient{
+			do: &http.Client{
+				Timeout: requestTimeout,
+			},
 		},
 		routes: routes,
 	}, nil
 }
 
+// correlatedClient sets the correlation ID of the context of the requests, if any, as their header, so that CNS can
+// log the requests of a CNI invocation with it.
+type correlatedClient struct {
+	do
+}
+
+func (c correlatedClient) Do(req *http.Request) (*http.Response, error) {
+	if id := acn.CorrelationIDFromContext(req.Context()); id != "" {
+		req.Header.Set(acn.CorrelationIDHeader, id)
+	}
+	return c.do.Do(req) //nolint:wrapcheck // the client is transparent
+}
+
 func buildRoutes(baseURL string, paths []string) (map[string]url.URL, error) {
 	base, err := url.Parse(baseURL)
 	if err != nil {
@@ -332,7 +355,10 @@ func (c *Client) RequestIPAddress(ctx context.Context, ipconfig cns.IPConfigRequ
 	}
 
 	if response.Response.ReturnCode != 0 {
-		return nil, errors.New(response.Response.Message)
+		return nil, &CNSClientError{
+			Code: response.Response.ReturnCode,
+			Err:  errors.New(response.Response.Message),
+		}
 	}
 
 	return &response, nil
@@ -424,7 +450,10 @@ func (c *Client) RequestIPs(ctx context.Context, ipconfig cns.IPConfigsRequest)
 	}
 
 	if response.Response.ReturnCode != 0 {
-		return nil, errors.New(response.Response.Message)
+		return nil, &CNSClientError{
+			Code: response.Response.ReturnCode,
+			Err:  errors.New(response.Response.Message),
+		}
 	}
 
 	return &response, nil
@@ -476,6 +505,83 @@ func (c *Client) ReleaseIPs(ctx context.Context, ipconfig cns.IPConfigsRequest)
 	return nil
 }
 
+// ReserveIPs reserves IPs for a Pod before its sandbox is created, so that CNS assigns them to the Pod when the CNI
+// requests its IPs. The reservation expires if the Pod doesn't request IPs within the TTL.
+func (c *Client) ReserveIPs(ctx context.Context, reserveReq cns.ReserveIPConfigRequest) (*cns.IPReservation, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(reserveReq); err != nil {
+		return nil, errors.Wrap(err, "failed to encode ReserveIPConfigRequest")
+	}
+
+	u := c.routes[cns.ReserveIPConfig]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.ReserveIPConfigResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode ReserveIPConfigResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return &resp.Reservation, nil
+}
+
+// UnreserveIPs releases the IPs reserved for a Pod which weren't assigned to it.
+func (c *Client) UnreserveIPs(ctx context.Context, unreserveReq cns.UnreserveIPConfigRequest) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(unreserveReq); err != nil {
+		return errors.Wrap(err, "failed to encode UnreserveIPConfigRequest")
+	}
+
+	u := c.routes[cns.UnreserveIPConfig]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.Response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return errors.Wrap(err, "failed to decode Response")
+	}
+
+	if resp.ReturnCode != 0 {
+		return &CNSClientError{
+			Code: resp.ReturnCode,
+			Err:  errors.New(resp.Message),
+		}
+	}
+
+	return nil
+}
+
 // GetIPAddressesMatchingStates takes a variadic number of string parameters, to get all IP Addresses matching a number of states
 // usage GetIPAddressesWithStates(ctx, types.Available...)
 func (c *Client) GetIPAddressesMatchingStates(ctx context.Context, stateFilter ...types.IPState) ([]cns.IPConfigurationStatus, error) {
@@ -550,6 +656,175 @@ func (c *Client) GetPodOrchestratorContext(ctx context.Context) (map[string][]st
 	return resp.PodContext, nil
 }
 
+// GetPodContextByIP returns the pod and network container the IP is assigned to.
+// The returned error satisfies IsNotFound if the IP is not assigned to a pod.
+func (c *Client) GetPodContextByIP(ctx context.Context, ip string) (*cns.PodIPContext, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cns.GetPodContextByIPRequest{IP: ip}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetPodContextByIPRequest")
+	}
+
+	u := c.routes[cns.GetPodContextByIP]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetPodContextByIPResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetPodContextByIPResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return &resp.PodIPContext, nil
+}
+
+// GetPodContextsByIP returns the pod and network container each of the IPs is assigned to.
+// IPs which are not assigned to a pod are omitted from the result.
+func (c *Client) GetPodContextsByIP(ctx context.Context, ips []string) ([]cns.PodIPContext, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cns.GetPodContextsByIPRequest{IPs: ips}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode GetPodContextsByIPRequest")
+	}
+
+	u := c.routes[cns.GetPodContextsByIP]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.GetPodContextsByIPResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode GetPodContextsByIPResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, errors.New(resp.Response.Message)
+	}
+
+	return resp.PodIPContexts, nil
+}
+
+// GetDrainStatus returns the progress of draining the IP pool of the node.
+func (c *Client) GetDrainStatus(ctx context.Context) (*cns.DrainStatus, error) {
+	return c.drain(ctx, http.MethodGet, http.NoBody)
+}
+
+// Drain starts or stops draining the IP pool of the node, and returns the progress of the drain.
+// While draining, CNS doesn't assign IPs to new Pods and releases the pool once no IPs are assigned.
+func (c *Client) Drain(ctx context.Context, drain bool) (*cns.DrainStatus, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cns.DrainRequest{Drain: drain}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode DrainRequest")
+	}
+	return c.drain(ctx, http.MethodPost, &body)
+}
+
+func (c *Client) drain(ctx context.Context, method string, body io.Reader) (*cns.DrainStatus, error) {
+	u := c.routes[cns.DrainPath]
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.DrainResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode DrainResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return &resp.Status, nil
+}
+
+// GetPendingReleaseIPs returns the IPs which CNS is releasing from the node.
+func (c *Client) GetPendingReleaseIPs(ctx context.Context) ([]cns.PendingReleaseIPConfig, error) {
+	return c.pendingRelease(ctx, http.MethodGet, http.NoBody)
+}
+
+// ConfirmPendingRelease confirms that no endpoint on the node uses the PendingRelease IPs with the IDs,
+// and returns the IPs which CNS is releasing from the node.
+func (c *Client) ConfirmPendingRelease(ctx context.Context, ipConfigIDs []string) ([]cns.PendingReleaseIPConfig, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(cns.PendingReleaseRequest{ConfirmedIPConfigIDs: ipConfigIDs}); err != nil {
+		return nil, errors.Wrap(err, "failed to encode PendingReleaseRequest")
+	}
+	return c.pendingRelease(ctx, http.MethodPost, &body)
+}
+
+func (c *Client) pendingRelease(ctx context.Context, method string, body io.Reader) ([]cns.PendingReleaseIPConfig, error) {
+	u := c.routes[cns.PendingReleasePath]
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.PendingReleaseResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode PendingReleaseResponse")
+	}
+
+	if resp.Response.ReturnCode != 0 {
+		return nil, &CNSClientError{
+			Code: resp.Response.ReturnCode,
+			Err:  errors.New(resp.Response.Message),
+		}
+	}
+
+	return resp.IPConfigs, nil
+}
+
 // GetHTTPServiceData gets all public in-memory struct details for debugging purpose
 func (c *Client) GetHTTPServiceData(ctx context.Context) (*restserver.GetHTTPServiceDataResponse, error) {
 	u := c.routes[cns.PathDebugRestData]
@@ -770,6 +1045,46 @@ func (c *Client) CreateNetworkContainer(ctx context.Context, cncr cns.CreateNetw
 	return nil
 }
 
+// PatchNetworkContainer applies the changes to the secondary IPs of an existing
+// network container since the base version of the patch. When CNS doesn't have
+// the base version, the error satisfies IsNCVersionMismatch and the whole
+// network container should be sent with CreateNetworkContainer instead.
+func (c *Client) PatchNetworkContainer(ctx context.Context, pncr cns.PatchNetworkContainerRequest) error {
+	if pncr.NetworkContainerid == "" {
+		return errors.New("network container id missing from request")
+	}
+
+	body, err := json.Marshal(pncr)
+	if err != nil {
+		return errors.Wrap(err, "encoding request as JSON")
+	}
+	u := c.routes[cns.PatchNetworkContainer]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "building HTTP request")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending HTTP request")
+	}
+	defer resp.Body.Close()
+
+	var out cns.PatchNetworkContainerResponse
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return errors.Wrap(err, "decoding JSON response")
+	}
+
+	if out.Response.ReturnCode != types.Success {
+		return &CNSClientError{
+			Code: out.Response.ReturnCode,
+			Err:  errors.New(out.Response.Message),
+		}
+	}
+	return nil
+}
+
 // PublishNetworkContainer publishes the provided network container via the
 // NMAgent resident on the node where CNS is running. This effectively proxies
 // the publication through CNS which can be useful for avoiding throttling
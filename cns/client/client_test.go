@@ -22,6 +22,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/restserver"
 	"github.com/Azure/azure-container-networking/cns/types"
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/google/go-cmp/cmp"
@@ -334,6 +335,93 @@ func TestCNSClientPodContextApi(t *testing.T) {
 	assert.NoError(t, err, "Expected to not fail when releasing IP reservation found with context")
 }
 
+func TestCNSClientPodContextByIPApi(t *testing.T) {
+	podName := "testpodname"
+	podNamespace := "testpodnamespace"
+	desiredIpAddress := "10.0.0.5"
+
+	secondaryIps := []string{desiredIpAddress}
+	cnsClient, _ := New("", 2*time.Second)
+
+	addTestStateToRestServer(t, secondaryIps)
+
+	podInfo := cns.NewPodInfo("", "", podName, podNamespace)
+	orchestratorContext, err := json.Marshal(podInfo)
+	assert.NoError(t, err)
+
+	// an IP which is not assigned to a pod is not found
+	_, err = cnsClient.GetPodContextByIP(context.TODO(), desiredIpAddress)
+	assert.True(t, IsNotFound(err), "Expected IP to not be found before it is assigned, got %v", err)
+
+	// request IP address
+	_, err = cnsClient.RequestIPAddress(context.TODO(), cns.IPConfigRequest{OrchestratorContext: orchestratorContext})
+	assert.NoError(t, err, "get IP from CNS failed")
+
+	podIPContext, err := cnsClient.GetPodContextByIP(context.TODO(), desiredIpAddress)
+	assert.NoError(t, err, "Get pod context by IP failed")
+	assert.Equal(t, podName, podIPContext.PodName)
+	assert.Equal(t, podNamespace, podIPContext.PodNamespace)
+	assert.NotEmpty(t, podIPContext.NetworkContainerID)
+
+	podIPContexts, err := cnsClient.GetPodContextsByIP(context.TODO(), []string{"10.0.0.6", desiredIpAddress})
+	assert.NoError(t, err, "Get pod contexts by IP failed")
+	assert.Equal(t, []cns.PodIPContext{*podIPContext}, podIPContexts)
+
+	_, err = cnsClient.GetPodContextsByIP(context.TODO(), []string{"invalid"})
+	assert.Error(t, err, "Expected invalid IP to fail")
+
+	// release requested IP address, expect success
+	err = cnsClient.ReleaseIPAddress(context.TODO(), cns.IPConfigRequest{OrchestratorContext: orchestratorContext})
+	assert.NoError(t, err, "Expected to not fail when releasing IP reservation found with context")
+}
+
+func TestCNSClientDrainApi(t *testing.T) {
+	cnsClient, _ := New("", 2*time.Second)
+	addTestStateToRestServer(t, []string{"10.0.0.5"})
+
+	status, err := cnsClient.Drain(context.TODO(), true)
+	assert.NoError(t, err, "Drain failed")
+	assert.Equal(t, &cns.DrainStatus{Draining: true, PoolSize: 1}, status)
+	defer func() {
+		status, err = cnsClient.Drain(context.TODO(), false)
+		assert.NoError(t, err, "Stopping drain failed")
+		assert.False(t, status.Draining)
+	}()
+
+	podInfo := cns.NewPodInfo("", "", "testpodname", "testpodnamespace")
+	orchestratorContext, err := json.Marshal(podInfo)
+	assert.NoError(t, err)
+
+	// no IPs are assigned while draining
+	_, err = cnsClient.RequestIPAddress(context.TODO(), cns.IPConfigRequest{OrchestratorContext: orchestratorContext})
+	assert.Error(t, err, "Expected IP request to fail while draining")
+
+	status, err = cnsClient.GetDrainStatus(context.TODO())
+	assert.NoError(t, err, "Get drain status failed")
+	assert.Equal(t, &cns.DrainStatus{Draining: true, PoolSize: 1}, status)
+}
+
+func TestCNSClientPendingReleaseApi(t *testing.T) {
+	cnsClient, _ := New("", 2*time.Second)
+	addTestStateToRestServer(t, []string{"10.0.0.6"})
+
+	pendingIPs, err := svc.MarkIPAsPendingRelease(1)
+	assert.NoError(t, err)
+	assert.Len(t, pendingIPs, 1)
+	var id string
+	for k := range pendingIPs {
+		id = k
+	}
+
+	ipConfigs, err := cnsClient.GetPendingReleaseIPs(context.TODO())
+	assert.NoError(t, err, "Get pending release IPs failed")
+	assert.Equal(t, []cns.PendingReleaseIPConfig{{ID: id, IPAddress: "10.0.0.6"}}, ipConfigs)
+
+	ipConfigs, err = cnsClient.ConfirmPendingRelease(context.TODO(), []string{id})
+	assert.NoError(t, err, "Confirm pending release failed")
+	assert.Equal(t, []cns.PendingReleaseIPConfig{{ID: id, IPAddress: "10.0.0.6", Confirmed: true}}, ipConfigs)
+}
+
 func TestCNSClientDebugAPI(t *testing.T) {
 	podName := "testpodname"
 	podNamespace := "testpodnamespace"
@@ -408,8 +496,10 @@ func TestNew(t *testing.T) {
 			url:  "",
 			want: &Client{
 				routes: emptyRoutes,
-				client: &http.Client{
-					Timeout: 0,
+				client: correlatedClient{
+					do: &http.Client{
+						Timeout: 0,
+					},
 				},
 			},
 			wantErr: false,
@@ -419,8 +509,10 @@ func TestNew(t *testing.T) {
 			url:  fqdnBaseURL,
 			want: &Client{
 				routes: fqdnRoutes,
-				client: &http.Client{
-					Timeout: 0,
+				client: correlatedClient{
+					do: &http.Client{
+						Timeout: 0,
+					},
 				},
 			},
 			wantErr: false,
@@ -430,8 +522,10 @@ func TestNew(t *testing.T) {
 			url:  fqdnWithPortBaseURL,
 			want: &Client{
 				routes: fqdnWithPortRoutes,
-				client: &http.Client{
-					Timeout: 0,
+				client: correlatedClient{
+					do: &http.Client{
+						Timeout: 0,
+					},
 				},
 			},
 			wantErr: false,
@@ -457,6 +551,32 @@ func TestNew(t *testing.T) {
 	}
 }
 
+type headerRecorder struct {
+	header http.Header
+}
+
+func (h *headerRecorder) Do(req *http.Request) (*http.Response, error) {
+	h.header = req.Header
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestCorrelatedClient(t *testing.T) {
+	recorder := &headerRecorder{}
+	client := correlatedClient{do: recorder}
+
+	req, err := http.NewRequestWithContext(acn.WithCorrelationID(context.Background(), "id"), http.MethodGet, defaultBaseURL, http.NoBody)
+	require.NoError(t, err)
+	_, err = client.Do(req) //nolint:bodyclose // the body is empty
+	require.NoError(t, err)
+	assert.Equal(t, "id", recorder.header.Get(acn.CorrelationIDHeader))
+
+	req, err = http.NewRequestWithContext(context.Background(), http.MethodGet, defaultBaseURL, http.NoBody)
+	require.NoError(t, err)
+	_, err = client.Do(req) //nolint:bodyclose // the body is empty
+	require.NoError(t, err)
+	assert.Empty(t, recorder.header.Values(acn.CorrelationIDHeader))
+}
+
 func TestBuildRoutes(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1139,6 +1259,53 @@ func TestCreateOrUpdateNetworkContainer(t *testing.T) {
 	}
 }
 
+func TestPatchNC(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	req := cns.PatchNetworkContainerRequest{
+		NetworkContainerid:      "frob",
+		BaseVersion:             "1",
+		Version:                 "2",
+		AddedSecondaryIPConfigs: map[string]cns.SecondaryIPConfig{"foo": {IPAddress: "10.0.0.1", NCVersion: 2}},
+	}
+
+	tests := []struct {
+		name         string
+		req          cns.PatchNetworkContainerRequest
+		returnCode   types.ResponseCode
+		shouldErr    bool
+		wantMismatch bool
+	}{
+		{name: "empty", req: cns.PatchNetworkContainerRequest{}, shouldErr: true},
+		{name: "patched", req: req, returnCode: types.Success},
+		{name: "version mismatch", req: req, returnCode: types.NetworkContainerVersionMismatch, shouldErr: true, wantMismatch: true},
+		{name: "unknown NC", req: req, returnCode: types.UnknownContainerID, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			client := &Client{
+				client: &mockdo{
+					objToReturn: cns.PatchNetworkContainerResponse{
+						Response: cns.Response{ReturnCode: tt.returnCode},
+					},
+					httpStatusCodeToReturn: http.StatusOK,
+				},
+				routes: emptyRoutes,
+			}
+
+			err := client.PatchNetworkContainer(context.TODO(), tt.req)
+			if tt.shouldErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantMismatch, IsNCVersionMismatch(err))
+		})
+	}
+}
+
 func TestPublishNC(t *testing.T) {
 	// create the routes necessary for a test client
 	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
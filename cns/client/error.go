@@ -29,10 +29,17 @@ func (e *CNSClientError) Error() string {
 }
 
 // IsNotFound tests if the provided error is of type CNSClientError and then
-// further tests if the error code is of type UnknowContainerID
+// further tests if the error code is of type UnknowContainerID or NotFound
 func IsNotFound(err error) bool {
 	e := &CNSClientError{}
-	return errors.As(err, &e) && (e.Code == types.UnknownContainerID)
+	return errors.As(err, &e) && (e.Code == types.UnknownContainerID || e.Code == types.NotFound)
+}
+
+// IsNCVersionMismatch tests if the provided error is of type CNSClientError and
+// then further tests if the error code is of type NetworkContainerVersionMismatch
+func IsNCVersionMismatch(err error) bool {
+	e := &CNSClientError{}
+	return errors.As(err, &e) && (e.Code == types.NetworkContainerVersionMismatch)
 }
 
 // IsUnsupportedAPI tests if the provided error is of type CNSClientError and then
@@ -21,6 +21,14 @@ func TestIsNotFound(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "is not found code",
+			err: &CNSClientError{
+				Code: types.NotFound,
+				Err:  errors.New("not found"),
+			},
+			want: true,
+		},
 		{
 			name: "is not cnsclienterr",
 			err:  errors.New("error"),
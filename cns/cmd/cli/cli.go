@@ -18,13 +18,15 @@ const (
 	getCmdArg       = "get"
 	getInMemoryData = "getInMemory"
 	getPodCmdArg    = "getPodContexts"
+	dumpCmdArg      = "dump"
 )
 
 func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error {
 	cnsIPAddress := os.Getenv(envCNSIPAddress)
 	cnsPort := os.Getenv(envCNSPort)
 
-	cnsClient, err := client.New("http://"+cnsIPAddress+":"+cnsPort, client.DefaultTimeout)
+	baseURL := "http://" + cnsIPAddress + ":" + cnsPort
+	cnsClient, err := client.New(baseURL, client.DefaultTimeout)
 	if err != nil {
 		return err
 	}
@@ -36,8 +38,10 @@ func HandleCNSClientCommands(ctx context.Context, cmd string, arg string) error
 		return getPodCmd(ctx, cnsClient)
 	case strings.EqualFold(getInMemoryData, cmd):
 		return getInMemory(ctx, cnsClient)
+	case strings.EqualFold(dumpCmdArg, cmd):
+		return dumpCmd(ctx, baseURL, arg)
 	default:
-		return fmt.Errorf("No debug cmd supplied, options are: %v", getCmdArg)
+		return fmt.Errorf("No debug cmd supplied, options are: %v, %v", getCmdArg, dumpCmdArg)
 	}
 }
 
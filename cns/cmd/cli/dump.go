@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	dumpClusterArg = "cluster"
+	// envSnapshotSigningKeyFile is the path to the key which CNS signs snapshots with. If set, every snapshot must be signed with it.
+	envSnapshotSigningKeyFile = "CNSSnapshotSigningKeyFile"
+
+	cnsNamespace       = "kube-system"
+	cnsPodSelector     = "k8s-app in (azure-cns, azure-cns-win)"
+	cnsPort            = 10090
+	dumpManifestFile   = "manifest.json"
+	dumpSnapshotsDir   = "snapshots"
+	dumpFetchTimeout   = 30 * time.Second
+	dumpFetchWorkers   = 10
+	dumpArchiveFileFmt = "cns-dump-%s.tar.gz"
+)
+
+var errSnapshotHTTPStatus = errors.New("unexpected http status for snapshot")
+
+// snapshotSource is a CNS which a snapshot is collected from.
+type snapshotSource struct {
+	// Name is the node of the CNS, and names its snapshot in the archive.
+	Name string
+	URL  string
+}
+
+// DumpManifest lists the snapshots in a dump archive, and the nodes whose snapshot couldn't be collected.
+type DumpManifest struct {
+	CreatedAt time.Time
+	Nodes     []DumpNode
+}
+
+// DumpNode is the result of collecting the snapshot of the CNS on a node.
+type DumpNode struct {
+	Name string
+	// File is the path of the snapshot in the archive. It's empty if the snapshot couldn't be collected.
+	File     string `json:",omitempty"`
+	Digest   string `json:",omitempty"`
+	Signed   bool
+	Verified bool
+	Error    string `json:",omitempty"`
+}
+
+// dumpCmd collects the snapshot of the local CNS, or of the CNS on every node of the cluster through the
+// Kubernetes API server proxy, into one archive in the working directory.
+func dumpCmd(ctx context.Context, baseURL, arg string) error {
+	var key []byte
+	if keyFile := os.Getenv(envSnapshotSigningKeyFile); keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read snapshot signing key")
+		}
+		key = bytes.TrimSpace(b)
+	}
+
+	var (
+		sources []snapshotSource
+		client  do
+	)
+	switch {
+	case arg == "":
+		hostname, _ := os.Hostname()
+		sources = []snapshotSource{{Name: hostname, URL: baseURL + cns.PathDebugSnapshot}}
+		client = &http.Client{Timeout: dumpFetchTimeout}
+	case strings.EqualFold(dumpClusterArg, arg):
+		var err error
+		sources, client, err = clusterSnapshotSources(ctx)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown dump arg %q, options are: %s or none for the local node", arg, dumpClusterArg)
+	}
+
+	path := fmt.Sprintf(dumpArchiveFileFmt, time.Now().UTC().Format("20060102T150405Z"))
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dump archive")
+	}
+	defer f.Close()
+
+	manifest, err := writeDumpArchive(ctx, f, client, sources, key)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, node := range manifest.Nodes {
+		if node.Error != "" {
+			failed++
+			fmt.Printf("%s: %s\n", node.Name, node.Error)
+		}
+	}
+	fmt.Printf("wrote %s with snapshots of %d/%d nodes\n", path, len(manifest.Nodes)-failed, len(manifest.Nodes))
+	return nil
+}
+
+type do interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// clusterSnapshotSources returns the CNS pods of the cluster as snapshot sources, reached through the API server proxy,
+// and an http client authenticated to the API server.
+func clusterSnapshotSources(ctx context.Context) ([]snapshotSource, do, error) {
+	kubeConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get kubeconfig")
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to build clientset")
+	}
+	httpClient, err := rest.HTTPClientFor(kubeConfig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to build API server http client")
+	}
+	httpClient.Timeout = dumpFetchTimeout
+
+	pods, err := clientset.CoreV1().Pods(cnsNamespace).List(ctx, metav1.ListOptions{LabelSelector: cnsPodSelector})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list CNS pods")
+	}
+
+	sources := make([]snapshotSource, 0, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		u := clientset.CoreV1().RESTClient().Get().
+			Namespace(pod.Namespace).
+			Resource("pods").
+			Name(pod.Name + ":" + strconv.Itoa(cnsPort)).
+			SubResource("proxy").
+			Suffix(cns.PathDebugSnapshot).
+			URL()
+		sources = append(sources, snapshotSource{Name: pod.Spec.NodeName, URL: u.String()})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+	return sources, httpClient, nil
+}
+
+// fetchSnapshot gets the encoded snapshot of a CNS.
+func fetchSnapshot(ctx context.Context, client do, url string) (*restserver.EncodedSnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Wrapf(errSnapshotHTTPStatus, "http response %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+	return &restserver.EncodedSnapshot{
+		Body:      body,
+		Digest:    res.Header.Get(restserver.SnapshotDigestHeader),
+		Signature: res.Header.Get(restserver.SnapshotSignatureHeader),
+	}, nil
+}
+
+// writeDumpArchive fetches and verifies the snapshots of the sources concurrently, and writes them decompressed to a
+// gzipped tar in w, along with a manifest. A node whose snapshot can't be fetched or verified is only listed in the manifest.
+func writeDumpArchive(ctx context.Context, w io.Writer, client do, sources []snapshotSource, key []byte) (*DumpManifest, error) {
+	manifest := &DumpManifest{
+		CreatedAt: time.Now().UTC(),
+		Nodes:     make([]DumpNode, len(sources)),
+	}
+	snapshots := make([][]byte, len(sources))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dumpFetchWorkers)
+	for i := range sources {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			manifest.Nodes[i], snapshots[i] = collectSnapshot(ctx, client, sources[i], key)
+		}(i)
+	}
+	wg.Wait()
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for i := range manifest.Nodes {
+		if snapshots[i] == nil {
+			continue
+		}
+		if err := writeTarFile(tw, manifest.Nodes[i].File, snapshots[i], manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode dump manifest")
+	}
+	if err := writeTarFile(tw, dumpManifestFile, b, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close dump archive")
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to compress dump archive")
+	}
+	return manifest, nil
+}
+
+// collectSnapshot fetches, verifies and decompresses the snapshot of a source.
+// Returns the decompressed snapshot, or nil if it failed.
+func collectSnapshot(ctx context.Context, client do, source snapshotSource, key []byte) (DumpNode, []byte) {
+	node := DumpNode{Name: source.Name}
+	encoded, err := fetchSnapshot(ctx, client, source.URL)
+	if err != nil {
+		node.Error = err.Error()
+		return node, nil
+	}
+	node.Digest = encoded.Digest
+	node.Signed = encoded.Signature != ""
+	if err := encoded.Verify(key); err != nil {
+		node.Error = err.Error()
+		return node, nil
+	}
+	node.Verified = len(key) > 0
+
+	zr, err := gzip.NewReader(bytes.NewReader(encoded.Body))
+	if err != nil {
+		node.Error = errors.Wrap(err, "failed to decompress snapshot").Error()
+		return node, nil
+	}
+	defer zr.Close()
+	snapshot, err := io.ReadAll(zr)
+	if err != nil {
+		node.Error = errors.Wrap(err, "failed to decompress snapshot").Error()
+		return node, nil
+	}
+	node.File = dumpSnapshotsDir + "/" + source.Name + ".json"
+	return node, snapshot
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644, //nolint:gomnd // rw-r--r--
+		Size:    int64(len(b)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.Wrapf(err, "failed to write %s header", name)
+	}
+	if _, err := tw.Write(b); err != nil {
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+	return nil
+}
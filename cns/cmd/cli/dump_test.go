@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/restserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDumpArchive(t *testing.T) {
+	key := []byte("test-key")
+	body, err := restserver.EncodeSnapshot(&restserver.Snapshot{NodeID: "node1"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/signed", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(restserver.SnapshotDigestHeader, restserver.SnapshotDigest(body))
+		w.Header().Set(restserver.SnapshotSignatureHeader, restserver.SignSnapshot(body, key))
+		_, _ = w.Write(body)
+	})
+	mux.HandleFunc("/unsigned", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(restserver.SnapshotDigestHeader, restserver.SnapshotDigest(body))
+		_, _ = w.Write(body)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sources := []snapshotSource{
+		{Name: "node1", URL: srv.URL + "/signed"},
+		{Name: "node2", URL: srv.URL + "/unsigned"},
+		{Name: "node3", URL: srv.URL + "/error"},
+	}
+	var archive bytes.Buffer
+	manifest, err := writeDumpArchive(context.Background(), &archive, srv.Client(), sources, key)
+	require.NoError(t, err)
+
+	require.Len(t, manifest.Nodes, 3)
+	assert.Equal(t, DumpNode{
+		Name:     "node1",
+		File:     "snapshots/node1.json",
+		Digest:   restserver.SnapshotDigest(body),
+		Signed:   true,
+		Verified: true,
+	}, manifest.Nodes[0])
+	assert.Equal(t, restserver.ErrSnapshotNotSigned.Error(), manifest.Nodes[1].Error)
+	assert.Empty(t, manifest.Nodes[1].File)
+	assert.NotEmpty(t, manifest.Nodes[2].Error)
+
+	files := readTarGz(t, &archive)
+	require.Len(t, files, 2)
+	var snapshot restserver.Snapshot
+	require.NoError(t, json.Unmarshal(files["snapshots/node1.json"], &snapshot))
+	assert.Equal(t, "node1", snapshot.NodeID)
+	var gotManifest DumpManifest
+	require.NoError(t, json.Unmarshal(files[dumpManifestFile], &gotManifest))
+	assert.Equal(t, manifest.Nodes, gotManifest.Nodes)
+}
+
+func readTarGz(t *testing.T, r io.Reader) map[string][]byte {
+	zr, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = b
+	}
+	return files
+}
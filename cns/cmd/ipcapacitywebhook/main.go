@@ -0,0 +1,96 @@
+// Command azure-cns-ipcapacity-webhook serves the validating admission webhook which rejects, or warns on,
+// Pods placed on Nodes whose IP pool is exhausted and can't grow.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/Azure/azure-container-networking/cns/ipcapacity"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberuntime "k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	name = "azure-cns-ipcapacity-webhook"
+	// webhookPath is the path of the webhook in the ValidatingWebhookConfiguration.
+	webhookPath = "/validate-pod-ip-capacity"
+)
+
+func main() {
+	var (
+		mode        string
+		port        int
+		certDir     string
+		metricsAddr string
+		probeAddr   string
+	)
+	flag.StringVar(&mode, "mode", string(ipcapacity.Warn), "what to do with pods placed on nodes without IP capacity: warn or deny")
+	flag.IntVar(&port, "port", webhook.DefaultPort, "port the webhook listens on")
+	flag.StringVar(&certDir, "cert-dir", "", "directory of the tls.crt and tls.key of the webhook")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":9090", "address the metrics are served on")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":9091", "address the health probes are served on")
+	flag.Parse()
+
+	logger.InitLogger(name, log.LevelInfo, log.TargetStdout, "")
+
+	if err := run(ctrl.SetupSignalHandler(), mode, port, certDir, metricsAddr, probeAddr); err != nil {
+		logger.Errorf("[ip-capacity] %s failed: %v", name, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, mode string, port int, certDir, metricsAddr, probeAddr string) error {
+	m, err := ipcapacity.ParseMode(mode)
+	if err != nil {
+		return err
+	}
+
+	scheme := kuberuntime.NewScheme()
+	if err = clientgoscheme.AddToScheme(scheme); err != nil {
+		return errors.Wrap(err, "failed to add client-go types to scheme")
+	}
+	if err = v1alpha.AddToScheme(scheme); err != nil {
+		return errors.Wrap(err, "failed to add nodenetworkconfig/v1alpha to scheme")
+	}
+
+	manager, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Port:                   port,
+		CertDir:                certDir,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to create manager")
+	}
+
+	if err = manager.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, ipcapacity.PodNodeNameIndex, ipcapacity.IndexPodNodeName); err != nil {
+		return errors.Wrap(err, "failed to index pods by node")
+	}
+
+	validator, err := ipcapacity.NewValidator(manager.GetClient(), scheme, m)
+	if err != nil {
+		return err
+	}
+	manager.GetWebhookServer().Register(webhookPath, &webhook.Admission{Handler: validator})
+
+	if err = manager.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return errors.Wrap(err, "failed to add healthz check")
+	}
+	if err = manager.AddReadyzCheck("webhook", manager.GetWebhookServer().StartedChecker()); err != nil {
+		return errors.Wrap(err, "failed to add readyz check")
+	}
+
+	logger.Printf("[ip-capacity] Starting %s in %s mode", name, m)
+	return errors.Wrap(manager.Start(ctx), "failed to run manager")
+}
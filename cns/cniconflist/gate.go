@@ -0,0 +1,79 @@
+package cniconflist
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/Azure/azure-container-networking/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// Generator generates a CNI conflist to its output stream
+type Generator interface {
+	Generate() error
+	Close() error
+}
+
+// GatedGenerator writes the conflist generated by a scenario Generator to a file on every Generate, and removes
+// it on Remove. Kubelet reports the node network as ready only while a conflist exists, so toggling the file
+// lets the kubelet network-ready condition follow the actual readiness of the node to serve CNI ADDs.
+type GatedGenerator struct {
+	filepath     string
+	newGenerator func(io.WriteCloser) Generator
+}
+
+// NewGatedGenerator creates a GatedGenerator writing to filepath with the Generators returned by newGenerator.
+// Any existing conflist is removed, so that the node is not ready until the first Generate.
+func NewGatedGenerator(filepath string, newGenerator func(io.WriteCloser) Generator) (*GatedGenerator, error) {
+	g := &GatedGenerator{
+		filepath:     filepath,
+		newGenerator: newGenerator,
+	}
+	if err := g.Remove(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Generate atomically writes the conflist, replacing any existing conflist.
+func (g *GatedGenerator) Generate() error {
+	// generate to memory first so that a failed generation never leaves a partial conflist in place.
+	var buf bytes.Buffer
+	if err := g.newGenerator(nopWriteCloser{&buf}).Generate(); err != nil {
+		return errors.Wrap(err, "unable to generate conflist")
+	}
+
+	w, err := fs.NewAtomicWriter(g.filepath)
+	if err != nil {
+		return errors.Wrap(err, "unable to create atomic writer")
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return errors.Wrap(err, "unable to write conflist")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "unable to close conflist")
+	}
+	return nil
+}
+
+// Remove removes the conflist if it exists.
+func (g *GatedGenerator) Remove() error {
+	if err := os.Remove(g.filepath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove conflist")
+	}
+	return nil
+}
+
+// Close is a no-op, since every Generate writes and closes its own file.
+func (g *GatedGenerator) Close() error {
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package cniconflist_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/cniconflist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errGenerate = errors.New("generate failed")
+
+type fakeGenerator struct {
+	w   io.WriteCloser
+	err error
+}
+
+func (f *fakeGenerator) Generate() error {
+	if f.err != nil {
+		return f.err
+	}
+	_, err := f.w.Write([]byte("conflist"))
+	return err //nolint:wrapcheck // test
+}
+
+func (f *fakeGenerator) Close() error {
+	return f.w.Close() //nolint:wrapcheck // test
+}
+
+func TestGatedGenerator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "10-azure.conflist")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0o600))
+
+	var genErr error
+	g, err := cniconflist.NewGatedGenerator(path, func(w io.WriteCloser) cniconflist.Generator {
+		return &fakeGenerator{w: w, err: genErr}
+	})
+	require.NoError(t, err)
+
+	// the stale conflist is removed until the first Generate
+	assert.NoFileExists(t, path)
+
+	require.NoError(t, g.Generate())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "conflist", string(b))
+
+	require.NoError(t, g.Remove())
+	assert.NoFileExists(t, path)
+	// removing a missing conflist is not an error
+	require.NoError(t, g.Remove())
+
+	// a failed generation doesn't write a conflist
+	genErr = errGenerate
+	require.ErrorIs(t, g.Generate(), errGenerate)
+	assert.NoFileExists(t, path)
+}
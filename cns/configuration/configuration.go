@@ -17,6 +17,11 @@ const (
 	// EnvCNSConfig is the CNS_CONFIGURATION_PATH env var key
 	EnvCNSConfig      = "CNS_CONFIGURATION_PATH"
 	defaultConfigName = "cns_config.json"
+
+	// StoreBackendJSON persists the state in a JSON file rewritten on every write.
+	StoreBackendJSON = "json"
+	// StoreBackendBolt persists the state in an embedded bolt database, committing every write in a transaction.
+	StoreBackendBolt = "bolt"
 )
 
 type CNSConfig struct {
@@ -41,8 +46,66 @@ type CNSConfig struct {
 	ManageEndpointState                  bool
 	CNIConflistScenario                  string
 	EnableCNIConflistGeneration          bool
+	EnableCNIConflistReadinessGate       bool
 	CNIConflistFilepath                  string
 	PopulateHomeAzCacheRetryIntervalSecs int
+	Bootstrap                            BootstrapSettings
+	// EnableIPReleaseConfirmation holds IPs released by the pool monitor in the NodeNetworkConfig until the CNI
+	// confirms that no endpoint uses them, or until IPReleaseConfirmationTimeoutSecs expire.
+	EnableIPReleaseConfirmation      bool
+	IPReleaseConfirmationTimeoutSecs int
+	// EnableIPPoolPressureReport sets the IPPoolPressure of the NodeNetworkConfig while the IP pool has been unable
+	// to grow for new Pods for IPPoolPressureThresholdSecs, so that node autoscalers can prefer adding nodes.
+	EnableIPPoolPressureReport  bool
+	IPPoolPressureThresholdSecs int
+	// StateCompactionIntervalSecs periodically drops tombstoned entries from the persisted state and backs it up,
+	// keeping the newest StateBackupCount backups. Zero disables compaction and backups.
+	StateCompactionIntervalSecs int
+	StateBackupCount            int
+	// IPConflictCheckIntervalSecs periodically publishes the IPs assigned on the Node as its IPClaimSummary and
+	// flags the IPs which other Nodes claim as well. Zero disables the check.
+	IPConflictCheckIntervalSecs int
+	// IPLeakCheckIntervalSecs periodically reports the IPs assigned for more than IPLeakGracePeriodSecs to Pods whose
+	// sandboxes the container runtime at CRIRuntimeEndpoint no longer runs, listed with crictl, and releases them if
+	// ReclaimLeakedIPs is set. Zero disables the check, a zero grace period means 30 minutes.
+	IPLeakCheckIntervalSecs int
+	IPLeakGracePeriodSecs   int
+	ReclaimLeakedIPs        bool
+	CRIRuntimeEndpoint      string
+	// SnapshotSigningKeyFile is the path to a key, e.g. from a Secret mounted on all Nodes, which signs the snapshots of
+	// the /debug/snapshot endpoint so that the cluster dump collector can verify them. Empty leaves them unsigned.
+	SnapshotSigningKeyFile string
+	// EnableKubernetesEvents emits Kubernetes Events on the Node when the IP pool is exhausted, an NC can't be
+	// programmed, or IPs have been pending release for IPReleaseStuckThresholdMins. Events of the same reason are
+	// emitted at most once per KubernetesEventIntervalSecs.
+	EnableKubernetesEvents      bool
+	KubernetesEventIntervalSecs int
+	IPReleaseStuckThresholdMins int
+	// WireserverCAFile is a PEM bundle of the CAs trusted by the TLS connections to the wireserver and NMAgent,
+	// instead of the CAs of the host.
+	WireserverCAFile string
+	// IPHooks are the local executables and webhooks notified of the IPs assigned to and released from Pods, with the
+	// metadata of the Pods, e.g. for DNS registration or firewall sync. Each notification is bounded by
+	// IPHookTimeoutSecs.
+	IPHooks           []IPHookSettings
+	IPHookTimeoutSecs int
+	// EnableMetricsNodeLabels adds the name of the Node and the values of its MetricsNodeLabels to every metric served
+	// on MetricsBindAddress in CRD mode, so that they can be scraped without relabeling.
+	EnableMetricsNodeLabels bool
+	MetricsNodeLabels       []string
+	// StoreBackend is the backend of the persisted state, StoreBackendJSON by default. StoreBackendBolt suits the
+	// high-churn Nodes, where rewriting the whole JSON file on every IP assignment is a bottleneck; the JSON state is
+	// migrated to it on the first start.
+	StoreBackend string
+}
+
+// IPHookSettings configures an IP hook, which either runs Exec or POSTs to WebhookURL.
+type IPHookSettings struct {
+	Name string
+	// Exec is the executable and its arguments, run with the event as JSON on its stdin.
+	Exec []string
+	// WebhookURL is the local endpoint the event is POSTed to as JSON.
+	WebhookURL string
 }
 
 type TelemetrySettings struct {
@@ -79,6 +142,22 @@ type ManagedSettings struct {
 	NodeSyncIntervalInSeconds int
 }
 
+// BootstrapSettings configures the self-registration of the Node in CRD mode, for standalone swift
+// deployments without an external bootstrap component.
+type BootstrapSettings struct {
+	// Enabled turns on the Node registration and NodeNetworkConfig creation on start.
+	Enabled bool
+	// RegistrationURL is the DNC or orchestrator provided endpoint the Node registers with.
+	// Registration is skipped if it is empty.
+	RegistrationURL string
+	// InitialIPCount is the RequestedIPCount of the NodeNetworkConfig created if it does not exist.
+	InitialIPCount int64
+	// RetryAttempts is the number of tries for each bootstrap step.
+	RetryAttempts uint
+	// RetryDelayInSeconds is the initial delay between tries, backing off exponentially.
+	RetryDelayInSeconds int
+}
+
 type MSISettings struct {
 	ResourceID string
 }
@@ -164,6 +243,18 @@ func setManagedSettingDefaults(managedSettings *ManagedSettings) {
 	}
 }
 
+func setBootstrapSettingsDefaults(bs *BootstrapSettings) {
+	if bs.InitialIPCount == 0 {
+		bs.InitialIPCount = 16 //nolint:gomnd // default batch size
+	}
+	if bs.RetryAttempts == 0 {
+		bs.RetryAttempts = 10 //nolint:gomnd // default retries
+	}
+	if bs.RetryDelayInSeconds == 0 {
+		bs.RetryDelayInSeconds = 5 //nolint:gomnd // default times
+	}
+}
+
 func setKeyVaultSettingsDefaults(kvs *KeyVaultSettings) {
 	if kvs.RefreshIntervalInHrs == 0 {
 		kvs.RefreshIntervalInHrs = 12 //nolint:gomnd // default times
@@ -175,6 +266,7 @@ func SetCNSConfigDefaults(config *CNSConfig) {
 	setTelemetrySettingDefaults(&config.TelemetrySettings)
 	setManagedSettingDefaults(&config.ManagedSettings)
 	setKeyVaultSettingsDefaults(&config.KeyVaultSettings)
+	setBootstrapSettingsDefaults(&config.Bootstrap)
 
 	if config.ChannelMode == "" {
 		config.ChannelMode = cns.Direct
@@ -208,6 +208,11 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 30,
 				WireserverIP:                         "168.63.129.16",
+				Bootstrap: BootstrapSettings{
+					InitialIPCount:      16,
+					RetryAttempts:       10,
+					RetryDelayInSeconds: 5,
+				},
 			},
 		},
 		{
@@ -231,6 +236,11 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 					RefreshIntervalInHrs: 3,
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 10,
+				Bootstrap: BootstrapSettings{
+					InitialIPCount:      8,
+					RetryAttempts:       3,
+					RetryDelayInSeconds: 1,
+				},
 			},
 			want: CNSConfig{
 				ChannelMode: "Other",
@@ -252,6 +262,11 @@ func TestSetCNSConfigDefaults(t *testing.T) {
 				},
 				PopulateHomeAzCacheRetryIntervalSecs: 10,
 				WireserverIP:                         "168.63.129.16",
+				Bootstrap: BootstrapSettings{
+					InitialIPCount:      8,
+					RetryAttempts:       3,
+					RetryDelayInSeconds: 1,
+				},
 			},
 		},
 	}
@@ -0,0 +1,86 @@
+// Package events emits Kubernetes Events on the Node for the IPAM occurrences which cluster admins need to
+// see without access to the logs of the Node, e.g. with kubectl describe node.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// Component is the source of the Events emitted by CNS.
+	Component = "azure-cns"
+	// DefaultInterval is the minimum delay between two Events of the same reason.
+	DefaultInterval = 10 * time.Minute
+)
+
+// The reasons of the Events.
+const (
+	// ReasonIPPoolExhausted is emitted while the IP pool has been unable to grow for new Pods.
+	ReasonIPPoolExhausted = "IPPoolExhausted"
+	// ReasonNCProgrammingFailed is emitted when a NetworkContainer of the NodeNetworkConfig can't be programmed.
+	ReasonNCProgrammingFailed = "NetworkContainerProgrammingFailed"
+	// ReasonIPReleaseStuck is emitted while IPs have been pending release for longer than expected.
+	ReasonIPReleaseStuck = "IPReleaseStuck"
+)
+
+// Recorder emits Warning Events on the Node, at most one per reason per interval so that a persistent
+// condition doesn't flood the apiserver. A nil Recorder discards the Events, so that they are optional.
+type Recorder struct {
+	recorder record.EventRecorder
+	node     *corev1.ObjectReference
+	interval time.Duration
+	now      func() time.Time
+
+	sync.Mutex
+	last map[string]time.Time
+}
+
+// NewRecorder creates a Recorder emitting the Events on the Node through the EventRecorder.
+func NewRecorder(recorder record.EventRecorder, node *corev1.Node, interval time.Duration) *Recorder {
+	if interval < 1 {
+		interval = DefaultInterval
+	}
+	return &Recorder{
+		recorder: recorder,
+		node: &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		},
+		interval: interval,
+		now:      time.Now,
+		last:     map[string]time.Time{},
+	}
+}
+
+// NewBroadcastRecorder creates an EventRecorder writing the Events of CNS to the apiserver.
+func NewBroadcastRecorder(clientset kubernetes.Interface, nodeName string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: Component, Host: nodeName})
+}
+
+// Warningf emits a Warning Event of the reason, unless one was emitted less than the interval ago.
+func (r *Recorder) Warningf(reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	now := r.now()
+	if last, ok := r.last[reason]; ok && now.Sub(last) < r.interval {
+		r.Unlock()
+		return
+	}
+	r.last[reason] = now
+	r.Unlock()
+	r.recorder.Event(r.node, corev1.EventTypeWarning, reason, fmt.Sprintf(messageFmt, args...))
+}
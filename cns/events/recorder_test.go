@@ -0,0 +1,40 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestWarningfRateLimit(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "uid-1"}}
+	r := NewRecorder(fake, node, time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.Warningf(ReasonNCProgrammingFailed, "Failed to program network container %s: %v", "nc-1", errors.New("boom"))
+	require.Len(t, fake.Events, 1)
+	assert.Equal(t, "Warning NetworkContainerProgrammingFailed Failed to program network container nc-1: boom", <-fake.Events)
+
+	// the same reason is dropped until the interval elapses, other reasons aren't
+	r.Warningf(ReasonNCProgrammingFailed, "Failed to program network container %s: %v", "nc-1", errors.New("boom"))
+	r.Warningf(ReasonIPPoolExhausted, "IP pool can't grow")
+	require.Len(t, fake.Events, 1)
+	assert.Equal(t, "Warning IPPoolExhausted IP pool can't grow", <-fake.Events)
+
+	now = now.Add(time.Minute)
+	r.Warningf(ReasonNCProgrammingFailed, "Failed to program network container %s: %v", "nc-1", errors.New("boom"))
+	assert.Len(t, fake.Events, 1)
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	assert.NotPanics(t, func() { r.Warningf(ReasonIPReleaseStuck, "%d IPs", 1) })
+}
@@ -116,6 +116,11 @@ func (ipm *IPStateManager) ReleaseIPConfig(ipconfigID string) (cns.IPConfigurati
 }
 
 func (ipm *IPStateManager) MarkIPAsPendingRelease(numberOfIPsToMark int) (map[string]cns.IPConfigurationStatus, error) {
+	return ipm.MarkIPFamilyAsPendingRelease(numberOfIPsToMark, "")
+}
+
+// MarkIPFamilyAsPendingRelease marks Available IPs of the family, or of any family if it's empty, as PendingRelease.
+func (ipm *IPStateManager) MarkIPFamilyAsPendingRelease(numberOfIPsToMark int, family cns.IPFamily) (map[string]cns.IPConfigurationStatus, error) {
 	ipm.Lock()
 	defer ipm.Unlock()
 
@@ -123,6 +128,14 @@ func (ipm *IPStateManager) MarkIPAsPendingRelease(numberOfIPsToMark int) (map[st
 
 	pendingReleaseIPs := make(map[string]cns.IPConfigurationStatus)
 
+	// the Available IPs of other families are put back on the stack in the same order
+	var skipped []string
+	defer func() {
+		for i := len(skipped) - 1; i >= 0; i-- {
+			ipm.AvailableIPIDStack.Push(skipped[i])
+		}
+	}()
+
 	defer func() {
 		// if there was an error, and not all ip's have been freed, restore state
 		if err != nil && len(pendingReleaseIPs) != numberOfIPsToMark {
@@ -135,7 +148,7 @@ func (ipm *IPStateManager) MarkIPAsPendingRelease(numberOfIPsToMark int) (map[st
 		}
 	}()
 
-	for i := 0; i < numberOfIPsToMark; i++ {
+	for len(pendingReleaseIPs) < numberOfIPsToMark {
 		id, err := ipm.AvailableIPIDStack.Pop()
 		if err != nil {
 			return ipm.PendingReleaseIPConfigState, err
@@ -143,6 +156,10 @@ func (ipm *IPStateManager) MarkIPAsPendingRelease(numberOfIPsToMark int) (map[st
 
 		// add all pending release to a slice
 		ipConfig := ipm.AvailableIPConfigState[id]
+		if family != "" && cns.IPFamilyOf(ipConfig.IPAddress) != family {
+			skipped = append(skipped, id)
+			continue
+		}
 		ipConfig.SetState(types.PendingRelease)
 		pendingReleaseIPs[id] = ipConfig
 
@@ -261,6 +278,10 @@ func (fake *HTTPServiceFake) MarkIPAsPendingRelease(numberToMark int) (map[strin
 	return fake.IPStateManager.MarkIPAsPendingRelease(numberToMark)
 }
 
+func (fake *HTTPServiceFake) MarkIPFamilyAsPendingRelease(numberToMark int, family cns.IPFamily) (map[string]cns.IPConfigurationStatus, error) {
+	return fake.IPStateManager.MarkIPFamilyAsPendingRelease(numberToMark, family)
+}
+
 func (fake *HTTPServiceFake) GetOption(string) interface{} {
 	return nil
 }
@@ -13,6 +13,8 @@ import (
 type MonitorFake struct {
 	IPsNotInUseCount  int64
 	NodeNetworkConfig *v1alpha.NodeNetworkConfig
+	Draining          bool
+	ConfirmedRelease  map[string]struct{}
 }
 
 func (*MonitorFake) Start(ctx context.Context) error {
@@ -28,6 +30,29 @@ func (*MonitorFake) Reconcile() error {
 	return nil
 }
 
+func (f *MonitorFake) SetDraining(_ context.Context, drain bool) error {
+	f.Draining = drain
+	return nil
+}
+
+func (f *MonitorFake) IsDraining() bool {
+	return f.Draining
+}
+
+func (f *MonitorFake) ConfirmRelease(ipConfigIDs []string) {
+	if f.ConfirmedRelease == nil {
+		f.ConfirmedRelease = map[string]struct{}{}
+	}
+	for _, id := range ipConfigIDs {
+		f.ConfirmedRelease[id] = struct{}{}
+	}
+}
+
+func (f *MonitorFake) IsReleaseConfirmed(ipConfigID string) bool {
+	_, ok := f.ConfirmedRelease[ipConfigID]
+	return ok
+}
+
 func (f *MonitorFake) GetStateSnapshot() cns.IpamPoolMonitorStateSnapshot {
 	return cns.IpamPoolMonitorStateSnapshot{
 		MaximumFreeIps:           int64(float64(f.NodeNetworkConfig.Status.Scaler.BatchSize) * (float64(f.NodeNetworkConfig.Status.Scaler.ReleaseThresholdPercent) / 100)), //nolint:gomnd // it's a percent
@@ -0,0 +1,81 @@
+package healthserver
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nodeNameLabel is the label of the metrics with the name of the Node.
+const nodeNameLabel = "node"
+
+// nodeLabels are the labels added to every metric served, nil until SetNodeLabels.
+var nodeLabels atomic.Pointer[[]*dto.LabelPair]
+
+// SetNodeLabels adds the name of the Node and the values of its labels with the keys to every metric served, so that
+// they can be scraped without relabeling. The label keys are prefixed with "node_" and sanitized into label names,
+// e.g. kubernetes.azure.com/agentpool as node_kubernetes_azure_com_agentpool. The Node may not have them all.
+func SetNodeLabels(node *corev1.Node, keys []string) {
+	labels := map[string]string{nodeNameLabel: node.Name}
+	for _, key := range keys {
+		if value, ok := node.Labels[key]; ok {
+			labels[nodeLabelName(key)] = value
+		}
+	}
+
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		name, value := name, value
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	nodeLabels.Store(&pairs)
+}
+
+func nodeLabelName(key string) string {
+	return "node_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// nodeLabelsGatherer adds the Node labels, once set, to the metrics of the Gatherer which don't have labels of the
+// same names already.
+type nodeLabelsGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g nodeLabelsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+	labels := nodeLabels.Load()
+	if labels == nil {
+		return mfs, err //nolint:wrapcheck // the metrics gathered are served along with the error
+	}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			m.Label = withLabels(m.Label, *labels)
+		}
+	}
+	return mfs, err //nolint:wrapcheck // the metrics gathered are served along with the error
+}
+
+func withLabels(existing, labels []*dto.LabelPair) []*dto.LabelPair {
+	names := make(map[string]struct{}, len(existing))
+	for _, l := range existing {
+		names[l.GetName()] = struct{}{}
+	}
+	merged := existing
+	for _, l := range labels {
+		if _, ok := names[l.GetName()]; !ok {
+			merged = append(merged, l)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].GetName() < merged[j].GetName() })
+	return merged
+}
@@ -0,0 +1,49 @@
+package healthserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeLabelsGatherer(t *testing.T) {
+	t.Cleanup(func() { nodeLabels.Store(nil) })
+
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge."}, []string{"node"})
+	reg.MustRegister(gauge)
+	gauge.WithLabelValues("other").Set(1)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Test counter."})
+	reg.MustRegister(counter)
+	counter.Inc()
+	gatherer := nodeLabelsGatherer{reg}
+
+	// without Node labels, the metrics are unchanged
+	require.NoError(t, testutil.GatherAndCompare(gatherer, strings.NewReader(`
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total 1
+`), "test_total"))
+
+	SetNodeLabels(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{"kubernetes.azure.com/agentpool": "pool1", "other": "ignored"},
+		},
+	}, []string{"kubernetes.azure.com/agentpool", "missing"})
+
+	// the Node labels are added, except those the metrics have already
+	require.NoError(t, testutil.GatherAndCompare(gatherer, strings.NewReader(`
+# HELP test_gauge Test gauge.
+# TYPE test_gauge gauge
+test_gauge{node="other",node_kubernetes_azure_com_agentpool="pool1"} 1
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{node="node1",node_kubernetes_azure_com_agentpool="pool1"} 1
+`)))
+}
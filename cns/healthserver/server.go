@@ -10,11 +10,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// Start serves the health checks and the metrics, with the Node labels once set by SetNodeLabels.
 func Start(log *zap.Logger, addr string) {
 	e := echo.New()
 	e.HideBanner = true
 	e.GET("/healthz", echo.WrapHandler(http.StripPrefix("/healthz", &healthz.Handler{})))
-	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+	e.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(nodeLabelsGatherer{metrics.Registry}, promhttp.HandlerOpts{
 		ErrorHandling: promhttp.HTTPErrorOnError,
 	})))
 	if err := e.Start(addr); err != nil {
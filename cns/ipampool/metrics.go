@@ -1,6 +1,7 @@
 package ipampool
 
 import (
+	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -12,6 +13,8 @@ const (
 	customerMetricLabel        = "customer_metric"
 	customerMetricLabelValue   = "customer metric"
 	subnetExhaustionStateLabel = "subnet_exhaustion_state"
+	ipStateLabel               = "state"
+	ipFamilyLabel              = "ip_family"
 	subnetIPExhausted          = 1
 	subnetIPNotExhausted       = 0
 )
@@ -113,6 +116,38 @@ var (
 		},
 		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
 	)
+	ipamPoolPressure = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_pool_pressure",
+			Help:        "Whether the IP pool has been unable to grow for new Pods for a sustained period.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
+	)
+	ipamSubnetIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_subnet_ips",
+			Help:        "Count of IPs in each subnet of the pool by state, in subnet-per-namespace mode.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel, ipStateLabel},
+	)
+	ipamFamilyIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_family_ips",
+			Help:        "Count of IPs of each IP family of a dual-stack pool by state.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel, ipFamilyLabel, ipStateLabel},
+	)
+	ipamFamilyRequestedIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "cx_ipam_family_requested_ips",
+			Help:        "Requested IP count of each IP family of a dual-stack pool.",
+			ConstLabels: prometheus.Labels{customerMetricLabel: customerMetricLabelValue},
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel, ipFamilyLabel},
+	)
 	ipamSubnetExhaustionCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "cx_ipam_subnet_exhaustion_state_count_total",
@@ -136,6 +171,10 @@ func init() {
 		ipamRequestedIPConfigCount,
 		ipamTotalIPCount,
 		ipamSubnetExhaustionState,
+		ipamPoolPressure,
+		ipamSubnetIPCount,
+		ipamFamilyIPCount,
+		ipamFamilyRequestedIPCount,
 		ipamSubnetExhaustionCount,
 	)
 }
@@ -159,3 +198,29 @@ func observeIPPoolState(state ipPoolState, meta metaState) {
 		ipamSubnetExhaustionState.WithLabelValues(labels...).Set(float64(subnetIPNotExhausted))
 	}
 }
+
+func observeSubnetPoolStates(states map[subnetMeta]map[types.IPState]int64) {
+	for subnet, counts := range states {
+		for state, count := range counts {
+			ipamSubnetIPCount.WithLabelValues(subnet.subnet, subnet.subnetCIDR, subnet.subnetARMID, string(state)).Set(float64(count))
+		}
+	}
+}
+
+func observeFamilyPoolState(state ipPoolState, meta metaState) {
+	labels := []string{meta.subnet, meta.subnetCIDR, meta.subnetARMID, string(state.family)}
+	ipamFamilyIPCount.WithLabelValues(append(labels, string(types.Assigned))...).Set(float64(state.allocatedToPods))
+	ipamFamilyIPCount.WithLabelValues(append(labels, string(types.Available))...).Set(float64(state.available))
+	ipamFamilyIPCount.WithLabelValues(append(labels, string(types.PendingProgramming))...).Set(float64(state.pendingProgramming))
+	ipamFamilyIPCount.WithLabelValues(append(labels, string(types.PendingRelease))...).Set(float64(state.pendingRelease))
+	ipamFamilyRequestedIPCount.WithLabelValues(labels...).Set(float64(state.requestedIPs))
+}
+
+func observeIPPoolPressure(sustained bool, meta metaState) {
+	labels := []string{meta.subnet, meta.subnetCIDR, meta.subnetARMID}
+	if sustained {
+		ipamPoolPressure.WithLabelValues(labels...).Set(1)
+	} else {
+		ipamPoolPressure.WithLabelValues(labels...).Set(0)
+	}
+}
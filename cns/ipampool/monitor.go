@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/events"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/metric"
 	"github.com/Azure/azure-container-networking/cns/types"
@@ -25,10 +27,22 @@ const (
 	DefaultMaxIPs = 250
 	// Subnet ARM ID /subscriptions/$(SUB)/resourceGroups/$(GROUP)/providers/Microsoft.Network/virtualNetworks/$(VNET)/subnets/$(SUBNET)
 	subnetARMIDTemplate = "/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s"
+	// DrainAnnotation on the NodeNetworkConfig requests that the IP pool of the node is drained, e.g. by a node cordon workflow
+	// which reclaims the subnet. Draining through the CNS API sets it too, so that the drain survives a CNS restart.
+	DrainAnnotation = "cns.azure.com/drain"
+	// DefaultReleaseConfirmationTimeout is how long a PendingRelease IP waits for the CNI to confirm that no endpoint uses it
+	// before it's released anyway, so that the pool still scales down without CNI commands on the node.
+	DefaultReleaseConfirmationTimeout = 5 * time.Minute
+	// DefaultPoolPressureThreshold is how long the pool must be unable to grow for new Pods before it is under
+	// sustained pressure.
+	DefaultPoolPressureThreshold = 2 * time.Minute
+	// DefaultReleaseStuckThreshold is how long an IP can be PendingRelease before its release is stuck.
+	DefaultReleaseStuckThreshold = 15 * time.Minute
 )
 
-type nodeNetworkConfigSpecUpdater interface {
+type nodeNetworkConfigUpdater interface {
 	UpdateSpec(context.Context, *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error)
+	SetAnnotation(ctx context.Context, annotation, value string) (*v1alpha.NodeNetworkConfig, error)
 }
 
 // metaState is the Monitor's configuration state for the IP pool.
@@ -43,32 +57,79 @@ type metaState struct {
 	subnet             string
 	subnetARMID        string
 	subnetCIDR         string
+	// ncSubnets are the subnets of the NCs by NC ID in subnet-per-namespace mode, nil otherwise.
+	ncSubnets map[string]subnetMeta
+	// families are the IP families of the pool when the NCs are dual-stack, each scaled on its own. It's nil when
+	// the pool has a single family, which is scaled as a whole.
+	families []cns.IPFamily
+}
+
+// subnetMeta identifies a subnet of the pool in the metrics.
+type subnetMeta struct {
+	subnet      string
+	subnetARMID string
+	subnetCIDR  string
 }
 
 type Options struct {
 	RefreshDelay time.Duration
 	MaxIPs       int64
+	// ReleaseConfirmation holds PendingRelease IPs back from the NNC until the CNI confirms that no endpoint
+	// uses them, or until the ReleaseConfirmationTimeout expires.
+	ReleaseConfirmation        bool
+	ReleaseConfirmationTimeout time.Duration
+	// ReportPoolPressure sets the IPPoolPressure of the NNC spec while the pool is under sustained pressure,
+	// which is always reported as a metric. The pool is under pressure once it has been unable to grow for new
+	// Pods for the PoolPressureThreshold.
+	ReportPoolPressure    bool
+	PoolPressureThreshold time.Duration
+	// Events, if set, emits Kubernetes Events on the Node while the pool is under sustained pressure, and while
+	// IPs have been PendingRelease for longer than the ReleaseStuckThreshold.
+	Events                *events.Recorder
+	ReleaseStuckThreshold time.Duration
 }
 
 type Monitor struct {
 	opts        *Options
 	spec        v1alpha.NodeNetworkConfigSpec
 	metastate   metaState
-	nnccli      nodeNetworkConfigSpecUpdater
+	nnccli      nodeNetworkConfigUpdater
 	httpService cns.HTTPService
 	cssSource   <-chan v1alpha1.ClusterSubnetState
 	nncSource   chan v1alpha.NodeNetworkConfig
 	started     chan interface{}
 	once        sync.Once
+	// draining is set by the DrainAnnotation of the NNC.
+	draining atomic.Bool
+	// release tracks the confirmations of PendingRelease IPs, see Options.ReleaseConfirmation.
+	release releaseState
+	// pressureSince is when the pool last became unable to grow for new Pods, zero if it can.
+	pressureSince time.Time
+}
+
+// releaseState tracks which PendingRelease IPs the CNI confirmed, and since when IPs have been waiting for it.
+type releaseState struct {
+	sync.Mutex
+	confirmed map[string]struct{}
+	firstSeen map[string]time.Time
 }
 
-func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater, cssSource <-chan v1alpha1.ClusterSubnetState, opts *Options) *Monitor {
+func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigUpdater, cssSource <-chan v1alpha1.ClusterSubnetState, opts *Options) *Monitor {
 	if opts.RefreshDelay < 1 {
 		opts.RefreshDelay = DefaultRefreshDelay
 	}
 	if opts.MaxIPs < 1 {
 		opts.MaxIPs = DefaultMaxIPs
 	}
+	if opts.ReleaseConfirmationTimeout < 1 {
+		opts.ReleaseConfirmationTimeout = DefaultReleaseConfirmationTimeout
+	}
+	if opts.PoolPressureThreshold < 1 {
+		opts.PoolPressureThreshold = DefaultPoolPressureThreshold
+	}
+	if opts.ReleaseStuckThreshold < 1 {
+		opts.ReleaseStuckThreshold = DefaultReleaseStuckThreshold
+	}
 	return &Monitor{
 		opts:        opts,
 		httpService: httpService,
@@ -76,6 +137,10 @@ func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater
 		cssSource:   cssSource,
 		nncSource:   make(chan v1alpha.NodeNetworkConfig),
 		started:     make(chan interface{}),
+		release: releaseState{
+			confirmed: map[string]struct{}{},
+			firstSeen: map[string]time.Time{},
+		},
 	}
 }
 
@@ -130,6 +195,17 @@ func (pm *Monitor) Start(ctx context.Context) error {
 				}
 			}
 
+			pm.metastate.families = ipFamiliesOf(nnc.Status.NetworkContainers)
+
+			pm.metastate.ncSubnets = nil
+			if len(nnc.Spec.NamespaceSubnets) > 0 {
+				pm.metastate.ncSubnets = make(map[string]subnetMeta, len(nnc.Status.NetworkContainers))
+				for i := range nnc.Status.NetworkContainers {
+					nc := &nnc.Status.NetworkContainers[i]
+					pm.metastate.ncSubnets[nc.ID] = subnetMeta{subnet: nc.SubnetName, subnetARMID: GenerateARMID(nc), subnetCIDR: nc.SubnetAddressSpace}
+				}
+			}
+
 			scaler := nnc.Status.Scaler
 			pm.metastate.batch = scaler.BatchSize
 			pm.metastate.max = scaler.MaxIPCount
@@ -139,6 +215,8 @@ func (pm *Monitor) Start(ctx context.Context) error {
 				logger.Printf("[ipam-pool-monitor] set initial pool spec %+v", pm.spec)
 				close(pm.started) // close the init channel the first time we fully receive a NodeNetworkConfig.
 			})
+			// the namespace subnets are set by the operator, the spec written by CNS keeps the latest ones.
+			pm.spec.NamespaceSubnets = nnc.Spec.NamespaceSubnets
 		}
 		// if control has flowed through the select(s) to this point, we can now reconcile.
 		err := pm.reconcile(ctx)
@@ -148,8 +226,10 @@ func (pm *Monitor) Start(ctx context.Context) error {
 	}
 }
 
-// ipPoolState is the current actual state of the CNS IP pool.
+// ipPoolState is the current actual state of the CNS IP pool, or of the IPs of one family of a dual-stack pool.
 type ipPoolState struct {
+	// family is the IP family of the IPs, empty for the whole pool.
+	family cns.IPFamily
 	// allocatedToPods are the IPs CNS gives to Pods.
 	allocatedToPods int64
 	// available are the IPs in state "Available".
@@ -169,12 +249,21 @@ type ipPoolState struct {
 }
 
 func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.NodeNetworkConfigSpec) ipPoolState {
+	return buildFamilyPoolState(ips, spec, "")
+}
+
+// buildFamilyPoolState builds the state of the IPs of the family in the pool, or of all IPs if the family is empty.
+func buildFamilyPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.NodeNetworkConfigSpec, family cns.IPFamily) ipPoolState { //nolint:gocritic // ignore hugeparam
 	state := ipPoolState{
-		totalIPs:     int64(len(ips)),
-		requestedIPs: spec.RequestedIPCount,
+		family:       family,
+		requestedIPs: requestedIPCount(&spec, family),
 	}
 	for i := range ips {
 		ip := ips[i]
+		if family != "" && cns.IPFamilyOf(ip.IPAddress) != family {
+			continue
+		}
+		state.totalIPs++
 		switch ip.GetState() {
 		case types.Assigned:
 			state.allocatedToPods++
@@ -191,6 +280,72 @@ func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.Nod
 	return state
 }
 
+// buildPoolStates builds the state of each IP family of a dual-stack pool, or of the whole pool if it has a single family.
+func buildPoolStates(ips map[string]cns.IPConfigurationStatus, spec v1alpha.NodeNetworkConfigSpec, families []cns.IPFamily) []ipPoolState { //nolint:gocritic // ignore hugeparam
+	if len(families) == 0 {
+		return []ipPoolState{buildIPPoolState(ips, spec)}
+	}
+	states := make([]ipPoolState, len(families))
+	for i, family := range families {
+		states[i] = buildFamilyPoolState(ips, spec, family)
+	}
+	return states
+}
+
+// ipFamiliesOf returns the IPv4 and IPv6 families if the subnets of the NCs are dual-stack, nil otherwise.
+func ipFamiliesOf(ncs []v1alpha.NetworkContainer) []cns.IPFamily {
+	var ipv4, ipv6 bool
+	for i := range ncs {
+		switch cns.IPFamilyOf(ncs[i].SubnetAddressSpace) {
+		case cns.IPv4Family:
+			ipv4 = true
+		case cns.IPv6Family:
+			ipv6 = true
+		}
+	}
+	if ipv4 && ipv6 {
+		return []cns.IPFamily{cns.IPv4Family, cns.IPv6Family}
+	}
+	return nil
+}
+
+// requestedIPCount returns the requested IP count of the family in the spec, or of the whole pool if it's empty.
+func requestedIPCount(spec *v1alpha.NodeNetworkConfigSpec, family cns.IPFamily) int64 {
+	switch family {
+	case cns.IPv4Family:
+		return spec.RequestedIPCount
+	case cns.IPv6Family:
+		return spec.RequestedIPv6Count
+	default:
+		return spec.RequestedIPCount + spec.RequestedIPv6Count
+	}
+}
+
+// setRequestedIPCount sets the requested IP count of the family in the spec. The count of a pool with a single
+// family is the RequestedIPCount.
+func setRequestedIPCount(spec *v1alpha.NodeNetworkConfigSpec, family cns.IPFamily, count int64) {
+	if family == cns.IPv6Family {
+		spec.RequestedIPv6Count = count
+		return
+	}
+	spec.RequestedIPCount = count
+}
+
+// buildSubnetPoolStates counts the IPs of the pool by state in each subnet of the NCs.
+func buildSubnetPoolStates(ips map[string]cns.IPConfigurationStatus, ncSubnets map[string]subnetMeta) map[subnetMeta]map[types.IPState]int64 {
+	states := make(map[subnetMeta]map[types.IPState]int64, len(ncSubnets))
+	for _, subnet := range ncSubnets {
+		states[subnet] = map[types.IPState]int64{types.Assigned: 0, types.Available: 0, types.PendingProgramming: 0, types.PendingRelease: 0}
+	}
+	for i := range ips {
+		ip := ips[i]
+		if subnet, ok := ncSubnets[ip.NCID]; ok {
+			states[subnet][ip.GetState()]++
+		}
+	}
+	return states
+}
+
 var statelogDownsample int
 
 func (pm *Monitor) reconcile(ctx context.Context) error {
@@ -198,6 +353,48 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 	meta := pm.metastate
 	state := buildIPPoolState(allocatedIPs, pm.spec)
 	observeIPPoolState(state, meta)
+	pools := buildPoolStates(allocatedIPs, pm.spec, meta.families)
+	if meta.families != nil {
+		for i := range pools {
+			observeFamilyPoolState(pools[i], meta)
+		}
+	}
+	if meta.ncSubnets != nil {
+		observeSubnetPoolStates(buildSubnetPoolStates(allocatedIPs, meta.ncSubnets))
+	}
+	pm.checkStuckReleases(allocatedIPs, time.Now())
+
+	if pm.IsDraining() {
+		return pm.drainPool(ctx, state)
+	}
+
+	// release the IPs which the CNI confirmed since the last reconcile
+	if pm.opts.ReleaseConfirmation {
+		if spec := pm.createNNCSpecForCRD(); len(pm.newlyReleased(spec)) > 0 {
+			logger.Printf("ipam-pool-monitor state %+v", state)
+			logger.Printf("[ipam-pool-monitor] Releasing confirmed Pending Release IPs...")
+			return pm.releaseConfirmedIPs(ctx, spec)
+		}
+	}
+
+	// the pool is under pressure if any of its families is
+	pressure := pools[0]
+	for i := range pools {
+		if isConstrained(pools[i], meta) {
+			pressure = pools[i]
+			break
+		}
+	}
+	sustained := pm.observePoolPressure(pressure, meta, time.Now())
+	if sustained {
+		pm.opts.Events.Warningf(events.ReasonIPPoolExhausted, "IP pool can't grow for new Pods: %d of max %d IPs requested, %d assigned, subnet exhausted %t",
+			pressure.requestedIPs, meta.max, pressure.allocatedToPods, meta.exhausted)
+	}
+	if report := pm.opts.ReportPoolPressure && sustained; report != pm.spec.IPPoolPressure {
+		logger.Printf("ipam-pool-monitor state %+v", state)
+		logger.Printf("[ipam-pool-monitor] Reporting IP pool pressure %t...", report)
+		return pm.reportPoolPressure(ctx, report)
+	}
 
 	// log every 30th reconcile to reduce the AI load. we will always log when the monitor
 	// changes the pool, below.
@@ -212,26 +409,36 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 		meta.maxFreeCount = 2
 	}
 
-	switch {
-	// pod count is increasing
-	case state.expectedAvailableIPs < meta.minFreeCount:
-		if state.requestedIPs == meta.max {
-			// If we're already at the maxIPCount, don't try to increase
-			return nil
+	// the pool of each family is scaled on its own, with the same batch and thresholds
+	atMax := false
+	for _, pool := range pools {
+		switch {
+		// pod count is increasing
+		case pool.expectedAvailableIPs < meta.minFreeCount:
+			if pool.requestedIPs == meta.max {
+				// If we're already at the maxIPCount, don't try to increase
+				atMax = true
+				continue
+			}
+			logger.Printf("ipam-pool-monitor state %+v", pool)
+			logger.Printf("[ipam-pool-monitor] Increasing pool size...")
+			return pm.increasePoolSize(ctx, meta, pool)
+
+		// pod count is decreasing
+		case pool.currentAvailableIPs >= meta.maxFreeCount:
+			logger.Printf("ipam-pool-monitor state %+v", pool)
+			logger.Printf("[ipam-pool-monitor] Decreasing pool size...")
+			return pm.decreasePoolSize(ctx, meta, pool)
 		}
-		logger.Printf("ipam-pool-monitor state %+v", state)
-		logger.Printf("[ipam-pool-monitor] Increasing pool size...")
-		return pm.increasePoolSize(ctx, meta, state)
-
-	// pod count is decreasing
-	case state.currentAvailableIPs >= meta.maxFreeCount:
-		logger.Printf("ipam-pool-monitor state %+v", state)
-		logger.Printf("[ipam-pool-monitor] Decreasing pool size...")
-		return pm.decreasePoolSize(ctx, meta, state)
+	}
+	if atMax {
+		return nil
+	}
 
+	switch {
 	// CRD has reconciled CNS state, and target spec is now the same size as the state
 	// free to remove the IPs from the CRD
-	case int64(len(pm.spec.IPsNotInUse)) != state.pendingRelease:
+	case int64(len(pm.spec.IPsNotInUse)) != pm.releasableCount(state):
 		logger.Printf("ipam-pool-monitor state %+v", state)
 		logger.Printf("[ipam-pool-monitor] Removing Pending Release IPs from CRD...")
 		return pm.cleanPendingRelease(ctx)
@@ -250,25 +457,26 @@ func (pm *Monitor) increasePoolSize(ctx context.Context, meta metaState, state i
 	tempNNCSpec := pm.createNNCSpecForCRD()
 
 	// Query the max IP count
-	previouslyRequestedIPCount := tempNNCSpec.RequestedIPCount
+	previouslyRequestedIPCount := requestedIPCount(&tempNNCSpec, state.family)
 	batchSize := meta.batch
 	modResult := previouslyRequestedIPCount % batchSize
 	logger.Printf("[ipam-pool-monitor] Previously RequestedIP Count %d", previouslyRequestedIPCount)
 	logger.Printf("[ipam-pool-monitor] Batch size : %d", batchSize)
 	logger.Printf("[ipam-pool-monitor] modResult of (previously requested IP count mod batch size) = %d", modResult)
 
-	tempNNCSpec.RequestedIPCount += batchSize - modResult
-	if tempNNCSpec.RequestedIPCount > meta.max {
+	updatedRequestedIPCount := previouslyRequestedIPCount + batchSize - modResult
+	if updatedRequestedIPCount > meta.max {
 		// We don't want to ask for more ips than the max
-		logger.Printf("[ipam-pool-monitor] Requested IP count (%d) is over max limit (%d), requesting max limit instead.", tempNNCSpec.RequestedIPCount, meta.max)
-		tempNNCSpec.RequestedIPCount = meta.max
+		logger.Printf("[ipam-pool-monitor] Requested IP count (%d) is over max limit (%d), requesting max limit instead.", updatedRequestedIPCount, meta.max)
+		updatedRequestedIPCount = meta.max
 	}
 
 	// If the requested IP count is same as before, then don't do anything
-	if tempNNCSpec.RequestedIPCount == previouslyRequestedIPCount {
-		logger.Printf("[ipam-pool-monitor] Previously requested IP count %d is same as updated IP count %d, doing nothing", previouslyRequestedIPCount, tempNNCSpec.RequestedIPCount)
+	if updatedRequestedIPCount == previouslyRequestedIPCount {
+		logger.Printf("[ipam-pool-monitor] Previously requested IP count %d is same as updated IP count %d, doing nothing", previouslyRequestedIPCount, updatedRequestedIPCount)
 		return nil
 	}
+	setRequestedIPCount(&tempNNCSpec, state.family, updatedRequestedIPCount)
 
 	logger.Printf("[ipam-pool-monitor] Increasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
@@ -292,7 +500,7 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	var updatedRequestedIPCount int64
 
 	// Ensure the updated requested IP count is a multiple of the batch size
-	previouslyRequestedIPCount := pm.spec.RequestedIPCount
+	previouslyRequestedIPCount := requestedIPCount(&pm.spec, state.family)
 	batchSize := meta.batch
 	modResult := previouslyRequestedIPCount % batchSize
 	logger.Printf("[ipam-pool-monitor] Previously RequestedIP Count %d", previouslyRequestedIPCount)
@@ -315,13 +523,19 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	if meta.notInUseCount == 0 || meta.notInUseCount < state.pendingRelease {
 		logger.Printf("[ipam-pool-monitor] Marking IPs as PendingRelease, ipsToBeReleasedCount %d", decreaseIPCountBy)
 		var err error
-		if pendingIPAddresses, err = pm.httpService.MarkIPAsPendingRelease(int(decreaseIPCountBy)); err != nil {
+		if pendingIPAddresses, err = pm.httpService.MarkIPFamilyAsPendingRelease(int(decreaseIPCountBy), state.family); err != nil {
 			return errors.Wrap(err, "marking IPs that are pending release")
 		}
 
 		newIpsMarkedAsPending = true
 	}
 
+	if pm.opts.ReleaseConfirmation {
+		// the IPs are removed from the NNC spec once the CNI confirms that no endpoint uses them, see releaseConfirmedIPs
+		logger.Printf("[ipam-pool-monitor] Marked %d IPs as PendingRelease, waiting for the CNI to confirm them", len(pendingIPAddresses))
+		return nil
+	}
+
 	tempNNCSpec := pm.createNNCSpecForCRD()
 
 	if newIpsMarkedAsPending {
@@ -332,7 +546,7 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	logger.Printf("[ipam-pool-monitor] Releasing IPCount in this batch %d, updatingPendingIpsNotInUse count %d",
 		len(pendingIPAddresses), pm.metastate.notInUseCount)
 
-	tempNNCSpec.RequestedIPCount -= int64(len(pendingIPAddresses))
+	setRequestedIPCount(&tempNNCSpec, state.family, requestedIPCount(&tempNNCSpec, state.family)-int64(len(pendingIPAddresses)))
 	logger.Printf("[ipam-pool-monitor] Decreasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
 	attempts := 0
@@ -364,10 +578,130 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, meta metaState, state i
 	return nil
 }
 
+// drainPool releases the whole pool back to DNC once no IPs are assigned to Pods.
+// CNS doesn't assign IPs while draining, so the assigned IPs only decrease as Pods are deleted.
+func (pm *Monitor) drainPool(ctx context.Context, state ipPoolState) error {
+	switch {
+	// wait for the Pods to be deleted
+	case state.allocatedToPods > 0:
+		return nil
+
+	case requestedIPCount(&pm.spec, "") > 0:
+		if unreleased := state.available + state.pendingProgramming; unreleased > 0 {
+			if _, err := pm.httpService.MarkIPAsPendingRelease(int(unreleased)); err != nil {
+				return errors.Wrap(err, "marking IPs that are pending release")
+			}
+		}
+
+		tempNNCSpec := pm.createNNCSpecForCRD()
+		tempNNCSpec.RequestedIPCount = 0
+		tempNNCSpec.RequestedIPv6Count = 0
+		logger.Printf("[ipam-pool-monitor] Draining pool, pool %+v, spec %+v", state, tempNNCSpec)
+		if _, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec); err != nil {
+			// the IPs stay PendingRelease, so the next reconcile retries with the same spec
+			return errors.Wrap(err, "executing UpdateSpec with NNC client")
+		}
+		logger.Printf("[ipam-pool-monitor] Draining pool: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
+		pm.spec = tempNNCSpec
+		return nil
+
+	// CRD has reconciled CNS state, free to remove the released IPs from the CRD
+	case int64(len(pm.spec.IPsNotInUse)) != pm.releasableCount(state):
+		return pm.cleanPendingRelease(ctx)
+	}
+
+	return nil
+}
+
+// observePoolPressure tracks whether the pool needs more IPs for new Pods but can't grow, because it's at the
+// max IP count or the subnet is exhausted, and returns whether that has lasted for the PoolPressureThreshold.
+func (pm *Monitor) observePoolPressure(state ipPoolState, meta metaState, now time.Time) bool {
+	constrained := isConstrained(state, meta)
+	switch {
+	case !constrained:
+		pm.pressureSince = time.Time{}
+	case pm.pressureSince.IsZero():
+		pm.pressureSince = now
+	}
+	sustained := constrained && now.Sub(pm.pressureSince) >= pm.opts.PoolPressureThreshold
+	observeIPPoolPressure(sustained, meta)
+	return sustained
+}
+
+// isConstrained returns whether the pool needs more IPs for new Pods but can't grow.
+func isConstrained(state ipPoolState, meta metaState) bool {
+	return state.expectedAvailableIPs < meta.minFreeCount && (state.requestedIPs >= meta.max || meta.exhausted)
+}
+
+// checkStuckReleases emits an Event if IPs have been PendingRelease for longer than the ReleaseStuckThreshold,
+// e.g. because the NNC isn't reconciled or the CNI never confirms their release.
+func (pm *Monitor) checkStuckReleases(ips map[string]cns.IPConfigurationStatus, now time.Time) {
+	if pm.opts.Events == nil {
+		return
+	}
+	stuck := 0
+	var oldest time.Time
+	for i := range ips {
+		ip := ips[i]
+		if ip.GetState() != types.PendingRelease || ip.LastStateTransition.IsZero() || now.Sub(ip.LastStateTransition) < pm.opts.ReleaseStuckThreshold {
+			continue
+		}
+		stuck++
+		if oldest.IsZero() || ip.LastStateTransition.Before(oldest) {
+			oldest = ip.LastStateTransition
+		}
+	}
+	if stuck > 0 {
+		pm.opts.Events.Warningf(events.ReasonIPReleaseStuck, "%d IPs have been pending release for longer than %s, the oldest for %s",
+			stuck, pm.opts.ReleaseStuckThreshold, now.Sub(oldest).Round(time.Second))
+	}
+}
+
+// reportPoolPressure sets the IPPoolPressure of the NNC spec.
+func (pm *Monitor) reportPoolPressure(ctx context.Context, pressure bool) error {
+	tempNNCSpec := pm.createNNCSpecForCRD()
+	tempNNCSpec.IPPoolPressure = pressure
+	if pm.opts.ReleaseConfirmation && len(pm.newlyReleased(tempNNCSpec)) > 0 {
+		return pm.releaseConfirmedIPs(ctx, tempNNCSpec)
+	}
+
+	if _, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec); err != nil {
+		// caller will retry to update the CRD again
+		return errors.Wrap(err, "executing UpdateSpec with NNC client")
+	}
+
+	logger.Printf("[ipam-pool-monitor] reportPoolPressure: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
+	pm.spec = tempNNCSpec
+	return nil
+}
+
+// SetDraining starts or stops draining the pool by setting or removing the DrainAnnotation of the NNC. While draining,
+// CNS doesn't assign IPs to Pods and the pool is released once no IPs are assigned.
+func (pm *Monitor) SetDraining(ctx context.Context, drain bool) error {
+	value := ""
+	if drain {
+		value = strconv.FormatBool(drain)
+	}
+	if _, err := pm.nnccli.SetAnnotation(ctx, DrainAnnotation, value); err != nil {
+		return errors.Wrapf(err, "setting %s annotation", DrainAnnotation)
+	}
+	pm.draining.Store(drain)
+	return nil
+}
+
+// IsDraining returns whether the pool is being drained.
+func (pm *Monitor) IsDraining() bool {
+	return pm.draining.Load()
+}
+
 // cleanPendingRelease removes IPs from the cache and CRD if the request controller has reconciled
 // CNS state and the pending IP release map is empty.
 func (pm *Monitor) cleanPendingRelease(ctx context.Context) error {
 	tempNNCSpec := pm.createNNCSpecForCRD()
+	if pm.opts.ReleaseConfirmation && len(pm.newlyReleased(tempNNCSpec)) > 0 {
+		// IPs were confirmed since the spec was last checked, so the requested IP count goes down too
+		return pm.releaseConfirmedIPs(ctx, tempNNCSpec)
+	}
 
 	_, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec)
 	if err != nil {
@@ -382,20 +716,155 @@ func (pm *Monitor) cleanPendingRelease(ctx context.Context) error {
 	return nil
 }
 
+// releaseConfirmedIPs removes the newly confirmed PendingRelease IPs in the spec from the requested IP count.
+func (pm *Monitor) releaseConfirmedIPs(ctx context.Context, tempNNCSpec v1alpha.NodeNetworkConfigSpec) error {
+	released := pm.newlyReleased(tempNNCSpec)
+	for family, count := range released {
+		requested := requestedIPCount(&tempNNCSpec, family) - count
+		if requested < 0 {
+			requested = 0
+		}
+		setRequestedIPCount(&tempNNCSpec, family, requested)
+	}
+	logger.Printf("[ipam-pool-monitor] Releasing %v confirmed IPs, spec %+v", released, tempNNCSpec)
+
+	if _, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec); err != nil {
+		// the IPs stay confirmed, so the next reconcile retries with the same spec
+		return errors.Wrap(err, "executing UpdateSpec with NNC client")
+	}
+
+	logger.Printf("[ipam-pool-monitor] Releasing confirmed IPs: UpdateCRDSpec succeeded for spec %+v", tempNNCSpec)
+	metric.StartPoolDecreaseTimer(pm.metastate.batch)
+	pm.spec = tempNNCSpec
+	return nil
+}
+
+// newlyReleased returns the number of IPs not in use in the spec which aren't in the cached spec, by the IP family
+// of the pool they are in.
+func (pm *Monitor) newlyReleased(spec v1alpha.NodeNetworkConfigSpec) map[cns.IPFamily]int64 {
+	released := make(map[string]struct{}, len(pm.spec.IPsNotInUse))
+	for _, id := range pm.spec.IPsNotInUse {
+		released[id] = struct{}{}
+	}
+	var ips map[string]cns.IPConfigurationStatus
+	if pm.metastate.families != nil {
+		ips = pm.httpService.GetPodIPConfigState()
+	}
+	counts := map[cns.IPFamily]int64{}
+	for _, id := range spec.IPsNotInUse {
+		if _, ok := released[id]; ok {
+			continue
+		}
+		var family cns.IPFamily
+		if pm.metastate.families != nil {
+			ip, ok := ips[id]
+			if !ok {
+				// the family of an IP which CNS doesn't have anymore is unknown, it isn't counted in any pool
+				continue
+			}
+			family = cns.IPFamilyOf(ip.IPAddress)
+		}
+		counts[family]++
+	}
+	return counts
+}
+
+// releasableCount returns the number of PendingRelease IPs which can be in the NNC spec.
+func (pm *Monitor) releasableCount(state ipPoolState) int64 {
+	if !pm.opts.ReleaseConfirmation {
+		return state.pendingRelease
+	}
+	return int64(len(pm.createNNCSpecForCRD().IPsNotInUse))
+}
+
+// ConfirmRelease records that no endpoint on the node uses the PendingRelease IPs with the IDs.
+func (pm *Monitor) ConfirmRelease(ipConfigIDs []string) {
+	pm.release.Lock()
+	defer pm.release.Unlock()
+	for _, id := range ipConfigIDs {
+		pm.release.confirmed[id] = struct{}{}
+	}
+}
+
+// IsReleaseConfirmed returns whether no endpoint on the node uses the PendingRelease IP with the ID.
+func (pm *Monitor) IsReleaseConfirmed(ipConfigID string) bool {
+	pm.release.Lock()
+	defer pm.release.Unlock()
+	_, ok := pm.release.confirmed[ipConfigID]
+	return ok
+}
+
+// isReleasable returns whether the PendingRelease IP can be released from the NNC: it's already released,
+// it's confirmed, or it waited for a confirmation longer than the ReleaseConfirmationTimeout.
+// The lock must be held by the caller.
+func (pm *Monitor) isReleasable(ipConfigID string, released map[string]struct{}, now time.Time) bool {
+	if _, ok := released[ipConfigID]; ok {
+		return true
+	}
+	if _, ok := pm.release.confirmed[ipConfigID]; ok {
+		return true
+	}
+	firstSeen, ok := pm.release.firstSeen[ipConfigID]
+	if !ok {
+		pm.release.firstSeen[ipConfigID] = now
+		return false
+	}
+	if now.Sub(firstSeen) < pm.opts.ReleaseConfirmationTimeout {
+		return false
+	}
+	logger.Printf("[ipam-pool-monitor] Releasing IP %s without confirmation after %s", ipConfigID, pm.opts.ReleaseConfirmationTimeout)
+	return true
+}
+
 // createNNCSpecForCRD translates CNS's map of IPs to be released and requested IP count into an NNC Spec.
+// The NamespaceSubnets set by the operator are kept as is.
+// With ReleaseConfirmation, only the PendingRelease IPs which are releasable are in the spec.
 func (pm *Monitor) createNNCSpecForCRD() v1alpha.NodeNetworkConfigSpec {
 	var spec v1alpha.NodeNetworkConfigSpec
 
-	// Update the count from cached spec
+	// Update the count and pressure from cached spec
 	spec.RequestedIPCount = pm.spec.RequestedIPCount
+	spec.RequestedIPv6Count = pm.spec.RequestedIPv6Count
+	spec.IPPoolPressure = pm.spec.IPPoolPressure
+	spec.NamespaceSubnets = pm.spec.NamespaceSubnets
 
 	// Get All Pending IPs from CNS and populate it again.
 	pendingIPs := pm.httpService.GetPendingReleaseIPConfigs()
+	if !pm.opts.ReleaseConfirmation {
+		for i := range pendingIPs {
+			pendingIP := pendingIPs[i]
+			spec.IPsNotInUse = append(spec.IPsNotInUse, pendingIP.ID)
+		}
+		return spec
+	}
+
+	released := make(map[string]struct{}, len(pm.spec.IPsNotInUse))
+	for _, id := range pm.spec.IPsNotInUse {
+		released[id] = struct{}{}
+	}
+	pending := make(map[string]struct{}, len(pendingIPs))
+	now := time.Now()
+
+	pm.release.Lock()
+	defer pm.release.Unlock()
 	for i := range pendingIPs {
 		pendingIP := pendingIPs[i]
-		spec.IPsNotInUse = append(spec.IPsNotInUse, pendingIP.ID)
+		pending[pendingIP.ID] = struct{}{}
+		if pm.isReleasable(pendingIP.ID, released, now) {
+			spec.IPsNotInUse = append(spec.IPsNotInUse, pendingIP.ID)
+		}
+	}
+	// forget the IPs which aren't PendingRelease anymore
+	for id := range pm.release.confirmed {
+		if _, ok := pending[id]; !ok {
+			delete(pm.release.confirmed, id)
+		}
+	}
+	for id := range pm.release.firstSeen {
+		if _, ok := pending[id]; !ok {
+			delete(pm.release.firstSeen, id)
+		}
 	}
-
 	return spec
 }
 
@@ -435,9 +904,14 @@ func GenerateARMID(nc *v1alpha.NetworkContainer) string {
 func (pm *Monitor) Update(nnc *v1alpha.NodeNetworkConfig) error {
 	pm.clampScaler(&nnc.Status.Scaler)
 
+	drain, _ := strconv.ParseBool(nnc.Annotations[DrainAnnotation])
+	if pm.draining.Swap(drain) != drain {
+		logger.Printf("[ipam-pool-monitor] %s annotation set to %t", DrainAnnotation, drain)
+	}
+
 	// if the nnc has converged, observe the pool scaling latency (if any).
 	allocatedIPs := len(pm.httpService.GetPodIPConfigState()) - len(pm.httpService.GetPendingReleaseIPConfigs())
-	if int(nnc.Spec.RequestedIPCount) == allocatedIPs {
+	if int(requestedIPCount(&nnc.Spec, "")) == allocatedIPs {
 		// observe elapsed duration for IP pool scaling
 		metric.ObserverPoolScaleLatency()
 	}
@@ -3,14 +3,20 @@ package ipampool
 import (
 	"context"
 	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/events"
 	"github.com/Azure/azure-container-networking/cns/fakes"
 	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 type fakeNodeNetworkConfigUpdater struct {
@@ -22,12 +28,28 @@ func (f *fakeNodeNetworkConfigUpdater) UpdateSpec(ctx context.Context, spec *v1a
 	return f.nnc, nil
 }
 
+func (f *fakeNodeNetworkConfigUpdater) SetAnnotation(ctx context.Context, annotation, value string) (*v1alpha.NodeNetworkConfig, error) {
+	if value == "" {
+		delete(f.nnc.Annotations, annotation)
+		return f.nnc, nil
+	}
+	if f.nnc.Annotations == nil {
+		f.nnc.Annotations = map[string]string{}
+	}
+	f.nnc.Annotations[annotation] = value
+	return f.nnc, nil
+}
+
 type fakeNodeNetworkConfigUpdaterFunc func(ctx context.Context, spec *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error)
 
 func (f fakeNodeNetworkConfigUpdaterFunc) UpdateSpec(ctx context.Context, spec *v1alpha.NodeNetworkConfigSpec) (*v1alpha.NodeNetworkConfig, error) {
 	return f(ctx, spec)
 }
 
+func (f fakeNodeNetworkConfigUpdaterFunc) SetAnnotation(ctx context.Context, _, _ string) (*v1alpha.NodeNetworkConfig, error) {
+	return f(ctx, nil)
+}
+
 type directUpdatePoolMonitor struct {
 	m *Monitor
 	cns.IPAMPoolMonitor
@@ -52,7 +74,7 @@ type testState struct {
 	totalIPs                int64
 }
 
-func initFakes(state testState, nnccli nodeNetworkConfigSpecUpdater) (*fakes.HTTPServiceFake, *fakes.RequestControllerFake, *Monitor) {
+func initFakes(state testState, nnccli nodeNetworkConfigUpdater) (*fakes.HTTPServiceFake, *fakes.RequestControllerFake, *Monitor) {
 	logger.InitLogger("testlogs", 0, 0, "./")
 
 	scalarUnits := v1alpha.Scaler{
@@ -557,6 +579,187 @@ func TestDecreaseWithPendingRelease(t *testing.T) {
 	assert.Len(t, poolmonitor.spec.IPsNotInUse, int(initState.batch)+int(initState.pendingRelease))
 }
 
+func TestDrainPool(t *testing.T) {
+	initState := testState{
+		batch:                   10,
+		assigned:                5,
+		allocated:               20,
+		requestThresholdPercent: 50,
+		releaseThresholdPercent: 150,
+		max:                     30,
+	}
+	fakecns, fakerc, poolmonitor := initFakes(initState, nil)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	require.NoError(t, poolmonitor.SetDraining(context.Background(), true))
+	assert.True(t, poolmonitor.IsDraining())
+	// the drain is persisted in the NNC, so that it survives a CNS restart
+	assert.Equal(t, "true", fakerc.NNC.Annotations[DrainAnnotation])
+
+	// the pool isn't released while IPs are assigned
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, initState.allocated, poolmonitor.spec.RequestedIPCount)
+	assert.Empty(t, poolmonitor.spec.IPsNotInUse)
+
+	// the whole pool is released once the Pods are gone
+	assert.NoError(t, fakecns.SetNumberOfAssignedIPs(0))
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 0, poolmonitor.spec.RequestedIPCount)
+	assert.Len(t, poolmonitor.spec.IPsNotInUse, int(initState.allocated))
+
+	// DNC removes the IPs, and the released IPs are then removed from the spec
+	assert.NoError(t, fakerc.Reconcile(true))
+	assert.Empty(t, fakecns.GetPodIPConfigState())
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Empty(t, poolmonitor.spec.IPsNotInUse)
+
+	// the pool scales up again once the drain is stopped
+	require.NoError(t, poolmonitor.SetDraining(context.Background(), false))
+	assert.NotContains(t, fakerc.NNC.Annotations, DrainAnnotation)
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, initState.batch, poolmonitor.spec.RequestedIPCount)
+}
+
+func TestPoolDecreaseWithReleaseConfirmation(t *testing.T) {
+	initState := testState{
+		allocated:               20,
+		assigned:                15,
+		batch:                   10,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	fakecns, fakerc, poolmonitor := initFakes(initState, nil)
+	poolmonitor.opts.ReleaseConfirmation = true
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	// the IPs are marked PendingRelease, but stay in the NNC until the CNI confirms them
+	assert.NoError(t, fakecns.SetNumberOfAssignedIPs(5))
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	pendingIPs := fakecns.GetPendingReleaseIPConfigs()
+	assert.Len(t, pendingIPs, 10)
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, initState.allocated, poolmonitor.spec.RequestedIPCount)
+	assert.Empty(t, poolmonitor.spec.IPsNotInUse)
+
+	// only the confirmed IPs are released
+	poolmonitor.ConfirmRelease([]string{pendingIPs[0].ID, pendingIPs[1].ID, pendingIPs[2].ID, pendingIPs[3].ID})
+	assert.True(t, poolmonitor.IsReleaseConfirmed(pendingIPs[0].ID))
+	assert.False(t, poolmonitor.IsReleaseConfirmed(pendingIPs[4].ID))
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 16, poolmonitor.spec.RequestedIPCount)
+	assert.Len(t, poolmonitor.spec.IPsNotInUse, 4)
+
+	ids := make([]string, 0, len(pendingIPs))
+	for i := range pendingIPs {
+		ids = append(ids, pendingIPs[i].ID)
+	}
+	poolmonitor.ConfirmRelease(ids)
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 10, poolmonitor.spec.RequestedIPCount)
+	assert.Len(t, poolmonitor.spec.IPsNotInUse, 10)
+
+	// DNC removes the IPs, and the confirmations are forgotten
+	assert.NoError(t, fakerc.Reconcile(true))
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Empty(t, poolmonitor.spec.IPsNotInUse)
+	assert.False(t, poolmonitor.IsReleaseConfirmed(pendingIPs[0].ID))
+}
+
+func TestReleaseConfirmationTimeout(t *testing.T) {
+	initState := testState{
+		allocated:               20,
+		assigned:                5,
+		batch:                   10,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	_, fakerc, poolmonitor := initFakes(initState, nil)
+	poolmonitor.opts.ReleaseConfirmation = true
+	poolmonitor.opts.ReleaseConfirmationTimeout = time.Nanosecond
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	// the first reconcile marks the IPs, and they're released without confirmation once the timeout expires
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, initState.allocated, poolmonitor.spec.RequestedIPCount)
+	assert.Eventually(t, func() bool {
+		_ = poolmonitor.reconcile(context.Background())
+		return poolmonitor.spec.RequestedIPCount == 10
+	}, time.Second, time.Millisecond)
+	assert.Len(t, poolmonitor.spec.IPsNotInUse, 10)
+}
+
+func TestPoolPressure(t *testing.T) {
+	initState := testState{
+		allocated:               30,
+		assigned:                28,
+		batch:                   10,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	fakecns, fakerc, poolmonitor := initFakes(initState, nil)
+	poolmonitor.opts.ReportPoolPressure = true
+	poolmonitor.opts.PoolPressureThreshold = time.Nanosecond
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	// the pool is at the max IP count and can't grow for new Pods
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Eventually(t, func() bool {
+		_ = poolmonitor.reconcile(context.Background())
+		return poolmonitor.spec.IPPoolPressure
+	}, time.Second, time.Millisecond)
+	assert.True(t, fakerc.NNC.Spec.IPPoolPressure)
+	assert.EqualValues(t, initState.max, poolmonitor.spec.RequestedIPCount)
+
+	// Pods are deleted and the pressure is gone
+	assert.NoError(t, fakecns.SetNumberOfAssignedIPs(20))
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.False(t, poolmonitor.spec.IPPoolPressure)
+	assert.False(t, fakerc.NNC.Spec.IPPoolPressure)
+}
+
+func TestPoolPressureThreshold(t *testing.T) {
+	initState := testState{
+		allocated:               30,
+		assigned:                28,
+		batch:                   10,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	_, fakerc, poolmonitor := initFakes(initState, nil)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	state := buildIPPoolState(poolmonitor.httpService.GetPodIPConfigState(), poolmonitor.spec)
+	now := time.Now()
+	assert.False(t, poolmonitor.observePoolPressure(state, poolmonitor.metastate, now))
+	assert.False(t, poolmonitor.observePoolPressure(state, poolmonitor.metastate, now.Add(time.Minute)))
+	assert.True(t, poolmonitor.observePoolPressure(state, poolmonitor.metastate, now.Add(DefaultPoolPressureThreshold)))
+	// pressure isn't reported in the NNC unless enabled
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.False(t, poolmonitor.spec.IPPoolPressure)
+}
+
+func TestDrainAnnotation(t *testing.T) {
+	_, fakerc, poolmonitor := initFakes(testState{batch: 10, allocated: 10, max: 30}, nil)
+	go func() {
+		for range poolmonitor.nncSource { //nolint:revive // drain the channel
+		}
+	}()
+	defer close(poolmonitor.nncSource)
+
+	nnc := fakerc.NNC.DeepCopy()
+	nnc.Annotations = map[string]string{DrainAnnotation: "true"}
+	assert.NoError(t, poolmonitor.Update(nnc))
+	assert.True(t, poolmonitor.IsDraining())
+
+	nnc.Annotations = nil
+	assert.NoError(t, poolmonitor.Update(nnc))
+	assert.False(t, poolmonitor.IsDraining())
+}
+
 func TestDecreaseWithAPIServerFailure(t *testing.T) {
 	initState := testState{
 		batch:                   16,
@@ -672,3 +875,103 @@ func TestCalculateIPs(t *testing.T) {
 		})
 	}
 }
+
+func TestReleaseStuckEvent(t *testing.T) {
+	initState := testState{
+		allocated:               20,
+		assigned:                5,
+		batch:                   10,
+		max:                     30,
+		releaseThresholdPercent: 150,
+		requestThresholdPercent: 50,
+	}
+	_, fakerc, poolmonitor := initFakes(initState, nil)
+	fakeRecorder := record.NewFakeRecorder(10)
+	poolmonitor.opts.Events = events.NewRecorder(fakeRecorder, &corev1.Node{}, time.Minute)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	// the pool is decreased and the released IPs are PendingRelease until the NNC is reconciled
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	ips := poolmonitor.httpService.GetPodIPConfigState()
+	poolmonitor.checkStuckReleases(ips, time.Now())
+	assert.Empty(t, fakeRecorder.Events)
+
+	poolmonitor.checkStuckReleases(ips, time.Now().Add(DefaultReleaseStuckThreshold))
+	require.Len(t, fakeRecorder.Events, 1)
+	assert.Contains(t, <-fakeRecorder.Events, "Warning IPReleaseStuck 10 IPs have been pending release")
+}
+
+func TestNamespaceSubnets(t *testing.T) {
+	initState := testState{
+		batch:                   10,
+		assigned:                8,
+		allocated:               10,
+		requestThresholdPercent: 50,
+		releaseThresholdPercent: 150,
+		max:                     30,
+	}
+	fakecns, fakerc, poolmonitor := initFakes(initState, nil)
+	assert.NoError(t, fakerc.Reconcile(true))
+	namespaceSubnets := []v1alpha.NamespaceSubnet{{SubnetName: "team-a", Namespaces: []string{"a"}}}
+	poolmonitor.spec.NamespaceSubnets = namespaceSubnets
+
+	// the namespace subnets set by the operator are kept when the pool grows
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Equal(t, namespaceSubnets, fakerc.NNC.Spec.NamespaceSubnets)
+
+	ips := fakecns.GetPodIPConfigState()
+	var ncID string
+	for i := range ips {
+		ncID = ips[i].NCID
+		break
+	}
+	teamA := subnetMeta{subnet: "team-a", subnetCIDR: "10.0.0.0/24"}
+	states := buildSubnetPoolStates(ips, map[string]subnetMeta{ncID: teamA, "other-nc": {subnet: "other"}})
+	assert.Equal(t, map[types.IPState]int64{types.Assigned: 8, types.Available: 2, types.PendingProgramming: 0, types.PendingRelease: 0}, states[teamA])
+	assert.Equal(t, int64(0), states[subnetMeta{subnet: "other"}][types.Assigned])
+}
+
+func TestDualStackPools(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	fakecns := fakes.NewHTTPServiceFake()
+	var ipconfigs []cns.IPConfigurationStatus
+	addIPs := func(prefix string, count, assigned int) {
+		for i := 0; i < count; i++ {
+			ipconfig := cns.IPConfigurationStatus{ID: prefix + strconv.Itoa(i), IPAddress: prefix + strconv.Itoa(i+1)}
+			ipconfig.SetState(types.Available)
+			if i < assigned {
+				ipconfig.SetState(types.Assigned)
+			}
+			ipconfigs = append(ipconfigs, ipconfig)
+		}
+	}
+	// the IPv4 pool needs more IPs, while most IPv6 IPs are free
+	addIPs("10.0.0.", 10, 8)
+	addIPs("fd00::", 20, 2)
+	fakecns.IPStateManager.AddIPConfigs(ipconfigs)
+
+	ncs := []v1alpha.NetworkContainer{{SubnetAddressSpace: "10.0.0.0/24"}, {SubnetAddressSpace: "fd00::/64"}}
+	fakeUpdater := &fakeNodeNetworkConfigUpdater{&v1alpha.NodeNetworkConfig{}}
+	poolmonitor := NewMonitor(fakecns, fakeUpdater, nil, &Options{RefreshDelay: 100 * time.Second})
+	poolmonitor.metastate = metaState{batch: 10, max: 30, minFreeCount: 5, maxFreeCount: 15, families: ipFamiliesOf(ncs)}
+	poolmonitor.spec = v1alpha.NodeNetworkConfigSpec{RequestedIPCount: 10, RequestedIPv6Count: 20}
+	require.Equal(t, []cns.IPFamily{cns.IPv4Family, cns.IPv6Family}, poolmonitor.metastate.families)
+
+	// the IPv4 pool grows on its own
+	require.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 20, fakeUpdater.nnc.Spec.RequestedIPCount)
+	assert.EqualValues(t, 20, fakeUpdater.nnc.Spec.RequestedIPv6Count)
+
+	// then the IPv6 pool shrinks, only releasing IPv6 IPs
+	require.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.EqualValues(t, 20, fakeUpdater.nnc.Spec.RequestedIPCount)
+	assert.EqualValues(t, 10, fakeUpdater.nnc.Spec.RequestedIPv6Count)
+	assert.Len(t, fakeUpdater.nnc.Spec.IPsNotInUse, 10)
+	for _, ip := range fakecns.GetPendingReleaseIPConfigs() {
+		assert.Equal(t, cns.IPv6Family, cns.IPFamilyOf(ip.IPAddress))
+	}
+
+	// a single stack pool is scaled as a whole
+	assert.Nil(t, ipFamiliesOf(ncs[:1]))
+	assert.EqualValues(t, 30, requestedIPCount(&poolmonitor.spec, ""))
+}
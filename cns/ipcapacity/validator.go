@@ -0,0 +1,216 @@
+// Package ipcapacity provides a validating admission webhook which rejects, or warns on, Pods placed on Nodes whose
+// IP pool is exhausted and can't grow, using the NodeNetworkConfigs published for CNS.
+package ipcapacity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// PodNodeNameIndex is the field index of Pods by Node which the client of the Validator must have.
+	PodNodeNameIndex = "spec.nodeName"
+	// nncNamespace is the namespace of the NodeNetworkConfigs, which are named after their Node.
+	nncNamespace = "kube-system"
+	// bindingSubresource is the subresource the scheduler creates to place a Pod on a Node.
+	bindingSubresource = "binding"
+)
+
+// Mode is what the Validator does with Pods placed on a Node without IP capacity.
+type Mode string
+
+const (
+	// Warn admits the Pod with a warning.
+	Warn Mode = "warn"
+	// Deny rejects the Pod, so that the scheduler retries the binding.
+	Deny Mode = "deny"
+)
+
+// ErrInvalidMode is returned for a Mode other than Warn or Deny.
+var ErrInvalidMode = errors.New("invalid mode")
+
+// ParseMode parses a Mode from a flag.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case Warn, Deny:
+		return m, nil
+	default:
+		return "", errors.Wrapf(ErrInvalidMode, "%q, expected %q or %q", s, Warn, Deny)
+	}
+}
+
+// IndexPodNodeName indexes Pods by Node for PodNodeNameIndex.
+func IndexPodNodeName(obj client.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
+
+// Validator checks the IP capacity of the Node of Pod creations and bindings.
+// It fails open: Pods are admitted when the capacity of their Node can't be determined.
+type Validator struct {
+	cli     client.Reader
+	decoder *admission.Decoder
+	mode    Mode
+}
+
+var _ admission.Handler = &Validator{}
+
+// NewValidator creates a Validator reading NodeNetworkConfigs and Pods, indexed by PodNodeNameIndex, from cli.
+func NewValidator(cli client.Reader, scheme *runtime.Scheme, mode Mode) (*Validator, error) {
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create decoder")
+	}
+	return &Validator{
+		cli:     cli,
+		decoder: decoder,
+		mode:    mode,
+	}, nil
+}
+
+// Handle admits Pods unless they are created on, or bound to, a Node without IP capacity.
+func (v *Validator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	var (
+		pod      *corev1.Pod
+		nodeName string
+	)
+	switch req.SubResource {
+	case "":
+		pod = &corev1.Pod{}
+		if err := v.decoder.Decode(req, pod); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		nodeName = targetNode(pod)
+	case bindingSubresource:
+		binding := &corev1.Binding{}
+		if err := v.decoder.Decode(req, binding); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		nodeName = binding.Target.Name
+		pod = &corev1.Pod{}
+		if err := v.cli.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, pod); err != nil {
+			logger.Errorf("[ip-capacity] Admitting binding of pod %s/%s, failed to get pod: %v", req.Namespace, req.Name, err)
+			return admission.Allowed("")
+		}
+	default:
+		return admission.Allowed("")
+	}
+
+	// host network Pods don't need an IP from the pool.
+	if nodeName == "" || pod.Spec.HostNetwork {
+		return admission.Allowed("")
+	}
+
+	msg, err := v.checkCapacity(ctx, nodeName)
+	if err != nil {
+		logger.Errorf("[ip-capacity] Admitting pod %s/%s, failed to check the IP capacity of node %s: %v",
+			req.Namespace, req.Name, nodeName, err)
+		return admission.Allowed("")
+	}
+	if msg == "" {
+		return admission.Allowed("")
+	}
+
+	logger.Printf("[ip-capacity] Pod %s/%s on node %s: %s", req.Namespace, req.Name, nodeName, msg)
+	if v.mode == Deny {
+		resp := admission.Denied(msg)
+		// the API server reports the message, not the reason, of denials to the client.
+		resp.Result.Message = msg
+		return resp
+	}
+	return admission.Allowed("").WithWarnings(msg)
+}
+
+// targetNode returns the Node a Pod is created on: either its NodeName, or the single Node its required
+// affinity selects by name, which is how DaemonSet Pods are placed.
+func targetNode(pod *corev1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil ||
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 {
+		return ""
+	}
+	for _, field := range terms[0].MatchFields {
+		if field.Key == "metadata.name" && field.Operator == corev1.NodeSelectorOpIn && len(field.Values) == 1 {
+			return field.Values[0]
+		}
+	}
+	return ""
+}
+
+// checkCapacity returns why the Node has no IP for another Pod, or an empty string if it has, or may soon have, one.
+// Only Nodes whose IPs are all allocated one by one, from dynamic VNET NetworkContainers, are checked.
+func (v *Validator) checkCapacity(ctx context.Context, nodeName string) (string, error) {
+	nnc := &v1alpha.NodeNetworkConfig{}
+	if err := v.cli.Get(ctx, types.NamespacedName{Namespace: nncNamespace, Name: nodeName}, nnc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "failed to get NodeNetworkConfig")
+	}
+
+	if len(nnc.Status.NetworkContainers) == 0 {
+		return "", nil
+	}
+	allocated := 0
+	for i := range nnc.Status.NetworkContainers {
+		nc := &nnc.Status.NetworkContainers[i]
+		if nc.AssignmentMode == v1alpha.Static || (nc.Type != "" && nc.Type != v1alpha.VNET) {
+			return "", nil
+		}
+		allocated += len(nc.IPAssignments)
+	}
+
+	maxIPs := nnc.Status.Scaler.MaxIPCount
+	atMax := maxIPs > 0 && int64(allocated) >= maxIPs
+	if !nnc.Spec.IPPoolPressure && !atMax {
+		return "", nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := v.cli.List(ctx, pods, client.MatchingFields{PodNodeNameIndex: nodeName}); err != nil {
+		return "", errors.Wrap(err, "failed to list pods")
+	}
+	used := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		used++
+	}
+	if used < allocated {
+		return "", nil
+	}
+
+	if atMax {
+		return fmt.Sprintf("node %s has no free pod IPs: %d pods use all %d IPs, which is the max IP count of the node",
+			nodeName, used, allocated), nil
+	}
+	return fmt.Sprintf("node %s has no free pod IPs: %d pods use all %d IPs, and the IP pool is unable to grow",
+		nodeName, used, allocated), nil
+}
@@ -0,0 +1,187 @@
+package ipcapacity
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newNNC(node string, ips int, maxIPs int64, pressure bool) *v1alpha.NodeNetworkConfig {
+	nc := v1alpha.NetworkContainer{ID: "nc", AssignmentMode: v1alpha.Dynamic, Type: v1alpha.VNET}
+	for i := 0; i < ips; i++ {
+		nc.IPAssignments = append(nc.IPAssignments, v1alpha.IPAssignment{Name: strconv.Itoa(i), IP: "10.0.0." + strconv.Itoa(i)})
+	}
+	return &v1alpha.NodeNetworkConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: nncNamespace, Name: node},
+		Spec:       v1alpha.NodeNetworkConfigSpec{RequestedIPCount: int64(ips), IPPoolPressure: pressure},
+		Status: v1alpha.NodeNetworkConfigStatus{
+			Scaler:            v1alpha.Scaler{MaxIPCount: maxIPs},
+			NetworkContainers: []v1alpha.NetworkContainer{nc},
+		},
+	}
+}
+
+func newPod(name, node string, hostNetwork bool, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       corev1.PodSpec{NodeName: node, HostNetwork: hostNetwork},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func newRequest(t *testing.T, subResource, name string, obj runtime.Object) admission.Request {
+	raw, err := json.Marshal(obj)
+	require.NoError(t, err)
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation:   admissionv1.Create,
+		SubResource: subResource,
+		Namespace:   "default",
+		Name:        name,
+		Object:      runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestHandle(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha.AddToScheme(scheme))
+
+	objs := []client.Object{
+		// full, at the max IP count
+		newNNC("full", 2, 2, false),
+		newPod("full-1", "full", false, corev1.PodRunning),
+		newPod("full-2", "full", false, corev1.PodPending),
+		newPod("full-host", "full", true, corev1.PodRunning),
+		newPod("full-done", "full", false, corev1.PodSucceeded),
+		newPod("unbound", "", false, corev1.PodPending),
+		// full, but the pool can grow
+		newNNC("growable", 1, 10, false),
+		newPod("growable-1", "growable", false, corev1.PodRunning),
+		// full, and the pool can't grow
+		newNNC("pressure", 1, 10, true),
+		newPod("pressure-1", "pressure", false, corev1.PodRunning),
+		// under pressure with a free IP
+		newNNC("free", 2, 10, true),
+		newPod("free-1", "free", false, corev1.PodRunning),
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(&corev1.Pod{}, PodNodeNameIndex, IndexPodNodeName).
+		Build()
+
+	tests := []struct {
+		name        string
+		req         admission.Request
+		wantAllowed bool
+		wantWarning bool
+	}{
+		{
+			name:        "pod on full node",
+			req:         newRequest(t, "", "new", newPod("new", "full", false, "")),
+			wantAllowed: false,
+		},
+		{
+			name:        "host network pod on full node",
+			req:         newRequest(t, "", "new", newPod("new", "full", true, "")),
+			wantAllowed: true,
+		},
+		{
+			name: "daemonset pod on full node",
+			req: newRequest(t, "", "new", &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "new"},
+				Spec: corev1.PodSpec{Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchFields: []corev1.NodeSelectorRequirement{{
+								Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"full"},
+							}},
+						}},
+					},
+				}}},
+			}),
+			wantAllowed: false,
+		},
+		{
+			name:        "unscheduled pod",
+			req:         newRequest(t, "", "new", newPod("new", "", false, "")),
+			wantAllowed: true,
+		},
+		{
+			name: "binding to full node",
+			req: newRequest(t, bindingSubresource, "unbound", &corev1.Binding{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unbound"},
+				Target:     corev1.ObjectReference{Kind: "Node", Name: "full"},
+			}),
+			wantAllowed: false,
+		},
+		{
+			name:        "pod on node whose pool can grow",
+			req:         newRequest(t, "", "new", newPod("new", "growable", false, "")),
+			wantAllowed: true,
+		},
+		{
+			name:        "pod on full node under pressure",
+			req:         newRequest(t, "", "new", newPod("new", "pressure", false, "")),
+			wantAllowed: false,
+		},
+		{
+			name:        "pod on node under pressure with a free IP",
+			req:         newRequest(t, "", "new", newPod("new", "free", false, "")),
+			wantAllowed: true,
+		},
+		{
+			name:        "pod on node without NodeNetworkConfig",
+			req:         newRequest(t, "", "new", newPod("new", "overlay", false, "")),
+			wantAllowed: true,
+		},
+	}
+
+	for _, mode := range []Mode{Deny, Warn} {
+		v, err := NewValidator(cli, scheme, mode)
+		require.NoError(t, err)
+		for _, tt := range tests {
+			tt := tt
+			t.Run(string(mode)+"/"+tt.name, func(t *testing.T) {
+				resp := v.Handle(context.Background(), tt.req)
+				if tt.wantAllowed {
+					assert.True(t, resp.Allowed)
+					assert.Empty(t, resp.Warnings)
+					return
+				}
+				if mode == Deny {
+					assert.False(t, resp.Allowed)
+					assert.Contains(t, resp.Result.Message, "has no free pod IPs")
+					return
+				}
+				assert.True(t, resp.Allowed)
+				require.Len(t, resp.Warnings, 1)
+				assert.Contains(t, resp.Warnings[0], "has no free pod IPs")
+			})
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("deny")
+	require.NoError(t, err)
+	assert.Equal(t, Deny, m)
+
+	_, err = ParseMode("block")
+	require.ErrorIs(t, err, ErrInvalidMode)
+}
@@ -0,0 +1,121 @@
+package ipconflict
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// jitterFactor spreads the checks of the Nodes over up to half an Interval, so that they don't all publish
+// their IPClaimSummary at the same time.
+const jitterFactor = 0.5
+
+type ipSource interface {
+	GetAssignedIPConfigs() []cns.IPConfigurationStatus
+}
+
+type claimClient interface {
+	List(context.Context) ([]v1alpha1.IPClaimSummary, error)
+	CreateOrUpdate(context.Context, *v1alpha1.IPClaimSummary) error
+}
+
+// Options configures the Checker.
+type Options struct {
+	// Interval is the delay between publishing the IPClaimSummary of the Node and checking it for conflicts.
+	Interval time.Duration
+	// Owner, if set, is the owner of the published IPClaimSummary, e.g. the Node, so that it is garbage
+	// collected with it.
+	Owner *metav1.OwnerReference
+}
+
+// Checker periodically publishes the IPs assigned on the Node and flags the ones claimed by other Nodes.
+type Checker struct {
+	nodeName string
+	ips      ipSource
+	cli      claimClient
+	opts     Options
+}
+
+// NewChecker creates a Checker publishing the assigned IPs of the ipSource as the IPClaimSummary of the Node.
+func NewChecker(nodeName string, ips ipSource, cli claimClient, opts *Options) *Checker {
+	return &Checker{
+		nodeName: nodeName,
+		ips:      ips,
+		cli:      cli,
+		opts:     *opts,
+	}
+}
+
+// Start runs the check every Interval, jittered, until the context is done.
+func (c *Checker) Start(ctx context.Context) error {
+	logger.Printf("[ip-conflict-checker] Starting IP conflict checker with interval %s", c.opts.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ip conflict checker context closed")
+		case <-time.After(wait.Jitter(c.opts.Interval, jitterFactor)):
+		}
+		if _, err := c.Check(ctx); err != nil {
+			ipConflictCheckFailures.Inc()
+			logger.Errorf("[ip-conflict-checker] Check failed with err %v", err)
+		}
+	}
+}
+
+// Check publishes the IPClaimSummary of the Node and returns the assigned IPs which other Nodes claim as well.
+// Conflicts are logged and counted in the ipam_ip_conflicts metric.
+func (c *Checker) Check(ctx context.Context) ([]Conflict, error) {
+	assigned := c.ips.GetAssignedIPConfigs()
+	ips := make([]string, len(assigned))
+	for i := range assigned {
+		ips[i] = assigned[i].IPAddress
+	}
+	claims, invalid := Summarize(ips)
+	if len(invalid) > 0 {
+		logger.Errorf("[ip-conflict-checker] Skipping assigned IPs which can't be parsed: %v", invalid)
+	}
+
+	summary := &v1alpha1.IPClaimSummary{
+		ObjectMeta: metav1.ObjectMeta{Name: c.nodeName},
+		Spec: v1alpha1.IPClaimSummarySpec{
+			IPCount:     len(ips) - len(invalid),
+			PrefixCount: len(claims),
+			Prefixes:    claims,
+		},
+	}
+	if c.opts.Owner != nil {
+		summary.OwnerReferences = []metav1.OwnerReference{*c.opts.Owner}
+	}
+	if err := c.cli.CreateOrUpdate(ctx, summary); err != nil {
+		return nil, errors.Wrap(err, "failed to publish ip claim summary")
+	}
+
+	summaries, err := c.cli.List(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list ip claim summaries")
+	}
+	others := make(map[string][]v1alpha1.PrefixClaim, len(summaries))
+	for i := range summaries {
+		if summaries[i].Name == c.nodeName {
+			continue
+		}
+		others[summaries[i].Name] = summaries[i].Spec.Prefixes
+	}
+
+	conflicts, err := FindConflicts(claims, others)
+	if err != nil {
+		// the conflicts found in the claims which could be decoded are still reported
+		logger.Errorf("[ip-conflict-checker] %v", err)
+	}
+	ipConflictCount.Set(float64(len(conflicts)))
+	for _, conflict := range conflicts {
+		logger.Errorf("[ip-conflict-checker] IP %s assigned on this node is also claimed by nodes %v", conflict.IP, conflict.Nodes)
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,64 @@
+package ipconflict
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeIPSource []string
+
+func (f fakeIPSource) GetAssignedIPConfigs() []cns.IPConfigurationStatus {
+	ipconfigs := make([]cns.IPConfigurationStatus, len(f))
+	for i := range f {
+		ipconfigs[i] = cns.IPConfigurationStatus{IPAddress: f[i]}
+	}
+	return ipconfigs
+}
+
+type fakeClaimClient map[string]v1alpha1.IPClaimSummary
+
+func (f fakeClaimClient) List(context.Context) ([]v1alpha1.IPClaimSummary, error) {
+	summaries := make([]v1alpha1.IPClaimSummary, 0, len(f))
+	for name := range f {
+		summaries = append(summaries, f[name])
+	}
+	return summaries, nil
+}
+
+func (f fakeClaimClient) CreateOrUpdate(_ context.Context, summary *v1alpha1.IPClaimSummary) error {
+	f[summary.Name] = *summary
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	cli := fakeClaimClient{}
+	owner := &metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: "node1", UID: "uid"}
+	node1 := NewChecker("node1", fakeIPSource{"10.0.0.4", "10.0.0.5"}, cli, &Options{Owner: owner})
+	node2 := NewChecker("node2", fakeIPSource{"10.0.0.5", "10.0.0.6", "10.0.0.7"}, cli, &Options{})
+
+	// node2 has not published its summary yet
+	conflicts, err := node1.Check(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, 2, cli["node1"].Spec.IPCount)
+	assert.Equal(t, 1, cli["node1"].Spec.PrefixCount)
+	assert.Equal(t, []metav1.OwnerReference{*owner}, cli["node1"].OwnerReferences)
+
+	conflicts, err = node2.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Conflict{{IP: netip.MustParseAddr("10.0.0.5"), Nodes: []string{"node1"}}}, conflicts)
+	assert.Equal(t, 3, cli["node2"].Spec.IPCount)
+
+	conflicts, err = node1.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Conflict{{IP: netip.MustParseAddr("10.0.0.5"), Nodes: []string{"node2"}}}, conflicts)
+}
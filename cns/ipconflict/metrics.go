@@ -0,0 +1,28 @@
+package ipconflict
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	ipConflictCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ipam_ip_conflicts",
+			Help: "Count of IPs assigned on this Node which are also claimed by other Nodes.",
+		},
+	)
+	ipConflictCheckFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ipam_ip_conflict_check_failures_total",
+			Help: "Number of IP conflict checks which failed to publish or read the IP claim summaries.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ipConflictCount,
+		ipConflictCheckFailures,
+	)
+}
@@ -0,0 +1,151 @@
+// Package ipconflict detects Pod IPs which CNS has assigned on more than one Node.
+// Each CNS publishes the IPs it assigned, aggregated by prefix into bitmaps, as the IPClaimSummary of its Node,
+// and checks its own IPs against the summaries of every other Node.
+package ipconflict
+
+import (
+	"encoding/base64"
+	"net/netip"
+	"sort"
+
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// prefixHostBits is the number of host bits of the aggregated prefixes, so every prefix holds 256 addresses.
+	prefixHostBits = 8
+	bitmapLen      = (1 << prefixHostBits) / 8
+)
+
+var ErrInvalidPrefixClaim = errors.New("invalid prefix claim")
+
+type bitmap [bitmapLen]byte
+
+// Conflict is an IP assigned on more than one Node.
+type Conflict struct {
+	IP netip.Addr
+	// Nodes are the other Nodes which claim the IP, sorted by name.
+	Nodes []string
+}
+
+// Summarize aggregates the IPs into the PrefixClaims of an IPClaimSummary, sorted by prefix.
+// IPs which can't be parsed are returned separately.
+func Summarize(ips []string) (claims []v1alpha1.PrefixClaim, invalid []string) {
+	bitmaps := map[netip.Prefix]*bitmap{}
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			invalid = append(invalid, ip)
+			continue
+		}
+		prefix, offset := aggregate(addr)
+		b, ok := bitmaps[prefix]
+		if !ok {
+			b = &bitmap{}
+			bitmaps[prefix] = b
+		}
+		b[offset/8] |= 1 << (offset % 8)
+	}
+
+	claims = make([]v1alpha1.PrefixClaim, 0, len(bitmaps))
+	for prefix, b := range bitmaps {
+		claims = append(claims, v1alpha1.PrefixClaim{
+			Prefix: prefix.String(),
+			Bitmap: base64.StdEncoding.EncodeToString(b[:]),
+		})
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Prefix < claims[j].Prefix })
+	return claims, invalid
+}
+
+// FindConflicts returns the IPs of the local claims which are also claimed by the other Nodes, sorted by IP.
+// others is keyed by the Node name. Prefix claims which can't be decoded are skipped and returned as an error.
+func FindConflicts(local []v1alpha1.PrefixClaim, others map[string][]v1alpha1.PrefixClaim) ([]Conflict, error) {
+	var decodeErrs []error
+	localBitmaps := make(map[netip.Prefix]bitmap, len(local))
+	for _, claim := range local {
+		prefix, b, err := decode(claim)
+		if err != nil {
+			decodeErrs = append(decodeErrs, err)
+			continue
+		}
+		localBitmaps[prefix] = b
+	}
+
+	nodesByIP := map[netip.Addr][]string{}
+	for node, claims := range others {
+		for _, claim := range claims {
+			prefix, b, err := decode(claim)
+			if err != nil {
+				decodeErrs = append(decodeErrs, errors.Wrapf(err, "node %s", node))
+				continue
+			}
+			localBitmap, ok := localBitmaps[prefix]
+			if !ok {
+				continue
+			}
+			for i := range b {
+				shared := b[i] & localBitmap[i]
+				for bit := 0; shared != 0; bit++ {
+					if shared&(1<<bit) == 0 {
+						continue
+					}
+					shared &^= 1 << bit
+					ip := offsetAddr(prefix, i*8+bit)
+					nodesByIP[ip] = append(nodesByIP[ip], node)
+				}
+			}
+		}
+	}
+
+	conflicts := make([]Conflict, 0, len(nodesByIP))
+	for ip, nodes := range nodesByIP {
+		sort.Strings(nodes)
+		conflicts = append(conflicts, Conflict{IP: ip, Nodes: nodes})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].IP.Less(conflicts[j].IP) })
+
+	if len(decodeErrs) > 0 {
+		return conflicts, errors.Errorf("failed to decode %d prefix claims, first error: %v", len(decodeErrs), decodeErrs[0])
+	}
+	return conflicts, nil
+}
+
+// aggregate returns the prefix of 256 addresses containing the addr, and the offset of the addr in it.
+func aggregate(addr netip.Addr) (netip.Prefix, int) {
+	addr = addr.Unmap()
+	prefix := netip.PrefixFrom(addr, addr.BitLen()-prefixHostBits).Masked()
+	b := addr.As16()
+	return prefix, int(b[15])
+}
+
+func offsetAddr(prefix netip.Prefix, offset int) netip.Addr {
+	b := prefix.Addr().As16()
+	b[15] = byte(offset)
+	addr := netip.AddrFrom16(b)
+	if prefix.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+func decode(claim v1alpha1.PrefixClaim) (netip.Prefix, bitmap, error) {
+	var b bitmap
+	prefix, err := netip.ParsePrefix(claim.Prefix)
+	if err != nil {
+		return prefix, b, errors.Wrapf(ErrInvalidPrefixClaim, "%v", err)
+	}
+	if prefix.Bits() != prefix.Addr().BitLen()-prefixHostBits || prefix.Masked() != prefix {
+		return prefix, b, errors.Wrapf(ErrInvalidPrefixClaim, "%s is not an aggregated prefix", claim.Prefix)
+	}
+	raw, err := base64.StdEncoding.DecodeString(claim.Bitmap)
+	if err != nil {
+		return prefix, b, errors.Wrapf(ErrInvalidPrefixClaim, "bitmap of %s: %v", claim.Prefix, err)
+	}
+	if len(raw) != bitmapLen {
+		return prefix, b, errors.Wrapf(ErrInvalidPrefixClaim, "bitmap of %s has %d bytes", claim.Prefix, len(raw))
+	}
+	copy(b[:], raw)
+	return prefix, b, nil
+}
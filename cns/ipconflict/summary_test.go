@@ -0,0 +1,61 @@
+package ipconflict
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	claims, invalid := Summarize([]string{"10.0.1.7", "10.0.0.1", "10.0.0.0", "10.0.0.255", "fd00::1:ff", "not-an-ip", "10.0.0.1"})
+	assert.Equal(t, []string{"not-an-ip"}, invalid)
+	require.Len(t, claims, 3)
+	assert.Equal(t, "10.0.0.0/24", claims[0].Prefix)
+	assert.Equal(t, "10.0.1.0/24", claims[1].Prefix)
+	assert.Equal(t, "fd00::1:0/120", claims[2].Prefix)
+
+	_, b, err := decode(claims[0])
+	require.NoError(t, err)
+	want := bitmap{}
+	want[0] = 0b11
+	want[31] = 0b10000000
+	assert.Equal(t, want, b)
+}
+
+func TestFindConflicts(t *testing.T) {
+	local, _ := Summarize([]string{"10.0.0.4", "10.0.0.5", "10.0.1.4", "fd00::4"})
+	node1, _ := Summarize([]string{"10.0.0.5", "10.0.0.6", "fd00::4"})
+	node2, _ := Summarize([]string{"10.0.0.5", "10.0.2.4"})
+	node3, _ := Summarize([]string{"10.0.1.5"})
+
+	conflicts, err := FindConflicts(local, map[string][]v1alpha1.PrefixClaim{
+		"node2": node2,
+		"node1": node1,
+		"node3": node3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{IP: netip.MustParseAddr("10.0.0.5"), Nodes: []string{"node1", "node2"}},
+		{IP: netip.MustParseAddr("fd00::4"), Nodes: []string{"node1"}},
+	}, conflicts)
+}
+
+func TestFindConflictsSkipsInvalidClaims(t *testing.T) {
+	local, _ := Summarize([]string{"10.0.0.4"})
+	node1, _ := Summarize([]string{"10.0.0.4"})
+	invalid := []v1alpha1.PrefixClaim{
+		{Prefix: "10.0.0.0/16", Bitmap: node1[0].Bitmap},
+		{Prefix: "10.0.0.0/24", Bitmap: "AAAA"},
+		{Prefix: "10.0.0.0/24", Bitmap: "!"},
+	}
+
+	conflicts, err := FindConflicts(local, map[string][]v1alpha1.PrefixClaim{
+		"node1": node1,
+		"node2": invalid,
+	})
+	require.ErrorContains(t, err, "failed to decode 3 prefix claims")
+	assert.Equal(t, []Conflict{{IP: netip.MustParseAddr("10.0.0.4"), Nodes: []string{"node1"}}}, conflicts)
+}
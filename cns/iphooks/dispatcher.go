@@ -0,0 +1,143 @@
+// Package iphooks notifies integrations, e.g. DNS registration, firewall sync or IPAM audit exporters, of the IPs
+// CNS assigns to and releases from Pods, without modifying the IPAM of CNS.
+package iphooks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTimeout bounds the notification of an Event to a Hook.
+	DefaultTimeout = 5 * time.Second
+	// DefaultQueueSize is the number of Events waiting to be delivered after which new Events are dropped.
+	DefaultQueueSize = 1024
+)
+
+// EventType is the change of the IPs of a Pod.
+type EventType string
+
+const (
+	// EventAssign is sent when IPs are assigned to a Pod, including again when CNI retries the request of a Pod.
+	EventAssign EventType = "Assign"
+	// EventRelease is sent when the IPs of a Pod are released.
+	EventRelease EventType = "Release"
+)
+
+// Event is an IP assignment or release, with the metadata of the Pod.
+type Event struct {
+	Type             EventType
+	PodName          string
+	PodNamespace     string
+	PodInterfaceID   string
+	InfraContainerID string
+	IPAddresses      []string
+	Timestamp        time.Time
+}
+
+// Hook is notified of the Events. Hooks may be notified of the same Event more than once, e.g. on CNI retries,
+// so they should be idempotent.
+type Hook interface {
+	// Name identifies the Hook in the logs and metrics.
+	Name() string
+	// Notify handles the Event. Errors are logged and counted but don't fail the IP assignment or release.
+	Notify(ctx context.Context, event Event) error
+}
+
+// Options configures the Dispatcher.
+type Options struct {
+	// Timeout bounds the notification of an Event to a Hook, DefaultTimeout if zero.
+	Timeout time.Duration
+	// QueueSize is the number of Events waiting to be delivered after which new Events are dropped,
+	// DefaultQueueSize if zero.
+	QueueSize int
+}
+
+// Dispatcher delivers the Events to the Hooks in order, asynchronously so that slow or failing Hooks don't delay
+// the IPAM of CNS. A nil Dispatcher drops the Events.
+type Dispatcher struct {
+	sync.RWMutex
+	hooks   []Hook
+	timeout time.Duration
+	events  chan Event
+}
+
+// NewDispatcher creates a Dispatcher of the Events to the hooks. More hooks, e.g. in-process plugins, can be
+// registered with Register.
+func NewDispatcher(opts *Options, hooks ...Hook) *Dispatcher {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	queueSize := opts.QueueSize
+	if queueSize == 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Dispatcher{
+		hooks:   hooks,
+		timeout: timeout,
+		events:  make(chan Event, queueSize),
+	}
+}
+
+// Register adds the Hook, which is notified of the Events dispatched after it.
+func (d *Dispatcher) Register(hook Hook) {
+	d.Lock()
+	defer d.Unlock()
+	d.hooks = append(d.hooks, hook)
+}
+
+// Dispatch queues the Event for the Hooks without blocking. The Event is dropped if the queue is full.
+func (d *Dispatcher) Dispatch(event Event) { //nolint:gocritic // events are passed by value to the hooks
+	if d == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	select {
+	case d.events <- event:
+	default:
+		droppedEvents.Inc()
+		logger.Errorf("[ip-hooks] Dropping %s event of pod %s/%s, the queue is full", event.Type, event.PodNamespace, event.PodName)
+	}
+}
+
+// Start delivers the Events to the Hooks until the context is done.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	logger.Printf("[ip-hooks] Starting IP hook dispatcher with %d hooks", len(d.snapshot()))
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ip hook dispatcher context closed")
+		case event := <-d.events:
+			d.notify(ctx, event)
+		}
+	}
+}
+
+func (d *Dispatcher) snapshot() []Hook {
+	d.RLock()
+	defer d.RUnlock()
+	return d.hooks
+}
+
+// notify delivers the Event to every Hook, each bounded by the timeout.
+func (d *Dispatcher) notify(ctx context.Context, event Event) { //nolint:gocritic // events are passed by value to the hooks
+	for _, hook := range d.snapshot() {
+		hookCtx, cancel := context.WithTimeout(ctx, d.timeout)
+		start := time.Now()
+		err := hook.Notify(hookCtx, event)
+		cancel()
+		hookLatency.WithLabelValues(hook.Name()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			hookFailures.WithLabelValues(hook.Name()).Inc()
+			logger.Errorf("[ip-hooks] Hook %s failed to handle %s event of pod %s/%s: %v",
+				hook.Name(), event.Type, event.PodNamespace, event.PodName, err)
+		}
+	}
+}
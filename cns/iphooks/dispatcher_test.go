@@ -0,0 +1,123 @@
+package iphooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var errHook = errors.New("hook failed")
+
+func TestMain(m *testing.M) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	os.Exit(m.Run())
+}
+
+type recordingHook struct {
+	events chan Event
+	err    error
+}
+
+func (*recordingHook) Name() string {
+	return "recording"
+}
+
+func (h *recordingHook) Notify(_ context.Context, event Event) error { //nolint:gocritic // events are passed by value to the hooks
+	h.events <- event
+	return h.err
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	failing := &recordingHook{events: make(chan Event, 2), err: errHook}
+	hook := &recordingHook{events: make(chan Event, 2)}
+	d := NewDispatcher(&Options{}, failing)
+	d.Register(hook)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Start(ctx) //nolint:errcheck // stopped by the context
+
+	d.Dispatch(Event{Type: EventAssign, PodName: "pod", IPAddresses: []string{"10.0.0.1"}})
+	d.Dispatch(Event{Type: EventRelease, PodName: "pod", IPAddresses: []string{"10.0.0.1"}})
+
+	// a failing hook doesn't stop the others from being notified
+	for _, h := range []*recordingHook{failing, hook} {
+		assign := receive(t, h.events)
+		require.Equal(t, EventAssign, assign.Type)
+		require.False(t, assign.Timestamp.IsZero())
+		require.Equal(t, EventRelease, receive(t, h.events).Type)
+	}
+}
+
+func TestDispatchDropsWhenFull(t *testing.T) {
+	d := NewDispatcher(&Options{QueueSize: 1})
+	d.Dispatch(Event{Type: EventAssign})
+	d.Dispatch(Event{Type: EventRelease})
+	require.Len(t, d.events, 1)
+	require.Equal(t, EventAssign, (<-d.events).Type)
+
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Dispatch(Event{Type: EventAssign})
+}
+
+func TestExecHook(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "event.json")
+	hook := &ExecHook{HookName: "exec", Path: "sh", Args: []string{"-c", "cat > " + out}}
+	require.NoError(t, hook.Notify(context.Background(), Event{Type: EventAssign, PodName: "pod"}))
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(b, &event))
+	require.Equal(t, "pod", event.PodName)
+
+	failing := &ExecHook{HookName: "exec", Path: "sh", Args: []string{"-c", "exit 1"}}
+	require.Error(t, failing.Notify(context.Background(), Event{}))
+}
+
+func TestWebhookHook(t *testing.T) {
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- event
+		if event.PodName == "rejected" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	hook := &WebhookHook{HookName: "webhook", URL: srv.URL}
+	require.NoError(t, hook.Notify(context.Background(), Event{Type: EventRelease, PodName: "pod"}))
+	require.Equal(t, "pod", receive(t, events).PodName)
+
+	err := hook.Notify(context.Background(), Event{PodName: "rejected"})
+	require.ErrorIs(t, err, ErrUnexpectedStatus)
+}
@@ -0,0 +1,77 @@
+package iphooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnexpectedStatus is returned when a webhook doesn't answer an Event with a 2xx status.
+var ErrUnexpectedStatus = errors.New("unexpected webhook response status")
+
+// ExecHook runs a local executable for each Event, with the Event as JSON on its stdin.
+// A non-zero exit code fails the notification.
+type ExecHook struct {
+	HookName string
+	Path     string
+	Args     []string
+}
+
+func (h *ExecHook) Name() string {
+	return h.HookName
+}
+
+func (h *ExecHook) Notify(ctx context.Context, event Event) error { //nolint:gocritic // events are passed by value to the hooks
+	b, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+	cmd := exec.CommandContext(ctx, h.Path, h.Args...) //nolint:gosec // the executable is configured by the admin
+	cmd.Stdin = bytes.NewReader(b)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "%s failed with output %q", h.Path, out)
+	}
+	return nil
+}
+
+// WebhookHook POSTs each Event as JSON to a local webhook.
+type WebhookHook struct {
+	HookName string
+	URL      string
+	Client   *http.Client
+}
+
+func (h *WebhookHook) Name() string {
+	return h.HookName
+}
+
+func (h *WebhookHook) Notify(ctx context.Context, event Event) error { //nolint:gocritic // events are passed by value to the hooks
+	b, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to create webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to POST event to %s", h.URL)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Wrapf(ErrUnexpectedStatus, "%s answered %s", h.URL, resp.Status)
+	}
+	return nil
+}
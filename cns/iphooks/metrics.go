@@ -0,0 +1,39 @@
+package iphooks
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	hookLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "ipam_ip_hook_latency_seconds",
+			Help: "Time taken by the IP hooks to handle an IP assignment or release event.",
+			//nolint:gomnd // default bucket consts
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 15), // 1 ms to ~16 seconds
+		},
+		[]string{"hook"},
+	)
+	hookFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipam_ip_hook_failures_total",
+			Help: "Number of IP assignment or release events the IP hooks failed to handle.",
+		},
+		[]string{"hook"},
+	)
+	droppedEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ipam_ip_hook_dropped_events_total",
+			Help: "Number of IP assignment or release events dropped because the IP hooks were too slow to handle them.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		hookLatency,
+		hookFailures,
+		droppedEvents,
+	)
+}
@@ -0,0 +1,143 @@
+// Package ipleak detects the IPs assigned to Pods whose sandboxes the container runtime no longer runs, e.g. because
+// their CNI DEL never completed, and optionally reclaims them.
+package ipleak
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// DefaultGracePeriod is the GracePeriod used if none is set.
+const DefaultGracePeriod = 30 * time.Minute
+
+type ipSource interface {
+	GetAssignedIPConfigs() []cns.IPConfigurationStatus
+	// ReleaseIPConfigs releases the IPs of the Pod only if they're still assigned to its sandbox, since the Pod may
+	// have been recreated after its IPs were listed.
+	ReleaseIPConfigs(cns.PodInfo) error
+}
+
+type sandboxLister interface {
+	PodSandboxIDs(context.Context) (map[string]struct{}, error)
+}
+
+// Options configures the Detector.
+type Options struct {
+	// Interval is the delay between the detections.
+	Interval time.Duration
+	// GracePeriod is how long an IP must have been assigned before it's considered leaked, so that the Pods being
+	// created while the sandboxes are listed are never reported. Zero means DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Reclaim releases the IPs of the Pods whose IPs leaked. They're only reported otherwise.
+	Reclaim bool
+}
+
+// Leak is an IP assigned to a Pod whose sandbox the container runtime no longer runs.
+type Leak struct {
+	IP          string
+	PodName     string
+	Namespace   string
+	ContainerID string
+	AssignedAt  time.Time
+}
+
+// Detector periodically cross-references the assigned IPs with the pod sandboxes of the container runtime.
+type Detector struct {
+	ips       ipSource
+	sandboxes sandboxLister
+	opts      Options
+}
+
+// NewDetector creates a Detector of the IPs of the ipSource leaked by Pods which aren't sandboxes of the lister.
+func NewDetector(ips ipSource, sandboxes sandboxLister, opts *Options) *Detector {
+	d := &Detector{
+		ips:       ips,
+		sandboxes: sandboxes,
+		opts:      *opts,
+	}
+	if d.opts.GracePeriod <= 0 {
+		d.opts.GracePeriod = DefaultGracePeriod
+	}
+	return d
+}
+
+// Start runs the detection every Interval until the context is done.
+func (d *Detector) Start(ctx context.Context) error {
+	logger.Printf("[ip-leak-detector] Starting IP leak detector with interval %s, grace period %s, reclaim %t",
+		d.opts.Interval, d.opts.GracePeriod, d.opts.Reclaim)
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.Detect(ctx); err != nil {
+			ipLeakCheckFailures.Inc()
+			logger.Errorf("[ip-leak-detector] Detection failed with err %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ip leak detector context closed")
+		case <-ticker.C:
+		}
+	}
+}
+
+// Detect returns the IPs assigned for more than the GracePeriod to Pods whose sandboxes aren't listed by the container
+// runtime, and releases them if Reclaim is set. The IPs of Pods without an infra container ID are skipped since their
+// sandbox can't be told. Leaks are logged and counted in the ipam_leaked_ips metric.
+func (d *Detector) Detect(ctx context.Context) ([]Leak, error) {
+	live, err := d.sandboxes.PodSandboxIDs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pod sandboxes")
+	}
+
+	now := time.Now()
+	var leaks []Leak
+	leakedPods := map[string]cns.PodInfo{}
+	for _, ipconfig := range d.ips.GetAssignedIPConfigs() { //nolint:gocritic // ignore copy
+		if ipconfig.PodInfo == nil || ipconfig.PodInfo.InfraContainerID() == "" {
+			continue
+		}
+		if _, ok := live[ipconfig.PodInfo.InfraContainerID()]; ok {
+			continue
+		}
+		if now.Sub(ipconfig.LastStateTransition) < d.opts.GracePeriod {
+			continue
+		}
+		leaks = append(leaks, Leak{
+			IP:          ipconfig.IPAddress,
+			PodName:     ipconfig.PodInfo.Name(),
+			Namespace:   ipconfig.PodInfo.Namespace(),
+			ContainerID: ipconfig.PodInfo.InfraContainerID(),
+			AssignedAt:  ipconfig.LastStateTransition,
+		})
+		leakedPods[ipconfig.PodInfo.Key()] = ipconfig.PodInfo
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].IP < leaks[j].IP })
+	leakedIPCount.Set(float64(len(leaks)))
+	for i := range leaks {
+		logger.Errorf("[ip-leak-detector] IP %s assigned at %s to pod %s/%s is leaked, its sandbox %s no longer exists",
+			leaks[i].IP, leaks[i].AssignedAt.Format(time.RFC3339), leaks[i].Namespace, leaks[i].PodName, leaks[i].ContainerID)
+	}
+
+	if !d.opts.Reclaim || len(leaks) == 0 {
+		return leaks, nil
+	}
+	if len(live) == 0 {
+		// every assigned IP would be reclaimed, the runtime is more likely restarting than running no Pod
+		logger.Printf("[ip-leak-detector] Not reclaiming leaked IPs since the container runtime has no pod sandbox")
+		return leaks, nil
+	}
+	for key, podInfo := range leakedPods {
+		if err := d.ips.ReleaseIPConfigs(podInfo); err != nil {
+			logger.Errorf("[ip-leak-detector] Failed to reclaim the IPs of pod %s: %v", key, err)
+			continue
+		}
+		logger.Printf("[ip-leak-detector] Reclaimed the leaked IPs of pod %s", key)
+		reclaimedPodCount.Inc()
+	}
+	return leaks, nil
+}
@@ -0,0 +1,88 @@
+package ipleak
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIPSource struct {
+	assigned []cns.IPConfigurationStatus
+	released []string
+}
+
+func (f *fakeIPSource) GetAssignedIPConfigs() []cns.IPConfigurationStatus {
+	return f.assigned
+}
+
+func (f *fakeIPSource) ReleaseIPConfigs(podInfo cns.PodInfo) error {
+	f.released = append(f.released, podInfo.Key())
+	return nil
+}
+
+type fakeSandboxLister struct {
+	ids map[string]struct{}
+	err error
+}
+
+func (f fakeSandboxLister) PodSandboxIDs(context.Context) (map[string]struct{}, error) {
+	return f.ids, f.err
+}
+
+func assigned(ip, containerID, name string, at time.Time) cns.IPConfigurationStatus {
+	return cns.IPConfigurationStatus{
+		IPAddress:           ip,
+		PodInfo:             cns.NewPodInfo(containerID, containerID+"-eth0", name, "default"),
+		LastStateTransition: at,
+	}
+}
+
+func TestDetect(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	old := time.Now().Add(-time.Hour)
+	ips := &fakeIPSource{assigned: []cns.IPConfigurationStatus{
+		assigned("10.0.0.4", "live", "live", old),
+		assigned("10.0.0.5", "dead", "dead", old),
+		assigned("10.0.0.6", "dead", "dead", old),
+		// created while the sandboxes were listed
+		assigned("10.0.0.7", "new", "new", time.Now()),
+		// the sandbox of the pod isn't known
+		{IPAddress: "10.0.0.8", PodInfo: cns.NewPodInfo("", "", "unknown", "default"), LastStateTransition: old},
+	}}
+	sandboxes := fakeSandboxLister{ids: map[string]struct{}{"live": {}}}
+
+	leaks, err := NewDetector(ips, sandboxes, &Options{}).Detect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Leak{
+		{IP: "10.0.0.5", PodName: "dead", Namespace: "default", ContainerID: "dead", AssignedAt: old},
+		{IP: "10.0.0.6", PodName: "dead", Namespace: "default", ContainerID: "dead", AssignedAt: old},
+	}, leaks)
+	assert.Empty(t, ips.released)
+
+	// the IPs of the pod are released once
+	leaks, err = NewDetector(ips, sandboxes, &Options{Reclaim: true}).Detect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, leaks, 2)
+	assert.Equal(t, []string{ips.assigned[1].PodInfo.Key()}, ips.released)
+}
+
+func TestDetectWithoutSandboxes(t *testing.T) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	ips := &fakeIPSource{assigned: []cns.IPConfigurationStatus{assigned("10.0.0.4", "dead", "dead", time.Now().Add(-time.Hour))}}
+
+	// the runtime without sandboxes is reported, but nothing is reclaimed
+	leaks, err := NewDetector(ips, fakeSandboxLister{ids: map[string]struct{}{}}, &Options{Reclaim: true}).Detect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, leaks, 1)
+	assert.Empty(t, ips.released)
+
+	_, err = NewDetector(ips, fakeSandboxLister{err: errors.New("crictl failed")}, &Options{Reclaim: true}).Detect(context.Background())
+	require.Error(t, err)
+	assert.Empty(t, ips.released)
+}
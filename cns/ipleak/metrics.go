@@ -0,0 +1,35 @@
+package ipleak
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	leakedIPCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ipam_leaked_ips",
+			Help: "Count of IPs assigned to Pods whose sandboxes the container runtime no longer runs.",
+		},
+	)
+	reclaimedPodCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ipam_leaked_ip_reclaimed_pods_total",
+			Help: "Number of Pods whose leaked IPs were reclaimed.",
+		},
+	)
+	ipLeakCheckFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ipam_ip_leak_check_failures_total",
+			Help: "Number of IP leak detections which failed to list the pod sandboxes of the container runtime.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		leakedIPCount,
+		reclaimedPodCount,
+		ipLeakCheckFailures,
+	)
+}
@@ -18,6 +18,13 @@ var (
 	ErrInvalidSecondaryIP = errors.New("invalid secondary IP")
 	// ErrUnsupportedNCQuantity indicates that the node has an unsupported nummber of Network Containers attached.
 	ErrUnsupportedNCQuantity = errors.New("unsupported number of network containers")
+	// ErrInvalidMultitenancy indicates that the encapsulation or tenant of a multitenant NC is invalid.
+	ErrInvalidMultitenancy = errors.New("invalid multitenancy")
+)
+
+const (
+	maxVLANID  = 4094
+	maxVxLANID = 1<<24 - 1
 )
 
 // CreateNCRequestFromDynamicNC generates a CreateNetworkContainerRequest from a dynamic NetworkContainer.
@@ -25,9 +32,13 @@ var (
 //nolint:gocritic //ignore hugeparam
 func CreateNCRequestFromDynamicNC(nc v1alpha.NetworkContainer) (*cns.CreateNetworkContainerRequest, error) {
 	primaryIP := nc.PrimaryIP
-	// if the PrimaryIP is not a CIDR, append a /32
+	// if the PrimaryIP is not a CIDR, append a /32, or a /128 if it's IPv6
 	if !strings.Contains(primaryIP, "/") {
-		primaryIP += "/32"
+		if strings.Contains(primaryIP, ":") {
+			primaryIP += "/128"
+		} else {
+			primaryIP += "/32"
+		}
 	}
 
 	primaryPrefix, err := netip.ParsePrefix(primaryIP)
@@ -56,7 +67,7 @@ func CreateNCRequestFromDynamicNC(nc v1alpha.NetworkContainer) (*cns.CreateNetwo
 			NCVersion: int(nc.Version),
 		}
 	}
-	return &cns.CreateNetworkContainerRequest{
+	req := &cns.CreateNetworkContainerRequest{
 		HostPrimaryIP:        nc.NodeIP,
 		SecondaryIPConfigs:   secondaryIPConfigs,
 		NetworkContainerid:   nc.ID,
@@ -66,7 +77,11 @@ func CreateNCRequestFromDynamicNC(nc v1alpha.NetworkContainer) (*cns.CreateNetwo
 			IPSubnet:         subnet,
 			GatewayIPAddress: nc.DefaultGateway,
 		},
-	}, nil
+	}
+	if err := setMultitenancy(req, nc); err != nil {
+		return nil, err
+	}
+	return req, nil
 }
 
 // CreateNCRequestFromStaticNC generates a CreateNetworkContainerRequest from a static NetworkContainer.
@@ -88,5 +103,48 @@ func CreateNCRequestFromStaticNC(nc v1alpha.NetworkContainer) (*cns.CreateNetwor
 	}
 
 	req := createNCRequestFromStaticNCHelper(nc, primaryPrefix, subnet)
+	if err := setMultitenancy(req, nc); err != nil {
+		return nil, err
+	}
 	return req, nil
 }
+
+// setMultitenancy sets the encapsulation and tenant of the multitenant NC types on the request. The VLAN NCs are
+// tagged with their EncapID, while the VNET scoped NCs are encapsulated with VxLAN unless their EncapType is VLAN.
+//
+//nolint:gocritic //ignore hugeparam
+func setMultitenancy(req *cns.CreateNetworkContainerRequest, nc v1alpha.NetworkContainer) error {
+	var encapType string
+	var maxID int64
+	switch nc.Type { //nolint:exhaustive // the other types are not multitenant
+	case v1alpha.MultitenantVLAN:
+		if nc.EncapType != "" && nc.EncapType != v1alpha.VLANEncap {
+			return errors.Wrapf(ErrInvalidMultitenancy, "NC %s of type %s has encap type %s", nc.ID, nc.Type, nc.EncapType)
+		}
+		encapType, maxID = cns.Vlan, maxVLANID
+	case v1alpha.MultitenantVNET:
+		switch nc.EncapType {
+		case "", v1alpha.VxLANEncap:
+			encapType, maxID = cns.Vxlan, maxVxLANID
+		case v1alpha.VLANEncap:
+			encapType, maxID = cns.Vlan, maxVLANID
+		default:
+			return errors.Wrapf(ErrInvalidMultitenancy, "NC %s has unknown encap type %s", nc.ID, nc.EncapType)
+		}
+	default:
+		return nil
+	}
+
+	if nc.EncapID < 1 || nc.EncapID > maxID {
+		return errors.Wrapf(ErrInvalidMultitenancy, "NC %s has %s ID %d out of range [1, %d]", nc.ID, encapType, nc.EncapID, maxID)
+	}
+	if nc.TenantID == "" {
+		return errors.Wrapf(ErrInvalidMultitenancy, "NC %s of type %s has no tenant", nc.ID, nc.Type)
+	}
+	req.MultiTenancyInfo = cns.MultiTenancyInfo{
+		EncapType: encapType,
+		ID:        int(nc.EncapID),
+	}
+	req.TenantID = nc.TenantID
+	return nil
+}
@@ -23,6 +23,7 @@ const (
 	testSecIP          = "10.0.0.2"
 	version            = 1
 	nodeIP             = "10.1.0.5"
+	tenantID           = "tenant1"
 )
 
 var invalidStatusMultiNC = v1alpha.NodeNetworkConfigStatus{
@@ -151,6 +152,43 @@ func TestCreateNCRequestFromDynamicNC(t *testing.T) {
 			wantErr: false,
 			want:    validSwiftRequest,
 		},
+		{
+			name: "IPv6 primary IP",
+			input: v1alpha.NetworkContainer{
+				PrimaryIP: "fd00::4",
+				ID:        ncID,
+				NodeIP:    nodeIP,
+				IPAssignments: []v1alpha.IPAssignment{
+					{
+						Name: uuid,
+						IP:   "fd00::5",
+					},
+				},
+				DefaultGateway:     "fd00::1",
+				SubnetAddressSpace: "fd00::/64",
+				Version:            version,
+			},
+			wantErr: false,
+			want: &cns.CreateNetworkContainerRequest{
+				HostPrimaryIP: nodeIP,
+				Version:       strconv.FormatInt(version, 10),
+				IPConfiguration: cns.IPConfiguration{
+					GatewayIPAddress: "fd00::1",
+					IPSubnet: cns.IPSubnet{
+						PrefixLength: 64,
+						IPAddress:    "fd00::4",
+					},
+				},
+				NetworkContainerid:   ncID,
+				NetworkContainerType: cns.Docker,
+				SecondaryIPConfigs: map[string]cns.SecondaryIPConfig{
+					uuid: {
+						IPAddress: "fd00::5",
+						NCVersion: version,
+					},
+				},
+			},
+		},
 		{
 			name: "IP assignment is CIDR",
 			input: v1alpha.NetworkContainer{
@@ -284,3 +322,111 @@ func TestCreateNCRequestFromStaticNC(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateNCRequestMultitenancy(t *testing.T) {
+	multitenantNC := func(ncType v1alpha.NCType, encapType v1alpha.EncapType, encapID int64) v1alpha.NetworkContainer {
+		nc := validSwiftNC
+		nc.Type = ncType
+		nc.EncapType = encapType
+		nc.EncapID = encapID
+		nc.TenantID = tenantID
+		return nc
+	}
+	staticNC := func(nc v1alpha.NetworkContainer) v1alpha.NetworkContainer {
+		nc.AssignmentMode = v1alpha.Static
+		nc.PrimaryIP = overlayPrimaryIP
+		return nc
+	}
+
+	tests := []struct {
+		name         string
+		input        v1alpha.NetworkContainer
+		wantInfo     cns.MultiTenancyInfo
+		wantTenantID string
+		wantErr      bool
+	}{
+		{
+			name:  "vnet",
+			input: validSwiftNC,
+		},
+		{
+			name:  "overlay",
+			input: validOverlayNC,
+		},
+		{
+			name:         "vlan",
+			input:        multitenantNC(v1alpha.MultitenantVLAN, "", 100),
+			wantInfo:     cns.MultiTenancyInfo{EncapType: cns.Vlan, ID: 100},
+			wantTenantID: tenantID,
+		},
+		{
+			name:         "static vlan",
+			input:        staticNC(multitenantNC(v1alpha.MultitenantVLAN, v1alpha.VLANEncap, 4094)),
+			wantInfo:     cns.MultiTenancyInfo{EncapType: cns.Vlan, ID: 4094},
+			wantTenantID: tenantID,
+		},
+		{
+			name:         "vnet scoped defaults to vxlan",
+			input:        multitenantNC(v1alpha.MultitenantVNET, "", 70000),
+			wantInfo:     cns.MultiTenancyInfo{EncapType: cns.Vxlan, ID: 70000},
+			wantTenantID: tenantID,
+		},
+		{
+			name:         "vnet scoped with vlan",
+			input:        multitenantNC(v1alpha.MultitenantVNET, v1alpha.VLANEncap, 10),
+			wantInfo:     cns.MultiTenancyInfo{EncapType: cns.Vlan, ID: 10},
+			wantTenantID: tenantID,
+		},
+		{
+			name:    "vlan with vxlan encap",
+			input:   multitenantNC(v1alpha.MultitenantVLAN, v1alpha.VxLANEncap, 100),
+			wantErr: true,
+		},
+		{
+			name:    "vlan ID out of range",
+			input:   multitenantNC(v1alpha.MultitenantVLAN, "", 4095),
+			wantErr: true,
+		},
+		{
+			name:    "vxlan ID out of range",
+			input:   multitenantNC(v1alpha.MultitenantVNET, v1alpha.VxLANEncap, 1<<24),
+			wantErr: true,
+		},
+		{
+			name:    "missing encap ID",
+			input:   multitenantNC(v1alpha.MultitenantVNET, "", 0),
+			wantErr: true,
+		},
+		{
+			name:    "unknown encap type",
+			input:   multitenantNC(v1alpha.MultitenantVNET, "gre", 100),
+			wantErr: true,
+		},
+		{
+			name: "missing tenant",
+			input: func() v1alpha.NetworkContainer {
+				nc := multitenantNC(v1alpha.MultitenantVLAN, "", 100)
+				nc.TenantID = ""
+				return nc
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			build := CreateNCRequestFromDynamicNC
+			if tt.input.AssignmentMode == v1alpha.Static {
+				build = CreateNCRequestFromStaticNC
+			}
+			got, err := build(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMultitenancy)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantInfo, got.MultiTenancyInfo)
+			assert.Equal(t, tt.wantTenantID, got.TenantID)
+		})
+	}
+}
@@ -12,6 +12,13 @@ var (
 			Help: "Allocated IP count.",
 		},
 	)
+	allocatedFamilyIPs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "allocated_family_ips",
+			Help: "Allocated IP count by IP family.",
+		},
+		[]string{"ip_family"},
+	)
 	requestedIPs = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "requested_ips",
@@ -29,6 +36,7 @@ var (
 func init() {
 	metrics.Registry.MustRegister(
 		allocatedIPs,
+		allocatedFamilyIPs,
 		requestedIPs,
 		unusedIPs,
 	)
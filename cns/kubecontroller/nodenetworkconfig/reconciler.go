@@ -2,10 +2,14 @@ package nodenetworkconfig
 
 import (
 	"context"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/events"
 	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/metric"
 	"github.com/Azure/azure-container-networking/cns/restserver"
 	cnstypes "github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig"
@@ -22,10 +26,33 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// pendingNCRequeueDelay is how long the reconciler waits before checking again on the NCs being programmed.
+const pendingNCRequeueDelay = time.Second
+
 type cnsClient interface {
 	CreateOrUpdateNetworkContainerInternal(*cns.CreateNetworkContainerRequest) cnstypes.ResponseCode
 }
 
+// asyncCNSClient programs the NCs in the background. When the cnsClient is one, the reconciler requeues the NNC
+// while its NCs are being programmed, instead of blocking a worker until they are.
+type asyncCNSClient interface {
+	CreateOrUpdateNetworkContainerAsync(*cns.CreateNetworkContainerRequest) (string, cnstypes.ResponseCode)
+	GetOperationStatus(string) (cns.OperationStatus, cnstypes.ResponseCode)
+}
+
+// namespaceSubnetsSetter is a cnsClient which assigns the IPs of the Pods of each namespace from the NCs of the
+// subnet mapped to it, in subnet-per-namespace mode.
+type namespaceSubnetsSetter interface {
+	SetNamespaceSubnets(map[string][]string)
+}
+
+// ncOperation is the last operation programming an NC with an asyncCNSClient.
+type ncOperation struct {
+	id   string
+	req  *cns.CreateNetworkContainerRequest
+	done bool
+}
+
 type nodeNetworkConfigListener interface {
 	Update(*v1alpha.NodeNetworkConfig) error
 }
@@ -42,23 +69,33 @@ type Reconciler struct {
 	once               sync.Once
 	started            chan interface{}
 	nodeIP             string
+	events             *events.Recorder
+	ncOperationsMu     sync.Mutex
+	ncOperations       map[string]*ncOperation
 }
 
 // NewReconciler creates a NodeNetworkConfig Reconciler which will get updates from the Kubernetes
 // apiserver for NNC events.
 // Provided nncListeners are passed the NNC after the Reconcile preprocesses it. Note: order matters! The
 // passed Listeners are notified in the order provided.
-func NewReconciler(cnscli cnsClient, ipampoolmonitorcli nodeNetworkConfigListener, nodeIP string) *Reconciler {
+// The failures to program the NCs are emitted as Events through the Recorder, if it isn't nil.
+func NewReconciler(cnscli cnsClient, ipampoolmonitorcli nodeNetworkConfigListener, nodeIP string, recorder *events.Recorder) *Reconciler {
 	return &Reconciler{
 		cnscli:             cnscli,
 		ipampoolmonitorcli: ipampoolmonitorcli,
 		started:            make(chan interface{}),
 		nodeIP:             nodeIP,
+		events:             recorder,
+		ncOperations:       map[string]*ncOperation{},
 	}
 }
 
 // Reconcile is called on CRD status changes
-func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (_ reconcile.Result, reconcileErr error) {
+	defer func() {
+		metric.ObserveReconcile(reconcileErr)
+	}()
+
 	listenersToNotify := []nodeNetworkConfigListener{}
 	nnc, err := r.nnccli.Get(ctx, req.NamespacedName)
 	if err != nil {
@@ -73,6 +110,9 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	logger.Printf("[cns-rc] CRD Spec: %+v", nnc.Spec)
 
 	ipAssignments := 0
+	ipAssignmentsByFamily := map[cns.IPFamily]int{}
+	pendingNCs := 0
+	ncIDsBySubnet := map[string][]string{}
 
 	// for each NC, parse it in to a CreateNCRequest and forward it to the appropriate Listener
 	for i := range nnc.Status.NetworkContainers {
@@ -104,16 +144,36 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 				"assignmentMode %s", nnc.Status.NetworkContainers[i].AssignmentMode)
 		}
 
-		responseCode := r.cnscli.CreateOrUpdateNetworkContainerInternal(req)
-		if err := restserver.ResponseCodeToError(responseCode); err != nil {
+		pending, err := r.programNC(req)
+		if err != nil {
 			logger.Errorf("[cns-rc] Error creating or updating NC in reconcile: %v", err)
+			r.events.Warningf(events.ReasonNCProgrammingFailed, "Failed to program network container %s: %v", req.NetworkContainerid, err)
 			return reconcile.Result{}, errors.Wrap(err, "failed to create or update network container")
 		}
+		if pending {
+			pendingNCs++
+			continue
+		}
 		ipAssignments += len(req.SecondaryIPConfigs)
+		ipAssignmentsByFamily[cns.IPFamilyOf(req.IPConfiguration.IPSubnet.IPAddress)] += len(req.SecondaryIPConfigs)
+		ncIDsBySubnet[nnc.Status.NetworkContainers[i].SubnetName] = append(ncIDsBySubnet[nnc.Status.NetworkContainers[i].SubnetName], req.NetworkContainerid)
+	}
+
+	if pendingNCs > 0 {
+		// the listeners are notified once all the NCs are programmed
+		logger.Printf("[cns-rc] %d network containers are still being programmed, requeueing", pendingNCs)
+		return reconcile.Result{RequeueAfter: pendingNCRequeueDelay}, nil
 	}
 
 	// record assigned IPs metric
 	allocatedIPs.Set(float64(ipAssignments))
+	for _, family := range []cns.IPFamily{cns.IPv4Family, cns.IPv6Family} {
+		allocatedFamilyIPs.WithLabelValues(string(family)).Set(float64(ipAssignmentsByFamily[family]))
+	}
+
+	if setter, ok := r.cnscli.(namespaceSubnetsSetter); ok {
+		setter.SetNamespaceSubnets(namespaceNCIDs(nnc.Spec.NamespaceSubnets, ncIDsBySubnet))
+	}
 
 	// push the NNC to the registered NNC listeners.
 	for _, l := range listenersToNotify {
@@ -130,6 +190,62 @@ func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	return reconcile.Result{}, nil
 }
 
+// namespaceNCIDs returns the NCs of the subnet mapped to each namespace in subnet-per-namespace mode, nil if the mode
+// is disabled. A namespace mapped to a subnet which isn't on the node has no NCs.
+func namespaceNCIDs(namespaceSubnets []v1alpha.NamespaceSubnet, ncIDsBySubnet map[string][]string) map[string][]string {
+	if len(namespaceSubnets) == 0 {
+		return nil
+	}
+	ncIDsByNamespace := map[string][]string{}
+	for _, mapping := range namespaceSubnets {
+		for _, namespace := range mapping.Namespaces {
+			ncIDsByNamespace[namespace] = append(ncIDsByNamespace[namespace], ncIDsBySubnet[mapping.SubnetName]...)
+		}
+	}
+	return ncIDsByNamespace
+}
+
+// programNC creates or updates the NC, and returns whether it is still being programmed by an asyncCNSClient.
+// An NC which an asyncCNSClient already programmed with the same request isn't programmed again.
+func (r *Reconciler) programNC(req *cns.CreateNetworkContainerRequest) (bool, error) {
+	async, ok := r.cnscli.(asyncCNSClient)
+	if !ok {
+		return false, restserver.ResponseCodeToError(r.cnscli.CreateOrUpdateNetworkContainerInternal(req))
+	}
+
+	r.ncOperationsMu.Lock()
+	defer r.ncOperationsMu.Unlock()
+	op, ok := r.ncOperations[req.NetworkContainerid]
+	if ok && op.done && reflect.DeepEqual(op.req, req) {
+		return false, nil
+	}
+	if !ok || !reflect.DeepEqual(op.req, req) {
+		id, code := async.CreateOrUpdateNetworkContainerAsync(req)
+		if err := restserver.ResponseCodeToError(code); err != nil {
+			return false, err
+		}
+		op = &ncOperation{id: id, req: req}
+		r.ncOperations[req.NetworkContainerid] = op
+	}
+
+	status, code := async.GetOperationStatus(op.id)
+	if err := restserver.ResponseCodeToError(code); err != nil {
+		// the next reconcile programs the NC again
+		delete(r.ncOperations, req.NetworkContainerid)
+		return false, errors.Wrapf(err, "failed to get status of operation %s", op.id)
+	}
+	switch status.State {
+	case cns.OperationPending:
+		return true, nil
+	case cns.OperationFailed:
+		delete(r.ncOperations, req.NetworkContainerid)
+		return false, restserver.ResponseCodeToError(status.ResponseCode)
+	default:
+		op.done = true
+		return false, nil
+	}
+}
+
 // Started blocks until the Reconciler has reconciled at least once,
 // then, and any time that it is called after that, it immediately returns true.
 // It accepts a cancellable Context and if the context is closed
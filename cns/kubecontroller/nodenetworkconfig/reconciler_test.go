@@ -46,6 +46,25 @@ func (m *mockNCGetter) Get(ctx context.Context, key types.NamespacedName) (*v1al
 	return m.get(ctx, key)
 }
 
+var validMultitenantStatus = v1alpha.NodeNetworkConfigStatus{
+	NetworkContainers: []v1alpha.NetworkContainer{
+		func() v1alpha.NetworkContainer {
+			nc := validSwiftNC
+			nc.Type = v1alpha.MultitenantVNET
+			nc.EncapID = 5000
+			nc.TenantID = tenantID
+			return nc
+		}(),
+	},
+}
+
+var validMultitenantRequest = func() *cns.CreateNetworkContainerRequest {
+	req := *validSwiftRequest
+	req.MultiTenancyInfo = cns.MultiTenancyInfo{EncapType: cns.Vxlan, ID: 5000}
+	req.TenantID = tenantID
+	return &req
+}()
+
 func TestReconcile(t *testing.T) {
 	logger.InitLogger("", 0, 0, "")
 	tests := []struct {
@@ -146,6 +165,44 @@ func TestReconcile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "multitenant NC",
+			ncGetter: mockNCGetter{
+				get: func(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+					return &v1alpha.NodeNetworkConfig{
+						Status: validMultitenantStatus,
+					}, nil
+				},
+			},
+			cnsClient: mockCNSClient{
+				createOrUpdateNC: func(*cns.CreateNetworkContainerRequest) cnstypes.ResponseCode {
+					return cnstypes.Success
+				},
+				update: func(*v1alpha.NodeNetworkConfig) error {
+					return nil
+				},
+			},
+			wantErr: false,
+			wantCNSClientState: cnsClientState{
+				req: validMultitenantRequest,
+				nnc: &v1alpha.NodeNetworkConfig{
+					Status: validMultitenantStatus,
+				},
+			},
+		},
+		{
+			name: "invalid multitenant NC",
+			ncGetter: mockNCGetter{
+				get: func(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+					status := *validMultitenantStatus.DeepCopy()
+					status.NetworkContainers[0].EncapID = 0
+					return &v1alpha.NodeNetworkConfig{
+						Status: status,
+					}, nil
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "node IP mismatch",
 			ncGetter: mockNCGetter{
@@ -174,7 +231,7 @@ func TestReconcile(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			r := NewReconciler(&tt.cnsClient, &tt.cnsClient, tt.nodeIP)
+			r := NewReconciler(&tt.cnsClient, &tt.cnsClient, tt.nodeIP, nil)
 			r.nnccli = &tt.ncGetter
 			got, err := r.Reconcile(context.Background(), tt.in)
 			if tt.wantErr {
@@ -187,3 +244,130 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+type mockAsyncCNSClient struct {
+	mockCNSClient
+	submitted int
+	state     cns.OperationState
+	code      cnstypes.ResponseCode
+}
+
+func (m *mockAsyncCNSClient) CreateOrUpdateNetworkContainerAsync(req *cns.CreateNetworkContainerRequest) (string, cnstypes.ResponseCode) {
+	m.mockCNSClient.state.req = req
+	m.submitted++
+	m.state = cns.OperationPending
+	return "op", cnstypes.Success
+}
+
+func (m *mockAsyncCNSClient) GetOperationStatus(id string) (cns.OperationStatus, cnstypes.ResponseCode) {
+	return cns.OperationStatus{OperationID: id, State: m.state, ResponseCode: m.code}, cnstypes.Success
+}
+
+func TestReconcileAsync(t *testing.T) {
+	logger.InitLogger("", 0, 0, "")
+	cnsClient := &mockAsyncCNSClient{
+		mockCNSClient: mockCNSClient{
+			update: func(*v1alpha.NodeNetworkConfig) error { return nil },
+		},
+	}
+	r := NewReconciler(cnsClient, cnsClient, "", nil)
+	r.nnccli = &mockNCGetter{
+		get: func(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+			return &v1alpha.NodeNetworkConfig{Status: validSwiftStatus}, nil
+		},
+	}
+
+	// the NNC is requeued without notifying the listeners while the NC is programmed
+	got, err := r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{RequeueAfter: pendingNCRequeueDelay}, got)
+	assert.Equal(t, validSwiftRequest, cnsClient.mockCNSClient.state.req)
+	assert.Nil(t, cnsClient.mockCNSClient.state.nnc)
+
+	got, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{RequeueAfter: pendingNCRequeueDelay}, got)
+	assert.Equal(t, 1, cnsClient.submitted)
+
+	cnsClient.state = cns.OperationSucceeded
+	got, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, got)
+	assert.NotNil(t, cnsClient.mockCNSClient.state.nnc)
+
+	// the programmed NC isn't programmed again
+	_, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnsClient.submitted)
+}
+
+func TestReconcileAsyncFailure(t *testing.T) {
+	logger.InitLogger("", 0, 0, "")
+	cnsClient := &mockAsyncCNSClient{}
+	r := NewReconciler(cnsClient, cnsClient, "", nil)
+	r.nnccli = &mockNCGetter{
+		get: func(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+			return &v1alpha.NodeNetworkConfig{Status: validSwiftStatus}, nil
+		},
+	}
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+
+	cnsClient.state = cns.OperationFailed
+	cnsClient.code = cnstypes.UnexpectedError
+	_, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.Error(t, err)
+
+	// the failed NC is programmed again
+	_, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnsClient.submitted)
+}
+
+type mockNamespaceSubnetsCNSClient struct {
+	mockCNSClient
+	ncIDsByNamespace map[string][]string
+}
+
+func (m *mockNamespaceSubnetsCNSClient) SetNamespaceSubnets(ncIDsByNamespace map[string][]string) {
+	m.ncIDsByNamespace = ncIDsByNamespace
+}
+
+func TestReconcileNamespaceSubnets(t *testing.T) {
+	logger.InitLogger("", 0, 0, "")
+	otherNC := validSwiftNC
+	otherNC.ID = "other-nc"
+	otherNC.SubnetName = "other-subnet"
+	nnc := &v1alpha.NodeNetworkConfig{
+		Spec: v1alpha.NodeNetworkConfigSpec{
+			NamespaceSubnets: []v1alpha.NamespaceSubnet{
+				{SubnetName: "other-subnet", Namespaces: []string{"team-a", "team-b"}},
+				{SubnetName: "missing-subnet", Namespaces: []string{"team-c"}},
+			},
+		},
+		Status: v1alpha.NodeNetworkConfigStatus{NetworkContainers: []v1alpha.NetworkContainer{validSwiftNC, otherNC}},
+	}
+	cnsClient := &mockNamespaceSubnetsCNSClient{
+		mockCNSClient: mockCNSClient{
+			createOrUpdateNC: func(*cns.CreateNetworkContainerRequest) cnstypes.ResponseCode { return cnstypes.Success },
+			update:           func(*v1alpha.NodeNetworkConfig) error { return nil },
+		},
+	}
+	r := NewReconciler(cnsClient, cnsClient, "", nil)
+	r.nnccli = &mockNCGetter{
+		get: func(context.Context, types.NamespacedName) (*v1alpha.NodeNetworkConfig, error) {
+			return nnc, nil
+		},
+	}
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"team-a": {"other-nc"}, "team-b": {"other-nc"}, "team-c": nil}, cnsClient.ncIDsByNamespace)
+
+	// the mode is disabled once the mapping is removed
+	nnc.Spec.NamespaceSubnets = nil
+	_, err = r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Nil(t, cnsClient.ncIDsByNamespace)
+}
@@ -34,3 +34,9 @@ func (sc *ScopedClient) UpdateSpec(ctx context.Context, spec *v1alpha.NodeNetwor
 	nnc, err := sc.Client.UpdateSpec(ctx, sc.NamespacedName, spec)
 	return nnc, errors.Wrapf(err, "failed to update nnc %v", sc.NamespacedName)
 }
+
+// SetAnnotation sets the annotation of the associated NodeNetworkConfig to value, or removes it if value is empty.
+func (sc *ScopedClient) SetAnnotation(ctx context.Context, annotation, value string) (*v1alpha.NodeNetworkConfig, error) {
+	nnc, err := sc.Client.SetAnnotation(ctx, sc.NamespacedName, annotation, value)
+	return nnc, errors.Wrapf(err, "failed to set annotation of nnc %v", sc.NamespacedName)
+}
@@ -0,0 +1,130 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// The SLI bundle is a small, fixed set of metrics that fleet alerting is
+// expected to be built on. Unlike the rest of the CNS metrics, which are free
+// to be renamed or relabeled as the code evolves, the names, types, and label
+// sets of the SLI metrics are a stable contract:
+//
+//   - a metric in the bundle is never renamed and its type never changes.
+//   - labels are never removed and label values are never renamed.
+//   - new labels or new SLIs may only be added with a bump of SLIVersion.
+//
+// See docs/cns-sli.md for the full list and suggested alert expressions.
+
+// SLIVersion is the version of the SLI metrics contract. It is exported as the
+// "version" label on cns_sli_info so that alerts can key off of it.
+const SLIVersion = "v1"
+
+const (
+	sliNamespace = "cns"
+	sliSubsystem = "sli"
+
+	resultLabel   = "result"
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var (
+	sliInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "info",
+			Help:      "SLI bundle contract version. Always 1.",
+		},
+		[]string{"version"},
+	)
+	sliIPAllocations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "ip_allocations_total",
+			Help:      "Count of pod IP allocation requests by result.",
+		},
+		[]string{resultLabel},
+	)
+	sliIPAllocationLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "ip_allocation_duration_seconds",
+			Help:      "Pod IP allocation request latency in seconds.",
+			//nolint:gomnd // default bucket consts
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 15), // 1 ms to ~16 seconds
+		},
+	)
+	sliPoolAvailableIPs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "ip_pool_available_ips",
+			Help:      "Count of IPs in the pool which are available for assignment.",
+		},
+	)
+	sliPoolTotalIPs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "ip_pool_total_ips",
+			Help:      "Count of IPs in the pool.",
+		},
+	)
+	sliReconciles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: sliNamespace,
+			Subsystem: sliSubsystem,
+			Name:      "reconciles_total",
+			Help:      "Count of NodeNetworkConfig reconciles by result.",
+		},
+		[]string{resultLabel},
+	)
+)
+
+func resultLabelValue(ok bool) string {
+	if ok {
+		return resultSuccess
+	}
+	return resultFailure
+}
+
+// ObserveIPAllocation records the result and latency of a pod IP allocation request.
+func ObserveIPAllocation(ok bool, latency time.Duration) {
+	sliIPAllocations.WithLabelValues(resultLabelValue(ok)).Inc()
+	sliIPAllocationLatency.Observe(latency.Seconds())
+}
+
+// ObservePoolAvailability records the current available and total IP counts of the pool.
+func ObservePoolAvailability(available, total int) {
+	sliPoolAvailableIPs.Set(float64(available))
+	sliPoolTotalIPs.Set(float64(total))
+}
+
+// ObserveReconcile records the result of a NodeNetworkConfig reconcile.
+func ObserveReconcile(err error) {
+	sliReconciles.WithLabelValues(resultLabelValue(err == nil)).Inc()
+}
+
+func init() {
+	sliInfo.WithLabelValues(SLIVersion).Set(1)
+	// pre-initialize the result series so that rate() based alerts have a
+	// zero baseline instead of an absent series.
+	for _, result := range []string{resultSuccess, resultFailure} {
+		sliIPAllocations.WithLabelValues(result)
+		sliReconciles.WithLabelValues(result)
+	}
+	metrics.Registry.MustRegister(
+		sliInfo,
+		sliIPAllocations,
+		sliIPAllocationLatency,
+		sliPoolAvailableIPs,
+		sliPoolTotalIPs,
+		sliReconciles,
+	)
+}
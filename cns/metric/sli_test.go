@@ -0,0 +1,54 @@
+package metric
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+func TestObserveIPAllocation(t *testing.T) {
+	success := testutil.ToFloat64(sliIPAllocations.WithLabelValues(resultSuccess))
+	failure := testutil.ToFloat64(sliIPAllocations.WithLabelValues(resultFailure))
+	ObserveIPAllocation(true, time.Millisecond)
+	ObserveIPAllocation(false, time.Millisecond)
+	ObserveIPAllocation(true, time.Millisecond)
+	assert.InDelta(t, success+2, testutil.ToFloat64(sliIPAllocations.WithLabelValues(resultSuccess)), 0)
+	assert.InDelta(t, failure+1, testutil.ToFloat64(sliIPAllocations.WithLabelValues(resultFailure)), 0)
+}
+
+func TestObserveReconcile(t *testing.T) {
+	failure := testutil.ToFloat64(sliReconciles.WithLabelValues(resultFailure))
+	ObserveReconcile(errors.New("reconcile failed"))
+	assert.InDelta(t, failure+1, testutil.ToFloat64(sliReconciles.WithLabelValues(resultFailure)), 0)
+}
+
+func TestObservePoolAvailability(t *testing.T) {
+	ObservePoolAvailability(8, 16)
+	assert.InDelta(t, 8, testutil.ToFloat64(sliPoolAvailableIPs), 0)
+	assert.InDelta(t, 16, testutil.ToFloat64(sliPoolTotalIPs), 0)
+}
+
+// TestSLIMetricNames guards the SLI contract: these names must never change.
+func TestSLIMetricNames(t *testing.T) {
+	mfs, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	got := map[string]bool{}
+	for _, mf := range mfs {
+		got[mf.GetName()] = true
+	}
+	for _, name := range []string{
+		"cns_sli_info",
+		"cns_sli_ip_allocations_total",
+		"cns_sli_ip_allocation_duration_seconds",
+		"cns_sli_ip_pool_available_ips",
+		"cns_sli_ip_pool_total_ips",
+		"cns_sli_reconciles_total",
+	} {
+		assert.True(t, got[name], "missing SLI metric %s", name)
+	}
+}
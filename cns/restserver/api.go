@@ -848,6 +848,39 @@ func (service *HTTPRestService) createOrUpdateNetworkContainer(w http.ResponseWr
 	logger.Response(service.Name, reserveResp, resp.ReturnCode, err)
 }
 
+func (service *HTTPRestService) patchNetworkContainer(w http.ResponseWriter, r *http.Request) {
+	logger.Printf("[Azure CNS] patchNetworkContainer")
+
+	var req cns.PatchNetworkContainerRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	var returnCode types.ResponseCode
+	var returnMessage string
+	switch r.Method {
+	case http.MethodPost:
+		returnCode = service.PatchNetworkContainerInternal(&req)
+		if returnCode != types.Success {
+			returnMessage = fmt.Sprintf("[Azure CNS] Error. PatchNetworkContainer failed with %s", returnCode)
+		}
+	default:
+		returnMessage = "[Azure CNS] Error. PatchNetworkContainer did not receive a POST."
+		returnCode = types.InvalidParameter
+	}
+
+	resp := cns.Response{
+		ReturnCode: returnCode,
+		Message:    returnMessage,
+	}
+
+	patchResp := &cns.PatchNetworkContainerResponse{Response: resp}
+	err = service.Listener.Encode(w, &patchResp)
+	logger.Response(service.Name, patchResp, resp.ReturnCode, err)
+}
+
 func (service *HTTPRestService) getNetworkContainerByID(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("[Azure CNS] getNetworkContainerByID")
 
@@ -15,6 +15,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -35,6 +36,9 @@ const (
 	cnsJsonFileName = "azure-cns.json"
 )
 
+// cnsJsonFilePath is the store of the test service, in the temporary directory created by TestMain.
+var cnsJsonFilePath string
+
 type IPAddress struct {
 	XMLName   xml.Name `xml:"IPAddress"`
 	Address   string   `xml:"Address,attr"`
@@ -172,6 +176,13 @@ func TestMain(m *testing.M) {
 	var err error
 	logger.InitLogger("testlogs", 0, 0, "./")
 
+	storeDir, err := os.MkdirTemp("", "cns-restserver")
+	if err != nil {
+		fmt.Printf("Failed to create the store directory. Error: %v", err)
+		os.Exit(1)
+	}
+	cnsJsonFilePath = filepath.Join(storeDir, cnsJsonFileName)
+
 	// Create the service.
 	if err = startService(); err != nil {
 		fmt.Printf("Failed to start CNS Service. Error: %v", err)
@@ -204,6 +215,7 @@ func TestMain(m *testing.M) {
 	// Cleanup.
 	service.Stop()
 	nmAgentServer.Stop()
+	os.RemoveAll(storeDir)
 
 	os.Exit(exitCode)
 }
@@ -1671,9 +1683,9 @@ func startService() error {
 	config := common.ServiceConfig{}
 
 	// Create the key value fileStore.
-	fileStore, err := store.NewJsonFileStore(cnsJsonFileName, processlock.NewMockFileLock(false))
+	fileStore, err := store.NewJsonFileStore(cnsJsonFilePath, processlock.NewMockFileLock(false))
 	if err != nil {
-		logger.Errorf("Failed to create store file: %s, due to error %v\n", cnsJsonFileName, err)
+		logger.Errorf("Failed to create store file: %s, due to error %v\n", cnsJsonFilePath, err)
 		return err
 	}
 	config.Store = fileStore
@@ -1718,7 +1730,7 @@ func startService() error {
 
 	if service != nil {
 		// Create empty azure-cns.json. CNS should start successfully by deleting this file
-		file, _ := os.Create(cnsJsonFileName)
+		file, _ := os.Create(cnsJsonFilePath)
 		file.Close()
 
 		err = service.Init(&config)
@@ -1733,9 +1745,9 @@ func startService() error {
 			return err
 		}
 
-		if _, err := os.Stat(cnsJsonFileName); err == nil || !os.IsNotExist(err) {
-			logger.Errorf("Failed to remove empty CNS state file: %s, err:%v", cnsJsonFileName, err)
-			return err
+		if _, err := os.Stat(cnsJsonFilePath); err == nil || !os.IsNotExist(err) {
+			logger.Errorf("Failed to remove empty CNS state file: %s, err:%v", cnsJsonFilePath, err)
+			return fmt.Errorf("empty CNS state file %s wasn't removed: %w", cnsJsonFilePath, err)
 		}
 	}
 
@@ -0,0 +1,19 @@
+package restserver
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	acn "github.com/Azure/azure-container-networking/common"
+)
+
+// newHandlerFuncWithCorrelationID logs the correlation ID of the CNI invocation which sent the request, if any, so that
+// the logs of the request can be found from the logs and telemetry of the CNI.
+func newHandlerFuncWithCorrelationID(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if id := req.Header.Get(acn.CorrelationIDHeader); id != "" {
+			logger.Printf("[Azure CNS] Received %s %s with correlation ID %s", req.Method, req.URL.Path, id)
+		}
+		handler(w, req)
+	}
+}
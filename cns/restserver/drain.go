@@ -0,0 +1,72 @@
+package restserver
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+// isDraining returns whether the IP pool of the node is being drained, in which case no new IPs are assigned to Pods.
+func (service *HTTPRestService) isDraining() bool {
+	return service.IPAMPoolMonitor != nil && service.IPAMPoolMonitor.IsDraining()
+}
+
+// hasAssignedIPs returns whether IPs are already assigned to the Pod, which are returned again even while draining.
+func (service *HTTPRestService) hasAssignedIPs(podInfo cns.PodInfo) bool {
+	service.RLock()
+	defer service.RUnlock()
+	return len(service.PodIPIDByPodInterfaceKey[podInfo.Key()]) > 0
+}
+
+// drainStatus returns the progress of draining the IP pool of the node.
+func (service *HTTPRestService) drainStatus() cns.DrainStatus {
+	status := cns.DrainStatus{
+		Draining:        service.isDraining(),
+		AssignedIPCount: len(service.GetAssignedIPConfigs()),
+		PoolSize:        len(service.GetPodIPConfigState()),
+	}
+	status.Drained = status.Draining && status.PoolSize == 0
+	return status
+}
+
+// drainHandler returns the drain status on GET, and starts or stops draining the IP pool of the node on POST.
+func (service *HTTPRestService) drainHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.DrainRequest
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := service.Listener.Decode(w, r, &req)
+		logger.Request(service.Name, req, err)
+		if err != nil {
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resp cns.DrainResponse
+	switch {
+	case service.IPAMPoolMonitor == nil:
+		resp.Response = cns.Response{
+			ReturnCode: types.UnsupportedAPI,
+			Message:    "draining requires the IPAM pool monitor",
+		}
+	case r.Method == http.MethodPost:
+		logger.Printf("[Azure CNS] Setting IP pool draining to %t", req.Drain)
+		if err := service.IPAMPoolMonitor.SetDraining(r.Context(), req.Drain); err != nil {
+			resp.Response = cns.Response{
+				ReturnCode: types.UnexpectedError,
+				Message:    "failed to set draining: " + err.Error(),
+			}
+		}
+		fallthrough
+	default:
+		resp.Status = service.drainStatus()
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
@@ -0,0 +1,53 @@
+package restserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/fakes"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIPConfigsWhileDraining(t *testing.T) {
+	svc := getTestService()
+	monitor := &fakes.MonitorFake{}
+	svc.IPAMPoolMonitor = monitor
+
+	ipconfigs := make(map[string]cns.IPConfigurationStatus)
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testIPID1, testNCID, types.Assigned, ipPrefixBitsv4, 0, testPod1Info)
+	ipconfigs[state1.ID] = state1
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testIPID2, testNCID, types.Available, ipPrefixBitsv4, 0, nil)
+	ipconfigs[state2.ID] = state2
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	require.NoError(t, monitor.SetDraining(context.Background(), true))
+
+	// a new Pod doesn't get an IP
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:   testPod2Info.InterfaceID(),
+		InfraContainerID: testPod2Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod2Info.OrchestratorContext()
+	resp, err := svc.requestIPConfigHandlerHelper(req)
+	require.Error(t, err)
+	assert.Equal(t, types.NodeDraining, resp.Response.ReturnCode)
+
+	// a Pod which already has an IP gets it again
+	req = cns.IPConfigsRequest{
+		PodInterfaceID:   testPod1Info.InterfaceID(),
+		InfraContainerID: testPod1Info.InfraContainerID(),
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	resp, err = svc.requestIPConfigHandlerHelper(req)
+	require.NoError(t, err)
+	require.Len(t, resp.PodIPInfo, 1)
+	assert.Equal(t, testIP1, resp.PodIPInfo[0].PodIPConfig.IPAddress)
+
+	assert.Equal(t, cns.DrainStatus{Draining: true, AssignedIPCount: 1, PoolSize: 2}, svc.drainStatus())
+
+	require.NoError(t, monitor.SetDraining(context.Background(), false))
+	assert.Equal(t, cns.DrainStatus{AssignedIPCount: 1, PoolSize: 2}, svc.drainStatus())
+}
@@ -0,0 +1,149 @@
+package restserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// healthCheckTimeout bounds each dependency check, so that probes answer before kubelet times them out.
+const healthCheckTimeout = 3 * time.Second
+
+// ErrIPAMPoolEmpty is the health of the IPAM pool before the first NodeNetworkConfig with IPs is reconciled.
+var ErrIPAMPoolEmpty = errors.New("IPAM pool has no IPs")
+
+// HealthCheck checks a dependency of CNS for the health endpoints.
+type HealthCheck struct {
+	Name string
+	// Liveness checks are also run by the liveness endpoint, so that kubelet restarts CNS when they fail.
+	// Only dependencies which a restart can fix should be liveness checks.
+	Liveness bool
+	Check    func(ctx context.Context) error
+}
+
+type healthChecks struct {
+	sync.Mutex
+	checks []HealthCheck
+}
+
+// AddHealthCheck adds a dependency to the health endpoints.
+func (service *HTTPRestService) AddHealthCheck(check HealthCheck) {
+	service.healthChecks.Lock()
+	defer service.healthChecks.Unlock()
+	service.healthChecks.checks = append(service.healthChecks.checks, check)
+}
+
+// addDefaultHealthChecks adds the dependencies of CNS in all modes: the state store and the wireserver.
+func (service *HTTPRestService) addDefaultHealthChecks() {
+	if service.store != nil {
+		service.AddHealthCheck(HealthCheck{
+			Name:     "state-store",
+			Liveness: true,
+			Check: func(context.Context) error {
+				// rewriting the current state checks that the store file can be written without changing it.
+				return errors.Wrap(service.store.Flush(), "failed to write state store")
+			},
+		})
+	}
+
+	if service.wscli != nil {
+		service.AddHealthCheck(HealthCheck{
+			Name: "wireserver",
+			Check: func(ctx context.Context) error {
+				_, err := service.wscli.GetInterfaces(ctx)
+				return errors.Wrap(err, "failed to get interfaces from wireserver")
+			},
+		})
+	}
+}
+
+// IPAMPoolHealthCheck checks that the IPAM pool has IPs. An exhausted pool is healthy, since IPs are released
+// and reassigned as pods churn, so this only fails until the first NodeNetworkConfig with IPs is reconciled.
+func (service *HTTPRestService) IPAMPoolHealthCheck() HealthCheck {
+	return HealthCheck{
+		Name: "ipam-pool",
+		Check: func(context.Context) error {
+			if len(service.GetPodIPConfigState()) == 0 {
+				return ErrIPAMPoolEmpty
+			}
+			return nil
+		},
+	}
+}
+
+// checkHealth runs the liveness checks, or all the checks for readiness, concurrently.
+func (service *HTTPRestService) checkHealth(ctx context.Context, livenessOnly bool) cns.HealthResponse {
+	service.healthChecks.Lock()
+	checks := make([]HealthCheck, 0, len(service.healthChecks.checks))
+	for _, check := range service.healthChecks.checks {
+		if check.Liveness || !livenessOnly {
+			checks = append(checks, check)
+		}
+	}
+	service.healthChecks.Unlock()
+
+	resp := cns.HealthResponse{
+		Healthy:      true,
+		Dependencies: make([]cns.DependencyHealth, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checks[i].Check(checkCtx)
+			resp.Dependencies[i] = cns.DependencyHealth{
+				Name:      checks[i].Name,
+				Healthy:   err == nil,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				resp.Dependencies[i].Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range resp.Dependencies {
+		resp.Healthy = resp.Healthy && resp.Dependencies[i].Healthy
+	}
+	return resp
+}
+
+func (service *HTTPRestService) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	service.writeHealth(w, r, true)
+}
+
+func (service *HTTPRestService) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	service.writeHealth(w, r, false)
+}
+
+// writeHealth responds with the health of the dependencies, and with 503 if any is unhealthy so that probes fail.
+func (service *HTTPRestService) writeHealth(w http.ResponseWriter, r *http.Request, livenessOnly bool) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := service.checkHealth(r.Context(), livenessOnly)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if resp.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		logger.Printf("[Azure CNS] Health check of %s failed: %+v", r.URL.Path, resp.Dependencies)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	// the status is already written, so the encode error can only be logged.
+	_ = service.Listener.Encode(w, &resp)
+}
@@ -0,0 +1,75 @@
+package restserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	svc := getTestService()
+	svc.store = store.NewMockStore("")
+	svc.addDefaultHealthChecks()
+	svc.AddHealthCheck(svc.IPAMPoolHealthCheck())
+
+	errNotSynced := errors.New("not synced")
+	synced := false
+	svc.AddHealthCheck(HealthCheck{
+		Name: "nnc-informer",
+		Check: func(context.Context) error {
+			if !synced {
+				return errNotSynced
+			}
+			return nil
+		},
+	})
+
+	get := func(handler http.HandlerFunc, path string) (int, cns.HealthResponse) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		var resp cns.HealthResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return w.Code, resp
+	}
+	names := func(resp cns.HealthResponse, healthy bool) []string {
+		var result []string
+		for _, dep := range resp.Dependencies {
+			if dep.Healthy == healthy {
+				result = append(result, dep.Name)
+			}
+		}
+		return result
+	}
+
+	// liveness only checks the state store
+	code, resp := get(svc.healthzHandler, cns.HealthzPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Healthy)
+	assert.Equal(t, []string{"state-store"}, names(resp, true))
+
+	// not ready before the NNC is synced and the pool has IPs
+	code, resp = get(svc.readyzHandler, cns.ReadyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.False(t, resp.Healthy)
+	assert.Equal(t, []string{"state-store", "wireserver"}, names(resp, true))
+	assert.Equal(t, []string{"ipam-pool", "nnc-informer"}, names(resp, false))
+	assert.Equal(t, ErrIPAMPoolEmpty.Error(), resp.Dependencies[2].Error)
+
+	synced = true
+	state, _ := NewPodStateWithOrchestratorContext(testIP1, testIPID1, testNCID, types.Available, ipPrefixBitsv4, 0, nil)
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{state.ID: state}, testNCID))
+
+	code, resp = get(svc.readyzHandler, cns.ReadyzPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Healthy)
+	assert.Len(t, resp.Dependencies, 4)
+}
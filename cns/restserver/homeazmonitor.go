@@ -43,6 +43,15 @@ func (h *HomeAzMonitor) GetHomeAz(_ context.Context) cns.GetHomeAzResponse {
 	return h.readCacheValue()
 }
 
+// HomeAz returns the cached home az, or 0 if it isn't known, e.g. because nmagent doesn't support the getHomeAz api.
+func (h *HomeAzMonitor) HomeAz() uint {
+	resp := h.readCacheValue()
+	if resp.Response.ReturnCode != types.Success || !resp.HomeAzResponse.IsSupported {
+		return 0
+	}
+	return resp.HomeAzResponse.HomeAz
+}
+
 // updateCacheValue updates home az cache value
 func (h *HomeAzMonitor) updateCacheValue(resp cns.GetHomeAzResponse) {
 	h.values.Set(homeAzCacheKey, resp, cache.NoExpiration)
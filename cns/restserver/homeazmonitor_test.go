@@ -87,6 +87,11 @@ func TestHomeAzMonitor(t *testing.T) {
 				t.Error("homeAz cache differs from expectation: diff:", cmp.Diff(getHomeAzResponse.HomeAzResponse, test.homeAzExp))
 			}
 
+			// only a successfully retrieved home az is returned to the callers of the ipam apis
+			if homeAz := homeAzMonitor.HomeAz(); test.shouldErr && homeAz != 0 || !test.shouldErr && homeAz != test.homeAzExp.HomeAz {
+				t.Errorf("unexpected home az %d", homeAz)
+			}
+
 			// check returnCode for error
 			if getHomeAzResponse.Response.ReturnCode != types.Success && !test.shouldErr {
 				t.Fatal("unexpected error: ", getHomeAzResponse.Response.Message)
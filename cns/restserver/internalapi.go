@@ -167,7 +167,9 @@ func (service *HTTPRestService) SyncHostNCVersion(ctx context.Context, channelMo
 	start := time.Now()
 	programmedNCCount, err := service.syncHostNCVersion(ctx, channelMode)
 	// even if we get an error, we want to write the CNI conflist if we have any NC programmed to any version
-	if programmedNCCount > 0 {
+	if remover, ok := service.cniConflistGenerator.(CNIConflistRemover); ok {
+		service.updateCNIConflistReadiness(remover, programmedNCCount)
+	} else if programmedNCCount > 0 {
 		// This will only be done once per lifetime of the CNS process. This function is threadsafe and will panic
 		// if it fails, so it is safe to call in a non-preemptable goroutine.
 		go service.MustGenerateCNIConflistOnce()
@@ -413,7 +415,13 @@ func (service *HTTPRestService) CreateOrUpdateNetworkContainerInternal(req *cns.
 
 	// This will Create Or Update the NC state.
 	returnCode, returnMessage := service.saveNetworkContainerGoalState(*req)
+	return service.completeNetworkContainerUpdate(req, returnCode, returnMessage)
+}
 
+// completeNetworkContainerUpdate logs the result of saving the NC state and programs its SNAT rules.
+func (service *HTTPRestService) completeNetworkContainerUpdate(req *cns.CreateNetworkContainerRequest,
+	returnCode types.ResponseCode, returnMessage string,
+) types.ResponseCode {
 	// If the NC was created successfully, log NC snapshot.
 	if returnCode == 0 {
 		logNCSnapshot(*req)
@@ -432,3 +440,35 @@ func (service *HTTPRestService) CreateOrUpdateNetworkContainerInternal(req *cns.
 
 	return returnCode
 }
+
+// PatchNetworkContainerInternal applies the changes to the secondary IPs of an NC since the base version of the patch,
+// and updates the NC as CreateOrUpdateNetworkContainerInternal would with the whole NC. Only the IPs the patch adds or
+// removes change state.
+func (service *HTTPRestService) PatchNetworkContainerInternal(req *cns.PatchNetworkContainerRequest) types.ResponseCode {
+	if req.NetworkContainerid == "" {
+		logger.Errorf("[Azure CNS] Error. NetworkContainerid is empty")
+		return types.NetworkContainerNotSpecified
+	}
+
+	if service.state.OrchestratorType != cns.KubernetesCRD && service.state.OrchestratorType != cns.Kubernetes {
+		logger.Errorf("[Azure CNS] Error. Unsupported OrchestratorType: %s", service.state.OrchestratorType)
+		return types.UnsupportedOrchestratorType
+	}
+
+	for _, secIPConfig := range req.AddedSecondaryIPConfigs {
+		if secIPConfig.IPAddress == "" {
+			logger.Errorf("Failed to add IPConfig to state: %+v, empty IPSubnet.IPAddress", secIPConfig)
+			return types.InvalidSecondaryIPConfig
+		}
+	}
+
+	logger.Printf("[Azure CNS] Patching NC %s from version %s to %s, adding %d and removing %d secondary IPs",
+		req.NetworkContainerid, req.BaseVersion, req.Version, len(req.AddedSecondaryIPConfigs), len(req.RemovedSecondaryIPConfigs))
+	// the base version is checked under the lock the patched NC is saved with
+	patched, returnCode, returnMessage := service.patchNetworkContainerGoalState(req)
+	if returnCode == types.UnknownContainerID || returnCode == types.NetworkContainerVersionMismatch {
+		logger.Errorf(returnMessage)
+		return returnCode
+	}
+	return service.completeNetworkContainerUpdate(&patched, returnCode, returnMessage)
+}
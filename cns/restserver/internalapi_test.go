@@ -206,6 +206,84 @@ func TestCreateAndUpdateNCWithSecondaryIPNCVersion(t *testing.T) {
 	}
 }
 
+func TestPatchNetworkContainerInternal(t *testing.T) {
+	restartService()
+	setEnv(t)
+	setOrchestratorTypeInternal(cns.KubernetesCRD)
+
+	keptID, removedID, addedID := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	secondaryIPConfigs := map[string]cns.SecondaryIPConfig{
+		keptID:    newSecondaryIPConfig("10.0.0.16", 0),
+		removedID: newSecondaryIPConfig("10.0.0.17", 0),
+	}
+	createNCReqInternal(t, secondaryIPConfigs, ncID, "0")
+	keptBefore := svc.PodIPConfigState[keptID]
+
+	patch := &cns.PatchNetworkContainerRequest{
+		NetworkContainerid:        ncID,
+		BaseVersion:               "0",
+		Version:                   "1",
+		AddedSecondaryIPConfigs:   map[string]cns.SecondaryIPConfig{addedID: newSecondaryIPConfig("10.0.0.18", 1)},
+		RemovedSecondaryIPConfigs: []string{removedID},
+	}
+	returnCode := svc.PatchNetworkContainerInternal(patch)
+	assert.Equal(t, types.Success, returnCode)
+
+	containerStatus := svc.state.ContainerStatus[ncID]
+	assert.Equal(t, "1", containerStatus.CreateNetworkContainerRequest.Version)
+	assert.Len(t, containerStatus.CreateNetworkContainerRequest.SecondaryIPConfigs, 2)
+	assert.Contains(t, svc.PodIPConfigState, keptID)
+	assert.Contains(t, svc.PodIPConfigState, addedID)
+	assert.NotContains(t, svc.PodIPConfigState, removedID)
+	// the IP added by the patch is pending programming until NMAgent has the new version, the kept IP is untouched
+	added, kept := svc.PodIPConfigState[addedID], svc.PodIPConfigState[keptID]
+	assert.Equal(t, types.PendingProgramming, added.GetState())
+	assert.Equal(t, keptBefore.GetState(), kept.GetState())
+
+	// the same patch doesn't apply to the new version
+	returnCode = svc.PatchNetworkContainerInternal(patch)
+	assert.Equal(t, types.NetworkContainerVersionMismatch, returnCode)
+
+	patch.NetworkContainerid = "unknown"
+	returnCode = svc.PatchNetworkContainerInternal(patch)
+	assert.Equal(t, types.UnknownContainerID, returnCode)
+}
+
+func TestConcurrentPatchNetworkContainerInternal(t *testing.T) {
+	restartService()
+	setEnv(t)
+	setOrchestratorTypeInternal(cns.KubernetesCRD)
+	createNCReqInternal(t, map[string]cns.SecondaryIPConfig{uuid.New().String(): newSecondaryIPConfig("10.0.0.16", 0)}, ncID, "0")
+
+	// only one of the patches of the same base version applies, the other one is based on a stale NC
+	const patches = 2
+	returnCodes := make(chan types.ResponseCode, patches)
+	var wg sync.WaitGroup
+	for i := 0; i < patches; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			returnCodes <- svc.PatchNetworkContainerInternal(&cns.PatchNetworkContainerRequest{
+				NetworkContainerid: ncID,
+				BaseVersion:        "0",
+				Version:            "1",
+				AddedSecondaryIPConfigs: map[string]cns.SecondaryIPConfig{
+					uuid.New().String(): newSecondaryIPConfig(fmt.Sprintf("10.0.0.%d", 17+i), 1),
+				},
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(returnCodes)
+
+	var got []types.ResponseCode
+	for returnCode := range returnCodes {
+		got = append(got, returnCode)
+	}
+	assert.ElementsMatch(t, []types.ResponseCode{types.Success, types.NetworkContainerVersionMismatch}, got)
+	assert.Len(t, svc.state.ContainerStatus[ncID].CreateNetworkContainerRequest.SecondaryIPConfigs, 2)
+}
+
 func TestSyncHostNCVersion(t *testing.T) {
 	// cns.KubernetesCRD has one more logic compared to other orchestrator type, so test both of them
 	orchestratorTypes := []string{cns.Kubernetes, cns.KubernetesCRD}
@@ -761,6 +839,54 @@ func (m *mockCNIConflistGenerator) getGeneratedCount() int {
 	return m.generatedCount
 }
 
+type mockCNIConflistRemover struct {
+	mockCNIConflistGenerator
+	removedCount int
+}
+
+func (m *mockCNIConflistRemover) Remove() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removedCount++
+	return nil
+}
+
+// TestCNIConflistReadinessGate tests that a gating generator writes the conflist once CNS is ready and
+// removes it when CNS is no longer ready
+func TestCNIConflistReadinessGate(t *testing.T) {
+	ncID := "some-existing-nc" //nolint:goconst // value not shared across tests, can change without issue
+	mockgen := &mockCNIConflistRemover{}
+	service := &HTTPRestService{
+		cniConflistGenerator: mockgen,
+		state: &httpRestServiceState{
+			ContainerStatus: map[string]containerstatus{
+				ncID: {
+					ID:          ncID,
+					HostVersion: "0",
+					CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{
+						Version: "0",
+					},
+				},
+			},
+		},
+		PodIPConfigState: map[string]cns.IPConfigurationStatus{},
+	}
+
+	// not ready while the pool is empty
+	service.SyncHostNCVersion(context.Background(), cns.CRD)
+	assert.Equal(t, 0, mockgen.getGeneratedCount())
+
+	service.PodIPConfigState["uuid"] = cns.IPConfigurationStatus{ID: "uuid", NCID: ncID}
+	service.SyncHostNCVersion(context.Background(), cns.CRD)
+	service.SyncHostNCVersion(context.Background(), cns.CRD)
+	assert.Equal(t, 1, mockgen.getGeneratedCount())
+	assert.Equal(t, 0, mockgen.removedCount)
+
+	delete(service.PodIPConfigState, "uuid")
+	service.SyncHostNCVersion(context.Background(), cns.CRD)
+	assert.Equal(t, 1, mockgen.removedCount)
+}
+
 // TestCNIConflistGenerationNewNC tests that discovering a new programmed NC in CNS state will trigger CNI conflist generation
 func TestCNIConflistGenerationNewNC(t *testing.T) {
 	ncID := "some-new-nc" //nolint:goconst // value not shared across tests, can change without issue
@@ -8,10 +8,13 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/filter"
+	"github.com/Azure/azure-container-networking/cns/iphooks"
 	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/metric"
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/pkg/errors"
@@ -20,10 +23,23 @@ import (
 var (
 	ErrStoreEmpty       = errors.New("empty endpoint state store")
 	ErrParsePodIPFailed = errors.New("failed to parse pod's ip")
+	// ErrNoAvailableIPs is returned when the IP pool has no free IP for the Pod, until the pool monitor grows it.
+	ErrNoAvailableIPs = errors.New("not enough IPs available, waiting on Azure CNS to allocate more")
+	// ErrNoNCForIPFamilies is returned when no NC of the Pod is of the IP families it requested.
+	ErrNoNCForIPFamilies = errors.New("no NC of the requested IP families")
+	// ErrDesiredIPUnavailable is returned when a desired IP of the Pod isn't an IP of the NCs, or is assigned to another Pod.
+	ErrDesiredIPUnavailable = errors.New("desired IP is unavailable")
+	// ErrSandboxChanged indicates that the IPs of the pod were assigned to another sandbox of it.
+	ErrSandboxChanged = errors.New("pod sandbox changed")
 )
 
 // requestIPConfigHandlerHelper validates the request, assigns IPs, and returns a response
-func (service *HTTPRestService) requestIPConfigHandlerHelper(ipconfigsRequest cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+func (service *HTTPRestService) requestIPConfigHandlerHelper(ipconfigsRequest cns.IPConfigsRequest) (_ *cns.IPConfigsResponse, err error) {
+	start := time.Now()
+	defer func() {
+		metric.ObserveIPAllocation(err == nil, time.Since(start))
+	}()
+
 	podInfo, returnCode, returnMessage := service.validateIPConfigsRequest(ipconfigsRequest)
 	if returnCode != types.Success {
 		return &cns.IPConfigsResponse{
@@ -34,14 +50,31 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ipconfigsRequest cn
 		}, errors.New("failed to validate ip config request")
 	}
 
+	// while draining, only Pods which already have IPs get them again
+	if service.isDraining() && !service.hasAssignedIPs(podInfo) {
+		return &cns.IPConfigsResponse{
+			Response: cns.Response{
+				ReturnCode: types.NodeDraining,
+				Message:    "IP pool of the node is draining, no new IPs are assigned",
+			},
+		}, errors.New("node is draining")
+	}
+
 	// record a pod requesting an IP
 	service.podsPendingIPAssignment.Push(podInfo.Key())
 
 	podIPInfo, err := requestIPConfigsHelper(service, ipconfigsRequest)
 	if err != nil {
+		returnCode := types.FailedToAllocateIPConfig
+		switch {
+		case errors.Is(err, ErrNoAvailableIPs):
+			returnCode = types.AddressUnavailable
+		case errors.Is(err, ErrDesiredIPUnavailable):
+			returnCode = types.DesiredIPUnavailable
+		}
 		return &cns.IPConfigsResponse{
 			Response: cns.Response{
-				ReturnCode: types.FailedToAllocateIPConfig,
+				ReturnCode: returnCode,
 				Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %s", err, ipconfigsRequest),
 			},
 			PodIPInfo: podIPInfo,
@@ -70,12 +103,22 @@ func (service *HTTPRestService) requestIPConfigHandlerHelper(ipconfigsRequest cn
 		}
 	}
 
-	return &cns.IPConfigsResponse{
+	ips := make([]string, len(podIPInfo))
+	for i := range podIPInfo {
+		ips[i] = podIPInfo[i].PodIPConfig.IPAddress
+	}
+	service.notifyIPHooks(iphooks.EventAssign, podInfo, ips)
+
+	resp := &cns.IPConfigsResponse{
 		Response: cns.Response{
 			ReturnCode: types.Success,
 		},
 		PodIPInfo: podIPInfo,
-	}, nil
+	}
+	if service.homeAzMonitor != nil {
+		resp.HomeAz = service.homeAzMonitor.HomeAz()
+	}
+	return resp, nil
 }
 
 // requestIPConfigHandler requests an IPConfig from the CNS state
@@ -132,6 +175,7 @@ func (service *HTTPRestService) requestIPConfigHandler(w http.ResponseWriter, r
 	reserveResp := &cns.IPConfigResponse{
 		Response:  ipConfigsResp.Response,
 		PodIpInfo: ipConfigsResp.PodIPInfo[0],
+		HomeAz:    ipConfigsResp.HomeAz,
 	}
 	w.Header().Set(cnsReturnCode, reserveResp.Response.ReturnCode.String())
 	err = service.Listener.Encode(w, &reserveResp)
@@ -360,12 +404,18 @@ func (service *HTTPRestService) removeEndpointState(podInfo cns.PodInfo) error {
 // MarkIPAsPendingRelease will set the IPs which are in PendingProgramming or Available to PendingRelease state
 // It will try to update [totalIpsToRelease]  number of ips.
 func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
+	return service.MarkIPFamilyAsPendingRelease(totalIpsToRelease, "")
+}
+
+// MarkIPFamilyAsPendingRelease is MarkIPAsPendingRelease for the IPs of the family, or of any family if it's empty.
+func (service *HTTPRestService) MarkIPFamilyAsPendingRelease(totalIpsToRelease int, family cns.IPFamily) (map[string]cns.IPConfigurationStatus, error) {
 	pendingReleasedIps := make(map[string]cns.IPConfigurationStatus)
 	service.Lock()
 	defer service.Unlock()
+	service.ipReservations.prune(time.Now())
 
 	for uuid, existingIpConfig := range service.PodIPConfigState {
-		if existingIpConfig.GetState() == types.PendingProgramming {
+		if existingIpConfig.GetState() == types.PendingProgramming && !service.ipReservations.reserved(uuid) && isIPFamily(existingIpConfig, family) {
 			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
 			if err != nil {
 				return nil, err
@@ -380,7 +430,7 @@ func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (m
 
 	// if not all expected IPs are set to PendingRelease, then check the Available IPs
 	for uuid, existingIpConfig := range service.PodIPConfigState {
-		if existingIpConfig.GetState() == types.Available {
+		if existingIpConfig.GetState() == types.Available && !service.ipReservations.reserved(uuid) && isIPFamily(existingIpConfig, family) {
 			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
 			if err != nil {
 				return nil, err
@@ -398,6 +448,11 @@ func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (m
 	return pendingReleasedIps, nil
 }
 
+// isIPFamily returns whether the IP is of the family, any IP is of the empty family.
+func isIPFamily(ipConfig cns.IPConfigurationStatus, family cns.IPFamily) bool { //nolint:gocritic // ignore hugeparam
+	return family == "" || cns.IPFamilyOf(ipConfig.IPAddress) == family
+}
+
 // TODO: Add a change so that we should only update the current state if it is different than the new state
 func (service *HTTPRestService) updateIPConfigState(ipID string, updatedState types.IPState, podInfo cns.PodInfo) (cns.IPConfigurationStatus, error) {
 	if ipConfig, found := service.PodIPConfigState[ipID]; found {
@@ -501,6 +556,100 @@ func (service *HTTPRestService) handleDebugIPAddresses(w http.ResponseWriter, r
 	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
 }
 
+func (service *HTTPRestService) getPodContextByIPHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.GetPodContextByIPRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.GetPodContextByIPResponse
+	contexts, err := service.podIPContextsByIP([]string{req.IP})
+	switch {
+	case err != nil:
+		resp.Response = cns.Response{
+			ReturnCode: types.InvalidParameter,
+			Message:    err.Error(),
+		}
+	case len(contexts) == 0:
+		resp.Response = cns.Response{
+			ReturnCode: types.NotFound,
+			Message:    fmt.Sprintf("IP %s is not assigned to a pod", req.IP),
+		}
+	default:
+		resp.PodIPContext = contexts[0]
+	}
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) getPodContextsByIPHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.GetPodContextsByIPRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.GetPodContextsByIPResponse
+	if resp.PodIPContexts, err = service.podIPContextsByIP(req.IPs); err != nil {
+		resp.Response = cns.Response{
+			ReturnCode: types.InvalidParameter,
+			Message:    err.Error(),
+		}
+	}
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+// podIPContextsByIP returns the pod context of each of the IPs which is assigned to a pod, in the order of the IPs.
+// IPs which are not assigned are skipped.
+func (service *HTTPRestService) podIPContextsByIP(ips []string) ([]cns.PodIPContext, error) {
+	// IPs are compared in their canonical form, so that e.g. equivalent IPv6 notations match.
+	wanted := make(map[string]int, len(ips))
+	for i, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, errors.Errorf("invalid IP %q", ip)
+		}
+		wanted[parsed.String()] = i
+	}
+
+	found := make(map[int]cns.PodIPContext, len(wanted))
+	service.RLock()
+	for _, ipConfig := range service.PodIPConfigState { //nolint:gocritic // ignore copy
+		if ipConfig.GetState() != types.Assigned || ipConfig.PodInfo == nil {
+			continue
+		}
+		parsed := net.ParseIP(ipConfig.IPAddress)
+		if parsed == nil {
+			continue
+		}
+		i, ok := wanted[parsed.String()]
+		if !ok {
+			continue
+		}
+		found[i] = cns.PodIPContext{
+			IP:                 ipConfig.IPAddress,
+			PodName:            ipConfig.PodInfo.Name(),
+			PodNamespace:       ipConfig.PodInfo.Namespace(),
+			InfraContainerID:   ipConfig.PodInfo.InfraContainerID(),
+			InterfaceID:        ipConfig.PodInfo.InterfaceID(),
+			NetworkContainerID: ipConfig.NCID,
+		}
+	}
+	service.RUnlock()
+
+	contexts := make([]cns.PodIPContext, 0, len(found))
+	for i := range ips {
+		if podIPContext, ok := found[i]; ok {
+			contexts = append(contexts, podIPContext)
+		}
+	}
+	return contexts, nil
+}
+
 // GetAssignedIPConfigs returns a filtered list of IPs which are in
 // Assigned State.
 func (service *HTTPRestService) GetAssignedIPConfigs() []cns.IPConfigurationStatus {
@@ -562,11 +711,39 @@ func (service *HTTPRestService) unassignIPConfig(ipconfig cns.IPConfigurationSta
 	return ipconfig, nil
 }
 
+// ReleaseIPConfigs releases the IPs of the Pod and removes its endpoint state if CNS manages it, as a CNI DEL does,
+// e.g. to reclaim the IPs leaked by a Pod whose CNI DEL never completed. The IPs are only released if they're still
+// assigned to the sandbox of the podInfo, otherwise ErrSandboxChanged is returned, so that the IPs a recreated sandbox
+// got back in the meantime are kept.
+func (service *HTTPRestService) ReleaseIPConfigs(podInfo cns.PodInfo) error {
+	service.Lock()
+	for _, ipID := range service.PodIPIDByPodInterfaceKey[podInfo.Key()] {
+		if ipConfig, ok := service.PodIPConfigState[ipID]; ok && ipConfig.PodInfo != nil &&
+			ipConfig.PodInfo.InfraContainerID() != podInfo.InfraContainerID() {
+			service.Unlock()
+			return errors.Wrapf(ErrSandboxChanged, "IP %s is assigned to sandbox %s", ipConfig.IPAddress, ipConfig.PodInfo.InfraContainerID())
+		}
+	}
+	err := service.releaseIPConfigsUntransacted(podInfo)
+	service.Unlock()
+	if err != nil {
+		return err
+	}
+	if service.Options[common.OptManageEndpointState] == true {
+		return service.removeEndpointState(podInfo)
+	}
+	return nil
+}
+
 // Todo - CNI should also pass the IPAddress which needs to be released to validate if that is the right IP allcoated
 // in the first place.
 func (service *HTTPRestService) releaseIPConfigs(podInfo cns.PodInfo) error {
 	service.Lock()
 	defer service.Unlock()
+	return service.releaseIPConfigsUntransacted(podInfo)
+}
+
+func (service *HTTPRestService) releaseIPConfigsUntransacted(podInfo cns.PodInfo) error {
 	ipsToBeReleased := make([]cns.IPConfigurationStatus, 0)
 
 	for i, ipID := range service.PodIPIDByPodInterfaceKey[podInfo.Key()] {
@@ -607,6 +784,11 @@ func (service *HTTPRestService) releaseIPConfigs(podInfo cns.PodInfo) error {
 	}
 
 	logger.Printf("[releaseIPConfigs] Successfully released all IPs for pod %+v", podInfo)
+	ips := make([]string, len(ipsToBeReleased))
+	for i := range ipsToBeReleased {
+		ips[i] = ipsToBeReleased[i].IPAddress
+	}
+	service.notifyIPHooks(iphooks.EventRelease, podInfo, ips)
 	return nil
 }
 
@@ -658,6 +840,29 @@ func (service *HTTPRestService) GetExistingIPConfig(podInfo cns.PodInfo) ([]cns.
 	return podIPInfo, ipConfigExists, nil
 }
 
+// updateExistingIPConfigsPodInfo records the infra container ID of the request in the IPs already assigned to the Pod.
+// When the Pods are keyed by name and namespace, a sandbox recreated after a failed CNI DEL gets the IPs of the previous
+// one, which would otherwise be reported as leaked, and reclaimed, once the previous sandbox is gone.
+func (service *HTTPRestService) updateExistingIPConfigsPodInfo(podInfo cns.PodInfo) {
+	if podInfo.InfraContainerID() == "" {
+		return
+	}
+	service.Lock()
+	defer service.Unlock()
+	for _, ipID := range service.PodIPIDByPodInterfaceKey[podInfo.Key()] {
+		ipConfig, ok := service.PodIPConfigState[ipID]
+		if !ok || ipConfig.PodInfo == nil || ipConfig.PodInfo.InfraContainerID() == podInfo.InfraContainerID() {
+			continue
+		}
+		logger.Printf("[updateExistingIPConfigsPodInfo] IP %s of pod %s moved from sandbox %s to %s",
+			ipConfig.IPAddress, podInfo.Key(), ipConfig.PodInfo.InfraContainerID(), podInfo.InfraContainerID())
+		ipConfig.PodInfo = podInfo
+		// the grace period of the leak detection starts over with the new sandbox
+		ipConfig.LastStateTransition = time.Now()
+		service.PodIPConfigState[ipID] = ipConfig
+	}
+}
+
 // Assigns a pod with all IPs desired
 func (service *HTTPRestService) AssignDesiredIPConfigs(podInfo cns.PodInfo, desiredIPAddresses []string) ([]cns.PodIpInfo, error) {
 	numDesiredIPAddresses := len(desiredIPAddresses)
@@ -669,6 +874,8 @@ func (service *HTTPRestService) AssignDesiredIPConfigs(podInfo cns.PodInfo, desi
 
 	service.Lock()
 	defer service.Unlock()
+	service.ipReservations.prune(time.Now())
+	podKey := ipReservationKey(podInfo.Name(), podInfo.Namespace())
 
 	for _, desiredIP := range desiredIPAddresses {
 		desiredIPMap[desiredIP] = struct{}{}
@@ -693,17 +900,18 @@ func (service *HTTPRestService) AssignDesiredIPConfigs(podInfo cns.PodInfo, desi
 				}
 				numIPConfigsAssigned++
 			} else {
-				//nolint:goerr113 // return error
-				return []cns.PodIpInfo{}, fmt.Errorf("[AssignDesiredIPConfigs] Desired IP is already assigned %+v, requested for pod %+v", ipConfig, podInfo)
+				return []cns.PodIpInfo{}, errors.Wrapf(ErrDesiredIPUnavailable, "[AssignDesiredIPConfigs] Desired IP is already assigned %+v, requested for pod %+v", ipConfig, podInfo)
 			}
 		case types.Available, types.PendingProgramming:
+			if service.ipReservations.reservedForOther(ipConfig.ID, podKey) {
+				return podIPInfo, errors.Wrapf(ErrDesiredIPUnavailable, "IP %s is reserved for another Pod", ipConfig.IPAddress)
+			}
 			// This race can happen during restart, where CNS state is lost and thus we have lost the NC programmed version
 			// As part of reconcile, we mark IPs as Assigned which are already assigned to Pods (listed from APIServer)
 			ipConfigsToAssign = append(ipConfigsToAssign, ipConfig)
 		default:
 			logger.Errorf("[AssignDesiredIPConfigs] Desired IP is not available %+v", ipConfig)
-			//nolint:goerr113 // return error
-			return podIPInfo, fmt.Errorf("IP not available")
+			return podIPInfo, errors.Wrapf(ErrDesiredIPUnavailable, "IP %s is %s", ipConfig.IPAddress, ipConfig.GetState())
 		}
 
 		// checks if found all of the desired IPs either as an available IP or already assigned to the pod
@@ -714,8 +922,7 @@ func (service *HTTPRestService) AssignDesiredIPConfigs(podInfo cns.PodInfo, desi
 
 	// if we did not find all of the desired IPs return an error
 	if len(ipConfigsToAssign)+numIPConfigsAssigned != numDesiredIPAddresses {
-		//nolint:goerr113 // return error
-		return podIPInfo, fmt.Errorf("not enough desired IPs found in pool")
+		return podIPInfo, errors.Wrapf(ErrDesiredIPUnavailable, "not all desired IPs %v found in pool", desiredIPAddresses)
 	}
 
 	failedToAssignIP := false
@@ -748,30 +955,53 @@ func (service *HTTPRestService) AssignDesiredIPConfigs(podInfo cns.PodInfo, desi
 		return podIPInfo, fmt.Errorf("not all requested ips %v were found/available in the pool", desiredIPAddresses)
 	}
 
+	// the reservation of the Pod, if any, is used up once it's assigned IPs
+	service.ipReservations.remove(podKey)
 	logger.Printf("[AssignDesiredIPConfigs] Successfully assigned all desired IPs for pod %+v", podInfo)
 	return podIPInfo, nil
 }
 
 // Assigns an available IP from each NC on the NNC. If there is one NC then we expect to only have one IP return
 // In the case of dualstack we would expect to have one IPv6 from one NC and one IPv4 from a second NC
+// In subnet-per-namespace mode, the IPs are only assigned from the NCs of the subnet of the Pod namespace.
 func (service *HTTPRestService) AssignAvailableIPConfigs(podInfo cns.PodInfo) ([]cns.PodIpInfo, error) {
+	return service.AssignAvailableIPConfigsOfFamilies(podInfo, nil)
+}
+
+// AssignAvailableIPConfigsOfFamilies is AssignAvailableIPConfigs from the NCs of the IP families only, or from all
+// NCs if there are none, e.g. to give a Pod only an IPv6 address on a dual-stack node.
+func (service *HTTPRestService) AssignAvailableIPConfigsOfFamilies(podInfo cns.PodInfo, families []cns.IPFamily) ([]cns.PodIpInfo, error) {
 	service.Lock()
 	defer service.Unlock()
+	ncIDs := service.ncIDsForNamespace(podInfo.Namespace())
+	if len(ncIDs) == 0 {
+		return []cns.PodIpInfo{}, errors.Wrapf(ErrNoSubnetForNamespace, "namespace %s", podInfo.Namespace())
+	}
+	if len(families) > 0 {
+		service.filterNCIDsByFamilies(ncIDs, families)
+		if len(ncIDs) == 0 {
+			return []cns.PodIpInfo{}, errors.Wrapf(ErrNoNCForIPFamilies, "families %v", families)
+		}
+	}
 	// Sets the number of IPs needed equal to the number of NCs so that we can get one IP per NC
-	numIPsNeeded := len(service.state.ContainerStatus)
+	numIPsNeeded := len(ncIDs)
 	// Creates a slice of PodIpInfo with the size as number of NCs to hold the result for assigned IP configs
 	podIPInfo := make([]cns.PodIpInfo, numIPsNeeded)
 	// This map is used to store whether or not we have found an available IP from an NC when looping through the pool
 	ipsToAssign := make(map[string]cns.IPConfigurationStatus)
+	service.ipReservations.prune(time.Now())
 
 	// Searches for available IPs in the pool
 	for _, ipState := range service.PodIPConfigState {
+		if _, ok := ncIDs[ipState.NCID]; !ok {
+			continue
+		}
 		// check if an IP from this NC is already set side for assignment.
 		if _, ncAlreadyMarkedForAssignment := ipsToAssign[ipState.NCID]; ncAlreadyMarkedForAssignment {
 			continue
 		}
-		// Checks if the current IP is available
-		if ipState.GetState() != types.Available {
+		// Checks if the current IP is available and not reserved for a Pod
+		if ipState.GetState() != types.Available || service.ipReservations.reserved(ipState.ID) {
 			continue
 		}
 		ipsToAssign[ipState.NCID] = ipState
@@ -783,8 +1013,7 @@ func (service *HTTPRestService) AssignAvailableIPConfigs(podInfo cns.PodInfo) ([
 
 	// Checks to make sure we found one IP for each NC
 	if len(ipsToAssign) != numIPsNeeded {
-		//nolint:goerr113 // return error
-		return podIPInfo, fmt.Errorf("not enough IPs available, waiting on Azure CNS to allocate more")
+		return podIPInfo, ErrNoAvailableIPs
 	}
 
 	failedToAssignIP := false
@@ -814,14 +1043,40 @@ func (service *HTTPRestService) AssignAvailableIPConfigs(podInfo cns.PodInfo) ([
 				logger.Errorf("[AssignAvailableIPConfigs] failed to mark IPConfig [%+v] back to Available. err: %v", ipState, err)
 			}
 		}
-		//nolint:goerr113 // return error
-		return podIPInfo, fmt.Errorf("not enough IPs available, waiting on Azure CNS to allocate more")
+		return podIPInfo, ErrNoAvailableIPs
 	}
 
 	logger.Printf("[AssignDesiredIPConfigs] Successfully assigned IPs for pod %+v", podInfo)
 	return podIPInfo, nil
 }
 
+// filterNCIDsByFamilies removes the NCs which aren't of one of the IP families.
+// Note: this func is an untransacted API as the caller will take a Service lock
+func (service *HTTPRestService) filterNCIDsByFamilies(ncIDs map[string]struct{}, families []cns.IPFamily) {
+	for ncID := range ncIDs {
+		ncStatus := service.state.ContainerStatus[ncID]
+		ncFamily := ncIPFamily(&ncStatus.CreateNetworkContainerRequest)
+		found := false
+		for _, family := range families {
+			if family == ncFamily {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(ncIDs, ncID)
+		}
+	}
+}
+
+// ncIPFamily returns the IP family of the secondary IPs of the NC, or of its primary IP if it has none.
+func ncIPFamily(req *cns.CreateNetworkContainerRequest) cns.IPFamily {
+	for _, secondaryIPConfig := range req.SecondaryIPConfigs {
+		return cns.IPFamilyOf(secondaryIPConfig.IPAddress)
+	}
+	return cns.IPFamilyOf(req.IPConfiguration.IPSubnet.IPAddress)
+}
+
 // If IPConfigs are already assigned to the pod, it returns that else it returns the available ipconfigs.
 func requestIPConfigsHelper(service *HTTPRestService, req cns.IPConfigsRequest) ([]cns.PodIpInfo, error) {
 	// check if ipconfigs already assigned to this pod and return if exists or error
@@ -832,12 +1087,20 @@ func requestIPConfigsHelper(service *HTTPRestService, req cns.IPConfigsRequest)
 	}
 
 	if podIPInfo, isExist, err := service.GetExistingIPConfig(podInfo); err != nil || isExist {
+		if isExist && err == nil {
+			service.updateExistingIPConfigsPodInfo(podInfo)
+		}
 		return podIPInfo, err
 	}
 
-	// if the desired IP configs are not specified, assign any free IPConfigs
+	// if the desired IP configs are not specified, assign the IPs reserved for the Pod, else any free IPConfigs
 	if len(req.DesiredIPAddresses) == 0 {
-		return service.AssignAvailableIPConfigs(podInfo)
+		reservedIPs := service.reservedIPAddresses(podInfo)
+		if len(reservedIPs) == 0 {
+			return service.AssignAvailableIPConfigsOfFamilies(podInfo, req.IPFamilies)
+		}
+		logger.Printf("[requestIPConfigsHelper] Assigning the reserved IPs %v to pod %+v", reservedIPs, podInfo)
+		return service.AssignDesiredIPConfigs(podInfo, reservedIPs)
 	}
 
 	if err := validateDesiredIPAddresses(req.DesiredIPAddresses); err != nil {
@@ -852,8 +1115,7 @@ func validateDesiredIPAddresses(desiredIPs []string) error {
 	for _, desiredIP := range desiredIPs {
 		ip := net.ParseIP(desiredIP)
 		if ip.To4() == nil && ip.To16() == nil {
-			//nolint:goerr113 // return error
-			return fmt.Errorf("[validateDesiredIPAddresses] invalid ip %s specified as desired IP", desiredIP)
+			return errors.Wrapf(ErrDesiredIPUnavailable, "[validateDesiredIPAddresses] invalid ip %s specified as desired IP", desiredIP)
 		}
 	}
 	return nil
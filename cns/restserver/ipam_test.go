@@ -4,20 +4,24 @@
 package restserver
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/netip"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/cns/common"
 	"github.com/Azure/azure-container-networking/cns/fakes"
+	"github.com/Azure/azure-container-networking/cns/ipleak"
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/Azure/azure-container-networking/store"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -757,6 +761,7 @@ func IPAMFailToGetIPWhenAllIPsAreAssigned(t *testing.T, ncStates []ncState) {
 	if err == nil {
 		t.Fatalf("Expected failure requesting IP when there are no more IPs: %+v", err)
 	}
+	assert.ErrorIs(t, err, ErrNoAvailableIPs)
 }
 
 func TestIPAMRequestThenReleaseThenRequestAgainSingleNC(t *testing.T) {
@@ -822,6 +827,7 @@ func IPAMRequestThenReleaseThenRequestAgain(t *testing.T, ncStates []ncState) {
 	if err == nil {
 		t.Fatal("Expected failure requesting IP when there are no more IPs")
 	}
+	assert.ErrorIs(t, err, ErrDesiredIPUnavailable)
 
 	// Release Test Pod 1
 	err = svc.releaseIPConfigs(testPod1Info)
@@ -1426,6 +1432,40 @@ func TestIPAMFailToRequestOneIPWhenExpectedToHaveTwo(t *testing.T) {
 	}
 }
 
+func TestIPAMRequestIPFamilies(t *testing.T) {
+	svc := getTestService()
+
+	ipv4State := NewPodState(testIP1, ipIDs[0][0], testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{ipv4State.ID: ipv4State}, testNCID))
+	ipv6State := NewPodState(testIP1v6, ipIDs[1][0], testNCIDv6, types.Available, 0)
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{ipv6State.ID: ipv6State}, testNCIDv6))
+
+	// the Pod only gets an IP of the requested family
+	req := cns.IPConfigsRequest{IPFamilies: []cns.IPFamily{cns.IPv6Family}}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	actualState, err := requestIPAddressAndGetState(t, req)
+	require.NoError(t, err)
+	require.Len(t, actualState, 1)
+	assert.Equal(t, testIP1v6, actualState[0].IPAddress)
+
+	// no IPv6 IP is left, while an IPv4 IP is
+	req.OrchestratorContext, _ = testPod2Info.OrchestratorContext()
+	_, err = requestIPAddressAndGetState(t, req)
+	require.ErrorIs(t, err, ErrNoAvailableIPs)
+
+	req.IPFamilies = []cns.IPFamily{"ipv5"}
+	_, err = requestIPAddressAndGetState(t, req)
+	require.ErrorIs(t, err, ErrNoNCForIPFamilies)
+
+	// only the IPs of the family are released
+	released, err := svc.MarkIPFamilyAsPendingRelease(1, cns.IPv6Family)
+	require.NoError(t, err)
+	assert.Empty(t, released)
+	released, err = svc.MarkIPFamilyAsPendingRelease(1, cns.IPv4Family)
+	require.NoError(t, err)
+	assert.Contains(t, released, ipv4State.ID)
+}
+
 func TestIPAMFailToReleasePartialIPsInPool(t *testing.T) {
 	svc := getTestService()
 
@@ -1495,3 +1535,107 @@ func TestIPAMFailToRequestPartialIPsInPool(t *testing.T) {
 		t.Fatalf("Expected fail requesting IPs due to only having one in the ipconfig map, IPs in the pool will not be assigned")
 	}
 }
+
+func TestIPAMRequestUnavailableDesiredIP(t *testing.T) {
+	svc := getTestService()
+
+	ipconfigs := make(map[string]cns.IPConfigurationStatus)
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testIPID1, testNCID, types.Assigned, ipPrefixBitsv4, 0, testPod1Info)
+	ipconfigs[state1.ID] = state1
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testIPID2, testNCID, types.Available, ipPrefixBitsv4, 0, nil)
+	ipconfigs[state2.ID] = state2
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	tests := []struct {
+		name      string
+		desiredIP string
+		wantCode  types.ResponseCode
+	}{
+		{
+			name:      "assigned to another pod",
+			desiredIP: testIP1,
+			wantCode:  types.DesiredIPUnavailable,
+		},
+		{
+			name:      "not in the pool",
+			desiredIP: testIP3,
+			wantCode:  types.DesiredIPUnavailable,
+		},
+		{
+			name:      "invalid",
+			desiredIP: "10.0.0",
+			wantCode:  types.DesiredIPUnavailable,
+		},
+		{
+			name:      "available",
+			desiredIP: testIP2,
+			wantCode:  types.Success,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := cns.IPConfigsRequest{
+				PodInterfaceID:     testPod2Info.InterfaceID(),
+				InfraContainerID:   testPod2Info.InfraContainerID(),
+				DesiredIPAddresses: []string{tt.desiredIP},
+			}
+			req.OrchestratorContext, _ = testPod2Info.OrchestratorContext()
+			resp, err := svc.requestIPConfigHandlerHelper(req)
+			assert.Equal(t, tt.wantCode, resp.Response.ReturnCode)
+			if tt.wantCode != types.Success {
+				require.ErrorIs(t, err, ErrDesiredIPUnavailable)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, resp.PodIPInfo, 1)
+			assert.Equal(t, tt.desiredIP, resp.PodIPInfo[0].PodIPConfig.IPAddress)
+		})
+	}
+}
+
+// liveSandboxes lists the IDs of the pod sandboxes the container runtime runs.
+type liveSandboxes map[string]struct{}
+
+func (s liveSandboxes) PodSandboxIDs(context.Context) (map[string]struct{}, error) {
+	return s, nil
+}
+
+// Tests that the IP of a Pod whose sandbox is recreated after a failed CNI DEL follows the new sandbox, so that it
+// isn't reclaimed as leaked once the previous sandbox is gone.
+func TestIPAMRecreatedSandboxKeepsIPConfigs(t *testing.T) {
+	svc := getTestService()
+	state := NewPodState(testIP1, testIPID1, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{state.ID: state}, testNCID))
+
+	request := func(podInfo cns.PodInfo) []cns.PodIpInfo {
+		req := cns.IPConfigsRequest{
+			PodInterfaceID:   podInfo.InterfaceID(),
+			InfraContainerID: podInfo.InfraContainerID(),
+		}
+		req.OrchestratorContext, _ = podInfo.OrchestratorContext()
+		podIPInfo, err := requestIPConfigsHelper(svc, req)
+		require.NoError(t, err)
+		require.Len(t, podIPInfo, 1)
+		return podIPInfo
+	}
+	request(cns.NewPodInfo("sandbox1", "sandbox1-eth0", "testpod1", "testpod1namespace"))
+	// the DEL of the first sandbox failed, the recreated sandbox gets the IPs of the pod back
+	podIPInfo := request(cns.NewPodInfo("sandbox2", "sandbox2-eth0", "testpod1", "testpod1namespace"))
+	assert.Equal(t, testIP1, podIPInfo[0].PodIPConfig.IPAddress)
+	assert.Equal(t, "sandbox2", svc.PodIPConfigState[testIPID1].PodInfo.InfraContainerID())
+
+	// a detection which listed the sandboxes before the recreation doesn't reclaim the IPs of the new sandbox
+	err := svc.ReleaseIPConfigs(cns.NewPodInfo("sandbox1", "sandbox1-eth0", "testpod1", "testpod1namespace"))
+	require.ErrorIs(t, err, ErrSandboxChanged)
+	ipConfig := svc.PodIPConfigState[testIPID1]
+	assert.Equal(t, types.Assigned, ipConfig.GetState())
+
+	detector := ipleak.NewDetector(svc, liveSandboxes{"sandbox2": {}, "other": {}}, &ipleak.Options{GracePeriod: time.Nanosecond, Reclaim: true})
+	leaks, err := detector.Detect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, leaks)
+	assigned := svc.GetAssignedIPConfigs()
+	require.Len(t, assigned, 1)
+	assert.Equal(t, testIP1, assigned[0].IPAddress)
+}
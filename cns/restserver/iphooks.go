@@ -0,0 +1,30 @@
+package restserver
+
+import (
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/iphooks"
+)
+
+// SetIPHooks sets the Dispatcher notified of the IPs assigned to and released from Pods. A nil Dispatcher disables
+// the notifications.
+func (service *HTTPRestService) SetIPHooks(d *iphooks.Dispatcher) {
+	service.Lock()
+	defer service.Unlock()
+	service.ipHooks = d
+}
+
+// notifyIPHooks dispatches the assignment or release of the IPs of the Pod to the IP hooks. It doesn't block, so it
+// can be called with the service lock held.
+func (service *HTTPRestService) notifyIPHooks(eventType iphooks.EventType, podInfo cns.PodInfo, ips []string) {
+	if service.ipHooks == nil || len(ips) == 0 {
+		return
+	}
+	service.ipHooks.Dispatch(iphooks.Event{
+		Type:             eventType,
+		PodName:          podInfo.Name(),
+		PodNamespace:     podInfo.Namespace(),
+		PodInterfaceID:   podInfo.InterfaceID(),
+		InfraContainerID: podInfo.InfraContainerID(),
+		IPAddresses:      ips,
+	})
+}
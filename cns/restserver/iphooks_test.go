@@ -0,0 +1,62 @@
+package restserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/iphooks"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingIPHook struct {
+	events chan iphooks.Event
+}
+
+func (*recordingIPHook) Name() string {
+	return "recording"
+}
+
+func (h *recordingIPHook) Notify(_ context.Context, event iphooks.Event) error { //nolint:gocritic // events are passed by value to the hooks
+	h.events <- event
+	return nil
+}
+
+func TestIPHooksNotifiedOfAssignAndRelease(t *testing.T) {
+	svc := getTestService()
+	hook := &recordingIPHook{events: make(chan iphooks.Event, 2)}
+	d := iphooks.NewDispatcher(&iphooks.Options{}, hook)
+	svc.SetIPHooks(d)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Start(ctx) //nolint:errcheck // stopped by the context
+
+	state := NewPodState(testIP1, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{state.ID: state}, testNCID))
+
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:     testPod1Info.InterfaceID(),
+		InfraContainerID:   testPod1Info.InfraContainerID(),
+		DesiredIPAddresses: []string{testIP1},
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	_, err := svc.requestIPConfigHandlerHelper(req)
+	require.NoError(t, err)
+	_, err = svc.releaseIPConfigHandlerHelper(req)
+	require.NoError(t, err)
+
+	for _, eventType := range []iphooks.EventType{iphooks.EventAssign, iphooks.EventRelease} {
+		select {
+		case event := <-hook.events:
+			require.Equal(t, eventType, event.Type)
+			require.Equal(t, testPod1Info.Name(), event.PodName)
+			require.Equal(t, testPod1Info.Namespace(), event.PodNamespace)
+			require.Equal(t, testPod1Info.InfraContainerID(), event.InfraContainerID)
+			require.Equal(t, []string{testIP1}, event.IPAddresses)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", eventType)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package restserver
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidIPReservation is returned when an IP reservation request doesn't identify the Pod or has a negative TTL.
+var ErrInvalidIPReservation = errors.New("invalid IP reservation request")
+
+// ipReservation is a set of Available IPs which are only assigned to the Pod they are reserved for, until it requests
+// IPs or the reservation expires.
+type ipReservation struct {
+	podName      string
+	podNamespace string
+	// ipConfigs are the reserved IPs, by ID.
+	ipConfigs map[string]cns.IPConfigurationStatus
+	expiresAt time.Time
+}
+
+// ipReservations are the IP reservations of the node, by Pod. They are guarded by the lock of the HTTPRestService,
+// like the IP pool. They aren't persisted: the reservations are lost on restart, and are made again by the operator.
+type ipReservations struct {
+	byPod map[string]*ipReservation
+	// byIPID is the reservation of each reserved IP.
+	byIPID map[string]*ipReservation
+}
+
+// ipReservationKey identifies the reservation of a Pod. The infra container of the Pod doesn't exist when its IPs are
+// reserved, so the reservations aren't keyed like the assigned IPs.
+func ipReservationKey(podName, podNamespace string) string {
+	return podNamespace + "/" + podName
+}
+
+func (r *ipReservations) add(reservation *ipReservation) {
+	if r.byPod == nil {
+		r.byPod = map[string]*ipReservation{}
+		r.byIPID = map[string]*ipReservation{}
+	}
+	r.remove(ipReservationKey(reservation.podName, reservation.podNamespace))
+	r.byPod[ipReservationKey(reservation.podName, reservation.podNamespace)] = reservation
+	for id := range reservation.ipConfigs {
+		r.byIPID[id] = reservation
+	}
+}
+
+// remove deletes the reservation of the Pod, and returns whether it had one.
+func (r *ipReservations) remove(podKey string) bool {
+	reservation, ok := r.byPod[podKey]
+	if !ok {
+		return false
+	}
+	delete(r.byPod, podKey)
+	for id := range reservation.ipConfigs {
+		delete(r.byIPID, id)
+	}
+	return true
+}
+
+// prune deletes the reservations which expired.
+func (r *ipReservations) prune(now time.Time) {
+	for key, reservation := range r.byPod {
+		if now.Before(reservation.expiresAt) {
+			continue
+		}
+		logger.Printf("[Azure CNS] IP reservation of Pod %s expired unused", key)
+		r.remove(key)
+	}
+}
+
+// reserved returns whether the IP is reserved for a Pod.
+func (r *ipReservations) reserved(ipID string) bool {
+	_, ok := r.byIPID[ipID]
+	return ok
+}
+
+// reservedForOther returns whether the IP is reserved for a Pod other than the one with the key.
+func (r *ipReservations) reservedForOther(ipID, podKey string) bool {
+	reservation, ok := r.byIPID[ipID]
+	return ok && ipReservationKey(reservation.podName, reservation.podNamespace) != podKey
+}
+
+// ipAddresses returns the reserved IPs of the Pod, sorted.
+func (r *ipReservations) ipAddresses(podKey string) []string {
+	reservation, ok := r.byPod[podKey]
+	if !ok {
+		return nil
+	}
+	ips := make([]string, 0, len(reservation.ipConfigs))
+	for i := range reservation.ipConfigs {
+		ips = append(ips, reservation.ipConfigs[i].IPAddress)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// ReserveIPConfigs reserves IPs of the pool for the Pod, the desired ones or an Available IP of each NC. The IPs stay
+// Available, but are only assigned to the Pod, on its next IP request, until the reservation expires.
+func (service *HTTPRestService) ReserveIPConfigs(req cns.ReserveIPConfigRequest) (cns.IPReservation, error) {
+	if req.PodName == "" || req.PodNamespace == "" || req.TTLInSeconds < 0 {
+		return cns.IPReservation{}, errors.Wrapf(ErrInvalidIPReservation, "%+v", req)
+	}
+	if err := validateDesiredIPAddresses(req.DesiredIPAddresses); err != nil {
+		return cns.IPReservation{}, err
+	}
+	ttl := cns.DefaultIPReservationTTL
+	if req.TTLInSeconds > 0 {
+		ttl = time.Duration(req.TTLInSeconds) * time.Second
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	now := time.Now()
+	service.ipReservations.prune(now)
+
+	podKey := ipReservationKey(req.PodName, req.PodNamespace)
+	var ipConfigs map[string]cns.IPConfigurationStatus
+	var err error
+	if len(req.DesiredIPAddresses) == 0 {
+		ipConfigs, err = service.reservableIPConfigsPerNCUntransacted(podKey)
+	} else {
+		ipConfigs, err = service.reservableDesiredIPConfigsUntransacted(podKey, req.DesiredIPAddresses)
+	}
+	if err != nil {
+		return cns.IPReservation{}, err
+	}
+
+	service.ipReservations.add(&ipReservation{
+		podName:      req.PodName,
+		podNamespace: req.PodNamespace,
+		ipConfigs:    ipConfigs,
+		expiresAt:    now.Add(ttl),
+	})
+	reservation := cns.IPReservation{
+		PodName:      req.PodName,
+		PodNamespace: req.PodNamespace,
+		IPAddresses:  service.ipReservations.ipAddresses(podKey),
+		ExpiresAt:    now.Add(ttl),
+	}
+	logger.Printf("[Azure CNS] Reserved IPs %v for Pod %s until %s", reservation.IPAddresses, podKey, reservation.ExpiresAt)
+	return reservation, nil
+}
+
+// reservableIPConfigsPerNCUntransacted returns an Available IP of each NC which isn't reserved for another Pod.
+func (service *HTTPRestService) reservableIPConfigsPerNCUntransacted(podKey string) (map[string]cns.IPConfigurationStatus, error) {
+	byNC := make(map[string]cns.IPConfigurationStatus)
+	for id, ipConfig := range service.PodIPConfigState { //nolint:gocritic // ignore copy
+		if _, found := byNC[ipConfig.NCID]; found {
+			continue
+		}
+		if ipConfig.GetState() != types.Available || service.ipReservations.reservedForOther(id, podKey) {
+			continue
+		}
+		byNC[ipConfig.NCID] = ipConfig
+	}
+	if len(byNC) == 0 || len(byNC) != len(service.state.ContainerStatus) {
+		return nil, ErrNoAvailableIPs
+	}
+
+	ipConfigs := make(map[string]cns.IPConfigurationStatus, len(byNC))
+	for _, ipConfig := range byNC { //nolint:gocritic // ignore copy
+		ipConfigs[ipConfig.ID] = ipConfig
+	}
+	return ipConfigs, nil
+}
+
+// reservableDesiredIPConfigsUntransacted returns the desired IPs, which must be Available or PendingProgramming, as
+// when they are assigned, and not reserved for another Pod.
+func (service *HTTPRestService) reservableDesiredIPConfigsUntransacted(podKey string, desiredIPAddresses []string) (map[string]cns.IPConfigurationStatus, error) {
+	desired := make(map[string]struct{}, len(desiredIPAddresses))
+	for _, ip := range desiredIPAddresses {
+		desired[ip] = struct{}{}
+	}
+
+	ipConfigs := make(map[string]cns.IPConfigurationStatus, len(desired))
+	for id, ipConfig := range service.PodIPConfigState { //nolint:gocritic // ignore copy
+		if _, found := desired[ipConfig.IPAddress]; !found {
+			continue
+		}
+		if state := ipConfig.GetState(); state != types.Available && state != types.PendingProgramming {
+			return nil, errors.Wrapf(ErrDesiredIPUnavailable, "IP %s is %s", ipConfig.IPAddress, state)
+		}
+		if service.ipReservations.reservedForOther(id, podKey) {
+			return nil, errors.Wrapf(ErrDesiredIPUnavailable, "IP %s is reserved for another Pod", ipConfig.IPAddress)
+		}
+		ipConfigs[id] = ipConfig
+	}
+	if len(ipConfigs) != len(desired) {
+		return nil, errors.Wrapf(ErrDesiredIPUnavailable, "not all desired IPs %v found in pool", desiredIPAddresses)
+	}
+	return ipConfigs, nil
+}
+
+// UnreserveIPConfigs deletes the reservation of the Pod, and returns whether it had one.
+func (service *HTTPRestService) UnreserveIPConfigs(req cns.UnreserveIPConfigRequest) bool {
+	service.Lock()
+	defer service.Unlock()
+	service.ipReservations.prune(time.Now())
+	podKey := ipReservationKey(req.PodName, req.PodNamespace)
+	if !service.ipReservations.remove(podKey) {
+		return false
+	}
+	logger.Printf("[Azure CNS] Released the IP reservation of Pod %s", podKey)
+	return true
+}
+
+// reservedIPAddresses returns the IPs reserved for the Pod, if any.
+func (service *HTTPRestService) reservedIPAddresses(podInfo cns.PodInfo) []string {
+	service.Lock()
+	defer service.Unlock()
+	service.ipReservations.prune(time.Now())
+	return service.ipReservations.ipAddresses(ipReservationKey(podInfo.Name(), podInfo.Namespace()))
+}
+
+func (service *HTTPRestService) reserveIPConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.ReserveIPConfigRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.ReserveIPConfigResponse
+	if service.isDraining() {
+		resp.Response = cns.Response{
+			ReturnCode: types.NodeDraining,
+			Message:    "IP pool of the node is draining, no IPs are reserved",
+		}
+	} else if resp.Reservation, err = service.ReserveIPConfigs(req); err != nil {
+		resp.Response.ReturnCode = types.FailedToAllocateIPConfig
+		switch {
+		case errors.Is(err, ErrInvalidIPReservation):
+			resp.Response.ReturnCode = types.InvalidParameter
+		case errors.Is(err, ErrNoAvailableIPs):
+			resp.Response.ReturnCode = types.AddressUnavailable
+		case errors.Is(err, ErrDesiredIPUnavailable):
+			resp.Response.ReturnCode = types.DesiredIPUnavailable
+		}
+		resp.Response.Message = err.Error()
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) unreserveIPConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.UnreserveIPConfigRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	var resp cns.Response
+	if !service.UnreserveIPConfigs(req) {
+		resp = cns.Response{
+			ReturnCode: types.ReservationNotFound,
+			Message:    "no IP reservation for Pod " + ipReservationKey(req.PodName, req.PodNamespace),
+		}
+	}
+
+	err = service.Listener.Encode(w, &resp)
+	logger.Response(service.Name, resp, resp.ReturnCode, err)
+}
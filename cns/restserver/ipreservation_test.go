@@ -0,0 +1,113 @@
+package restserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIPConfigsRequest(t *testing.T, podInfo cns.PodInfo) cns.IPConfigsRequest {
+	b, err := podInfo.OrchestratorContext()
+	require.NoError(t, err)
+	return cns.IPConfigsRequest{
+		PodInterfaceID:      podInfo.InterfaceID(),
+		InfraContainerID:    podInfo.InfraContainerID(),
+		OrchestratorContext: b,
+	}
+}
+
+func TestReserveIPConfigs(t *testing.T) {
+	svc := getTestService()
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		testIPID1: NewPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+		testIPID2: NewPodState(testIP2, testIPID2, testNCID, types.Available, 0),
+	}
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	_, err := svc.ReserveIPConfigs(cns.ReserveIPConfigRequest{PodName: testPod1Info.Name()})
+	require.ErrorIs(t, err, ErrInvalidIPReservation)
+
+	reservation, err := svc.ReserveIPConfigs(cns.ReserveIPConfigRequest{
+		PodName:            testPod2Info.Name(),
+		PodNamespace:       testPod2Info.Namespace(),
+		DesiredIPAddresses: []string{testIP2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testIP2}, reservation.IPAddresses)
+	assert.WithinDuration(t, time.Now().Add(cns.DefaultIPReservationTTL), reservation.ExpiresAt, time.Minute)
+
+	// the IP reserved for the second Pod can't be reserved or assigned to another Pod
+	_, err = svc.ReserveIPConfigs(cns.ReserveIPConfigRequest{
+		PodName:            testPod3Info.Name(),
+		PodNamespace:       testPod3Info.Namespace(),
+		DesiredIPAddresses: []string{testIP2},
+	})
+	require.ErrorIs(t, err, ErrDesiredIPUnavailable)
+	req := newIPConfigsRequest(t, testPod1Info)
+	req.DesiredIPAddresses = []string{testIP2}
+	_, err = requestIPConfigsHelper(svc, req)
+	require.ErrorIs(t, err, ErrDesiredIPUnavailable)
+
+	// a Pod without reservation gets an unreserved IP, and the Pod with the reservation gets the reserved IP
+	podIPInfo, err := requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod1Info))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo[0].PodIPConfig.IPAddress)
+	podIPInfo, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod2Info))
+	require.NoError(t, err)
+	assert.Equal(t, testIP2, podIPInfo[0].PodIPConfig.IPAddress)
+
+	// the reservation is used up
+	assert.False(t, svc.UnreserveIPConfigs(cns.UnreserveIPConfigRequest{PodName: testPod2Info.Name(), PodNamespace: testPod2Info.Namespace()}))
+}
+
+func TestIPReservationExpiry(t *testing.T) {
+	svc := getTestService()
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		testIPID1: NewPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+	}
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	reservation, err := svc.ReserveIPConfigs(cns.ReserveIPConfigRequest{
+		PodName:      testPod2Info.Name(),
+		PodNamespace: testPod2Info.Namespace(),
+		TTLInSeconds: 60,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{testIP1}, reservation.IPAddresses)
+
+	// the only IP is reserved, so it's neither assigned to another Pod nor released by a scale down
+	_, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod1Info))
+	require.ErrorIs(t, err, ErrNoAvailableIPs)
+	released, err := svc.MarkIPAsPendingRelease(1)
+	require.NoError(t, err)
+	assert.Empty(t, released)
+
+	// once the reservation expires the IP is assigned to any Pod
+	svc.Lock()
+	svc.ipReservations.prune(time.Now().Add(time.Minute))
+	svc.Unlock()
+	podIPInfo, err := requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod1Info))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo[0].PodIPConfig.IPAddress)
+}
+
+func TestUnreserveIPConfigs(t *testing.T) {
+	svc := getTestService()
+	ipconfigs := map[string]cns.IPConfigurationStatus{
+		testIPID1: NewPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+	}
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+
+	_, err := svc.ReserveIPConfigs(cns.ReserveIPConfigRequest{PodName: testPod2Info.Name(), PodNamespace: testPod2Info.Namespace()})
+	require.NoError(t, err)
+	require.True(t, svc.UnreserveIPConfigs(cns.UnreserveIPConfigRequest{PodName: testPod2Info.Name(), PodNamespace: testPod2Info.Namespace()}))
+	require.False(t, svc.UnreserveIPConfigs(cns.UnreserveIPConfigRequest{PodName: testPod2Info.Name(), PodNamespace: testPod2Info.Namespace()}))
+
+	podIPInfo, err := requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod1Info))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo[0].PodIPConfig.IPAddress)
+}
@@ -1,10 +1,13 @@
 package restserver
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/metric"
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -17,6 +20,8 @@ const (
 	cnsReturnCode            = "cns_return_code"
 	customerMetricLabel      = "customer_metric"
 	customerMetricLabelValue = "customer metric"
+	// maxRecordedResponseBytes bounds the body of a response recorded to read its CNS return code from.
+	maxRecordedResponseBytes = 64 << 10
 )
 
 var (
@@ -29,6 +34,13 @@ var (
 		},
 		[]string{"url", "verb", "cns_return_code"},
 	)
+	httpRequestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Count of requests by endpoint, verb, and response code.",
+		},
+		[]string{"url", "verb", "cns_return_code"},
+	)
 	ipAssignmentLatency = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name: "ip_assignment_latency_seconds",
@@ -62,6 +74,12 @@ var (
 		},
 		[]string{"ok"},
 	)
+	cniConflistReady = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cni_conflist_ready",
+			Help: "1 if the readiness gated CNI conflist is present, 0 otherwise.",
+		},
+	)
 	allocatedIPCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "cx_allocated_ips_v2",
@@ -107,10 +125,12 @@ var (
 func init() {
 	metrics.Registry.MustRegister(
 		httpRequestLatency,
+		httpRequestCount,
 		ipAssignmentLatency,
 		ipConfigStatusStateTransitionTime,
 		syncHostNCVersionCount,
 		syncHostNCVersionLatency,
+		cniConflistReady,
 		allocatedIPCount,
 		assignedIPCount,
 		availableIPCount,
@@ -119,19 +139,68 @@ func init() {
 	)
 }
 
-// Every http response is 200 so we really want cns  response code.
-// Hard tto do with middleware unless we derserialize the responses but making it an explit header works around it.
-// if that doesn't work we could have a separate countervec just for response codes.
+// Every http response is 200 so we really want cns response code.
+// The handlers which set it as an explicit header are cheapest, for the others it's read from the JSON response.
 
-func newHandlerFuncWithHistogram(handler http.HandlerFunc, histogram *prometheus.HistogramVec) http.HandlerFunc {
+// newHandlerFuncWithMetrics records the count and latency of the requests to the handler, labeled with the path it's
+// registered on rather than the request URI, so that query strings don't add labels.
+func newHandlerFuncWithMetrics(path string, handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		start := time.Now()
+		rw := &metricsResponseWriter{ResponseWriter: w}
 		defer func() {
-			code := w.Header().Get(cnsReturnCode)
-			histogram.WithLabelValues(req.URL.RequestURI(), req.Method, code).Observe(time.Since(start).Seconds())
+			code := rw.returnCode()
+			httpRequestLatency.WithLabelValues(path, req.Method, code).Observe(time.Since(start).Seconds())
+			httpRequestCount.WithLabelValues(path, req.Method, code).Inc()
 		}()
-		handler(w, req)
+		handler(rw, req)
+	}
+}
+
+// metricsResponseWriter records the body of the response, up to maxRecordedResponseBytes.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *metricsResponseWriter) Write(b []byte) (int, error) {
+	if !w.truncated {
+		if w.body.Len()+len(b) > maxRecordedResponseBytes {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck // the writer is transparent
+}
+
+// returnCode returns the CNS return code of the response: the cnsReturnCode header, else the ReturnCode of the
+// JSON response or of its Response. It's empty if the response has none, e.g. health checks.
+func (w *metricsResponseWriter) returnCode() string {
+	if code := w.Header().Get(cnsReturnCode); code != "" {
+		return code
+	}
+	if w.truncated || w.body.Len() == 0 {
+		return ""
+	}
+	var resp struct {
+		ReturnCode *types.ResponseCode
+		Response   *struct {
+			ReturnCode *types.ResponseCode
+		}
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		return ""
+	}
+	if resp.ReturnCode != nil {
+		return resp.ReturnCode.String()
+	}
+	if resp.Response != nil && resp.Response.ReturnCode != nil {
+		return resp.Response.ReturnCode.String()
 	}
+	return ""
 }
 
 func stateTransitionMiddleware(i *cns.IPConfigurationStatus, s types.IPState) {
@@ -149,4 +218,5 @@ func publishIPStateMetrics(state *ipState) {
 	availableIPCount.WithLabelValues(labels...).Set(float64(state.availableIPs))
 	pendingProgrammingIPCount.WithLabelValues(labels...).Set(float64(state.programmingIPs))
 	pendingReleaseIPCount.WithLabelValues(labels...).Set(float64(state.releasingIPs))
+	metric.ObservePoolAvailability(int(state.availableIPs), int(state.allocatedIPs))
 }
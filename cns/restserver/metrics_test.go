@@ -0,0 +1,82 @@
+package restserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerFuncWithMetricsReturnCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		code    string
+	}{
+		{
+			name: "header",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set(cnsReturnCode, types.NotFound.String())
+				_, _ = w.Write([]byte(`{"ReturnCode":0}`))
+			},
+			code: types.NotFound.String(),
+		},
+		{
+			name: "top level",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"ReturnCode":2,"Message":"invalid"}`))
+			},
+			code: types.InvalidParameter.String(),
+		},
+		{
+			name: "nested",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"podIPInfo":[],"response":{"ReturnCode":0,"Message":""}}`))
+			},
+			code: types.Success.String(),
+		},
+		{
+			name: "not json",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`ok`))
+			},
+		},
+		{
+			name: "truncated",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"ReturnCode":0,"Message":"`))
+				_, _ = w.Write(make([]byte, maxRecordedResponseBytes))
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/test/" + strings.ReplaceAll(tt.name, " ", "-")
+			before := testutil.ToFloat64(httpRequestCount.WithLabelValues(path, http.MethodPost, tt.code))
+			handler := newHandlerFuncWithMetrics(path, tt.handler)
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, path+"?query=1", http.NoBody))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.InDelta(t, before+1, testutil.ToFloat64(httpRequestCount.WithLabelValues(path, http.MethodPost, tt.code)), 0)
+		})
+	}
+}
+
+func TestHandlerFuncWithMetricsWritesThrough(t *testing.T) {
+	handler := newHandlerFuncWithMetrics(cns.HealthzPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("not ready"))
+	})
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, cns.HealthzPath, http.NoBody))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "not ready", w.Body.String())
+}
@@ -0,0 +1,68 @@
+package restserver
+
+import (
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// ErrNoSubnetForNamespace is returned in subnet-per-namespace mode when no NC of the node serves the namespace of the
+// Pod: its subnet isn't on the node, or every subnet is mapped to other namespaces.
+var ErrNoSubnetForNamespace = errors.New("no subnet for the namespace of the pod")
+
+// namespaceSubnets are the NCs the Pods of each namespace get their IPs from, in subnet-per-namespace mode. They are
+// guarded by the lock of the HTTPRestService, like the IP pool. They aren't persisted: the NNC reconciler sets them
+// again on restart.
+type namespaceSubnets struct {
+	// ncIDsByNamespace are the NCs of the subnet mapped to each namespace, empty if the mode is disabled.
+	ncIDsByNamespace map[string][]string
+	// mappedNCIDs are the NCs of the subnets mapped to any namespace.
+	mappedNCIDs map[string]struct{}
+}
+
+// SetNamespaceSubnets enables the subnet-per-namespace mode with the NCs of the subnet mapped to each namespace, or
+// disables it if there are none. The Pods of the namespaces which aren't mapped get their IPs from the NCs of the
+// unmapped subnets.
+func (service *HTTPRestService) SetNamespaceSubnets(ncIDsByNamespace map[string][]string) {
+	mapped := make(map[string]struct{})
+	for _, ncIDs := range ncIDsByNamespace {
+		for _, ncID := range ncIDs {
+			mapped[ncID] = struct{}{}
+		}
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	if len(ncIDsByNamespace) != len(service.namespaceSubnets.ncIDsByNamespace) {
+		logger.Printf("[SetNamespaceSubnets] NCs of %d namespaces are mapped", len(ncIDsByNamespace))
+	}
+	service.namespaceSubnets = namespaceSubnets{ncIDsByNamespace: ncIDsByNamespace, mappedNCIDs: mapped}
+}
+
+// ncIDsForNamespace returns the NCs the Pods of the namespace get one IP each from: every NC of the node, unless
+// the subnet-per-namespace mode is enabled.
+// Note: this func is an untransacted API as the caller will take a Service lock
+func (service *HTTPRestService) ncIDsForNamespace(namespace string) map[string]struct{} {
+	ncIDs := make(map[string]struct{}, len(service.state.ContainerStatus))
+	if len(service.namespaceSubnets.ncIDsByNamespace) == 0 {
+		for ncID := range service.state.ContainerStatus {
+			ncIDs[ncID] = struct{}{}
+		}
+		return ncIDs
+	}
+
+	if mapped, ok := service.namespaceSubnets.ncIDsByNamespace[namespace]; ok {
+		for _, ncID := range mapped {
+			if _, exists := service.state.ContainerStatus[ncID]; exists {
+				ncIDs[ncID] = struct{}{}
+			}
+		}
+		return ncIDs
+	}
+
+	for ncID := range service.state.ContainerStatus {
+		if _, ok := service.namespaceSubnets.mappedNCIDs[ncID]; !ok {
+			ncIDs[ncID] = struct{}{}
+		}
+	}
+	return ncIDs
+}
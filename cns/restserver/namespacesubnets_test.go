@@ -0,0 +1,52 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignIPConfigsFromNamespaceSubnet(t *testing.T) {
+	svc := getTestService()
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		testIPID1: NewPodState(testIP1, testIPID1, testNCID, types.Available, 0),
+		testIPID2: NewPodState(testIP2, testIPID2, testNCID, types.Available, 0),
+	}, testNCID))
+	require.NoError(t, UpdatePodIPConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		testIPID1v6: NewPodState(testIP1v6, testIPID1v6, testNCIDv6, types.Available, 0),
+	}, testNCIDv6))
+
+	svc.SetNamespaceSubnets(map[string][]string{
+		testPod1Info.Namespace(): {testNCIDv6},
+		testPod3Info.Namespace(): nil,
+	})
+
+	// the Pods of a mapped namespace only get IPs from the NCs of its subnet
+	podIPInfo, err := requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod1Info))
+	require.NoError(t, err)
+	require.Len(t, podIPInfo, 1)
+	assert.Equal(t, testIP1v6, podIPInfo[0].PodIPConfig.IPAddress)
+
+	// the Pods of the other namespaces get IPs from the unmapped NCs
+	podIPInfo, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod2Info))
+	require.NoError(t, err)
+	require.Len(t, podIPInfo, 1)
+	assert.Equal(t, testNCID, svc.PodIPConfigState[svc.PodIPIDByPodInterfaceKey[testPod2Info.Key()][0]].NCID)
+
+	// the subnet of the namespace isn't on the node
+	_, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod3Info))
+	require.ErrorIs(t, err, ErrNoSubnetForNamespace)
+
+	// the subnet of the namespace has no free IP left, even though the unmapped NC has
+	pod4Info := cns.NewPodInfo("718e04-eth1", testPod4GUID, "testpod4", testPod1Info.Namespace())
+	_, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, pod4Info))
+	require.ErrorIs(t, err, ErrNoAvailableIPs)
+
+	// without mapping, the Pods need a free IP in every NC
+	svc.SetNamespaceSubnets(nil)
+	_, err = requestIPConfigsHelper(svc, newIPConfigsRequest(t, testPod3Info))
+	require.ErrorIs(t, err, ErrNoAvailableIPs)
+}
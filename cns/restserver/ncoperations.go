@@ -0,0 +1,155 @@
+package restserver
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/google/uuid"
+)
+
+// ncOperationRetention is how long the status of a completed NC operation can be queried.
+const ncOperationRetention = 10 * time.Minute
+
+// ncOperation is an asynchronous create or update of an NC.
+type ncOperation struct {
+	status cns.OperationStatus
+	req    *cns.CreateNetworkContainerRequest
+	// done is closed once the operation is no longer pending.
+	done chan struct{}
+}
+
+// ncOperationJournal tracks the asynchronous NC operations until ncOperationRetention after they complete.
+// It isn't persisted: the NNC reconciler programs the NCs again after a restart.
+type ncOperationJournal struct {
+	sync.Mutex
+	operations map[string]*ncOperation
+	// latest is the last operation of each NC. The operations of an NC run in order.
+	latest map[string]*ncOperation
+}
+
+// add journals a pending operation for the request, unless the last operation of the NC is pending with the same
+// request, which is returned instead. Returns whether the operation is new, and the previous operation of the NC
+// which a new operation must wait for.
+func (j *ncOperationJournal) add(req *cns.CreateNetworkContainerRequest, now time.Time) (op, previous *ncOperation, isNew bool) {
+	j.Lock()
+	defer j.Unlock()
+	if j.operations == nil {
+		j.operations = map[string]*ncOperation{}
+		j.latest = map[string]*ncOperation{}
+	}
+	j.prune(now)
+
+	previous = j.latest[req.NetworkContainerid]
+	if previous != nil && previous.status.State == cns.OperationPending && reflect.DeepEqual(previous.req, req) {
+		return previous, nil, false
+	}
+	op = &ncOperation{
+		status: cns.OperationStatus{
+			OperationID:        uuid.New().String(),
+			NetworkContainerID: req.NetworkContainerid,
+			State:              cns.OperationPending,
+			StartTime:          now,
+		},
+		req:  req,
+		done: make(chan struct{}),
+	}
+	j.operations[op.status.OperationID] = op
+	j.latest[req.NetworkContainerid] = op
+	return op, previous, true
+}
+
+// complete records the result of the operation.
+func (j *ncOperationJournal) complete(op *ncOperation, code types.ResponseCode, now time.Time) {
+	j.Lock()
+	defer j.Unlock()
+	op.status.ResponseCode = code
+	op.status.State = cns.OperationSucceeded
+	if code != types.Success {
+		op.status.State = cns.OperationFailed
+	}
+	op.status.EndTime = now
+	close(op.done)
+}
+
+func (j *ncOperationJournal) status(operationID string) (cns.OperationStatus, bool) {
+	j.Lock()
+	defer j.Unlock()
+	op, ok := j.operations[operationID]
+	if !ok {
+		return cns.OperationStatus{}, false
+	}
+	return op.status, true
+}
+
+// prune forgets the operations completed more than ncOperationRetention ago. The journal must be locked.
+func (j *ncOperationJournal) prune(now time.Time) {
+	for id, op := range j.operations {
+		if op.status.State == cns.OperationPending || now.Sub(op.status.EndTime) < ncOperationRetention {
+			continue
+		}
+		delete(j.operations, id)
+		if j.latest[op.status.NetworkContainerID] == op {
+			delete(j.latest, op.status.NetworkContainerID)
+		}
+	}
+}
+
+// CreateOrUpdateNetworkContainerAsync creates or updates the NC as CreateOrUpdateNetworkContainerInternal does, in the
+// background, and returns the ID of the operation to get the status of with GetOperationStatus. The operations of an
+// NC run in order, and an NC which is already pending with the same request isn't programmed again: the ID of the
+// pending operation is returned instead.
+func (service *HTTPRestService) CreateOrUpdateNetworkContainerAsync(req *cns.CreateNetworkContainerRequest) (string, types.ResponseCode) {
+	if req.NetworkContainerid == "" {
+		logger.Errorf("[Azure CNS] Error. NetworkContainerid is empty")
+		return "", types.NetworkContainerNotSpecified
+	}
+
+	op, previous, isNew := service.ncOperations.add(req, time.Now())
+	if !isNew {
+		logger.Printf("[Azure CNS] NC %s is already being programmed by operation %s", req.NetworkContainerid, op.status.OperationID)
+		return op.status.OperationID, types.Success
+	}
+
+	logger.Printf("[Azure CNS] Programming NC %s with operation %s", req.NetworkContainerid, op.status.OperationID)
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		code := service.CreateOrUpdateNetworkContainerInternal(req)
+		service.ncOperations.complete(op, code, time.Now())
+		logger.Printf("[Azure CNS] Operation %s programming NC %s completed with %s", op.status.OperationID, req.NetworkContainerid, code)
+	}()
+	return op.status.OperationID, types.Success
+}
+
+// GetOperationStatus returns the status of the asynchronous NC operation, or UnknownOperationID if there is no such
+// operation or it completed more than ncOperationRetention ago.
+func (service *HTTPRestService) GetOperationStatus(operationID string) (cns.OperationStatus, types.ResponseCode) {
+	status, ok := service.ncOperations.status(operationID)
+	if !ok {
+		return cns.OperationStatus{}, types.UnknownOperationID
+	}
+	return status, types.Success
+}
+
+func (service *HTTPRestService) getOperationStatus(w http.ResponseWriter, r *http.Request) {
+	var req cns.GetOperationStatusRequest
+	err := service.Listener.Decode(w, r, &req)
+	logger.Request(service.Name, &req, err)
+	if err != nil {
+		return
+	}
+
+	resp := cns.GetOperationStatusResponse{}
+	resp.Operation, resp.Response.ReturnCode = service.GetOperationStatus(req.OperationID)
+	if resp.Response.ReturnCode != types.Success {
+		resp.Response.Message = "unknown operation " + req.OperationID
+	}
+	err = service.Listener.Encode(w, &resp)
+	logger.Response(service.Name, resp, resp.Response.ReturnCode, err)
+}
@@ -0,0 +1,84 @@
+package restserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNCOperationJournal(t *testing.T) {
+	var j ncOperationJournal
+	now := time.Now()
+	req := &cns.CreateNetworkContainerRequest{NetworkContainerid: ncID, Version: "0"}
+
+	op, previous, isNew := j.add(req, now)
+	require.True(t, isNew)
+	require.Nil(t, previous)
+
+	// the same request doesn't start another operation while the NC is pending
+	same := *req
+	pending, _, isNew := j.add(&same, now)
+	require.False(t, isNew)
+	require.Equal(t, op, pending)
+
+	// another request waits for the pending operation
+	updated := &cns.CreateNetworkContainerRequest{NetworkContainerid: ncID, Version: "1"}
+	next, previous, isNew := j.add(updated, now)
+	require.True(t, isNew)
+	require.Equal(t, op, previous)
+
+	j.complete(op, types.Success, now)
+	j.complete(next, types.InvalidPrimaryIPConfig, now)
+	status, ok := j.status(op.status.OperationID)
+	require.True(t, ok)
+	assert.Equal(t, cns.OperationSucceeded, status.State)
+	status, ok = j.status(next.status.OperationID)
+	require.True(t, ok)
+	assert.Equal(t, cns.OperationFailed, status.State)
+	assert.Equal(t, types.InvalidPrimaryIPConfig, status.ResponseCode)
+
+	// completed operations are forgotten after the retention
+	j.add(&cns.CreateNetworkContainerRequest{NetworkContainerid: "other"}, now.Add(ncOperationRetention))
+	_, ok = j.status(op.status.OperationID)
+	require.False(t, ok)
+	require.NotContains(t, j.latest, ncID)
+}
+
+func TestCreateOrUpdateNetworkContainerAsync(t *testing.T) {
+	restartService()
+	setEnv(t)
+	setOrchestratorTypeInternal(cns.KubernetesCRD)
+
+	secondaryIPConfigs := map[string]cns.SecondaryIPConfig{uuid.New().String(): newSecondaryIPConfig("10.0.0.16", 0)}
+	req := generateNetworkContainerRequest(secondaryIPConfigs, ncID, "0")
+	operationID, code := svc.CreateOrUpdateNetworkContainerAsync(req)
+	require.Equal(t, types.Success, code)
+
+	var status cns.OperationStatus
+	require.Eventually(t, func() bool {
+		status, code = svc.GetOperationStatus(operationID)
+		return code == types.Success && status.State != cns.OperationPending
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, cns.OperationSucceeded, status.State)
+	assert.Equal(t, ncID, status.NetworkContainerID)
+	assert.Contains(t, svc.state.ContainerStatus, ncID)
+
+	invalid := generateNetworkContainerRequest(secondaryIPConfigs, ncID, "1")
+	invalid.IPConfiguration.IPSubnet = cns.IPSubnet{}
+	operationID, code = svc.CreateOrUpdateNetworkContainerAsync(invalid)
+	require.Equal(t, types.Success, code)
+	require.Eventually(t, func() bool {
+		status, code = svc.GetOperationStatus(operationID)
+		return code == types.Success && status.State != cns.OperationPending
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, cns.OperationFailed, status.State)
+	assert.Equal(t, types.InvalidPrimaryIPConfig, status.ResponseCode)
+
+	_, code = svc.GetOperationStatus("unknown")
+	assert.Equal(t, types.UnknownOperationID, code)
+}
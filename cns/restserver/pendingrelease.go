@@ -0,0 +1,75 @@
+package restserver
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+// pendingReleaseIPConfigs returns the IPs which CNS is releasing from the node, and whether the CNI confirmed them.
+func (service *HTTPRestService) pendingReleaseIPConfigs() []cns.PendingReleaseIPConfig {
+	pendingIPs := service.GetPendingReleaseIPConfigs()
+	ipConfigs := make([]cns.PendingReleaseIPConfig, 0, len(pendingIPs))
+	for i := range pendingIPs {
+		ipConfigs = append(ipConfigs, cns.PendingReleaseIPConfig{
+			ID:        pendingIPs[i].ID,
+			IPAddress: pendingIPs[i].IPAddress,
+			Confirmed: service.IPAMPoolMonitor.IsReleaseConfirmed(pendingIPs[i].ID),
+		})
+	}
+	return ipConfigs
+}
+
+// confirmPendingRelease confirms the release of the IPs with the IDs which are still PendingRelease.
+// PendingRelease IPs are never assigned again, so a confirmed IP stays unused until it's released.
+func (service *HTTPRestService) confirmPendingRelease(ipConfigIDs []string) {
+	pendingIPs := service.GetPendingReleaseIPConfigs()
+	pending := make(map[string]struct{}, len(pendingIPs))
+	for i := range pendingIPs {
+		pending[pendingIPs[i].ID] = struct{}{}
+	}
+	confirmed := make([]string, 0, len(ipConfigIDs))
+	for _, id := range ipConfigIDs {
+		if _, ok := pending[id]; ok {
+			confirmed = append(confirmed, id)
+		}
+	}
+	logger.Printf("[Azure CNS] Confirming release of %d PendingRelease IPs", len(confirmed))
+	service.IPAMPoolMonitor.ConfirmRelease(confirmed)
+}
+
+// pendingReleaseHandler returns the PendingRelease IPs on GET, and confirms the release of IPs on POST.
+func (service *HTTPRestService) pendingReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.PendingReleaseRequest
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := service.Listener.Decode(w, r, &req)
+		logger.Request(service.Name, req, err)
+		if err != nil {
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var resp cns.PendingReleaseResponse
+	switch {
+	case service.IPAMPoolMonitor == nil:
+		resp.Response = cns.Response{
+			ReturnCode: types.UnsupportedAPI,
+			Message:    "releasing IPs requires the IPAM pool monitor",
+		}
+	case r.Method == http.MethodPost:
+		service.confirmPendingRelease(req.ConfirmedIPConfigIDs)
+		fallthrough
+	default:
+		resp.IPConfigs = service.pendingReleaseIPConfigs()
+	}
+
+	err := service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name, req, resp, resp.Response.ReturnCode, err)
+}
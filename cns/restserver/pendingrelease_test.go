@@ -0,0 +1,43 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/fakes"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmPendingRelease(t *testing.T) {
+	svc := getTestService()
+	monitor := &fakes.MonitorFake{}
+	svc.IPAMPoolMonitor = monitor
+
+	ipconfigs := make(map[string]cns.IPConfigurationStatus)
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testIPID1, testNCID, types.PendingRelease, ipPrefixBitsv4, 0, nil)
+	ipconfigs[state1.ID] = state1
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testIPID2, testNCID, types.Available, ipPrefixBitsv4, 0, nil)
+	ipconfigs[state2.ID] = state2
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+	svc.PodIPConfigState[testIPID1] = state1
+
+	assert.Equal(t, []cns.PendingReleaseIPConfig{{ID: testIPID1, IPAddress: testIP1}}, svc.pendingReleaseIPConfigs())
+
+	// only PendingRelease IPs can be confirmed
+	svc.confirmPendingRelease([]string{testIPID1, testIPID2})
+	assert.True(t, monitor.IsReleaseConfirmed(testIPID1))
+	assert.False(t, monitor.IsReleaseConfirmed(testIPID2))
+	assert.Equal(t, []cns.PendingReleaseIPConfig{{ID: testIPID1, IPAddress: testIP1, Confirmed: true}}, svc.pendingReleaseIPConfigs())
+
+	// a PendingRelease IP isn't assigned again
+	req := cns.IPConfigsRequest{
+		PodInterfaceID:     testPod1Info.InterfaceID(),
+		InfraContainerID:   testPod1Info.InfraContainerID(),
+		DesiredIPAddresses: []string{testIP1},
+	}
+	req.OrchestratorContext, _ = testPod1Info.OrchestratorContext()
+	_, err := svc.requestIPConfigHandlerHelper(req)
+	require.Error(t, err)
+}
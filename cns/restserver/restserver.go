@@ -12,6 +12,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns/common"
 	"github.com/Azure/azure-container-networking/cns/dockerclient"
 	"github.com/Azure/azure-container-networking/cns/ipamclient"
+	"github.com/Azure/azure-container-networking/cns/iphooks"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/networkcontainers"
 	"github.com/Azure/azure-container-networking/cns/routes"
@@ -74,6 +75,13 @@ type HTTPRestService struct {
 	EndpointStateStore      store.KeyValueStore
 	cniConflistGenerator    CNIConflistGenerator
 	generateCNIConflistOnce sync.Once
+	cniConflistReady        bool
+	snapshotSigningKey      []byte
+	healthChecks            healthChecks
+	ipHooks                 *iphooks.Dispatcher
+	ncOperations            ncOperationJournal
+	ipReservations          ipReservations
+	namespaceSubnets        namespaceSubnets
 }
 
 type CNIConflistGenerator interface {
@@ -81,6 +89,13 @@ type CNIConflistGenerator interface {
 	Close() error
 }
 
+// CNIConflistRemover is implemented by CNIConflistGenerators which can remove the conflist.
+// The conflist of such generators gates kubelet network readiness: it is written while CNS is ready
+// to serve CNI ADDs, and removed while it is not, instead of being generated once.
+type CNIConflistRemover interface {
+	Remove() error
+}
+
 type NoOpConflistGenerator struct{}
 
 func (*NoOpConflistGenerator) Generate() error {
@@ -228,67 +243,84 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 		return err
 	}
 
-	// Add handlers.
+	// Add handlers, recording the count and latency of their requests and logging the correlation IDs of the CNI.
 	listener := service.Listener
+	addHandler := func(path string, handler http.HandlerFunc) {
+		listener.AddHandler(path, newHandlerFuncWithMetrics(path, newHandlerFuncWithCorrelationID(handler)))
+	}
 	// default handlers
-	listener.AddHandler(cns.SetEnvironmentPath, service.setEnvironment)
-	listener.AddHandler(cns.CreateNetworkPath, service.createNetwork)
-	listener.AddHandler(cns.DeleteNetworkPath, service.deleteNetwork)
-	listener.AddHandler(cns.ReserveIPAddressPath, service.reserveIPAddress)
-	listener.AddHandler(cns.ReleaseIPAddressPath, service.releaseIPAddress)
-	listener.AddHandler(cns.GetHostLocalIPPath, service.getHostLocalIP)
-	listener.AddHandler(cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
-	listener.AddHandler(cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
-	listener.AddHandler(cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
-	listener.AddHandler(cns.DeleteNetworkContainer, service.deleteNetworkContainer)
-	listener.AddHandler(cns.GetInterfaceForContainer, service.getInterfaceForContainer)
-	listener.AddHandler(cns.SetOrchestratorType, service.setOrchestratorType)
-	listener.AddHandler(cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
-	listener.AddHandler(cns.GetAllNetworkContainers, service.getAllNetworkContainers)
-	listener.AddHandler(cns.AttachContainerToNetwork, service.attachNetworkContainerToNetwork)
-	listener.AddHandler(cns.DetachContainerFromNetwork, service.detachNetworkContainerFromNetwork)
-	listener.AddHandler(cns.CreateHnsNetworkPath, service.createHnsNetwork)
-	listener.AddHandler(cns.DeleteHnsNetworkPath, service.deleteHnsNetwork)
-	listener.AddHandler(cns.NumberOfCPUCoresPath, service.getNumberOfCPUCores)
-	listener.AddHandler(cns.CreateHostNCApipaEndpointPath, service.createHostNCApipaEndpoint)
-	listener.AddHandler(cns.DeleteHostNCApipaEndpointPath, service.deleteHostNCApipaEndpoint)
-	listener.AddHandler(cns.PublishNetworkContainer, service.publishNetworkContainer)
-	listener.AddHandler(cns.UnpublishNetworkContainer, service.unpublishNetworkContainer)
-	listener.AddHandler(cns.RequestIPConfig, newHandlerFuncWithHistogram(service.requestIPConfigHandler, httpRequestLatency))
-	listener.AddHandler(cns.RequestIPConfigs, newHandlerFuncWithHistogram(service.requestIPConfigsHandler, httpRequestLatency))
-	listener.AddHandler(cns.ReleaseIPConfig, newHandlerFuncWithHistogram(service.releaseIPConfigHandler, httpRequestLatency))
-	listener.AddHandler(cns.ReleaseIPConfigs, newHandlerFuncWithHistogram(service.releaseIPConfigsHandler, httpRequestLatency))
-	listener.AddHandler(cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
-	listener.AddHandler(cns.PathDebugIPAddresses, service.handleDebugIPAddresses)
-	listener.AddHandler(cns.PathDebugPodContext, service.handleDebugPodContext)
-	listener.AddHandler(cns.PathDebugRestData, service.handleDebugRestData)
-	listener.AddHandler(cns.NetworkContainersURLPath, service.getOrRefreshNetworkContainers)
-	listener.AddHandler(cns.GetHomeAz, service.getHomeAz)
+	addHandler(cns.SetEnvironmentPath, service.setEnvironment)
+	addHandler(cns.CreateNetworkPath, service.createNetwork)
+	addHandler(cns.DeleteNetworkPath, service.deleteNetwork)
+	addHandler(cns.ReserveIPAddressPath, service.reserveIPAddress)
+	addHandler(cns.ReleaseIPAddressPath, service.releaseIPAddress)
+	addHandler(cns.GetHostLocalIPPath, service.getHostLocalIP)
+	addHandler(cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
+	addHandler(cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
+	addHandler(cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
+	addHandler(cns.PatchNetworkContainer, service.patchNetworkContainer)
+	addHandler(cns.GetOperationStatus, service.getOperationStatus)
+	addHandler(cns.DeleteNetworkContainer, service.deleteNetworkContainer)
+	addHandler(cns.GetInterfaceForContainer, service.getInterfaceForContainer)
+	addHandler(cns.SetOrchestratorType, service.setOrchestratorType)
+	addHandler(cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
+	addHandler(cns.GetAllNetworkContainers, service.getAllNetworkContainers)
+	addHandler(cns.AttachContainerToNetwork, service.attachNetworkContainerToNetwork)
+	addHandler(cns.DetachContainerFromNetwork, service.detachNetworkContainerFromNetwork)
+	addHandler(cns.CreateHnsNetworkPath, service.createHnsNetwork)
+	addHandler(cns.DeleteHnsNetworkPath, service.deleteHnsNetwork)
+	addHandler(cns.NumberOfCPUCoresPath, service.getNumberOfCPUCores)
+	addHandler(cns.CreateHostNCApipaEndpointPath, service.createHostNCApipaEndpoint)
+	addHandler(cns.DeleteHostNCApipaEndpointPath, service.deleteHostNCApipaEndpoint)
+	addHandler(cns.PublishNetworkContainer, service.publishNetworkContainer)
+	addHandler(cns.UnpublishNetworkContainer, service.unpublishNetworkContainer)
+	addHandler(cns.RequestIPConfig, service.requestIPConfigHandler)
+	addHandler(cns.RequestIPConfigs, service.requestIPConfigsHandler)
+	addHandler(cns.ReleaseIPConfig, service.releaseIPConfigHandler)
+	addHandler(cns.ReleaseIPConfigs, service.releaseIPConfigsHandler)
+	addHandler(cns.ReserveIPConfig, service.reserveIPConfigHandler)
+	addHandler(cns.UnreserveIPConfig, service.unreserveIPConfigHandler)
+	addHandler(cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
+	addHandler(cns.PathDebugIPAddresses, service.handleDebugIPAddresses)
+	addHandler(cns.PathDebugPodContext, service.handleDebugPodContext)
+	addHandler(cns.PathDebugRestData, service.handleDebugRestData)
+	addHandler(cns.PathDebugSnapshot, service.handleDebugSnapshot)
+	addHandler(cns.GetPodContextByIP, service.getPodContextByIPHandler)
+	addHandler(cns.GetPodContextsByIP, service.getPodContextsByIPHandler)
+	addHandler(cns.DrainPath, service.drainHandler)
+	addHandler(cns.PendingReleasePath, service.pendingReleaseHandler)
+	addHandler(cns.NetworkContainersURLPath, service.getOrRefreshNetworkContainers)
+	addHandler(cns.GetHomeAz, service.getHomeAz)
+	addHandler(cns.HealthzPath, service.healthzHandler)
+	addHandler(cns.ReadyzPath, service.readyzHandler)
+	service.addDefaultHealthChecks()
 
 	// handlers for v0.2
-	listener.AddHandler(cns.V2Prefix+cns.SetEnvironmentPath, service.setEnvironment)
-	listener.AddHandler(cns.V2Prefix+cns.CreateNetworkPath, service.createNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteNetworkPath, service.deleteNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.ReserveIPAddressPath, service.reserveIPAddress)
-	listener.AddHandler(cns.V2Prefix+cns.ReleaseIPAddressPath, service.releaseIPAddress)
-	listener.AddHandler(cns.V2Prefix+cns.GetHostLocalIPPath, service.getHostLocalIP)
-	listener.AddHandler(cns.V2Prefix+cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
-	listener.AddHandler(cns.V2Prefix+cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
-	listener.AddHandler(cns.V2Prefix+cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteNetworkContainer, service.deleteNetworkContainer)
-	listener.AddHandler(cns.V2Prefix+cns.GetInterfaceForContainer, service.getInterfaceForContainer)
-	listener.AddHandler(cns.V2Prefix+cns.SetOrchestratorType, service.setOrchestratorType)
-	listener.AddHandler(cns.V2Prefix+cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
-	listener.AddHandler(cns.V2Prefix+cns.GetAllNetworkContainers, service.getAllNetworkContainers)
-	listener.AddHandler(cns.V2Prefix+cns.AttachContainerToNetwork, service.attachNetworkContainerToNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.DetachContainerFromNetwork, service.detachNetworkContainerFromNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.CreateHnsNetworkPath, service.createHnsNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteHnsNetworkPath, service.deleteHnsNetwork)
-	listener.AddHandler(cns.V2Prefix+cns.NumberOfCPUCoresPath, service.getNumberOfCPUCores)
-	listener.AddHandler(cns.V2Prefix+cns.CreateHostNCApipaEndpointPath, service.createHostNCApipaEndpoint)
-	listener.AddHandler(cns.V2Prefix+cns.DeleteHostNCApipaEndpointPath, service.deleteHostNCApipaEndpoint)
-	listener.AddHandler(cns.V2Prefix+cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
-	listener.AddHandler(cns.V2Prefix+cns.GetHomeAz, service.getHomeAz)
+	addHandler(cns.V2Prefix+cns.SetEnvironmentPath, service.setEnvironment)
+	addHandler(cns.V2Prefix+cns.CreateNetworkPath, service.createNetwork)
+	addHandler(cns.V2Prefix+cns.DeleteNetworkPath, service.deleteNetwork)
+	addHandler(cns.V2Prefix+cns.ReserveIPAddressPath, service.reserveIPAddress)
+	addHandler(cns.V2Prefix+cns.ReleaseIPAddressPath, service.releaseIPAddress)
+	addHandler(cns.V2Prefix+cns.GetHostLocalIPPath, service.getHostLocalIP)
+	addHandler(cns.V2Prefix+cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
+	addHandler(cns.V2Prefix+cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
+	addHandler(cns.V2Prefix+cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
+	addHandler(cns.V2Prefix+cns.PatchNetworkContainer, service.patchNetworkContainer)
+	addHandler(cns.V2Prefix+cns.GetOperationStatus, service.getOperationStatus)
+	addHandler(cns.V2Prefix+cns.DeleteNetworkContainer, service.deleteNetworkContainer)
+	addHandler(cns.V2Prefix+cns.GetInterfaceForContainer, service.getInterfaceForContainer)
+	addHandler(cns.V2Prefix+cns.SetOrchestratorType, service.setOrchestratorType)
+	addHandler(cns.V2Prefix+cns.GetNetworkContainerByOrchestratorContext, service.getNetworkContainerByOrchestratorContext)
+	addHandler(cns.V2Prefix+cns.GetAllNetworkContainers, service.getAllNetworkContainers)
+	addHandler(cns.V2Prefix+cns.AttachContainerToNetwork, service.attachNetworkContainerToNetwork)
+	addHandler(cns.V2Prefix+cns.DetachContainerFromNetwork, service.detachNetworkContainerFromNetwork)
+	addHandler(cns.V2Prefix+cns.CreateHnsNetworkPath, service.createHnsNetwork)
+	addHandler(cns.V2Prefix+cns.DeleteHnsNetworkPath, service.deleteHnsNetwork)
+	addHandler(cns.V2Prefix+cns.NumberOfCPUCoresPath, service.getNumberOfCPUCores)
+	addHandler(cns.V2Prefix+cns.CreateHostNCApipaEndpointPath, service.createHostNCApipaEndpoint)
+	addHandler(cns.V2Prefix+cns.DeleteHostNCApipaEndpointPath, service.deleteHostNCApipaEndpoint)
+	addHandler(cns.V2Prefix+cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
+	addHandler(cns.V2Prefix+cns.GetHomeAz, service.getHomeAz)
 
 	// Initialize HTTP client to be reused in CNS
 	connectionTimeout, _ := service.GetOption(acn.OptHttpConnectionTimeout).(int)
@@ -336,6 +368,37 @@ func (service *HTTPRestService) Stop() {
 	logger.Printf("[Azure CNS]  Service stopped.")
 }
 
+// updateCNIConflistReadiness writes or removes the conflist of a gating generator when the readiness of CNS changes.
+// CNS is ready once any NC is programmed and the IP pool is not empty. Note that an exhausted pool is still ready,
+// since IPs are released and reassigned as pods churn. Must be called with the service lock held.
+func (service *HTTPRestService) updateCNIConflistReadiness(remover CNIConflistRemover, programmedNCCount int) {
+	ready := programmedNCCount > 0 && len(service.PodIPConfigState) > 0
+	if ready == service.cniConflistReady {
+		return
+	}
+
+	var err error
+	if ready {
+		logger.Printf("[Azure CNS] Writing CNI conflist, %d NCs are programmed and the pool has %d IPs", programmedNCCount, len(service.PodIPConfigState))
+		err = service.cniConflistGenerator.Generate()
+	} else {
+		logger.Printf("[Azure CNS] Removing CNI conflist, %d NCs are programmed and the pool has %d IPs", programmedNCCount, len(service.PodIPConfigState))
+		err = remover.Remove()
+	}
+	if err != nil {
+		// leave the readiness unchanged so that the next sync retries.
+		logger.Errorf("[Azure CNS] Failed to update CNI conflist readiness to %t: %v", ready, err)
+		return
+	}
+
+	service.cniConflistReady = ready
+	if ready {
+		cniConflistReady.Set(1)
+	} else {
+		cniConflistReady.Set(0)
+	}
+}
+
 // MustGenerateCNIConflistOnce will generate the CNI conflist once if the service was initialized with
 // a conflist generator. If not, this is a no-op.
 func (service *HTTPRestService) MustGenerateCNIConflistOnce() {
@@ -0,0 +1,186 @@
+package restserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SnapshotDigestHeader is the hex SHA-256 of the compressed snapshot in the response of the PathDebugSnapshot.
+	SnapshotDigestHeader = "X-Cns-Snapshot-Digest"
+	// SnapshotSignatureHeader is the hex HMAC-SHA256 of the compressed snapshot in the response of the PathDebugSnapshot.
+	// It's only set if CNS has a snapshot signing key.
+	SnapshotSignatureHeader = "X-Cns-Snapshot-Signature"
+
+	contentTypeGzip = "application/gzip"
+)
+
+var (
+	ErrSnapshotDigestMismatch    = errors.New("snapshot digest mismatch")
+	ErrSnapshotSignatureMismatch = errors.New("snapshot signature mismatch")
+	ErrSnapshotNotSigned         = errors.New("snapshot is not signed")
+)
+
+// Snapshot is the in-memory state of a CNS, exported for debugging from all the nodes of a cluster.
+type Snapshot struct {
+	NodeID              string
+	Version             string
+	TimeStamp           time.Time
+	OrchestratorType    string
+	HTTPRestServiceData HTTPRestServiceData
+	NetworkContainers   map[string]SnapshotNetworkContainer // NetworkContainerID is key.
+	EndpointState       map[string]*EndpointInfo            // container id is key.
+}
+
+// SnapshotNetworkContainer is the state of a network container in a Snapshot.
+// The authorization token of the request is redacted.
+type SnapshotNetworkContainer struct {
+	VMVersion                     string
+	HostVersion                   string
+	VfpUpdateComplete             bool
+	CreateNetworkContainerRequest cns.CreateNetworkContainerRequest
+}
+
+// SetSnapshotSigningKey sets the key which signs the snapshots of the PathDebugSnapshot.
+// The same key should be shared by the CNS of all nodes, so that the collector can verify every snapshot.
+func (service *HTTPRestService) SetSnapshotSigningKey(key []byte) {
+	service.Lock()
+	defer service.Unlock()
+	service.snapshotSigningKey = key
+}
+
+// snapshot returns the in-memory state. It shares the maps of the service, so it must be encoded
+// with the service lock held.
+func (service *HTTPRestService) snapshot() Snapshot {
+	snapshot := Snapshot{
+		NodeID:           service.state.NodeID,
+		Version:          service.Version,
+		TimeStamp:        time.Now().UTC(),
+		OrchestratorType: service.state.OrchestratorType,
+		HTTPRestServiceData: HTTPRestServiceData{
+			PodIPIDByPodInterfaceKey: service.PodIPIDByPodInterfaceKey,
+			PodIPConfigState:         service.PodIPConfigState,
+		},
+		NetworkContainers: make(map[string]SnapshotNetworkContainer, len(service.state.ContainerStatus)),
+		EndpointState:     service.EndpointState,
+	}
+	if service.IPAMPoolMonitor != nil {
+		snapshot.HTTPRestServiceData.IPAMPoolMonitor = service.IPAMPoolMonitor.GetStateSnapshot()
+	}
+	for ncID, status := range service.state.ContainerStatus {
+		req := status.CreateNetworkContainerRequest
+		req.AuthorizationToken = ""
+		snapshot.NetworkContainers[ncID] = SnapshotNetworkContainer{
+			VMVersion:                     status.VMVersion,
+			HostVersion:                   status.HostVersion,
+			VfpUpdateComplete:             status.VfpUpdateComplete,
+			CreateNetworkContainerRequest: req,
+		}
+	}
+	return snapshot
+}
+
+// EncodeSnapshot returns the snapshot as gzipped JSON.
+func EncodeSnapshot(snapshot *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to encode snapshot")
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to compress snapshot")
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeSnapshot decodes gzipped JSON returned by EncodeSnapshot.
+func DecodeSnapshot(body []byte) (*Snapshot, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress snapshot")
+	}
+	defer zr.Close()
+	var snapshot Snapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "failed to decode snapshot")
+	}
+	return &snapshot, nil
+}
+
+// SnapshotDigest returns the hex SHA-256 of the encoded snapshot.
+func SnapshotDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignSnapshot returns the hex HMAC-SHA256 of the encoded snapshot with the key.
+func SignSnapshot(body, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EncodedSnapshot is a Snapshot as returned by the PathDebugSnapshot: gzipped JSON, with its digest and signature.
+type EncodedSnapshot struct {
+	Body      []byte
+	Digest    string
+	Signature string
+}
+
+// Verify checks the body against the digest, and against the signature if a key is given.
+func (e *EncodedSnapshot) Verify(key []byte) error {
+	if SnapshotDigest(e.Body) != e.Digest {
+		return ErrSnapshotDigestMismatch
+	}
+	if len(key) == 0 {
+		return nil
+	}
+	if e.Signature == "" {
+		return ErrSnapshotNotSigned
+	}
+	if !hmac.Equal([]byte(SignSnapshot(e.Body, key)), []byte(e.Signature)) {
+		return ErrSnapshotSignatureMismatch
+	}
+	return nil
+}
+
+// handleDebugSnapshot returns the gzipped snapshot of the in-memory state, with its digest and signature in the headers.
+func (service *HTTPRestService) handleDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	service.RLock()
+	snapshot := service.snapshot()
+	body, err := EncodeSnapshot(&snapshot)
+	key := service.snapshotSigningKey
+	service.RUnlock()
+	if err != nil {
+		logger.Errorf("[Azure CNS] Failed to encode snapshot: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(common.ContentType, contentTypeGzip)
+	w.Header().Set(SnapshotDigestHeader, SnapshotDigest(body))
+	if len(key) > 0 {
+		w.Header().Set(SnapshotSignatureHeader, SignSnapshot(body, key))
+	}
+	if _, err := w.Write(body); err != nil {
+		logger.Errorf("[Azure CNS] Failed to write snapshot: %v", err)
+		return
+	}
+	logger.Printf("[Azure CNS] Served snapshot of %d bytes, signed: %t", len(body), len(key) > 0)
+}
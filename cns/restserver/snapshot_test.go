@@ -0,0 +1,110 @@
+package restserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDebugSnapshot(t *testing.T) {
+	svc := getTestService()
+	ipconfigs := make(map[string]cns.IPConfigurationStatus)
+	state, _ := NewPodStateWithOrchestratorContext(testIP1, testIPID1, testNCID, types.Assigned, ipPrefixBitsv4, 0, testPod1Info)
+	ipconfigs[state.ID] = state
+	require.NoError(t, UpdatePodIPConfigState(t, svc, ipconfigs, testNCID))
+	svc.state.ContainerStatus[testNCID] = containerstatus{
+		ID:          testNCID,
+		VMVersion:   "1",
+		HostVersion: "1",
+		CreateNetworkContainerRequest: cns.CreateNetworkContainerRequest{
+			NetworkContainerid: testNCID,
+			AuthorizationToken: "secret",
+		},
+	}
+
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{
+			name: "unsigned",
+		},
+		{
+			name: "signed",
+			key:  []byte("test-key"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			svc.SetSnapshotSigningKey(tt.key)
+			rec := httptest.NewRecorder()
+			svc.handleDebugSnapshot(rec, httptest.NewRequest(http.MethodGet, cns.PathDebugSnapshot, http.NoBody))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			encoded := &EncodedSnapshot{
+				Body:      rec.Body.Bytes(),
+				Digest:    rec.Header().Get(SnapshotDigestHeader),
+				Signature: rec.Header().Get(SnapshotSignatureHeader),
+			}
+			require.NoError(t, encoded.Verify(tt.key))
+			assert.Equal(t, len(tt.key) > 0, encoded.Signature != "")
+
+			snapshot, err := DecodeSnapshot(encoded.Body)
+			require.NoError(t, err)
+			assert.Contains(t, snapshot.HTTPRestServiceData.PodIPConfigState, testIPID1)
+			require.Contains(t, snapshot.NetworkContainers, testNCID)
+			assert.Empty(t, snapshot.NetworkContainers[testNCID].CreateNetworkContainerRequest.AuthorizationToken)
+		})
+	}
+}
+
+func TestEncodedSnapshotVerify(t *testing.T) {
+	body := []byte("snapshot")
+	key := []byte("test-key")
+	tests := []struct {
+		name    string
+		encoded EncodedSnapshot
+		key     []byte
+		wantErr error
+	}{
+		{
+			name:    "digest only",
+			encoded: EncodedSnapshot{Body: body, Digest: SnapshotDigest(body)},
+		},
+		{
+			name:    "wrong digest",
+			encoded: EncodedSnapshot{Body: body, Digest: SnapshotDigest([]byte("other"))},
+			wantErr: ErrSnapshotDigestMismatch,
+		},
+		{
+			name:    "signed",
+			encoded: EncodedSnapshot{Body: body, Digest: SnapshotDigest(body), Signature: SignSnapshot(body, key)},
+			key:     key,
+		},
+		{
+			name:    "not signed",
+			encoded: EncodedSnapshot{Body: body, Digest: SnapshotDigest(body)},
+			key:     key,
+			wantErr: ErrSnapshotNotSigned,
+		},
+		{
+			name:    "signed with another key",
+			encoded: EncodedSnapshot{Body: body, Digest: SnapshotDigest(body), Signature: SignSnapshot(body, []byte("other-key"))},
+			key:     key,
+			wantErr: ErrSnapshotSignatureMismatch,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.encoded.Verify(tt.key)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package restserver
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+)
+
+// CompactState drops tombstoned entries from the persisted state and saves it if any were dropped.
+// Deleting an NC leaves its ID behind in the orchestrator contexts it was not the last NC of, and
+// contexts whose NCs are all gone; those entries are never read again, but grow the state of long-lived nodes.
+// It returns the number of dropped entries.
+func (service *HTTPRestService) CompactState() (int, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	dropped := service.compactOrchestratorContexts()
+	if dropped == 0 {
+		return 0, nil
+	}
+	logger.Printf("[Azure CNS] Compacted state, dropped %d tombstoned entries", dropped)
+	return dropped, service.saveState()
+}
+
+// compactOrchestratorContexts removes NC IDs without a ContainerStatus from the orchestrator contexts, and
+// the contexts left without NCs. The lock must be held by the caller.
+func (service *HTTPRestService) compactOrchestratorContexts() int {
+	dropped := 0
+	for orchestratorContext, networkContainerIDs := range service.state.ContainerIDByOrchestratorContext {
+		if networkContainerIDs == nil || *networkContainerIDs == "" {
+			delete(service.state.ContainerIDByOrchestratorContext, orchestratorContext)
+			dropped++
+			continue
+		}
+
+		var live []string
+		for _, ncid := range strings.Split(string(*networkContainerIDs), ",") {
+			if _, ok := service.state.ContainerStatus[ncid]; ok {
+				live = append(live, ncid)
+				continue
+			}
+			dropped++
+		}
+		if len(live) == 0 {
+			delete(service.state.ContainerIDByOrchestratorContext, orchestratorContext)
+			continue
+		}
+		*networkContainerIDs = ncList(strings.Join(live, ","))
+	}
+	return dropped
+}
@@ -0,0 +1,34 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactState(t *testing.T) {
+	svc := getTestService()
+	svc.state.ContainerStatus = map[string]containerstatus{
+		"nc1": {ID: "nc1"},
+		"nc2": {ID: "nc2"},
+	}
+	live, stale, gone, empty := ncList("nc1,nc3"), ncList("nc2"), ncList("nc4,nc5"), ncList("")
+	svc.state.ContainerIDByOrchestratorContext = map[string]*ncList{
+		"pod1": &live,
+		"pod2": &stale,
+		"pod3": &gone,
+		"pod4": &empty,
+	}
+
+	dropped, err := svc.CompactState()
+	require.NoError(t, err)
+	assert.Equal(t, 4, dropped)
+	assert.Len(t, svc.state.ContainerIDByOrchestratorContext, 2)
+	assert.Equal(t, ncList("nc1"), *svc.state.ContainerIDByOrchestratorContext["pod1"])
+	assert.Equal(t, ncList("nc2"), *svc.state.ContainerIDByOrchestratorContext["pod2"])
+
+	dropped, err = svc.CompactState()
+	require.NoError(t, err)
+	assert.Zero(t, dropped)
+}
@@ -117,7 +117,36 @@ func (service *HTTPRestService) saveNetworkContainerGoalState(
 	// we don't want to overwrite what other calls may have written
 	service.Lock()
 	defer service.Unlock()
+	return service.saveNetworkContainerGoalStateUntransacted(req)
+}
+
+// patchNetworkContainerGoalState applies the patch to the NC and saves the patched NC as saveNetworkContainerGoalState
+// does, all under the service lock so that no other update of the NC lands between the check of the base version of
+// the patch and the save.
+func (service *HTTPRestService) patchNetworkContainerGoalState(
+	patch *cns.PatchNetworkContainerRequest,
+) (cns.CreateNetworkContainerRequest, types.ResponseCode, string) {
+	service.Lock()
+	defer service.Unlock()
+
+	existingNCStatus, ok := service.state.ContainerStatus[patch.NetworkContainerid]
+	if !ok {
+		return cns.CreateNetworkContainerRequest{}, types.UnknownContainerID,
+			fmt.Sprintf("[Azure CNS] Error. Patching unknown NC %s", patch.NetworkContainerid)
+	}
+	patched, err := existingNCStatus.CreateNetworkContainerRequest.Patch(patch)
+	if err != nil {
+		// the caller resends the whole NC when it doesn't have the base version
+		return cns.CreateNetworkContainerRequest{}, types.NetworkContainerVersionMismatch,
+			fmt.Sprintf("[Azure CNS] Error. Failed to patch NC: %v", err)
+	}
+	returnCode, returnMessage := service.saveNetworkContainerGoalStateUntransacted(patched)
+	return patched, returnCode, returnMessage
+}
 
+func (service *HTTPRestService) saveNetworkContainerGoalStateUntransacted(
+	req cns.CreateNetworkContainerRequest,
+) (types.ResponseCode, string) {
 	var (
 		hostVersion                string
 		existingSecondaryIPConfigs map[string]cns.SecondaryIPConfig // uuid is key
@@ -501,6 +530,7 @@ func (service *HTTPRestService) getAllNetworkContainerResponses(
 			LocalIPConfiguration:       savedReq.LocalIPConfiguration,
 			AllowHostToNCCommunication: savedReq.AllowHostToNCCommunication,
 			AllowNCToHostCommunication: savedReq.AllowNCToHostCommunication,
+			EndpointPolicies:           savedReq.PodEndpointPolicies(),
 		}
 
 		// If the NC version check wasn't skipped, take into account the VFP programming status when returning the response
@@ -820,6 +850,7 @@ func (service *HTTPRestService) populateIPConfigInfoUntransacted(ipConfigStatus
 	}
 
 	podIPInfo.NetworkContainerPrimaryIPConfig = primaryIPCfg
+	podIPInfo.EndpointPolicies = ncStatus.CreateNetworkContainerRequest.PodEndpointPolicies()
 	primaryHostInterface, err := service.getPrimaryHostInterface(context.TODO())
 	if err != nil {
 		return err
@@ -918,6 +949,7 @@ func (service *HTTPRestService) handleGetNetworkContainers(w http.ResponseWriter
 			LocalIPConfiguration:       ncDetails.CreateNetworkContainerRequest.LocalIPConfiguration,
 			AllowHostToNCCommunication: ncDetails.CreateNetworkContainerRequest.AllowHostToNCCommunication,
 			AllowNCToHostCommunication: ncDetails.CreateNetworkContainerRequest.AllowNCToHostCommunication,
+			EndpointPolicies:           ncDetails.CreateNetworkContainerRequest.PodEndpointPolicies(),
 		}
 		networkContainers[i] = getNcResp
 		i++
@@ -0,0 +1,256 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultBaseURL is the address CNS listens on.
+	DefaultBaseURL = "http://localhost:10090"
+	// DefaultTimeout bounds each attempt of a request.
+	DefaultTimeout = 5 * time.Second
+	// DefaultMaxAttempts is the number of attempts of the requests which fail transiently.
+	DefaultMaxAttempts = 3
+	// DefaultBackoff is the delay before the first retry, doubled for every retry after it.
+	DefaultBackoff = 100 * time.Millisecond
+
+	contentTypeJSON = "application/json"
+	userAgent       = "azure-cns-sdk/" + Version
+)
+
+// Interface is the CNS API of the SDK. It is implemented by the Client, and by the fake.Client for tests.
+type Interface interface {
+	// RequestIPs allocates the IPs of a pod interface. It is idempotent: requesting the IPs of
+	// an interface again returns the IPs already allocated to it.
+	RequestIPs(ctx context.Context, req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error)
+	// ReleaseIPs releases the IPs of a pod interface, and succeeds if they are already released.
+	ReleaseIPs(ctx context.Context, req cns.IPConfigsRequest) error
+	// GetPodContextByIP returns the pod an IP is assigned to. The error satisfies IsNotFound if there is none.
+	GetPodContextByIP(ctx context.Context, ip string) (*cns.PodIPContext, error)
+	// GetPodContextsByIP returns the pods the IPs are assigned to, omitting the IPs assigned to none.
+	GetPodContextsByIP(ctx context.Context, ips []string) ([]cns.PodIPContext, error)
+	// Ready returns the health of the dependencies of CNS. The error satisfies IsNotReady if CNS isn't ready.
+	Ready(ctx context.Context) (*cns.HealthResponse, error)
+}
+
+var _ Interface = &Client{}
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// WithBaseURL sets the address of CNS, DefaultBaseURL by default.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse base URL %s", baseURL)
+		}
+		c.baseURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client of the requests, whose Timeout bounds each attempt.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithRetry sets the number of attempts of the requests which fail transiently, and the delay before
+// the first retry. maxAttempts of 1 disables the retries.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(c *Client) error {
+		if maxAttempts < 1 {
+			return errors.Errorf("invalid max attempts %d", maxAttempts)
+		}
+		c.maxAttempts = maxAttempts
+		c.backoff = backoff
+		return nil
+	}
+}
+
+// Client is the client of the CNS API. It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// New creates a Client of the CNS on DefaultBaseURL, unless configured otherwise by the options.
+func New(opts ...Option) (*Client, error) {
+	baseURL, _ := url.Parse(DefaultBaseURL)
+	c := &Client{
+		baseURL:     baseURL,
+		httpClient:  &http.Client{Timeout: DefaultTimeout},
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// RequestIPs allocates the IPs of a pod interface.
+func (c *Client) RequestIPs(ctx context.Context, req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+	resp := &cns.IPConfigsResponse{}
+	if err := c.do(ctx, http.MethodPost, cns.RequestIPConfigs, req, resp, true); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(cns.RequestIPConfigs, resp.Response); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReleaseIPs releases the IPs of a pod interface.
+func (c *Client) ReleaseIPs(ctx context.Context, req cns.IPConfigsRequest) error {
+	resp := &cns.Response{}
+	if err := c.do(ctx, http.MethodPost, cns.ReleaseIPConfigs, req, resp, true); err != nil {
+		return err
+	}
+	return checkResponse(cns.ReleaseIPConfigs, *resp)
+}
+
+// GetPodContextByIP returns the pod an IP is assigned to.
+func (c *Client) GetPodContextByIP(ctx context.Context, ip string) (*cns.PodIPContext, error) {
+	resp := &cns.GetPodContextByIPResponse{}
+	if err := c.do(ctx, http.MethodPost, cns.GetPodContextByIP, cns.GetPodContextByIPRequest{IP: ip}, resp, true); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(cns.GetPodContextByIP, resp.Response); err != nil {
+		return nil, err
+	}
+	return &resp.PodIPContext, nil
+}
+
+// GetPodContextsByIP returns the pods the IPs are assigned to.
+func (c *Client) GetPodContextsByIP(ctx context.Context, ips []string) ([]cns.PodIPContext, error) {
+	resp := &cns.GetPodContextsByIPResponse{}
+	if err := c.do(ctx, http.MethodPost, cns.GetPodContextsByIP, cns.GetPodContextsByIPRequest{IPs: ips}, resp, true); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(cns.GetPodContextsByIP, resp.Response); err != nil {
+		return nil, err
+	}
+	return resp.PodIPContexts, nil
+}
+
+// Ready returns the health of the dependencies of CNS. It isn't retried, so that callers polling the
+// readiness of CNS see every failure.
+func (c *Client) Ready(ctx context.Context) (*cns.HealthResponse, error) {
+	resp := &cns.HealthResponse{}
+	err := c.do(ctx, http.MethodGet, cns.ReadyzPath, nil, resp, false)
+	if err != nil && !IsNotReady(err) {
+		return nil, err
+	}
+	return resp, err
+}
+
+// checkResponse returns the ReturnCode of a response as an *Error.
+func checkResponse(path string, resp cns.Response) error {
+	if resp.ReturnCode == types.Success {
+		return nil
+	}
+	return &Error{Path: path, StatusCode: http.StatusOK, Code: resp.ReturnCode, Message: resp.Message}
+}
+
+// do sends the request, retrying it if it failed transiently and retry is set, and decodes the response
+// into out. Responses with the status of an error are returned as an *Error, after decoding their body
+// into out if it is JSON.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}, retry bool) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return errors.Wrapf(err, "failed to encode request of %s", path)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path = path
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		statusCode, err := c.doOnce(ctx, method, u.String(), body, out)
+		if err == nil {
+			return nil
+		}
+		// the requests cancelled by their context, or failed with a status other than of a transient
+		// failure, would fail again
+		transient := ctx.Err() == nil && (statusCode == 0 || isRetriable(statusCode))
+		if !retry || !transient || attempt >= c.maxAttempts {
+			if statusCode != 0 {
+				return &Error{Path: path, StatusCode: statusCode, Code: codeOfStatus(statusCode), Message: err.Error()}
+			}
+			return errors.Wrapf(err, "cns %s failed after %d attempts", path, attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "cns %s failed after %d attempts: %v", path, attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// doOnce sends the request once and decodes the response into out. If it fails, it returns the HTTP
+// status of the response, or 0 if there is none.
+func (c *Client) doOnce(ctx context.Context, method, u string, body []byte, out interface{}) (int, error) {
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", contentTypeJSON)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read response")
+	}
+	if res.StatusCode != http.StatusOK {
+		// the body of errors is decoded if possible, e.g. the health of a CNS which isn't ready
+		_ = json.Unmarshal(b, out)
+		return res.StatusCode, errors.Errorf("http response %s", res.Status)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return res.StatusCode, errors.Wrap(err, "failed to decode response")
+	}
+	return 0, nil
+}
+
+// codeOfStatus returns the ReturnCode of the HTTP status of a failed request.
+func codeOfStatus(statusCode int) types.ResponseCode {
+	if statusCode == http.StatusNotFound {
+		// CNS serves every path of its API, so unknown paths are APIs of later versions.
+		return types.UnsupportedAPI
+	}
+	return types.UnexpectedError
+}
@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a Client of a CNS serving the handler, and the count of the requests to it.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, userAgent, r.Header.Get("User-Agent"))
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(WithBaseURL(srv.URL), WithRetry(3, time.Millisecond))
+	require.NoError(t, err)
+	return c, &requests
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, statusCode int, v interface{}) {
+	w.WriteHeader(statusCode)
+	require.NoError(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestRequestIPsRetriesTransientFailures(t *testing.T) {
+	var attempts int32
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, cns.RequestIPConfigs, r.URL.Path)
+		req := cns.IPConfigsRequest{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "abcd-eth0", req.PodInterfaceID)
+
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, http.StatusOK, cns.IPConfigsResponse{
+			PodIPInfo: []cns.PodIpInfo{{PodIPConfig: cns.IPSubnet{IPAddress: "10.0.0.5", PrefixLength: 24}}},
+		})
+	})
+
+	resp, err := c.RequestIPs(context.Background(), cns.IPConfigsRequest{PodInterfaceID: "abcd-eth0"})
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.5", resp.PodIPInfo[0].PodIPConfig.IPAddress)
+	require.EqualValues(t, 3, atomic.LoadInt32(requests))
+}
+
+func TestRequestIPsStopsRetryingAfterMaxAttempts(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := c.RequestIPs(context.Background(), cns.IPConfigsRequest{})
+	e := &Error{}
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, http.StatusBadGateway, e.StatusCode)
+	require.Equal(t, cns.RequestIPConfigs, e.Path)
+	require.EqualValues(t, 3, atomic.LoadInt32(requests))
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		handler          http.HandlerFunc
+		wantNotFound     bool
+		wantUnsupported  bool
+		wantRequestCount int32
+	}{
+		{
+			name: "return code",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				writeJSON(t, w, http.StatusOK, cns.GetPodContextByIPResponse{
+					Response: cns.Response{ReturnCode: types.NotFound, Message: "not found"},
+				})
+			},
+			wantNotFound:     true,
+			wantRequestCount: 1,
+		},
+		{
+			name: "unsupported API",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantUnsupported:  true,
+			wantRequestCount: 1,
+		},
+		{
+			name: "invalid response",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("not json"))
+			},
+			wantRequestCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newTestClient(t, tt.handler)
+			_, err := c.GetPodContextByIP(context.Background(), "10.0.0.5")
+			require.Error(t, err)
+			require.Equal(t, tt.wantNotFound, IsNotFound(err))
+			require.Equal(t, tt.wantUnsupported, IsUnsupportedAPI(err))
+			require.Equal(t, tt.wantRequestCount, atomic.LoadInt32(requests), "errors other than transient failures are not retried")
+		})
+	}
+}
+
+func TestRequestCancelledByContext(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.ReleaseIPs(ctx, cns.IPConfigsRequest{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualValues(t, 1, atomic.LoadInt32(requests))
+}
+
+func TestReady(t *testing.T) {
+	health := cns.HealthResponse{
+		Healthy:      false,
+		Dependencies: []cns.DependencyHealth{{Name: "nmagent", Healthy: false, Error: "timeout"}},
+	}
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, cns.ReadyzPath, r.URL.Path)
+		writeJSON(t, w, http.StatusServiceUnavailable, health)
+	})
+
+	resp, err := c.Ready(context.Background())
+	require.True(t, IsNotReady(err))
+	require.Equal(t, &health, resp)
+	require.EqualValues(t, 1, atomic.LoadInt32(requests), "readiness is not retried")
+}
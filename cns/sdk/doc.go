@@ -0,0 +1,23 @@
+// Package sdk is the client of the CNS API for integrators outside of this repo, e.g. device plugins and
+// service meshes, which need the pod IPs of a node without depending on the internals of CNS.
+//
+// It depends only on the API types of the cns package, retries the requests which failed transiently,
+// honors the deadlines of the contexts of the requests, and returns the errors of CNS as an *Error.
+// Code using the SDK should depend on Interface, so that its tests can use the fake package instead
+// of a CNS.
+//
+//	cli, err := sdk.New(sdk.WithBaseURL("http://localhost:10090"))
+//	if err != nil {
+//		return err
+//	}
+//	podCtx, err := cli.GetPodContextByIP(ctx, "10.0.0.5")
+//	if sdk.IsNotFound(err) {
+//		// the IP isn't assigned to a pod of the node
+//	}
+//
+// The SDK follows semantic versioning, independently of CNS: Version is bumped with every change of the
+// package, and its major version with every breaking change. Releases are tagged cns/sdk/<Version>.
+package sdk
+
+// Version is the semantic version of the SDK, sent to CNS in the User-Agent of the requests.
+const Version = "v0.1.0"
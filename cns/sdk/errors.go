@@ -0,0 +1,53 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+// Error is an error returned by CNS, either as the ReturnCode of a response or as the HTTP status of a request.
+type Error struct {
+	// Path is the path of the API which returned the error.
+	Path string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the ReturnCode of the response, or UnsupportedAPI if CNS doesn't serve the API.
+	Code types.ResponseCode
+	// Message describes the error.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("cns %s failed: %s (%d, %s)", e.Path, e.Message, e.StatusCode, e.Code)
+}
+
+// IsNotFound returns true if CNS doesn't know the pod, container or IP of the request.
+func IsNotFound(err error) bool {
+	e := &Error{}
+	return errors.As(err, &e) && (e.Code == types.NotFound || e.Code == types.UnknownContainerID)
+}
+
+// IsUnsupportedAPI returns true if the version of CNS doesn't serve the API.
+func IsUnsupportedAPI(err error) bool {
+	e := &Error{}
+	return errors.As(err, &e) && e.Code == types.UnsupportedAPI
+}
+
+// IsNotReady returns true if CNS isn't ready, see Client.Ready.
+func IsNotReady(err error) bool {
+	e := &Error{}
+	return errors.As(err, &e) && e.StatusCode == http.StatusServiceUnavailable
+}
+
+// isRetriable returns true for the HTTP statuses of transient failures.
+func isRetriable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
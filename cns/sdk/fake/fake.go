@@ -0,0 +1,127 @@
+// Package fake provides an in-memory sdk.Interface, for the tests of code integrating with CNS through the SDK.
+package fake
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/sdk"
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+// Client allocates the pod IPs from a fixed pool, one IP per pod, the way CNS does in a single stack cluster.
+type Client struct {
+	sync.Mutex
+	prefixLength uint8
+	free         []string
+	// ipsByPod are the IPs allocated to the pods, by the key of their PodInfo.
+	ipsByPod map[string]string
+	// podsByIP are the pods the IPs are allocated to.
+	podsByIP map[string]cns.PodIPContext
+	health   cns.HealthResponse
+}
+
+var _ sdk.Interface = &Client{}
+
+// NewClient creates a ready Client allocating the IPs, which are in a subnet of the prefix length.
+func NewClient(prefixLength uint8, ips ...string) *Client {
+	return &Client{
+		prefixLength: prefixLength,
+		free:         append([]string{}, ips...),
+		ipsByPod:     map[string]string{},
+		podsByIP:     map[string]cns.PodIPContext{},
+		health:       cns.HealthResponse{Healthy: true},
+	}
+}
+
+// SetHealth sets the health returned by Ready, which fails unless it is healthy.
+func (c *Client) SetHealth(health cns.HealthResponse) {
+	c.Lock()
+	defer c.Unlock()
+	c.health = health
+}
+
+func (c *Client) RequestIPs(_ context.Context, req cns.IPConfigsRequest) (*cns.IPConfigsResponse, error) {
+	podInfo, err := cns.NewPodInfoFromIPConfigsRequest(req)
+	if err != nil {
+		return nil, newError(cns.RequestIPConfigs, types.UnexpectedError, err.Error())
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	ip, ok := c.ipsByPod[podInfo.Key()]
+	if !ok {
+		if len(c.free) == 0 {
+			return nil, newError(cns.RequestIPConfigs, types.FailedToAllocateIPConfig, "no free IPs")
+		}
+		ip, c.free = c.free[0], c.free[1:]
+		c.ipsByPod[podInfo.Key()] = ip
+		c.podsByIP[ip] = cns.PodIPContext{
+			IP:               ip,
+			PodName:          podInfo.Name(),
+			PodNamespace:     podInfo.Namespace(),
+			InfraContainerID: podInfo.InfraContainerID(),
+			InterfaceID:      podInfo.InterfaceID(),
+		}
+	}
+
+	return &cns.IPConfigsResponse{
+		PodIPInfo: []cns.PodIpInfo{
+			{PodIPConfig: cns.IPSubnet{IPAddress: ip, PrefixLength: c.prefixLength}},
+		},
+	}, nil
+}
+
+func (c *Client) ReleaseIPs(_ context.Context, req cns.IPConfigsRequest) error {
+	podInfo, err := cns.NewPodInfoFromIPConfigsRequest(req)
+	if err != nil {
+		return newError(cns.ReleaseIPConfigs, types.UnexpectedError, err.Error())
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if ip, ok := c.ipsByPod[podInfo.Key()]; ok {
+		delete(c.ipsByPod, podInfo.Key())
+		delete(c.podsByIP, ip)
+		c.free = append(c.free, ip)
+	}
+	return nil
+}
+
+func (c *Client) GetPodContextByIP(_ context.Context, ip string) (*cns.PodIPContext, error) {
+	c.Lock()
+	defer c.Unlock()
+	podCtx, ok := c.podsByIP[ip]
+	if !ok {
+		return nil, newError(cns.GetPodContextByIP, types.NotFound, "IP is not assigned to a pod")
+	}
+	return &podCtx, nil
+}
+
+func (c *Client) GetPodContextsByIP(_ context.Context, ips []string) ([]cns.PodIPContext, error) {
+	c.Lock()
+	defer c.Unlock()
+	var podCtxs []cns.PodIPContext
+	for _, ip := range ips {
+		if podCtx, ok := c.podsByIP[ip]; ok {
+			podCtxs = append(podCtxs, podCtx)
+		}
+	}
+	return podCtxs, nil
+}
+
+func (c *Client) Ready(context.Context) (*cns.HealthResponse, error) {
+	c.Lock()
+	defer c.Unlock()
+	health := c.health
+	if !health.Healthy {
+		return &health, &sdk.Error{Path: cns.ReadyzPath, StatusCode: http.StatusServiceUnavailable, Code: types.UnexpectedError, Message: "not ready"}
+	}
+	return &health, nil
+}
+
+func newError(path string, code types.ResponseCode, msg string) *sdk.Error {
+	return &sdk.Error{Path: path, StatusCode: http.StatusOK, Code: code, Message: msg}
+}
@@ -0,0 +1,60 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func ipConfigsRequest(t *testing.T, name string) cns.IPConfigsRequest {
+	orchestratorContext, err := json.Marshal(cns.KubernetesPodInfo{PodName: name, PodNamespace: "default"})
+	require.NoError(t, err)
+	return cns.IPConfigsRequest{
+		PodInterfaceID:      name + "-eth0",
+		InfraContainerID:    name,
+		OrchestratorContext: orchestratorContext,
+	}
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient(24, "10.0.0.4", "10.0.0.5")
+
+	resp, err := c.RequestIPs(ctx, ipConfigsRequest(t, "a"))
+	require.NoError(t, err)
+	require.Equal(t, cns.IPSubnet{IPAddress: "10.0.0.4", PrefixLength: 24}, resp.PodIPInfo[0].PodIPConfig)
+
+	// requesting the IPs of a pod again returns the same IP
+	resp, err = c.RequestIPs(ctx, ipConfigsRequest(t, "a"))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.4", resp.PodIPInfo[0].PodIPConfig.IPAddress)
+
+	_, err = c.RequestIPs(ctx, ipConfigsRequest(t, "b"))
+	require.NoError(t, err)
+	_, err = c.RequestIPs(ctx, ipConfigsRequest(t, "c"))
+	require.Error(t, err, "the pool should be exhausted")
+
+	podCtx, err := c.GetPodContextByIP(ctx, "10.0.0.4")
+	require.NoError(t, err)
+	require.Equal(t, "a", podCtx.PodName)
+
+	require.NoError(t, c.ReleaseIPs(ctx, ipConfigsRequest(t, "a")))
+	require.NoError(t, c.ReleaseIPs(ctx, ipConfigsRequest(t, "a")), "releasing again should succeed")
+	_, err = c.GetPodContextByIP(ctx, "10.0.0.4")
+	require.True(t, sdk.IsNotFound(err))
+
+	podCtxs, err := c.GetPodContextsByIP(ctx, []string{"10.0.0.4", "10.0.0.5"})
+	require.NoError(t, err)
+	require.Len(t, podCtxs, 1)
+	require.Equal(t, "b", podCtxs[0].PodName)
+
+	_, err = c.Ready(ctx)
+	require.NoError(t, err)
+	c.SetHealth(cns.HealthResponse{Healthy: false})
+	_, err = c.Ready(ctx)
+	require.True(t, sdk.IsNotReady(err))
+}
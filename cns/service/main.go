@@ -8,8 +8,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
@@ -22,14 +24,19 @@ import (
 	"github.com/Azure/azure-container-networking/cnm/ipam"
 	"github.com/Azure/azure-container-networking/cnm/network"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/bootstrap"
 	cnscli "github.com/Azure/azure-container-networking/cns/cmd/cli"
 	"github.com/Azure/azure-container-networking/cns/cniconflist"
 	"github.com/Azure/azure-container-networking/cns/cnireconciler"
 	"github.com/Azure/azure-container-networking/cns/common"
 	"github.com/Azure/azure-container-networking/cns/configuration"
+	"github.com/Azure/azure-container-networking/cns/events"
 	"github.com/Azure/azure-container-networking/cns/healthserver"
 	"github.com/Azure/azure-container-networking/cns/hnsclient"
 	"github.com/Azure/azure-container-networking/cns/ipampool"
+	"github.com/Azure/azure-container-networking/cns/ipconflict"
+	"github.com/Azure/azure-container-networking/cns/iphooks"
+	"github.com/Azure/azure-container-networking/cns/ipleak"
 	cssctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/clustersubnetstate"
 	nncctrl "github.com/Azure/azure-container-networking/cns/kubecontroller/nodenetworkconfig"
 	"github.com/Azure/azure-container-networking/cns/logger"
@@ -41,8 +48,11 @@ import (
 	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/crd"
 	"github.com/Azure/azure-container-networking/crd/clustersubnetstate/api/v1alpha1"
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary"
+	icsv1alpha1 "github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig"
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/Azure/azure-container-networking/crictl"
 	acnfs "github.com/Azure/azure-container-networking/internal/fs"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/nmagent"
@@ -59,10 +69,10 @@ import (
 	kuberuntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/exec"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
 const (
@@ -74,6 +84,9 @@ const (
 	defaultCNINetworkConfigFileName   = "10-azure.conflist"
 	dncApiVersion                     = "?api-version=2018-03-01"
 	poolIPAMRefreshRateInMilliseconds = 1000
+	stateBackupDirName                = "backup"
+	restoreLatestStateBackup          = "latest"
+	defaultStateBackupCount           = 5
 
 	// 720 * acn.FiveSeconds sec sleeps = 1Hr
 	maxRetryNodeRegister = 720
@@ -95,6 +108,8 @@ var (
 	rootErrCh chan error
 )
 
+var errNNCCacheNotSynced = errors.New("NodeNetworkConfig cache is not synced")
+
 // Version is populated by make during build.
 var version string
 
@@ -266,14 +281,14 @@ var args = acn.ArgumentList{
 	{
 		Name:         acn.OptDebugCmd,
 		Shorthand:    acn.OptDebugCmdAlias,
-		Description:  "Debug flag to retrieve IPconfigs, available values: assigned, available, all",
+		Description:  "Debug command to run against CNS, available values: get, getPodContexts, getInMemory, dump",
 		Type:         "string",
 		DefaultValue: "",
 	},
 	{
 		Name:         acn.OptDebugArg,
 		Shorthand:    acn.OptDebugArgAlias,
-		Description:  "Argument flag to be paired with the 'debugcmd' flag.",
+		Description:  "Argument flag to be paired with the 'debugcmd' flag, e.g. the IP state for get, or cluster for dump.",
 		Type:         "string",
 		DefaultValue: "",
 	},
@@ -305,6 +320,13 @@ var args = acn.ArgumentList{
 		Type:         "string",
 		DefaultValue: "",
 	},
+	{
+		Name:         acn.OptRestoreStateBackup,
+		Shorthand:    acn.OptRestoreStateBackupAlias,
+		Description:  "Restore the CNS state from a backup and exit, 'latest' restores the newest intact backup. CNS must not be running.",
+		Type:         "string",
+		DefaultValue: "",
+	},
 }
 
 // init() is executed before main() whenever this package is imported
@@ -466,6 +488,7 @@ func main() {
 	telemetryDaemonEnabled := acn.GetArg(acn.OptTelemetryService).(bool)
 	cniConflistFilepathArg := acn.GetArg(acn.OptCNIConflistFilepath).(string)
 	cniConflistScenarioArg := acn.GetArg(acn.OptCNIConflistScenario).(string)
+	restoreStateBackupArg := acn.GetArg(acn.OptRestoreStateBackup).(string)
 
 	if vers {
 		printVersion()
@@ -521,27 +544,40 @@ func main() {
 			// allow the filepath to get overidden by command line arg
 			conflistFilepath = cniConflistFilepathArg
 		}
-		writer, newWriterErr := acnfs.NewAtomicWriter(conflistFilepath)
-		if newWriterErr != nil {
-			logger.Errorf("unable to create atomic writer to generate cni conflist: %v", newWriterErr)
-			os.Exit(1)
-		}
-
 		// allow the scenario to get overridden by command line arg
 		scenarioString := cnsconfig.CNIConflistScenario
 		if cniConflistScenarioArg != "" {
 			scenarioString = cniConflistScenarioArg
 		}
 
+		var newGenerator func(io.WriteCloser) cniconflist.Generator
 		switch scenario := cniConflistScenario(scenarioString); scenario {
 		case scenarioV4Overlay:
-			conflistGenerator = &cniconflist.V4OverlayGenerator{Writer: writer}
+			newGenerator = func(w io.WriteCloser) cniconflist.Generator { return &cniconflist.V4OverlayGenerator{Writer: w} }
 		case scenarioCilium:
-			conflistGenerator = &cniconflist.CiliumGenerator{Writer: writer}
+			newGenerator = func(w io.WriteCloser) cniconflist.Generator { return &cniconflist.CiliumGenerator{Writer: w} }
 		default:
 			logger.Errorf("unable to generate cni conflist for unknown scenario: %s", scenario)
 			os.Exit(1)
 		}
+
+		if cnsconfig.EnableCNIConflistReadinessGate {
+			// the conflist is written and removed as CNS readiness changes, so that kubelet
+			// doesn't report the node network as ready while CNI ADDs would fail.
+			gatedGenerator, newGeneratorErr := cniconflist.NewGatedGenerator(conflistFilepath, newGenerator)
+			if newGeneratorErr != nil {
+				logger.Errorf("unable to create readiness gated cni conflist generator: %v", newGeneratorErr)
+				os.Exit(1)
+			}
+			conflistGenerator = gatedGenerator
+		} else {
+			writer, newWriterErr := acnfs.NewAtomicWriter(conflistFilepath)
+			if newWriterErr != nil {
+				logger.Errorf("unable to create atomic writer to generate cni conflist: %v", newWriterErr)
+				os.Exit(1)
+			}
+			conflistGenerator = newGenerator(writer)
+		}
 	}
 
 	// start the health server
@@ -554,6 +590,14 @@ func main() {
 		return
 	}
 
+	// the calls to the host endpoints bypass the HTTP(S)_PROXY of the node
+	hostTransport, err := acn.NewHTTPTransport(acn.HTTPClientConfig{CAFile: cnsconfig.WireserverCAFile})
+	if err != nil {
+		logger.Errorf("[Azure CNS] Failed to create the transport of the host endpoints: %v", err)
+		return
+	}
+	nmaConfig.Transport = hostTransport
+
 	nmaClient, err := nmagent.NewClient(nmaConfig)
 	if err != nil {
 		logger.Errorf("[Azure CNS] Failed to start nmagent client due to error: %v", err)
@@ -616,9 +660,25 @@ func main() {
 		return
 	}
 
+	// Restore the state before the store is created, since the bolt store holds its file open.
+	storeFileName := stateStoreFileName(storeFileLocation+name, cnsconfig.StoreBackend)
+	stateBackupDir := storeFileLocation + stateBackupDirName
+	if restoreStateBackupArg != "" {
+		backupPath := restoreStateBackupArg
+		if backupPath == restoreLatestStateBackup {
+			backupPath = ""
+		}
+		restored, err := store.RestoreBackup(storeFileName, stateBackupDir, backupPath)
+		if err != nil {
+			fmt.Printf("Failed to restore state: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Restored state %s from backup %s\n", storeFileName, restored)
+		os.Exit(0)
+	}
+
 	// Create the key value store.
-	storeFileName := storeFileLocation + name + ".json"
-	config.Store, err = store.NewJsonFileStore(storeFileName, lockclient)
+	config.Store, err = newStateStore(storeFileLocation+name, cnsconfig.StoreBackend, lockclient)
 	if err != nil {
 		logger.Errorf("Failed to create store file: %s, due to error %v\n", storeFileName, err)
 		return
@@ -640,8 +700,8 @@ func main() {
 			return
 		}
 		// Create the key value store.
-		storeFileName := endpointStoreLocation + endpointStoreName + ".json"
-		endpointStateStore, err = store.NewJsonFileStore(storeFileName, endpointStoreLock)
+		storeFileName := stateStoreFileName(endpointStoreLocation+endpointStoreName, cnsconfig.StoreBackend)
+		endpointStateStore, err = newStateStore(endpointStoreLocation+endpointStoreName, cnsconfig.StoreBackend, endpointStoreLock)
 		if err != nil {
 			logger.Errorf("Failed to create endpoint state store file: %s, due to error %v\n", storeFileName, err)
 			return
@@ -650,10 +710,24 @@ func main() {
 
 	wsProxy := wireserver.Proxy{
 		Host:       cnsconfig.WireserverIP,
-		HTTPClient: &http.Client{},
+		HTTPClient: &http.Client{Transport: hostTransport},
 	}
 
-	httpRestService, err := restserver.NewHTTPRestService(&config, &wireserver.Client{HTTPClient: &http.Client{}}, &wsProxy, nmaClient,
+	// the interface queries are idempotent, so they're retried
+	wsTransport, err := acn.NewHTTPTransport(acn.HTTPClientConfig{CAFile: cnsconfig.WireserverCAFile, Retry: acn.DefaultHostRetryPolicy})
+	if err != nil {
+		logger.Errorf("[Azure CNS] Failed to create the wireserver transport: %v", err)
+		return
+	}
+	wsConfig := nmaConfig
+	wsConfig.Transport = wsTransport
+	wsNMAClient, err := nmagent.NewClient(wsConfig)
+	if err != nil {
+		logger.Errorf("[Azure CNS] Failed to create the wireserver client: %v", err)
+		return
+	}
+
+	httpRestService, err := restserver.NewHTTPRestService(&config, &wireserver.Client{NMAgent: wsNMAClient}, &wsProxy, nmaClient,
 		endpointStateStore, conflistGenerator, homeAzMonitor)
 	if err != nil {
 		logger.Errorf("Failed to create CNS object, err:%v.\n", err)
@@ -670,6 +744,30 @@ func main() {
 	httpRestService.SetOption(acn.OptProgramSNATIPTables, cnsconfig.ProgramSNATIPTables)
 	httpRestService.SetOption(acn.OptManageEndpointState, cnsconfig.ManageEndpointState)
 
+	if cnsconfig.SnapshotSigningKeyFile != "" {
+		key, err := os.ReadFile(cnsconfig.SnapshotSigningKeyFile)
+		if err != nil {
+			logger.Errorf("Failed to read snapshot signing key, err:%v.\n", err)
+			return
+		}
+		httpRestService.SetSnapshotSigningKey(bytes.TrimSpace(key))
+	}
+
+	if len(cnsconfig.IPHooks) > 0 {
+		hooks, err := newIPHooks(cnsconfig.IPHooks)
+		if err != nil {
+			logger.Errorf("Failed to configure IP hooks, err:%v.\n", err)
+			return
+		}
+		dispatcher := iphooks.NewDispatcher(&iphooks.Options{Timeout: time.Duration(cnsconfig.IPHookTimeoutSecs) * time.Second}, hooks...)
+		httpRestService.SetIPHooks(dispatcher)
+		go func() {
+			if e := dispatcher.Start(rootCtx); e != nil {
+				logger.Printf("[Azure CNS] Stopped IP hook dispatcher: %v", e)
+			}
+		}()
+	}
+
 	// Create default ext network if commandline option is set
 	if len(strings.TrimSpace(createDefaultExtNetworkType)) > 0 {
 		if err := hnsclient.CreateDefaultExtNetwork(createDefaultExtNetworkType); err == nil {
@@ -782,6 +880,10 @@ func main() {
 		go httpRestService.SendNCSnapShotPeriodically(rootCtx, cnsconfig.TelemetrySettings.SnapshotIntervalInMins)
 	}
 
+	if cnsconfig.StateCompactionIntervalSecs > 0 {
+		go compactStatePeriodically(rootCtx, httpRestService, config.Store, storeFileName, stateBackupDir, cnsconfig)
+	}
+
 	// If CNS is running on managed DNC mode
 	if config.ChannelMode == cns.Managed {
 		if privateEndpoint == "" || infravnet == "" || nodeID == "" {
@@ -918,6 +1020,76 @@ func main() {
 	logger.Close()
 }
 
+// newIPHooks creates the exec and webhook IP hooks of the config.
+func newIPHooks(settings []configuration.IPHookSettings) ([]iphooks.Hook, error) {
+	hooks := make([]iphooks.Hook, 0, len(settings))
+	for i := range settings {
+		s := &settings[i]
+		switch {
+		case len(s.Exec) > 0 && s.WebhookURL == "":
+			hooks = append(hooks, &iphooks.ExecHook{HookName: s.Name, Path: s.Exec[0], Args: s.Exec[1:]})
+		case len(s.Exec) == 0 && s.WebhookURL != "":
+			if _, err := url.ParseRequestURI(s.WebhookURL); err != nil {
+				return nil, errors.Wrapf(err, "invalid webhook URL of IP hook %s", s.Name)
+			}
+			hooks = append(hooks, &iphooks.WebhookHook{HookName: s.Name, URL: s.WebhookURL})
+		default:
+			return nil, errors.Errorf("IP hook %s must set exactly one of Exec and WebhookURL", s.Name)
+		}
+	}
+	return hooks, nil
+}
+
+// stateStoreFileName returns the file of the state store with the path, without extension, in the backend.
+func stateStoreFileName(path, backend string) string {
+	if backend == configuration.StoreBackendBolt {
+		return path + ".db"
+	}
+	return path + ".json"
+}
+
+// newStateStore creates the state store with the path, without extension, in the backend. The bolt store migrates the
+// state of the JSON store with the same path on its first start.
+func newStateStore(path, backend string, lockclient processlock.Interface) (store.KeyValueStore, error) {
+	switch backend {
+	case "", configuration.StoreBackendJSON:
+		return store.NewJsonFileStore(stateStoreFileName(path, backend), lockclient) //nolint:wrapcheck // logged by the caller
+	case configuration.StoreBackendBolt:
+		return store.NewBoltStore(stateStoreFileName(path, backend), stateStoreFileName(path, configuration.StoreBackendJSON), lockclient) //nolint:wrapcheck // logged by the caller
+	default:
+		return nil, errors.Errorf("unknown store backend %q", backend)
+	}
+}
+
+// compactStatePeriodically drops tombstoned entries from the CNS state and backs up the compacted state file,
+// so that a corrupted state can be restored with the restore-state-backup flag.
+func compactStatePeriodically(ctx context.Context, httpRestService *restserver.HTTPRestService, stateStore store.KeyValueStore, storeFileName, backupDir string, cnsconfig *configuration.CNSConfig) {
+	keep := cnsconfig.StateBackupCount
+	if keep <= 0 {
+		keep = defaultStateBackupCount
+	}
+	ticker := time.NewTicker(time.Duration(cnsconfig.StateCompactionIntervalSecs) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := httpRestService.CompactState(); err != nil {
+			logger.Errorf("[Azure CNS] Failed to compact state: %v", err)
+			continue
+		}
+		backup, err := store.BackupStore(stateStore, storeFileName, backupDir, keep)
+		if err != nil {
+			logger.Errorf("[Azure CNS] Failed to back up state: %v", err)
+			continue
+		}
+		logger.Printf("[Azure CNS] Backed up state to %s", backup)
+	}
+}
+
 func InitializeMultiTenantController(ctx context.Context, httpRestService cns.HTTPService, cnsconfig configuration.CNSConfig) error {
 	var multiTenantController multitenantcontroller.RequestController
 	kubeConfig, err := ctrl.GetConfig()
@@ -1136,12 +1308,36 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 		return errors.Wrap(err, "failed to create NNC client")
 	}
 	// TODO(rbtr): nodename and namespace should be in the cns config
-	scopedcli := nncctrl.NewScopedClient(nnccli, types.NamespacedName{Namespace: "kube-system", Name: nodeName})
+	nncName := types.NamespacedName{Namespace: "kube-system", Name: nodeName}
+	scopedcli := nncctrl.NewScopedClient(nnccli, nncName)
+
+	// get our Node so that we can xref it against the NodeNetworkConfig's to make sure that the
+	// NNC is not stale and represents the Node we're running on.
+	node, err := clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %s", nodeName)
+	}
+	if cnsconfig.EnableMetricsNodeLabels {
+		healthserver.SetNodeLabels(node, cnsconfig.MetricsNodeLabels)
+	}
+
+	// the Events are emitted on the Node, a nil Recorder discards them.
+	var eventRecorder *events.Recorder
+	if cnsconfig.EnableKubernetesEvents {
+		eventRecorder = events.NewRecorder(events.NewBroadcastRecorder(clientset, nodeName), node,
+			time.Duration(cnsconfig.KubernetesEventIntervalSecs)*time.Second)
+	}
 
 	clusterSubnetStateChan := make(chan v1alpha1.ClusterSubnetState)
 	// initialize the ipam pool monitor
 	poolOpts := ipampool.Options{
-		RefreshDelay: poolIPAMRefreshRateInMilliseconds * time.Millisecond,
+		RefreshDelay:               poolIPAMRefreshRateInMilliseconds * time.Millisecond,
+		ReleaseConfirmation:        cnsconfig.EnableIPReleaseConfirmation,
+		ReleaseConfirmationTimeout: time.Duration(cnsconfig.IPReleaseConfirmationTimeoutSecs) * time.Second,
+		ReportPoolPressure:         cnsconfig.EnableIPPoolPressureReport,
+		PoolPressureThreshold:      time.Duration(cnsconfig.IPPoolPressureThresholdSecs) * time.Second,
+		Events:                     eventRecorder,
+		ReleaseStuckThreshold:      time.Duration(cnsconfig.IPReleaseStuckThresholdMins) * time.Minute,
 	}
 	poolMonitor := ipampool.NewMonitor(httpRestServiceImplementation, scopedcli, clusterSubnetStateChan, &poolOpts)
 	httpRestServiceImplementation.IPAMPoolMonitor = poolMonitor
@@ -1204,6 +1400,9 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 	if err = v1alpha1.AddToScheme(crdSchemes); err != nil {
 		return errors.Wrap(err, "failed to add clustersubnetstate/v1alpha1 to scheme")
 	}
+	if err = icsv1alpha1.AddToScheme(crdSchemes); err != nil {
+		return errors.Wrap(err, "failed to add ipclaimsummary/v1alpha1 to scheme")
+	}
 	manager, err := ctrl.NewManager(kubeConfig, ctrl.Options{
 		Scheme:             crdSchemes,
 		MetricsBindAddress: "0",
@@ -1214,18 +1413,24 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 		return errors.Wrap(err, "failed to create manager")
 	}
 
-	// get our Node so that we can xref it against the NodeNetworkConfig's to make sure that the
-	// NNC is not stale and represents the Node we're running on.
-	node, err := clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
-	if err != nil {
-		return errors.Wrapf(err, "failed to get node %s", nodeName)
+	if cnsconfig.Bootstrap.Enabled {
+		bootstrapper := bootstrap.New(acn.GetHttpClient(), nnccli, node, nncName, bootstrap.Options{
+			RegistrationURL: cnsconfig.Bootstrap.RegistrationURL,
+			InitialIPCount:  cnsconfig.Bootstrap.InitialIPCount,
+			Attempts:        cnsconfig.Bootstrap.RetryAttempts,
+			Delay:           time.Duration(cnsconfig.Bootstrap.RetryDelayInSeconds) * time.Second,
+		})
+		if err := bootstrapper.Run(ctx); err != nil { //nolint:govet // intentional shadow
+			return errors.Wrapf(err, "failed to bootstrap node, status: %s", bootstrapper.Status())
+		}
+		logger.Printf("Bootstrapped node, status: %s", bootstrapper.Status())
 	}
 
 	// get CNS Node IP to compare NC Node IP with this Node IP to ensure NCs were created for this node
 	nodeIP := configuration.NodeIP()
 
 	// NodeNetworkConfig reconciler
-	nncReconciler := nncctrl.NewReconciler(httpRestServiceImplementation, poolMonitor, nodeIP)
+	nncReconciler := nncctrl.NewReconciler(httpRestServiceImplementation, poolMonitor, nodeIP, eventRecorder)
 	// pass Node to the Reconciler for Controller xref
 	if err := nncReconciler.SetupWithManager(manager, node); err != nil { //nolint:govet // intentional shadow
 		return errors.Wrapf(err, "failed to setup nnc reconciler with manager")
@@ -1239,9 +1444,50 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 		}
 	}
 
-	// adding some routes to the root service mux
-	mux := httpRestServiceImplementation.Listener.GetMux()
-	mux.Handle("/readyz", http.StripPrefix("/readyz", &healthz.Handler{}))
+	if cnsconfig.IPConflictCheckIntervalSecs > 0 {
+		// the manager client reads the IPClaimSummaries from the informer cache of the manager, so that checking
+		// for conflicts watches the summaries instead of listing all of them from the apiserver on every Node.
+		conflictChecker := ipconflict.NewChecker(nodeName, httpRestServiceImplementation, ipclaimsummary.NewClient(manager.GetClient()), &ipconflict.Options{
+			Interval: time.Duration(cnsconfig.IPConflictCheckIntervalSecs) * time.Second,
+			// the IPClaimSummary is garbage collected with the Node
+			Owner: &metav1.OwnerReference{APIVersion: "v1", Kind: "Node", Name: node.Name, UID: node.UID},
+		})
+		go func() {
+			if !manager.GetCache().WaitForCacheSync(ctx) {
+				return
+			}
+			if e := conflictChecker.Start(ctx); e != nil {
+				logger.Printf("[Azure CNS] Stopped IP conflict checker: %v", e)
+			}
+		}()
+		logger.Printf("initialized and started IP conflict checker")
+	}
+
+	if cnsconfig.IPLeakCheckIntervalSecs > 0 {
+		leakDetector := ipleak.NewDetector(httpRestServiceImplementation, crictl.New(exec.New(), cnsconfig.CRIRuntimeEndpoint), &ipleak.Options{
+			Interval:    time.Duration(cnsconfig.IPLeakCheckIntervalSecs) * time.Second,
+			GracePeriod: time.Duration(cnsconfig.IPLeakGracePeriodSecs) * time.Second,
+			Reclaim:     cnsconfig.ReclaimLeakedIPs,
+		})
+		go func() {
+			if e := leakDetector.Start(ctx); e != nil {
+				logger.Printf("[Azure CNS] Stopped IP leak detector: %v", e)
+			}
+		}()
+		logger.Printf("initialized and started IP leak detector")
+	}
+
+	// CNS is ready once the NodeNetworkConfig cache is synced and the IPAM pool has IPs.
+	httpRestServiceImplementation.AddHealthCheck(restserver.HealthCheck{
+		Name: "nnc-informer",
+		Check: func(ctx context.Context) error {
+			if !manager.GetCache().WaitForCacheSync(ctx) {
+				return errNNCCacheNotSynced
+			}
+			return nil
+		},
+	})
+	httpRestServiceImplementation.AddHealthCheck(httpRestServiceImplementation.IPAMPoolHealthCheck())
 	if cnsconfig.EnablePprof {
 		httpRestServiceImplementation.RegisterPProfEndpoints()
 	}
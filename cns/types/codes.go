@@ -43,6 +43,10 @@ const (
 	NmAgentInternalServerError             ResponseCode = 41
 	StatusUnauthorized                     ResponseCode = 42
 	UnsupportedAPI                         ResponseCode = 43
+	NodeDraining                           ResponseCode = 44
+	DesiredIPUnavailable                   ResponseCode = 45
+	NetworkContainerVersionMismatch        ResponseCode = 46
+	UnknownOperationID                     ResponseCode = 47
 	UnexpectedError                        ResponseCode = 99
 )
 
@@ -123,6 +127,14 @@ func (c ResponseCode) String() string {
 		return "NmAgentInternalServerError"
 	case StatusUnauthorized:
 		return "StatusUnauthorized"
+	case NodeDraining:
+		return "NodeDraining"
+	case DesiredIPUnavailable:
+		return "DesiredIPUnavailable"
+	case NetworkContainerVersionMismatch:
+		return "NetworkContainerVersionMismatch"
+	case UnknownOperationID:
+		return "UnknownOperationID"
 	default:
 		return "UnknownError"
 	}
@@ -1,18 +1,13 @@
 package wireserver
 
 import (
-	"bytes"
 	"context"
-	"encoding/xml"
-	"io"
-	"net/http"
 
 	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/nmagent"
 	"github.com/pkg/errors"
 )
 
-const hostQueryURL = "http://168.63.129.16/machine/plugins?comp=nmagent&type=getinterfaceinfov1"
-
 type GetNetworkContainerOpts struct {
 	NetworkContainerID string
 	PrimaryAddress     string
@@ -20,37 +15,36 @@ type GetNetworkContainerOpts struct {
 	APIVersion         string
 }
 
-type do interface {
-	Do(*http.Request) (*http.Response, error)
+type nmagentClient interface {
+	GetInterfaceIPInfo(context.Context) (nmagent.Interfaces, error)
 }
 
 type Client struct {
-	HTTPClient do
+	NMAgent nmagentClient
 }
 
 // GetInterfaces queries interfaces from the wireserver.
 func (c *Client) GetInterfaces(ctx context.Context) (*GetInterfacesResult, error) {
 	logger.Printf("[Azure CNS] GetPrimaryInterfaceInfoFromHost")
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hostQueryURL, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to construct request")
-	}
-	resp, err := c.HTTPClient.Do(req)
+	interfaces, err := c.NMAgent.GetInterfaceIPInfo(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to execute request")
+		return nil, errors.Wrap(err, "failed to get interface IP info")
 	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read response body")
-	}
-
-	logger.Printf("[Azure CNS] Response received from NMAgent for get interface details: %s", string(b))
 
-	var res GetInterfacesResult
-	if err := xml.NewDecoder(bytes.NewReader(b)).Decode(&res); err != nil {
-		return nil, errors.Wrap(err, "failed to decode response body")
+	logger.Printf("[Azure CNS] Response received from NMAgent for get interface details: %+v", interfaces)
+
+	res := &GetInterfacesResult{Interface: make([]Interface, 0, len(interfaces.Interface))}
+	for _, i := range interfaces.Interface {
+		iface := Interface{MacAddress: i.MacAddress, IsPrimary: i.IsPrimary}
+		for _, s := range i.IPSubnet {
+			subnet := Subnet{Prefix: s.Prefix}
+			for _, a := range s.IPAddress {
+				subnet.IPAddress = append(subnet.IPAddress, Address{Address: a.Address, IsPrimary: a.IsPrimary})
+			}
+			iface.IPSubnet = append(iface.IPSubnet, subnet)
+		}
+		res.Interface = append(res.Interface, iface)
 	}
-	return &res, nil
+	return res, nil
 }
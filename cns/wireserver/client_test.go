@@ -0,0 +1,41 @@
+package wireserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/nmagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLogger("testlogs", 0, 0, "./")
+	os.Exit(m.Run())
+}
+
+type nmagentFunc func(context.Context) (nmagent.Interfaces, error)
+
+func (f nmagentFunc) GetInterfaceIPInfo(ctx context.Context) (nmagent.Interfaces, error) {
+	return f(ctx)
+}
+
+func TestGetInterfaces(t *testing.T) {
+	b, err := os.ReadFile("testdata/interfaces.xml")
+	require.NoError(t, err)
+	var want GetInterfacesResult
+	require.NoError(t, xml.NewDecoder(bytes.NewReader(b)).Decode(&want))
+
+	client := Client{NMAgent: nmagentFunc(func(context.Context) (nmagent.Interfaces, error) {
+		var interfaces nmagent.Interfaces
+		err := xml.NewDecoder(bytes.NewReader(b)).Decode(&interfaces)
+		return interfaces, err //nolint:wrapcheck // test
+	})}
+	got, err := client.GetInterfaces(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &want, got)
+}
@@ -16,6 +16,10 @@ const (
 	unpublishNCURLFmt = `http://%s/machine/plugins/?comp=nmagent&type=NetworkManagement/interfaces/%s/networkContainers/%s/authenticationToken/%s/api-version/1/method/DELETE`
 )
 
+type do interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
 type Proxy struct {
 	Host       string
 	HTTPClient do
@@ -12,6 +12,7 @@ const (
 	OptEnvironmentMAS          = "mas"
 	OptEnvironmentFileIpam     = "fileIpam"
 	OptEnvironmentIPv6NodeIpam = "ipv6NodeIpam"
+	OptEnvironmentIMDS         = "imds"
 
 	// API server URL.
 	OptAPIServerURL      = "api-url"
@@ -38,7 +39,7 @@ const (
 	OptLogLocation      = "log-location"
 	OptLogLocationAlias = "o"
 
-	// IPAM query URL.
+	// IPAM query URL. The interface info is queried from the NMAgent of its host.
 	OptIpamQueryUrl      = "ipam-query-url"
 	OptIpamQueryUrlAlias = "q"
 
@@ -46,6 +47,19 @@ const (
 	OptIpamQueryInterval      = "ipam-query-interval"
 	OptIpamQueryIntervalAlias = "i"
 
+	// IPAM query failover URLs, a list or a comma separated string. Tried in order after the IPAM query URL.
+	OptIpamQueryFailoverUrls = "ipam-query-failover-urls"
+
+	// IPAM query cache file. The query result is cached on disk for the query interval when set.
+	OptIpamQueryCacheFile = "ipam-query-cache-file"
+
+	// IPAM address sources to chain, in fallback order. Overrides the environment.
+	OptIpamSources = "ipam-sources"
+
+	// IPAM resync interval, in address requests. Every n-th request queries the address source again
+	// bypassing its cache, to detect the addresses which no longer belong to the node. Negative disables it.
+	OptIpamResyncAddInterval = "ipam-resync-add-interval"
+
 	// Start CNM
 	OptStartAzureCNM      = "start-azure-cnm"
 	OptStartAzureCNMAlias = "startcnm"
@@ -146,4 +160,8 @@ const (
 	OptCNIConflistScenario = "cni-conflist-scenario"
 	// OptCNIConflistScenarioAlias "shorthand" for the cni conflist scenairo, see above
 	OptCNIConflistScenarioAlias = "cniconflistscenario"
+
+	// Restore state from backup
+	OptRestoreStateBackup      = "restore-state-backup"
+	OptRestoreStateBackupAlias = "rsb"
 )
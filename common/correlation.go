@@ -0,0 +1,35 @@
+package common
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// CorrelationIDHeader is the header of the requests to CNS with the correlation ID of the CNI invocation sending them.
+	CorrelationIDHeader = "X-Correlation-Id"
+	// CorrelationIDMetadataKey is the gRPC metadata key of the correlation ID of the CNI invocation calling NNS.
+	CorrelationIDMetadataKey = "x-correlation-id"
+)
+
+type correlationIDKey struct{}
+
+// NewCorrelationID returns a new ID correlating the logs, telemetry and requests of a single CNI invocation.
+func NewCorrelationID() string {
+	return uuid.NewString()
+}
+
+// WithCorrelationID returns a copy of the context carrying the correlation ID, or the context if the ID is empty.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by the context, or an empty string if there's none.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WireserverIP is the address of the wireserver, and of the NMAgent behind it.
+	WireserverIP = "168.63.129.16"
+	// IMDSIP is the address of the instance metadata service.
+	IMDSIP = "169.254.169.254"
+)
+
+var (
+	// ErrCircuitOpen is returned for the requests which aren't sent because the previous requests kept failing.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrInvalidCAFile is returned for a CA file without PEM certificates.
+	ErrInvalidCAFile = errors.New("no certificates in CA file")
+)
+
+// DefaultHostRetryPolicy retries the idempotent queries of the host endpoints, and stops sending them for a while
+// when the host endpoints keep failing, e.g. during host updates.
+var DefaultHostRetryPolicy = RetryPolicy{
+	Attempts:         3,
+	Backoff:          500 * time.Millisecond,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// HTTPClientConfig configures the HTTP clients of the calls to the host endpoints, e.g. wireserver, NMAgent and IMDS.
+type HTTPClientConfig struct {
+	// ConnectionTimeout bounds establishing a connection, ResponseHeaderTimeout waiting for the response headers once
+	// the request is sent, and Timeout a whole request including its retries. Zero doesn't bound them.
+	ConnectionTimeout     time.Duration
+	ResponseHeaderTimeout time.Duration
+	Timeout               time.Duration
+	// CAFile, if set, is a PEM bundle of the CAs trusted by the TLS connections, instead of the CAs of the host.
+	CAFile string
+	// Retry is how the requests which fail transiently are retried.
+	Retry RetryPolicy
+}
+
+// RetryPolicy is how the requests which fail transiently are retried. The zero value doesn't retry them.
+type RetryPolicy struct {
+	// Attempts is the max number of attempts of a request, 1 if zero.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for every retry after it.
+	Backoff time.Duration
+	// BreakerThreshold is the number of consecutive failed requests after which the circuit breaker opens, failing
+	// the requests without sending them for the BreakerCooldown. Zero disables the circuit breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// NewHTTPClient creates a client of the host endpoints, see NewHTTPTransport.
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	transport, err := NewHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.Timeout}, nil
+}
+
+// NewHTTPTransport creates a transport which sends the requests through the proxy of the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables, except the requests to the wireserver and IMDS which the proxy can't reach, and
+// which retries the requests which fail transiently.
+func NewHTTPTransport(cfg HTTPClientConfig) (http.RoundTripper, error) {
+	transport := &http.Transport{
+		Proxy: proxyBypassingHost,
+		DialContext: (&net.Dialer{
+			Timeout: cfg.ConnectionTimeout,
+		}).DialContext,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CA file %s", cfg.CAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Wrap(ErrInvalidCAFile, cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.Retry.Attempts <= 1 && cfg.Retry.BreakerThreshold == 0 {
+		return transport, nil
+	}
+	return &retryTransport{
+		next:    transport,
+		policy:  cfg.Retry,
+		breaker: circuitBreaker{threshold: cfg.Retry.BreakerThreshold, cooldown: cfg.Retry.BreakerCooldown},
+	}, nil
+}
+
+// proxyBypassingHost returns the proxy of the environment, except for the host endpoints.
+func proxyBypassingHost(req *http.Request) (*url.URL, error) {
+	if host := req.URL.Hostname(); host == WireserverIP || host == IMDSIP {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// retryTransport retries the requests which fail transiently, and stops sending requests while they keep failing.
+type retryTransport struct {
+	next    http.RoundTripper
+	policy  RetryPolicy
+	breaker circuitBreaker
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow(time.Now()) {
+		return nil, errors.Wrapf(ErrCircuitOpen, "%s %s", req.Method, req.URL.Redacted())
+	}
+
+	backoff := t.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		transient := isTransientFailure(resp, err)
+		// the requests whose body can't be sent again aren't retried
+		last := attempt >= t.policy.Attempts || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil)
+		if !transient || last || req.Context().Err() != nil {
+			t.breaker.record(transient, time.Now())
+			return resp, err //nolint:wrapcheck // the errors of the transport are returned as is
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		log.Printf("[Utils] %s %s failed on attempt %d, retrying in %v", req.Method, req.URL.Redacted(), attempt, backoff)
+
+		select {
+		case <-req.Context().Done():
+			t.breaker.record(true, time.Now())
+			return nil, errors.Wrapf(req.Context().Err(), "%s %s failed after %d attempts", req.Method, req.URL.Redacted(), attempt)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to rewind request body")
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// isTransientFailure returns whether the request failed in a way that may not fail again.
+func isTransientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// circuitBreaker opens after threshold consecutive failures, and lets a request through once the cooldown expires.
+// It closes again once a request succeeds.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b.threshold == 0 {
+		return true
+	}
+	b.Lock()
+	defer b.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+func (b *circuitBreaker) record(failed bool, now time.Time) {
+	if b.threshold == 0 {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		log.Printf("[Utils] Opening circuit breaker for %v after %d consecutive failures", b.cooldown, b.failures)
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
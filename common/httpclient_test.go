@@ -0,0 +1,97 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClientRetriesTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPClientConfig{Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond}})
+	require.NoError(t, err)
+	// the body is sent again with every attempt
+	resp, err := client.Post(server.URL, "text/plain", bytes.NewReader([]byte("ping")))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ping", string(b))
+	assert.EqualValues(t, 3, requests.Load())
+}
+
+func TestHTTPClientDoesNotRetryPermanentFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPClientConfig{Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond}})
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.EqualValues(t, 1, requests.Load())
+}
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPClientConfig{Retry: RetryPolicy{Attempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Hour}})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL) //nolint:govet // intentional shadow
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	// the breaker is open, so the request isn't sent
+	_, err = client.Get(server.URL) //nolint:bodyclose // there is no response
+	require.True(t, errors.Is(err, ErrCircuitOpen), err)
+	assert.EqualValues(t, 2, requests.Load())
+}
+
+func TestProxyBypassesHostEndpoints(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	for _, u := range []string{"http://" + WireserverIP + "/machine", "http://" + IMDSIP + "/metadata/instance"} {
+		req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+		require.NoError(t, err)
+		proxy, err := proxyBypassingHost(req)
+		require.NoError(t, err)
+		assert.Nil(t, proxy, u)
+	}
+}
+
+func TestNewHTTPClientInvalidCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err := NewHTTPClient(HTTPClientConfig{CAFile: caFile})
+	require.ErrorIs(t, err, ErrInvalidCAFile)
+}
@@ -0,0 +1,232 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/nmagent"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultInterfaceInfoAttempts   = 3
+	defaultInterfaceInfoRetryDelay = 500 * time.Millisecond
+)
+
+var ErrInterfaceInfoQuery = errors.New("failed to query interface info")
+
+// InterfaceInfoGetter gets the host agent interface info document, e.g. an *nmagent.Client.
+type InterfaceInfoGetter interface {
+	GetInterfaceIPInfo(context.Context) (nmagent.Interfaces, error)
+}
+
+// NewInterfaceInfoGetters creates the getters of the interface info documents at the given URLs, e.g. the
+// ipamQueryURL. The getters send their requests through transport.
+func NewInterfaceInfoGetters(transport http.RoundTripper, urls ...string) ([]InterfaceInfoGetter, error) {
+	getters := make([]InterfaceInfoGetter, 0, len(urls))
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid interface info URL %s", rawURL)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid interface info URL %s: no scheme or host", rawURL)
+		}
+		getters = append(getters, &urlInterfaceInfoGetter{
+			url:    u.String(),
+			client: &http.Client{Transport: transport},
+		})
+	}
+	return getters, nil
+}
+
+// urlInterfaceInfoGetter gets the interface info document at a URL, with its path and query.
+type urlInterfaceInfoGetter struct {
+	url    string
+	client *http.Client
+}
+
+func (g *urlInterfaceInfoGetter) GetInterfaceIPInfo(ctx context.Context) (nmagent.Interfaces, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url, http.NoBody)
+	if err != nil {
+		return nmagent.Interfaces{}, errors.Wrapf(err, "failed to create the request of %s", g.url)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nmagent.Interfaces{}, errors.Wrapf(err, "failed to get %s", g.url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nmagent.Interfaces{}, errors.Errorf("failed to get %s: %s", g.url, resp.Status)
+	}
+
+	var doc nmagent.Interfaces
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nmagent.Interfaces{}, errors.Wrapf(err, "failed to decode the interface info of %s", g.url)
+	}
+	return doc, nil
+}
+
+// InterfaceInfoClient fetches the host agent interface info document from NMAgent
+// and caches it in memory and, if a cache file is set, on disk so that it
+// is shared between short lived CNI invocations.
+// Queries are tried against each getter in order, and the whole list is retried a few times.
+// If every attempt fails, the last cached document is returned even if it is stale.
+type InterfaceInfoClient struct {
+	sync.Mutex
+	getters    []InterfaceInfoGetter
+	cacheFile  string
+	ttl        time.Duration
+	attempts   int
+	retryDelay time.Duration
+	doc        *XmlDocument
+	fetchedAt  time.Time
+}
+
+// NewInterfaceInfoClient creates an InterfaceInfoClient which queries the given getters in order.
+// An empty cacheFile disables the disk cache, and a zero ttl disables caching of fresh results.
+func NewInterfaceInfoClient(cacheFile string, ttl time.Duration, getters ...InterfaceInfoGetter) *InterfaceInfoClient {
+	return &InterfaceInfoClient{
+		getters:    getters,
+		cacheFile:  cacheFile,
+		ttl:        ttl,
+		attempts:   defaultInterfaceInfoAttempts,
+		retryDelay: defaultInterfaceInfoRetryDelay,
+	}
+}
+
+// Get returns the interface info document, from the cache if it is fresh.
+func (c *InterfaceInfoClient) Get(ctx context.Context) (*XmlDocument, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.doc == nil && c.cacheFile != "" {
+		c.loadCacheFile()
+	}
+
+	if c.doc != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.doc, nil
+	}
+
+	doc, err := c.query(ctx)
+	if err != nil {
+		if c.doc == nil {
+			return nil, err
+		}
+		log.Printf("[Utils] %v, using interface info cached at %v", err, c.fetchedAt)
+		return c.doc, nil
+	}
+
+	c.cache(doc)
+	return doc, nil
+}
+
+// Refresh queries the interface info document bypassing the cache, and caches it.
+// Unlike Get, it fails instead of returning a stale document.
+func (c *InterfaceInfoClient) Refresh(ctx context.Context) (*XmlDocument, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	doc, err := c.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache(doc)
+	return doc, nil
+}
+
+func (c *InterfaceInfoClient) cache(doc *XmlDocument) {
+	c.doc = doc
+	c.fetchedAt = time.Now()
+	if c.cacheFile != "" {
+		c.saveCacheFile()
+	}
+}
+
+func (c *InterfaceInfoClient) query(ctx context.Context) (*XmlDocument, error) {
+	var lastErr error
+	for attempt := 0; attempt < c.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, errors.Wrapf(ErrInterfaceInfoQuery, "%v", ctx.Err())
+			case <-time.After(c.retryDelay):
+			}
+		}
+
+		for i, getter := range c.getters {
+			doc, err := getter.GetInterfaceIPInfo(ctx)
+			if err == nil {
+				return &doc, nil
+			}
+			log.Printf("[Utils] interface info query %d failed on attempt %d: %v", i, attempt+1, err)
+			lastErr = err
+		}
+	}
+
+	return nil, errors.Wrapf(ErrInterfaceInfoQuery, "%v", lastErr)
+}
+
+func (c *InterfaceInfoClient) loadCacheFile() {
+	info, err := os.Stat(c.cacheFile)
+	if err != nil {
+		return
+	}
+
+	raw, err := os.ReadFile(c.cacheFile)
+	if err != nil {
+		log.Printf("[Utils] Failed to read interface info cache %s: %v", c.cacheFile, err)
+		return
+	}
+
+	var doc XmlDocument
+	if err := xml.NewDecoder(bytes.NewReader(raw)).Decode(&doc); err != nil {
+		log.Printf("[Utils] Ignoring invalid interface info cache %s: %v", c.cacheFile, err)
+		return
+	}
+
+	c.doc = &doc
+	c.fetchedAt = info.ModTime()
+}
+
+// saveCacheFile writes the cache through a temp file so that concurrent readers never see a partial document.
+func (c *InterfaceInfoClient) saveCacheFile() {
+	raw, err := xml.Marshal(c.doc)
+	if err != nil {
+		log.Printf("[Utils] Failed to encode interface info cache: %v", err)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.cacheFile), filepath.Base(c.cacheFile)+".tmp")
+	if err != nil {
+		log.Printf("[Utils] Failed to create interface info cache: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(raw)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("[Utils] Failed to write interface info cache: %v", err)
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), c.cacheFile); err != nil {
+		log.Printf("[Utils] Failed to save interface info cache %s: %v", c.cacheFile, err)
+	}
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testInterfaceInfo = `<Interfaces><Interface MacAddress="000D3A6E1825" IsPrimary="true"><IPSubnet Prefix="10.0.0.0/16">` +
+	`<IPAddress Address="10.0.0.4" IsPrimary="true"/><IPAddress Address="10.0.0.5" IsPrimary="false"/></IPSubnet></Interface></Interfaces>`
+
+func getters(t *testing.T, urls ...string) []InterfaceInfoGetter {
+	t.Helper()
+	getters, err := NewInterfaceInfoGetters(http.DefaultTransport, urls...)
+	require.NoError(t, err)
+	return getters
+}
+
+func TestInterfaceInfoClientFailoverAndCache(t *testing.T) {
+	requests := 0
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(testInterfaceInfo))
+	}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "interfaceinfo.xml")
+	client := NewInterfaceInfoClient(cacheFile, time.Minute, getters(t, unhealthy.URL, healthy.URL)...)
+
+	doc, err := client.Get(context.Background())
+	require.NoError(t, err)
+	require.Len(t, doc.Interface, 1)
+	require.Equal(t, 1, requests)
+
+	// a fresh client is served from the disk cache
+	client = NewInterfaceInfoClient(cacheFile, time.Minute, getters(t, healthy.URL)...)
+	_, err = client.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+}
+
+func TestInterfaceInfoClientServesStaleCacheOnFailure(t *testing.T) {
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(testInterfaceInfo))
+	}))
+	defer server.Close()
+
+	client := NewInterfaceInfoClient("", 0, getters(t, server.URL)...)
+	client.retryDelay = time.Millisecond
+
+	_, err := client.Get(context.Background())
+	require.NoError(t, err)
+
+	fail = true
+	doc, err := client.Get(context.Background())
+	require.NoError(t, err)
+	require.Len(t, doc.Interface, 1)
+
+	// with nothing cached the error is returned
+	client = NewInterfaceInfoClient("", 0, getters(t, server.URL)...)
+	client.retryDelay = time.Millisecond
+	_, err = client.Get(context.Background())
+	require.ErrorIs(t, err, ErrInterfaceInfoQuery)
+}
+
+func TestInterfaceInfoClientRefreshBypassesCache(t *testing.T) {
+	requests := 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(testInterfaceInfo))
+	}))
+	defer server.Close()
+
+	client := NewInterfaceInfoClient("", time.Minute, getters(t, server.URL)...)
+	client.retryDelay = time.Millisecond
+
+	_, err := client.Get(context.Background())
+	require.NoError(t, err)
+	doc, err := client.Refresh(context.Background())
+	require.NoError(t, err)
+	require.Len(t, doc.Interface, 1)
+	require.Equal(t, 2, requests)
+
+	// unlike Get, the cached document isn't returned on failure
+	fail = true
+	_, err = client.Refresh(context.Background())
+	require.ErrorIs(t, err, ErrInterfaceInfoQuery)
+}
+
+func TestInterfaceInfoGettersUseTheWholeURL(t *testing.T) {
+	var requestURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		_, _ = w.Write([]byte(testInterfaceInfo))
+	}))
+	defer server.Close()
+
+	getter := getters(t, server.URL+"/machine/plugins?comp=nmagent&type=getinterfaceinfov1")[0]
+	doc, err := getter.GetInterfaceIPInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, doc.Interface, 1)
+	require.Equal(t, "/machine/plugins?comp=nmagent&type=getinterfaceinfov1", requestURI)
+
+	_, err = NewInterfaceInfoGetters(http.DefaultTransport, "168.63.129.16")
+	require.Error(t, err)
+}
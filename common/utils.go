@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"net"
@@ -18,6 +17,7 @@ import (
 	"time"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/nmagent"
 )
 
 const (
@@ -33,25 +33,7 @@ const (
 )
 
 // XmlDocument - Azure host agent XML document format.
-type XmlDocument struct {
-	XMLName   xml.Name `xml:"Interfaces"`
-	Interface []struct {
-		XMLName    xml.Name `xml:"Interface"`
-		MacAddress string   `xml:"MacAddress,attr"`
-		IsPrimary  bool     `xml:"IsPrimary,attr"`
-
-		IPSubnet []struct {
-			XMLName xml.Name `xml:"IPSubnet"`
-			Prefix  string   `xml:"Prefix,attr"`
-
-			IPAddress []struct {
-				XMLName   xml.Name `xml:"IPAddress"`
-				Address   string   `xml:"Address,attr"`
-				IsPrimary bool     `xml:"IsPrimary,attr"`
-			}
-		}
-	}
-}
+type XmlDocument = nmagent.Interfaces
 
 // Metadata retrieved from wireserver
 type Metadata struct {
@@ -89,14 +71,11 @@ func InitHttpClient(
 	responseHeaderTimeoutSec int) *http.Client {
 	log.Printf("[Utils] Initializing HTTP client with connection timeout: %d, response header timeout: %d",
 		connectionTimeoutSec, responseHeaderTimeoutSec)
-	httpClient = &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: time.Duration(connectionTimeoutSec) * time.Second,
-			}).DialContext,
-			ResponseHeaderTimeout: time.Duration(responseHeaderTimeoutSec) * time.Second,
-		},
-	}
+	// without a CA file, the client can't fail to be created
+	httpClient, _ = NewHTTPClient(HTTPClientConfig{
+		ConnectionTimeout:     time.Duration(connectionTimeoutSec) * time.Second,
+		ResponseHeaderTimeout: time.Duration(responseHeaderTimeoutSec) * time.Second,
+	})
 
 	return httpClient
 }
@@ -187,14 +166,10 @@ func GetHostMetadata(fileName string) (Metadata, error) {
 
 	req.Header.Set("Metadata", "True")
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: time.Duration(httpConnectionTimeout) * time.Second,
-			}).DialContext,
-			ResponseHeaderTimeout: time.Duration(headerTimeout) * time.Second,
-		},
-	}
+	client, _ := NewHTTPClient(HTTPClientConfig{
+		ConnectionTimeout:     time.Duration(httpConnectionTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(headerTimeout) * time.Second,
+	})
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -0,0 +1,43 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "make" to regenerate code after modifying this file
+
+// +kubebuilder:object:root=true
+
+// AddressGroup is a named set of CIDRs which NetworkPolicies can reference instead of repeating them in ipBlocks.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=ag
+// +kubebuilder:printcolumn:name="CIDRs",type=string,JSONPath=`.spec.cidrs`
+type AddressGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AddressGroupSpec `json:"spec,omitempty"`
+}
+
+// AddressGroupSpec defines the CIDRs of an AddressGroup
+type AddressGroupSpec struct {
+	// CIDRs are the address ranges of the group, e.g. 10.0.0.0/8 or a single IP as 10.0.0.1/32.
+	// +kubebuilder:validation:Optional
+	CIDRs []string `json:"cidrs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AddressGroupList contains a list of AddressGroup
+type AddressGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddressGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AddressGroup{}, &AddressGroupList{})
+}
@@ -0,0 +1,26 @@
+package addressgroup
+
+import (
+	_ "embed"
+
+	// import the manifests package so that caller of this package have the manifests compiled in as a side-effect.
+	_ "github.com/Azure/azure-container-networking/crd/addressgroup/manifests"
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// AddressGroupsYAML embeds the CRD YAML for downstream consumers.
+//
+//go:embed manifests/acn.azure.com_addressgroups.yaml
+var AddressGroupsYAML []byte
+
+// GetAddressGroups parses the raw []byte AddressGroups in
+// to a CustomResourceDefinition and returns it or an unmarshalling error.
+func GetAddressGroups() (*apiextensionsv1.CustomResourceDefinition, error) {
+	addressGroups := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(AddressGroupsYAML, &addressGroups); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling embedded addressgroups")
+	}
+	return addressGroups, nil
+}
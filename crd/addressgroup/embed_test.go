@@ -0,0 +1,21 @@
+package addressgroup
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const filename = "manifests/acn.azure.com_addressgroups.yaml"
+
+func TestEmbed(t *testing.T) {
+	b, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, b, AddressGroupsYAML)
+}
+
+func TestGetAddressGroups(t *testing.T) {
+	_, err := GetAddressGroups()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,58 @@
+//go:build !ignore_uncovered
+// +build !ignore_uncovered
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "make" to regenerate code after modifying this file
+
+// IPClaimSummary is the summary of the Pod IPs assigned by CNS on a Node, published so that IPs
+// claimed by more than one Node can be detected. It is named after the Node.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=ics
+// +kubebuilder:printcolumn:name="Prefixes",type=integer,JSONPath=`.spec.prefixCount`
+// +kubebuilder:printcolumn:name="IPs",type=integer,JSONPath=`.spec.ipCount`
+type IPClaimSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IPClaimSummarySpec `json:"spec,omitempty"`
+}
+
+// IPClaimSummarySpec defines the IPs assigned on a Node, aggregated by prefix.
+type IPClaimSummarySpec struct {
+	// IPCount is the number of assigned IPs.
+	// +kubebuilder:validation:Optional
+	IPCount int `json:"ipCount,omitempty"`
+	// PrefixCount is the number of prefixes containing assigned IPs.
+	// +kubebuilder:validation:Optional
+	PrefixCount int `json:"prefixCount,omitempty"`
+	// Prefixes are the assigned IPs grouped by the prefix containing them.
+	// +kubebuilder:validation:Optional
+	Prefixes []PrefixClaim `json:"prefixes,omitempty"`
+}
+
+// PrefixClaim is the set of assigned IPs in a prefix of 256 addresses.
+type PrefixClaim struct {
+	// Prefix is the /24 (IPv4) or /120 (IPv6) containing the IPs, e.g. 10.240.1.0/24.
+	Prefix string `json:"prefix"`
+	// Bitmap is the base64 encoded 256 bit bitmap of the IPs, where bit i (LSB first in each byte)
+	// is set if the i-th address of the Prefix is assigned.
+	Bitmap string `json:"bitmap"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPClaimSummaryList contains a list of IPClaimSummary
+type IPClaimSummaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPClaimSummary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IPClaimSummary{}, &IPClaimSummaryList{})
+}
@@ -0,0 +1,68 @@
+package ipclaimsummary
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Azure/azure-container-networking/crd/ipclaimsummary/api/v1alpha1"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Scheme is a runtime scheme containing the client-go scheme and the IPClaimSummary scheme.
+var Scheme = runtime.NewScheme()
+
+func init() {
+	_ = scheme.AddToScheme(Scheme)
+	_ = v1alpha1.AddToScheme(Scheme)
+}
+
+// Client provides methods to interact with instances of the IPClaimSummary custom resource.
+type Client struct {
+	cli client.Client
+}
+
+// NewClient creates a new IPClaimSummary client from the passed ctrlcli.Client.
+func NewClient(cli client.Client) *Client {
+	return &Client{
+		cli: cli,
+	}
+}
+
+// Get returns the IPClaimSummary of the Node.
+func (c *Client) Get(ctx context.Context, nodeName string) (*v1alpha1.IPClaimSummary, error) {
+	ipClaimSummary := &v1alpha1.IPClaimSummary{}
+	err := c.cli.Get(ctx, types.NamespacedName{Name: nodeName}, ipClaimSummary)
+	return ipClaimSummary, errors.Wrapf(err, "failed to get ics %s", nodeName)
+}
+
+// List returns the IPClaimSummaries of all Nodes.
+func (c *Client) List(ctx context.Context) ([]v1alpha1.IPClaimSummary, error) {
+	list := &v1alpha1.IPClaimSummaryList{}
+	if err := c.cli.List(ctx, list); err != nil {
+		return nil, errors.Wrap(err, "failed to list ics")
+	}
+	return list.Items, nil
+}
+
+// CreateOrUpdate creates the IPClaimSummary, or replaces the Spec of the existing one with the same name.
+// The existing IPClaimSummary is not updated if its Spec is unchanged.
+func (c *Client) CreateOrUpdate(ctx context.Context, ipClaimSummary *v1alpha1.IPClaimSummary) error {
+	existing := &v1alpha1.IPClaimSummary{}
+	err := c.cli.Get(ctx, types.NamespacedName{Name: ipClaimSummary.Name}, existing)
+	if apierrors.IsNotFound(err) {
+		return errors.Wrapf(c.cli.Create(ctx, ipClaimSummary), "failed to create ics %s", ipClaimSummary.Name)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ics %s", ipClaimSummary.Name)
+	}
+	if reflect.DeepEqual(existing.Spec, ipClaimSummary.Spec) {
+		return nil
+	}
+	existing.Spec = ipClaimSummary.Spec
+	return errors.Wrapf(c.cli.Update(ctx, existing), "failed to update ics %s", ipClaimSummary.Name)
+}
@@ -0,0 +1,26 @@
+package ipclaimsummary
+
+import (
+	_ "embed"
+
+	// import the manifests package so that caller of this package have the manifests compiled in as a side-effect.
+	_ "github.com/Azure/azure-container-networking/crd/ipclaimsummary/manifests"
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// IPClaimSummariesYAML embeds the CRD YAML for downstream consumers.
+//
+//go:embed manifests/acn.azure.com_ipclaimsummaries.yaml
+var IPClaimSummariesYAML []byte
+
+// GetIPClaimSummaries parses the raw []byte IPClaimSummaries in
+// to a CustomResourceDefinition and returns it or an unmarshalling error.
+func GetIPClaimSummaries() (*apiextensionsv1.CustomResourceDefinition, error) {
+	ipClaimSummaries := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(IPClaimSummariesYAML, &ipClaimSummaries); err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling embedded ipclaimsummaries")
+	}
+	return ipClaimSummaries, nil
+}
@@ -0,0 +1,21 @@
+package ipclaimsummary
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const filename = "manifests/acn.azure.com_ipclaimsummaries.yaml"
+
+func TestEmbed(t *testing.T) {
+	b, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, b, IPClaimSummariesYAML)
+}
+
+func TestGetIPClaimSummaries(t *testing.T) {
+	_, err := GetIPClaimSummaries()
+	assert.NoError(t, err)
+}
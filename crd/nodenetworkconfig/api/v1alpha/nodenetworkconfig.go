@@ -46,6 +46,26 @@ type NodeNetworkConfigSpec struct {
 	// +kubebuilder:validation:Optional
 	RequestedIPCount int64    `json:"requestedIPCount"`
 	IPsNotInUse      []string `json:"ipsNotInUse,omitempty"`
+	// IPPoolPressure is set by CNS while the IP pool has been unable to grow for new Pods for a sustained period,
+	// because it is at the max IP count or the subnet is exhausted. Node autoscalers can use it to prefer adding
+	// nodes over scheduling more Pods on this one.
+	// +kubebuilder:validation:Optional
+	IPPoolPressure bool `json:"ipPoolPressure,omitempty"`
+	// NamespaceSubnets enables the subnet-per-namespace mode, where the Pods of the listed namespaces get their IPs
+	// from the NCs of the mapped subnet, and the Pods of the other namespaces from the NCs of the unmapped subnets.
+	// It is set by the operator, CNS only preserves it.
+	// +kubebuilder:validation:Optional
+	NamespaceSubnets []NamespaceSubnet `json:"namespaceSubnets,omitempty"`
+	// RequestedIPv6Count is the requested count of IPv6 IPs when the NCs of the node are dual-stack, the
+	// RequestedIPCount is then the count of IPv4 IPs.
+	// +kubebuilder:validation:Optional
+	RequestedIPv6Count int64 `json:"requestedIPv6Count,omitempty"`
+}
+
+// NamespaceSubnet maps namespaces to a subnet of the NCs of the node, by the SubnetName of the NCs.
+type NamespaceSubnet struct {
+	SubnetName string   `json:"subnetName"`
+	Namespaces []string `json:"namespaces"`
 }
 
 // Status indicates the NNC reconcile status
@@ -92,6 +112,20 @@ const (
 	VNET      NCType = "vnet"
 	VNETBlock NCType = "vnetblock"
 	Overlay   NCType = "overlay"
+	// MultitenantVLAN NCs belong to a tenant whose traffic is tagged with the EncapID of the NC as VLAN ID.
+	MultitenantVLAN NCType = "multitenantvlan"
+	// MultitenantVNET NCs are scoped to the VNET of a tenant, whose traffic is encapsulated with the EncapType of
+	// the NC, VxLAN if unset, and its EncapID.
+	MultitenantVNET NCType = "multitenantvnet"
+)
+
+// EncapType is how the traffic of a multitenant NC is isolated from the other tenants.
+// +kubebuilder:validation:Enum=vlan;vxlan
+type EncapType string
+
+const (
+	VLANEncap  EncapType = "vlan"
+	VxLANEncap EncapType = "vxlan"
 )
 
 // NetworkContainer defines the structure of a Network Container as found in NetworkConfigStatus
@@ -114,6 +148,10 @@ type NetworkContainer struct {
 	ResourceGroupID string `json:"resourceGroupID,omitempty"`
 	VNETID          string `json:"vnetID,omitempty"`
 	SubnetID        string `json:"subnetID,omitempty"`
+	// EncapType, EncapID and TenantID are only set on the multitenant NC types.
+	EncapType EncapType `json:"encapType,omitempty"`
+	EncapID   int64     `json:"encapID,omitempty"`
+	TenantID  string    `json:"tenantID,omitempty"`
 }
 
 // IPAssignment groups an IP address and Name. Name is a UUID set by the the IP address assigner.
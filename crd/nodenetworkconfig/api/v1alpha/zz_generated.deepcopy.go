@@ -24,6 +24,26 @@ func (in *IPAssignment) DeepCopy() *IPAssignment {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSubnet) DeepCopyInto(out *NamespaceSubnet) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSubnet.
+func (in *NamespaceSubnet) DeepCopy() *NamespaceSubnet {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSubnet)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkContainer) DeepCopyInto(out *NetworkContainer) {
 	*out = *in
@@ -111,6 +131,13 @@ func (in *NodeNetworkConfigSpec) DeepCopyInto(out *NodeNetworkConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NamespaceSubnets != nil {
+		in, out := &in.NamespaceSubnets, &out.NamespaceSubnets
+		*out = make([]NamespaceSubnet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeNetworkConfigSpec.
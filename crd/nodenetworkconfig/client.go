@@ -2,6 +2,7 @@ package nodenetworkconfig
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 
 	"github.com/Azure/azure-container-networking/crd"
@@ -105,6 +106,11 @@ func (c *Client) Get(ctx context.Context, key types.NamespacedName) (*v1alpha.No
 	return nodeNetworkConfig, errors.Wrapf(err, "failed to get nnc %v", key)
 }
 
+// Create creates the passed NodeNetworkConfig.
+func (c *Client) Create(ctx context.Context, nnc *v1alpha.NodeNetworkConfig) error {
+	return errors.Wrapf(c.cli.Create(ctx, nnc), "failed to create nnc %s/%s", nnc.Namespace, nnc.Name)
+}
+
 // PatchSpec performs a server-side patch of the passed NodeNetworkConfigSpec to the NodeNetworkConfig specified by the NamespacedName.
 func (c *Client) PatchSpec(ctx context.Context, key types.NamespacedName, spec *v1alpha.NodeNetworkConfigSpec, fieldManager string) (*v1alpha.NodeNetworkConfig, error) {
 	obj := genPatchSkel(key)
@@ -147,6 +153,28 @@ func (c *Client) SetControllerRef(ctx context.Context, key types.NamespacedName,
 	return obj, nil
 }
 
+// SetAnnotation sets the annotation of the NodeNetworkConfig specified by the NamespacedName to value, or removes it if
+// value is empty, using HTTP Patch.
+func (c *Client) SetAnnotation(ctx context.Context, key types.NamespacedName, annotation, value string) (*v1alpha.NodeNetworkConfig, error) {
+	var v *string // null removes the annotation
+	if value != "" {
+		v = &value
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{annotation: v},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal annotation patch")
+	}
+	obj := genPatchSkel(key)
+	if err := c.cli.Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return nil, errors.Wrapf(err, "failed to patch nnc annotation %s", annotation)
+	}
+	return obj, nil
+}
+
 func genPatchSkel(key types.NamespacedName) *v1alpha.NodeNetworkConfig {
 	return &v1alpha.NodeNetworkConfig{
 		TypeMeta: metav1.TypeMeta{
@@ -0,0 +1,49 @@
+// Package crictl lists the pod sandboxes of the container runtime of the node with crictl.
+package crictl
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	utilexec "k8s.io/utils/exec"
+)
+
+const crictl = "crictl"
+
+// sandboxIDRegex matches the lines of the sandbox IDs in the output, which also has the warnings crictl writes to stderr.
+var sandboxIDRegex = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// Client runs crictl against the CRI runtime endpoint, or the endpoint crictl is configured with if it's empty.
+type Client struct {
+	exec            utilexec.Interface
+	runtimeEndpoint string
+}
+
+// New creates a Client running crictl against the runtime endpoint, e.g. unix:///run/containerd/containerd.sock.
+func New(exec utilexec.Interface, runtimeEndpoint string) *Client {
+	return &Client{exec: exec, runtimeEndpoint: runtimeEndpoint}
+}
+
+// PodSandboxIDs returns the IDs of the pod sandboxes of the runtime, ready or not, which are the container IDs the CNI
+// is invoked with. A sandbox which isn't ready still owns its IPs until the CNI DEL.
+func (c *Client) PodSandboxIDs(ctx context.Context) (map[string]struct{}, error) {
+	var args []string
+	if c.runtimeEndpoint != "" {
+		args = append(args, "--runtime-endpoint", c.runtimeEndpoint)
+	}
+	args = append(args, "pods", "--quiet")
+
+	output, err := c.exec.CommandContext(ctx, crictl, args...).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pod sandboxes, output: %s", output)
+	}
+	ids := make(map[string]struct{})
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); sandboxIDRegex.MatchString(line) {
+			ids[line] = struct{}{}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,29 @@
+package crictl
+
+import (
+	"context"
+	"testing"
+
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodSandboxIDs(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"crictl", "pods", "--quiet"}, Stdout: "WARN[0000] runtime connect using default endpoints\n3f813b029429b4e4\n6e688597eafb97c8\n"},
+		{Cmd: []string{"crictl", "--runtime-endpoint", "unix:///run/containerd/containerd.sock", "pods", "--quiet"}, Stdout: ""},
+		{Cmd: []string{"crictl", "pods", "--quiet"}, ExitCode: 1},
+	}
+	fakeexec := testutils.GetFakeExecWithScripts(calls)
+
+	ids, err := New(fakeexec, "").PodSandboxIDs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"3f813b029429b4e4": {}, "6e688597eafb97c8": {}}, ids)
+
+	ids, err = New(fakeexec, "unix:///run/containerd/containerd.sock").PodSandboxIDs(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	_, err = New(fakeexec, "").PodSandboxIDs(context.Background())
+	require.Error(t, err)
+}
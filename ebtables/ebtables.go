@@ -4,6 +4,8 @@
 package ebtables
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strings"
@@ -30,6 +32,12 @@ const (
 	// Ebtable Targets
 	Accept         = "ACCEPT"
 	RedirectAccept = "redirect --redirect-target ACCEPT"
+	// EndpointChainPrefix prefixes the nat chains holding the rules of an endpoint. ebtables rules can't carry
+	// comments, so the chain marks its rules as owned by the endpoint: they are deleted with the chain, and the
+	// chains of deleted endpoints can be swept without touching rules created by other agents.
+	EndpointChainPrefix = "AZEP-"
+	// endpointChainHashLen keeps the chain names within the ebtables limit of 31 characters.
+	endpointChainHashLen = 16
 )
 
 // SetSnatForInterface sets a MAC SNAT rule for an interface.
@@ -44,8 +52,12 @@ func SetSnatForInterface(interfaceName string, macAddress net.HardwareAddr, acti
 
 // SetArpReply sets an ARP reply rule for the given target IP address and MAC address.
 func SetArpReply(ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
+	return SetArpReplyInChain(PreRouting, ipAddress, macAddress, action)
+}
+
+// SetArpReplyInChain sets an ARP reply rule for the given target IP address and MAC address in a nat chain.
+func SetArpReplyInChain(chain string, ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
 	table := Nat
-	chain := PreRouting
 	rule := fmt.Sprintf("-p ARP --arp-op Request --arp-ip-dst %s -j arpreply --arpreply-mac %s --arpreply-target DROP",
 		ipAddress, macAddress.String())
 
@@ -92,6 +104,11 @@ func SetVepaMode(bridgeName string, downstreamIfNamePrefix string, upstreamMacAd
 
 // SetDnatForIPAddress sets a MAC DNAT rule for an IP address.
 func SetDnatForIPAddress(interfaceName string, ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
+	return SetDnatForIPAddressInChain(PreRouting, interfaceName, ipAddress, macAddress, action)
+}
+
+// SetDnatForIPAddressInChain sets a MAC DNAT rule for an IP address in a nat chain.
+func SetDnatForIPAddressInChain(chain, interfaceName string, ipAddress net.IP, macAddress net.HardwareAddr, action string) error {
 	protocol := "IPv4"
 	dst := "--ip-dst"
 	if ipAddress.To4() == nil {
@@ -100,7 +117,6 @@ func SetDnatForIPAddress(interfaceName string, ipAddress net.IP, macAddress net.
 	}
 
 	table := Nat
-	chain := PreRouting
 	rule := fmt.Sprintf("-p %s -i %s %s %s -j dnat --to-dst %s --dnat-target ACCEPT",
 		protocol, interfaceName, dst, ipAddress.String(), macAddress.String())
 
@@ -208,6 +224,108 @@ func SetArpDropRuleForIpCidr(ipCidr string, ifName string) error {
 	return runEbCmd(Nat, Append, PostRouting, rule)
 }
 
+// EndpointChain returns the name of the nat chain holding the rules of the endpoint.
+func EndpointChain(endpointID string) string {
+	hash := sha256.Sum256([]byte(endpointID))
+	return EndpointChainPrefix + hex.EncodeToString(hash[:])[:endpointChainHashLen]
+}
+
+// AddEndpointChain creates the nat chain of an endpoint and jumps to it from PREROUTING. Frames which don't match
+// any rule of the chain return to PREROUTING.
+func AddEndpointChain(chain string) error {
+	if err := runEbCmd(Nat, "-N", chain, "-P RETURN"); err != nil {
+		return err
+	}
+
+	return runEbCmd(Nat, Append, PreRouting, "-j "+chain)
+}
+
+// DeleteEndpointChain deletes the nat chain of an endpoint with its rules. The jump to the chain may be missing
+// if adding the chain failed halfway, so only a failure to delete the chain itself is returned.
+func DeleteEndpointChain(chain string) error {
+	//nolint:errcheck // the chain can't be deleted below if the jump still exists
+	runEbCmd(Nat, Delete, PreRouting, "-j "+chain)
+
+	if err := runEbCmd(Nat, "-F", chain, ""); err != nil {
+		return err
+	}
+
+	return runEbCmd(Nat, "-X", chain, "")
+}
+
+// GetEndpointChains gets the nat chains of endpoints.
+func GetEndpointChains() ([]string, error) {
+	p := platform.NewExecClient()
+	out, err := p.ExecuteCommand(fmt.Sprintf("ebtables -t %s -L", Nat))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEndpointChains(out), nil
+}
+
+// EndpointChainOfJump returns the endpoint chain which the rule jumps to, if any.
+func EndpointChainOfJump(rule string) (string, bool) {
+	chain := strings.TrimPrefix(rule, "-j ")
+	if chain == rule || !strings.HasPrefix(chain, EndpointChainPrefix) || strings.Contains(chain, " ") {
+		return "", false
+	}
+	return chain, true
+}
+
+// GetArpReplies gets the IP and MAC addresses of the ARP reply rules in a nat chain.
+func GetArpReplies(chain string) (map[string]net.HardwareAddr, error) {
+	rules, err := GetEbtableRules(Nat, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[string]net.HardwareAddr)
+	for _, rule := range rules {
+		if ip, mac, ok := parseArpReply(rule); ok {
+			replies[ip.String()] = mac
+		}
+	}
+
+	return replies, nil
+}
+
+// parseEndpointChains finds the endpoint chains in the output of ebtables -L.
+func parseEndpointChains(out string) []string {
+	var chains []string
+	for _, line := range strings.Split(out, "\n") {
+		name, ok := strings.CutPrefix(line, "Bridge chain: ")
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, ",")
+		if strings.HasPrefix(name, EndpointChainPrefix) {
+			chains = append(chains, name)
+		}
+	}
+
+	return chains
+}
+
+// parseArpReply gets the IP and MAC addresses of an ARP reply rule.
+func parseArpReply(rule string) (net.IP, net.HardwareAddr, bool) {
+	var (
+		ip  net.IP
+		mac net.HardwareAddr
+	)
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		switch fields[i] {
+		case "--arp-ip-dst":
+			ip = net.ParseIP(fields[i+1])
+		case "--arpreply-mac":
+			mac, _ = net.ParseMAC(fields[i+1])
+		}
+	}
+
+	return ip, mac, ip != nil && mac != nil
+}
+
 // EbTableRuleExists checks if eb rule exists in table and chain.
 func EbTableRuleExists(tableName, chainName, matchSet string) (bool, error) {
 	rules, err := GetEbtableRules(tableName, chainName)
@@ -0,0 +1,52 @@
+package ebtables
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointChain(t *testing.T) {
+	chain := EndpointChain("a1b2c3d4-eth0")
+	require.Len(t, chain, len(EndpointChainPrefix)+endpointChainHashLen)
+	require.LessOrEqual(t, len(chain), 31)
+	require.Equal(t, chain, EndpointChain("a1b2c3d4-eth0"))
+	require.NotEqual(t, chain, EndpointChain("a1b2c3d4-eth1"))
+
+	got, ok := EndpointChainOfJump("-j " + chain)
+	require.True(t, ok)
+	require.Equal(t, chain, got)
+
+	_, ok = EndpointChainOfJump("-j OTHER-CHAIN")
+	require.False(t, ok)
+	_, ok = EndpointChainOfJump("-p IPv4 -i eth0 --ip-dst 10.240.0.6 -j dnat --to-dst cc:ad:1d:4e:e5:f1 --dnat-target ACCEPT")
+	require.False(t, ok)
+}
+
+func TestParseEndpointChains(t *testing.T) {
+	out := `Bridge table: nat
+
+Bridge chain: PREROUTING, entries: 2, policy: ACCEPT
+-p ARP -i eth0 --arp-op Reply -j dnat --to-dst ff:ff:ff:ff:ff:ff --dnat-target ACCEPT
+-j AZEP-0123456789abcdef
+
+Bridge chain: OUTPUT, entries: 0, policy: ACCEPT
+
+Bridge chain: AZEP-0123456789abcdef, entries: 1, policy: RETURN
+-p ARP --arp-op Request --arp-ip-dst 10.240.0.6 -j arpreply --arpreply-mac cc:ad:1d:4e:e5:f1
+
+Bridge chain: OTHER-AGENT, entries: 0, policy: RETURN
+`
+	require.Equal(t, []string{"AZEP-0123456789abcdef"}, parseEndpointChains(out))
+}
+
+func TestParseArpReply(t *testing.T) {
+	ip, mac, ok := parseArpReply("-p ARP --arp-op Request --arp-ip-dst 10.240.0.6 -j arpreply --arpreply-mac cc:ad:1d:4e:e5:f1")
+	require.True(t, ok)
+	require.True(t, ip.Equal(net.ParseIP("10.240.0.6")))
+	require.Equal(t, "cc:ad:1d:4e:e5:f1", mac.String())
+
+	_, _, ok = parseArpReply("-p IPv4 -i eth0 --ip-dst 10.240.0.6 -j dnat --to-dst cc:ad:1d:4e:e5:f1 --dnat-target ACCEPT")
+	require.False(t, ok)
+}
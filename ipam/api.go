@@ -5,6 +5,7 @@ package ipam
 
 import (
 	"fmt"
+	"time"
 )
 
 var (
@@ -17,9 +18,9 @@ var (
 	errAddressPoolExists    = fmt.Errorf("Address pool already exists")
 	errAddressPoolNotFound  = fmt.Errorf("Address pool not found")
 	errAddressExists        = fmt.Errorf("Address already exists")
-	errAddressNotFound      = fmt.Errorf("Address not found")
-	errAddressInUse         = fmt.Errorf("Address already in use")
 	errNoAvailableAddresses = fmt.Errorf("No available addresses")
+	// errNotRefreshed is returned by the sources which skip a refresh because they were queried recently.
+	errNotRefreshed = fmt.Errorf("Address source not refreshed")
 
 	// Options used by AddressManager.
 	OptInterfaceName      = "azure.interface.name"
@@ -29,4 +30,28 @@ var (
 )
 
 // Exportable errors returned by AddressManager
-var ErrNoAvailableAddressPools = fmt.Errorf("no available address pools")
+var (
+	ErrNoAvailableAddressPools = fmt.Errorf("no available address pools")
+	// ErrAddressNotFound and ErrAddressInUse are returned when a specific address is requested.
+	ErrAddressNotFound = fmt.Errorf("Address not found")
+	ErrAddressInUse    = fmt.Errorf("Address already in use")
+)
+
+// StaleAddress is an address in use by a container which the address source no longer assigns to the node,
+// e.g. a secondary IP removed at the Azure control plane. It isn't allocated again once it's released.
+type StaleAddress struct {
+	AddressSpace string
+	Pool         string
+	Address      string
+	ContainerID  string `json:",omitempty"`
+}
+
+// LeakedAddress is an address in use by a container which the container runtime no longer runs, e.g. because its CNI
+// DEL never completed. It's allocated to no other container until it's released.
+type LeakedAddress struct {
+	AddressSpace string
+	Pool         string
+	Address      string
+	ContainerID  string
+	AllocatedAt  time.Time
+}
@@ -4,10 +4,9 @@
 package ipam
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
 	"net"
-	"net/http"
 	"strings"
 	"time"
 
@@ -24,6 +23,8 @@ const (
 	httpConnectionTimeout = 10
 	// http response header timeout
 	responseHeaderTimeout = 10
+	// timeout for a query including retries
+	azureQueryTimeout = 30 * time.Second
 )
 
 // Microsoft Azure IPAM configuration source.
@@ -33,6 +34,7 @@ type azureSource struct {
 	queryUrl      string
 	queryInterval time.Duration
 	lastRefresh   time.Time
+	infoClient    *common.InterfaceInfoClient
 }
 
 // Creates the Azure source.
@@ -48,13 +50,43 @@ func newAzureSource(options map[string]interface{}) (*azureSource, error) {
 		queryInterval = azureQueryInterval
 	}
 
+	urls := append([]string{queryUrl}, failoverURLs(options)...)
+
+	httpClient := common.InitHttpClient(httpConnectionTimeout, responseHeaderTimeout)
+	if httpClient == nil {
+		log.Errorf("[ipam] Failed intializing http client")
+		return nil, fmt.Errorf("Error intializing http client")
+	}
+
+	getters, err := common.NewInterfaceInfoGetters(httpClient.Transport, urls...)
+	if err != nil {
+		log.Errorf("[ipam] Failed to create the NMAgent clients: %v", err)
+		return nil, err
+	}
+
+	cacheFile, _ := options[common.OptIpamQueryCacheFile].(string)
+
 	return &azureSource{
 		name:          "Azure",
 		queryUrl:      queryUrl,
 		queryInterval: queryInterval,
+		infoClient:    common.NewInterfaceInfoClient(cacheFile, queryInterval, getters...),
 	}, nil
 }
 
+// failoverURLs returns the URLs queried in order when the query URL fails.
+func failoverURLs(options map[string]interface{}) []string {
+	switch urls := options[common.OptIpamQueryFailoverUrls].(type) {
+	case []string:
+		return urls
+	case string:
+		if urls != "" {
+			return strings.Split(urls, ",")
+		}
+	}
+	return nil
+}
+
 // Starts the Azure source.
 func (s *azureSource) start(sink addressConfigSink) error {
 	s.sink = sink
@@ -70,9 +102,20 @@ func (s *azureSource) stop() {
 func (s *azureSource) refresh() error {
 	// Refresh only if enough time has passed since the last query.
 	if time.Since(s.lastRefresh) < s.queryInterval {
-		return nil
+		return errNotRefreshed
 	}
 	s.lastRefresh = time.Now()
+	return s.refreshFrom(s.infoClient.Get)
+}
+
+// Resyncs configuration with the interface info queried again, even if the cached one is fresh.
+func (s *azureSource) resync() error {
+	s.lastRefresh = time.Now()
+	return s.refreshFrom(s.infoClient.Refresh)
+}
+
+// refreshFrom configures the local address space from the interface info returned by get.
+func (s *azureSource) refreshFrom(get func(context.Context) (*common.XmlDocument, error)) error {
 
 	// Query the list of local interfaces.
 	interfaces, err := net.Interfaces()
@@ -86,35 +129,16 @@ func (s *azureSource) refresh() error {
 		return err
 	}
 
-	httpClient := common.InitHttpClient(httpConnectionTimeout, responseHeaderTimeout)
-	if httpClient == nil {
-		log.Errorf("[ipam] Failed intializing http client")
-		return fmt.Errorf("Error intializing http client")
-	}
-
 	log.Printf("[ipam] Wireserver call %v to retrieve IP List", s.queryUrl)
 	// Fetch configuration.
-	resp, err := httpClient.Get(s.queryUrl)
+	ctx, cancel := context.WithTimeout(context.Background(), azureQueryTimeout)
+	defer cancel()
+	doc, err := get(ctx)
 	if err != nil {
 		log.Printf("[ipam] wireserver call failed with: %v", err)
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Errorf("[ipam] http return error code for wireserver call %+v", resp)
-		return fmt.Errorf("wireserver http error %+v", resp)
-	}
-
-	// Decode XML document.
-	var doc common.XmlDocument
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&doc)
-	if err != nil {
-		return err
-	}
-
 	// For each interface...
 	for _, i := range doc.Interface {
 		ifName := ""
@@ -119,11 +119,11 @@ var _ = Describe("Test azure source", func() {
 		})
 
 		Context("When refresh interval is too short", func() {
-			It("Skip refresh and return nil", func() {
+			It("Skip refresh and return errNotRefreshed", func() {
 				source.lastRefresh = time.Now()
 				source.queryInterval = time.Hour
 				err = source.refresh()
-				Expect(err).To(BeNil())
+				Expect(err).To(Equal(errNotRefreshed))
 				source.queryInterval = time.Nanosecond
 			})
 		})
@@ -170,6 +170,27 @@ var _ = Describe("Test azure source", func() {
 			})
 		})
 
+		Context("When the query URL fails", func() {
+			It("Should query the failover URLs", func() {
+				options := make(map[string]interface{})
+				options[common.OptEnvironment] = common.OptEnvironmentAzure
+				options[common.OptAPIServerURL] = "null"
+				options[common.OptIpamQueryUrl] = "http://localhost:1"
+				options[common.OptIpamQueryFailoverUrls] = []string{"http://" + ipamQueryUrl}
+
+				am, err := createAddressManager(options)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = am.(*addressManager).source.refresh()
+				Expect(err).ToNot(HaveOccurred())
+
+				as, ok := am.(*addressManager).AddrSpaces["local"]
+				Expect(ok).To(BeTrue())
+				_, ok = as.Pools["10.0.0.0/16"]
+				Expect(ok).To(BeTrue())
+			})
+		})
+
 		Context("When create new azure source with options", func() {
 			It("Should return with default queryInterval", func() {
 				options := make(map[string]interface{})
@@ -0,0 +1,182 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// IMDS network metadata URL to query.
+	imdsQueryURL = "http://169.254.169.254/metadata/instance/network?api-version=2021-02-01"
+	// timeout for an IMDS query.
+	imdsQueryTimeout = 10 * time.Second
+)
+
+// Azure Instance Metadata Service IPAM configuration source.
+type imdsSource struct {
+	name          string
+	sink          addressConfigSink
+	queryURL      string
+	queryInterval time.Duration
+	lastRefresh   time.Time
+	httpClient    *http.Client
+}
+
+// IMDS network metadata JSON object format.
+type imdsNetwork struct {
+	Interface []imdsInterface `json:"interface"`
+}
+
+type imdsInterface struct {
+	MacAddress string `json:"macAddress"`
+	IPv4       struct {
+		IPAddress []struct {
+			PrivateIPAddress string `json:"privateIpAddress"`
+		} `json:"ipAddress"`
+		Subnet []struct {
+			Address string `json:"address"`
+			Prefix  string `json:"prefix"`
+		} `json:"subnet"`
+	} `json:"ipv4"`
+}
+
+// Creates the IMDS source.
+func newIMDSSource(options map[string]interface{}) (*imdsSource, error) {
+	i, _ := options[common.OptIpamQueryInterval].(int)
+	queryInterval := time.Duration(i) * time.Second
+	if queryInterval == 0 {
+		queryInterval = azureQueryInterval
+	}
+
+	httpClient := common.InitHttpClient(httpConnectionTimeout, responseHeaderTimeout)
+	if httpClient == nil {
+		log.Errorf("[ipam] Failed intializing http client")
+		return nil, fmt.Errorf("Error intializing http client")
+	}
+
+	name, _ := options[common.OptEnvironment].(string)
+	return &imdsSource{
+		name:          name,
+		queryURL:      imdsQueryURL,
+		queryInterval: queryInterval,
+		httpClient:    httpClient,
+	}, nil
+}
+
+// Starts the IMDS source.
+func (s *imdsSource) start(sink addressConfigSink) error {
+	s.sink = sink
+	return nil
+}
+
+// Stops the IMDS source.
+func (s *imdsSource) stop() {
+	s.sink = nil
+}
+
+// Refreshes configuration.
+func (s *imdsSource) refresh() error {
+	// Refresh only if enough time has passed since the last query.
+	if time.Since(s.lastRefresh) < s.queryInterval {
+		return errNotRefreshed
+	}
+	s.lastRefresh = time.Now()
+
+	localInterfaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), imdsQueryTimeout)
+	defer cancel()
+	network, err := s.query(ctx)
+	if err != nil {
+		log.Printf("[ipam] IMDS call failed with: %v", err)
+		return err
+	}
+
+	local, err := s.sink.newAddressSpace(LocalDefaultAddressSpaceId, LocalScope)
+	if err != nil {
+		return err
+	}
+
+	if err = populateAddressSpace(local, network.toNetworkInterfaces(), localInterfaces); err != nil {
+		return err
+	}
+
+	// Set the local address space as active.
+	return s.sink.setAddressSpace(local)
+}
+
+func (s *imdsSource) query(ctx context.Context) (*imdsNetwork, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.queryURL, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IMDS http error %d", resp.StatusCode) //nolint:goerr113 // one-off
+	}
+
+	network := &imdsNetwork{}
+	if err := json.NewDecoder(resp.Body).Decode(network); err != nil {
+		return nil, errors.Wrap(err, "failed to decode IMDS network metadata")
+	}
+
+	return network, nil
+}
+
+// toNetworkInterfaces converts the IMDS metadata to the file IPAM format.
+// IMDS lists the primary interface first, and the primary address of each interface first.
+func (n *imdsNetwork) toNetworkInterfaces() *NetworkInterfaces {
+	interfaces := &NetworkInterfaces{}
+	for i, imdsIf := range n.Interface {
+		iface := Interface{
+			MacAddress: imdsIf.MacAddress,
+			IsPrimary:  i == 0,
+		}
+
+		for _, imdsSubnet := range imdsIf.IPv4.Subnet {
+			prefix := imdsSubnet.Address + "/" + imdsSubnet.Prefix
+			_, subnet, err := net.ParseCIDR(prefix)
+			if err != nil {
+				log.Printf("[ipam] Failed to parse IMDS subnet:%v err:%v.", prefix, err)
+				continue
+			}
+
+			ipSubnet := IPSubnet{Prefix: prefix}
+			for j, imdsAddr := range imdsIf.IPv4.IPAddress {
+				if ip := net.ParseIP(imdsAddr.PrivateIPAddress); ip == nil || !subnet.Contains(ip) {
+					continue
+				}
+				ipSubnet.IPAddresses = append(ipSubnet.IPAddresses, IPAddress{
+					Address:   imdsAddr.PrivateIPAddress,
+					IsPrimary: j == 0,
+				})
+			}
+			iface.IPSubnets = append(iface.IPSubnets, ipSubnet)
+		}
+
+		interfaces.Interfaces = append(interfaces.Interfaces, iface)
+	}
+
+	return interfaces
+}
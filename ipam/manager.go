@@ -4,6 +4,8 @@
 package ipam
 
 import (
+	"errors"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +18,8 @@ import (
 const (
 	// IPAM store key.
 	storeKey = "IPAM"
+	// Default number of address requests between the resyncs of the address source.
+	defaultResyncAddInterval = 10
 )
 
 // AddressManager manages the set of address spaces and pools allocated to containers.
@@ -23,9 +27,14 @@ type addressManager struct {
 	Version    string
 	TimeStamp  time.Time
 	AddrSpaces map[string]*addressSpace `json:"AddressSpaces"`
-	store      store.KeyValueStore
-	source     addressConfigSource
-	netApi     common.NetApi
+	// AddsSinceResync is the number of address requests since the address source was last resynced.
+	AddsSinceResync int `json:",omitempty"`
+	store           store.KeyValueStore
+	source          addressConfigSource
+	netApi          common.NetApi
+	resyncInterval  int
+	// newStaleAddrs are the addresses which became stale since the address manager was initialized.
+	newStaleAddrs []StaleAddress
 	sync.Mutex
 }
 
@@ -45,15 +54,29 @@ type AddressManager interface {
 
 	RequestAddress(asId, poolId, address string, options map[string]string) (string, error)
 	ReleaseAddress(asId, poolId, address string, options map[string]string) error
+
+	// StaleAddresses returns the addresses in use which the address source no longer assigns to the node.
+	StaleAddresses() []StaleAddress
+	// NewStaleAddresses returns the addresses which became stale since the address manager was initialized.
+	NewStaleAddresses() []StaleAddress
+	// ReclaimLeakedAddresses returns the addresses in use by containers which aren't live, allocated more than
+	// gracePeriod ago, and releases them unless dryRun is set.
+	ReclaimLeakedAddresses(liveContainerIDs map[string]struct{}, gracePeriod time.Duration, dryRun bool) ([]LeakedAddress, error)
 }
 
 // AddressConfigSource configures the address pools managed by AddressManager.
+// Sources are created by name from sourceFactories, and may be chained to fall back in order.
 type addressConfigSource interface {
 	start(sink addressConfigSink) error
 	stop()
 	refresh() error
 }
 
+// addressConfigResyncer is implemented by the sources which cache their configuration, to bypass the cache.
+type addressConfigResyncer interface {
+	resync() error
+}
+
 // AddressConfigSink interface is used by AddressConfigSources to configure address pools.
 type addressConfigSink interface {
 	newAddressSpace(id string, scope int) (*addressSpace, error)
@@ -185,33 +208,29 @@ func (am *addressManager) StartSource(options map[string]interface{}) error {
 	var isLoaded bool
 	environment, _ := options[common.OptEnvironment].(string)
 
+	am.resyncInterval = defaultResyncAddInterval
+	if i, ok := options[common.OptIpamResyncAddInterval].(int); ok && i != 0 {
+		am.resyncInterval = i
+	}
+
 	if am.AddrSpaces != nil && len(am.AddrSpaces) > 0 &&
 		am.AddrSpaces[LocalDefaultAddressSpaceId] != nil &&
 		len(am.AddrSpaces[LocalDefaultAddressSpaceId].Pools) > 0 {
 		isLoaded = true
 	}
 
-	switch environment {
-	case common.OptEnvironmentAzure:
-		am.source, err = newAzureSource(options)
-
-	case common.OptEnvironmentMAS:
-		am.source, err = newFileIpamSource(options)
-
-	case common.OptEnvironmentFileIpam:
-		am.source, err = newFileIpamSource(options)
+	names := sourceNames(options)
+	switch {
+	case len(names) > 1:
+		environment = strings.Join(names, ",")
+		am.source, err = wrapSource(newChainSource(names, options, isLoaded))
 
-	case common.OptEnvironmentIPv6NodeIpam:
-		am.source, err = newIPv6IpamSource(options, isLoaded)
-
-	case "null":
-		am.source, err = newNullSource()
-
-	case "":
+	case names[0] == "":
 		am.source = nil
 
 	default:
-		return errInvalidConfiguration
+		environment = names[0]
+		am.source, err = newSource(environment, options, isLoaded)
 	}
 
 	if am.source != nil {
@@ -239,12 +258,24 @@ func (am *addressManager) refreshSource() {
 	if am.source != nil {
 		log.Printf("[ipam] Refreshing address source.")
 		err := am.source.refresh()
-		if err != nil {
+		if err != nil && !errors.Is(err, errNotRefreshed) {
 			log.Printf("[ipam] Source refresh failed, err:%v.\n", err)
 		}
 	}
 }
 
+// Resyncs the configuration source bypassing its cache.
+func (am *addressManager) resyncSource() {
+	if am.source != nil {
+		log.Printf("[ipam] Resyncing address source after %d address requests.", am.AddsSinceResync)
+		if err := resyncSource(am.source); err != nil && !errors.Is(err, errNotRefreshed) {
+			log.Printf("[ipam] Source resync failed, err:%v.\n", err)
+			return
+		}
+		am.AddsSinceResync = 0
+	}
+}
+
 //
 // AddressManager API
 //
@@ -346,7 +377,13 @@ func (am *addressManager) RequestAddress(asId, poolId, address string, options m
 	am.Lock()
 	defer am.Unlock()
 
-	am.refreshSource()
+	// periodically query the source again, in case addresses were removed while its cached configuration was fresh
+	am.AddsSinceResync++
+	if am.resyncInterval > 0 && am.AddsSinceResync >= am.resyncInterval {
+		am.resyncSource()
+	} else {
+		am.refreshSource()
+	}
 
 	as, err := am.getAddressSpace(asId)
 	if err != nil {
@@ -401,3 +438,84 @@ func (am *addressManager) ReleaseAddress(asId string, poolId string, address str
 
 	return nil
 }
+
+// StaleAddresses returns the addresses in use which the address source no longer assigns to the node.
+func (am *addressManager) StaleAddresses() []StaleAddress {
+	am.Lock()
+	defer am.Unlock()
+
+	var stale []StaleAddress
+	for asID, as := range am.AddrSpaces {
+		for poolID, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				if ar.Stale {
+					stale = append(stale, StaleAddress{AddressSpace: asID, Pool: poolID, Address: ar.Addr.String(), ContainerID: ar.ID})
+				}
+			}
+		}
+	}
+	return stale
+}
+
+// NewStaleAddresses returns the addresses which became stale since the address manager was initialized.
+func (am *addressManager) NewStaleAddresses() []StaleAddress {
+	am.Lock()
+	defer am.Unlock()
+	return am.newStaleAddrs
+}
+
+// ReclaimLeakedAddresses returns the addresses in use by containers which aren't in liveContainerIDs, e.g. the pod
+// sandboxes of the container runtime, and were allocated more than gracePeriod ago, so that the containers created
+// while the live containers were listed are never reported. The leaked addresses are released unless dryRun is set.
+// The addresses in use without a container ID are skipped, and the ones allocated by older versions, which didn't
+// record the allocation time, start their grace period now.
+func (am *addressManager) ReclaimLeakedAddresses(liveContainerIDs map[string]struct{}, gracePeriod time.Duration, dryRun bool) ([]LeakedAddress, error) {
+	am.Lock()
+	defer am.Unlock()
+
+	now := time.Now()
+	modified := false
+	var leaked []LeakedAddress
+	var pools []*addressPool
+	for asID, as := range am.AddrSpaces {
+		for poolID, ap := range as.Pools {
+			for _, ar := range ap.Addresses {
+				if !ar.InUse || ar.ID == "" {
+					continue
+				}
+				if _, ok := liveContainerIDs[ar.ID]; ok {
+					continue
+				}
+				if ar.AllocatedAt.IsZero() {
+					ar.AllocatedAt = now
+					modified = true
+					continue
+				}
+				if now.Sub(ar.AllocatedAt) < gracePeriod {
+					continue
+				}
+				leaked = append(leaked, LeakedAddress{
+					AddressSpace: asID, Pool: poolID, Address: ar.Addr.String(), ContainerID: ar.ID, AllocatedAt: ar.AllocatedAt,
+				})
+				pools = append(pools, ap)
+			}
+		}
+	}
+
+	if !dryRun {
+		for i := range leaked {
+			log.Printf("[ipam] Reclaiming address %s leaked by container %s", leaked[i].Address, leaked[i].ContainerID)
+			if err := pools[i].releaseAddress(leaked[i].Address, map[string]string{OptAddressID: leaked[i].ContainerID}); err != nil {
+				return leaked, err
+			}
+			modified = true
+		}
+	}
+
+	if modified {
+		if err := am.save(); err != nil {
+			return leaked, err
+		}
+	}
+	return leaked, nil
+}
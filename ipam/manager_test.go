@@ -361,6 +361,46 @@ var _ = Describe("Test Manager", func() {
 		})
 	})
 
+	Describe("Test ReclaimLeakedAddresses", func() {
+		Context("When the containers of addresses in use aren't live", func() {
+			It("Should report them after the grace period and release them unless dry run", func() {
+				am, err := createAddressManager(nil)
+				Expect(err).NotTo(HaveOccurred())
+				poolID := subnet1.String()
+				ap := am.(*addressManager).AddrSpaces[LocalDefaultAddressSpaceId].Pools[poolID]
+				for addr, id := range map[string]string{addr11.String(): "live", addr12.String(): "dead", addr13.String(): "old"} {
+					_, err = am.RequestAddress(LocalDefaultAddressSpaceId, poolID, addr, map[string]string{OptAddressID: id})
+					Expect(err).NotTo(HaveOccurred())
+				}
+				// allocated by a version which didn't record the allocation time
+				ap.Addresses[addr13.String()].AllocatedAt = time.Time{}
+				live := map[string]struct{}{"live": {}}
+
+				leaked, err := am.ReclaimLeakedAddresses(live, time.Hour, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaked).To(BeEmpty())
+				Expect(ap.Addresses[addr13.String()].AllocatedAt.IsZero()).To(BeFalse())
+
+				allocatedAt := time.Now().Add(-2 * time.Hour)
+				ap.Addresses[addr12.String()].AllocatedAt = allocatedAt
+				expected := []LeakedAddress{{
+					AddressSpace: LocalDefaultAddressSpaceId, Pool: poolID, Address: addr12.String(), ContainerID: "dead", AllocatedAt: allocatedAt,
+				}}
+				leaked, err = am.ReclaimLeakedAddresses(live, time.Hour, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaked).To(Equal(expected))
+				Expect(ap.Addresses[addr12.String()].InUse).To(BeTrue())
+
+				leaked, err = am.ReclaimLeakedAddresses(live, time.Hour, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leaked).To(Equal(expected))
+				Expect(ap.Addresses[addr12.String()].InUse).To(BeFalse())
+				Expect(ap.Addresses[addr11.String()].InUse).To(BeTrue())
+				Expect(ap.Addresses[addr13.String()].InUse).To(BeTrue())
+			})
+		})
+	})
+
 	Describe("Test GetDefaultAddressSpaces", func() {
 		Context("When local and global are nil", func() {
 			It("Should return empty string", func() {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/platform"
@@ -77,11 +78,14 @@ type AddressPoolInfo struct {
 
 // Represents an IP address in a pool.
 type addressRecord struct {
-	ID        string
-	Addr      net.IP
-	InUse     bool
-	unhealthy bool
-	epoch     int
+	ID    string
+	Addr  net.IP
+	InUse bool
+	// Stale is set while the address is in use but the source no longer assigns it to the node.
+	Stale bool `json:",omitempty"`
+	// AllocatedAt is when the address was allocated to ID, zero if it isn't in use or was allocated by an older version.
+	AllocatedAt time.Time `json:",omitempty"`
+	epoch       int
 }
 
 //
@@ -178,7 +182,10 @@ func (am *addressManager) setAddressSpace(as *addressSpace) error {
 }
 
 // Merges a new address space to an existing one.
-func (as *addressSpace) merge(newas *addressSpace) {
+// Returns the addresses in use which became stale, since the new address space doesn't have them.
+func (as *addressSpace) merge(newas *addressSpace) []StaleAddress {
+	var stale []StaleAddress
+
 	// The new epoch after the merge.
 	// epoch is essentially the count of invocations
 	// used to ensure if certain addresses refreshed from the source
@@ -209,7 +216,7 @@ func (as *addressSpace) merge(newas *addressSpace) {
 				} else {
 					// This address record already exists.
 					ar.epoch = as.epoch
-					ar.unhealthy = false
+					ar.Stale = false
 				}
 
 				delete(pv.Addresses, ak)
@@ -233,7 +240,10 @@ func (as *addressSpace) merge(newas *addressSpace) {
 				} else if av.InUse {
 					// Address is no longer valid, but still in use.
 					pv.epoch = as.epoch
-					av.unhealthy = true
+					if !av.Stale {
+						av.Stale = true
+						stale = append(stale, StaleAddress{AddressSpace: as.Id, Pool: pk, Address: av.Addr.String(), ContainerID: av.ID})
+					}
 				} else {
 					// This address is no longer available.
 					delete(pv.Addresses, ak)
@@ -247,6 +257,8 @@ func (as *addressSpace) merge(newas *addressSpace) {
 			}
 		}
 	}
+
+	return stale
 }
 
 // Creates a new addressPool object.
@@ -399,7 +411,7 @@ func (ap *addressPool) getInfo() *AddressPoolInfo {
 		if !ar.InUse {
 			available++
 		}
-		if ar.unhealthy {
+		if ar.Stale {
 			unhealthyAddrs = append(unhealthyAddrs, ar.Addr)
 		}
 	}
@@ -467,14 +479,18 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 		// Return the specific address requested.
 		ar = ap.Addresses[address]
 		if ar == nil {
-			log.Printf("[ipam] Address request failed with %v", errAddressNotFound)
-			return "", errAddressNotFound
+			log.Printf("[ipam] Address request failed with %v", ErrAddressNotFound)
+			return "", ErrAddressNotFound
+		}
+		if ar.Stale {
+			log.Printf("[ipam] Address request failed with %v, the address is stale", ErrAddressNotFound)
+			return "", ErrAddressNotFound
 		}
 		if ar.InUse {
 			// Return the same address if IDs match.
 			if id == "" || id != ar.ID {
-				log.Printf("[ipam] Address request failed with %v", errAddressInUse)
-				return "", errAddressInUse
+				log.Printf("[ipam] Address request failed with %v", ErrAddressInUse)
+				return "", ErrAddressInUse
 			}
 		}
 	} else if options[OptAddressType] == OptAddressTypeGateway {
@@ -491,7 +507,7 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 	// If no address was found, return any available address.
 	if ar == nil {
 		for _, ar = range ap.Addresses {
-			if !ar.InUse && ar.ID == "" {
+			if !ar.InUse && ar.ID == "" && !ar.Stale {
 				break
 			}
 			ar = nil
@@ -508,6 +524,9 @@ func (ap *addressPool) requestAddress(address string, options map[string]string)
 		ar.ID = id
 	}
 
+	if !ar.InUse {
+		ar.AllocatedAt = time.Now()
+	}
 	ar.InUse = true
 
 	// Return address in CIDR notation.
@@ -562,6 +581,7 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 	}
 
 	ar.InUse = false
+	ar.AllocatedAt = time.Time{}
 
 	if id != "" && ar.ID == id {
 		delete(ap.addrsByID, ar.ID)
@@ -569,7 +589,7 @@ func (ap *addressPool) releaseAddress(address string, options map[string]string)
 	}
 
 	// Delete address record if it is no longer available.
-	if ar.epoch < ap.as.epoch {
+	if ar.epoch < ap.as.epoch || ar.Stale {
 		log.Printf("Deleting Address record from address pool as metadata doesn't have this address")
 		delete(ap.Addresses, address)
 	}
@@ -269,7 +269,7 @@ var _ = Describe("Test Pool", func() {
 					as:        originAs,
 					Addresses: map[string]*addressRecord{},
 				}
-				pool1.Addresses[arId] = &addressRecord{InUse: true, unhealthy: true}
+				pool1.Addresses[arId] = &addressRecord{InUse: true, Stale: true}
 				originAs.Pools[poolId] = pool1
 
 				newAs := &addressSpace{
@@ -289,7 +289,7 @@ var _ = Describe("Test Pool", func() {
 				ar := pool1.Addresses[arId]
 				Expect(ar.epoch).To(Equal(4))
 				Expect(ar.InUse).To(BeTrue())
-				Expect(ar.unhealthy).To(BeFalse())
+				Expect(ar.Stale).To(BeFalse())
 				Expect(newAs.Pools[poolId]).To(BeNil())
 			})
 		})
@@ -327,7 +327,7 @@ var _ = Describe("Test Pool", func() {
 				ar := pool1.Addresses[arId]
 				Expect(ar.epoch).To(Equal(4))
 				Expect(ar.InUse).To(BeTrue())
-				Expect(ar.unhealthy).To(BeFalse())
+				Expect(ar.Stale).To(BeFalse())
 			})
 		})
 
@@ -350,6 +350,8 @@ var _ = Describe("Test Pool", func() {
 					Addresses: map[string]*addressRecord{},
 				}
 				pool1.Addresses[arId] = &addressRecord{
+					ID:    "container1",
+					Addr:  net.ParseIP("10.0.0.1"),
 					epoch: 3,
 					InUse: true,
 				}
@@ -358,13 +360,18 @@ var _ = Describe("Test Pool", func() {
 					Id:    asId,
 					Pools: map[string]*addressPool{},
 				}
-				originAs.merge(newAs)
+				stale := originAs.merge(newAs)
 				pool1 = originAs.Pools[poolId]
 				Expect(pool1.epoch).To(Equal(4))
 				ar := pool1.Addresses[arId]
 				Expect(ar.epoch).To(Equal(3))
 				Expect(ar.InUse).To(BeTrue())
-				Expect(ar.unhealthy).To(BeTrue())
+				Expect(ar.Stale).To(BeTrue())
+				Expect(stale).To(Equal([]StaleAddress{{AddressSpace: asId, Pool: poolId, Address: "10.0.0.1", ContainerID: "container1"}}))
+
+				// the address is reported stale once
+				Expect(originAs.merge(newAs)).To(BeEmpty())
+				Expect(ar.Stale).To(BeTrue())
 			})
 		})
 
@@ -834,16 +841,16 @@ var _ = Describe("Test Pool", func() {
 					Addresses: map[string]*addressRecord{},
 				}
 				ap.Addresses["10.0.0.1/16"] = &addressRecord{
-					unhealthy: true,
-					Addr:      net.IPv4zero,
+					Stale: true,
+					Addr:  net.IPv4zero,
 				}
 				ap.Addresses["10.0.0.2/16"] = &addressRecord{
-					unhealthy: false,
-					Addr:      net.IPv4zero,
+					Stale: false,
+					Addr:  net.IPv4zero,
 				}
 				ap.Addresses["10.0.0.3/16"] = &addressRecord{
-					unhealthy: true,
-					Addr:      net.IPv4zero,
+					Stale: true,
+					Addr:  net.IPv4zero,
 				}
 				apInfo := ap.getInfo()
 				Expect(len(apInfo.UnhealthyAddrs)).To(Equal(2))
@@ -868,12 +875,12 @@ var _ = Describe("Test Pool", func() {
 
 	Describe("Test requestAddress", func() {
 		Context("When addressRecord not found", func() {
-			It("Should raise errAddressNotFound", func() {
+			It("Should raise ErrAddressNotFound", func() {
 				ap := &addressPool{
 					Addresses: map[string]*addressRecord{},
 				}
 				addr, err := ap.requestAddress("10.0.0.1/16", nil)
-				Expect(err).To(Equal(errAddressNotFound))
+				Expect(err).To(Equal(ErrAddressNotFound))
 				Expect(addr).To(BeEmpty())
 			})
 		})
@@ -936,19 +943,19 @@ var _ = Describe("Test Pool", func() {
 		})
 
 		Context("When addressRecord is in use and id is empty", func() {
-			It("Should raise errAddressInUse", func() {
+			It("Should raise ErrAddressInUse", func() {
 				ap := &addressPool{
 					Addresses: map[string]*addressRecord{},
 				}
 				ap.Addresses["10.0.0.1/16"] = &addressRecord{InUse: true}
 				addr, err := ap.requestAddress("10.0.0.1/16", nil)
-				Expect(err).To(Equal(errAddressInUse))
+				Expect(err).To(Equal(ErrAddressInUse))
 				Expect(addr).To(BeEmpty())
 			})
 		})
 
 		Context("When addressRecord is in use and id is not equal to the addressRecord's id", func() {
-			It("Should raise errAddressInUse", func() {
+			It("Should raise ErrAddressInUse", func() {
 				ap := &addressPool{
 					Addresses: map[string]*addressRecord{},
 				}
@@ -960,13 +967,13 @@ var _ = Describe("Test Pool", func() {
 				options := map[string]string{}
 				options[OptAddressID] = "10.0.0.2/16"
 				addr, err := ap.requestAddress("10.0.0.1/16", options)
-				Expect(err).To(Equal(errAddressInUse))
+				Expect(err).To(Equal(ErrAddressInUse))
 				Expect(addr).To(BeEmpty())
 			})
 		})
 
 		Context("When OptAddressType is OptAddressTypeGateway", func() {
-			It("Should raise errAddressInUse", func() {
+			It("Should raise ErrAddressInUse", func() {
 				ap := &addressPool{
 					Gateway: net.IPv4(10, 0, 0, 1),
 				}
@@ -0,0 +1,176 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// sourceFactory creates an address source from the plugin options.
+// isLoaded is true if the address manager already has pools from a previous run.
+type sourceFactory func(options map[string]interface{}, isLoaded bool) (addressConfigSource, error)
+
+// sourceFactories maps the environment names accepted in the plugin options to their address sources.
+// New environments add a source here without changes to the address manager.
+// There is no CNS source: the IPs CNS manages are allocated through the CNS IPAM plugin, so that CNS tracks them.
+var sourceFactories = map[string]sourceFactory{
+	common.OptEnvironmentAzure: func(options map[string]interface{}, _ bool) (addressConfigSource, error) {
+		return wrapSource(newAzureSource(options))
+	},
+	common.OptEnvironmentMAS: func(options map[string]interface{}, _ bool) (addressConfigSource, error) {
+		return wrapSource(newFileIpamSource(options))
+	},
+	common.OptEnvironmentFileIpam: func(options map[string]interface{}, _ bool) (addressConfigSource, error) {
+		return wrapSource(newFileIpamSource(options))
+	},
+	common.OptEnvironmentIPv6NodeIpam: func(options map[string]interface{}, isLoaded bool) (addressConfigSource, error) {
+		return wrapSource(newIPv6IpamSource(options, isLoaded))
+	},
+	common.OptEnvironmentIMDS: func(options map[string]interface{}, _ bool) (addressConfigSource, error) {
+		return wrapSource(newIMDSSource(options))
+	},
+	"null": func(map[string]interface{}, bool) (addressConfigSource, error) {
+		return wrapSource(newNullSource())
+	},
+}
+
+// wrapSource converts a source constructor result to the interface, so that a
+// failed constructor doesn't return a non-nil interface holding a nil pointer.
+func wrapSource[T addressConfigSource](source T, err error) (addressConfigSource, error) {
+	if err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// sourceNames returns the names of the sources to chain, in fallback order.
+// OptIpamSources takes precedence over OptEnvironment, and may be a list or a comma separated string.
+func sourceNames(options map[string]interface{}) []string {
+	switch sources := options[common.OptIpamSources].(type) {
+	case []string:
+		if len(sources) > 0 {
+			return sources
+		}
+	case string:
+		if sources != "" {
+			return strings.Split(sources, ",")
+		}
+	}
+
+	environment, _ := options[common.OptEnvironment].(string)
+	return []string{environment}
+}
+
+// newSource creates the source for a single environment.
+func newSource(name string, options map[string]interface{}, isLoaded bool) (addressConfigSource, error) {
+	factory, ok := sourceFactories[name]
+	if !ok {
+		return nil, errInvalidConfiguration
+	}
+
+	// sources read their name from the environment option.
+	sourceOptions := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		sourceOptions[k] = v
+	}
+	sourceOptions[common.OptEnvironment] = name
+
+	return factory(sourceOptions, isLoaded)
+}
+
+// Chains address sources, refreshing from the first source that succeeds.
+type chainSource struct {
+	names   []string
+	sources []addressConfigSource
+	// active is the index of the source which last refreshed successfully, -1 if none did.
+	active int
+}
+
+// Creates a source which falls back to each of the named sources in order.
+func newChainSource(names []string, options map[string]interface{}, isLoaded bool) (*chainSource, error) {
+	s := &chainSource{
+		names:  names,
+		active: -1,
+	}
+
+	for _, name := range names {
+		source, err := newSource(name, options, isLoaded)
+		if err != nil {
+			log.Printf("[ipam] Failed to create source %v, err:%v.", name, err)
+			return nil, err
+		}
+		s.sources = append(s.sources, source)
+	}
+
+	return s, nil
+}
+
+// Starts the chained sources.
+func (s *chainSource) start(sink addressConfigSink) error {
+	for i, source := range s.sources {
+		if err := source.start(sink); err != nil {
+			log.Printf("[ipam] Failed to start source %v, err:%v.", s.names[i], err)
+			return err
+		}
+	}
+	return nil
+}
+
+// Stops the chained sources.
+func (s *chainSource) stop() {
+	for _, source := range s.sources {
+		source.stop()
+	}
+}
+
+// Refreshes configuration from the first source that succeeds.
+func (s *chainSource) refresh() error {
+	return s.refreshFrom("refresh", func(source addressConfigSource) error { return source.refresh() })
+}
+
+// Resyncs configuration from the first source that succeeds, bypassing the caches of the sources which have one.
+func (s *chainSource) resync() error {
+	return s.refreshFrom("resync", resyncSource)
+}
+
+// refreshFrom refreshes configuration from the first source that succeeds with refresh. A source which skips the
+// refresh because it was queried recently only counts as a success if it's the active source, since its previous
+// query may have failed.
+func (s *chainSource) refreshFrom(verb string, refresh func(addressConfigSource) error) error {
+	err := errNotRefreshed
+	for i, source := range s.sources {
+		sourceErr := refresh(source)
+		if errors.Is(sourceErr, errNotRefreshed) {
+			if i == s.active {
+				return nil
+			}
+			continue
+		}
+		if sourceErr != nil {
+			log.Printf("[ipam] Source %v %s failed, err:%v.", s.names[i], verb, sourceErr)
+			err = sourceErr
+			continue
+		}
+
+		if i != s.active {
+			log.Printf("[ipam] Address source %v is active.", s.names[i])
+			s.active = i
+		}
+		return nil
+	}
+
+	return err
+}
+
+// resyncSource resyncs the source if it has a cache to bypass, and refreshes it otherwise.
+func resyncSource(source addressConfigSource) error {
+	if r, ok := source.(addressConfigResyncer); ok {
+		return r.resync()
+	}
+	return source.refresh()
+}
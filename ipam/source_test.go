@@ -0,0 +1,245 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package ipam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+)
+
+var errFakeSource = errors.New("fake source failed")
+
+type fakeSource struct {
+	err       error
+	refreshes int
+}
+
+func (s *fakeSource) start(addressConfigSink) error { return nil }
+func (s *fakeSource) stop()                         {}
+func (s *fakeSource) refresh() error {
+	s.refreshes++
+	return s.err
+}
+
+func TestSourceNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "environment",
+			options: map[string]interface{}{common.OptEnvironment: common.OptEnvironmentAzure},
+			want:    []string{common.OptEnvironmentAzure},
+		},
+		{
+			name: "sources list overrides environment",
+			options: map[string]interface{}{
+				common.OptEnvironment: common.OptEnvironmentAzure,
+				common.OptIpamSources: []string{common.OptEnvironmentIMDS, common.OptEnvironmentAzure},
+			},
+			want: []string{common.OptEnvironmentIMDS, common.OptEnvironmentAzure},
+		},
+		{
+			name: "empty sources list",
+			options: map[string]interface{}{
+				common.OptEnvironment: common.OptEnvironmentAzure,
+				common.OptIpamSources: []string(nil),
+			},
+			want: []string{common.OptEnvironmentAzure},
+		},
+		{
+			name:    "comma separated sources",
+			options: map[string]interface{}{common.OptIpamSources: "imds,fileIpam"},
+			want:    []string{common.OptEnvironmentIMDS, common.OptEnvironmentFileIpam},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceNames(tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sourceNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSourceInvalid(t *testing.T) {
+	if _, err := newSource("invalid", map[string]interface{}{}, false); !errors.Is(err, errInvalidConfiguration) {
+		t.Errorf("newSource() err = %v, want %v", err, errInvalidConfiguration)
+	}
+
+	if _, err := newChainSource([]string{"null", "invalid"}, map[string]interface{}{}, false); !errors.Is(err, errInvalidConfiguration) {
+		t.Errorf("newChainSource() err = %v, want %v", err, errInvalidConfiguration)
+	}
+}
+
+func TestChainSourceFallback(t *testing.T) {
+	primary := &fakeSource{err: errFakeSource}
+	secondary := &fakeSource{}
+	s := &chainSource{
+		names:   []string{"primary", "secondary"},
+		sources: []addressConfigSource{primary, secondary},
+		active:  -1,
+	}
+
+	if err := s.refresh(); err != nil {
+		t.Fatalf("refresh() err = %v", err)
+	}
+	if s.active != 1 {
+		t.Errorf("active = %d, want 1", s.active)
+	}
+
+	// the primary source is preferred again once it recovers.
+	primary.err = nil
+	if err := s.refresh(); err != nil {
+		t.Fatalf("refresh() err = %v", err)
+	}
+	if s.active != 0 || secondary.refreshes != 1 {
+		t.Errorf("active = %d, secondary refreshes = %d, want 0 and 1", s.active, secondary.refreshes)
+	}
+
+	primary.err = errFakeSource
+	secondary.err = errFakeSource
+	if err := s.refresh(); !errors.Is(err, errFakeSource) {
+		t.Errorf("refresh() err = %v, want %v", err, errFakeSource)
+	}
+}
+
+func TestChainSourceSkipsThrottledSource(t *testing.T) {
+	primary := &fakeSource{err: errFakeSource}
+	secondary := &fakeSource{}
+	s := &chainSource{
+		names:   []string{"primary", "secondary"},
+		sources: []addressConfigSource{primary, secondary},
+		active:  -1,
+	}
+	if err := s.refresh(); err != nil {
+		t.Fatalf("refresh() err = %v", err)
+	}
+
+	// the primary source skips the refresh after its failed query, so the chain keeps falling back.
+	primary.err = errNotRefreshed
+	if err := s.refresh(); err != nil {
+		t.Fatalf("refresh() err = %v", err)
+	}
+	if s.active != 1 || secondary.refreshes != 2 {
+		t.Errorf("active = %d, secondary refreshes = %d, want 1 and 2", s.active, secondary.refreshes)
+	}
+
+	// the active source skipping the refresh keeps its pools.
+	secondary.err = errNotRefreshed
+	if err := s.refresh(); err != nil {
+		t.Fatalf("refresh() err = %v", err)
+	}
+	if s.active != 1 {
+		t.Errorf("active = %d, want 1", s.active)
+	}
+}
+
+func TestStartSourceChain(t *testing.T) {
+	am := &addressManager{AddrSpaces: make(map[string]*addressSpace)}
+	options := map[string]interface{}{common.OptIpamSources: []string{"null", common.OptEnvironmentAzure}}
+	if err := am.StartSource(options); err != nil {
+		t.Fatalf("StartSource() err = %v", err)
+	}
+	defer am.StopSource()
+
+	if _, ok := am.source.(*chainSource); !ok {
+		t.Errorf("source = %T, want *chainSource", am.source)
+	}
+}
+
+func TestIMDSSourceQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"interface":[
+			{"macAddress":"000D3A000001","ipv4":{"ipAddress":[{"privateIpAddress":"10.0.0.4"},{"privateIpAddress":"10.0.0.5"}],"subnet":[{"address":"10.0.0.0","prefix":"24"}]}},
+			{"macAddress":"000D3A000002","ipv4":{"ipAddress":[{"privateIpAddress":"10.1.0.4"}],"subnet":[{"address":"10.1.0.0","prefix":"24"}]}}
+		]}`))
+	}))
+	defer srv.Close()
+
+	s, err := newIMDSSource(map[string]interface{}{common.OptEnvironment: common.OptEnvironmentIMDS})
+	if err != nil {
+		t.Fatalf("newIMDSSource() err = %v", err)
+	}
+	s.queryURL = srv.URL
+
+	network, err := s.query(context.Background())
+	if err != nil {
+		t.Fatalf("query() err = %v", err)
+	}
+
+	want := &NetworkInterfaces{
+		Interfaces: []Interface{
+			{
+				MacAddress: "000D3A000001",
+				IsPrimary:  true,
+				IPSubnets: []IPSubnet{{
+					Prefix: "10.0.0.0/24",
+					IPAddresses: []IPAddress{
+						{Address: "10.0.0.4", IsPrimary: true},
+						{Address: "10.0.0.5"},
+					},
+				}},
+			},
+			{
+				MacAddress: "000D3A000002",
+				IPSubnets: []IPSubnet{{
+					Prefix:      "10.1.0.0/24",
+					IPAddresses: []IPAddress{{Address: "10.1.0.4", IsPrimary: true}},
+				}},
+			},
+		},
+	}
+	if got := network.toNetworkInterfaces(); !reflect.DeepEqual(got, want) {
+		t.Errorf("toNetworkInterfaces() = %+v, want %+v", got, want)
+	}
+}
+
+type fakeResyncSource struct {
+	fakeSource
+	resyncs int
+}
+
+func (s *fakeResyncSource) resync() error {
+	s.resyncs++
+	return s.err
+}
+
+func TestRequestAddressResync(t *testing.T) {
+	source := &fakeResyncSource{}
+	am := &addressManager{AddrSpaces: make(map[string]*addressSpace), source: source, resyncInterval: 3}
+
+	for i := 0; i < 2; i++ {
+		_, _ = am.RequestAddress(LocalDefaultAddressSpaceId, "10.0.0.0/16", "", nil)
+	}
+	if source.refreshes != 2 || source.resyncs != 0 {
+		t.Fatalf("refreshes = %d, resyncs = %d, want 2 and 0", source.refreshes, source.resyncs)
+	}
+
+	// a failed resync is retried on the next request
+	source.err = errFakeSource
+	_, _ = am.RequestAddress(LocalDefaultAddressSpaceId, "10.0.0.0/16", "", nil)
+	if source.resyncs != 1 || am.AddsSinceResync != 3 {
+		t.Fatalf("resyncs = %d, adds since resync = %d, want 1 and 3", source.resyncs, am.AddsSinceResync)
+	}
+
+	source.err = nil
+	_, _ = am.RequestAddress(LocalDefaultAddressSpaceId, "10.0.0.0/16", "", nil)
+	if source.resyncs != 2 || am.AddsSinceResync != 0 {
+		t.Errorf("resyncs = %d, adds since resync = %d, want 2 and 0", source.resyncs, am.AddsSinceResync)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"log"
 	"os"
 	"path"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Log level
@@ -42,6 +44,9 @@ const (
 	rotationCheckFrq = 8
 )
 
+// DefaultMaxLogFileSize is the size in bytes at which log files are rotated, unless SetLogFileLimits changed it.
+const DefaultMaxLogFileSize = maxLogFileSize
+
 // Logger object
 type Logger struct {
 	l            *log.Logger
@@ -51,9 +56,16 @@ type Logger struct {
 	target       int
 	maxFileSize  int
 	maxFileCount int
-	callCount    int
-	directory    string
-	mutex        *sync.Mutex
+	maxAge       time.Duration
+	compress     bool
+	maxTotalSize int64
+	// fileStart is the time of the first entry of the log file, once the max age is checked.
+	fileStart time.Time
+	callCount int
+	directory string
+	// correlationID is logged in every entry, once set.
+	correlationID string
+	mutex         *sync.Mutex
 }
 
 var pid = os.Getpid()
@@ -108,6 +120,14 @@ func (logger *Logger) SetLevel(level int) {
 	logger.level = level
 }
 
+// SetCorrelationID sets the ID logged in every entry, to correlate the entries of a single operation, e.g. a CNI
+// invocation, with its requests and telemetry.
+func (logger *Logger) SetCorrelationID(id string) {
+	logger.mutex.Lock()
+	logger.correlationID = id
+	logger.mutex.Unlock()
+}
+
 // SetLogFileLimits sets the log file limits.
 func (logger *Logger) SetLogFileLimits(maxFileSize int, maxFileCount int) {
 	logger.maxFileSize = maxFileSize
@@ -149,8 +169,8 @@ func (logger *Logger) getLogFileName() string {
 	return logFileName
 }
 
-// todo: handle errors and use atomic file rotation
-// Rotate checks the active log file size and rotates log files if necessary.
+// Rotate checks the active log file size and age and rotates log files if necessary.
+// It is called with the mutex held, so it logs through the underlying logger.
 func (logger *Logger) rotate() {
 	// Return if target is not a log file.
 	if (logger.target != TargetLogfile && logger.target != TargetStdOutAndLogFile) || logger.out == nil {
@@ -160,30 +180,23 @@ func (logger *Logger) rotate() {
 	fileName := logger.getLogFileName()
 	fileInfo, err := os.Stat(fileName)
 	if err != nil {
-		logger.Logf("[log] Failed to query log file info %+v.", err)
+		logger.l.Printf("[%v] [log] Failed to query log file info %+v.", pid, err)
 		return
 	}
 
-	// Rotate if size limit is reached.
-	if fileInfo.Size() >= int64(logger.maxFileSize) {
-		logger.out.Close()
-		var fn1, fn2 string
-
-		// Rotate log files, keeping the last maxFileCount files.
-		for n := logger.maxFileCount - 1; n >= 0; n-- {
-			fn2 = fn1
-			if n == 0 {
-				fn1 = fileName
-			} else {
-				fn1 = fmt.Sprintf("%v.%v", fileName, n)
-			}
-			if fn2 != "" {
-				os.Rename(fn1, fn2)
-			}
-		}
-
-		// Create a new log file.
-		logger.SetTarget(logger.target)
+	// Rotate if size or age limit is reached.
+	if fileInfo.Size() < int64(logger.maxFileSize) && !logger.expired(fileName) {
+		return
+	}
+
+	logger.out.Close()
+	rotateErr := logger.rotateFiles(fileName)
+	logger.fileStart = time.Time{}
+
+	// Create a new log file.
+	logger.SetTarget(logger.target)
+	if rotateErr != nil {
+		logger.l.Printf("[%v] [log] Failed to rotate log file %s: %v.", pid, fileName, rotateErr)
 	}
 }
 
@@ -223,7 +236,11 @@ func (logger *Logger) logf(format string, args ...interface{}) {
 	if logger.callCount%rotationCheckFrq == 0 {
 		logger.rotate()
 	}
-	format = fmt.Sprintf("[%v] %s", pid, format)
+	if logger.correlationID != "" {
+		format = fmt.Sprintf("[%v] [%s] %s", pid, strings.ReplaceAll(logger.correlationID, "%", "%%"), format)
+	} else {
+		format = fmt.Sprintf("[%v] %s", pid, format)
+	}
 	logger.callCount++
 	logger.l.Printf(format, args...)
 }
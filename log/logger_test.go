@@ -4,11 +4,14 @@
 package log
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -103,3 +106,128 @@ func TestPid(t *testing.T) {
 		t.Fatalf("Unexpected log: %s.", log)
 	}
 }
+
+// Tests that the correlation ID is logged in every entry once set.
+func TestCorrelationID(t *testing.T) {
+	l := NewLogger(logName, LevelInfo, TargetLogfile, t.TempDir())
+	if l == nil {
+		t.Fatalf("Failed to create logger.")
+	}
+
+	l.Printf("LogText %v", 1)
+	l.SetCorrelationID("id%d")
+	l.Printf("LogText %v", 2)
+	l.Close()
+
+	logBytes, err := os.ReadFile(l.getLogFileName())
+	if err != nil {
+		t.Fatalf("Failed to read log, %v", err)
+	}
+	log := string(logBytes)
+	for _, expectedLog := range []string{
+		fmt.Sprintf("[%v] LogText 1", os.Getpid()),
+		fmt.Sprintf("[%v] [id%%d] LogText 2", os.Getpid()),
+	} {
+		if !strings.Contains(log, expectedLog) {
+			t.Fatalf("Unexpected log: %s.", log)
+		}
+	}
+}
+
+// Tests that the rotated log files are compressed.
+func TestLogFileRotationCompresses(t *testing.T) {
+	logDirectory := t.TempDir()
+	l := NewLogger(logName, LevelInfo, TargetLogfile, logDirectory)
+	if l == nil {
+		t.Fatalf("Failed to create logger.\n")
+	}
+
+	l.SetRotation(RotationConfig{MaxFileSize: 1024, MaxFileCount: 4, Compress: true})
+
+	for i := 1; i <= 100; i++ {
+		l.Logf("LogText %v", i)
+	}
+
+	l.Close()
+
+	fn := path.Join(logDirectory, logName+".log")
+	if _, err := os.Stat(fn); err != nil {
+		t.Errorf("Failed to find active log file.")
+	}
+
+	gz, err := os.Open(fn + ".1.gz")
+	if err != nil {
+		t.Fatalf("Failed to find the 1st compressed rotated log file: %v", err)
+	}
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	if err != nil {
+		t.Fatalf("Failed to read the 1st compressed rotated log file: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil || !strings.Contains(string(b), "LogText") {
+		t.Errorf("Unexpected content of the 1st compressed rotated log file: %s, err: %v", b, err)
+	}
+	if _, err := os.Stat(fn + ".1"); err == nil {
+		t.Errorf("Found the uncompressed 1st rotated log file which should have been deleted.")
+	}
+	if _, err := os.Stat(fn + ".4.gz"); err == nil {
+		t.Errorf("Found the 4th rotated log file which should have been deleted.")
+	}
+}
+
+// Tests that the oldest rotated log files are pruned above the max total size.
+func TestLogFileRotationPrunesAboveMaxTotalSize(t *testing.T) {
+	logDirectory := t.TempDir()
+	l := NewLogger(logName, LevelInfo, TargetLogfile, logDirectory)
+	if l == nil {
+		t.Fatalf("Failed to create logger.\n")
+	}
+
+	l.SetRotation(RotationConfig{MaxFileSize: 1024, MaxFileCount: 8, MaxTotalSize: 2048})
+
+	for i := 1; i <= 200; i++ {
+		l.Logf("LogText %v", i)
+	}
+
+	l.Close()
+
+	fn := path.Join(logDirectory, logName+".log")
+	if _, err := os.Stat(fn + ".1"); err != nil {
+		t.Errorf("Failed to find the 1st rotated log file.")
+	}
+	if _, err := os.Stat(fn + ".2"); err == nil {
+		t.Errorf("Found the 2nd rotated log file which should have been pruned.")
+	}
+}
+
+// Tests that the log file rotates when its first entry is older than the max age.
+func TestLogFileRotatesWhenMaxAgeIsReached(t *testing.T) {
+	logDirectory := t.TempDir()
+	fn := path.Join(logDirectory, logName+".log")
+	old := time.Now().Add(-2 * time.Hour).Format("2006/01/02 15:04:05")
+	if err := os.WriteFile(fn, []byte(old+" [1] LogText 0\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	l := NewLogger(logName, LevelInfo, TargetLogfile, logDirectory)
+	if l == nil {
+		t.Fatalf("Failed to create logger.\n")
+	}
+	l.SetRotation(RotationConfig{MaxAge: time.Hour})
+	l.Logf("LogText %v", 1)
+	l.Logf("LogText %v", 2)
+	l.Close()
+
+	rotated, err := os.ReadFile(fn + ".1")
+	if err != nil {
+		t.Fatalf("Failed to find the 1st rotated log file: %v", err)
+	}
+	if !strings.Contains(string(rotated), "LogText 0") {
+		t.Errorf("Unexpected content of the 1st rotated log file: %s", rotated)
+	}
+	active, err := os.ReadFile(fn)
+	if err != nil || strings.Contains(string(active), "LogText 0") || !strings.Contains(string(active), "LogText 2") {
+		t.Errorf("Unexpected content of the active log file: %s, err: %v", active, err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxLogFileCount is the number of log files kept, including the active one, unless changed.
+	DefaultMaxLogFileCount = maxLogFileCount
+
+	gzipExtension = ".gz"
+	// entryTimeLayout is the layout of the timestamp log.LstdFlags prefixes the entries with.
+	entryTimeLayout = "2006/01/02 15:04:05"
+)
+
+// RotationConfig configures the rotation of the log file.
+type RotationConfig struct {
+	// MaxFileSize is the size in bytes at which the log file is rotated, DefaultMaxLogFileSize if zero.
+	MaxFileSize int
+	// MaxFileCount is the number of log files kept including the active one, DefaultMaxLogFileCount if zero.
+	MaxFileCount int
+	// MaxAge rotates the log file once its first entry is older. Zero only rotates it on size.
+	MaxAge time.Duration
+	// Compress gzips the rotated log files.
+	Compress bool
+	// MaxTotalSize is the size in bytes of the log files above which the oldest rotated files are deleted, even if
+	// fewer than MaxFileCount are kept. Zero only prunes them on count.
+	MaxTotalSize int64
+}
+
+// SetRotation sets how the log file is rotated.
+func (logger *Logger) SetRotation(cfg RotationConfig) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	logger.maxFileSize = cfg.MaxFileSize
+	if logger.maxFileSize <= 0 {
+		logger.maxFileSize = maxLogFileSize
+	}
+	logger.maxFileCount = cfg.MaxFileCount
+	if logger.maxFileCount <= 0 {
+		logger.maxFileCount = maxLogFileCount
+	}
+	logger.maxAge = cfg.MaxAge
+	logger.compress = cfg.Compress
+	logger.maxTotalSize = cfg.MaxTotalSize
+}
+
+// expired returns whether the first entry of the log file is older than the max age.
+func (logger *Logger) expired(fileName string) bool {
+	if logger.maxAge <= 0 {
+		return false
+	}
+	if logger.fileStart.IsZero() {
+		logger.fileStart = firstEntryTime(fileName)
+	}
+	return time.Since(logger.fileStart) >= logger.maxAge
+}
+
+// firstEntryTime returns the timestamp of the first entry of the log file, since short-lived processes like the
+// CNI plugins append to the file another process created. It returns the current time if the file has no entry.
+func firstEntryTime(fileName string) time.Time {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return time.Now()
+	}
+	defer f.Close()
+
+	b := make([]byte, len(entryTimeLayout))
+	if _, err := io.ReadFull(f, b); err != nil {
+		return time.Now()
+	}
+	t, err := time.ParseInLocation(entryTimeLayout, string(b), time.Local)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+// rotatedFileName returns the name of the n-th rotated log file, and whether it exists, compressed or not.
+func rotatedFileName(fileName string, n int) (string, bool) {
+	name := fmt.Sprintf("%s.%d", fileName, n)
+	for _, candidate := range []string{name, name + gzipExtension} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return name, false
+}
+
+// rotateFiles renames the log file to fileName.1, shifting the rotated files and deleting the ones beyond the
+// count, then compresses the rotated file and prunes the oldest ones above the max total size.
+func (logger *Logger) rotateFiles(fileName string) error {
+	if logger.maxFileCount <= 1 {
+		return os.Remove(fileName)
+	}
+
+	for n := logger.maxFileCount - 1; n >= 1; n-- {
+		src, ok := rotatedFileName(fileName, n)
+		if !ok {
+			continue
+		}
+		if n == logger.maxFileCount-1 {
+			os.Remove(src)
+			continue
+		}
+		dst := fmt.Sprintf("%s.%d", fileName, n+1)
+		if strings.HasSuffix(src, gzipExtension) {
+			dst += gzipExtension
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+
+	rotated := fileName + ".1"
+	if err := os.Rename(fileName, rotated); err != nil {
+		return err
+	}
+	if logger.compress {
+		if err := compressFile(rotated); err != nil {
+			return err
+		}
+	}
+	if logger.maxTotalSize > 0 {
+		logger.pruneFiles(fileName)
+	}
+	return nil
+}
+
+// pruneFiles deletes the oldest rotated files while the log files take more than the max total size.
+func (logger *Logger) pruneFiles(fileName string) {
+	sizes := make([]int64, logger.maxFileCount)
+	var total int64
+	if info, err := os.Stat(fileName); err == nil {
+		total = info.Size()
+	}
+	for n := 1; n < logger.maxFileCount; n++ {
+		if name, ok := rotatedFileName(fileName, n); ok {
+			if info, err := os.Stat(name); err == nil {
+				sizes[n] = info.Size()
+				total += sizes[n]
+			}
+		}
+	}
+
+	for n := logger.maxFileCount - 1; n >= 1 && total > logger.maxTotalSize; n-- {
+		if name, ok := rotatedFileName(fileName, n); ok && os.Remove(name) == nil {
+			total -= sizes[n]
+		}
+	}
+}
+
+// compressFile gzips the file into fileName.gz, and deletes it.
+func compressFile(fileName string) error {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dstName := fileName + gzipExtension
+	dst, err := os.OpenFile(dstName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, logFilePerm)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dstName)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(fileName)
+}
@@ -20,10 +20,20 @@ func SetLevel(level int) {
 	stdLog.SetLevel(level)
 }
 
+// SetCorrelationID sets the ID logged in every entry of the standard logger.
+func SetCorrelationID(id string) {
+	stdLog.SetCorrelationID(id)
+}
+
 func SetLogFileLimits(maxFileSize int, maxFileCount int) {
 	stdLog.SetLogFileLimits(maxFileSize, maxFileCount)
 }
 
+// SetRotation sets how the log file of the standard logger is rotated.
+func SetRotation(cfg RotationConfig) {
+	stdLog.SetRotation(cfg)
+}
+
 func Close() {
 	stdLog.Close()
 }
@@ -36,6 +46,11 @@ func GetLogDirectory() string {
 	return stdLog.GetLogDirectory()
 }
 
+// GetLogFileName returns the full name of the log file of the standard logger.
+func GetLogFileName() string {
+	return stdLog.getLogFileName()
+}
+
 func Request(tag string, request interface{}, err error) {
 	stdLog.Request(tag, request, err)
 }
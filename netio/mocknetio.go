@@ -4,15 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 )
 
 type getInterfaceValidationFn func(name string) (*net.Interface, error)
 
+// MockNetIO fakes the host interfaces. Created with NewMockNetIO it serves a single dummy interface for
+// any name; created from a MockTopology it serves the interfaces of the topology.
 type MockNetIO struct {
+	sync.Mutex
 	fail           bool
 	failAttempt    int
 	numTimesCalled int
 	getInterfaceFn getInterfaceValidationFn
+
+	topology   *MockTopology
+	interfaces map[string]*MockInterface
+	calls      map[string]int
+	step       int
 }
 
 // ErrMockNetIOFail - mock netio error
@@ -30,6 +39,10 @@ func (netshim *MockNetIO) SetGetInterfaceValidatonFn(fn getInterfaceValidationFn
 }
 
 func (netshim *MockNetIO) GetNetworkInterfaceByName(name string) (*net.Interface, error) {
+	if netshim.topology != nil {
+		return netshim.topologyInterfaceByName(name)
+	}
+
 	netshim.numTimesCalled++
 
 	if netshim.fail && netshim.failAttempt == netshim.numTimesCalled {
@@ -53,5 +66,8 @@ func (netshim *MockNetIO) GetNetworkInterfaceByName(name string) (*net.Interface
 }
 
 func (netshim *MockNetIO) GetNetworkInterfaceAddrs(iface *net.Interface) ([]net.Addr, error) {
+	if netshim.topology != nil {
+		return netshim.topologyInterfaceAddrs(iface)
+	}
 	return []net.Addr{}, nil
 }
@@ -0,0 +1,270 @@
+package netio
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	getNetworkInterfaceByNameMethod  = "GetNetworkInterfaceByName"
+	getNetworkInterfaceAddrsMethod   = "GetNetworkInterfaceAddrs"
+	defaultMockInterfaceMTU          = 1500
+	defaultMockInterfaceHardwareAddr = "ab:cd:ef:12:34:56"
+)
+
+// ErrMockInterfaceNotFound - mock netio error when the interface is not in the topology
+var ErrMockInterfaceNotFound = errors.New("no such network interface")
+
+// MockInterface is an interface of a MockTopology.
+type MockInterface struct {
+	Name         string   `json:"name"`
+	Index        int      `json:"index"`
+	MTU          int      `json:"mtu,omitempty"`
+	HardwareAddr string   `json:"mac,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"`
+	Down         bool     `json:"down,omitempty"`
+}
+
+// MockTopologyEvent changes the topology when its step is reached, e.g. a secondary NIC is
+// hot-added or an interface flaps. Add inserts or replaces the interface, Remove deletes the
+// named interface, and Up/Addresses update the named interface.
+type MockTopologyEvent struct {
+	Step      int            `json:"step"`
+	Interface string         `json:"interface,omitempty"`
+	Add       *MockInterface `json:"add,omitempty"`
+	Remove    bool           `json:"remove,omitempty"`
+	Up        *bool          `json:"up,omitempty"`
+	Addresses []string       `json:"addresses,omitempty"`
+}
+
+// MockErrorSchedule fails the listed calls (1-based, counted per method) of a MockNetIO method.
+// If Interface is set, only calls for that interface are counted.
+type MockErrorSchedule struct {
+	Method    string `json:"method"`
+	Interface string `json:"interface,omitempty"`
+	Calls     []int  `json:"calls"`
+}
+
+// MockTopology describes the host interfaces served by a MockNetIO, how they change over time
+// and which calls fail.
+type MockTopology struct {
+	Interfaces []MockInterface     `json:"interfaces"`
+	Events     []MockTopologyEvent `json:"events,omitempty"`
+	Errors     []MockErrorSchedule `json:"errors,omitempty"`
+}
+
+// NewMockNetIOWithTopology returns a MockNetIO serving the interfaces of the topology.
+func NewMockNetIOWithTopology(topology *MockTopology) (*MockNetIO, error) {
+	state := map[string]*MockInterface{}
+	for i := range topology.Interfaces {
+		iface := topology.Interfaces[i]
+		if err := iface.validate(); err != nil {
+			return nil, err
+		}
+		state[iface.Name] = &iface
+	}
+	for i := range topology.Events {
+		if err := topology.Events[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range topology.Errors {
+		switch topology.Errors[i].Method {
+		case getNetworkInterfaceByNameMethod, getNetworkInterfaceAddrsMethod:
+		default:
+			return nil, errors.Errorf("unknown method %q in error schedule", topology.Errors[i].Method)
+		}
+	}
+
+	netshim := &MockNetIO{
+		topology:   topology,
+		interfaces: state,
+		calls:      map[string]int{},
+	}
+	netshim.applyEvents()
+	return netshim, nil
+}
+
+// NewMockNetIOFromYAML returns a MockNetIO serving the topology in the YAML fixture.
+func NewMockNetIOFromYAML(data []byte) (*MockNetIO, error) {
+	topology := &MockTopology{}
+	if err := yaml.UnmarshalStrict(data, topology); err != nil {
+		return nil, errors.Wrap(err, "failed to parse mock topology")
+	}
+	return NewMockNetIOWithTopology(topology)
+}
+
+// LoadMockNetIO returns a MockNetIO serving the topology in the YAML fixture file.
+func LoadMockNetIO(path string) (*MockNetIO, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read mock topology %s", path)
+	}
+	return NewMockNetIOFromYAML(data)
+}
+
+// Step returns the current step of the topology.
+func (netshim *MockNetIO) Step() int {
+	netshim.Lock()
+	defer netshim.Unlock()
+	return netshim.step
+}
+
+// Advance moves the topology to the next step and applies the events of that step.
+func (netshim *MockNetIO) Advance() {
+	netshim.Lock()
+	defer netshim.Unlock()
+	netshim.step++
+	netshim.applyEvents()
+}
+
+// applyEvents applies the events of the current step. The lock must be held by the caller.
+func (netshim *MockNetIO) applyEvents() {
+	for i := range netshim.topology.Events {
+		event := netshim.topology.Events[i]
+		if event.Step != netshim.step {
+			continue
+		}
+		if event.Add != nil {
+			iface := *event.Add
+			netshim.interfaces[iface.Name] = &iface
+			continue
+		}
+		iface, ok := netshim.interfaces[event.Interface]
+		if !ok {
+			continue
+		}
+		if event.Remove {
+			delete(netshim.interfaces, event.Interface)
+			continue
+		}
+		if event.Up != nil {
+			iface.Down = !*event.Up
+		}
+		if event.Addresses != nil {
+			iface.Addresses = event.Addresses
+		}
+	}
+}
+
+// scheduledError returns an error if the call to method is scheduled to fail. The lock must be
+// held by the caller.
+func (netshim *MockNetIO) scheduledError(method, name string) error {
+	netshim.calls[method]++
+	netshim.calls[method+"/"+name]++
+	for _, schedule := range netshim.topology.Errors {
+		if schedule.Method != method {
+			continue
+		}
+		call := netshim.calls[method]
+		if schedule.Interface != "" {
+			if schedule.Interface != name {
+				continue
+			}
+			call = netshim.calls[method+"/"+name]
+		}
+		for _, c := range schedule.Calls {
+			if c == call {
+				return fmt.Errorf("%w:%s call %d for %s", ErrMockNetIOFail, method, call, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (netshim *MockNetIO) topologyInterfaceByName(name string) (*net.Interface, error) {
+	netshim.Lock()
+	defer netshim.Unlock()
+
+	if err := netshim.scheduledError(getNetworkInterfaceByNameMethod, name); err != nil {
+		return nil, err
+	}
+	iface, ok := netshim.interfaces[name]
+	if !ok {
+		return nil, fmt.Errorf("%w:%s", ErrMockInterfaceNotFound, name)
+	}
+	return iface.toNetInterface(), nil
+}
+
+func (netshim *MockNetIO) topologyInterfaceAddrs(netIface *net.Interface) ([]net.Addr, error) {
+	if netIface == nil {
+		return []net.Addr{}, ErrInterfaceNil
+	}
+
+	netshim.Lock()
+	defer netshim.Unlock()
+
+	if err := netshim.scheduledError(getNetworkInterfaceAddrsMethod, netIface.Name); err != nil {
+		return nil, err
+	}
+	iface, ok := netshim.interfaces[netIface.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w:%s", ErrMockInterfaceNotFound, netIface.Name)
+	}
+	addrs := make([]net.Addr, 0, len(iface.Addresses))
+	for _, address := range iface.Addresses {
+		ip, ipNet, _ := net.ParseCIDR(address)
+		ipNet.IP = ip
+		addrs = append(addrs, ipNet)
+	}
+	return addrs, nil
+}
+
+func (iface *MockInterface) validate() error {
+	if iface.Name == "" {
+		return errors.New("mock interface has no name")
+	}
+	if iface.HardwareAddr != "" {
+		if _, err := net.ParseMAC(iface.HardwareAddr); err != nil {
+			return errors.Wrapf(err, "invalid mac of mock interface %s", iface.Name)
+		}
+	}
+	for _, address := range iface.Addresses {
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			return errors.Wrapf(err, "invalid address of mock interface %s", iface.Name)
+		}
+	}
+	return nil
+}
+
+func (event *MockTopologyEvent) validate() error {
+	if event.Add != nil {
+		return event.Add.validate()
+	}
+	if event.Interface == "" {
+		return errors.Errorf("topology event at step %d has no interface", event.Step)
+	}
+	for _, address := range event.Addresses {
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			return errors.Wrapf(err, "invalid address in topology event at step %d", event.Step)
+		}
+	}
+	return nil
+}
+
+func (iface *MockInterface) toNetInterface() *net.Interface {
+	mac := iface.HardwareAddr
+	if mac == "" {
+		mac = defaultMockInterfaceHardwareAddr
+	}
+	hwAddr, _ := net.ParseMAC(mac)
+	mtu := iface.MTU
+	if mtu == 0 {
+		mtu = defaultMockInterfaceMTU
+	}
+	var flags net.Flags
+	if !iface.Down {
+		flags = net.FlagUp | net.FlagRunning
+	}
+	return &net.Interface{
+		Index:        iface.Index,
+		MTU:          mtu,
+		Name:         iface.Name,
+		HardwareAddr: hwAddr,
+		Flags:        flags,
+	}
+}
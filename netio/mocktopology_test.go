@@ -0,0 +1,101 @@
+package netio
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockTopologyFixture(t *testing.T) {
+	netshim, err := LoadMockNetIO("testdata/topology.yaml")
+	require.NoError(t, err)
+
+	eth0, err := netshim.GetNetworkInterfaceByName("eth0")
+	require.NoError(t, err)
+	assert.Equal(t, 2, eth0.Index)
+	assert.Equal(t, "00:0d:3a:00:00:01", eth0.HardwareAddr.String())
+	assert.NotZero(t, eth0.Flags&net.FlagUp)
+
+	addrs, err := netshim.GetNetworkInterfaceAddrs(eth0)
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+	assert.Equal(t, "10.0.0.4/24", addrs[0].String())
+
+	// the secondary NIC doesn't exist until it is hot-added
+	_, err = netshim.GetNetworkInterfaceByName("eth1")
+	require.ErrorIs(t, err, ErrMockInterfaceNotFound)
+
+	netshim.Advance()
+	eth1, err := netshim.GetNetworkInterfaceByName("eth1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, eth1.Index)
+
+	// the first addrs call for eth1 is scheduled to fail
+	_, err = netshim.GetNetworkInterfaceAddrs(eth1)
+	require.ErrorIs(t, err, ErrMockNetIOFail)
+	addrs, err = netshim.GetNetworkInterfaceAddrs(eth1)
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.0.4/24", addrs[0].String())
+
+	// eth0 flaps
+	netshim.Advance()
+	eth0, err = netshim.GetNetworkInterfaceByName("eth0")
+	require.NoError(t, err)
+	assert.Zero(t, eth0.Flags&net.FlagUp)
+
+	netshim.Advance()
+	assert.Equal(t, 3, netshim.Step())
+	eth0, err = netshim.GetNetworkInterfaceByName("eth0")
+	require.NoError(t, err)
+	assert.NotZero(t, eth0.Flags&net.FlagUp)
+}
+
+func TestMockTopologyInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+	}{
+		{
+			name:    "unknown field",
+			fixture: "interfaces: [{name: eth0, speed: 10}]",
+		},
+		{
+			name:    "invalid address",
+			fixture: "interfaces: [{name: eth0, addresses: [10.0.0.4]}]",
+		},
+		{
+			name:    "event without interface",
+			fixture: "events: [{step: 1, up: false}]",
+		},
+		{
+			name:    "unknown method",
+			fixture: "errors: [{method: GetRoutes, calls: [1]}]",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMockNetIOFromYAML([]byte(tt.fixture))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMockTopologyErrorSchedule(t *testing.T) {
+	netshim, err := NewMockNetIOWithTopology(&MockTopology{
+		Interfaces: []MockInterface{{Name: "eth0", Index: 2}},
+		Errors:     []MockErrorSchedule{{Method: getNetworkInterfaceByNameMethod, Calls: []int{2, 3}}},
+	})
+	require.NoError(t, err)
+
+	for call, fail := range []bool{false, true, true, false} {
+		_, err := netshim.GetNetworkInterfaceByName("eth0")
+		if fail {
+			require.ErrorIs(t, err, ErrMockNetIOFail, "call %d", call+1)
+		} else {
+			require.NoError(t, err, "call %d", call+1)
+		}
+	}
+}
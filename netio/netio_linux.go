@@ -0,0 +1,21 @@
+package netio
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+func (ns *NetIO) GetNetworkInterfaceByName(name string) (*net.Interface, error) {
+	iface, err := net.InterfaceByName(name)
+	return iface, errors.Wrap(err, "GetNetworkInterfaceByName failed")
+}
+
+func (ns *NetIO) GetNetworkInterfaceAddrs(iface *net.Interface) ([]net.Addr, error) {
+	if iface == nil {
+		return []net.Addr{}, ErrInterfaceNil
+	}
+
+	addrs, err := iface.Addrs()
+	return addrs, errors.Wrap(err, "GetNetworkInterfaceAddrs failed")
+}
@@ -0,0 +1,179 @@
+package netio
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// hnsAliasFormat is the friendly name of the host vNIC created by HNS for an adapter bound to an external vSwitch.
+	hnsAliasFormat = "vEthernet (%s)"
+	hnsAliasPrefix = "vEthernet"
+
+	// initial size of the GetAdaptersAddresses buffer, as recommended by its documentation
+	adapterAddressesBufferSize = 15000
+)
+
+// ErrInterfaceNotFound - errors out when no adapter matches the interface name
+var ErrInterfaceNotFound = errors.New("no adapter found for interface")
+
+// adapter is a copy of the fields of an IP_ADAPTER_ADDRESSES used by NetIO.
+type adapter struct {
+	index        int
+	adapterName  string
+	friendlyName string
+	mtu          int
+	hardwareAddr net.HardwareAddr
+	flags        net.Flags
+	addrs        []net.Addr
+}
+
+// HNSInterfaceAlias returns the friendly name of the host vNIC HNS creates for the adapter,
+// or the name itself if it already is one.
+func HNSInterfaceAlias(name string) string {
+	if strings.Contains(name, hnsAliasPrefix) {
+		return name
+	}
+	return fmt.Sprintf(hnsAliasFormat, name)
+}
+
+// GetNetworkInterfaceByName returns the interface of the adapter matching the name. The name can be the
+// friendly name of the adapter (which is the name CNI sees), its GUID adapter name, or the name of the adapter
+// of an HNS vSwitch and its "vEthernet (<name>)" host vNIC interchangeably.
+func (ns *NetIO) GetNetworkInterfaceByName(name string) (*net.Interface, error) {
+	adapters, err := getAdapters()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetNetworkInterfaceByName failed")
+	}
+
+	a := findAdapter(adapters, name)
+	if a == nil {
+		return nil, errors.Wrapf(ErrInterfaceNotFound, "GetNetworkInterfaceByName failed for %s", name)
+	}
+	return a.netInterface(), nil
+}
+
+// GetNetworkInterfaceAddrs returns the unicast addresses of the adapter of the interface.
+func (ns *NetIO) GetNetworkInterfaceAddrs(iface *net.Interface) ([]net.Addr, error) {
+	if iface == nil {
+		return []net.Addr{}, ErrInterfaceNil
+	}
+
+	adapters, err := getAdapters()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetNetworkInterfaceAddrs failed")
+	}
+
+	for i := range adapters {
+		if adapters[i].index == iface.Index {
+			return adapters[i].addrs, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrInterfaceNotFound, "GetNetworkInterfaceAddrs failed for %s", iface.Name)
+}
+
+// findAdapter returns the adapter matching the name, preferring exact matches over HNS aliases.
+func findAdapter(adapters []adapter, name string) *adapter {
+	for i := range adapters {
+		if strings.EqualFold(adapters[i].friendlyName, name) || strings.EqualFold(adapters[i].adapterName, name) {
+			return &adapters[i]
+		}
+	}
+
+	// the name of the adapter bound to an HNS vSwitch maps to its host vNIC, and the other way around
+	alias := fmt.Sprintf(hnsAliasFormat, name)
+	var unaliased string
+	if strings.HasPrefix(name, hnsAliasPrefix+" (") && strings.HasSuffix(name, ")") {
+		unaliased = strings.TrimSuffix(strings.TrimPrefix(name, hnsAliasPrefix+" ("), ")")
+	}
+	for i := range adapters {
+		if strings.EqualFold(adapters[i].friendlyName, alias) ||
+			(unaliased != "" && strings.EqualFold(adapters[i].friendlyName, unaliased)) {
+			return &adapters[i]
+		}
+	}
+	return nil
+}
+
+func (a *adapter) netInterface() *net.Interface {
+	return &net.Interface{
+		Index:        a.index,
+		MTU:          a.mtu,
+		Name:         a.friendlyName,
+		HardwareAddr: a.hardwareAddr,
+		Flags:        a.flags,
+	}
+}
+
+// getAdapters returns the adapters of the host from GetAdaptersAddresses.
+func getAdapters() ([]adapter, error) {
+	size := uint32(adapterAddressesBufferSize)
+	var b []byte
+	for {
+		b = make([]byte, size)
+		err := windows.GetAdaptersAddresses(syscall.AF_UNSPEC, windows.GAA_FLAG_INCLUDE_PREFIX, 0,
+			(*windows.IpAdapterAddresses)(unsafe.Pointer(&b[0])), &size)
+		if err == nil {
+			if size == 0 {
+				return nil, nil
+			}
+			break
+		}
+		if !errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) || size <= uint32(len(b)) {
+			return nil, os.NewSyscallError("getadaptersaddresses", err)
+		}
+	}
+
+	var adapters []adapter
+	for aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&b[0])); aa != nil; aa = aa.Next {
+		adapters = append(adapters, newAdapter(aa))
+	}
+	return adapters, nil
+}
+
+func newAdapter(aa *windows.IpAdapterAddresses) adapter {
+	a := adapter{
+		index:        int(aa.IfIndex),
+		adapterName:  windows.BytePtrToString(aa.AdapterName),
+		friendlyName: windows.UTF16PtrToString(aa.FriendlyName),
+		mtu:          int(aa.Mtu),
+	}
+	if a.index == 0 {
+		// IPv6 only adapter
+		a.index = int(aa.Ipv6IfIndex)
+	}
+	if aa.PhysicalAddressLength > 0 {
+		a.hardwareAddr = make(net.HardwareAddr, aa.PhysicalAddressLength)
+		copy(a.hardwareAddr, aa.PhysicalAddress[:])
+	}
+
+	if aa.OperStatus == windows.IfOperStatusUp {
+		a.flags |= net.FlagUp | net.FlagRunning
+	}
+	switch aa.IfType {
+	case windows.IF_TYPE_SOFTWARE_LOOPBACK:
+		a.flags |= net.FlagLoopback | net.FlagMulticast
+	case windows.IF_TYPE_ETHERNET_CSMACD, windows.IF_TYPE_IEEE80211:
+		a.flags |= net.FlagBroadcast | net.FlagMulticast
+	}
+
+	for ua := aa.FirstUnicastAddress; ua != nil; ua = ua.Next {
+		ip := ua.Address.IP()
+		if ip == nil {
+			continue
+		}
+		bits := net.IPv6len * 8 //nolint:gomnd // bits per byte
+		if ip.To4() != nil {
+			bits = net.IPv4len * 8 //nolint:gomnd // bits per byte
+		}
+		a.addrs = append(a.addrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(int(ua.OnLinkPrefixLength), bits)})
+	}
+	return a
+}
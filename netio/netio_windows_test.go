@@ -0,0 +1,44 @@
+package netio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAdapter(t *testing.T) {
+	adapters := []adapter{
+		{index: 4, adapterName: "{5b2a0d9e-1111-4a43-9c7f-0d8e8d6c1a01}", friendlyName: "Ethernet"},
+		{index: 12, adapterName: "{5b2a0d9e-2222-4a43-9c7f-0d8e8d6c1a02}", friendlyName: "vEthernet (Ethernet 2)"},
+	}
+
+	tests := []struct {
+		name  string
+		index int
+	}{
+		{name: "Ethernet", index: 4},
+		{name: "ethernet", index: 4},
+		{name: "{5b2a0d9e-1111-4a43-9c7f-0d8e8d6c1a01}", index: 4},
+		{name: "vEthernet (Ethernet)", index: 4},
+		{name: "vEthernet (Ethernet 2)", index: 12},
+		{name: "Ethernet 2", index: 12},
+		{name: "Ethernet 3", index: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a := findAdapter(adapters, tt.name)
+			if tt.index == 0 {
+				require.Nil(t, a)
+				return
+			}
+			require.NotNil(t, a)
+			require.Equal(t, tt.index, a.index)
+		})
+	}
+}
+
+func TestHNSInterfaceAlias(t *testing.T) {
+	require.Equal(t, "vEthernet (Ethernet)", HNSInterfaceAlias("Ethernet"))
+	require.Equal(t, "vEthernet (Ethernet)", HNSInterfaceAlias("vEthernet (Ethernet)"))
+}
@@ -289,6 +289,68 @@ func (Netlink) DeleteIPRoute(route *Route) error {
 	return setIpRoute(route, false)
 }
 
+// Rule represents an IP routing policy rule.
+type Rule struct {
+	Family   int
+	Src      *net.IPNet
+	Table    int
+	Priority int
+}
+
+// setIPRule sends an IP rule set request.
+func setIPRule(rule *Rule, add bool) error {
+	var msgType, flags int
+
+	s, err := getSocket()
+	if err != nil {
+		return err
+	}
+
+	if add {
+		msgType = unix.RTM_NEWRULE
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL | unix.NLM_F_ACK
+	} else {
+		msgType = unix.RTM_DELRULE
+		flags = unix.NLM_F_ACK
+	}
+
+	req := newRequest(msgType, flags)
+
+	// The rule header has the layout of the route message, with the action in place of the type.
+	msg := newRtMsg(rule.Family)
+	msg.Protocol = 0
+	msg.Scope = 0
+	msg.Type = unix.FR_ACT_TO_TBL
+	if rule.Table < 256 {
+		msg.Table = uint8(rule.Table)
+	}
+	req.addPayload(msg)
+
+	if rule.Src != nil {
+		prefixLength, _ := rule.Src.Mask.Size()
+		msg.Src_len = uint8(prefixLength)
+		req.addPayload(newAttributeIpAddress(unix.FRA_SRC, rule.Src.IP))
+	}
+
+	req.addPayload(newAttributeUint32(unix.FRA_TABLE, uint32(rule.Table)))
+
+	if rule.Priority != 0 {
+		req.addPayload(newAttributeUint32(unix.FRA_PRIORITY, uint32(rule.Priority)))
+	}
+
+	return s.sendAndWaitForAck(req)
+}
+
+// AddIPRule adds an IP rule to the routing policy database.
+func (Netlink) AddIPRule(rule *Rule) error {
+	return setIPRule(rule, true)
+}
+
+// DeleteIPRule deletes an IP rule from the routing policy database.
+func (Netlink) DeleteIPRule(rule *Rule) error {
+	return setIPRule(rule, false)
+}
+
 // GetIPAddressFamily returns the address family of an IP address.
 func GetIPAddressFamily(ip net.IP) int {
 	if len(ip) <= net.IPv4len {
@@ -21,6 +21,7 @@ const (
 	LINK_TYPE_VETH   = "veth"
 	LINK_TYPE_IPVLAN = "ipvlan"
 	LINK_TYPE_DUMMY  = "dummy"
+	LINK_TYPE_IFB    = "ifb"
 )
 
 // IPVLAN link attributes.
@@ -81,6 +82,12 @@ type DummyLink struct {
 	LinkInfo
 }
 
+// IFBLink represents an intermediate functional block device, used to shape the ingress traffic of
+// another interface.
+type IFBLink struct {
+	LinkInfo
+}
+
 // AddLink adds a new network interface of a specified type.
 func (Netlink) AddLink(link Link) error {
 	info := link.Info()
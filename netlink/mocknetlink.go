@@ -15,11 +15,18 @@ func newErrorMockNetlink(errStr string) error {
 
 type routeValidateFn func(route *Route) error
 
+type getRouteFn func(filter *Route) ([]*Route, error)
+
+type ruleValidateFn func(rule *Rule) error
+
 type MockNetlink struct {
 	returnError   bool
 	errorString   string
 	deleteRouteFn routeValidateFn
 	addRouteFn    routeValidateFn
+	getRouteFn    getRouteFn
+	addRuleFn     ruleValidateFn
+	deleteRuleFn  ruleValidateFn
 }
 
 func NewMockNetlink(returnError bool, errorString string) *MockNetlink {
@@ -37,6 +44,18 @@ func (f *MockNetlink) SetAddRouteValidationFn(fn routeValidateFn) {
 	f.addRouteFn = fn
 }
 
+func (f *MockNetlink) SetGetRouteFn(fn getRouteFn) {
+	f.getRouteFn = fn
+}
+
+func (f *MockNetlink) SetAddRuleValidationFn(fn ruleValidateFn) {
+	f.addRuleFn = fn
+}
+
+func (f *MockNetlink) SetDeleteRuleValidationFn(fn ruleValidateFn) {
+	f.deleteRuleFn = fn
+}
+
 func (f *MockNetlink) error() error {
 	if f.returnError {
 		return newErrorMockNetlink(f.errorString)
@@ -96,7 +115,10 @@ func (f *MockNetlink) DeleteIPAddress(string, net.IP, *net.IPNet) error {
 	return f.error()
 }
 
-func (f *MockNetlink) GetIPRoute(*Route) ([]*Route, error) {
+func (f *MockNetlink) GetIPRoute(filter *Route) ([]*Route, error) {
+	if f.getRouteFn != nil {
+		return f.getRouteFn(filter)
+	}
 	return nil, f.error()
 }
 
@@ -113,3 +135,17 @@ func (f *MockNetlink) DeleteIPRoute(r *Route) error {
 	}
 	return f.error()
 }
+
+func (f *MockNetlink) AddIPRule(r *Rule) error {
+	if f.addRuleFn != nil {
+		return f.addRuleFn(r)
+	}
+	return f.error()
+}
+
+func (f *MockNetlink) DeleteIPRule(r *Rule) error {
+	if f.deleteRuleFn != nil {
+		return f.deleteRuleFn(r)
+	}
+	return f.error()
+}
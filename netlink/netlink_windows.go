@@ -12,6 +12,8 @@ type Link interface {
 
 type Route struct{}
 
+type Rule struct{}
+
 // LinkInfo respresents the common properties of all network interfaces.
 type LinkInfo struct {
 	Type string
@@ -85,3 +87,11 @@ func (Netlink) AddIPRoute(route *Route) error {
 func (Netlink) DeleteIPRoute(route *Route) error {
 	return nil
 }
+
+func (Netlink) AddIPRule(rule *Rule) error {
+	return nil
+}
+
+func (Netlink) DeleteIPRule(rule *Rule) error {
+	return nil
+}
@@ -24,4 +24,6 @@ type NetlinkInterface interface {
 	GetIPRoute(filter *Route) ([]*Route, error)
 	AddIPRoute(route *Route) error
 	DeleteIPRoute(route *Route) error
+	AddIPRule(rule *Rule) error
+	DeleteIPRule(rule *Rule) error
 }
@@ -20,6 +20,8 @@ var (
 	errMultipleEndpointsFound = fmt.Errorf("Multiple endpoints found")
 	errEndpointInUse          = fmt.Errorf("Endpoint is already joined to a sandbox")
 	errEndpointNotInUse       = fmt.Errorf("Endpoint is not joined to a sandbox")
+	errRouteTablesExhausted   = fmt.Errorf("No route table left for source routing")
+	errNodeLocalNATExhausted  = fmt.Errorf("No node-local NAT IP left in subnet")
 )
 
 type networkNotFoundError struct{}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Prefix for the ifb device shaping the egress traffic of an endpoint.
+	ifbInterfacePrefix = commonInterfacePrefix + "b"
+
+	// Latency of the token bucket filter, as in the upstream bandwidth plugin.
+	tbfLatencyInMillis = 25
+
+	bitsPerByte = 8
+)
+
+// getIFBName returns the name of the ifb device of the host veth, which has the same suffix as the host veth.
+func getIFBName(hostIfName string) string {
+	return ifbInterfacePrefix + hostIfName[len(hostVEthInterfacePrefix):]
+}
+
+// tbfCommand returns the command limiting the traffic leaving the interface with a token bucket filter.
+func tbfCommand(ifName string, rate, burst uint64) string {
+	return fmt.Sprintf("tc qdisc add dev %s root tbf rate %dbit burst %d latency %dms",
+		ifName, rate, burst/bitsPerByte, tbfLatencyInMillis)
+}
+
+// setupBandwidth limits the bandwidth of the endpoint on its host veth.
+// The traffic to the container leaves the host veth and is shaped there. The traffic from the container
+// enters the host veth and is redirected to an ifb device to be shaped when leaving it.
+func setupBandwidth(nl netlink.NetlinkInterface, plc platform.ExecClient, hostIfName string, bw *BandwidthInfo) error {
+	if bw.IngressRate > 0 {
+		log.Printf("[net] Limiting ingress bandwidth of %s to %d bit/s", hostIfName, bw.IngressRate)
+		if _, err := plc.ExecuteCommand(tbfCommand(hostIfName, bw.IngressRate, bw.IngressBurst)); err != nil {
+			return errors.Wrapf(err, "failed to limit ingress bandwidth of %s", hostIfName)
+		}
+	}
+
+	if bw.EgressRate > 0 {
+		ifbName := getIFBName(hostIfName)
+		log.Printf("[net] Limiting egress bandwidth of %s to %d bit/s with %s", hostIfName, bw.EgressRate, ifbName)
+		link := netlink.IFBLink{
+			LinkInfo: netlink.LinkInfo{
+				Type:  netlink.LINK_TYPE_IFB,
+				Name:  ifbName,
+				Flags: net.FlagUp,
+			},
+		}
+		if err := nl.AddLink(&link); err != nil {
+			return errors.Wrapf(err, "failed to add ifb device %s", ifbName)
+		}
+
+		cmds := []string{
+			fmt.Sprintf("tc qdisc add dev %s handle ffff: ingress", hostIfName),
+			fmt.Sprintf("tc filter add dev %s parent ffff: protocol all u32 match u32 0 0 action mirred egress redirect dev %s", hostIfName, ifbName),
+			tbfCommand(ifbName, bw.EgressRate, bw.EgressBurst),
+		}
+		for _, cmd := range cmds {
+			if _, err := plc.ExecuteCommand(cmd); err != nil {
+				return errors.Wrapf(err, "failed to limit egress bandwidth of %s", hostIfName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteBandwidth deletes the ifb device of the endpoint. The qdiscs are deleted with the host veth.
+func deleteBandwidth(nl netlink.NetlinkInterface, hostIfName string, bw *BandwidthInfo) {
+	if bw == nil || bw.EgressRate == 0 {
+		return
+	}
+
+	ifbName := getIFBName(hostIfName)
+	log.Printf("[net] Deleting ifb device %s", ifbName)
+	if err := nl.DeleteLink(ifbName); err != nil {
+		log.Printf("[net] Failed to delete ifb device %s: %v", ifbName, err)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupBandwidth(t *testing.T) {
+	tests := []struct {
+		name string
+		bw   *BandwidthInfo
+		cmds []string
+	}{
+		{
+			name: "ingress",
+			bw:   &BandwidthInfo{IngressRate: 1000000, IngressBurst: 80000},
+			cmds: []string{
+				"tc qdisc add dev azv0123456789a root tbf rate 1000000bit burst 10000 latency 25ms",
+			},
+		},
+		{
+			name: "egress",
+			bw:   &BandwidthInfo{EgressRate: 2000000, EgressBurst: 160000},
+			cmds: []string{
+				"tc qdisc add dev azv0123456789a handle ffff: ingress",
+				"tc filter add dev azv0123456789a parent ffff: protocol all u32 match u32 0 0 action mirred egress redirect dev azb0123456789a",
+				"tc qdisc add dev azb0123456789a root tbf rate 2000000bit burst 20000 latency 25ms",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var cmds []string
+			plc := platform.NewMockExecClient(false)
+			plc.SetExecCommand(func(cmd string) (string, error) {
+				cmds = append(cmds, cmd)
+				return "", nil
+			})
+
+			err := setupBandwidth(netlink.NewMockNetlink(false, ""), plc, "azv0123456789a", tt.bw)
+			require.NoError(t, err)
+			require.Equal(t, tt.cmds, cmds)
+		})
+	}
+}
+
+func TestSetupBandwidthFails(t *testing.T) {
+	bw := &BandwidthInfo{EgressRate: 2000000, EgressBurst: 160000}
+
+	err := setupBandwidth(netlink.NewMockNetlink(true, "mock netlink error"), platform.NewMockExecClient(false), "azv0123456789a", bw)
+	require.Error(t, err)
+
+	err = setupBandwidth(netlink.NewMockNetlink(false, ""), platform.NewMockExecClient(true), "azv0123456789a", bw)
+	require.ErrorIs(t, err, platform.ErrMockExec)
+}
@@ -81,18 +81,25 @@ func (client *LinuxBridgeEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		return err
 	}
 
+	// The rules of the endpoint are added to its own chain, which marks them as owned by the endpoint.
+	chain := ebtables.EndpointChain(epInfo.Id)
+	log.Printf("[net] Adding ebtables chain %v for endpoint %v", chain, epInfo.Id)
+	if err = ebtables.AddEndpointChain(chain); err != nil {
+		return err
+	}
+
 	for _, ipAddr := range epInfo.IPAddresses {
 		if ipAddr.IP.To4() != nil {
 			// Add ARP reply rule.
 			log.Printf("[net] Adding ARP reply rule for IP address %v", ipAddr.String())
-			if err = ebtables.SetArpReply(ipAddr.IP, client.getArpReplyAddress(client.containerMac), ebtables.Append); err != nil {
+			if err = ebtables.SetArpReplyInChain(chain, ipAddr.IP, client.getArpReplyAddress(client.containerMac), ebtables.Append); err != nil {
 				return err
 			}
 		}
 
 		// Add MAC address translation rule.
 		log.Printf("[net] Adding MAC DNAT rule for IP address %v", ipAddr.String())
-		if err := ebtables.SetDnatForIPAddress(client.hostPrimaryIfName, ipAddr.IP, client.containerMac, ebtables.Append); err != nil {
+		if err := ebtables.SetDnatForIPAddressInChain(chain, client.hostPrimaryIfName, ipAddr.IP, client.containerMac, ebtables.Append); err != nil {
 			return err
 		}
 
@@ -122,22 +129,20 @@ func (client *LinuxBridgeEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 }
 
 func (client *LinuxBridgeEndpointClient) DeleteEndpointRules(ep *endpoint) {
+	// Delete the chain of the endpoint with its rules. Endpoints created before the rules were added to a chain
+	// have their rules in PREROUTING.
+	chain := ebtables.EndpointChain(ep.Id)
+	log.Printf("[net] Deleting ebtables chain %v of endpoint %v.", chain, ep.Id)
+	inChain := true
+	if err := ebtables.DeleteEndpointChain(chain); err != nil {
+		log.Printf("[net] Failed to delete ebtables chain %v, deleting the rules from %v: %v.", chain, ebtables.PreRouting, err)
+		inChain = false
+	}
+
 	// Delete rules for IP addresses on the container interface.
 	for _, ipAddr := range ep.IPAddresses {
-		if ipAddr.IP.To4() != nil {
-			// Delete ARP reply rule.
-			log.Printf("[net] Deleting ARP reply rule for IP address %v on %v.", ipAddr.String(), ep.Id)
-			err := ebtables.SetArpReply(ipAddr.IP, client.getArpReplyAddress(ep.MacAddress), ebtables.Delete)
-			if err != nil {
-				log.Printf("[net] Failed to delete ARP reply rule for IP address %v: %v.", ipAddr.String(), err)
-			}
-		}
-
-		// Delete MAC address translation rule.
-		log.Printf("[net] Deleting MAC DNAT rule for IP address %v on %v.", ipAddr.String(), ep.Id)
-		err := ebtables.SetDnatForIPAddress(client.hostPrimaryIfName, ipAddr.IP, ep.MacAddress, ebtables.Delete)
-		if err != nil {
-			log.Printf("[net] Failed to delete MAC DNAT rule for IP address %v: %v.", ipAddr.String(), err)
+		if !inChain {
+			client.deletePreRoutingRules(ep, ipAddr)
 		}
 
 		if client.mode != opModeTunnel && ipAddr.IP.To4() != nil {
@@ -155,6 +160,26 @@ func (client *LinuxBridgeEndpointClient) DeleteEndpointRules(ep *endpoint) {
 	}
 }
 
+// deletePreRoutingRules deletes the rules for an IP address of an endpoint created before the rules were added
+// to the chain of the endpoint.
+func (client *LinuxBridgeEndpointClient) deletePreRoutingRules(ep *endpoint, ipAddr net.IPNet) {
+	if ipAddr.IP.To4() != nil {
+		// Delete ARP reply rule.
+		log.Printf("[net] Deleting ARP reply rule for IP address %v on %v.", ipAddr.String(), ep.Id)
+		err := ebtables.SetArpReply(ipAddr.IP, client.getArpReplyAddress(ep.MacAddress), ebtables.Delete)
+		if err != nil {
+			log.Printf("[net] Failed to delete ARP reply rule for IP address %v: %v.", ipAddr.String(), err)
+		}
+	}
+
+	// Delete MAC address translation rule.
+	log.Printf("[net] Deleting MAC DNAT rule for IP address %v on %v.", ipAddr.String(), ep.Id)
+	err := ebtables.SetDnatForIPAddress(client.hostPrimaryIfName, ipAddr.IP, ep.MacAddress, ebtables.Delete)
+	if err != nil {
+		log.Printf("[net] Failed to delete MAC DNAT rule for IP address %v: %v.", ipAddr.String(), err)
+	}
+}
+
 // getArpReplyAddress returns the MAC address to use in ARP replies.
 func (client *LinuxBridgeEndpointClient) getArpReplyAddress(epMacAddress net.HardwareAddr) net.HardwareAddr {
 	var macAddress net.HardwareAddr
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netio"
@@ -45,10 +46,18 @@ type endpoint struct {
 	NetworkContainerID       string
 	NetworkNameSpace         string `json:",omitempty"`
 	ContainerID              string
-	PODName                  string `json:",omitempty"`
-	PODNameSpace             string `json:",omitempty"`
-	InfraVnetAddressSpace    string `json:",omitempty"`
-	NetNs                    string `json:",omitempty"`
+	PODName                  string            `json:",omitempty"`
+	PODNameSpace             string            `json:",omitempty"`
+	InfraVnetAddressSpace    string            `json:",omitempty"`
+	NetNs                    string            `json:",omitempty"`
+	Bandwidth                *BandwidthInfo    `json:",omitempty"`
+	RouteTableID             int               `json:",omitempty"`
+	Resources                []Resource        `json:",omitempty"`
+	NodeLocalNAT             *NodeLocalNATInfo `json:",omitempty"`
+	VnetBlock                *VnetBlockInfo    `json:",omitempty"`
+	Mirror                   *MirrorInfo       `json:",omitempty"`
+	// Datapath is DatapathAccelerated or DatapathSynthetic if acceleration was requested for the endpoint.
+	Datapath string `json:",omitempty"`
 }
 
 // EndpointInfo contains read-only information about an endpoint.
@@ -83,6 +92,54 @@ type EndpointInfo struct {
 	VnetCidrs                string
 	ServiceCidrs             string
 	NATInfo                  []policy.NATInfo
+	Bandwidth                *BandwidthInfo
+	// EnableSourceRouting routes the traffic from the IPs of the endpoint through its own route table,
+	// so that pods with multiple interfaces reply through the interface of the IP they are reached on.
+	EnableSourceRouting bool
+	// RouteTableID is the route table allocated to the endpoint by the network manager for source routing.
+	RouteTableID int
+	// NodeLocalNAT allocates a node-local IP to the endpoint, see NodeLocalNATInfo.
+	NodeLocalNAT *NodeLocalNATInfo
+	// VnetBlock configures the host for the endpoint in the vnet-block mode, see VnetBlockInfo.
+	VnetBlock *VnetBlockInfo
+	// Mirror mirrors the traffic of the endpoint until it expires, see MirrorInfo.
+	Mirror *MirrorInfo
+	// AcceleratedNetworking backs the endpoint with an SR-IOV virtual function, see AcceleratedNetworkingInfo.
+	AcceleratedNetworking *AcceleratedNetworkingInfo
+	// Datapath is the datapath the endpoint was created with if AcceleratedNetworking was requested, set by
+	// CreateEndpoint.
+	Datapath string
+}
+
+// BandwidthInfo limits the bandwidth of an endpoint. Rates are in bits per second and bursts in bits.
+// Ingress is the traffic to the container, egress the traffic from the container.
+type BandwidthInfo struct {
+	IngressRate  uint64
+	IngressBurst uint64
+	EgressRate   uint64
+	EgressBurst  uint64
+}
+
+// Datapaths of the endpoints which requested acceleration.
+const (
+	// DatapathAccelerated is the datapath of the endpoints backed by an SR-IOV virtual function of the host NIC.
+	DatapathAccelerated = "accelerated"
+	// DatapathSynthetic is the datapath of the endpoints whose traffic goes through the synthetic vmNIC of the VM.
+	DatapathSynthetic = "synthetic"
+)
+
+// AcceleratedNetworkingInfo backs an endpoint with an SR-IOV virtual function of the host NIC, so that its traffic
+// bypasses the synthetic vmNIC path. If the endpoint can't be accelerated, it falls back to the synthetic datapath
+// unless DisableFallback is set. Windows with HNS v2 only.
+type AcceleratedNetworkingInfo struct {
+	DisableFallback bool
+}
+
+// MirrorInfo mirrors the traffic to and from an endpoint to a collector interface of the host until Expiry, for
+// debugging. Linux only.
+type MirrorInfo struct {
+	CollectorInterface string
+	Expiry             time.Time
 }
 
 // RouteInfo contains information about an IP route.
@@ -241,6 +298,13 @@ func (ep *endpoint) getInfo() *EndpointInfo {
 		PODName:                  ep.PODName,
 		PODNameSpace:             ep.PODNameSpace,
 		NetworkContainerID:       ep.NetworkContainerID,
+		Bandwidth:                ep.Bandwidth,
+		EnableSourceRouting:      ep.RouteTableID != 0,
+		RouteTableID:             ep.RouteTableID,
+		NodeLocalNAT:             ep.NodeLocalNAT,
+		VnetBlock:                ep.VnetBlock,
+		Mirror:                   ep.Mirror,
+		Datapath:                 ep.Datapath,
 	}
 
 	info.Routes = append(info.Routes, ep.Routes...)
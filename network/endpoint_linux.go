@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
@@ -26,6 +27,14 @@ const (
 	hostVEthInterfacePrefix = commonInterfacePrefix + "v"
 )
 
+// Kinds of the resources created for an endpoint.
+const (
+	resourceVeth          ResourceKind = "veth"
+	resourceEndpointRules ResourceKind = "endpointrules"
+	resourceIFB           ResourceKind = "ifb"
+	resourceSourceRouting ResourceKind = "sourcerouting"
+)
+
 type AzureHNSEndpointClient interface{}
 
 func generateVethName(key string) string {
@@ -64,6 +73,7 @@ func (nw *network) newEndpointImpl(
 	var contIfName string
 	var localIP string
 	var vlanid int = 0
+	var resources []Resource
 
 	if nw.Endpoints[epInfo.Id] != nil {
 		log.Printf("[net] Endpoint alreday exists.")
@@ -121,7 +131,7 @@ func (nw *network) newEndpointImpl(
 					ovsctl.NewOvsctl(),
 					plc)
 			}
-		} else if nw.Mode != opModeTransparent {
+		} else if !isTransparentMode(nw.Mode) {
 			log.Printf("Bridge client")
 			epClient = NewLinuxBridgeEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode, nl, plc)
 		} else {
@@ -159,12 +169,15 @@ func (nw *network) newEndpointImpl(
 			// set deleteHostVeth to true to cleanup host veth interface if created
 			//nolint:errcheck // ignore error
 			epClient.DeleteEndpoints(endpt)
+			deleteBandwidth(nl, hostIfName, epInfo.Bandwidth)
 		}
 	}()
 
 	if err = epClient.AddEndpoints(epInfo); err != nil {
 		return nil, err
 	}
+	veth := Resource{Kind: resourceVeth, ID: hostIfName}
+	resources = append(resources, veth)
 
 	containerIf, err = netioCli.GetNetworkInterfaceByName(contIfName)
 	if err != nil {
@@ -175,6 +188,24 @@ func (nw *network) newEndpointImpl(
 	if err = epClient.AddEndpointRules(epInfo); err != nil {
 		return nil, err
 	}
+	resources = append(resources, Resource{Kind: resourceEndpointRules, ID: epInfo.Id, DependsOn: []string{veth.Key()}})
+
+	// Limit the bandwidth on the host veth, before entering the container network namespace.
+	if epInfo.Bandwidth != nil {
+		if err = setupBandwidth(nl, plc, hostIfName, epInfo.Bandwidth); err != nil {
+			return nil, err
+		}
+		if epInfo.Bandwidth.EgressRate > 0 {
+			resources = append(resources, Resource{Kind: resourceIFB, ID: getIFBName(hostIfName), DependsOn: []string{veth.Key()}})
+		}
+	}
+
+	// Mirror the traffic on the host veth, after the bandwidth qdiscs it shares. The filters are deleted with the veth.
+	if epInfo.Mirror != nil {
+		if err = setupMirror(plc, hostIfName, epInfo.Mirror, epInfo.Bandwidth); err != nil {
+			return nil, err
+		}
+	}
 
 	// If a network namespace for the container interface is specified...
 	if epInfo.NetNsPath != "" {
@@ -225,6 +256,21 @@ func (nw *network) newEndpointImpl(
 		return nil, err
 	}
 
+	if epInfo.RouteTableID != 0 {
+		ifName := epInfo.IfName
+		if ifName == "" {
+			ifName = contIfName
+		}
+		if err = setupSourceRouting(nl, netioCli, ifName, epInfo); err != nil {
+			return nil, err
+		}
+		resources = append(resources, Resource{
+			Kind:      resourceSourceRouting,
+			ID:        strconv.Itoa(epInfo.RouteTableID),
+			DependsOn: []string{veth.Key()},
+		})
+	}
+
 	// Create the endpoint object.
 	ep = &endpoint{
 		Id:                       epInfo.Id,
@@ -245,6 +291,12 @@ func (nw *network) newEndpointImpl(
 		ContainerID:              epInfo.ContainerID,
 		PODName:                  epInfo.PODName,
 		PODNameSpace:             epInfo.PODNameSpace,
+		Bandwidth:                epInfo.Bandwidth,
+		RouteTableID:             epInfo.RouteTableID,
+		NodeLocalNAT:             epInfo.NodeLocalNAT,
+		VnetBlock:                epInfo.VnetBlock,
+		Mirror:                   epInfo.Mirror,
+		Resources:                resources,
 	}
 
 	if nw.extIf != nil {
@@ -273,18 +325,41 @@ func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.
 			} else {
 				epClient = NewOVSEndpointClient(nw, epInfo, ep.HostIfName, "", ep.VlanID, ep.LocalIP, nl, ovsctl.NewOvsctl(), plc)
 			}
-		} else if nw.Mode != opModeTransparent {
+		} else if !isTransparentMode(nw.Mode) {
 			epClient = NewLinuxBridgeEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode, nl, plc)
 		} else {
 			epClient = NewTransparentEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode, nl, plc)
 		}
 	}
 
-	epClient.DeleteEndpointRules(ep)
-	// deleteHostVeth set to false not to delete veth as CRI will remove network namespace and
-	// veth will get removed as part of that.
-	//nolint:errcheck // ignore error
-	epClient.DeleteEndpoints(ep)
+	// Endpoints created before their resources were recorded are deleted in a fixed order.
+	if len(ep.Resources) == 0 {
+		epClient.DeleteEndpointRules(ep)
+		// deleteHostVeth set to false not to delete veth as CRI will remove network namespace and
+		// veth will get removed as part of that.
+		//nolint:errcheck // ignore error
+		epClient.DeleteEndpoints(ep)
+		deleteBandwidth(nl, ep.HostIfName, ep.Bandwidth)
+		return nil
+	}
+
+	deleters := map[ResourceKind]resourceDeleter{
+		resourceEndpointRules: func(string) error {
+			epClient.DeleteEndpointRules(ep)
+			return nil
+		},
+		resourceVeth: func(string) error {
+			return epClient.DeleteEndpoints(ep)
+		},
+		resourceIFB: nl.DeleteLink,
+		resourceSourceRouting: func(string) error {
+			return deleteSourceRouting(nl, ep)
+		},
+	}
+	// The veth may already be removed with the container network namespace by CRI, so failures are only logged.
+	if err := teardownResources(ep.Resources, deleters); err != nil {
+		log.Printf("[net] Failed to tear down endpoint %s: %v", ep.Id, err)
+	}
 
 	return nil
 }
@@ -5,6 +5,8 @@ package network
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -37,6 +39,16 @@ const (
 	// hostNCApipaEndpointName indicates the prefix for the name of the apipa endpoint used for
 	// the host container connectivity
 	hostNCApipaEndpointNamePrefix = "HostNCApipaEndpoint"
+
+	// iovOffloadWeightFull offloads all the traffic of an accelerated endpoint to its virtual function
+	iovOffloadWeightFull = 100
+)
+
+// Kinds of the resources created for an endpoint.
+const (
+	resourceHcnEndpoint          ResourceKind = "hcnendpoint"
+	resourceHcnNamespaceEndpoint ResourceKind = "hcnnamespaceendpoint"
+	resourceHostNCApipaEndpoint  ResourceKind = "hostncapipaendpoint"
 )
 
 // ConstructEndpointID constructs endpoint name from netNsPath.
@@ -86,6 +98,15 @@ func (nw *network) newEndpointImplHnsV1(epInfo *EndpointInfo) (*endpoint, error)
 		}
 	}
 
+	var datapath string
+	if epInfo.AcceleratedNetworking != nil {
+		if epInfo.AcceleratedNetworking.DisableFallback {
+			return nil, fmt.Errorf("%w with HNS v1", errAccelerationUnsupported)
+		}
+		log.Printf("[net] Accelerated networking is unsupported with HNS v1, using the synthetic datapath")
+		datapath = DatapathSynthetic
+	}
+
 	// Get Infrastructure containerID. Handle ADD calls for workload container.
 	var err error
 	infraEpName, _ := ConstructEndpointID(epInfo.ContainerID, epInfo.NetNsPath, epInfo.IfName)
@@ -158,6 +179,7 @@ func (nw *network) newEndpointImplHnsV1(epInfo *EndpointInfo) (*endpoint, error)
 		EnableSnatOnHost: epInfo.EnableSnatOnHost,
 		NetNs:            epInfo.NetNsPath,
 		ContainerID:      epInfo.ContainerID,
+		Datapath:         datapath,
 	}
 
 	for _, route := range epInfo.Routes {
@@ -220,6 +242,14 @@ func (nw *network) configureHcnEndpoint(epInfo *EndpointInfo) (*hcn.HostComputeE
 		return nil, err
 	}
 
+	if epInfo.AcceleratedNetworking != nil {
+		iovPolicy, err := iovEndpointPolicy()
+		if err != nil {
+			return nil, err
+		}
+		hcnEndpoint.Policies = append(hcnEndpoint.Policies, iovPolicy)
+	}
+
 	for _, route := range epInfo.Routes {
 		hcnRoute := hcn.Route{
 			NextHop:           route.Gw.String(),
@@ -308,6 +338,47 @@ func (nw *network) createHostNCApipaEndpoint(cli apipaClient, epInfo *EndpointIn
 	return nil
 }
 
+// iovEndpointPolicy returns the policy backing an hcn endpoint with an SR-IOV virtual function.
+func iovEndpointPolicy() (hcn.EndpointPolicy, error) {
+	settings, err := json.Marshal(hcn.IovPolicySetting{IovOffloadWeight: iovOffloadWeightFull})
+	if err != nil {
+		return hcn.EndpointPolicy{}, fmt.Errorf("failed to marshal iov policy settings: %w", err)
+	}
+	return hcn.EndpointPolicy{Type: hcn.IOV, Settings: settings}, nil
+}
+
+// withoutIovEndpointPolicy returns the policies without the iov ones, to fall back to the synthetic datapath.
+func withoutIovEndpointPolicy(policies []hcn.EndpointPolicy) []hcn.EndpointPolicy {
+	var synthetic []hcn.EndpointPolicy
+	for _, epPolicy := range policies {
+		if epPolicy.Type != hcn.IOV {
+			synthetic = append(synthetic, epPolicy)
+		}
+	}
+	return synthetic
+}
+
+// createHcnEndpoint creates the hcn endpoint, and returns its datapath if acceleration was requested. An endpoint
+// which can't be accelerated is created again with the synthetic datapath, unless the fallback is disabled.
+func createHcnEndpoint(hcnEndpoint *hcn.HostComputeEndpoint, epInfo *EndpointInfo) (*hcn.HostComputeEndpoint, string, error) {
+	hnsResponse, err := Hnsv2.CreateEndpoint(hcnEndpoint)
+	if epInfo.AcceleratedNetworking == nil {
+		return hnsResponse, "", err //nolint:wrapcheck // wrapped by the caller
+	}
+	if err == nil {
+		return hnsResponse, DatapathAccelerated, nil
+	}
+	if epInfo.AcceleratedNetworking.DisableFallback {
+		return nil, "", fmt.Errorf("failed to create accelerated endpoint: %w", err)
+	}
+
+	log.Printf("[net] Failed to create accelerated hcn endpoint: %s due to error: %v, falling back to the synthetic datapath",
+		hcnEndpoint.Name, err)
+	hcnEndpoint.Policies = withoutIovEndpointPolicy(hcnEndpoint.Policies)
+	hnsResponse, err = Hnsv2.CreateEndpoint(hcnEndpoint)
+	return hnsResponse, DatapathSynthetic, err //nolint:wrapcheck // wrapped by the caller
+}
+
 // newEndpointImplHnsV2 creates a new endpoint in the network using Hnsv2
 func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (*endpoint, error) {
 	hcnEndpoint, err := nw.configureHcnEndpoint(epInfo)
@@ -318,7 +389,7 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 
 	// Create the HCN endpoint.
 	log.Printf("[net] Creating hcn endpoint: %s computenetwork:%s", hcnEndpoint.Name, hcnEndpoint.HostComputeNetwork)
-	hnsResponse, err := Hnsv2.CreateEndpoint(hcnEndpoint)
+	hnsResponse, datapath, err := createHcnEndpoint(hcnEndpoint, epInfo)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create endpoint: %s due to error: %v", hcnEndpoint.Name, err)
 	}
@@ -342,6 +413,11 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 		return nil, fmt.Errorf("[net] Failed to add endpoint: %s to hcn namespace: %s due to error: %v",
 			hnsResponse.Id, namespace.Id, err)
 	}
+	hcnEndpointResource := Resource{Kind: resourceHcnEndpoint, ID: hnsResponse.Id}
+	resources := []Resource{
+		hcnEndpointResource,
+		{Kind: resourceHcnNamespaceEndpoint, ID: namespace.Id, DependsOn: []string{hcnEndpointResource.Key()}},
+	}
 
 	defer func() {
 		if err != nil {
@@ -357,6 +433,7 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 		if err = nw.createHostNCApipaEndpoint(cli, epInfo); err != nil {
 			return nil, fmt.Errorf("Failed to create HostNCApipaEndpoint due to error: %v", err)
 		}
+		resources = append(resources, Resource{Kind: resourceHostNCApipaEndpoint, ID: epInfo.NetworkContainerID})
 	}
 
 	var vlanid int
@@ -386,6 +463,8 @@ func (nw *network) newEndpointImplHnsV2(cli apipaClient, epInfo *EndpointInfo) (
 		AllowInboundFromNCToHost: epInfo.AllowInboundFromNCToHost,
 		AllowInboundFromHostToNC: epInfo.AllowInboundFromHostToNC,
 		NetworkContainerID:       epInfo.NetworkContainerID,
+		Resources:                resources,
+		Datapath:                 datapath,
 	}
 
 	for _, route := range epInfo.Routes {
@@ -436,6 +515,10 @@ func (nw *network) deleteEndpointImplHnsV2(ep *endpoint) error {
 		err         error
 	)
 
+	if len(ep.Resources) > 0 {
+		return nw.teardownEndpointResourcesHnsV2(ep)
+	}
+
 	if ep.AllowInboundFromHostToNC || ep.AllowInboundFromNCToHost {
 		if err = nw.deleteHostNCApipaEndpoint(ep.NetworkContainerID); err != nil {
 			log.Errorf("[net] Failed to delete HostNCApipaEndpoint due to error: %v", err)
@@ -472,6 +555,46 @@ func (nw *network) deleteEndpointImplHnsV2(ep *endpoint) error {
 	return nil
 }
 
+// teardownEndpointResourcesHnsV2 deletes the resources recorded for the endpoint when it was created.
+// The endpoint is only deleted once it is removed from its namespace.
+func (nw *network) teardownEndpointResourcesHnsV2(ep *endpoint) error {
+	deleters := map[ResourceKind]resourceDeleter{
+		resourceHostNCApipaEndpoint: nw.deleteHostNCApipaEndpoint,
+		resourceHcnNamespaceEndpoint: func(namespaceID string) error {
+			err := Hnsv2.RemoveNamespaceEndpoint(namespaceID, ep.HnsId)
+			if err != nil && !isHcnNotFound(err) {
+				return fmt.Errorf("failed to remove hcn endpoint: %s from namespace: %s due to error: %w", ep.HnsId, namespaceID, err)
+			}
+			return nil
+		},
+		resourceHcnEndpoint: func(id string) error {
+			hcnEndpoint, err := Hnsv2.GetEndpointByID(id)
+			if err != nil {
+				if isHcnNotFound(err) {
+					log.Printf("[net] Delete called on the Endpoint: %s which doesn't exist. Error: %v", id, err)
+					return nil
+				}
+				return fmt.Errorf("failed to get hcn endpoint with id: %s due to err: %w", id, err)
+			}
+			if err = Hnsv2.DeleteEndpoint(hcnEndpoint); err != nil {
+				return fmt.Errorf("failed to delete hcn endpoint: %s due to error: %w", id, err)
+			}
+			log.Printf("[net] Successfully deleted hcn endpoint with id: %s", id)
+			return nil
+		},
+	}
+
+	return teardownResources(ep.Resources, deleters)
+}
+
+// isHcnNotFound returns true if the hcn object of the error is already deleted.
+func isHcnNotFound(err error) bool {
+	var endpointNotFound hcn.EndpointNotFoundError
+	var namespaceNotFound hcn.NamespaceNotFoundError
+	return errors.As(err, &endpointNotFound) || errors.As(err, &namespaceNotFound) ||
+		strings.Contains(strings.ToLower(err.Error()), "not found")
+}
+
 // getInfoImpl returns information about the endpoint.
 func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
 	epInfo.Data["hnsid"] = ep.HnsId
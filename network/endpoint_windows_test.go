@@ -7,12 +7,14 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-container-networking/network/hnswrapper"
+	"github.com/Microsoft/hcsshim/hcn"
 )
 
 func TestNewAndDeleteEndpointImplHnsV2(t *testing.T) {
@@ -207,3 +209,81 @@ func TestDeleteEndpointImplHnsv1Timeout(t *testing.T) {
 		t.Fatal("Failed to timeout HNS calls for deleting endpoint")
 	}
 }
+
+// noVFHnsv2Fake fails to create the endpoints backed by a virtual function, like a VM without virtual functions left.
+type noVFHnsv2Fake struct {
+	hnswrapper.Hnsv2wrapperFake
+}
+
+func (f noVFHnsv2Fake) CreateEndpoint(endpoint *hcn.HostComputeEndpoint) (*hcn.HostComputeEndpoint, error) {
+	for _, epPolicy := range endpoint.Policies {
+		if epPolicy.Type == hcn.IOV {
+			return nil, errors.New("no virtual function available")
+		}
+	}
+	return f.Hnsv2wrapperFake.CreateEndpoint(endpoint)
+}
+
+func TestNewEndpointImplHnsV2Accelerated(t *testing.T) {
+	tests := []struct {
+		name         string
+		hns          hnswrapper.HnsV2WrapperInterface
+		accelerated  *AcceleratedNetworkingInfo
+		wantDatapath string
+		wantErr      bool
+	}{
+		{
+			name: "not requested",
+			hns:  hnswrapper.NewHnsv2wrapperFake(),
+		},
+		{
+			name:         "accelerated",
+			hns:          hnswrapper.NewHnsv2wrapperFake(),
+			accelerated:  &AcceleratedNetworkingInfo{},
+			wantDatapath: DatapathAccelerated,
+		},
+		{
+			name:         "fallback to synthetic",
+			hns:          noVFHnsv2Fake{Hnsv2wrapperFake: *hnswrapper.NewHnsv2wrapperFake()},
+			accelerated:  &AcceleratedNetworkingInfo{},
+			wantDatapath: DatapathSynthetic,
+		},
+		{
+			name:        "fallback disabled",
+			hns:         noVFHnsv2Fake{Hnsv2wrapperFake: *hnswrapper.NewHnsv2wrapperFake()},
+			accelerated: &AcceleratedNetworkingInfo{DisableFallback: true},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nw := &network{
+				Endpoints: map[string]*endpoint{},
+			}
+			Hnsv2 = hnswrapper.Hnsv2wrapperwithtimeout{Hnsv2: tt.hns}
+			epInfo := &EndpointInfo{
+				Id:                    "753d3fb6-e9b3-49e2-a109-2acc5dda61f1",
+				ContainerID:           "545055c2-1462-42c8-b222-e75d0b291632",
+				NetNsPath:             "fakeNameSpace",
+				IfName:                "eth0",
+				Data:                  make(map[string]interface{}),
+				MacAddress:            net.HardwareAddr("00:00:5e:00:53:01"),
+				AcceleratedNetworking: tt.accelerated,
+			}
+			ep, err := nw.newEndpointImplHnsV2(nil, epInfo)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error but received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ep.Datapath != tt.wantDatapath {
+				t.Fatalf("got datapath %q, want %q", ep.Datapath, tt.wantDatapath)
+			}
+		})
+	}
+}
@@ -5,4 +5,7 @@ import "errors"
 var (
 	errSubnetV6NotFound = errors.New("Couldn't find ipv6 subnet in network info")
 	errV6SnatRuleNotSet = errors.New("ipv6 snat rule not set. Might be VM ipv6 address missing")
+	errHostIfNotReady   = errors.New("host interface has no IP address yet")
+	// errAccelerationUnsupported is returned when an endpoint requires acceleration which the platform doesn't support.
+	errAccelerationUnsupported = errors.New("accelerated networking is unsupported")
 )
@@ -81,6 +81,9 @@ type NetworkManager interface {
 	CreateNetwork(nwInfo *NetworkInfo) error
 	DeleteNetwork(networkID string) error
 	GetNetworkInfo(networkID string) (NetworkInfo, error)
+	// RepairNetwork rebuilds the host configuration of the network if it is missing, like the bridge after a reboot,
+	// and returns whether it did
+	RepairNetwork(networkID string) (bool, error)
 	// FindNetworkIDFromNetNs returns the network name that contains an endpoint created for this netNS, errNetworkNotFound if no network is found
 	FindNetworkIDFromNetNs(netNs string) (string, error)
 	GetNumEndpointsByContainerID(containerID string) int
@@ -95,6 +98,11 @@ type NetworkManager interface {
 	UpdateEndpoint(networkID string, existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) error
 	GetNumberOfEndpoints(ifName string, networkID string) int
 	SetupNetworkUsingState(networkMonitor *cnms.NetworkMonitor) error
+	// RebuildState adds the endpoints of the network found on the host to the state, and returns their IDs
+	RebuildState(nwInfo *NetworkInfo) ([]string, error)
+	// RemoveExpiredMirrors stops mirroring the traffic of the endpoints of the network whose mirror expired, and
+	// returns their IDs
+	RemoveExpiredMirrors(networkID string, now time.Time) ([]string, error)
 }
 
 // Creates a new network manager.
@@ -330,6 +338,58 @@ func (nm *networkManager) GetNetworkInfo(networkId string) (NetworkInfo, error)
 	return nwInfo, nil
 }
 
+// RepairNetwork rebuilds the host configuration of the given network if it is missing.
+func (nm *networkManager) RepairNetwork(networkID string) (bool, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return false, err
+	}
+
+	repaired, err := nm.repairNetworkImpl(nw)
+	if err != nil || !repaired {
+		return false, err
+	}
+
+	err = nm.save()
+	if err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// RemoveExpiredMirrors stops mirroring the traffic of the endpoints of the given network whose mirror expired.
+func (nm *networkManager) RemoveExpiredMirrors(networkID string, now time.Time) ([]string, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for id, ep := range nw.Endpoints {
+		if ep.Mirror == nil || now.Before(ep.Mirror.Expiry) {
+			continue
+		}
+		// the mirror is dropped from the state even if its filters are gone already, e.g. after a reboot
+		if err := deleteMirror(nm.plClient, ep.HostIfName, ep.Bandwidth); err != nil {
+			log.Printf("[net] Failed to delete the mirror of endpoint %s: %v", id, err)
+		}
+		ep.Mirror = nil
+		removed = append(removed, id)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	return removed, nm.save()
+}
+
 // CreateEndpoint creates a new container endpoint.
 func (nm *networkManager) CreateEndpoint(cli apipaClient, networkID string, epInfo *EndpointInfo) error {
 	nm.Lock()
@@ -347,10 +407,23 @@ func (nm *networkManager) CreateEndpoint(cli apipaClient, networkID string, epIn
 		}
 	}
 
-	_, err = nw.newEndpoint(cli, nm.netlink, nm.plClient, nm.netio, epInfo)
+	if epInfo.EnableSourceRouting {
+		if epInfo.RouteTableID, err = nm.allocateRouteTableID(epInfo.NetNsPath); err != nil {
+			return err
+		}
+	}
+
+	if epInfo.NodeLocalNAT != nil {
+		if err = nm.allocateNodeLocalNATIP(epInfo); err != nil {
+			return err
+		}
+	}
+
+	ep, err := nw.newEndpoint(cli, nm.netlink, nm.plClient, nm.netio, epInfo)
 	if err != nil {
 		return err
 	}
+	epInfo.Datapath = ep.Datapath
 
 	err = nm.save()
 	if err != nil {
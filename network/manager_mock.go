@@ -1,6 +1,8 @@
 package network
 
 import (
+	"time"
+
 	cnms "github.com/Azure/azure-container-networking/cnms/cnmspackage"
 	"github.com/Azure/azure-container-networking/common"
 )
@@ -51,6 +53,11 @@ func (nm *MockNetworkManager) GetNetworkInfo(networkID string) (NetworkInfo, err
 	return NetworkInfo{}, errNetworkNotFound
 }
 
+// RepairNetwork mock
+func (nm *MockNetworkManager) RepairNetwork(networkID string) (bool, error) {
+	return false, nil
+}
+
 // CreateEndpoint mock
 func (nm *MockNetworkManager) CreateEndpoint(_ apipaClient, networkID string, epInfo *EndpointInfo) error {
 	nm.TestEndpointInfoMap[epInfo.Id] = epInfo
@@ -129,3 +136,13 @@ func (nm *MockNetworkManager) GetNumEndpointsByContainerID(_ string) int {
 
 	return numEndpoints
 }
+
+// RebuildState mock
+func (nm *MockNetworkManager) RebuildState(_ *NetworkInfo) ([]string, error) {
+	return nil, nil
+}
+
+// RemoveExpiredMirrors mock
+func (nm *MockNetworkManager) RemoveExpiredMirrors(_ string, _ time.Time) ([]string, error) {
+	return nil, nil
+}
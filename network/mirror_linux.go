@@ -0,0 +1,72 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Handle of the prio qdisc classifying the traffic to the container for its mirror.
+	mirrorQdiscHandle = "1:"
+	// Priority of the mirror filters, ahead of the redirect of the bandwidth limit.
+	mirrorFilterPriority = 1
+)
+
+// mirrorFilterCommand returns the command mirroring the traffic classified by the qdisc to the collector. The traffic
+// continues to the next filters, e.g. the redirect to the ifb device of the bandwidth limit.
+func mirrorFilterCommand(hostIfName, parent, collector string) string {
+	return fmt.Sprintf("tc filter add dev %s parent %s prio %d protocol all u32 match u32 0 0 action mirred egress mirror dev %s continue",
+		hostIfName, parent, mirrorFilterPriority, collector)
+}
+
+// setupMirror mirrors the traffic of the endpoint on its host veth to the collector interface.
+// The traffic from the container enters the host veth and is mirrored by a filter of its ingress qdisc, which the
+// bandwidth limit may have added already. The traffic to the container leaves the host veth and is mirrored by a filter
+// of a prio root qdisc, unless the ingress bandwidth limit has its own root qdisc, to which filters can't be attached.
+func setupMirror(plc platform.ExecClient, hostIfName string, mirror *MirrorInfo, bw *BandwidthInfo) error {
+	log.Printf("[net] Mirroring the traffic of %s to %s until %s", hostIfName, mirror.CollectorInterface, mirror.Expiry)
+
+	var cmds []string
+	if bw == nil || bw.EgressRate == 0 {
+		cmds = append(cmds, fmt.Sprintf("tc qdisc add dev %s handle ffff: ingress", hostIfName))
+	}
+	cmds = append(cmds, mirrorFilterCommand(hostIfName, "ffff:", mirror.CollectorInterface))
+	if bw == nil || bw.IngressRate == 0 {
+		cmds = append(cmds,
+			fmt.Sprintf("tc qdisc add dev %s handle %s root prio", hostIfName, mirrorQdiscHandle),
+			mirrorFilterCommand(hostIfName, mirrorQdiscHandle, mirror.CollectorInterface))
+	} else {
+		log.Printf("[net] Not mirroring the traffic to %s, which has an ingress bandwidth limit", hostIfName)
+	}
+
+	for _, cmd := range cmds {
+		if _, err := plc.ExecuteCommand(cmd); err != nil {
+			return errors.Wrapf(err, "failed to mirror the traffic of %s to %s", hostIfName, mirror.CollectorInterface)
+		}
+	}
+	return nil
+}
+
+// deleteMirror stops mirroring the traffic of the endpoint, deleting the filters and qdiscs added by setupMirror but
+// not those of the bandwidth limit.
+func deleteMirror(plc platform.ExecClient, hostIfName string, bw *BandwidthInfo) error {
+	log.Printf("[net] Deleting the mirror of the traffic of %s", hostIfName)
+
+	cmds := []string{fmt.Sprintf("tc filter del dev %s parent ffff: prio %d", hostIfName, mirrorFilterPriority)}
+	if bw == nil || bw.EgressRate == 0 {
+		cmds = append(cmds, fmt.Sprintf("tc qdisc del dev %s handle ffff: ingress", hostIfName))
+	}
+	if bw == nil || bw.IngressRate == 0 {
+		cmds = append(cmds, fmt.Sprintf("tc qdisc del dev %s handle %s root", hostIfName, mirrorQdiscHandle))
+	}
+
+	for _, cmd := range cmds {
+		if _, err := plc.ExecuteCommand(cmd); err != nil {
+			return errors.Wrapf(err, "failed to delete the mirror of the traffic of %s", hostIfName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupMirror(t *testing.T) {
+	mirror := &MirrorInfo{CollectorInterface: "erspan0", Expiry: time.Now().Add(time.Hour)}
+	tests := []struct {
+		name string
+		bw   *BandwidthInfo
+		cmds []string
+	}{
+		{
+			name: "no bandwidth limit",
+			cmds: []string{
+				"tc qdisc add dev azv0123456789a handle ffff: ingress",
+				"tc filter add dev azv0123456789a parent ffff: prio 1 protocol all u32 match u32 0 0 action mirred egress mirror dev erspan0 continue",
+				"tc qdisc add dev azv0123456789a handle 1: root prio",
+				"tc filter add dev azv0123456789a parent 1: prio 1 protocol all u32 match u32 0 0 action mirred egress mirror dev erspan0 continue",
+			},
+		},
+		{
+			name: "bandwidth limits",
+			bw:   &BandwidthInfo{IngressRate: 1000000, IngressBurst: 80000, EgressRate: 2000000, EgressBurst: 160000},
+			cmds: []string{
+				"tc filter add dev azv0123456789a parent ffff: prio 1 protocol all u32 match u32 0 0 action mirred egress mirror dev erspan0 continue",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var cmds []string
+			plc := platform.NewMockExecClient(false)
+			plc.SetExecCommand(func(cmd string) (string, error) {
+				cmds = append(cmds, cmd)
+				return "", nil
+			})
+
+			require.NoError(t, setupMirror(plc, "azv0123456789a", mirror, tt.bw))
+			require.Equal(t, tt.cmds, cmds)
+		})
+	}
+}
+
+func TestRemoveExpiredMirrors(t *testing.T) {
+	now := time.Now()
+	var cmds []string
+	plc := platform.NewMockExecClient(false)
+	plc.SetExecCommand(func(cmd string) (string, error) {
+		cmds = append(cmds, cmd)
+		return "", nil
+	})
+	expired := &endpoint{Id: "expired", HostIfName: "azv0123456789a", Mirror: &MirrorInfo{CollectorInterface: "erspan0", Expiry: now}}
+	active := &endpoint{Id: "active", HostIfName: "azv0123456789b", Mirror: &MirrorInfo{CollectorInterface: "erspan0", Expiry: now.Add(time.Minute)}}
+	nm := &networkManager{
+		plClient: plc,
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {Networks: map[string]*network{
+				"azure": {Id: "azure", Endpoints: map[string]*endpoint{"expired": expired, "active": active, "none": {Id: "none"}}},
+			}},
+		},
+	}
+
+	removed, err := nm.RemoveExpiredMirrors("azure", now)
+	require.NoError(t, err)
+	require.Equal(t, []string{"expired"}, removed)
+	require.Nil(t, expired.Mirror)
+	require.NotNil(t, active.Mirror)
+	require.Equal(t, []string{
+		"tc filter del dev azv0123456789a parent ffff: prio 1",
+		"tc qdisc del dev azv0123456789a handle ffff: ingress",
+		"tc qdisc del dev azv0123456789a handle 1: root",
+	}, cmds)
+}
@@ -0,0 +1,8 @@
+package network
+
+import "github.com/Azure/azure-container-networking/platform"
+
+// deleteMirror does nothing, the traffic of the endpoints isn't mirrored on Windows.
+func deleteMirror(_ platform.ExecClient, _ string, _ *BandwidthInfo) error {
+	return nil
+}
@@ -1,11 +1,14 @@
 package network
 
 import (
+	"bytes"
 	"fmt"
+	"net"
 
 	cnms "github.com/Azure/azure-container-networking/cnms/cnmspackage"
 	"github.com/Azure/azure-container-networking/ebtables"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
 )
 
 const (
@@ -20,16 +23,27 @@ func (nm *networkManager) monitorNetworkState(networkMonitor *cnms.NetworkMonito
 		return err
 	}
 
-	currentStateRulesMap := nm.AddStateRulesToMap()
+	endpointChains, err := ebtables.GetEndpointChains()
+	if err != nil {
+		log.Printf("GetEndpointChains failed with error %v", err)
+		return err
+	}
+
+	currentStateRulesMap := nm.AddStateRulesToMap(endpointChains)
 	networkMonitor.CreateRequiredL2Rules(currentEbtableRulesMap, currentStateRulesMap)
-	networkMonitor.RemoveInvalidL2Rules(currentEbtableRulesMap, currentStateRulesMap)
+	networkMonitor.RemoveInvalidL2Rules(currentEbtableRulesMap, currentStateRulesMap, nm.deleteEndpointChain)
 
 	return nil
 }
 
 // AddStateRulesToMap adds rules to state based off network manager settings.
-func (nm *networkManager) AddStateRulesToMap() map[string]string {
+// The rules of endpoints with a chain are in the chain, so only the jump to the chain is in state.
+func (nm *networkManager) AddStateRulesToMap(endpointChains []string) map[string]string {
 	rulesMap := make(map[string]string)
+	chains := make(map[string]struct{}, len(endpointChains))
+	for _, chain := range endpointChains {
+		chains[chain] = struct{}{}
+	}
 
 	for _, extIf := range nm.ExternalInterfaces {
 		arpDnatKey := fmt.Sprintf("-p ARP -i %s --arp-op Reply -j dnat --to-dst ff:ff:ff:ff:ff:ff --dnat-target ACCEPT", extIf.Name)
@@ -47,6 +61,12 @@ func (nm *networkManager) AddStateRulesToMap() map[string]string {
 
 		for _, nw := range extIf.Networks {
 			for _, ep := range nw.Endpoints {
+				chain := ebtables.EndpointChain(ep.Id)
+				if _, ok := chains[chain]; ok {
+					rulesMap["-j "+chain] = ebtables.PreRouting
+					continue
+				}
+
 				for _, ipAddr := range ep.IPAddresses {
 					if ipAddr.IP.To4() != nil {
 						arpReplyKey := fmt.Sprintf("-p ARP --arp-op Request --arp-ip-dst %s -j arpreply --arpreply-mac %s", ipAddr.IP.String(), ep.MacAddress.String())
@@ -72,3 +92,54 @@ func (nm *networkManager) AddStateRulesToMap() map[string]string {
 
 	return rulesMap
 }
+
+// deleteEndpointChain deletes the chain of an endpoint which isn't in state anymore, with the static ARP entries
+// of its IP addresses. The ARP reply rules of the chain record which entries the endpoint owned; entries of IP
+// addresses which are used by another endpoint are kept.
+func (nm *networkManager) deleteEndpointChain(chain string) error {
+	arpReplies, err := ebtables.GetArpReplies(chain)
+	if err != nil {
+		return err
+	}
+
+	if err := ebtables.DeleteEndpointChain(chain); err != nil {
+		return err
+	}
+
+	inUse := make(map[string]struct{})
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				for _, ipAddr := range ep.IPAddresses {
+					inUse[ipAddr.IP.String()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// in tunnel mode, ARP replies resolve to the virtual MAC address and there are no static ARP entries
+	virtualMac, _ := net.ParseMAC(virtualMacAddress)
+	for ip, mac := range arpReplies {
+		if _, ok := inUse[ip]; ok || bytes.Equal(mac, virtualMac) {
+			continue
+		}
+
+		for _, extIf := range nm.ExternalInterfaces {
+			if extIf.BridgeName == "" {
+				continue
+			}
+
+			log.Printf("[monitor] Removing static arp for IP address %v and MAC %v of endpoint chain %v", ip, mac.String(), chain)
+			linkInfo := netlink.LinkInfo{
+				Name:       extIf.BridgeName,
+				IPAddr:     net.ParseIP(ip),
+				MacAddress: mac,
+			}
+			if err := nm.netlink.SetOrRemoveLinkAddress(linkInfo, netlink.REMOVE, netlink.NUD_INCOMPLETE); err != nil {
+				log.Printf("[monitor] Failed removing arp for IP address %v: %v", ip, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -7,16 +7,27 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-container-networking/netlink"
 
 	"golang.org/x/sys/unix"
 )
 
+// netnsDuration is the total time threads of this process have spent inside other network namespaces, in nanoseconds.
+var netnsDuration int64
+
 // Namespace represents a network namespace.
 type Namespace struct {
-	file   *os.File
-	prevNs *Namespace
+	file      *os.File
+	prevNs    *Namespace
+	enteredAt time.Time
+}
+
+// NetnsDuration returns the total time this process has spent inside other network namespaces.
+func NetnsDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&netnsDuration))
 }
 
 // OpenNamespace creates a new namespace object for the given netns path.
@@ -86,6 +97,8 @@ func (ns *Namespace) Enter() error {
 	// Recycle the netlink socket for the new network namespace.
 	netlink.ResetSocket()
 
+	ns.enteredAt = time.Now()
+
 	return nil
 }
 
@@ -99,6 +112,8 @@ func (ns *Namespace) Exit() error {
 	ns.prevNs.Close()
 	ns.prevNs = nil
 
+	atomic.AddInt64(&netnsDuration, int64(time.Since(ns.enteredAt)))
+
 	runtime.UnlockOSThread()
 
 	// Recycle the netlink socket for the new network namespace.
@@ -19,9 +19,17 @@ const (
 	opModeTunnel          = "tunnel"
 	opModeTransparent     = "transparent"
 	opModeTransparentVlan = "transparent-vlan"
-	opModeDefault         = opModeTunnel
+	// opModeVnetBlock is the transparent mode for pod IPs from a VNet block routed to the node, see VnetBlockInfo.
+	opModeVnetBlock = "vnet-block"
+	opModeDefault   = opModeTunnel
 )
 
+// isTransparentMode returns whether the endpoints of the mode are veths routed by the host through /32 routes,
+// without a bridge.
+func isTransparentMode(mode string) bool {
+	return mode == opModeTransparent || mode == opModeVnetBlock
+}
+
 const (
 	// ipv6 modes
 	IPV6Nat = "ipv6nat"
@@ -54,6 +62,8 @@ type network struct {
 	EnableSnatOnHost bool
 	NetNs            string
 	SnatBridgeIP     string
+	// HostSysctls are the prior values of the host sysctls set for the network, restored when it's deleted.
+	HostSysctls map[string]string `json:",omitempty"`
 }
 
 // NetworkInfo contains read-only information about a container network.
@@ -104,7 +114,7 @@ func (nm *networkManager) newExternalInterface(ifName string, subnet string) err
 	}
 
 	// Find the host interface.
-	hostIf, err := net.InterfaceByName(ifName)
+	hostIf, err := nm.netio.GetNetworkInterfaceByName(ifName)
 	if err != nil {
 		return err
 	}
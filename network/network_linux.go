@@ -79,15 +79,9 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		if opt != nil && opt[VlanIDKey] != nil {
 			vlanid, _ = strconv.Atoi(opt[VlanIDKey].(string))
 		}
-	case opModeTransparent:
-		log.Printf("Transparent mode")
+	case opModeTransparent, opModeVnetBlock:
+		log.Printf("Transparent mode %s", nwInfo.Mode)
 		ifName = extIf.Name
-		if nwInfo.IPV6Mode != "" {
-			nu := networkutils.NewNetworkUtils(nm.netlink, nm.plClient)
-			if err := nu.EnableIPV6Forwarding(); err != nil {
-				return nil, fmt.Errorf("Ipv6 forwarding failed: %w", err)
-			}
-		}
 	case opModeTransparentVlan:
 		log.Printf("Transparent vlan mode")
 		ifName = extIf.Name
@@ -101,6 +95,12 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		return nil, err
 	}
 
+	// set the host sysctls which the datapath of the mode requires, recording their prior values.
+	hostSysctls, err := nm.applySysctls(requiredSysctls(nwInfo.Mode, nwInfo.IPV6Mode != ""))
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the network object.
 	nw := &network{
 		Id:               nwInfo.Id,
@@ -110,6 +110,7 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		VlanId:           vlanid,
 		DNS:              nwInfo.DNS,
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
+		HostSysctls:      hostSysctls,
 	}
 
 	return nw, nil
@@ -138,6 +139,8 @@ func (nm *networkManager) handleCommonOptions(ifName string, nwInfo *NetworkInfo
 func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 	var networkClient NetworkClient
 
+	nm.restoreSysctls(nw)
+
 	if nw.VlanId != 0 {
 		networkClient = NewOVSClient(nw.extIf.BridgeName, nw.extIf.Name, ovsctl.NewOvsctl(), nm.netlink, nm.plClient)
 	} else {
@@ -565,6 +568,78 @@ func (nm *networkManager) disconnectExternalInterface(extIf *externalInterface,
 	log.Printf("[net] Disconnected interface %v.", extIf.Name)
 }
 
+// repairNetworkImpl reconnects the external interface of a bridge or tunnel mode network to a new bridge if its bridge
+// is missing, like on the first ADD after a reboot, since the bridge doesn't survive reboots but the state file does.
+func (nm *networkManager) repairNetworkImpl(nw *network) (bool, error) {
+	if nw.Mode != opModeBridge && nw.Mode != opModeTunnel {
+		return false, nil
+	}
+
+	extIf := nw.extIf
+	if extIf == nil || extIf.BridgeName == "" {
+		return false, nil
+	}
+
+	bridgeName := extIf.BridgeName
+	if _, err := nm.netio.GetNetworkInterfaceByName(bridgeName); err == nil {
+		return false, nil
+	}
+
+	log.Printf("[net] Bridge %v of network %v is missing, rebuilding it.", bridgeName, nw.Id)
+
+	// The IP configuration of the host interface is moved to the bridge, so the host interface must have got it back
+	// after the reboot, else the node would be left without connectivity.
+	hostIf, err := nm.netio.GetNetworkInterfaceByName(extIf.Name)
+	if err != nil {
+		return false, err
+	}
+
+	addrs, err := nm.netio.GetNetworkInterfaceAddrs(hostIf)
+	if err != nil {
+		return false, err
+	}
+
+	hasIP := false
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+			hasIP = true
+			break
+		}
+	}
+
+	if !hasIP {
+		return false, fmt.Errorf("failed to rebuild bridge %v on interface %v: %w", bridgeName, extIf.Name, errHostIfNotReady)
+	}
+
+	nwInfo := NetworkInfo{
+		Id:               nw.Id,
+		Mode:             nw.Mode,
+		Subnets:          nw.Subnets,
+		DNS:              nw.DNS,
+		BridgeName:       bridgeName,
+		EnableSnatOnHost: nw.EnableSnatOnHost,
+		Options:          make(map[string]interface{}),
+	}
+	getNetworkInfoImpl(&nwInfo, nw)
+
+	// The saved IP configuration is the one from before the reboot, it is saved again from the host interface.
+	savedIPAddresses, savedRoutes := extIf.IPAddresses, extIf.Routes
+	extIf.BridgeName = ""
+	extIf.IPAddresses = nil
+	extIf.Routes = nil
+
+	if err = nm.connectExternalInterface(extIf, &nwInfo); err != nil {
+		// Keep the network marked as connected so that the next ADD tries again.
+		extIf.BridgeName = bridgeName
+		extIf.IPAddresses, extIf.Routes = savedIPAddresses, savedRoutes
+		return false, err
+	}
+
+	log.Printf("[net] Rebuilt bridge %v of network %v.", extIf.BridgeName, nw.Id)
+
+	return true, nil
+}
+
 func (*networkManager) addToIptables(cmds []iptables.IPTableEntry) error {
 	log.Printf("Adding additional iptable rules...")
 	for _, cmd := range cmds {
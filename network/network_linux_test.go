@@ -0,0 +1,111 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/platform"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test NetworkLinux", func() {
+	Describe("Test repairNetworkImpl", func() {
+		newNetwork := func(mode string, interfaces ...netio.MockInterface) (*networkManager, *network) {
+			netiocl, err := netio.NewMockNetIOWithTopology(&netio.MockTopology{Interfaces: interfaces})
+			Expect(err).NotTo(HaveOccurred())
+			extIf := &externalInterface{Name: "eth0", BridgeName: "azure0"}
+			nw := &network{Id: "azure", Mode: mode, extIf: extIf}
+			return &networkManager{netio: netiocl}, nw
+		}
+		eth0 := netio.MockInterface{Name: "eth0", Index: 2, Addresses: []string{"10.0.0.4/24"}}
+
+		Context("When the bridge exists", func() {
+			It("Should not repair the network", func() {
+				nm, nw := newNetwork(opModeBridge, eth0, netio.MockInterface{Name: "azure0", Index: 3})
+				repaired, err := nm.repairNetworkImpl(nw)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(BeFalse())
+			})
+		})
+
+		Context("When the network doesn't use a bridge", func() {
+			It("Should not repair the network", func() {
+				nm, nw := newNetwork(opModeTransparent, eth0)
+				repaired, err := nm.repairNetworkImpl(nw)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(BeFalse())
+			})
+		})
+
+		Context("When the bridge is missing and the host interface has no IP address yet", func() {
+			It("Should fail and keep the network connected to the bridge", func() {
+				nm, nw := newNetwork(opModeTunnel, netio.MockInterface{Name: "eth0", Index: 2})
+				repaired, err := nm.repairNetworkImpl(nw)
+				Expect(errors.Is(err, errHostIfNotReady)).To(BeTrue())
+				Expect(repaired).To(BeFalse())
+				Expect(nw.extIf.BridgeName).To(Equal("azure0"))
+			})
+		})
+	})
+
+	Describe("Test host sysctls", func() {
+		// newSysctlManager returns a networkManager whose sysctl commands read and write the sysctls.
+		newSysctlManager := func(sysctls map[string]string) *networkManager {
+			pl := platform.NewMockExecClient(false)
+			pl.SetExecCommand(func(cmd string) (string, error) {
+				var key, value string
+				if _, err := fmt.Sscanf(cmd, "sysctl -n %s", &key); err == nil {
+					return sysctls[key] + "\n", nil
+				}
+				if _, err := fmt.Sscanf(cmd, "sysctl -w %s", &key); err == nil {
+					key, value, _ = strings.Cut(key, "=")
+					sysctls[key] = value
+				}
+				return "", nil
+			})
+			return &networkManager{plClient: pl, ExternalInterfaces: map[string]*externalInterface{}}
+		}
+
+		Context("When the sysctls required by the mode aren't set", func() {
+			It("Should set them and restore them when the network is deleted", func() {
+				sysctls := map[string]string{ipv4ForwardSysctl: "0", rpFilterAllSysctl: rpFilterStrict}
+				nm := newSysctlManager(sysctls)
+				prior, err := nm.applySysctls(requiredSysctls(opModeTransparentVlan, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(prior).To(Equal(map[string]string{ipv4ForwardSysctl: "0", rpFilterAllSysctl: rpFilterStrict}))
+				Expect(sysctls).To(Equal(map[string]string{ipv4ForwardSysctl: "1", rpFilterAllSysctl: rpFilterLoose}))
+
+				nm.restoreSysctls(&network{Id: "nw", Mode: opModeTransparentVlan, HostSysctls: prior})
+				Expect(sysctls).To(Equal(map[string]string{ipv4ForwardSysctl: "0", rpFilterAllSysctl: rpFilterStrict}))
+			})
+		})
+
+		Context("When the sysctls already have values the mode accepts", func() {
+			It("Should not change or record them", func() {
+				sysctls := map[string]string{ipv4ForwardSysctl: "1", rpFilterAllSysctl: "0"}
+				nm := newSysctlManager(sysctls)
+				prior, err := nm.applySysctls(requiredSysctls(opModeTransparentVlan, false))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(prior).To(BeEmpty())
+				Expect(sysctls).To(Equal(map[string]string{ipv4ForwardSysctl: "1", rpFilterAllSysctl: "0"}))
+			})
+		})
+
+		Context("When another network requires a sysctl of the deleted network", func() {
+			It("Should hand its prior value over to the other network", func() {
+				sysctls := map[string]string{ipv4ForwardSysctl: "1"}
+				nm := newSysctlManager(sysctls)
+				deleted := &network{Id: "nw1", Mode: opModeBridge, HostSysctls: map[string]string{ipv4ForwardSysctl: "0"}}
+				remaining := &network{Id: "nw2", Mode: opModeTransparent}
+				nm.ExternalInterfaces["eth0"] = &externalInterface{Networks: map[string]*network{"nw1": deleted, "nw2": remaining}}
+
+				nm.restoreSysctls(deleted)
+				Expect(sysctls[ipv4ForwardSysctl]).To(Equal("1"))
+				Expect(remaining.HostSysctls).To(Equal(map[string]string{ipv4ForwardSysctl: "0"}))
+			})
+		})
+	})
+})
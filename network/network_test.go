@@ -4,6 +4,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/platform"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -32,6 +33,20 @@ var _ = Describe("Test Network", func() {
 				Expect(nm.ExternalInterfaces[ifName].Subnets).NotTo(ContainElement("10.1.0.0/16"))
 			})
 		})
+
+		Context("When external interface doesn't exist", func() {
+			It("Should add the host interface", func() {
+				ifName := "eth0"
+				nm := &networkManager{
+					ExternalInterfaces: map[string]*externalInterface{},
+					netio:              netio.NewMockNetIO(false, 0),
+				}
+				err := nm.newExternalInterface(ifName, "10.1.0.0/16")
+				Expect(err).To(BeNil())
+				Expect(nm.ExternalInterfaces[ifName].MacAddress.String()).To(Equal("ab:cd:ef:12:34:56"))
+				Expect(nm.ExternalInterfaces[ifName].Subnets).To(ContainElement("10.1.0.0/16"))
+			})
+		})
 	})
 
 	Describe("Test deleteExternalInterface", func() {
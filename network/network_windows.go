@@ -14,6 +14,7 @@ import (
 
 	"github.com/Azure/azure-container-networking/cni/util"
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
 	"github.com/Azure/azure-container-networking/network/hnswrapper"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Microsoft/hcsshim"
@@ -30,8 +31,6 @@ const (
 	baseDecimal            = 10
 	bitSize                = 32
 	defaultRouteCIDR       = "0.0.0.0/0"
-	// prefix for interface name created by azure network
-	ifNamePrefix = "vEthernet"
 	// ipv4 default hop
 	ipv4DefaultHop = "0.0.0.0"
 	// ipv6 default hop
@@ -82,6 +81,11 @@ func EnableHnsV1Timeout(timeoutValue int) {
 	}
 }
 
+// NetnsDuration always returns zero, since network namespaces are not entered on Windows.
+func NetnsDuration() time.Duration {
+	return 0
+}
+
 // newNetworkImplHnsV1 creates a new container network for HNSv1.
 func (nm *networkManager) newNetworkImplHnsV1(nwInfo *NetworkInfo, extIf *externalInterface) (*network, error) {
 	var (
@@ -200,10 +204,7 @@ func (nm *networkManager) addNewNetRules(nwInfo *NetworkInfo) error {
 	)
 
 	// get interface name of the VM adapter
-	ifName := nwInfo.MasterIfName
-	if !strings.Contains(nwInfo.MasterIfName, ifNamePrefix) {
-		ifName = fmt.Sprintf("%s (%s)", ifNamePrefix, nwInfo.MasterIfName)
-	}
+	ifName := netio.HNSInterfaceAlias(nwInfo.MasterIfName)
 
 	// check if external interface name is empty
 	if ifName == "" {
@@ -295,10 +296,7 @@ func (nm *networkManager) appIPV6RouteEntry(nwInfo *NetworkInfo) error {
 		}
 
 		// get interface name of VM adapter
-		ifName := nwInfo.MasterIfName
-		if !strings.Contains(nwInfo.MasterIfName, ifNamePrefix) {
-			ifName = fmt.Sprintf("%s (%s)", ifNamePrefix, nwInfo.MasterIfName)
-		}
+		ifName := netio.HNSInterfaceAlias(nwInfo.MasterIfName)
 
 		cmd := fmt.Sprintf(routeCmd, "delete", nwInfo.Subnets[1].Prefix.String(),
 			ifName, ipv6DefaultHop)
@@ -524,3 +522,8 @@ func (nm *networkManager) deleteNetworkImplHnsV2(nw *network) error {
 
 func getNetworkInfoImpl(nwInfo *NetworkInfo, nw *network) {
 }
+
+// repairNetworkImpl does nothing on Windows, where HNS persists the networks across reboots.
+func (*networkManager) repairNetworkImpl(*network) (bool, error) {
+	return false, nil
+}
@@ -6,6 +6,7 @@ package networkutils
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
@@ -35,6 +36,8 @@ const (
 	enableIPV6ForwardCmd = "sysctl -w net.ipv6.conf.all.forwarding=1"
 	disableRACmd         = "sysctl -w net.ipv6.conf.%s.accept_ra=0"
 	acceptRAV6File       = "/proc/sys/net/ipv6/conf/%s/accept_ra"
+	readSysctlCmd        = "sysctl -n %s"
+	writeSysctlCmd       = "sysctl -w %s=%s"
 )
 
 var errorNetworkUtils = errors.New("NetworkUtils Error")
@@ -273,6 +276,21 @@ func (nu NetworkUtils) SetProxyArp(ifName string) error {
 	return errors.Wrapf(err, "failed to set proxy arp for interface %v", ifName)
 }
 
+// ReadSysctl returns the value of the kernel parameter of the host, e.g. net.ipv4.ip_forward.
+func (nu NetworkUtils) ReadSysctl(key string) (string, error) {
+	out, err := nu.plClient.ExecuteCommand(fmt.Sprintf(readSysctlCmd, key))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read sysctl %s", key)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// WriteSysctl sets the kernel parameter of the host to the value.
+func (nu NetworkUtils) WriteSysctl(key, value string) error {
+	_, err := nu.plClient.ExecuteCommand(fmt.Sprintf(writeSysctlCmd, key, value))
+	return errors.Wrapf(err, "failed to write sysctl %s=%s", key, value)
+}
+
 func getPrivateIPSpace() []string {
 	privateIPAddresses := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}
 	return privateIPAddresses
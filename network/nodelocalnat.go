@@ -0,0 +1,81 @@
+package network
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+// NodeLocalNATInfo is the node-local IP of an endpoint, allocated from a dedicated subnet for the host to SNAT the
+// egress traffic of the endpoint to, per pod or per pod namespace.
+type NodeLocalNATInfo struct {
+	Subnet net.IPNet
+	// PerNamespace shares the IP between the endpoints of the pod namespace. The IP then isn't assigned to the
+	// endpoints, since it can't be routed to several of them.
+	PerNamespace bool
+	// IP is the IP allocated by the network manager.
+	IP net.IP
+}
+
+// allocateNodeLocalNATIP allocates the node-local NAT IP of the endpoint: the IP of the other endpoints of the pod
+// namespace if the IP is per namespace, else the lowest IP of the subnet unused by the endpoints of the node.
+// A per pod IP is assigned to the endpoint after its other IPs. The IPs are recorded in the endpoint state, and are
+// released with their last endpoint.
+func (nm *networkManager) allocateNodeLocalNATIP(epInfo *EndpointInfo) error {
+	natInfo := epInfo.NodeLocalNAT
+	subnet, ok := netip.AddrFromSlice(natInfo.Subnet.IP)
+	if !ok {
+		return errors.Errorf("invalid node-local NAT subnet %s", natInfo.Subnet.String())
+	}
+	ones, _ := natInfo.Subnet.Mask.Size()
+	prefix := netip.PrefixFrom(subnet.Unmap(), ones).Masked()
+
+	used := make(map[netip.Addr]bool)
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				if ep.NodeLocalNAT == nil || ep.NodeLocalNAT.IP == nil {
+					continue
+				}
+				ip, ok := netip.AddrFromSlice(ep.NodeLocalNAT.IP)
+				if !ok || !prefix.Contains(ip.Unmap()) {
+					continue
+				}
+				if natInfo.PerNamespace && ep.NodeLocalNAT.PerNamespace && ep.PODNameSpace == epInfo.PODNameSpace {
+					natInfo.IP = ep.NodeLocalNAT.IP
+					log.Printf("[net] Sharing node-local NAT IP %s of namespace %s", natInfo.IP, epInfo.PODNameSpace)
+					return nil
+				}
+				used[ip.Unmap()] = true
+			}
+		}
+	}
+
+	ip, err := lowestUnusedIP(prefix, used)
+	if err != nil {
+		return err
+	}
+	natInfo.IP = net.IP(ip.AsSlice())
+	log.Printf("[net] Allocated node-local NAT IP %s to endpoint %s", natInfo.IP, epInfo.Id)
+	if !natInfo.PerNamespace {
+		epInfo.IPAddresses = append(epInfo.IPAddresses, net.IPNet{IP: natInfo.IP, Mask: net.CIDRMask(ip.BitLen(), ip.BitLen())})
+	}
+	return nil
+}
+
+// lowestUnusedIP returns the lowest unused IP of the prefix, excluding the subnet address and, in IPv4, the broadcast
+// address.
+func lowestUnusedIP(prefix netip.Prefix, used map[netip.Addr]bool) (netip.Addr, error) {
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	for ip := prefix.Addr().Next(); prefix.Contains(ip); ip = ip.Next() {
+		if ip.Is4() && hostBits > 1 && !prefix.Contains(ip.Next()) {
+			break
+		}
+		if !used[ip] {
+			return ip, nil
+		}
+	}
+	return netip.Addr{}, errors.Wrapf(errNodeLocalNATExhausted, "%s", prefix)
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateNodeLocalNATIP(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("169.254.100.0/30")
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Networks: map[string]*network{
+					"nw1": {
+						Endpoints: map[string]*endpoint{
+							"ep1": {
+								PODNameSpace: "ns1",
+								NodeLocalNAT: &NodeLocalNATInfo{Subnet: *subnet, IP: net.ParseIP("169.254.100.1")},
+							},
+							"ep2": {PODNameSpace: "ns2"},
+						},
+					},
+				},
+			},
+		},
+	}
+	endpoints := nm.ExternalInterfaces["eth0"].Networks["nw1"].Endpoints
+
+	podIP := net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}
+	epInfo := &EndpointInfo{Id: "ep3", PODNameSpace: "ns1", IPAddresses: []net.IPNet{podIP}, NodeLocalNAT: &NodeLocalNATInfo{Subnet: *subnet}}
+	require.NoError(t, nm.allocateNodeLocalNATIP(epInfo))
+	require.True(t, epInfo.NodeLocalNAT.IP.Equal(net.ParseIP("169.254.100.2")), "a per pod IP shouldn't be shared")
+	require.Len(t, epInfo.IPAddresses, 2, "the pod IP should be assigned after the other IPs")
+	require.Equal(t, podIP, epInfo.IPAddresses[0])
+	require.Equal(t, "169.254.100.2/32", epInfo.IPAddresses[1].String())
+	endpoints["ep3"] = &endpoint{PODNameSpace: "ns1", NodeLocalNAT: epInfo.NodeLocalNAT}
+
+	// the broadcast address isn't allocated
+	epInfo = &EndpointInfo{Id: "ep4", PODNameSpace: "ns2", NodeLocalNAT: &NodeLocalNATInfo{Subnet: *subnet, PerNamespace: true}}
+	require.ErrorIs(t, nm.allocateNodeLocalNATIP(epInfo), errNodeLocalNATExhausted)
+
+	delete(endpoints, "ep1")
+	require.NoError(t, nm.allocateNodeLocalNATIP(epInfo))
+	require.True(t, epInfo.NodeLocalNAT.IP.Equal(net.ParseIP("169.254.100.1")), "released IPs should be allocated again")
+	require.Empty(t, epInfo.IPAddresses, "a namespace IP shouldn't be assigned to the endpoint")
+	endpoints["ep4"] = &endpoint{PODNameSpace: "ns2", NodeLocalNAT: epInfo.NodeLocalNAT}
+
+	epInfo = &EndpointInfo{Id: "ep5", PODNameSpace: "ns2", NodeLocalNAT: &NodeLocalNATInfo{Subnet: *subnet, PerNamespace: true}}
+	require.NoError(t, nm.allocateNodeLocalNATIP(epInfo))
+	require.True(t, epInfo.NodeLocalNAT.IP.Equal(net.ParseIP("169.254.100.1")), "the IP of the namespace should be shared")
+}
@@ -13,8 +13,13 @@ const (
 	ACLPolicy         CNIPolicyType = "ACL"
 	L4WFPProxyPolicy  CNIPolicyType = "L4WFPPROXY"
 	LoopbackDSRPolicy CNIPolicyType = "LoopbackDSR"
+	QosPolicy         CNIPolicyType = "QOS"
 )
 
+// PassthroughEndpointPolicy is an endpoint policy from CNS, whose Data is applied verbatim to the endpoint.
+// On Windows, it's an HNS v2 endpoint policy.
+const PassthroughEndpointPolicy CNIPolicyType = "PassthroughEndpointPolicy"
+
 type CNIPolicyType string
 
 type Policy struct {
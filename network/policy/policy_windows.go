@@ -89,6 +89,13 @@ func SerializePolicies(policyType CNIPolicyType, policies []Policy, epInfoData m
 				} else {
 					jsonPolicies = append(jsonPolicies, dsrPolicy)
 				}
+			case QosPolicy:
+				// QoS policy comes as a HNSv2 type, it needs to be converted to HNSv1
+				if qosPolicy, err := SerializeQosPolicy(policy); err != nil {
+					log.Printf("Failed to serialize QoS policy")
+				} else {
+					jsonPolicies = append(jsonPolicies, qosPolicy)
+				}
 			default:
 				jsonPolicies = append(jsonPolicies, policy.Data)
 			}
@@ -126,6 +133,67 @@ func GetOutBoundNatExceptionList(policy Policy) ([]string, error) {
 	return nil, nil
 }
 
+// AddOutBoundNATExceptions returns the policies with the exceptions added to the ExceptionList of their
+// OutBoundNAT endpoint policies, or with a new OutBoundNAT endpoint policy if they have none, so that the traffic
+// to the exceptions isn't SNATed by any of them. The policies passed in are not modified.
+func AddOutBoundNATExceptions(policies []Policy, exceptions []string) ([]Policy, error) {
+	merged := make([]Policy, 0, len(policies)+1)
+	found := false
+	for _, policy := range policies {
+		if policy.Type != EndpointPolicy || GetPolicyType(policy) != OutBoundNatPolicy {
+			merged = append(merged, policy)
+			continue
+		}
+		found = true
+
+		// keep the other fields of the policy as they are
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(policy.Data, &data); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal OutBoundNAT policy")
+		}
+		exceptionList, err := GetOutBoundNatExceptionList(policy)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse OutBoundNAT exception list")
+		}
+		for _, exception := range exceptions {
+			if !contains(exceptionList, exception) {
+				exceptionList = append(exceptionList, exception)
+			}
+		}
+		if data["ExceptionList"], err = json.Marshal(exceptionList); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal OutBoundNAT exception list")
+		}
+		policyData, err := json.Marshal(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal OutBoundNAT policy")
+		}
+		merged = append(merged, Policy{Type: policy.Type, Data: policyData})
+	}
+
+	if !found {
+		exceptionList, err := json.Marshal(exceptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal OutBoundNAT exception list")
+		}
+		policyData, err := json.Marshal(KVPairOutBoundNAT{Type: OutBoundNatPolicy, ExceptionList: exceptionList})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal OutBoundNAT policy")
+		}
+		merged = append(merged, Policy{Type: EndpointPolicy, Data: policyData})
+	}
+
+	return merged, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func SerializeNATPolicy(policy Policy) (json.RawMessage, error) {
 	var (
 		endpointPolicy    hcn.EndpointPolicy
@@ -184,6 +252,29 @@ func SerializeOutBoundNATPolicy(policy Policy, epInfoData map[string]interface{}
 	return nil, fmt.Errorf("OutBoundNAT policy not set")
 }
 
+// SerializeQosPolicy converts the HNSv2 QoS policy to HNSv1 and returns serialized json
+func SerializeQosPolicy(policy Policy) (json.RawMessage, error) {
+	var (
+		endpointPolicy hcn.EndpointPolicy
+		qosSetting     hcn.QosPolicySetting
+	)
+	if err := json.Unmarshal(policy.Data, &endpointPolicy); err != nil {
+		return nil, errors.Wrap(err, "unmarshal qos policy failed")
+	}
+	if err := json.Unmarshal(endpointPolicy.Settings, &qosSetting); err != nil {
+		return nil, errors.Wrap(err, "unmarshal qos policy settings failed")
+	}
+	qosPolicy := hcsshim.QosPolicy{
+		Type:                            hcsshim.QOS,
+		MaximumOutgoingBandwidthInBytes: qosSetting.MaximumOutgoingBandwidthInBytes,
+	}
+	rawPolicy, err := json.Marshal(qosPolicy)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal error for qos policy")
+	}
+	return rawPolicy, nil
+}
+
 func SerializeLoopbackDSRPolicy(policy Policy) (json.RawMessage, error) {
 	var dsrData LoopbackDSR
 
@@ -235,11 +326,14 @@ func GetPolicyType(policy Policy) CNIPolicyType {
 		}
 	}
 
-	// Check if the type if Port mapping / NAT
+	// Check if the type if Port mapping / NAT or QoS
 	var dataPortMapping hcn.EndpointPolicy
 	if err := json.Unmarshal(policy.Data, &dataPortMapping); err == nil {
-		if dataPortMapping.Type == hcn.PortMapping {
+		switch dataPortMapping.Type {
+		case hcn.PortMapping:
 			return PortMappingPolicy
+		case hcn.QOS:
+			return QosPolicy
 		}
 	}
 
@@ -400,6 +494,30 @@ func GetHcnPortMappingPolicy(policy Policy) (hcn.EndpointPolicy, error) {
 	return portMappingPolicy, nil
 }
 
+// GetHcnQosPolicy returns QoS policy.
+func GetHcnQosPolicy(policy Policy) (hcn.EndpointPolicy, error) {
+	var qosPolicy hcn.EndpointPolicy
+	if err := json.Unmarshal(policy.Data, &qosPolicy); err != nil {
+		return qosPolicy, errors.Wrapf(err, "invalid policy: %+v. Expecting QoS policy", policy)
+	}
+	qosPolicy.Type = hcn.QOS
+
+	return qosPolicy, nil
+}
+
+// GetHcnPassthroughEndpointPolicy returns the HNS endpoint policy in the data of a passthrough policy.
+func GetHcnPassthroughEndpointPolicy(policy Policy) (hcn.EndpointPolicy, error) {
+	var endpointPolicy hcn.EndpointPolicy
+	if err := json.Unmarshal(policy.Data, &endpointPolicy); err != nil {
+		return endpointPolicy, errors.Wrapf(err, "invalid policy: %+v. Expecting HNS endpoint policy", policy)
+	}
+	if endpointPolicy.Type == "" {
+		return endpointPolicy, errors.Errorf("invalid policy: %+v. HNS endpoint policy has no type", policy)
+	}
+
+	return endpointPolicy, nil
+}
+
 // GetHcnACLPolicy returns ACL policy.
 func GetHcnACLPolicy(policy Policy) (hcn.EndpointPolicy, error) {
 	aclEndpolicySetting := hcn.EndpointPolicy{
@@ -475,6 +593,17 @@ func GetHcnEndpointPolicies(policyType CNIPolicyType, policies []Policy, epInfoD
 	var hcnEndPointPolicies []hcn.EndpointPolicy
 
 	for _, policy := range policies {
+		if policy.Type == PassthroughEndpointPolicy && policyType == EndpointPolicy {
+			endpointPolicy, err := GetHcnPassthroughEndpointPolicy(policy)
+			if err != nil {
+				log.Printf("Failed to parse passthrough policy: %+v with error %v", policy.Data, err)
+				return hcnEndPointPolicies, err
+			}
+			hcnEndPointPolicies = append(hcnEndPointPolicies, endpointPolicy)
+			log.Printf("Successfully retrieve passthrough endpoint policy: %s", endpointPolicy.Type)
+			continue
+		}
+
 		if policy.Type == policyType {
 			var err error
 			var endpointPolicy hcn.EndpointPolicy
@@ -494,6 +623,8 @@ func GetHcnEndpointPolicies(policyType CNIPolicyType, policies []Policy, epInfoD
 				endpointPolicy, err = GetHcnL4WFPProxyPolicy(policy)
 			case LoopbackDSRPolicy:
 				endpointPolicy, err = GetHcnLoopbackDSRPolicy(policy)
+			case QosPolicy:
+				endpointPolicy, err = GetHcnQosPolicy(policy)
 			default:
 				// return error as we should be able to parse all the policies specified
 				return hcnEndPointPolicies, fmt.Errorf("Failed to set Policy: Type: %s, Data: %s", policy.Type, policy.Data)
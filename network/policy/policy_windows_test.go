@@ -47,4 +47,102 @@ var _ = Describe("Windows Policies", func() {
 			Expect(string(generatedPolicy.Settings)).To(Equal(expected_policy))
 		})
 	})
+
+	Describe("Test QoS policy", func() {
+		policy := Policy{
+			Type: EndpointPolicy,
+			Data: []byte(`{"Type": "QOS", "Settings": {"MaximumOutgoingBandwidthInBytes": 125000}}`),
+		}
+
+		It("Should detect the policy type", func() {
+			Expect(GetPolicyType(policy)).To(Equal(QosPolicy))
+		})
+
+		It("Should convert the policy to HNSv1", func() {
+			serializedPolicy, err := SerializeQosPolicy(policy)
+			Expect(err).To(BeNil())
+			Expect(string(serializedPolicy)).To(Equal(`{"Type":"QOS","MaximumOutgoingBandwidthInBytes":125000}`))
+		})
+
+		It("Should return the HNSv2 policy", func() {
+			generatedPolicy, err := GetHcnQosPolicy(policy)
+			Expect(err).To(BeNil())
+			Expect(string(generatedPolicy.Settings)).To(Equal(`{"MaximumOutgoingBandwidthInBytes": 125000}`))
+		})
+	})
+
+	Describe("Test passthrough policy", func() {
+		It("Should return the HNSv2 policy verbatim", func() {
+			policy := Policy{
+				Type: PassthroughEndpointPolicy,
+				Data: []byte(`{"Type": "SDNRoute", "Settings": {"DestinationPrefix": "10.0.0.0/8", "NeedEncap": true}}`),
+			}
+			generatedPolicy, err := GetHcnPassthroughEndpointPolicy(policy)
+			Expect(err).To(BeNil())
+			Expect(string(generatedPolicy.Type)).To(Equal("SDNRoute"))
+			Expect(string(generatedPolicy.Settings)).To(Equal(`{"DestinationPrefix": "10.0.0.0/8", "NeedEncap": true}`))
+		})
+
+		It("Should raise error for a policy without type", func() {
+			policy := Policy{
+				Type: PassthroughEndpointPolicy,
+				Data: []byte(`{"Settings": {}}`),
+			}
+			_, err := GetHcnPassthroughEndpointPolicy(policy)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("Should be added to the endpoint policies", func() {
+			policies := []Policy{
+				{
+					Type: PassthroughEndpointPolicy,
+					Data: []byte(`{"Type": "SDNRoute", "Settings": {"DestinationPrefix": "10.0.0.0/8"}}`),
+				},
+			}
+			endpointPolicies, err := GetHcnEndpointPolicies(EndpointPolicy, policies, nil, false, false, nil)
+			Expect(err).To(BeNil())
+			Expect(endpointPolicies).To(HaveLen(1))
+			Expect(string(endpointPolicies[0].Type)).To(Equal("SDNRoute"))
+
+			networkPolicies, err := GetHcnEndpointPolicies(NetworkPolicy, policies, nil, false, false, nil)
+			Expect(err).To(BeNil())
+			Expect(networkPolicies).To(BeEmpty())
+		})
+	})
+
+	Describe("Test AddOutBoundNATExceptions", func() {
+		It("Should add the exceptions to the OutBoundNAT policy", func() {
+			policies := []Policy{
+				{
+					Type: EndpointPolicy,
+					Data: []byte(`{"Type": "OutBoundNAT", "ExceptionList": ["10.240.0.0/16"]}`),
+				},
+				{
+					Type: EndpointPolicy,
+					Data: []byte(`{"Type": "ROUTE", "DestinationPrefix": "10.0.0.0/8", "NeedEncap": true}`),
+				},
+			}
+			merged, err := AddOutBoundNATExceptions(policies, []string{"10.240.0.0/16", "192.168.0.0/16"})
+			Expect(err).To(BeNil())
+			Expect(merged).To(HaveLen(2))
+			Expect(string(merged[0].Data)).To(Equal(`{"ExceptionList":["10.240.0.0/16","192.168.0.0/16"],"Type":"OutBoundNAT"}`))
+			Expect(merged[1]).To(Equal(policies[1]))
+			// the policies passed in are not modified
+			Expect(string(policies[0].Data)).To(Equal(`{"Type": "OutBoundNAT", "ExceptionList": ["10.240.0.0/16"]}`))
+
+			endpointPolicies, err := GetHcnEndpointPolicies(EndpointPolicy, merged[:1], nil, false, false, nil)
+			Expect(err).To(BeNil())
+			Expect(endpointPolicies).To(HaveLen(1))
+			Expect(string(endpointPolicies[0].Settings)).To(Equal(`{"Exceptions":["10.240.0.0/16","192.168.0.0/16"]}`))
+		})
+
+		It("Should add an OutBoundNAT policy if there is none", func() {
+			merged, err := AddOutBoundNATExceptions(nil, []string{"192.168.0.0/16"})
+			Expect(err).To(BeNil())
+			Expect(merged).To(HaveLen(1))
+			Expect(merged[0].Type).To(Equal(EndpointPolicy))
+			Expect(GetPolicyType(merged[0])).To(Equal(OutBoundNatPolicy))
+			Expect(GetOutBoundNatExceptionList(merged[0])).To(Equal([]string{"192.168.0.0/16"}))
+		})
+	})
 })
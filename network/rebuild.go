@@ -0,0 +1,164 @@
+package network
+
+import (
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+)
+
+var errNoMasterInterface = errors.New("no master interface found for the endpoints, set master in the network configuration")
+
+// RebuildState adds the endpoints found on the host to the state of the network, for nodes whose state was lost, so
+// that the CNI can delete them. The network is added to the state if it's missing, without configuring the host.
+// Endpoints already in the state are kept. Returns the IDs of the endpoints added.
+func (nm *networkManager) RebuildState(nwInfo *NetworkInfo) ([]string, error) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	eps, err := discoverEndpoints(nwInfo.Id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to discover the endpoints of network %s", nwInfo.Id)
+	}
+	log.Printf("[net] Discovered %d endpoints of network %s", len(eps), nwInfo.Id)
+
+	nw, err := nm.getNetwork(nwInfo.Id)
+	if err != nil {
+		if len(eps) == 0 {
+			return nil, nil
+		}
+		if nw, err = nm.rebuildNetwork(nwInfo, eps); err != nil {
+			return nil, err
+		}
+	}
+
+	added := mergeRebuiltEndpoints(nw, eps)
+	if len(added) == 0 {
+		return nil, nil
+	}
+	if err := nm.save(); err != nil {
+		return nil, errors.Wrap(err, "failed to save the rebuilt state")
+	}
+	return added, nil
+}
+
+// rebuildNetwork adds the network of the endpoints to the state. When the network info has no master interface, the
+// host interface with an address in the subnet of an endpoint is used, as on ADD.
+func (nm *networkManager) rebuildNetwork(nwInfo *NetworkInfo, eps []*endpoint) (*network, error) {
+	subnets := rebuiltSubnets(eps)
+	master := nwInfo.MasterIfName
+	if master == "" {
+		master = masterInterfaceForSubnets(subnets)
+		if master == "" {
+			return nil, errNoMasterInterface
+		}
+	}
+
+	if err := nm.newExternalInterface(master, subnets[0].Prefix.String()); err != nil {
+		return nil, errors.Wrapf(err, "failed to add external interface %s", master)
+	}
+	extIf := nm.ExternalInterfaces[master]
+
+	mode := nwInfo.Mode
+	if mode == "" {
+		mode = opModeDefault
+	}
+	nw := &network{
+		Id:               nwInfo.Id,
+		Mode:             mode,
+		Subnets:          subnets,
+		Endpoints:        make(map[string]*endpoint),
+		extIf:            extIf,
+		DNS:              nwInfo.DNS,
+		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
+	}
+	if err := nm.rebuildNetworkImpl(nwInfo, nw); err != nil {
+		return nil, err
+	}
+	extIf.Networks[nw.Id] = nw
+	log.Printf("[net] Rebuilt network %s on interface %s with subnets %+v", nw.Id, master, subnets)
+	return nw, nil
+}
+
+// mergeRebuiltEndpoints adds the endpoints which aren't in the network yet, by ID or by sandbox, to the network and
+// returns their IDs.
+func mergeRebuiltEndpoints(nw *network, eps []*endpoint) []string {
+	var added []string
+	for _, ep := range eps {
+		if nw.Endpoints[ep.Id] != nil {
+			continue
+		}
+		known := false
+		for _, existing := range nw.Endpoints {
+			if existing.IfName == ep.IfName && endpointNetNs(existing) != "" && endpointNetNs(existing) == endpointNetNs(ep) {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+		log.Printf("[net] Rebuilt endpoint %s with IPs %v in netns %s", ep.Id, ep.IPAddresses, endpointNetNs(ep))
+		nw.Endpoints[ep.Id] = ep
+		added = append(added, ep.Id)
+	}
+	return added
+}
+
+// endpointNetNs returns the network namespace of the endpoint, recorded as NetworkNameSpace on Linux and NetNs on
+// Windows.
+func endpointNetNs(ep *endpoint) string {
+	if ep.NetworkNameSpace != "" {
+		return ep.NetworkNameSpace
+	}
+	return ep.NetNs
+}
+
+// rebuiltSubnets returns the subnets of the IPs of the endpoints.
+func rebuiltSubnets(eps []*endpoint) []SubnetInfo {
+	var subnets []SubnetInfo
+	seen := map[string]bool{}
+	for _, ep := range eps {
+		for _, ip := range ep.IPAddresses {
+			prefix := net.IPNet{IP: ip.IP.Mask(ip.Mask), Mask: ip.Mask}
+			if seen[prefix.String()] {
+				continue
+			}
+			seen[prefix.String()] = true
+			family := platform.AfINET
+			if ip.IP.To4() == nil {
+				family = platform.AfINET6
+			}
+			subnets = append(subnets, SubnetInfo{Family: family, Prefix: prefix})
+		}
+	}
+	return subnets
+}
+
+// masterInterfaceForSubnets returns the first host interface with an address in one of the subnets.
+func masterInterfaceForSubnets(subnets []SubnetInfo) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Printf("[net] Failed to list host interfaces: %v", err)
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			for i := range subnets {
+				if subnets[i].Prefix.Contains(ipNet.IP) {
+					return iface.Name
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+// netNsDir is where the container runtimes bind mount the network namespaces of the pods.
+var netNsDir = "/var/run/netns"
+
+// discoverEndpoints returns the endpoints found in the network namespaces of the pods.
+var discoverEndpoints = discoverNetNsEndpoints
+
+// discoverNetNsEndpoints returns an endpoint for each interface with an IP in the pod network namespaces. The host
+// veth names are hashes of the container IDs, which the kernel doesn't know, so the endpoints are identified by their
+// netns and interface, and the CNI finds them by netns on DEL.
+func discoverNetNsEndpoints(string) ([]*endpoint, error) {
+	entries, err := os.ReadDir(netNsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %s", netNsDir)
+	}
+
+	var eps []*endpoint
+	for _, entry := range entries {
+		nsPath := filepath.Join(netNsDir, entry.Name())
+		nsEps, err := netNsEndpoints(nsPath)
+		if err != nil {
+			log.Printf("[net] Skipping netns %s: %v", nsPath, err)
+			continue
+		}
+		eps = append(eps, nsEps...)
+	}
+	return eps, nil
+}
+
+// netNsEndpoints returns an endpoint for each interface with a global unicast IP in the network namespace.
+func netNsEndpoints(nsPath string) ([]*endpoint, error) {
+	ns, err := OpenNamespace(nsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer ns.Close()
+
+	if err := ns.Enter(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns %s: %v", nsPath, err)
+		}
+	}()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list interfaces")
+	}
+
+	var eps []*endpoint
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list addresses of %s", iface.Name)
+		}
+		var ips []net.IPNet
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsGlobalUnicast() {
+				ips = append(ips, *ipNet)
+			}
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		eps = append(eps, &endpoint{
+			Id:               fmt.Sprintf("%s-%s", filepath.Base(nsPath), iface.Name),
+			IfName:           iface.Name,
+			MacAddress:       iface.HardwareAddr,
+			IPAddresses:      ips,
+			NetworkNameSpace: nsPath,
+		})
+	}
+	return eps, nil
+}
+
+// rebuildNetworkImpl records the bridge of the external interface in bridge mode, for DEL to remove the rules of the
+// endpoints from it.
+func (nm *networkManager) rebuildNetworkImpl(nwInfo *NetworkInfo, nw *network) error {
+	if (nw.Mode != opModeBridge && nw.Mode != opModeTunnel) || nw.extIf.BridgeName != "" {
+		return nil
+	}
+	bridgeName := nwInfo.BridgeName
+	if bridgeName == "" {
+		hostIf, err := nm.netio.GetNetworkInterfaceByName(nw.extIf.Name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get interface %s", nw.extIf.Name)
+		}
+		bridgeName = fmt.Sprintf("%s%d", bridgePrefix, hostIf.Index)
+	}
+	nw.extIf.BridgeName = bridgeName
+	return nil
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildState(t *testing.T) {
+	defer func(discover func(string) ([]*endpoint, error)) { discoverEndpoints = discover }(discoverEndpoints)
+	_, subnet, _ := net.ParseCIDR("10.240.0.0/16")
+	eps := []*endpoint{
+		{Id: "ns1-eth0", IfName: "eth0", NetworkNameSpace: "/var/run/netns/ns1", IPAddresses: []net.IPNet{{IP: net.ParseIP("10.240.0.5").To4(), Mask: subnet.Mask}}},
+		{Id: "ns2-eth0", IfName: "eth0", NetworkNameSpace: "/var/run/netns/ns2", IPAddresses: []net.IPNet{{IP: net.ParseIP("10.240.0.6").To4(), Mask: subnet.Mask}}},
+	}
+	discoverEndpoints = func(string) ([]*endpoint, error) { return eps, nil }
+
+	nm := &networkManager{ExternalInterfaces: map[string]*externalInterface{}, netio: netio.NewMockNetIO(false, 0)}
+	nwInfo := &NetworkInfo{Id: "azure", Mode: opModeTransparent, MasterIfName: "eth0"}
+	added, err := nm.RebuildState(nwInfo)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"ns1-eth0", "ns2-eth0"}, added)
+
+	nw, err := nm.getNetwork("azure")
+	require.NoError(t, err)
+	require.Equal(t, opModeTransparent, nw.Mode)
+	require.Len(t, nw.Subnets, 1)
+	require.Equal(t, subnet.String(), nw.Subnets[0].Prefix.String())
+	require.Equal(t, "eth0", nw.extIf.Name)
+
+	// endpoints already in the state, by ID or by sandbox, are kept
+	delete(nw.Endpoints, "ns2-eth0")
+	nw.Endpoints["12345678-eth0"] = &endpoint{Id: "12345678-eth0", ContainerID: "12345678abcd", IfName: "eth0", NetworkNameSpace: "/var/run/netns/ns2"}
+	added, err = nm.RebuildState(nwInfo)
+	require.NoError(t, err)
+	require.Empty(t, added)
+	require.Len(t, nw.Endpoints, 2)
+	require.Contains(t, nw.Endpoints, "ns1-eth0")
+}
+
+func TestRebuildStateWithoutEndpoints(t *testing.T) {
+	defer func(discover func(string) ([]*endpoint, error)) { discoverEndpoints = discover }(discoverEndpoints)
+	discoverEndpoints = func(string) ([]*endpoint, error) { return nil, nil }
+
+	nm := &networkManager{ExternalInterfaces: map[string]*externalInterface{}, netio: netio.NewMockNetIO(false, 0)}
+	added, err := nm.RebuildState(&NetworkInfo{Id: "azure"})
+	require.NoError(t, err)
+	require.Empty(t, added)
+	require.Empty(t, nm.ExternalInterfaces)
+}
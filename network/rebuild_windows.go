@@ -0,0 +1,77 @@
+package network
+
+import (
+	"net"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/pkg/errors"
+)
+
+// discoverEndpoints returns the HNS endpoints of the network.
+var discoverEndpoints = discoverHnsEndpoints
+
+// discoverHnsEndpoints returns an endpoint for each HNS endpoint of the network. The HNS endpoints are named after the
+// endpoint IDs, so the CNI finds them by ID on DEL.
+func discoverHnsEndpoints(networkID string) ([]*endpoint, error) {
+	hnsNetwork, err := Hnsv2.GetNetworkByName(networkID)
+	if err != nil {
+		if errors.As(err, &hcn.NetworkNotFoundError{}) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get HNS network %s", networkID)
+	}
+
+	hnsEndpoints, err := Hnsv2.ListEndpointsOfNetwork(hnsNetwork.Id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the endpoints of HNS network %s", hnsNetwork.Id)
+	}
+
+	var eps []*endpoint
+	for i := range hnsEndpoints {
+		hnsEndpoint := &hnsEndpoints[i]
+		// the apipa endpoints of the NCs are owned by CNS
+		if strings.HasPrefix(hnsEndpoint.Name, hostNCApipaEndpointNamePrefix) {
+			continue
+		}
+		// the endpoint IDs are the container ID prefix and the interface name, see ConstructEndpointID
+		_, ifName, ok := strings.Cut(hnsEndpoint.Name, "-")
+		if !ok {
+			continue
+		}
+		ep := &endpoint{
+			Id:     hnsEndpoint.Name,
+			HnsId:  hnsEndpoint.Id,
+			IfName: ifName,
+			NetNs:  hnsEndpoint.HostComputeNamespace,
+		}
+		ep.MacAddress, _ = net.ParseMAC(hnsEndpoint.MacAddress)
+		for _, ipConfig := range hnsEndpoint.IpConfigurations {
+			ip := net.ParseIP(ipConfig.IpAddress)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			ep.IPAddresses = append(ep.IPAddresses, net.IPNet{IP: ip, Mask: net.CIDRMask(int(ipConfig.PrefixLength), bits)})
+		}
+		if len(ep.IPAddresses) == 0 {
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	return eps, nil
+}
+
+// rebuildNetworkImpl records the HNS network of the network.
+func (nm *networkManager) rebuildNetworkImpl(_ *NetworkInfo, nw *network) error {
+	hnsNetwork, err := Hnsv2.GetNetworkByName(nw.Id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get HNS network %s", nw.Id)
+	}
+	nw.HnsId = hnsNetwork.Id
+	return nil
+}
@@ -0,0 +1,31 @@
+package network
+
+const (
+	// Route tables of the endpoints with source routing. The tables above are reserved by the kernel:
+	// 253 is the default, 254 the main and 255 the local table.
+	minSourceRoutingTableID = 100
+	maxSourceRoutingTableID = 252
+)
+
+// allocateRouteTableID returns the lowest route table unused by the endpoints in the network namespace.
+// Each endpoint of a pod with multiple interfaces needs its own table, and the tables are recorded in the
+// endpoint state so that they are unique across the networks of the pod.
+func (nm *networkManager) allocateRouteTableID(netNsPath string) (int, error) {
+	used := make(map[int]bool)
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				if ep.RouteTableID != 0 && ep.NetworkNameSpace == netNsPath {
+					used[ep.RouteTableID] = true
+				}
+			}
+		}
+	}
+
+	for id := minSourceRoutingTableID; id <= maxSourceRoutingTableID; id++ {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, errRouteTablesExhausted
+}
@@ -0,0 +1,101 @@
+package network
+
+import (
+	"net"
+	"os"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Priority of the rules looking up the route tables of the endpoints, before the main table at 32766.
+const sourceRoutingRulePriority = 1000
+
+// sourceRoutingRules returns the rules looking up the route table of the endpoint for the traffic from its IPs.
+func sourceRoutingRules(ipAddresses []net.IPNet, tableID int) []*netlink.Rule {
+	rules := make([]*netlink.Rule, 0, len(ipAddresses))
+	for _, ipAddr := range ipAddresses {
+		bits := 8 * net.IPv4len
+		if ipAddr.IP.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		rules = append(rules, &netlink.Rule{
+			Family:   netlink.GetIPAddressFamily(ipAddr.IP),
+			Src:      &net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(bits, bits)},
+			Table:    tableID,
+			Priority: sourceRoutingRulePriority,
+		})
+	}
+	return rules
+}
+
+// setupSourceRouting copies the routes of the container interface to the route table of the endpoint, and routes
+// the traffic from the IPs of the endpoint through that table, so that it leaves through the interface it came in on.
+// It must be called in the container network namespace, once the routes of the interface are configured.
+func setupSourceRouting(nl netlink.NetlinkInterface, netioCli netio.NetIOInterface, ifName string, epInfo *EndpointInfo) error {
+	containerIf, err := netioCli.GetNetworkInterfaceByName(ifName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get interface %s", ifName)
+	}
+
+	routes, err := nl.GetIPRoute(&netlink.Route{LinkIndex: containerIf.Index})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get routes of %s", ifName)
+	}
+
+	log.Printf("[net] Copying %d routes of %s to route table %d", len(routes), ifName, epInfo.RouteTableID)
+	for _, route := range routes {
+		tableRoute := *route
+		tableRoute.Table = epInfo.RouteTableID
+		if err := nl.AddIPRoute(&tableRoute); err != nil && !errors.Is(err, unix.EEXIST) {
+			return errors.Wrapf(err, "failed to add route %+v to table %d", tableRoute, epInfo.RouteTableID)
+		}
+	}
+
+	for _, rule := range sourceRoutingRules(epInfo.IPAddresses, epInfo.RouteTableID) {
+		log.Printf("[net] Adding IP rule from %v lookup %d", rule.Src, rule.Table)
+		if err := nl.AddIPRule(rule); err != nil && !errors.Is(err, unix.EEXIST) {
+			return errors.Wrapf(err, "failed to add rule from %v lookup %d", rule.Src, rule.Table)
+		}
+	}
+
+	return nil
+}
+
+// deleteSourceRouting deletes the rules of the endpoint from its container network namespace.
+// The routes of its table are deleted with its interface, and everything with the namespace if it is already gone.
+func deleteSourceRouting(nl netlink.NetlinkInterface, ep *endpoint) error {
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to open netns %s", ep.NetworkNameSpace)
+	}
+	defer ns.Close()
+
+	if err := ns.Enter(); err != nil {
+		return errors.Wrapf(err, "failed to enter netns %s", ep.NetworkNameSpace)
+	}
+	defer func() {
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	return deleteSourceRoutingRules(nl, ep.IPAddresses, ep.RouteTableID)
+}
+
+// deleteSourceRoutingRules deletes the rules looking up the route table, ignoring those already deleted.
+func deleteSourceRoutingRules(nl netlink.NetlinkInterface, ipAddresses []net.IPNet, tableID int) error {
+	for _, rule := range sourceRoutingRules(ipAddresses, tableID) {
+		log.Printf("[net] Deleting IP rule from %v lookup %d", rule.Src, rule.Table)
+		if err := nl.DeleteIPRule(rule); err != nil && !errors.Is(err, unix.ENOENT) {
+			return errors.Wrapf(err, "failed to delete rule from %v lookup %d", rule.Src, rule.Table)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSetupSourceRouting(t *testing.T) {
+	_, gwNet, _ := net.ParseCIDR("169.254.1.1/32")
+	mainRoutes := []*netlink.Route{
+		{Family: unix.AF_INET, Dst: gwNet, Scope: netlink.RT_SCOPE_LINK, LinkIndex: 2},
+		{Family: unix.AF_INET, Gw: net.ParseIP("169.254.1.1"), LinkIndex: 2},
+	}
+	epInfo := &EndpointInfo{
+		IPAddresses: []net.IPNet{
+			{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)},
+			{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(64, 128)},
+		},
+		RouteTableID: 101,
+	}
+
+	var (
+		addedRoutes []*netlink.Route
+		addedRules  []*netlink.Rule
+	)
+	nl := netlink.NewMockNetlink(false, "")
+	nl.SetGetRouteFn(func(filter *netlink.Route) ([]*netlink.Route, error) {
+		require.Equal(t, 2, filter.LinkIndex)
+		require.Zero(t, filter.Table, "routes should be copied from the main table")
+		return mainRoutes, nil
+	})
+	nl.SetAddRouteValidationFn(func(route *netlink.Route) error {
+		addedRoutes = append(addedRoutes, route)
+		return nil
+	})
+	nl.SetAddRuleValidationFn(func(rule *netlink.Rule) error {
+		addedRules = append(addedRules, rule)
+		// rules left over by a failed ADD are reused
+		return unix.EEXIST
+	})
+
+	require.NoError(t, setupSourceRouting(nl, netio.NewMockNetIO(false, 0), "eth1", epInfo))
+
+	require.Len(t, addedRoutes, len(mainRoutes))
+	for i, route := range addedRoutes {
+		require.Equal(t, 101, route.Table)
+		require.Equal(t, mainRoutes[i].Gw, route.Gw)
+		require.Zero(t, mainRoutes[i].Table, "main table routes shouldn't be modified")
+	}
+	require.Equal(t, []*netlink.Rule{
+		{
+			Family:   unix.AF_INET,
+			Src:      &net.IPNet{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(32, 32)},
+			Table:    101,
+			Priority: sourceRoutingRulePriority,
+		},
+		{
+			Family:   unix.AF_INET6,
+			Src:      &net.IPNet{IP: net.ParseIP("fd00::4"), Mask: net.CIDRMask(128, 128)},
+			Table:    101,
+			Priority: sourceRoutingRulePriority,
+		},
+	}, addedRules)
+}
+
+func TestSetupSourceRoutingFails(t *testing.T) {
+	epInfo := &EndpointInfo{
+		IPAddresses:  []net.IPNet{{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)}},
+		RouteTableID: 100,
+	}
+
+	err := setupSourceRouting(netlink.NewMockNetlink(false, ""), netio.NewMockNetIO(true, 1), "eth1", epInfo)
+	require.Error(t, err)
+
+	err = setupSourceRouting(netlink.NewMockNetlink(true, "mock netlink error"), netio.NewMockNetIO(false, 0), "eth1", epInfo)
+	require.Error(t, err)
+}
+
+func TestDeleteSourceRoutingRules(t *testing.T) {
+	ips := []net.IPNet{
+		{IP: net.ParseIP("10.0.0.4"), Mask: net.CIDRMask(24, 32)},
+		{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)},
+	}
+
+	var deleted []string
+	nl := netlink.NewMockNetlink(false, "")
+	nl.SetDeleteRuleValidationFn(func(rule *netlink.Rule) error {
+		require.Equal(t, 102, rule.Table)
+		deleted = append(deleted, rule.Src.String())
+		if rule.Src.IP.Equal(ips[0].IP) {
+			return unix.ENOENT
+		}
+		return nil
+	})
+	require.NoError(t, deleteSourceRoutingRules(nl, ips, 102))
+	require.Equal(t, []string{"10.0.0.4/32", "10.0.0.5/32"}, deleted)
+
+	nl.SetDeleteRuleValidationFn(func(*netlink.Rule) error { return unix.EPERM })
+	require.Error(t, deleteSourceRoutingRules(nl, ips, 102))
+}
@@ -0,0 +1,50 @@
+package network
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateRouteTableID(t *testing.T) {
+	nm := &networkManager{
+		ExternalInterfaces: map[string]*externalInterface{
+			"eth0": {
+				Networks: map[string]*network{
+					"nw1": {
+						Endpoints: map[string]*endpoint{
+							"ep1": {NetworkNameSpace: "/var/run/netns/pod1", RouteTableID: 100},
+							"ep2": {NetworkNameSpace: "/var/run/netns/pod2", RouteTableID: 101},
+							"ep3": {NetworkNameSpace: "/var/run/netns/pod1"},
+						},
+					},
+				},
+			},
+			"eth1": {
+				Networks: map[string]*network{
+					"nw2": {
+						Endpoints: map[string]*endpoint{
+							"ep4": {NetworkNameSpace: "/var/run/netns/pod1", RouteTableID: 102},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	id, err := nm.allocateRouteTableID("/var/run/netns/pod1")
+	require.NoError(t, err)
+	require.Equal(t, 101, id, "the lowest table unused in the namespace should be allocated")
+
+	id, err = nm.allocateRouteTableID("/var/run/netns/pod3")
+	require.NoError(t, err)
+	require.Equal(t, minSourceRoutingTableID, id)
+
+	endpoints := nm.ExternalInterfaces["eth0"].Networks["nw1"].Endpoints
+	for id := minSourceRoutingTableID; id <= maxSourceRoutingTableID; id++ {
+		endpoints["full-"+strconv.Itoa(id)] = &endpoint{NetworkNameSpace: "/var/run/netns/pod4", RouteTableID: id}
+	}
+	_, err = nm.allocateRouteTableID("/var/run/netns/pod4")
+	require.ErrorIs(t, err, errRouteTablesExhausted)
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+)
+
+const (
+	ipv4ForwardSysctl  = "net.ipv4.ip_forward"
+	ipv6ForwardSysctl  = "net.ipv6.conf.all.forwarding"
+	rpFilterAllSysctl  = "net.ipv4.conf.all.rp_filter"
+	rpFilterDisabled   = "0"
+	rpFilterStrict     = "1"
+	rpFilterLoose      = "2"
+	sysctlEnabledValue = "1"
+)
+
+// hostSysctl is a kernel parameter of the host which the datapath of a network mode requires, instead of assuming
+// that the node image sets it.
+type hostSysctl struct {
+	key   string
+	value string
+	// accepts, if set, accepts current values other than value, e.g. an rp_filter which is already off.
+	accepts func(current string) bool
+}
+
+func (s hostSysctl) satisfiedBy(current string) bool {
+	if s.accepts != nil {
+		return s.accepts(current)
+	}
+	return current == s.value
+}
+
+// requiredSysctls returns the host sysctls which the datapath of the network mode requires.
+func requiredSysctls(mode string, ipv6 bool) []hostSysctl {
+	sysctls := []hostSysctl{{key: ipv4ForwardSysctl, value: sysctlEnabledValue}}
+	switch mode {
+	case opModeTransparent:
+		if ipv6 {
+			sysctls = append(sysctls, hostSysctl{key: ipv6ForwardSysctl, value: sysctlEnabledValue})
+		}
+	case opModeTransparentVlan:
+		// the tunneled traffic is routed asymmetrically, which a strict rp_filter drops
+		sysctls = append(sysctls, looseRPFilter())
+	case opModeVnetBlock:
+		if ipv6 {
+			sysctls = append(sysctls, hostSysctl{key: ipv6ForwardSysctl, value: sysctlEnabledValue})
+		}
+		// the kernel applies the highest of the all and interface rp_filters, so the rp_filter disabled on the host
+		// veths only takes effect if the all rp_filter isn't strict
+		sysctls = append(sysctls, looseRPFilter())
+	}
+	return sysctls
+}
+
+func looseRPFilter() hostSysctl {
+	return hostSysctl{
+		key:     rpFilterAllSysctl,
+		value:   rpFilterLoose,
+		accepts: func(current string) bool { return current != rpFilterStrict },
+	}
+}
+
+// applySysctls sets the sysctls which don't have a value the datapath accepts, and returns the prior values of the
+// sysctls it set, so that they're restored when the network is deleted. If a sysctl can't be set, the sysctls which
+// were set are restored.
+func (nm *networkManager) applySysctls(sysctls []hostSysctl) (map[string]string, error) {
+	nu := networkutils.NewNetworkUtils(nm.netlink, nm.plClient)
+	prior := map[string]string{}
+	for _, s := range sysctls {
+		current, err := nu.ReadSysctl(s.key)
+		if err != nil {
+			log.Printf("[net] Failed to read sysctl %s, setting it without recording its value: %v", s.key, err)
+		} else if s.satisfiedBy(current) {
+			continue
+		}
+		if err := nu.WriteSysctl(s.key, s.value); err != nil {
+			nm.writeSysctls(prior)
+			return nil, fmt.Errorf("failed to set sysctl required by the datapath: %w", err)
+		}
+		log.Printf("[net] Set sysctl %s=%s, was %q", s.key, s.value, current)
+		if current != "" {
+			prior[s.key] = current
+		}
+	}
+	return prior, nil
+}
+
+// restoreSysctls restores the prior values of the sysctls set for a network which is deleted. The sysctls required
+// by a remaining network aren't restored: the remaining network takes over their prior values instead.
+func (nm *networkManager) restoreSysctls(nw *network) {
+	restore := map[string]string{}
+	for key, value := range nw.HostSysctls {
+		if other := nm.networkRequiringSysctl(nw, key); other != nil {
+			if other.HostSysctls == nil {
+				other.HostSysctls = map[string]string{}
+			}
+			other.HostSysctls[key] = value
+			continue
+		}
+		restore[key] = value
+	}
+	nm.writeSysctls(restore)
+	nw.HostSysctls = nil
+}
+
+// networkRequiringSysctl returns a network other than nw whose mode may require the sysctl, nil if there is none.
+func (nm *networkManager) networkRequiringSysctl(nw *network, key string) *network {
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, other := range extIf.Networks {
+			if other == nw {
+				continue
+			}
+			for _, s := range requiredSysctls(other.Mode, true) {
+				if s.key == key {
+					return other
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (nm *networkManager) writeSysctls(sysctls map[string]string) {
+	nu := networkutils.NewNetworkUtils(nm.netlink, nm.plClient)
+	for key, value := range sysctls {
+		if err := nu.WriteSysctl(key, value); err != nil {
+			log.Errorf("[net] Failed to restore sysctl %s=%s: %v", key, value, err)
+			continue
+		}
+		log.Printf("[net] Restored sysctl %s=%s", key, value)
+	}
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+// ResourceKind is the kind of a resource created for an endpoint.
+type ResourceKind string
+
+// Resource is a resource created for an endpoint during ADD. The resources are recorded in the endpoint
+// state, with the resources each one depends on, so DEL can tear them down in reverse dependency order.
+type Resource struct {
+	Kind      ResourceKind
+	ID        string
+	DependsOn []string `json:",omitempty"`
+}
+
+// Key identifies the resource in the DependsOn of other resources.
+func (r Resource) Key() string {
+	return string(r.Kind) + "/" + r.ID
+}
+
+// resourceDeleter deletes the resource with the ID.
+type resourceDeleter func(id string) error
+
+var (
+	errNoResourceDeleter   = errors.New("no deleter for resource kind")
+	errDependentNotDeleted = errors.New("resource is still in use by a dependent which couldn't be deleted")
+)
+
+// teardownResources deletes the resources, each one before the resources it depends on. A failed deletion doesn't
+// stop the deletion of the resources independent of it, but the resources it depends on are kept since they are
+// still in use; the errors of all the resources are returned so DEL can be retried.
+func teardownResources(resources []Resource, deleters map[ResourceKind]resourceDeleter) error {
+	var errs []error
+	kept := make(map[string]bool, len(resources))
+	dependents := make(map[string][]string, len(resources))
+	for _, r := range resources {
+		for _, dep := range r.DependsOn {
+			dependents[dep] = append(dependents[dep], r.Key())
+		}
+	}
+
+	for _, r := range teardownOrder(resources) {
+		key := r.Key()
+		blocked := ""
+		for _, dependent := range dependents[key] {
+			if kept[dependent] {
+				blocked = dependent
+				break
+			}
+		}
+		if blocked != "" {
+			log.Printf("[net] Keeping resource %s, its dependent %s wasn't deleted", key, blocked)
+			errs = append(errs, fmt.Errorf("%s: %w: %s", key, errDependentNotDeleted, blocked))
+			kept[key] = true
+			continue
+		}
+
+		deleter, ok := deleters[r.Kind]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %w", key, errNoResourceDeleter))
+			kept[key] = true
+			continue
+		}
+
+		log.Printf("[net] Deleting resource %s", key)
+		if err := deleter(r.ID); err != nil {
+			log.Printf("[net] Failed to delete resource %s: %v", key, err)
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", key, err))
+			kept[key] = true
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// teardownOrder returns the resources ordered so that each resource comes before the resources it depends on.
+// Independent resources are deleted in the reverse order of their creation. Resources in a dependency cycle,
+// which can only come from a corrupted state, are deleted last.
+func teardownOrder(resources []Resource) []Resource {
+	recorded := make(map[string]bool, len(resources))
+	for _, r := range resources {
+		recorded[r.Key()] = true
+	}
+
+	// number of recorded dependents of each resource which aren't ordered yet
+	pending := make(map[string]int, len(resources))
+	for _, r := range resources {
+		for _, dep := range r.DependsOn {
+			if recorded[dep] {
+				pending[dep]++
+			}
+		}
+	}
+
+	ordered := make([]Resource, 0, len(resources))
+	done := make(map[string]bool, len(resources))
+	for progress := true; progress; {
+		progress = false
+		for i := len(resources) - 1; i >= 0; i-- {
+			r := resources[i]
+			if done[r.Key()] || pending[r.Key()] > 0 {
+				continue
+			}
+			ordered = append(ordered, r)
+			done[r.Key()] = true
+			for _, dep := range r.DependsOn {
+				pending[dep]--
+			}
+			progress = true
+		}
+	}
+
+	for i := len(resources) - 1; i >= 0; i-- {
+		if !done[resources[i].Key()] {
+			log.Printf("[net] Resource %s is in a dependency cycle", resources[i].Key())
+			ordered = append(ordered, resources[i])
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errDeleteFailed = errors.New("delete failed")
+
+func TestTeardownResources(t *testing.T) {
+	veth := Resource{Kind: "veth", ID: "azv1"}
+	rules := Resource{Kind: "rules", ID: "ep1", DependsOn: []string{veth.Key()}}
+	ifb := Resource{Kind: "ifb", ID: "azb1", DependsOn: []string{veth.Key()}}
+	policy := Resource{Kind: "policy", ID: "p1", DependsOn: []string{rules.Key()}}
+	apipa := Resource{Kind: "apipa", ID: "nc1"}
+
+	tests := []struct {
+		name      string
+		resources []Resource
+		failing   map[string]bool
+		deleted   []string
+		wantErr   bool
+	}{
+		{
+			name:      "reverse dependency order",
+			resources: []Resource{veth, rules, ifb, policy, apipa},
+			deleted:   []string{"apipa/nc1", "policy/p1", "ifb/azb1", "rules/ep1", "veth/azv1"},
+		},
+		{
+			name:      "dependencies recorded after their dependents",
+			resources: []Resource{policy, rules, veth},
+			deleted:   []string{"policy/p1", "rules/ep1", "veth/azv1"},
+		},
+		{
+			name:      "failure keeps dependencies and deletes independent resources",
+			resources: []Resource{veth, rules, ifb, policy, apipa},
+			failing:   map[string]bool{"policy/p1": true},
+			deleted:   []string{"apipa/nc1", "ifb/azb1"},
+			wantErr:   true,
+		},
+		{
+			name:      "failure of an independent resource",
+			resources: []Resource{veth, rules, apipa},
+			failing:   map[string]bool{"apipa/nc1": true},
+			deleted:   []string{"rules/ep1", "veth/azv1"},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown kind",
+			resources: []Resource{veth, {Kind: "unknown", ID: "u1", DependsOn: []string{veth.Key()}}},
+			deleted:   nil,
+			wantErr:   true,
+		},
+		{
+			name: "cycle",
+			resources: []Resource{
+				{Kind: "veth", ID: "a", DependsOn: []string{"rules/b"}},
+				{Kind: "rules", ID: "b", DependsOn: []string{"veth/a"}},
+			},
+			deleted: []string{"rules/b", "veth/a"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			deleter := func(kind ResourceKind) resourceDeleter {
+				return func(id string) error {
+					key := Resource{Kind: kind, ID: id}.Key()
+					if tt.failing[key] {
+						return errDeleteFailed
+					}
+					deleted = append(deleted, key)
+					return nil
+				}
+			}
+			deleters := map[ResourceKind]resourceDeleter{}
+			for _, kind := range []ResourceKind{"veth", "rules", "ifb", "policy", "apipa"} {
+				deleters[kind] = deleter(kind)
+			}
+
+			err := teardownResources(tt.resources, deleters)
+			require.Equal(t, tt.deleted, deleted)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
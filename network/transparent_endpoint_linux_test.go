@@ -389,3 +389,45 @@ func TestTransConfigureContainerInterfacesAndRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestTransVnetBlockRules(t *testing.T) {
+	var cmds []string
+	plc := platform.NewMockExecClient(false)
+	plc.SetExecCommand(func(cmd string) (string, error) {
+		cmds = append(cmds, cmd)
+		return "", nil
+	})
+	nl := netlink.NewMockNetlink(false, "")
+	client := &TransparentEndpointClient{
+		hostPrimaryIfName: "eth0",
+		hostVethName:      "azvhost",
+		containerVethName: "azvcontainer",
+		netlink:           nl,
+		plClient:          plc,
+		netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+		netioshim:         netio.NewMockNetIO(false, 0),
+	}
+	ipAddresses := []net.IPNet{
+		{IP: net.ParseIP("192.168.0.4"), Mask: net.CIDRMask(ipv4FullMask, ipv4Bits)},
+		{IP: net.ParseIP("fc00::4"), Mask: net.CIDRMask(ipv6FullMask, ipv6Bits)},
+	}
+	vnetBlock := &VnetBlockInfo{DisableProxyARP: true, ProxyNDP: true, DisableRPFilter: true}
+
+	err := client.AddEndpointRules(&EndpointInfo{IPAddresses: ipAddresses, VnetBlock: vnetBlock})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"sysctl -w net.ipv4.conf.azvhost.rp_filter=0",
+		"sysctl -w net.ipv6.conf.eth0.proxy_ndp=1",
+		"ip -6 neigh replace proxy fc00::4 dev eth0",
+	}, cmds)
+
+	cmds = nil
+	client.DeleteEndpointRules(&endpoint{IPAddresses: ipAddresses, VnetBlock: vnetBlock})
+	require.Equal(t, []string{"ip -6 neigh del proxy fc00::4 dev eth0"}, cmds)
+
+	// the default toggles only set proxy ARP on the host veth, like the transparent mode
+	cmds = nil
+	err = client.AddEndpointRules(&EndpointInfo{IPAddresses: ipAddresses, VnetBlock: &VnetBlockInfo{}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo 1 > /proc/sys/net/ipv4/conf/azvhost/proxy_arp"}, cmds)
+}
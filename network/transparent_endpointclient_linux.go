@@ -157,10 +157,16 @@ func (client *TransparentEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		}
 	}
 
-	log.Printf("calling setArpProxy for %v", client.hostVethName)
-	if err := client.setArpProxy(client.hostVethName); err != nil {
-		log.Printf("setArpProxy failed with: %v", err)
-		return err
+	if epInfo.VnetBlock == nil || !epInfo.VnetBlock.DisableProxyARP {
+		log.Printf("calling setArpProxy for %v", client.hostVethName)
+		if err := client.setArpProxy(client.hostVethName); err != nil {
+			log.Printf("setArpProxy failed with: %v", err)
+			return err
+		}
+	}
+
+	if epInfo.VnetBlock != nil {
+		return client.configureVnetBlock(epInfo)
 	}
 
 	return nil
@@ -187,6 +193,10 @@ func (client *TransparentEndpointClient) DeleteEndpointRules(ep *endpoint) {
 			log.Printf("[net] Failed to delete route on VM for the ip %v: %v", ipNet.String(), err)
 		}
 	}
+
+	if ep.VnetBlock != nil && ep.VnetBlock.ProxyNDP {
+		client.deleteProxyNDPEntries(ep.IPAddresses)
+	}
 }
 
 func (client *TransparentEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
@@ -0,0 +1,15 @@
+package network
+
+// VnetBlockInfo configures the host for an endpoint in the vnet-block mode, where the IPs of the pods are from a
+// block of the VNet routed to the node. Like in the transparent mode, the host routes each IP of the pods to its veth
+// through a /32 (or /128) route; the toggles replace the scripts which set up the rest of the datapath on the nodes.
+type VnetBlockInfo struct {
+	// DisableProxyARP stops the host veth from answering the ARP requests of the pod, when the pod resolves its
+	// gateway by other means.
+	DisableProxyARP bool
+	// ProxyNDP answers the neighbor solicitations for the IPv6 of the pod on the host primary interface.
+	ProxyNDP bool
+	// DisableRPFilter disables the reverse path filter on the host veth, for the traffic of the pod which is routed
+	// asymmetrically, e.g. through another interface of the host.
+	DisableRPFilter bool
+}
@@ -0,0 +1,64 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+)
+
+const (
+	proxyNDPSysctl         = "net.ipv6.conf.%s.proxy_ndp"
+	rpFilterSysctl         = "net.ipv4.conf.%s.rp_filter"
+	addProxyNeighborCmd    = "ip -6 neigh replace proxy %s dev %s"
+	deleteProxyNeighborCmd = "ip -6 neigh del proxy %s dev %s"
+)
+
+// configureVnetBlock applies the vnet-block toggles of the endpoint to the host, once the routes to the pod are added.
+// The proxy_ndp of the host primary interface is shared by the endpoints, so it isn't reset when they're deleted.
+func (client *TransparentEndpointClient) configureVnetBlock(epInfo *EndpointInfo) error {
+	if epInfo.VnetBlock.DisableRPFilter {
+		log.Printf("[net] Disabling rp_filter on %s", client.hostVethName)
+		if err := client.netUtilsClient.WriteSysctl(fmt.Sprintf(rpFilterSysctl, client.hostVethName), rpFilterDisabled); err != nil {
+			return newErrorTransparentEndpointClient(err.Error())
+		}
+	}
+
+	if !epInfo.VnetBlock.ProxyNDP {
+		return nil
+	}
+	ipv6s := ipv6Addresses(epInfo.IPAddresses)
+	if len(ipv6s) == 0 {
+		return nil
+	}
+	if err := client.netUtilsClient.WriteSysctl(fmt.Sprintf(proxyNDPSysctl, client.hostPrimaryIfName), sysctlEnabledValue); err != nil {
+		return newErrorTransparentEndpointClient(err.Error())
+	}
+	for _, ip := range ipv6s {
+		log.Printf("[net] Adding proxy neighbor entry for %s on %s", ip, client.hostPrimaryIfName)
+		if _, err := client.plClient.ExecuteCommand(fmt.Sprintf(addProxyNeighborCmd, ip, client.hostPrimaryIfName)); err != nil {
+			return newErrorTransparentEndpointClient(fmt.Sprintf("failed to add proxy neighbor entry for %s: %v", ip, err))
+		}
+	}
+	return nil
+}
+
+// deleteProxyNDPEntries deletes the proxy neighbor entries of the IPv6 of the endpoint from the host primary interface.
+func (client *TransparentEndpointClient) deleteProxyNDPEntries(ipAddresses []net.IPNet) {
+	for _, ip := range ipv6Addresses(ipAddresses) {
+		log.Printf("[net] Deleting proxy neighbor entry for %s on %s", ip, client.hostPrimaryIfName)
+		if _, err := client.plClient.ExecuteCommand(fmt.Sprintf(deleteProxyNeighborCmd, ip, client.hostPrimaryIfName)); err != nil {
+			log.Printf("[net] Failed to delete proxy neighbor entry for %s: %v", ip, err)
+		}
+	}
+}
+
+func ipv6Addresses(ipAddresses []net.IPNet) []net.IP {
+	var ips []net.IP
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() == nil {
+			ips = append(ips, ipAddr.IP)
+		}
+	}
+	return ips
+}
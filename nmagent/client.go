@@ -21,10 +21,15 @@ func NewClient(c Config) (*Client, error) {
 		return nil, errors.Wrap(err, "validating config")
 	}
 
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
 	client := &Client{
 		httpClient: &http.Client{
 			Transport: &internal.WireserverTransport{
-				Transport: http.DefaultTransport,
+				Transport: transport,
 			},
 		},
 		host:      c.Host,
@@ -56,62 +61,21 @@ type Client struct {
 
 // JoinNetwork joins a node to a customer's virtual network.
 func (c *Client) JoinNetwork(ctx context.Context, jnr JoinNetworkRequest) error {
-	req, err := c.buildRequest(ctx, jnr)
-	if err != nil {
-		return errors.Wrap(err, "building request")
-	}
-
-	err = c.retrier.Do(ctx, func() error {
-		resp, err := c.httpClient.Do(req) // nolint:govet // the shadow is intentional
-		if err != nil {
-			return errors.Wrap(err, "executing request")
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return die(resp.StatusCode, resp.Header, resp.Body)
-		}
-		return nil
-	})
-
-	return err // nolint:wrapcheck // wrapping this just introduces noise
+	return c.do(ctx, jnr, nil)
 }
 
 // GetNetworkConfiguration retrieves the configuration of a customer's virtual
 // network. Only subnets which have been delegated will be returned.
 func (c *Client) GetNetworkConfiguration(ctx context.Context, gncr GetNetworkConfigRequest) (VirtualNetwork, error) {
 	var out VirtualNetwork
-
-	req, err := c.buildRequest(ctx, gncr)
-	if err != nil {
-		return out, errors.Wrap(err, "building request")
-	}
-
-	err = c.retrier.Do(ctx, func() error {
-		resp, err := c.httpClient.Do(req) // nolint:govet // the shadow is intentional
-		if err != nil {
-			return errors.Wrap(err, "executing http request to")
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return die(resp.StatusCode, resp.Header, resp.Body)
-		}
-
+	err := c.do(ctx, gncr, func(resp *http.Response) error {
 		ct := resp.Header.Get(internal.HeaderContentType)
 		if ct != internal.MimeJSON {
 			return NewContentError(ct, resp.Body, resp.ContentLength)
 		}
-
-		err = json.NewDecoder(resp.Body).Decode(&out)
-		if err != nil {
-			return errors.Wrap(err, "decoding json response")
-		}
-
-		return nil
+		return decodeJSON(resp, &out)
 	})
-
-	return out, err // nolint:wrapcheck // wrapping just introduces noise here
+	return out, err
 }
 
 // GetNetworkContainerVersion gets the current goal state version of a Network
@@ -121,145 +85,120 @@ func (c *Client) GetNetworkConfiguration(ctx context.Context, gncr GetNetworkCon
 // Provisioning OwningServiceInstanceId property. The authentication token must
 // match the token on the subnet containing the Network Container address.
 func (c *Client) GetNCVersion(ctx context.Context, ncvr NCVersionRequest) (NCVersion, error) {
-	req, err := c.buildRequest(ctx, ncvr)
-	if err != nil {
-		return NCVersion{}, errors.Wrap(err, "building request")
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return NCVersion{}, errors.Wrap(err, "submitting request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return NCVersion{}, die(resp.StatusCode, resp.Header, resp.Body)
-	}
-
 	var out NCVersion
-	err = json.NewDecoder(resp.Body).Decode(&out)
+	err := c.do(ctx, ncvr, func(resp *http.Response) error {
+		return decodeJSON(resp, &out)
+	})
 	if err != nil {
-		return NCVersion{}, errors.Wrap(err, "decoding response")
+		return NCVersion{}, err
 	}
-
 	return out, nil
 }
 
 // PutNetworkContainer applies a Network Container goal state and publishes it
 // to PubSub.
 func (c *Client) PutNetworkContainer(ctx context.Context, pncr *PutNetworkContainerRequest) error {
-	req, err := c.buildRequest(ctx, pncr)
-	if err != nil {
-		return errors.Wrap(err, "building request")
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "submitting request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return die(resp.StatusCode, resp.Header, resp.Body)
-	}
-	return nil
+	return c.do(ctx, pncr, nil)
 }
 
 // SupportedAPIs retrieves the capabilities of the nmagent running on
 // the node. This is useful for detecting if GRE Keys are supported.
 func (c *Client) SupportedAPIs(ctx context.Context) ([]string, error) {
-	sar := &SupportedAPIsRequest{}
-	req, err := c.buildRequest(ctx, sar)
-	if err != nil {
-		return nil, errors.Wrap(err, "building request")
-	}
-
-	resp, err := c.httpClient.Do(req)
+	var out SupportedAPIsResponseXML
+	err := c.do(ctx, &SupportedAPIsRequest{}, func(resp *http.Response) error {
+		return errors.Wrap(xml.NewDecoder(resp.Body).Decode(&out), "decoding response")
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "submitting request")
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return out.SupportedApis, nil
+}
 
-	var out SupportedAPIsResponseXML
-	err = xml.NewDecoder(resp.Body).Decode(&out)
+// GetInterfaceIPInfo retrieves the interfaces of the VM, with the prefixes of
+// their subnets and their IP addresses.
+func (c *Client) GetInterfaceIPInfo(ctx context.Context) (Interfaces, error) {
+	var out Interfaces
+	err := c.do(ctx, &GetInterfaceIPInfoRequest{}, func(resp *http.Response) error {
+		return errors.Wrap(xml.NewDecoder(resp.Body).Decode(&out), "decoding response")
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "decoding response")
+		return Interfaces{}, err
 	}
-
-	return out.SupportedApis, nil
+	return out, nil
 }
 
 // DeleteNetworkContainer removes a Network Container, its associated IP
 // addresses, and network policies from an interface.
 func (c *Client) DeleteNetworkContainer(ctx context.Context, dcr DeleteContainerRequest) error {
-	req, err := c.buildRequest(ctx, dcr)
-	if err != nil {
-		return errors.Wrap(err, "building request")
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "submitting request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return die(resp.StatusCode, resp.Header, resp.Body)
-	}
-
-	return nil
+	return c.do(ctx, dcr, nil)
 }
 
+// GetNCVersionList gets the current goal state versions of all the Network
+// Containers of the node.
 func (c *Client) GetNCVersionList(ctx context.Context) (NCVersionList, error) {
-	req, err := c.buildRequest(ctx, &NCVersionListRequest{})
-	if err != nil {
-		return NCVersionList{}, errors.Wrap(err, "building request")
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return NCVersionList{}, errors.Wrap(err, "submitting request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return NCVersionList{}, die(resp.StatusCode, resp.Header, resp.Body)
-	}
-
 	var out NCVersionList
-	err = json.NewDecoder(resp.Body).Decode(&out)
+	err := c.do(ctx, &NCVersionListRequest{}, func(resp *http.Response) error {
+		return decodeJSON(resp, &out)
+	})
 	if err != nil {
-		return NCVersionList{}, errors.Wrap(err, "decoding response")
+		return NCVersionList{}, err
 	}
-
 	return out, nil
 }
 
 // GetHomeAz gets node's home az from nmagent
 func (c *Client) GetHomeAz(ctx context.Context) (AzResponse, error) {
-	getHomeAzRequest := &GetHomeAzRequest{}
-	var homeAzResponse AzResponse
-	req, err := c.buildRequest(ctx, getHomeAzRequest)
+	var out AzResponse
+	err := c.do(ctx, &GetHomeAzRequest{}, func(resp *http.Response) error {
+		return decodeJSON(resp, &out)
+	})
 	if err != nil {
-		return homeAzResponse, errors.Wrap(err, "building request")
+		return AzResponse{}, err
 	}
+	return out, nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return homeAzResponse, errors.Wrap(err, "submitting request")
-	}
-	defer resp.Body.Close()
+// do submits the request, retrying while NMAgent reports that it's still
+// processing it, and handles the successful response, if handle isn't nil.
+// The request is rebuilt for each attempt, since its body is consumed.
+func (c *Client) do(ctx context.Context, req Request, handle func(*http.Response) error) error {
+	err := c.retrier.Do(ctx, func() error {
+		httpReq, err := c.buildRequest(ctx, req)
+		if err != nil {
+			return errors.Wrap(err, "building request")
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return homeAzResponse, die(resp.StatusCode, resp.Header, resp.Body)
-	}
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			return errors.Wrap(err, "submitting request")
+		}
+		defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(&homeAzResponse)
-	if err != nil {
-		return homeAzResponse, errors.Wrap(err, "decoding response")
+		if resp.StatusCode != http.StatusOK {
+			return die(resp.StatusCode, resp.Header, resp.Body)
+		}
+		if handle == nil {
+			return nil
+		}
+		return handle(resp)
+	})
+	return err // nolint:wrapcheck // wrapping this just introduces noise
+}
+
+// decodeJSON decodes the JSON response into out, and validates it if it's a
+// validator.
+func decodeJSON(resp *http.Response, out interface{}) error {
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "decoding response")
 	}
+	if v, ok := out.(validator); ok {
+		return errors.Wrap(v.Validate(), "validating response")
+	}
+	return nil
+}
 
-	return homeAzResponse, nil
+type validator interface {
+	Validate() error
 }
 
 func die(code int, headers http.Header, body io.ReadCloser) error {
@@ -3,7 +3,9 @@ package nmagent_test
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -373,6 +375,55 @@ func TestNMAgentPutNetworkContainer(t *testing.T) {
 	}
 }
 
+func TestNMAgentPutNetworkContainerRetry(t *testing.T) {
+	// the request body must be sent again with each attempt
+	var bodies []string
+	client := nmagent.NewTestClient(&TestTripper{
+		RoundTripF: func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal("unexpected error reading request body: err:", err)
+			}
+			bodies = append(bodies, string(body))
+
+			rr := httptest.NewRecorder()
+			if len(bodies) < 3 {
+				rr.WriteHeader(http.StatusProcessing)
+			} else {
+				rr.WriteHeader(http.StatusOK)
+			}
+			_, _ = rr.WriteString(`{"httpStatusCode": "200"}`)
+			return rr.Result(), nil
+		},
+	})
+
+	ctx, cancel := testContext(t)
+	defer cancel()
+
+	err := client.PutNetworkContainer(ctx, &nmagent.PutNetworkContainerRequest{
+		ID:                  "350f1e3c-4283-4f51-83a1-c44253962ef1",
+		Version:             uint64(12345),
+		VNetID:              "be3a33e-61e3-42c7-bd23-6b949f57bd36",
+		SubnetName:          "TestSubnet",
+		IPv4Addrs:           []string{"10.0.0.43"},
+		VlanID:              1234,
+		AuthenticationToken: "swordfish",
+		PrimaryAddress:      "10.0.0.1",
+	})
+	if err != nil {
+		t.Fatal("unexpected error: err:", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatal("client did not make the expected number of API calls: got:", len(bodies), "exp:", 3)
+	}
+	for _, body := range bodies {
+		if body == "" || body != bodies[0] {
+			t.Error("request body differs between attempts: got:", body, "exp:", bodies[0])
+		}
+	}
+}
+
 func TestNMAgentDeleteNC(t *testing.T) {
 	deleteTests := []struct {
 		name      string
@@ -481,6 +532,84 @@ func TestNMAgentSupportedAPIs(t *testing.T) {
 	}
 }
 
+func TestNMAgentGetInterfaceIPInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		exp       nmagent.Interfaces
+		expPath   string
+		resp      string
+		shouldErr bool
+	}{
+		{
+			"happy",
+			nmagent.Interfaces{
+				XMLName: xml.Name{Local: "Interfaces"},
+				Interface: []nmagent.Interface{
+					{
+						MacAddress: "002248263DBD",
+						IsPrimary:  true,
+						IPSubnet: []nmagent.InterfaceSubnet{
+							{
+								Prefix: "10.240.0.0/16",
+								IPAddress: []nmagent.InterfaceAddress{
+									{Address: "10.240.0.4", IsPrimary: true},
+									{Address: "10.240.0.5", IsPrimary: false},
+								},
+							},
+						},
+					},
+				},
+			},
+			"/machine/plugins?comp=nmagent&type=getinterfaceinfov1",
+			`<Interfaces><Interface MacAddress="002248263DBD" IsPrimary="true"><IPSubnet Prefix="10.240.0.0/16">` +
+				`<IPAddress Address="10.240.0.4" IsPrimary="true"/><IPAddress Address="10.240.0.5" IsPrimary="false"/>` +
+				`</IPSubnet></Interface></Interfaces>`,
+			false,
+		},
+		{
+			"malformed",
+			nmagent.Interfaces{},
+			"/machine/plugins?comp=nmagent&type=getinterfaceinfov1",
+			"<Interfaces>",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotPath string
+			client := nmagent.NewTestClient(&TestTripper{
+				RoundTripF: func(req *http.Request) (*http.Response, error) {
+					gotPath = req.URL.RequestURI()
+					rr := httptest.NewRecorder()
+					_, _ = rr.WriteString(test.resp)
+					return rr.Result(), nil
+				},
+			})
+
+			got, err := client.GetInterfaceIPInfo(context.Background())
+			if err != nil && !test.shouldErr {
+				t.Fatal("unexpected error: err:", err)
+			}
+
+			if err == nil && test.shouldErr {
+				t.Fatal("expected error but received none")
+			}
+
+			if gotPath != test.expPath {
+				t.Error("paths differ: got:", gotPath, "exp:", test.expPath)
+			}
+
+			if !cmp.Equal(got, test.exp) {
+				t.Error("response differs from expectation: diff:", cmp.Diff(got, test.exp))
+			}
+		})
+	}
+}
+
 func TestGetNCVersion(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -610,6 +739,20 @@ func TestGetNCVersionList(t *testing.T) {
 			nmagent.NCVersionList{},
 			true,
 		},
+		{
+			"missing version",
+			map[string]interface{}{
+				"httpStatusCode": "200",
+				"networkContainers": []map[string]interface{}{
+					{
+						"networkContainerId": "foo",
+					},
+				},
+			},
+			"/machine/plugins?comp=nmagent&type=NetworkManagement%2Finterfaces%2Fapi-version%2F2",
+			nmagent.NCVersionList{},
+			true,
+		},
 	}
 
 	for _, test := range tests {
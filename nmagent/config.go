@@ -2,6 +2,7 @@ package nmagent
 
 import (
 	"net"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -21,7 +22,8 @@ type Config struct {
 	/////////////////////
 	// Optional Config //
 	/////////////////////
-	UseTLS bool // forces all connections to use TLS
+	UseTLS    bool              // forces all connections to use TLS
+	Transport http.RoundTripper // the transport of the requests, http.DefaultTransport if nil
 }
 
 // Validate reports whether this configuration is a valid configuration for a
@@ -51,7 +51,12 @@ func (r Retrier) Do(ctx context.Context, run func() error) error {
 				if err != nil {
 					return pkgerrors.Wrap(err, "sleeping during retry")
 				}
-				time.Sleep(delay)
+				select {
+				case <-ctx.Done():
+					// nolint:wrapcheck // no meaningful information can be added to this error
+					return ctx.Err()
+				case <-time.After(delay):
+				}
 				continue
 			}
 
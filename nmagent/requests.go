@@ -497,3 +497,32 @@ func (g *GetHomeAzRequest) Path() string {
 func (g *GetHomeAzRequest) Validate() error {
 	return nil
 }
+
+var _ Request = &GetInterfaceIPInfoRequest{}
+
+// GetInterfaceIPInfoRequest is a request for the interfaces of the VM and
+// their IP addresses.
+type GetInterfaceIPInfoRequest struct{}
+
+// Body is a no-op method to satisfy the Request interface while indicating
+// that there is no body for a GetInterfaceIPInfo Request.
+func (g *GetInterfaceIPInfoRequest) Body() (io.Reader, error) {
+	return nil, nil
+}
+
+// Method indicates that GetInterfaceIPInfo requests are GET requests.
+func (g *GetInterfaceIPInfoRequest) Method() string {
+	return http.MethodGet
+}
+
+// Path returns the necessary URI path for invoking a GetInterfaceIPInfo
+// request.
+func (g *GetInterfaceIPInfoRequest) Path() string {
+	return "/getinterfaceinfov1"
+}
+
+// Validate is a no-op method because GetInterfaceIPInfoRequest have no
+// parameters, and therefore can never be invalid.
+func (g *GetInterfaceIPInfoRequest) Validate() error {
+	return nil
+}
@@ -1,5 +1,12 @@
 package nmagent
 
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/nmagent/internal"
+)
+
 type VirtualNetwork struct {
 	CNetSpace      string   `json:"cnetSpace"`
 	DefaultGateway string   `json:"defaultGateway"`
@@ -24,6 +31,29 @@ type SupportedAPIsResponseXML struct {
 	SupportedApis []string `xml:"type"`
 }
 
+// Interfaces is the response produced from requests for the interfaces of
+// the VM and their IP addresses.
+type Interfaces struct {
+	XMLName   xml.Name    `xml:"Interfaces"`
+	Interface []Interface `xml:"Interface"`
+}
+
+type Interface struct {
+	MacAddress string            `xml:"MacAddress,attr"`
+	IsPrimary  bool              `xml:"IsPrimary,attr"`
+	IPSubnet   []InterfaceSubnet `xml:"IPSubnet"`
+}
+
+type InterfaceSubnet struct {
+	Prefix    string             `xml:"Prefix,attr"`
+	IPAddress []InterfaceAddress `xml:"IPAddress"`
+}
+
+type InterfaceAddress struct {
+	Address   string `xml:"Address,attr"`
+	IsPrimary bool   `xml:"IsPrimary,attr"`
+}
+
 // NCVersion is a response produced from requests for a network container's
 // version.
 type NCVersion struct {
@@ -31,12 +61,42 @@ type NCVersion struct {
 	Version            string `json:"version"` // the current network container version
 }
 
+// Validate ensures that the NCVersion identifies the network container and
+// its version.
+func (n NCVersion) Validate() error {
+	err := internal.ValidationError{}
+
+	if n.NetworkContainerID == "" {
+		err.MissingFields = append(err.MissingFields, "NetworkContainerID")
+	}
+
+	if n.Version == "" {
+		err.MissingFields = append(err.MissingFields, "Version")
+	}
+
+	if err.IsEmpty() {
+		return nil
+	}
+
+	return err
+}
+
 // NetworkContainerListResponse is a collection of network container IDs mapped
 // to their current versions.
 type NCVersionList struct {
 	Containers []NCVersion `json:"networkContainers"`
 }
 
+// Validate ensures that every NCVersion of the list is valid.
+func (n NCVersionList) Validate() error {
+	for i, nc := range n.Containers {
+		if err := nc.Validate(); err != nil {
+			return fmt.Errorf("networkContainers[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
 type AzResponse struct {
 	HomeAz uint `json:"homeAz"`
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"time"
 
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	contracts "github.com/Azure/azure-container-networking/proto/nodenetworkservice/3.302.0.744"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -63,6 +65,11 @@ func configureContainerNetworking(
 		NetworkNamespaceId: nwNamespace,
 	}
 
+	// pass the correlation ID of the CNI invocation, so that nns can log the request with it
+	if id := acn.CorrelationIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, acn.CorrelationIDMetadataKey, id)
+	}
+
 	localCtx, cancel := context.WithTimeout(ctx, apiTimeout)
 	defer cancel()
 
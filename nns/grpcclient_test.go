@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/test/nnsmockserver"
 )
 
@@ -42,6 +43,18 @@ func TestAddContainerNetworking(t *testing.T) {
 	}
 }
 
+// the correlation ID of the CNI invocation is passed to nns
+func TestAddContainerNetworkingCorrelationID(t *testing.T) {
+	client := &GrpcClient{}
+	ctx := acn.WithCorrelationID(context.Background(), "correlation-id")
+	if _, err := client.AddContainerNetworking(ctx, "sf_8e9961f4-5b4f-4b3c-a9ae-c3294b0d9681", "testnwspace"); err != nil {
+		t.Fatalf("TestAddContainerNetworkingCorrelationID failed: %v", err)
+	}
+	if id := mockserver.LastCorrelationID(); id != "correlation-id" {
+		t.Fatalf("expected correlation ID %q, got %q", "correlation-id", id)
+	}
+}
+
 // CNI DEL to delete container from network
 func TestDeleteContainerNetworking(t *testing.T) {
 	client := &GrpcClient{}
@@ -3,7 +3,6 @@
 package npm
 
 import (
-	"encoding/json"
 	"time"
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
@@ -15,7 +14,7 @@ import (
 )
 
 // CacheEncoder is used only for unit tests to test encoding and decoding Cache.
-func CacheEncoder(nodeName string) json.Marshaler {
+func CacheEncoder(nodeName string) *NetworkPolicyManager {
 	noResyncPeriodFunc := func() time.Duration { return 0 }
 	kubeclient := k8sfake.NewSimpleClientset()
 	kubeInformer := kubeinformers.NewSharedInformerFactory(kubeclient, noResyncPeriodFunc())
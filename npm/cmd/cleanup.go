@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/common"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+const (
+	linuxCleanupMode   = "linux-cleanup"
+	windowsCleanupMode = "windows-cleanup"
+)
+
+var (
+	errUnknownMode         = errors.New("unknown mode")
+	errWrongOSForMode      = errors.New("cleanup mode doesn't match the OS of the node")
+	errCleanupNotConfirmed = fmt.Errorf("refusing to clean up without --%s. use --%s to list what would be removed", flagConfirmCleanup, flagDryRun)
+	errCleanupFailed       = errors.New("failed to clean up all NPM artifacts")
+)
+
+// validateCleanupFlags checks that the mode is the cleanup mode of this OS,
+// and that the cleanup is either a dry run or confirmed.
+func validateCleanupFlags(flags npmconfig.Flags, isWindows bool) error {
+	switch flags.Mode {
+	case linuxCleanupMode:
+		if isWindows {
+			return fmt.Errorf("%w: %s on Windows", errWrongOSForMode, flags.Mode)
+		}
+	case windowsCleanupMode:
+		if !isWindows {
+			return fmt.Errorf("%w: %s on Linux", errWrongOSForMode, flags.Mode)
+		}
+	default:
+		return fmt.Errorf("%w: %s. expected %s or %s", errUnknownMode, flags.Mode, linuxCleanupMode, windowsCleanupMode)
+	}
+
+	if !flags.DryRun && !flags.ConfirmCleanup {
+		return errCleanupNotConfirmed
+	}
+	return nil
+}
+
+// cleanup removes all NPM ipsets, iptables chains and HNS policies from the node, then returns instead of running NPM.
+// It's meant for uninstall DaemonSets and migrations to other policy engines. NPM must not be running on the node.
+func cleanup(config npmconfig.Config, flags npmconfig.Flags) error {
+	if err := validateCleanupFlags(flags, util.IsWindowsDP()); err != nil {
+		return err
+	}
+	if err := initLogging(); err != nil {
+		return err
+	}
+	klog.Infof("running in %s mode. dry run: %t", flags.Mode, flags.DryRun)
+
+	networkName := config.WindowsNetworkName
+	if networkName == "" {
+		networkName = util.AzureNetworkName
+	}
+	enableIPv6 := config.Toggles.EnableIPv6 && !util.IsWindowsDP()
+	cfg := &dataplane.Config{
+		IPSetManagerCfg: &ipsets.IPSetManagerCfg{
+			NetworkName: networkName,
+			EnableIPv6:  enableIPv6,
+		},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{
+			PolicyMode: policies.IPSetPolicyMode,
+			EnableIPv6: enableIPv6,
+		},
+	}
+
+	artifacts, err := dataplane.Cleanup(common.NewIOShim(), cfg, flags.DryRun)
+	verb := "removed"
+	if flags.DryRun {
+		verb = "would remove"
+	}
+	for _, artifact := range artifacts {
+		fmt.Printf("%s %s\n", verb, artifact)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errCleanupFailed, err)
+	}
+	klog.Infof("finished %s mode. %s %d NPM artifacts", flags.Mode, verb, len(artifacts))
+	return nil
+}
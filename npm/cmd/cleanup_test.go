@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCleanupFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		flags     npmconfig.Flags
+		isWindows bool
+		wantErr   error
+	}{
+		{
+			name:  "linux cleanup confirmed",
+			flags: npmconfig.Flags{Mode: linuxCleanupMode, ConfirmCleanup: true},
+		},
+		{
+			name:      "windows dry run",
+			flags:     npmconfig.Flags{Mode: windowsCleanupMode, DryRun: true},
+			isWindows: true,
+		},
+		{
+			name:    "not confirmed",
+			flags:   npmconfig.Flags{Mode: linuxCleanupMode},
+			wantErr: errCleanupNotConfirmed,
+		},
+		{
+			name:    "windows mode on linux",
+			flags:   npmconfig.Flags{Mode: windowsCleanupMode, ConfirmCleanup: true},
+			wantErr: errWrongOSForMode,
+		},
+		{
+			name:      "linux mode on windows",
+			flags:     npmconfig.Flags{Mode: linuxCleanupMode, DryRun: true},
+			isWindows: true,
+			wantErr:   errWrongOSForMode,
+		},
+		{
+			name:    "unknown mode",
+			flags:   npmconfig.Flags{Mode: "cleanup", ConfirmCleanup: true},
+			wantErr: errUnknownMode,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCleanupFlags(tt.flags, tt.isWindows)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/conformance"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var errConformanceFailed = errors.New("conformance scenarios failed")
+
+func newConformanceCmd() *cobra.Command {
+	cfg := conformance.DefaultConfig
+	var (
+		kubeConfigPath string
+		npmNamespace   string
+		npmSelector    string
+	)
+
+	conformanceCmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "Validates the network policies enforced by NPM against a live cluster",
+		Long: "Creates probe pods in namespaces of their own, runs a curated subset of the upstream network policy " +
+			"conformance scenarios against them, and reports whether each scenario passed. The iptables and ipsets " +
+			"of the nodes are dumped from the NPM pods when a scenario fails. The namespaces are deleted when done.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// an empty path loads the in cluster config
+			k8sConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig [%s] with err config: %w", kubeConfigPath, err)
+			}
+			clientset, err := kubernetes.NewForConfig(k8sConfig)
+			if err != nil {
+				return fmt.Errorf("failed to generate clientset with cluster config: %w", err)
+			}
+
+			prober := conformance.NewExecProber(k8sConfig, clientset, npmNamespace, npmSelector)
+			report, err := conformance.Run(context.Background(), cfg, clientset, prober)
+			if err != nil {
+				return fmt.Errorf("conformance run failed: %w", err)
+			}
+			fmt.Print(report)
+			if failed := report.Failed(); failed > 0 {
+				return fmt.Errorf("%w: %d of %d", errConformanceFailed, failed, len(report.Results))
+			}
+			return nil
+		},
+	}
+
+	flags := conformanceCmd.Flags()
+	flags.StringVar(&kubeConfigPath, flagKubeConfigPath, flagDefaults[flagKubeConfigPath], "path to kubeconfig")
+	flags.StringVar(&npmNamespace, "npm-namespace", "kube-system", "namespace of the NPM pods")
+	flags.StringVar(&npmSelector, "npm-selector", "k8s-app=azure-npm", "label selector of the NPM pods")
+	flags.StringSliceVar(&cfg.Scenarios, "scenarios", nil, "scenarios to run, all if empty")
+	flags.StringVar(&cfg.NamespacePrefix, "namespace-prefix", cfg.NamespacePrefix, "prefix of the namespaces of the probe pods")
+	flags.StringVar(&cfg.Image, "image", cfg.Image, "agnhost image of the probe pods")
+	flags.DurationVar(&cfg.ReadyTimeout, "ready-timeout", cfg.ReadyTimeout, "how long the probe pods have to be running")
+	flags.DurationVar(&cfg.ScenarioTimeout, "scenario-timeout", cfg.ScenarioTimeout, "how long the dataplane has to converge to each scenario")
+	flags.StringVar(&cfg.DumpDir, "dump-dir", cfg.DumpDir, "directory of the dataplane dumps of the failed scenarios, none if empty")
+	flags.BoolVar(&cfg.KeepNamespaces, "keep-namespaces", cfg.KeepNamespaces, "keep the namespaces of the probe pods")
+
+	return conformanceCmd
+}
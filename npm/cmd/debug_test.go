@@ -27,6 +27,7 @@ const (
 	debugCmdString          = "debug"
 	convertIPTableCmdString = "convertiptable"
 	getTuplesCmdString      = "gettuples"
+	traceCmdString          = "trace"
 	parseIPTableCmdString   = "parseiptable"
 )
 
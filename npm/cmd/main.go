@@ -14,10 +14,14 @@ import (
 const (
 	flagVersion        = "version"
 	flagKubeConfigPath = "kubeconfig"
+	flagMode           = "mode"
+	flagDryRun         = "dry-run"
+	flagConfirmCleanup = "confirm-cleanup"
 )
 
 var flagDefaults = map[string]string{
 	flagKubeConfigPath: "",
+	flagMode:           "",
 }
 
 // Version is populated by make during build.
@@ -49,6 +49,8 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(startCmd)
 
 	rootCmd.AddCommand(newDebugCmd())
+	rootCmd.AddCommand(newScaleTestCmd())
+	rootCmd.AddCommand(newConformanceCmd())
 
 	return rootCmd
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/pkg/scale"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	fakeDataplane = "fake"
+	realDataplane = "real"
+)
+
+var errUnknownDataplane = errors.New("unknown dataplane")
+
+func newScaleTestCmd() *cobra.Command {
+	cfg := scale.DefaultConfig
+	var (
+		dataplaneKind string
+		applyLatency  time.Duration
+	)
+
+	scaleTestCmd := &cobra.Command{
+		Use:   "scale-test",
+		Short: "Measures the convergence latency of NPM against synthetic namespaces, pods, policies and label churn",
+		Long: "Runs the NPM controllers against a fake API server populated with synthetic objects, churns pod labels, " +
+			"and reports the percentiles of the time the dataplane takes to converge to each change. " +
+			"The real dataplane programs the ipsets and iptables of this node, so it must not run where NPM runs.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var dp dataplane.GenericDataplane
+			switch dataplaneKind {
+			case fakeDataplane:
+				dp = &scale.FakeDataplane{ApplyLatency: applyLatency}
+			case realDataplane:
+				realDP, err := newScaleTestDataplane(cfg.NodeName, ctx.Done())
+				if err != nil {
+					return err
+				}
+				dp = realDP
+			default:
+				return fmt.Errorf("%w: %s. expected %s or %s", errUnknownDataplane, dataplaneKind, fakeDataplane, realDataplane)
+			}
+
+			report, err := scale.Run(ctx, cfg, dp)
+			if err != nil {
+				return fmt.Errorf("scale test failed: %w", err)
+			}
+			fmt.Print(report)
+			return nil
+		},
+	}
+
+	flags := scaleTestCmd.Flags()
+	flags.StringVar(&dataplaneKind, "dataplane", fakeDataplane, "dataplane to converge: fake or real")
+	flags.DurationVar(&applyLatency, "apply-latency", 0, "time each apply of the fake dataplane takes")
+	flags.IntVar(&cfg.Namespaces, "namespaces", cfg.Namespaces, "number of namespaces")
+	flags.IntVar(&cfg.PodsPerNamespace, "pods-per-namespace", cfg.PodsPerNamespace, "number of pods in each namespace")
+	flags.IntVar(&cfg.Policies, "policies", cfg.Policies, "number of network policies, spread over the namespaces")
+	flags.IntVar(&cfg.AppsPerNamespace, "apps-per-namespace", cfg.AppsPerNamespace, "number of pod groups selected by the policies in each namespace")
+	flags.IntVar(&cfg.LabelValues, "label-values", cfg.LabelValues, "number of values the churned pod label takes")
+	flags.IntVar(&cfg.LabelChurnPerSecond, "churn-rate", cfg.LabelChurnPerSecond, "pod label changes per second")
+	flags.DurationVar(&cfg.ChurnDuration, "churn-duration", cfg.ChurnDuration, "how long to churn pod labels")
+	flags.DurationVar(&cfg.ConvergenceTimeout, "timeout", cfg.ConvergenceTimeout, "how long the dataplane has to converge")
+	flags.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the churn")
+
+	return scaleTestCmd
+}
+
+// newScaleTestDataplane creates a dataplane with the default NPM config which applies synchronously,
+// so that changes are measured until they are programmed.
+func newScaleTestDataplane(nodeName string, stopCh <-chan struct{}) (*dataplane.DataPlane, error) {
+	cfg := &dataplane.Config{
+		ApplyMaxBatches: npmconfig.DefaultConfig.ApplyMaxBatches,
+		ApplyInterval:   time.Duration(npmconfig.DefaultConfig.ApplyIntervalInMilliseconds) * time.Millisecond,
+		IPSetManagerCfg: &ipsets.IPSetManagerCfg{
+			IPSetMode:   ipsets.ApplyAllIPSets,
+			NetworkName: util.AzureNetworkName,
+		},
+		PolicyManagerCfg: &policies.PolicyManagerCfg{
+			PolicyMode:           policies.IPSetPolicyMode,
+			PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		},
+	}
+
+	dp, err := dataplane.NewDataPlane(nodeName, common.NewIOShim(), cfg, stopCh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dataplane: %w", err)
+	}
+	return dp, nil
+}
@@ -5,6 +5,8 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-container-networking/common"
@@ -13,19 +15,28 @@ import (
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	restserver "github.com/Azure/azure-container-networking/npm/http/server"
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	k8sversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	"k8s.io/utils/exec"
 )
@@ -54,13 +65,23 @@ func newStartNPMCmd() *cobra.Command {
 
 			flags := npmconfig.Flags{
 				KubeConfigPath: viper.GetString(flagKubeConfigPath),
+				Mode:           viper.GetString(flagMode),
+				DryRun:         viper.GetBool(flagDryRun),
+				ConfirmCleanup: viper.GetBool(flagConfirmCleanup),
 			}
 
+			if flags.Mode != "" {
+				return cleanup(*config, flags)
+			}
 			return start(*config, flags)
 		},
 	}
 
 	startNPMCmd.Flags().String(flagKubeConfigPath, flagDefaults[flagKubeConfigPath], "path to kubeconfig")
+	startNPMCmd.Flags().String(flagMode, flagDefaults[flagMode],
+		fmt.Sprintf("run in %s or %s mode to remove all NPM ipsets, chains and HNS policies from the node and exit", linuxCleanupMode, windowsCleanupMode))
+	startNPMCmd.Flags().Bool(flagDryRun, false, "in a cleanup mode, only list what would be removed")
+	startNPMCmd.Flags().Bool(flagConfirmCleanup, false, "confirm removing NPM artifacts in a cleanup mode")
 
 	return startNPMCmd
 }
@@ -84,6 +105,8 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 
 	klog.Infof("initializing metrics")
 	metrics.InitializeAll()
+	// the work queues of the controllers report their metrics labeled with their names, e.g. "Pods"
+	npmcommon.SetMetricsProvider(metrics.WorkqueueMetricsProvider())
 
 	// Create the kubernetes client
 	var k8sConfig *rest.Config
@@ -119,13 +142,31 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 
 	k8sServerVersion := k8sServerVersion(clientset)
 
+	hostNetworkPods := config.HostNetworkPodsMode()
+	if config.HostNetworkPods != "" && !strings.EqualFold(string(config.HostNetworkPods), string(hostNetworkPods)) {
+		klog.Warningf("unknown HostNetworkPods %s. Using %s instead", config.HostNetworkPods, hostNetworkPods)
+	}
+	if hostNetworkPods == npmconfig.HostNetworkPodsEnforceOnNodeIP && util.IsWindowsDP() {
+		klog.Warningf("HostNetworkPods %s is not supported on Windows. Using %s instead", hostNetworkPods, npmconfig.HostNetworkPodsWarn)
+		hostNetworkPods = npmconfig.HostNetworkPodsWarn
+	}
+	klog.Infof("HostNetworkPods is %s", hostNetworkPods)
+	if len(config.ExcludedNamespaces) > 0 {
+		klog.Infof("ignoring the pods and network policies of namespaces %v", config.ExcludedNamespaces)
+	}
+
 	var dp dataplane.GenericDataplane
 	stopChannel := wait.NeverStop
 	if config.Toggles.EnableV2NPM {
 		// update the dataplane config
 		npmV2DataplaneCfg.MaxBatchedACLsPerPod = config.MaxBatchedACLsPerPod
+		npmV2DataplaneCfg.IPSetManagerCfg.MaxLinesPerRestore = config.MaxLinesPerIPSetRestore
+		npmV2DataplaneCfg.IPSetManagerCfg.MaxConsecutiveApplyFailures = config.MaxConsecutiveApplyFailures
+		npmV2DataplaneCfg.PolicyManagerCfg.MaxConsecutiveApplyFailures = config.MaxConsecutiveApplyFailures
 
 		npmV2DataplaneCfg.ApplyInBackground = config.Toggles.ApplyInBackground
+		// buffer events until the controllers have processed their initial informer caches (see npMgr.Start)
+		npmV2DataplaneCfg.BufferEventsOnBootup = true
 		if config.ApplyMaxBatches > 0 {
 			npmV2DataplaneCfg.ApplyMaxBatches = config.ApplyMaxBatches
 		} else {
@@ -136,6 +177,11 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		} else {
 			npmV2DataplaneCfg.ApplyInterval = time.Duration(npmconfig.DefaultConfig.ApplyIntervalInMilliseconds * int(time.Millisecond))
 		}
+		if config.ChainIntegrityCheckIntervalInSeconds > 0 {
+			npmV2DataplaneCfg.ChainIntegrityCheckInterval = time.Duration(config.ChainIntegrityCheckIntervalInSeconds) * time.Second
+		} else {
+			npmV2DataplaneCfg.ChainIntegrityCheckInterval = time.Duration(npmconfig.DefaultConfig.ChainIntegrityCheckIntervalInSeconds) * time.Second
+		}
 
 		if config.WindowsNetworkName == "" {
 			npmV2DataplaneCfg.NetworkName = util.AzureNetworkName
@@ -144,6 +190,12 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		}
 
 		npmV2DataplaneCfg.PlaceAzureChainFirst = config.Toggles.PlaceAzureChainFirst
+		npmV2DataplaneCfg.EnforceOnHostNetwork = hostNetworkPods == npmconfig.HostNetworkPodsEnforceOnNodeIP
+		enableIPv6 := config.Toggles.EnableIPv6 && !util.IsWindowsDP()
+		npmV2DataplaneCfg.IPSetManagerCfg.EnableIPv6 = enableIPv6
+		npmV2DataplaneCfg.PolicyManagerCfg.EnableIPv6 = enableIPv6
+		npmV2DataplaneCfg.PolicyManagerCfg.MirrorIptablesBackends = config.Toggles.MirrorIptablesBackends && !util.IsWindowsDP()
+		npmV2DataplaneCfg.FlushConntrack = config.Toggles.FlushConntrack
 		if config.Toggles.ApplyIPSetsOnNeed {
 			npmV2DataplaneCfg.IPSetMode = ipsets.ApplyOnNeed
 		} else {
@@ -160,6 +212,12 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 			klog.Infof("node IP is %s", nodeIP)
 		}
 		npmV2DataplaneCfg.NodeIP = nodeIP
+		npmV2DataplaneCfg.MigrationMarkerPath = dataplane.DefaultMigrationMarkerPath
+		npmV2DataplaneCfg.Budget = dataplane.Budget{
+			MaxIPSets:  config.Budget.MaxIPSets,
+			MaxMembers: config.Budget.MaxIPSetMembers,
+			MaxRules:   config.Budget.MaxRules,
+		}
 
 		dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
 		if err != nil {
@@ -169,6 +227,34 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		dp.RunPeriodicTasks()
 	}
 	npMgr := npm.NewNetworkPolicyManager(config, factory, dp, exec.New(), version, k8sServerVersion)
+	if config.Toggles.EnableV2NPM && config.Toggles.EnablePolicyStatus {
+		npMgr.NetPolControllerV2.SetStatusWriter(controllersv2.NewAnnotationStatusWriter(clientset))
+	}
+	if config.Toggles.EnableV2NPM && config.Toggles.EnableAddressGroups {
+		dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+		if err != nil {
+			return fmt.Errorf("failed to generate dynamic client with cluster config: %w", err)
+		}
+		npMgr.EnableAddressGroups(dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, resyncPeriod))
+	}
+	if config.Toggles.EnableV2NPM {
+		npMgr.PodControllerV2.SetIPv6Enabled(npmV2DataplaneCfg.IPSetManagerCfg.EnableIPv6)
+		npMgr.PodControllerV2.SetEnforceOnHostNetwork(npmV2DataplaneCfg.EnforceOnHostNetwork)
+		npMgr.PodControllerV2.SetExcludedNamespaces(config.ExcludedNamespaces)
+		npMgr.NamespaceControllerV2.SetExcludedNamespaces(config.ExcludedNamespaces)
+		npMgr.NetPolControllerV2.SetExcludedNamespaces(config.ExcludedNamespaces)
+		npMgr.NetPolControllerV2.SetHostNetworkPods(hostNetworkPods, npMgr.PodInformer.Lister(), npMgr.NsInformer.Lister())
+		if config.Toggles.EnableAuditMode {
+			npMgr.NetPolControllerV2.EnableAuditMode(npMgr.NsInformer)
+		}
+		if npmV2DataplaneCfg.Budget != (dataplane.Budget{}) {
+			// report the policies exceeding the budget to their authors, who can't see the NPM logs
+			broadcaster := record.NewBroadcaster()
+			broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+			npMgr.NetPolControllerV2.SetEventRecorder(
+				broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "azure-npm", Host: models.GetNodeName()}))
+		}
+	}
 	err = metrics.CreateTelemetryHandle(config.NPMVersion(), version, npm.GetAIMetadata())
 	if err != nil {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
@@ -182,9 +268,31 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 		return fmt.Errorf("failed to start with err: %w", err)
 	}
 
+	watchApplierConfig(npMgr)
+
 	select {}
 }
 
+// watchApplierConfig updates the worker counts and rate limits of the controllers when the config file changes.
+// Other config changes still require a restart.
+func watchApplierConfig(npMgr *npm.NetworkPolicyManager) {
+	cfgFile := viper.ConfigFileUsed()
+	if _, err := os.Stat(cfgFile); err != nil {
+		klog.Infof("not watching config file %s for applier changes: %v", cfgFile, err)
+		return
+	}
+
+	viper.OnConfigChange(func(_ fsnotify.Event) {
+		config := &npmconfig.Config{}
+		if err := viper.Unmarshal(config); err != nil {
+			klog.Errorf("failed to reload config with error: %v", err)
+			return
+		}
+		npMgr.UpdateAppliers(config.Appliers)
+	})
+	viper.WatchConfig()
+}
+
 func initLogging() error {
 	log.SetName("azure-npm")
 	log.SetLevel(log.LevelInfo)
@@ -11,6 +11,7 @@ import (
 	"github.com/Azure/azure-container-networking/npm/controller"
 	restserver "github.com/Azure/azure-container-networking/npm/http/server"
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/dpshim"
 	"github.com/Azure/azure-container-networking/npm/pkg/transport"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -62,6 +63,8 @@ func startControlplane(config npmconfig.Config, flags npmconfig.Flags) error {
 
 	klog.Infof("initializing metrics")
 	metrics.InitializeAll()
+	// the work queues of the controllers report their metrics labeled with their names, e.g. "Pods"
+	npmcommon.SetMetricsProvider(metrics.WorkqueueMetricsProvider())
 
 	// Create the kubernetes client
 	var k8sConfig *rest.Config
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/debug"
+	"github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultTraceDuration = 10 * time.Second
+
+func newTraceCmd() *cobra.Command {
+	traceCmd := &cobra.Command{
+		Use:   "trace",
+		Short: "Trace the packets between specified source and destination and print which NPM chain or ACL decided them",
+		Long: "Trace the packets between specified source and destination while traffic is sent, with iptables TRACE rules " +
+			"on Linux or a pktmon capture on Windows, and print which NPM chain accepted or dropped them. " +
+			"The tracing is removed when the trace ends or is interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, _ := cmd.Flags().GetString("src")
+			if src == "" {
+				return fmt.Errorf("%w", errors.ErrSrcNotSpecified)
+			}
+			dst, _ := cmd.Flags().GetString("dst")
+			if dst == "" {
+				return fmt.Errorf("%w", errors.ErrDstNotSpecified)
+			}
+			duration, _ := cmd.Flags().GetDuration("duration")
+
+			config := &npmconfig.Config{}
+			err := viper.Unmarshal(config)
+			if err != nil {
+				return fmt.Errorf("failed to load config with err %w", err)
+			}
+
+			c := &debug.Converter{
+				NPMDebugEndpointHost: "http://localhost",
+				NPMDebugEndpointPort: api.DefaultHttpPort,
+				EnableV2NPM:          config.Toggles.EnableV2NPM,
+			}
+			srcIP, err := c.FlowTraceIP(&npmcommon.Input{Content: src, Type: npmcommon.GetInputType(src)})
+			if err != nil {
+				return fmt.Errorf("failed to resolve the source: %w", err)
+			}
+			dstIP, err := c.FlowTraceIP(&npmcommon.Input{Content: dst, Type: npmcommon.GetInputType(dst)})
+			if err != nil {
+				return fmt.Errorf("failed to resolve the destination: %w", err)
+			}
+
+			// an interrupt ends the trace early, the tracing is still cleaned up
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Tracing %s -> %s for %s, send traffic now...\n", srcIP, dstIP, duration)
+			packets, err := debug.TraceFlow(ctx, common.NewIOShim(), srcIP, dstIP, duration)
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+
+			debug.PrettyPrintFlowTrace(cmd.OutOrStdout(), packets)
+			return nil
+		},
+	}
+
+	traceCmd.Flags().StringP("src", "s", "", "set the source, a pod as namespace/name or an IP")
+	traceCmd.Flags().StringP("dst", "d", "", "set the destination, a pod as namespace/name or an IP")
+	traceCmd.Flags().Duration("duration", defaultTraceDuration, "how long to trace the flow")
+
+	return traceCmd
+}
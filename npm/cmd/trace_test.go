@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+// (TODO) test case where the flow is traced
+func TestTraceCmd(t *testing.T) {
+	baseArgs := []string{debugCmdString, traceCmdString}
+
+	tests := []*testCases{
+		{
+			name:    "no src or dst",
+			args:    baseArgs,
+			wantErr: true,
+		},
+		{
+			name:    "no src",
+			args:    concatArgs(baseArgs, dstFlag, testIP2),
+			wantErr: true,
+		},
+		{
+			name:    "no dst",
+			args:    concatArgs(baseArgs, srcFlag, testIP1),
+			wantErr: true,
+		},
+		{
+			name:    "external src",
+			args:    concatArgs(baseArgs, srcFlag, "External", dstFlag, testIP2),
+			wantErr: true,
+		},
+		{
+			name:    "bad duration",
+			args:    concatArgs(baseArgs, srcFlag, testIP1, dstFlag, testIP2, "--duration", "ten"),
+			wantErr: true,
+		},
+	}
+
+	testCommand(t, tests)
+}
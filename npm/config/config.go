@@ -1,6 +1,10 @@
 package npmconfig
 
-import "github.com/Azure/azure-container-networking/npm/util"
+import (
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+)
 
 const (
 	defaultResyncPeriod         = 15
@@ -10,6 +14,8 @@ const (
 	defaultListeningPort        = 10091
 	defaultGrpcPort             = 10092
 	defaultGrpcServicePort      = 9002
+	defaultApplierWorkers       = 1
+	defaultChainIntegrityCheck  = 60
 	// ConfigEnvPath is what's used by viper to load config path
 	ConfigEnvPath = "NPM_CONFIG"
 
@@ -17,6 +23,20 @@ const (
 	v2 = 2
 )
 
+// HostNetworkPodsMode is how NPM v2 handles network policies selecting host-network pods, whose IP is their node's IP.
+type HostNetworkPodsMode string
+
+const (
+	// HostNetworkPodsIgnore leaves host-network pods out of IPSets, so policies don't apply to them.
+	HostNetworkPodsIgnore HostNetworkPodsMode = "Ignore"
+	// HostNetworkPodsWarn is like Ignore, but logs the policies whose selectors match host-network pods.
+	HostNetworkPodsWarn HostNetworkPodsMode = "Warn"
+	// HostNetworkPodsEnforceOnNodeIP adds the node IP of host-network pods to their IPSets, and enforces policies on
+	// traffic to and from the node IP too. Every host-network pod of a node shares its IP, so a policy selecting one of
+	// them applies to all of them. Linux only: Windows falls back to Warn.
+	HostNetworkPodsEnforceOnNodeIP HostNetworkPodsMode = "EnforceOnNodeIP"
+)
+
 // DefaultConfig is the guaranteed configuration NPM can run in out of the box
 var DefaultConfig = Config{
 	ResyncPeriodInMinutes: defaultResyncPeriod,
@@ -35,6 +55,15 @@ var DefaultConfig = Config{
 	ApplyIntervalInMilliseconds: defaultApplyInterval,
 	MaxBatchedACLsPerPod:        defaultMaxBatchedACLsPerPod,
 
+	ChainIntegrityCheckIntervalInSeconds: defaultChainIntegrityCheck,
+
+	HostNetworkPods: HostNetworkPodsIgnore,
+
+	Appliers: AppliersConfig{
+		IPSets:   ApplierConfig{Workers: defaultApplierWorkers},
+		Policies: ApplierConfig{Workers: defaultApplierWorkers},
+	},
+
 	Toggles: Toggles{
 		EnablePrometheusMetrics: true,
 		EnablePprof:             true,
@@ -43,6 +72,12 @@ var DefaultConfig = Config{
 		PlaceAzureChainFirst:    util.PlaceAzureChainFirst,
 		ApplyIPSetsOnNeed:       false,
 		ApplyInBackground:       true,
+		EnablePolicyStatus:      false,
+		EnableIPv6:              false,
+		EnableAddressGroups:     false,
+		EnableAuditMode:         false,
+		MirrorIptablesBackends:  false,
+		FlushConntrack:          false,
 	},
 }
 
@@ -69,8 +104,55 @@ type Config struct {
 	// MaxBatchedACLsPerPod is the maximum number of ACLs that can be added to a Pod at once in Windows.
 	// The zero value is valid.
 	// A NetworkPolicy's ACLs are always in the same batch, and there will be at least one NetworkPolicy per batch.
-	MaxBatchedACLsPerPod int     `json:"MaxBatchedACLsPerPod,omitempty"`
-	Toggles              Toggles `json:"Toggles,omitempty"`
+	MaxBatchedACLsPerPod int `json:"MaxBatchedACLsPerPod,omitempty"`
+	// MaxLinesPerIPSetRestore is the maximum number of lines in each ipset restore file in Linux.
+	// Changes to many sets are restored in chunks, so a failure only retries its own chunk.
+	// The zero value means the dataplane default.
+	MaxLinesPerIPSetRestore int `json:"MaxLinesPerIPSetRestore,omitempty"`
+	// MaxConsecutiveApplyFailures is how many times in a row v2 can fail to apply an IPSet or policy before rebuilding just that object
+	// instead of applying its changes. Objects are only rebuilt in Linux. The zero value means the dataplane default.
+	MaxConsecutiveApplyFailures int `json:"MaxConsecutiveApplyFailures,omitempty"`
+	// ChainIntegrityCheckIntervalInSeconds is how often v2 Linux checks that the jump to AZURE-NPM chain is still in the FORWARD chain
+	// and in the right position, repairing it otherwise. Values less than 1 mean the default.
+	ChainIntegrityCheckIntervalInSeconds int `json:"ChainIntegrityCheckIntervalInSeconds,omitempty"`
+	// HostNetworkPods is Ignore, Warn or EnforceOnNodeIP (v2 only). The empty string means Ignore.
+	HostNetworkPods HostNetworkPodsMode `json:"HostNetworkPods,omitempty"`
+	// ExcludedNamespaces are the namespaces NPM ignores entirely (v2 only): their pods aren't added to IPSets and their
+	// network policies aren't applied, so that their churn costs nothing and policies can't lock out system components.
+	// The policies of other namespaces can't select their pods as peers either. None are excluded by default.
+	ExcludedNamespaces []string `json:"ExcludedNamespaces,omitempty"`
+	// Appliers applies to v2 only, and can be changed at runtime by updating the config file.
+	Appliers AppliersConfig `json:"Appliers,omitempty"`
+	// Budget caps what v2 programs on each node. Policies which would exceed it are pending until usage drops.
+	Budget  BudgetConfig `json:"Budget,omitempty"`
+	Toggles Toggles      `json:"Toggles,omitempty"`
+}
+
+// BudgetConfig caps the IPSets, IPSet members, and rules (iptables rules in Linux, ACLs in Windows) of a node,
+// so that too many policies don't push the kernel or HNS into failure modes. 0 means no limit.
+type BudgetConfig struct {
+	MaxIPSets       int `json:"MaxIPSets,omitempty"`
+	MaxIPSetMembers int `json:"MaxIPSetMembers,omitempty"`
+	MaxRules        int `json:"MaxRules,omitempty"`
+}
+
+// AppliersConfig configures the controllers applying IPSet and policy updates independently,
+// so that slow policy updates don't delay IPSet membership updates (the common case for pod churn).
+type AppliersConfig struct {
+	// IPSets configures the pod and namespace controllers, which only update IPSets.
+	IPSets ApplierConfig `json:"IPSets,omitempty"`
+	// Policies configures the network policy controller.
+	Policies ApplierConfig `json:"Policies,omitempty"`
+}
+
+// ApplierConfig configures the workers of a controller.
+type ApplierConfig struct {
+	// Workers is the number of work items processed concurrently. Values less than 1 mean 1.
+	Workers int `json:"Workers,omitempty"`
+	// MaxQPS is the maximum rate of work items processed per second across all workers. 0 means no limit.
+	MaxQPS float32 `json:"MaxQPS,omitempty"`
+	// Burst is the number of work items which can be processed above MaxQPS in a burst. Values less than 1 mean 1.
+	Burst int `json:"Burst,omitempty"`
 }
 
 type Toggles struct {
@@ -82,10 +164,43 @@ type Toggles struct {
 	ApplyIPSetsOnNeed       bool
 	// ApplyInBackground applies for Windows only
 	ApplyInBackground bool
+	// EnablePolicyStatus reports whether each network policy was accepted and programmed in an annotation on the policy (v2 only)
+	EnablePolicyStatus bool
+	// EnableIPv6 enforces policies for IPv6 pod addresses in dual-stack clusters with ip6tables and inet6 ipsets (v2 Linux only)
+	EnableIPv6 bool
+	// EnableAddressGroups watches AddressGroup CRDs, whose CIDRs network policies can reference with the
+	// npm.azure.com/address-groups annotation (v2 only). The CRD must be installed.
+	EnableAddressGroups bool
+	// EnableAuditMode logs instead of dropping the traffic denied by the policies of the namespaces annotated with
+	// npm.azure.com/policy-mode=audit (v2 Linux only)
+	EnableAuditMode bool
+	// MirrorIptablesBackends programs the rules in both the nft and legacy iptables backends instead of only the detected one,
+	// e.g. while nodes migrate from one backend to the other (v2 Linux only)
+	MirrorIptablesBackends bool
+	// FlushConntrack deletes the conntrack entries of the connections of the local pods selected by a network policy which
+	// the policy denies, once it's added or its rules change, so that they don't bypass it (v2 only, the VFP flows of the
+	// pods on Windows)
+	FlushConntrack bool
 }
 
 type Flags struct {
 	KubeConfigPath string `json:"KubeConfigPath"`
+	// Mode is empty to run NPM, or a cleanup mode (linux-cleanup or windows-cleanup) to remove all NPM artifacts and exit.
+	Mode string `json:"Mode"`
+	// DryRun only lists the artifacts a cleanup mode would remove.
+	DryRun bool `json:"DryRun"`
+	// ConfirmCleanup must be set for a cleanup mode to remove anything.
+	ConfirmCleanup bool `json:"ConfirmCleanup"`
+}
+
+// HostNetworkPodsMode returns the configured HostNetworkPods mode, or Ignore if it's empty or unknown.
+func (c Config) HostNetworkPodsMode() HostNetworkPodsMode {
+	for _, mode := range []HostNetworkPodsMode{HostNetworkPodsIgnore, HostNetworkPodsWarn, HostNetworkPodsEnforceOnNodeIP} {
+		if strings.EqualFold(string(c.HostNetworkPods), string(mode)) {
+			return mode
+		}
+	}
+	return HostNetworkPodsIgnore
 }
 
 // NPMVersion returns 1 if EnableV2NPM=false and 2 otherwise
@@ -92,6 +92,7 @@ func NewNetworkPolicyServer(
 	n.PodControllerV2 = controllersv2.NewPodController(n.PodInformer, dp, n.NpmNamespaceCacheV2)
 	n.NamespaceControllerV2 = controllersv2.NewNamespaceController(n.NsInformer, dp, n.NpmNamespaceCacheV2)
 	n.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(n.NpInformer, dp)
+	n.PodControllerV2.YieldToPolicyDeletes(n.NetPolControllerV2)
 
 	return n, nil
 }
@@ -130,6 +131,28 @@ func (n *NetworkPolicyServer) MarshalJSON() ([]byte, error) {
 	return npmCacheRaw, nil
 }
 
+// GetNodeName returns the node of the cache served by the debug API.
+func (n *NetworkPolicyServer) GetNodeName() string {
+	return n.NodeName
+}
+
+// CacheKeys returns the keys of the maps of the cache served by pages by the debug API.
+func (n *NetworkPolicyServer) CacheKeys() []models.CacheKey {
+	return []models.CacheKey{models.NsMap, models.PodMap}
+}
+
+// CachePage returns up to limit entries of a map of the cache, from the start key in key order.
+func (n *NetworkPolicyServer) CachePage(key models.CacheKey, start string, limit int) (*common.CachePage, error) {
+	switch key {
+	case models.NsMap:
+		return n.NpmNamespaceCacheV2.NsMapPage(start, limit) //nolint:wrapcheck // already wrapped
+	case models.PodMap:
+		return n.PodControllerV2.PodMapPage(start, limit) //nolint:wrapcheck // already wrapped
+	default:
+		return nil, errors.Wrapf(models.ErrUnknownCacheKey, "%s in controller cache", key)
+	}
+}
+
 func (n *NetworkPolicyServer) GetAppVersion() string {
 	return n.Version
 }
@@ -6,6 +6,25 @@ const (
 	NodeMetricsPath    = "/node-metrics"
 	ClusterMetricsPath = "/cluster-metrics"
 	NPMMgrPath         = "/npm/v1/debug/manager"
+	// NPMCachePath serves a page of one of the maps of the NPM cache, selected by the query parameters below.
+	NPMCachePath = "/npm/v1/debug/cache"
+)
+
+// Query parameters of NPMCachePath.
+const (
+	// CacheKeyParam is the map of the cache, one of the models.CacheKey other than NodeName.
+	CacheKeyParam = "key"
+	// CacheStartParam is the first key of the page, the Continue of the previous page.
+	CacheStartParam = "start"
+	// CacheLimitParam is the max number of entries of the page.
+	CacheLimitParam = "limit"
+)
+
+const (
+	// DefaultCachePageLimit is the number of entries of a page of the cache when no limit is requested.
+	DefaultCachePageLimit = 500
+	// MaxCachePageLimit bounds the number of entries of a page of the cache.
+	MaxCachePageLimit = 5000
 )
 
 type DescribeIPSetRequest struct{}
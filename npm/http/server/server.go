@@ -1,16 +1,23 @@
 package server
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/pprof"
 	_ "net/http/pprof"
+	"sort"
+	"strconv"
 
 	"github.com/Azure/azure-container-networking/log"
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/http/api"
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/models"
+	"github.com/pkg/errors"
 	"k8s.io/klog"
 
 	"github.com/gorilla/mux"
@@ -21,7 +28,15 @@ type NPMRestServer struct {
 	router           *mux.Router
 }
 
-func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marshaler) {
+// NPMCache is the cache of NPM served by the debug API, one page of one of its maps at a time,
+// so that the cache is never encoded at once.
+type NPMCache interface {
+	GetNodeName() string
+	CacheKeys() []models.CacheKey
+	CachePage(key models.CacheKey, start string, limit int) (*common.CachePage, error)
+}
+
+func NPMRestServerListenAndServe(config npmconfig.Config, npmCache NPMCache) {
 	rs := NPMRestServer{}
 
 	rs.router = mux.NewRouter()
@@ -33,9 +48,10 @@ func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marsha
 	}
 
 	// the nil check is for fan-out npm
-	if config.Toggles.EnableHTTPDebugAPI && npmEncoder != nil {
+	if config.Toggles.EnableHTTPDebugAPI && npmCache != nil {
 		// ACN CLI debug handlers
-		rs.router.Handle(api.NPMMgrPath, rs.npmCacheHandler(npmEncoder)).Methods(http.MethodGet)
+		rs.router.Handle(api.NPMMgrPath, rs.npmCacheHandler(npmCache)).Methods(http.MethodGet)
+		rs.router.Handle(api.NPMCachePath, rs.npmCachePageHandler(npmCache)).Methods(http.MethodGet)
 	}
 
 	if config.Toggles.EnablePprof {
@@ -61,15 +77,94 @@ func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marsha
 	klog.Errorf("Failed to start NPM HTTP Server with error: %+v", srv.ListenAndServe())
 }
 
-func (n *NPMRestServer) npmCacheHandler(npmCacheEncoder json.Marshaler) http.Handler {
+// npmCacheHandler serves the whole cache in the format of the cache files of the debug CLI.
+// The cache is streamed page by page, so an error after the first page truncates the response.
+func (n *NPMRestServer) npmCacheHandler(npmCache NPMCache) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		b, err := json.Marshal(npmCacheEncoder)
+		w.Header().Set("Content-Type", "application/json")
+		if err := writeCache(w, npmCache); err != nil {
+			log.Errorf("failed to write cache: %v", err)
+		}
+	})
+}
+
+// writeCache writes the cache as a JSON object of its node name and maps, one page of a map at a time.
+func writeCache(w io.Writer, npmCache NPMCache) error {
+	bw := bufio.NewWriter(w)
+	nodeName, err := json.Marshal(npmCache.GetNodeName())
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal node name")
+	}
+	fmt.Fprintf(bw, "{%q:%s", models.NodeName, nodeName)
+
+	for _, key := range npmCache.CacheKeys() {
+		fmt.Fprintf(bw, ",%q:{", key)
+		first := true
+		for start := ""; ; {
+			page, err := npmCache.CachePage(key, start, api.DefaultCachePageLimit)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get page of %s at %q", key, start)
+			}
+			itemKeys := make([]string, 0, len(page.Items))
+			for k := range page.Items {
+				itemKeys = append(itemKeys, k)
+			}
+			sort.Strings(itemKeys)
+			for _, k := range itemKeys {
+				// keys are encoded as JSON, since %q escapes some characters differently
+				itemKey, err := json.Marshal(k)
+				if err != nil {
+					return errors.Wrapf(err, "failed to marshal key %s of %s", k, key)
+				}
+				if !first {
+					bw.WriteByte(',') //nolint:errcheck // errors are returned by Flush
+				}
+				first = false
+				fmt.Fprintf(bw, "%s:%s", itemKey, page.Items[k])
+			}
+			if page.Continue == "" {
+				break
+			}
+			start = page.Continue
+		}
+		bw.WriteByte('}') //nolint:errcheck // errors are returned by Flush
+	}
+	bw.WriteByte('}') //nolint:errcheck // errors are returned by Flush
+
+	return errors.Wrap(bw.Flush(), "failed to write cache")
+}
+
+// npmCachePageHandler serves a page of a map of the cache, selected by the query parameters of api.NPMCachePath.
+func (n *NPMRestServer) npmCachePageHandler(npmCache NPMCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := api.DefaultCachePageLimit
+		if l := query.Get(api.CacheLimitParam); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > api.MaxCachePageLimit {
+				http.Error(w, fmt.Sprintf("invalid %s %q, expected 1 to %d", api.CacheLimitParam, l, api.MaxCachePageLimit),
+					http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := npmCache.CachePage(models.CacheKey(query.Get(api.CacheKeyParam)), query.Get(api.CacheStartParam), limit)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			status := http.StatusInternalServerError
+			if errors.Is(err, models.ErrUnknownCacheKey) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
-		_, err = w.Write(b)
+
+		b, err := json.Marshal(page)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(b); err != nil {
 			log.Errorf("failed to write resp: %v", err)
 		}
 	})
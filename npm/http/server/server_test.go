@@ -10,7 +10,9 @@ import (
 	"github.com/Azure/azure-container-networking/npm"
 	"github.com/Azure/azure-container-networking/npm/http/api"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetNPMCacheHandler(t *testing.T) {
@@ -55,3 +57,69 @@ func TestGetNPMCacheHandler(t *testing.T) {
 
 	assert.Exactly(expected, actual)
 }
+
+// onePerPageCache serves its pods one per page, whatever the limit.
+type onePerPageCache struct {
+	pods map[string]*common.NpmPod
+}
+
+func (c *onePerPageCache) GetNodeName() string { return "nodename" }
+
+func (c *onePerPageCache) CacheKeys() []models.CacheKey { return []models.CacheKey{models.PodMap} }
+
+func (c *onePerPageCache) CachePage(key models.CacheKey, start string, _ int) (*common.CachePage, error) {
+	if key != models.PodMap {
+		return nil, models.ErrUnknownCacheKey
+	}
+	return common.NewCachePage(c.pods, start, 1)
+}
+
+func TestGetNPMCacheHandlerStreamsPages(t *testing.T) {
+	npmCache := &onePerPageCache{pods: map[string]*common.NpmPod{
+		"x/a": {Name: "a", Namespace: "x", PodIP: "10.0.0.1"},
+		"x/b": {Name: "b", Namespace: "x", PodIP: "10.0.0.2"},
+		"y/a": {Name: "a", Namespace: "y", PodIP: "10.0.0.3"},
+	}}
+	n := &NPMRestServer{}
+
+	rr := httptest.NewRecorder()
+	n.npmCacheHandler(npmCache).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, api.NPMMgrPath, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	actual := &common.Cache{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), actual), rr.Body.String())
+	require.Equal(t, "nodename", actual.NodeName)
+	require.Equal(t, npmCache.pods, actual.PodMap)
+}
+
+func TestGetNPMCachePageHandler(t *testing.T) {
+	n := &NPMRestServer{}
+	handler := n.npmCachePageHandler(npm.CacheEncoder("nodename"))
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{name: "default limit", query: "?key=NsMap", status: http.StatusOK},
+		{name: "limit", query: "?key=PodMap&start=x%2Fa&limit=10", status: http.StatusOK},
+		{name: "unknown key", query: "?key=NodeName", status: http.StatusBadRequest},
+		{name: "invalid limit", query: "?key=SetMap&limit=abc", status: http.StatusBadRequest},
+		{name: "limit above max", query: "?key=SetMap&limit=5001", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, api.NPMCachePath+tt.query, nil))
+			require.Equal(t, tt.status, rr.Code, rr.Body.String())
+			if tt.status != http.StatusOK {
+				return
+			}
+			page := &common.CachePage{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), page))
+			require.Empty(t, page.Items)
+		})
+	}
+}
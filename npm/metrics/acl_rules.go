@@ -1,5 +1,10 @@
 package metrics
 
+import (
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
 // IncNumACLRules increments the number of ACL rules.
 func IncNumACLRules() {
 	numACLRules.Inc()
@@ -31,6 +36,29 @@ func RecordACLRuleExecTime(timer *Timer) {
 	timer.stopAndRecord(addACLRuleExecTime)
 }
 
+// RecordIPTablesJumpRepair counts a repair of the jump from FORWARD chain to AZURE-NPM chain.
+// The reason is why the jump needed repair, e.g. it was missing or misplaced.
+func RecordIPTablesJumpRepair(ipFamily, reason string) {
+	iptablesJumpRepairs.With(getJumpRepairLabels(ipFamily, reason)).Inc()
+}
+
+// RecordIPTablesBackend records the iptables backend NPM detected and programs, and whether kube rules were found
+// in both backends.
+func RecordIPTablesBackend(backend string, mixed bool) {
+	for _, b := range []string{util.IptablesBackendNft, util.IptablesBackendLegacy} {
+		value := 0.0
+		if b == backend {
+			value = 1
+		}
+		iptablesBackend.With(prometheus.Labels{backendLabel: b}).Set(value)
+	}
+	if mixed {
+		iptablesMixedBackends.Set(1)
+	} else {
+		iptablesMixedBackends.Set(0)
+	}
+}
+
 // GetNumACLRules returns the number of ACL rules.
 // This function is slow.
 func GetNumACLRules() (int, error) {
@@ -42,3 +70,25 @@ func GetNumACLRules() (int, error) {
 func GetACLRuleExecCount() (int, error) {
 	return getCountValue(addACLRuleExecTime)
 }
+
+// GetIPTablesJumpRepairCount returns the number of repairs of the jump to AZURE-NPM chain for the IP family and reason.
+// This function is slow.
+func GetIPTablesJumpRepairCount(ipFamily, reason string) (int, error) {
+	return getCounterVecValue(iptablesJumpRepairs, getJumpRepairLabels(ipFamily, reason))
+}
+
+// GetIPTablesBackend returns 1 if NPM programs the iptables backend, 0 otherwise.
+// This function is slow.
+func GetIPTablesBackend(backend string) (int, error) {
+	return getVecValue(iptablesBackend, prometheus.Labels{backendLabel: backend})
+}
+
+// GetIPTablesMixedBackends returns 1 if kube rules were found in both iptables backends, 0 otherwise.
+// This function is slow.
+func GetIPTablesMixedBackends() (int, error) {
+	return getValue(iptablesMixedBackends)
+}
+
+func getJumpRepairLabels(ipFamily, reason string) prometheus.Labels {
+	return prometheus.Labels{ipFamilyLabel: ipFamily, repairReasonLabel: reason}
+}
@@ -1,6 +1,10 @@
 package metrics
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 var (
 	numRulesMetric       = &basicMetric{ResetNumACLRules, IncNumACLRules, DecNumACLRules, GetNumACLRules}
@@ -31,3 +35,42 @@ func TestIncNumACLRulesBy(t *testing.T) {
 func TestDecNumACLRulesBy(t *testing.T) {
 	numRulesAmountMetric.testDecByMetric(t)
 }
+
+func TestRecordIPTablesJumpRepair(t *testing.T) {
+	missing, err := GetIPTablesJumpRepairCount("ipv4", "missing")
+	require.NoError(t, err)
+	misplaced, err := GetIPTablesJumpRepairCount("ipv4", "misplaced")
+	require.NoError(t, err)
+
+	RecordIPTablesJumpRepair("ipv4", "missing")
+	RecordIPTablesJumpRepair("ipv4", "missing")
+	RecordIPTablesJumpRepair("ipv4", "misplaced")
+
+	newMissing, err := GetIPTablesJumpRepairCount("ipv4", "missing")
+	require.NoError(t, err)
+	newMisplaced, err := GetIPTablesJumpRepairCount("ipv4", "misplaced")
+	require.NoError(t, err)
+	require.Equal(t, missing+2, newMissing)
+	require.Equal(t, misplaced+1, newMisplaced)
+}
+
+func TestRecordIPTablesBackend(t *testing.T) {
+	RecordIPTablesBackend("nft", true)
+	nft, err := GetIPTablesBackend("nft")
+	require.NoError(t, err)
+	legacy, err := GetIPTablesBackend("legacy")
+	require.NoError(t, err)
+	mixed, err := GetIPTablesMixedBackends()
+	require.NoError(t, err)
+	require.Equal(t, 1, nft)
+	require.Equal(t, 0, legacy)
+	require.Equal(t, 1, mixed)
+
+	RecordIPTablesBackend("legacy", false)
+	legacy, err = GetIPTablesBackend("legacy")
+	require.NoError(t, err)
+	mixed, err = GetIPTablesMixedBackends()
+	require.NoError(t, err)
+	require.Equal(t, 1, legacy)
+	require.Equal(t, 0, mixed)
+}
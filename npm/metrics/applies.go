@@ -0,0 +1,39 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ApplyObject is the kind of dataplane object of an apply.
+type ApplyObject string
+
+const (
+	IPSetObject  ApplyObject = "ipset"
+	PolicyObject ApplyObject = "policy"
+)
+
+// ApplyType is whether an object was applied incrementally or rebuilt.
+type ApplyType string
+
+const (
+	// IncrementalApply only applies the changes to the object since its last apply.
+	IncrementalApply ApplyType = "incremental"
+	// RebuildApply reprograms the whole object, after its incremental applies failed too many times in a row.
+	RebuildApply ApplyType = "rebuild"
+)
+
+// RecordDataplaneApply counts an apply of an IPSet or policy which had or didn't have an error.
+func RecordDataplaneApply(object ApplyObject, applyType ApplyType, hadError bool) {
+	dataplaneApplies.With(getApplyLabels(object, applyType, hadError)).Inc()
+}
+
+// GetDataplaneApplyCount returns the number of applies of IPSets or policies of the apply type, which had or didn't have an error.
+// This function is slow.
+func GetDataplaneApplyCount(object ApplyObject, applyType ApplyType, hadError bool) (int, error) {
+	return getCounterVecValue(dataplaneApplies, getApplyLabels(object, applyType, hadError))
+}
+
+func getApplyLabels(object ApplyObject, applyType ApplyType, hadError bool) prometheus.Labels {
+	labels := getErrorLabels(hadError)
+	labels[objectLabel] = string(object)
+	labels[applyTypeLabel] = string(applyType)
+	return labels
+}
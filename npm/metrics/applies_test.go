@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataplaneApplyMetrics(t *testing.T) {
+	incremental, err := GetDataplaneApplyCount(IPSetObject, IncrementalApply, false)
+	require.NoError(t, err)
+	rebuildFailures, err := GetDataplaneApplyCount(PolicyObject, RebuildApply, true)
+	require.NoError(t, err)
+
+	RecordDataplaneApply(IPSetObject, IncrementalApply, false)
+	RecordDataplaneApply(IPSetObject, IncrementalApply, false)
+	RecordDataplaneApply(PolicyObject, RebuildApply, true)
+
+	newIncremental, err := GetDataplaneApplyCount(IPSetObject, IncrementalApply, false)
+	require.NoError(t, err)
+	require.Equal(t, incremental+2, newIncremental)
+	newRebuildFailures, err := GetDataplaneApplyCount(PolicyObject, RebuildApply, true)
+	require.NoError(t, err)
+	require.Equal(t, rebuildFailures+1, newRebuildFailures)
+	ipsetRebuilds, err := GetDataplaneApplyCount(IPSetObject, RebuildApply, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, ipsetRebuilds)
+}
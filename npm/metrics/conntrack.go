@@ -0,0 +1,27 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// RecordConntrackFlush counts a flush of the conntrack entries of a pod IP of the family, and the entries it deleted.
+func RecordConntrackFlush(ipFamily string, entries int, hadError bool) {
+	conntrackFlushes.With(getConntrackFlushLabels(ipFamily, hadError)).Inc()
+	conntrackFlushedEntries.With(prometheus.Labels{ipFamilyLabel: ipFamily}).Add(float64(entries))
+}
+
+// GetConntrackFlushCount returns the number of flushes of the conntrack entries of pod IPs of the family.
+// This function is slow.
+func GetConntrackFlushCount(ipFamily string, hadError bool) (int, error) {
+	return getCounterVecValue(conntrackFlushes, getConntrackFlushLabels(ipFamily, hadError))
+}
+
+// GetConntrackFlushedEntryCount returns the number of conntrack entries of the family flushed after applying policies.
+// This function is slow.
+func GetConntrackFlushedEntryCount(ipFamily string) (int, error) {
+	return getCounterVecValue(conntrackFlushedEntries, prometheus.Labels{ipFamilyLabel: ipFamily})
+}
+
+func getConntrackFlushLabels(ipFamily string, hadError bool) prometheus.Labels {
+	labels := getErrorLabels(hadError)
+	labels[ipFamilyLabel] = ipFamily
+	return labels
+}
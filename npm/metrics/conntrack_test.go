@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordConntrackFlush(t *testing.T) {
+	flushes, err := GetConntrackFlushCount("ipv4", false)
+	require.NoError(t, err)
+	failures, err := GetConntrackFlushCount("ipv4", true)
+	require.NoError(t, err)
+	entries, err := GetConntrackFlushedEntryCount("ipv4")
+	require.NoError(t, err)
+
+	RecordConntrackFlush("ipv4", 3, false)
+	RecordConntrackFlush("ipv4", 0, true)
+
+	newFlushes, err := GetConntrackFlushCount("ipv4", false)
+	require.NoError(t, err)
+	newFailures, err := GetConntrackFlushCount("ipv4", true)
+	require.NoError(t, err)
+	newEntries, err := GetConntrackFlushedEntryCount("ipv4")
+	require.NoError(t, err)
+	require.Equal(t, flushes+1, newFlushes)
+	require.Equal(t, failures+1, newFailures)
+	require.Equal(t, entries+3, newEntries)
+}
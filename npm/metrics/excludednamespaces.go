@@ -0,0 +1,24 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// ExcludedObject is the kind of object of an event skipped because it's in an excluded namespace.
+type ExcludedObject string
+
+const (
+	ExcludedPod           ExcludedObject = "pod"
+	ExcludedNamespace     ExcludedObject = "namespace"
+	ExcludedNetworkPolicy ExcludedObject = "networkpolicy"
+)
+
+// RecordExcludedNamespaceEvent counts an event of the object skipped because it's in an excluded namespace.
+func RecordExcludedNamespaceEvent(object ExcludedObject) {
+	excludedNamespaceEvents.With(prometheus.Labels{objectLabel: string(object)}).Inc()
+}
+
+// GetExcludedNamespaceEventCount returns the number of events of the object skipped because they're in an excluded
+// namespace.
+// This function is slow.
+func GetExcludedNamespaceEventCount(object ExcludedObject) (int, error) {
+	return getCounterVecValue(excludedNamespaceEvents, prometheus.Labels{objectLabel: string(object)})
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordExcludedNamespaceEvent(t *testing.T) {
+	before, err := GetExcludedNamespaceEventCount(ExcludedPod)
+	require.NoError(t, err)
+	policies, err := GetExcludedNamespaceEventCount(ExcludedNetworkPolicy)
+	require.NoError(t, err)
+
+	RecordExcludedNamespaceEvent(ExcludedPod)
+
+	after, err := GetExcludedNamespaceEventCount(ExcludedPod)
+	require.NoError(t, err)
+	require.Equal(t, before+1, after)
+	newPolicies, err := GetExcludedNamespaceEventCount(ExcludedNetworkPolicy)
+	require.NoError(t, err)
+	require.Equal(t, policies, newPolicies)
+}
@@ -41,6 +41,16 @@ func RecordIPSetExecTime(timer *Timer) {
 	timer.stopAndRecord(addIPSetExecTime)
 }
 
+// SetPendingIPSetRestoreChunks sets the number of ipset restore chunks left to run in the current apply.
+func SetPendingIPSetRestoreChunks(val int) {
+	ipsetRestorePendingChunks.Set(float64(val))
+}
+
+// RecordIPSetRestoreChunk counts an ipset restore chunk which ran with or without an error.
+func RecordIPSetRestoreChunk(hadError bool) {
+	ipsetRestoreChunks.With(getErrorLabels(hadError)).Inc()
+}
+
 // AddEntryToIPSet increments the number of entries for IPSet setName.
 // It doesn't ever update the number of IPSets.
 func AddEntryToIPSet(setName string) {
@@ -114,6 +124,18 @@ func GetIPSetExecCount() (int, error) {
 	return getCountValue(addIPSetExecTime)
 }
 
+// GetPendingIPSetRestoreChunks returns the number of ipset restore chunks left to run in the current apply.
+// This function is slow.
+func GetPendingIPSetRestoreChunks() (int, error) {
+	return getValue(ipsetRestorePendingChunks)
+}
+
+// GetIPSetRestoreChunkCount returns the number of ipset restore chunks which ran with or without an error.
+// This function is slow.
+func GetIPSetRestoreChunkCount(hadError bool) (int, error) {
+	return getCounterVecValue(ipsetRestoreChunks, getErrorLabels(hadError))
+}
+
 func updateIPSetInventory(setName string) {
 	labels := getIPSetInventoryLabels(setName)
 	val := getEntryCountForIPSet(setName)
@@ -26,6 +26,27 @@ func TestRecordIPSetExecTime(t *testing.T) {
 	testStopAndRecord(t, setExecMetric)
 }
 
+func TestIPSetRestoreChunkMetrics(t *testing.T) {
+	succeeded, err := GetIPSetRestoreChunkCount(false)
+	require.NoError(t, err)
+	failed, err := GetIPSetRestoreChunkCount(true)
+	require.NoError(t, err)
+
+	SetPendingIPSetRestoreChunks(3)
+	RecordIPSetRestoreChunk(false)
+	RecordIPSetRestoreChunk(true)
+	pending, err := GetPendingIPSetRestoreChunks()
+	require.NoError(t, err)
+	require.Equal(t, 3, pending)
+
+	newSucceeded, err := GetIPSetRestoreChunkCount(false)
+	require.NoError(t, err)
+	newFailed, err := GetIPSetRestoreChunkCount(true)
+	require.NoError(t, err)
+	require.Equal(t, succeeded+1, newSucceeded)
+	require.Equal(t, failed+1, newFailed)
+}
+
 func TestIncNumIPSets(t *testing.T) {
 	testIncMetric(t, numSetsMetric)
 }
@@ -1,5 +1,12 @@
 package metrics
 
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
 // IncNumPolicies increments the number of policies.
 func IncNumPolicies() {
 	numPolicies.Inc()
@@ -25,6 +32,21 @@ func RecordControllerPolicyExecTime(timer *Timer, op OperationKind, hadError boo
 	}
 }
 
+// RecordPolicyTranslationCacheHit counts a policy sync which skipped translation because the spec was unchanged.
+func RecordPolicyTranslationCacheHit() {
+	policyTranslationCache.With(prometheus.Labels{cacheResultLabel: cacheHit}).Inc()
+}
+
+// RecordPolicyTranslationCacheMiss counts a policy sync which translated the policy.
+func RecordPolicyTranslationCacheMiss() {
+	policyTranslationCache.With(prometheus.Labels{cacheResultLabel: cacheMiss}).Inc()
+}
+
+// SetNumBudgetPendingPolicies sets the number of policies which exceed the dataplane budget.
+func SetNumBudgetPendingPolicies(val int) {
+	budgetPendingPolicies.Set(float64(val))
+}
+
 // GetNumPolicies returns the number of policies.
 // This function is slow.
 func GetNumPolicies() (int, error) {
@@ -39,3 +61,19 @@ func GetControllerPolicyExecCount(op OperationKind, hadError bool) (int, error)
 	}
 	return getCountVecValue(controllerPolicyExecTime, getCRUDExecTimeLabels(op, hadError))
 }
+
+// GetPolicyTranslationCacheCount returns the number of policy syncs which hit (or missed) the translation cache.
+// This function is slow.
+func GetPolicyTranslationCacheCount(hit bool) (int, error) {
+	result := cacheMiss
+	if hit {
+		result = cacheHit
+	}
+	return getCounterVecValue(policyTranslationCache, prometheus.Labels{cacheResultLabel: result})
+}
+
+// GetNumBudgetPendingPolicies returns the number of policies which exceed the dataplane budget.
+// This function is slow.
+func GetNumBudgetPendingPolicies() (int, error) {
+	return getValue(budgetPendingPolicies)
+}
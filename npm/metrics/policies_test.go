@@ -1,6 +1,10 @@
 package metrics
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 var numPoliciesMetric = &basicMetric{ResetNumPolicies, IncNumPolicies, DecNumPolicies, GetNumPolicies}
 
@@ -22,3 +26,21 @@ func TestDecNumPolicies(t *testing.T) {
 func TestResetNumPolicies(t *testing.T) {
 	testResetMetric(t, numPoliciesMetric)
 }
+
+func TestRecordPolicyTranslationCache(t *testing.T) {
+	hits, err := GetPolicyTranslationCacheCount(true)
+	require.NoError(t, err)
+	misses, err := GetPolicyTranslationCacheCount(false)
+	require.NoError(t, err)
+
+	RecordPolicyTranslationCacheHit()
+	RecordPolicyTranslationCacheMiss()
+	RecordPolicyTranslationCacheMiss()
+
+	newHits, err := GetPolicyTranslationCacheCount(true)
+	require.NoError(t, err)
+	newMisses, err := GetPolicyTranslationCacheCount(false)
+	require.NoError(t, err)
+	require.Equal(t, hits+1, newHits)
+	require.Equal(t, misses+2, newMisses)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// policyHashBuckets bounds the number of values of the policy hash label, whatever the number of policies.
+const policyHashBuckets = 256
+
+// PolicyErrorClass is the class of error which failed to apply a network policy.
+type PolicyErrorClass string
+
+const (
+	// TranslationError is a policy which can't be translated. It isn't retried.
+	TranslationError PolicyErrorClass = "translation"
+	// UnsupportedError is a policy with features the dataplane doesn't support, e.g. on Windows. It isn't retried.
+	UnsupportedError PolicyErrorClass = "unsupported"
+	// BudgetExceededError is a policy which would exceed the dataplane budget of the node.
+	BudgetExceededError PolicyErrorClass = "budget_exceeded"
+	// DataplaneError is a failure to program or remove the policy in the dataplane.
+	DataplaneError PolicyErrorClass = "dataplane"
+	// ListerError is a failure to get the policy from the informer cache.
+	ListerError PolicyErrorClass = "lister"
+)
+
+// PolicyHash returns the hash bucket of the policy key, <namespace>/<name>, which labels its apply latency.
+// Several policies share each bucket, so the bucket of a policy is logged along with its applies.
+func PolicyHash(policyKey string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(policyKey))
+	return fmt.Sprintf("%02x", h.Sum32()%policyHashBuckets)
+}
+
+// RecordPolicyApplyLatency records the time from receiving an event of the policy until the dataplane converged to it.
+func RecordPolicyApplyLatency(policyKey string, latency time.Duration) {
+	policyApplyLatency.With(prometheus.Labels{policyHashLabel: PolicyHash(policyKey)}).Observe(float64(latency) / float64(time.Millisecond))
+}
+
+// RecordPolicyApplyFailure counts a failure to apply a policy.
+func RecordPolicyApplyFailure(class PolicyErrorClass) {
+	policyApplyFailures.With(prometheus.Labels{errorClassLabel: string(class)}).Inc()
+}
+
+// GetPolicyApplyLatencyCount returns the number of apply latencies recorded in the hash bucket of the policy.
+// This function is slow.
+func GetPolicyApplyLatencyCount(policyKey string) (int, error) {
+	return getHistogramVecCount(policyApplyLatency, prometheus.Labels{policyHashLabel: PolicyHash(policyKey)})
+}
+
+// GetPolicyApplyFailureCount returns the number of failures to apply a policy of the class.
+// This function is slow.
+func GetPolicyApplyFailureCount(class PolicyErrorClass) (int, error) {
+	return getCounterVecValue(policyApplyFailures, prometheus.Labels{errorClassLabel: string(class)})
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyHash(t *testing.T) {
+	require.Equal(t, PolicyHash("x/test"), PolicyHash("x/test"))
+	buckets := make(map[string]struct{})
+	for i := 0; i < 10*policyHashBuckets; i++ {
+		buckets[PolicyHash(time.Duration(i).String())] = struct{}{}
+	}
+	require.LessOrEqual(t, len(buckets), policyHashBuckets)
+}
+
+func TestRecordPolicyApplyLatency(t *testing.T) {
+	before, err := GetPolicyApplyLatencyCount("x/test")
+	require.NoError(t, err)
+
+	RecordPolicyApplyLatency("x/test", 20*time.Millisecond)
+	RecordPolicyApplyLatency("x/test", time.Second)
+
+	after, err := GetPolicyApplyLatencyCount("x/test")
+	require.NoError(t, err)
+	require.Equal(t, before+2, after)
+}
+
+func TestRecordPolicyApplyFailure(t *testing.T) {
+	before, err := GetPolicyApplyFailureCount(DataplaneError)
+	require.NoError(t, err)
+	budget, err := GetPolicyApplyFailureCount(BudgetExceededError)
+	require.NoError(t, err)
+
+	RecordPolicyApplyFailure(DataplaneError)
+
+	after, err := GetPolicyApplyFailureCount(DataplaneError)
+	require.NoError(t, err)
+	require.Equal(t, before+1, after)
+	newBudget, err := GetPolicyApplyFailureCount(BudgetExceededError)
+	require.NoError(t, err)
+	require.Equal(t, budget, newBudget)
+}
@@ -35,6 +35,35 @@ const (
 	numIPSetEntriesName = "num_ipset_entries"
 	numIPSetEntriesHelp = "The total number of entries in every IPSet"
 
+	ipsetRestoreChunksName = "ipset_restore_chunks_total"
+	ipsetRestoreChunksHelp = "The number of ipset restore chunks run when applying IPSets"
+
+	ipsetRestorePendingChunksName = "ipset_restore_pending_chunks"
+	ipsetRestorePendingChunksHelp = "The number of ipset restore chunks left to run in the current apply of IPSets"
+
+	dataplaneAppliesName = "dataplane_applies_total"
+	dataplaneAppliesHelp = "The number of times an IPSet or policy was applied to the dataplane, incrementally or by a rebuild after consecutive failures"
+	objectLabel          = "object"
+	applyTypeLabel       = "apply_type"
+
+	iptablesJumpRepairsName = "iptables_jump_repairs_total"
+	iptablesJumpRepairsHelp = "The number of times the jump from FORWARD chain to AZURE-NPM chain was found missing or misplaced and repaired"
+	ipFamilyLabel           = "ip_family"
+	repairReasonLabel       = "reason"
+
+	conntrackFlushesName = "conntrack_flushes_total"
+	conntrackFlushesHelp = "The number of times the conntrack entries of a pod were flushed after applying a network policy selecting it"
+
+	conntrackFlushedEntriesName = "conntrack_flushed_entries_total"
+	conntrackFlushedEntriesHelp = "The number of conntrack entries flushed after applying network policies, so that established connections don't bypass them"
+
+	iptablesBackendName = "iptables_backend"
+	iptablesBackendHelp = "1 for the iptables backend (nft or legacy) NPM detected and programs, 0 for the other"
+	backendLabel        = "backend"
+
+	iptablesMixedBackendsName = "iptables_mixed_backends"
+	iptablesMixedBackendsHelp = "1 if kube rules were found in both the nft and legacy iptables backends on bootup, 0 otherwise"
+
 	ipsetInventoryName = "ipset_counts"
 	ipsetInventoryHelp = "The number of entries in each individual IPSet"
 	setNameLabel       = "set_name"
@@ -54,6 +83,48 @@ const (
 	namespaceExecTimeName           = "namespace_exec_time"
 	controllerNamespaceExecTimeHelp = "Execution time in milliseconds for adding/updating/deleting a namespace"
 
+	policyTranslationCacheName = "policy_translation_cache_total"
+	policyTranslationCacheHelp = "The number of network policy syncs which hit or missed the translation cache. A hit skips translation and the dataplane"
+
+	budgetPendingPoliciesName = "budget_pending_policies"
+	budgetPendingPoliciesHelp = "The number of network policies which aren't programmed because they would exceed the dataplane budget of this node"
+	cacheResultLabel          = "result"
+
+	policyApplyLatencyName = "policy_apply_latency"
+	policyApplyLatencyHelp = "Time in milliseconds from receiving a network policy event until the dataplane converges to the policy, by hash bucket of the policy name"
+	policyHashLabel        = "policy_hash"
+
+	policyApplyFailuresName = "policy_apply_failures_total"
+	policyApplyFailuresHelp = "The number of failures to apply a network policy to the dataplane, by class of error"
+	errorClassLabel         = "error_class"
+
+	excludedNamespaceEventsName = "excluded_namespace_events_total"
+	excludedNamespaceEventsHelp = "The number of pod, namespace and network policy events skipped because they're in a namespace excluded by the config"
+
+	// the work queue metrics are named like the ones of client-go work queues in k8s.io/component-base
+	workqueueDepthName = "workqueue_depth"
+	workqueueDepthHelp = "The current depth of the work queue"
+
+	workqueueAddsName = "workqueue_adds_total"
+	workqueueAddsHelp = "The number of adds handled by the work queue"
+
+	workqueueLatencyName = "workqueue_queue_duration_seconds"
+	workqueueLatencyHelp = "How long in seconds an item stays in the work queue before being requested"
+
+	workqueueWorkDurationName = "workqueue_work_duration_seconds"
+	workqueueWorkDurationHelp = "How long in seconds processing an item from the work queue takes"
+
+	workqueueUnfinishedWorkName = "workqueue_unfinished_work_seconds"
+	workqueueUnfinishedWorkHelp = "How many seconds of work has been done that is in progress and hasn't been observed by work_duration. " +
+		"Large values indicate stuck threads"
+
+	workqueueLongestRunningProcessorName = "workqueue_longest_running_processor_seconds"
+	workqueueLongestRunningProcessorHelp = "How many seconds the longest running processor of the work queue has been running"
+
+	workqueueRetriesName = "workqueue_retries_total"
+	workqueueRetriesHelp = "The number of retries handled by the work queue"
+	queueNameLabel       = "name"
+
 	quantileMedian float64 = 0.5
 	deltaMedian    float64 = 0.05
 	quantile90th   float64 = 0.9
@@ -83,6 +154,19 @@ var (
 	ipsetInventory       *prometheus.GaugeVec
 	ipsetInventoryLabels = []string{setNameLabel, setHashLabel}
 
+	ipsetRestoreChunks        *prometheus.CounterVec
+	ipsetRestorePendingChunks prometheus.Gauge
+
+	dataplaneApplies *prometheus.CounterVec
+
+	iptablesJumpRepairs *prometheus.CounterVec
+
+	conntrackFlushes        *prometheus.CounterVec
+	conntrackFlushedEntries *prometheus.CounterVec
+
+	iptablesBackend       *prometheus.GaugeVec
+	iptablesMixedBackends prometheus.Gauge
+
 	// controller perf metrics
 	// used to be a regular Summary in v1.4.16 and below
 	addPolicyExecTime       *prometheus.SummaryVec
@@ -92,6 +176,20 @@ var (
 	controllerPodExecTime       *prometheus.SummaryVec
 	controllerNamespaceExecTime *prometheus.SummaryVec
 	controllerExecTimeLabels    = []string{operationLabel, hadErrorLabel}
+	policyTranslationCache      *prometheus.CounterVec
+	budgetPendingPolicies       prometheus.Gauge
+	policyApplyLatency          *prometheus.HistogramVec
+	policyApplyFailures         *prometheus.CounterVec
+	excludedNamespaceEvents     *prometheus.CounterVec
+
+	// work queue metrics of the controllers, by queue name
+	workqueueDepth                   *prometheus.GaugeVec
+	workqueueAdds                    *prometheus.CounterVec
+	workqueueLatency                 *prometheus.HistogramVec
+	workqueueWorkDuration            *prometheus.HistogramVec
+	workqueueUnfinishedWork          *prometheus.GaugeVec
+	workqueueLongestRunningProcessor *prometheus.GaugeVec
+	workqueueRetries                 *prometheus.CounterVec
 )
 
 type RegistryType string
@@ -164,6 +262,14 @@ func initializeDaemonMetrics() {
 	// NODE METRICS
 	addACLRuleExecTime = createNodeSummary(addACLRuleExecTimeName, addACLRuleExecTimeHelp)
 	addIPSetExecTime = createNodeSummary(addIPSetExecTimeName, addIPSetExecTimeHelp)
+	ipsetRestoreChunks = createNodeCounterVec(ipsetRestoreChunksName, "", ipsetRestoreChunksHelp, []string{hadErrorLabel})
+	ipsetRestorePendingChunks = createNodeGauge(ipsetRestorePendingChunksName, ipsetRestorePendingChunksHelp)
+	dataplaneApplies = createNodeCounterVec(dataplaneAppliesName, "", dataplaneAppliesHelp, []string{objectLabel, applyTypeLabel, hadErrorLabel})
+	iptablesJumpRepairs = createNodeCounterVec(iptablesJumpRepairsName, "", iptablesJumpRepairsHelp, []string{ipFamilyLabel, repairReasonLabel})
+	conntrackFlushes = createNodeCounterVec(conntrackFlushesName, "", conntrackFlushesHelp, []string{ipFamilyLabel, hadErrorLabel})
+	conntrackFlushedEntries = createNodeCounterVec(conntrackFlushedEntriesName, "", conntrackFlushedEntriesHelp, []string{ipFamilyLabel})
+	iptablesBackend = createNodeGaugeVec(iptablesBackendName, iptablesBackendHelp, []string{backendLabel})
+	iptablesMixedBackends = createNodeGauge(iptablesMixedBackendsName, iptablesMixedBackendsHelp)
 }
 
 // initializeControllerMetrics creates metrics modified by the controller
@@ -179,6 +285,26 @@ func initializeControllerMetrics() {
 	controllerPolicyExecTime = createControllerExecTimeSummaryVec(policyExecTimeName, controllerPolicyExecTimeHelp)
 	controllerPodExecTime = createControllerExecTimeSummaryVec(podExecTimeName, controllerPodExecTimeHelp)
 	controllerNamespaceExecTime = createControllerExecTimeSummaryVec(namespaceExecTimeName, controllerNamespaceExecTimeHelp)
+	policyTranslationCache = createNodeCounterVec(policyTranslationCacheName, controllerPrefix, policyTranslationCacheHelp, []string{cacheResultLabel})
+	budgetPendingPolicies = createNodeGauge(budgetPendingPoliciesName, budgetPendingPoliciesHelp)
+	policyApplyLatency = createNodeHistogramVec(policyApplyLatencyName, controllerPrefix, policyApplyLatencyHelp,
+		//nolint:gomnd // 10 ms to ~80 seconds
+		prometheus.ExponentialBuckets(10, 2, 14), []string{policyHashLabel})
+	policyApplyFailures = createNodeCounterVec(policyApplyFailuresName, controllerPrefix, policyApplyFailuresHelp, []string{errorClassLabel})
+	excludedNamespaceEvents = createNodeCounterVec(excludedNamespaceEventsName, controllerPrefix, excludedNamespaceEventsHelp, []string{objectLabel})
+
+	workqueueLabels := []string{queueNameLabel}
+	workqueueDepth = createNodeGaugeVec(workqueueDepthName, workqueueDepthHelp, workqueueLabels)
+	workqueueAdds = createNodeCounterVec(workqueueAddsName, "", workqueueAddsHelp, workqueueLabels)
+	workqueueLatency = createNodeHistogramVec(workqueueLatencyName, "", workqueueLatencyHelp,
+		//nolint:gomnd // 1 ns to ~17 minutes, as in k8s.io/component-base
+		prometheus.ExponentialBuckets(10e-9, 10, 10), workqueueLabels)
+	workqueueWorkDuration = createNodeHistogramVec(workqueueWorkDurationName, "", workqueueWorkDurationHelp,
+		//nolint:gomnd // 1 ns to ~17 minutes, as in k8s.io/component-base
+		prometheus.ExponentialBuckets(10e-9, 10, 10), workqueueLabels)
+	workqueueUnfinishedWork = createNodeGaugeVec(workqueueUnfinishedWorkName, workqueueUnfinishedWorkHelp, workqueueLabels)
+	workqueueLongestRunningProcessor = createNodeGaugeVec(workqueueLongestRunningProcessorName, workqueueLongestRunningProcessorHelp, workqueueLabels)
+	workqueueRetries = createNodeCounterVec(workqueueRetriesName, "", workqueueRetriesHelp, workqueueLabels)
 }
 
 func register(collector prometheus.Collector, name string, registryType RegistryType) {
@@ -220,6 +346,45 @@ func createClusterGaugeVec(name, helpMessage string, labels []string) *prometheu
 	return gaugeVec
 }
 
+func createNodeGauge(name, helpMessage string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+		},
+	)
+	register(gauge, name, NodeMetrics)
+	return gauge
+}
+
+func createNodeGaugeVec(name, helpMessage string, labels []string) *prometheus.GaugeVec {
+	gaugeVec := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+		},
+		labels,
+	)
+	register(gaugeVec, name, NodeMetrics)
+	return gaugeVec
+}
+
+func createNodeCounterVec(name, subsystem, helpMessage string, labels []string) *prometheus.CounterVec {
+	counterVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      helpMessage,
+		},
+		labels,
+	)
+	register(counterVec, name, NodeMetrics)
+	return counterVec
+}
+
 func createNodeSummary(name, helpMessage string) prometheus.Summary {
 	// uses default observation TTL of 10 minutes
 	summary := prometheus.NewSummary(
@@ -250,6 +415,21 @@ func createNodeSummaryVec(name, subsystem, helpMessage string, labels []string)
 	return summary
 }
 
+func createNodeHistogramVec(name, subsystem, helpMessage string, buckets []float64, labels []string) *prometheus.HistogramVec {
+	histogram := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      helpMessage,
+			Buckets:   buckets,
+		},
+		labels,
+	)
+	register(histogram, name, NodeMetrics)
+	return histogram
+}
+
 func createControllerExecTimeSummaryVec(name, helpMessage string) *prometheus.SummaryVec {
 	return createNodeSummaryVec(name, controllerPrefix, helpMessage, controllerExecTimeLabels)
 }
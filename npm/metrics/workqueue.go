@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// workqueueMetricsProvider provides the metrics of the work queues of the controllers, labeled with the queue name.
+type workqueueMetricsProvider struct{}
+
+var _ workqueue.MetricsProvider = workqueueMetricsProvider{}
+
+// WorkqueueMetricsProvider returns the provider of the metrics of the named work queues of the controllers, e.g. "Pods".
+// The metrics must be initialized before the queues are created.
+func WorkqueueMetricsProvider() workqueue.MetricsProvider {
+	return workqueueMetricsProvider{}
+}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunningProcessor.With(queueNameLabels(name))
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.With(queueNameLabels(name))
+}
+
+// GetWorkqueueAddCount returns the number of adds handled by the work queue with the name.
+// This function is slow.
+func GetWorkqueueAddCount(name string) (int, error) {
+	return getCounterVecValue(workqueueAdds, queueNameLabels(name))
+}
+
+// GetWorkqueueDepth returns the current depth of the work queue with the name.
+// This function is slow.
+func GetWorkqueueDepth(name string) (int, error) {
+	return getVecValue(workqueueDepth, queueNameLabels(name))
+}
+
+// GetWorkqueueRetryCount returns the number of retries handled by the work queue with the name.
+// This function is slow.
+func GetWorkqueueRetryCount(name string) (int, error) {
+	return getCounterVecValue(workqueueRetries, queueNameLabels(name))
+}
+
+func queueNameLabels(name string) prometheus.Labels {
+	return prometheus.Labels{queueNameLabel: name}
+}
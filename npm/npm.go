@@ -5,6 +5,7 @@ package npm
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/ipsm"
@@ -14,7 +15,9 @@ import (
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
@@ -23,6 +26,14 @@ import (
 
 var aiMetadata string //nolint // aiMetadata is set in Makefile
 
+const (
+	// bootupIdlePollInterval is how often the v2 controllers are checked for having drained their initial events.
+	bootupIdlePollInterval = 500 * time.Millisecond
+	// bootupIdleTimeout bounds how long bootup waits for the v2 controllers to drain their initial events.
+	// Items that keep failing are retried by the controllers after bootup finishes.
+	bootupIdleTimeout = 5 * time.Minute
+)
+
 // NetworkPolicyManager contains informers for pod, namespace and networkpolicy.
 type NetworkPolicyManager struct {
 	config npmconfig.Config
@@ -46,6 +57,9 @@ type NetworkPolicyManager struct {
 
 	// Azure-specific variables
 	models.AzureConfig
+
+	// addressGroupInformerFactory is the informer factory of the AddressGroupControllerV2, if AddressGroups are enabled
+	addressGroupInformerFactory dynamicinformer.DynamicSharedInformerFactory
 }
 
 // NewNetworkPolicyManager creates a NetworkPolicyManager
@@ -81,6 +95,8 @@ func NewNetworkPolicyManager(config npmconfig.Config,
 		npMgr.NamespaceControllerV2 = controllersv2.NewNamespaceController(npMgr.NsInformer, dp, npMgr.NpmNamespaceCacheV2)
 		// Question(jungukcho): Is config.Toggles.PlaceAzureChainFirst needed for v2?
 		npMgr.NetPolControllerV2 = controllersv2.NewNetworkPolicyController(npMgr.NpInformer, dp)
+		npMgr.PodControllerV2.YieldToPolicyDeletes(npMgr.NetPolControllerV2)
+		npMgr.UpdateAppliers(config.Appliers)
 		return npMgr
 	}
 
@@ -94,6 +110,18 @@ func NewNetworkPolicyManager(config npmconfig.Config,
 	return npMgr
 }
 
+// EnableAddressGroups creates the controller of the AddressGroups watched by the factory.
+// It must be called before Start, and is a no-op for v1.
+func (npMgr *NetworkPolicyManager) EnableAddressGroups(factory dynamicinformer.DynamicSharedInformerFactory) {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return
+	}
+
+	npMgr.addressGroupInformerFactory = factory
+	npMgr.AddressGroupControllerV2 = controllersv2.NewAddressGroupController(factory.ForResource(controllersv2.AddressGroupResource), npMgr.Dataplane)
+	npMgr.AddressGroupControllerV2.Applier().Update(npMgr.config.Appliers.IPSets)
+}
+
 // Dear Time Traveler:
 // This is the server end of the debug dragons den. Several of these properties of the
 // npMgr struct have overridden methods which override the MarshalJson, just as this one
@@ -163,6 +191,61 @@ func (npMgr *NetworkPolicyManager) MarshalJSON() ([]byte, error) {
 	return npmCacheRaw, nil
 }
 
+// GetNodeName returns the node of the cache served by the debug API.
+func (npMgr *NetworkPolicyManager) GetNodeName() string {
+	return npMgr.NodeName
+}
+
+// CacheKeys returns the keys of the maps of the cache served by pages by the debug API.
+func (npMgr *NetworkPolicyManager) CacheKeys() []models.CacheKey {
+	if npMgr.config.Toggles.EnableV2NPM {
+		return []models.CacheKey{models.NsMap, models.PodMap, models.SetMap}
+	}
+	return []models.CacheKey{models.NsMap, models.PodMap, models.ListMap, models.SetMap}
+}
+
+// CachePage returns up to limit entries of a map of the cache, from the start key in key order,
+// so that the debug API never encodes the whole cache of the node at once.
+func (npMgr *NetworkPolicyManager) CachePage(key models.CacheKey, start string, limit int) (*common.CachePage, error) {
+	if npMgr.config.Toggles.EnableV2NPM {
+		switch key {
+		case models.NsMap:
+			return npMgr.NpmNamespaceCacheV2.NsMapPage(start, limit) //nolint:wrapcheck // already wrapped
+		case models.PodMap:
+			return npMgr.PodControllerV2.PodMapPage(start, limit) //nolint:wrapcheck // already wrapped
+		case models.SetMap:
+			return common.NewCachePage(npMgr.Dataplane.GetAllIPSets(), start, limit) //nolint:wrapcheck // already wrapped
+		}
+		return nil, errors.Wrapf(models.ErrUnknownCacheKey, "%s in v2 cache", key)
+	}
+
+	var (
+		mapRaw []byte
+		err    error
+	)
+	switch key {
+	case models.NsMap:
+		return npMgr.NpmNamespaceCacheV1.NsMapPage(start, limit) //nolint:wrapcheck // already wrapped
+	case models.PodMap:
+		return npMgr.PodControllerV1.PodMapPage(start, limit) //nolint:wrapcheck // already wrapped
+	case models.ListMap:
+		mapRaw, err = npMgr.ipsMgr.GetListMapRaw()
+	case models.SetMap:
+		mapRaw, err = npMgr.ipsMgr.GetSetMapRaw()
+	default:
+		return nil, errors.Wrapf(models.ErrUnknownCacheKey, "%s in v1 cache", key)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal v1 %s", key)
+	}
+	// the v1 ipset maps only hold names, so they are small enough to copy
+	m := map[string]string{}
+	if err := json.Unmarshal(mapRaw, &m); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal v1 %s", key)
+	}
+	return common.NewCachePage(m, start, limit) //nolint:wrapcheck // already wrapped
+}
+
 // GetAppVersion returns network policy manager app version
 func (npMgr *NetworkPolicyManager) GetAppVersion() string {
 	return npMgr.Version
@@ -193,11 +276,31 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 		return fmt.Errorf("NetworkPolicy informer error: %w", models.ErrInformerSyncFailure)
 	}
 
+	if npMgr.addressGroupInformerFactory != nil {
+		npMgr.addressGroupInformerFactory.Start(stopCh)
+		for resource, synced := range npMgr.addressGroupInformerFactory.WaitForCacheSync(stopCh) {
+			if !synced {
+				return fmt.Errorf("%s informer error: %w", resource.Resource, models.ErrInformerSyncFailure)
+			}
+		}
+	}
+
 	// start v2 NPM controllers after synced
 	if config.Toggles.EnableV2NPM {
 		go npMgr.PodControllerV2.Run(stopCh)
 		go npMgr.NamespaceControllerV2.Run(stopCh)
 		go npMgr.NetPolControllerV2.Run(stopCh)
+		if npMgr.AddressGroupControllerV2 != nil {
+			go npMgr.AddressGroupControllerV2.Run(stopCh)
+		}
+
+		// The dataplane buffers events until every controller has drained the events for its initial
+		// informer cache, then programs IPSets and policies in a single pass. Otherwise, policies could
+		// be applied against partially populated IPSets, causing transient drops after a restart.
+		npMgr.waitForV2ControllersIdle()
+		if err := npMgr.Dataplane.FinishBootupPhase(); err != nil {
+			return fmt.Errorf("failed to finish dataplane bootup with err %w", err)
+		}
 
 		return nil
 	}
@@ -211,6 +314,45 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 	return nil
 }
 
+// waitForV2ControllersIdle blocks until all v2 controllers are idle on two consecutive polls,
+// or until bootupIdleTimeout passes.
+func (npMgr *NetworkPolicyManager) waitForV2ControllersIdle() {
+	isIdle := func() bool {
+		return npMgr.PodControllerV2.IsIdle() && npMgr.NamespaceControllerV2.IsIdle() && npMgr.NetPolControllerV2.IsIdle() &&
+			(npMgr.AddressGroupControllerV2 == nil || npMgr.AddressGroupControllerV2.IsIdle())
+	}
+
+	// polling twice guards against catching a worker between dequeuing an item and marking it in-flight
+	wasIdle := false
+	err := wait.PollImmediate(bootupIdlePollInterval, bootupIdleTimeout, func() (bool, error) {
+		idle := isIdle()
+		done := idle && wasIdle
+		wasIdle = idle
+		return done, nil
+	})
+	if err != nil {
+		klog.Warningf("finishing bootup before v2 controllers are idle: %v", err)
+		return
+	}
+	klog.Info("v2 controllers finished processing initial events")
+}
+
+// UpdateAppliers changes the worker counts and rate limits of the v2 controllers.
+// IPSet and policy updates are applied independently, so slow policy updates don't delay IPSet membership updates.
+// It is a no-op for v1.
+func (npMgr *NetworkPolicyManager) UpdateAppliers(cfg npmconfig.AppliersConfig) {
+	if !npMgr.config.Toggles.EnableV2NPM {
+		return
+	}
+
+	npMgr.PodControllerV2.Applier().Update(cfg.IPSets)
+	npMgr.NamespaceControllerV2.Applier().Update(cfg.IPSets)
+	npMgr.NetPolControllerV2.Applier().Update(cfg.Policies)
+	if npMgr.AddressGroupControllerV2 != nil {
+		npMgr.AddressGroupControllerV2.Applier().Update(cfg.IPSets)
+	}
+}
+
 // GetAIMetadata returns ai metadata number
 func GetAIMetadata() string {
 	return aiMetadata
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -41,13 +42,18 @@ func TestNPMCache(t *testing.T) {
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != api.NPMMgrPath {
-			t.Errorf("Expected to request '/fixedvalue', got: %s", r.URL.Path)
+		if r.URL.Path != api.NPMCachePath {
+			t.Errorf("Expected to request '%s', got: %s", api.NPMCachePath, r.URL.Path)
 		}
 
+		query := r.URL.Query()
+		limit, err := strconv.Atoi(query.Get(api.CacheLimitParam))
+		require.NoError(t, err)
+		page, err := npmMgr.CachePage(models.CacheKey(query.Get(api.CacheKeyParam)), query.Get(api.CacheStartParam), limit)
+		require.NoError(t, err)
+
 		w.WriteHeader(http.StatusOK)
-		encoder := &npmMgr
-		b, err := json.Marshal(encoder)
+		b, err := json.Marshal(page)
 		require.NoError(t, err)
 		_, err = w.Write(b)
 		require.NoError(t, err)
@@ -0,0 +1,393 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+// Package conformance runs a curated subset of the upstream network policy conformance scenarios against a live
+// cluster, to validate an NPM install. Probe pods are created in namespaces of their own, and for each scenario its
+// policies are created and the connectivity between the probe pods is compared to the one the policies allow.
+// The dataplane of the nodes is dumped when a scenario fails.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// ProbeContainerPrefix is the prefix of the names of the containers of the probe pods, followed by their port.
+	ProbeContainerPrefix = "cont-"
+
+	pollInterval = time.Second
+)
+
+var (
+	// ErrInvalidConfig is returned by Run for a Config which can't run.
+	ErrInvalidConfig = errors.New("invalid conformance config")
+	// ErrPodsNotReady is returned by Run when the probe pods aren't running in time.
+	ErrPodsNotReady = errors.New("probe pods not ready")
+)
+
+// Prober probes the connectivity between the probe pods, and dumps the dataplane of the nodes.
+type Prober interface {
+	// Probe returns whether the probe pod can connect to the TCP address.
+	Probe(ctx context.Context, from *corev1.Pod, addr string) (bool, error)
+	// Dump returns the dataplane of NPM on the node, by the name of its parts.
+	Dump(ctx context.Context, nodeName string) (map[string]string, error)
+}
+
+// Config is what Run runs and how.
+type Config struct {
+	// NamespacePrefix is the prefix of the namespaces of the probe pods, which must not exist.
+	NamespacePrefix string
+	// Image of the probe pods, which must be agnhost.
+	Image string
+	// Scenarios are the names of the scenarios to run, all of them if empty.
+	Scenarios []string
+	// ReadyTimeout is how long the probe pods have to be running.
+	ReadyTimeout time.Duration
+	// ScenarioTimeout is how long the dataplane has to converge to the policies of a scenario before it fails.
+	ScenarioTimeout time.Duration
+	// DumpDir is where the dataplane of the nodes is dumped when a scenario fails, nowhere if empty.
+	DumpDir string
+	// KeepNamespaces leaves the namespaces of the probe pods in the cluster, e.g. to debug failures.
+	KeepNamespaces bool
+}
+
+// DefaultConfig runs all the scenarios.
+var DefaultConfig = Config{
+	NamespacePrefix: "npm-conformance-",
+	Image:           "k8s.gcr.io/e2e-test-images/agnhost:2.33",
+	ReadyTimeout:    5 * time.Minute,
+	ScenarioTimeout: time.Minute,
+}
+
+func (cfg *Config) validate() error {
+	switch {
+	case cfg.NamespacePrefix == "" || cfg.Image == "":
+		return fmt.Errorf("%w: need a namespace prefix and an image", ErrInvalidConfig)
+	case cfg.ReadyTimeout <= 0 || cfg.ScenarioTimeout <= 0:
+		return fmt.Errorf("%w: timeouts must be positive", ErrInvalidConfig)
+	}
+	known := map[string]struct{}{}
+	for i := range Scenarios {
+		known[Scenarios[i].Name] = struct{}{}
+	}
+	for _, name := range cfg.Scenarios {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("%w: unknown scenario %s", ErrInvalidConfig, name)
+		}
+	}
+	return nil
+}
+
+// ProbeFailure is a probe whose result isn't the one the policies of its scenario allow.
+type ProbeFailure struct {
+	From     string
+	To       string
+	Port     int32
+	Expected bool
+	// Err is set if the probe couldn't run.
+	Err error
+}
+
+func (f ProbeFailure) String() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s -> %s:%d: %v", f.From, f.To, f.Port, f.Err)
+	}
+	return fmt.Sprintf("%s -> %s:%d: expected connected=%t, got %t", f.From, f.To, f.Port, f.Expected, !f.Expected)
+}
+
+// ScenarioResult is the result of a scenario.
+type ScenarioResult struct {
+	Name     string
+	Duration time.Duration
+	// Failures are the probes which still failed at the ScenarioTimeout, empty if the scenario passed.
+	Failures []ProbeFailure
+	// Err is set if the scenario couldn't run.
+	Err error
+	// Dumps are the files of the dataplane dumps of the failed scenario.
+	Dumps []string
+}
+
+// Passed returns whether the connectivity converged to the one allowed by the policies.
+func (r *ScenarioResult) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// Report is the result of a conformance run.
+type Report struct {
+	Results []ScenarioResult
+}
+
+// Failed returns the number of scenarios which failed.
+func (r *Report) Failed() int {
+	failed := 0
+	for i := range r.Results {
+		if !r.Results[i].Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for i := range r.Results {
+		res := &r.Results[i]
+		status := "PASS"
+		if !res.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s %s (%v)\n", status, res.Name, res.Duration.Round(time.Millisecond))
+		if res.Err != nil {
+			fmt.Fprintf(&b, "    error: %v\n", res.Err)
+		}
+		for _, f := range res.Failures {
+			fmt.Fprintf(&b, "    %s\n", f)
+		}
+		for _, dump := range res.Dumps {
+			fmt.Fprintf(&b, "    dump: %s\n", dump)
+		}
+	}
+	fmt.Fprintf(&b, "%d/%d scenarios passed\n", len(r.Results)-r.Failed(), len(r.Results))
+	return b.String()
+}
+
+// Run creates the probe pods, runs the scenarios of cfg and returns their results. It fails only if the scenarios
+// can't run at all: the failures of the scenarios are in the Report.
+func Run(ctx context.Context, cfg Config, clientset kubernetes.Interface, prober Prober) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &runner{cfg: cfg, clientset: clientset, prober: prober}
+	defer r.cleanup()
+	if err := r.createProbePods(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for i := range Scenarios {
+		if !r.selected(Scenarios[i].Name) {
+			continue
+		}
+		res := r.runScenario(ctx, &Scenarios[i])
+		klog.Infof("[conformance] scenario %s passed: %t", res.Name, res.Passed())
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+type runner struct {
+	cfg       Config
+	clientset kubernetes.Interface
+	prober    Prober
+	// pods are the running probe pods.
+	pods map[probePod]*corev1.Pod
+	// created are the namespaces created by the run.
+	created []string
+}
+
+func (r *runner) selected(name string) bool {
+	if len(r.cfg.Scenarios) == 0 {
+		return true
+	}
+	for _, s := range r.cfg.Scenarios {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *runner) namespace(ns string) string {
+	return r.cfg.NamespacePrefix + ns
+}
+
+// createProbePods creates the namespaces of the probe pods and the pods, and waits until the pods are running.
+func (r *runner) createProbePods(ctx context.Context) error {
+	for _, ns := range namespaces {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: r.namespace(ns), Labels: map[string]string{nsLabel: ns}}}
+		if _, err := r.clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create namespace %s: %w", namespace.Name, err)
+		}
+		r.created = append(r.created, namespace.Name)
+		for _, name := range podNames {
+			if _, err := r.clientset.CoreV1().Pods(namespace.Name).Create(ctx, r.probePodSpec(namespace.Name, name), metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed to create probe pod %s/%s: %w", namespace.Name, name, err)
+			}
+		}
+	}
+
+	r.pods = map[probePod]*corev1.Pod{}
+	err := wait.PollImmediate(pollInterval, r.cfg.ReadyTimeout, func() (bool, error) {
+		for _, ns := range namespaces {
+			for _, name := range podNames {
+				pod, err := r.clientset.CoreV1().Pods(r.namespace(ns)).Get(ctx, name, metav1.GetOptions{})
+				if err != nil {
+					return false, fmt.Errorf("failed to get probe pod %s/%s: %w", r.namespace(ns), name, err)
+				}
+				if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+					return false, nil
+				}
+				r.pods[probePod{ns, name}] = pod
+			}
+		}
+		return true, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("%w after %v", ErrPodsNotReady, r.cfg.ReadyTimeout)
+	}
+	return err
+}
+
+// probePodSpec serves every port from a container of its own, so that the probes are independent of each other.
+func (r *runner) probePodSpec(namespace, name string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{podLabel: name}},
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{corev1.LabelOSStable: "linux"},
+		},
+	}
+	for _, port := range ports {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Name:            fmt.Sprintf("%s%d", ProbeContainerPrefix, port),
+			Image:           r.cfg.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/agnhost", "serve-hostname", "--tcp", "--http=false", "--port", fmt.Sprint(port)},
+			Ports:           []corev1.ContainerPort{{ContainerPort: port, Protocol: corev1.ProtocolTCP}},
+		})
+	}
+	return pod
+}
+
+func (r *runner) cleanup() {
+	if r.cfg.KeepNamespaces {
+		return
+	}
+	for _, namespace := range r.created {
+		err := r.clientset.CoreV1().Namespaces().Delete(context.Background(), namespace, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("[conformance] failed to delete namespace %s: %v", namespace, err)
+		}
+	}
+}
+
+// runScenario creates the policies of the scenario, probes until the connectivity is the one they allow or the
+// ScenarioTimeout expires, and deletes them.
+func (r *runner) runScenario(ctx context.Context, s *Scenario) ScenarioResult {
+	res := ScenarioResult{Name: s.Name}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	policies := r.clientset.NetworkingV1()
+	for _, policy := range s.Policies {
+		p := policy.DeepCopy()
+		p.Namespace = r.namespace(policy.Namespace)
+		if _, err := policies.NetworkPolicies(p.Namespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
+			res.Err = fmt.Errorf("failed to create policy %s/%s: %w", p.Namespace, p.Name, err)
+			break
+		}
+		defer func() {
+			if err := policies.NetworkPolicies(p.Namespace).Delete(context.Background(), p.Name, metav1.DeleteOptions{}); err != nil {
+				klog.Errorf("[conformance] failed to delete policy %s/%s: %v", p.Namespace, p.Name, err)
+			}
+		}()
+	}
+	if res.Err != nil {
+		return res
+	}
+
+	_ = wait.PollImmediate(pollInterval, r.cfg.ScenarioTimeout, func() (bool, error) {
+		res.Failures = r.probeAll(ctx, s)
+		return len(res.Failures) == 0, ctx.Err()
+	})
+	if len(res.Failures) > 0 && r.cfg.DumpDir != "" {
+		res.Dumps = r.dump(ctx, s.Name)
+	}
+	return res
+}
+
+// probeAll probes every port of every probe pod from every other probe pod, and returns the unexpected results.
+func (r *runner) probeAll(ctx context.Context, s *Scenario) []ProbeFailure {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures []ProbeFailure
+	)
+	for from, fromPod := range r.pods {
+		for to, toPod := range r.pods {
+			if from == to {
+				// connections of pods to themselves don't leave the pod
+				continue
+			}
+			for _, port := range ports {
+				from, fromPod, to, port := from, fromPod, to, port
+				addr := fmt.Sprintf("%s:%d", toPod.Status.PodIP, port)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					expected := s.Allowed(from, to, port)
+					connected, err := r.prober.Probe(ctx, fromPod, addr)
+					if err == nil && connected == expected {
+						return
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					failures = append(failures, ProbeFailure{From: from.String(), To: to.String(), Port: port, Expected: expected, Err: err})
+				}()
+			}
+		}
+	}
+	wg.Wait()
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].String() < failures[j].String()
+	})
+	return failures
+}
+
+// dump writes the dataplane of the nodes of the probe pods to DumpDir/<scenario>/<node>-<part>.txt and returns the
+// files written.
+func (r *runner) dump(ctx context.Context, scenario string) []string {
+	dir := filepath.Join(r.cfg.DumpDir, scenario)
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gomnd // readable dumps
+		klog.Errorf("[conformance] failed to create dump dir %s: %v", dir, err)
+		return nil
+	}
+
+	nodes := map[string]struct{}{}
+	for _, pod := range r.pods {
+		nodes[pod.Spec.NodeName] = struct{}{}
+	}
+	var files []string
+	for node := range nodes {
+		parts, err := r.prober.Dump(ctx, node)
+		if err != nil {
+			klog.Errorf("[conformance] failed to dump dataplane of node %s: %v", node, err)
+			continue
+		}
+		for part, content := range parts {
+			file := filepath.Join(dir, fmt.Sprintf("%s-%s.txt", node, part))
+			if err := os.WriteFile(file, []byte(content), 0o644); err != nil { //nolint:gomnd // readable dumps
+				klog.Errorf("[conformance] failed to write dump %s: %v", file, err)
+				continue
+			}
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,122 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeProber connects the probe pods unless blocked returns true.
+type fakeProber struct {
+	sync.Mutex
+	namespaceByIP map[string]string
+	blocked       func(fromNamespace, toNamespace string) bool
+	dumped        []string
+}
+
+func (p *fakeProber) Probe(_ context.Context, from *corev1.Pod, addr string) (bool, error) {
+	p.Lock()
+	defer p.Unlock()
+	ip := strings.Split(addr, ":")[0]
+	return !p.blocked(from.Namespace, p.namespaceByIP[ip]), nil
+}
+
+func (p *fakeProber) Dump(_ context.Context, nodeName string) (map[string]string, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.dumped = append(p.dumped, nodeName)
+	return map[string]string{"iptables": "*filter"}, nil
+}
+
+// newFakeCluster creates a clientset running the pods on node-1 as soon as they are created.
+func newFakeCluster(prober *fakeProber) *k8sfake.Clientset {
+	clientset := k8sfake.NewSimpleClientset()
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		prober.Lock()
+		defer prober.Unlock()
+		pod.Spec.NodeName = "node-1"
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.PodIP = fmt.Sprintf("10.0.0.%d", len(prober.namespaceByIP)+1)
+		prober.namespaceByIP[pod.Status.PodIP] = pod.Namespace
+		return false, nil, nil
+	})
+	return clientset
+}
+
+func testConfig(scenarios ...string) Config {
+	cfg := DefaultConfig
+	cfg.Scenarios = scenarios
+	cfg.ReadyTimeout = time.Second
+	cfg.ScenarioTimeout = 10 * time.Millisecond
+	return cfg
+}
+
+func TestRunPasses(t *testing.T) {
+	prober := &fakeProber{
+		namespaceByIP: map[string]string{},
+		blocked: func(_, toNamespace string) bool {
+			return toNamespace == DefaultConfig.NamespacePrefix+nsX
+		},
+	}
+	clientset := newFakeCluster(prober)
+
+	report, err := Run(context.Background(), testConfig("default-deny-ingress"), clientset, prober)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.True(t, report.Results[0].Passed(), report.String())
+	assert.Equal(t, 0, report.Failed())
+	assert.Empty(t, prober.dumped)
+
+	// the namespaces and policies of the run are deleted
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, namespaces.Items)
+}
+
+func TestRunFailureDumpsDataplane(t *testing.T) {
+	prober := &fakeProber{
+		namespaceByIP: map[string]string{},
+		blocked:       func(string, string) bool { return false },
+	}
+	clientset := newFakeCluster(prober)
+	cfg := testConfig("default-deny-ingress", "default-deny-egress")
+	cfg.DumpDir = t.TempDir()
+
+	report, err := Run(context.Background(), cfg, clientset, prober)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, 2, report.Failed())
+
+	res := report.Results[0]
+	assert.Equal(t, "default-deny-ingress", res.Name)
+	// every probe pod connects to the 2 pods of x on 2 ports, except to itself
+	assert.Len(t, res.Failures, 12)
+	for _, f := range res.Failures {
+		assert.False(t, f.Expected)
+		assert.True(t, strings.HasPrefix(f.To, nsX+"/"), f.To)
+	}
+	require.Len(t, res.Dumps, 1)
+	b, err := os.ReadFile(res.Dumps[0])
+	require.NoError(t, err)
+	assert.Equal(t, "*filter", string(b))
+	assert.Contains(t, report.String(), "FAIL default-deny-ingress")
+}
+
+func TestRunUnknownScenario(t *testing.T) {
+	prober := &fakeProber{namespaceByIP: map[string]string{}}
+	_, err := Run(context.Background(), testConfig("nope"), newFakeCluster(prober), prober)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// probeTimeout bounds the connection of a probe, after which the connection is considered blocked.
+const probeTimeout = "1s"
+
+// ErrNoNPMPod is returned by Dump for a node without an NPM pod.
+var ErrNoNPMPod = errors.New("no NPM pod on node")
+
+// DumpCommands are the commands run in the NPM pod of a node to dump its dataplane, by the name of their part.
+var DumpCommands = map[string][]string{
+	"iptables": {"iptables-save"},
+	"ipsets":   {"ipset", "save"},
+}
+
+// ExecProber probes from the probe pods with agnhost connect, and dumps the dataplane of a node from its NPM pod,
+// both through the exec API of the pods.
+type ExecProber struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+	// npmNamespace and npmSelector select the NPM pods.
+	npmNamespace string
+	npmSelector  string
+}
+
+// NewExecProber creates an ExecProber dumping the dataplane from the NPM pods in the namespace with the labels.
+func NewExecProber(config *rest.Config, clientset kubernetes.Interface, npmNamespace, npmSelector string) *ExecProber {
+	return &ExecProber{
+		config:       config,
+		clientset:    clientset,
+		npmNamespace: npmNamespace,
+		npmSelector:  npmSelector,
+	}
+}
+
+func (p *ExecProber) Probe(ctx context.Context, from *corev1.Pod, addr string) (bool, error) {
+	cmd := []string{"/agnhost", "connect", addr, "--timeout=" + probeTimeout, "--protocol=tcp"}
+	_, err := p.exec(ctx, from.Namespace, from.Name, from.Spec.Containers[0].Name, cmd)
+	if err == nil {
+		return true, nil
+	}
+	// agnhost exits with an error when the connection fails
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (p *ExecProber) Dump(ctx context.Context, nodeName string) (map[string]string, error) {
+	pods, err := p.clientset.CoreV1().Pods(p.npmNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: p.npmSelector,
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list NPM pods: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoNPMPod, nodeName)
+	}
+
+	npmPod := &pods.Items[0]
+	parts := map[string]string{}
+	for part, cmd := range DumpCommands {
+		out, err := p.exec(ctx, npmPod.Namespace, npmPod.Name, npmPod.Spec.Containers[0].Name, cmd)
+		if err != nil {
+			// the other parts may still explain the failure
+			out = fmt.Sprintf("%s failed: %v\n%s", strings.Join(cmd, " "), err, out)
+		}
+		parts[part] = out
+	}
+	return parts, nil
+}
+
+// exec runs the command in the container and returns its output.
+func (p *ExecProber) exec(ctx context.Context, namespace, pod, container string, cmd []string) (string, error) {
+	req := p.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(p.config, "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("failed to create executor: %w", err)
+	}
+	var out bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &out, Stderr: &out})
+	if err != nil {
+		return out.String(), fmt.Errorf("failed to exec %v in %s/%s: %w", cmd, namespace, pod, err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package conformance
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The probe pods are pods a and b in namespaces x and y, as in the upstream netpol conformance tests.
+// Their labels and the labels of their namespaces are under a prefix of their own, so that the policies of the
+// scenarios only select them.
+const (
+	nsX = "x"
+	nsY = "y"
+
+	podA = "a"
+	podB = "b"
+
+	podLabel = "conformance.npm.azure.com/pod"
+	nsLabel  = "conformance.npm.azure.com/ns"
+)
+
+// The ports served by every probe pod, by one container each.
+const (
+	port80 int32 = 80
+	port81 int32 = 81
+)
+
+var (
+	namespaces = []string{nsX, nsY}
+	podNames   = []string{podA, podB}
+	ports      = []int32{port80, port81}
+)
+
+// probePod is a probe pod, by the name of its namespace in the scenarios.
+type probePod struct {
+	Namespace string
+	Name      string
+}
+
+func (p probePod) String() string {
+	return p.Namespace + "/" + p.Name
+}
+
+// Scenario is a set of network policies and the connectivity they allow between the probe pods.
+type Scenario struct {
+	Name string
+	// Policies are created before probing, in the namespace of the scenarios they set.
+	Policies []*networkingv1.NetworkPolicy
+	// Allowed returns whether the policies allow a probe pod to connect to a port of another.
+	Allowed func(from, to probePod, port int32) bool
+}
+
+// Scenarios is the curated subset of the upstream netpol conformance scenarios run by Run.
+var Scenarios = []Scenario{
+	{
+		Name:     "default-deny-ingress",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "deny-all", metav1.LabelSelector{})},
+		Allowed: func(_, to probePod, _ int32) bool {
+			return to.Namespace != nsX
+		},
+	},
+	{
+		Name: "allow-ingress-from-same-namespace",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "allow-same-namespace", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}})},
+		Allowed: func(from, to probePod, _ int32) bool {
+			return to.Namespace != nsX || from.Namespace == nsX
+		},
+	},
+	{
+		Name: "allow-ingress-from-namespace",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "allow-from-y", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: nsSelector(nsY)}}})},
+		Allowed: func(from, to probePod, _ int32) bool {
+			return to.Namespace != nsX || from.Namespace == nsY
+		},
+	},
+	{
+		Name: "allow-ingress-from-pod",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "allow-b-to-a", *podSelector(podA),
+			networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{PodSelector: podSelector(podB)}}})},
+		Allowed: func(from, to probePod, _ int32) bool {
+			return to != (probePod{nsX, podA}) || from == (probePod{nsX, podB})
+		},
+	},
+	{
+		Name: "allow-ingress-from-pod-in-namespace",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "allow-y-b-to-a", *podSelector(podA),
+			networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: nsSelector(nsY), PodSelector: podSelector(podB)}}})},
+		Allowed: func(from, to probePod, _ int32) bool {
+			return to != (probePod{nsX, podA}) || from == (probePod{nsY, podB})
+		},
+	},
+	{
+		Name: "allow-ingress-on-port",
+		Policies: []*networkingv1.NetworkPolicy{ingressPolicy(nsX, "allow-81", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(port81)}})},
+		Allowed: func(_, to probePod, port int32) bool {
+			return to.Namespace != nsX || port == port81
+		},
+	},
+	{
+		Name:     "default-deny-egress",
+		Policies: []*networkingv1.NetworkPolicy{egressPolicy(nsX, "deny-all-egress", metav1.LabelSelector{})},
+		Allowed: func(from, _ probePod, _ int32) bool {
+			return from.Namespace != nsX
+		},
+	},
+	{
+		Name: "allow-egress-to-namespace",
+		Policies: []*networkingv1.NetworkPolicy{egressPolicy(nsX, "allow-to-y", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: nsSelector(nsY)}}})},
+		Allowed: func(from, to probePod, _ int32) bool {
+			return from.Namespace != nsX || to.Namespace == nsY
+		},
+	},
+	{
+		Name: "allow-egress-on-port",
+		Policies: []*networkingv1.NetworkPolicy{egressPolicy(nsX, "allow-egress-80", metav1.LabelSelector{},
+			networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{tcpPort(port80)}})},
+		Allowed: func(from, _ probePod, port int32) bool {
+			return from.Namespace != nsX || port == port80
+		},
+	},
+}
+
+func ingressPolicy(namespace, name string, selector metav1.LabelSelector, rules ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: selector,
+			Ingress:     rules,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func egressPolicy(namespace, name string, selector metav1.LabelSelector, rules ...networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: selector,
+			Egress:      rules,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+}
+
+func podSelector(pod string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{podLabel: pod}}
+}
+
+func nsSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{nsLabel: namespace}}
+}
+
+func tcpPort(port int32) networkingv1.NetworkPolicyPort {
+	protocol := corev1.ProtocolTCP
+	p := intstr.FromInt(int(port))
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog"
+)
+
+// Applier runs the workers of a controller, bounding how many work items are processed concurrently
+// and how often. The worker count and rate limit can be changed while the workers are running.
+type Applier struct {
+	sync.Mutex
+	name    string
+	cfg     npmconfig.ApplierConfig
+	limiter flowcontrol.RateLimiter
+	// workerStopChs holds a stop channel per running worker
+	workerStopChs []chan struct{}
+	// processNextWorkItem is set by Run
+	processNextWorkItem func() bool
+	stopCh              <-chan struct{}
+}
+
+// NewApplier creates an Applier named name with the given config.
+func NewApplier(name string, cfg npmconfig.ApplierConfig) *Applier {
+	a := &Applier{name: name}
+	a.cfg, a.limiter = normalizeApplierConfig(cfg)
+	return a
+}
+
+func normalizeApplierConfig(cfg npmconfig.ApplierConfig) (npmconfig.ApplierConfig, flowcontrol.RateLimiter) {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.MaxQPS <= 0 {
+		return cfg, nil
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return cfg, flowcontrol.NewTokenBucketRateLimiter(cfg.MaxQPS, cfg.Burst)
+}
+
+// Run starts the workers, each calling processNextWorkItem in a loop until it returns false or stopCh is closed.
+// Run does not block.
+func (a *Applier) Run(stopCh <-chan struct{}, processNextWorkItem func() bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.stopCh = stopCh
+	a.processNextWorkItem = processNextWorkItem
+	a.scaleWorkers()
+}
+
+// Update changes the worker count and rate limit. Surplus workers exit after finishing their current work item.
+func (a *Applier) Update(cfg npmconfig.ApplierConfig) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.cfg, a.limiter = normalizeApplierConfig(cfg)
+	klog.Infof("[Applier] %s applier updated to %d workers, max qps %v, burst %d", a.name, a.cfg.Workers, a.cfg.MaxQPS, a.cfg.Burst)
+
+	if a.processNextWorkItem != nil {
+		a.scaleWorkers()
+	}
+}
+
+// Config returns the current config.
+func (a *Applier) Config() npmconfig.ApplierConfig {
+	a.Lock()
+	defer a.Unlock()
+	return a.cfg
+}
+
+// scaleWorkers starts or stops workers to match the config. Must be called with the lock held.
+func (a *Applier) scaleWorkers() {
+	for len(a.workerStopChs) < a.cfg.Workers {
+		workerStopCh := make(chan struct{})
+		a.workerStopChs = append(a.workerStopChs, workerStopCh)
+		go wait.Until(func() { a.runWorker(workerStopCh) }, time.Second, mergeStopChs(a.stopCh, workerStopCh))
+	}
+
+	for len(a.workerStopChs) > a.cfg.Workers {
+		last := len(a.workerStopChs) - 1
+		close(a.workerStopChs[last])
+		a.workerStopChs = a.workerStopChs[:last]
+	}
+}
+
+func (a *Applier) runWorker(workerStopCh <-chan struct{}) {
+	for {
+		select {
+		case <-workerStopCh:
+			return
+		default:
+		}
+
+		a.Lock()
+		limiter := a.limiter
+		a.Unlock()
+		if limiter != nil {
+			limiter.Accept()
+		}
+
+		if !a.processNextWorkItem() {
+			return
+		}
+	}
+}
+
+func mergeStopChs(a, b <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWork returns a processNextWorkItem func which blocks until release is closed,
+// and counts the number of concurrent callers.
+func blockingWork(release <-chan struct{}, active *int32) func() bool {
+	return func() bool {
+		atomic.AddInt32(active, 1)
+		defer atomic.AddInt32(active, -1)
+		<-release
+		return true
+	}
+}
+
+func TestApplierScalesWorkers(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	release := make(chan struct{})
+	var active int32
+
+	a := NewApplier("test", npmconfig.ApplierConfig{Workers: 3})
+	a.Run(stopCh, blockingWork(release, &active))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&active) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	a.Update(npmconfig.ApplierConfig{Workers: 5})
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&active) == 5 }, 5*time.Second, 10*time.Millisecond)
+
+	// surplus workers exit after finishing their current item
+	a.Update(npmconfig.ApplierConfig{Workers: 1})
+	close(release)
+	require.Len(t, a.workerStopChs, 1)
+}
+
+func TestApplierNormalizesConfig(t *testing.T) {
+	a := NewApplier("test", npmconfig.ApplierConfig{Workers: -1, MaxQPS: 10})
+	require.Equal(t, npmconfig.ApplierConfig{Workers: 1, MaxQPS: 10, Burst: 1}, a.Config())
+	require.NotNil(t, a.limiter)
+
+	a.Update(npmconfig.ApplierConfig{Workers: 2})
+	require.Equal(t, npmconfig.ApplierConfig{Workers: 2}, a.Config())
+	require.Nil(t, a.limiter)
+}
+
+func TestApplierRateLimits(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	var processed int32
+
+	a := NewApplier("test", npmconfig.ApplierConfig{Workers: 2, MaxQPS: 10, Burst: 1})
+	a.Run(stopCh, func() bool {
+		atomic.AddInt32(&processed, 1)
+		return true
+	})
+
+	time.Sleep(500 * time.Millisecond)
+	// 1 burst + ~5 items at 10 qps across all workers
+	require.LessOrEqual(t, atomic.LoadInt32(&processed), int32(8))
+}
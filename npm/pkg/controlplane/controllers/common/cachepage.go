@@ -0,0 +1,42 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// CachePage is a page of one of the maps of the NPM cache. The debug API serves the cache by pages,
+// so that the cache of nodes with many pods and policies is never encoded at once.
+type CachePage struct {
+	Items map[string]json.RawMessage
+	// Continue is the first key of the next page, empty on the last page.
+	Continue string `json:",omitempty"`
+}
+
+// NewCachePage encodes up to limit entries of m whose key is at or after start, in key order.
+// It must be called with the lock of m held, and only the entries of the page are encoded.
+func NewCachePage[V any](m map[string]V, start string, limit int) (*CachePage, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k >= start {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	page := &CachePage{}
+	if len(keys) > limit {
+		page.Continue = keys[limit]
+		keys = keys[:limit]
+	}
+	page.Items = make(map[string]json.RawMessage, len(keys))
+	for _, k := range keys {
+		b, err := json.Marshal(m[k])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal cache entry %s: %w", k, err)
+		}
+		page.Items[k] = b
+	}
+	return page, nil
+}
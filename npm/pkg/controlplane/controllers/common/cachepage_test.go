@@ -0,0 +1,42 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCachePage(t *testing.T) {
+	m := map[string]*Namespace{
+		"a": {Name: "a"},
+		"b": {Name: "b"},
+		"c": {Name: "c"},
+	}
+
+	page, err := NewCachePage(m, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	require.Contains(t, page.Items, "a")
+	require.Contains(t, page.Items, "b")
+	require.Equal(t, "c", page.Continue)
+
+	page, err = NewCachePage(m, page.Continue, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.Empty(t, page.Continue)
+	ns := &Namespace{}
+	require.NoError(t, json.Unmarshal(page.Items["c"], ns))
+	require.Equal(t, "c", ns.Name)
+
+	// a point lookup is a page of one entry starting at the key
+	page, err = NewCachePage(m, "bb", 1)
+	require.NoError(t, err)
+	require.NotContains(t, page.Items, "bb")
+	require.Contains(t, page.Items, "c")
+
+	page, err = NewCachePage[string](nil, "", 1)
+	require.NoError(t, err)
+	require.Empty(t, page.Items)
+	require.Empty(t, page.Continue)
+}
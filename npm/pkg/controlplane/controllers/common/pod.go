@@ -14,6 +14,10 @@ type NpmPod struct {
 	Labels         map[string]string
 	ContainerPorts []corev1.ContainerPort
 	Phase          corev1.PodPhase
+	// HostNetwork pods have their node's IP, which they share with the other host-network pods of the node.
+	HostNetwork bool `json:",omitempty"`
+	// Generation orders the pods assigned the same IP, see PodGeneration.
+	Generation int64 `json:",omitempty"`
 }
 
 type LabelAppendOperation bool
@@ -39,9 +43,20 @@ func NewNpmPod(podObj *corev1.Pod) *NpmPod {
 		Labels:         make(map[string]string),
 		ContainerPorts: []corev1.ContainerPort{},
 		Phase:          podObj.Status.Phase,
+		HostNetwork:    podObj.Spec.HostNetwork,
+		Generation:     PodGeneration(podObj),
 	}
 }
 
+// PodGeneration returns the creation time of the pod in nanoseconds, which orders the pods assigned the same IP:
+// a pod is created after the pods whose IP it reuses. Zero if the creation time isn't set.
+func PodGeneration(podObj *corev1.Pod) int64 {
+	if podObj.CreationTimestamp.IsZero() {
+		return 0
+	}
+	return podObj.CreationTimestamp.UnixNano()
+}
+
 func (n *NpmPod) AppendLabels(newPod map[string]string, clear LabelAppendOperation) {
 	if clear {
 		n.Labels = make(map[string]string)
@@ -68,6 +83,7 @@ func (n *NpmPod) UpdateNpmPodAttributes(podObj *corev1.Pod) {
 	if n.Phase != podObj.Status.Phase {
 		n.Phase = podObj.Status.Phase
 	}
+	n.Generation = PodGeneration(podObj)
 }
 
 // noUpdate evaluates whether NpmPod is required to be update given podObj.
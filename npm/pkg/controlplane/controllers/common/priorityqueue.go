@@ -0,0 +1,396 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Priority is the priority of a work item in a PriorityQueue.
+type Priority int
+
+const (
+	// PriorityNormal is the priority of work items queued with Add.
+	PriorityNormal Priority = iota
+	// PriorityHigh work items are handed out before PriorityNormal ones, e.g. deletes of network policies, which
+	// would keep enforcing rules which no longer exist the longer they wait.
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+
+	// yieldPollInterval is how often a queue yielding to another checks whether the other has high priority work.
+	yieldPollInterval = 10 * time.Millisecond
+	// unfinishedWorkUpdatePeriod is how often the unfinished work metrics of a named queue are updated, as in client-go.
+	unfinishedWorkUpdatePeriod = 500 * time.Millisecond
+)
+
+var (
+	metricsProviderOnce sync.Once
+	// metricsProvider provides the metrics of the named PriorityQueues, none if nil
+	metricsProvider workqueue.MetricsProvider
+)
+
+// SetMetricsProvider sets the provider of the metrics of the work queues created afterwards with a name: the named
+// PriorityQueues and the client-go named work queues. Only the first call has an effect.
+func SetMetricsProvider(provider workqueue.MetricsProvider) {
+	metricsProviderOnce.Do(func() {
+		metricsProvider = provider
+		workqueue.SetProvider(provider)
+	})
+}
+
+// PriorityQueue is a work queue of keys which hands out high priority keys first. Like the client-go work queue:
+//   - a key queued several times before a worker gets it is handed out once, so rapid updates of the same object
+//     are coalesced
+//   - a key is never handed to two workers at once. A key queued while being processed is handed out again once
+//     it's Done
+//   - AddRateLimited requeues a key with exponential backoff per key, until the key is Forgotten
+//
+// A key queued at several priorities before a worker gets it is handed out at the highest one, and a key requeued
+// with AddRateLimited or AddAfter keeps the priority it was handed out at.
+// PriorityQueue implements workqueue.RateLimitingInterface, where Add queues a key at PriorityNormal.
+type PriorityQueue struct {
+	cond *sync.Cond
+	// queues are the FIFOs of the keys waiting for a worker, per priority
+	queues [numPriorities][]interface{}
+	// dirty is the priority of the keys which need processing, including the ones being processed which were
+	// queued again
+	dirty map[interface{}]Priority
+	// processing is the priority of the keys handed out to workers which aren't Done yet
+	processing map[interface{}]Priority
+	// requeuePriority is the priority a key was lastly handed out at, until it's Forgotten
+	requeuePriority map[interface{}]Priority
+	rateLimiter     workqueue.RateLimiter
+	shuttingDown    bool
+	// yieldTo is the queue whose high priority work is waited on before handing out PriorityNormal keys, for up
+	// to maxYield per key. Nothing is waited on if nil.
+	yieldTo  *PriorityQueue
+	maxYield time.Duration
+	// metrics are the metrics of a named queue, nil if the queue has no name
+	metrics *queueMetrics
+}
+
+var _ workqueue.RateLimitingInterface = &PriorityQueue{}
+
+// NewPriorityQueue creates a PriorityQueue which backs off failed keys according to rateLimiter.
+func NewPriorityQueue(rateLimiter workqueue.RateLimiter) *PriorityQueue {
+	return &PriorityQueue{
+		cond:            sync.NewCond(&sync.Mutex{}),
+		dirty:           make(map[interface{}]Priority),
+		processing:      make(map[interface{}]Priority),
+		requeuePriority: make(map[interface{}]Priority),
+		rateLimiter:     rateLimiter,
+	}
+}
+
+// NewNamedPriorityQueue creates a PriorityQueue like NewPriorityQueue, whose metrics are labeled with name like the
+// ones of client-go named work queues, if SetMetricsProvider was called.
+func NewNamedPriorityQueue(rateLimiter workqueue.RateLimiter, name string) *PriorityQueue {
+	return newPriorityQueueWithMetrics(rateLimiter, name, metricsProvider)
+}
+
+func newPriorityQueueWithMetrics(rateLimiter workqueue.RateLimiter, name string, provider workqueue.MetricsProvider) *PriorityQueue {
+	q := NewPriorityQueue(rateLimiter)
+	if name == "" || provider == nil {
+		return q
+	}
+	q.metrics = &queueMetrics{
+		depth:                   provider.NewDepthMetric(name),
+		adds:                    provider.NewAddsMetric(name),
+		latency:                 provider.NewLatencyMetric(name),
+		workDuration:            provider.NewWorkDurationMetric(name),
+		unfinishedWorkSeconds:   provider.NewUnfinishedWorkSecondsMetric(name),
+		longestRunningProcessor: provider.NewLongestRunningProcessorSecondsMetric(name),
+		retries:                 provider.NewRetriesMetric(name),
+		addTimes:                make(map[interface{}]time.Time),
+		processingStartTimes:    make(map[interface{}]time.Time),
+	}
+	go q.updateUnfinishedWorkLoop()
+	return q
+}
+
+// YieldTo makes the workers of q wait for the PriorityHigh keys queued in or processed by other before they're handed
+// PriorityNormal keys of q, for at most maxYield per key so that q isn't starved by a key of other which keeps failing.
+// It must be called before the workers are started.
+func (q *PriorityQueue) YieldTo(other *PriorityQueue, maxYield time.Duration) {
+	q.yieldTo = other
+	q.maxYield = maxYield
+}
+
+// Add queues item at PriorityNormal.
+func (q *PriorityQueue) Add(item interface{}) {
+	q.AddWithPriority(item, PriorityNormal)
+}
+
+// AddWithPriority queues item at priority, or raises its priority if it's already queued at a lower one.
+func (q *PriorityQueue) AddWithPriority(item interface{}, priority Priority) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+
+	queued, ok := q.dirty[item]
+	if ok && queued >= priority {
+		return
+	}
+	if !ok {
+		q.metrics.add(item)
+	}
+	q.dirty[item] = priority
+	if _, ok := q.processing[item]; ok {
+		// queued again once Done
+		return
+	}
+	if ok {
+		q.queues[queued] = removeItem(q.queues[queued], item)
+	}
+	q.queues[priority] = append(q.queues[priority], item)
+	q.cond.Signal()
+}
+
+func removeItem(items []interface{}, item interface{}) []interface{} {
+	for i := range items {
+		if items[i] == item {
+			return append(items[:i], items[i+1:]...)
+		}
+	}
+	return items
+}
+
+// Len returns the number of keys waiting for a worker.
+func (q *PriorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	n := 0
+	for i := range q.queues {
+		n += len(q.queues[i])
+	}
+	return n
+}
+
+// pendingHigh returns whether PriorityHigh keys are waiting for a worker or being processed.
+func (q *PriorityQueue) pendingHigh() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if len(q.queues[PriorityHigh]) > 0 {
+		return true
+	}
+	for _, priority := range q.processing {
+		if priority == PriorityHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// Get blocks until a key is queued, and returns the oldest key of the highest priority. shutdown is true once the
+// queue is shut down and empty.
+func (q *PriorityQueue) Get() (item interface{}, shutdown bool) {
+	var yieldDeadline time.Time
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for {
+		for q.empty() && !q.shuttingDown {
+			q.cond.Wait()
+		}
+		if q.empty() {
+			return nil, true
+		}
+
+		priority := Priority(numPriorities - 1)
+		for len(q.queues[priority]) == 0 {
+			priority--
+		}
+		if priority == PriorityNormal && q.yieldTo != nil && !q.shuttingDown {
+			if yieldDeadline.IsZero() {
+				yieldDeadline = time.Now().Add(q.maxYield)
+			}
+			// takes the lock of the other queue, which mustn't yield to q in turn
+			if time.Now().Before(yieldDeadline) && q.yieldTo.pendingHigh() {
+				q.cond.L.Unlock()
+				time.Sleep(yieldPollInterval)
+				q.cond.L.Lock()
+				continue
+			}
+		}
+
+		item = q.queues[priority][0]
+		q.queues[priority][0] = nil
+		q.queues[priority] = q.queues[priority][1:]
+		delete(q.dirty, item)
+		q.processing[item] = priority
+		q.requeuePriority[item] = priority
+		q.metrics.get(item)
+		return item, false
+	}
+}
+
+func (q *PriorityQueue) empty() bool {
+	for i := range q.queues {
+		if len(q.queues[i]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Done marks item as processed, and queues it again if it was added while being processed.
+func (q *PriorityQueue) Done(item interface{}) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.metrics.done(item)
+	delete(q.processing, item)
+	if priority, ok := q.dirty[item]; ok {
+		q.queues[priority] = append(q.queues[priority], item)
+		q.cond.Signal()
+	} else if len(q.processing) == 0 {
+		// wakes up ShutDownWithDrain
+		q.cond.Broadcast()
+	}
+}
+
+// ShutDown makes the queue ignore new keys, and makes the workers exit once they've processed the queued keys.
+func (q *PriorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+// ShutDownWithDrain shuts the queue down, and blocks until all keys handed out are Done.
+func (q *PriorityQueue) ShutDownWithDrain() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.shuttingDown = true
+	q.cond.Broadcast()
+	for len(q.processing) > 0 {
+		q.cond.Wait()
+	}
+}
+
+// ShuttingDown returns whether the queue is shut down.
+func (q *PriorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+// AddAfter queues item after duration, at the priority it was lastly handed out at.
+func (q *PriorityQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.cond.L.Lock()
+	priority := q.requeuePriority[item]
+	q.metrics.retry()
+	q.cond.L.Unlock()
+	if duration <= 0 {
+		q.AddWithPriority(item, priority)
+		return
+	}
+	time.AfterFunc(duration, func() { q.AddWithPriority(item, priority) })
+}
+
+// AddRateLimited queues item once the rate limiter allows it, at the priority it was lastly handed out at.
+func (q *PriorityQueue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+// Forget stops backing off item, and forgets the priority it was lastly handed out at.
+func (q *PriorityQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	delete(q.requeuePriority, item)
+}
+
+// NumRequeues returns how many times item was requeued with AddRateLimited since it was lastly Forgotten.
+func (q *PriorityQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+// updateUnfinishedWorkLoop updates the unfinished work metrics periodically until the queue is shut down.
+func (q *PriorityQueue) updateUnfinishedWorkLoop() {
+	ticker := time.NewTicker(unfinishedWorkUpdatePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.cond.L.Lock()
+		if q.shuttingDown {
+			q.cond.L.Unlock()
+			return
+		}
+		q.metrics.updateUnfinishedWork()
+		q.cond.L.Unlock()
+	}
+}
+
+// queueMetrics are the metrics of a named PriorityQueue, the same as the ones of a client-go work queue. They're
+// updated under the lock of the queue, and nil queueMetrics update nothing.
+type queueMetrics struct {
+	depth                   workqueue.GaugeMetric
+	adds                    workqueue.CounterMetric
+	latency                 workqueue.HistogramMetric
+	workDuration            workqueue.HistogramMetric
+	unfinishedWorkSeconds   workqueue.SettableGaugeMetric
+	longestRunningProcessor workqueue.SettableGaugeMetric
+	retries                 workqueue.CounterMetric
+	// addTimes are the times the keys waiting for a worker were queued
+	addTimes map[interface{}]time.Time
+	// processingStartTimes are the times the keys being processed were handed out
+	processingStartTimes map[interface{}]time.Time
+}
+
+func (m *queueMetrics) add(item interface{}) {
+	if m == nil {
+		return
+	}
+	m.adds.Inc()
+	m.depth.Inc()
+	if _, ok := m.addTimes[item]; !ok {
+		m.addTimes[item] = time.Now()
+	}
+}
+
+func (m *queueMetrics) get(item interface{}) {
+	if m == nil {
+		return
+	}
+	m.depth.Dec()
+	m.processingStartTimes[item] = time.Now()
+	if addTime, ok := m.addTimes[item]; ok {
+		m.latency.Observe(time.Since(addTime).Seconds())
+		delete(m.addTimes, item)
+	}
+}
+
+func (m *queueMetrics) done(item interface{}) {
+	if m == nil {
+		return
+	}
+	if startTime, ok := m.processingStartTimes[item]; ok {
+		m.workDuration.Observe(time.Since(startTime).Seconds())
+		delete(m.processingStartTimes, item)
+	}
+}
+
+func (m *queueMetrics) retry() {
+	if m == nil {
+		return
+	}
+	m.retries.Inc()
+}
+
+func (m *queueMetrics) updateUnfinishedWork() {
+	if m == nil {
+		return
+	}
+	var total, oldest float64
+	for _, startTime := range m.processingStartTimes {
+		age := time.Since(startTime).Seconds()
+		total += age
+		if age > oldest {
+			oldest = age
+		}
+	}
+	m.unfinishedWorkSeconds.Set(total)
+	m.longestRunningProcessor.Set(oldest)
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func get(t *testing.T, q *PriorityQueue) interface{} {
+	t.Helper()
+	item, shutdown := q.Get()
+	require.False(t, shutdown)
+	return item
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	q := NewPriorityQueue(workqueue.DefaultControllerRateLimiter())
+	q.Add("a")
+	q.Add("b")
+	q.AddWithPriority("c", PriorityHigh)
+	// coalesced with the queued key
+	q.Add("a")
+	// raised to the high priority
+	q.AddWithPriority("b", PriorityHigh)
+	require.Equal(t, 3, q.Len())
+
+	require.Equal(t, "c", get(t, q))
+	require.Equal(t, "b", get(t, q))
+	require.Equal(t, "a", get(t, q))
+	require.Zero(t, q.Len())
+
+	q.ShutDown()
+	_, shutdown := q.Get()
+	require.True(t, shutdown)
+}
+
+func TestPriorityQueueRequeue(t *testing.T) {
+	q := NewPriorityQueue(workqueue.NewItemExponentialFailureRateLimiter(0, 0))
+	q.AddWithPriority("a", PriorityHigh)
+	require.Equal(t, "a", get(t, q))
+
+	// a key queued while being processed is handed out once it's done
+	q.Add("a")
+	require.Zero(t, q.Len())
+	q.Done("a")
+	require.Equal(t, 1, q.Len())
+	require.Equal(t, "a", get(t, q))
+
+	// a failed key is retried at the priority it was handed out at
+	q.Done("a")
+	q.AddWithPriority("a", PriorityHigh)
+	require.Equal(t, "a", get(t, q))
+	q.Add("b")
+	q.AddRateLimited("a")
+	q.Done("a")
+	require.Equal(t, 1, q.NumRequeues("a"))
+	require.Equal(t, "a", get(t, q))
+	require.Equal(t, "b", get(t, q))
+
+	// once forgotten, the key isn't backed off nor retried at its priority
+	q.Forget("a")
+	require.Zero(t, q.NumRequeues("a"))
+	q.Add("c")
+	q.AddRateLimited("a")
+	q.Done("a")
+	require.Equal(t, "c", get(t, q))
+	require.Equal(t, "a", get(t, q))
+}
+
+func TestPriorityQueueYieldTo(t *testing.T) {
+	pods := NewPriorityQueue(workqueue.DefaultControllerRateLimiter())
+	policies := NewPriorityQueue(workqueue.DefaultControllerRateLimiter())
+	pods.YieldTo(policies, time.Hour)
+
+	policies.AddWithPriority("deleted", PriorityHigh)
+	pods.Add("pod")
+	got := make(chan interface{})
+	go func() {
+		item, _ := pods.Get()
+		got <- item
+	}()
+
+	// the pod waits until the delete is applied
+	require.Equal(t, "deleted", get(t, policies))
+	select {
+	case <-got:
+		require.FailNow(t, "pod handed out before the policy delete was done")
+	case <-time.After(5 * yieldPollInterval):
+	}
+	policies.Done("deleted")
+	require.Equal(t, "pod", <-got)
+
+	// normal priority keys of the other queue aren't waited on
+	policies.Add("updated")
+	pods.Add("other pod")
+	require.Equal(t, "other pod", get(t, pods))
+}
+
+func TestPriorityQueueYieldToIsBounded(t *testing.T) {
+	pods := NewPriorityQueue(workqueue.DefaultControllerRateLimiter())
+	policies := NewPriorityQueue(workqueue.DefaultControllerRateLimiter())
+	pods.YieldTo(policies, 5*yieldPollInterval)
+
+	policies.AddWithPriority("deleted", PriorityHigh)
+	pods.Add("pod")
+	require.Equal(t, "pod", get(t, pods))
+}
+
+func TestNamedPriorityQueueMetrics(t *testing.T) {
+	metrics.InitializeAll()
+	q := newPriorityQueueWithMetrics(workqueue.NewItemExponentialFailureRateLimiter(0, 0), "TestQueue",
+		metrics.WorkqueueMetricsProvider())
+	defer q.ShutDown()
+
+	q.Add("a")
+	q.AddWithPriority("b", PriorityHigh)
+	// coalesced keys aren't counted again
+	q.Add("a")
+	q.AddWithPriority("a", PriorityHigh)
+	requireWorkqueueMetrics(t, "TestQueue", 2, 2, 0)
+
+	require.Equal(t, "b", get(t, q))
+	requireWorkqueueMetrics(t, "TestQueue", 2, 1, 0)
+	q.AddRateLimited("b")
+	q.Done("b")
+	requireWorkqueueMetrics(t, "TestQueue", 3, 2, 1)
+}
+
+func requireWorkqueueMetrics(t *testing.T, name string, adds, depth, retries int) {
+	t.Helper()
+	val, err := metrics.GetWorkqueueAddCount(name)
+	require.NoError(t, err)
+	require.Equal(t, adds, val, "adds")
+	val, err = metrics.GetWorkqueueDepth(name)
+	require.NoError(t, err)
+	require.Equal(t, depth, val, "depth")
+	val, err = metrics.GetWorkqueueRetryCount(name)
+	require.NoError(t, err)
+	require.Equal(t, retries, val, "retries")
+}
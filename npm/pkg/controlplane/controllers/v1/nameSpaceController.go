@@ -50,6 +50,13 @@ func (n *NpmNamespaceCache) MarshalJSON() ([]byte, error) {
 	return nsMapRaw, nil
 }
 
+// NsMapPage returns a page of the namespace map for the debug API, see common.NewCachePage.
+func (n *NpmNamespaceCache) NsMapPage(start string, limit int) (*common.CachePage, error) {
+	n.RLock()
+	defer n.RUnlock()
+	return common.NewCachePage(n.NsMap, start, limit)
+}
+
 type NamespaceController struct {
 	nameSpaceLister   corelisters.NamespaceLister
 	workqueue         workqueue.RateLimitingInterface
@@ -74,6 +74,13 @@ func (c *PodController) MarshalJSON() ([]byte, error) {
 	return podMapRaw, nil
 }
 
+// PodMapPage returns a page of the pod map for the debug API, see common.NewCachePage.
+func (c *PodController) PodMapPage(start string, limit int) (*common.CachePage, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return common.NewCachePage(c.podMap, start, limit)
+}
+
 func (c *PodController) LengthOfPodMap() int {
 	return len(c.podMap)
 }
@@ -0,0 +1,249 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/azure-container-networking/crd/addressgroup/api/v1alpha1"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// AddressGroupResource is the resource of the AddressGroup CRD watched by the AddressGroupController.
+var AddressGroupResource = v1alpha1.GroupVersion.WithResource("addressgroups")
+
+var errAddressGroupFormat = errors.New("invalid address group object")
+
+// AddressGroupController keeps the shared CIDR ipset of each AddressGroup in sync with its CIDRs.
+// Network policies reference the ipset through the translation.AddressGroupsAnnotation, so updating
+// the CIDRs of a group only updates one ipset instead of re-translating every policy.
+type AddressGroupController struct {
+	sync.RWMutex
+	addressGroupLister cache.GenericLister
+	workqueue          workqueue.RateLimitingInterface
+	// groupMembers is the lastly applied ipset members of each AddressGroup. Key is the group name.
+	groupMembers map[string][]string
+	dp           dataplane.GenericDataplane
+	// inFlight is the number of work items currently being processed
+	inFlight int32
+	applier  *common.Applier
+}
+
+// NewAddressGroupController creates an AddressGroupController from an informer of AddressGroupResource,
+// e.g. created by a dynamic informer factory.
+func NewAddressGroupController(addressGroupInformer informers.GenericInformer, dp dataplane.GenericDataplane) *AddressGroupController {
+	addressGroupController := &AddressGroupController{
+		addressGroupLister: addressGroupInformer.Lister(),
+		workqueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "AddressGroups"),
+		groupMembers:       make(map[string][]string),
+		dp:                 dp,
+		applier:            common.NewApplier("AddressGroups", npmconfig.ApplierConfig{}),
+	}
+
+	addressGroupInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    addressGroupController.enqueueAddressGroup,
+			UpdateFunc: func(_, obj interface{}) { addressGroupController.enqueueAddressGroup(obj) },
+			DeleteFunc: addressGroupController.enqueueAddressGroup,
+		},
+	)
+	return addressGroupController
+}
+
+// GetCache returns the lastly applied ipset members of each AddressGroup.
+func (c *AddressGroupController) GetCache() map[string][]string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.groupMembers
+}
+
+func (c *AddressGroupController) enqueueAddressGroup(obj interface{}) {
+	// DeletionHandlingMetaNamespaceKeyFunc also handles objects of type DeletedFinalStateUnknown
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+func (c *AddressGroupController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Infof("Starting AddressGroup worker")
+	c.applier.Run(stopCh, c.processNextWorkItem)
+
+	klog.Infof("Started AddressGroup worker")
+	<-stopCh
+	klog.Info("Shutting down AddressGroup workers")
+}
+
+// IsIdle returns true if the controller has no queued or in-flight work items.
+// Items waiting on a rate limited requeue are not counted.
+func (c *AddressGroupController) IsIdle() bool {
+	return c.workqueue.Len() == 0 && atomic.LoadInt32(&c.inFlight) == 0
+}
+
+// Applier returns the applier running the workers of the controller, which can be used to change
+// the worker count and rate limit at runtime.
+func (c *AddressGroupController) Applier() *common.Applier {
+	return c.applier
+}
+
+func (c *AddressGroupController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+
+	if shutdown {
+		return false
+	}
+	atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+
+	err := func(obj interface{}) error {
+		defer c.workqueue.Done(obj)
+		key, ok := obj.(string)
+		if !ok {
+			c.workqueue.Forget(obj)
+			utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v, err %w", obj, errWorkqueueFormatting))
+			return nil
+		}
+		if err := c.syncAddressGroup(key); err != nil {
+			// Put the item back on the workqueue to handle any transient errors.
+			c.workqueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing address group '%s': %w, requeuing", key, err)
+		}
+		c.workqueue.Forget(obj)
+		klog.Infof("Successfully synced address group '%s'", key)
+		return nil
+	}(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		metrics.SendErrorLogAndMetric(util.NetpolID, "syncAddressGroup error due to %v", err)
+	}
+
+	return true
+}
+
+// syncAddressGroup updates the ipset of the AddressGroup with key to its current CIDRs,
+// and cleans up the ipset of a deleted AddressGroup.
+func (c *AddressGroupController) syncAddressGroup(key string) error {
+	var desired []string
+	obj, err := c.addressGroupLister.Get(key)
+	switch {
+	case k8serrors.IsNotFound(err):
+		klog.Infof("AddressGroup %s is not found, may be it is deleted", key)
+	case err != nil:
+		return fmt.Errorf("failed to get address group: %w", err)
+	default:
+		addressGroup, err := toAddressGroup(obj)
+		if err != nil {
+			// re-queuing will result in the same error
+			utilruntime.HandleError(err)
+			return nil
+		}
+		if addressGroup.DeletionTimestamp == nil {
+			var unsupported []string
+			desired, unsupported = translation.AddressGroupMembers(addressGroup.Spec.CIDRs)
+			if len(unsupported) > 0 {
+				klog.Warningf("AddressGroup %s has unsupported CIDRs which are ignored: %v", key, unsupported)
+			}
+		}
+	}
+
+	c.RLock()
+	applied, exists := c.groupMembers[key]
+	c.RUnlock()
+	if !exists && len(desired) == 0 {
+		return nil
+	}
+
+	if err := c.updateMembers(key, applied, desired); err != nil {
+		return err
+	}
+	if err := c.dp.ApplyDataPlane(); err != nil {
+		return fmt.Errorf("failed to apply dataplane changes while syncing address group: %w", err)
+	}
+	return nil
+}
+
+// updateMembers changes the ipset members of the AddressGroup from applied to desired.
+// The ipset is deleted if there are no desired members, unless network policies still reference it.
+func (c *AddressGroupController) updateMembers(group string, applied, desired []string) error {
+	setMetadata := translation.AddressGroupSetMetadata(group)
+	desiredSet := make(map[string]struct{}, len(desired))
+	for _, member := range desired {
+		desiredSet[member] = struct{}{}
+	}
+
+	kept := make([]string, 0, len(applied))
+	for i, member := range applied {
+		if _, ok := desiredSet[member]; ok {
+			kept = append(kept, member)
+			continue
+		}
+		if err := c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{setMetadata}, dataplane.NewPodMetadata("", member, "")); err != nil {
+			// the members which weren't removed yet are still applied
+			c.setMembers(group, append(kept, applied[i:]...))
+			return fmt.Errorf("failed to remove members of address group: %w", err)
+		}
+	}
+
+	if len(desired) == 0 {
+		c.dp.DeleteIPSet(setMetadata, util.SoftDelete)
+		c.setMembers(group, nil)
+		return nil
+	}
+
+	// record the desired members first: if adding fails, removing members which weren't added yet is a no-op
+	c.setMembers(group, desired)
+	c.dp.CreateIPSets([]*ipsets.IPSetMetadata{setMetadata})
+	for _, member := range desired {
+		if err := c.dp.AddToSets([]*ipsets.IPSetMetadata{setMetadata}, dataplane.NewPodMetadata("", member, "")); err != nil {
+			return fmt.Errorf("failed to add members of address group: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *AddressGroupController) setMembers(group string, members []string) {
+	c.Lock()
+	defer c.Unlock()
+	if members == nil {
+		delete(c.groupMembers, group)
+		return
+	}
+	c.groupMembers[group] = members
+}
+
+func toAddressGroup(obj runtime.Object) (*v1alpha1.AddressGroup, error) {
+	if addressGroup, ok := obj.(*v1alpha1.AddressGroup); ok {
+		return addressGroup, nil
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected type %T", errAddressGroupFormat, obj)
+	}
+	addressGroup := &v1alpha1.AddressGroup{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), addressGroup); err != nil {
+		return nil, fmt.Errorf("%w: %v", errAddressGroupFormat, err)
+	}
+	return addressGroup, nil
+}
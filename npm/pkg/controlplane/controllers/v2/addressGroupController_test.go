@@ -0,0 +1,78 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	"github.com/Azure/azure-container-networking/npm/util"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+)
+
+func newAddressGroupInformer() informers.GenericInformer {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{AddressGroupResource: "AddressGroupList"})
+	return dynamicinformer.NewDynamicSharedInformerFactory(client, 0).ForResource(AddressGroupResource)
+}
+
+func newAddressGroup(name string, cidrs ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "acn.azure.com/v1alpha1",
+		"kind":       "AddressGroup",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"cidrs": cidrs},
+	}}
+}
+
+func TestSyncAddressGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+
+	informer := newAddressGroupInformer()
+	c := NewAddressGroupController(informer, dp)
+	setMetadata := []*ipsets.IPSetMetadata{translation.AddressGroupSetMetadata("corp")}
+	member := func(cidr string) *dataplane.PodMetadata { return dataplane.NewPodMetadata("", cidr, "") }
+
+	// add
+	require.NoError(t, informer.Informer().GetIndexer().Add(newAddressGroup("corp", "10.0.0.0/8", "172.16.0.0/12")))
+	dp.EXPECT().CreateIPSets(setMetadata).Times(1)
+	dp.EXPECT().AddToSets(setMetadata, member("10.0.0.0/8")).Return(nil).Times(1)
+	dp.EXPECT().AddToSets(setMetadata, member("172.16.0.0/12")).Return(nil).Times(1)
+	dp.EXPECT().ApplyDataPlane().Return(nil).Times(1)
+	require.NoError(t, c.syncAddressGroup("corp"))
+	require.Equal(t, map[string][]string{"corp": {"10.0.0.0/8", "172.16.0.0/12"}}, c.GetCache())
+
+	// update only removes the CIDRs which were dropped
+	require.NoError(t, informer.Informer().GetIndexer().Update(newAddressGroup("corp", "10.0.0.0/8", "192.168.0.0/16")))
+	dp.EXPECT().RemoveFromSets(setMetadata, member("172.16.0.0/12")).Return(nil).Times(1)
+	dp.EXPECT().CreateIPSets(setMetadata).Times(1)
+	dp.EXPECT().AddToSets(setMetadata, member("10.0.0.0/8")).Return(nil).Times(1)
+	dp.EXPECT().AddToSets(setMetadata, member("192.168.0.0/16")).Return(nil).Times(1)
+	dp.EXPECT().ApplyDataPlane().Return(nil).Times(1)
+	require.NoError(t, c.syncAddressGroup("corp"))
+	require.Equal(t, map[string][]string{"corp": {"10.0.0.0/8", "192.168.0.0/16"}}, c.GetCache())
+
+	// delete
+	require.NoError(t, informer.Informer().GetIndexer().Delete(newAddressGroup("corp")))
+	dp.EXPECT().RemoveFromSets(setMetadata, member("10.0.0.0/8")).Return(nil).Times(1)
+	dp.EXPECT().RemoveFromSets(setMetadata, member("192.168.0.0/16")).Return(nil).Times(1)
+	dp.EXPECT().DeleteIPSet(setMetadata[0], util.SoftDelete).Times(1)
+	dp.EXPECT().ApplyDataPlane().Return(nil).Times(1)
+	require.NoError(t, c.syncAddressGroup("corp"))
+	require.Empty(t, c.GetCache())
+
+	// deleting an unknown group is a no-op
+	require.NoError(t, c.syncAddressGroup("corp"))
+}
@@ -0,0 +1,21 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+// excludedNamespaces are the namespaces whose pods, namespace and network policies the controllers ignore entirely.
+type excludedNamespaces map[string]struct{}
+
+func newExcludedNamespaces(namespaces []string) excludedNamespaces {
+	excluded := make(excludedNamespaces, len(namespaces))
+	for _, ns := range namespaces {
+		if ns != "" {
+			excluded[ns] = struct{}{}
+		}
+	}
+	return excluded
+}
+
+func (e excludedNamespaces) has(namespace string) bool {
+	_, ok := e[namespace]
+	return ok
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestExcludedNamespaces(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// the dataplane mock fails the test if the events of the excluded namespace reach it
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	podFixture := newFixture(t, dp)
+	podFixture.newPodController(stopCh)
+	podFixture.podController.SetExcludedNamespaces([]string{"kube-system"})
+	nsFixture := newNsFixture(t, dp)
+	nsFixture.newNsController(stopCh)
+	nsFixture.nsController.SetExcludedNamespaces([]string{"kube-system"})
+	netPolFixture := newNetPolFixture(t)
+	netPolFixture.newNetPolController(stopCh, dp)
+	netPolFixture.netPolController.SetExcludedNamespaces([]string{"kube-system"})
+
+	podObj := createPod("coredns", "kube-system", "0", "1.2.3.4", map[string]string{"k8s-app": "kube-dns"}, NonHostNetwork, corev1.PodRunning)
+	podFixture.podController.addPod(podObj)
+	podFixture.podController.deletePod(cache.DeletedFinalStateUnknown{Key: "kube-system/coredns", Obj: podObj})
+	require.Zero(t, podFixture.podController.workqueue.Len())
+
+	nsObj := newNameSpace("kube-system", "0", map[string]string{})
+	nsFixture.nsController.addNamespace(nsObj)
+	nsFixture.nsController.deleteNamespace(nsObj)
+	require.Zero(t, nsFixture.nsController.workqueue.Len())
+
+	netPolObj := createNetPol()
+	netPolObj.Namespace = "kube-system"
+	netPolFixture.netPolController.addNetworkPolicy(netPolObj)
+	netPolFixture.netPolController.deleteNetworkPolicy(netPolObj)
+	require.Zero(t, netPolFixture.netPolController.workqueue.Len())
+
+	skippedPods, err := metrics.GetExcludedNamespaceEventCount(metrics.ExcludedPod)
+	require.NoError(t, err)
+	require.Equal(t, 2, skippedPods)
+	skippedPolicies, err := metrics.GetExcludedNamespaceEventCount(metrics.ExcludedNetworkPolicy)
+	require.NoError(t, err)
+	require.Equal(t, 2, skippedPolicies)
+
+	// the events of other namespaces are still handled
+	podFixture.podController.addPod(createPod("test-pod", "test-namespace", "0", "1.2.3.4", map[string]string{}, NonHostNetwork, corev1.PodRunning))
+	require.Equal(t, 1, podFixture.podController.workqueue.Len())
+	netPolFixture.netPolController.addNetworkPolicy(createNetPol())
+	require.Equal(t, 1, netPolFixture.netPolController.workqueue.Len())
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// hostNetworkPodsSelectedBy returns the keys of the host-network pods which the network policy selects,
+// either as its target or as a peer of its rules, in sorted order.
+func hostNetworkPodsSelectedBy(netPol *networkingv1.NetworkPolicy, podLister corelisters.PodLister,
+	nsLister corelisters.NamespaceLister,
+) ([]string, error) {
+	podKeys := make(map[string]struct{})
+
+	if err := addSelectedHostNetworkPods(podKeys, podLister, netPol.Namespace, &netPol.Spec.PodSelector); err != nil {
+		return nil, err
+	}
+
+	peers := make([]networkingv1.NetworkPolicyPeer, 0)
+	for i := range netPol.Spec.Ingress {
+		peers = append(peers, netPol.Spec.Ingress[i].From...)
+	}
+	for i := range netPol.Spec.Egress {
+		peers = append(peers, netPol.Spec.Egress[i].To...)
+	}
+	for i := range peers {
+		peer := &peers[i]
+		if peer.PodSelector == nil && peer.NamespaceSelector == nil {
+			// ipBlock peer
+			continue
+		}
+
+		namespaces := []string{netPol.Namespace}
+		if peer.NamespaceSelector != nil {
+			nsSelector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse namespace selector: %w", err)
+			}
+			nsList, err := nsLister.List(nsSelector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list namespaces: %w", err)
+			}
+			namespaces = make([]string, 0, len(nsList))
+			for _, ns := range nsList {
+				namespaces = append(namespaces, ns.Name)
+			}
+		}
+
+		podSelector := peer.PodSelector
+		if podSelector == nil {
+			podSelector = &metav1.LabelSelector{}
+		}
+		for _, ns := range namespaces {
+			if err := addSelectedHostNetworkPods(podKeys, podLister, ns, podSelector); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]string, 0, len(podKeys))
+	for podKey := range podKeys {
+		result = append(result, podKey)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func addSelectedHostNetworkPods(podKeys map[string]struct{}, podLister corelisters.PodLister, namespace string,
+	podSelector *metav1.LabelSelector,
+) error {
+	selector, err := metav1.LabelSelectorAsSelector(podSelector)
+	if err != nil {
+		return fmt.Errorf("failed to parse pod selector: %w", err)
+	}
+	pods, err := podLister.Pods(namespace).List(selector)
+	if err != nil {
+		return fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+	}
+	for _, pod := range pods {
+		if !isHostNetworkPod(pod) || isCompletePod(pod) {
+			continue
+		}
+		podKey, err := cache.MetaNamespaceKeyFunc(pod)
+		if err != nil {
+			continue
+		}
+		podKeys[podKey] = struct{}{}
+	}
+	return nil
+}
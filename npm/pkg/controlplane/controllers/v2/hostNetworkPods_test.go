@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHostNetworkPodsSelectedBy(t *testing.T) {
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range []*corev1.Pod{
+		createPod("exporter", "monitoring", "0", "10.0.0.4", map[string]string{"app": "exporter"}, HostNetwork, corev1.PodRunning),
+		createPod("web", "test-ns", "0", "10.0.0.5", map[string]string{"app": "web"}, HostNetwork, corev1.PodRunning),
+		createPod("web-pod", "test-ns", "0", "10.240.0.5", map[string]string{"app": "web"}, NonHostNetwork, corev1.PodRunning),
+		createPod("web-done", "test-ns", "0", "10.0.0.5", map[string]string{"app": "web"}, HostNetwork, corev1.PodSucceeded),
+	} {
+		require.NoError(t, podIndexer.Add(pod))
+	}
+	nsIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range []*corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "ops"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+	} {
+		require.NoError(t, nsIndexer.Add(ns))
+	}
+	podLister := corelisters.NewPodLister(podIndexer)
+	nsLister := corelisters.NewNamespaceLister(nsIndexer)
+
+	tests := []struct {
+		name string
+		spec networkingv1.NetworkPolicySpec
+		want []string
+	}{
+		{
+			name: "target",
+			spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			want: []string{"test-ns/web"},
+		},
+		{
+			name: "peer in other namespace",
+			spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{
+						{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}}},
+						{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+					},
+				}},
+			},
+			want: []string{"monitoring/exporter"},
+		},
+		{
+			name: "egress peer in policy namespace",
+			spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+				}},
+			},
+			want: []string{"test-ns/web"},
+		},
+		{
+			name: "no host-network pods",
+			spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "none"}},
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			netPol := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"},
+				Spec:       tt.spec,
+			}
+			got, err := hostNetworkPodsSelectedBy(netPol, podLister, nsLister)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -7,8 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
-	"time"
+	"sync/atomic"
 
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
@@ -18,7 +19,6 @@ import (
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8slabels "k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformer "k8s.io/client-go/informers/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -54,11 +54,23 @@ func (n *NpmNamespaceCache) MarshalJSON() ([]byte, error) {
 	return nsMapRaw, nil
 }
 
+// NsMapPage returns a page of the namespace map for the debug API, see common.NewCachePage.
+func (n *NpmNamespaceCache) NsMapPage(start string, limit int) (*common.CachePage, error) {
+	n.RLock()
+	defer n.RUnlock()
+	return common.NewCachePage(n.NsMap, start, limit)
+}
+
 type NamespaceController struct {
 	dp                dataplane.GenericDataplane
 	nameSpaceLister   corelisters.NamespaceLister
 	workqueue         workqueue.RateLimitingInterface
 	npmNamespaceCache *NpmNamespaceCache
+	// inFlight is the number of work items currently being processed
+	inFlight int32
+	applier  *common.Applier
+	// excluded are the namespaces which don't have IPSets
+	excluded excludedNamespaces
 }
 
 func NewNamespaceController(nameSpaceInformer coreinformer.NamespaceInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache) *NamespaceController {
@@ -67,6 +79,7 @@ func NewNamespaceController(nameSpaceInformer coreinformer.NamespaceInformer, dp
 		nameSpaceLister:   nameSpaceInformer.Lister(),
 		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Namespaces"),
 		npmNamespaceCache: npmNamespaceCache,
+		applier:           common.NewApplier("Namespaces", npmconfig.ApplierConfig{}),
 	}
 
 	nameSpaceInformer.Informer().AddEventHandler(
@@ -79,6 +92,12 @@ func NewNamespaceController(nameSpaceInformer coreinformer.NamespaceInformer, dp
 	return nameSpaceController
 }
 
+// SetExcludedNamespaces sets the namespaces which don't have IPSets.
+// It must be called before Run.
+func (nsc *NamespaceController) SetExcludedNamespaces(namespaces []string) {
+	nsc.excluded = newExcludedNamespaces(namespaces)
+}
+
 func (n *NamespaceController) GetCache() map[string]*common.Namespace {
 	return n.npmNamespaceCache.GetCache()
 }
@@ -94,6 +113,11 @@ func (nsc *NamespaceController) needSync(obj interface{}, event string) (string,
 		return key, needSync
 	}
 
+	if nsc.excluded.has(nsObj.Name) {
+		metrics.RecordExcludedNamespaceEvent(metrics.ExcludedNamespace)
+		return key, needSync
+	}
+
 	var err error
 	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
 		utilruntime.HandleError(err)
@@ -149,6 +173,11 @@ func (nsc *NamespaceController) deleteNamespace(obj interface{}) {
 		}
 	}
 
+	if nsc.excluded.has(nsObj.Name) {
+		metrics.RecordExcludedNamespaceEvent(metrics.ExcludedNamespace)
+		return
+	}
+
 	var err error
 	var key string
 	if key, err = cache.MetaNamespaceKeyFunc(nsObj); err != nil {
@@ -167,16 +196,23 @@ func (nsc *NamespaceController) Run(stopCh <-chan struct{}) {
 	klog.Info("Starting Namespace controller\n")
 	klog.Info("Starting workers")
 	// Launch workers to process namespace resources
-	go wait.Until(nsc.runWorker, time.Second, stopCh)
+	nsc.applier.Run(stopCh, nsc.processNextWorkItem)
 
 	klog.Info("Started workers")
 	<-stopCh
 	klog.Info("Shutting down workers")
 }
 
-func (nsc *NamespaceController) runWorker() {
-	for nsc.processNextWorkItem() {
-	}
+// IsIdle returns true if the controller has no queued or in-flight work items.
+// Items waiting on a rate limited requeue are not counted.
+func (nsc *NamespaceController) IsIdle() bool {
+	return nsc.workqueue.Len() == 0 && atomic.LoadInt32(&nsc.inFlight) == 0
+}
+
+// Applier returns the applier running the workers of the controller, which can be used to change
+// the worker count and rate limit at runtime.
+func (nsc *NamespaceController) Applier() *common.Applier {
+	return nsc.applier
 }
 
 func (nsc *NamespaceController) processNextWorkItem() bool {
@@ -185,6 +221,8 @@ func (nsc *NamespaceController) processNextWorkItem() bool {
 	if shutdown {
 		return false
 	}
+	atomic.AddInt32(&nsc.inFlight, 1)
+	defer atomic.AddInt32(&nsc.inFlight, -1)
 
 	err := func(obj interface{}) error {
 		defer nsc.workqueue.Done(obj)
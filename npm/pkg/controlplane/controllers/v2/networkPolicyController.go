@@ -3,27 +3,47 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
+	"hash/fnv"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	netpollister "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 )
 
+const (
+	// maxReportedHostNetworkPods is the number of host-network pods listed when reporting the ones a policy selects.
+	maxReportedHostNetworkPods = 5
+	// budgetRetryInterval is how often a policy exceeding the dataplane budget is retried, in case usage dropped
+	// without a policy being deleted (e.g. pods were deleted).
+	budgetRetryInterval = time.Minute
+
+	budgetExceededReason  = "BudgetExceeded"
+	budgetAvailableReason = "BudgetAvailable"
+)
+
 var (
 	errNetPolKeyFormat          = errors.New("invalid network policy key format")
 	errNetPolTranslationFailure = errors.New("failed to translate network policy")
@@ -32,9 +52,34 @@ var (
 type NetworkPolicyController struct {
 	sync.RWMutex
 	netPolLister netpollister.NetworkPolicyLister
-	workqueue    workqueue.RateLimitingInterface
+	// workqueue hands out the keys of deleted policies first, since they keep being enforced until they're removed.
+	workqueue    *common.PriorityQueue
 	rawNpSpecMap map[string]*networkingv1.NetworkPolicySpec // Key is <nsname>/<policyname>
-	dp           dataplane.GenericDataplane
+	// specHashes is the hash of the lastly translated spec of each network policy, including policies which weren't
+	// applied because their translation is unsupported. Re-adds of an unchanged spec skip translation and the dataplane.
+	specHashes map[string]string // Key is <nsname>/<policyname>
+	dp         dataplane.GenericDataplane
+	// inFlight is the number of work items currently being processed
+	inFlight int32
+	applier  *common.Applier
+	// statusWriter reports the status of translated policies. Status isn't reported if nil.
+	statusWriter PolicyStatusWriter
+	// hostNetworkPods is how policies selecting host-network pods are handled. They're only reported if podLister is set.
+	hostNetworkPods npmconfig.HostNetworkPodsMode
+	podLister       corelisters.PodLister
+	nsLister        corelisters.NamespaceLister
+	// budgetPending is the keys of the policies which aren't programmed because they exceed the dataplane budget.
+	// They're retried every budgetRetryInterval, and as soon as a policy is deleted.
+	budgetPending map[string]struct{}
+	// recorder emits events on the policies which exceed the dataplane budget. Events aren't emitted if nil.
+	recorder record.EventRecorder
+	// auditNsLister looks up the policy mode of namespaces. Policies are always enforced if nil.
+	auditNsLister corelisters.NamespaceLister
+	// policyEvents is when the oldest event of each network policy which the dataplane hasn't converged to yet was
+	// received, to record the apply latency of the policy.
+	policyEvents map[string]time.Time
+	// excluded are the namespaces whose policies aren't applied
+	excluded excludedNamespaces
 }
 
 func (c *NetworkPolicyController) GetCache() map[string]*networkingv1.NetworkPolicySpec {
@@ -45,10 +90,14 @@ func (c *NetworkPolicyController) GetCache() map[string]*networkingv1.NetworkPol
 
 func NewNetworkPolicyController(npInformer networkinginformers.NetworkPolicyInformer, dp dataplane.GenericDataplane) *NetworkPolicyController {
 	netPolController := &NetworkPolicyController{
-		netPolLister: npInformer.Lister(),
-		workqueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "NetworkPolicy"),
-		rawNpSpecMap: make(map[string]*networkingv1.NetworkPolicySpec),
-		dp:           dp,
+		netPolLister:  npInformer.Lister(),
+		workqueue:     common.NewNamedPriorityQueue(workqueue.DefaultControllerRateLimiter(), "NetworkPolicy"),
+		rawNpSpecMap:  make(map[string]*networkingv1.NetworkPolicySpec),
+		specHashes:    make(map[string]string),
+		budgetPending: make(map[string]struct{}),
+		policyEvents:  make(map[string]time.Time),
+		dp:            dp,
+		applier:       common.NewApplier("NetworkPolicy", npmconfig.ApplierConfig{}),
 	}
 
 	npInformer.Informer().AddEventHandler(
@@ -61,10 +110,148 @@ func NewNetworkPolicyController(npInformer networkinginformers.NetworkPolicyInfo
 	return netPolController
 }
 
+// SetStatusWriter sets the writer reporting the status of network policies.
+// It must be called before Run.
+func (c *NetworkPolicyController) SetStatusWriter(w PolicyStatusWriter) {
+	c.statusWriter = w
+}
+
+// SetEventRecorder sets the recorder of the events on the policies which exceed the dataplane budget.
+// It must be called before Run.
+func (c *NetworkPolicyController) SetEventRecorder(recorder record.EventRecorder) {
+	c.recorder = recorder
+}
+
+// SetExcludedNamespaces sets the namespaces whose policies aren't applied.
+// It must be called before Run.
+func (c *NetworkPolicyController) SetExcludedNamespaces(namespaces []string) {
+	c.excluded = newExcludedNamespaces(namespaces)
+}
+
+// SetHostNetworkPods sets how policies selecting host-network pods are handled. In Warn and EnforceOnNodeIP modes,
+// the policies selecting host-network pods as their target or as a peer are logged when they're added or updated.
+// It must be called before Run.
+func (c *NetworkPolicyController) SetHostNetworkPods(mode npmconfig.HostNetworkPodsMode, podLister corelisters.PodLister,
+	nsLister corelisters.NamespaceLister,
+) {
+	c.hostNetworkPods = mode
+	if mode == npmconfig.HostNetworkPodsIgnore {
+		return
+	}
+	c.podLister = podLister
+	c.nsLister = nsLister
+}
+
+// EnableAuditMode applies the policies of the namespaces annotated with translation.PolicyModeAnnotation in audit mode,
+// and translates them again when the annotation changes.
+// It must be called before Run.
+func (c *NetworkPolicyController) EnableAuditMode(nsInformer coreinformers.NamespaceInformer) {
+	c.auditNsLister = nsInformer.Lister()
+	nsInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.addNamespacePolicyMode,
+			UpdateFunc: c.updateNamespacePolicyMode,
+		},
+	)
+}
+
+func (c *NetworkPolicyController) addNamespacePolicyMode(obj interface{}) {
+	if ns, ok := obj.(*corev1.Namespace); ok && translation.IsAuditNamespace(ns) {
+		c.enqueueNamespacePolicies(ns.Name)
+	}
+}
+
+func (c *NetworkPolicyController) updateNamespacePolicyMode(old, newns interface{}) {
+	oldNs, ok := old.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	newNs, ok := newns.(*corev1.Namespace)
+	if ok && translation.IsAuditNamespace(oldNs) != translation.IsAuditNamespace(newNs) {
+		c.enqueueNamespacePolicies(newNs.Name)
+	}
+}
+
+// enqueueNamespacePolicies queues the network policies of the namespace, e.g. after its policy mode changed.
+func (c *NetworkPolicyController) enqueueNamespacePolicies(namespace string) {
+	if c.excluded.has(namespace) {
+		return
+	}
+	netPols, err := c.netPolLister.NetworkPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list network policies of namespace %s: %w", namespace, err))
+		return
+	}
+	for _, netPol := range netPols {
+		c.workqueue.Add(netPol.Namespace + "/" + netPol.Name)
+	}
+}
+
+// isAuditNamespace returns whether the policies of the namespace are in audit mode.
+func (c *NetworkPolicyController) isAuditNamespace(namespace string) bool {
+	if c.auditNsLister == nil {
+		return false
+	}
+	ns, err := c.auditNsLister.Get(namespace)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			klog.Warningf("failed to get namespace %s to look up its policy mode, enforcing its policies: %s", namespace, err.Error())
+		}
+		return false
+	}
+	return translation.IsAuditNamespace(ns)
+}
+
 func (c *NetworkPolicyController) LengthOfRawNpMap() int {
+	c.RLock()
+	defer c.RUnlock()
 	return len(c.rawNpSpecMap)
 }
 
+// cachedNetPolSpec returns the lastly applied spec of the network policy with key.
+// The workqueue never hands the same key to two workers at once, so only the map itself needs the lock.
+func (c *NetworkPolicyController) cachedNetPolSpec(key string) (*networkingv1.NetworkPolicySpec, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	spec, ok := c.rawNpSpecMap[key]
+	return spec, ok
+}
+
+// cachedSpecHash returns the hash of the lastly translated spec of the network policy with key.
+func (c *NetworkPolicyController) cachedSpecHash(key string) (string, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	hash, ok := c.specHashes[key]
+	return hash, ok
+}
+
+func (c *NetworkPolicyController) setSpecHash(key, hash string) {
+	c.Lock()
+	defer c.Unlock()
+	if hash == "" {
+		delete(c.specHashes, key)
+		return
+	}
+	c.specHashes[key] = hash
+}
+
+// hashNetPolSpec returns a hash of the JSON encoding of the spec, of the annotations affecting its translation and of
+// the policy mode of its namespace.
+// The encoding is deterministic since encoding/json sorts map keys (e.g. of label selectors).
+func hashNetPolSpec(netPol *networkingv1.NetworkPolicy, audit bool) (string, error) {
+	b, err := json.Marshal(&netPol.Spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal network policy spec: %w", err)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	_, _ = h.Write([]byte(netPol.Annotations[translation.AddressGroupsAnnotation]))
+	if audit {
+		_, _ = h.Write([]byte(translation.PolicyModeAudit))
+	}
+	return strconv.FormatUint(h.Sum64(), 16), nil
+}
+
 // getNetworkPolicyKey returns namespace/name of network policy object if it is valid network policy object and has valid namespace/name.
 // If not, it returns error.
 func (c *NetworkPolicyController) getNetworkPolicyKey(obj interface{}) (string, error) {
@@ -89,7 +276,7 @@ func (c *NetworkPolicyController) addNetworkPolicy(obj interface{}) {
 		return
 	}
 
-	c.workqueue.Add(netPolkey)
+	c.enqueueEvent(netPolkey)
 }
 
 func (c *NetworkPolicyController) updateNetworkPolicy(old, newnetpol interface{}) {
@@ -110,7 +297,7 @@ func (c *NetworkPolicyController) updateNetworkPolicy(old, newnetpol interface{}
 		}
 	}
 
-	c.workqueue.Add(netPolkey)
+	c.enqueueEvent(netPolkey)
 }
 
 func (c *NetworkPolicyController) deleteNetworkPolicy(obj interface{}) {
@@ -139,7 +326,50 @@ func (c *NetworkPolicyController) deleteNetworkPolicy(obj interface{}) {
 		return
 	}
 
-	c.workqueue.Add(netPolkey)
+	c.enqueueEventWithPriority(netPolkey, common.PriorityHigh)
+}
+
+// enqueueEvent queues the network policy of an event, and records when the event was received unless an older event
+// of the policy is still being applied.
+func (c *NetworkPolicyController) enqueueEvent(key string) {
+	c.enqueueEventWithPriority(key, common.PriorityNormal)
+}
+
+func (c *NetworkPolicyController) enqueueEventWithPriority(key string, priority common.Priority) {
+	if namespace, _, err := cache.SplitMetaNamespaceKey(key); err == nil && c.excluded.has(namespace) {
+		metrics.RecordExcludedNamespaceEvent(metrics.ExcludedNetworkPolicy)
+		return
+	}
+	c.Lock()
+	if _, ok := c.policyEvents[key]; !ok {
+		c.policyEvents[key] = time.Now()
+	}
+	c.Unlock()
+	c.workqueue.AddWithPriority(key, priority)
+}
+
+// policyConverged records the apply latency of the network policy, from its oldest event the dataplane hadn't
+// converged to, if any.
+func (c *NetworkPolicyController) policyConverged(key string) {
+	c.Lock()
+	received, ok := c.policyEvents[key]
+	delete(c.policyEvents, key)
+	c.Unlock()
+	if ok {
+		metrics.RecordPolicyApplyLatency(key, time.Since(received))
+	}
+}
+
+// policyFailed counts a failure to apply the network policy. Unless the policy is retried, the dataplane won't
+// converge to its events, so their apply latency isn't recorded.
+func (c *NetworkPolicyController) policyFailed(key string, class metrics.PolicyErrorClass, retried bool) {
+	metrics.RecordPolicyApplyFailure(class)
+	if retried {
+		return
+	}
+	c.Lock()
+	delete(c.policyEvents, key)
+	c.Unlock()
 }
 
 func (c *NetworkPolicyController) Run(stopCh <-chan struct{}) {
@@ -147,16 +377,23 @@ func (c *NetworkPolicyController) Run(stopCh <-chan struct{}) {
 	defer c.workqueue.ShutDown()
 
 	klog.Infof("Starting Network Policy worker")
-	go wait.Until(c.runWorker, time.Second, stopCh)
+	c.applier.Run(stopCh, c.processNextWorkItem)
 
 	klog.Infof("Started Network Policy worker")
 	<-stopCh
 	klog.Info("Shutting down Network Policy workers")
 }
 
-func (c *NetworkPolicyController) runWorker() {
-	for c.processNextWorkItem() {
-	}
+// IsIdle returns true if the controller has no queued or in-flight work items.
+// Items waiting on a rate limited requeue are not counted.
+func (c *NetworkPolicyController) IsIdle() bool {
+	return c.workqueue.Len() == 0 && atomic.LoadInt32(&c.inFlight) == 0
+}
+
+// Applier returns the applier running the workers of the controller, which can be used to change
+// the worker count and rate limit at runtime.
+func (c *NetworkPolicyController) Applier() *common.Applier {
+	return c.applier
 }
 
 func (c *NetworkPolicyController) processNextWorkItem() bool {
@@ -165,6 +402,8 @@ func (c *NetworkPolicyController) processNextWorkItem() bool {
 	if shutdown {
 		return false
 	}
+	atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
 
 	err := func(obj interface{}) error {
 		defer c.workqueue.Done(obj)
@@ -181,6 +420,12 @@ func (c *NetworkPolicyController) processNextWorkItem() bool {
 		// Run the syncNetPol, passing it the namespace/name string of the
 		// network policy resource to be synced.
 		if err := c.syncNetPol(key); err != nil {
+			if errors.Is(err, dataplane.ErrBudgetExceeded) {
+				// backing off would soon stop retrying a policy which may wait long for the budget
+				c.workqueue.Forget(obj)
+				c.workqueue.AddAfter(key, budgetRetryInterval)
+				return fmt.Errorf("error syncing '%s': %w, retrying in %v", key, err, budgetRetryInterval)
+			}
 			// Put the item back on the workqueue to handle any transient errors.
 			c.workqueue.AddRateLimited(key)
 			return fmt.Errorf("error syncing '%s': %w, requeuing", key, err)
@@ -188,7 +433,8 @@ func (c *NetworkPolicyController) processNextWorkItem() bool {
 		// Finally, if no error occurs we Forget this item so it does not
 		// get queued again until another change happens.
 		c.workqueue.Forget(obj)
-		klog.Infof("Successfully synced '%s'", key)
+		c.policyConverged(key)
+		klog.Infof("Successfully synced '%s' (policy hash %s)", key, metrics.PolicyHash(key))
 		return nil
 	}(obj)
 	if err != nil {
@@ -224,7 +470,7 @@ func (c *NetworkPolicyController) syncNetPol(key string) error {
 		if k8serrors.IsNotFound(err) {
 			klog.Infof("Network Policy %s is not found, may be it is deleted", key)
 
-			if _, ok := c.rawNpSpecMap[key]; ok {
+			if _, ok := c.cachedNetPolSpec(key); ok {
 				// record time to delete policy if it exists (can't call within cleanUpNetworkPolicy because this can be called by a pod update)
 				operationKind = metrics.DeleteOp
 			}
@@ -237,13 +483,14 @@ func (c *NetworkPolicyController) syncNetPol(key string) error {
 			}
 			return err
 		}
+		c.policyFailed(key, metrics.ListerError, true)
 		return err
 	}
 
 	// If DeletionTimestamp of the netPolObj is set, start cleaning up lastly applied states.
 	// This is early cleaning up process from updateNetPol event
 	if netPolObj.ObjectMeta.DeletionTimestamp != nil || netPolObj.ObjectMeta.DeletionGracePeriodSeconds != nil {
-		if _, ok := c.rawNpSpecMap[key]; ok {
+		if _, ok := c.cachedNetPolSpec(key); ok {
 			// record time to delete policy if it exists (can't call within cleanUpNetworkPolicy because this can be called by a pod update)
 			operationKind = metrics.DeleteOp
 		}
@@ -254,27 +501,30 @@ func (c *NetworkPolicyController) syncNetPol(key string) error {
 		return nil
 	}
 
-	cachedNetPolSpecObj, netPolExists := c.rawNpSpecMap[key]
-	if netPolExists {
-		// if network policy does not have different states against lastly applied states stored in cachedNetPolObj,
-		// netPolController does not need to reconcile this update.
-		// In this updateNetworkPolicy event,
-		// newNetPol was updated with states which netPolController does not need to reconcile.
-		if reflect.DeepEqual(cachedNetPolSpecObj, &netPolObj.Spec) {
-			return nil
-		}
+	// if the spec is unchanged since it was lastly translated (e.g. on a resync), netPolController does not need to reconcile it.
+	// A spec which fails to hash is always translated.
+	audit := c.isAuditNamespace(namespace)
+	hash, hashErr := hashNetPolSpec(netPolObj, audit)
+	if hashErr != nil {
+		klog.Warningf("failed to hash spec of network policy %s: %s", key, hashErr.Error())
+	} else if cachedHash, ok := c.cachedSpecHash(key); ok && cachedHash == hash {
+		metrics.RecordPolicyTranslationCacheHit()
+		return nil
 	}
+	metrics.RecordPolicyTranslationCacheMiss()
 
-	operationKind, err = c.syncAddAndUpdateNetPol(netPolObj)
+	operationKind, err = c.syncAddAndUpdateNetPol(netPolObj, audit)
 	if err != nil {
 		return fmt.Errorf("[syncNetPol] error due to  %w", err)
 	}
 
+	c.setSpecHash(key, hash)
 	return nil
 }
 
-// syncAddAndUpdateNetPol handles a new network policy or an updated network policy object triggered by add and update events
-func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1.NetworkPolicy) (metrics.OperationKind, error) {
+// syncAddAndUpdateNetPol handles a new network policy or an updated network policy object triggered by add and update events.
+// In audit mode, the traffic the policy would deny is logged instead of dropped.
+func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1.NetworkPolicy, audit bool) (metrics.OperationKind, error) {
 	var err error
 	netpolKey, err := cache.MetaNamespaceKeyFunc(netPolObj)
 	if err != nil {
@@ -284,22 +534,31 @@ func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1
 
 	// install translated rules into kernel
 	npmNetPolObj, err := translation.TranslatePolicy(netPolObj)
+	if err == nil && audit {
+		err = translation.AuditPolicy(npmNetPolObj)
+	}
 	if err != nil {
 		if isUnsupportedWindowsTranslationErr(err) {
 			klog.Warningf("NetworkPolicy %s in namespace %s is not translated because it has unsupported translated features of Windows: %s",
 				netPolObj.ObjectMeta.Name, netPolObj.ObjectMeta.Namespace, err.Error())
 
+			c.reportStatus(netPolObj, networkingv1.NetworkPolicyConditionStatusFailure,
+				string(networkingv1.NetworkPolicyConditionReasonFeatureNotSupported), err.Error())
+			c.policyFailed(netpolKey, metrics.UnsupportedError, false)
+
 			// We can safely suppress unsupported network policy because re-Queuing will result in same error.
 			// The exec time isn't relevant here, so consider a no-op.
 			return metrics.NoOp, nil
 		}
 
 		klog.Errorf("Failed to translate podSelector in NetworkPolicy %s in namespace %s: %s", netPolObj.ObjectMeta.Name, netPolObj.ObjectMeta.Namespace, err.Error())
+		c.reportStatus(netPolObj, networkingv1.NetworkPolicyConditionStatusFailure, policyTranslationFailedReason, err.Error())
+		c.policyFailed(netpolKey, metrics.TranslationError, false)
 		// The exec time isn't relevant here, so consider a no-op. Returning nil to prevent re-queuing since this is not a transient error.
 		return metrics.NoOp, nil
 	}
 
-	_, policyExisted := c.rawNpSpecMap[netpolKey]
+	_, policyExisted := c.cachedNetPolSpec(netpolKey)
 	var operationKind metrics.OperationKind
 	if policyExisted {
 		operationKind = metrics.UpdateOp
@@ -312,6 +571,12 @@ func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1
 	// if yes: then will delete old rules and program new rules
 	// if no: then will program add new rules
 	err = c.dp.UpdatePolicy(npmNetPolObj)
+	if errors.Is(err, dataplane.ErrBudgetExceeded) {
+		c.markBudgetPending(netPolObj, err)
+		c.policyFailed(netpolKey, metrics.BudgetExceededError, true)
+	} else if err != nil {
+		c.policyFailed(netpolKey, metrics.DataplaneError, true)
+	}
 	if err != nil {
 		// if error occurred the key is re-queued in workqueue and process this function again,
 		// which eventually meets desired states of network policy
@@ -323,13 +588,100 @@ func (c *NetworkPolicyController) syncAddAndUpdateNetPol(netPolObj *networkingv1
 		metrics.IncNumPolicies()
 	}
 
+	c.Lock()
 	c.rawNpSpecMap[netpolKey] = &netPolObj.Spec
+	_, wasPending := c.budgetPending[netpolKey]
+	delete(c.budgetPending, netpolKey)
+	metrics.SetNumBudgetPendingPolicies(len(c.budgetPending))
+	c.Unlock()
+	if wasPending {
+		c.recordEvent(netPolObj, corev1.EventTypeNormal, budgetAvailableReason, "NetworkPolicy is programmed now that it fits the dataplane budget")
+	}
+
+	if audit {
+		c.reportStatus(netPolObj, networkingv1.NetworkPolicyConditionStatusAccepted, policyProgrammedReason,
+			"NetworkPolicy is programmed by NPM in audit mode, logging the traffic it would deny")
+	} else {
+		c.reportStatus(netPolObj, networkingv1.NetworkPolicyConditionStatusAccepted, policyProgrammedReason, "NetworkPolicy is programmed by NPM")
+	}
+	c.reportHostNetworkPods(netPolObj)
 	return operationKind, nil
 }
 
+// reportHostNetworkPods logs the host-network pods the network policy selects, since policies don't apply to them
+// in Warn mode, and apply to all the host-network pods of their node in EnforceOnNodeIP mode.
+func (c *NetworkPolicyController) reportHostNetworkPods(netPolObj *networkingv1.NetworkPolicy) {
+	if c.podLister == nil {
+		return
+	}
+
+	podKeys, err := hostNetworkPodsSelectedBy(netPolObj, c.podLister, c.nsLister)
+	if err != nil {
+		klog.Errorf("failed to find host-network pods selected by NetworkPolicy %s/%s: %s", netPolObj.Namespace, netPolObj.Name, err.Error())
+		return
+	}
+	if len(podKeys) == 0 {
+		return
+	}
+
+	if len(podKeys) > maxReportedHostNetworkPods {
+		podKeys = append(podKeys[:maxReportedHostNetworkPods], "...")
+	}
+	if c.hostNetworkPods == npmconfig.HostNetworkPodsEnforceOnNodeIP {
+		klog.Infof("NetworkPolicy %s/%s selects host-network pods %v. It applies to their node IPs, and hence to all host-network pods of their nodes",
+			netPolObj.Namespace, netPolObj.Name, podKeys)
+		return
+	}
+	metrics.SendErrorLogAndMetric(util.NetpolID,
+		"warning: NetworkPolicy %s/%s selects host-network pods %v, which it doesn't apply to. Set HostNetworkPods to EnforceOnNodeIP to enforce it on their node IPs",
+		netPolObj.Namespace, netPolObj.Name, podKeys)
+}
+
+// markBudgetPending records that the network policy isn't programmed because it exceeds the dataplane budget.
+// Its status and an event are only reported the first time, not on every retry.
+func (c *NetworkPolicyController) markBudgetPending(netPolObj *networkingv1.NetworkPolicy, err error) {
+	key := netPolObj.Namespace + "/" + netPolObj.Name
+	c.Lock()
+	_, wasPending := c.budgetPending[key]
+	c.budgetPending[key] = struct{}{}
+	metrics.SetNumBudgetPendingPolicies(len(c.budgetPending))
+	c.Unlock()
+	if wasPending {
+		return
+	}
+
+	c.reportStatus(netPolObj, networkingv1.NetworkPolicyConditionStatusFailure, budgetExceededReason, err.Error())
+	c.recordEvent(netPolObj, corev1.EventTypeWarning, budgetExceededReason,
+		fmt.Sprintf("NetworkPolicy is pending until it fits the dataplane budget: %s", err.Error()))
+}
+
+// requeueBudgetPending retries the network policies exceeding the dataplane budget, after usage dropped.
+func (c *NetworkPolicyController) requeueBudgetPending() {
+	c.RLock()
+	defer c.RUnlock()
+	for key := range c.budgetPending {
+		c.workqueue.Add(key)
+	}
+}
+
+func (c *NetworkPolicyController) recordEvent(netPolObj *networkingv1.NetworkPolicy, eventType, reason, message string) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Event(netPolObj, eventType, reason, message)
+}
+
 // DeleteNetworkPolicy handles deleting network policy based on netPolKey.
 func (c *NetworkPolicyController) cleanUpNetworkPolicy(netPolKey string) error {
-	_, cachedNetPolObjExists := c.rawNpSpecMap[netPolKey]
+	// forget the translated spec even if the policy was never applied (e.g. its translation is unsupported)
+	c.setSpecHash(netPolKey, "")
+
+	c.Lock()
+	delete(c.budgetPending, netPolKey)
+	metrics.SetNumBudgetPendingPolicies(len(c.budgetPending))
+	c.Unlock()
+
+	_, cachedNetPolObjExists := c.cachedNetPolSpec(netPolKey)
 	// if there is no applied network policy with the netPolKey, do not need to clean up process.
 	if !cachedNetPolObjExists {
 		return nil
@@ -337,12 +689,18 @@ func (c *NetworkPolicyController) cleanUpNetworkPolicy(netPolKey string) error {
 
 	err := c.dp.RemovePolicy(netPolKey)
 	if err != nil {
+		c.policyFailed(netPolKey, metrics.DataplaneError, true)
 		return fmt.Errorf("[cleanUpNetworkPolicy] Error: failed to remove policy due to %w", err)
 	}
 
 	// Success to clean up ipset and iptables operations in kernel and delete the cached network policy from RawNpMap
+	c.Lock()
 	delete(c.rawNpSpecMap, netPolKey)
+	c.Unlock()
 	metrics.DecNumPolicies()
+
+	// the deleted policy may have freed enough of the dataplane budget for the pending policies
+	c.requeueBudgetPending()
 	return nil
 }
 
@@ -350,5 +708,6 @@ func isUnsupportedWindowsTranslationErr(err error) bool {
 	return errors.Is(err, translation.ErrUnsupportedNamedPort) ||
 		errors.Is(err, translation.ErrUnsupportedNegativeMatch) ||
 		errors.Is(err, translation.ErrUnsupportedSCTP) ||
-		errors.Is(err, translation.ErrUnsupportedExceptCIDR)
+		errors.Is(err, translation.ErrUnsupportedExceptCIDR) ||
+		errors.Is(err, translation.ErrUnsupportedAuditMode)
 }
@@ -9,8 +9,11 @@ import (
 
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/metrics/promutil"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -21,6 +24,7 @@ import (
 	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 type netPolFixture struct {
@@ -391,6 +395,200 @@ func TestUpdateNetworkPolicy(t *testing.T) {
 	checkNetPolTestResult("TestUpdateNetPol", f, testCases)
 }
 
+func TestResyncUnchangedNetworkPolicy(t *testing.T) {
+	netPolObj := createNetPol()
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, netPolObj)
+	f.kubeobjects = append(f.kubeobjects, netPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f.newNetPolController(stopCh, dp)
+
+	// the re-add of the unchanged spec skips translation and the dataplane
+	dp.EXPECT().UpdatePolicy(gomock.Any()).Times(1)
+	addNetPol(f, netPolObj)
+	addNetPol(f, netPolObj.DeepCopy())
+
+	testCases := []expectedNetPolValues{
+		{1, 0, netPolPromVals{1, 1, 0, 0}},
+	}
+	checkNetPolTestResult("TestResyncUnchangedNetworkPolicy", f, testCases)
+
+	hits, err := metrics.GetPolicyTranslationCacheCount(true)
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 1, hits)
+	misses, err := metrics.GetPolicyTranslationCacheCount(false)
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 1, misses)
+}
+
+func TestDeletedNetworkPolicyIsSyncedFirst(t *testing.T) {
+	updatedNetPolObj := createNetPol()
+	deletedNetPolObj := createNetPol()
+	deletedNetPolObj.Name = "deleted"
+
+	f := newNetPolFixture(t)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	f.newNetPolController(stopCh, dpmocks.NewMockGenericDataplane(ctrl))
+
+	f.netPolController.addNetworkPolicy(updatedNetPolObj)
+	f.netPolController.deleteNetworkPolicy(deletedNetPolObj)
+	require.Equal(t, 2, f.netPolController.workqueue.Len())
+	key, _ := f.netPolController.workqueue.Get()
+	require.Equal(t, getKey(deletedNetPolObj, t), key)
+	key, _ = f.netPolController.workqueue.Get()
+	require.Equal(t, getKey(updatedNetPolObj, t), key)
+}
+
+func TestBudgetExceededNetworkPolicy(t *testing.T) {
+	programmedNetPolObj := createNetPol()
+	pendingNetPolObj := createNetPol()
+	pendingNetPolObj.Name = "pending"
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, programmedNetPolObj, pendingNetPolObj)
+	f.kubeobjects = append(f.kubeobjects, programmedNetPolObj, pendingNetPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f.newNetPolController(stopCh, dp)
+	recorder := record.NewFakeRecorder(10)
+	f.netPolController.SetEventRecorder(recorder)
+
+	gomock.InOrder(
+		dp.EXPECT().UpdatePolicy(gomock.Any()).Return(nil),
+		dp.EXPECT().UpdatePolicy(gomock.Any()).Return(fmt.Errorf("%w: no more rules", dataplane.ErrBudgetExceeded)),
+		dp.EXPECT().RemovePolicy(getKey(programmedNetPolObj, t)).Return(nil),
+		dp.EXPECT().UpdatePolicy(gomock.Any()).Return(nil),
+	)
+
+	addNetPol(f, programmedNetPolObj)
+	addNetPol(f, pendingNetPolObj)
+	// the pending policy is retried later instead of backing off
+	require.Equal(t, 0, f.netPolController.workqueue.Len())
+	require.Equal(t, 1, f.netPolController.LengthOfRawNpMap())
+	pending, err := metrics.GetNumBudgetPendingPolicies()
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 1, pending)
+	require.Contains(t, <-recorder.Events, "Warning BudgetExceeded")
+
+	// deleting a policy frees budget, so the pending policy is retried right away
+	require.NoError(t, f.kubeInformer.Networking().V1().NetworkPolicies().Informer().GetIndexer().Delete(programmedNetPolObj))
+	f.netPolController.deleteNetworkPolicy(programmedNetPolObj)
+	f.netPolController.processNextWorkItem()
+	require.Equal(t, 1, f.netPolController.workqueue.Len())
+	f.netPolController.processNextWorkItem()
+
+	require.Equal(t, 1, f.netPolController.LengthOfRawNpMap())
+	pending, err = metrics.GetNumBudgetPendingPolicies()
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 0, pending)
+	require.Contains(t, <-recorder.Events, "Normal BudgetAvailable")
+
+	// the apply latency of the pending policy is recorded once it's programmed, and counts the failure
+	failures, err := metrics.GetPolicyApplyFailureCount(metrics.BudgetExceededError)
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 1, failures)
+	applies, err := metrics.GetPolicyApplyLatencyCount(getKey(pendingNetPolObj, t))
+	promutil.NotifyIfErrors(t, err)
+	require.GreaterOrEqual(t, applies, 1)
+	require.Empty(t, f.netPolController.policyEvents)
+}
+
+func TestPolicyApplyLatencyNotRecordedForTranslationFailure(t *testing.T) {
+	netPolObj := createNetPol()
+	netPolObj.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	netPolObj.Spec.Ingress[0].From[1].IPBlock.CIDR = "invalid"
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, netPolObj)
+	f.kubeobjects = append(f.kubeobjects, netPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f.newNetPolController(stopCh, dp)
+
+	addNetPol(f, netPolObj)
+
+	failures, err := metrics.GetPolicyApplyFailureCount(metrics.TranslationError)
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 1, failures)
+	applies, err := metrics.GetPolicyApplyLatencyCount(getKey(netPolObj, t))
+	promutil.NotifyIfErrors(t, err)
+	require.Equal(t, 0, applies)
+	require.Empty(t, f.netPolController.policyEvents)
+}
+
+func TestAuditModeNetworkPolicy(t *testing.T) {
+	if util.IsWindowsDP() {
+		t.Skip("audit mode is Linux only")
+	}
+	netPolObj := createNetPol()
+	netPolObj.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	nsObj := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        netPolObj.Namespace,
+			Annotations: map[string]string{translation.PolicyModeAnnotation: translation.PolicyModeAudit},
+		},
+	}
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, netPolObj)
+	f.kubeobjects = append(f.kubeobjects, netPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f.newNetPolController(stopCh, dp)
+	nsInformer := f.kubeInformer.Core().V1().Namespaces()
+	require.NoError(t, nsInformer.Informer().GetIndexer().Add(nsObj))
+	f.netPolController.EnableAuditMode(nsInformer)
+
+	var targets [][]policies.Verdict
+	dp.EXPECT().UpdatePolicy(gomock.Any()).DoAndReturn(func(npmNetPol *policies.NPMNetworkPolicy) error {
+		verdicts := make([]policies.Verdict, 0, len(npmNetPol.ACLs))
+		for _, acl := range npmNetPol.ACLs {
+			verdicts = append(verdicts, acl.Target)
+		}
+		targets = append(targets, verdicts)
+		return nil
+	}).Times(2)
+
+	addNetPol(f, netPolObj)
+	require.Contains(t, targets[0], policies.Audited)
+	require.NotContains(t, targets[0], policies.Dropped)
+
+	// enforcing the namespace translates its policies again
+	enforcedNsObj := nsObj.DeepCopy()
+	enforcedNsObj.Annotations[translation.PolicyModeAnnotation] = translation.PolicyModeEnforce
+	require.NoError(t, nsInformer.Informer().GetIndexer().Update(enforcedNsObj))
+	f.netPolController.updateNamespacePolicyMode(nsObj, enforcedNsObj)
+	require.Equal(t, 1, f.netPolController.workqueue.Len())
+	f.netPolController.processNextWorkItem()
+	require.Contains(t, targets[1], policies.Dropped)
+	require.NotContains(t, targets[1], policies.Audited)
+
+	// other namespace updates are ignored
+	f.netPolController.updateNamespacePolicyMode(enforcedNsObj, enforcedNsObj.DeepCopy())
+	require.Equal(t, 0, f.netPolController.workqueue.Len())
+}
+
 func TestLabelUpdateNetworkPolicy(t *testing.T) {
 	oldNetPolObj := createNetPol()
 
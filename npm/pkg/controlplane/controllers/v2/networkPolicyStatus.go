@@ -0,0 +1,143 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// PolicyStatusAnnotationPrefix prefixes the annotation holding the NetworkPolicyStatus reported by NPM.
+	// Linux and Windows nodes support different policy features, so each OS reports its own status.
+	PolicyStatusAnnotationPrefix = "npm.azure.com/status-"
+
+	// policyProgrammedReason is the reason of the Accepted condition of a policy programmed into the dataplane.
+	policyProgrammedReason = "Programmed"
+	// policyTranslationFailedReason is the reason of the Failure condition of a policy which couldn't be translated.
+	policyTranslationFailedReason = "TranslationFailed"
+
+	statusWriteTimeout = 10 * time.Second
+)
+
+// PolicyStatusWriter reports the status of network policies to their authors.
+// The upstream NetworkPolicyStatus is gated off in most clusters, so the status is written to an annotation
+// until it is available; another writer can then set the status subresource instead.
+type PolicyStatusWriter interface {
+	WriteStatus(ctx context.Context, netPol *networkingv1.NetworkPolicy, status *networkingv1.NetworkPolicyStatus) error
+}
+
+// PolicyStatusAnnotation returns the key of the status annotation written by NPM on this OS.
+func PolicyStatusAnnotation() string {
+	if util.IsWindowsDP() {
+		return PolicyStatusAnnotationPrefix + "windows"
+	}
+	return PolicyStatusAnnotationPrefix + "linux"
+}
+
+// AnnotationStatusWriter writes the status of network policies as JSON to their PolicyStatusAnnotation.
+type AnnotationStatusWriter struct {
+	kubeclientset kubernetes.Interface
+}
+
+func NewAnnotationStatusWriter(kubeclientset kubernetes.Interface) *AnnotationStatusWriter {
+	return &AnnotationStatusWriter{kubeclientset: kubeclientset}
+}
+
+// WriteStatus patches the status annotation of the network policy.
+// Every node of an OS reports the same status, so the annotation is only patched if the conditions changed.
+func (w *AnnotationStatusWriter) WriteStatus(ctx context.Context, netPol *networkingv1.NetworkPolicy, status *networkingv1.NetworkPolicyStatus) error {
+	key := PolicyStatusAnnotation()
+	existing := networkingv1.NetworkPolicyStatus{}
+	if raw, ok := netPol.Annotations[key]; ok {
+		// an unreadable annotation is overwritten
+		_ = json.Unmarshal([]byte(raw), &existing)
+	}
+
+	updated := mergeStatusConditions(existing.Conditions, status.Conditions)
+	if equalStatusConditions(existing.Conditions, updated) {
+		return nil
+	}
+
+	value, err := json.Marshal(networkingv1.NetworkPolicyStatus{Conditions: updated})
+	if err != nil {
+		return fmt.Errorf("failed to marshal network policy status: %w", err)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{key: string(value)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal network policy status patch: %w", err)
+	}
+
+	_, err = w.kubeclientset.NetworkingV1().NetworkPolicies(netPol.Namespace).Patch(ctx, netPol.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch status annotation of network policy %s/%s: %w", netPol.Namespace, netPol.Name, err)
+	}
+	return nil
+}
+
+// mergeStatusConditions returns the desired conditions, keeping the transition time of existing conditions
+// whose status didn't change.
+func mergeStatusConditions(existing, desired []metav1.Condition) []metav1.Condition {
+	merged := make([]metav1.Condition, 0, len(desired))
+	for i := range desired {
+		condition := desired[i]
+		if old := meta.FindStatusCondition(existing, condition.Type); old != nil && old.Status == condition.Status {
+			condition.LastTransitionTime = old.LastTransitionTime
+		}
+		merged = append(merged, condition)
+	}
+	return merged
+}
+
+func equalStatusConditions(a, b []metav1.Condition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Status != b[i].Status || a[i].Reason != b[i].Reason ||
+			a[i].Message != b[i].Message || a[i].ObservedGeneration != b[i].ObservedGeneration ||
+			!a[i].LastTransitionTime.Equal(&b[i].LastTransitionTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// reportStatus writes a single condition as the status of the network policy, if a status writer is set.
+// Reporting is best effort: failures are logged and don't requeue the policy.
+func (c *NetworkPolicyController) reportStatus(netPolObj *networkingv1.NetworkPolicy, conditionType networkingv1.NetworkPolicyConditionType, reason, message string) {
+	if c.statusWriter == nil {
+		return
+	}
+
+	status := &networkingv1.NetworkPolicyStatus{
+		Conditions: []metav1.Condition{{
+			Type:               string(conditionType),
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: netPolObj.Generation,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusWriteTimeout)
+	defer cancel()
+	if err := c.statusWriter.WriteStatus(ctx, netPolObj, status); err != nil {
+		klog.Warningf("failed to report status of NetworkPolicy %s in namespace %s: %s", netPolObj.Name, netPolObj.Namespace, err.Error())
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAddNetworkPolicyReportsStatus(t *testing.T) {
+	netPolObj := createNetPol()
+	netPolObj.Generation = 2
+
+	f := newNetPolFixture(t)
+	f.netPolLister = append(f.netPolLister, netPolObj)
+	f.kubeobjects = append(f.kubeobjects, netPolObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f.newNetPolController(stopCh, dp)
+	kubeclient := k8sfake.NewSimpleClientset(netPolObj)
+	f.netPolController.SetStatusWriter(NewAnnotationStatusWriter(kubeclient))
+
+	dp.EXPECT().UpdatePolicy(gomock.Any()).Times(1)
+	addNetPol(f, netPolObj)
+
+	patched, err := kubeclient.NetworkingV1().NetworkPolicies(netPolObj.Namespace).Get(context.Background(), netPolObj.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	status := networkingv1.NetworkPolicyStatus{}
+	require.NoError(t, json.Unmarshal([]byte(patched.Annotations[PolicyStatusAnnotation()]), &status))
+	require.Len(t, status.Conditions, 1)
+	require.Equal(t, string(networkingv1.NetworkPolicyConditionStatusAccepted), status.Conditions[0].Type)
+	require.Equal(t, metav1.ConditionTrue, status.Conditions[0].Status)
+	require.Equal(t, policyProgrammedReason, status.Conditions[0].Reason)
+	require.Equal(t, int64(2), status.Conditions[0].ObservedGeneration)
+}
+
+func TestAnnotationStatusWriterSkipsUnchangedStatus(t *testing.T) {
+	netPolObj := createNetPol()
+	kubeclient := k8sfake.NewSimpleClientset(netPolObj)
+	w := NewAnnotationStatusWriter(kubeclient)
+
+	status := &networkingv1.NetworkPolicyStatus{
+		Conditions: []metav1.Condition{{
+			Type:               string(networkingv1.NetworkPolicyConditionStatusAccepted),
+			Status:             metav1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             policyProgrammedReason,
+		}},
+	}
+	require.NoError(t, w.WriteStatus(context.Background(), netPolObj, status))
+	require.Len(t, kubeclient.Actions(), 1)
+
+	// another node reporting the same condition later doesn't patch the policy again
+	patched, err := kubeclient.NetworkingV1().NetworkPolicies(netPolObj.Namespace).Get(context.Background(), netPolObj.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	status.Conditions[0].LastTransitionTime = metav1.NewTime(status.Conditions[0].LastTransitionTime.Add(time.Minute))
+	require.NoError(t, w.WriteStatus(context.Background(), patched, status))
+	require.Len(t, kubeclient.Actions(), 2) // the Get above
+
+	// a changed condition is patched
+	status.Conditions[0].Type = string(networkingv1.NetworkPolicyConditionStatusFailure)
+	require.NoError(t, w.WriteStatus(context.Background(), patched, status))
+	require.Len(t, kubeclient.Actions(), 3)
+}
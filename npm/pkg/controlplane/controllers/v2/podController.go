@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
@@ -18,7 +20,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformer "k8s.io/client-go/informers/core/v1"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -35,26 +36,41 @@ const (
 
 	addEvent    string = "ADD"
 	updateEvent string = "UPDATE"
+
+	// maxPolicyDeleteYield is how long a pod worker waits for the deletes of network policies to be applied
+	maxPolicyDeleteYield = time.Second
 )
 
 var kubeAllNamespaces = &ipsets.IPSetMetadata{Name: util.KubeAllNamespacesFlag, Type: ipsets.KeyLabelOfNamespace}
 
 type PodController struct {
 	podLister corelisters.PodLister
-	workqueue workqueue.RateLimitingInterface
+	workqueue *common.PriorityQueue
 	dp        dataplane.GenericDataplane
 	podMap    map[string]*common.NpmPod // Key is <nsname>/<podname>
 	sync.RWMutex
 	npmNamespaceCache *NpmNamespaceCache
+	// inFlight is the number of work items currently being processed
+	inFlight int32
+	applier  *common.Applier
+	// ipv6Enabled accepts pods with an IPv6 PodIP, for dataplanes which program IPv6 ipsets
+	ipv6Enabled bool
+	// enforceOnHostNetwork adds host-network pods to IPSets with their node IP, which the host-network pods of the node share
+	enforceOnHostNetwork bool
+	sharedMembers        sharedIPSetMembers
+	// excluded are the namespaces whose pods aren't added to IPSets
+	excluded excludedNamespaces
 }
 
 func NewPodController(podInformer coreinformer.PodInformer, dp dataplane.GenericDataplane, npmNamespaceCache *NpmNamespaceCache) *PodController {
 	podController := &PodController{
 		podLister:         podInformer.Lister(),
-		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Pods"),
+		workqueue:         common.NewNamedPriorityQueue(workqueue.DefaultControllerRateLimiter(), "Pods"),
 		dp:                dp,
 		podMap:            make(map[string]*common.NpmPod),
+		sharedMembers:     make(sharedIPSetMembers),
 		npmNamespaceCache: npmNamespaceCache,
+		applier:           common.NewApplier("Pods", npmconfig.ApplierConfig{}),
 	}
 
 	podInformer.Informer().AddEventHandler(
@@ -67,6 +83,32 @@ func NewPodController(podInformer coreinformer.PodInformer, dp dataplane.Generic
 	return podController
 }
 
+// YieldToPolicyDeletes makes the pod workers wait for the deletes of network policies queued in netPolController to be
+// applied before syncing pods, for at most maxPolicyDeleteYield per pod. Otherwise, under pod churn, the pod workers
+// keep the dataplane busy with label updates while deleted policies are still enforced.
+// It must be called before Run.
+func (c *PodController) YieldToPolicyDeletes(netPolController *NetworkPolicyController) {
+	c.workqueue.YieldTo(netPolController.workqueue, maxPolicyDeleteYield)
+}
+
+// SetIPv6Enabled sets whether pods with an IPv6 PodIP are added to IPSets.
+// It must be called before Run.
+func (c *PodController) SetIPv6Enabled(enabled bool) {
+	c.ipv6Enabled = enabled
+}
+
+// SetEnforceOnHostNetwork sets whether host-network pods are added to IPSets with their node IP.
+// It must be called before Run.
+func (c *PodController) SetEnforceOnHostNetwork(enabled bool) {
+	c.enforceOnHostNetwork = enabled
+}
+
+// SetExcludedNamespaces sets the namespaces whose pods aren't added to IPSets.
+// It must be called before Run.
+func (c *PodController) SetExcludedNamespaces(namespaces []string) {
+	c.excluded = newExcludedNamespaces(namespaces)
+}
+
 func (c *PodController) MarshalJSON() ([]byte, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -79,6 +121,13 @@ func (c *PodController) MarshalJSON() ([]byte, error) {
 	return podMapRaw, nil
 }
 
+// PodMapPage returns a page of the pod map for the debug API, see common.NewCachePage.
+func (c *PodController) PodMapPage(start string, limit int) (*common.CachePage, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return common.NewCachePage(c.podMap, start, limit)
+}
+
 func (c *PodController) LengthOfPodMap() int {
 	return len(c.podMap)
 }
@@ -99,7 +148,12 @@ func (c *PodController) needSync(eventType string, obj interface{}) (string, boo
 		return key, needSync
 	}
 
-	if isHostNetworkPod(podObj) {
+	if isHostNetworkPod(podObj) && !c.enforceOnHostNetwork {
+		return key, needSync
+	}
+
+	if c.excluded.has(podObj.Namespace) {
+		metrics.RecordExcludedNamespaceEvent(metrics.ExcludedPod)
 		return key, needSync
 	}
 
@@ -171,7 +225,12 @@ func (c *PodController) deletePod(obj interface{}) {
 	}
 
 	klog.Infof("[POD DELETE EVENT] for %s in %s", podObj.Name, podObj.Namespace)
-	if isHostNetworkPod(podObj) {
+	if isHostNetworkPod(podObj) && !c.enforceOnHostNetwork {
+		return
+	}
+
+	if c.excluded.has(podObj.Namespace) {
+		metrics.RecordExcludedNamespaceEvent(metrics.ExcludedPod)
 		return
 	}
 
@@ -192,16 +251,23 @@ func (c *PodController) Run(stopCh <-chan struct{}) {
 	defer c.workqueue.ShutDown()
 
 	klog.Infof("Starting Pod worker")
-	go wait.Until(c.runWorker, time.Second, stopCh)
+	c.applier.Run(stopCh, c.processNextWorkItem)
 
 	klog.Info("Started Pod workers")
 	<-stopCh
 	klog.Info("Shutting down Pod workers")
 }
 
-func (c *PodController) runWorker() {
-	for c.processNextWorkItem() {
-	}
+// IsIdle returns true if the controller has no queued or in-flight work items.
+// Items waiting on a rate limited requeue are not counted.
+func (c *PodController) IsIdle() bool {
+	return c.workqueue.Len() == 0 && atomic.LoadInt32(&c.inFlight) == 0
+}
+
+// Applier returns the applier running the workers of the controller, which can be used to change
+// the worker count and rate limit at runtime.
+func (c *PodController) Applier() *common.Applier {
+	return c.applier
 }
 
 func (c *PodController) processNextWorkItem() bool {
@@ -210,6 +276,8 @@ func (c *PodController) processNextWorkItem() bool {
 	if shutdown {
 		return false
 	}
+	atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
 
 	err := func(obj interface{}) error {
 		defer c.workqueue.Done(obj)
@@ -342,8 +410,8 @@ func (c *PodController) syncAddedPod(podObj *corev1.Pod) error {
 	klog.Infof("POD CREATING: [%s/%s/%s/%s/%+v/%s]", string(podObj.GetUID()), podObj.Namespace,
 		podObj.Name, podObj.Spec.NodeName, podObj.Labels, podObj.Status.PodIP)
 
-	if !util.IsIPV4(podObj.Status.PodIP) {
-		msg := fmt.Sprintf("[syncAddedPod] warning: ADD POD  [%s/%s/%s/%+v] ignored as the PodIP is not valid ipv4 address. ip: [%s]", podObj.Namespace,
+	if !c.isValidPodIP(podObj.Status.PodIP) {
+		msg := fmt.Sprintf("[syncAddedPod] warning: ADD POD  [%s/%s/%s/%+v] ignored as the PodIP is not a supported IP address. ip: [%s]", podObj.Namespace,
 			podObj.Name, podObj.Spec.NodeName, podObj.Labels, podObj.Status.PodIP)
 		metrics.SendLog(util.PodID, msg, metrics.PrintLog)
 		// return nil so that we don't requeue.
@@ -357,12 +425,14 @@ func (c *PodController) syncAddedPod(podObj *corev1.Pod) error {
 	podKey, _ := cache.MetaNamespaceKeyFunc(podObj)
 
 	podMetadata := dataplane.NewPodMetadata(podKey, podObj.Status.PodIP, podObj.Spec.NodeName)
+	podMetadata.Generation = common.PodGeneration(podObj)
+	hostNetwork := isHostNetworkPod(podObj)
 
 	namespaceSet := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(podObj.Namespace, ipsets.Namespace)}
 
 	// Add the pod ip information into namespace's ipset.
 	klog.Infof("Adding pod %s (ip : %s) to ipset %s", podKey, podObj.Status.PodIP, podObj.Namespace)
-	if err = c.dp.AddToSets(namespaceSet, podMetadata); err != nil {
+	if err = c.addToSets(namespaceSet, podMetadata, hostNetwork); err != nil {
 		return fmt.Errorf("[syncAddedPod] Error: failed to add pod to namespace ipset with err: %w", err)
 	}
 
@@ -380,7 +450,7 @@ func (c *PodController) syncAddedPod(podObj *corev1.Pod) error {
 
 		klog.Infof("Creating ipsets %+v and %+v if they do not exist", targetSetKey, targetSetKeyValue)
 		klog.Infof("Adding pod %s (ip : %s) to ipset %s and %s", podKey, npmPodObj.PodIP, labelKey, labelKeyValue)
-		if err = c.dp.AddToSets(allSets, podMetadata); err != nil {
+		if err = c.addToSets(allSets, podMetadata, hostNetwork); err != nil {
 			return fmt.Errorf("[syncAddedPod] Error: failed to add pod to label ipset with err: %w", err)
 		}
 		npmPodObj.AppendLabels(map[string]string{labelKey: labelVal}, common.AppendToExistingLabels)
@@ -389,7 +459,7 @@ func (c *PodController) syncAddedPod(podObj *corev1.Pod) error {
 	// Add pod's named ports from its ipset.
 	klog.Infof("Adding named port ipsets")
 	containerPorts := common.GetContainerPortList(podObj)
-	if err = c.manageNamedPortIpsets(containerPorts, podKey, npmPodObj.PodIP, podObj.Spec.NodeName, addNamedPort); err != nil {
+	if err = c.manageNamedPortIpsets(containerPorts, podKey, npmPodObj.PodIP, podObj.Spec.NodeName, npmPodObj.Generation, hostNetwork, addNamedPort); err != nil {
 		return fmt.Errorf("[syncAddedPod] Error: failed to add pod to named port ipset with err: %w", err)
 	}
 	npmPodObj.AppendContainerPorts(podObj)
@@ -455,8 +525,10 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 	addToIPSets, deleteFromIPSets := util.GetIPSetListCompareLabels(cachedNpmPod.Labels, newPodObj.Labels)
 
 	newPodMetadata := dataplane.NewPodMetadata(podKey, newPodObj.Status.PodIP, newPodObj.Spec.NodeName)
+	newPodMetadata.Generation = common.PodGeneration(newPodObj)
 	// should have newPodMetadata == cachedPodMetadata since from branch above, we have cachedNpmPod.PodIP == newPodObj.Status.PodIP
 	cachedPodMetadata := dataplane.NewPodMetadata(podKey, cachedNpmPod.PodIP, newPodMetadata.NodeName)
+	cachedPodMetadata.Generation = cachedNpmPod.Generation
 	// Delete the pod from its label's ipset.
 	for _, removeIPSetName := range deleteFromIPSets {
 		klog.Infof("Deleting pod %s (ip : %s) from ipset %s", podKey, cachedNpmPod.PodIP, removeIPSetName)
@@ -467,7 +539,7 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 		} else {
 			toRemoveSet = ipsets.NewIPSetMetadata(removeIPSetName, ipsets.KeyLabelOfPod)
 		}
-		if err = c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{toRemoveSet}, cachedPodMetadata); err != nil {
+		if err = c.removeFromSets([]*ipsets.IPSetMetadata{toRemoveSet}, cachedPodMetadata, cachedNpmPod.HostNetwork); err != nil {
 			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to delete pod from label ipset with err: %w", err)
 		}
 		// {IMPORTANT} The order of compared list will be key and then key+val. NPM should only append after both key
@@ -491,7 +563,7 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 		}
 
 		klog.Infof("Adding pod %s (ip : %s) to ipset %s", podKey, newPodObj.Status.PodIP, addIPSetName)
-		if err = c.dp.AddToSets([]*ipsets.IPSetMetadata{toAddSet}, newPodMetadata); err != nil {
+		if err = c.addToSets([]*ipsets.IPSetMetadata{toAddSet}, newPodMetadata, cachedNpmPod.HostNetwork); err != nil {
 			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to add pod to label ipset with err: %w", err)
 		}
 		// {IMPORTANT} Same as above order is assumed to be key and then key+val. NPM should only append to existing labels
@@ -514,14 +586,15 @@ func (c *PodController) syncAddAndUpdatePod(newPodObj *corev1.Pod) (metrics.Oper
 	if !reflect.DeepEqual(cachedNpmPod.ContainerPorts, newPodPorts) {
 		// Delete cached pod's named ports from its ipset.
 		if err = c.manageNamedPortIpsets(
-			cachedNpmPod.ContainerPorts, podKey, cachedNpmPod.PodIP, "", deleteNamedPort); err != nil {
+			cachedNpmPod.ContainerPorts, podKey, cachedNpmPod.PodIP, "", cachedNpmPod.Generation, cachedNpmPod.HostNetwork, deleteNamedPort); err != nil {
 			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to delete pod from named port ipset with err: %w", err)
 		}
 		// Since portList ipset deletion is successful, NPM can remove cachedContainerPorts
 		cachedNpmPod.RemoveContainerPorts()
 
 		// Add new pod's named ports from its ipset.
-		if err = c.manageNamedPortIpsets(newPodPorts, podKey, newPodObj.Status.PodIP, newPodObj.Spec.NodeName, addNamedPort); err != nil {
+		if err = c.manageNamedPortIpsets(
+			newPodPorts, podKey, newPodObj.Status.PodIP, newPodObj.Spec.NodeName, newPodMetadata.Generation, cachedNpmPod.HostNetwork, addNamedPort); err != nil {
 			return metrics.UpdateOp, fmt.Errorf("[syncAddAndUpdatePod] Error: failed to add pod to named port ipset with err: %w", err)
 		}
 		cachedNpmPod.AppendContainerPorts(newPodObj)
@@ -542,11 +615,12 @@ func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 
 	var err error
 	cachedPodMetadata := dataplane.NewPodMetadata(cachedNpmPodKey, cachedNpmPod.PodIP, "")
+	cachedPodMetadata.Generation = cachedNpmPod.Generation
 	// Delete the pod from its namespace's ipset.
 	// note: NodeName empty is not going to call update pod
-	if err = c.dp.RemoveFromSets(
+	if err = c.removeFromSets(
 		[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(cachedNpmPod.Namespace, ipsets.Namespace)},
-		cachedPodMetadata); err != nil {
+		cachedPodMetadata, cachedNpmPod.HostNetwork); err != nil {
 		return fmt.Errorf("[cleanUpDeletedPod] Error: failed to delete pod from namespace ipset with err: %w", err)
 	}
 
@@ -554,12 +628,12 @@ func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 	for labelKey, labelVal := range cachedNpmPod.Labels {
 		labelKeyValue := util.GetIpSetFromLabelKV(labelKey, labelVal)
 		klog.Infof("Deleting pod %s (ip : %s) from ipsets %s and %s", cachedNpmPodKey, cachedNpmPod.PodIP, labelKey, labelKeyValue)
-		if err = c.dp.RemoveFromSets(
+		if err = c.removeFromSets(
 			[]*ipsets.IPSetMetadata{
 				ipsets.NewIPSetMetadata(labelKey, ipsets.KeyLabelOfPod),
 				ipsets.NewIPSetMetadata(labelKeyValue, ipsets.KeyValueLabelOfPod),
 			},
-			cachedPodMetadata); err != nil {
+			cachedPodMetadata, cachedNpmPod.HostNetwork); err != nil {
 			return fmt.Errorf("[cleanUpDeletedPod] Error: failed to delete pod from label ipset with err: %w", err)
 		}
 		cachedNpmPod.RemoveLabelsWithKey(labelKey)
@@ -567,7 +641,7 @@ func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 
 	// Delete pod's named ports from its ipset. Need to pass true in the manageNamedPortIpsets function call
 	if err = c.manageNamedPortIpsets(
-		cachedNpmPod.ContainerPorts, cachedNpmPodKey, cachedNpmPod.PodIP, "", deleteNamedPort); err != nil {
+		cachedNpmPod.ContainerPorts, cachedNpmPodKey, cachedNpmPod.PodIP, "", cachedNpmPod.Generation, cachedNpmPod.HostNetwork, deleteNamedPort); err != nil {
 		return fmt.Errorf("[cleanUpDeletedPod] Error: failed to delete pod from named port ipset with err: %w", err)
 	}
 
@@ -577,7 +651,7 @@ func (c *PodController) cleanUpDeletedPod(cachedNpmPodKey string) error {
 
 // manageNamedPortIpsets helps with adding or deleting Pod namedPort IPsets.
 func (c *PodController) manageNamedPortIpsets(portList []corev1.ContainerPort, podKey,
-	podIP, nodeName string, namedPortOperation NamedPortOperation) error {
+	podIP, nodeName string, generation int64, hostNetwork bool, namedPortOperation NamedPortOperation) error {
 	if util.IsWindowsDP() {
 		// NOTE: if we support namedport operations, need to be careful of implications of including the node name in the pod metadata below
 		// since we say the node name is "" in cleanUpDeletedPod
@@ -591,23 +665,18 @@ func (c *PodController) manageNamedPortIpsets(portList []corev1.ContainerPort, p
 		}
 
 		// K8s guarantees port.Protocol has "TCP", "UDP", or "SCTP" if the field exists.
-		var protocol string
-		if len(port.Protocol) != 0 {
-			// without adding ":" after protocol, ipset complains.
-			protocol = fmt.Sprintf("%s:", port.Protocol)
-		}
-
-		namedPortIpsetEntry := fmt.Sprintf("%s,%s%d", podIP, protocol, port.ContainerPort)
+		namedPortIpsetEntry := ipsets.NamedPortMember(podIP, string(port.Protocol), port.ContainerPort)
 
 		// nodename in NewPodMetadata is nil so UpdatePod is ignored
 		podMetadata := dataplane.NewPodMetadata(podKey, namedPortIpsetEntry, nodeName)
+		podMetadata.Generation = generation
 		switch namedPortOperation {
 		case deleteNamedPort:
-			if err := c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(port.Name, ipsets.NamedPorts)}, podMetadata); err != nil {
+			if err := c.removeFromSets([]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(port.Name, ipsets.NamedPorts)}, podMetadata, hostNetwork); err != nil {
 				return fmt.Errorf("failed to remove from set when deleting named port with err %w", err)
 			}
 		case addNamedPort:
-			if err := c.dp.AddToSets([]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(port.Name, ipsets.NamedPorts)}, podMetadata); err != nil {
+			if err := c.addToSets([]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(port.Name, ipsets.NamedPorts)}, podMetadata, hostNetwork); err != nil {
 				return fmt.Errorf("failed to add to set when deleting named port with err %w", err)
 			}
 		}
@@ -616,6 +685,88 @@ func (c *PodController) manageNamedPortIpsets(portList []corev1.ContainerPort, p
 	return nil
 }
 
+// addToSets adds the pod to the sets. A host-network pod's member is added under a key shared by the host-network
+// pods of the node, the first time one of them needs it in a set.
+func (c *PodController) addToSets(sets []*ipsets.IPSetMetadata, podMetadata *dataplane.PodMetadata, hostNetwork bool) error {
+	if !hostNetwork {
+		return c.dp.AddToSets(sets, podMetadata) //nolint:wrapcheck // callers wrap the error
+	}
+
+	sharedMetadata := dataplane.NewPodMetadata(hostNetworkPodKey(podMetadata.PodIP), podMetadata.PodIP, podMetadata.NodeName)
+	for _, set := range sets {
+		if !c.sharedMembers.add(set, podMetadata.PodIP, podMetadata.PodKey) {
+			continue
+		}
+		if err := c.dp.AddToSets([]*ipsets.IPSetMetadata{set}, sharedMetadata); err != nil {
+			c.sharedMembers.remove(set, podMetadata.PodIP, podMetadata.PodKey)
+			return err //nolint:wrapcheck // callers wrap the error
+		}
+	}
+	return nil
+}
+
+// removeFromSets removes the pod from the sets. A host-network pod's member is removed
+// when the last host-network pod of the node needing it in a set is removed.
+func (c *PodController) removeFromSets(sets []*ipsets.IPSetMetadata, podMetadata *dataplane.PodMetadata, hostNetwork bool) error {
+	if !hostNetwork {
+		return c.dp.RemoveFromSets(sets, podMetadata) //nolint:wrapcheck // callers wrap the error
+	}
+
+	sharedMetadata := dataplane.NewPodMetadata(hostNetworkPodKey(podMetadata.PodIP), podMetadata.PodIP, podMetadata.NodeName)
+	for _, set := range sets {
+		if !c.sharedMembers.remove(set, podMetadata.PodIP, podMetadata.PodKey) {
+			continue
+		}
+		if err := c.dp.RemoveFromSets([]*ipsets.IPSetMetadata{set}, sharedMetadata); err != nil {
+			c.sharedMembers.add(set, podMetadata.PodIP, podMetadata.PodKey)
+			return err //nolint:wrapcheck // callers wrap the error
+		}
+	}
+	return nil
+}
+
+// hostNetworkPodKey is the pod key of an IPSet member shared by host-network pods.
+func hostNetworkPodKey(member string) string {
+	return "hostnetwork/" + member
+}
+
+// sharedIPSetMembers tracks the pods needing each IPSet member which pods share.
+// Key is <set prefixed name>/<member>, and value is the set of pod keys.
+type sharedIPSetMembers map[string]map[string]struct{}
+
+// add records that the pod needs the member in the set, and returns true if it's the first pod needing it.
+func (m sharedIPSetMembers) add(set *ipsets.IPSetMetadata, member, podKey string) bool {
+	key := set.GetPrefixName() + "/" + member
+	pods, ok := m[key]
+	if !ok {
+		pods = make(map[string]struct{})
+		m[key] = pods
+	}
+	if _, ok := pods[podKey]; ok {
+		return false
+	}
+	pods[podKey] = struct{}{}
+	return len(pods) == 1
+}
+
+// remove records that the pod doesn't need the member in the set anymore, and returns true if it was the last pod needing it.
+func (m sharedIPSetMembers) remove(set *ipsets.IPSetMetadata, member, podKey string) bool {
+	key := set.GetPrefixName() + "/" + member
+	pods, ok := m[key]
+	if !ok {
+		return false
+	}
+	if _, ok := pods[podKey]; !ok {
+		return false
+	}
+	delete(pods, podKey)
+	if len(pods) > 0 {
+		return false
+	}
+	delete(m, key)
+	return true
+}
+
 // isCompletePod evaluates whether this pod is completely in terminated states,
 // which means pod is gracefully shutdown.
 func isCompletePod(podObj *corev1.Pod) bool {
@@ -636,6 +787,11 @@ func isCompletePod(podObj *corev1.Pod) bool {
 	return false
 }
 
+// isValidPodIP returns true if the pod IP is a valid IPv4 address, or a valid IPv6 address when IPv6 is enabled.
+func (c *PodController) isValidPodIP(podIP string) bool {
+	return util.IsIPV4(podIP) || (c.ipv6Enabled && util.IsIPV6(podIP))
+}
+
 func hasValidPodIP(podObj *corev1.Pod) bool {
 	return len(podObj.Status.PodIP) > 0
 }
@@ -289,13 +289,13 @@ func TestAddMultiplePods(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod-1", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-ns/test-pod-1", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-ns/test-pod-1", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod-2", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-ns/test-pod-2", "1.2.3.5,8080", ""),
+				dataplane.NewPodMetadata("test-ns/test-pod-2", "1.2.3.5,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -347,7 +347,7 @@ func TestAddPod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -390,6 +390,62 @@ func TestAddHostNetworkPod(t *testing.T) {
 	}
 }
 
+func TestHostNetworkPodsEnforcedOnNodeIP(t *testing.T) {
+	labels := map[string]string{
+		"app": "test-pod",
+	}
+	podObj1 := createPod("test-pod-1", "test-namespace", "0", "1.2.3.4", labels, HostNetwork, corev1.PodRunning)
+	podObj2 := createPod("test-pod-2", "test-namespace", "0", "1.2.3.4", labels, HostNetwork, corev1.PodRunning)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f := newFixture(t, dp)
+	f.podLister = append(f.podLister, podObj1, podObj2)
+	f.kubeobjects = append(f.kubeobjects, podObj1, podObj2)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f.newPodController(stopCh)
+	f.podController.SetEnforceOnHostNetwork(true)
+
+	nsSet := ipsets.NewIPSetMetadata("test-namespace", ipsets.Namespace)
+	labelSets := []*ipsets.IPSetMetadata{
+		ipsets.NewIPSetMetadata("app", ipsets.KeyLabelOfPod),
+		ipsets.NewIPSetMetadata("app:test-pod", ipsets.KeyValueLabelOfPod),
+	}
+	// the pods share the node IP, which is added once and removed with the last pod
+	sharedMetadata := dataplane.NewPodMetadata("hostnetwork/1.2.3.4", "1.2.3.4", "")
+
+	dp.EXPECT().AddToLists([]*ipsets.IPSetMetadata{kubeAllNamespaces}, []*ipsets.IPSetMetadata{nsSet}).Return(nil).Times(1)
+	dp.EXPECT().ApplyDataPlane().Return(nil).AnyTimes()
+	for _, set := range append([]*ipsets.IPSetMetadata{nsSet}, labelSets...) {
+		dp.EXPECT().AddToSets([]*ipsets.IPSetMetadata{set}, sharedMetadata).Return(nil).Times(1)
+		dp.EXPECT().RemoveFromSets([]*ipsets.IPSetMetadata{set}, sharedMetadata).Return(nil).Times(1)
+	}
+	if !util.IsWindowsDP() {
+		for _, name := range []string{"app:test-pod-1", "app:test-pod-2"} {
+			namedPortSet := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(name, ipsets.NamedPorts)}
+			namedPortMetadata := dataplane.NewPodMetadata("hostnetwork/1.2.3.4,TCP:8080", "1.2.3.4,TCP:8080", "")
+			dp.EXPECT().AddToSets(namedPortSet, namedPortMetadata).Return(nil).Times(1)
+			dp.EXPECT().RemoveFromSets(namedPortSet, namedPortMetadata).Return(nil).Times(1)
+		}
+	}
+
+	addPod(t, f, podObj1)
+	addPod(t, f, podObj2)
+	require.Len(t, f.podController.podMap, 2)
+	require.True(t, f.podController.podMap[getKey(podObj1, t)].HostNetwork)
+
+	for _, podObj := range []*corev1.Pod{podObj1, podObj2} {
+		require.NoError(t, f.kubeInformer.Core().V1().Pods().Informer().GetIndexer().Delete(podObj))
+		f.podController.deletePod(podObj)
+		f.podController.processNextWorkItem()
+	}
+	require.Empty(t, f.podController.podMap)
+	require.Empty(t, f.podController.sharedMembers)
+}
+
 func TestDeletePod(t *testing.T) {
 	labels := map[string]string{
 		"app": "test-pod",
@@ -423,7 +479,7 @@ func TestDeletePod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -435,7 +491,7 @@ func TestDeletePod(t *testing.T) {
 		dp.EXPECT().
 			RemoveFromSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -539,7 +595,7 @@ func TestDeletePodWithTombstoneAfterAddingPod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -551,7 +607,7 @@ func TestDeletePodWithTombstoneAfterAddingPod(t *testing.T) {
 		dp.EXPECT().
 			RemoveFromSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -602,7 +658,7 @@ func TestLabelUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -658,7 +714,7 @@ func TestEmptyIPUpdate(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -670,7 +726,7 @@ func TestEmptyIPUpdate(t *testing.T) {
 		dp.EXPECT().
 			RemoveFromSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -751,7 +807,7 @@ func TestIPAddressUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -763,7 +819,7 @@ func TestIPAddressUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			RemoveFromSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -775,7 +831,7 @@ func TestIPAddressUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "4.3.2.1,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "4.3.2.1,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -825,7 +881,7 @@ func TestPodStatusUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			AddToSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -837,7 +893,7 @@ func TestPodStatusUpdatePod(t *testing.T) {
 		dp.EXPECT().
 			RemoveFromSets(
 				[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
-				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,8080", ""),
+				dataplane.NewPodMetadata("test-namespace/test-pod", "1.2.3.4,TCP:8080", ""),
 			).
 			Return(nil).Times(1)
 	}
@@ -900,6 +956,65 @@ func TestHasValidPodIP(t *testing.T) {
 	require.False(t, hasValidPodIP(podObj))
 }
 
+func TestIsValidPodIP(t *testing.T) {
+	c := &PodController{}
+	require.True(t, c.isValidPodIP("1.2.3.4"))
+	require.False(t, c.isValidPodIP("fd00::1"))
+
+	c.SetIPv6Enabled(true)
+	require.True(t, c.isValidPodIP("1.2.3.4"))
+	require.True(t, c.isValidPodIP("fd00::1"))
+	require.False(t, c.isValidPodIP(""))
+	require.False(t, c.isValidPodIP("fd00::zz"))
+}
+
+func TestAddIPv6Pod(t *testing.T) {
+	if util.IsWindowsDP() {
+		t.Skip("IPv6 pods are only supported on Linux")
+	}
+	labels := map[string]string{
+		"app": "test-pod",
+	}
+	podObj := createPod("test-pod", "test-namespace", "0", "fd00::4", labels, NonHostNetwork, corev1.PodRunning)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	f := newFixture(t, dp)
+	f.podLister = append(f.podLister, podObj)
+	f.kubeobjects = append(f.kubeobjects, podObj)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	f.newPodController(stopCh)
+	f.podController.SetIPv6Enabled(true)
+
+	mockIPSets := []*ipsets.IPSetMetadata{
+		ipsets.NewIPSetMetadata("test-namespace", ipsets.Namespace),
+		ipsets.NewIPSetMetadata("app", ipsets.KeyLabelOfPod),
+		ipsets.NewIPSetMetadata("app:test-pod", ipsets.KeyValueLabelOfPod),
+	}
+	podMetadata := dataplane.NewPodMetadata("test-namespace/test-pod", "fd00::4", "")
+
+	dp.EXPECT().AddToLists([]*ipsets.IPSetMetadata{kubeAllNamespaces}, mockIPSets[:1]).Return(nil).Times(1)
+	dp.EXPECT().AddToSets(mockIPSets[:1], podMetadata).Return(nil).Times(1)
+	dp.EXPECT().AddToSets(mockIPSets[1:], podMetadata).Return(nil).Times(1)
+	dp.EXPECT().
+		AddToSets(
+			[]*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata("app:test-pod", ipsets.NamedPorts)},
+			dataplane.NewPodMetadata("test-namespace/test-pod", "fd00::4,TCP:8080", ""),
+		).
+		Return(nil).Times(1)
+	dp.EXPECT().ApplyDataPlane().Return(nil).Times(1)
+
+	addPod(t, f, podObj)
+	testCases := []expectedValues{
+		{1, 1, 0, podPromVals{1, 0, 0}},
+	}
+	checkPodTestResult("TestAddIPv6Pod", f, testCases)
+	checkNpmPodWithInput("TestAddIPv6Pod", f, podObj)
+}
+
 func TestIsCompletePod(t *testing.T) {
 	var zeroGracePeriod int64
 	var defaultGracePeriod int64 = 30
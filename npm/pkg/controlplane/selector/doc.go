@@ -0,0 +1,5 @@
+// Package selector translates the label selectors of network policies to the ipsets which select the same pods and
+// namespaces, and evaluates the translated selectors against labels.
+// It is OS agnostic: the Linux and Windows dataplanes get the same translation, and the capabilities which differ
+// between them are options of the Engine, so that the selectors behave the same on both up to those capabilities.
+package selector
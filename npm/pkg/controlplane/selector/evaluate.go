@@ -0,0 +1,51 @@
+package selector
+
+import (
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// IsMember returns whether a pod or namespace with the labels is a member of the set, as the ipset is populated by
+// the controllers: every namespace is a member of the all-namespaces set.
+func IsMember(set *Set, labels map[string]string) bool {
+	switch set.SetType {
+	case ipsets.KeyLabelOfNamespace, ipsets.KeyLabelOfPod:
+		if set.SetType == ipsets.KeyLabelOfNamespace && set.Name == util.KubeAllNamespacesFlag {
+			return true
+		}
+		_, ok := labels[set.Name]
+		return ok
+	case ipsets.KeyValueLabelOfNamespace, ipsets.KeyValueLabelOfPod:
+		return hasLabelSet(labels, set.Name)
+	case ipsets.NestedLabelOfPod:
+		for _, member := range set.Members {
+			if hasLabelSet(labels, member) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Matches returns whether a pod or namespace with the labels is selected by the translated selector, i.e. whether it
+// is a member of the included sets and of none of the excluded sets.
+func Matches(sets []Set, labels map[string]string) bool {
+	for i := range sets {
+		if IsMember(&sets[i], labels) != sets[i].Include {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLabelSet returns whether one of the labels has the key-value set name.
+func hasLabelSet(labels map[string]string, setName string) bool {
+	for k, v := range labels {
+		if util.GetIpSetFromLabelKV(k, v) == setName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// ErrUnsupportedNegativeMatch is returned when a selector uses the NotIn or DoesNotExist operator and the
+	// dataplane can't match the objects which aren't in a set, as on Windows.
+	ErrUnsupportedNegativeMatch = errors.New("unsupported NotExist operator translation features used on windows")
+	// ErrInvalidMatchExpressionValues ensures proper matchExpression label values since k8s doesn't perform this check.
+	ErrInvalidMatchExpressionValues = errors.New(
+		"matchExpression label values must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character",
+	)
+	// ErrUnsupportedOperator is returned when a matchExpression has an operator which isn't a label selector operator.
+	ErrUnsupportedOperator = errors.New("unsupported matchExpression operator")
+)
+
+// validLabelRegex is defined from the result of kubectl (this includes empty string matches):
+// a valid label must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with
+// an alphanumeric character (e.g. 'MyValue',  or 'my_value',  or '12345', regex used for validation is '(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?'
+var validLabelRegex = regexp.MustCompile("(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?")
+
+// Set is an ipset which the selected pods or namespaces are members of, or with Include false, aren't members of.
+type Set struct {
+	Include bool
+	SetType ipsets.SetType
+	// Name is among
+	// 1. matchKey + ":" + matchVal (can be empty string) case
+	// 2. "matchKey" case
+	// or 3. "matchKey + : + multiple matchVals" case.
+	Name string
+	// Members exists only if SetType is NestedLabelOfPod, and has the names of its KeyValueLabelOfPod sets.
+	Members []string
+}
+
+// Engine translates label selectors to ipsets.
+type Engine struct {
+	// NegativeMatch is whether the dataplane can match the objects which aren't in a set. Without it, the selectors
+	// using the NotIn and DoesNotExist operators are rejected with ErrUnsupportedNegativeMatch.
+	NegativeMatch bool
+}
+
+// NewEngine returns the engine for the dataplane of the OS.
+func NewEngine() Engine {
+	return Engine{NegativeMatch: !util.IsWindowsDP()}
+}
+
+// validate checks the operator and the values of the requirement.
+func (e Engine) validate(req *metav1.LabelSelectorRequirement) error {
+	if !e.NegativeMatch && (req.Operator == metav1.LabelSelectorOpNotIn || req.Operator == metav1.LabelSelectorOpDoesNotExist) {
+		return ErrUnsupportedNegativeMatch
+	}
+	switch req.Operator {
+	case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+		for _, v := range req.Values {
+			if !IsValidLabelValue(v) {
+				return ErrInvalidMatchExpressionValues
+			}
+		}
+	case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedOperator, req.Operator)
+	}
+	return nil
+}
+
+// FlattenNamespaceSelector splits the namespace selector into selectors whose matchExpressions have single values,
+// which are ORed. There are no nested namespace sets, so an In operator with multiple values is split into a selector
+// per value, and a NotIn operator with multiple values into a single-value NotIn per value in every selector.
+// A nil selector selects nothing and is flattened to no selectors.
+//
+// Take below example: this nsSelector has 2 values in an In and 2 values in a NotIn matchExpression.
+//
+//	namespaceSelector:
+//	  matchExpressions:
+//	  - {key: ns, operator: In, values: [netpol-x, netpol-y]}
+//	  - {key: env, operator: NotIn, values: [dev, test]}
+//
+// It is flattened to the two selectors below, which translate policy replicates into two rules, resulting in an OR
+// condition between the In values, and an AND condition between the NotIn values.
+//
+//	namespaceSelector:
+//	  matchExpressions:
+//	  - {key: env, operator: NotIn, values: [dev]}
+//	  - {key: env, operator: NotIn, values: [test]}
+//	  - {key: ns, operator: In, values: [netpol-x]}
+//	namespaceSelector:
+//	  matchExpressions:
+//	  - {key: env, operator: NotIn, values: [dev]}
+//	  - {key: env, operator: NotIn, values: [test]}
+//	  - {key: ns, operator: In, values: [netpol-y]}
+func (e Engine) FlattenNamespaceSelector(nsSelector *metav1.LabelSelector) ([]metav1.LabelSelector, error) {
+	// To avoid any additional length checks, just return a slice of labelSelectors
+	// with original nsSelector
+	if nsSelector == nil {
+		return []metav1.LabelSelector{}, nil
+	}
+
+	// create a baseSelector which needs to be same across all
+	// new labelSelectors
+	baseSelector := &metav1.LabelSelector{
+		MatchLabels:      nsSelector.MatchLabels,
+		MatchExpressions: []metav1.LabelSelectorRequirement{},
+	}
+
+	multiValuePresent := false
+	multiValueMatchExprs := []metav1.LabelSelectorRequirement{}
+	for i := range nsSelector.MatchExpressions {
+		req := nsSelector.MatchExpressions[i]
+		if err := e.validate(&req); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case req.Operator == metav1.LabelSelectorOpIn && len(req.Values) > 1:
+			// for multiple values, it will create a slice of them to be used for Zipping with baseSelector
+			// to create multiple nsSelectors to preserve OR condition across the values
+			multiValuePresent = true
+			multiValueMatchExprs = append(multiValueMatchExprs, req)
+		case req.Operator == metav1.LabelSelectorOpNotIn && len(req.Values) > 1:
+			// a namespace must have none of the values, so each value is excluded in every nsSelector
+			multiValuePresent = true
+			for _, v := range req.Values {
+				baseSelector.MatchExpressions = append(baseSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+					Key:      req.Key,
+					Operator: req.Operator,
+					Values:   []string{v},
+				})
+			}
+		default:
+			// single values, Exists and DoesNotExist can be added to the baseSelector as is
+			baseSelector.MatchExpressions = append(baseSelector.MatchExpressions, req)
+		}
+	}
+
+	// If there are no multiValue NS selector match expressions
+	// return the original NsSelector
+	if !multiValuePresent {
+		return []metav1.LabelSelector{*nsSelector}, nil
+	}
+
+	// Now use the baseSelector and loop over multiValueMatchExprs to create all
+	// combinations of values
+	flatNsSelectors := []metav1.LabelSelector{
+		*baseSelector.DeepCopy(),
+	}
+	for _, req := range multiValueMatchExprs {
+		flatNsSelectors = zipMatchExprs(flatNsSelectors, req)
+	}
+
+	return flatNsSelectors, nil
+}
+
+// zipMatchExprs helps with zipping a given matchExpr with given baseLabelSelectors
+// this func will loop over each baseSelector in the slice,
+// deepCopies each baseSelector, combines with given matchExpr by looping over each value
+// and creating a new LabelSelector with given baseSelector and value matchExpr
+// then returns a new slice of these zipped LabelSelectors
+func zipMatchExprs(baseSelectors []metav1.LabelSelector, matchExpr metav1.LabelSelectorRequirement) []metav1.LabelSelector {
+	zippedLabelSelectors := []metav1.LabelSelector{}
+	for _, selector := range baseSelectors {
+		for _, value := range matchExpr.Values {
+			tempBaseSelector := selector.DeepCopy()
+			tempBaseSelector.MatchExpressions = append(
+				tempBaseSelector.MatchExpressions,
+				metav1.LabelSelectorRequirement{
+					Key:      matchExpr.Key,
+					Operator: matchExpr.Operator,
+					Values:   []string{value},
+				},
+			)
+			zippedLabelSelectors = append(zippedLabelSelectors, *tempBaseSelector)
+		}
+	}
+	return zippedLabelSelectors
+}
+
+// parsedSets maintains slice of unique Set.
+type parsedSets struct {
+	sets []Set
+	// Use set data structure to avoid the duplicate setName among matchLabels and MatchExpression.
+	// The key of labelSet includes "!" if operator is "OpNotIn" or "OpDoesNotExist"
+	// to make difference when it has the same key (and value), but different operator
+	// while this is weird since it is not always matched, but K8s accepts this spec.
+	labelSet map[string]struct{}
+}
+
+func newParsedSets() parsedSets {
+	return parsedSets{
+		sets:     []Set{},
+		labelSet: map[string]struct{}{},
+	}
+}
+
+// add only adds non-duplicated Set.
+// Only nested labels from podSelector has members fields.
+func (ps *parsedSets) add(include bool, setType ipsets.SetType, setName string, members ...string) {
+	setNameWithOp := setName
+	if !include {
+		// adding setType.String() is not necessary, but it has more robust just in case.
+		setNameWithOp = "!" + setName + setType.String()
+	}
+
+	// in case setNameWithOp exists in a set, do not need to add it.
+	if _, exist := ps.labelSet[setNameWithOp]; exist {
+		return
+	}
+
+	ps.sets = append(ps.sets, Set{
+		Include: include,
+		SetType: setType,
+		Name:    setName,
+		Members: members,
+	})
+	ps.labelSet[setNameWithOp] = struct{}{}
+}
+
+// NamespaceSets returns the sets of the namespaces the flattened namespace selector selects, see
+// FlattenNamespaceSelector, which validates the selector. Members are always nil since the In operators of a
+// flattened selector have a single value.
+func (e Engine) NamespaceSets(selector *metav1.LabelSelector) []Set {
+	parsed := newParsedSets()
+
+	// #1. All namespaces case
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		parsed.add(true, ipsets.KeyLabelOfNamespace, util.KubeAllNamespacesFlag)
+		return parsed.sets
+	}
+
+	// #2. MatchLabels
+	for matchKey, matchVal := range selector.MatchLabels {
+		// matchKey + ":" + matchVal (can be empty string) case
+		setName := util.GetIpSetFromLabelKV(matchKey, matchVal)
+		parsed.add(true, ipsets.KeyValueLabelOfNamespace, setName)
+	}
+
+	// #3. MatchExpressions
+	for _, req := range selector.MatchExpressions {
+		include := isPositive(req.Operator)
+		switch req.Operator {
+		case metav1.LabelSelectorOpIn:
+			// "matchKey + : + matchVal" case
+			parsed.add(include, ipsets.KeyValueLabelOfNamespace, util.GetIpSetFromLabelKV(req.Key, req.Values[0]))
+		case metav1.LabelSelectorOpNotIn:
+			// "! + matchKey + : + matchVal" case, for each value
+			for _, v := range req.Values {
+				parsed.add(include, ipsets.KeyValueLabelOfNamespace, util.GetIpSetFromLabelKV(req.Key, v))
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			// "(!) + matchKey" case
+			parsed.add(include, ipsets.KeyLabelOfNamespace, req.Key)
+		}
+	}
+
+	return parsed.sets
+}
+
+// PodSets returns the sets of the pods the pod selector selects. The In and NotIn operators with multiple values
+// are translated to a NestedLabelOfPod set of the KeyValueLabelOfPod sets of the values, named after the policy.
+func (e Engine) PodSets(policyKey string, selector *metav1.LabelSelector) ([]Set, error) {
+	parsed := newParsedSets()
+
+	// #1. MatchLabels
+	for matchKey, matchVal := range selector.MatchLabels {
+		// matchKey + ":" + matchVal (can be empty string) case
+		setName := util.GetIpSetFromLabelKV(matchKey, matchVal)
+		parsed.add(true, ipsets.KeyValueLabelOfPod, setName)
+	}
+
+	// #2. MatchExpressions
+	for i := range selector.MatchExpressions {
+		req := &selector.MatchExpressions[i]
+		if err := e.validate(req); err != nil {
+			return nil, err
+		}
+
+		var setName string
+		var setType ipsets.SetType
+		var members []string
+		switch req.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+			// "(!) + matchKey + : + matchVal" case
+			if len(req.Values) == 1 {
+				setName = util.GetIpSetFromLabelKV(req.Key, req.Values[0])
+				setType = ipsets.KeyValueLabelOfPod
+			} else {
+				// "(!) + matchKey + : + multiple matchVals" case
+				// see caveat in definition of TranslatedIPSet for why the policy key must be included in the set name
+				setName = fmt.Sprintf("%s-%s", policyKey, req.Key)
+				for _, val := range req.Values {
+					setName = util.GetIpSetFromLabelKV(setName, val)
+					members = append(members, util.GetIpSetFromLabelKV(req.Key, val))
+				}
+				setType = ipsets.NestedLabelOfPod
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			// "(!) + matchKey" case
+			setName = req.Key
+			setType = ipsets.KeyLabelOfPod
+		}
+
+		parsed.add(isPositive(req.Operator), setType, setName, members...)
+	}
+
+	return parsed.sets, nil
+}
+
+// isPositive returns whether the operator selects the objects in a set, rather than the objects which aren't.
+func isPositive(op metav1.LabelSelectorOperator) bool {
+	return op == metav1.LabelSelectorOpIn || op == metav1.LabelSelectorOpExists
+}
+
+// IsValidLabelValue ensures the string is empty or satisfies validLabelRegex.
+// Given that v != "", ReplaceAllString() would yield "" when v matches this regex exactly once.
+func IsValidLabelValue(v string) bool {
+	matches := validLabelRegex.FindAllStringIndex(v, -1)
+	// v = "abc-123" would produce [[0 7]], which satisfies the below
+	// v = "" will produce [[0 0]], which satisfies the below
+	// v = "$" would produce [[0 0] [1 1]], which would fail the below
+	// v = "abc$" would produce [[0 3] [4 4]], which would fail the below
+	return len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(v)
+}
@@ -1,18 +1,26 @@
-package translation
+package selector
 
 import (
 	"fmt"
+	"math/rand"
 	"reflect"
 	"testing"
+	"testing/quick"
 
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// testEngine is the engine of a dataplane with negative matches, as on Linux.
+var testEngine = Engine{NegativeMatch: true}
+
 func TestFlattenNameSpaceSelectorCases(t *testing.T) {
 	firstSelector := &metav1.LabelSelector{}
 
-	testSelectors, err := flattenNameSpaceSelector(firstSelector)
+	testSelectors, err := testEngine.FlattenNamespaceSelector(firstSelector)
 	require.Nil(t, err)
 	if len(testSelectors) != 1 {
 		t.Errorf("TestFlattenNameSpaceSelectorCases failed @ 1st selector length check %+v", testSelectors)
@@ -20,7 +28,7 @@ func TestFlattenNameSpaceSelectorCases(t *testing.T) {
 
 	var secondSelector *metav1.LabelSelector
 
-	testSelectors, err = flattenNameSpaceSelector(secondSelector)
+	testSelectors, err = testEngine.FlattenNamespaceSelector(secondSelector)
 	require.Nil(t, err)
 	if len(testSelectors) > 0 {
 		t.Errorf("TestFlattenNameSpaceSelectorCases failed @ 1st selector length check %+v", testSelectors)
@@ -65,7 +73,7 @@ func TestFlattenNameSpaceSelector(t *testing.T) {
 		MatchLabels: commonMatchLabel,
 	}
 
-	testSelectors, err := flattenNameSpaceSelector(firstSelector)
+	testSelectors, err := testEngine.FlattenNamespaceSelector(firstSelector)
 	require.Nil(t, err)
 	if len(testSelectors) != 1 {
 		t.Errorf("TestFlattenNameSpaceSelector failed @ 1st selector length check %+v", testSelectors)
@@ -110,7 +118,7 @@ func TestFlattenNameSpaceSelector(t *testing.T) {
 		MatchLabels: commonMatchLabel,
 	}
 
-	testSelectors, err = flattenNameSpaceSelector(secondSelector)
+	testSelectors, err = testEngine.FlattenNamespaceSelector(secondSelector)
 	require.Nil(t, err)
 	if len(testSelectors) != 8 {
 		t.Errorf("TestFlattenNameSpaceSelector failed @ 2nd selector length check %+v", testSelectors)
@@ -405,7 +413,7 @@ func TestFlattenNameSpaceSelectorWoMatchLabels(t *testing.T) {
 		},
 	}
 
-	testSelectors, err := flattenNameSpaceSelector(firstSelector)
+	testSelectors, err := testEngine.FlattenNamespaceSelector(firstSelector)
 	require.Nil(t, err)
 	if len(testSelectors) != 2 {
 		t.Errorf("TestFlattenNameSpaceSelector failed @ 1st selector length check %+v", testSelectors)
@@ -587,7 +595,7 @@ func TestFlattenNamespaceSelectorError(t *testing.T) {
 	for i, tt := range tests {
 		tt := tt
 		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
-			s, err := flattenNameSpaceSelector(tt.selector)
+			s, err := testEngine.FlattenNamespaceSelector(tt.selector)
 			if tt.wantErr {
 				require.Error(t, err)
 				require.Nil(t, s)
@@ -614,7 +622,7 @@ func TestIsValidLabel(t *testing.T) {
 	}
 
 	for _, g := range good {
-		require.True(t, isValidLabelValue(g), "string was [%s]", g)
+		require.True(t, IsValidLabelValue(g), "string was [%s]", g)
 	}
 
 	bad := []string{
@@ -632,6 +640,193 @@ func TestIsValidLabel(t *testing.T) {
 	}
 
 	for _, b := range bad {
-		require.False(t, isValidLabelValue(b), "string was [%s]", b)
+		require.False(t, IsValidLabelValue(b), "string was [%s]", b)
+	}
+}
+
+func TestFlattenNamespaceSelectorNotIn(t *testing.T) {
+	// a namespace selected by NotIn must have none of the values, so the values aren't split into ORed selectors
+	selectors, err := testEngine.FlattenNamespaceSelector(&metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "env", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"dev", "test"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []metav1.LabelSelector{
+		{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "env", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"dev"}},
+				{Key: "env", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"test"}},
+			},
+		},
+	}, selectors)
+}
+
+func TestSetsOperators(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     metav1.LabelSelectorRequirement
+		podSets []Set
+		nsSets  []Set
+	}{
+		{
+			name:    "in",
+			req:     metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpIn, Values: []string{"v"}},
+			podSets: []Set{{Include: true, SetType: ipsets.KeyValueLabelOfPod, Name: "k:v"}},
+			nsSets:  []Set{{Include: true, SetType: ipsets.KeyValueLabelOfNamespace, Name: "k:v"}},
+		},
+		{
+			name:    "not in",
+			req:     metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"v"}},
+			podSets: []Set{{Include: false, SetType: ipsets.KeyValueLabelOfPod, Name: "k:v"}},
+			nsSets:  []Set{{Include: false, SetType: ipsets.KeyValueLabelOfNamespace, Name: "k:v"}},
+		},
+		{
+			name:    "exists",
+			req:     metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpExists},
+			podSets: []Set{{Include: true, SetType: ipsets.KeyLabelOfPod, Name: "k"}},
+			nsSets:  []Set{{Include: true, SetType: ipsets.KeyLabelOfNamespace, Name: "k"}},
+		},
+		{
+			name:    "does not exist",
+			req:     metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpDoesNotExist},
+			podSets: []Set{{Include: false, SetType: ipsets.KeyLabelOfPod, Name: "k"}},
+			nsSets:  []Set{{Include: false, SetType: ipsets.KeyLabelOfNamespace, Name: "k"}},
+		},
+		{
+			name: "in with multiple values",
+			req:  metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpIn, Values: []string{"v1", "v2"}},
+			podSets: []Set{
+				{Include: true, SetType: ipsets.NestedLabelOfPod, Name: "ns/policy-k:v1:v2", Members: []string{"k:v1", "k:v2"}},
+			},
+		},
+		{
+			name: "not in with multiple values",
+			req:  metav1.LabelSelectorRequirement{Key: "k", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"v1", "v2"}},
+			podSets: []Set{
+				{Include: false, SetType: ipsets.NestedLabelOfPod, Name: "ns/policy-k:v1:v2", Members: []string{"k:v1", "k:v2"}},
+			},
+			nsSets: []Set{
+				{Include: false, SetType: ipsets.KeyValueLabelOfNamespace, Name: "k:v1"},
+				{Include: false, SetType: ipsets.KeyValueLabelOfNamespace, Name: "k:v2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sel := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{tt.req}}
+			podSets, err := testEngine.PodSets("ns/policy", sel)
+			require.NoError(t, err)
+			require.Equal(t, tt.podSets, podSets)
+
+			if tt.nsSets != nil {
+				require.Equal(t, tt.nsSets, testEngine.NamespaceSets(sel))
+			}
+
+			// without negative matches, the NotIn and DoesNotExist operators are rejected for pods and namespaces
+			windows := Engine{}
+			_, podErr := windows.PodSets("ns/policy", sel)
+			_, nsErr := windows.FlattenNamespaceSelector(sel)
+			if isPositive(tt.req.Operator) {
+				require.NoError(t, podErr)
+				require.NoError(t, nsErr)
+			} else {
+				require.ErrorIs(t, podErr, ErrUnsupportedNegativeMatch)
+				require.ErrorIs(t, nsErr, ErrUnsupportedNegativeMatch)
+			}
+		})
+	}
+}
+
+func TestUnsupportedOperator(t *testing.T) {
+	sel := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "k", Operator: "Gt", Values: []string{"1"}}},
+	}
+	_, err := testEngine.PodSets("ns/policy", sel)
+	require.ErrorIs(t, err, ErrUnsupportedOperator)
+	_, err = testEngine.FlattenNamespaceSelector(sel)
+	require.ErrorIs(t, err, ErrUnsupportedOperator)
+}
+
+func TestAllNamespaces(t *testing.T) {
+	sets := testEngine.NamespaceSets(&metav1.LabelSelector{})
+	require.Equal(t, []Set{{Include: true, SetType: ipsets.KeyLabelOfNamespace, Name: util.KubeAllNamespacesFlag}}, sets)
+	require.True(t, Matches(sets, nil))
+}
+
+var (
+	testKeys   = []string{"app", "env", "tier"}
+	testValues = []string{"", "a", "b", "c"}
+	testOps    = []metav1.LabelSelectorOperator{
+		metav1.LabelSelectorOpIn,
+		metav1.LabelSelectorOpNotIn,
+		metav1.LabelSelectorOpExists,
+		metav1.LabelSelectorOpDoesNotExist,
+	}
+)
+
+// selectorCase is a random selector and random labels, over few keys and values so that they often match.
+type selectorCase struct {
+	Selector *metav1.LabelSelector
+	Labels   map[string]string
+}
+
+func (selectorCase) Generate(r *rand.Rand, _ int) reflect.Value {
+	c := selectorCase{
+		Selector: &metav1.LabelSelector{},
+		Labels:   map[string]string{},
+	}
+	for _, k := range testKeys {
+		if r.Intn(2) == 0 {
+			c.Labels[k] = testValues[r.Intn(len(testValues))]
+		}
+	}
+	if r.Intn(3) == 0 {
+		c.Selector.MatchLabels = map[string]string{testKeys[r.Intn(len(testKeys))]: testValues[r.Intn(len(testValues))]}
+	}
+	for i := r.Intn(4); i > 0; i-- {
+		req := metav1.LabelSelectorRequirement{Key: testKeys[r.Intn(len(testKeys))], Operator: testOps[r.Intn(len(testOps))]}
+		if req.Operator == metav1.LabelSelectorOpIn || req.Operator == metav1.LabelSelectorOpNotIn {
+			for _, j := range r.Perm(len(testValues))[:1+r.Intn(len(testValues))] {
+				req.Values = append(req.Values, testValues[j])
+			}
+		}
+		c.Selector.MatchExpressions = append(c.Selector.MatchExpressions, req)
+	}
+	return reflect.ValueOf(c)
+}
+
+// k8sMatches returns whether the selector selects the labels as Kubernetes does.
+func k8sMatches(t *testing.T, sel *metav1.LabelSelector, l map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	require.NoError(t, err)
+	return s.Matches(labels.Set(l))
+}
+
+// the translated pod selectors select the same pods as Kubernetes
+func TestPodSetsMatchKubernetes(t *testing.T) {
+	property := func(c selectorCase) bool {
+		sets, err := testEngine.PodSets("ns/policy", c.Selector)
+		require.NoError(t, err)
+		return Matches(sets, c.Labels) == k8sMatches(t, c.Selector, c.Labels)
+	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 5000, Rand: rand.New(rand.NewSource(1))})) //nolint:gosec // test
+}
+
+// the flattened namespace selectors select the same namespaces as Kubernetes
+func TestNamespaceSetsMatchKubernetes(t *testing.T) {
+	property := func(c selectorCase) bool {
+		flattened, err := testEngine.FlattenNamespaceSelector(c.Selector)
+		require.NoError(t, err)
+		matches := false
+		for i := range flattened {
+			if Matches(testEngine.NamespaceSets(&flattened[i]), c.Labels) {
+				matches = true
+			}
+		}
+		return matches == k8sMatches(t, c.Selector, c.Labels)
 	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 5000, Rand: rand.New(rand.NewSource(1))})) //nolint:gosec // test
 }
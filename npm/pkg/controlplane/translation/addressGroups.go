@@ -0,0 +1,90 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+const (
+	// AddressGroupsAnnotation maps ipBlock CIDRs of a network policy to the AddressGroups replacing them.
+	// Its value is a comma-separated list of "<cidr>=<group>" pairs, e.g. "198.51.100.1/32=corp-ranges".
+	// An ipBlock peer whose CIDR is listed (and which has no excepts) matches the CIDRs of the AddressGroup instead,
+	// so the placeholder CIDR should be an address no traffic comes from, e.g. from a documentation range.
+	AddressGroupsAnnotation = "npm.azure.com/address-groups"
+
+	addressGroupSetPrefix = "addressgroup-"
+)
+
+var (
+	// ErrInvalidAddressGroups is returned when the AddressGroupsAnnotation of a network policy is malformed.
+	ErrInvalidAddressGroups = errors.New("invalid address groups annotation")
+	// ErrAddressGroupWithExcept is returned when an ipBlock referencing an AddressGroup has excepts.
+	ErrAddressGroupWithExcept = errors.New("ipBlock referencing an address group can't have excepts")
+)
+
+// AddressGroupSetMetadata returns the metadata of the CIDR ipset shared by every policy referencing the AddressGroup.
+func AddressGroupSetMetadata(group string) *ipsets.IPSetMetadata {
+	return ipsets.NewIPSetMetadata(addressGroupSetPrefix+group, ipsets.CIDRBlocks)
+}
+
+// AddressGroupMembers returns the ipset members for the CIDRs of an AddressGroup, and the CIDRs which aren't supported.
+// The CIDRs are compacted, and like in ipBlocks, CIDRs matching everything are split into halves, since ipset doesn't
+// allow them.
+func AddressGroupMembers(cidrs []string) (members, unsupported []string) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !util.IsIPV4(cidr) && (util.IsWindowsDP() || !util.IsIPV6(cidr)) {
+			unsupported = append(unsupported, cidr)
+			continue
+		}
+		prefix, err := parseCIDR(cidr)
+		if err != nil {
+			unsupported = append(unsupported, cidr)
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	for _, prefix := range compactCIDRs(prefixes) {
+		for _, member := range ipsetCIDRs(prefix) {
+			members = append(members, member.String())
+		}
+	}
+	return members, unsupported
+}
+
+// addressGroups parses the AddressGroupsAnnotation into a map of placeholder CIDRs to AddressGroup names.
+func addressGroups(annotations map[string]string) (map[string]string, error) {
+	value, ok := annotations[AddressGroupsAnnotation]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	groups := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		cidr, group, found := strings.Cut(strings.TrimSpace(pair), "=")
+		cidr, group = strings.TrimSpace(cidr), strings.TrimSpace(group)
+		if !found || cidr == "" || group == "" {
+			return nil, fmt.Errorf("%w: %q is not a <cidr>=<group> pair", ErrInvalidAddressGroups, pair)
+		}
+		if existing, ok := groups[cidr]; ok && existing != group {
+			return nil, fmt.Errorf("%w: %s refers to both %s and %s", ErrInvalidAddressGroups, cidr, existing, group)
+		}
+		groups[cidr] = group
+	}
+	return groups, nil
+}
+
+// addressGroupRule returns the shared ipset of the AddressGroup and its SetInfo.
+// The ipset has no members here: they are owned by the AddressGroup controller.
+func addressGroupRule(group string, matchType policies.MatchType) (*ipsets.TranslatedIPSet, policies.SetInfo) {
+	setMetadata := AddressGroupSetMetadata(group)
+	addressGroupIPSet := ipsets.NewTranslatedIPSet(setMetadata.Name, ipsets.CIDRBlocks)
+	setInfo := policies.NewSetInfo(setMetadata.Name, ipsets.CIDRBlocks, included, matchType)
+	return addressGroupIPSet, setInfo
+}
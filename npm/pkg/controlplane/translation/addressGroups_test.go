@@ -0,0 +1,114 @@
+package translation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAddressGroupsAnnotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		groups  map[string]string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "multiple pairs",
+			value:  "198.51.100.1/32=corp-ranges, 198.51.100.2/32 = partners",
+			groups: map[string]string{"198.51.100.1/32": "corp-ranges", "198.51.100.2/32": "partners"},
+		},
+		{
+			name:   "repeated pair",
+			value:  "198.51.100.1/32=corp-ranges,198.51.100.1/32=corp-ranges",
+			groups: map[string]string{"198.51.100.1/32": "corp-ranges"},
+		},
+		{
+			name:    "missing group",
+			value:   "198.51.100.1/32=",
+			wantErr: true,
+		},
+		{
+			name:    "not a pair",
+			value:   "corp-ranges",
+			wantErr: true,
+		},
+		{
+			name:    "cidr refers to two groups",
+			value:   "198.51.100.1/32=corp-ranges,198.51.100.1/32=partners",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := addressGroups(map[string]string{AddressGroupsAnnotation: tt.value})
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidAddressGroups)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.groups, groups)
+		})
+	}
+}
+
+func TestTranslatePolicyWithAddressGroups(t *testing.T) {
+	netPol := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "allow-corp",
+			Namespace:   defaultNS,
+			Annotations: map[string]string{AddressGroupsAnnotation: "198.51.100.1/32=corp-ranges"},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{IPBlock: &networkingv1.IPBlock{CIDR: "198.51.100.1/32"}},
+						{IPBlock: &networkingv1.IPBlock{CIDR: "172.17.0.0/16"}},
+					},
+				},
+			},
+		},
+	}
+
+	npmNetPol, err := TranslatePolicy(netPol)
+	require.NoError(t, err)
+
+	groupSet := ipsets.NewTranslatedIPSet("addressgroup-corp-ranges", ipsets.CIDRBlocks)
+	ipBlockSet := ipsets.NewTranslatedIPSet("allow-corp-in-ns-default-0-1IN", ipsets.CIDRBlocks, "172.17.0.0/16")
+	require.Equal(t, []*ipsets.TranslatedIPSet{groupSet, ipBlockSet}, npmNetPol.RuleIPSets)
+
+	groupSetInfo := policies.NewSetInfo("addressgroup-corp-ranges", ipsets.CIDRBlocks, included, policies.SrcMatch)
+	require.Equal(t, []policies.SetInfo{groupSetInfo}, npmNetPol.ACLs[0].SrcList)
+
+	netPol.Spec.Ingress[0].From[0].IPBlock.Except = []string{"198.51.100.1/32"}
+	_, err = TranslatePolicy(netPol)
+	require.True(t, errors.Is(err, ErrAddressGroupWithExcept))
+}
+
+func TestAddressGroupMembers(t *testing.T) {
+	members, unsupported := AddressGroupMembers([]string{"10.0.0.0/8", "0.0.0.0/0", "10.0.0.0/8", "not-a-cidr", "2001:db8::/32"})
+	if util.IsWindowsDP() {
+		require.Equal(t, []string{"0.0.0.0/1", "128.0.0.0/1"}, members)
+		require.Equal(t, []string{"not-a-cidr", "2001:db8::/32"}, unsupported)
+		return
+	}
+	require.Equal(t, []string{"0.0.0.0/1", "128.0.0.0/1", "2001:db8::/32"}, members)
+	require.Equal(t, []string{"not-a-cidr"}, unsupported)
+
+	// adjacent and overlapping CIDRs are merged
+	members, _ = AddressGroupMembers([]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.0.5", "10.0.2.0/23", "192.168.1.7/16"})
+	require.Equal(t, []string{"10.0.0.0/22", "192.168.0.0/16"}, members)
+}
@@ -0,0 +1,42 @@
+package translation
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PolicyModeAnnotation sets how the network policies of a namespace are applied: "enforce" (the default) drops the
+	// traffic the policies don't allow, and "audit" only logs it, so that operators can observe which traffic would be
+	// denied before enforcing the policies. Audit mode is Linux only.
+	PolicyModeAnnotation = "npm.azure.com/policy-mode"
+
+	PolicyModeEnforce = "enforce"
+	PolicyModeAudit   = "audit"
+)
+
+// ErrUnsupportedAuditMode is returned when a network policy of a namespace in audit mode is translated on Windows.
+var ErrUnsupportedAuditMode = errors.New("unsupported audit policy mode used on windows")
+
+// IsAuditNamespace returns whether the network policies of the namespace are in audit mode.
+func IsAuditNamespace(ns *corev1.Namespace) bool {
+	return ns != nil && strings.EqualFold(ns.Annotations[PolicyModeAnnotation], PolicyModeAudit)
+}
+
+// AuditPolicy turns the translated policy into an audit one, whose ACLs log the traffic they would drop instead.
+// Its allow ACLs are unchanged, so that only the traffic the policy denies is logged.
+func AuditPolicy(npmNetPol *policies.NPMNetworkPolicy) error {
+	if util.IsWindowsDP() {
+		return ErrUnsupportedAuditMode
+	}
+	for _, acl := range npmNetPol.ACLs {
+		if acl.Target == policies.Dropped {
+			acl.Target = policies.Audited
+		}
+	}
+	return nil
+}
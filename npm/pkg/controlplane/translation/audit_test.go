@@ -0,0 +1,51 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsAuditNamespace(t *testing.T) {
+	require.False(t, IsAuditNamespace(nil))
+	require.False(t, IsAuditNamespace(&corev1.Namespace{}))
+	require.False(t, IsAuditNamespace(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{PolicyModeAnnotation: PolicyModeEnforce}},
+	}))
+	require.True(t, IsAuditNamespace(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{PolicyModeAnnotation: "Audit"}},
+	}))
+}
+
+func TestAuditPolicy(t *testing.T) {
+	npObj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-ingress", Namespace: "x"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}},
+			},
+		},
+	}
+	npmNetPol, err := TranslatePolicy(npObj)
+	require.NoError(t, err)
+
+	err = AuditPolicy(npmNetPol)
+	if util.IsWindowsDP() {
+		require.ErrorIs(t, err, ErrUnsupportedAuditMode)
+		return
+	}
+	require.NoError(t, err)
+	targets := make([]policies.Verdict, 0, len(npmNetPol.ACLs))
+	for _, acl := range npmNetPol.ACLs {
+		targets = append(targets, acl.Target)
+	}
+	require.Equal(t, []policies.Verdict{policies.Allowed, policies.Audited}, targets)
+	policies.NormalizePolicy(npmNetPol)
+	require.NoError(t, policies.ValidatePolicy(npmNetPol))
+}
@@ -0,0 +1,182 @@
+package translation
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// parseCIDR parses an ipBlock CIDR, or an IP as a single-address CIDR, into its canonical form without host bits.
+func parseCIDR(cidr string) (netip.Prefix, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %s", ErrUnsupportedIPAddress, cidr)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrUnsupportedIPAddress, cidr)
+	}
+	return prefix.Masked(), nil
+}
+
+// compactCIDRs returns the smallest sorted list of CIDRs matching the same addresses: the CIDRs contained in others
+// are dropped and the adjacent halves of a CIDR are merged into it.
+func compactCIDRs(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, len(prefixes))
+	copy(sorted, prefixes)
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	compacted := make([]netip.Prefix, 0, len(sorted))
+	for _, prefix := range sorted {
+		// a CIDR overlapping the previous one starts within it, so it's contained in it
+		if n := len(compacted); n > 0 && compacted[n-1].Overlaps(prefix) {
+			continue
+		}
+		compacted = append(compacted, prefix)
+		for n := len(compacted); n > 1; n = len(compacted) {
+			parent, ok := mergeHalves(compacted[n-2], compacted[n-1])
+			if !ok {
+				break
+			}
+			compacted = append(compacted[:n-2], parent)
+		}
+	}
+	return compacted
+}
+
+// mergeHalves returns the CIDR whose lower and upper halves are lo and hi.
+func mergeHalves(lo, hi netip.Prefix) (netip.Prefix, bool) {
+	if lo.Bits() != hi.Bits() || lo.Bits() == 0 || lo.Addr().BitLen() != hi.Addr().BitLen() {
+		return netip.Prefix{}, false
+	}
+	parent := netip.PrefixFrom(lo.Addr(), lo.Bits()-1).Masked()
+	if parent.Addr() != lo.Addr() || !parent.Contains(hi.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return parent, true
+}
+
+// splitHalves returns the lower and upper halves of the CIDR, which must not be a single address.
+func splitHalves(prefix netip.Prefix) (lo, hi netip.Prefix) {
+	bits := prefix.Bits()
+	b := prefix.Addr().AsSlice()
+	b[bits/8] |= 0x80 >> (bits % 8)
+	upper, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(prefix.Addr(), bits+1), netip.PrefixFrom(upper, bits+1)
+}
+
+// subtractCIDRs returns the sorted CIDRs matching the addresses of the CIDR which none of the excepts match. It gives up
+// and returns false once there are more than limit of them.
+func subtractCIDRs(prefix netip.Prefix, excepts []netip.Prefix, limit int) ([]netip.Prefix, bool) {
+	var remaining []netip.Prefix
+	var subtract func(prefix netip.Prefix, excepts []netip.Prefix) bool
+	subtract = func(prefix netip.Prefix, excepts []netip.Prefix) bool {
+		var overlapping []netip.Prefix
+		for _, except := range excepts {
+			if !except.Overlaps(prefix) {
+				continue
+			}
+			if except.Bits() <= prefix.Bits() {
+				return true
+			}
+			overlapping = append(overlapping, except)
+		}
+		if len(overlapping) == 0 {
+			remaining = append(remaining, prefix)
+			return len(remaining) <= limit
+		}
+		lo, hi := splitHalves(prefix)
+		return subtract(lo, overlapping) && subtract(hi, overlapping)
+	}
+	if !subtract(prefix, excepts) {
+		return nil, false
+	}
+	return remaining, true
+}
+
+// ipsetCIDRs splits the CIDRs matching everything into halves, since ipset doesn't allow them.
+func ipsetCIDRs(prefix netip.Prefix) []netip.Prefix {
+	if prefix.Bits() != 0 {
+		return []netip.Prefix{prefix}
+	}
+	lo, hi := splitHalves(prefix)
+	return []netip.Prefix{lo, hi}
+}
+
+// ipBlockMembers returns the members of the ipset of an ipBlock, with the fewest members of:
+//   - the CIDR with the excepts within it as nomatch members, which ipset matches as the most specific CIDR, or
+//   - the CIDRs left once the excepts are subtracted from the CIDR.
+//
+// The excepts are compacted first, so that an ipBlock with a long list of them takes as few members as possible.
+func ipBlockMembers(cidr string, excepts []string) ([]string, error) {
+	prefix, err := parseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	exceptPrefixes := make([]netip.Prefix, 0, len(excepts))
+	for _, except := range excepts {
+		exceptPrefix, err := parseCIDR(except)
+		if err != nil {
+			return nil, err
+		}
+		exceptPrefixes = append(exceptPrefixes, exceptPrefix)
+	}
+	exceptPrefixes = compactCIDRs(exceptPrefixes)
+
+	// the halves of a CIDR matching everything which an except matches entirely can't be members with it
+	var bases, nomatches []netip.Prefix
+	for _, base := range ipsetCIDRs(prefix) {
+		if !containedInAny(base, exceptPrefixes) {
+			bases = append(bases, base)
+		}
+	}
+	for _, except := range exceptPrefixes {
+		for _, base := range bases {
+			if base.Overlaps(except) {
+				nomatches = append(nomatches, except)
+				break
+			}
+		}
+	}
+
+	limit := len(bases) + len(nomatches)
+	remaining := make([]netip.Prefix, 0, limit)
+	for _, base := range bases {
+		subtracted, ok := subtractCIDRs(base, nomatches, limit-len(remaining))
+		if !ok {
+			members := make([]string, 0, limit)
+			for _, base := range bases {
+				members = append(members, base.String())
+			}
+			for _, except := range nomatches {
+				members = append(members, exceptCidr(except.String()))
+			}
+			return members, nil
+		}
+		remaining = append(remaining, subtracted...)
+	}
+
+	members := make([]string, 0, len(remaining))
+	for _, member := range remaining {
+		members = append(members, member.String())
+	}
+	return members, nil
+}
+
+func containedInAny(prefix netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, p := range prefixes {
+		if p.Bits() <= prefix.Bits() && p.Overlaps(prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package translation
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func prefixes(cidrs ...string) []netip.Prefix {
+	result := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		result = append(result, netip.MustParsePrefix(cidr))
+	}
+	return result
+}
+
+func TestCompactCIDRs(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []netip.Prefix
+		want  []netip.Prefix
+	}{
+		{
+			name:  "nested",
+			cidrs: prefixes("10.0.0.0/24", "10.0.0.0/8", "10.0.0.0/8"),
+			want:  prefixes("10.0.0.0/8"),
+		},
+		{
+			name:  "adjacent halves merged recursively",
+			cidrs: prefixes("10.0.0.3/32", "10.0.0.0/31", "10.0.0.2/32", "10.0.0.4/30"),
+			want:  prefixes("10.0.0.0/29"),
+		},
+		{
+			name:  "adjacent but not halves of a CIDR",
+			cidrs: prefixes("10.0.1.0/24", "10.0.2.0/24"),
+			want:  prefixes("10.0.1.0/24", "10.0.2.0/24"),
+		},
+		{
+			name:  "families are compacted separately",
+			cidrs: prefixes("::/1", "0.0.0.0/1", "8000::/1", "128.0.0.0/1"),
+			want:  prefixes("0.0.0.0/0", "::/0"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, compactCIDRs(tt.cidrs))
+		})
+	}
+}
+
+func TestSubtractCIDRs(t *testing.T) {
+	remaining, ok := subtractCIDRs(netip.MustParsePrefix("10.0.0.0/24"), prefixes("10.0.0.0/25", "10.0.0.192/27"), 10)
+	require.True(t, ok)
+	require.Equal(t, prefixes("10.0.0.128/26", "10.0.0.224/27"), remaining)
+
+	remaining, ok = subtractCIDRs(netip.MustParsePrefix("10.0.0.0/24"), prefixes("10.0.0.0/16"), 10)
+	require.True(t, ok)
+	require.Empty(t, remaining)
+
+	// subtracting a single address from a /8 leaves 24 CIDRs
+	_, ok = subtractCIDRs(netip.MustParsePrefix("10.0.0.0/8"), prefixes("10.0.0.1/32"), 2)
+	require.False(t, ok)
+}
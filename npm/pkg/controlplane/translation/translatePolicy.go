@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/selector"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -23,19 +24,20 @@ var (
 	// ErrUnsupportedNamedPort is returned when named port translation feature is used in windows.
 	ErrUnsupportedNamedPort = errors.New("unsupported namedport translation features used on windows")
 	// ErrUnsupportedNegativeMatch is returned when negative match translation feature is used in windows.
-	ErrUnsupportedNegativeMatch = errors.New("unsupported NotExist operator translation features used on windows")
+	ErrUnsupportedNegativeMatch = selector.ErrUnsupportedNegativeMatch
 	// ErrUnsupportedExceptCIDR is returned when Except CIDR block translation feature is used in windows.
 	ErrUnsupportedExceptCIDR = errors.New("unsupported Except CIDR block translation features used on windows")
 	// ErrUnsupportedSCTP is returned when SCTP protocol is used in windows.
 	ErrUnsupportedSCTP = errors.New("unsupported SCTP protocol used on windows")
 	// ErrInvalidMatchExpressionValues ensures proper matchExpression label values since k8s doesn't perform this check.
-	ErrInvalidMatchExpressionValues = errors.New(
-		"matchExpression label values must be an empty string or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character",
-	)
-	// ErrUnsupportedIPAddress is returned when an unsupported IP address, such as IPV6, is used
+	ErrInvalidMatchExpressionValues = selector.ErrInvalidMatchExpressionValues
+	// ErrUnsupportedIPAddress is returned when an unsupported IP address, such as IPV6 on Windows, is used
 	ErrUnsupportedIPAddress = errors.New("unsupported IP address")
 )
 
+// selectorEngine translates the label selectors of the policies for the dataplane of the OS.
+var selectorEngine = selector.NewEngine()
+
 type podSelectorResult struct {
 	psSets      []*ipsets.TranslatedIPSet
 	childPSSets []*ipsets.TranslatedIPSet
@@ -150,68 +152,21 @@ func exceptCidr(exceptCidr string) string {
 	return exceptCidr + " " + util.IpsetNomatch
 }
 
-// deDuplicateExcept removes redundance elements and return slices which has only unique element.
-func deDuplicateExcept(exceptInIPBlock []string) []string {
-	deDupExcepts := []string{}
-	exceptsSet := make(map[string]struct{})
-	for _, except := range exceptInIPBlock {
-		if _, exist := exceptsSet[except]; !exist {
-			deDupExcepts = append(deDupExcepts, except)
-			exceptsSet[except] = struct{}{}
-		}
-	}
-	return deDupExcepts
-}
-
 // ipBlockIPSet return translatedIPSet based based on ipBlockRule.
+// Ipset doesn't allow 0.0.0.0/0 or ::/0 to be added, so they are split in halves, and the excepts are compacted
+// (see ipBlockMembers).
 func ipBlockIPSet(policyName, ns string, direction policies.Direction, ipBlockSetIndex, ipBlockPeerIndex int, ipBlockRule *networkingv1.IPBlock) (*ipsets.TranslatedIPSet, error) {
 	if ipBlockRule == nil || ipBlockRule.CIDR == "" {
 		return nil, nil
 	}
 
-	// de-duplicated Except if there are redundance elements.
-	deDupExcepts := deDuplicateExcept(ipBlockRule.Except)
-	lenOfDeDupExcepts := len(deDupExcepts)
-
-	if util.IsWindowsDP() && lenOfDeDupExcepts > 0 {
+	if util.IsWindowsDP() && len(ipBlockRule.Except) > 0 {
 		return nil, ErrUnsupportedExceptCIDR
 	}
 
-	var members []string
-	indexOfMembers := 0
-	// Ipset doesn't allow 0.0.0.0/0 to be added.
-	// A solution is split 0.0.0.0/0 in half which convert to 0.0.0.0/1 and 128.0.0.0/1.
-	// splitCIDRSet is used to handle case where IPBlock has "0.0.0.0/0" in CIDR and "0.0.0.0/1" or "128.0.0.0/1"  in Except.
-	// splitCIDRSet has two entries ("0.0.0.0/1" and "128.0.0.0/1") as key.
-	splitCIDRLen := 2
-	splitCIDRSet := make(map[string]int, splitCIDRLen)
-	if ipBlockRule.CIDR == "0.0.0.0/0" {
-		// two cidrs (0.0.0.0/1 and 128.0.0.0/1) for 0.0.0.0/0 + except.
-		members = make([]string, lenOfDeDupExcepts+splitCIDRLen)
-		// in case of "0.0.0.0/0", "0.0.0.0/1" or "0.0.0.0/1 nomatch" comes eariler than "128.0.0.0/1" or "128.0.0.0/1 nomatch".
-		splitCIDRs := []string{"0.0.0.0/1", "128.0.0.0/1"}
-		for _, cidr := range splitCIDRs {
-			members[indexOfMembers] = cidr
-			splitCIDRSet[cidr] = indexOfMembers
-			indexOfMembers++
-		}
-	} else {
-		// one cidr + except
-		members = make([]string, lenOfDeDupExcepts+1)
-		members[indexOfMembers] = ipBlockRule.CIDR
-		indexOfMembers++
-	}
-
-	for i := 0; i < lenOfDeDupExcepts; i++ {
-		except := deDupExcepts[i]
-		if splitCIDRIndex, exist := splitCIDRSet[except]; exist {
-			// replace stored splitCIDR with "nomatch" option
-			members[splitCIDRIndex] = exceptCidr(except)
-			indexOfMembers--
-			members = members[:len(members)-1]
-		} else {
-			members[i+indexOfMembers] = exceptCidr(except)
-		}
+	members, err := ipBlockMembers(ipBlockRule.CIDR, ipBlockRule.Except)
+	if err != nil {
+		return nil, err
 	}
 
 	ipBlockIPSetName := ipBlockSetName(policyName, ns, direction, ipBlockSetIndex, ipBlockPeerIndex)
@@ -227,7 +182,8 @@ func ipBlockRule(policyName, ns string, direction policies.Direction, matchType
 		return nil, policies.SetInfo{}, nil
 	}
 
-	if !util.IsIPV4(ipBlockRule.CIDR) {
+	// IPv6 CIDRs are added to the inet6 family twin of the set in dual-stack clusters, which Windows doesn't support.
+	if !util.IsIPV4(ipBlockRule.CIDR) && (util.IsWindowsDP() || !util.IsIPV6(ipBlockRule.CIDR)) {
 		return nil, policies.SetInfo{}, ErrUnsupportedIPAddress
 	}
 
@@ -243,7 +199,7 @@ func ipBlockRule(policyName, ns string, direction policies.Direction, matchType
 // Children are members of a list-type IPSet.
 // This function is called only when the NetworkPolicyPeer has namespaceSelector field.
 func podSelector(policyKey string, matchType policies.MatchType, selector *metav1.LabelSelector) (*podSelectorResult, error) {
-	podSelectors, err := parsePodSelector(policyKey, selector)
+	podSelectors, err := selectorEngine.PodSets(policyKey, selector)
 	if err != nil {
 		return nil, err
 	}
@@ -256,14 +212,14 @@ func podSelector(policyKey string, matchType policies.MatchType, selector *metav
 	}
 	for i := 0; i < lenOfPodSelectors; i++ {
 		ps := podSelectors[i]
-		psResult.psSets = append(psResult.psSets, ipsets.NewTranslatedIPSet(ps.setName, ps.setType, ps.members...))
+		psResult.psSets = append(psResult.psSets, ipsets.NewTranslatedIPSet(ps.Name, ps.SetType, ps.Members...))
 
 		// if value is nested value, create translatedIPSet with the nested value
-		for j := 0; j < len(ps.members); j++ {
-			psResult.childPSSets = append(psResult.childPSSets, ipsets.NewTranslatedIPSet(ps.members[j], ipsets.KeyValueLabelOfPod))
+		for j := 0; j < len(ps.Members); j++ {
+			psResult.childPSSets = append(psResult.childPSSets, ipsets.NewTranslatedIPSet(ps.Members[j], ipsets.KeyValueLabelOfPod))
 		}
 
-		psResult.psList[i] = policies.NewSetInfo(ps.setName, ps.setType, ps.include, matchType)
+		psResult.psList[i] = policies.NewSetInfo(ps.Name, ps.SetType, ps.Include, matchType)
 	}
 	return psResult, nil
 }
@@ -284,15 +240,15 @@ func podSelectorWithNS(policyKey, ns string, matchType policies.MatchType, selec
 
 // nameSpaceSelector translates namespaceSelector of NetworkPolicyPeer in networkpolicy object to translatedIPSet and SetInfo.
 func nameSpaceSelector(matchType policies.MatchType, selector *metav1.LabelSelector) ([]*ipsets.TranslatedIPSet, []policies.SetInfo) {
-	nsSelectors := parseNSSelector(selector)
+	nsSelectors := selectorEngine.NamespaceSets(selector)
 	lenOfnsSelectors := len(nsSelectors)
 	nsSelectorIPSets := make([]*ipsets.TranslatedIPSet, lenOfnsSelectors)
 	nsSelectorList := make([]policies.SetInfo, lenOfnsSelectors)
 
 	for i := 0; i < lenOfnsSelectors; i++ {
 		nsc := nsSelectors[i]
-		nsSelectorIPSets[i] = ipsets.NewTranslatedIPSet(nsc.setName, nsc.setType)
-		nsSelectorList[i] = policies.NewSetInfo(nsc.setName, nsc.setType, nsc.include, matchType)
+		nsSelectorIPSets[i] = ipsets.NewTranslatedIPSet(nsc.Name, nsc.SetType)
+		nsSelectorList[i] = policies.NewSetInfo(nsc.Name, nsc.SetType, nsc.Include, matchType)
 	}
 
 	return nsSelectorIPSets, nsSelectorList
@@ -355,8 +311,9 @@ func peerAndPortRule(npmNetPol *policies.NPMNetworkPolicy, direction policies.Di
 }
 
 // translateRule translates ingress or egress rules and update npmNetPol object.
-func translateRule(npmNetPol *policies.NPMNetworkPolicy, netPolName string, direction policies.Direction, matchType policies.MatchType, ruleIndex int,
-	ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) error {
+// addressGroups maps the placeholder CIDRs of ipBlocks to the AddressGroups replacing them.
+func translateRule(npmNetPol *policies.NPMNetworkPolicy, netPolName string, addressGroups map[string]string, direction policies.Direction,
+	matchType policies.MatchType, ruleIndex int, ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) error {
 	// TODO(jungukcho): need to clean up it.
 	// Leave allowExternal variable now while the condition is checked before calling this function.
 	allowExternal, portRuleExists, peerRuleExists := ruleExists(ports, peers)
@@ -391,7 +348,18 @@ func translateRule(npmNetPol *policies.NPMNetworkPolicy, netPolName string, dire
 	for peerIdx, peer := range peers {
 		// #2.1 Handle IPBlock and port if exist
 		if peer.IPBlock != nil {
-			if len(peer.IPBlock.CIDR) > 0 {
+			if group, ok := addressGroups[peer.IPBlock.CIDR]; ok {
+				if len(peer.IPBlock.Except) > 0 {
+					return fmt.Errorf("%w: %s", ErrAddressGroupWithExcept, group)
+				}
+				addressGroupIPSet, addressGroupSetInfo := addressGroupRule(group, matchType)
+				npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, addressGroupIPSet)
+
+				err := peerAndPortRule(npmNetPol, direction, ports, []policies.SetInfo{addressGroupSetInfo})
+				if err != nil {
+					return err
+				}
+			} else if len(peer.IPBlock.CIDR) > 0 {
 				ipBlockIPSet, ipBlockSetInfo, err := ipBlockRule(netPolName, npmNetPol.Namespace, direction, matchType, ruleIndex, peerIdx, peer.IPBlock)
 				if err != nil {
 					return err
@@ -415,9 +383,9 @@ func translateRule(npmNetPol *policies.NPMNetworkPolicy, netPolName string, dire
 
 		// #2.2 handle nameSpaceSelector and port if exist
 		if peer.PodSelector == nil && peer.NamespaceSelector != nil {
-			// Before translating NamespaceSelector, flattenNameSpaceSelector function call should be called
+			// Before translating NamespaceSelector, the selector should be flattened
 			// to handle multiple values in matchExpressions spec.
-			flattenNSSelector, err := flattenNameSpaceSelector(peer.NamespaceSelector)
+			flattenNSSelector, err := selectorEngine.FlattenNamespaceSelector(peer.NamespaceSelector)
 			if err != nil {
 				return err
 			}
@@ -456,9 +424,9 @@ func translateRule(npmNetPol *policies.NPMNetworkPolicy, netPolName string, dire
 		npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, psResult.psSets...)
 		npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, psResult.childPSSets...)
 
-		// Before translating NamespaceSelector, flattenNameSpaceSelector function call should be called
+		// Before translating NamespaceSelector, the selector should be flattened
 		// to handle multiple values in matchExpressions spec.
-		flattenNSSelector, err := flattenNameSpaceSelector(peer.NamespaceSelector)
+		flattenNSSelector, err := selectorEngine.FlattenNamespaceSelector(peer.NamespaceSelector)
 		if err != nil {
 			return err
 		}
@@ -498,7 +466,7 @@ func isAllowAllToIngress(ingress []networkingv1.NetworkPolicyIngressRule) bool {
 
 // ingressPolicy traslates NetworkPolicyIngressRule in NetworkPolicy object
 // to NPMNetworkPolicy object.
-func ingressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, ingress []networkingv1.NetworkPolicyIngressRule) error {
+func ingressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, addressGroups map[string]string, ingress []networkingv1.NetworkPolicyIngressRule) error {
 	// #1. Allow all traffic from both internal and external.
 	// In yaml file, it is specified with '{}'.
 	if isAllowAllToIngress(ingress) {
@@ -517,7 +485,7 @@ func ingressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, ingr
 	// #3. Ingress rule is not AllowAll (including internal and external) and DenyAll policy.
 	// So, start translating ingress policy.
 	for i, rule := range ingress {
-		if err := translateRule(npmNetPol, netPolName, policies.Ingress, policies.SrcMatch, i, rule.Ports, rule.From); err != nil {
+		if err := translateRule(npmNetPol, netPolName, addressGroups, policies.Ingress, policies.SrcMatch, i, rule.Ports, rule.From); err != nil {
 			return err
 		}
 	}
@@ -537,7 +505,7 @@ func isAllowAllToEgress(egress []networkingv1.NetworkPolicyEgressRule) bool {
 
 // egressPolicy traslates NetworkPolicyEgressRule in networkpolicy object
 // to NPMNetworkPolicy object.
-func egressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, egress []networkingv1.NetworkPolicyEgressRule) error {
+func egressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, addressGroups map[string]string, egress []networkingv1.NetworkPolicyEgressRule) error {
 	// #1. Allow all traffic to both internal and external.
 	// In yaml file, it is specified with '{}'.
 	if isAllowAllToEgress(egress) {
@@ -556,7 +524,7 @@ func egressPolicy(npmNetPol *policies.NPMNetworkPolicy, netPolName string, egres
 	// #3. Egress rule is not AllowAll (including internal and external) and DenyAll.
 	// So, start translating egress policy.
 	for i, rule := range egress {
-		err := translateRule(npmNetPol, netPolName, policies.Egress, policies.DstMatch, i, rule.Ports, rule.To)
+		err := translateRule(npmNetPol, netPolName, addressGroups, policies.Egress, policies.DstMatch, i, rule.Ports, rule.To)
 		if err != nil {
 			return err
 		}
@@ -575,6 +543,11 @@ func TranslatePolicy(npObj *networkingv1.NetworkPolicy) (*policies.NPMNetworkPol
 	netPolName := npObj.Name
 	npmNetPol := policies.NewNPMNetworkPolicy(netPolName, npObj.Namespace)
 
+	addressGroups, err := addressGroups(npObj.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
 	// podSelector in spec.PodSelector is common for ingress and egress.
 	// Process this podSelector first.
 	psResult, err := podSelectorWithNS(npmNetPol.PolicyKey, npmNetPol.Namespace, policies.EitherMatch, &npObj.Spec.PodSelector)
@@ -590,12 +563,12 @@ func TranslatePolicy(npObj *networkingv1.NetworkPolicy) (*policies.NPMNetworkPol
 	// and Egress will be set if the NetworkPolicy has any egress rules.
 	for _, ptype := range npObj.Spec.PolicyTypes {
 		if ptype == networkingv1.PolicyTypeIngress {
-			err := ingressPolicy(npmNetPol, netPolName, npObj.Spec.Ingress)
+			err := ingressPolicy(npmNetPol, netPolName, addressGroups, npObj.Spec.Ingress)
 			if err != nil {
 				return nil, err
 			}
 		} else {
-			err := egressPolicy(npmNetPol, netPolName, npObj.Spec.Egress)
+			err := egressPolicy(npmNetPol, netPolName, addressGroups, npObj.Spec.Egress)
 			if err != nil {
 				return nil, err
 			}
@@ -423,7 +423,7 @@ func TestIPBlockIPSet(t *testing.T) {
 				CIDR:   "0.0.0.0/0",
 				Except: []string{"10.0.0.0/1"},
 			},
-			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1", "128.0.0.0/1", "10.0.0.0/1 nomatch"}...),
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"128.0.0.0/1"}...),
 			skipWindows:     true,
 		},
 		{
@@ -433,7 +433,7 @@ func TestIPBlockIPSet(t *testing.T) {
 				CIDR:   "0.0.0.0/0",
 				Except: []string{"0.0.0.0/1"},
 			},
-			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1 nomatch", "128.0.0.0/1"}...),
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"128.0.0.0/1"}...),
 			skipWindows:     true,
 		},
 		{
@@ -443,7 +443,7 @@ func TestIPBlockIPSet(t *testing.T) {
 				CIDR:   "0.0.0.0/0",
 				Except: []string{"128.0.0.0/1"},
 			},
-			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1", "128.0.0.0/1 nomatch"}...),
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1"}...),
 			skipWindows:     true,
 		},
 		{
@@ -453,7 +453,7 @@ func TestIPBlockIPSet(t *testing.T) {
 				CIDR:   "0.0.0.0/0",
 				Except: []string{"0.0.0.0/1", "128.0.0.0/1"},
 			},
-			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1 nomatch", "128.0.0.0/1 nomatch"}...),
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{}...),
 			skipWindows:     true,
 		},
 		{
@@ -463,7 +463,37 @@ func TestIPBlockIPSet(t *testing.T) {
 				CIDR:   "0.0.0.0/0",
 				Except: []string{"0.0.0.0/1", "128.0.0.0/1", "128.0.0.0/1"},
 			},
-			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"0.0.0.0/1 nomatch", "128.0.0.0/1 nomatch"}...),
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{}...),
+			skipWindows:     true,
+		},
+		{
+			name:        "cidr: ::/0 and except: 8000::/1 and fd00::/8",
+			ipBlockInfo: createIPBlockInfo("test", defaultNS, policies.Ingress, policies.SrcMatch, 0, 0),
+			ipBlockRule: &networkingv1.IPBlock{
+				CIDR:   "::/0",
+				Except: []string{"8000::/1", "fd00::/8"},
+			},
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"::/1"}...),
+			skipWindows:     true,
+		},
+		{
+			name:        "adjacent and nested excepts are compacted",
+			ipBlockInfo: createIPBlockInfo("test", defaultNS, policies.Ingress, policies.SrcMatch, 0, 0),
+			ipBlockRule: &networkingv1.IPBlock{
+				CIDR:   "10.0.0.0/8",
+				Except: []string{"10.1.128.0/17", "10.1.0.0/17", "10.1.2.0/24", "10.2.0.1"},
+			},
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"10.0.0.0/8", "10.1.0.0/16 nomatch", "10.2.0.1/32 nomatch"}...),
+			skipWindows:     true,
+		},
+		{
+			name:        "except subtracted when fewer members are left",
+			ipBlockInfo: createIPBlockInfo("test", defaultNS, policies.Ingress, policies.SrcMatch, 0, 0),
+			ipBlockRule: &networkingv1.IPBlock{
+				CIDR:   "10.0.0.0/24",
+				Except: []string{"10.0.0.0/26", "10.0.0.128/26", "10.0.0.64/27"},
+			},
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, []string{"10.0.0.96/27", "10.0.0.192/26"}...),
 			skipWindows:     true,
 		},
 	}
@@ -541,11 +571,23 @@ func TestIPBlockRule(t *testing.T) {
 			setInfo:         policies.NewSetInfo("test-network-policy-in-ns-default-0-0IN", ipsets.CIDRBlocks, included, policies.SrcMatch),
 			skipWindows:     true,
 		},
+		{
+			name:        "ipv6 cidr and except",
+			ipBlockInfo: createIPBlockInfo("test", defaultNS, policies.Ingress, policies.SrcMatch, 0, 0),
+			ipBlockRule: &networkingv1.IPBlock{
+				CIDR:   "2002::1234:abcd:ffff:c0a8:101/64",
+				Except: []string{"2002::1234:abcd:ffff:c0a8:101/96"},
+			},
+			translatedIPSet: ipsets.NewTranslatedIPSet("test-in-ns-default-0-0IN", ipsets.CIDRBlocks,
+				[]string{"2002:0:0:1234::/64", "2002::1234:abcd:ffff:0:0/96 nomatch"}...),
+			setInfo:     policies.NewSetInfo("test-in-ns-default-0-0IN", ipsets.CIDRBlocks, included, policies.SrcMatch),
+			skipWindows: true,
+		},
 		{
 			name:        "invalid ipv6",
 			ipBlockInfo: createIPBlockInfo("test", defaultNS, policies.Ingress, policies.SrcMatch, 0, 0),
 			ipBlockRule: &networkingv1.IPBlock{
-				CIDR: "2002::1234:abcd:ffff:c0a8:101/64",
+				CIDR: "2002::1234:abcd:ffff:c0a8:101/129",
 			},
 			translatedIPSet: nil,
 			setInfo:         policies.SetInfo{},
@@ -2138,7 +2180,7 @@ func TestIngressPolicy(t *testing.T) {
 			npmNetPol.PodSelectorList = psResult.psList
 			splitPolicyKey := strings.Split(npmNetPol.PolicyKey, "/")
 			require.Len(t, splitPolicyKey, 2, "policy key must include name")
-			err = ingressPolicy(npmNetPol, splitPolicyKey[1], tt.rules)
+			err = ingressPolicy(npmNetPol, splitPolicyKey[1], nil, tt.rules)
 			if tt.wantErr || (tt.skipWindows && util.IsWindowsDP()) {
 				require.Error(t, err)
 			} else {
@@ -2829,7 +2871,7 @@ func TestEgressPolicy(t *testing.T) {
 			npmNetPol.PodSelectorList = psResult.psList
 			splitPolicyKey := strings.Split(npmNetPol.PolicyKey, "/")
 			require.Len(t, splitPolicyKey, 2, "policy key must include name")
-			err = egressPolicy(npmNetPol, splitPolicyKey[1], tt.rules)
+			err = egressPolicy(npmNetPol, splitPolicyKey[1], nil, tt.rules)
 			if tt.wantErr || (tt.skipWindows && util.IsWindowsDP()) {
 				require.Error(t, err)
 			} else {
@@ -0,0 +1,95 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+)
+
+// ErrBudgetExceeded is returned when adding a policy would exceed the Budget of the node.
+// The policy isn't programmed, and the previous version of an updated policy stays programmed.
+var ErrBudgetExceeded = errors.New("dataplane budget exceeded")
+
+// Budget caps what the policies of a node program into the kernel (Linux) or HNS (Windows),
+// so that too many policies fail to be added instead of pushing the dataplane into failure modes.
+// Zero leaves a dimension unbounded.
+type Budget struct {
+	MaxIPSets  int
+	MaxMembers int
+	MaxRules   int
+}
+
+// Usage is how much of the Budget the dataplane uses.
+type Usage struct {
+	IPSets  int
+	Members int
+	Rules   int
+}
+
+// Usage returns the IPSets and members in the cache and the ACLs of the policies, including the policies buffered during bootup.
+func (dp *DataPlane) Usage() Usage {
+	return dp.usageExcluding("")
+}
+
+// usageExcluding returns the Usage without the ACLs of the policy, which an update replaces.
+func (dp *DataPlane) usageExcluding(policyKey string) Usage {
+	var usage Usage
+	usage.IPSets, usage.Members = dp.ipsetMgr.Usage()
+	usage.Rules = dp.policyMgr.NumACLs()
+	if policy, ok := dp.policyMgr.GetPolicy(policyKey); ok {
+		usage.Rules -= len(policy.ACLs)
+	}
+
+	dp.bootupInfo.Lock()
+	for key, policy := range dp.bootupInfo.pendingPolicies {
+		if key != policyKey {
+			usage.Rules += len(policy.ACLs)
+		}
+	}
+	dp.bootupInfo.Unlock()
+	return usage
+}
+
+// checkBudget returns ErrBudgetExceeded if adding the policy would exceed the Budget.
+// The IPSets of the policy which don't exist yet, and their members, count toward the Budget.
+func (dp *DataPlane) checkBudget(policy *policies.NPMNetworkPolicy) error {
+	if dp.Budget == (Budget{}) {
+		return nil
+	}
+
+	usage := dp.usageExcluding(policy.PolicyKey)
+	newSets := make(map[string]struct{})
+	newMembers := 0
+	sets := make([]*ipsets.TranslatedIPSet, 0, len(policy.PodSelectorIPSets)+len(policy.ChildPodSelectorIPSets)+len(policy.RuleIPSets))
+	sets = append(sets, policy.PodSelectorIPSets...)
+	sets = append(sets, policy.ChildPodSelectorIPSets...)
+	sets = append(sets, policy.RuleIPSets...)
+	for _, set := range sets {
+		name := set.Metadata.GetPrefixName()
+		if _, ok := newSets[name]; ok || dp.ipsetMgr.GetIPSet(name) != nil {
+			continue
+		}
+		newSets[name] = struct{}{}
+		newMembers += len(set.Members)
+	}
+
+	if exceeds(usage.IPSets+len(newSets), dp.Budget.MaxIPSets) {
+		return fmt.Errorf("%w: policy %s needs %d more IPSets with %d in use out of %d",
+			ErrBudgetExceeded, policy.PolicyKey, len(newSets), usage.IPSets, dp.Budget.MaxIPSets)
+	}
+	if exceeds(usage.Members+newMembers, dp.Budget.MaxMembers) {
+		return fmt.Errorf("%w: policy %s needs %d more IPSet members with %d in use out of %d",
+			ErrBudgetExceeded, policy.PolicyKey, newMembers, usage.Members, dp.Budget.MaxMembers)
+	}
+	if exceeds(usage.Rules+len(policy.ACLs), dp.Budget.MaxRules) {
+		return fmt.Errorf("%w: policy %s needs %d rules with %d in use out of %d",
+			ErrBudgetExceeded, policy.PolicyKey, len(policy.ACLs), usage.Rules, dp.Budget.MaxRules)
+	}
+	return nil
+}
+
+func exceeds(total, limit int) bool {
+	return limit > 0 && total > limit
+}
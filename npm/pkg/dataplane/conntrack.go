@@ -0,0 +1,105 @@
+package dataplane
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+)
+
+// connFlow is a connection of a pod in its original direction, from src to dst.
+// The ports are 0 for protocols without ports, e.g. ICMP.
+type connFlow struct {
+	// protocol is the lower case name of the protocol, e.g. "tcp"
+	protocol string
+	src      string
+	dst      string
+	srcPort  int32
+	dstPort  int32
+}
+
+// deniedDirections returns whether one of the queued policies of the pod denies ingress traffic, and whether one of
+// them denies egress traffic.
+func (flush conntrackFlush) deniedDirections() (deniesIngress, deniesEgress bool) {
+	for _, policy := range flush.policies {
+		ingress, egress := policy.DeniedDirections()
+		deniesIngress = deniesIngress || ingress
+		deniesEgress = deniesEgress || egress
+	}
+	return deniesIngress, deniesEgress
+}
+
+// deniesFlow returns whether one of the queued policies of the pod denies the flow, which is ingress or egress traffic
+// of the pod. A flow another policy of the pod allows is denied as well: its next packets are evaluated against all the
+// policies of the pod again once its entry is flushed, so it keeps flowing.
+func (dp *DataPlane) deniesFlow(flush conntrackFlush, direction policies.Direction, flow connFlow) bool {
+	for _, policy := range flush.policies {
+		if dp.policyDeniesFlow(policy, direction, flow) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyDeniesFlow returns whether the policy drops the traffic of the direction and none of its ACLs allow the flow.
+func (dp *DataPlane) policyDeniesFlow(policy *policies.NPMNetworkPolicy, direction policies.Direction, flow connFlow) bool {
+	denies := false
+	for _, acl := range policy.ACLs {
+		if !acl.HasDirection(direction) {
+			continue
+		}
+		switch acl.Target {
+		case policies.Allowed:
+			if dp.aclMatchesFlow(acl, flow) {
+				return false
+			}
+		case policies.Dropped:
+			denies = true
+		}
+	}
+	return denies
+}
+
+// aclMatchesFlow returns whether the protocol, destination ports and sets of the ACL match the flow.
+func (dp *DataPlane) aclMatchesFlow(acl *policies.ACLPolicy, flow connFlow) bool {
+	if acl.Protocol != "" && acl.Protocol != policies.UnspecifiedProtocol && !strings.EqualFold(string(acl.Protocol), flow.protocol) {
+		return false
+	}
+	if acl.DstPorts.Port != 0 {
+		endPort := acl.DstPorts.EndPort
+		if endPort == 0 {
+			endPort = acl.DstPorts.Port
+		}
+		if flow.dstPort < acl.DstPorts.Port || flow.dstPort > endPort {
+			return false
+		}
+	}
+	for _, setInfo := range acl.SrcList {
+		if !dp.setInfoMatchesFlow(setInfo, flow) {
+			return false
+		}
+	}
+	for _, setInfo := range acl.DstList {
+		if !dp.setInfoMatchesFlow(setInfo, flow) {
+			return false
+		}
+	}
+	return true
+}
+
+// setInfoMatchesFlow returns whether the set matches the source or destination of the flow, as its MatchType says, or
+// doesn't match it if the set is excluded.
+func (dp *DataPlane) setInfoMatchesFlow(setInfo policies.SetInfo, flow connFlow) bool {
+	setName := setInfo.IPSet.GetPrefixName()
+	var matched bool
+	switch setInfo.MatchType {
+	case policies.SrcMatch:
+		matched = dp.ipsetMgr.MatchesIP(setName, flow.src, "", 0)
+	case policies.DstMatch:
+		matched = dp.ipsetMgr.MatchesIP(setName, flow.dst, "", 0)
+	case policies.DstDstMatch:
+		matched = dp.ipsetMgr.MatchesIP(setName, flow.dst, flow.protocol, flow.dstPort)
+	default:
+		return false
+	}
+	return matched == setInfo.Included
+}
@@ -29,6 +29,8 @@ const (
 
 	ip1 = "10.0.0.1"
 	ip2 = "10.0.0.2"
+	// otherSubnetIP is the IP of a pod in the subnet of another node
+	otherSubnetIP = "10.1.0.1"
 
 	endpoint1 = "test1"
 	endpoint2 = "test2"
@@ -2259,6 +2261,130 @@ func getAllMultiJobTests() []*MultiJobTestCase {
 	}
 }
 
+func remoteSubnetTests() []*SerialTestCase {
+	return []*SerialTestCase{
+		{
+			Description: "pod created off node in another subnet and pod created on node, then policy selecting both created",
+			Actions: []*Action{
+				CreateEndpoint(endpoint1, ip1),
+				CreatePod("x", "a", ip1, thisNode, map[string]string{"k1": "v1"}),
+				CreatePod("x", "b", otherSubnetIP, otherNode, map[string]string{"k1": "v1"}),
+				ApplyDP(),
+				UpdatePolicy(policyXBaseOnK1V1()),
+			},
+			TestCaseMetadata: &TestCaseMetadata{
+				Tags: []Tag{
+					podCrudTag,
+					netpolCrudTag,
+				},
+				DpCfg:            defaultWindowsDPCfg,
+				InitialEndpoints: nil,
+				ExpectedSetPolicies: []*hcn.SetPolicySetting{
+					dptestutils.SetPolicy(emptySet),
+					dptestutils.SetPolicy(allNamespaces, emptySet.GetHashedName(), nsXSet.GetHashedName()),
+					dptestutils.SetPolicy(nsXSet, ip1, otherSubnetIP),
+					dptestutils.SetPolicy(podK1Set, ip1, otherSubnetIP),
+					dptestutils.SetPolicy(podK1V1Set, ip1, otherSubnetIP),
+				},
+				ExpectedEnpdointACLs: map[string][]*hnswrapper.FakeEndpointPolicy{
+					endpoint1: {
+						{
+							ID:              "azure-acl-x-base",
+							Protocols:       "",
+							Action:          "Allow",
+							Direction:       "In",
+							LocalAddresses:  "",
+							RemoteAddresses: "",
+							LocalPorts:      "",
+							RemotePorts:     "",
+							Priority:        222,
+						},
+						{
+							ID:              "azure-acl-x-base",
+							Protocols:       "",
+							Action:          "Allow",
+							Direction:       "Out",
+							LocalAddresses:  "",
+							RemoteAddresses: "",
+							LocalPorts:      "",
+							RemotePorts:     "",
+							Priority:        222,
+						},
+						{
+							ID:              "azure-acl-x-base",
+							Action:          "Allow",
+							Direction:       "In",
+							RemoteAddresses: testNodeIP,
+							Priority:        201,
+						},
+					},
+				},
+			},
+		},
+		{
+			Description: "pod off node in another subnet deleted, then its IP reused on node",
+			Actions: []*Action{
+				UpdatePolicy(policyXBaseOnK1V1()),
+				CreatePod("x", "b", otherSubnetIP, otherNode, map[string]string{"k1": "v1"}),
+				ApplyDP(),
+				DeletePod("x", "b", otherSubnetIP, map[string]string{"k1": "v1"}),
+				ApplyDP(),
+				CreateEndpoint(endpoint1, otherSubnetIP),
+				CreatePod("x", "a", otherSubnetIP, thisNode, map[string]string{"k1": "v1"}),
+				ApplyDP(),
+			},
+			TestCaseMetadata: &TestCaseMetadata{
+				Tags: []Tag{
+					podCrudTag,
+					netpolCrudTag,
+				},
+				DpCfg:            defaultWindowsDPCfg,
+				InitialEndpoints: nil,
+				ExpectedSetPolicies: []*hcn.SetPolicySetting{
+					dptestutils.SetPolicy(emptySet),
+					dptestutils.SetPolicy(allNamespaces, emptySet.GetHashedName(), nsXSet.GetHashedName()),
+					dptestutils.SetPolicy(nsXSet, otherSubnetIP),
+					dptestutils.SetPolicy(podK1Set, otherSubnetIP),
+					dptestutils.SetPolicy(podK1V1Set, otherSubnetIP),
+				},
+				ExpectedEnpdointACLs: map[string][]*hnswrapper.FakeEndpointPolicy{
+					endpoint1: {
+						{
+							ID:              "azure-acl-x-base",
+							Protocols:       "",
+							Action:          "Allow",
+							Direction:       "In",
+							LocalAddresses:  "",
+							RemoteAddresses: "",
+							LocalPorts:      "",
+							RemotePorts:     "",
+							Priority:        222,
+						},
+						{
+							ID:              "azure-acl-x-base",
+							Protocols:       "",
+							Action:          "Allow",
+							Direction:       "Out",
+							LocalAddresses:  "",
+							RemoteAddresses: "",
+							LocalPorts:      "",
+							RemotePorts:     "",
+							Priority:        222,
+						},
+						{
+							ID:              "azure-acl-x-base",
+							Action:          "Allow",
+							Direction:       "In",
+							RemoteAddresses: testNodeIP,
+							Priority:        201,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 func applyInBackgroundTests() []*SerialTestCase {
 	allTests := make([]*SerialTestCase, 0)
 	allTests = append(allTests, basicTests()...)
@@ -3,6 +3,8 @@ package dataplane
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,12 +21,20 @@ const (
 	reconcileDuration = time.Duration(5 * time.Minute)
 
 	contextBackground = "BACKGROUND"
+	contextBootup     = "BOOTUP"
 	contextApplyDP    = "APPLY-DP"
 	contextAddNetPol  = "ADD-NETPOL"
 	contextDelNetPol  = "DEL-NETPOL"
+
+	// the IP families of conntrack entries
+	ipv4Family = "ipv4"
+	ipv6Family = "ipv6"
 )
 
-var ErrInvalidApplyConfig = errors.New("invalid apply config")
+var (
+	ErrInvalidApplyConfig = errors.New("invalid apply config")
+	ErrBootupPolicies     = errors.New("failed to add policies buffered during bootup")
+)
 
 type PolicyMode string
 
@@ -33,6 +43,23 @@ type Config struct {
 	ApplyInBackground bool
 	ApplyMaxBatches   int
 	ApplyInterval     time.Duration
+	// BufferEventsOnBootup defers applying IPSets and policies until FinishBootupPhase() is called.
+	// This lets the control plane replay its informer caches before anything is programmed,
+	// so policies are never applied against partially populated IPSets.
+	BufferEventsOnBootup bool
+	// ChainIntegrityCheckInterval is how often the jump to the Azure chains is repaired in Linux if it's missing or misplaced,
+	// in addition to the reconcile every 5 minutes. The zero value disables the more frequent check.
+	ChainIntegrityCheckInterval time.Duration
+	// Budget caps the IPSets, members, and rules programmed by the policies. Policies exceeding it fail with ErrBudgetExceeded.
+	Budget Budget
+	// MigrationMarkerPath is where the bootup records the version of the dataplane and the migration from older versions,
+	// usually DefaultMigrationMarkerPath. The empty value disables the marker.
+	MigrationMarkerPath string
+	// FlushConntrack deletes the conntrack entries of the connections of the local pods selected by a policy which the
+	// policy denies, once it's added or its rules change, so that they're evaluated against the policy instead of
+	// bypassing it. The flushes run in the background, started by RunPeriodicTasks. On Windows, the VFP flows of the
+	// endpoints are flushed instead.
+	FlushConntrack bool
 	*ipsets.IPSetManagerCfg
 	*policies.PolicyManagerCfg
 }
@@ -46,11 +73,104 @@ func newEndpointCache() *endpointCache {
 	return &endpointCache{cache: make(map[string]*npmEndpoint)}
 }
 
+// remotePodCache stores the pods on other nodes, learned from the pod events of the shared informer.
+// Key is PodIP and value is PodKey.
+type remotePodCache struct {
+	sync.Mutex
+	cache map[string]string
+}
+
+func newRemotePodCache() *remotePodCache {
+	return &remotePodCache{cache: make(map[string]string)}
+}
+
+// track records the pod if it's on another node. The pod is forgotten once it's deleted (no node name)
+// or its IP is reused on this node.
+func (c *remotePodCache) track(m *PodMetadata, nodeName string) {
+	c.Lock()
+	defer c.Unlock()
+
+	switch m.NodeName {
+	case "":
+		if c.cache[m.PodIP] == m.PodKey {
+			delete(c.cache, m.PodIP)
+		}
+	case nodeName:
+		delete(c.cache, m.PodIP)
+	default:
+		c.cache[m.PodIP] = m.PodKey
+	}
+}
+
+// isRemote returns whether the pod with the IP is on another node.
+func (c *remotePodCache) isRemote(ip, podKey string) bool {
+	c.Lock()
+	defer c.Unlock()
+	key, ok := c.cache[ip]
+	return ok && key == podKey
+}
+
+// conntrackFlushQueue holds the conntrack flushes queued by the policies applied, until they're run in the background.
+// Key is PodIP.
+type conntrackFlushQueue struct {
+	sync.Mutex
+	pending map[string]conntrackFlush
+	// queued is signaled when flushes are queued
+	queued chan struct{}
+}
+
+// conntrackFlush is the policies of a pod whose denied connections have their conntrack entries flushed.
+// Key of policies is PolicyKey.
+type conntrackFlush struct {
+	podKey   string
+	policies map[string]*policies.NPMNetworkPolicy
+}
+
+func newConntrackFlushQueue() *conntrackFlushQueue {
+	return &conntrackFlushQueue{
+		pending: make(map[string]conntrackFlush),
+		queued:  make(chan struct{}, 1),
+	}
+}
+
+// queue adds the policy to the flush of the pod IP already queued, if any.
+func (q *conntrackFlushQueue) queue(ip, podKey string, policy *policies.NPMNetworkPolicy) {
+	q.Lock()
+	defer q.Unlock()
+	flush, ok := q.pending[ip]
+	if !ok || flush.podKey != podKey {
+		flush = conntrackFlush{podKey: podKey, policies: make(map[string]*policies.NPMNetworkPolicy)}
+		q.pending[ip] = flush
+	}
+	flush.policies[policy.PolicyKey] = policy
+	select {
+	case q.queued <- struct{}{}:
+	default:
+	}
+}
+
+// dequeueAll returns the queued flushes and empties the queue.
+func (q *conntrackFlushQueue) dequeueAll() map[string]conntrackFlush {
+	q.Lock()
+	defer q.Unlock()
+	pending := q.pending
+	q.pending = make(map[string]conntrackFlush)
+	return pending
+}
+
 type applyInfo struct {
 	sync.Mutex
 	numBatches int
 }
 
+type bootupInfo struct {
+	sync.Mutex
+	inProgress bool
+	// pendingPolicies holds the latest version of each policy added during bootup.
+	// Key is the policy key.
+	pendingPolicies map[string]*policies.NPMNetworkPolicy
+}
+
 type DataPlane struct {
 	*Config
 	applyInBackground bool
@@ -60,12 +180,18 @@ type DataPlane struct {
 	nodeName          string
 	// endpointCache stores all endpoints of the network (including off-node)
 	// Key is PodIP
-	endpointCache  *endpointCache
-	ioShim         *common.IOShim
-	updatePodCache *updatePodCache
-	endpointQuery  *endpointQuery
-	applyInfo      *applyInfo
-	stopChannel    <-chan struct{}
+	endpointCache *endpointCache
+	// remotePods stores the pods on other nodes, whose IPs are only matched by the SetPolicies of the network in Windows,
+	// and whose conntrack entries aren't flushed
+	remotePods *remotePodCache
+	// conntrackFlushes holds the conntrack flushes which are run in the background, see Config.FlushConntrack
+	conntrackFlushes *conntrackFlushQueue
+	ioShim           *common.IOShim
+	updatePodCache   *updatePodCache
+	endpointQuery    *endpointQuery
+	applyInfo        *applyInfo
+	bootupInfo       *bootupInfo
+	stopChannel      <-chan struct{}
 }
 
 func NewDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChannel <-chan struct{}) (*DataPlane, error) {
@@ -80,13 +206,19 @@ func NewDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChann
 		policyMgr: policies.NewPolicyManager(ioShim, cfg.PolicyManagerCfg),
 		ipsetMgr:  ipsets.NewIPSetManager(cfg.IPSetManagerCfg, ioShim),
 		// networkID is set when initializing Windows dataplane
-		networkID:     "",
-		endpointCache: newEndpointCache(),
-		nodeName:      nodeName,
-		ioShim:        ioShim,
-		endpointQuery: new(endpointQuery),
-		applyInfo:     &applyInfo{},
-		stopChannel:   stopChannel,
+		networkID:        "",
+		endpointCache:    newEndpointCache(),
+		remotePods:       newRemotePodCache(),
+		conntrackFlushes: newConntrackFlushQueue(),
+		nodeName:         nodeName,
+		ioShim:           ioShim,
+		endpointQuery:    new(endpointQuery),
+		applyInfo:        &applyInfo{},
+		bootupInfo: &bootupInfo{
+			inProgress:      cfg.BufferEventsOnBootup,
+			pendingPolicies: make(map[string]*policies.NPMNetworkPolicy),
+		},
+		stopChannel: stopChannel,
 	}
 
 	// do not let Linux apply in background
@@ -115,12 +247,77 @@ func NewDataPlane(nodeName string, ioShim *common.IOShim, cfg *Config, stopChann
 	return dp, nil
 }
 
+// Cleanup removes all NPM sets and policies from the dataplane of the node without booting up NPM,
+// e.g. before uninstalling NPM or migrating to another policy engine.
+// If dryRun is true, nothing is removed. Returns the artifacts which were (or would be) removed.
+func Cleanup(ioShim *common.IOShim, cfg *Config, dryRun bool) ([]string, error) {
+	dp := &DataPlane{
+		Config:        cfg,
+		policyMgr:     policies.NewPolicyManager(ioShim, cfg.PolicyManagerCfg),
+		ipsetMgr:      ipsets.NewIPSetManager(cfg.IPSetManagerCfg, ioShim),
+		endpointCache: newEndpointCache(),
+		remotePods:    newRemotePodCache(),
+		ioShim:        ioShim,
+		endpointQuery: new(endpointQuery),
+		applyInfo:     &applyInfo{},
+		bootupInfo:    &bootupInfo{},
+	}
+	return dp.cleanupDataPlane(dryRun)
+}
+
+func ipsetArtifacts(setNames []string) []string {
+	artifacts := make([]string, 0, len(setNames))
+	for _, name := range setNames {
+		artifacts = append(artifacts, "ipset "+name)
+	}
+	return artifacts
+}
+
 // BootupDataplane cleans the NPM sets and policies in the dataplane and performs initialization.
 func (dp *DataPlane) BootupDataplane() error {
 	// NOTE: used to create an all-namespaces set, but there's no need since it will be created by the control plane
 	return dp.bootupDataPlane() //nolint:wrapcheck // unnecessary to wrap error
 }
 
+// FinishBootupPhase ends the bootup phase started when BufferEventsOnBootup is set.
+// All IPSet changes made during bootup are applied in one pass, and then the policies buffered during bootup are added.
+// Calls to the dataplane which would apply changes block until this finishes. A no-op outside of the bootup phase.
+func (dp *DataPlane) FinishBootupPhase() error {
+	dp.bootupInfo.Lock()
+	defer dp.bootupInfo.Unlock()
+
+	if !dp.bootupInfo.inProgress {
+		return nil
+	}
+	dp.bootupInfo.inProgress = false
+
+	klog.Infof("[DataPlane] finishing bootup phase with %d buffered policies", len(dp.bootupInfo.pendingPolicies))
+	if err := dp.applyDataPlaneNow(contextBootup); err != nil {
+		return err
+	}
+
+	policyKeys := make([]string, 0, len(dp.bootupInfo.pendingPolicies))
+	for policyKey := range dp.bootupInfo.pendingPolicies {
+		policyKeys = append(policyKeys, policyKey)
+	}
+	sort.Strings(policyKeys)
+
+	var failedPolicyKeys []string
+	for _, policyKey := range policyKeys {
+		if err := dp.addPolicy(dp.bootupInfo.pendingPolicies[policyKey], true); err != nil {
+			metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "[DataPlane] failed to add policy %s while finishing bootup: %s", policyKey, err.Error())
+			failedPolicyKeys = append(failedPolicyKeys, policyKey)
+		}
+	}
+	dp.bootupInfo.pendingPolicies = nil
+
+	if len(failedPolicyKeys) > 0 {
+		return fmt.Errorf("[DataPlane] failed to add policies %v while finishing bootup: %w", failedPolicyKeys, ErrBootupPolicies)
+	}
+	klog.Info("[DataPlane] finished bootup phase")
+	return nil
+}
+
 // RunPeriodicTasks runs periodic tasks. Should only be called once.
 func (dp *DataPlane) RunPeriodicTasks() {
 	go func() {
@@ -145,6 +342,38 @@ func (dp *DataPlane) RunPeriodicTasks() {
 		}
 	}()
 
+	if dp.ChainIntegrityCheckInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(dp.ChainIntegrityCheckInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-dp.stopChannel:
+					return
+				case <-ticker.C:
+					// in Windows, does nothing
+					// in Linux, doesn't lock policy manager
+					dp.policyMgr.CheckChainIntegrity()
+				}
+			}
+		}()
+	}
+
+	if dp.FlushConntrack {
+		go func() {
+			for {
+				select {
+				case <-dp.stopChannel:
+					return
+				case <-dp.conntrackFlushes.queued:
+					// the flushes queued while the previous ones ran are batched
+					dp.flushQueuedConntrack()
+				}
+			}
+		}()
+	}
+
 	if !dp.applyInBackground {
 		return
 	}
@@ -192,11 +421,19 @@ func (dp *DataPlane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, forceDelete
 // AddToSets takes in a list of IPSet names along with IP member
 // and then updates it local cache
 func (dp *DataPlane) AddToSets(setNames []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
-	err := dp.ipsetMgr.AddToSets(setNames, podMetadata.PodIP, podMetadata.PodKey)
+	err := dp.ipsetMgr.AddToSetsWithGeneration(setNames, podMetadata.PodIP, podMetadata.PodKey, podMetadata.Generation)
+	if errors.Is(err, ipsets.ErrStaleMember) {
+		// the IP was reused by a newer pod, whose policies mustn't be updated for this pod
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("[DataPlane] error while adding to set: %w", err)
 	}
 
+	if dp.shouldUpdatePod() || dp.FlushConntrack {
+		dp.remotePods.track(podMetadata, dp.nodeName)
+	}
+
 	if dp.shouldUpdatePod() && podMetadata.NodeName == dp.nodeName {
 		klog.Infof("[DataPlane] Updating Sets to Add for pod key %s", podMetadata.PodKey)
 
@@ -214,11 +451,15 @@ func (dp *DataPlane) AddToSets(setNames []*ipsets.IPSetMetadata, podMetadata *Po
 // RemoveFromSets takes in list of setnames from which a given IP member should be
 // removed and will update the local cache
 func (dp *DataPlane) RemoveFromSets(setNames []*ipsets.IPSetMetadata, podMetadata *PodMetadata) error {
-	err := dp.ipsetMgr.RemoveFromSets(setNames, podMetadata.PodIP, podMetadata.PodKey)
+	err := dp.ipsetMgr.RemoveFromSetsWithGeneration(setNames, podMetadata.PodIP, podMetadata.PodKey, podMetadata.Generation)
 	if err != nil {
 		return fmt.Errorf("[DataPlane] error while removing from set: %w", err)
 	}
 
+	if dp.shouldUpdatePod() || dp.FlushConntrack {
+		dp.remotePods.track(podMetadata, dp.nodeName)
+	}
+
 	if dp.shouldUpdatePod() && podMetadata.NodeName == dp.nodeName {
 		klog.Infof("[DataPlane] Updating Sets to Remove for pod key %s", podMetadata.PodKey)
 
@@ -259,6 +500,11 @@ func (dp *DataPlane) RemoveFromList(listName *ipsets.IPSetMetadata, setNames []*
 // and accordingly makes changes in dataplane. This function helps emulate a single call to
 // dataplane instead of multiple ipset operations calls ipset operations calls to dataplane
 func (dp *DataPlane) ApplyDataPlane() error {
+	if dp.isInBootupPhase() {
+		// all changes are applied at once in FinishBootupPhase()
+		return nil
+	}
+
 	if dp.applyInBackground {
 		return dp.incrementBatchAndApplyIfNeeded(contextApplyDP)
 	}
@@ -348,7 +594,29 @@ func (dp *DataPlane) applyDataPlaneNow(context string) error {
 // AddPolicy takes in a translated NPMNetworkPolicy object and applies on dataplane
 func (dp *DataPlane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
 	klog.Infof("[DataPlane] Add Policy called for %s", policy.PolicyKey)
+	return dp.addOrBufferPolicy(policy, true)
+}
 
+// addOrBufferPolicy adds the policy, or buffers it until the bootup finishes. The conntrack entries of its pods are
+// flushed if flushConntrack is true.
+func (dp *DataPlane) addOrBufferPolicy(policy *policies.NPMNetworkPolicy, flushConntrack bool) error {
+	if err := dp.checkBudget(policy); err != nil {
+		return err
+	}
+
+	dp.bootupInfo.Lock()
+	if dp.bootupInfo.inProgress {
+		klog.Infof("[DataPlane] buffering policy %s until bootup finishes", policy.PolicyKey)
+		dp.bootupInfo.pendingPolicies[policy.PolicyKey] = policy
+		dp.bootupInfo.Unlock()
+		return nil
+	}
+	dp.bootupInfo.Unlock()
+
+	return dp.addPolicy(policy, flushConntrack)
+}
+
+func (dp *DataPlane) addPolicy(policy *policies.NPMNetworkPolicy, flushConntrack bool) error {
 	// Create and add references for Selector IPSets first
 	err := dp.createIPSetsAndReferences(policy.AllPodSelectorIPSets(), policy.PolicyKey, ipsets.SelectorType)
 	if err != nil {
@@ -377,12 +645,85 @@ func (dp *DataPlane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
 	if err != nil {
 		return fmt.Errorf("[DataPlane] error while adding policy: %w", err)
 	}
+
+	if dp.FlushConntrack && flushConntrack {
+		dp.queueConntrackFlush(policy)
+	}
 	return nil
 }
 
+// queueConntrackFlush queues the flush of the conntrack entries of the local pods selected by the policy, for the
+// connections the policy denies: the connections to the pods for ingress and from the pods for egress. Pods on other
+// nodes are skipped since their policies are enforced there. The flushes are run in the background by flushQueuedConntrack.
+func (dp *DataPlane) queueConntrackFlush(policy *policies.NPMNetworkPolicy) {
+	if deniesIngress, deniesEgress := policy.DeniedDirections(); !deniesIngress && !deniesEgress {
+		// e.g. a policy allowing all traffic, or in audit mode
+		return
+	}
+	selectorIPSets := make(map[string]struct{}, len(policy.PodSelectorIPSets))
+	for _, set := range policy.PodSelectorIPSets {
+		selectorIPSets[set.Metadata.GetPrefixName()] = struct{}{}
+	}
+	ips, err := dp.ipsetMgr.GetIPsFromSelectorIPSets(selectorIPSets)
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "error: failed to get the pods of policy %s to flush conntrack: %s", policy.PolicyKey, err.Error())
+		return
+	}
+
+	queued := 0
+	for ip, podKey := range ips {
+		if dp.remotePods.isRemote(ip, podKey) {
+			continue
+		}
+		dp.conntrackFlushes.queue(ip, podKey, policy)
+		queued++
+	}
+	klog.Infof("[DataPlane] queued conntrack flush of %d pods of policy %s", queued, policy.PolicyKey)
+}
+
+// flushQueuedConntrack runs the queued conntrack flushes, once per pod IP for all its queued policies. Failures are only
+// logged and counted, the policies are programmed regardless.
+func (dp *DataPlane) flushQueuedConntrack() {
+	pending := dp.conntrackFlushes.dequeueAll()
+	total := 0
+	for ip, flush := range pending {
+		family := ipv4Family
+		if util.IsIPV6(ip) {
+			family = ipv6Family
+		}
+		n, err := dp.flushConntrackOfIP(family, ip, flush)
+		metrics.RecordConntrackFlush(family, n, err != nil)
+		if err != nil {
+			metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "error: failed to flush conntrack of pod %s with IP %s: %s", flush.podKey, ip, err.Error())
+			continue
+		}
+		total += n
+	}
+	klog.Infof("[DataPlane] flushed %d conntrack entries of %d pods", total, len(pending))
+}
+
+// sameRules returns whether the policies select the same pods with the same rules, in which case the connections
+// established under the old policy needn't be flushed.
+func sameRules(oldPolicy, newPolicy *policies.NPMNetworkPolicy) bool {
+	return reflect.DeepEqual(oldPolicy.PodSelectorIPSets, newPolicy.PodSelectorIPSets) &&
+		reflect.DeepEqual(oldPolicy.ChildPodSelectorIPSets, newPolicy.ChildPodSelectorIPSets) &&
+		reflect.DeepEqual(oldPolicy.RuleIPSets, newPolicy.RuleIPSets) &&
+		reflect.DeepEqual(oldPolicy.ACLs, newPolicy.ACLs)
+}
+
 // RemovePolicy takes in network policyKey (namespace/name of network policy) and removes it from dataplane and cache
 func (dp *DataPlane) RemovePolicy(policyKey string) error {
 	klog.Infof("[DataPlane] Remove Policy called for %s", policyKey)
+
+	dp.bootupInfo.Lock()
+	if dp.bootupInfo.inProgress {
+		// nothing has been programmed yet, so it's enough to forget the buffered policy
+		delete(dp.bootupInfo.pendingPolicies, policyKey)
+		dp.bootupInfo.Unlock()
+		return nil
+	}
+	dp.bootupInfo.Unlock()
+
 	// because policy Manager will remove from policy from cache
 	// keep a local copy to remove references for ipsets
 	policy, ok := dp.policyMgr.GetPolicy(policyKey)
@@ -446,6 +787,20 @@ func (dp *DataPlane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
 		return dp.AddPolicy(policy)
 	}
 
+	// keep the existing policy programmed if the updated policy doesn't fit
+	if err := dp.checkBudget(policy); err != nil {
+		return err
+	}
+
+	// the established connections are only flushed if the rules of the policy change, not on every resync
+	flushConntrack := true
+	if dp.FlushConntrack {
+		oldPolicy, _ := dp.policyMgr.GetPolicy(policy.PolicyKey)
+		// the cached policy was normalized when it was added
+		policies.NormalizePolicy(policy)
+		flushConntrack = !sameRules(oldPolicy, policy)
+	}
+
 	// TODO it would be ideal to calculate a diff of policies
 	// and remove/apply only the delta of IPSets and policies
 
@@ -455,13 +810,19 @@ func (dp *DataPlane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
 		return fmt.Errorf("[DataPlane] error while updating policy: %w", err)
 	}
 	// and add the new updated policy
-	err = dp.AddPolicy(policy)
+	err = dp.addOrBufferPolicy(policy, flushConntrack)
 	if err != nil {
 		return fmt.Errorf("[DataPlane] error while updating policy: %w", err)
 	}
 	return nil
 }
 
+func (dp *DataPlane) isInBootupPhase() bool {
+	dp.bootupInfo.Lock()
+	defer dp.bootupInfo.Unlock()
+	return dp.bootupInfo.inProgress
+}
+
 func (dp *DataPlane) GetAllIPSets() map[string]string {
 	return dp.ipsetMgr.GetAllIPSets()
 }
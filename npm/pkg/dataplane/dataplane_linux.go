@@ -1,9 +1,26 @@
 package dataplane
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/util"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"k8s.io/klog"
+)
+
+const conntrack = "conntrack"
+
+var (
+	// conntrackDeletedRegex matches the number of entries conntrack -D deleted, e.g.
+	// "conntrack v1.4.6 (conntrack-tools): 2 flow entries have been deleted."
+	conntrackDeletedRegex = regexp.MustCompile(`(\d+) flow entries have been deleted`)
+	// conntrackEntryRegex matches the protocol and the first tuple of an entry, which is its original direction
+	conntrackEntryRegex = regexp.MustCompile(`^(\w+)\s+\d+\s.*?\bsrc=(\S+) dst=(\S+)(?: sport=(\d+) dport=(\d+))?`)
 )
 
 func (dp *DataPlane) getEndpointsToApplyPolicy(policy *policies.NPMNetworkPolicy) (map[string]string, error) {
@@ -21,7 +38,14 @@ func (dp *DataPlane) updatePod(pod *updateNPMPod) error {
 }
 
 func (dp *DataPlane) bootupDataPlane() error {
-	util.DetectIptablesVersion(dp.ioShim)
+	detection := util.DetectIptablesVersion(dp.ioShim)
+	klog.Infof("detected iptables backend %s", detection.Backend)
+	metrics.RecordIPTablesBackend(detection.Backend, detection.Mixed)
+	if detection.Mixed {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID,
+			"warning: found kube rules in both nft and legacy iptables. NPM programs %s iptables (mirrored in both: %t)",
+			detection.Backend, dp.MirrorIptablesBackends)
+	}
 
 	// It is important to keep order to clean-up ACLs before ipsets. Otherwise we won't be able to delete ipsets referenced by ACLs
 	if err := dp.policyMgr.Bootup(nil); err != nil {
@@ -30,10 +54,109 @@ func (dp *DataPlane) bootupDataPlane() error {
 	if err := dp.ipsetMgr.ResetIPSets(); err != nil {
 		return npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to reset ipsets dataplane", err)
 	}
+	dp.migrateDataplane()
 	return nil
 }
 
+func (dp *DataPlane) cleanupDataPlane(dryRun bool) ([]string, error) {
+	util.DetectIptablesVersion(dp.ioShim)
+
+	// same order as bootup: ipsets can't be destroyed while iptables rules reference them
+	chains, err := dp.policyMgr.Cleanup(nil, dryRun)
+	if err != nil {
+		return chains, npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to clean up policy dataplane", err)
+	}
+	sets, err := dp.ipsetMgr.Cleanup(dryRun)
+	artifacts := append(chains, ipsetArtifacts(sets)...)
+	if err != nil {
+		return artifacts, npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to clean up ipsets dataplane", err)
+	}
+	return artifacts, nil
+}
+
 func (dp *DataPlane) refreshPodEndpoints() error {
 	// NOOP in Linux
 	return nil
 }
+
+// flushConntrackOfIP deletes the conntrack entries of the connections to the IP which the queued policies of its pod
+// deny for ingress, and of the connections from the IP they deny for egress. Returns the number of entries deleted.
+func (dp *DataPlane) flushConntrackOfIP(family, ip string, flush conntrackFlush) (int, error) {
+	deniesIngress, deniesEgress := flush.deniedDirections()
+	directions := []struct {
+		direction policies.Direction
+		filter    string
+		denied    bool
+	}{
+		{direction: policies.Ingress, filter: "--orig-dst", denied: deniesIngress},
+		{direction: policies.Egress, filter: "--orig-src", denied: deniesEgress},
+	}
+
+	deleted := 0
+	for _, d := range directions {
+		if !d.denied {
+			continue
+		}
+		output, err := dp.ioShim.Exec.Command(conntrack, "-L", "-f", family, d.filter, ip).CombinedOutput()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list conntrack entries with %s %s: %w", d.filter, ip, err)
+		}
+
+		flushed := make(map[connFlow]struct{})
+		for _, line := range strings.Split(string(output), "\n") {
+			flow, ok := parseConntrackEntry(line)
+			if !ok || !dp.deniesFlow(flush, d.direction, flow) {
+				continue
+			}
+			// entries of protocols without ports, e.g. ICMP, are deleted together for the same addresses
+			if _, ok := flushed[flow]; ok {
+				continue
+			}
+			flushed[flow] = struct{}{}
+			n, err := dp.deleteConntrackEntries(family, flow)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// parseConntrackEntry parses the original direction of an entry listed by conntrack -L, e.g.
+// "tcp      6 431999 ESTABLISHED src=10.0.0.5 dst=10.0.0.6 sport=43210 dport=80 src=10.0.0.6 dst=10.0.0.5 ..."
+// Entries of protocols which conntrack can't filter on by name are skipped.
+func parseConntrackEntry(line string) (connFlow, bool) {
+	match := conntrackEntryRegex.FindStringSubmatch(line)
+	if match == nil || match[1] == "unknown" {
+		return connFlow{}, false
+	}
+	flow := connFlow{protocol: match[1], src: match[2], dst: match[3]}
+	if match[4] != "" {
+		srcPort, _ := strconv.ParseInt(match[4], 10, 32)
+		dstPort, _ := strconv.ParseInt(match[5], 10, 32)
+		flow.srcPort = int32(srcPort)
+		flow.dstPort = int32(dstPort)
+	}
+	return flow, true
+}
+
+// deleteConntrackEntries deletes the conntrack entries of the flow. Returns the number of entries deleted.
+func (dp *DataPlane) deleteConntrackEntries(family string, flow connFlow) (int, error) {
+	args := []string{"-D", "-f", family, "-p", flow.protocol, "--orig-src", flow.src, "--orig-dst", flow.dst}
+	if flow.srcPort != 0 {
+		args = append(args, "--sport", strconv.Itoa(int(flow.srcPort)), "--dport", strconv.Itoa(int(flow.dstPort)))
+	}
+	output, err := dp.ioShim.Exec.Command(conntrack, args...).CombinedOutput()
+	// conntrack exits with 1 if there was no entry to delete, e.g. if the connection was closed since it was listed,
+	// so the output tells whether it failed
+	match := conntrackDeletedRegex.FindSubmatch(output)
+	if match == nil {
+		if err == nil {
+			err = fmt.Errorf("unexpected output: %s", output)
+		}
+		return 0, fmt.Errorf("failed to delete conntrack entries of %s flow from %s to %s: %w", flow.protocol, flow.src, flow.dst, err)
+	}
+	n, _ := strconv.Atoi(string(match[1]))
+	return n, nil
+}
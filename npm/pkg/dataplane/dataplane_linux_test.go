@@ -0,0 +1,136 @@
+package dataplane
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushConntrack(t *testing.T) {
+	metrics.InitializeAll()
+
+	calls := append(getBootupTestCalls(),
+		testutils.TestCmd{
+			Cmd: []string{"conntrack", "-L", "-f", "ipv4", "--orig-dst", "10.0.0.1"},
+			Stdout: "tcp      6 431999 ESTABLISHED src=10.0.0.3 dst=10.0.0.1 sport=43210 dport=80 src=10.0.0.1 dst=10.0.0.3 sport=80 dport=43210 [ASSURED] mark=0 use=1\n" +
+				"tcp      6 431999 ESTABLISHED src=10.0.0.3 dst=10.0.0.1 sport=43211 dport=8080 src=10.0.0.1 dst=10.0.0.3 sport=8080 dport=43211 [ASSURED] mark=0 use=1\n" +
+				"icmp     1 29 src=10.0.0.4 dst=10.0.0.1 type=8 code=0 id=7 src=10.0.0.1 dst=10.0.0.4 type=0 code=0 id=7 mark=0 use=1\n" +
+				"conntrack v1.4.6 (conntrack-tools): 3 flow entries have been shown.",
+		},
+		// the connection to the allowed port of the allowed peer is left alone
+		testutils.TestCmd{
+			Cmd:    []string{"conntrack", "-D", "-f", "ipv4", "-p", "tcp", "--orig-src", "10.0.0.3", "--orig-dst", "10.0.0.1", "--sport", "43211", "--dport", "8080"},
+			Stdout: "conntrack v1.4.6 (conntrack-tools): 1 flow entries have been deleted.",
+		},
+		testutils.TestCmd{
+			Cmd:    []string{"conntrack", "-D", "-f", "ipv4", "-p", "icmp", "--orig-src", "10.0.0.4", "--orig-dst", "10.0.0.1"},
+			Stdout: "conntrack v1.4.6 (conntrack-tools): 1 flow entries have been deleted.",
+		},
+		testutils.TestCmd{
+			Cmd: []string{"conntrack", "-L", "-f", "ipv4", "--orig-src", "10.0.0.1"},
+			Stdout: "udp      17 29 src=10.0.0.1 dst=10.0.0.10 sport=5353 dport=53 [UNREPLIED] src=10.0.0.10 dst=10.0.0.1 sport=53 dport=5353 mark=0 use=1\n" +
+				"conntrack v1.4.6 (conntrack-tools): 1 flow entries have been shown.",
+		},
+		// the connection was closed since it was listed
+		testutils.TestCmd{
+			Cmd:      []string{"conntrack", "-D", "-f", "ipv4", "-p", "udp", "--orig-src", "10.0.0.1", "--orig-dst", "10.0.0.10", "--sport", "5353", "--dport", "53"},
+			Stdout:   "conntrack v1.4.6 (conntrack-tools): 0 flow entries have been deleted.",
+			ExitCode: 1,
+		},
+	)
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	cfg := *dpCfg
+	cfg.FlushConntrack = true
+	dp, err := NewDataPlane("testnode", ioshim, &cfg, nil)
+	require.NoError(t, err)
+
+	nsSet := ipsets.NewIPSetMetadata("testns", ipsets.Namespace)
+	peerSet := ipsets.NewIPSetMetadata("peerns", ipsets.Namespace)
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/a", "10.0.0.1", "testnode")))
+	// the policies of pods on other nodes are enforced there
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet}, NewPodMetadata("testns/b", "10.0.0.2", "othernode")))
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{peerSet}, NewPodMetadata("peerns/c", "10.0.0.3", "othernode")))
+
+	allowPeer := policies.NewACLPolicy(policies.Allowed, policies.Ingress)
+	allowPeer.AddSetInfo([]policies.SetInfo{policies.NewSetInfo("peerns", ipsets.Namespace, true, policies.SrcMatch)})
+	allowPeer.Protocol = policies.TCP
+	allowPeer.DstPorts = policies.Ports{Port: 80, EndPort: 80}
+	ingress := &policies.NPMNetworkPolicy{
+		PolicyKey:         "testns/allow-peer-ingress",
+		PodSelectorIPSets: []*ipsets.TranslatedIPSet{{Metadata: nsSet}},
+		ACLs:              []*policies.ACLPolicy{allowPeer, policies.NewACLPolicy(policies.Dropped, policies.Ingress)},
+	}
+	egress := &policies.NPMNetworkPolicy{
+		PolicyKey:         "testns/deny-egress",
+		PodSelectorIPSets: []*ipsets.TranslatedIPSet{{Metadata: nsSet}},
+		ACLs:              []*policies.ACLPolicy{policies.NewACLPolicy(policies.Dropped, policies.Egress)},
+	}
+	audit := &policies.NPMNetworkPolicy{
+		PolicyKey:         "testns/audit",
+		PodSelectorIPSets: []*ipsets.TranslatedIPSet{{Metadata: nsSet}},
+		ACLs:              []*policies.ACLPolicy{policies.NewACLPolicy(policies.Audited, policies.Ingress)},
+	}
+	// the flushes of the pods of the policies are batched, once per pod, and a policy which drops nothing flushes nothing
+	dp.queueConntrackFlush(ingress)
+	dp.queueConntrackFlush(egress)
+	dp.queueConntrackFlush(audit)
+
+	entries, err := metrics.GetConntrackFlushedEntryCount(ipv4Family)
+	require.NoError(t, err)
+	dp.flushQueuedConntrack()
+	newEntries, err := metrics.GetConntrackFlushedEntryCount(ipv4Family)
+	require.NoError(t, err)
+	require.Equal(t, entries+2, newEntries)
+
+	// nothing is left to flush
+	dp.flushQueuedConntrack()
+}
+
+func TestSameRules(t *testing.T) {
+	nsSet := ipsets.NewIPSetMetadata("testns", ipsets.Namespace)
+	newPolicy := func(target policies.Verdict) *policies.NPMNetworkPolicy {
+		policy := &policies.NPMNetworkPolicy{
+			PolicyKey:         "testns/policy",
+			PodSelectorIPSets: []*ipsets.TranslatedIPSet{{Metadata: nsSet}},
+			ACLs:              []*policies.ACLPolicy{policies.NewACLPolicy(target, policies.Ingress)},
+		}
+		policies.NormalizePolicy(policy)
+		return policy
+	}
+
+	// a resync of the policy doesn't flush the established connections
+	require.True(t, sameRules(newPolicy(policies.Dropped), newPolicy(policies.Dropped)))
+	require.False(t, sameRules(newPolicy(policies.Dropped), newPolicy(policies.Allowed)))
+
+	otherSelector := newPolicy(policies.Dropped)
+	otherSelector.PodSelectorIPSets = []*ipsets.TranslatedIPSet{{Metadata: ipsets.NewIPSetMetadata("otherns", ipsets.Namespace)}}
+	require.False(t, sameRules(newPolicy(policies.Dropped), otherSelector))
+}
+
+func TestFlushConntrackOfIPFailure(t *testing.T) {
+	metrics.InitializeAll()
+
+	calls := append(getBootupTestCalls(), testutils.TestCmd{
+		Cmd:      []string{"conntrack", "-L", "-f", "ipv6", "--orig-dst", "fd00::1"},
+		Stdout:   "conntrack: command not found",
+		ExitCode: 127,
+	})
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	dp, err := NewDataPlane("testnode", ioshim, dpCfg, nil)
+	require.NoError(t, err)
+
+	denyIngress := &policies.NPMNetworkPolicy{
+		PolicyKey: "testns/deny-ingress",
+		ACLs:      []*policies.ACLPolicy{policies.NewACLPolicy(policies.Dropped, policies.Ingress)},
+	}
+	flush := conntrackFlush{podKey: "testns/a", policies: map[string]*policies.NPMNetworkPolicy{denyIngress.PolicyKey: denyIngress}}
+	_, err = dp.flushConntrackOfIP(ipv6Family, "fd00::1", flush)
+	require.Error(t, err)
+}
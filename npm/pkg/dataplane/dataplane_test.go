@@ -191,6 +191,37 @@ func TestAddToSet(t *testing.T) {
 	}
 }
 
+func TestPodIPReuse(t *testing.T) {
+	metrics.InitializeAll()
+
+	calls := getBootupTestCalls()
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	dp, err := NewDataPlane("testnode", ioshim, dpCfg, nil)
+	require.NoError(t, err)
+
+	nsSet := ipsets.NewIPSetMetadata("testns", ipsets.Namespace)
+	oldLabelSet := ipsets.NewIPSetMetadata("app:old", ipsets.KeyValueLabelOfPod)
+	staleLabelSet := ipsets.NewIPSetMetadata("tier:old", ipsets.KeyValueLabelOfPod)
+	newLabelSet := ipsets.NewIPSetMetadata("app:new", ipsets.KeyValueLabelOfPod)
+
+	oldPod := NewPodMetadata("testns/old", "10.0.0.1", "")
+	oldPod.Generation = 1
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet, oldLabelSet}, oldPod))
+
+	// the IP is reused by a new pod before the old pod's update and delete events are handled
+	newPod := NewPodMetadata("testns/new", "10.0.0.1", "")
+	newPod.Generation = 2
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet, newLabelSet}, newPod))
+	require.NoError(t, dp.AddToSets([]*ipsets.IPSetMetadata{nsSet, staleLabelSet}, oldPod))
+	require.NoError(t, dp.RemoveFromSets([]*ipsets.IPSetMetadata{nsSet, oldLabelSet}, oldPod))
+
+	require.Equal(t, "testns/new", dp.ipsetMgr.GetIPSet(nsSet.GetPrefixName()).IPPodKey["10.0.0.1"])
+	require.Equal(t, "testns/new", dp.ipsetMgr.GetIPSet(newLabelSet.GetPrefixName()).IPPodKey["10.0.0.1"])
+	require.Empty(t, dp.ipsetMgr.GetIPSet(oldLabelSet.GetPrefixName()).IPPodKey)
+	require.Nil(t, dp.ipsetMgr.GetIPSet(staleLabelSet.GetPrefixName()))
+}
+
 func TestApplyPolicy(t *testing.T) {
 	metrics.InitializeAll()
 
@@ -250,6 +281,88 @@ func TestUpdatePolicy(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestBufferPoliciesDuringBootup(t *testing.T) {
+	metrics.InitializeAll()
+
+	bufferCfg := *dpCfg
+	bufferCfg.BufferEventsOnBootup = true
+
+	// nothing is programmed until the bootup phase finishes
+	calls := append(getBootupTestCalls(), getAddPolicyTestCallsForDP(&testPolicyobj)...)
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	dp, err := NewDataPlane("testnode", ioshim, &bufferCfg, nil)
+	require.NoError(t, err)
+
+	removedPolicyobj := testPolicyobj
+	removedPolicyobj.PolicyKey = "ns1/removedpolicy"
+	require.NoError(t, dp.AddPolicy(&removedPolicyobj))
+	require.NoError(t, dp.AddPolicy(&testPolicyobj))
+	require.NoError(t, dp.RemovePolicy(removedPolicyobj.PolicyKey))
+	require.NoError(t, dp.ApplyDataPlane())
+	require.False(t, dp.policyMgr.PolicyExists(testPolicyobj.PolicyKey))
+
+	require.NoError(t, dp.FinishBootupPhase())
+	require.True(t, dp.policyMgr.PolicyExists(testPolicyobj.PolicyKey))
+	require.False(t, dp.policyMgr.PolicyExists(removedPolicyobj.PolicyKey))
+
+	// finishing again is a no-op
+	require.NoError(t, dp.FinishBootupPhase())
+}
+
+func TestPolicyBudget(t *testing.T) {
+	metrics.InitializeAll()
+
+	budgetCfg := *dpCfg
+	budgetCfg.Budget = Budget{MaxRules: 1}
+
+	otherPolicyobj := testPolicyobj
+	otherPolicyobj.PolicyKey = "ns1/otherpolicy"
+	otherPolicyobj.ACLPolicyID = "azure-acl-ns1-otherpolicy"
+
+	calls := append(getBootupTestCalls(), getAddPolicyTestCallsForDP(&testPolicyobj)...)
+	calls = append(calls, getRemovePolicyTestCallsForDP(&testPolicyobj)...)
+	calls = append(calls, getAddPolicyTestCallsForDP(&otherPolicyobj)...)
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	dp, err := NewDataPlane("testnode", ioshim, &budgetCfg, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, dp.AddPolicy(&testPolicyobj))
+	require.Equal(t, 1, dp.Usage().Rules)
+
+	// nothing is programmed for a policy exceeding the budget
+	err = dp.AddPolicy(&otherPolicyobj)
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	require.False(t, dp.policyMgr.PolicyExists(otherPolicyobj.PolicyKey))
+
+	// an update exceeding the budget keeps the existing policy
+	biggerPolicyobj := testPolicyobj
+	biggerPolicyobj.ACLs = append(biggerPolicyobj.ACLs, &policies.ACLPolicy{Target: policies.Dropped, Direction: policies.Ingress})
+	require.ErrorIs(t, dp.UpdatePolicy(&biggerPolicyobj), ErrBudgetExceeded)
+	require.True(t, dp.policyMgr.PolicyExists(testPolicyobj.PolicyKey))
+
+	// the policy fits once usage drops
+	require.NoError(t, dp.RemovePolicy(testPolicyobj.PolicyKey))
+	require.NoError(t, dp.AddPolicy(&otherPolicyobj))
+}
+
+func TestPolicyBudgetIPSets(t *testing.T) {
+	metrics.InitializeAll()
+
+	budgetCfg := *dpCfg
+	budgetCfg.Budget = Budget{MaxIPSets: len(getAffectedIPSets(&testPolicyobj)) - 1}
+
+	calls := getBootupTestCalls()
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	dp, err := NewDataPlane("testnode", ioshim, &budgetCfg, nil)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, dp.AddPolicy(&testPolicyobj), ErrBudgetExceeded)
+	require.Equal(t, Usage{}, dp.Usage())
+}
+
 func TestUpdatePodCache(t *testing.T) {
 	m1 := NewPodMetadata("x/a", "10.0.0.1", nodeName)
 	m2 := NewPodMetadata("x/b", "10.0.0.2", nodeName)
@@ -392,6 +505,27 @@ func TestUpdatePodCache(t *testing.T) {
 	require.Equal(t, c.cache, map[string]*updateNPMPod{m1.PodKey: p1, m3Node2.PodKey: p})
 }
 
+func TestRemotePodCache(t *testing.T) {
+	c := newRemotePodCache()
+
+	// a pod in another subnet on another node
+	c.track(NewPodMetadata("x/a", "10.1.0.1", "other-node"), nodeName)
+	require.True(t, c.isRemote("10.1.0.1", "x/a"))
+	require.False(t, c.isRemote("10.1.0.1", "x/b"))
+
+	// the IP is reused by a pod on this node
+	c.track(NewPodMetadata("x/b", "10.1.0.1", nodeName), nodeName)
+	require.False(t, c.isRemote("10.1.0.1", "x/a"))
+
+	// deleting a pod which no longer owns the IP keeps the new remote owner
+	c.track(NewPodMetadata("x/c", "10.1.0.2", "other-node"), nodeName)
+	c.track(NewPodMetadata("x/d", "10.1.0.2", ""), nodeName)
+	require.True(t, c.isRemote("10.1.0.2", "x/c"))
+	c.track(NewPodMetadata("x/c", "10.1.0.2", ""), nodeName)
+	require.False(t, c.isRemote("10.1.0.2", "x/c"))
+	require.Empty(t, c.cache)
+}
+
 func getBootupTestCalls() []testutils.TestCmd {
 	return append(policies.GetBootupTestCalls(true), ipsets.GetResetTestCalls()...)
 }
@@ -21,6 +21,8 @@ const (
 	// used for lints
 	hcnSchemaMajorVersion = 2
 	hcnSchemaMinorVersion = 0
+
+	vfpctrl = "vfpctrl.exe"
 )
 
 var errPolicyModeUnsupported = errors.New("only IPSet policy mode is supported")
@@ -118,9 +120,45 @@ func (dp *DataPlane) bootupDataPlane() error {
 	if err := dp.ipsetMgr.ResetIPSets(); err != nil {
 		return npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to reset ipsets dataplane", err)
 	}
+	dp.migrateDataplane()
 	return nil
 }
 
+func (dp *DataPlane) cleanupDataPlane(dryRun bool) ([]string, error) {
+	if dp.NetworkName == "" {
+		dp.NetworkName = util.AzureNetworkName
+	}
+	// don't wait for the network like initializeDataPlane: without it, there is nothing to clean up
+	if err := dp.setNetworkIDByName(dp.NetworkName); err != nil {
+		if isNetworkNotFoundErr(err) {
+			klog.Infof("[DataPlane] network %s not found. nothing to clean up", dp.NetworkName)
+			return nil, nil
+		}
+		return nil, npmerrors.SimpleErrorWrapper("failed to get network info", err)
+	}
+
+	allEndpoints, err := dp.getAllPodEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	epIDs := make([]string, len(allEndpoints))
+	for k, e := range allEndpoints {
+		epIDs[k] = e.Id
+	}
+
+	// same order as bootup: SetPolicies can't be deleted while ACLs reference them
+	acls, err := dp.policyMgr.Cleanup(epIDs, dryRun)
+	if err != nil {
+		return acls, npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to clean up policy dataplane", err)
+	}
+	sets, err := dp.ipsetMgr.Cleanup(dryRun)
+	artifacts := append(acls, ipsetArtifacts(sets)...)
+	if err != nil {
+		return artifacts, npmerrors.ErrorWrapper(npmerrors.BootupDataplane, false, "failed to clean up ipsets dataplane", err)
+	}
+	return artifacts, nil
+}
+
 func (dp *DataPlane) shouldUpdatePod() bool {
 	return true
 }
@@ -303,9 +341,15 @@ func (dp *DataPlane) getEndpointsToApplyPolicy(policy *policies.NPMNetworkPolicy
 	defer dp.endpointCache.Unlock()
 
 	endpointList := make(map[string]string)
+	remotePodCount := 0
 	for ip, podKey := range netpolSelectorIPs {
 		endpoint, ok := dp.endpointCache.cache[ip]
 		if !ok {
+			if dp.remotePods.isRemote(ip, podKey) {
+				// the ACLs of local endpoints match remote pods through the SetPolicies of the network
+				remotePodCount++
+				continue
+			}
 			klog.Infof("[DataPlane] ignoring selector IP since it was not found in the endpoint cache and might not be in the HNS network. ip: %s. podKey: %s", ip, podKey)
 			continue
 		}
@@ -319,9 +363,39 @@ func (dp *DataPlane) getEndpointsToApplyPolicy(policy *policies.NPMNetworkPolicy
 		endpointList[ip] = endpoint.id
 		endpoint.netPolReference[policy.PolicyKey] = struct{}{}
 	}
+	if remotePodCount > 0 {
+		klog.Infof("[DataPlane] policy %s selects %d pods on other nodes, which are only matched in SetPolicies", policy.PolicyKey, remotePodCount)
+	}
 	return endpointList, nil
 }
 
+// flushConntrackOfIP flushes the VFP flows of the endpoint with the IP if the queued policies of its pod deny some of its
+// ingress or egress traffic. The flows of the endpoint's port are flushed together, so the ones the policies allow are
+// evaluated against them again and keep flowing. VFP doesn't report how many flows were flushed, so 0 is returned.
+func (dp *DataPlane) flushConntrackOfIP(_, ip string, flush conntrackFlush) (int, error) {
+	if deniesIngress, deniesEgress := flush.deniedDirections(); !deniesIngress && !deniesEgress {
+		return 0, nil
+	}
+
+	dp.endpointCache.Lock()
+	endpoint, ok := dp.endpointCache.cache[ip]
+	var endpointID string
+	if ok {
+		endpointID = endpoint.id
+	}
+	dp.endpointCache.Unlock()
+	if !ok {
+		// the endpoint was deleted since the flush was queued, so it has no flows left
+		return 0, nil
+	}
+
+	output, err := dp.ioShim.Exec.Command(vfpctrl, "/port", endpointID, "/flush-unified-flows").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to flush vfp flows of endpoint %s with IP %s: %w: %s", endpointID, ip, err, output)
+	}
+	return 0, nil
+}
+
 func (dp *DataPlane) getAllPodEndpoints() ([]*hcn.HostComputeEndpoint, error) {
 	klog.Infof("getting all endpoints for network ID %s", dp.networkID)
 	endpoints, err := dp.ioShim.Hns.ListEndpointsOfNetwork(dp.networkID)
@@ -38,6 +38,10 @@ func TestRemoteEndpoints(t *testing.T) {
 	testSerialCases(t, remoteEndpointTests(), 0)
 }
 
+func TestRemoteSubnets(t *testing.T) {
+	testSerialCases(t, remoteSubnetTests(), 0)
+}
+
 func TestAllMultiJobCases(t *testing.T) {
 	testMultiJobCases(t, getAllMultiJobTests(), 0)
 }
@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"strconv"
@@ -26,9 +27,10 @@ import (
 )
 
 var (
-	ErrUnknownSetType = fmt.Errorf("unknown set type")
-	EgressChain       = "AZURE-NPM-EGRESS"
-	EgressChainPrefix = EgressChain + "-"
+	ErrUnknownSetType   = fmt.Errorf("unknown set type")
+	ErrCachePageRequest = fmt.Errorf("failed to get cache page")
+	EgressChain         = "AZURE-NPM-EGRESS"
+	EgressChainPrefix   = EgressChain + "-"
 
 	IngressChain       = "AZURE-NPM-INGRESS"
 	IngressChainPrefix = IngressChain + "-"
@@ -62,33 +64,51 @@ func (c *Converter) NpmCacheFromFile(npmCacheJSONFile string) error {
 }
 
 // NpmCache initialize NPM cache from node.
+// Pods and namespaces are fetched from the node as they are looked up, see pagedCache.
 func (c *Converter) NpmCache() error {
+	cache, err := newPagedCache(c.fetchCachePage, c.EnableV2NPM)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get cache from debug http endpoint")
+	}
+	c.NPMCache = cache
+
+	return nil
+}
+
+// fetchCachePage requests a page of a map of the NPM cache from the node.
+func (c *Converter) fetchCachePage(key models.CacheKey, start string, limit int) (*npmcommon.CachePage, error) {
+	query := url.Values{}
+	query.Set(api.CacheKeyParam, string(key))
+	query.Set(api.CacheStartParam, start)
+	query.Set(api.CacheLimitParam, strconv.Itoa(limit))
 	req, err := http.NewRequestWithContext(
 		context.Background(),
 		http.MethodGet,
-		fmt.Sprintf("%v:%v%v", c.NPMDebugEndpointHost, c.NPMDebugEndpointPort, api.NPMMgrPath),
+		fmt.Sprintf("%v:%v%v?%v", c.NPMDebugEndpointHost, c.NPMDebugEndpointPort, api.NPMCachePath, query.Encode()),
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create http request : %w", err)
+		return nil, fmt.Errorf("failed to create http request : %w", err)
 	}
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to request NPM Cache : %w", err)
+		return nil, fmt.Errorf("failed to request NPM Cache : %w", err)
 	}
 	defer resp.Body.Close()
 	byteArray, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response's data : %w", err)
+		return nil, fmt.Errorf("failed to read response's data : %w", err)
 	}
-
-	err = c.getCacheFromBytes(byteArray)
-	if err != nil {
-		return errors.Wrapf(err, "failed to get cache from debug http endpoint")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: %s", ErrCachePageRequest, resp.Status, strings.TrimSpace(string(byteArray)))
 	}
 
-	return nil
+	page := &npmcommon.CachePage{}
+	if err := json.Unmarshal(byteArray, page); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cache page : %w", err)
+	}
+	return page, nil
 }
 
 // Hello Time Traveler:
@@ -337,6 +357,9 @@ func (c *Converter) getRulesFromChain(iptableChain *NPMIPtable.Chain) ([]*pb.Rul
 
 			case util.IptablesAzureAcceptChain:
 				rule.Allowed = true
+			case util.IptablesLog:
+				// rules of policies in audit mode don't drop anything
+				continue
 			default:
 				// ignore other targets
 				rule.Allowed = false
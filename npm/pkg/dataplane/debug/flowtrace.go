@@ -0,0 +1,183 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/pkg/errors"
+)
+
+// flowTraceComment is the comment of the rules and the name of the filters installed to trace a flow,
+// so that leftovers of an interrupted trace can be found.
+const flowTraceComment = "azure-npm-trace"
+
+// Verdicts of the trace events which end the path of a packet.
+const (
+	VerdictAccept = "ACCEPT"
+	VerdictDrop   = "DROP"
+	VerdictReject = "REJECT"
+)
+
+var (
+	errExternalFlowTrace = errors.New("flows from or to External can't be traced, specify a pod or an IP")
+	errNoFlowTraceIP     = errors.New("pod has no IP")
+)
+
+// TraceEvent is a step of the path of a traced packet: a rule or policy of an iptables chain on Linux,
+// or a drop by a component of the data path on Windows.
+type TraceEvent struct {
+	Table string
+	Chain string
+	// Rule is the rule matched, as in iptables -S, empty for the policy or the return of the chain.
+	Rule string
+	// Verdict is the target of the rule, e.g. ACCEPT, DROP, RETURN or the chain jumped to.
+	Verdict string
+	// Detail is what else the trace tells about the step, e.g. the drop reason on Windows.
+	Detail string
+}
+
+// PacketTrace is the path of a packet of the flow.
+type PacketTrace struct {
+	ID     string
+	Events []*TraceEvent
+}
+
+// Verdict returns the event which decided the fate of the packet: the first DROP or REJECT,
+// else the last ACCEPT of the filter table, where NPM programs its chains, else the last event.
+func (p *PacketTrace) Verdict() *TraceEvent {
+	var decisive *TraceEvent
+	for _, event := range p.Events {
+		switch {
+		case event.Verdict == VerdictDrop || event.Verdict == VerdictReject:
+			return event
+		case event.Verdict == VerdictAccept && (event.Table == "" || event.Table == util.IptablesFilterTable):
+			decisive = event
+		}
+	}
+	if decisive == nil && len(p.Events) > 0 {
+		decisive = p.Events[len(p.Events)-1]
+	}
+	return decisive
+}
+
+// NPMChains returns the NPM chains the packet went through, in order.
+func (p *PacketTrace) NPMChains() []string {
+	var chains []string
+	for _, event := range p.Events {
+		if !strings.HasPrefix(event.Chain, util.IptablesAzureChain) {
+			continue
+		}
+		if len(chains) > 0 && chains[len(chains)-1] == event.Chain {
+			continue
+		}
+		chains = append(chains, event.Chain)
+	}
+	return chains
+}
+
+// flowTracer traces the packets of a flow in the data path of the OS.
+type flowTracer interface {
+	// collect returns the packets traced so far.
+	collect() ([]*PacketTrace, error)
+	// stop removes what was installed to trace the flow.
+	stop() error
+}
+
+// TraceFlow traces the packets from srcIP to dstIP for the duration, or until the context is done,
+// and returns their paths through the data path. What is installed to trace the flow is removed before returning.
+// Linux traces with iptables TRACE rules in the raw table, Windows captures the drops with pktmon.
+func TraceFlow(ctx context.Context, ioShim *common.IOShim, srcIP, dstIP string, duration time.Duration) ([]*PacketTrace, error) {
+	tracer, err := startFlowTrace(ioShim, srcIP, dstIP)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to start tracing flow %s -> %s", srcIP, dstIP)
+	}
+	defer func() {
+		if err := tracer.stop(); err != nil {
+			fmt.Printf("failed to clean up the trace of flow %s -> %s: %v\n", srcIP, dstIP, err)
+		}
+	}()
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	packets, err := tracer.collect()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to collect the trace of flow %s -> %s", srcIP, dstIP)
+	}
+	return packets, nil
+}
+
+// FlowTraceIP returns the IP to trace for the source or destination input, the IP itself or the IP of the pod,
+// looked up in the NPM cache of the node.
+func (c *Converter) FlowTraceIP(input *npmcommon.Input) (string, error) {
+	switch input.Type {
+	case npmcommon.IPADDRS:
+		return input.Content, nil
+	case npmcommon.EXTERNAL:
+		return "", errExternalFlowTrace
+	}
+
+	if c.NPMCache == nil {
+		if err := c.NpmCache(); err != nil {
+			return "", err
+		}
+	}
+	pod, err := c.NPMCache.GetPod(input)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get pod %s", input.Content)
+	}
+	if pod.PodIP == "" {
+		return "", errors.Wrapf(errNoFlowTraceIP, "pod %s", input.Content)
+	}
+	return pod.PodIP, nil
+}
+
+// PrettyPrintFlowTrace prints the verdict of each traced packet, the NPM chains it went through and its path.
+func PrettyPrintFlowTrace(w io.Writer, packets []*PacketTrace) {
+	if len(packets) == 0 {
+		fmt.Fprintf(w, "No packets of the flow were traced, was traffic sent during the trace?\n")
+		return
+	}
+
+	for _, packet := range packets {
+		fmt.Fprintf(w, "Packet %s:\n", packet.ID)
+		if verdict := packet.Verdict(); verdict != nil {
+			fmt.Fprintf(w, "\tVerdict: %s by %s\n", verdict.Verdict, eventLocation(verdict))
+			if verdict.Rule != "" {
+				fmt.Fprintf(w, "\tRule: %s\n", verdict.Rule)
+			}
+			if verdict.Detail != "" {
+				fmt.Fprintf(w, "\tDetail: %s\n", verdict.Detail)
+			}
+		}
+		if chains := packet.NPMChains(); len(chains) > 0 {
+			fmt.Fprintf(w, "\tNPM chains: %s\n", strings.Join(chains, " -> "))
+		}
+		fmt.Fprintf(w, "\tPath:\n")
+		for _, event := range packet.Events {
+			fmt.Fprintf(w, "\t\t%s: %s\n", eventLocation(event), event.Verdict)
+		}
+	}
+}
+
+func eventLocation(event *TraceEvent) string {
+	if event.Table == "" {
+		return event.Chain
+	}
+	return event.Table + ":" + event.Chain
+}
+
+// lines returns the non-empty lines of the output of a command.
+func lines(output []byte) []string {
+	return strings.FieldsFunc(string(output), func(r rune) bool { return r == '\n' || r == '\r' })
+}
@@ -0,0 +1,364 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/pkg/errors"
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	traceTable = "raw"
+	// xtablesMonitor prints the traces of the nft backend.
+	xtablesMonitor = "xtables-monitor"
+	dmesg          = "dmesg"
+)
+
+// traceChains are the chains of the raw table the packets of the flow enter, from a pod or another host and from
+// the host itself.
+var traceChains = []string{"PREROUTING", util.IptablesOutputChain}
+
+var (
+	// legacyTraceLine matches the kernel log of the legacy backend,
+	// e.g. "TRACE: filter:AZURE-NPM:rule:3 IN=eth0 OUT=azure0 SRC=10.0.0.1 DST=10.0.0.2 ... ID=5678 ...".
+	legacyTraceLine = regexp.MustCompile(`TRACE: ([^:]+):(\S+):(rule|return|policy):(\d+) (.*)$`)
+	// nftTraceLine matches xtables-monitor --trace,
+	// e.g. "TRACE: 2 fc04d4d2 filter:AZURE-NPM:rule:0x2d:JUMP:AZURE-NPM-INGRESS  -4 -t filter -A AZURE-NPM -j AZURE-NPM-INGRESS".
+	nftTraceLine  = regexp.MustCompile(`TRACE: \d+ (\S+) ([^:]+):(\S+?):(rule|return|policy):(\S*)\s*(.*)$`)
+	nftPacketLine = regexp.MustCompile(`PACKET: \d+ (\S+) (.*)$`)
+	packetField   = regexp.MustCompile(`\b(SRC|DST|ID)=(\S+)`)
+)
+
+var errTraceNoRule = errors.New("rule not found in chain")
+
+// startFlowTrace inserts TRACE rules for the flow in the raw table, so that the kernel traces the packets through
+// every chain, and starts collecting the traces of the iptables backend NPM uses.
+func startFlowTrace(ioShim *common.IOShim, srcIP, dstIP string) (flowTracer, error) {
+	backend := util.DetectIptablesVersion(ioShim).Backend
+	tracer := &iptablesTracer{ioShim: ioShim, backend: backend, srcIP: srcIP, dstIP: dstIP}
+
+	if backend == util.IptablesBackendNft {
+		if err := tracer.startMonitor(); err != nil {
+			return nil, err
+		}
+	} else {
+		lines, err := tracer.kernelLog()
+		if err != nil {
+			return nil, err
+		}
+		tracer.kernelLogStart = len(lines)
+	}
+
+	for _, chain := range traceChains {
+		if err := tracer.traceRule(util.IptablesInsertionFlag, chain); err != nil {
+			if stopErr := tracer.stop(); stopErr != nil {
+				fmt.Printf("failed to clean up the trace: %v\n", stopErr)
+			}
+			return nil, err
+		}
+	}
+	return tracer, nil
+}
+
+// iptablesTracer collects the traces from xtables-monitor with the nft backend,
+// and from the kernel log with the legacy backend.
+type iptablesTracer struct {
+	ioShim  *common.IOShim
+	backend string
+	srcIP   string
+	dstIP   string
+
+	// monitor is xtables-monitor --trace, writing to monitorOutput.
+	monitor       utilexec.Cmd
+	monitorOutput *syncBuffer
+	// kernelLogStart is the number of lines of the kernel log before the trace.
+	kernelLogStart int
+}
+
+func (t *iptablesTracer) traceRule(op, chain string) error {
+	args := []string{util.IptablesWaitFlag, util.IptablesTableFlag, traceTable, op, chain}
+	if op == util.IptablesInsertionFlag {
+		// before the rules of kube-proxy, which may not return
+		args = append(args, "1")
+	}
+	args = append(args,
+		"-s", t.srcIP, "-d", t.dstIP,
+		util.IptablesModuleFlag, util.IptablesCommentModuleFlag, util.IptablesCommentFlag, flowTraceComment,
+		util.IptablesJumpFlag, "TRACE",
+	)
+	output, err := t.ioShim.Exec.Command(util.Iptables, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %s %s: %s", util.Iptables, strings.Join(args, " "), string(output))
+	}
+	return nil
+}
+
+func (t *iptablesTracer) startMonitor() error {
+	t.monitorOutput = &syncBuffer{}
+	t.monitor = t.ioShim.Exec.Command(xtablesMonitor, "--trace")
+	t.monitor.SetStdout(t.monitorOutput)
+	if err := t.monitor.Start(); err != nil {
+		t.monitor = nil
+		return errors.Wrapf(err, "failed to start %s", xtablesMonitor)
+	}
+	return nil
+}
+
+func (t *iptablesTracer) stopMonitor() {
+	if t.monitor == nil {
+		return
+	}
+	t.monitor.Stop()
+	// the monitor exits on the signal, so the error of Wait is expected
+	_ = t.monitor.Wait()
+	t.monitor = nil
+}
+
+func (t *iptablesTracer) kernelLog() ([]string, error) {
+	output, err := t.ioShim.Exec.Command(dmesg).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the kernel log: %s", string(output))
+	}
+	return lines(output), nil
+}
+
+func (t *iptablesTracer) collect() ([]*PacketTrace, error) {
+	if t.backend == util.IptablesBackendNft {
+		t.stopMonitor()
+		return parseNftTrace(t.monitorOutput.Bytes(), t.srcIP, t.dstIP), nil
+	}
+
+	logLines, err := t.kernelLog()
+	if err != nil {
+		return nil, err
+	}
+	if t.kernelLogStart > len(logLines) {
+		// the kernel log was cleared or wrapped during the trace
+		t.kernelLogStart = 0
+	}
+	packets := parseLegacyTrace(logLines[t.kernelLogStart:], t.srcIP, t.dstIP)
+	t.resolveLegacyVerdicts(packets)
+	return packets, nil
+}
+
+func (t *iptablesTracer) stop() error {
+	t.stopMonitor()
+	var errs []string
+	for _, chain := range traceChains {
+		if err := t.traceRule(util.IptablesDeletionFlag, chain); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseNftTrace returns the packets from srcIP to dstIP traced by xtables-monitor --trace, by trace ID.
+func parseNftTrace(output []byte, srcIP, dstIP string) []*PacketTrace {
+	var packets []*PacketTrace
+	byID := map[string]*PacketTrace{}
+	inFlow := map[string]bool{}
+	for _, line := range lines(output) {
+		if m := nftPacketLine.FindStringSubmatch(line); m != nil {
+			fields := packetFields(m[2])
+			inFlow[m[1]] = fields["SRC"] == srcIP && fields["DST"] == dstIP
+			continue
+		}
+
+		m := nftTraceLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id := m[1]
+		if flow, seen := inFlow[id]; seen && !flow {
+			continue
+		}
+		event := &TraceEvent{Table: m[2], Chain: m[3], Rule: strings.TrimSpace(m[6])}
+		verdict := m[5]
+		switch m[4] {
+		case "rule":
+			// the verdict follows the handle of the rule, and a jump or goto is followed by the chain
+			parts := strings.SplitN(verdict, ":", 3)
+			switch {
+			case len(parts) == 3:
+				event.Verdict = parts[2]
+			case len(parts) == 2:
+				event.Verdict = parts[1]
+			default:
+				event.Verdict = verdict
+			}
+		case "return":
+			event.Verdict = "RETURN"
+		case "policy":
+			event.Verdict = verdict
+			event.Detail = "policy"
+		}
+		event.Verdict = strings.ToUpper(event.Verdict)
+
+		packet, ok := byID[id]
+		if !ok {
+			packet = &PacketTrace{ID: id}
+			byID[id] = packet
+			packets = append(packets, packet)
+		}
+		packet.Events = append(packet.Events, event)
+	}
+	return packets
+}
+
+// legacyRuleRef is where a legacy trace event matched, to resolve its verdict from the rules of the chain.
+type legacyRuleRef struct {
+	kind string
+	num  int
+}
+
+// parseLegacyTrace returns the packets from srcIP to dstIP traced in the kernel log, by IP ID. The kernel only logs
+// the number of the rule matched, so the rules and verdicts are set by resolveLegacyVerdicts.
+func parseLegacyTrace(logLines []string, srcIP, dstIP string) []*PacketTrace {
+	var packets []*PacketTrace
+	byID := map[string]*PacketTrace{}
+	for _, line := range logLines {
+		m := legacyTraceLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		fields := packetFields(m[5])
+		if fields["SRC"] != srcIP || fields["DST"] != dstIP {
+			continue
+		}
+		id := fields["ID"]
+		packet, ok := byID[id]
+		if !ok {
+			packet = &PacketTrace{ID: id}
+			byID[id] = packet
+			packets = append(packets, packet)
+		}
+		packet.Events = append(packet.Events, &TraceEvent{
+			Table:  m[1],
+			Chain:  m[2],
+			Detail: m[3] + ":" + m[4],
+		})
+	}
+	return packets
+}
+
+// resolveLegacyVerdicts sets the rule and verdict of the legacy trace events from the current rules of the chains.
+func (t *iptablesTracer) resolveLegacyVerdicts(packets []*PacketTrace) {
+	rulesByChain := map[string][]string{}
+	for _, packet := range packets {
+		for _, event := range packet.Events {
+			key := event.Table + ":" + event.Chain
+			rules, ok := rulesByChain[key]
+			if !ok {
+				output, err := t.ioShim.Exec.Command(util.Iptables, util.IptablesWaitFlag, util.IptablesTableFlag, event.Table, "-S", event.Chain).CombinedOutput()
+				if err != nil {
+					fmt.Printf("failed to list the rules of chain %s: %v\n", key, err)
+				}
+				rules = lines(output)
+				rulesByChain[key] = rules
+			}
+			resolveLegacyVerdict(event, rules)
+		}
+	}
+}
+
+// resolveLegacyVerdict sets the rule and verdict of the event from the rules of its chain, as listed by iptables -S.
+func resolveLegacyVerdict(event *TraceEvent, rules []string) {
+	ref, err := parseLegacyRuleRef(event.Detail)
+	if err != nil {
+		event.Verdict = "UNKNOWN"
+		return
+	}
+
+	switch ref.kind {
+	case "return":
+		event.Verdict = "RETURN"
+		return
+	case "policy":
+		event.Detail = "policy"
+		event.Verdict = "UNKNOWN"
+		for _, rule := range rules {
+			fields := strings.Fields(rule)
+			if len(fields) == 3 && fields[0] == "-P" {
+				event.Verdict = fields[2]
+			}
+		}
+		return
+	}
+
+	n := 0
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule, "-A ") {
+			continue
+		}
+		n++
+		if n != ref.num {
+			continue
+		}
+		event.Rule = rule
+		event.Detail = ""
+		event.Verdict = ruleTarget(rule)
+		return
+	}
+	event.Verdict = "UNKNOWN"
+	event.Detail = errors.Wrapf(errTraceNoRule, "rule %d, the rules changed during the trace", ref.num).Error()
+}
+
+func parseLegacyRuleRef(detail string) (legacyRuleRef, error) {
+	parts := strings.SplitN(detail, ":", 2)
+	if len(parts) != 2 {
+		return legacyRuleRef{}, errTraceNoRule
+	}
+	num, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return legacyRuleRef{}, errors.Wrap(err, "failed to parse the rule number")
+	}
+	return legacyRuleRef{kind: parts[0], num: num}, nil
+}
+
+// ruleTarget returns the target of the rule jumped or gone to, or CONTINUE for a rule without target.
+func ruleTarget(rule string) string {
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == util.IptablesJumpFlag || fields[i] == "-g" {
+			return fields[i+1]
+		}
+	}
+	return "CONTINUE"
+}
+
+func packetFields(s string) map[string]string {
+	fields := map[string]string{}
+	for _, m := range packetField.FindAllStringSubmatch(s, -1) {
+		fields[m[1]] = m[2]
+	}
+	return fields
+}
+
+// syncBuffer is a buffer written by a command while it runs.
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	n, err := b.buf.Write(p)
+	return n, errors.Wrap(err, "failed to buffer the output")
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
@@ -0,0 +1,117 @@
+package debug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	traceSrcIP = "10.224.0.87"
+	traceDstIP = "10.224.0.20"
+)
+
+func TestParseNftTrace(t *testing.T) {
+	output := `PACKET: 2 fc04d4d2 IN=eth0 SRC=10.224.0.87 DST=10.224.0.20 LEN=60 TTL=64 ID=51234 PROTO=TCP SPT=41234 DPT=80 SYN
+ TRACE: 2 fc04d4d2 raw:PREROUTING:rule:0x3:CONTINUE  -4 -t raw -A PREROUTING -s 10.224.0.87/32 -d 10.224.0.20/32 -m comment --comment azure-npm-trace -j TRACE
+ TRACE: 2 fc04d4d2 raw:PREROUTING:return:
+ TRACE: 2 fc04d4d2 raw:PREROUTING:policy:ACCEPT
+ TRACE: 2 fc04d4d2 filter:FORWARD:rule:0x1e:JUMP:AZURE-NPM  -4 -t filter -A FORWARD -j AZURE-NPM
+ TRACE: 2 fc04d4d2 filter:AZURE-NPM:rule:0x2d:JUMP:AZURE-NPM-INGRESS  -4 -t filter -A AZURE-NPM -j AZURE-NPM-INGRESS
+ TRACE: 2 fc04d4d2 filter:AZURE-NPM-INGRESS:rule:0x31:DROP  -4 -t filter -A AZURE-NPM-INGRESS -m set --match-set azure-npm-123 dst -j DROP
+PACKET: 2 aabbccdd IN=eth0 SRC=10.224.0.20 DST=10.224.0.87 LEN=60 TTL=64 ID=0 PROTO=TCP SPT=80 DPT=41234 SYN ACK
+ TRACE: 2 aabbccdd filter:FORWARD:policy:ACCEPT
+`
+	packets := parseNftTrace([]byte(output), traceSrcIP, traceDstIP)
+	require.Len(t, packets, 1)
+	packet := packets[0]
+	require.Equal(t, "fc04d4d2", packet.ID)
+	require.Len(t, packet.Events, 6)
+	require.Equal(t, "RETURN", packet.Events[1].Verdict)
+	require.Equal(t, "AZURE-NPM", packet.Events[3].Verdict)
+
+	verdict := packet.Verdict()
+	require.Equal(t, VerdictDrop, verdict.Verdict)
+	require.Equal(t, "AZURE-NPM-INGRESS", verdict.Chain)
+	require.Equal(t, "-4 -t filter -A AZURE-NPM-INGRESS -m set --match-set azure-npm-123 dst -j DROP", verdict.Rule)
+	require.Equal(t, []string{"AZURE-NPM", "AZURE-NPM-INGRESS"}, packet.NPMChains())
+}
+
+func TestParseLegacyTrace(t *testing.T) {
+	logLines := []string{
+		"[1234.5] TRACE: raw:PREROUTING:policy:2 IN=eth0 OUT= SRC=10.224.0.87 DST=10.224.0.20 LEN=60 ID=51234 DF PROTO=TCP",
+		"[1234.5] TRACE: filter:FORWARD:rule:1 IN=eth0 OUT=azure0 SRC=10.224.0.87 DST=10.224.0.20 LEN=60 ID=51234 DF PROTO=TCP",
+		"[1234.5] TRACE: filter:AZURE-NPM:rule:2 IN=eth0 OUT=azure0 SRC=10.224.0.87 DST=10.224.0.20 LEN=60 ID=51234 DF PROTO=TCP",
+		"[1234.5] TRACE: filter:AZURE-NPM-ACCEPT:rule:1 IN=eth0 OUT=azure0 SRC=10.224.0.87 DST=10.224.0.20 LEN=60 ID=51234 DF PROTO=TCP",
+		"[1234.6] TRACE: filter:FORWARD:rule:1 IN=eth0 OUT=azure0 SRC=10.224.0.20 DST=10.224.0.87 LEN=60 ID=0 DF PROTO=TCP",
+		"[1234.7] some other kernel log",
+	}
+	packets := parseLegacyTrace(logLines, traceSrcIP, traceDstIP)
+	require.Len(t, packets, 1)
+	require.Equal(t, "51234", packets[0].ID)
+	require.Len(t, packets[0].Events, 4)
+
+	rules := map[string]string{
+		"raw:PREROUTING":          "-P PREROUTING ACCEPT\n-A PREROUTING -s 10.224.0.87/32 -d 10.224.0.20/32 -j TRACE\n",
+		"filter:FORWARD":          "-P FORWARD ACCEPT\n-A FORWARD -j AZURE-NPM\n",
+		"filter:AZURE-NPM":        "-N AZURE-NPM\n-A AZURE-NPM -j AZURE-NPM-INGRESS\n-A AZURE-NPM -m mark --mark 0x2000 -j AZURE-NPM-ACCEPT\n",
+		"filter:AZURE-NPM-ACCEPT": "-N AZURE-NPM-ACCEPT\n-A AZURE-NPM-ACCEPT -j ACCEPT\n",
+	}
+	for _, event := range packets[0].Events {
+		resolveLegacyVerdict(event, lines([]byte(rules[event.Table+":"+event.Chain])))
+	}
+
+	require.Equal(t, "ACCEPT", packets[0].Events[0].Verdict)
+	require.Equal(t, "policy", packets[0].Events[0].Detail)
+	require.Equal(t, "AZURE-NPM-ACCEPT", packets[0].Events[2].Verdict)
+	verdict := packets[0].Verdict()
+	require.Equal(t, "AZURE-NPM-ACCEPT", verdict.Chain)
+	require.Equal(t, "-A AZURE-NPM-ACCEPT -j ACCEPT", verdict.Rule)
+	require.Equal(t, []string{"AZURE-NPM", "AZURE-NPM-ACCEPT"}, packets[0].NPMChains())
+}
+
+func TestResolveLegacyVerdictRulesChanged(t *testing.T) {
+	event := &TraceEvent{Table: "filter", Chain: "AZURE-NPM", Detail: "rule:3"}
+	resolveLegacyVerdict(event, []string{"-N AZURE-NPM", "-A AZURE-NPM -j AZURE-NPM-INGRESS"})
+	require.Equal(t, "UNKNOWN", event.Verdict)
+}
+
+func TestTraceFlowLegacy(t *testing.T) {
+	insertPrerouting := []string{
+		util.IptablesLegacy, "-w", "-t", "raw", "-I", "PREROUTING", "1", "-s", traceSrcIP, "-d", traceDstIP,
+		"-m", "comment", "--comment", "azure-npm-trace", "-j", "TRACE",
+	}
+	insertOutput := append([]string{}, insertPrerouting...)
+	insertOutput[5] = "OUTPUT"
+	deletePrerouting := append(append([]string{}, insertPrerouting[:4]...), "-D", "PREROUTING")
+	deletePrerouting = append(deletePrerouting, insertPrerouting[7:]...)
+	deleteOutput := append([]string{}, deletePrerouting...)
+	deleteOutput[5] = "OUTPUT"
+
+	calls := []testutils.TestCmd{
+		{Cmd: []string{util.IptablesSaveNft, "-t", "mangle"}, Stdout: ""},
+		{Cmd: []string{util.IptablesSaveLegacy, "-t", "mangle"}, Stdout: "-N KUBE-IPTABLES-HINT"},
+		{Cmd: []string{"dmesg"}, Stdout: "[1.0] booted\n"},
+		{Cmd: insertPrerouting},
+		{Cmd: insertOutput},
+		{
+			Cmd:    []string{"dmesg"},
+			Stdout: "[1.0] booted\n[2.0] TRACE: filter:AZURE-NPM:rule:1 IN=eth0 OUT=azure0 SRC=10.224.0.87 DST=10.224.0.20 ID=7 PROTO=TCP\n",
+		},
+		{Cmd: []string{util.IptablesLegacy, "-w", "-t", "filter", "-S", "AZURE-NPM"}, Stdout: "-N AZURE-NPM\n-A AZURE-NPM -j DROP\n"},
+		{Cmd: deletePrerouting},
+		{Cmd: deleteOutput},
+	}
+	ioShim := common.NewMockIOShim(calls)
+	defer ioShim.VerifyCalls(t, calls)
+
+	packets, err := TraceFlow(context.Background(), ioShim, traceSrcIP, traceDstIP, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, packets, 1)
+	require.Equal(t, VerdictDrop, packets[0].Verdict().Verdict)
+}
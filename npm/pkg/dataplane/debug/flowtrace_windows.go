@@ -0,0 +1,165 @@
+package debug
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/pkg/errors"
+)
+
+const pktmon = "pktmon"
+
+var (
+	// pktmonDrop matches the drops in the text format of a pktmon capture, e.g.
+	// "... PktGroupId 1125899906842625, PktNumber 1, Appearance 1, Direction Tx , Type IP , Component 27, Edge 1,
+	// Filter 1 , DropReason Filtered VFP , DropLocation 0xE0004A53 , OriginalSize 60, LoggedSize 60".
+	pktmonDrop       = regexp.MustCompile(`PktGroupId (\d+).*Component (\d+).*DropReason ([^,]+?)\s*,\s*DropLocation ([^,\s]+)`)
+	pktmonComponents = regexp.MustCompile(`^\s*(\d+)\s+(.+?)\s*$`)
+)
+
+// startFlowTrace adds a pktmon filter for the flow and starts capturing its drops.
+// The ACLs of the HNS endpoints are enforced by VFP, which reports the drops of the ACLs as "Filtered VFP"
+// without the ACL, so the verdict is the component and reason of the drop, not the NPM ACL.
+func startFlowTrace(ioShim *common.IOShim, srcIP, dstIP string) (flowTracer, error) {
+	dir, err := os.MkdirTemp("", flowTraceComment)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the capture directory")
+	}
+	tracer := &pktmonTracer{ioShim: ioShim, dir: dir}
+
+	if err := tracer.run("filter", "add", flowTraceComment, "-i", srcIP, dstIP); err != nil {
+		tracer.cleanup()
+		return nil, err
+	}
+	tracer.filtered = true
+	if err := tracer.run("start", "--capture", "--type", "drop", "--file-name", tracer.etlFile()); err != nil {
+		if stopErr := tracer.stop(); stopErr != nil {
+			fmt.Printf("failed to clean up the trace: %v\n", stopErr)
+		}
+		return nil, err
+	}
+	tracer.capturing = true
+	return tracer, nil
+}
+
+type pktmonTracer struct {
+	ioShim *common.IOShim
+	// dir holds the capture and its text format.
+	dir       string
+	filtered  bool
+	capturing bool
+}
+
+func (t *pktmonTracer) etlFile() string {
+	return filepath.Join(t.dir, "trace.etl")
+}
+
+func (t *pktmonTracer) run(args ...string) error {
+	_, err := t.output(args...)
+	return err
+}
+
+func (t *pktmonTracer) output(args ...string) ([]byte, error) {
+	output, err := t.ioShim.Exec.Command(pktmon, args...).CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %s %s: %s", pktmon, strings.Join(args, " "), string(output))
+	}
+	return output, nil
+}
+
+func (t *pktmonTracer) collect() ([]*PacketTrace, error) {
+	if t.capturing {
+		if err := t.run("stop"); err != nil {
+			return nil, err
+		}
+		t.capturing = false
+	}
+
+	txtFile := filepath.Join(t.dir, "trace.txt")
+	if err := t.run("etl2txt", t.etlFile(), "--out", txtFile); err != nil {
+		return nil, err
+	}
+	text, err := os.ReadFile(txtFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the capture")
+	}
+
+	components := map[string]string{}
+	if output, err := t.output("list", "--all"); err == nil {
+		components = parsePktmonComponents(lines(output))
+	}
+	return parsePktmonDrops(lines(text), components), nil
+}
+
+func (t *pktmonTracer) stop() error {
+	var errs []string
+	if t.capturing {
+		if err := t.run("stop"); err != nil {
+			errs = append(errs, err.Error())
+		}
+		t.capturing = false
+	}
+	if t.filtered {
+		if err := t.run("filter", "remove"); err != nil {
+			errs = append(errs, err.Error())
+		}
+		t.filtered = false
+	}
+	t.cleanup()
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (t *pktmonTracer) cleanup() {
+	if err := os.RemoveAll(t.dir); err != nil {
+		fmt.Printf("failed to remove the capture directory %s: %v\n", t.dir, err)
+	}
+}
+
+// parsePktmonDrops returns the dropped packets of the capture, by packet group. Each drop is an event of the
+// component which dropped the packet.
+func parsePktmonDrops(textLines []string, components map[string]string) []*PacketTrace {
+	var packets []*PacketTrace
+	byID := map[string]*PacketTrace{}
+	for _, line := range textLines {
+		m := pktmonDrop.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id := m[1]
+		packet, ok := byID[id]
+		if !ok {
+			packet = &PacketTrace{ID: id}
+			byID[id] = packet
+			packets = append(packets, packet)
+		}
+
+		component := "component " + m[2]
+		if name, ok := components[m[2]]; ok {
+			component = name
+		}
+		packet.Events = append(packet.Events, &TraceEvent{
+			Chain:   component,
+			Verdict: VerdictDrop,
+			Detail:  fmt.Sprintf("%s at %s", m[3], m[4]),
+		})
+	}
+	return packets
+}
+
+// parsePktmonComponents returns the names of the components listed by pktmon list, by ID.
+func parsePktmonComponents(listLines []string) map[string]string {
+	components := map[string]string{}
+	for _, line := range listLines {
+		if m := pktmonComponents.FindStringSubmatch(line); m != nil {
+			components[m[1]] = m[2]
+		}
+	}
+	return components
+}
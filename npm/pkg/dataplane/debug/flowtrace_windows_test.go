@@ -0,0 +1,23 @@
+package debug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePktmonDrops(t *testing.T) {
+	text := []string{
+		"[00]0000.0000::2022-10-17 01:43:12.000 [Microsoft-Windows-PktMon] PktGroupId 1125899906842625, PktNumber 1, Appearance 1, Direction Tx , Type IP , Component 27, Edge 1, Filter 1 , OriginalSize 60, LoggedSize 60",
+		"[00]0000.0000::2022-10-17 01:43:12.000 [Microsoft-Windows-PktMon] Drop: PktGroupId 1125899906842625, PktNumber 1, Appearance 2, Direction Tx , Type IP , Component 27, Edge 1, Filter 1 , DropReason Filtered VFP , DropLocation 0xE0004A53 , OriginalSize 60, LoggedSize 60",
+	}
+	components := parsePktmonComponents([]string{"  Id  Name", "  27  Virtual Filtering Platform VFP Extension"})
+
+	packets := parsePktmonDrops(text, components)
+	require.Len(t, packets, 1)
+	require.Equal(t, "1125899906842625", packets[0].ID)
+	verdict := packets[0].Verdict()
+	require.Equal(t, VerdictDrop, verdict.Verdict)
+	require.Equal(t, "Virtual Filtering Platform VFP Extension", verdict.Chain)
+	require.Equal(t, "Filtered VFP at 0xE0004A53", verdict.Detail)
+}
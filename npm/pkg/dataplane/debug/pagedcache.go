@@ -0,0 +1,184 @@
+package debug
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/Azure/azure-container-networking/npm/http/api"
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/models"
+	"github.com/pkg/errors"
+	"k8s.io/utils/lru"
+)
+
+// pagedCacheSize bounds the number of pods and of namespaces kept by a pagedCache.
+const pagedCacheSize = 1000
+
+// pageFetcher gets a page of a map of the NPM cache of a node.
+type pageFetcher func(key models.CacheKey, start string, limit int) (*npmcommon.CachePage, error)
+
+// pagedCache is a GenericCache hydrated on demand from the pages of the NPM cache served by a node.
+// Pods and namespaces are fetched as they are looked up and only the most recently used are kept,
+// so that the cache of nodes with many pods is never held at once. The set and list maps are fetched
+// when the cache is created, since the converter needs all of them.
+type pagedCache struct {
+	fetch      pageFetcher
+	pods       *lru.Cache
+	namespaces *lru.Cache
+	setMap     map[string]string
+	listMap    map[string]string
+
+	sync.Mutex
+	// podKeysByIP indexes the pods seen while scanning the pod map for IP lookups.
+	podKeysByIP map[string]string
+	// podScanNext is where the next IP lookup resumes scanning the pod map.
+	podScanNext string
+	podScanDone bool
+}
+
+func newPagedCache(fetch pageFetcher, enableV2NPM bool) (*pagedCache, error) {
+	c := &pagedCache{
+		fetch:       fetch,
+		pods:        lru.New(pagedCacheSize),
+		namespaces:  lru.New(pagedCacheSize),
+		listMap:     map[string]string{},
+		podKeysByIP: map[string]string{},
+	}
+
+	var err error
+	if c.setMap, err = c.fetchStringMap(models.SetMap); err != nil {
+		return nil, err
+	}
+	if !enableV2NPM {
+		if c.listMap, err = c.fetchStringMap(models.ListMap); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// fetchStringMap fetches all the pages of a map of strings.
+func (c *pagedCache) fetchStringMap(key models.CacheKey) (map[string]string, error) {
+	m := map[string]string{}
+	for start := ""; ; {
+		page, err := c.fetch(key, start, api.MaxCachePageLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch %s", key)
+		}
+		for k, raw := range page.Items {
+			var v string
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, errors.Wrapf(err, "failed to unmarshal %s of %s", k, key)
+			}
+			m[k] = v
+		}
+		if page.Continue == "" {
+			return m, nil
+		}
+		start = page.Continue
+	}
+}
+
+// fetchEntry fetches the entry of a map by its key, returning false if the map doesn't have it.
+func (c *pagedCache) fetchEntry(key models.CacheKey, k string, v interface{}) (bool, error) {
+	page, err := c.fetch(key, k, 1)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to fetch %s of %s", k, key)
+	}
+	raw, ok := page.Items[k]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, errors.Wrapf(err, "failed to unmarshal %s of %s", k, key)
+	}
+	return true, nil
+}
+
+func (c *pagedCache) GetPod(input *npmcommon.Input) (*npmcommon.NpmPod, error) {
+	switch input.Type {
+	case npmcommon.NSPODNAME:
+		return c.getPodByKey(input.Content)
+	case npmcommon.IPADDRS:
+		return c.getPodByIP(input.Content)
+	case npmcommon.EXTERNAL:
+		return &npmcommon.NpmPod{}, nil
+	default:
+		return nil, npmcommon.ErrInvalidInput
+	}
+}
+
+func (c *pagedCache) getPodByKey(key string) (*npmcommon.NpmPod, error) {
+	if pod, ok := c.pods.Get(key); ok {
+		return pod.(*npmcommon.NpmPod), nil
+	}
+	pod := &npmcommon.NpmPod{}
+	ok, err := c.fetchEntry(models.PodMap, key, pod)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, npmcommon.ErrInvalidInput
+	}
+	c.pods.Add(key, pod)
+	return pod, nil
+}
+
+// getPodByIP looks up the pods already seen, then scans the rest of the pod map until the pod is found.
+func (c *pagedCache) getPodByIP(ip string) (*npmcommon.NpmPod, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if key, ok := c.podKeysByIP[ip]; ok {
+		if pod, err := c.getPodByKey(key); err == nil && pod.PodIP == ip {
+			return pod, nil
+		}
+		delete(c.podKeysByIP, ip)
+	}
+
+	for !c.podScanDone {
+		page, err := c.fetch(models.PodMap, c.podScanNext, api.DefaultCachePageLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch %s", models.PodMap)
+		}
+		var found *npmcommon.NpmPod
+		for key, raw := range page.Items {
+			pod := &npmcommon.NpmPod{}
+			if err := json.Unmarshal(raw, pod); err != nil {
+				return nil, errors.Wrapf(err, "failed to unmarshal %s of %s", key, models.PodMap)
+			}
+			c.pods.Add(key, pod)
+			c.podKeysByIP[pod.PodIP] = key
+			if pod.PodIP == ip {
+				found = pod
+			}
+		}
+		c.podScanNext = page.Continue
+		c.podScanDone = page.Continue == ""
+		if found != nil {
+			return found, nil
+		}
+	}
+	return nil, npmcommon.ErrInvalidIPAddress
+}
+
+func (c *pagedCache) GetNamespaceLabel(namespace, labelkey string) string {
+	if ns, ok := c.namespaces.Get(namespace); ok {
+		return ns.(*npmcommon.Namespace).LabelsMap[labelkey]
+	}
+	ns := &npmcommon.Namespace{}
+	if ok, err := c.fetchEntry(models.NsMap, namespace, ns); err != nil || !ok {
+		return ""
+	}
+	c.namespaces.Add(namespace, ns)
+	return ns.LabelsMap[labelkey]
+}
+
+func (c *pagedCache) GetSetMap() map[string]string {
+	return c.setMap
+}
+
+func (c *pagedCache) GetListMap() map[string]string {
+	cache := &npmcommon.Cache{ListMap: c.listMap}
+	return cache.GetListMap()
+}
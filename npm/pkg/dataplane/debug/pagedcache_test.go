@@ -0,0 +1,91 @@
+package debug
+
+import (
+	"testing"
+
+	npmcommon "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/models"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNodeCache serves the pages of a cache, two entries per page at most, and counts the fetches.
+type fakeNodeCache struct {
+	cache   *npmcommon.Cache
+	fetches int
+}
+
+func (f *fakeNodeCache) fetch(key models.CacheKey, start string, limit int) (*npmcommon.CachePage, error) {
+	f.fetches++
+	if limit > 2 {
+		limit = 2
+	}
+	switch key {
+	case models.NsMap:
+		return npmcommon.NewCachePage(f.cache.NsMap, start, limit)
+	case models.PodMap:
+		return npmcommon.NewCachePage(f.cache.PodMap, start, limit)
+	case models.SetMap:
+		return npmcommon.NewCachePage(f.cache.SetMap, start, limit)
+	case models.ListMap:
+		return npmcommon.NewCachePage(f.cache.ListMap, start, limit)
+	default:
+		return nil, models.ErrUnknownCacheKey
+	}
+}
+
+func TestPagedCache(t *testing.T) {
+	node := &fakeNodeCache{cache: &npmcommon.Cache{
+		NsMap: map[string]*npmcommon.Namespace{
+			"x": {Name: "x", LabelsMap: map[string]string{"ns": "x"}},
+		},
+		PodMap: map[string]*npmcommon.NpmPod{
+			"x/a": {Name: "a", Namespace: "x", PodIP: "10.0.0.1"},
+			"x/b": {Name: "b", Namespace: "x", PodIP: "10.0.0.2"},
+			"x/c": {Name: "c", Namespace: "x", PodIP: "10.0.0.3"},
+			"y/a": {Name: "a", Namespace: "y", PodIP: "10.0.0.4"},
+			"y/b": {Name: "b", Namespace: "y", PodIP: "10.0.0.5"},
+		},
+		SetMap: map[string]string{
+			util.GetHashedName("ns-x"):     "ns-x",
+			util.GetHashedName("podlabel"): "podlabel",
+			util.GetHashedName("app:web"):  "app:web",
+		},
+		ListMap: map[string]string{"all-namespaces": "all-namespaces"},
+	}}
+
+	c, err := newPagedCache(node.fetch, false)
+	require.NoError(t, err)
+	require.Equal(t, node.cache.SetMap, c.GetSetMap())
+	require.Equal(t, node.cache.GetListMap(), c.GetListMap())
+
+	node.fetches = 0
+	pod, err := c.GetPod(&npmcommon.Input{Content: "x/b", Type: npmcommon.NSPODNAME})
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.2", pod.PodIP)
+	_, err = c.GetPod(&npmcommon.Input{Content: "x/b", Type: npmcommon.NSPODNAME})
+	require.NoError(t, err)
+	require.Equal(t, 1, node.fetches, "the pod should be fetched once")
+
+	_, err = c.GetPod(&npmcommon.Input{Content: "x/d", Type: npmcommon.NSPODNAME})
+	require.ErrorIs(t, err, npmcommon.ErrInvalidInput)
+
+	node.fetches = 0
+	pod, err = c.GetPod(&npmcommon.Input{Content: "10.0.0.3", Type: npmcommon.IPADDRS})
+	require.NoError(t, err)
+	require.Equal(t, "c", pod.Name)
+	require.Equal(t, 2, node.fetches, "the scan should stop at the page of the pod")
+
+	// pods seen by earlier scans aren't fetched again
+	node.fetches = 0
+	pod, err = c.GetPod(&npmcommon.Input{Content: "10.0.0.1", Type: npmcommon.IPADDRS})
+	require.NoError(t, err)
+	require.Equal(t, "a", pod.Name)
+	require.Equal(t, 0, node.fetches)
+
+	_, err = c.GetPod(&npmcommon.Input{Content: "10.0.0.9", Type: npmcommon.IPADDRS})
+	require.ErrorIs(t, err, npmcommon.ErrInvalidIPAddress)
+
+	require.Equal(t, "x", c.GetNamespaceLabel("x", "ns"))
+	require.Empty(t, c.GetNamespaceLabel("y", "ns"))
+}
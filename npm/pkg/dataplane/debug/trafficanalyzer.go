@@ -10,6 +10,7 @@ import (
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	common "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/pb"
 	"github.com/Azure/azure-container-networking/npm/util"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -408,11 +409,17 @@ func matchNAMEDPORTS(pod *common.NpmPod, setInfo *pb.RuleResponse_SetInfo, rule
 			if !setInfo.Included {
 				return false
 			}
-			if rule.Protocol != "" && rule.Protocol != strings.ToLower(string(namedPort.Protocol)) {
-				return false
+			// a pod can name ports of different protocols the same, e.g. a DNS server's
+			protocol := string(namedPort.Protocol)
+			if protocol == "" {
+				protocol = ipsets.DefaultNamedPortProtocol
+			}
+			protocol = strings.ToLower(protocol)
+			if rule.Protocol != "" && strings.ToLower(rule.Protocol) != protocol {
+				continue
 			}
 			if rule.Protocol == "" {
-				rule.Protocol = strings.ToLower(string(namedPort.Protocol))
+				rule.Protocol = protocol
 			}
 			if origin == "src" {
 				rule.SPort = namedPort.ContainerPort
@@ -7,8 +7,10 @@ import (
 	"testing"
 
 	common "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/pb"
 	"github.com/Azure/azure-container-networking/npm/util"
 	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
 )
 
 func AsSha256(o interface{}) string {
@@ -149,3 +151,32 @@ func TestGetNetworkTuple(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchNamedPortsProtocol(t *testing.T) {
+	pod := &common.NpmPod{
+		ContainerPorts: []v1.ContainerPort{
+			{Name: "dns", ContainerPort: 53, Protocol: v1.ProtocolTCP},
+			{Name: "dns", ContainerPort: 5353, Protocol: v1.ProtocolUDP},
+			{Name: "web", ContainerPort: 8080},
+		},
+	}
+	setInfo := func(port string) *pb.RuleResponse_SetInfo {
+		return &pb.RuleResponse_SetInfo{Name: util.NamedPortIPSetPrefix + port, Included: true}
+	}
+
+	rule := &pb.RuleResponse{Protocol: "udp"}
+	require.True(t, matchNAMEDPORTS(pod, setInfo("dns"), rule, "dst"))
+	require.Equal(t, int32(5353), rule.DPort)
+
+	rule = &pb.RuleResponse{Protocol: "tcp"}
+	require.True(t, matchNAMEDPORTS(pod, setInfo("dns"), rule, "dst"))
+	require.Equal(t, int32(53), rule.DPort)
+
+	// a port without a protocol is TCP
+	rule = &pb.RuleResponse{}
+	require.True(t, matchNAMEDPORTS(pod, setInfo("web"), rule, "dst"))
+	require.Equal(t, "tcp", rule.Protocol)
+
+	rule = &pb.RuleResponse{Protocol: "sctp"}
+	require.False(t, matchNAMEDPORTS(pod, setInfo("dns"), rule, "dst"))
+}
@@ -48,6 +48,11 @@ func (dp *DPShim) BootupDataplane() error {
 	return nil
 }
 
+// FinishBootupPhase is a no-op in DPShim since DPShim does not buffer events during bootup
+func (dp *DPShim) FinishBootupPhase() error {
+	return nil
+}
+
 // HydrateClients is used in DPShim to hydrate a restarted Daemon Client
 func (dp *DPShim) HydrateClients() (*protos.Events, error) {
 	dp.lock()
@@ -43,6 +43,8 @@ type dirtyCacheInterface interface {
 	deleteMember(set *IPSet, member string)
 	// delete will mark the set to be deleted in the cache
 	destroy(set *IPSet)
+	// destroyed will remove the set from the delete cache once it's deleted from the kernel
+	destroyed(setName string)
 	// setsToAddOrUpdate returns the set names to be added or updated
 	setsToAddOrUpdate() map[string]struct{}
 	// setsToDelete returns the set names to be deleted
@@ -184,6 +186,10 @@ func (dc *dirtyCache) destroy(set *IPSet) {
 	delete(dc.toUpdateCache, set.Name)
 }
 
+func (dc *dirtyCache) destroyed(setName string) {
+	delete(dc.toDestroyCache, setName)
+}
+
 func (dc *dirtyCache) setsToAddOrUpdate() map[string]struct{} {
 	sets := make(map[string]struct{}, len(dc.toCreateCache)+len(dc.toUpdateCache))
 	for set := range dc.toCreateCache {
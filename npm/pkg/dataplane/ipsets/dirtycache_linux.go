@@ -54,6 +54,10 @@ func (diff *memberDiff) removeMemberFromDiffToAdd(member string) {
 	delete(diff.membersToAdd, member)
 }
 
+func (diff *memberDiff) removeMemberFromDiffToDelete(member string) {
+	delete(diff.membersToDelete, member)
+}
+
 func (diff *memberDiff) resetMembersToAdd() {
 	diff.membersToAdd = make(map[string]struct{})
 }
@@ -3,6 +3,8 @@ package ipsets
 import (
 	"errors"
 	"fmt"
+	"net/netip"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/npm/metrics"
@@ -34,6 +36,19 @@ func NewIPSetMetadata(name string, setType SetType) *IPSetMetadata {
 	return set
 }
 
+// DefaultNamedPortProtocol is the protocol of a container port which doesn't specify one, as in the pod spec.
+const DefaultNamedPortProtocol = "TCP"
+
+// NamedPortMember returns the member of a NamedPorts set for a container port of the pod with the IP. The member
+// always encodes the protocol of the port, so that a rule for the named port with another protocol doesn't match it.
+func NamedPortMember(podIP, protocol string, port int32) string {
+	if protocol == "" {
+		protocol = DefaultNamedPortProtocol
+	}
+	// without a ":" after the protocol, ipset complains
+	return fmt.Sprintf("%s,%s:%d", podIP, protocol, port)
+}
+
 func (setMetadata *IPSetMetadata) GetHashedName() string {
 	prefixedName := setMetadata.GetPrefixName()
 	if prefixedName == Unknown {
@@ -184,6 +199,8 @@ var (
 	}
 	// ErrIPSetInvalidKind is returned when IPSet kind is invalid
 	ErrIPSetInvalidKind = errors.New("invalid IPSet Kind")
+	// ErrStaleMember is returned when a member is added for a pod of an older generation than the last pod it was added for.
+	ErrStaleMember = errors.New("member was added for a pod of a newer generation")
 )
 
 func (x SetType) String() string {
@@ -210,6 +227,8 @@ type IPSet struct {
 	// IpPodKey is used for setMaps to store Ips and ports as keys
 	// and podKey as value
 	IPPodKey map[string]string
+	// ipGenerations are the generations of the pods which the members of IPPodKey were added for, if not zero.
+	ipGenerations map[string]int64
 	// This is used for listMaps to store child IP Sets
 	MemberIPSets map[string]*IPSet
 	// Using a map to emulate set and value as struct{} for
@@ -253,6 +272,18 @@ func NewIPSet(setMetadata *IPSetMetadata) *IPSet {
 	return set
 }
 
+// setGeneration records the generation of the pod which the member was added for. A zero generation forgets it.
+func (set *IPSet) setGeneration(member string, generation int64) {
+	if generation == 0 {
+		delete(set.ipGenerations, member)
+		return
+	}
+	if set.ipGenerations == nil {
+		set.ipGenerations = make(map[string]int64)
+	}
+	set.ipGenerations[member] = generation
+}
+
 // GetSetMetadata returns set metadata with unprefixed original name and SetType
 func (set *IPSet) GetSetMetadata() *IPSetMetadata {
 	return NewIPSetMetadata(set.unprefixedName, set.Type)
@@ -406,3 +437,59 @@ func GetMembersOfTranslatedSets(members []string) []*IPSetMetadata {
 	}
 	return memberList
 }
+
+// isIPAffiliated determines whether an PodIP belongs to the set or its member sets in the case of a list set.
+// This method and GetSetContents are good examples of how the ipset struct may have been better designed
+// as an interface with hash and list implementations. Not worth it to redesign though.
+func (set *IPSet) isIPAffiliated(ip, podKey string) bool {
+	if set.Kind == HashSet {
+		if key, ok := set.IPPodKey[ip]; ok && key == podKey {
+			return true
+		}
+	}
+	for _, memberSet := range set.MemberIPSets {
+		if key, ok := memberSet.IPPodKey[ip]; ok && key == podKey {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesIP determines whether the set or its member sets match the IP, and the protocol and port for NamedPorts sets.
+// A CIDRBlocks set matches the IP with its most specific CIDR, which is a nomatch member for the excepts of ipBlocks.
+func (set *IPSet) matchesIP(ip, protocol string, port int32) bool {
+	if set.Kind == ListSet {
+		for _, memberSet := range set.MemberIPSets {
+			if memberSet.matchesIP(ip, protocol, port) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch set.Type {
+	case NamedPorts:
+		_, ok := set.IPPodKey[NamedPortMember(ip, strings.ToUpper(protocol), port)]
+		return ok
+	case CIDRBlocks:
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return false
+		}
+		matched := false
+		bits := -1
+		for member := range set.IPPodKey {
+			cidr, nomatch := strings.CutSuffix(member, " "+util.IpsetNomatch)
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil || prefix.Bits() <= bits || !prefix.Contains(addr) {
+				continue
+			}
+			matched = !nomatch
+			bits = prefix.Bits()
+		}
+		return matched
+	default:
+		_, ok := set.IPPodKey[ip]
+		return ok
+	}
+}
@@ -151,3 +151,10 @@ func TestShouldBeInKernelAndCanDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestNamedPortMember(t *testing.T) {
+	require.Equal(t, "10.0.0.1,UDP:53", NamedPortMember("10.0.0.1", "UDP", 53))
+	require.Equal(t, "10.0.0.1,SCTP:9000", NamedPortMember("10.0.0.1", "SCTP", 9000))
+	// a port without a protocol is TCP, as in the pod spec
+	require.Equal(t, "10.0.0.1,TCP:8080", NamedPortMember("10.0.0.1", "", 8080))
+}
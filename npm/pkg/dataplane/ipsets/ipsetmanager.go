@@ -8,6 +8,7 @@ import (
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/npm/util/errorbudget"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"k8s.io/klog"
 )
@@ -51,9 +52,34 @@ type IPSetManager struct {
 	setMap     map[string]*IPSet
 	dirtyCache dirtyCacheInterface
 	ioShim     *common.IOShim
+	// applyFailures counts the consecutive applies in which each set failed.
+	// Sets whose budget is exhausted are in setsToRebuild until an apply of them succeeds.
+	applyFailures *errorbudget.Budget
+	setsToRebuild map[string]struct{}
+	// failedSets are the sets whose lines failed in the current apply. Only Linux reports them.
+	failedSets map[string]*setApplyFailure
+	// podIPOwners are the pods of the newest generation which each member was added to sets for.
+	// Only members added with a generation are tracked. See AddToSetsWithGeneration.
+	podIPOwners map[string]*podIPOwner
 	sync.RWMutex
 }
 
+// podIPOwner is the pod of the newest generation which a member was added to sets for.
+type podIPOwner struct {
+	podKey     string
+	generation int64
+	// numSets is the number of sets with the member since it's tracked. The owner is forgotten when it's zero.
+	numSets int
+}
+
+// setApplyFailure is the changes to a set which failed in an apply.
+type setApplyFailure struct {
+	// created is true if the set couldn't be created, so none of its members were applied.
+	created         bool
+	membersToAdd    map[string]struct{}
+	membersToDelete map[string]struct{}
+}
+
 type IPSetManagerCfg struct {
 	IPSetMode IPSetMode
 	// NetworkName can be left empty or set to 'azure' or 'Calico' (case sensitive)
@@ -62,15 +88,28 @@ type IPSetManagerCfg struct {
 	// This is necessary for HNS (Windows); otherwise, an allow ACL with a list condition
 	// allows all IPs if the list has no members.
 	AddEmptySetToLists bool
+	// EnableIPv6 only affects Linux. It programs an inet6 family twin of each set for dual-stack clusters.
+	// Members of hash sets are added to the twin of their IP family, and lists of twins mirror the lists.
+	EnableIPv6 bool
+	// MaxLinesPerRestore only affects Linux. It bounds the number of lines in each ipset restore file of an apply.
+	// Zero uses a default bound.
+	MaxLinesPerRestore int
+	// MaxConsecutiveApplyFailures only affects Linux. It's the number of applies in a row in which a set can fail
+	// before the next apply rebuilds the set from the cache instead of applying its changes.
+	// Zero uses errorbudget.DefaultMaxFailures.
+	MaxConsecutiveApplyFailures int
 }
 
 func NewIPSetManager(iMgrCfg *IPSetManagerCfg, ioShim *common.IOShim) *IPSetManager {
 	return &IPSetManager{
-		iMgrCfg:    iMgrCfg,
-		emptySet:   nil, // will be set if needed in calls to AddToLists
-		setMap:     make(map[string]*IPSet),
-		dirtyCache: newDirtyCache(),
-		ioShim:     ioShim,
+		iMgrCfg:       iMgrCfg,
+		emptySet:      nil, // will be set if needed in calls to AddToLists
+		setMap:        make(map[string]*IPSet),
+		dirtyCache:    newDirtyCache(),
+		ioShim:        ioShim,
+		applyFailures: errorbudget.New(iMgrCfg.MaxConsecutiveApplyFailures),
+		setsToRebuild: make(map[string]struct{}),
+		podIPOwners:   make(map[string]*podIPOwner),
 	}
 }
 
@@ -99,8 +138,10 @@ func (iMgr *IPSetManager) ResetIPSets() error {
 	metrics.ResetIPSetEntries()
 	err := iMgr.resetIPSets()
 	iMgr.setMap = make(map[string]*IPSet)
+	iMgr.podIPOwners = make(map[string]*podIPOwner)
 	iMgr.emptySet = nil
 	iMgr.clearDirtyCache()
+	iMgr.resetApplyFailures()
 	if err != nil {
 		metrics.SendErrorLogAndMetric(util.IpsmID, "error: failed to reset ipsetmanager: %s", err.Error())
 		return fmt.Errorf("error while resetting ipsetmanager: %w", err)
@@ -108,6 +149,25 @@ func (iMgr *IPSetManager) ResetIPSets() error {
 	return nil
 }
 
+// Cleanup destroys all NPM IPSets in the kernel (Linux) or HNS (Windows), including sets unknown to the cache.
+// If dryRun is true, the sets are only listed. Returns the names of the sets which were (or would be) destroyed.
+func (iMgr *IPSetManager) Cleanup(dryRun bool) ([]string, error) {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	names, err := iMgr.cleanup(dryRun)
+	if err != nil {
+		return names, fmt.Errorf("error while cleaning up ipsetmanager: %w", err)
+	}
+	if !dryRun {
+		iMgr.setMap = make(map[string]*IPSet)
+		iMgr.podIPOwners = make(map[string]*podIPOwner)
+		iMgr.emptySet = nil
+		iMgr.clearDirtyCache()
+		iMgr.resetApplyFailures()
+	}
+	return names, nil
+}
+
 func (iMgr *IPSetManager) CreateIPSets(setMetadatas []*IPSetMetadata) {
 	iMgr.Lock()
 	defer iMgr.Unlock()
@@ -169,6 +229,16 @@ func (iMgr *IPSetManager) GetIPSet(name string) *IPSet {
 	return iMgr.setMap[name]
 }
 
+// Usage returns the number of sets in the cache and the total number of their members.
+func (iMgr *IPSetManager) Usage() (numSets, numMembers int) {
+	iMgr.RLock()
+	defer iMgr.RUnlock()
+	for _, set := range iMgr.setMap {
+		numMembers += len(set.IPPodKey) + len(set.MemberIPSets)
+	}
+	return len(iMgr.setMap), numMembers
+}
+
 // AddReference creates the set if necessary and adds relevant reference
 // it throws an error if the set and reference type are an invalid combination
 func (iMgr *IPSetManager) AddReference(setMetadata *IPSetMetadata, referenceName string, referenceType ReferenceType) error {
@@ -234,11 +304,19 @@ func (iMgr *IPSetManager) DeleteReference(setName, referenceName string, referen
 }
 
 func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey string) error {
+	return iMgr.AddToSetsWithGeneration(addToSets, ip, podKey, 0)
+}
+
+// AddToSetsWithGeneration adds the member to the sets for the pod of the generation, which orders the pods assigned
+// the same IP, e.g. their creation time. Once the member is added for a pod of a newer generation, the adds for the
+// pods of older generations are stale and return ErrStaleMember, so that the out-of-order events of a pod whose IP
+// was reused don't add the member to its sets. A zero generation isn't tracked.
+func (iMgr *IPSetManager) AddToSetsWithGeneration(addToSets []*IPSetMetadata, ip, podKey string, generation int64) error {
 	if len(addToSets) == 0 {
 		return nil
 	}
 
-	if !validateIPSetMemberIP(ip) {
+	if !iMgr.isValidMemberIP(ip) {
 		msg := fmt.Sprintf("error: failed to add to sets: invalid ip %s", ip)
 		metrics.SendErrorLogAndMetric(util.IpsmID, msg)
 		return npmerrors.Errorf(npmerrors.AppendIPSet, true, msg)
@@ -247,6 +325,23 @@ func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey strin
 	iMgr.Lock()
 	defer iMgr.Unlock()
 
+	owner := iMgr.podIPOwners[ip]
+	if generation != 0 {
+		if owner != nil && owner.generation > generation {
+			klog.Infof(
+				"[IPSetManager] AddToSet: Ip: %s was added for podKey: %s of generation %d, newer than podKey: %s of generation %d. Ignore the add as this is stale update",
+				ip, owner.podKey, owner.generation, podKey, generation,
+			)
+			return ErrStaleMember
+		}
+		if owner == nil {
+			owner = &podIPOwner{}
+			iMgr.podIPOwners[ip] = owner
+		}
+		owner.podKey = podKey
+		owner.generation = generation
+	}
+
 	for _, metadata := range addToSets {
 		// 1. check for errors and create a missing set
 		prefixedName := metadata.GetPrefixName()
@@ -263,18 +358,29 @@ func (iMgr *IPSetManager) AddToSets(addToSets []*IPSetMetadata, ip, podKey strin
 		if !ok {
 			iMgr.modifyCacheForKernelMemberAdd(set, ip)
 			metrics.AddEntryToIPSet(prefixedName)
+			if owner != nil {
+				owner.numSets++
+			}
 		}
 		set.IPPodKey[ip] = podKey
+		set.setGeneration(ip, generation)
 	}
 	return nil
 }
 
 func (iMgr *IPSetManager) RemoveFromSets(removeFromSets []*IPSetMetadata, ip, podKey string) error {
+	return iMgr.RemoveFromSetsWithGeneration(removeFromSets, ip, podKey, 0)
+}
+
+// RemoveFromSetsWithGeneration removes the member from the sets for the pod of the generation. The member isn't
+// removed from the sets where it was added for another pod, or for the same pod key of a newer generation, e.g. a
+// StatefulSet pod recreated with the same IP. A zero generation removes the member added for the pod key.
+func (iMgr *IPSetManager) RemoveFromSetsWithGeneration(removeFromSets []*IPSetMetadata, ip, podKey string, generation int64) error {
 	if len(removeFromSets) == 0 {
 		return nil
 	}
 
-	if !validateIPSetMemberIP(ip) {
+	if !iMgr.isValidMemberIP(ip) {
 		msg := fmt.Sprintf("error: failed to add to sets: invalid ip %s", ip)
 		metrics.SendErrorLogAndMetric(util.IpsmID, msg)
 		return npmerrors.Errorf(npmerrors.AppendIPSet, true, msg)
@@ -309,11 +415,25 @@ func (iMgr *IPSetManager) RemoveFromSets(removeFromSets []*IPSetMetadata, ip, po
 			)
 			continue
 		}
+		if cachedGeneration := set.ipGenerations[ip]; generation != 0 && cachedGeneration > generation {
+			klog.Infof(
+				"[IPSetManager] DeleteFromSet: Ip: %s, setName:%s was added for generation %d of podKey: %s, newer than generation %d. Ignore the delete as this is stale update",
+				ip, prefixedName, cachedGeneration, podKey, generation,
+			)
+			continue
+		}
 
 		// update the IP ownership with podkey
 		iMgr.modifyCacheForKernelMemberDelete(set, ip)
 		delete(set.IPPodKey, ip)
+		set.setGeneration(ip, 0)
 		metrics.RemoveEntryFromIPSet(prefixedName)
+		if owner, ok := iMgr.podIPOwners[ip]; ok {
+			owner.numSets--
+			if owner.numSets <= 0 {
+				delete(iMgr.podIPOwners, ip)
+			}
+		}
 	}
 	return nil
 }
@@ -461,17 +581,114 @@ func (iMgr *IPSetManager) ApplyIPSets() error {
 	// Call the appropriate apply ipsets
 	prometheusTimer := metrics.StartNewTimer()
 	defer metrics.RecordIPSetExecTime(prometheusTimer) // record execution time regardless of failure
+	setsInApply := iMgr.dirtyCache.setsToAddOrUpdate()
+	iMgr.failedSets = make(map[string]*setApplyFailure)
 	err := iMgr.applyIPSets()
 	if err != nil {
 		metrics.SendErrorLogAndMetric(util.IpsmID, "error: failed to apply ipsets: %s", err.Error())
+		iMgr.trackApplyFailures(setsInApply, false)
 		return err
 	}
 
 	iMgr.clearDirtyCache()
+	iMgr.trackApplyFailures(setsInApply, true)
 	// TODO could also set the number of ipsets in NPM (not necessarily in kernel) here using len(iMgr.setMap)
 	return nil
 }
 
+// recordApplyFailure records that a line creating the set, or adding or deleting the member, failed in the current apply.
+func (iMgr *IPSetManager) recordApplyFailure(set *IPSet, create bool, memberToAdd, memberToDelete string) {
+	if iMgr.failedSets == nil {
+		iMgr.failedSets = make(map[string]*setApplyFailure)
+	}
+	failure, ok := iMgr.failedSets[set.Name]
+	if !ok {
+		failure = &setApplyFailure{
+			membersToAdd:    make(map[string]struct{}),
+			membersToDelete: make(map[string]struct{}),
+		}
+		iMgr.failedSets[set.Name] = failure
+	}
+	failure.created = failure.created || create
+	if memberToAdd != "" {
+		failure.membersToAdd[memberToAdd] = struct{}{}
+	}
+	if memberToDelete != "" {
+		failure.membersToDelete[memberToDelete] = struct{}{}
+	}
+}
+
+/*
+trackApplyFailures updates the consecutive failures of the sets created or updated in an apply,
+and makes the next apply retry the changes which failed.
+If the apply didn't complete, only the sets with failed lines are counted, since the lines of the others may not have run.
+
+A set whose budget is exhausted is rebuilt by the next applies until one succeeds: the set is flushed (or destroyed
+if nothing references it, in case it has the wrong type), created, and all of its members are added.
+Failed destroys of deleted sets aren't tracked.
+*/
+func (iMgr *IPSetManager) trackApplyFailures(setsInApply map[string]struct{}, completed bool) {
+	for prefixedName := range setsInApply {
+		applyType := metrics.IncrementalApply
+		if _, ok := iMgr.setsToRebuild[prefixedName]; ok {
+			applyType = metrics.RebuildApply
+		}
+
+		failure, failed := iMgr.failedSets[prefixedName]
+		if !failed {
+			if completed {
+				metrics.RecordDataplaneApply(metrics.IPSetObject, applyType, false)
+				iMgr.applyFailures.Reset(prefixedName)
+				delete(iMgr.setsToRebuild, prefixedName)
+			}
+			continue
+		}
+		metrics.RecordDataplaneApply(metrics.IPSetObject, applyType, true)
+
+		set, ok := iMgr.setMap[prefixedName]
+		if !ok || iMgr.dirtyCache.isSetToDelete(prefixedName) {
+			// the set is being deleted, so there's nothing to retry
+			iMgr.applyFailures.Reset(prefixedName)
+			delete(iMgr.setsToRebuild, prefixedName)
+			continue
+		}
+
+		if iMgr.applyFailures.Failed(prefixedName) {
+			if applyType == metrics.IncrementalApply {
+				metrics.SendErrorLogAndMetric(util.IpsmID, "error: set %s failed %d applies in a row. rebuilding it in the next apply",
+					prefixedName, iMgr.applyFailures.Failures(prefixedName))
+			}
+			iMgr.setsToRebuild[prefixedName] = struct{}{}
+			iMgr.markDirty(set)
+			continue
+		}
+
+		if failure.created {
+			iMgr.markDirty(set)
+			continue
+		}
+		for member := range failure.membersToAdd {
+			iMgr.dirtyCache.addMember(set, member)
+		}
+		for member := range failure.membersToDelete {
+			iMgr.dirtyCache.deleteMember(set, member)
+		}
+	}
+	iMgr.failedSets = nil
+}
+
+func (iMgr *IPSetManager) resetApplyFailures() {
+	iMgr.applyFailures = errorbudget.New(iMgr.iMgrCfg.MaxConsecutiveApplyFailures)
+	iMgr.setsToRebuild = make(map[string]struct{})
+}
+
+// markDirty makes the next apply create the set and add all of its members, unless the set is already dirty.
+func (iMgr *IPSetManager) markDirty(set *IPSet) {
+	if !iMgr.dirtyCache.isSetToAddOrUpdate(set.Name) {
+		iMgr.dirtyCache.create(set)
+	}
+}
+
 func (iMgr *IPSetManager) GetAllIPSets() map[string]string {
 	iMgr.RLock()
 	defer iMgr.RUnlock()
@@ -482,6 +699,122 @@ func (iMgr *IPSetManager) GetAllIPSets() map[string]string {
 	return setMap
 }
 
+// GetIPsFromSelectorIPSets will take in a map of prefixedSetNames and return an intersection of IPs mapped to pod key
+func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error) {
+	ips := make(map[string]string)
+	if len(setList) == 0 {
+		return ips, nil
+	}
+	iMgr.Lock()
+	defer iMgr.Unlock()
+
+	if err := iMgr.validateSelectorIPSets(setList); err != nil {
+		return nil, err
+	}
+
+	// the following is a space/time optimized way to get the intersection of IPs from the selector sets
+	// we should always take the hash set branch because a pod selector always includes a namespace ipset,
+	// which is a hash set, and we favor hash sets for firstSet
+	var firstSet *IPSet
+	for setName := range setList {
+		firstSet = iMgr.setMap[setName]
+		if firstSet.Kind == HashSet {
+			// firstSet can be any set, but ideally is a hash set for efficiency (compare the branch for hash sets to the one for lists below)
+			break
+		}
+	}
+	if firstSet.Kind == HashSet {
+		// include every IP in firstSet that is also affiliated with every other selector set
+		for ip, podKey := range firstSet.IPPodKey {
+			isAffiliated := true
+			for otherSetName := range setList {
+				if otherSetName == firstSet.Name {
+					continue
+				}
+				otherSet := iMgr.setMap[otherSetName]
+				if !otherSet.isIPAffiliated(ip, podKey) {
+					isAffiliated = false
+					break
+				}
+			}
+
+			if isAffiliated {
+				ips[ip] = podKey
+			}
+		}
+	} else {
+		// should never reach this branch (see note above)
+		// include every IP affiliated with firstSet that is also affiliated with every other selector set
+		// identical to the hash set case, except we have to make space for all IPs affiliated with firstSet
+
+		// only loop over the unique affiliated IPs
+		for _, memberSet := range firstSet.MemberIPSets {
+			for ip, podKey := range memberSet.IPPodKey {
+				if oldKey, ok := ips[ip]; ok && oldKey != podKey {
+					// this could lead to unintentionally considering this Pod (Pod B) to be part of the selector set if:
+					// 1. Pod B has the same IP as a previous Pod A
+					// 2. Pod B create is somehow processed before Pod A delete
+					// 3. This method is called before Pod A delete
+					// again, this
+					klog.Warningf("[GetIPsFromSelectorIPSets] IP currently associated with two different pod keys. to ensure no issues occur with network policies, restart this ip: %s", ip)
+				}
+				ips[ip] = podKey
+			}
+		}
+		for ip, podKey := range ips {
+			// identical to the hash set case
+			isAffiliated := true
+			for otherSetName := range setList {
+				if otherSetName == firstSet.Name {
+					continue
+				}
+				otherSet := iMgr.setMap[otherSetName]
+				if !otherSet.isIPAffiliated(ip, podKey) {
+					isAffiliated = false
+					break
+				}
+			}
+
+			if !isAffiliated {
+				delete(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+// MatchesIP returns whether the set with the prefixed name, or one of its member sets, matches the IP the way the kernel
+// would. For a NamedPorts set, the protocol and port of the IP are matched too.
+func (iMgr *IPSetManager) MatchesIP(setName, ip, protocol string, port int32) bool {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+
+	set, ok := iMgr.setMap[setName]
+	if !ok {
+		return false
+	}
+	return set.matchesIP(ip, protocol, port)
+}
+
+func (iMgr *IPSetManager) validateSelectorIPSets(setList map[string]struct{}) error {
+	for setName := range setList {
+		if !iMgr.exists(setName) {
+			return npmerrors.Errorf(
+				npmerrors.GetSelectorReference,
+				false,
+				fmt.Sprintf("[ipset manager] selector ipset %s does not exist", setName))
+		}
+		set := iMgr.setMap[setName]
+		if !set.canSetBeSelectorIPSet() {
+			return npmerrors.Errorf(
+				npmerrors.IPSetIntersection,
+				false,
+				fmt.Sprintf("[IPSet] Selector IPSet cannot be of type %s", set.Type.String()))
+		}
+	}
+	return nil
+}
+
 func (iMgr *IPSetManager) exists(name string) bool {
 	_, ok := iMgr.setMap[name]
 	return ok
@@ -505,6 +838,8 @@ func (iMgr *IPSetManager) modifyCacheForCacheDeletion(set *IPSet, deleteOption u
 
 	delete(iMgr.setMap, set.Name)
 	metrics.DeleteIPSet(set.Name)
+	iMgr.applyFailures.Reset(set.Name)
+	delete(iMgr.setsToRebuild, set.Name)
 	if iMgr.iMgrCfg.IPSetMode == ApplyAllIPSets {
 		iMgr.modifyCacheForKernelRemoval(set)
 	}
@@ -583,8 +918,23 @@ func (iMgr *IPSetManager) clearDirtyCache() {
 	iMgr.dirtyCache.reset()
 }
 
+// isValidMemberIP returns true if the member has a valid IPv4 IP or CIDR, or a valid IPv6 one when IPv6 is enabled.
+func (iMgr *IPSetManager) isValidMemberIP(ip string) bool {
+	return validateIPSetMemberIP(ip) || (iMgr.iMgrCfg.EnableIPv6 && validateIPSetMemberIPv6(ip))
+}
+
 // validateIPSetMemberIP helps valid if a member added to an HashSet has valid IP or CIDR
 func validateIPSetMemberIP(ip string) bool {
+	return util.IsIPV4(memberIP(ip))
+}
+
+// validateIPSetMemberIPv6 is the IPv6 counterpart of validateIPSetMemberIP
+func validateIPSetMemberIPv6(ip string) bool {
+	return util.IsIPV6(memberIP(ip))
+}
+
+// memberIP returns the IP or CIDR of a HashSet member
+func memberIP(member string) string {
 	// possible formats
 	// 192.168.0.1
 	// 192.168.0.1,tcp:25227
@@ -593,8 +943,7 @@ func validateIPSetMemberIP(ip string) bool {
 	// 192.168.0.0/24,tcp:25227
 	// 192.168.0.0/24 nomatch
 	// always guaranteed to have ip, not guaranteed to have port + protocol
-	ipDetails := strings.Split(ip, ",")
+	ipDetails := strings.Split(member, ",")
 	ipField := strings.Split(ipDetails[0], " ")
-
-	return util.IsIPV4(ipField[0])
+	return ipField[0]
 }
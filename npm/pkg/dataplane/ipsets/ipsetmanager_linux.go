@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/parse"
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -39,6 +40,8 @@ const (
 	ipsetIPPortHashFlag = "hash:ip,port"
 	ipsetMaxelemName    = "maxelem"
 	ipsetMaxelemNum     = "4294967295"
+	ipsetFamilyFlag     = "family"
+	ipsetInet6Family    = "inet6"
 
 	// constants for parsing ipset save
 	createStringWithSpace = "create "
@@ -51,6 +54,7 @@ const (
 
 	// creator constants
 	maxTryCount                    = 5
+	defaultMaxLinesPerRestore      = 5000
 	destroySectionPrefix           = "delete"
 	addOrUpdateSectionPrefix       = "add/update"
 	ipsetRestoreLineFailurePattern = "Error in line (\\d+):"
@@ -137,6 +141,31 @@ func (iMgr *IPSetManager) resetIPSets() error {
 	return nil
 }
 
+// cleanup lists the current NPM ipsets, and destroys them unless dryRun is true.
+func (iMgr *IPSetManager) cleanup(dryRun bool) ([]string, error) {
+	listNamesCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag, ipsetNameFlag)
+	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, azureNPMPrefix)
+	klog.Infof("running this command while cleaning up ipsets: [%s %s %s | %s %s]", ipsetCommand, ipsetListFlag, ipsetNameFlag, ioutil.Grep, azureNPMPrefix)
+	azureIPSets, haveAzureNPMIPSets, commandError := ioutil.PipeCommandToGrep(listNamesCommand, grepCommand)
+	if commandError != nil {
+		return nil, npmerrors.SimpleErrorWrapper("failed to run ipset list for cleaning up IPSets", commandError)
+	}
+	if !haveAzureNPMIPSets {
+		return nil, nil
+	}
+
+	names := make([]string, 0)
+	for _, line := range strings.Split(string(azureIPSets), "\n") {
+		if line != "" {
+			names = append(names, line)
+		}
+	}
+	if dryRun {
+		return names, nil
+	}
+	return names, iMgr.resetIPSets()
+}
+
 // resetWithoutRestore will return true (success) if able to reset without restore
 func (iMgr *IPSetManager) resetWithoutRestore() bool {
 	listNamesCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag, ipsetNameFlag)
@@ -405,14 +434,29 @@ example where:
 		-X set4
 */
 func (iMgr *IPSetManager) applyIPSets() error {
-	creator := iMgr.fileCreatorForApply(maxTryCount)
-	restoreError := creator.RunCommandWithFile(ipsetCommand, ipsetRestoreFlag)
-	if restoreError != nil {
-		return npmerrors.SimpleErrorWrapper("ipset restore failed when applying ipsets", restoreError)
+	chunks := iMgr.chunksForApply(maxTryCount, iMgr.maxLinesPerRestore())
+	metrics.SetPendingIPSetRestoreChunks(len(chunks))
+	defer metrics.SetPendingIPSetRestoreChunks(0)
+	for i, chunk := range chunks {
+		restoreError := chunk.creator.RunCommandWithFile(ipsetCommand, ipsetRestoreFlag)
+		metrics.RecordIPSetRestoreChunk(restoreError != nil)
+		if restoreError != nil {
+			msg := fmt.Sprintf("ipset restore failed when applying chunk %d of %d of ipsets", i+1, len(chunks))
+			return npmerrors.SimpleErrorWrapper(msg, restoreError)
+		}
+		chunk.checkpoint()
+		metrics.SetPendingIPSetRestoreChunks(len(chunks) - i - 1)
 	}
 	return nil
 }
 
+func (iMgr *IPSetManager) maxLinesPerRestore() int {
+	if iMgr.iMgrCfg.MaxLinesPerRestore > 0 {
+		return iMgr.iMgrCfg.MaxLinesPerRestore
+	}
+	return defaultMaxLinesPerRestore
+}
+
 func (iMgr *IPSetManager) ipsetSave() ([]byte, error) {
 	command := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetSaveFlag)
 	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, azureNPMPrefix)
@@ -475,17 +519,71 @@ func (iMgr *IPSetManager) fileCreatorForApplyWithSaveFile(maxTryCount int, saveF
 	return creator
 }
 
-// NOTE: duplicate code in the first step in this function and fileCreatorForApplyWithSaveFile
+// fileCreatorForApply returns the whole restore file of an apply as one FileCreator.
 func (iMgr *IPSetManager) fileCreatorForApply(maxTryCount int) *ioutil.FileCreator {
-	creator := ioutil.NewFileCreator(iMgr.ioShim, maxTryCount, ipsetRestoreLineFailurePattern) // TODO make the line failure pattern into a definition constant eventually
+	return iMgr.chunksForApply(maxTryCount, 0)[0].creator
+}
+
+// restoreChunk is one ipset restore file of an apply.
+// Once the chunk is restored, its checkpoints remove its changes from the dirty cache
+// so that a failure in a later chunk doesn't make the next apply repeat them.
+type restoreChunk struct {
+	creator     *ioutil.FileCreator
+	checkpoints []func()
+}
+
+func (chunk *restoreChunk) checkpoint() {
+	for _, f := range chunk.checkpoints {
+		f()
+	}
+}
+
+// restoreChunker splits the lines of an apply into chunks of at most maxLines lines (unbounded if maxLines is 0).
+// The lines for a single set or member are never split across chunks.
+type restoreChunker struct {
+	ioShim      *common.IOShim
+	maxTryCount int
+	maxLines    int
+	chunks      []*restoreChunk
+}
+
+// next returns the chunk to add the lines of the next set or member to.
+func (chunker *restoreChunker) next() *restoreChunk {
+	if len(chunker.chunks) > 0 {
+		last := chunker.chunks[len(chunker.chunks)-1]
+		if chunker.maxLines == 0 || last.creator.TotalLines() < chunker.maxLines {
+			return last
+		}
+	}
+	chunk := &restoreChunk{
+		creator: ioutil.NewFileCreator(chunker.ioShim, chunker.maxTryCount, ipsetRestoreLineFailurePattern), // TODO make the line failure pattern into a definition constant eventually
+	}
+	chunker.chunks = append(chunker.chunks, chunk)
+	return chunk
+}
+
+// chunksForApply returns the restore files of an apply in the order they must run. There is always at least one chunk.
+// Chunks keep the order of the lines, so sets are still created before members are added and flushed before they're destroyed.
+// NOTE: duplicate code in the first step in this function and fileCreatorForApplyWithSaveFile
+func (iMgr *IPSetManager) chunksForApply(maxTryCount, maxLines int) []*restoreChunk {
+	chunker := &restoreChunker{
+		ioShim:      iMgr.ioShim,
+		maxTryCount: maxTryCount,
+		maxLines:    maxLines,
+	}
+	chunker.next()
 
 	// 1. create all sets first so we don't try to add a member set to a list if it hasn't been created yet
 	setsToAddOrUpdate := iMgr.dirtyCache.setsToAddOrUpdate()
 	for prefixedName := range setsToAddOrUpdate {
 		set := iMgr.setMap[prefixedName]
+		creator := chunker.next().creator
+		if _, ok := iMgr.setsToRebuild[prefixedName]; ok {
+			iMgr.resetSetForRebuild(creator, set)
+		}
 		iMgr.createSetForApply(creator, set)
-		// NOTE: currently no logic to handle this scenario:
-		// if a set in the toAddOrUpdateCache is in the kernel with the wrong type, then we'll try to create it, which will fail in the first restore call, but then be skipped in a retry
+		// NOTE: if a set in the toAddOrUpdateCache is in the kernel with the wrong type, then we'll try to create it, which will fail in the first restore call, but then be skipped in a retry.
+		// The failure counts against the set's error budget, so the set is eventually rebuilt.
 	}
 
 	// 2. delete/add members from dirty sets to add or update
@@ -493,11 +591,32 @@ func (iMgr *IPSetManager) fileCreatorForApply(maxTryCount int) *ioutil.FileCreat
 		sectionID := sectionID(addOrUpdateSectionPrefix, prefixedName)
 		set := iMgr.setMap[prefixedName]
 		diff := iMgr.dirtyCache.memberDiff(prefixedName)
+		if _, ok := iMgr.setsToRebuild[prefixedName]; ok {
+			// the set was flushed, so add all of its members instead of its diff
+			for member := range diff.membersToDelete {
+				member := member
+				chunk := chunker.next()
+				chunk.checkpoints = append(chunk.checkpoints, func() { diff.removeMemberFromDiffToDelete(member) })
+			}
+			for member := range diffOnCreate(set).membersToAdd {
+				member := member
+				chunk := chunker.next()
+				iMgr.addMemberForApply(chunk.creator, set, sectionID, member)
+				chunk.checkpoints = append(chunk.checkpoints, func() { diff.removeMemberFromDiffToAdd(member) })
+			}
+			continue
+		}
 		for member := range diff.membersToDelete {
-			iMgr.deleteMemberForApply(creator, set, sectionID, member)
+			member := member
+			chunk := chunker.next()
+			iMgr.deleteMemberForApply(chunk.creator, set, sectionID, member)
+			chunk.checkpoints = append(chunk.checkpoints, func() { diff.removeMemberFromDiffToDelete(member) })
 		}
 		for member := range diff.membersToAdd {
-			iMgr.addMemberForApply(creator, set, sectionID, member)
+			member := member
+			chunk := chunker.next()
+			iMgr.addMemberForApply(chunk.creator, set, sectionID, member)
+			chunk.checkpoints = append(chunk.checkpoints, func() { diff.removeMemberFromDiffToAdd(member) })
 		}
 	}
 
@@ -512,12 +631,15 @@ func (iMgr *IPSetManager) fileCreatorForApply(maxTryCount int) *ioutil.FileCreat
 	// flush all sets first in case a set we're destroying is referenced by a list we're destroying
 	setsToDelete := iMgr.dirtyCache.setsToDelete()
 	for prefixedName := range setsToDelete {
-		iMgr.flushSetForApply(creator, prefixedName)
+		iMgr.flushSetForApply(chunker.next().creator, prefixedName)
 	}
 	for prefixedName := range setsToDelete {
-		iMgr.destroySetForApply(creator, prefixedName)
+		prefixedName := prefixedName
+		chunk := chunker.next()
+		iMgr.destroySetForApply(chunk.creator, prefixedName)
+		chunk.checkpoints = append(chunk.checkpoints, func() { iMgr.dirtyCache.destroyed(prefixedName) })
 	}
-	return creator
+	return chunker.chunks
 }
 
 // updates the creator (adds/deletes members) for dirty sets already in the kernel
@@ -703,6 +825,9 @@ func (iMgr *IPSetManager) flushSetForApply(creator *ioutil.FileCreator, prefixed
 	sectionID := sectionID(destroySectionPrefix, prefixedName)
 	hashedName := util.GetHashedName(prefixedName)
 	creator.AddLine(sectionID, errorHandlers, ipsetFlushFlag, hashedName) // flush set
+	if iMgr.iMgrCfg.EnableIPv6 {
+		creator.AddLine(sectionID, errorHandlers, ipsetFlushFlag, util.GetIPv6HashedName(hashedName)) // flush IPv6 twin
+	}
 }
 
 func (iMgr *IPSetManager) destroySetForApply(creator *ioutil.FileCreator, prefixedName string) {
@@ -726,6 +851,33 @@ func (iMgr *IPSetManager) destroySetForApply(creator *ioutil.FileCreator, prefix
 	sectionID := sectionID(destroySectionPrefix, prefixedName)
 	hashedName := util.GetHashedName(prefixedName)
 	creator.AddLine(sectionID, errorHandlers, ipsetDestroyFlag, hashedName) // destroy set
+	if iMgr.iMgrCfg.EnableIPv6 {
+		creator.AddLine(sectionID, errorHandlers, ipsetDestroyFlag, util.GetIPv6HashedName(hashedName)) // destroy IPv6 twin
+	}
+}
+
+// resetSetForRebuild flushes the set before it's created and its members are added, so that the kernel set has exactly the members in the cache.
+// The set is also destroyed if nothing references it, in case it exists with the wrong type.
+func (iMgr *IPSetManager) resetSetForRebuild(creator *ioutil.FileCreator, set *IPSet) {
+	errorHandlers := []*ioutil.LineErrorHandler{
+		{
+			// e.g. the set doesn't exist, or is in use by a kernel component and can't be destroyed
+			Definition: ioutil.AlwaysMatchDefinition,
+			Method:     ioutil.Continue,
+			Callback: func() {
+				klog.Infof("[IPSetManager] skipping flush or destroy line when rebuilding set %s", set.Name)
+			},
+		},
+	}
+	sectionID := sectionID(addOrUpdateSectionPrefix, set.Name)
+	hashedNames := []string{set.HashedName}
+	if iMgr.iMgrCfg.EnableIPv6 {
+		hashedNames = append(hashedNames, util.GetIPv6HashedName(set.HashedName))
+	}
+	for _, hashedName := range hashedNames {
+		creator.AddLine(sectionID, errorHandlers, ipsetFlushFlag, hashedName)   // flush set
+		creator.AddLine(sectionID, errorHandlers, ipsetDestroyFlag, hashedName) // destroy set if unreferenced
+	}
 }
 
 func (iMgr *IPSetManager) createSetForApply(creator *ioutil.FileCreator, set *IPSet) {
@@ -748,7 +900,7 @@ func (iMgr *IPSetManager) createSetForApply(creator *ioutil.FileCreator, set *IP
 			Method:     ioutil.ContinueAndAbortSection,
 			Callback: func() {
 				metrics.SendErrorLogAndMetric(util.IpsmID, "skipping create and any following adds/deletes for set %s since the set already exists with different specs", prefixedName)
-				// TODO mark the set as a failure and handle this
+				iMgr.recordApplyFailure(set, true, "", "")
 			},
 		},
 		{
@@ -756,12 +908,23 @@ func (iMgr *IPSetManager) createSetForApply(creator *ioutil.FileCreator, set *IP
 			Method:     ioutil.ContinueAndAbortSection,
 			Callback: func() {
 				metrics.SendErrorLogAndMetric(util.IpsmID, "skipping create and any following adds/deletes for set %s due to unknown error", prefixedName)
-				// TODO same as above error handler
+				iMgr.recordApplyFailure(set, true, "", "")
 			},
 		},
 	}
 	sectionID := sectionID(addOrUpdateSectionPrefix, prefixedName)
 	creator.AddLine(sectionID, errorHandlers, specs...) // create set
+	if iMgr.iMgrCfg.EnableIPv6 {
+		// lists don't have a family, but hash sets default to inet
+		ipv6Specs := []string{ipsetCreateFlag, util.GetIPv6HashedName(set.HashedName), ipsetExistFlag, methodFlag}
+		if set.Kind == HashSet {
+			ipv6Specs = append(ipv6Specs, ipsetFamilyFlag, ipsetInet6Family)
+		}
+		if set.Type == CIDRBlocks {
+			ipv6Specs = append(ipv6Specs, ipsetMaxelemName, ipsetMaxelemNum)
+		}
+		creator.AddLine(sectionID, errorHandlers, ipv6Specs...) // create IPv6 twin
+	}
 }
 
 func (iMgr *IPSetManager) deleteMemberForApply(creator *ioutil.FileCreator, set *IPSet, sectionID, member string) {
@@ -771,10 +934,17 @@ func (iMgr *IPSetManager) deleteMemberForApply(creator *ioutil.FileCreator, set
 			Method:     ioutil.Continue,
 			Callback: func() {
 				metrics.SendErrorLogAndMetric(util.IpsmID, "skipping delete line for set %s due to unknown error", set.Name)
+				iMgr.recordApplyFailure(set, false, "", member)
 			},
 		},
 	}
-	creator.AddLine(sectionID, errorHandlers, ipsetDeleteFlag, set.HashedName, member) // delete member
+	if !iMgr.iMgrCfg.EnableIPv6 {
+		creator.AddLine(sectionID, errorHandlers, ipsetDeleteFlag, set.HashedName, member) // delete member
+		return
+	}
+	for _, line := range iMgr.dualStackMemberLines(set, member) {
+		creator.AddLine(sectionID, errorHandlers, ipsetDeleteFlag, line[0], line[1]) // delete member
+	}
 }
 
 func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IPSet, sectionID, member string) {
@@ -786,7 +956,7 @@ func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IP
 				Method:     ioutil.Continue,
 				Callback: func() {
 					metrics.SendErrorLogAndMetric(util.IpsmID, "skipping add of %s to list %s since the member doesn't exist", member, set.Name)
-					// TODO reconcile
+					iMgr.recordApplyFailure(set, false, member, "")
 				},
 			},
 			{
@@ -794,6 +964,7 @@ func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IP
 				Method:     ioutil.Continue,
 				Callback: func() {
 					metrics.SendErrorLogAndMetric(util.IpsmID, "skipping add of %s to list %s due to unknown error", member, set.Name)
+					iMgr.recordApplyFailure(set, false, member, "")
 				},
 			},
 		}
@@ -804,11 +975,33 @@ func (iMgr *IPSetManager) addMemberForApply(creator *ioutil.FileCreator, set *IP
 				Method:     ioutil.Continue,
 				Callback: func() {
 					metrics.SendErrorLogAndMetric(util.IpsmID, "skipping add line for hash set %s due to unknown error", set.Name)
+					iMgr.recordApplyFailure(set, false, member, "")
 				},
 			},
 		}
 	}
-	creator.AddLine(sectionID, errorHandlers, ipsetAddFlag, set.HashedName, member) // add member
+	if !iMgr.iMgrCfg.EnableIPv6 {
+		creator.AddLine(sectionID, errorHandlers, ipsetAddFlag, set.HashedName, member) // add member
+		return
+	}
+	for _, line := range iMgr.dualStackMemberLines(set, member) {
+		creator.AddLine(sectionID, errorHandlers, ipsetAddFlag, line[0], line[1]) // add member
+	}
+}
+
+// dualStackMemberLines returns the set name and member for each line adding/deleting the member in dual-stack mode.
+// A hash set member goes to the set of its IP family. A list has the member set, and its IPv6 twin has the member's twin.
+func (iMgr *IPSetManager) dualStackMemberLines(set *IPSet, member string) [][2]string {
+	if set.Kind == ListSet {
+		return [][2]string{
+			{set.HashedName, member},
+			{util.GetIPv6HashedName(set.HashedName), util.GetIPv6HashedName(member)},
+		}
+	}
+	if validateIPSetMemberIPv6(member) {
+		return [][2]string{{util.GetIPv6HashedName(set.HashedName), member}}
+	}
+	return [][2]string{{set.HashedName, member}}
 }
 
 func sectionID(prefix, prefixedName string) string {
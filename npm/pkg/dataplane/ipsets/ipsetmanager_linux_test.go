@@ -992,6 +992,175 @@ func TestDeleteMembers(t *testing.T) {
 	require.False(t, wasFileAltered, "file should not be altered")
 }
 
+func TestApplyDualStack(t *testing.T) {
+	calls := []testutils.TestCmd{
+		fakeRestoreSuccessCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(&IPSetManagerCfg{IPSetMode: ApplyAllIPSets, NetworkName: "azure", EnableIPv6: true}, ioshim)
+	// create to destroy later
+	iMgr.CreateIPSets([]*IPSetMetadata{TestKeyPodSet.Metadata})
+	iMgr.clearDirtyCache()
+	iMgr.DeleteIPSet(TestKeyPodSet.PrefixName, util.SoftDelete)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "1.1.1.1", "a"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "fd00::1", "b"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestCIDRSet.Metadata}, "fd00::/64", ""))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestCIDRSet.Metadata}, "fd00::/80 nomatch", ""))
+	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+
+	nsSetV6 := util.GetIPv6HashedName(TestNSSet.HashedName)
+	cidrSetV6 := util.GetIPv6HashedName(TestCIDRSet.HashedName)
+	listV6 := util.GetIPv6HashedName(TestKeyNSList.HashedName)
+	podSetV6 := util.GetIPv6HashedName(TestKeyPodSet.HashedName)
+	expectedLines := []string{
+		fmt.Sprintf("-N %s --exist nethash", TestNSSet.HashedName),
+		fmt.Sprintf("-N %s --exist nethash family inet6", nsSetV6),
+		fmt.Sprintf("-N %s --exist nethash maxelem 4294967295", TestCIDRSet.HashedName),
+		fmt.Sprintf("-N %s --exist nethash family inet6 maxelem 4294967295", cidrSetV6),
+		fmt.Sprintf("-N %s --exist setlist", TestKeyNSList.HashedName),
+		fmt.Sprintf("-N %s --exist setlist", listV6),
+		fmt.Sprintf("-A %s 1.1.1.1", TestNSSet.HashedName),
+		fmt.Sprintf("-A %s fd00::1", nsSetV6),
+		fmt.Sprintf("-A %s fd00::/64", cidrSetV6),
+		fmt.Sprintf("-A %s fd00::/80 nomatch", cidrSetV6),
+		fmt.Sprintf("-A %s %s", TestKeyNSList.HashedName, TestNSSet.HashedName),
+		fmt.Sprintf("-A %s %s", listV6, nsSetV6),
+		fmt.Sprintf("-F %s", TestKeyPodSet.HashedName),
+		fmt.Sprintf("-F %s", podSetV6),
+		fmt.Sprintf("-X %s", TestKeyPodSet.HashedName),
+		fmt.Sprintf("-X %s", podSetV6),
+		"",
+	}
+	sortedExpectedLines := testAndSortRestoreFileLines(t, expectedLines)
+	creator := iMgr.fileCreatorForApply(len(calls))
+	actualLines := testAndSortRestoreFileString(t, creator.ToString())
+	dptestutils.AssertEqualLines(t, sortedExpectedLines, actualLines)
+	wasFileAltered, err := creator.RunCommandOnceWithFile("ipset", "restore")
+	require.NoError(t, err, "ipset restore should be successful")
+	require.False(t, wasFileAltered, "file should not be altered")
+}
+
+func TestChunksForApply(t *testing.T) {
+	iMgr := NewIPSetManager(&IPSetManagerCfg{IPSetMode: ApplyAllIPSets, NetworkName: "azure", MaxLinesPerRestore: 2}, common.NewMockIOShim(nil))
+	// create to destroy later
+	iMgr.CreateIPSets([]*IPSetMetadata{TestKeyPodSet.Metadata})
+	iMgr.clearDirtyCache()
+	iMgr.DeleteIPSet(TestKeyPodSet.PrefixName, util.SoftDelete)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.1", "a"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.2", "b"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.3", "c"))
+
+	chunks := iMgr.chunksForApply(maxTryCount, iMgr.maxLinesPerRestore())
+	require.Len(t, chunks, 3)
+	require.True(t, strings.HasPrefix(chunks[0].creator.ToString(), "-N "+TestNSSet.HashedName), "sets should be created in the first chunk")
+	require.Equal(t, fmt.Sprintf("-F %s\n-X %s\n", TestKeyPodSet.HashedName, TestKeyPodSet.HashedName), chunks[2].creator.ToString())
+
+	var combined string
+	for _, chunk := range chunks {
+		require.LessOrEqual(t, chunk.creator.TotalLines(), 2)
+		combined += chunk.creator.ToString()
+	}
+	expectedLines := testAndSortRestoreFileString(t, iMgr.fileCreatorForApply(maxTryCount).ToString())
+	dptestutils.AssertEqualLines(t, expectedLines, testAndSortRestoreFileString(t, combined))
+}
+
+func TestApplyIPSetsChunkFailureKeepsCheckpoint(t *testing.T) {
+	calls := []testutils.TestCmd{
+		fakeRestoreSuccessCommand,
+		// fail the second chunk 5 times because this is our max try count
+		{Cmd: ipsetRestoreStringSlice, ExitCode: 1},
+		{Cmd: ipsetRestoreStringSlice, ExitCode: 1},
+		{Cmd: ipsetRestoreStringSlice, ExitCode: 1},
+		{Cmd: ipsetRestoreStringSlice, ExitCode: 1},
+		{Cmd: ipsetRestoreStringSlice, ExitCode: 1},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(&IPSetManagerCfg{IPSetMode: ApplyAllIPSets, NetworkName: "azure", MaxLinesPerRestore: 2}, ioshim)
+	// create to destroy later
+	iMgr.CreateIPSets([]*IPSetMetadata{TestKeyPodSet.Metadata})
+	iMgr.clearDirtyCache()
+	iMgr.DeleteIPSet(TestKeyPodSet.PrefixName, util.SoftDelete)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.1", "a"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.2", "b"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.3", "c"))
+
+	failedChunks, err := metrics.GetIPSetRestoreChunkCount(true)
+	require.NoError(t, err)
+	require.Error(t, iMgr.ApplyIPSets())
+	newFailedChunks, err := metrics.GetIPSetRestoreChunkCount(true)
+	require.NoError(t, err)
+	require.Equal(t, failedChunks+1, newFailedChunks)
+	pending, err := metrics.GetPendingIPSetRestoreChunks()
+	require.NoError(t, err)
+	require.Equal(t, 0, pending)
+
+	// the member added in the first chunk isn't added again, and the remaining changes are still dirty
+	require.Len(t, iMgr.dirtyCache.memberDiff(TestNSSet.PrefixName).membersToAdd, 2)
+	require.True(t, iMgr.dirtyCache.isSetToAddOrUpdate(TestNSSet.PrefixName))
+	require.True(t, iMgr.dirtyCache.isSetToDelete(TestKeyPodSet.PrefixName))
+	require.Len(t, iMgr.chunksForApply(maxTryCount, iMgr.maxLinesPerRestore()), 3)
+
+	calls = []testutils.TestCmd{fakeRestoreSuccessCommand, fakeRestoreSuccessCommand, fakeRestoreSuccessCommand}
+	ioshim = common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr.ioShim = ioshim
+	require.NoError(t, iMgr.ApplyIPSets())
+	require.Equal(t, 0, iMgr.dirtyCache.numSetsToAddOrUpdate())
+	require.Equal(t, 0, iMgr.dirtyCache.numSetsToDelete())
+}
+
+func TestApplyIPSetsRebuildsSetAfterConsecutiveFailures(t *testing.T) {
+	// the add line is the last line, so the retry without it has nothing to run
+	failedAddCall := testutils.TestCmd{Cmd: ipsetRestoreStringSlice, Stdout: "Error in line 2: some error", ExitCode: 1}
+	calls := []testutils.TestCmd{failedAddCall, failedAddCall}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(&IPSetManagerCfg{IPSetMode: ApplyAllIPSets, NetworkName: "azure", MaxConsecutiveApplyFailures: 2}, ioshim)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.1", "a"))
+
+	incrementalFailures, err := metrics.GetDataplaneApplyCount(metrics.IPSetObject, metrics.IncrementalApply, true)
+	require.NoError(t, err)
+	rebuilds, err := metrics.GetDataplaneApplyCount(metrics.IPSetObject, metrics.RebuildApply, false)
+	require.NoError(t, err)
+
+	// the failed member is retried incrementally until the budget is exhausted
+	require.NoError(t, iMgr.ApplyIPSets())
+	require.Contains(t, iMgr.dirtyCache.memberDiff(TestNSSet.PrefixName).membersToAdd, "10.0.0.1")
+	require.NotContains(t, iMgr.setsToRebuild, TestNSSet.PrefixName)
+	require.NoError(t, iMgr.ApplyIPSets())
+	require.Contains(t, iMgr.setsToRebuild, TestNSSet.PrefixName)
+
+	// the rebuild flushes the set and adds all of its members
+	chunks := iMgr.chunksForApply(maxTryCount, iMgr.maxLinesPerRestore())
+	require.Len(t, chunks, 1)
+	require.True(t, strings.HasPrefix(chunks[0].creator.ToString(),
+		fmt.Sprintf("-F %s\n-X %s\n-N %s", TestNSSet.HashedName, TestNSSet.HashedName, TestNSSet.HashedName)))
+	require.Contains(t, chunks[0].creator.ToString(), fmt.Sprintf("-A %s 10.0.0.1", TestNSSet.HashedName))
+
+	calls = []testutils.TestCmd{fakeRestoreSuccessCommand}
+	ioshim = common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr.ioShim = ioshim
+	require.NoError(t, iMgr.ApplyIPSets())
+	require.Empty(t, iMgr.setsToRebuild)
+	require.Equal(t, 0, iMgr.applyFailures.Failures(TestNSSet.PrefixName))
+	require.Equal(t, 0, iMgr.dirtyCache.numSetsToAddOrUpdate())
+
+	newIncrementalFailures, err := metrics.GetDataplaneApplyCount(metrics.IPSetObject, metrics.IncrementalApply, true)
+	require.NoError(t, err)
+	require.Equal(t, incrementalFailures+2, newIncrementalFailures)
+	newRebuilds, err := metrics.GetDataplaneApplyCount(metrics.IPSetObject, metrics.RebuildApply, false)
+	require.NoError(t, err)
+	require.Equal(t, rebuilds+1, newRebuilds)
+}
+
+func TestAddIPv6MemberWithoutDualStack(t *testing.T) {
+	iMgr := NewIPSetManager(applyAlwaysCfg, common.NewMockIOShim(nil))
+	require.Error(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "fd00::1", "a"))
+}
+
 func TestUpdateWithIdenticalSaveFile(t *testing.T) {
 	calls := []testutils.TestCmd{fakeRestoreSuccessCommand}
 	ioshim := common.NewMockIOShim(calls)
@@ -569,6 +569,32 @@ func TestCreateAfterHardDelete(t *testing.T) {
 	})
 }
 
+func TestMatchesIP(t *testing.T) {
+	metrics.ReinitializeAll()
+	calls := []testutils.TestCmd{}
+	ioShim := common.NewMockIOShim(calls)
+	defer ioShim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(applyOnNeedCfg, ioShim)
+
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.1", "pod-a"))
+	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata}))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNamedportSet.Metadata}, NamedPortMember("10.0.0.1", "TCP", 80), "pod-a"))
+	for _, cidr := range []string{"10.0.0.0/8", "10.1.0.0/16 nomatch", "10.1.1.0/24"} {
+		require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestCIDRSet.Metadata}, cidr, ""))
+	}
+
+	require.True(t, iMgr.MatchesIP(TestNSSet.PrefixName, "10.0.0.1", "", 0))
+	require.False(t, iMgr.MatchesIP(TestNSSet.PrefixName, "10.0.0.2", "", 0))
+	require.True(t, iMgr.MatchesIP(TestKeyNSList.PrefixName, "10.0.0.1", "", 0))
+	require.True(t, iMgr.MatchesIP(TestNamedportSet.PrefixName, "10.0.0.1", "tcp", 80))
+	require.False(t, iMgr.MatchesIP(TestNamedportSet.PrefixName, "10.0.0.1", "udp", 80))
+	// the most specific CIDR matches
+	require.True(t, iMgr.MatchesIP(TestCIDRSet.PrefixName, "10.2.0.1", "", 0))
+	require.False(t, iMgr.MatchesIP(TestCIDRSet.PrefixName, "10.1.0.1", "", 0))
+	require.True(t, iMgr.MatchesIP(TestCIDRSet.PrefixName, "10.1.1.1", "", 0))
+	require.False(t, iMgr.MatchesIP("missing-set", "10.0.0.1", "", 0))
+}
+
 func TestDeleteIPSetNotAllowed(t *testing.T) {
 	// try to delete a list with a member and a set referenced in kernel (by a list)
 	// must use applyAlwaysCfg for set to be in kernel
@@ -1053,6 +1079,31 @@ func TestRemoveFromSets(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestAddToSetsWithGeneration(t *testing.T) {
+	iMgr := NewIPSetManager(applyAlwaysCfg, common.NewMockIOShim(nil))
+	nsSet := NewIPSetMetadata(testSetName, Namespace)
+	oldPodSet := NewIPSetMetadata("app:old", KeyValueLabelOfPod)
+
+	// the IP is reused by a newer pod before the events of the older pod are handled
+	require.NoError(t, iMgr.AddToSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/new", 2))
+	require.ErrorIs(t, iMgr.AddToSetsWithGeneration([]*IPSetMetadata{nsSet, oldPodSet}, testPodIP, "ns/old", 1), ErrStaleMember)
+	require.Equal(t, "ns/new", iMgr.GetIPSet(nsSet.GetPrefixName()).IPPodKey[testPodIP])
+	require.Nil(t, iMgr.GetIPSet(oldPodSet.GetPrefixName()))
+	require.NoError(t, iMgr.RemoveFromSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/old", 1))
+	require.Contains(t, iMgr.GetIPSet(nsSet.GetPrefixName()).IPPodKey, testPodIP)
+
+	// the pod is recreated with the same key and IP, e.g. a StatefulSet pod
+	require.NoError(t, iMgr.AddToSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/new", 3))
+	require.NoError(t, iMgr.RemoveFromSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/new", 2))
+	require.Contains(t, iMgr.GetIPSet(nsSet.GetPrefixName()).IPPodKey, testPodIP)
+
+	// the owner is forgotten once the member is removed from all sets
+	require.NoError(t, iMgr.RemoveFromSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/new", 3))
+	require.Empty(t, iMgr.GetIPSet(nsSet.GetPrefixName()).IPPodKey)
+	require.Empty(t, iMgr.podIPOwners)
+	require.NoError(t, iMgr.AddToSetsWithGeneration([]*IPSetMetadata{nsSet}, testPodIP, "ns/old", 1))
+}
+
 func TestRemoveFromSetMissing(t *testing.T) {
 	iMgr := NewIPSetManager(applyOnNeedCfg, common.NewMockIOShim([]testutils.TestCmd{}))
 	setMetadata := NewIPSetMetadata(testSetName, Namespace)
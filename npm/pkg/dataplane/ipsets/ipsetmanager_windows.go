@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/npm/util"
@@ -50,90 +51,6 @@ func (iMgr *IPSetManager) DoesIPSatisfySelectorIPSets(ip, podKey string, setList
 	return true, nil
 }
 
-// GetIPsFromSelectorIPSets will take in a map of prefixedSetNames and return an intersection of IPs mapped to pod key
-func (iMgr *IPSetManager) GetIPsFromSelectorIPSets(setList map[string]struct{}) (map[string]string, error) {
-	ips := make(map[string]string)
-	if len(setList) == 0 {
-		return ips, nil
-	}
-	iMgr.Lock()
-	defer iMgr.Unlock()
-
-	if err := iMgr.validateSelectorIPSets(setList); err != nil {
-		return nil, err
-	}
-
-	// the following is a space/time optimized way to get the intersection of IPs from the selector sets
-	// we should always take the hash set branch because a pod selector always includes a namespace ipset,
-	// which is a hash set, and we favor hash sets for firstSet
-	var firstSet *IPSet
-	for setName := range setList {
-		firstSet = iMgr.setMap[setName]
-		if firstSet.Kind == HashSet {
-			// firstSet can be any set, but ideally is a hash set for efficiency (compare the branch for hash sets to the one for lists below)
-			break
-		}
-	}
-	if firstSet.Kind == HashSet {
-		// include every IP in firstSet that is also affiliated with every other selector set
-		for ip, podKey := range firstSet.IPPodKey {
-			isAffiliated := true
-			for otherSetName := range setList {
-				if otherSetName == firstSet.Name {
-					continue
-				}
-				otherSet := iMgr.setMap[otherSetName]
-				if !otherSet.isIPAffiliated(ip, podKey) {
-					isAffiliated = false
-					break
-				}
-			}
-
-			if isAffiliated {
-				ips[ip] = podKey
-			}
-		}
-	} else {
-		// should never reach this branch (see note above)
-		// include every IP affiliated with firstSet that is also affiliated with every other selector set
-		// identical to the hash set case, except we have to make space for all IPs affiliated with firstSet
-
-		// only loop over the unique affiliated IPs
-		for _, memberSet := range firstSet.MemberIPSets {
-			for ip, podKey := range memberSet.IPPodKey {
-				if oldKey, ok := ips[ip]; ok && oldKey != podKey {
-					// this could lead to unintentionally considering this Pod (Pod B) to be part of the selector set if:
-					// 1. Pod B has the same IP as a previous Pod A
-					// 2. Pod B create is somehow processed before Pod A delete
-					// 3. This method is called before Pod A delete
-					// again, this
-					klog.Warningf("[GetIPsFromSelectorIPSets] IP currently associated with two different pod keys. to ensure no issues occur with network policies, restart this ip: %s", ip)
-				}
-				ips[ip] = podKey
-			}
-		}
-		for ip, podKey := range ips {
-			// identical to the hash set case
-			isAffiliated := true
-			for otherSetName := range setList {
-				if otherSetName == firstSet.Name {
-					continue
-				}
-				otherSet := iMgr.setMap[otherSetName]
-				if !otherSet.isIPAffiliated(ip, podKey) {
-					isAffiliated = false
-					break
-				}
-			}
-
-			if !isAffiliated {
-				delete(ips, ip)
-			}
-		}
-	}
-	return ips, nil
-}
-
 func (iMgr *IPSetManager) GetSelectorReferencesBySet(setName string) (map[string]struct{}, error) {
 	iMgr.Lock()
 	defer iMgr.Unlock()
@@ -151,25 +68,6 @@ func (iMgr *IPSetManager) GetSelectorReferencesBySet(setName string) (map[string
 	return m, nil
 }
 
-func (iMgr *IPSetManager) validateSelectorIPSets(setList map[string]struct{}) error {
-	for setName := range setList {
-		if !iMgr.exists(setName) {
-			return npmerrors.Errorf(
-				npmerrors.GetSelectorReference,
-				false,
-				fmt.Sprintf("[ipset manager] selector ipset %s does not exist", setName))
-		}
-		set := iMgr.setMap[setName]
-		if !set.canSetBeSelectorIPSet() {
-			return npmerrors.Errorf(
-				npmerrors.IPSetIntersection,
-				false,
-				fmt.Sprintf("[IPSet] Selector IPSet cannot be of type %s", set.Type.String()))
-		}
-	}
-	return nil
-}
-
 func (iMgr *IPSetManager) resetIPSets() error {
 	klog.Infof("[IPSetManager Windows] Resetting Dataplane")
 	network, err := iMgr.getHCnNetwork()
@@ -194,6 +92,33 @@ func (iMgr *IPSetManager) resetIPSets() error {
 	return nil
 }
 
+// cleanup lists the NPM SetPolicies of the network, and removes them unless dryRun is true.
+// There is nothing to clean up if the network doesn't exist.
+func (iMgr *IPSetManager) cleanup(dryRun bool) ([]string, error) {
+	network, err := iMgr.getHCnNetwork()
+	if err != nil {
+		var notFoundErr hcn.NetworkNotFoundError
+		if errors.As(err, &notFoundErr) {
+			klog.Infof("[IPSetManager Windows] No network %s to clean up", iMgr.iMgrCfg.NetworkName)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	_, toDeleteSets := iMgr.segregateSetPolicies(network.Policies, resetIPSetsTrue)
+	names := make([]string, 0, len(toDeleteSets))
+	for name := range toDeleteSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if dryRun || len(toDeleteSets) == 0 {
+		return names, nil
+	}
+
+	klog.Infof("[IPSetManager Windows] Cleaning up %d Set Policies", len(toDeleteSets))
+	return names, iMgr.modifySetPolicies(network, hcn.RequestTypeRemove, toDeleteSets)
+}
+
 func (iMgr *IPSetManager) applyIPSets() error {
 	network, err := iMgr.getHCnNetwork()
 	if err != nil {
@@ -0,0 +1,112 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/klog"
+)
+
+const (
+	// legacyDataplaneVersion is the naming scheme of the chains and ipsets of NPM v1.
+	legacyDataplaneVersion = 1
+	// currentDataplaneVersion is the naming scheme of the chains, ipsets and SetPolicies of NPM v2.
+	currentDataplaneVersion = 2
+)
+
+// MigrationMarker records the version of the dataplane NPM booted up, and the artifacts of an older version it removed.
+// It is kept on the host, so that it outlives the NPM Pod.
+type MigrationMarker struct {
+	Version int `json:"version"`
+	// MigratedFrom is the version of the dataplane found at bootup, zero if unknown.
+	MigratedFrom     int       `json:"migratedFrom,omitempty"`
+	RemovedArtifacts []string  `json:"removedArtifacts,omitempty"`
+	Time             time.Time `json:"time"`
+}
+
+// readMigrationMarker returns the marker at the path, or nil if there is none.
+func readMigrationMarker(path string) (*MigrationMarker, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration marker: %w", err)
+	}
+	marker := &MigrationMarker{}
+	if err := json.Unmarshal(b, marker); err != nil {
+		return nil, fmt.Errorf("failed to decode migration marker %s: %w", path, err)
+	}
+	return marker, nil
+}
+
+// writeMigrationMarker replaces the marker at the path atomically.
+func writeMigrationMarker(path string, marker *MigrationMarker) error {
+	b, err := json.Marshal(marker)
+	if err != nil {
+		return fmt.Errorf("failed to encode migration marker: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write migration marker: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write migration marker: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write migration marker: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write migration marker: %w", err)
+	}
+	return nil
+}
+
+// migrateDataplane removes the artifacts of older NPM versions which survive the bootup reset, before any current state
+// is applied, and records the migration in the marker at the MigrationMarkerPath.
+// The marker is only rewritten when the version of the dataplane changes, and isn't written if the removal fails, so that
+// the next bootup migrates again. A failure doesn't fail the bootup: the artifacts left are retried by reconcile.
+func (dp *DataPlane) migrateDataplane() {
+	var marker *MigrationMarker
+	if dp.MigrationMarkerPath != "" {
+		var err error
+		if marker, err = readMigrationMarker(dp.MigrationMarkerPath); err != nil {
+			metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "warning: ignoring migration marker: %s", err.Error())
+		}
+	}
+
+	removed, err := dp.policyMgr.RemoveLegacyChains()
+	artifacts := make([]string, 0, len(removed))
+	for _, chain := range removed {
+		artifacts = append(artifacts, "chain "+chain)
+	}
+	if err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID,
+			"warning: failed to remove legacy dataplane artifacts, reconcile retries them. removed: %v. err: %s", artifacts, err.Error())
+		return
+	}
+
+	if dp.MigrationMarkerPath == "" || (marker != nil && marker.Version == currentDataplaneVersion && len(artifacts) == 0) {
+		return
+	}
+
+	newMarker := &MigrationMarker{Version: currentDataplaneVersion, RemovedArtifacts: artifacts, Time: time.Now().UTC()}
+	switch {
+	case len(artifacts) > 0:
+		newMarker.MigratedFrom = legacyDataplaneVersion
+	case marker != nil:
+		newMarker.MigratedFrom = marker.Version
+	}
+	klog.Infof("[DataPlane] migrated dataplane from version %d to %d. removed artifacts: %v", newMarker.MigratedFrom, newMarker.Version, artifacts)
+	if err := writeMigrationMarker(dp.MigrationMarkerPath, newMarker); err != nil {
+		metrics.SendErrorLogAndMetric(util.DaemonDataplaneID, "warning: %s", err.Error())
+	}
+}
@@ -0,0 +1,52 @@
+package dataplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDataplane(t *testing.T) {
+	metrics.InitializeAll()
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	path := filepath.Join(t.TempDir(), "azure-npm-dataplane.json")
+	dp := &DataPlane{
+		Config:    &Config{MigrationMarkerPath: path, PolicyManagerCfg: dpCfg.PolicyManagerCfg},
+		policyMgr: policies.NewPolicyManager(ioshim, dpCfg.PolicyManagerCfg),
+	}
+
+	// a marker from an older version is replaced
+	require.NoError(t, writeMigrationMarker(path, &MigrationMarker{Version: legacyDataplaneVersion}))
+	dp.migrateDataplane()
+	marker, err := readMigrationMarker(path)
+	require.NoError(t, err)
+	require.Equal(t, currentDataplaneVersion, marker.Version)
+	require.Equal(t, legacyDataplaneVersion, marker.MigratedFrom)
+	require.Empty(t, marker.RemovedArtifacts)
+
+	// the marker isn't rewritten while the version doesn't change
+	dp.migrateDataplane()
+	unchanged, err := readMigrationMarker(path)
+	require.NoError(t, err)
+	require.Equal(t, marker.Time, unchanged.Time)
+
+	// a corrupt marker is replaced
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	dp.migrateDataplane()
+	marker, err = readMigrationMarker(path)
+	require.NoError(t, err)
+	require.Equal(t, currentDataplaneVersion, marker.Version)
+	require.Zero(t, marker.MigratedFrom)
+}
+
+func TestReadMigrationMarkerMissing(t *testing.T) {
+	marker, err := readMigrationMarker(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	require.Nil(t, marker)
+}
@@ -134,6 +134,20 @@ func (mr *MockGenericDataplaneMockRecorder) DeleteIPSet(setMetadata, deleteOptio
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIPSet", reflect.TypeOf((*MockGenericDataplane)(nil).DeleteIPSet), setMetadata, deleteOption)
 }
 
+// FinishBootupPhase mocks base method.
+func (m *MockGenericDataplane) FinishBootupPhase() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishBootupPhase")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishBootupPhase indicates an expected call of FinishBootupPhase.
+func (mr *MockGenericDataplaneMockRecorder) FinishBootupPhase() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishBootupPhase", reflect.TypeOf((*MockGenericDataplane)(nil).FinishBootupPhase))
+}
+
 // GetAllIPSets mocks base method.
 func (m *MockGenericDataplane) GetAllIPSets() map[string]string {
 	m.ctrl.T.Helper()
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -47,6 +48,33 @@ var (
 	}
 	jumpFromForwardToAzureChainArgs = append([]string{util.IptablesForwardChain}, jumpToAzureChainArgs...)
 
+	// hostJumpArgs are the jumps for EnforceOnHostNetwork. Traffic to the node only goes through ingress rules,
+	// and traffic from the node only through egress rules, so that e.g. pods reaching their node aren't newly subject
+	// to their egress policies, and the node reaching pods isn't newly subject to their ingress policies.
+	hostJumpArgs = [][]string{
+		{
+			util.IptablesInputChain, util.IptablesJumpFlag, util.IptablesAzureIngressChain,
+			util.IptablesModuleFlag, util.IptablesCtstateModuleFlag, util.IptablesCtstateFlag, util.IptablesNewState,
+		},
+		{
+			util.IptablesOutputChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain,
+			util.IptablesModuleFlag, util.IptablesCtstateModuleFlag, util.IptablesCtstateFlag, util.IptablesNewState,
+		},
+	}
+
+	hostJumpIgnoredErrors = []*exitErrorInfo{
+		{
+			exitCode:     doesNotExistErrorCode,
+			stdErr:       "does a matching rule exist",
+			messageToLog: "didn't find jump rule from INPUT/OUTPUT chain to Azure chains",
+		},
+		{
+			exitCode:     couldntLoadTargetErrorCode,
+			stdErr:       "Couldn't load target",
+			messageToLog: "didn't find jump rule from INPUT/OUTPUT chain to Azure chains because the Azure chain doesn't exist",
+		},
+	}
+
 	removeDeprecatedJumpIgnoredErrors = []*exitErrorInfo{
 		{
 			// doesNotExistErrorCode happens when AZURE-NPM chain exists, but this jump rule doesn't exist
@@ -62,6 +90,23 @@ var (
 		},
 	}
 
+	// legacyChains are the chains of NPM v1, including the ones removed in its later versions.
+	legacyChains = []string{
+		util.IptablesAzureKubeSystemChain,
+		util.IptablesAzureIngressPortChain,
+		util.IptablesAzureIngressFromChain,
+		util.IptablesAzureEgressPortChain,
+		util.IptablesAzureEgressToChain,
+		util.IptablesAzureIngressDropsChain,
+		util.IptablesAzureEgressDropsChain,
+		util.IptablesAzureTargetSetsChain,
+		util.IptablesAzureIngressWrongDropsChain,
+		util.IptablesAzureIngressFromNsChain,
+		util.IptablesAzureIngressFromPodChain,
+		util.IptablesAzureEgressToNsChain,
+		util.IptablesAzureEgressToPodChain,
+	}
+
 	listForwardEntriesArgs = []string{
 		util.IptablesWaitFlag, util.IptablesDefaultWaitTime, util.IptablesTableFlag, util.IptablesFilterTable,
 		util.IptablesNumericFlag, util.IptablesListFlag, util.IptablesForwardChain, util.IptablesLineNumbersFlag,
@@ -76,6 +121,15 @@ var (
 	}
 )
 
+// jumpRepair is why the jump from FORWARD chain to AZURE-NPM chain was repaired.
+type jumpRepair string
+
+const (
+	noJumpRepair  jumpRepair = ""
+	jumpMissing   jumpRepair = "missing"
+	jumpMisplaced jumpRepair = "misplaced"
+)
+
 type exitErrorInfo struct {
 	exitCode     int
 	stdErr       string
@@ -116,6 +170,11 @@ func (s *staleChains) add(chain string) {
 	}
 }
 
+func (s *staleChains) has(chain string) bool {
+	_, ok := s.chainsToCleanup[chain]
+	return ok
+}
+
 func (s *staleChains) remove(chain string) {
 	delete(s.chainsToCleanup, chain)
 }
@@ -162,6 +221,7 @@ Like the rest of PolicyManager, minimizes the number of OS calls by consolidatin
     - delete old v2 policy chains
 
 3. Add/reposition the jump from FORWARD chain to AZURE-NPM chain.
+4. If EnforceOnHostNetwork is set, add the jumps from INPUT and OUTPUT chains to the Azure ingress and egress chains.
 
 TODO: could use one grep call instead of separate calls for getting jump line nums and for getting deprecated chains and old v2 policy chains
   - would use a grep pattern like so: <line num...AZURE-NPM>|<Chain AZURE-NPM>
@@ -174,13 +234,14 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 	pMgr.reconcileManager.forceLock()
 	defer pMgr.reconcileManager.forceUnlock()
 
-	if strings.Contains(util.Iptables, "nft") {
+	// legacy iptables is kept when mirroring, and the bootup of the mirror families resets its chains instead
+	if strings.Contains(util.Iptables, "nft") && !pMgr.MirrorIptablesBackends {
 		util.Iptables = util.IptablesLegacy
 		util.IptablesSave = util.IptablesSaveLegacy
 		util.IptablesRestore = util.IptablesRestoreLegacy
 
 		// 0. delete the deprecated jump to deprecated AZURE-NPM in legacy iptables
-		deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
+		deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(ipv4Family, removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
 		if deprecatedErrCode == 0 {
 			klog.Infof("deleted deprecated jump rule from FORWARD chain to AZURE-NPM chain")
 		} else if deprecatedErr != nil {
@@ -190,7 +251,7 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 		}
 
 		// 0. delete the deprecated jump to current AZURE-NPM in legacy iptables
-		deprecatedErrCode, deprecatedErr = pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...)
+		deprecatedErrCode, deprecatedErr = pMgr.ignoreErrorsAndRunIPTablesCommand(ipv4Family, removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...)
 		if deprecatedErrCode == 0 {
 			klog.Infof("deleted deprecated jump rule from FORWARD chain to AZURE-NPM chain")
 		} else if deprecatedErr != nil {
@@ -209,7 +270,7 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 		// So flush all the chains and then destroy them
 		var aggregateError error
 		for chain := range currentChains {
-			errCode, err := pMgr.runIPTablesCommand(util.IptablesFlushFlag, chain)
+			errCode, err := pMgr.runIPTablesCommand(ipv4Family, util.IptablesFlushFlag, chain)
 			if err != nil && errCode != doesNotExistErrorCode {
 				// add to staleChains if it's not one of the iptablesAzureChains
 				pMgr.staleChains.add(chain)
@@ -223,7 +284,7 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 		}
 
 		for chain := range currentChains {
-			errCode, err := pMgr.runIPTablesCommand(util.IptablesDestroyFlag, chain)
+			errCode, err := pMgr.runIPTablesCommand(ipv4Family, util.IptablesDestroyFlag, chain)
 			if err != nil && errCode != doesNotExistErrorCode {
 				// add to staleChains if it's not one of the iptablesAzureChains
 				pMgr.staleChains.add(chain)
@@ -248,7 +309,7 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 	}
 
 	// 1. delete the deprecated jump to AZURE-NPM
-	deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
+	deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(ipv4Family, removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
 	if deprecatedErrCode == 0 {
 		klog.Infof("deleted deprecated jump rule from FORWARD chain to AZURE-NPM chain")
 	} else if deprecatedErr != nil {
@@ -264,28 +325,175 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 
 	// 2. cleanup old NPM chains, and configure base chains and their rules.
 	creator := pMgr.creatorForBootup(currentChains)
-	if err := restore(creator); err != nil {
+	if err := restore(ipv4Family, creator); err != nil {
 		return npmerrors.SimpleErrorWrapper("failed to run iptables-restore for bootup", err)
 	}
 
 	// 3. add/reposition the jump to AZURE-NPM
-	if err := pMgr.positionAzureChainJumpRule(); err != nil {
+	if _, err := pMgr.positionAzureChainJumpRule(ipv4Family); err != nil {
 		baseErrString := "failed to add/reposition jump from FORWARD chain to AZURE-NPM chain"
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error: %s", baseErrString, err.Error())
 		return npmerrors.SimpleErrorWrapper(baseErrString, err) // we used to ignore this error in v1
 	}
+
+	if pMgr.EnforceOnHostNetwork {
+		if _, err := pMgr.addMissingHostJumpRules(ipv4Family); err != nil {
+			return err
+		}
+	}
+
+	for _, family := range pMgr.families()[1:] {
+		if err := pMgr.bootupFamily(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bootupFamily repeats steps 2 to 4 of bootup() for ip6tables and the mirror families.
+// NPM never programmed ip6tables before dual-stack support, so there are no deprecated jumps or legacy chains to clean up
+// in ip6tables. The mirror of iptables may have the deprecated jump if NPM v1 ran with that backend.
+func (pMgr *PolicyManager) bootupFamily(family ipFamily) error {
+	if family == ipv4MirrorFamily {
+		deprecatedErrCode, deprecatedErr := pMgr.ignoreErrorsAndRunIPTablesCommand(family, removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, deprecatedJumpFromForwardToAzureChainArgs...)
+		if deprecatedErrCode == 0 {
+			klog.Infof("deleted deprecated %s jump rule from FORWARD chain to AZURE-NPM chain", family)
+		} else if deprecatedErr != nil {
+			metrics.SendErrorLogAndMetric(util.IptmID,
+				"failed to delete deprecated %s jump rule from FORWARD chain to AZURE-NPM chain for unexpected reason with exit code %d and error: %s",
+				family, deprecatedErrCode, deprecatedErr.Error())
+		}
+	}
+
+	currentChains, err := ioutil.AllCurrentAzureChainsWithCommand(pMgr.ioShim.Exec, family.iptables(), util.IptablesDefaultWaitTime)
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to get current %s chains for bootup", family), err)
+	}
+
+	// creatorForBootup resets the stale chains, so keep the ones found in the previous families.
+	// Stale chains are deleted from all families.
+	staleChains := pMgr.staleChains.emptyAndGetAll()
+	creator := pMgr.creatorForBootup(currentChains)
+	for _, chain := range staleChains {
+		pMgr.staleChains.add(chain)
+	}
+	if err := restore(family, creator); err != nil {
+		return npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to run %s restore for bootup", family), err)
+	}
+
+	if _, err := pMgr.positionAzureChainJumpRule(family); err != nil {
+		baseErrString := fmt.Sprintf("failed to add/reposition %s jump from FORWARD chain to AZURE-NPM chain", family)
+		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error: %s", baseErrString, err.Error())
+		return npmerrors.SimpleErrorWrapper(baseErrString, err)
+	}
+
+	if pMgr.EnforceOnHostNetwork {
+		_, err := pMgr.addMissingHostJumpRules(family)
+		return err
+	}
 	return nil
 }
 
+// addMissingHostJumpRules adds the jumps from INPUT and OUTPUT chains to the Azure chains if they're missing.
+// Unlike the jump from FORWARD chain, they aren't repositioned: they're only inserted first when missing.
+// Returns whether any jump was missing.
+func (pMgr *PolicyManager) addMissingHostJumpRules(family ipFamily) (bool, error) {
+	repaired := false
+	for _, args := range hostJumpArgs {
+		errCode, err := pMgr.ignoreErrorsAndRunIPTablesCommand(family, hostJumpIgnoredErrors, util.IptablesCheckFlag, args...)
+		if err != nil {
+			baseErrString := fmt.Sprintf("failed to check %s jump from %s chain", family, args[0])
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error code %d and error %s", baseErrString, errCode, err.Error())
+			return repaired, npmerrors.SimpleErrorWrapper(baseErrString, err)
+		}
+		if errCode == 0 {
+			continue
+		}
+
+		klog.Infof("Inserting %s jump from %s chain to %s chain", family, args[0], args[2])
+		if insertErrCode, err := pMgr.runIPTablesCommand(family, util.IptablesInsertionFlag, args...); err != nil {
+			baseErrString := fmt.Sprintf("failed to insert %s jump from %s chain", family, args[0])
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error code %d and error %s", baseErrString, insertErrCode, err.Error())
+			return repaired, npmerrors.SimpleErrorWrapper(baseErrString, err)
+		}
+		repaired = true
+	}
+	return repaired, nil
+}
+
+/*
+cleanup removes everything NPM programmed in iptables (and ip6tables if IPv6 is enabled), leaving NPM uninstalled:
+1. Delete the jumps from FORWARD chain to AZURE-NPM chain (including the deprecated one), and from INPUT and OUTPUT chains.
+2. Flush all Azure chains, then delete them, in one restore file.
+
+Unlike bootup, nothing is marked stale, since reconcile() won't run after cleanup.
+*/
+func (pMgr *PolicyManager) cleanup(_ []string, dryRun bool) ([]string, error) {
+	klog.Infof("cleaning up iptables Azure chains. dry run: %t", dryRun)
+
+	pMgr.reconcileManager.forceLock()
+	defer pMgr.reconcileManager.forceUnlock()
+
+	artifacts := make([]string, 0)
+	for _, family := range pMgr.families() {
+		currentChains, err := ioutil.AllCurrentAzureChainsWithCommand(pMgr.ioShim.Exec, family.iptables(), util.IptablesDefaultWaitTime)
+		if err != nil {
+			return artifacts, npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to get current %s chains for cleanup", family), err)
+		}
+		chains := make([]string, 0, len(currentChains))
+		for chain := range currentChains {
+			chains = append(chains, chain)
+		}
+		sort.Strings(chains)
+		for _, chain := range chains {
+			artifacts = append(artifacts, fmt.Sprintf("%s chain %s", family, chain))
+		}
+		if dryRun || len(chains) == 0 {
+			continue
+		}
+
+		// 1. delete the jumps to AZURE-NPM. The chain can't be deleted while it is referenced,
+		// so a failure here surfaces as a failure of the restore below.
+		for _, jumpArgs := range [][]string{jumpFromForwardToAzureChainArgs, deprecatedJumpFromForwardToAzureChainArgs} {
+			errCode, err := pMgr.ignoreErrorsAndRunIPTablesCommand(family, removeDeprecatedJumpIgnoredErrors, util.IptablesDeletionFlag, jumpArgs...)
+			if errCode == 0 {
+				klog.Infof("deleted %s jump rule from FORWARD chain to AZURE-NPM chain: %v", family, jumpArgs)
+			} else if err != nil {
+				klog.Infof("didn't delete %s jump rule from FORWARD chain to AZURE-NPM chain %v with exit code %d: %s", family, jumpArgs, errCode, err.Error())
+			}
+		}
+
+		// the jumps for EnforceOnHostNetwork, whether it's enabled or not, since it may have been enabled before
+		for _, jumpArgs := range hostJumpArgs {
+			errCode, err := pMgr.ignoreErrorsAndRunIPTablesCommand(family, hostJumpIgnoredErrors, util.IptablesDeletionFlag, jumpArgs...)
+			if errCode == 0 {
+				klog.Infof("deleted %s jump rule from %s chain: %v", family, jumpArgs[0], jumpArgs)
+			} else if err != nil {
+				klog.Infof("didn't delete %s jump rule from %s chain %v with exit code %d: %s", family, jumpArgs[0], jumpArgs, errCode, err.Error())
+			}
+		}
+
+		// 2. flush all chains first, since the chains may jump to each other
+		creator := pMgr.newCreatorWithChains(nil)
+		for _, chain := range chains {
+			creator.AddLine("", nil, util.IptablesFlushFlag, chain)
+		}
+		for _, chain := range chains {
+			creator.AddLine("", nil, util.IptablesDestroyFlag, chain)
+		}
+		creator.AddLine("", nil, util.IptablesRestoreCommit)
+		if err := restore(family, creator); err != nil {
+			return artifacts, npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to run %s restore for cleanup", family), err)
+		}
+	}
+	return artifacts, nil
+}
+
 // reconcile does the following:
 // - creates the jump rule from FORWARD chain to AZURE-NPM chain (if it does not exist) and makes sure it's after the jumps to KUBE-FORWARD & KUBE-SERVICES chains (if they exist).
 // - cleans up stale policy chains. It can be forced to stop this process if reconcileManager.forceLock() is called.
 func (pMgr *PolicyManager) reconcile() {
-	if err := pMgr.positionAzureChainJumpRule(); err != nil {
-		msg := fmt.Sprintf("failed to reconcile jump rule to Azure-NPM due to %s", err.Error())
-		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
-		klog.Error(msg)
-	}
+	pMgr.checkChainIntegrity()
 
 	pMgr.reconcileManager.Lock()
 	defer pMgr.reconcileManager.Unlock()
@@ -303,6 +511,40 @@ func (pMgr *PolicyManager) reconcile() {
 	}
 }
 
+// checkChainIntegrity repairs the jump from FORWARD chain to AZURE-NPM chain in each family if it's missing or misplaced,
+// e.g. after a firewall manager or kube-proxy restart rewrote the FORWARD chain. Each repair is counted in a metric.
+// With EnforceOnHostNetwork, it also adds the jumps from INPUT and OUTPUT chains if they're missing.
+// It doesn't lock the policy manager since it only touches the FORWARD, INPUT and OUTPUT chains.
+func (pMgr *PolicyManager) checkChainIntegrity() {
+	for _, family := range pMgr.families() {
+		repair, err := pMgr.positionAzureChainJumpRule(family)
+		if err != nil {
+			msg := fmt.Sprintf("failed to reconcile %s jump rule to Azure-NPM due to %s", family, err.Error())
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
+			klog.Error(msg)
+			continue
+		}
+		if repair != noJumpRepair {
+			metrics.SendErrorLogAndMetric(util.IptmID, "Info: repaired %s jump from FORWARD chain to AZURE-NPM chain since it was %s", family, repair)
+			metrics.RecordIPTablesJumpRepair(string(family), string(repair))
+		}
+
+		if !pMgr.EnforceOnHostNetwork {
+			continue
+		}
+		repaired, err := pMgr.addMissingHostJumpRules(family)
+		if err != nil {
+			msg := fmt.Sprintf("failed to reconcile %s jump rules from INPUT and OUTPUT chains due to %s", family, err.Error())
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
+			klog.Error(msg)
+			continue
+		}
+		if repaired {
+			metrics.SendErrorLogAndMetric(util.IptmID, "Info: repaired %s jump from INPUT or OUTPUT chain to Azure chains since it was missing", family)
+		}
+	}
+}
+
 // cleanupChains deletes all the chains in the given list.
 // If a chain fails to delete and it isn't one of the iptablesAzureChains, then it is added to the staleChains.
 // This is a separate function for with a slice argument so that UTs can have deterministic behavior for ioshim.
@@ -318,15 +560,17 @@ deleteLoop:
 			}
 			break deleteLoop
 		default:
-			errCode, err := pMgr.runIPTablesCommand(util.IptablesDestroyFlag, chain)
-			if err != nil && errCode != doesNotExistErrorCode {
-				// add to staleChains if it's not one of the iptablesAzureChains
-				pMgr.staleChains.add(chain)
-				currentErrString := fmt.Sprintf("failed to clean up chain %s with err [%v]", chain, err)
-				if aggregateError == nil {
-					aggregateError = npmerrors.SimpleError(currentErrString)
-				} else {
-					aggregateError = npmerrors.SimpleErrorWrapper(fmt.Sprintf("%s and had previous error", currentErrString), aggregateError)
+			for _, family := range pMgr.families() {
+				errCode, err := pMgr.runIPTablesCommand(family, util.IptablesDestroyFlag, chain)
+				if err != nil && errCode != doesNotExistErrorCode {
+					// add to staleChains if it's not one of the iptablesAzureChains
+					pMgr.staleChains.add(chain)
+					currentErrString := fmt.Sprintf("failed to clean up %s chain %s with err [%v]", family, chain, err)
+					if aggregateError == nil {
+						aggregateError = npmerrors.SimpleError(currentErrString)
+					} else {
+						aggregateError = npmerrors.SimpleErrorWrapper(fmt.Sprintf("%s and had previous error", currentErrString), aggregateError)
+					}
 				}
 			}
 		}
@@ -337,18 +581,47 @@ deleteLoop:
 	return nil
 }
 
+// removeLegacyChains deletes the legacy chains among the stale chains found at bootup, in every family. The chains which
+// fail to be deleted stay stale, and are retried by reconcile().
+func (pMgr *PolicyManager) removeLegacyChains() ([]string, error) {
+	pMgr.reconcileManager.Lock()
+	defer pMgr.reconcileManager.Unlock()
+
+	found := make([]string, 0)
+	for _, chain := range legacyChains {
+		if pMgr.staleChains.has(chain) {
+			found = append(found, chain)
+			pMgr.staleChains.remove(chain)
+		}
+	}
+	if len(found) == 0 {
+		return found, nil
+	}
+
+	klog.Infof("removing legacy chains: %+v", found)
+	err := pMgr.cleanupChains(found)
+	removed := make([]string, 0, len(found))
+	for _, chain := range found {
+		if !pMgr.staleChains.has(chain) {
+			removed = append(removed, chain)
+		}
+	}
+	return removed, err
+}
+
 // this function has a direct comparison in NPM v1 iptables manager (iptm.go)
-func (pMgr *PolicyManager) runIPTablesCommand(operationFlag string, args ...string) (int, error) {
-	return pMgr.ignoreErrorsAndRunIPTablesCommand(nil, operationFlag, args...)
+func (pMgr *PolicyManager) runIPTablesCommand(family ipFamily, operationFlag string, args ...string) (int, error) {
+	return pMgr.ignoreErrorsAndRunIPTablesCommand(family, nil, operationFlag, args...)
 }
 
-func (pMgr *PolicyManager) ignoreErrorsAndRunIPTablesCommand(ignored []*exitErrorInfo, operationFlag string, args ...string) (int, error) {
+func (pMgr *PolicyManager) ignoreErrorsAndRunIPTablesCommand(family ipFamily, ignored []*exitErrorInfo, operationFlag string, args ...string) (int, error) {
 	allArgs := []string{util.IptablesWaitFlag, util.IptablesDefaultWaitTime, operationFlag}
 	allArgs = append(allArgs, args...)
+	iptables := family.iptables()
 
-	klog.Infof("Executing iptables command with args %v", allArgs)
+	klog.Infof("Executing %s command with args %v", iptables, allArgs)
 
-	command := pMgr.ioShim.Exec.Command(util.Iptables, allArgs...)
+	command := pMgr.ioShim.Exec.Command(iptables, allArgs...)
 	output, err := command.CombinedOutput()
 
 	var exitError utilexec.ExitError
@@ -358,14 +631,14 @@ func (pMgr *PolicyManager) ignoreErrorsAndRunIPTablesCommand(ignored []*exitErro
 		outputString := strings.TrimSuffix(string(output), "\n")
 		for _, info := range ignored {
 			if errCode == info.exitCode && strings.Contains(outputString, info.stdErr) {
-				klog.Infof("%s. not able to run iptables command [%s %s]. exit code: %d, output: %s", info.messageToLog, util.Iptables, allArgsString, errCode, outputString)
+				klog.Infof("%s. not able to run iptables command [%s %s]. exit code: %d, output: %s", info.messageToLog, iptables, allArgsString, errCode, outputString)
 				return errCode, nil
 			}
 		}
 		if errCode > 0 {
-			metrics.SendErrorLogAndMetric(util.IptmID, "error: There was an error running command: [%s %s] Stderr: [%v, %s]", util.Iptables, allArgsString, exitError, outputString)
+			metrics.SendErrorLogAndMetric(util.IptmID, "error: There was an error running command: [%s %s] Stderr: [%v, %s]", iptables, allArgsString, exitError, outputString)
 		}
-		return errCode, npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to run iptables command [%s %s] Stderr: [%s]", util.Iptables, allArgsString, outputString), exitError)
+		return errCode, npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to run iptables command [%s %s] Stderr: [%s]", iptables, allArgsString, outputString), exitError)
 	}
 	return 0, nil
 }
@@ -424,28 +697,29 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 // add/reposition the jump from FORWARD chain to AZURE-NPM chain to be in the correct position based on config:
 // option 1) jump to AZURE-NPM chain should be the first rule
 // option 2) jump to AZURE-NPM chain should be after the jump to KUBE-SERVICES chain
-func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
+// Returns whether the jump was missing or misplaced, or noJumpRepair if it was already in the correct position.
+func (pMgr *PolicyManager) positionAzureChainJumpRule(family ipFamily) (jumpRepair, error) {
 	// get the line number for the azure jump
-	azureChainLineNum, err := pMgr.chainLineNumber(util.IptablesAzureChain)
+	azureChainLineNum, err := pMgr.chainLineNumber(family, util.IptablesAzureChain)
 	if err != nil {
 		baseErrString := "failed to get index of jump from FORWARD chain to AZURE-NPM chain"
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s: %s", baseErrString, err.Error())
-		return npmerrors.SimpleErrorWrapper(baseErrString, err)
+		return noJumpRepair, npmerrors.SimpleErrorWrapper(baseErrString, err)
 	}
 
 	if pMgr.PlaceAzureChainFirst == util.PlaceAzureChainFirst && azureChainLineNum == 1 {
 		// the azure jump is in the right position, so we're done
-		return nil
+		return noJumpRepair, nil
 	}
 
 	// place the azure jump in the first position, unless we want option 2 above and the kube jump exists
 	targetIndex := 1
 	if pMgr.PlaceAzureChainFirst == util.PlaceAzureChainAfterKubeServices {
-		kubeChainLineNum, err := pMgr.chainLineNumber(util.IptablesKubeServicesChain)
+		kubeChainLineNum, err := pMgr.chainLineNumber(family, util.IptablesKubeServicesChain)
 		if err != nil {
 			baseErrString := "failed to get index of jump from FORWARD chain to KUBE-SERVICES chain"
 			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s: %s", baseErrString, err.Error())
-			return npmerrors.SimpleErrorWrapper(baseErrString, err)
+			return noJumpRepair, npmerrors.SimpleErrorWrapper(baseErrString, err)
 		}
 
 		if kubeChainLineNum != 0 {
@@ -457,16 +731,18 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 
 	if azureChainLineNum == targetIndex {
 		// the azure jump is in the right position, so we're done
-		return nil
+		return noJumpRepair, nil
 	}
 
 	// delete the azure jump if it exists and update the target index
+	repair := jumpMissing
 	if azureChainLineNum != 0 {
+		repair = jumpMisplaced
 		metrics.SendErrorLogAndMetric(util.IptmID, "Info: Reconciler deleting and re-adding jump from FORWARD chain to AZURE-NPM chain table.")
-		if deleteErrCode, deleteErr := pMgr.runIPTablesCommand(util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...); deleteErr != nil {
+		if deleteErrCode, deleteErr := pMgr.runIPTablesCommand(family, util.IptablesDeletionFlag, jumpFromForwardToAzureChainArgs...); deleteErr != nil {
 			baseErrString := "failed to delete jump from FORWARD chain to AZURE-NPM chain"
 			metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error code %d and error %s", baseErrString, deleteErrCode, deleteErr.Error())
-			return npmerrors.SimpleErrorWrapper(baseErrString, deleteErr)
+			return noJumpRepair, npmerrors.SimpleErrorWrapper(baseErrString, deleteErr)
 		}
 
 		if azureChainLineNum < targetIndex {
@@ -487,18 +763,18 @@ func (pMgr *PolicyManager) positionAzureChainJumpRule() error {
 		args = []string{util.IptablesForwardChain, strconv.Itoa(targetIndex)}
 		args = append(args, jumpToAzureChainArgs...)
 	}
-	if insertErrCode, err := pMgr.runIPTablesCommand(util.IptablesInsertionFlag, args...); err != nil {
+	if insertErrCode, err := pMgr.runIPTablesCommand(family, util.IptablesInsertionFlag, args...); err != nil {
 		baseErrString := "failed to insert jump from FORWARD chain to AZURE-NPM chain"
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s with error code %d and error %s", baseErrString, insertErrCode, err.Error())
-		return npmerrors.SimpleErrorWrapper(baseErrString, err)
+		return noJumpRepair, npmerrors.SimpleErrorWrapper(baseErrString, err)
 	}
-	return nil
+	return repair, nil
 }
 
 // returns 0 if the chain does not exist
 // this function has a direct comparison in NPM v1 iptables manager (iptm.go)
-func (pMgr *PolicyManager) chainLineNumber(chain string) (int, error) {
-	listForwardEntriesCommand := pMgr.ioShim.Exec.Command(util.Iptables, listForwardEntriesArgs...)
+func (pMgr *PolicyManager) chainLineNumber(family ipFamily, chain string) (int, error) {
+	listForwardEntriesCommand := pMgr.ioShim.Exec.Command(family.iptables(), listForwardEntriesArgs...)
 	grepCommand := pMgr.ioShim.Exec.Command(ioutil.Grep, chain)
 	searchResults, gotMatches, err := ioutil.PipeCommandToGrep(listForwardEntriesCommand, grepCommand)
 	if err != nil {
@@ -187,6 +187,101 @@ func TestCleanupChainsFailure(t *testing.T) {
 	assertStaleChainsContain(t, pMgr.staleChains, testChain1, testChain3)
 }
 
+func TestRemoveLegacyChains(t *testing.T) {
+	calls := []testutils.TestCmd{
+		getFakeDestroyCommand(util.IptablesAzureKubeSystemChain),
+		getFakeDestroyCommandWithExitCode(util.IptablesAzureIngressPortChain, 2),
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	// the v2 policy chains are left to reconcile
+	pMgr.staleChains.add(testChain1)
+	pMgr.staleChains.add(util.IptablesAzureIngressPortChain)
+	pMgr.staleChains.add(util.IptablesAzureKubeSystemChain)
+	removed, err := pMgr.RemoveLegacyChains()
+	require.Error(t, err)
+	require.Equal(t, []string{util.IptablesAzureKubeSystemChain}, removed)
+	assertStaleChainsContain(t, pMgr.staleChains, testChain1, util.IptablesAzureIngressPortChain)
+}
+
+func TestCleanupChainsDualStack(t *testing.T) {
+	ip6tablesDestroyCommand := testutils.TestCmd{Cmd: []string{ipv6Family.iptables(), "-w", "60", "-X", testChain1}, ExitCode: 2}
+	calls := []testutils.TestCmd{
+		getFakeDestroyCommand(testChain1),
+		ip6tablesDestroyCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, EnableIPv6: true})
+
+	pMgr.staleChains.add(testChain1)
+	require.Error(t, pMgr.cleanupChains(pMgr.staleChains.emptyAndGetAll()))
+	assertStaleChainsContain(t, pMgr.staleChains, testChain1)
+}
+
+func TestBootupIPv6(t *testing.T) {
+	ip6tables := ipv6Family.iptables()
+	calls := []testutils.TestCmd{
+		{Cmd: []string{ip6tables, "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: "Chain AZURE-NPM-INGRESS-123456 (1 references)\n"},
+		{Cmd: []string{ipv6Family.iptablesRestore(), "-w", "60", "-T", "filter", "--noflush"}},
+		{Cmd: []string{ip6tables, "-w", "60", "-t", "filter", "-n", "-L", "FORWARD", "--line-numbers"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+		{Cmd: []string{ip6tables, "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, PlaceAzureChainFirst: util.PlaceAzureChainFirst, EnableIPv6: true})
+
+	// stale chains from iptables are kept
+	pMgr.staleChains.add(testChain1)
+	require.NoError(t, pMgr.bootupFamily(ipv6Family))
+	assertStaleChainsContain(t, pMgr.staleChains, testChain1, "AZURE-NPM-INGRESS-123456")
+}
+
+func TestBootupMirror(t *testing.T) {
+	iptables := ipv4MirrorFamily.iptables()
+	require.NotEqual(t, ipv4Family.iptables(), iptables)
+	calls := []testutils.TestCmd{
+		{Cmd: []string{iptables, "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}, ExitCode: 2},
+		{Cmd: []string{iptables, "-w", "60", "-t", "filter", "-n", "-L"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: "Chain AZURE-NPM-INGRESS-123456 (1 references)\n"},
+		{Cmd: []string{ipv4MirrorFamily.iptablesRestore(), "-w", "60", "-T", "filter", "--noflush"}},
+		{Cmd: []string{iptables, "-w", "60", "-t", "filter", "-n", "-L", "FORWARD", "--line-numbers"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+		{Cmd: []string{iptables, "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, PlaceAzureChainFirst: util.PlaceAzureChainFirst, MirrorIptablesBackends: true})
+	require.Equal(t, []ipFamily{ipv4Family, ipv4MirrorFamily}, pMgr.families())
+
+	pMgr.staleChains.add(testChain1)
+	require.NoError(t, pMgr.bootupFamily(ipv4MirrorFamily))
+	assertStaleChainsContain(t, pMgr.staleChains, testChain1, "AZURE-NPM-INGRESS-123456")
+}
+
+func TestMirrorFamilies(t *testing.T) {
+	defer func(iptables string) { util.Iptables = iptables }(util.Iptables)
+
+	util.Iptables = util.IptablesNft
+	require.Equal(t, util.IptablesNft, ipv4Family.iptables())
+	require.Equal(t, util.Ip6tablesNft, ipv6Family.iptables())
+	require.Equal(t, util.IptablesLegacy, ipv4MirrorFamily.iptables())
+	require.Equal(t, util.IptablesRestoreLegacy, ipv4MirrorFamily.iptablesRestore())
+	require.Equal(t, util.Ip6tablesRestoreLegacy, ipv6MirrorFamily.iptablesRestore())
+
+	util.Iptables = util.IptablesLegacy
+	require.Equal(t, util.IptablesNft, ipv4MirrorFamily.iptables())
+	require.Equal(t, util.Ip6tablesNft, ipv6MirrorFamily.iptables())
+	require.Equal(t, util.GetIPv6HashedName("azure-npm-123"), ipv6MirrorFamily.setName("azure-npm-123"))
+
+	pMgr := NewPolicyManager(common.NewMockIOShim(nil), &PolicyManagerCfg{EnableIPv6: true, MirrorIptablesBackends: true})
+	require.Equal(t, []ipFamily{ipv4Family, ipv6Family, ipv4MirrorFamily, ipv6MirrorFamily}, pMgr.families())
+}
+
 func TestCreatorForBootup(t *testing.T) {
 	v1Chains := []string{
 		"AZURE-NPM-INGRESS-DROPS",
@@ -772,7 +867,7 @@ func TestPositionAzureChainJumpRule(t *testing.T) {
 				PlaceAzureChainFirst: tt.placeAzureChainFirst,
 			}
 			pMgr := NewPolicyManager(ioshim, cfg)
-			err := pMgr.positionAzureChainJumpRule()
+			_, err := pMgr.positionAzureChainJumpRule(ipv4Family)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -782,6 +877,110 @@ func TestPositionAzureChainJumpRule(t *testing.T) {
 	}
 }
 
+func TestCheckChainIntegrity(t *testing.T) {
+	tests := []struct {
+		name       string
+		calls      []testutils.TestCmd
+		wantRepair jumpRepair
+	}{
+		{
+			name: "jump in place",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{
+					Cmd:    []string{"grep", "AZURE-NPM"},
+					Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+				},
+			},
+			wantRepair: noJumpRepair,
+		},
+		{
+			name: "jump missing",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+				{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+			},
+			wantRepair: jumpMissing,
+		},
+		{
+			name: "jump misplaced",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{
+					Cmd:    []string{"grep", "AZURE-NPM"},
+					Stdout: "3    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+				},
+				{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+				{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+			},
+			wantRepair: jumpMisplaced,
+		},
+		{
+			name: "repair fails",
+			calls: []testutils.TestCmd{
+				{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+				{Cmd: []string{"grep", "AZURE-NPM"}, ExitCode: 1},
+				{Cmd: []string{"iptables", "-w", "60", "-I", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}, ExitCode: 1},
+			},
+			wantRepair: noJumpRepair,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ioshim := common.NewMockIOShim(tt.calls)
+			defer ioshim.VerifyCalls(t, tt.calls)
+			pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+			repairCounts := make(map[jumpRepair]int)
+			for _, repair := range []jumpRepair{jumpMissing, jumpMisplaced} {
+				count, err := metrics.GetIPTablesJumpRepairCount(string(ipv4Family), string(repair))
+				require.NoError(t, err)
+				repairCounts[repair] = count
+			}
+
+			pMgr.CheckChainIntegrity()
+
+			for _, repair := range []jumpRepair{jumpMissing, jumpMisplaced} {
+				count, err := metrics.GetIPTablesJumpRepairCount(string(ipv4Family), string(repair))
+				require.NoError(t, err)
+				if repair == tt.wantRepair {
+					require.Equal(t, repairCounts[repair]+1, count, "wrong count for %s repairs", repair)
+				} else {
+					require.Equal(t, repairCounts[repair], count, "wrong count for %s repairs", repair)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckChainIntegrityHostJumps(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: listLineNumbersCommandStrings, PipedToCommand: true},
+		{
+			Cmd:    []string{"grep", "AZURE-NPM"},
+			Stdout: "1    AZURE-NPM  all  --  0.0.0.0/0            0.0.0.0/0    ...",
+		},
+		{Cmd: []string{"iptables", "-w", "60", "-C", "INPUT", "-j", "AZURE-NPM-INGRESS", "-m", "conntrack", "--ctstate", "NEW"}},
+		{
+			Cmd:      []string{"iptables", "-w", "60", "-C", "OUTPUT", "-j", "AZURE-NPM-EGRESS", "-m", "conntrack", "--ctstate", "NEW"},
+			ExitCode: 1,
+			Stdout:   "iptables: Bad rule (does a matching rule exist in that chain?).",
+		},
+		{Cmd: []string{"iptables", "-w", "60", "-I", "OUTPUT", "-j", "AZURE-NPM-EGRESS", "-m", "conntrack", "--ctstate", "NEW"}},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:           IPSetPolicyMode,
+		PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		EnforceOnHostNetwork: true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+	pMgr.CheckChainIntegrity()
+}
+
 func TestChainLineNumber(t *testing.T) {
 	testChainName := "TEST-CHAIN-NAME"
 	tests := []struct {
@@ -863,7 +1062,7 @@ func TestChainLineNumber(t *testing.T) {
 			ioshim := common.NewMockIOShim(tt.calls)
 			defer ioshim.VerifyCalls(t, tt.calls)
 			pMgr := NewPolicyManager(ioshim, ipsetConfig)
-			lineNum, err := pMgr.chainLineNumber(testChainName)
+			lineNum, err := pMgr.chainLineNumber(ipv4Family, testChainName)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -894,3 +1093,47 @@ func stringsToMap(items []string) map[string]struct{} {
 	}
 	return m
 }
+
+func TestCleanupLinux(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: listAllCommandStrings, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: grepOutputAzureChainsWithoutPolicies},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM", "-m", "conntrack", "--ctstate", "NEW"}},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "FORWARD", "-j", "AZURE-NPM"}, ExitCode: 1, Stdout: "No chain/target/match by that name"},
+		{
+			Cmd:      []string{"iptables", "-w", "60", "-D", "INPUT", "-j", "AZURE-NPM-INGRESS", "-m", "conntrack", "--ctstate", "NEW"},
+			ExitCode: 1,
+			Stdout:   "iptables: Bad rule (does a matching rule exist in that chain?).",
+		},
+		{Cmd: []string{"iptables", "-w", "60", "-D", "OUTPUT", "-j", "AZURE-NPM-EGRESS", "-m", "conntrack", "--ctstate", "NEW"}},
+		fakeIPTablesRestoreCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	artifacts, err := pMgr.Cleanup(nil, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"ipv4 chain AZURE-NPM",
+		"ipv4 chain AZURE-NPM-ACCEPT",
+		"ipv4 chain AZURE-NPM-EGRESS",
+		"ipv4 chain AZURE-NPM-INGRESS",
+		"ipv4 chain AZURE-NPM-INGRESS-ALLOW-MARK",
+	}, artifacts)
+}
+
+func TestCleanupLinuxDryRun(t *testing.T) {
+	// only lists chains
+	calls := []testutils.TestCmd{
+		{Cmd: listAllCommandStrings, PipedToCommand: true},
+		{Cmd: []string{"grep", "Chain AZURE-NPM"}, Stdout: "Chain AZURE-NPM (1 references)\n"},
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	artifacts, err := pMgr.Cleanup(nil, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ipv4 chain AZURE-NPM"}, artifacts)
+}
@@ -0,0 +1,83 @@
+package policies
+
+import "github.com/Azure/azure-container-networking/npm/util"
+
+// ipFamily is the IP family of iptables rules.
+// In dual-stack clusters, NPM programs the same chains in iptables and ip6tables.
+// The ip6tables rules match the IPv6 twins of the ipsets (see util.GetIPv6HashedName).
+type ipFamily string
+
+const (
+	ipv4Family ipFamily = "ipv4"
+	ipv6Family ipFamily = "ipv6"
+	// The mirror families are the IP families in the iptables backend (nft or legacy) which bootup didn't detect.
+	// With MirrorIptablesBackends, NPM programs the same chains in both backends.
+	ipv4MirrorFamily ipFamily = "ipv4-mirror"
+	ipv6MirrorFamily ipFamily = "ipv6-mirror"
+)
+
+// families returns the IP families which policies are programmed for.
+// The IPv4 family of the detected backend is always first.
+func (pMgr *PolicyManager) families() []ipFamily {
+	families := []ipFamily{ipv4Family}
+	if pMgr.EnableIPv6 {
+		families = append(families, ipv6Family)
+	}
+	if pMgr.MirrorIptablesBackends {
+		families = append(families, ipv4MirrorFamily)
+		if pMgr.EnableIPv6 {
+			families = append(families, ipv6MirrorFamily)
+		}
+	}
+	return families
+}
+
+func (family ipFamily) isIPv6() bool {
+	return family == ipv6Family || family == ipv6MirrorFamily
+}
+
+func (family ipFamily) isMirror() bool {
+	return family == ipv4MirrorFamily || family == ipv6MirrorFamily
+}
+
+// usesNft returns whether the family is programmed with the nft backend.
+// The non-mirror families use the backend that bootup detected for iptables.
+func (family ipFamily) usesNft() bool {
+	return (util.Iptables == util.IptablesNft) != family.isMirror()
+}
+
+// iptables returns the iptables binary for the family.
+func (family ipFamily) iptables() string {
+	switch {
+	case family.isIPv6() && family.usesNft():
+		return util.Ip6tablesNft
+	case family.isIPv6():
+		return util.Ip6tablesLegacy
+	case family.usesNft():
+		return util.IptablesNft
+	default:
+		return util.IptablesLegacy
+	}
+}
+
+// iptablesRestore returns the iptables-restore binary for the family.
+func (family ipFamily) iptablesRestore() string {
+	switch {
+	case family.isIPv6() && family.usesNft():
+		return util.Ip6tablesRestoreNft
+	case family.isIPv6():
+		return util.Ip6tablesRestoreLegacy
+	case family.usesNft():
+		return util.IptablesRestoreNft
+	default:
+		return util.IptablesRestoreLegacy
+	}
+}
+
+// setName returns the name of the ipset with the given hashed name for the family.
+func (family ipFamily) setName(hashedName string) string {
+	if !family.isIPv6() {
+		return hashedName
+	}
+	return util.GetIPv6HashedName(hashedName)
+}
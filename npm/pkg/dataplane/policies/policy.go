@@ -46,6 +46,19 @@ func (netPol *NPMNetworkPolicy) AllPodSelectorIPSets() []*ipsets.TranslatedIPSet
 	return append(netPol.PodSelectorIPSets, netPol.ChildPodSelectorIPSets...)
 }
 
+// DeniedDirections returns whether the policy drops ingress traffic it doesn't allow, and whether it drops egress
+// traffic it doesn't allow. Policies in audit mode drop nothing.
+func (netPol *NPMNetworkPolicy) DeniedDirections() (deniesIngress, deniesEgress bool) {
+	for _, aclPolicy := range netPol.ACLs {
+		if aclPolicy.Target != Dropped {
+			continue
+		}
+		deniesIngress = deniesIngress || aclPolicy.hasIngress()
+		deniesEgress = deniesEgress || aclPolicy.hasEgress()
+	}
+	return deniesIngress, deniesEgress
+}
+
 func (netPol *NPMNetworkPolicy) numACLRulesProducedInKernel() int {
 	numRules := 0
 	hasIngress := false
@@ -142,6 +155,9 @@ func ValidatePolicy(networkPolicy *NPMNetworkPolicy) error {
 		if !aclPolicy.hasKnownProtocol() {
 			return npmerrors.SimpleError(fmt.Sprintf("ACL policy for NetPol %s has unknown protocol [%s]", networkPolicy.PolicyKey, aclPolicy.Protocol))
 		}
+		if util.IsWindowsDP() && aclPolicy.Target == Audited {
+			return npmerrors.SimpleError(fmt.Sprintf("ACL policy for NetPol %s has unsupported audit target on Windows", networkPolicy.PolicyKey))
+		}
 		if util.IsWindowsDP() && aclPolicy.Protocol == SCTP {
 			return npmerrors.SimpleError(fmt.Sprintf("ACL policy for NetPol %s has unsupported SCTP protocol on Windows", networkPolicy.PolicyKey))
 		}
@@ -208,6 +224,11 @@ func (aclPolicy *ACLPolicy) hasKnownDirection() bool {
 		aclPolicy.Direction == Both
 }
 
+// HasDirection returns whether the ACL applies to the traffic of the direction, Ingress or Egress.
+func (aclPolicy *ACLPolicy) HasDirection(direction Direction) bool {
+	return aclPolicy.Direction == direction || aclPolicy.Direction == Both
+}
+
 func (aclPolicy *ACLPolicy) hasIngress() bool {
 	return aclPolicy.Direction == Ingress || aclPolicy.Direction == Both
 }
@@ -224,7 +245,7 @@ func (aclPolicy *ACLPolicy) hasKnownProtocol() bool {
 }
 
 func (aclPolicy *ACLPolicy) hasKnownTarget() bool {
-	return aclPolicy.Target == Allowed || aclPolicy.Target == Dropped
+	return aclPolicy.Target == Allowed || aclPolicy.Target == Dropped || aclPolicy.Target == Audited
 }
 
 func (aclPolicy *ACLPolicy) satisifiesPortAndProtocolConstraints() bool {
@@ -330,6 +351,9 @@ const (
 	Allowed Verdict = "ALLOW"
 	// Dropped is denying a flow
 	Dropped Verdict = "DROP"
+	// Audited is logging a flow which would be denied, without denying it.
+	// Linux only: HNS has no action which logs without blocking.
+	Audited Verdict = "AUDIT"
 )
 
 // Protocol can be TCP, UDP, SCTP, or unspecified since they are currently supported in networkpolicy.
@@ -49,6 +49,12 @@ func (networkPolicy *NPMNetworkPolicy) chainName(prefix string) string {
 	return joinWithDash(prefix, policyHash)
 }
 
+// auditLogPrefix returns the prefix of the kernel log entries of the flows which the policy would deny in audit mode,
+// e.g. NPM-AUDIT-IN-<policy hash>: for ingress. It fits the 29 characters iptables allows.
+func (networkPolicy *NPMNetworkPolicy) auditLogPrefix(direction string) string {
+	return fmt.Sprintf("NPM-AUDIT-%s-%s:", direction, util.Hash(networkPolicy.PolicyKey))
+}
+
 func (networkPolicy *NPMNetworkPolicy) commentForJumpToIngress() string {
 	return networkPolicy.commentForJump(forIngress)
 }
@@ -91,13 +97,13 @@ func (info SetInfo) comment() string {
 	return "!" + name
 }
 
-func (info SetInfo) matchSetSpecs(matchString string) []string {
+func (info SetInfo) matchSetSpecs(family ipFamily, matchString string) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs)
 	specs = append(specs, util.IptablesModuleFlag, util.IptablesSetModuleFlag)
 	if !info.Included {
 		specs = append(specs, util.IptablesNotFlag)
 	}
-	hashedSetName := info.IPSet.GetHashedName()
+	hashedSetName := family.setName(info.IPSet.GetHashedName())
 	specs = append(specs, util.IptablesMatchSetFlag, hashedSetName, matchString)
 	return specs
 }
@@ -128,9 +134,12 @@ func (aclPolicy *ACLPolicy) comment() string {
 	}
 
 	builder := strings.Builder{}
-	if aclPolicy.Target == Allowed {
+	switch aclPolicy.Target {
+	case Allowed:
 		builder.WriteString("ALLOW")
-	} else {
+	case Audited:
+		builder.WriteString("AUDIT")
+	default:
 		builder.WriteString("DROP")
 	}
 
@@ -8,10 +8,16 @@ import (
 	"github.com/Microsoft/hcsshim/hcn"
 )
 
-const (
-	blockRulePriotity = 3000
-	allowRulePriotity = 222
-	policyIDPrefix    = "azure-acl"
+const policyIDPrefix = "azure-acl"
+
+// HNS evaluates the ACLs of an endpoint from the lowest priority. The priorities below the allow band are reserved for
+// the base ACLs and the readiness probe ACL, and each kind of rule of the policies gets a band of its own, so that the
+// allow rules are evaluated before the deny rules and the deny rules before the default deny, whatever the order in
+// which the policies are applied to the endpoint.
+var (
+	allowRulePriorityBand       = aclPriorityBand{name: "allow", start: 222, end: 2999}
+	denyRulePriorityBand        = aclPriorityBand{name: "deny", start: 3000, end: 3999}
+	defaultDenyRulePriorityBand = aclPriorityBand{name: "default deny", start: 4000, end: 4999}
 )
 
 var (
@@ -26,8 +32,32 @@ var (
 	ErrNamedPortsNotSupported     = errors.New("Named Port translation is not supported in windows dataplane")
 	ErrNegativeMatchsNotSupported = errors.New("Negative match types is not supported in windows dataplane")
 	ErrProtocolNotSupported       = errors.New("Protocol mentioned is not supported")
+	ErrACLPriorityOverflow        = errors.New("too many rules for their ACL priority band")
 )
 
+// aclPriorityBand is the range of priorities of one kind of rule, inclusive.
+type aclPriorityBand struct {
+	name       string
+	start, end uint16
+}
+
+// aclPriorities assigns the rules of a policy the priorities of their band in order, separately for each direction
+// since HNS only evaluates the ACLs of the direction of the traffic.
+type aclPriorities map[aclPriorityBand]map[hcn.DirectionType]uint16
+
+// next returns the next priority of the band in the direction, or an error if the band has none left.
+func (p aclPriorities) next(band aclPriorityBand, direction hcn.DirectionType) (uint16, error) {
+	if p[band] == nil {
+		p[band] = make(map[hcn.DirectionType]uint16)
+	}
+	assigned := p[band][direction]
+	if int(band.start)+int(assigned) > int(band.end) {
+		return 0, fmt.Errorf("%w: more than %d %s rules of direction %s", ErrACLPriorityOverflow, int(band.end-band.start)+1, band.name, direction)
+	}
+	p[band][direction] = assigned + 1
+	return band.start + assigned, nil
+}
+
 // aclPolicyID returns azure-acl-<network policy namespace>-<network policy name> format
 // to differentiate ACLs among different network policies,
 // but aclPolicy in the same network policy has the same aclPolicyID.
@@ -65,7 +95,8 @@ func (orig NPMACLPolSettings) compare(newACL *NPMACLPolSettings) bool {
 		orig.Priority == newACL.Priority
 }
 
-func (acl *ACLPolicy) convertToAclSettings(aclID string) (*NPMACLPolSettings, error) {
+// convertToAclSettings converts the ACL to HNS ACL settings, with the next priority of its band.
+func (acl *ACLPolicy) convertToAclSettings(aclID string, priorities aclPriorities) (*NPMACLPolSettings, error) {
 	policySettings := &NPMACLPolSettings{}
 	for _, setInfo := range acl.SrcList {
 		if !setInfo.Included {
@@ -82,11 +113,6 @@ func (acl *ACLPolicy) convertToAclSettings(aclID string) (*NPMACLPolSettings, er
 	policySettings.Direction = getHCNDirection(acl.Direction)
 	policySettings.Action = getHCNAction(acl.Target)
 
-	// TODO need to have better priority handling
-	policySettings.Priority = uint16(allowRulePriotity)
-	if policySettings.Action == hcn.ActionTypeBlock {
-		policySettings.Priority = uint16(blockRulePriotity)
-	}
 	protoNum, ok := protocolNumMap[acl.Protocol]
 	if !ok {
 		return policySettings, ErrProtocolNotSupported
@@ -137,9 +163,27 @@ func (acl *ACLPolicy) convertToAclSettings(aclID string) (*NPMACLPolSettings, er
 		policySettings.RemotePorts = dstPortStr
 	}
 
+	priority, err := priorities.next(acl.priorityBand(), policySettings.Direction)
+	if err != nil {
+		return policySettings, err
+	}
+	policySettings.Priority = priority
+
 	return policySettings, nil
 }
 
+// priorityBand returns the band of the priority of the ACL: a drop which matches any traffic is a default deny.
+func (acl *ACLPolicy) priorityBand() aclPriorityBand {
+	if acl.Target != Dropped {
+		return allowRulePriorityBand
+	}
+	if len(acl.SrcList) == 0 && len(acl.DstList) == 0 && acl.DstPorts == (Ports{}) &&
+		(acl.Protocol == "" || acl.Protocol == UnspecifiedProtocol) {
+		return defaultDenyRulePriorityBand
+	}
+	return denyRulePriorityBand
+}
+
 func (acl *ACLPolicy) checkIPSets() bool {
 	for _, set := range acl.SrcList {
 		if set.IPSet.Type == ipsets.NamedPorts {
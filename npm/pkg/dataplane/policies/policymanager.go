@@ -7,6 +7,7 @@ import (
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/npm/metrics"
 	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/Azure/azure-container-networking/npm/util/errorbudget"
 	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
 	"k8s.io/klog"
 )
@@ -39,6 +40,18 @@ type PolicyManagerCfg struct {
 	// The zero value is valid.
 	// A NetworkPolicy's ACLs are always in the same batch, and there will be at least one NetworkPolicy per batch.
 	MaxBatchedACLsPerPod int
+	// EnableIPv6 only affects Linux. It programs policies in ip6tables too, matching the IPv6 twins of IPSets.
+	EnableIPv6 bool
+	// EnforceOnHostNetwork only affects Linux. It jumps to the Azure ingress and egress chains from the INPUT and OUTPUT chains,
+	// so that policies selecting host-network pods (whose IP is the node IP) apply to traffic to and from the node.
+	EnforceOnHostNetwork bool
+	// MaxConsecutiveApplyFailures is the number of times in a row adding a policy can fail before the next add rebuilds it,
+	// removing whatever the failed adds left in the dataplane first. Policies are only rebuilt in Linux.
+	// Zero uses errorbudget.DefaultMaxFailures.
+	MaxConsecutiveApplyFailures int
+	// MirrorIptablesBackends only affects Linux. It programs the chains in both iptables backends (nft and legacy)
+	// instead of only the backend detected on bootup, e.g. while nodes migrate from one backend to the other.
+	MirrorIptablesBackends bool
 }
 
 type PolicyMap struct {
@@ -62,6 +75,8 @@ type PolicyManager struct {
 	ioShim           *common.IOShim
 	staleChains      *staleChains
 	reconcileManager *reconcileManager
+	// addFailures counts the consecutive failed adds of each policy. It's protected by the PolicyMap lock.
+	addFailures *errorbudget.Budget
 	*PolicyManagerCfg
 }
 
@@ -75,6 +90,7 @@ func NewPolicyManager(ioShim *common.IOShim, cfg *PolicyManagerCfg) *PolicyManag
 		reconcileManager: &reconcileManager{
 			releaseLockSignal: make(chan struct{}, 1),
 		},
+		addFailures:      errorbudget.New(cfg.MaxConsecutiveApplyFailures),
 		PolicyManagerCfg: cfg,
 	}
 }
@@ -106,10 +122,42 @@ func (pMgr *PolicyManager) Bootup(epIDs []string) error {
 	return nil
 }
 
+// Cleanup removes all NPM policies from the dataplane: the Azure chains and the jump to them in Linux,
+// and the NPM ACLs of the given endpoints in Windows. Unlike Bootup, the base chains are not recreated.
+// If dryRun is true, the artifacts are only listed. Returns the artifacts which were (or would be) removed.
+func (pMgr *PolicyManager) Cleanup(epIDs []string, dryRun bool) ([]string, error) {
+	artifacts, err := pMgr.cleanup(epIDs, dryRun)
+	if err != nil {
+		return artifacts, npmerrors.SimpleErrorWrapper("failed to clean up policy manager", err)
+	}
+	if !dryRun {
+		pMgr.policyMap.cache = make(map[string]*NPMNetworkPolicy)
+		pMgr.addFailures = errorbudget.New(pMgr.MaxConsecutiveApplyFailures)
+		metrics.ResetNumACLRules()
+	}
+	return artifacts, nil
+}
+
+// RemoveLegacyChains deletes the chains of NPM v1 found at bootup, which bootup only flushes, instead of leaving them
+// to the background cleanup of stale chains. Returns the chains which were deleted. NOOP in Windows, where NPM v1 never ran.
+func (pMgr *PolicyManager) RemoveLegacyChains() ([]string, error) {
+	chains, err := pMgr.removeLegacyChains()
+	if err != nil {
+		return chains, npmerrors.SimpleErrorWrapper("failed to remove legacy chains", err)
+	}
+	return chains, nil
+}
+
 func (pMgr *PolicyManager) Reconcile() {
 	pMgr.reconcile()
 }
 
+// CheckChainIntegrity repairs the jump to the Azure chains in Linux if it's missing or misplaced.
+// Reconcile also does this, but less often. NOOP in Windows.
+func (pMgr *PolicyManager) CheckChainIntegrity() {
+	pMgr.checkChainIntegrity()
+}
+
 func (pMgr *PolicyManager) PolicyExists(policyKey string) bool {
 	pMgr.policyMap.RLock()
 	defer pMgr.policyMap.RUnlock()
@@ -126,6 +174,18 @@ func (pMgr *PolicyManager) GetPolicy(policyKey string) (*NPMNetworkPolicy, bool)
 	return policy, ok
 }
 
+// NumACLs returns the number of ACLs of the policies in the cache.
+func (pMgr *PolicyManager) NumACLs() int {
+	pMgr.policyMap.RLock()
+	defer pMgr.policyMap.RUnlock()
+
+	numACLs := 0
+	for _, policy := range pMgr.policyMap.cache {
+		numACLs += len(policy.ACLs)
+	}
+	return numACLs
+}
+
 func (pMgr *PolicyManager) AddPolicy(policy *NPMNetworkPolicy, endpointList map[string]string) error {
 	if len(policy.ACLs) == 0 {
 		klog.Infof("[DataPlane] No ACLs in policy %s to apply", policy.PolicyKey)
@@ -144,15 +204,30 @@ func (pMgr *PolicyManager) AddPolicy(policy *NPMNetworkPolicy, endpointList map[
 
 	// Call actual dataplane function to apply changes
 	timer := metrics.StartNewTimer()
-	err := pMgr.addPolicy(policy, endpointList)
+	applyType := metrics.IncrementalApply
+	var err error
+	if pMgr.addFailures.Exhausted(policy.PolicyKey) {
+		// earlier adds may have left part of the policy in the dataplane, so remove it before adding the policy again
+		klog.Infof("[PolicyManager] rebuilding policy %s after %d failed adds in a row", policy.PolicyKey, pMgr.addFailures.Failures(policy.PolicyKey))
+		applyType = metrics.RebuildApply
+		err = pMgr.rebuildPolicy(policy, pMgr.addFailures.Failures(policy.PolicyKey))
+	}
+	if err == nil {
+		err = pMgr.addPolicy(policy, endpointList)
+	}
 	metrics.RecordACLRuleExecTime(timer) // record execution time regardless of failure
+	metrics.RecordDataplaneApply(metrics.PolicyObject, applyType, err != nil)
 	if err != nil {
 		// NOTE: in Linux, Prometheus metrics may be off at this point since some ACL rules may have been applied successfully
 		// In Windows, Prometheus metrics may be off at this point since we don't know how many endpoints had rules applied successfully.
 		msg := fmt.Sprintf("failed to add policy: %s", err.Error())
+		if pMgr.addFailures.Failed(policy.PolicyKey) && applyType == metrics.IncrementalApply {
+			msg += ". rebuilding it in the next add"
+		}
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
 		return npmerrors.Errorf(npmerrors.AddPolicy, false, msg)
 	}
+	pMgr.addFailures.Reset(policy.PolicyKey)
 
 	// update Prometheus metrics on success
 	if util.IsWindowsDP() {
@@ -208,6 +283,7 @@ func (pMgr *PolicyManager) RemovePolicy(policyKey string) error {
 
 	// remove policy from cache
 	delete(pMgr.policyMap.cache, policyKey)
+	pMgr.addFailures.Reset(policyKey)
 	return nil
 }
 
@@ -44,15 +44,17 @@ Known errors that we should retry on:
 func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
 	// 1. Add rules for the network policies and activate NPM (if necessary).
 	chainsToCreate := chainNames([]*NPMNetworkPolicy{networkPolicy})
-	creator := pMgr.creatorForNewNetworkPolicies(chainsToCreate, []*NPMNetworkPolicy{networkPolicy})
 
 	// Stop reconciling so we don't contend for iptables, and so reconcile doesn't delete chainsToCreate.
 	pMgr.reconcileManager.forceLock()
 	defer pMgr.reconcileManager.forceUnlock()
 
-	err := restore(creator)
-	if err != nil {
-		return npmerrors.SimpleErrorWrapper("failed to restore iptables with updated policies", err)
+	for _, family := range pMgr.families() {
+		creator := pMgr.creatorForNewNetworkPolicies(family, chainsToCreate, []*NPMNetworkPolicy{networkPolicy})
+		err := restore(family, creator)
+		if err != nil {
+			return npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to restore %s iptables with updated policies", family), err)
+		}
 	}
 
 	// 2. Make sure the new chains don't get deleted in the background
@@ -64,7 +66,6 @@ func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[stri
 
 func (pMgr *PolicyManager) removePolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
 	chainsToDelete := chainNames([]*NPMNetworkPolicy{networkPolicy})
-	creator := pMgr.creatorForRemovingPolicies(chainsToDelete)
 
 	// Stop reconciling so we don't contend for iptables, and so we don't update the staleChains at the same time as reconcile()
 	pMgr.reconcileManager.forceLock()
@@ -78,9 +79,12 @@ func (pMgr *PolicyManager) removePolicy(networkPolicy *NPMNetworkPolicy, _ map[s
 	}
 
 	// 2. Flush the policy chains and deactivate NPM (if necessary).
-	restoreErr := restore(creator)
-	if restoreErr != nil {
-		return npmerrors.SimpleErrorWrapper("failed to flush policies", restoreErr)
+	for _, family := range pMgr.families() {
+		creator := pMgr.creatorForRemovingPolicies(chainsToDelete)
+		restoreErr := restore(family, creator)
+		if restoreErr != nil {
+			return npmerrors.SimpleErrorWrapper(fmt.Sprintf("failed to flush %s policies", family), restoreErr)
+		}
 	}
 
 	// 3. Delete policy chains in the background.
@@ -90,8 +94,8 @@ func (pMgr *PolicyManager) removePolicy(networkPolicy *NPMNetworkPolicy, _ map[s
 	return nil
 }
 
-func restore(creator *ioutil.FileCreator) error {
-	err := creator.RunCommandWithFile(util.IptablesRestore, util.IptablesWaitFlag, util.IptablesDefaultWaitTime, util.IptablesRestoreTableFlag, util.IptablesFilterTable, util.IptablesRestoreNoFlushFlag)
+func restore(family ipFamily, creator *ioutil.FileCreator) error {
+	err := creator.RunCommandWithFile(family.iptablesRestore(), util.IptablesWaitFlag, util.IptablesDefaultWaitTime, util.IptablesRestoreTableFlag, util.IptablesFilterTable, util.IptablesRestoreNoFlushFlag)
 	if err != nil {
 		return npmerrors.SimpleErrorWrapper("failed to restore iptables file", err)
 	}
@@ -143,64 +147,102 @@ func (pMgr *PolicyManager) newCreatorWithChains(chainNames []string) *ioutil.Fil
 	return creator
 }
 
+/*
+rebuildPolicy removes the jumps to the policy chains which failed adds of the policy may have left, so that the next add
+programs the policy from scratch. The policy chains themselves are flushed by the add.
+With iptables-restore --noflush, each failed add can leave a jump per direction and IP family, e.g. if the restore for
+IPv4 succeeded but the restore for IPv6 failed, so there are at most as many duplicate jumps as failed adds.
+*/
+func (pMgr *PolicyManager) rebuildPolicy(policy *NPMNetworkPolicy, failedAdds int) error {
+	pMgr.reconcileManager.forceLock()
+	defer pMgr.reconcileManager.forceUnlock()
+
+	hasIngress, hasEgress := policy.hasIngressAndEgress()
+	for _, family := range pMgr.families() {
+		for _, direction := range []UniqueDirection{forIngress, !forIngress} {
+			if (direction == forIngress && !hasIngress) || (direction != forIngress && !hasEgress) {
+				continue
+			}
+			for i := 0; i < failedAdds; i++ {
+				deleted, err := pMgr.deleteJumpRuleIfExists(family, policy, direction)
+				if err != nil {
+					return npmerrors.SimpleErrorWrapper("failed to delete jumps to policy chains for rebuild", err)
+				}
+				if !deleted {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // will make a similar func for on update eventually
 func (pMgr *PolicyManager) deleteOldJumpRulesOnRemove(policy *NPMNetworkPolicy) error {
 	shouldDeleteIngress, shouldDeleteEgress := policy.hasIngressAndEgress()
-	if shouldDeleteIngress {
-		if err := pMgr.deleteJumpRule(policy, true); err != nil {
-			return err
+	for _, family := range pMgr.families() {
+		if shouldDeleteIngress {
+			if err := pMgr.deleteJumpRule(family, policy, true); err != nil {
+				return err
+			}
 		}
-	}
-	if shouldDeleteEgress {
-		if err := pMgr.deleteJumpRule(policy, false); err != nil {
-			return err
+		if shouldDeleteEgress {
+			if err := pMgr.deleteJumpRule(family, policy, false); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func (pMgr *PolicyManager) deleteJumpRule(policy *NPMNetworkPolicy, direction UniqueDirection) error {
+func (pMgr *PolicyManager) deleteJumpRule(family ipFamily, policy *NPMNetworkPolicy, direction UniqueDirection) error {
+	_, err := pMgr.deleteJumpRuleIfExists(family, policy, direction)
+	return err
+}
+
+// deleteJumpRuleIfExists deletes a jump to the policy chain of the direction, and returns whether there was one.
+func (pMgr *PolicyManager) deleteJumpRuleIfExists(family ipFamily, policy *NPMNetworkPolicy, direction UniqueDirection) (bool, error) {
 	var specs []string
 	var baseChainName string
 	var chainName string
 	if direction == forIngress {
-		specs = ingressJumpSpecs(policy)
+		specs = ingressJumpSpecs(family, policy)
 		baseChainName = util.IptablesAzureIngressChain
 		chainName = policy.ingressChainName()
 	} else {
-		specs = egressJumpSpecs(policy)
+		specs = egressJumpSpecs(family, policy)
 		baseChainName = util.IptablesAzureEgressChain
 		chainName = policy.egressChainName()
 	}
 
 	specs = append([]string{baseChainName}, specs...)
-	errCode, err := pMgr.runIPTablesCommand(util.IptablesDeletionFlag, specs...)
+	errCode, err := pMgr.runIPTablesCommand(family, util.IptablesDeletionFlag, specs...)
 	// if this actually happens (don't think it should), could use ignoreErrorsAndRunIPTablesCommand instead with: "Bad rule (does a matching rule exist in that chain?)"
 	if err != nil && errCode != doesNotExistErrorCode {
-		errorString := fmt.Sprintf("failed to delete jump from %s chain to %s chain for policy %s with exit code %d", baseChainName, chainName, policy.PolicyKey, errCode)
+		errorString := fmt.Sprintf("failed to delete %s jump from %s chain to %s chain for policy %s with exit code %d", family, baseChainName, chainName, policy.PolicyKey, errCode)
 		log.Errorf("%s: %w", errorString, err)
-		return npmerrors.SimpleErrorWrapper(errorString, err)
+		return false, npmerrors.SimpleErrorWrapper(errorString, err)
 	}
-	return nil
+	return err == nil, nil
 }
 
-func ingressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
+func ingressJumpSpecs(family ipFamily, networkPolicy *NPMNetworkPolicy) []string {
 	chainName := networkPolicy.ingressChainName()
 	specs := []string{util.IptablesJumpFlag, chainName}
-	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, DstMatch)...)
+	specs = append(specs, matchSetSpecsForNetworkPolicy(family, networkPolicy, DstMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToIngress())...)
 	return specs
 }
 
-func egressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
+func egressJumpSpecs(family ipFamily, networkPolicy *NPMNetworkPolicy) []string {
 	chainName := networkPolicy.egressChainName()
 	specs := []string{util.IptablesJumpFlag, chainName}
-	specs = append(specs, matchSetSpecsForNetworkPolicy(networkPolicy, SrcMatch)...)
+	specs = append(specs, matchSetSpecsForNetworkPolicy(family, networkPolicy, SrcMatch)...)
 	specs = append(specs, commentSpecs(networkPolicy.commentForJumpToEgress())...)
 	return specs
 }
 
-func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, networkPolicies []*NPMNetworkPolicy) *ioutil.FileCreator {
+func (pMgr *PolicyManager) creatorForNewNetworkPolicies(family ipFamily, policyChains []string, networkPolicies []*NPMNetworkPolicy) *ioutil.FileCreator {
 	creator := pMgr.newCreatorWithChains(policyChains)
 
 	// 1. Activate NPM if necessary
@@ -216,17 +258,17 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 	egressJumpLineNumber := 1
 	for _, networkPolicy := range networkPolicies {
 		// 2.1 add all rules for the policy chain(s)
-		writeNetworkPolicyRules(creator, networkPolicy)
+		writeNetworkPolicyRules(family, creator, networkPolicy)
 
 		// 2.2 add jump rule(s) to the policy chain(s)
 		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
 		if hasIngress {
-			ingressJumpSpecs := insertSpecs(util.IptablesAzureIngressChain, ingressJumpLineNumber, ingressJumpSpecs(networkPolicy))
+			ingressJumpSpecs := insertSpecs(util.IptablesAzureIngressChain, ingressJumpLineNumber, ingressJumpSpecs(family, networkPolicy))
 			creator.AddLine("", nil, ingressJumpSpecs...) // TODO error handler
 			ingressJumpLineNumber++
 		}
 		if hasEgress {
-			egressJumpSpecs := insertSpecs(util.IptablesAzureEgressChain, egressJumpLineNumber, egressJumpSpecs(networkPolicy))
+			egressJumpSpecs := insertSpecs(util.IptablesAzureEgressChain, egressJumpLineNumber, egressJumpSpecs(family, networkPolicy))
 			creator.AddLine("", nil, egressJumpSpecs...) // TODO error handler
 			egressJumpLineNumber++
 		}
@@ -236,40 +278,46 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 }
 
 // write rules for the policy chain(s)
-func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
+func writeNetworkPolicyRules(family ipFamily, creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
 	for _, aclPolicy := range networkPolicy.ACLs {
 		var chainName string
 		var actionSpecs []string
 		if aclPolicy.hasIngress() {
 			chainName = networkPolicy.ingressChainName()
-			if aclPolicy.Target == Allowed {
+			switch aclPolicy.Target {
+			case Allowed:
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureIngressAllowMarkChain}
-			} else {
+			case Audited:
+				actionSpecs = auditLogSpecs(networkPolicy.auditLogPrefix("IN"))
+			default:
 				actionSpecs = setMarkSpecs(util.IptablesAzureIngressDropMarkHex)
 			}
 		} else {
 			chainName = networkPolicy.egressChainName()
-			if aclPolicy.Target == Allowed {
+			switch aclPolicy.Target {
+			case Allowed:
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
-			} else {
+			case Audited:
+				actionSpecs = auditLogSpecs(networkPolicy.auditLogPrefix("OUT"))
+			default:
 				actionSpecs = setMarkSpecs(util.IptablesAzureEgressDropMarkHex)
 			}
 		}
 		line := []string{"-A", chainName}
 		line = append(line, actionSpecs...)
-		line = append(line, iptablesRuleSpecs(aclPolicy)...)
+		line = append(line, iptablesRuleSpecs(family, aclPolicy)...)
 		creator.AddLine("", nil, line...) // TODO add error handler
 	}
 }
 
-func iptablesRuleSpecs(aclPolicy *ACLPolicy) []string {
+func iptablesRuleSpecs(family ipFamily, aclPolicy *ACLPolicy) []string {
 	specs := make([]string, 0)
 	if aclPolicy.Protocol != UnspecifiedProtocol {
 		specs = append(specs, util.IptablesProtFlag, string(aclPolicy.Protocol))
 	}
 	specs = append(specs, dstPortSpecs(aclPolicy.DstPorts)...)
-	specs = append(specs, matchSetSpecsFromSetInfo(aclPolicy.SrcList)...)
-	specs = append(specs, matchSetSpecsFromSetInfo(aclPolicy.DstList)...)
+	specs = append(specs, matchSetSpecsFromSetInfo(family, aclPolicy.SrcList)...)
+	specs = append(specs, matchSetSpecsFromSetInfo(family, aclPolicy.DstList)...)
 	specs = append(specs, commentSpecs(aclPolicy.comment())...)
 	return specs
 }
@@ -281,20 +329,20 @@ func dstPortSpecs(portRange Ports) []string {
 	return []string{util.IptablesDstPortFlag, portRange.toIPTablesString()}
 }
 
-func matchSetSpecsForNetworkPolicy(networkPolicy *NPMNetworkPolicy, matchType MatchType) []string {
+func matchSetSpecsForNetworkPolicy(family ipFamily, networkPolicy *NPMNetworkPolicy, matchType MatchType) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs*len(networkPolicy.PodSelectorList))
 	matchString := matchType.toIPTablesString()
 	for _, setInfo := range networkPolicy.PodSelectorList {
-		specs = append(specs, setInfo.matchSetSpecs(matchString)...)
+		specs = append(specs, setInfo.matchSetSpecs(family, matchString)...)
 	}
 	return specs
 }
 
-func matchSetSpecsFromSetInfo(setInfoList []SetInfo) []string {
+func matchSetSpecsFromSetInfo(family ipFamily, setInfoList []SetInfo) []string {
 	specs := make([]string, 0, maxLengthForMatchSetSpecs*len(setInfoList))
 	for _, setInfo := range setInfoList {
 		matchString := setInfo.MatchType.toIPTablesString()
-		specs = append(specs, setInfo.matchSetSpecs(matchString)...)
+		specs = append(specs, setInfo.matchSetSpecs(family, matchString)...)
 	}
 	return specs
 }
@@ -308,6 +356,21 @@ func setMarkSpecs(mark string) []string {
 	}
 }
 
+// auditLogSpecs logs the flows matching a rule instead of marking them to be dropped. LOG doesn't terminate the
+// chain, so the flows go on as if the policy didn't select them. Logging is rate limited to spare the kernel log.
+func auditLogSpecs(prefix string) []string {
+	return []string{
+		util.IptablesModuleFlag,
+		util.IptablesLimitModuleFlag,
+		util.IptablesLimitFlag,
+		util.IptablesAuditLogLimit,
+		util.IptablesJumpFlag,
+		util.IptablesLog,
+		util.IptablesLogPrefixFlag,
+		prefix,
+	}
+}
+
 func commentSpecs(comment string) []string {
 	return []string{
 		util.IptablesModuleFlag,
@@ -219,6 +219,38 @@ func TestAddPolicyFailure(t *testing.T) {
 	promVals{0, 1}.testPrometheusMetrics(t)
 }
 
+func TestAddPolicyRebuildsAfterConsecutiveFailures(t *testing.T) {
+	deleteJump := append([]string{"iptables", "-w", "60", "-D", util.IptablesAzureIngressChain}, ingressJumpSpecs(ipv4Family, ingressNetPol)...)
+	calls := []testutils.TestCmd{
+		fakeIPTablesRestoreFailureCommand,
+		fakeIPTablesRestoreFailureCommand,
+		// the rebuild deletes the jump the failed add may have left, then adds the policy
+		{Cmd: deleteJump},
+		fakeIPTablesRestoreCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, MaxConsecutiveApplyFailures: 1})
+
+	incrementalFailures, err := metrics.GetDataplaneApplyCount(metrics.PolicyObject, metrics.IncrementalApply, true)
+	require.NoError(t, err)
+	rebuilds, err := metrics.GetDataplaneApplyCount(metrics.PolicyObject, metrics.RebuildApply, false)
+	require.NoError(t, err)
+
+	require.Error(t, pMgr.AddPolicy(ingressNetPol, nil))
+	require.True(t, pMgr.addFailures.Exhausted(ingressNetPol.PolicyKey))
+	require.NoError(t, pMgr.AddPolicy(ingressNetPol, nil))
+	require.False(t, pMgr.addFailures.Exhausted(ingressNetPol.PolicyKey))
+	require.True(t, pMgr.PolicyExists(ingressNetPol.PolicyKey))
+
+	newIncrementalFailures, err := metrics.GetDataplaneApplyCount(metrics.PolicyObject, metrics.IncrementalApply, true)
+	require.NoError(t, err)
+	require.Equal(t, incrementalFailures+1, newIncrementalFailures)
+	newRebuilds, err := metrics.GetDataplaneApplyCount(metrics.PolicyObject, metrics.RebuildApply, false)
+	require.NoError(t, err)
+	require.Equal(t, rebuilds+1, newRebuilds)
+}
+
 func TestCreatorForAddPolicies(t *testing.T) {
 	calls := []testutils.TestCmd{fakeIPTablesRestoreCommand}
 	ioshim := common.NewMockIOShim(calls)
@@ -227,7 +259,7 @@ func TestCreatorForAddPolicies(t *testing.T) {
 
 	// 1. test with activation
 	policies := []*NPMNetworkPolicy{allTestNetworkPolicies[0]}
-	creator := pMgr.creatorForNewNetworkPolicies(chainNames(policies), policies)
+	creator := pMgr.creatorForNewNetworkPolicies(ipv4Family, chainNames(policies), policies)
 	actualLines := strings.Split(creator.ToString(), "\n")
 	expectedLines := []string{
 		"*filter",
@@ -254,7 +286,7 @@ func TestCreatorForAddPolicies(t *testing.T) {
 	// 2. test without activation
 	// add a policy to the cache so that we don't activate (the cache doesn't impact creatorForNewNetworkPolicies)
 	require.NoError(t, pMgr.AddPolicy(allTestNetworkPolicies[0], nil))
-	creator = pMgr.creatorForNewNetworkPolicies(chainNames(allTestNetworkPolicies), allTestNetworkPolicies)
+	creator = pMgr.creatorForNewNetworkPolicies(ipv4Family, chainNames(allTestNetworkPolicies), allTestNetworkPolicies)
 	actualLines = strings.Split(creator.ToString(), "\n")
 	expectedLines = []string{
 		"*filter",
@@ -282,6 +314,101 @@ func TestCreatorForAddPolicies(t *testing.T) {
 	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
 }
 
+func TestCreatorForAddPoliciesIPv6(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	policies := []*NPMNetworkPolicy{ingressNetPol}
+	// add a policy to the cache so that we don't activate (the cache doesn't impact creatorForNewNetworkPolicies)
+	pMgr.policyMap.cache[bothDirectionsNetPol.PolicyKey] = bothDirectionsNetPol
+	creator := pMgr.creatorForNewNetworkPolicies(ipv6Family, chainNames(policies), policies)
+	actualLines := strings.Split(creator.ToString(), "\n")
+	ingressDropRuleIPv6 := fmt.Sprintf(
+		"-j MARK --set-mark %s -p TCP --dport 222:333 -m set --match-set %s src -m set ! --match-set %s dst -m comment --comment %s",
+		util.IptablesAzureIngressDropMarkHex,
+		util.GetIPv6HashedName(ipsets.TestCIDRSet.HashedName),
+		util.GetIPv6HashedName(ipsets.TestKeyPodSet.HashedName),
+		ingressDropComment,
+	)
+	ingressNetPolJumpIPv6 := fmt.Sprintf(
+		"-j %s -m set --match-set %s dst -m set --match-set %s dst -m comment --comment %s",
+		ingressNetPolChain,
+		util.GetIPv6HashedName(ipsets.TestKeyPodSet.HashedName),
+		util.GetIPv6HashedName(ipsets.TestNSSet.HashedName),
+		ingressNetPolJumpComment,
+	)
+	expectedLines := []string{
+		"*filter",
+		fmt.Sprintf(":%s - -", ingressNetPolChain),
+		fmt.Sprintf("-A %s %s", ingressNetPolChain, ingressDropRuleIPv6),
+		fmt.Sprintf("-I AZURE-NPM-INGRESS 1 %s", ingressNetPolJumpIPv6),
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestCreatorForAddAuditPolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	auditedIngressACL := *ingressDeniedACL
+	auditedIngressACL.Target = Audited
+	auditedEgressACL := *egressDeniedACL
+	auditedEgressACL.Target = Audited
+	auditNetPol := &NPMNetworkPolicy{
+		Namespace:   "x",
+		PolicyKey:   "x/test1",
+		ACLPolicyID: "azure-acl-x-test1",
+		PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+			{Metadata: ipsets.TestKeyPodSet.Metadata},
+		},
+		PodSelectorList: bothDirectionsNetPol.PodSelectorList,
+		ACLs:            []*ACLPolicy{&auditedIngressACL, ingressAllowedACL, &auditedEgressACL},
+	}
+	policies := []*NPMNetworkPolicy{auditNetPol}
+	// add a policy to the cache so that we don't activate (the cache doesn't impact creatorForNewNetworkPolicies)
+	pMgr.policyMap.cache[ingressNetPol.PolicyKey] = ingressNetPol
+	creator := pMgr.creatorForNewNetworkPolicies(ipv4Family, chainNames(policies), policies)
+	actualLines := strings.Split(creator.ToString(), "\n")
+	hash := util.Hash(auditNetPol.PolicyKey)
+	expectedLines := []string{
+		"*filter",
+		fmt.Sprintf(":%s - -", bothDirectionsNetPolIngressChain),
+		fmt.Sprintf(":%s - -", bothDirectionsNetPolEgressChain),
+		fmt.Sprintf("-A %s -m limit --limit 10/second -j LOG --log-prefix NPM-AUDIT-IN-%s: "+
+			"-p TCP --dport 222:333 -m set --match-set %s src -m set ! --match-set %s dst -m comment --comment AUDIT%s",
+			bothDirectionsNetPolIngressChain, hash, ipsets.TestCIDRSet.HashedName, ipsets.TestKeyPodSet.HashedName,
+			strings.TrimPrefix(ingressDropComment, "DROP")),
+		fmt.Sprintf("-A %s %s", bothDirectionsNetPolIngressChain, ingressAllowRule),
+		fmt.Sprintf("-A %s -m limit --limit 10/second -j LOG --log-prefix NPM-AUDIT-OUT-%s: "+
+			"-p UDP --dport 144 -m set --match-set %s dst -m comment --comment AUDIT%s",
+			bothDirectionsNetPolEgressChain, hash, ipsets.TestCIDRSet.HashedName, strings.TrimPrefix(egressDropComment, "DROP")),
+		fmt.Sprintf("-I AZURE-NPM-INGRESS 1 %s", ingressEgressNetPolIngressJump),
+		fmt.Sprintf("-I AZURE-NPM-EGRESS 1 %s", ingressEgressNetPolEgressJump),
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestAddAndRemovePolicyDualStack(t *testing.T) {
+	fakeIP6TablesRestoreCommand := testutils.TestCmd{Cmd: []string{ipv6Family.iptablesRestore(), "-w", "60", "-T", "filter", "--noflush"}}
+	calls := []testutils.TestCmd{fakeIPTablesRestoreCommand, fakeIP6TablesRestoreCommand}
+	deleteIngressJumpSpecs := []string{ipv6Family.iptables(), "-w", "60", "-D", util.IptablesAzureIngressChain}
+	deleteIngressJumpSpecs = append(deleteIngressJumpSpecs, ingressJumpSpecs(ipv6Family, ingressNetPol)...)
+	calls = append(calls, GetRemovePolicyTestCalls(ingressNetPol)[0], testutils.TestCmd{Cmd: deleteIngressJumpSpecs})
+	calls = append(calls, fakeIPTablesRestoreCommand, fakeIP6TablesRestoreCommand)
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, &PolicyManagerCfg{PolicyMode: IPSetPolicyMode, EnableIPv6: true})
+
+	require.NoError(t, pMgr.AddPolicy(ingressNetPol, nil))
+	require.NoError(t, pMgr.RemovePolicy(ingressNetPol.PolicyKey))
+}
+
 func TestCreatorForRemovePolicies(t *testing.T) {
 	calls := []testutils.TestCmd{fakeIPTablesRestoreCommand}
 	ioshim := common.NewMockIOShim(calls)
@@ -126,10 +126,50 @@ func (pMgr *PolicyManager) bootup(epIDs []string) error {
 	return nil
 }
 
+// cleanup removes the NPM ACLs from the endpoints, like bootup. With dryRun, lists the endpoints with NPM ACLs instead.
+func (pMgr *PolicyManager) cleanup(epIDs []string, dryRun bool) ([]string, error) {
+	artifacts := make([]string, 0)
+	for _, epID := range epIDs {
+		epObj, err := pMgr.ioShim.Hns.GetEndpointByID(epID)
+		if err != nil {
+			if isNotFoundErr(err) {
+				continue
+			}
+			return artifacts, fmt.Errorf("[PolicyManagerWindows] failed to get endpoint %s for cleanup: %w", epID, err)
+		}
+		epBuilder, err := splitEndpointPolicies(epObj.Policies)
+		if err != nil {
+			return artifacts, fmt.Errorf("[PolicyManagerWindows] failed to split policies of endpoint %s for cleanup: %w", epID, err)
+		}
+		numACLs := len(epBuilder.aclPolicies)
+		if !epBuilder.resetAllNPMAclPolicies() {
+			continue
+		}
+		artifacts = append(artifacts, fmt.Sprintf("endpoint %s: %d ACL policies", epID, numACLs-len(epBuilder.aclPolicies)))
+	}
+	if dryRun {
+		return artifacts, nil
+	}
+	return artifacts, pMgr.bootup(epIDs)
+}
+
+func (pMgr *PolicyManager) removeLegacyChains() ([]string, error) {
+	return nil, nil
+}
+
 func (pMgr *PolicyManager) reconcile() {
 	// not implemented
 }
 
+// rebuildPolicy is a NOOP in Windows, where adding a policy skips the endpoints which already have it.
+func (pMgr *PolicyManager) rebuildPolicy(_ *NPMNetworkPolicy, _ int) error {
+	return nil
+}
+
+func (pMgr *PolicyManager) checkChainIntegrity() {
+	// NOOP in Windows
+}
+
 // AddAllPolicies is used in Windows to add all NetworkPolicies to an endpoint.
 // Will make a series of sequential HNS ADD calls based on MaxBatchedACLsPerPod.
 // A NetworkPolicy's ACLs are always in the same batch, and there will be at least one NetworkPolicy per batch.
@@ -464,8 +504,9 @@ func getEPPolicyReqFromACLSettings(settings []*NPMACLPolSettings) (hcn.PolicyEnd
 func (pMgr *PolicyManager) getSettingsFromACL(policy *NPMNetworkPolicy) ([]*NPMACLPolSettings, error) {
 	// +1 for readiness probe ACL
 	hnsRules := make([]*NPMACLPolSettings, len(policy.ACLs)+1)
+	priorities := aclPriorities{}
 	for i, acl := range policy.ACLs {
-		rule, err := acl.convertToAclSettings(policy.ACLPolicyID)
+		rule, err := acl.convertToAclSettings(policy.ACLPolicyID, priorities)
 		if err != nil {
 			// TODO need some retry mechanism to check why the translations failed
 			return hnsRules, err
@@ -25,7 +25,7 @@ var (
 			RemoteAddresses: ipsets.TestKeyPodSet.HashedName,
 			RemotePorts:     "",
 			LocalPorts:      getPortStr(222, 333),
-			Priority:        blockRulePriotity,
+			Priority:        int(denyRulePriorityBand.start),
 		},
 		{
 			ID:              TestNetworkPolicies[0].ACLPolicyID,
@@ -36,7 +36,7 @@ var (
 			RemoteAddresses: "",
 			LocalPorts:      "",
 			RemotePorts:     "",
-			Priority:        allowRulePriotity,
+			Priority:        int(allowRulePriorityBand.start),
 		},
 		{
 			ID:              TestNetworkPolicies[0].ACLPolicyID,
@@ -47,7 +47,7 @@ var (
 			RemoteAddresses: "",
 			LocalPorts:      "",
 			RemotePorts:     "144",
-			Priority:        blockRulePriotity,
+			Priority:        int(denyRulePriorityBand.start),
 		},
 		{
 			ID:              TestNetworkPolicies[0].ACLPolicyID,
@@ -58,7 +58,7 @@ var (
 			RemoteAddresses: "",
 			LocalPorts:      "",
 			RemotePorts:     "",
-			Priority:        allowRulePriotity,
+			Priority:        int(allowRulePriorityBand.start),
 		},
 		// readiness probe ACL
 		{
@@ -188,6 +188,49 @@ func TestRemovePoliciesEndpointNotFound(t *testing.T) {
 	}
 }
 
+func TestACLPriorityBands(t *testing.T) {
+	pMgr, _ := getPMgr(t)
+	policy := &NPMNetworkPolicy{
+		ACLPolicyID: "azure-acl-x-priorities",
+		ACLs: []*ACLPolicy{
+			// the default deny is first, but still evaluated after the other rules
+			{Target: Dropped, Direction: Ingress, Protocol: UnspecifiedProtocol},
+			{Target: Allowed, Direction: Ingress, Protocol: TCP, DstPorts: Ports{Port: 80}},
+			{Target: Dropped, Direction: Ingress, Protocol: TCP, DstPorts: Ports{Port: 81}},
+			{Target: Allowed, Direction: Ingress, Protocol: UDP},
+			{Target: Allowed, Direction: Egress, Protocol: UnspecifiedProtocol},
+		},
+	}
+
+	rules, err := pMgr.getSettingsFromACL(policy)
+	require.NoError(t, err)
+	priorities := make([]uint16, 0, len(rules))
+	for _, rule := range rules {
+		priorities = append(priorities, rule.Priority)
+	}
+	// the last rule is the readiness probe ACL
+	require.Equal(t, []uint16{4000, 222, 3000, 223, 222, 201}, priorities)
+}
+
+func TestACLPriorityBandOverflow(t *testing.T) {
+	pMgr, _ := getPMgr(t)
+	policy := &NPMNetworkPolicy{ACLPolicyID: "azure-acl-x-overflow"}
+	for i := 0; i <= int(denyRulePriorityBand.end-denyRulePriorityBand.start); i++ {
+		policy.ACLs = append(policy.ACLs, &ACLPolicy{Target: Dropped, Direction: Ingress, Protocol: TCP, DstPorts: Ports{Port: 80}})
+	}
+	_, err := pMgr.getSettingsFromACL(policy)
+	require.NoError(t, err)
+
+	policy.ACLs = append(policy.ACLs, &ACLPolicy{Target: Dropped, Direction: Ingress, Protocol: TCP, DstPorts: Ports{Port: 80}})
+	_, err = pMgr.getSettingsFromACL(policy)
+	require.ErrorIs(t, err, ErrACLPriorityOverflow)
+
+	// the rules of the other direction have their own priorities
+	policy.ACLs[len(policy.ACLs)-1].Direction = Egress
+	_, err = pMgr.getSettingsFromACL(policy)
+	require.NoError(t, err)
+}
+
 // Helper functions for UTS
 
 func getPMgr(t *testing.T) (*PolicyManager, *hnswrapper.Hnsv2wrapperFake) {
@@ -28,12 +28,12 @@ func GetRemovePolicyTestCalls(policy *NPMNetworkPolicy) []testutils.TestCmd {
 	hasIngress, hasEgress := policy.hasIngressAndEgress()
 	if hasIngress {
 		deleteIngressJumpSpecs := []string{"iptables", "-w", "60", "-D", util.IptablesAzureIngressChain}
-		deleteIngressJumpSpecs = append(deleteIngressJumpSpecs, ingressJumpSpecs(policy)...)
+		deleteIngressJumpSpecs = append(deleteIngressJumpSpecs, ingressJumpSpecs(ipv4Family, policy)...)
 		calls = append(calls, testutils.TestCmd{Cmd: deleteIngressJumpSpecs})
 	}
 	if hasEgress {
 		deleteEgressJumpSpecs := []string{"iptables", "-w", "60", "-D", util.IptablesAzureEgressChain}
-		deleteEgressJumpSpecs = append(deleteEgressJumpSpecs, egressJumpSpecs(policy)...)
+		deleteEgressJumpSpecs = append(deleteEgressJumpSpecs, egressJumpSpecs(ipv4Family, policy)...)
 		calls = append(calls, testutils.TestCmd{Cmd: deleteEgressJumpSpecs})
 	}
 
@@ -12,6 +12,7 @@ import (
 
 type GenericDataplane interface {
 	BootupDataplane() error
+	FinishBootupPhase() error
 	RunPeriodicTasks()
 	GetAllIPSets() map[string]string
 	GetIPSet(setName string) *ipsets.IPSet
@@ -45,6 +46,8 @@ type PodMetadata struct {
 	PodKey   string
 	PodIP    string
 	NodeName string
+	// Generation orders the pods assigned the same IP, see PodGeneration. The zero value isn't tracked.
+	Generation int64
 }
 
 func NewPodMetadata(podKey, podIP, nodeName string) *PodMetadata {
@@ -1,5 +1,8 @@
 package dataplane
 
+// DefaultMigrationMarkerPath is on a host directory mounted in the NPM Pod.
+const DefaultMigrationMarkerPath = "/var/log/azure-npm-dataplane.json"
+
 // npmEndpoint holds info relevant for endpoints in windows
 type npmEndpoint struct {
 	netPolReference map[string]struct{}
@@ -4,6 +4,9 @@ import "github.com/Microsoft/hcsshim/hcn"
 
 const unspecifiedPodKey = ""
 
+// DefaultMigrationMarkerPath is next to the NPM config on the host.
+const DefaultMigrationMarkerPath = "c:\\k\\azure-npm\\azure-npm-dataplane.json"
+
 const (
 	hcnEndpointStateCreated = iota + 1
 	hcnEndpointStateAttached
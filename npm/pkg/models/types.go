@@ -15,6 +15,7 @@ import (
 var (
 	ErrMarshalNPMCache     = errors.New("failed to marshal NPM Cache")
 	ErrInformerSyncFailure = errors.New("informer sync failure")
+	ErrUnknownCacheKey     = errors.New("unknown cache key")
 )
 
 // Cache is the cache lookup key for the NPM cache
@@ -34,6 +35,8 @@ type K8SControllersV2 struct {
 	NamespaceControllerV2 *controllersv2.NamespaceController     //nolint:structcheck // false lint error
 	NpmNamespaceCacheV2   *controllersv2.NpmNamespaceCache       //nolint:structcheck // false lint error
 	NetPolControllerV2    *controllersv2.NetworkPolicyController //nolint:structcheck // false lint error
+	// AddressGroupControllerV2 is nil unless AddressGroups are enabled
+	AddressGroupControllerV2 *controllersv2.AddressGroupController //nolint:structcheck // false lint error
 }
 
 // Informers are the informers for the k8s controllers
@@ -0,0 +1,79 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package scale
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+)
+
+// FakeDataplane is a dataplane which programs nothing, to measure the convergence of the control plane alone.
+// ApplyLatency simulates the time the kernel takes to apply ipsets.
+type FakeDataplane struct {
+	ApplyLatency time.Duration
+}
+
+var _ dataplane.GenericDataplane = &FakeDataplane{}
+
+func (*FakeDataplane) BootupDataplane() error {
+	return nil
+}
+
+func (*FakeDataplane) FinishBootupPhase() error {
+	return nil
+}
+
+func (*FakeDataplane) RunPeriodicTasks() {}
+
+func (*FakeDataplane) GetAllIPSets() map[string]string {
+	return map[string]string{}
+}
+
+func (*FakeDataplane) GetIPSet(string) *ipsets.IPSet {
+	return nil
+}
+
+func (*FakeDataplane) CreateIPSets([]*ipsets.IPSetMetadata) {}
+
+func (*FakeDataplane) DeleteIPSet(*ipsets.IPSetMetadata, util.DeleteOption) {}
+
+func (*FakeDataplane) AddToSets([]*ipsets.IPSetMetadata, *dataplane.PodMetadata) error {
+	return nil
+}
+
+func (*FakeDataplane) RemoveFromSets([]*ipsets.IPSetMetadata, *dataplane.PodMetadata) error {
+	return nil
+}
+
+func (*FakeDataplane) AddToLists([]*ipsets.IPSetMetadata, []*ipsets.IPSetMetadata) error {
+	return nil
+}
+
+func (*FakeDataplane) RemoveFromList(*ipsets.IPSetMetadata, []*ipsets.IPSetMetadata) error {
+	return nil
+}
+
+func (dp *FakeDataplane) ApplyDataPlane() error {
+	time.Sleep(dp.ApplyLatency)
+	return nil
+}
+
+func (*FakeDataplane) GetAllPolicies() []string {
+	return nil
+}
+
+func (*FakeDataplane) AddPolicy(*policies.NPMNetworkPolicy) error {
+	return nil
+}
+
+func (*FakeDataplane) RemovePolicy(string) error {
+	return nil
+}
+
+func (*FakeDataplane) UpdatePolicy(*policies.NPMNetworkPolicy) error {
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package scale
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// appLabel spreads the pods of a namespace over the apps selected by the policies.
+	appLabel = "scale-app"
+	// churnLabel is the label whose value is changed by the churn.
+	churnLabel = "scale-churn"
+)
+
+// generator synthesizes the namespaces, pods and policies of a Config in a cluster, and churns the labels of the pods.
+type generator struct {
+	cfg     *Config
+	client  kubernetes.Interface
+	tracker *convergenceTracker
+	rand    *rand.Rand
+	pods    []*corev1.Pod
+	// churnValues is the current value of the churn label of each pod
+	churnValues []int
+}
+
+func newGenerator(cfg *Config, client kubernetes.Interface, tracker *convergenceTracker) *generator {
+	return &generator{
+		cfg:     cfg,
+		client:  client,
+		tracker: tracker,
+		//nolint:gosec // the churn doesn't need a secure random generator
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+func namespaceName(i int) string {
+	return fmt.Sprintf("scale-ns-%d", i)
+}
+
+func churnValue(i int) string {
+	return fmt.Sprintf("v%d", i)
+}
+
+// podIP returns a unique IP in 10.0.0.0/8 for each of the first 2^24-2 pods.
+func podIP(i int) string {
+	n := i + 1
+	return net.IPv4(10, byte(n>>16), byte(n>>8), byte(n)).String()
+}
+
+// createObjects creates the namespaces, pods and policies, and expects the pods and policies to converge.
+func (g *generator) createObjects(ctx context.Context) error {
+	for i := 0; i < g.cfg.Namespaces; i++ {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   namespaceName(i),
+				Labels: map[string]string{"scale-ns": namespaceName(i)},
+			},
+		}
+		if _, err := g.client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create namespace %s: %w", ns.Name, err)
+		}
+	}
+
+	for i := 0; i < g.cfg.Namespaces*g.cfg.PodsPerNamespace; i++ {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pod-%d", i),
+				Namespace: namespaceName(i % g.cfg.Namespaces),
+				Labels: map[string]string{
+					appLabel:   fmt.Sprintf("app-%d", i%g.cfg.AppsPerNamespace),
+					churnLabel: churnValue(0),
+				},
+			},
+			Spec: corev1.PodSpec{NodeName: g.cfg.NodeName},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				PodIP: podIP(i),
+			},
+		}
+		g.tracker.expect(podObjectPrefix+pod.Namespace+"/"+pod.Name, churnLabel+util.IpsetLabelDelimter+churnValue(0), time.Now())
+		created, err := g.client.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create pod %s: %w", pod.Name, err)
+		}
+		g.pods = append(g.pods, created)
+		g.churnValues = append(g.churnValues, 0)
+	}
+
+	for i := 0; i < g.cfg.Policies; i++ {
+		policy := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("policy-%d", i),
+				Namespace: namespaceName(i % g.cfg.Namespaces),
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchLabels: map[string]string{appLabel: fmt.Sprintf("app-%d", i%g.cfg.AppsPerNamespace)},
+				},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From: []networkingv1.NetworkPolicyPeer{
+							{
+								NamespaceSelector: &metav1.LabelSelector{},
+								PodSelector: &metav1.LabelSelector{
+									MatchLabels: map[string]string{churnLabel: churnValue(i % g.cfg.LabelValues)},
+								},
+							},
+						},
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		}
+		g.tracker.expect(policyObjectPrefix+policy.Namespace+"/"+policy.Name, "", time.Now())
+		if _, err := g.client.NetworkingV1().NetworkPolicies(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create network policy %s: %w", policy.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// churn changes the churn label of random pods at the configured rate until ctx is done,
+// and returns the number of changes.
+func (g *generator) churn(ctx context.Context) (int, error) {
+	if g.cfg.LabelChurnPerSecond == 0 || len(g.pods) == 0 || g.cfg.LabelValues < 2 {
+		<-ctx.Done()
+		return 0, nil
+	}
+
+	ticker := time.NewTicker(time.Second / time.Duration(g.cfg.LabelChurnPerSecond))
+	defer ticker.Stop()
+
+	changes := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return changes, nil
+		case <-ticker.C:
+		}
+
+		i := g.rand.Intn(len(g.pods))
+		// pick a value different from the current one
+		value := (g.churnValues[i] + 1 + g.rand.Intn(g.cfg.LabelValues-1)) % g.cfg.LabelValues
+
+		pod := g.pods[i].DeepCopy()
+		pod.Labels[churnLabel] = churnValue(value)
+		// the fake API server doesn't bump resource versions, and the controllers ignore updates without a new one
+		pod.ResourceVersion = strconv.Itoa(changes + 1)
+		g.tracker.expect(podObjectPrefix+pod.Namespace+"/"+pod.Name, churnLabel+util.IpsetLabelDelimter+churnValue(value), time.Now())
+		updated, err := g.client.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
+		if err != nil {
+			if ctx.Err() != nil {
+				return changes, nil
+			}
+			return changes, fmt.Errorf("failed to update pod %s: %w", pod.Name, err)
+		}
+		g.pods[i] = updated
+		g.churnValues[i] = value
+		changes++
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+
+// Package scale generates synthetic namespaces, pods, policies and label churn against the NPM controllers
+// and a real or fake dataplane, and measures how long the dataplane takes to converge to each change.
+package scale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm"
+	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog"
+	"k8s.io/utils/exec"
+)
+
+const convergencePollInterval = 10 * time.Millisecond
+
+var (
+	// ErrInvalidConfig is returned by Run for a Config which can't generate a cluster.
+	ErrInvalidConfig = errors.New("invalid scale config")
+	// ErrNotConverged is returned by Run when the dataplane doesn't converge to the initial objects in time.
+	ErrNotConverged = errors.New("dataplane didn't converge")
+)
+
+// Config is the synthetic cluster and churn of a scale run.
+type Config struct {
+	Namespaces       int
+	PodsPerNamespace int
+	Policies         int
+	// AppsPerNamespace is how many groups of pods the policies select in each namespace.
+	AppsPerNamespace int
+	// LabelValues is how many values the churned label of the pods takes, and so how many ipsets it churns.
+	LabelValues int
+	// LabelChurnPerSecond is how many pod label changes are generated every second during ChurnDuration.
+	LabelChurnPerSecond int
+	ChurnDuration       time.Duration
+	// ConvergenceTimeout is how long the dataplane has to converge to the initial objects, and after the churn.
+	ConvergenceTimeout time.Duration
+	// NodeName is the node of the pods.
+	NodeName string
+	// Seed makes the churn reproducible.
+	Seed int64
+}
+
+// DefaultConfig generates a small cluster which converges within seconds on the fake dataplane.
+var DefaultConfig = Config{
+	Namespaces:          10,
+	PodsPerNamespace:    100,
+	Policies:            50,
+	AppsPerNamespace:    5,
+	LabelValues:         10,
+	LabelChurnPerSecond: 100,
+	ChurnDuration:       30 * time.Second,
+	ConvergenceTimeout:  5 * time.Minute,
+	NodeName:            "scale-node",
+	Seed:                1,
+}
+
+func (cfg *Config) validate() error {
+	switch {
+	case cfg.Namespaces <= 0:
+		return fmt.Errorf("%w: need at least one namespace", ErrInvalidConfig)
+	case cfg.PodsPerNamespace < 0 || cfg.Policies < 0 || cfg.LabelChurnPerSecond < 0 || cfg.ChurnDuration < 0:
+		return fmt.Errorf("%w: counts, rates and durations can't be negative", ErrInvalidConfig)
+	case cfg.AppsPerNamespace <= 0 || cfg.LabelValues <= 0:
+		return fmt.Errorf("%w: need at least one app and one label value", ErrInvalidConfig)
+	case cfg.LabelChurnPerSecond > int(time.Second):
+		return fmt.Errorf("%w: churn rate can't exceed one change per nanosecond", ErrInvalidConfig)
+	case cfg.ConvergenceTimeout <= 0:
+		return fmt.Errorf("%w: convergence timeout must be positive", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Latencies summarizes the convergence latencies of the changes of a phase.
+type Latencies struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func newLatencies(latencies []time.Duration) Latencies {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		return latencies[int(math.Ceil(p*float64(len(latencies))))-1]
+	}
+	return Latencies{
+		Count: len(latencies),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+		Max:   percentile(1),
+	}
+}
+
+func (l Latencies) String() string {
+	return fmt.Sprintf("count=%d p50=%v p90=%v p99=%v max=%v", l.Count, l.P50, l.P90, l.P99, l.Max)
+}
+
+// Report is the result of a scale run.
+type Report struct {
+	Pods     int
+	Policies int
+	// Bootup is how long the dataplane took to converge to all the initial objects after NPM started.
+	Bootup time.Duration
+	// Initial are the convergence latencies of the initial pods and policies, from their creation.
+	Initial Latencies
+	// LabelChanges is the number of pod label changes generated.
+	LabelChanges int
+	// Churn are the convergence latencies of the label changes.
+	Churn Latencies
+	// Superseded is the number of label changes replaced by a later change to the same pod before converging,
+	// which are not part of the latencies.
+	Superseded int
+	// NotConverged is the number of label changes which didn't converge within the timeout.
+	NotConverged int
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pods: %d policies: %d\n", r.Pods, r.Policies)
+	fmt.Fprintf(&b, "bootup: %v\n", r.Bootup)
+	fmt.Fprintf(&b, "initial convergence: %v\n", r.Initial)
+	fmt.Fprintf(&b, "label changes: %d superseded: %d not converged: %d\n", r.LabelChanges, r.Superseded, r.NotConverged)
+	fmt.Fprintf(&b, "churn convergence: %v\n", r.Churn)
+	return b.String()
+}
+
+// Run starts the v2 NPM controllers against dp and a fake API server, creates the objects of cfg,
+// waits for the dataplane to converge to them, churns the pod labels and measures the convergence of each change.
+// dp should apply synchronously, since changes are considered converged once ApplyDataPlane returns.
+func Run(ctx context.Context, cfg Config, dp dataplane.GenericDataplane) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	metrics.InitializeAll()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client := k8sfake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(client, 0)
+	tracker := newConvergenceTracker()
+	npMgr := npm.NewNetworkPolicyManager(npmconfig.DefaultConfig, factory, newTrackingDataplane(dp, tracker), exec.New(),
+		"scale", &version.Info{})
+
+	g := newGenerator(&cfg, client, tracker)
+	if err := g.createObjects(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Pods:     len(g.pods),
+		Policies: cfg.Policies,
+	}
+
+	klog.Infof("[scale] starting NPM with %d pods and %d policies", report.Pods, report.Policies)
+	start := time.Now()
+	if err := npMgr.Start(npmconfig.DefaultConfig, ctx.Done()); err != nil {
+		return nil, fmt.Errorf("failed to start NPM: %w", err)
+	}
+	if !waitForConvergence(ctx, tracker, cfg.ConvergenceTimeout) {
+		return nil, fmt.Errorf("%w: %d initial objects pending after %v", ErrNotConverged, tracker.numPending(), cfg.ConvergenceTimeout)
+	}
+	report.Bootup = time.Since(start)
+	latencies, _ := tracker.reset()
+	report.Initial = newLatencies(latencies)
+	klog.Infof("[scale] converged to the initial objects in %v", report.Bootup)
+
+	churnCtx, cancelChurn := context.WithTimeout(ctx, cfg.ChurnDuration)
+	defer cancelChurn()
+	changes, err := g.churn(churnCtx)
+	if err != nil {
+		return nil, err
+	}
+	report.LabelChanges = changes
+
+	waitForConvergence(ctx, tracker, cfg.ConvergenceTimeout)
+	report.NotConverged = tracker.numPending()
+	latencies, report.Superseded = tracker.reset()
+	report.Churn = newLatencies(latencies)
+
+	return report, nil
+}
+
+// waitForConvergence returns whether all the changes converged before the timeout.
+func waitForConvergence(ctx context.Context, tracker *convergenceTracker, timeout time.Duration) bool {
+	ticker := time.NewTicker(convergencePollInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for tracker.numPending() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package scale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConverges(t *testing.T) {
+	cfg := Config{
+		Namespaces:          3,
+		PodsPerNamespace:    10,
+		Policies:            4,
+		AppsPerNamespace:    2,
+		LabelValues:         3,
+		LabelChurnPerSecond: 200,
+		ChurnDuration:       200 * time.Millisecond,
+		ConvergenceTimeout:  10 * time.Second,
+		NodeName:            "node",
+		Seed:                1,
+	}
+
+	report, err := Run(context.Background(), cfg, &FakeDataplane{ApplyLatency: time.Millisecond})
+	require.NoError(t, err)
+
+	assert.Equal(t, 30, report.Pods)
+	assert.Equal(t, 4, report.Policies)
+	assert.Equal(t, 34, report.Initial.Count)
+	assert.Positive(t, report.LabelChanges)
+	assert.Zero(t, report.NotConverged)
+	assert.Equal(t, report.LabelChanges, report.Churn.Count+report.Superseded)
+	assert.GreaterOrEqual(t, report.Churn.Max, report.Churn.P99)
+	assert.GreaterOrEqual(t, report.Churn.P99, report.Churn.P50)
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Namespaces = 0
+	_, err := Run(context.Background(), cfg, &FakeDataplane{})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestNewLatencies(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, Latencies{
+		Count: 100,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}, newLatencies(latencies))
+	assert.Equal(t, Latencies{}, newLatencies(nil))
+}
+
+func TestConvergenceTracker(t *testing.T) {
+	tracker := newConvergenceTracker()
+	start := time.Now()
+
+	tracker.expect("pod/a", "l:v1", start)
+	// superseded by a later change
+	tracker.expect("pod/a", "l:v2", start)
+	tracker.expect("policy/p", "", start)
+
+	tracker.observe("pod/a", "l:v1", start.Add(time.Second))
+	assert.Equal(t, 2, tracker.numPending())
+	tracker.observe("pod/a", "l:v2", start.Add(2*time.Second))
+	tracker.observe("policy/p", "", start.Add(3*time.Second))
+	assert.Zero(t, tracker.numPending())
+
+	latencies, superseded := tracker.reset()
+	assert.Equal(t, []time.Duration{2 * time.Second, 3 * time.Second}, latencies)
+	assert.Equal(t, 1, superseded)
+}
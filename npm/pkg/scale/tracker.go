@@ -0,0 +1,155 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package scale
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+)
+
+const (
+	podObjectPrefix    = "pod/"
+	policyObjectPrefix = "policy/"
+)
+
+type pendingChange struct {
+	// target is the ipset the pod must be added to, or empty for a policy
+	target string
+	start  time.Time
+}
+
+// convergenceTracker records the changes generated in the cluster which the dataplane must still converge to,
+// and how long the converged ones took.
+// A change to an object replaces the pending change to the same object, since the controllers only sync the latest state.
+type convergenceTracker struct {
+	sync.Mutex
+	pending    map[string]pendingChange
+	latencies  []time.Duration
+	superseded int
+}
+
+func newConvergenceTracker() *convergenceTracker {
+	return &convergenceTracker{pending: make(map[string]pendingChange)}
+}
+
+// expect records a change to an object, which must be called before the change is written to the cluster.
+func (t *convergenceTracker) expect(objKey, target string, start time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	if _, ok := t.pending[objKey]; ok {
+		t.superseded++
+	}
+	t.pending[objKey] = pendingChange{target: target, start: start}
+}
+
+// observe marks the pending change to an object as converged if it has the target.
+func (t *convergenceTracker) observe(objKey, target string, at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	change, ok := t.pending[objKey]
+	if !ok || change.target != target {
+		return
+	}
+	t.latencies = append(t.latencies, at.Sub(change.start))
+	delete(t.pending, objKey)
+}
+
+func (t *convergenceTracker) numPending() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.pending)
+}
+
+// reset forgets the converged changes and returns their latencies and how many changes were superseded.
+func (t *convergenceTracker) reset() ([]time.Duration, int) {
+	t.Lock()
+	defer t.Unlock()
+
+	latencies, superseded := t.latencies, t.superseded
+	t.latencies = nil
+	t.superseded = 0
+	return latencies, superseded
+}
+
+// trackingDataplane passes the calls of the controllers to a dataplane and observes the generated changes in them.
+// Pod label changes converge when the dataplane is applied after the pod was added to the ipset of its new label,
+// and policies converge when they are added or updated.
+type trackingDataplane struct {
+	dataplane.GenericDataplane
+	tracker *convergenceTracker
+
+	sync.Mutex
+	// staged are the ipsets pods were added to since the last apply, by object key
+	staged map[string][]string
+}
+
+func newTrackingDataplane(dp dataplane.GenericDataplane, tracker *convergenceTracker) *trackingDataplane {
+	return &trackingDataplane{
+		GenericDataplane: dp,
+		tracker:          tracker,
+		staged:           make(map[string][]string),
+	}
+}
+
+func (dp *trackingDataplane) AddToSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *dataplane.PodMetadata) error {
+	if err := dp.GenericDataplane.AddToSets(setMetadatas, podMetadata); err != nil {
+		return err //nolint:wrapcheck // the controllers handle the errors of the dataplane
+	}
+
+	dp.Lock()
+	defer dp.Unlock()
+	objKey := podObjectPrefix + podMetadata.PodKey
+	for _, setMetadata := range setMetadatas {
+		if setMetadata.Type == ipsets.KeyValueLabelOfPod {
+			dp.staged[objKey] = append(dp.staged[objKey], setMetadata.Name)
+		}
+	}
+	return nil
+}
+
+func (dp *trackingDataplane) ApplyDataPlane() error {
+	dp.Lock()
+	staged := dp.staged
+	dp.staged = make(map[string][]string)
+	dp.Unlock()
+
+	if err := dp.GenericDataplane.ApplyDataPlane(); err != nil {
+		// the changes are applied by a later apply
+		dp.Lock()
+		for objKey, sets := range staged {
+			dp.staged[objKey] = append(dp.staged[objKey], sets...)
+		}
+		dp.Unlock()
+		return err //nolint:wrapcheck // the controllers handle the errors of the dataplane
+	}
+
+	now := time.Now()
+	for objKey, sets := range staged {
+		for _, set := range sets {
+			dp.tracker.observe(objKey, set, now)
+		}
+	}
+	return nil
+}
+
+func (dp *trackingDataplane) AddPolicy(policy *policies.NPMNetworkPolicy) error {
+	if err := dp.GenericDataplane.AddPolicy(policy); err != nil {
+		return err //nolint:wrapcheck // the controllers handle the errors of the dataplane
+	}
+	dp.tracker.observe(policyObjectPrefix+policy.PolicyKey, "", time.Now())
+	return nil
+}
+
+func (dp *trackingDataplane) UpdatePolicy(policy *policies.NPMNetworkPolicy) error {
+	if err := dp.GenericDataplane.UpdatePolicy(policy); err != nil {
+		return err //nolint:wrapcheck // the controllers handle the errors of the dataplane
+	}
+	dp.tracker.observe(policyObjectPrefix+policy.PolicyKey, "", time.Now())
+	return nil
+}
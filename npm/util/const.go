@@ -46,6 +46,9 @@ const (
 	IptablesLegacy             string = "iptables"
 	IptablesSaveLegacy         string = "iptables-save"
 	IptablesRestoreLegacy      string = "iptables-restore"
+	Ip6tablesNft               string = "ip6tables-nft"         //nolint (avoid warning to capitalize this p)
+	Ip6tablesRestoreNft        string = "ip6tables-nft-restore" //nolint (avoid warning to capitalize this p)
+	Ip6tablesRestoreLegacy     string = "ip6tables-restore"     //nolint (avoid warning to capitalize this p)
 	IptablesRestoreNoFlushFlag string = "--noflush"
 	IptablesRestoreTableFlag   string = "-T"
 	IptablesRestoreCommit      string = "COMMIT"
@@ -67,6 +70,11 @@ const (
 	IptablesDrop               string = "DROP"
 	IptablesReturn             string = "RETURN"
 	IptablesMark               string = "MARK"
+	IptablesLog                string = "LOG"
+	IptablesLogPrefixFlag      string = "--log-prefix"
+	IptablesLimitModuleFlag    string = "limit"
+	IptablesLimitFlag          string = "--limit"
+	IptablesAuditLogLimit      string = "10/second" // rate of the log entries of each rule auditing a network policy
 	IptablesSrcFlag            string = "src"
 	IptablesDstFlag            string = "dst"
 	IptablesNamedPortFlag      string = "dst,dst"
@@ -103,6 +111,7 @@ const (
 	IptablesKubeServicesChain          string = "KUBE-SERVICES"
 	IptablesForwardChain               string = "FORWARD"
 	IptablesInputChain                 string = "INPUT"
+	IptablesOutputChain                string = "OUTPUT"
 	IptablesAzureChain                 string = "AZURE-NPM"
 	IptablesAzureAcceptChain           string = "AZURE-NPM-ACCEPT"
 	IptablesAzureKubeSystemChain       string = "AZURE-NPM-KUBE-SYSTEM"
@@ -189,6 +198,7 @@ const (
 
 	AzureNpmFlag   string = "azure-npm"
 	AzureNpmPrefix string = "azure-npm-"
+	IPv6SetSuffix  string = "-ipv6"
 
 	IpsetMaxelemName string = "maxelem" // todo, what's using this?
 	IpsetMaxelemNum  string = "4294967295"
@@ -272,62 +282,99 @@ const (
 	FanOutServerID    // for v2
 )
 
-func DetectIptablesVersion(ioShim *common.IOShim) {
+// iptables backends
+const (
+	IptablesBackendNft    = "nft"
+	IptablesBackendLegacy = "legacy"
+)
+
+// IptablesDetection is what DetectIptablesVersion found about the iptables backends of the node.
+type IptablesDetection struct {
+	// Backend is the backend NPM programs, the one kubelet and kube-proxy use.
+	Backend string
+	// Mixed is whether kube rules were found in both backends, e.g. during a migration from legacy to nft.
+	// The rules of both backends then apply to the traffic, so NPM rules in one backend may not be enforced as expected.
+	Mixed bool
+}
+
+// DetectIptablesVersion sets the iptables binaries to the backend kubelet and kube-proxy use:
+// the backend with the kube hint chains in the mangle table, or else the backend with more rules.
+func DetectIptablesVersion(ioShim *common.IOShim) IptablesDetection {
 	cmd := ioShim.Exec.Command(IptablesSaveNft, "-t", "mangle")
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		fmt.Printf("Error running iptables-nft-save: %s", err)
-		return
+		return iptablesDetection(false)
+	}
+
+	if hasKubeHint(output) {
+		setIptablesBackend(IptablesBackendNft)
+		// kubelet creates the hint chains in the backend it uses, so hints in both backends mean both were used
+		lCmd := ioShim.Exec.Command(IptablesSaveLegacy, "-t", "mangle")
+		loutput, err := lCmd.CombinedOutput()
+		return iptablesDetection(err == nil && hasKubeHint(loutput))
+	}
+
+	lCmd := ioShim.Exec.Command(IptablesSaveLegacy, "-t", "mangle")
+	loutput, err := lCmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("Error running iptables-legacy-save: %s", err)
+		return iptablesDetection(false)
+	}
+
+	if hasKubeHint(loutput) {
+		setIptablesBackend(IptablesBackendLegacy)
+		return iptablesDetection(false)
+	}
+
+	nftSaveCmd := ioShim.Exec.Command(IptablesSaveNft)
+	nftSaveOutput, err := nftSaveCmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("Error running iptables-nft-save: %s", err)
+		return iptablesDetection(false)
 	}
+	nftCount := countLines(nftSaveOutput)
 
-	if strings.Contains(string(output), "KUBE-IPTABLES-HINT") || strings.Contains(string(output), "KUBE-KUBELET-CANARY") {
+	legacySaveCmd := ioShim.Exec.Command(IptablesSaveLegacy)
+	legacySaveOutput, err := legacySaveCmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("Error running iptables-legacy-save: %s", err)
+		return iptablesDetection(false)
+	}
+	legacyCount := countLines(legacySaveOutput)
+
+	if legacyCount > nftCount {
+		setIptablesBackend(IptablesBackendLegacy)
+	} else {
+		setIptablesBackend(IptablesBackendNft)
+	}
+	return iptablesDetection(nftCount > 0 && legacyCount > 0)
+}
+
+func hasKubeHint(output []byte) bool {
+	return strings.Contains(string(output), "KUBE-IPTABLES-HINT") || strings.Contains(string(output), "KUBE-KUBELET-CANARY")
+}
+
+func setIptablesBackend(backend string) {
+	if backend == IptablesBackendNft {
 		Iptables = IptablesNft
 		IptablesSave = IptablesSaveNft
 		IptablesRestore = IptablesRestoreNft
-	} else {
-		lCmd := ioShim.Exec.Command(IptablesSaveLegacy, "-t", "mangle")
-
-		loutput, err := lCmd.CombinedOutput()
-		if err != nil {
-			fmt.Printf("Error running iptables-legacy-save: %s", err)
-			return
-		}
+		return
+	}
+	Iptables = IptablesLegacy
+	IptablesSave = IptablesSaveLegacy
+	IptablesRestore = IptablesRestoreLegacy
+}
 
-		if strings.Contains(string(loutput), "KUBE-IPTABLES-HINT") || strings.Contains(string(loutput), "KUBE-KUBELET-CANARY") {
-			Iptables = IptablesLegacy
-			IptablesSave = IptablesSaveLegacy
-			IptablesRestore = IptablesRestoreLegacy
-		} else {
-			lsavecmd := ioShim.Exec.Command(IptablesSaveNft)
-			lsaveoutput, err := lsavecmd.CombinedOutput()
-			if err != nil {
-				fmt.Printf("Error running iptables-nft-save: %s", err)
-				return
-			}
-
-			lcount := countLines(lsaveoutput)
-
-			savecmd := ioShim.Exec.Command(IptablesSaveLegacy)
-			saveoutput, err := savecmd.CombinedOutput()
-			if err != nil {
-				fmt.Printf("Error running iptables-legacy-save: %s", err)
-				return
-			}
-
-			count := countLines(saveoutput)
-
-			if lcount > count {
-				Iptables = IptablesLegacy
-				IptablesSave = IptablesSaveLegacy
-				IptablesRestore = IptablesRestoreLegacy
-			} else {
-				Iptables = IptablesNft
-				IptablesSave = IptablesSaveNft
-				IptablesRestore = IptablesRestoreNft
-			}
-		}
+// iptablesDetection returns the detection of the backend the iptables binaries are set to.
+func iptablesDetection(mixed bool) IptablesDetection {
+	backend := IptablesBackendLegacy
+	if Iptables == IptablesNft {
+		backend = IptablesBackendNft
 	}
+	return IptablesDetection{Backend: backend, Mixed: mixed}
 }
 
 func countLines(output []byte) int {
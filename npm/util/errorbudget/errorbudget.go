@@ -0,0 +1,44 @@
+// Package errorbudget counts the consecutive failures of dataplane objects, so that an object which keeps failing
+// incremental updates can be rebuilt on its own instead of retried forever or resetting the whole dataplane.
+package errorbudget
+
+// DefaultMaxFailures is the number of consecutive failures an object can have before its budget is exhausted.
+const DefaultMaxFailures = 3
+
+// Budget tracks the consecutive failures of objects by key. It isn't safe for concurrent use.
+type Budget struct {
+	maxFailures int
+	failures    map[string]int
+}
+
+// New returns a Budget allowing maxFailures consecutive failures per object. Values less than 1 mean DefaultMaxFailures.
+func New(maxFailures int) *Budget {
+	if maxFailures < 1 {
+		maxFailures = DefaultMaxFailures
+	}
+	return &Budget{
+		maxFailures: maxFailures,
+		failures:    make(map[string]int),
+	}
+}
+
+// Failed records a failure of the object and returns whether its budget is now exhausted.
+func (b *Budget) Failed(key string) bool {
+	b.failures[key]++
+	return b.Exhausted(key)
+}
+
+// Reset forgets the failures of the object, after it succeeded or was deleted.
+func (b *Budget) Reset(key string) {
+	delete(b.failures, key)
+}
+
+// Exhausted returns whether the object failed at least the maximum number of consecutive times.
+func (b *Budget) Exhausted(key string) bool {
+	return b.failures[key] >= b.maxFailures
+}
+
+// Failures returns the number of consecutive failures of the object.
+func (b *Budget) Failures(key string) int {
+	return b.failures[key]
+}
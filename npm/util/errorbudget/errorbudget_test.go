@@ -0,0 +1,29 @@
+package errorbudget
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	b := New(2)
+	require.False(t, b.Failed("a"))
+	require.False(t, b.Exhausted("a"))
+	require.True(t, b.Failed("a"))
+	require.True(t, b.Exhausted("a"))
+	require.False(t, b.Exhausted("b"))
+
+	// failures must be consecutive
+	b.Reset("a")
+	require.Equal(t, 0, b.Failures("a"))
+	require.False(t, b.Failed("a"))
+}
+
+func TestDefaultMaxFailures(t *testing.T) {
+	b := New(0)
+	for i := 1; i < DefaultMaxFailures; i++ {
+		require.False(t, b.Failed("a"))
+	}
+	require.True(t, b.Failed("a"))
+}
@@ -23,7 +23,12 @@ var (
 )
 
 func AllCurrentAzureChains(exec utilexec.Interface, lockWaitTimeSeconds string) (map[string]struct{}, error) {
-	iptablesListCommand := exec.Command(util.Iptables,
+	return AllCurrentAzureChainsWithCommand(exec, util.Iptables, lockWaitTimeSeconds)
+}
+
+// AllCurrentAzureChainsWithCommand lists the Azure chains with the given iptables binary, e.g. ip6tables.
+func AllCurrentAzureChainsWithCommand(exec utilexec.Interface, iptablesCommand, lockWaitTimeSeconds string) (map[string]struct{}, error) {
+	iptablesListCommand := exec.Command(iptablesCommand,
 		util.IptablesWaitFlag, lockWaitTimeSeconds, util.IptablesTableFlag, util.IptablesFilterTable,
 		util.IptablesNumericFlag, util.IptablesListFlag,
 	)
@@ -117,6 +117,11 @@ func (creator *FileCreator) AddLine(sectionID string, errorHandlers []*LineError
 	section.lineNums = append(section.lineNums, len(creator.lines)-1)
 }
 
+// TotalLines returns the number of lines added to the FileCreator, including lines omitted after errors.
+func (creator *FileCreator) TotalLines() int {
+	return len(creator.lines)
+}
+
 // ToString combines the lines in the FileCreator and ends with a new line.
 func (creator *FileCreator) ToString() string {
 	result := strings.Builder{}
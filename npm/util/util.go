@@ -164,6 +164,12 @@ func GetHashedName(name string) string {
 	return AzureNpmPrefix + Hash(name)
 }
 
+// GetIPv6HashedName returns the name of the inet6 family ipset paired with the ipset with the given hashed name.
+// In dual-stack clusters, each ipset has an IPv6 twin which is referenced by ip6tables rules.
+func GetIPv6HashedName(hashedName string) string {
+	return GetHashedName(hashedName + IPv6SetSuffix)
+}
+
 // CompareK8sVer compares two k8s versions.
 // returns -1, 0, 1 if firstVer smaller, equals, bigger than secondVer respectively.
 // returns -2 for error.
@@ -342,6 +348,26 @@ func IsIPV4(ip string) bool {
 	return address.Is4()
 }
 
+// IsIPV6 is the IPv6 counterpart of IsIPV4. "::/0" is the only valid block with a zero-length prefix.
+func IsIPV6(ip string) bool {
+	isIPBlock := strings.Contains(ip, "/")
+	ipOnly := strings.Split(ip, "/")
+	if strings.HasSuffix(ip, "/0") && ipOnly[0] != "::" {
+		return false
+	}
+
+	address, err := netip.ParseAddr(ipOnly[0])
+	if err != nil || !address.Is6() || address.Is4In6() {
+		return false
+	}
+
+	if isIPBlock {
+		_, _, err := net.ParseCIDR(ip)
+		return err == nil
+	}
+	return true
+}
+
 // Get preferred outbound ip of this machine
 // source: https://stackoverflow.com/questions/23558425/how-do-i-get-the-local-ip-address-in-go
 func NodeIP() (string, error) {
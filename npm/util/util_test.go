@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/common"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/version"
 )
@@ -451,3 +453,69 @@ func TestNodeIP(t *testing.T) {
 	_, err := NodeIP()
 	require.Nil(t, err, "NodeIP() returned error")
 }
+
+func TestDetectIptablesVersion(t *testing.T) {
+	defer setIptablesBackend(IptablesBackendLegacy)
+
+	hint := ":KUBE-IPTABLES-HINT - [0:0]"
+	tests := []struct {
+		name     string
+		calls    []testutils.TestCmd
+		expected IptablesDetection
+	}{
+		{
+			name: "nft hint",
+			calls: []testutils.TestCmd{
+				{Cmd: []string{"iptables-nft-save", "-t", "mangle"}, Stdout: hint},
+				{Cmd: []string{"iptables-save", "-t", "mangle"}},
+			},
+			expected: IptablesDetection{Backend: IptablesBackendNft},
+		},
+		{
+			name: "hints in both backends",
+			calls: []testutils.TestCmd{
+				{Cmd: []string{"iptables-nft-save", "-t", "mangle"}, Stdout: hint},
+				{Cmd: []string{"iptables-save", "-t", "mangle"}, Stdout: hint},
+			},
+			expected: IptablesDetection{Backend: IptablesBackendNft, Mixed: true},
+		},
+		{
+			name: "legacy hint",
+			calls: []testutils.TestCmd{
+				{Cmd: []string{"iptables-nft-save", "-t", "mangle"}},
+				{Cmd: []string{"iptables-save", "-t", "mangle"}, Stdout: hint},
+			},
+			expected: IptablesDetection{Backend: IptablesBackendLegacy},
+		},
+		{
+			name: "more nft rules",
+			calls: []testutils.TestCmd{
+				{Cmd: []string{"iptables-nft-save", "-t", "mangle"}},
+				{Cmd: []string{"iptables-save", "-t", "mangle"}},
+				{Cmd: []string{"iptables-nft-save"}, Stdout: "-A FORWARD -j KUBE-FORWARD\n-A FORWARD -j KUBE-SERVICES\n"},
+				{Cmd: []string{"iptables-save"}, Stdout: "-A FORWARD -j DOCKER\n"},
+			},
+			expected: IptablesDetection{Backend: IptablesBackendNft, Mixed: true},
+		},
+		{
+			name: "more legacy rules",
+			calls: []testutils.TestCmd{
+				{Cmd: []string{"iptables-nft-save", "-t", "mangle"}},
+				{Cmd: []string{"iptables-save", "-t", "mangle"}},
+				{Cmd: []string{"iptables-nft-save"}},
+				{Cmd: []string{"iptables-save"}, Stdout: "-A FORWARD -j KUBE-FORWARD\n"},
+			},
+			expected: IptablesDetection{Backend: IptablesBackendLegacy},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			setIptablesBackend(IptablesBackendLegacy)
+			ioshim := common.NewMockIOShim(tt.calls)
+			defer ioshim.VerifyCalls(t, tt.calls)
+			require.Equal(t, tt.expected, DetectIptablesVersion(ioshim))
+			require.Equal(t, tt.expected.Backend == IptablesBackendNft, Iptables == IptablesNft)
+		})
+	}
+}
@@ -26,6 +26,8 @@ const (
 	CNIStateFilePath = "/var/run/azure-vnet.json"
 	// CNIIpamStatePath is the name of IPAM state file
 	CNIIpamStatePath = "/var/run/azure-vnet-ipam.json"
+	// CNIInterfaceInfoCachePath is the path where the interface info queried from the IPAM query URL is cached
+	CNIInterfaceInfoCachePath = "/var/run/azure-vnet-interfaceinfo.xml"
 	// CNIBinaryPath is the path to the CNI binary
 	CNIBinaryPath = "/opt/cni/bin/azure-vnet"
 	// CNSRuntimePath is the path where CNS state files are stored.
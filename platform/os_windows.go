@@ -5,15 +5,22 @@ package platform
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/Azure/azure-container-networking/log"
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
 )
 
 const (
@@ -32,6 +39,9 @@ const (
 	// CNIIpamStatePath is the name of IPAM state file
 	CNIIpamStatePath = "C:\\k\\azure-vnet-ipam.json"
 
+	// CNIInterfaceInfoCachePath is the path where the interface info queried from the IPAM query URL is cached
+	CNIInterfaceInfoCachePath = "C:\\k\\azure-vnet-interfaceinfo.xml"
+
 	// CNIBinaryPath is the path to the CNI binary
 	CNIBinaryPath = "C:\\k\\azurecni\\bin\\azure-vnet.exe"
 
@@ -55,16 +65,17 @@ const (
 	// for vlan tagged arp requests
 	SDNRemoteArpMacAddress = "12-34-56-78-9a-bc"
 
-	// Command to get SDNRemoteArpMacAddress registry key
-	GetSdnRemoteArpMacAddressCommand = "(Get-ItemProperty " +
-		"-Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name SDNRemoteArpMacAddress).SDNRemoteArpMacAddress"
+	// hnsStateRegistryKey is the HKLM registry key of the HNS state, which holds SDNRemoteArpMacAddress.
+	hnsStateRegistryKey = `SYSTEM\CurrentControlSet\Services\hns\State`
+
+	// sdnRemoteArpMacAddressValue is the registry value of the remote arp mac address.
+	sdnRemoteArpMacAddressValue = "SDNRemoteArpMacAddress"
 
-	// Command to set SDNRemoteArpMacAddress registry key
-	SetSdnRemoteArpMacAddressCommand = "Set-ItemProperty " +
-		"-Path HKLM:\\SYSTEM\\CurrentControlSet\\Services\\hns\\State -Name SDNRemoteArpMacAddress -Value \"12-34-56-78-9a-bc\""
+	// hnsServiceName is the name of the HNS service.
+	hnsServiceName = "hns"
 
-	// Command to restart HNS service
-	RestartHnsServiceCommand = "Restart-Service -Name hns"
+	// serviceStateTimeout bounds the wait for a service to stop or start.
+	serviceStateTimeout = 30 * time.Second
 )
 
 // Flag to check if sdnRemoteArpMacAddress registry key is set
@@ -75,12 +86,28 @@ func GetOSInfo() string {
 	return "windows"
 }
 
+// GetProcessSupport returns an error if the process information of the current process can't be queried.
 func GetProcessSupport() error {
-	cmd := fmt.Sprintf("Get-Process -Id %v", os.Getpid())
-	_, err := ExecutePowershellCommand(cmd)
+	_, err := processImageName(uint32(os.Getpid()))
 	return err
 }
 
+// processImageName returns the path of the executable of the process.
+func processImageName(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck // best effort
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return "", fmt.Errorf("failed to query the image name of process %d: %w", pid, err)
+	}
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
 var tickCount = syscall.NewLazyDLL("kernel32.dll").NewProc("GetTickCount64")
 
 // GetLastRebootTime returns the last time the system rebooted.
@@ -122,9 +149,8 @@ func SetOutboundSNAT(subnet string) error {
 func ClearNetworkConfiguration() (bool, error) {
 	jsonStore := CNIRuntimePath + "azure-vnet.json"
 	log.Printf("Deleting the json store %s", jsonStore)
-	cmd := exec.Command("cmd", "/c", "del", jsonStore)
 
-	if err := cmd.Run(); err != nil {
+	if err := os.Remove(jsonStore); err != nil {
 		log.Printf("Error deleting the json store %s", jsonStore)
 		return true, err
 	}
@@ -132,10 +158,37 @@ func ClearNetworkConfiguration() (bool, error) {
 	return true, nil
 }
 
+// KillProcessByName terminates the processes with the image name, e.g. azure-vnet-telemetry.exe.
 func KillProcessByName(processName string) {
-	p := NewExecClient()
-	cmd := fmt.Sprintf("taskkill /IM %v /F", processName)
-	p.ExecuteCommand(cmd)
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		log.Printf("Failed to list the processes to kill %s: %v", processName, err)
+		return
+	}
+	defer windows.CloseHandle(snapshot) //nolint:errcheck // best effort
+
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		if !strings.EqualFold(windows.UTF16ToString(entry.ExeFile[:]), processName) {
+			continue
+		}
+		if err := terminateProcess(entry.ProcessID); err != nil {
+			log.Printf("Failed to kill process %s with pid %d: %v", processName, entry.ProcessID, err)
+		}
+	}
+}
+
+func terminateProcess(pid uint32) error {
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, pid)
+	if err != nil {
+		return fmt.Errorf("failed to open process: %w", err)
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck // best effort
+
+	if err := windows.TerminateProcess(h, 1); err != nil {
+		return fmt.Errorf("failed to terminate process: %w", err)
+	}
+	return nil
 }
 
 // ExecutePowershellCommand executes powershell command
@@ -164,20 +217,26 @@ func ExecutePowershellCommand(command string) (string, error) {
 // SetSdnRemoteArpMacAddress sets the regkey for SDNRemoteArpMacAddress needed for multitenancy
 func SetSdnRemoteArpMacAddress() error {
 	if sdnRemoteArpMacAddressSet == false {
-		result, err := ExecutePowershellCommand(GetSdnRemoteArpMacAddressCommand)
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, hnsStateRegistryKey, registry.QUERY_VALUE|registry.SET_VALUE)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to open the HNS state registry key: %w", err)
+		}
+		defer key.Close()
+
+		result, _, err := key.GetStringValue(sdnRemoteArpMacAddressValue)
+		if err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("failed to read SDNRemoteArpMacAddress: %w", err)
 		}
 
 		// Set the reg key if not already set or has incorrect value
 		if result != SDNRemoteArpMacAddress {
-			if _, err = ExecutePowershellCommand(SetSdnRemoteArpMacAddressCommand); err != nil {
+			if err = key.SetStringValue(sdnRemoteArpMacAddressValue, SDNRemoteArpMacAddress); err != nil {
 				log.Printf("Failed to set SDNRemoteArpMacAddress due to error %s", err.Error())
 				return err
 			}
 
 			log.Printf("[Azure CNS] SDNRemoteArpMacAddress regKey set successfully. Restarting hns service.")
-			if _, err := ExecutePowershellCommand(RestartHnsServiceCommand); err != nil {
+			if err := restartService(hnsServiceName); err != nil {
 				log.Printf("Failed to Restart HNS Service due to error %s", err.Error())
 				return err
 			}
@@ -189,35 +248,76 @@ func SetSdnRemoteArpMacAddress() error {
 	return nil
 }
 
+// restartService stops the service, if running, and starts it again.
+func restartService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect() //nolint:errcheck // best effort
+
+	service, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", name, err)
+	}
+	defer service.Close()
+
+	status, err := service.Query()
+	if err != nil {
+		return fmt.Errorf("failed to query service %s: %w", name, err)
+	}
+	if status.State != svc.Stopped {
+		if _, err := service.Control(svc.Stop); err != nil {
+			return fmt.Errorf("failed to stop service %s: %w", name, err)
+		}
+		if err := waitForServiceState(service, svc.Stopped); err != nil {
+			return err
+		}
+	}
+
+	if err := service.Start(); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", name, err)
+	}
+	return waitForServiceState(service, svc.Running)
+}
+
+func waitForServiceState(service *mgr.Service, state svc.State) error {
+	deadline := time.Now().Add(serviceStateTimeout)
+	for {
+		status, err := service.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query service %s: %w", service.Name, err)
+		}
+		if status.State == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s didn't reach state %d within %v", service.Name, state, serviceStateTimeout)
+		}
+		time.Sleep(100 * time.Millisecond) //nolint:gomnd // polling interval
+	}
+}
+
 func GetOSDetails() (map[string]string, error) {
 	return nil, nil
 }
 
+// GetProcessNameByID returns the name of the executable of the process, without its extension like Get-Process.
 func GetProcessNameByID(pidstr string) (string, error) {
 	pidstr = strings.Trim(pidstr, "\r\n")
-	cmd := fmt.Sprintf("Get-Process -Id %s|Format-List", pidstr)
-	out, err := ExecutePowershellCommand(cmd)
+	pid, err := strconv.ParseUint(pidstr, 10, 32)
 	if err != nil {
-		log.Printf("Process is not running. Output:%v, Error %v", out, err)
-		return "", err
+		return "", fmt.Errorf("invalid pid %q: %w", pidstr, err)
 	}
 
-	if len(out) <= 0 {
-		log.Printf("Output length is 0")
-		return "", fmt.Errorf("get-process output length is 0")
-	}
-
-	lines := strings.Split(out, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "Name") {
-			pName := strings.Split(line, ":")
-			if len(pName) > 1 {
-				return strings.TrimSpace(pName[1]), nil
-			}
-		}
+	path, err := processImageName(uint32(pid))
+	if err != nil {
+		log.Printf("Process is not running. Error %v", err)
+		return "", err
 	}
 
-	return "", fmt.Errorf("Process not found")
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name)), nil
 }
 
 func PrintDependencyPackageDetails() {
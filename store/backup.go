@@ -0,0 +1,177 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BackupExtension - Extension of the backups of a store file.
+	BackupExtension = ".bak"
+	// ChecksumExtension - Extension of the file holding the sha256 checksum of a backup.
+	ChecksumExtension = ".sha256"
+
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+var (
+	ErrNoBackup         = errors.New("no backup found")
+	ErrBackupCorrupted  = errors.New("backup checksum mismatch")
+	ErrBackupNoChecksum = errors.New("backup has no checksum")
+)
+
+// snapshotter is implemented by the stores whose file can't be copied while they're in use.
+type snapshotter interface {
+	snapshot() ([]byte, error)
+}
+
+// Backup copies the store file to a timestamped backup in dir, next to a file holding its sha256 checksum,
+// and removes all but the newest keep backups of the store file. It returns the path of the new backup.
+func Backup(fileName, dir string, keep int) (string, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read store file %s", fileName)
+	}
+	return backup(fileName, dir, keep, b)
+}
+
+// BackupStore backs up the file of the store like Backup, from a consistent snapshot of the store when its file
+// can't be copied while it's in use.
+func BackupStore(kvs KeyValueStore, fileName, dir string, keep int) (string, error) {
+	s, ok := kvs.(snapshotter)
+	if !ok {
+		return Backup(fileName, dir, keep)
+	}
+	b, err := s.snapshot()
+	if err != nil {
+		return "", err
+	}
+	return backup(fileName, dir, keep, b)
+}
+
+func backup(fileName, dir string, keep int, b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", ErrStoreEmpty
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gomnd // directory permissions
+		return "", errors.Wrapf(err, "failed to create backup directory %s", dir)
+	}
+
+	path := filepath.Join(dir, filepath.Base(fileName)+"."+time.Now().UTC().Format(backupTimeFormat)+BackupExtension)
+	if err := writeFileAtomic(path, b); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	if err := writeFileAtomic(path+ChecksumExtension, []byte(hex.EncodeToString(sum[:]))); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	backups, err := ListBackups(fileName, dir)
+	if err != nil {
+		return path, err
+	}
+	for i := keep; i < len(backups); i++ {
+		_ = os.Remove(backups[i])
+		_ = os.Remove(backups[i] + ChecksumExtension)
+	}
+	return path, nil
+}
+
+// ListBackups returns the backups of the store file in dir, newest first.
+func ListBackups(fileName, dir string) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, filepath.Base(fileName)+".*"+BackupExtension))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list backups")
+	}
+	// the timestamps sort lexically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// VerifyBackup returns the contents of the backup if they match its checksum.
+func VerifyBackup(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read backup %s", path)
+	}
+	want, err := os.ReadFile(path + ChecksumExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrap(ErrBackupNoChecksum, path)
+		}
+		return nil, errors.Wrapf(err, "failed to read checksum of backup %s", path)
+	}
+	sum := sha256.Sum256(b)
+	if hex.EncodeToString(sum[:]) != strings.TrimSpace(string(want)) {
+		return nil, errors.Wrap(ErrBackupCorrupted, path)
+	}
+	return b, nil
+}
+
+// RestoreBackup replaces the store file with the backup after verifying its checksum.
+// If path is empty, the newest intact backup of the store file in dir is restored.
+// The store must not be in use while it is restored. It returns the path of the restored backup.
+func RestoreBackup(fileName, dir, path string) (string, error) {
+	if path != "" {
+		b, err := VerifyBackup(path)
+		if err != nil {
+			return "", err
+		}
+		return path, writeFileAtomic(fileName, b)
+	}
+
+	backups, err := ListBackups(fileName, dir)
+	if err != nil {
+		return "", err
+	}
+	for _, backup := range backups {
+		b, err := VerifyBackup(backup)
+		if err != nil {
+			continue
+		}
+		return backup, writeFileAtomic(fileName, b)
+	}
+	return "", errors.Wrapf(ErrNoBackup, "no intact backup of %s in %s", fileName, dir)
+}
+
+// writeFileAtomic writes the file through a temp file in the same directory.
+func writeFileAtomic(fileName string, b []byte) error {
+	dir, file := filepath.Split(fileName)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, file)
+	if err != nil {
+		return errors.Wrap(err, "cannot create temp file")
+	}
+	tmpFileName := f.Name()
+
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		_ = os.Remove(tmpFileName)
+		return errors.Wrap(err, "temp file write failed")
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(tmpFileName)
+		return errors.Wrap(err, "temp file close failed")
+	}
+	if err = platform.ReplaceFile(tmpFileName, fileName); err != nil {
+		_ = os.Remove(tmpFileName)
+		return errors.Wrapf(err, "failed to replace %s", fileName)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupRotation(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "state.json")
+	backupDir := filepath.Join(dir, "backups")
+
+	var paths []string
+	for _, contents := range []string{`{"v":1}`, `{"v":2}`, `{"v":3}`} {
+		require.NoError(t, os.WriteFile(fileName, []byte(contents), 0o600))
+		path, err := Backup(fileName, backupDir, 2)
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+
+	backups, err := ListBackups(fileName, backupDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{paths[2], paths[1]}, backups)
+	_, err = os.Stat(paths[0] + ChecksumExtension)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "state.json")
+	backupDir := filepath.Join(dir, "backups")
+
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"v":1}`), 0o600))
+	older, err := Backup(fileName, backupDir, 3)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"v":2}`), 0o600))
+	newer, err := Backup(fileName, backupDir, 3)
+	require.NoError(t, err)
+
+	// the newest backup is restored
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"v":`), 0o600))
+	restored, err := RestoreBackup(fileName, backupDir, "")
+	require.NoError(t, err)
+	require.Equal(t, newer, restored)
+	b, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, `{"v":2}`, string(b))
+
+	// a corrupted backup is skipped
+	require.NoError(t, os.WriteFile(newer, []byte(`{"v":3}`), 0o600))
+	_, err = VerifyBackup(newer)
+	require.ErrorIs(t, err, ErrBackupCorrupted)
+	restored, err = RestoreBackup(fileName, backupDir, "")
+	require.NoError(t, err)
+	require.Equal(t, older, restored)
+	b, err = os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.Equal(t, `{"v":1}`, string(b))
+
+	// a corrupted backup isn't restored explicitly either
+	_, err = RestoreBackup(fileName, backupDir, newer)
+	require.ErrorIs(t, err, ErrBackupCorrupted)
+
+	require.NoError(t, os.Remove(older+ChecksumExtension))
+	_, err = RestoreBackup(fileName, backupDir, "")
+	require.ErrorIs(t, err, ErrNoBackup)
+}
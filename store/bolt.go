@@ -0,0 +1,230 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// MigratedExtension is added to the file name of the JSON store once migrated to a bolt store.
+	MigratedExtension = ".migrated"
+
+	// boltOpenTimeout is how long to wait for another process to close the bolt file.
+	boltOpenTimeout = 10 * time.Second
+	boltFilePerm    = 0o600
+)
+
+// boltBucket is the bucket of the keys of the store.
+var boltBucket = []byte("store")
+
+// boltStore is an implementation of KeyValueStore using an embedded bolt database, for stores which are written often:
+// every write is a transaction committing only the pages of the key, rather than a rewrite of the whole store file,
+// and a crash leaves the last committed transaction.
+type boltStore struct {
+	fileName    string
+	db          *bolt.DB
+	processLock processlock.Interface
+	sync.Mutex
+}
+
+// NewBoltStore creates a new boltStore object, accessed as a KeyValueStore. If the bolt file is empty and the JSON
+// store file exists, its keys are migrated to the bolt file in a single transaction and the JSON store file is renamed
+// with the MigratedExtension, so that it isn't read again.
+func NewBoltStore(fileName, jsonFileName string, lockclient processlock.Interface) (KeyValueStore, error) {
+	if fileName == "" {
+		return &boltStore{}, errors.New("need to pass in a bolt file path")
+	}
+	db, err := bolt.Open(fileName, boltFilePerm, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return &boltStore{}, errors.Wrapf(err, "failed to open bolt file %s", fileName)
+	}
+	kvs := &boltStore{
+		fileName:    fileName,
+		db:          db,
+		processLock: lockclient,
+	}
+
+	if jsonFileName != "" {
+		if err := kvs.migrate(jsonFileName); err != nil {
+			_ = db.Close()
+			return &boltStore{}, err
+		}
+	}
+	return kvs, nil
+}
+
+// migrate copies the keys of the JSON store file, or of its previous snapshot, to the empty bolt file.
+func (kvs *boltStore) migrate(jsonFileName string) error {
+	if kvs.Exists() {
+		return nil
+	}
+	data, err := readStoreFile(jsonFileName)
+	if err != nil {
+		snapshot, snapshotErr := readStoreFile(jsonFileName + SnapshotExtension)
+		if snapshotErr != nil {
+			if errors.Is(err, ErrKeyNotFound) {
+				// there's nothing to migrate
+				return nil
+			}
+			return errors.Wrapf(err, "failed to read store file %s to migrate", jsonFileName)
+		}
+		data = snapshot
+	}
+
+	err = kvs.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		for key, raw := range data {
+			if raw == nil {
+				continue
+			}
+			if err := b.Put([]byte(key), *raw); err != nil {
+				return err //nolint:wrapcheck // wrapped below
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to migrate store file %s", jsonFileName)
+	}
+
+	for _, name := range []string{jsonFileName, jsonFileName + SnapshotExtension} {
+		if err := os.Rename(name, name+MigratedExtension); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to rename migrated store file %s", name)
+		}
+	}
+	log.Printf("Migrated %d keys of store file %s to %s", len(data), jsonFileName, kvs.fileName)
+	return nil
+}
+
+// Exists returns whether the store has any key.
+func (kvs *boltStore) Exists() bool {
+	exists := false
+	_ = kvs.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(boltBucket); b != nil {
+			k, _ := b.Cursor().First()
+			exists = k != nil
+		}
+		return nil
+	})
+	return exists
+}
+
+// Read restores the value for the given key from persistent store.
+func (kvs *boltStore) Read(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return kvs.db.View(func(tx *bolt.Tx) error { //nolint:wrapcheck // the errors are returned as is
+		b := tx.Bucket(boltBucket)
+		if b == nil {
+			return ErrKeyNotFound
+		}
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return ErrKeyNotFound
+		}
+		// the value is only valid in the transaction, and it's decoded before returning
+		return json.Unmarshal(raw, value)
+	})
+}
+
+// Write saves the given key value pair to persistent store, in a transaction synced to disk.
+func (kvs *boltStore) Write(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err //nolint:wrapcheck // the errors are returned as is, like the JSON store
+	}
+	err = kvs.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped below
+		}
+		return b.Put([]byte(key), raw) //nolint:wrapcheck // wrapped below
+	})
+	return errors.Wrapf(err, "failed to write key %s", key)
+}
+
+// Flush does nothing, since every write is committed.
+func (kvs *boltStore) Flush() error {
+	return nil
+}
+
+// snapshot returns a consistent copy of the bolt file, which can't be copied while it's written.
+func (kvs *boltStore) snapshot() ([]byte, error) {
+	var buf bytes.Buffer
+	err := kvs.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(&buf)
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to snapshot bolt file %s", kvs.fileName)
+	}
+	return buf.Bytes(), nil
+}
+
+// Lock locks the store for exclusive access.
+func (kvs *boltStore) Lock(timeout time.Duration) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	status := make(chan error, 1)
+	go func() {
+		status <- kvs.processLock.Lock()
+	}()
+
+	select {
+	case <-time.After(timeout):
+		return ErrTimeoutLockingStore
+	case err := <-status:
+		return errors.Wrap(err, "processLock acquire error")
+	}
+}
+
+// Unlock unlocks the store.
+func (kvs *boltStore) Unlock() error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	return errors.Wrap(kvs.processLock.Unlock(), "unlock error")
+}
+
+// GetModificationTime returns the modification time of the persistent store.
+func (kvs *boltStore) GetModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	info, err := os.Stat(kvs.fileName)
+	if err != nil {
+		log.Printf("os.stat() for file %v failed: %v", kvs.fileName, err)
+		return time.Time{}.UTC(), err //nolint:wrapcheck // like the JSON store
+	}
+
+	return info.ModTime().UTC(), nil
+}
+
+// Remove closes and removes the bolt file. The store can't be used afterwards.
+func (kvs *boltStore) Remove() {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if err := kvs.db.Close(); err != nil {
+		log.Errorf("could not close file %s. Error: %v", kvs.fileName, err)
+	}
+	if err := os.Remove(kvs.fileName); err != nil {
+		log.Errorf("could not remove file %s. Error: %v", kvs.fileName, err)
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStoreReadWrite(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.db")
+	kvs, err := NewBoltStore(fileName, "", processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.False(t, kvs.Exists())
+
+	var value testType1
+	require.ErrorIs(t, kvs.Read("key", &value), ErrKeyNotFound)
+
+	require.NoError(t, kvs.Write("key", &testType1{Field1: "value", Field2: 1}))
+	require.True(t, kvs.Exists())
+	require.NoError(t, kvs.Read("key", &value))
+	require.Equal(t, testType1{Field1: "value", Field2: 1}, value)
+	require.ErrorIs(t, kvs.Read("other", &value), ErrKeyNotFound)
+
+	kvs.Remove()
+	_, err = os.Stat(fileName)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestBoltStoreMigration(t *testing.T) {
+	dir := t.TempDir()
+	jsonFileName := filepath.Join(dir, "state.json")
+	fileName := filepath.Join(dir, "state.db")
+
+	jsonStore, err := NewJsonFileStore(jsonFileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, jsonStore.Write("key", &testType1{Field1: "value", Field2: 1}))
+
+	kvs, err := NewBoltStore(fileName, jsonFileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read("key", &value))
+	require.Equal(t, testType1{Field1: "value", Field2: 1}, value)
+
+	// the JSON store file isn't read again
+	_, err = os.Stat(jsonFileName)
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(jsonFileName + MigratedExtension)
+	require.NoError(t, err)
+
+	// the state survives reopening the bolt file
+	require.NoError(t, kvs.(*boltStore).db.Close())
+	kvs, err = NewBoltStore(fileName, jsonFileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Read("key", &value))
+	require.Equal(t, testType1{Field1: "value", Field2: 1}, value)
+	kvs.Remove()
+}
+
+func TestBackupBoltStore(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "state.db")
+	backupDir := filepath.Join(dir, "backups")
+
+	kvs, err := NewBoltStore(fileName, "", processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write("key", &testType1{Field1: "value", Field2: 1}))
+	path, err := BackupStore(kvs, fileName, backupDir, 1)
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write("key", &testType1{Field1: "other", Field2: 2}))
+	require.NoError(t, kvs.(*boltStore).db.Close())
+
+	// the restored snapshot is a valid bolt file
+	restored, err := RestoreBackup(fileName, backupDir, "")
+	require.NoError(t, err)
+	require.Equal(t, path, restored)
+	kvs, err = NewBoltStore(fileName, "", processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read("key", &value))
+	require.Equal(t, testType1{Field1: "value", Field2: 1}, value)
+	kvs.Remove()
+}
@@ -6,7 +6,7 @@ package store
 import (
 	"encoding/json"
 	"fmt"
-	"io"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"sync"
@@ -24,8 +24,28 @@ const (
 
 	// DefaultLockTimeout - lock timeout in milliseconds
 	DefaultLockTimeout = 10000 * time.Millisecond
+
+	// SnapshotExtension - Extension added to the file name for the previous snapshot of the store,
+	// which is read if the store file is torn or corrupted.
+	SnapshotExtension = ".prev"
+
+	// headerKey is the key of the header embedded in the store file.
+	headerKey = "StoreHeader"
+	// storeVersion is the version of the format of the store file.
+	storeVersion = 1
 )
 
+// ErrStoreCorrupted is returned when the store file doesn't match its checksum or can't be decoded.
+var ErrStoreCorrupted = errors.New("store is corrupted")
+
+// header is embedded in the store file to detect torn and corrupted writes.
+// Files without a header, written by older versions, are trusted if they can be decoded.
+type header struct {
+	Version int
+	// CRC32 is the IEEE checksum of the compact JSON encoding of all other keys.
+	CRC32 uint32
+}
+
 // jsonFileStore is an implementation of KeyValueStore using a local JSON file.
 type jsonFileStore struct {
 	fileName    string
@@ -50,11 +70,15 @@ func NewJsonFileStore(fileName string, lockclient processlock.Interface) (KeyVal
 	return kvs, nil
 }
 
+// Exists returns whether the store file or its previous snapshot exists.
 func (kvs *jsonFileStore) Exists() bool {
-	if _, err := os.Stat(kvs.fileName); err != nil {
-		return false
+	if _, err := os.Stat(kvs.fileName); err == nil {
+		return true
+	}
+	if _, err := os.Stat(kvs.fileName + SnapshotExtension); err == nil {
+		return true
 	}
-	return true
+	return false
 }
 
 // Read restores the value for the given key from persistent store.
@@ -64,31 +88,23 @@ func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 
 	// Read contents from file if memory is not in sync.
 	if !kvs.inSync {
-		// Open and parse the file if it exists.
-		file, err := os.Open(kvs.fileName)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return ErrKeyNotFound
-			}
+		data, err := readStoreFile(kvs.fileName)
+		if errors.Is(err, ErrStoreEmpty) {
+			// an empty store file isn't recovered from the snapshot, it's written to request a reset of the store
 			return err
 		}
-		defer file.Close()
-
-		b, err := io.ReadAll(file)
 		if err != nil {
-			return err
-		}
-
-		if len(b) == 0 {
-			log.Printf("Unable to read file %s, was empty", kvs.fileName)
-			return ErrStoreEmpty
-		}
-
-		// Decode to raw JSON messages.
-		if err := json.Unmarshal(b, &kvs.data); err != nil {
-			return err
+			// fall back to the previous snapshot if the store file was torn or corrupted by a crash,
+			// or is missing because the crash happened between moving it to the snapshot and replacing it.
+			snapshot, snapshotErr := readStoreFile(kvs.fileName + SnapshotExtension)
+			if snapshotErr != nil {
+				return err
+			}
+			log.Errorf("Failed to read store file %s, recovered the previous snapshot: %v", kvs.fileName, err)
+			data = snapshot
 		}
 
+		kvs.data = data
 		kvs.inSync = true
 	}
 
@@ -125,8 +141,10 @@ func (kvs *jsonFileStore) Flush() error {
 }
 
 // Lock-free flush for internal callers.
+// The state is written to a synced temp file, the store file is kept as the previous snapshot,
+// and the temp file replaces it. A crash at any point leaves an intact store file or snapshot.
 func (kvs *jsonFileStore) flush() error {
-	buf, err := json.MarshalIndent(&kvs.data, "", "\t")
+	buf, err := encodeStore(kvs.data)
 	if err != nil {
 		return err
 	}
@@ -156,18 +174,110 @@ func (kvs *jsonFileStore) flush() error {
 		return fmt.Errorf("Temp file write failed with: %v", err)
 	}
 
+	// the contents must be on disk before the rename, or a crash can leave a renamed but empty file
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("temp file sync failed with: %v", err)
+	}
+
 	if err = f.Close(); err != nil {
 		return fmt.Errorf("temp file close failed with: %v", err)
 	}
 
+	// keep the current store file as the previous snapshot, unless it is corrupted
+	if _, readErr := readStoreFile(kvs.fileName); readErr == nil {
+		if err = platform.ReplaceFile(kvs.fileName, kvs.fileName+SnapshotExtension); err != nil {
+			return fmt.Errorf("rename state file to snapshot failed:%v", err)
+		}
+	}
+
 	// atomic replace
 	if err = platform.ReplaceFile(tmpFileName, kvs.fileName); err != nil {
 		return fmt.Errorf("rename temp file to state file failed:%v", err)
 	}
 
+	syncDir(dir)
 	return nil
 }
 
+// encodeStore encodes the data with a header holding its checksum.
+func encodeStore(data map[string]*json.RawMessage) ([]byte, error) {
+	compact, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	h, err := json.Marshal(header{Version: storeVersion, CRC32: crc32.ChecksumIEEE(compact)})
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(h)
+
+	withHeader := make(map[string]*json.RawMessage, len(data)+1)
+	for k, v := range data {
+		withHeader[k] = v
+	}
+	withHeader[headerKey] = &raw
+	return json.MarshalIndent(withHeader, "", "\t")
+}
+
+// readStoreFile reads and verifies the store file, and returns its data without the header.
+// It returns ErrKeyNotFound if the file doesn't exist.
+func readStoreFile(fileName string) (map[string]*json.RawMessage, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		log.Printf("Unable to read file %s, was empty", fileName)
+		return nil, ErrStoreEmpty
+	}
+
+	// Decode to raw JSON messages.
+	data := make(map[string]*json.RawMessage)
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, errors.Wrapf(ErrStoreCorrupted, "failed to decode %s: %v", fileName, err)
+	}
+
+	raw, ok := data[headerKey]
+	if !ok {
+		return data, nil
+	}
+	delete(data, headerKey)
+
+	var h header
+	if raw == nil {
+		return nil, errors.Wrapf(ErrStoreCorrupted, "%s has an empty header", fileName)
+	}
+	if err := json.Unmarshal(*raw, &h); err != nil {
+		return nil, errors.Wrapf(ErrStoreCorrupted, "failed to decode header of %s: %v", fileName, err)
+	}
+	if h.Version > storeVersion {
+		return nil, errors.Errorf("%s has unsupported version %d", fileName, h.Version)
+	}
+	compact, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(compact) != h.CRC32 {
+		return nil, errors.Wrapf(ErrStoreCorrupted, "%s doesn't match its checksum", fileName)
+	}
+	return data, nil
+}
+
+// syncDir syncs the directory so that the renames in it are durable.
+// Errors are ignored: directories can't be synced on Windows, where renames are written through instead.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
 func (kvs *jsonFileStore) lockUtil(status chan error) {
 	err := kvs.processLock.Lock()
 	status <- err
@@ -232,5 +342,8 @@ func (kvs *jsonFileStore) Remove() {
 	if err := os.Remove(kvs.fileName); err != nil {
 		log.Errorf("could not remove file %s. Error: %v", kvs.fileName, err)
 	}
+	if err := os.Remove(kvs.fileName + SnapshotExtension); err != nil && !os.IsNotExist(err) {
+		log.Errorf("could not remove file %s. Error: %v", kvs.fileName+SnapshotExtension, err)
+	}
 	kvs.Mutex.Unlock()
 }
@@ -70,7 +70,7 @@ func TestKeyValuePairsAreReinstantiatedFromJSONFile(t *testing.T) {
 // Tests that the key value pairs written to the store are persisted correctly in JSON encoded file.
 func TestKeyValuePairsArePersistedToJSONFile(t *testing.T) {
 	writtenValue := testType1{"test", 42}
-	expectedPair := `{"key1":{"Field1":"test","Field2":42}}`
+	expectedPair := `{"StoreHeader":{"Version":1,"CRC32":655233881},"key1":{"Field1":"test","Field2":42}}`
 	var actualPair string
 
 	// Create the store.
@@ -91,7 +91,7 @@ func TestKeyValuePairsArePersistedToJSONFile(t *testing.T) {
 		t.Fatalf("Failed to open file %v", err)
 	}
 
-	data := make([]byte, 100)
+	data := make([]byte, 200)
 	n, err := file.Read(data)
 	if err != nil {
 		t.Fatalf("Failed to read from file %v", err)
@@ -151,6 +151,7 @@ func TestKeyValuePairsAreWrittenAndReadCorrectly(t *testing.T) {
 
 	// Cleanup.
 	os.Remove(testFileName)
+	os.Remove(testFileName + SnapshotExtension)
 }
 
 // test case for testing newjsonfilestore idempotent
@@ -233,3 +234,86 @@ func TestFileName(t *testing.T) {
 		t.Fatalf("This should not fail for a non-empty file %v", err)
 	}
 }
+
+// Tests that a store file torn, corrupted or removed by a crash is recovered from the previous snapshot.
+func TestRecoverPreviousSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, fileName string)
+	}{
+		{
+			name: "torn write",
+			corrupt: func(t *testing.T, fileName string) {
+				b, err := os.ReadFile(fileName)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(fileName, b[:len(b)/2], 0o600))
+			},
+		},
+		{
+			name: "checksum mismatch",
+			corrupt: func(t *testing.T, fileName string) {
+				b, err := os.ReadFile(fileName)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(fileName, []byte(strings.Replace(string(b), "43", "44", 1)), 0o600))
+			},
+		},
+		{
+			name: "missing file",
+			corrupt: func(t *testing.T, fileName string) {
+				require.NoError(t, os.Remove(fileName))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := t.TempDir() + "/test.json"
+			kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+			require.NoError(t, err)
+			require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+			require.NoError(t, kvs.Write(testKey1, &testType1{"test", 43}))
+
+			tt.corrupt(t, fileName)
+
+			kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+			require.NoError(t, err)
+			require.True(t, kvs.Exists())
+			var value testType1
+			require.NoError(t, kvs.Read(testKey1, &value))
+			require.Equal(t, testType1{"test", 42}, value)
+
+			// the next write replaces the corrupted file, and keeps the intact snapshot
+			require.NoError(t, kvs.Write(testKey2, &testType1{"test", 1}))
+			snapshot, err := readStoreFile(fileName + SnapshotExtension)
+			require.NoError(t, err)
+			require.Contains(t, snapshot, testKey1)
+			require.NotContains(t, snapshot, testKey2)
+		})
+	}
+}
+
+func TestCorruptedStoreWithoutSnapshot(t *testing.T) {
+	fileName := t.TempDir() + "/test.json"
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"key1":`), 0o600))
+
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrStoreCorrupted)
+}
+
+// Tests that an empty store file, which requests a reset of the store, isn't recovered from the previous snapshot.
+func TestEmptyStoreWithSnapshot(t *testing.T) {
+	fileName := t.TempDir() + "/test.json"
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 43}))
+	require.NoError(t, os.WriteFile(fileName, nil, 0o600))
+
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrStoreEmpty)
+}
@@ -3,6 +3,7 @@ package telemetry
 
 import (
 	"errors"
+	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/log"
@@ -16,6 +17,9 @@ var (
 	ErrTelemetryDisabled = errors.New("telemetry is disabled")
 )
 
+// metrics batches the metrics over the BatchInterval of the AI config, if it's set.
+var metrics *metricBuffer
+
 const (
 	// Wait time for AI to gracefully close AI telemetry session
 	waitTimeInSecs = 10
@@ -36,6 +40,11 @@ func CreateAITelemetryHandle(aiConfig aitelemetry.AIConfig, disableAll, disableM
 
 	gDisableMetric = disableMetric
 	gDisableTrace = disableTrace
+
+	if aiConfig.BatchInterval > 0 && !disableMetric {
+		metrics = newMetricBuffer(th.TrackMetric)
+		go metrics.run(time.Duration(aiConfig.BatchInterval) * time.Second)
+	}
 	return nil
 }
 
@@ -57,27 +66,44 @@ func SendAITelemetry(cnireport CNIReport) {
 		Message:          msg,
 		Context:          cnireport.ContainerName,
 		AppVersion:       cnireport.Version,
-		CustomDimensions: make(map[string]string),
+		CustomDimensions: reportDimensions(&cnireport),
 	}
 
-	report.CustomDimensions[ContextStr] = cnireport.Context
-	report.CustomDimensions[SubContextStr] = cnireport.SubContext
-	report.CustomDimensions[VMUptimeStr] = cnireport.VMUptime
-	report.CustomDimensions[OperationTypeStr] = cnireport.OperationType
-	report.CustomDimensions[VersionStr] = cnireport.Version
-
 	th.TrackLog(report)
 }
 
+// reportDimensions returns the dimensions the report is sent with, to Application Insights or an OTLP collector.
+func reportDimensions(cnireport *CNIReport) map[string]string {
+	dimensions := map[string]string{
+		ContextStr:       cnireport.Context,
+		SubContextStr:    cnireport.SubContext,
+		VMUptimeStr:      cnireport.VMUptime,
+		OperationTypeStr: cnireport.OperationType,
+		VersionStr:       cnireport.Version,
+	}
+	if cnireport.Client != "" {
+		dimensions[ClientStr] = cnireport.Client
+	}
+	return dimensions
+}
+
 func SendAIMetric(aiMetric AIMetric) {
 	if th == nil || gDisableMetric {
 		return
 	}
 
+	if metrics != nil {
+		metrics.add(aiMetric.Metric)
+		return
+	}
 	th.TrackMetric(aiMetric.Metric)
 }
 
 func CloseAITelemetryHandle() {
+	if metrics != nil {
+		metrics.close()
+		metrics = nil
+	}
 	if th != nil {
 		th.Close(waitTimeInSecs)
 	}
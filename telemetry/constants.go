@@ -9,6 +9,14 @@ const (
 	CNIDelTimeMetricStr    = "CNIDelTimeMs"
 	CNIUpdateTimeMetricStr = "CNIUpdateTimeMs"
 	CNILockTimeoutStr      = "CNILockTimeoutError"
+	// CNINetworkRepairedMetricStr counts the networks whose missing host configuration was rebuilt on ADD
+	CNINetworkRepairedMetricStr = "CNINetworkRepaired"
+
+	// Disk usage metric names
+	CNILogSizeMetricStr             = "CNILogSizeBytes"
+	CNIStateFileSizeMetricStr       = "CNIStateFileSizeBytes"
+	CNIStaleLockFilesMetricStr      = "CNIStaleLockFiles"
+	CNILogRotationFailuresMetricStr = "CNILogRotationFailures"
 
 	// Dimension Names
 	ContextStr        = "Context"
@@ -20,6 +28,24 @@ const (
 	CNIModeStr        = "CNIMode"
 	CNINetworkModeStr = "CNINetworkMode"
 	OSTypeStr         = "OSType"
+	// FileStr is the name of the file a disk usage metric is about.
+	FileStr = "File"
+	// ClientStr is the name the client which sent the report to the telemetry service identified itself with.
+	ClientStr = "Client"
+	// DatapathStr is the datapath of the endpoint of the pod, if acceleration was requested for it.
+	DatapathStr = "Datapath"
+
+	// PhaseDurationSuffixStr is appended to a phase name to form its duration dimension, e.g. IPAMDurationMs
+	PhaseDurationSuffixStr = "DurationMs"
+
+	// Phases of a CNI operation
+	PhaseLockWait = "LockWait"
+	PhaseIPAM     = "IPAM"
+	// PhaseNetns is the time spent inside the container network namespace. Linux only, a subset of PhaseDataplane.
+	PhaseNetns = "Netns"
+	// PhaseDataplane is the time spent programming networks and endpoints through HNS or netlink.
+	PhaseDataplane = "Dataplane"
+	PhaseDNS       = "DNS"
 
 	// Values
 	SucceededStr     = "Succeeded"
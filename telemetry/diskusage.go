@@ -0,0 +1,142 @@
+// Copyright Microsoft. All rights reserved.
+package telemetry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// cniLogFilePrefix matches the log files of the CNI plugins and of the telemetry service, including rotated ones.
+	cniLogFilePrefix = "azure-vnet"
+	// rotationFailureFactor is how many times the rotation size an active log file must grow to before its rotation
+	// is considered failed, since a log file is only rotated every few writes.
+	rotationFailureFactor = 2
+	// staleLockFileAge is how long a lock file must not have been acquired to be considered stale.
+	// Lock files are rewritten on every acquisition, so an old one belongs to a plugin which doesn't run anymore.
+	staleLockFileAge = 24 * time.Hour
+)
+
+// DiskUsagePaths are the files whose disk usage is reported.
+type DiskUsagePaths struct {
+	LogDirectory  string
+	StateFiles    []string
+	LockDirectory string
+}
+
+// DiskUsage is the disk usage of the CNI on a node.
+type DiskUsage struct {
+	// LogBytes is the size of the CNI log files in the log directory, including rotated ones.
+	LogBytes int64
+	// StateFileBytes is the size of each state file which exists, by file name.
+	StateFileBytes map[string]int64
+	// StaleLockFiles is the number of lock files which haven't been acquired for staleLockFileAge.
+	StaleLockFiles int
+	// RotationFailures is the number of active CNI log files which grew well past the rotation size.
+	RotationFailures int
+}
+
+// collectDiskUsage measures the disk usage of the files in paths. Missing files and directories count as empty.
+func collectDiskUsage(paths DiskUsagePaths, now time.Time) DiskUsage {
+	usage := DiskUsage{StateFileBytes: make(map[string]int64)}
+
+	if entries, err := readDir(paths.LogDirectory); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), cniLogFilePrefix) || !strings.Contains(entry.Name(), ".log") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			usage.LogBytes += info.Size()
+			if strings.HasSuffix(entry.Name(), ".log") && info.Size() >= rotationFailureFactor*log.DefaultMaxLogFileSize {
+				usage.RotationFailures++
+			}
+		}
+	}
+
+	for _, stateFile := range paths.StateFiles {
+		if info, err := os.Stat(stateFile); err == nil {
+			usage.StateFileBytes[filepath.Base(stateFile)] = info.Size()
+		}
+	}
+
+	if entries, err := readDir(paths.LockDirectory); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), store.LockExtension) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if now.Sub(info.ModTime()) >= staleLockFileAge {
+				usage.StaleLockFiles++
+			}
+		}
+	}
+
+	return usage
+}
+
+// readDir reads the directory, which is the working directory if empty like the log and lock paths on Windows.
+func readDir(dir string) ([]os.DirEntry, error) {
+	if dir == "" {
+		dir = "."
+	}
+	return os.ReadDir(dir) //nolint:wrapcheck // callers only check whether it failed
+}
+
+// metrics returns the disk usage as AI metrics.
+func (usage *DiskUsage) metrics(appVersion string) []AIMetric {
+	newMetric := func(name string, value float64, dims map[string]string) AIMetric {
+		return AIMetric{
+			Metric: aitelemetry.Metric{
+				Name:             name,
+				Value:            value,
+				AppVersion:       appVersion,
+				CustomDimensions: dims,
+			},
+		}
+	}
+
+	result := []AIMetric{
+		newMetric(CNILogSizeMetricStr, float64(usage.LogBytes), nil),
+		newMetric(CNIStaleLockFilesMetricStr, float64(usage.StaleLockFiles), nil),
+		newMetric(CNILogRotationFailuresMetricStr, float64(usage.RotationFailures), nil),
+	}
+	for file, size := range usage.StateFileBytes {
+		result = append(result, newMetric(CNIStateFileSizeMetricStr, float64(size), map[string]string{FileStr: file}))
+	}
+	return result
+}
+
+// ReportDiskUsage sends the disk usage of the files in paths as AI metrics every interval, until ctx is done,
+// so that operators can catch log growth filling the disk of nodes.
+// The interval should be longer than the AI batch interval, since identical metrics are summed over a batch.
+func ReportDiskUsage(ctx context.Context, paths DiskUsagePaths, interval time.Duration, appVersion string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		usage := collectDiskUsage(paths, time.Now())
+		log.Logf("[Telemetry] Disk usage: %+v", usage)
+		for _, metric := range usage.metrics(appVersion) {
+			SendAIMetric(metric)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
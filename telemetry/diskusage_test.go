@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectDiskUsage(t *testing.T) {
+	now := time.Now()
+	logDir := t.TempDir()
+	lockDir := t.TempDir()
+	stateDir := t.TempDir()
+
+	writeFile := func(path string, size int, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0o600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	writeFile(filepath.Join(logDir, "azure-vnet.log"), 100, now)
+	writeFile(filepath.Join(logDir, "azure-vnet.log.1"), 200, now)
+	writeFile(filepath.Join(logDir, "azure-vnet-ipam.log"), 2*log.DefaultMaxLogFileSize, now)
+	writeFile(filepath.Join(logDir, "syslog"), 1000, now)
+	writeFile(filepath.Join(stateDir, "azure-vnet.json"), 50, now)
+	writeFile(filepath.Join(lockDir, "azure-vnet.lock"), 5, now)
+	writeFile(filepath.Join(lockDir, "azure-cnm.lock"), 5, now.Add(-2*staleLockFileAge))
+
+	usage := collectDiskUsage(DiskUsagePaths{
+		LogDirectory:  logDir,
+		StateFiles:    []string{filepath.Join(stateDir, "azure-vnet.json"), filepath.Join(stateDir, "azure-vnet-ipam.json")},
+		LockDirectory: lockDir,
+	}, now)
+
+	assert.Equal(t, DiskUsage{
+		LogBytes:         300 + 2*log.DefaultMaxLogFileSize,
+		StateFileBytes:   map[string]int64{"azure-vnet.json": 50},
+		StaleLockFiles:   1,
+		RotationFailures: 1,
+	}, usage)
+	assert.Len(t, usage.metrics("v1"), 4)
+}
@@ -0,0 +1,95 @@
+// Copyright Microsoft. All rights reserved.
+package telemetry
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+)
+
+// maxBufferedMetrics is the number of distinct metrics buffered before they are flushed early.
+const maxBufferedMetrics = MaxNumReports
+
+// metricBuffer coalesces the identical metrics sent within a batch interval by summing their values,
+// and tracks them once at the end of the interval, so that hot paths like per-ADD metrics don't
+// each cost an Application Insights item.
+type metricBuffer struct {
+	track   func(aitelemetry.Metric)
+	mutex   sync.Mutex
+	metrics map[string]*aitelemetry.Metric
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newMetricBuffer(track func(aitelemetry.Metric)) *metricBuffer {
+	return &metricBuffer{
+		track:   track,
+		metrics: make(map[string]*aitelemetry.Metric),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// metricKey identifies the metrics which are coalesced: same name, app version and custom dimensions.
+func metricKey(metric *aitelemetry.Metric) string {
+	// map keys are marshalled sorted
+	dims, _ := json.Marshal(metric.CustomDimensions)
+	return metric.Name + "\x00" + metric.AppVersion + "\x00" + string(dims)
+}
+
+// add buffers the metric, summing it to an identical buffered metric if any.
+func (b *metricBuffer) add(metric aitelemetry.Metric) {
+	key := metricKey(&metric)
+
+	b.mutex.Lock()
+	if buffered, ok := b.metrics[key]; ok {
+		buffered.Value += metric.Value
+		b.mutex.Unlock()
+		return
+	}
+	b.metrics[key] = &metric
+	full := len(b.metrics) >= maxBufferedMetrics
+	b.mutex.Unlock()
+
+	if full {
+		b.flush()
+	}
+}
+
+// flush tracks the buffered metrics and empties the buffer.
+func (b *metricBuffer) flush() {
+	b.mutex.Lock()
+	metrics := b.metrics
+	b.metrics = make(map[string]*aitelemetry.Metric, len(metrics))
+	b.mutex.Unlock()
+
+	for _, metric := range metrics {
+		b.track(*metric)
+	}
+}
+
+// run flushes the buffer every interval, until close is called.
+func (b *metricBuffer) run(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.flush()
+		case <-b.stop:
+			b.flush()
+			return
+		}
+	}
+}
+
+// close stops run, after it flushed the buffer a last time.
+func (b *metricBuffer) close() {
+	close(b.stop)
+	<-b.done
+}
@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metricRecorder struct {
+	sync.Mutex
+	metrics []aitelemetry.Metric
+}
+
+func (r *metricRecorder) track(metric aitelemetry.Metric) {
+	r.Lock()
+	defer r.Unlock()
+	r.metrics = append(r.metrics, metric)
+}
+
+func (r *metricRecorder) tracked() []aitelemetry.Metric {
+	r.Lock()
+	defer r.Unlock()
+	metrics := append([]aitelemetry.Metric(nil), r.metrics...)
+	sort.Slice(metrics, func(i, j int) bool {
+		return metricKey(&metrics[i]) < metricKey(&metrics[j])
+	})
+	return metrics
+}
+
+func TestMetricBufferCoalesces(t *testing.T) {
+	rec := &metricRecorder{}
+	b := newMetricBuffer(rec.track)
+
+	b.add(aitelemetry.Metric{Name: "add", Value: 1, CustomDimensions: map[string]string{"a": "1", "b": "2"}})
+	b.add(aitelemetry.Metric{Name: "add", Value: 2, CustomDimensions: map[string]string{"b": "2", "a": "1"}})
+	b.add(aitelemetry.Metric{Name: "add", Value: 5, CustomDimensions: map[string]string{"a": "other"}})
+	b.add(aitelemetry.Metric{Name: "del", Value: 1})
+	b.add(aitelemetry.Metric{Name: "del", Value: 1, AppVersion: "v2"})
+	require.Empty(t, rec.tracked())
+
+	b.flush()
+	assert.Equal(t, []aitelemetry.Metric{
+		{Name: "add", Value: 3, CustomDimensions: map[string]string{"a": "1", "b": "2"}},
+		{Name: "add", Value: 5, CustomDimensions: map[string]string{"a": "other"}},
+		{Name: "del", Value: 1},
+		{Name: "del", Value: 1, AppVersion: "v2"},
+	}, rec.tracked())
+
+	// the buffer is empty after a flush
+	b.flush()
+	assert.Len(t, rec.tracked(), 4)
+}
+
+func TestMetricBufferFlushesWhenFull(t *testing.T) {
+	rec := &metricRecorder{}
+	b := newMetricBuffer(rec.track)
+
+	for i := 0; i < maxBufferedMetrics-1; i++ {
+		b.add(aitelemetry.Metric{Name: strconv.Itoa(i), Value: 1})
+	}
+	require.Empty(t, rec.tracked())
+
+	b.add(aitelemetry.Metric{Name: "last", Value: 1})
+	assert.Len(t, rec.tracked(), maxBufferedMetrics)
+}
+
+func TestMetricBufferRun(t *testing.T) {
+	rec := &metricRecorder{}
+	b := newMetricBuffer(rec.track)
+	go b.run(time.Millisecond)
+
+	b.add(aitelemetry.Metric{Name: "add", Value: 1})
+	require.Eventually(t, func() bool {
+		return len(rec.tracked()) == 1
+	}, time.Second, time.Millisecond)
+
+	// close flushes the pending metrics
+	b.add(aitelemetry.Metric{Name: "del", Value: 1})
+	b.close()
+	require.Len(t, rec.tracked(), 2)
+}
@@ -0,0 +1,385 @@
+// Copyright Microsoft. All rights reserved.
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/pkg/errors"
+)
+
+// Exporters of the telemetry service, selected by the Exporter of the TelemetryConfig.
+const (
+	ExporterAppInsights = "appinsights"
+	ExporterOTLP        = "otlp"
+	ExporterBoth        = "both"
+)
+
+const (
+	otlpLogsPath    = "/v1/logs"
+	otlpMetricsPath = "/v1/metrics"
+	otlpTimeout     = 10 * time.Second
+	// defaultOTLPBatchInterval is how often the buffered reports and metrics are sent if the config doesn't set it.
+	defaultOTLPBatchInterval = 15 * time.Second
+
+	// OTLP severity numbers of the reports with an event and an error message
+	otlpSeverityInfo  = 9
+	otlpSeverityError = 17
+
+	// ContainerNameStr is the attribute of the OTLP log records with the name of the container of the report.
+	ContainerNameStr = "ContainerName"
+)
+
+// ErrInvalidOTLPEndpoint is returned when the OTLP endpoint isn't an absolute http or https URL.
+var ErrInvalidOTLPEndpoint = errors.New("invalid OTLP endpoint")
+
+var errOTLPStatus = errors.New("collector rejected the OTLP request")
+
+// otlp sends the reports and metrics to the OpenTelemetry collector, if it's created.
+var otlp *otlpExporter
+
+// OTLPConfig is the config of the export of the reports and metrics to an OpenTelemetry collector.
+type OTLPConfig struct {
+	// Endpoint is the base URL of the OTLP/HTTP receiver of the collector, e.g. http://localhost:4318.
+	Endpoint string
+	// Headers are added to the requests to the collector, e.g. for authentication.
+	Headers map[string]string
+	// ServiceName and ServiceVersion are the service.name and service.version resource attributes.
+	ServiceName    string
+	ServiceVersion string
+	// BatchInterval is how often the buffered reports and metrics are sent. The zero value means the default.
+	BatchInterval time.Duration
+}
+
+// ExportsToAppInsights returns whether the exporter sends the reports and metrics to Application Insights.
+func ExportsToAppInsights(exporter string) bool {
+	return exporter == "" || exporter == ExporterAppInsights || exporter == ExporterBoth
+}
+
+// ExportsToOTLP returns whether the exporter sends the reports and metrics to an OpenTelemetry collector.
+func ExportsToOTLP(exporter string) bool {
+	return exporter == ExporterOTLP || exporter == ExporterBoth
+}
+
+// otlpExporter buffers the reports as OTLP log records and the metrics as OTLP gauge data points, and posts them as
+// JSON to the collector at the end of each batch interval, or early once MaxNumReports are buffered.
+type otlpExporter struct {
+	config        OTLPConfig
+	client        *http.Client
+	resource      otlpResource
+	disableTrace  bool
+	disableMetric bool
+	mutex         sync.Mutex
+	logs          []otlpLogRecord
+	metrics       []otlpPendingMetric
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+type otlpPendingMetric struct {
+	name  string
+	point otlpNumberDataPoint
+}
+
+// CreateOTLPExporter starts sending the reports and metrics pushed to the telemetry service to the collector.
+func CreateOTLPExporter(config OTLPConfig, disableAll, disableMetric, disableTrace bool) error {
+	if disableAll {
+		log.Printf("Telemetry is disabled")
+		return ErrTelemetryDisabled
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return errors.Wrapf(ErrInvalidOTLPEndpoint, "%q", config.Endpoint)
+	}
+	if config.BatchInterval <= 0 {
+		config.BatchInterval = defaultOTLPBatchInterval
+	}
+
+	attributes := []otlpKeyValue{
+		otlpAttribute("service.name", config.ServiceName),
+		otlpAttribute("service.version", config.ServiceVersion),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, otlpAttribute("host.name", hostname))
+	}
+
+	otlp = &otlpExporter{
+		config:        config,
+		client:        &http.Client{Timeout: otlpTimeout},
+		resource:      otlpResource{Attributes: attributes},
+		disableTrace:  disableTrace,
+		disableMetric: disableMetric,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go otlp.run()
+	return nil
+}
+
+// SendOTLPTelemetry buffers the report as a log record with the dimensions of the Application Insights trace.
+func SendOTLPTelemetry(cnireport CNIReport) {
+	if otlp == nil || otlp.disableTrace {
+		return
+	}
+
+	record := otlpLogRecord{
+		TimeUnixNano:   otlpTime(time.Now()),
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+	}
+	if cnireport.ErrorMessage != "" {
+		record.Body = otlpAnyValue{StringValue: cnireport.ErrorMessage}
+		record.SeverityNumber = otlpSeverityError
+		record.SeverityText = "ERROR"
+	} else if cnireport.EventMessage != "" {
+		record.Body = otlpAnyValue{StringValue: cnireport.EventMessage}
+	} else {
+		return
+	}
+
+	dimensions := reportDimensions(&cnireport)
+	dimensions[ContainerNameStr] = cnireport.ContainerName
+	record.Attributes = otlpAttributes(dimensions)
+
+	otlp.mutex.Lock()
+	otlp.logs = append(otlp.logs, record)
+	full := len(otlp.logs) >= MaxNumReports
+	otlp.mutex.Unlock()
+
+	if full {
+		otlp.flush()
+	}
+}
+
+// SendOTLPMetric buffers the metric as a gauge data point with its custom dimensions as attributes.
+func SendOTLPMetric(aiMetric AIMetric) {
+	if otlp == nil || otlp.disableMetric {
+		return
+	}
+
+	dimensions := make(map[string]string, len(aiMetric.Metric.CustomDimensions)+1)
+	for k, v := range aiMetric.Metric.CustomDimensions {
+		dimensions[k] = v
+	}
+	if _, ok := dimensions[VersionStr]; !ok && aiMetric.Metric.AppVersion != "" {
+		dimensions[VersionStr] = aiMetric.Metric.AppVersion
+	}
+
+	metric := otlpPendingMetric{
+		name: aiMetric.Metric.Name,
+		point: otlpNumberDataPoint{
+			TimeUnixNano: otlpTime(time.Now()),
+			AsDouble:     aiMetric.Metric.Value,
+			Attributes:   otlpAttributes(dimensions),
+		},
+	}
+
+	otlp.mutex.Lock()
+	otlp.metrics = append(otlp.metrics, metric)
+	full := len(otlp.metrics) >= MaxNumReports
+	otlp.mutex.Unlock()
+
+	if full {
+		otlp.flush()
+	}
+}
+
+// CloseOTLPExporter sends the buffered reports and metrics and stops the exporter.
+func CloseOTLPExporter() {
+	if otlp == nil {
+		return
+	}
+	close(otlp.stop)
+	<-otlp.done
+	otlp = nil
+}
+
+// run sends the buffered reports and metrics every batch interval until the exporter is closed.
+func (e *otlpExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.config.BatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.stop:
+			e.flush()
+			return
+		}
+	}
+}
+
+// flush sends the buffered reports and metrics. They are dropped if the collector can't be reached.
+func (e *otlpExporter) flush() {
+	e.mutex.Lock()
+	logs, metrics := e.logs, e.metrics
+	e.logs, e.metrics = nil, nil
+	e.mutex.Unlock()
+
+	scope := otlpScope{Name: e.config.ServiceName, Version: e.config.ServiceVersion}
+	if len(logs) > 0 {
+		req := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+			Resource:  e.resource,
+			ScopeLogs: []otlpScopeLogs{{Scope: scope, LogRecords: logs}},
+		}}}
+		if err := e.post(otlpLogsPath, req); err != nil {
+			log.Printf("[Telemetry] Failed to send %d reports to the OTLP collector: %v", len(logs), err)
+		}
+	}
+
+	if len(metrics) > 0 {
+		req := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+			Resource:     e.resource,
+			ScopeMetrics: []otlpScopeMetrics{{Scope: scope, Metrics: groupOTLPMetrics(metrics)}},
+		}}}
+		if err := e.post(otlpMetricsPath, req); err != nil {
+			log.Printf("[Telemetry] Failed to send %d metrics to the OTLP collector: %v", len(metrics), err)
+		}
+	}
+}
+
+func (e *otlpExporter) post(path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the OTLP request")
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(e.config.Endpoint, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to create the OTLP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to post the OTLP request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Wrapf(errOTLPStatus, "status %d for %s", resp.StatusCode, path)
+	}
+	return nil
+}
+
+// groupOTLPMetrics groups the data points of the metrics of the same name, in the order they were sent.
+func groupOTLPMetrics(pending []otlpPendingMetric) []otlpMetric {
+	index := make(map[string]int)
+	metrics := make([]otlpMetric, 0, len(pending))
+	for _, m := range pending {
+		i, ok := index[m.name]
+		if !ok {
+			i = len(metrics)
+			index[m.name] = i
+			metrics = append(metrics, otlpMetric{Name: m.name})
+		}
+		metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, m.point)
+	}
+	return metrics
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttribute(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// otlpAttributes returns the dimensions as attributes, sorted by key.
+func otlpAttributes(dimensions map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		attributes = append(attributes, otlpAttribute(k, dimensions[k]))
+	}
+	return attributes
+}
+
+// The OTLP/HTTP JSON encoding of the export requests, see opentelemetry-proto.
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/aitelemetry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateOTLPExporter(t *testing.T) {
+	require.ErrorIs(t, CreateOTLPExporter(OTLPConfig{Endpoint: "http://localhost:4318"}, true, false, false), ErrTelemetryDisabled)
+	require.ErrorIs(t, CreateOTLPExporter(OTLPConfig{}, false, false, false), ErrInvalidOTLPEndpoint)
+	require.ErrorIs(t, CreateOTLPExporter(OTLPConfig{Endpoint: "localhost:4318"}, false, false, false), ErrInvalidOTLPEndpoint)
+	require.Nil(t, otlp)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mutex sync.Mutex
+	var logs otlpLogsRequest
+	var metrics otlpMetricsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case otlpLogsPath:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&logs))
+		case otlpMetricsPath:
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&metrics))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := OTLPConfig{
+		Endpoint:       server.URL + "/",
+		Headers:        map[string]string{"Authorization": "secret"},
+		ServiceName:    "AzureCNI",
+		ServiceVersion: "v1.0.0",
+		BatchInterval:  time.Hour,
+	}
+	require.NoError(t, CreateOTLPExporter(config, false, false, false))
+
+	push(CNIReport{ErrorMessage: "failed", ContainerName: "pod", OperationType: "ADD", Client: "azure-vnet"})
+	push(CNIReport{})
+	push(AIMetric{Metric: aitelemetry.Metric{Name: CNIAddTimeMetricStr, Value: 10, CustomDimensions: map[string]string{StatusStr: SucceededStr}}})
+	push(AIMetric{Metric: aitelemetry.Metric{Name: CNIAddTimeMetricStr, Value: 20, AppVersion: "v1.0.0"}})
+	push(AIMetric{Metric: aitelemetry.Metric{Name: CNIDelTimeMetricStr, Value: 5}})
+	// the buffered reports and metrics are sent on close
+	CloseOTLPExporter()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, logs.ResourceLogs, 1)
+	require.Contains(t, logs.ResourceLogs[0].Resource.Attributes, otlpAttribute("service.name", "AzureCNI"))
+	records := logs.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 1)
+	require.Equal(t, "failed", records[0].Body.StringValue)
+	require.Equal(t, otlpSeverityError, records[0].SeverityNumber)
+	require.Contains(t, records[0].Attributes, otlpAttribute(ContainerNameStr, "pod"))
+	require.Contains(t, records[0].Attributes, otlpAttribute(OperationTypeStr, "ADD"))
+	require.Contains(t, records[0].Attributes, otlpAttribute(ClientStr, "azure-vnet"))
+
+	require.Len(t, metrics.ResourceMetrics, 1)
+	sent := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	require.Len(t, sent, 2)
+	require.Equal(t, CNIAddTimeMetricStr, sent[0].Name)
+	require.Len(t, sent[0].Gauge.DataPoints, 2)
+	require.Equal(t, []otlpKeyValue{otlpAttribute(StatusStr, SucceededStr)}, sent[0].Gauge.DataPoints[0].Attributes)
+	require.Equal(t, []otlpKeyValue{otlpAttribute(VersionStr, "v1.0.0")}, sent[0].Gauge.DataPoints[1].Attributes)
+	require.Equal(t, CNIDelTimeMetricStr, sent[1].Name)
+	require.InDelta(t, 5, sent[1].Gauge.DataPoints[0].AsDouble, 0)
+}
@@ -0,0 +1,123 @@
+// Copyright Microsoft. All rights reserved.
+package telemetry
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRecentReportsCount is the number of reports the telemetry service keeps for DumpRecent by default.
+const DefaultRecentReportsCount = 100
+
+// dumpRecentTimeout is how long DumpRecent waits for the telemetry service to answer.
+const dumpRecentTimeout = 10 * time.Second
+
+// RecentReport is a report or metric received by the telemetry service, as returned by DumpRecent.
+type RecentReport struct {
+	Received time.Time
+	// Client is the name the client identified as, or UnknownClient.
+	Client    string
+	CNIReport *CNIReport `json:",omitempty"`
+	AIMetric  *AIMetric  `json:",omitempty"`
+}
+
+type dumpRecentMessage struct {
+	DumpRecent bool
+}
+
+// recentReports is a ring of the last reports received, for on-node debugging without access to Application Insights.
+type recentReports struct {
+	mutex   sync.Mutex
+	reports []RecentReport
+	// next is the index of the slot the next report is written to, and full whether the ring wrapped.
+	next int
+	full bool
+}
+
+func newRecentReports(size int) *recentReports {
+	return &recentReports{reports: make([]RecentReport, size)}
+}
+
+// add records the report, overwriting the oldest one if the ring is full.
+func (r *recentReports) add(report RecentReport) {
+	if r == nil || len(r.reports) == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reports[r.next] = report
+	r.next = (r.next + 1) % len(r.reports)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the reports recorded, oldest first.
+func (r *recentReports) list() []RecentReport {
+	if r == nil {
+		return []RecentReport{}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.full {
+		return append([]RecentReport{}, r.reports[:r.next]...)
+	}
+	reports := make([]RecentReport, 0, len(r.reports))
+	reports = append(reports, r.reports[r.next:]...)
+	return append(reports, r.reports[:r.next]...)
+}
+
+// RecentReports returns the last reports and metrics received by the telemetry service, oldest first. Server only.
+func (tb *TelemetryBuffer) RecentReports() []RecentReport {
+	return tb.recent.list()
+}
+
+// DumpRecent queries the running telemetry service for its RecentReports.
+func (tb *TelemetryBuffer) DumpRecent() ([]RecentReport, error) {
+	if tb.client == nil {
+		if err := tb.dial(); err != nil {
+			return nil, errors.Wrap(err, "failed to connect to the telemetry service")
+		}
+	}
+
+	b, err := json.Marshal(dumpRecentMessage{DumpRecent: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the query")
+	}
+	//nolint:makezero //the delimiter terminates the message
+	if _, err = tb.write(append(b, Delimiter)); err != nil {
+		return nil, errors.Wrap(err, "failed to send the query")
+	}
+
+	if err = tb.client.SetReadDeadline(time.Now().Add(dumpRecentTimeout)); err != nil {
+		return nil, errors.Wrap(err, "failed to set the read deadline")
+	}
+	resp, err := read(bufio.NewReader(tb.client))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the recent reports")
+	}
+
+	var reports []RecentReport
+	if err = json.Unmarshal(resp, &reports); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal the recent reports")
+	}
+	return reports, nil
+}
+
+// writeRecentReports answers a DumpRecent query on the connection.
+func (tb *TelemetryBuffer) writeRecentReports(conn net.Conn) error {
+	b, err := json.Marshal(tb.RecentReports())
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the recent reports")
+	}
+	//nolint:makezero //the delimiter terminates the message
+	_, err = conn.Write(append(b, Delimiter))
+	return errors.Wrap(err, "failed to write the recent reports")
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentReportsRing(t *testing.T) {
+	r := newRecentReports(3)
+	require.Empty(t, r.list())
+
+	for _, msg := range []string{"1", "2"} {
+		r.add(RecentReport{CNIReport: &CNIReport{EventMessage: msg}})
+	}
+	require.Len(t, r.list(), 2)
+
+	for _, msg := range []string{"3", "4", "5"} {
+		r.add(RecentReport{CNIReport: &CNIReport{EventMessage: msg}})
+	}
+	var msgs []string
+	for _, report := range r.list() {
+		msgs = append(msgs, report.CNIReport.EventMessage)
+	}
+	require.Equal(t, []string{"3", "4", "5"}, msgs)
+
+	// the ring is disabled without slots
+	empty := newRecentReports(0)
+	empty.add(RecentReport{})
+	require.Empty(t, empty.list())
+}
+
+func TestDumpRecent(t *testing.T) {
+	tbServer, closeTBServer := createTBServer(t)
+	defer closeTBServer()
+
+	tbClient := NewTelemetryBuffer()
+	tbClient.ClientName = "azure-vnet"
+	err := tbClient.Connect()
+	require.NoError(t, err)
+	defer tbClient.Close()
+
+	SendCNIEvent(tbClient, &CNIReport{ErrorMessage: "failed"})
+	err = SendCNIMetric(&AIMetric{}, tbClient)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-tbServer.data:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for reports")
+		}
+	}
+
+	tbQuery := NewTelemetryBuffer()
+	defer tbQuery.Close()
+	reports, err := tbQuery.DumpRecent()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, "azure-vnet", reports[0].Client)
+	require.Equal(t, "failed", reports[0].CNIReport.ErrorMessage)
+	require.Nil(t, reports[0].AIMetric)
+	require.NotNil(t, reports[1].AIMetric)
+	require.False(t, reports[1].Received.IsZero())
+}
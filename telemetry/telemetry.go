@@ -4,7 +4,10 @@
 package telemetry
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
+	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/Azure/azure-container-networking/common"
@@ -18,6 +21,10 @@ const (
 	CNITelemetryFile = platform.CNIRuntimePath + "AzureCNITelemetry.json"
 	// ContentType of JSON
 	ContentType = "application/json"
+
+	interfaceInfoCacheTTL      = 5 * time.Minute
+	interfaceInfoQueryTimeout  = 5 * time.Second
+	interfaceInfoClientTimeout = 2
 )
 
 // OS Details structure.
@@ -80,7 +87,58 @@ type CNIReport struct {
 	SystemDetails     SystemInfo
 	InterfaceDetails  InterfaceInfo
 	BridgeDetails     BridgeInfo
-	Metadata          common.Metadata `json:"compute"`
+	// PhaseDurationsMs is the time spent in each phase of the operation, keyed by Phase.
+	PhaseDurationsMs map[string]int64 `json:",omitempty"`
+	// CorrelationID is the ID of the CNI invocation, logged in its log entries and sent in its requests to CNS and NNS.
+	CorrelationID string `json:",omitempty"`
+	// HomeAz is the home availability zone of the node returned by CNS, if known.
+	HomeAz uint `json:",omitempty"`
+	// Datapath is the datapath the endpoint of the pod was created with, if acceleration was requested for it.
+	Datapath string `json:",omitempty"`
+	// Client is the name of the client which sent the report, set by the telemetry service.
+	Client   string          `json:",omitempty"`
+	Metadata common.Metadata `json:"compute"`
+	// PhaseObserver, if set, is called when a phase tracked by TrackPhase starts and ends.
+	PhaseObserver func(phase string, started bool) `json:"-"`
+}
+
+// AddPhaseDuration adds d to the time spent in phase.
+func (report *CNIReport) AddPhaseDuration(phase string, d time.Duration) {
+	if report.PhaseDurationsMs == nil {
+		report.PhaseDurationsMs = make(map[string]int64)
+	}
+	report.PhaseDurationsMs[phase] += d.Milliseconds()
+}
+
+// SetPhaseDuration sets the time spent in phase to d.
+func (report *CNIReport) SetPhaseDuration(phase string, d time.Duration) {
+	if report.PhaseDurationsMs == nil {
+		report.PhaseDurationsMs = make(map[string]int64)
+	}
+	report.PhaseDurationsMs[phase] = d.Milliseconds()
+}
+
+// TrackPhase starts timing phase, and returns a func which adds the elapsed time to it. Usage:
+//
+//	defer report.TrackPhase(telemetry.PhaseIPAM)()
+func (report *CNIReport) TrackPhase(phase string) func() {
+	start := time.Now()
+	if report.PhaseObserver != nil {
+		report.PhaseObserver(phase, true)
+	}
+	return func() {
+		report.AddPhaseDuration(phase, time.Since(start))
+		if report.PhaseObserver != nil {
+			report.PhaseObserver(phase, false)
+		}
+	}
+}
+
+// SetPhaseDimensions adds the phase durations to the custom dimensions of the metric.
+func (report *CNIReport) SetPhaseDimensions(cniMetric *AIMetric) {
+	for phase, ms := range report.PhaseDurationsMs {
+		cniMetric.Metric.CustomDimensions[phase+PhaseDurationSuffixStr] = strconv.FormatInt(ms, 10)
+	}
 }
 
 type AIMetric struct {
@@ -101,6 +159,52 @@ func (report *CNIReport) GetReport(name string, version string, ipamQueryURL str
 
 	report.GetSystemDetails()
 	report.GetOSDetails()
+	report.GetInterfaceDetails(ipamQueryURL)
+}
+
+// GetInterfaceDetails fills in the primary interface details from the interface info at ipamQueryURL.
+// The interface info is read from the cache of the IPAM plugin while it is fresh, so that it is not queried on every
+// invocation.
+func (report *CNIReport) GetInterfaceDetails(ipamQueryURL string) {
+	httpClient := common.InitHttpClient(interfaceInfoClientTimeout, interfaceInfoClientTimeout)
+	getters, err := common.NewInterfaceInfoGetters(httpClient.Transport, ipamQueryURL)
+	if err != nil {
+		report.InterfaceDetails.ErrorMessage = "GetInterfaceDetails failed with " + err.Error()
+		return
+	}
+	client := common.NewInterfaceInfoClient(platform.CNIInterfaceInfoCachePath, interfaceInfoCacheTTL, getters...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), interfaceInfoQueryTimeout)
+	defer cancel()
+
+	doc, err := client.Get(ctx)
+	if err != nil {
+		report.InterfaceDetails.ErrorMessage = "GetInterfaceDetails failed with " + err.Error()
+		return
+	}
+
+	for _, i := range doc.Interface {
+		if !i.IsPrimary {
+			continue
+		}
+
+		report.InterfaceDetails.InterfaceType = "Primary"
+		report.InterfaceDetails.MAC = i.MacAddress
+		report.InterfaceDetails.SecondaryCATotalCount = 0
+		for _, subnet := range i.IPSubnet {
+			report.InterfaceDetails.Subnet = subnet.Prefix
+			for _, address := range subnet.IPAddress {
+				if address.IsPrimary {
+					report.InterfaceDetails.PrimaryCA = address.Address
+				} else {
+					report.InterfaceDetails.SecondaryCATotalCount++
+				}
+			}
+		}
+		return
+	}
+
+	report.InterfaceDetails.ErrorMessage = "GetInterfaceDetails failed to find primary interface"
 }
 
 // SendReport will send telemetry report to HostNetAgent.
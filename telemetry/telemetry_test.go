@@ -6,6 +6,7 @@ package telemetry
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
 	"github.com/stretchr/testify/require"
@@ -151,3 +152,21 @@ func TestGetSystemDetails(t *testing.T) {
 	cniReport.GetSystemDetails()
 	require.Equal(t, expectedErrMsg, cniReport.ErrorMessage)
 }
+
+func TestPhaseDurations(t *testing.T) {
+	report := &CNIReport{}
+	report.AddPhaseDuration(PhaseIPAM, 5*time.Millisecond)
+	report.AddPhaseDuration(PhaseIPAM, 7*time.Millisecond)
+	report.SetPhaseDuration(PhaseNetns, 3*time.Millisecond)
+	report.SetPhaseDuration(PhaseNetns, 4*time.Millisecond)
+	report.TrackPhase(PhaseDNS)()
+
+	require.Equal(t, int64(12), report.PhaseDurationsMs[PhaseIPAM])
+	require.Equal(t, int64(4), report.PhaseDurationsMs[PhaseNetns])
+	require.Contains(t, report.PhaseDurationsMs, PhaseDNS)
+
+	cniMetric := &AIMetric{Metric: aitelemetry.Metric{CustomDimensions: make(map[string]string)}}
+	report.SetPhaseDimensions(cniMetric)
+	require.Equal(t, "12", cniMetric.Metric.CustomDimensions["IPAMDurationMs"])
+	require.Equal(t, "4", cniMetric.Metric.CustomDimensions["NetnsDurationMs"])
+}
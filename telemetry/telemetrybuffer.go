@@ -8,8 +8,10 @@ import (
 	"context"
 	"encoding/json"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +35,40 @@ type TelemetryConfig struct {
 	BatchSizeInBytes              int
 	GetEnvRetryCount              int
 	GetEnvRetryWaitTimeInSecs     int
+	// PipeSecurityDescriptor is the SDDL security descriptor of the telemetry named pipe. Windows only.
+	// Defaults to allowing only SYSTEM and Administrators.
+	PipeSecurityDescriptor string
+
+	// DiskUsageReportIntervalInSecs is how often the telemetry service reports the disk usage of the CNI log, state and lock files.
+	// The zero value means the default.
+	DiskUsageReportIntervalInSecs int
+	DisableDiskUsageReport        bool
+
+	// StatsAddress is the local address of the HTTP endpoint serving the per-client stats of the telemetry service.
+	// The empty string means the default.
+	StatsAddress         string
+	DisableStatsEndpoint bool
+
+	// LogMaxFileSizeInMB, LogMaxFileCount, LogMaxAgeInHours, LogCompress and LogMaxTotalSizeInMB configure the rotation
+	// of the telemetry service log file, see log.RotationConfig. The zero values mean the defaults.
+	LogMaxFileSizeInMB  int
+	LogMaxFileCount     int
+	LogMaxAgeInHours    int
+	LogCompress         bool
+	LogMaxTotalSizeInMB int
+
+	// RecentReportsCount is the number of reports and metrics the telemetry service keeps in memory for
+	// azure-vnet-telemetry --dump-recent. The zero value means DefaultRecentReportsCount.
+	RecentReportsCount int
+
+	// Exporter selects where the reports and metrics are sent: ExporterAppInsights, ExporterOTLP or ExporterBoth.
+	// The empty string means ExporterAppInsights.
+	Exporter string
+	// OTLPEndpoint is the base URL of the OTLP/HTTP receiver of the OpenTelemetry collector the reports are sent to as
+	// log records and the metrics as gauges, e.g. http://localhost:4318. Required by ExporterOTLP and ExporterBoth.
+	OTLPEndpoint string
+	// OTLPHeaders are added to the requests to the OpenTelemetry collector, e.g. for authentication.
+	OTLPHeaders map[string]string
 }
 
 // FdName - file descriptor name
@@ -43,6 +79,8 @@ const (
 	Delimiter      = '\n'
 	MaxPayloadSize = 4096
 	MaxNumReports  = 1000
+	// UnknownClient is the name of the clients which don't identify themselves, e.g. older versions.
+	UnknownClient = "unknown"
 )
 
 // TelemetryBuffer object
@@ -52,9 +90,44 @@ type TelemetryBuffer struct {
 	connections []net.Conn
 	FdExists    bool
 	Connected   bool
-	data        chan interface{}
-	cancel      chan bool
-	mutex       sync.Mutex
+	// PipeSecurityDescriptor overrides the SDDL security descriptor used when listening on the named pipe. Windows only.
+	PipeSecurityDescriptor string
+	// ClientName and ClientVersion identify the client to the telemetry service on connect, e.g. azure-vnet.
+	// The client isn't identified if ClientName is empty.
+	ClientName    string
+	ClientVersion string
+	// RecentReportsCount is the number of reports kept for DumpRecent, DefaultRecentReportsCount if zero. Server only.
+	RecentReportsCount int
+	data               chan interface{}
+	cancel             chan bool
+	mutex              sync.Mutex
+	// clients is the accounting of the reports of each client, by name. Server only.
+	clients map[string]*ClientStats
+	// recent is the ring of the last reports received. Server only.
+	recent *recentReports
+}
+
+// ClientHello is the first message a client sends on each connection, to identify itself.
+type ClientHello struct {
+	Name    string
+	Version string
+}
+
+type clientHelloMessage struct {
+	ClientHello ClientHello
+}
+
+// ClientStats is the accounting of the reports the telemetry service received from a client.
+type ClientStats struct {
+	Name    string
+	Version string
+	// Connections is the number of connections on which the client identified itself.
+	Connections int
+	// Reports is the number of reports and metrics received, and Errors the number of error reports and malformed messages.
+	Reports   int
+	Errors    int
+	ErrorRate float64
+	LastSeen  time.Time
 }
 
 // Buffer object holds the different types of reports
@@ -69,6 +142,7 @@ func NewTelemetryBuffer() *TelemetryBuffer {
 	tb.data = make(chan interface{}, MaxNumReports)
 	tb.cancel = make(chan bool, 1)
 	tb.connections = make([]net.Conn, 0)
+	tb.clients = make(map[string]*ClientStats)
 
 	return &tb
 }
@@ -92,6 +166,12 @@ func (tb *TelemetryBuffer) StartServer() error {
 		return err
 	}
 
+	count := tb.RecentReportsCount
+	if count == 0 {
+		count = DefaultRecentReportsCount
+	}
+	tb.recent = newRecentReports(count)
+
 	log.Logf("Telemetry service started")
 	// Spawn server goroutine to handle incoming connections
 	go func() {
@@ -102,51 +182,7 @@ func (tb *TelemetryBuffer) StartServer() error {
 				tb.mutex.Lock()
 				tb.connections = append(tb.connections, conn)
 				tb.mutex.Unlock()
-				go func() {
-					for {
-						reportStr, err := read(conn)
-						if err == nil {
-							var tmp map[string]interface{}
-							err = json.Unmarshal(reportStr, &tmp)
-							if err != nil {
-								log.Logf("StartServer: unmarshal error:%v", err)
-								return
-							}
-							if _, ok := tmp["CniSucceeded"]; ok {
-								var cniReport CNIReport
-								json.Unmarshal([]byte(reportStr), &cniReport)
-								tb.data <- cniReport
-							} else if _, ok := tmp["Metric"]; ok {
-								var aiMetric AIMetric
-								json.Unmarshal([]byte(reportStr), &aiMetric)
-								tb.data <- aiMetric
-							} else {
-								log.Logf("StartServer: default case:%+v...", tmp)
-							}
-						} else {
-							var index int
-							var value net.Conn
-							var found bool
-
-							tb.mutex.Lock()
-							defer tb.mutex.Unlock()
-
-							for index, value = range tb.connections {
-								if value == conn {
-									conn.Close()
-									found = true
-									break
-								}
-							}
-
-							if found {
-								tb.connections = remove(tb.connections, index)
-							}
-
-							return
-						}
-					}
-				}()
+				go tb.serve(conn)
 			} else {
 				log.Logf("Telemetry Server accept error %v", err)
 				return
@@ -157,8 +193,138 @@ func (tb *TelemetryBuffer) StartServer() error {
 	return nil
 }
 
+// serve reads the reports of a client until its connection is closed, accounting them to the client it identifies as.
+func (tb *TelemetryBuffer) serve(conn net.Conn) {
+	client := ClientHello{Name: UnknownClient}
+	reader := bufio.NewReader(conn)
+	for {
+		reportStr, err := read(reader)
+		if err != nil {
+			tb.removeConnection(conn)
+			return
+		}
+
+		var tmp map[string]interface{}
+		err = json.Unmarshal(reportStr, &tmp)
+		if err != nil {
+			log.Logf("StartServer: unmarshal error:%v", err)
+			tb.recordReport(client, true)
+			tb.removeConnection(conn)
+			return
+		}
+		if _, ok := tmp["ClientHello"]; ok {
+			var hello clientHelloMessage
+			json.Unmarshal(reportStr, &hello)
+			if hello.ClientHello.Name != "" {
+				client = hello.ClientHello
+				tb.recordConnection(client)
+			}
+		} else if _, ok := tmp["CniSucceeded"]; ok {
+			var cniReport CNIReport
+			json.Unmarshal([]byte(reportStr), &cniReport)
+			if client.Name != UnknownClient {
+				cniReport.Client = client.Name
+			}
+			tb.recordReport(client, cniReport.ErrorMessage != "")
+			tb.recent.add(RecentReport{Received: time.Now(), Client: client.Name, CNIReport: &cniReport})
+			tb.data <- cniReport
+		} else if _, ok := tmp["Metric"]; ok {
+			var aiMetric AIMetric
+			json.Unmarshal([]byte(reportStr), &aiMetric)
+			if client.Name != UnknownClient {
+				if aiMetric.Metric.CustomDimensions == nil {
+					aiMetric.Metric.CustomDimensions = make(map[string]string)
+				}
+				aiMetric.Metric.CustomDimensions[ClientStr] = client.Name
+			}
+			tb.recordReport(client, false)
+			tb.recent.add(RecentReport{Received: time.Now(), Client: client.Name, AIMetric: &aiMetric})
+			tb.data <- aiMetric
+		} else if _, ok := tmp["DumpRecent"]; ok {
+			if err := tb.writeRecentReports(conn); err != nil {
+				log.Logf("StartServer: failed to answer the recent reports query: %v", err)
+				tb.removeConnection(conn)
+				return
+			}
+		} else {
+			log.Logf("StartServer: default case:%+v...", tmp)
+			tb.recordReport(client, true)
+		}
+	}
+}
+
+func (tb *TelemetryBuffer) removeConnection(conn net.Conn) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	for index, value := range tb.connections {
+		if value == conn {
+			conn.Close()
+			tb.connections = remove(tb.connections, index)
+			return
+		}
+	}
+}
+
+// clientStats returns the stats of the client, creating them if needed. The caller holds the mutex.
+func (tb *TelemetryBuffer) clientStats(client ClientHello) *ClientStats {
+	stats, ok := tb.clients[client.Name]
+	if !ok {
+		stats = &ClientStats{Name: client.Name}
+		tb.clients[client.Name] = stats
+	}
+	if client.Version != "" {
+		stats.Version = client.Version
+	}
+	stats.LastSeen = time.Now()
+	return stats
+}
+
+func (tb *TelemetryBuffer) recordConnection(client ClientHello) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.clientStats(client).Connections++
+}
+
+func (tb *TelemetryBuffer) recordReport(client ClientHello, failed bool) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	stats := tb.clientStats(client)
+	stats.Reports++
+	if failed {
+		stats.Errors++
+	}
+}
+
+// ClientStats returns the accounting of the reports received from each client, sorted by client name.
+func (tb *TelemetryBuffer) ClientStats() []ClientStats {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	stats := make([]ClientStats, 0, len(tb.clients))
+	for _, s := range tb.clients {
+		c := *s
+		if c.Reports > 0 {
+			c.ErrorRate = float64(c.Errors) / float64(c.Reports)
+		}
+		stats = append(stats, c)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// StatsHandler serves the ClientStats as JSON.
+func (tb *TelemetryBuffer) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tb.ClientStats()); err != nil {
+			log.Logf("[Telemetry] Failed to encode client stats: %v", err)
+		}
+	})
+}
+
 func (tb *TelemetryBuffer) Connect() error {
-	err := tb.Dial(FdName)
+	err := tb.dial()
 	if err == nil {
 		tb.Connected = true
 	} else if tb.FdExists {
@@ -188,9 +354,10 @@ func (tb *TelemetryBuffer) PushData(ctx context.Context) {
 	}
 }
 
-// read - read from the file descriptor
-func read(conn net.Conn) (b []byte, err error) {
-	b, err = bufio.NewReader(conn).ReadBytes(Delimiter)
+// read - read from the file descriptor.
+// The reader is kept for the whole connection, since it may buffer the messages following the one read.
+func read(reader *bufio.Reader) (b []byte, err error) {
+	b, err = reader.ReadBytes(Delimiter)
 	if err == nil {
 		b = b[:len(b)-1]
 	}
@@ -199,11 +366,50 @@ func read(conn net.Conn) (b []byte, err error) {
 }
 
 // Write - write to the file descriptor.
+// If the write fails, the connection is re-established once and the write is retried, so that
+// a restart of the telemetry service doesn't drop the reports of long running clients.
 func (tb *TelemetryBuffer) Write(b []byte) (c int, err error) {
 	buf := make([]byte, len(b))
 	copy(buf, b)
 	//nolint:makezero //keeping old code
 	buf = append(buf, Delimiter)
+
+	if tb.client != nil {
+		c, err = tb.write(buf)
+		if err == nil {
+			return c, nil
+		}
+		log.Logf("telemetry write failed: %v, reconnecting", err)
+		tb.client.Close()
+		tb.client = nil
+	}
+
+	if err = tb.dial(); err != nil {
+		return 0, err
+	}
+
+	return tb.write(buf)
+}
+
+// dial connects to the telemetry service, and identifies the client if ClientName is set.
+func (tb *TelemetryBuffer) dial() error {
+	if err := tb.Dial(FdName); err != nil {
+		return err
+	}
+	if tb.ClientName == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(clientHelloMessage{ClientHello: ClientHello{Name: tb.ClientName, Version: tb.ClientVersion}})
+	if err != nil {
+		return err
+	}
+	//nolint:makezero //the delimiter terminates the message
+	_, err = tb.write(append(b, Delimiter))
+	return err
+}
+
+func (tb *TelemetryBuffer) write(buf []byte) (c int, err error) {
 	w := bufio.NewWriter(tb.client)
 	c, err = w.Write(buf)
 	if err == nil {
@@ -248,9 +454,11 @@ func push(x interface{}) {
 	switch y := x.(type) {
 	case CNIReport:
 		SendAITelemetry(y)
+		SendOTLPTelemetry(y)
 
 	case AIMetric:
 		SendAIMetric(y)
+		SendOTLPMetric(y)
 	default:
 		log.Printf("Push fn: Default case:%+v", y)
 	}
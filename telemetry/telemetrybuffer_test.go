@@ -1,6 +1,9 @@
 package telemetry
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -154,3 +157,82 @@ func TestStartTelemetryService(t *testing.T) {
 	err := StartTelemetryService("", nil)
 	require.Error(t, err)
 }
+
+func TestWriteReconnects(t *testing.T) {
+	_, closeTBServer := createTBServer(t)
+
+	tbClient := NewTelemetryBuffer()
+	err := tbClient.Connect()
+	require.NoError(t, err)
+	defer tbClient.Close()
+
+	// restart the server, the client should reconnect on the next write
+	closeTBServer()
+	tbServer, closeTBServer := createTBServer(t)
+	defer closeTBServer()
+
+	report, err := (&ReportManager{Report: &CNIReport{EventMessage: "testdata"}}).ReportToBytes()
+	require.NoError(t, err)
+	_, err = tbClient.Write(report)
+	require.NoError(t, err)
+
+	// the report reaches the new server
+	select {
+	case data := <-tbServer.data:
+		require.Equal(t, "testdata", data.(CNIReport).EventMessage)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the report")
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	tbServer, closeTBServer := createTBServer(t)
+	defer closeTBServer()
+
+	tbClient := NewTelemetryBuffer()
+	tbClient.ClientName = "azure-vnet"
+	tbClient.ClientVersion = "v1.4.0"
+	err := tbClient.Connect()
+	require.NoError(t, err)
+	defer tbClient.Close()
+
+	anonClient := NewTelemetryBuffer()
+	err = anonClient.Connect()
+	require.NoError(t, err)
+	defer anonClient.Close()
+
+	SendCNIEvent(tbClient, &CNIReport{EventMessage: "added"})
+	SendCNIEvent(tbClient, &CNIReport{ErrorMessage: "failed"})
+	SendCNIEvent(anonClient, &CNIReport{EventMessage: "added"})
+
+	clients := map[string]int{}
+	for i := 0; i < 3; i++ {
+		select {
+		case data := <-tbServer.data:
+			clients[data.(CNIReport).Client]++
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for reports")
+		}
+	}
+	require.Equal(t, map[string]int{"azure-vnet": 2, "": 1}, clients)
+
+	stats := tbServer.ClientStats()
+	require.Len(t, stats, 2)
+	require.Equal(t, "azure-vnet", stats[0].Name)
+	require.Equal(t, "v1.4.0", stats[0].Version)
+	require.Equal(t, 1, stats[0].Connections)
+	require.Equal(t, 2, stats[0].Reports)
+	require.Equal(t, 1, stats[0].Errors)
+	require.InDelta(t, 0.5, stats[0].ErrorRate, 0.001)
+	require.False(t, stats[0].LastSeen.IsZero())
+	require.Equal(t, UnknownClient, stats[1].Name)
+	require.Equal(t, 1, stats[1].Reports)
+
+	rec := httptest.NewRecorder()
+	tbServer.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var served []ClientStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	require.Len(t, served, 2)
+	require.Equal(t, 2, served[0].Reports)
+}
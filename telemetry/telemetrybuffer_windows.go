@@ -15,6 +15,8 @@ const (
 	TelemetryServiceProcessName = "azure-vnet-telemetry.exe"
 	CniInstallDir               = "c:\\k\\azurecni\\bin"
 	metadataFile                = "azuremetadata.json"
+	// defaultPipeSecurityDescriptor allows only SYSTEM and Builtin Administrators to access the pipe.
+	defaultPipeSecurityDescriptor = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
 )
 
 // Dial - try to connect to a named pipe with 'name'
@@ -29,7 +31,14 @@ func (tb *TelemetryBuffer) Dial(name string) (err error) {
 
 // Listen - try to create and listen on named pipe with 'name'
 func (tb *TelemetryBuffer) Listen(name string) (err error) {
-	listener, err := winio.ListenPipe(fmt.Sprintf(fdTemplate, name), nil)
+	sddl := tb.PipeSecurityDescriptor
+	if sddl == "" {
+		sddl = defaultPipeSecurityDescriptor
+	}
+
+	listener, err := winio.ListenPipe(fmt.Sprintf(fdTemplate, name), &winio.PipeConfig{
+		SecurityDescriptor: sddl,
+	})
 	if err == nil {
 		tb.listener = listener
 	}
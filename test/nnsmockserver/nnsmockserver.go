@@ -8,25 +8,39 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync/atomic"
 
+	acn "github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
 	nns "github.com/Azure/azure-container-networking/proto/nodenetworkservice/3.302.0.744"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type NnsMockServer struct {
 	srv *grpc.Server
+	api *serverApi
 }
 
 // node network service mock server implementation
-type serverApi struct{}
+type serverApi struct {
+	// correlationID is the correlation ID of the last request, if any.
+	correlationID atomic.Value
+}
 
 func (s *serverApi) ConfigureContainerNetworking(
 	ctx context.Context,
 	req *nns.ConfigureContainerNetworkingRequest) (*nns.ConfigureContainerNetworkingResponse, error) {
 
 	fmt.Printf("Received request of type :%s \n", req.RequestType)
+	var correlationID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(acn.CorrelationIDMetadataKey); len(ids) > 0 {
+			correlationID = ids[0]
+		}
+	}
+	s.correlationID.Store(correlationID)
 	if err := isValidPodName(req.ContainerId); err != nil {
 		return nil, fmt.Errorf("NnsMockServer: RequestType:%s failed with error: %v", req.RequestType, err)
 	}
@@ -67,9 +81,16 @@ func (s *serverApi) PingNodeNetworkService(
 func NewNnsMockServer() *NnsMockServer {
 	return &NnsMockServer{
 		srv: grpc.NewServer(),
+		api: &serverApi{},
 	}
 }
 
+// LastCorrelationID returns the correlation ID of the last request to configure container networking, if any.
+func (s *NnsMockServer) LastCorrelationID() string {
+	id, _ := s.api.correlationID.Load().(string)
+	return id
+}
+
 func (s *NnsMockServer) StartGrpcServer(port string) {
 	endpoint := fmt.Sprintf(":%s", port)
 	lis, err := net.Listen("tcp", endpoint)
@@ -77,7 +98,7 @@ func (s *NnsMockServer) StartGrpcServer(port string) {
 		log.Errorf("nnsmockserver: failed to listen at endpoint %s with error %v", endpoint, err)
 	}
 
-	nns.RegisterNodeNetworkServiceServer(s.srv, &serverApi{})
+	nns.RegisterNodeNetworkServiceServer(s.srv, s.api)
 	if err := s.srv.Serve(lis); err != nil {
 		log.Errorf("nnsmockserver: failed to serve at port %s with error %s", port, err)
 	}
@@ -28,22 +28,24 @@ var _ zapcore.Core = (*Core)(nil)
 // the Sink.Write([]byte) (which knows how to decode it back in to a TraceTelemetry).
 type Core struct {
 	zapcore.LevelEnabler
-	enc          traceEncoder
-	fieldMappers map[string]fieldTagMapper
-	fields       []zapcore.Field
-	out          zapcore.WriteSyncer
-	lock         *sync.Mutex
+	enc              traceEncoder
+	fieldMappers     map[string]fieldTagMapper
+	dimensionMappers map[string]string
+	fields           []zapcore.Field
+	out              zapcore.WriteSyncer
+	lock             *sync.Mutex
 }
 
 // NewCore creates a new appinsights zap core. Should only be initialized using an appinsights Sink as the
 // zapcore.WriteSyncer argument - other sinks will not error but will also not produce meaningful output.
 func NewCore(le zapcore.LevelEnabler, out zapcore.WriteSyncer) *Core {
 	return &Core{
-		LevelEnabler: le,
-		enc:          newTraceEncoder(),
-		fieldMappers: make(map[string]fieldTagMapper),
-		out:          out,
-		lock:         &sync.Mutex{},
+		LevelEnabler:     le,
+		enc:              newTraceEncoder(),
+		fieldMappers:     make(map[string]fieldTagMapper),
+		dimensionMappers: make(map[string]string),
+		out:              out,
+		lock:             &sync.Mutex{},
 	}
 }
 
@@ -61,6 +63,19 @@ func (c *Core) WithFieldMappers(fieldMappers ...map[string]string) *Core {
 	return clone
 }
 
+// WithDimensionMappers renames the fields to the custom dimensions they are mapped to, so that the components can
+// log with their own field names and query appinsights by shared dimension names. The fields which aren't mapped
+// are set to the custom dimension of their name. Fields mapped to tags by WithFieldMappers aren't custom dimensions.
+func (c *Core) WithDimensionMappers(dimensionMappers ...map[string]string) *Core {
+	clone := c.clone()
+	for _, dimensionMapper := range dimensionMappers {
+		for field, dimension := range dimensionMapper {
+			clone.dimensionMappers[field] = dimension
+		}
+	}
+	return clone
+}
+
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 	clone := c.clone()
 	clone.fields = append(clone.fields, fields...)
@@ -96,15 +111,19 @@ func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
 
 	// set fields
 	for i := range fields {
-		// handle zap object first
-		if fields[i].Type == zapcore.ObjectMarshalerType {
-			fields[i].AddTo(c.enc)
-		} else if mapper, ok := c.fieldMappers[fields[i].Key]; ok {
-			// check mapped fields
-			mapper(t, fieldStringer(&fields[i]))
-		} else {
-			t.Properties[fields[i].Key] = fieldStringer(&fields[i])
+		// check mapped fields, objects can't be tags
+		if fields[i].Type != zapcore.ObjectMarshalerType {
+			if mapper, ok := c.fieldMappers[fields[i].Key]; ok {
+				mapper(t, fieldStringer(&fields[i]))
+				continue
+			}
 		}
+		// the encoder sets the custom dimensions of the field by its type
+		field := fields[i]
+		if dimension, ok := c.dimensionMappers[field.Key]; ok {
+			field.Key = dimension
+		}
+		field.AddTo(c.enc)
 	}
 	b, err := c.enc.encode(t)
 	if err != nil {
@@ -121,21 +140,26 @@ func (c *Core) Sync() error {
 }
 
 // clone derives a new Core from this Core, copying the references to the encoder and sink
-// and duplicating the contents of the fields and fieldMappers so that children and parent
+// and duplicating the contents of the fields and mappers so that children and parent
 // cores may mutate their fields and mappers independently after cloning.
 func (c *Core) clone() *Core {
 	fieldMappers := make(map[string]fieldTagMapper, len(c.fieldMappers))
 	for k, v := range c.fieldMappers {
 		fieldMappers[k] = v
 	}
+	dimensionMappers := make(map[string]string, len(c.dimensionMappers))
+	for k, v := range c.dimensionMappers {
+		dimensionMappers[k] = v
+	}
 	fields := make([]zapcore.Field, len(c.fields))
 	copy(fields, c.fields)
 	return &Core{
-		LevelEnabler: c.LevelEnabler,
-		enc:          c.enc,
-		fieldMappers: fieldMappers,
-		fields:       fields,
-		out:          c.out,
-		lock:         c.lock,
+		LevelEnabler:     c.LevelEnabler,
+		enc:              c.enc,
+		fieldMappers:     fieldMappers,
+		dimensionMappers: dimensionMappers,
+		fields:           fields,
+		out:              c.out,
+		lock:             c.lock,
 	}
 }
@@ -0,0 +1,83 @@
+package zapai
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// traceRecorder decodes the traces written by a Core, like the Sink.
+type traceRecorder struct {
+	dec    traceDecoder
+	traces []*appinsights.TraceTelemetry
+}
+
+func (r *traceRecorder) Write(b []byte) (int, error) {
+	t, err := r.dec.decode(b)
+	if err != nil {
+		return 0, err
+	}
+	r.traces = append(r.traces, t)
+	return len(b), nil
+}
+
+func (r *traceRecorder) Sync() error {
+	return nil
+}
+
+type nc struct {
+	id     string
+	subnet string
+}
+
+func (n nc) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("id", n.id)
+	enc.AddString("subnet", n.subnet)
+	return nil
+}
+
+func TestCoreWrite(t *testing.T) {
+	out := &traceRecorder{dec: newTraceDecoder()}
+	core := NewCore(zapcore.InfoLevel, out).
+		WithFieldMappers(DefaultMappers).
+		WithDimensionMappers(map[string]string{"ncID": "NetworkContainerID", "nc": "NetworkContainer"})
+	log := zap.New(core).With(zap.String("version", "1.2.3"), zap.String("ncID", "nc-1"))
+
+	log.Debug("dropped by level")
+	log.Warn("allocated",
+		zap.Int("count", 3),
+		zap.Uint32("port", 80),
+		zap.Float64("ratio", 0.5),
+		zap.Duration("elapsed", time.Second),
+		zap.Bool("ok", true),
+		zap.Strings("ips", []string{"10.0.0.1", "10.0.0.2"}),
+		zap.Error(errors.New("boom")),
+		zap.Object("nc", nc{id: "nc-2", subnet: "podnet"}),
+		zap.Namespace("req"),
+		zap.String("id", "r-1"),
+	)
+
+	require.Len(t, out.traces, 1)
+	trace := out.traces[0]
+	assert.Equal(t, "allocated", trace.Message)
+	assert.Equal(t, "1.2.3", trace.Tags["ai.application.ver"])
+	assert.Equal(t, map[string]string{
+		"NetworkContainerID":      "nc-1",
+		"count":                   "3",
+		"port":                    "80",
+		"ratio":                   "0.5",
+		"elapsed":                 "1s",
+		"ok":                      "true",
+		"ips":                     `["10.0.0.1","10.0.0.2"]`,
+		"error":                   "boom",
+		"NetworkContainer_id":     "nc-2",
+		"NetworkContainer_subnet": "podnet",
+		"req_id":                  "r-1",
+	}, trace.Properties)
+}
@@ -2,7 +2,9 @@ package zapai
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"sync"
@@ -42,118 +44,134 @@ type gobber struct {
 	sync.Mutex
 }
 
+// key returns the custom dimension of the field key in the current object, as the keys of the enclosing objects
+// and namespaces joined by underscores.
+func (g *gobber) key(key string) string {
+	if g.keyPrefix == "" {
+		return key
+	}
+	return g.keyPrefix + "_" + key
+}
+
 func (g *gobber) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
 	curPrefix := g.keyPrefix
-	if len(g.keyPrefix) == 0 {
-		g.keyPrefix = key
-	} else {
-		g.keyPrefix = g.keyPrefix + "_" + key
-	}
-	marshaler.MarshalLogObject(g)
+	g.keyPrefix = g.key(key)
+	err := marshaler.MarshalLogObject(g)
 	g.keyPrefix = curPrefix
-	return nil
+	return errors.Wrapf(err, "failed to marshal object %s", key)
 }
 
 func (g *gobber) AddString(key, value string) {
-	g.traceTelemetry.Properties[g.keyPrefix+"_"+key] = value
+	g.traceTelemetry.Properties[g.key(key)] = value
 }
 
 func (g *gobber) AddBool(key string, value bool) {
-	g.traceTelemetry.Properties[g.keyPrefix+"_"+key] = strconv.FormatBool(value)
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatBool(value)
 }
 
 func (g *gobber) AddInt(key string, value int) {
-	g.traceTelemetry.Properties[g.keyPrefix+"_"+key] = strconv.Itoa(value)
+	g.AddInt64(key, int64(value))
 }
 
 func (g *gobber) AddInt64(key string, value int64) {
-	g.traceTelemetry.Properties[g.keyPrefix+"_"+key] = strconv.FormatInt(value, 10)
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatInt(value, 10)
 }
 
-func (g *gobber) AddUint16(key string, value uint16) {
-	g.traceTelemetry.Properties[g.keyPrefix+"_"+key] = strconv.FormatUint(uint64(value), 10)
+func (g *gobber) AddInt32(key string, value int32) {
+	g.AddInt64(key, int64(value))
 }
 
-func (g *gobber) AddArray(_ string, _ zapcore.ArrayMarshaler) error {
-	// TODO to be implemented
-	return nil
+func (g *gobber) AddInt16(key string, value int16) {
+	g.AddInt64(key, int64(value))
 }
 
-func (g *gobber) AddBinary(_ string, _ []byte) {
-	// TODO to be implemented
+func (g *gobber) AddInt8(key string, value int8) {
+	g.AddInt64(key, int64(value))
 }
 
-func (g *gobber) AddByteString(_ string, _ []byte) {
-	// TODO to be implemented
+func (g *gobber) AddUint(key string, value uint) {
+	g.AddUint64(key, uint64(value))
 }
 
-func (g *gobber) AddComplex128(_ string, _ complex128) {
-	// TODO to be implemented
+func (g *gobber) AddUint64(key string, value uint64) {
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatUint(value, 10)
 }
 
-func (g *gobber) AddComplex64(_ string, _ complex64) {
-	// TODO to be implemented
+func (g *gobber) AddUint32(key string, value uint32) {
+	g.AddUint64(key, uint64(value))
 }
 
-func (g *gobber) AddDuration(_ string, _ time.Duration) {
-	// TODO to be implemented
+func (g *gobber) AddUint16(key string, value uint16) {
+	g.AddUint64(key, uint64(value))
 }
 
-func (g *gobber) AddFloat64(_ string, _ float64) {
-	// TODO to be implemented
+func (g *gobber) AddUint8(key string, value uint8) {
+	g.AddUint64(key, uint64(value))
 }
 
-func (g *gobber) AddFloat32(_ string, _ float32) {
-	// TODO to be implemented
+func (g *gobber) AddUintptr(key string, value uintptr) {
+	g.AddUint64(key, uint64(value))
 }
 
-func (g *gobber) AddInt32(_ string, _ int32) {
-	// TODO to be implemented
+func (g *gobber) AddFloat64(key string, value float64) {
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatFloat(value, 'g', -1, 64)
 }
 
-func (g *gobber) AddInt16(_ string, _ int16) {
-	// TODO to be implemented
+func (g *gobber) AddFloat32(key string, value float32) {
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatFloat(float64(value), 'g', -1, 32)
 }
 
-func (g *gobber) AddInt8(_ string, _ int8) {
-	// TODO to be implemented
+func (g *gobber) AddComplex128(key string, value complex128) {
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatComplex(value, 'g', -1, 128)
 }
 
-func (g *gobber) AddTime(_ string, _ time.Time) {
-	// TODO to be implemented
+func (g *gobber) AddComplex64(key string, value complex64) {
+	g.traceTelemetry.Properties[g.key(key)] = strconv.FormatComplex(complex128(value), 'g', -1, 64)
 }
 
-func (g *gobber) AddUint(_ string, _ uint) {
-	// TODO to be implemented
+func (g *gobber) AddDuration(key string, value time.Duration) {
+	g.traceTelemetry.Properties[g.key(key)] = value.String()
 }
 
-func (g *gobber) AddUint64(_ string, _ uint64) {
-	// TODO to be implemented
+func (g *gobber) AddTime(key string, value time.Time) {
+	g.traceTelemetry.Properties[g.key(key)] = value.Format(time.RFC3339Nano)
 }
 
-func (g *gobber) AddUint32(_ string, _ uint32) {
-	// TODO to be implemented
+func (g *gobber) AddBinary(key string, value []byte) {
+	g.traceTelemetry.Properties[g.key(key)] = base64.StdEncoding.EncodeToString(value)
 }
 
-func (g *gobber) AddUint8(_ string, _ uint8) {
-	// TODO to be implemented
+func (g *gobber) AddByteString(key string, value []byte) {
+	g.traceTelemetry.Properties[g.key(key)] = string(value)
 }
 
-func (g *gobber) AddUintptr(_ string, _ uintptr) {
-	// TODO to be implemented
+// AddArray sets the custom dimension to the JSON of the array, as appinsights dimensions can't be nested.
+func (g *gobber) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	enc := zapcore.NewMapObjectEncoder()
+	if err := enc.AddArray(key, marshaler); err != nil {
+		return errors.Wrapf(err, "failed to marshal array %s", key)
+	}
+	return g.AddReflected(key, enc.Fields[key])
 }
 
-func (g *gobber) AddReflected(_ string, _ interface{}) error {
-	// TODO to be implemented
+// AddReflected sets the custom dimension to the JSON of the value.
+func (g *gobber) AddReflected(key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s", key)
+	}
+	g.traceTelemetry.Properties[g.key(key)] = string(b)
 	return nil
 }
 
-func (g *gobber) OpenNamespace(_ string) {
-	// TODO to be implemented
+// OpenNamespace prefixes the keys of the following fields of the current object with the namespace.
+func (g *gobber) OpenNamespace(key string) {
+	g.keyPrefix = g.key(key)
 }
 
 func (g *gobber) setTraceTelemetry(traceTelemetry *appinsights.TraceTelemetry) {
 	g.traceTelemetry = traceTelemetry
+	g.keyPrefix = ""
 }
 
 // newTraceEncoder creates a gobber that can only encode.
@@ -218,6 +236,8 @@ func fieldStringer(f *zapcore.Field) string {
 	case zapcore.BoolType:
 		return strconv.FormatBool(f.Integer == 1)
 	default:
-		return fmt.Sprintf("%v", f)
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		return fmt.Sprint(enc.Fields[f.Key])
 	}
 }
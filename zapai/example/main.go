@@ -66,11 +66,15 @@ func main() {
 		return
 	}
 	defer aiclose()
+	// the traces dropped by the AI sink under pressure should be exported with the process metrics
+	defer func() { fmt.Printf("dropped %d traces\n", zapai.Dropped()) }()
 
 	// build the AI core
 	aicore := zapai.NewCore(zapcore.DebugLevel, aisink)
 	// (optional): add the zap Field to AI Tag mappers
 	aicore = aicore.WithFieldMappers(zapai.DefaultMappers)
+	// (optional): rename zap Fields to the AI custom dimensions queried across components
+	aicore = aicore.WithDimensionMappers(map[string]string{"ncId": "NetworkContainerID"})
 
 	// compose the logfmt and aicore in to a virtual tee core so they both receive all log events
 	teecore := zapcore.NewTee(logfmtcore, jsoncore, aicore)
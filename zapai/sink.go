@@ -5,9 +5,11 @@ import (
 	"encoding/gob"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/microsoft/ApplicationInsights-Go/appinsights/contracts"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -19,8 +21,22 @@ const (
 	paramMaxBatchInterval = "maxBatchInterval"
 	paramMaxBatchSize     = "maxBatchSize"
 	paramGracePeriod      = "gracePeriod"
+	paramMaxQueueSize     = "maxQueueSize"
+	// DefaultMaxQueueSize is the number of traces a Sink buffers when its SinkConfig doesn't set a MaxQueueSize.
+	DefaultMaxQueueSize = 4096
+	// droppedReportInterval is how often a Sink reports the traces it dropped to appinsights.
+	droppedReportInterval = time.Minute
 )
 
+// dropped counts the traces dropped by every Sink of the process.
+var dropped atomic.Uint64
+
+// Dropped returns the number of traces the Sinks of the process dropped because their queue was full or they were
+// closed. The Sinks are hidden by zap.Open, so this is the counter to export to the metrics of the process.
+func Dropped() uint64 {
+	return dropped.Load()
+}
+
 func init() {
 	// register the appinsights sink factory
 	_ = zap.RegisterSink(SinkScheme, sinkbuilder)
@@ -39,12 +55,15 @@ func sinkbuilder(u *url.URL) (zap.Sink, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newSink(cfg), nil
+	return newSink(cfg, appinsights.NewTelemetryClientFromConfig(&cfg.TelemetryConfiguration)), nil
 }
 
 // SinkConfig is a container struct for an appinsights Sink configuration.
 type SinkConfig struct {
 	GracePeriod time.Duration
+	// MaxQueueSize bounds the number of traces buffered by the Sink while the appinsights channel is busy sending.
+	// Traces written while the queue is full are dropped. Defaults to DefaultMaxQueueSize.
+	MaxQueueSize int
 	appinsights.TelemetryConfiguration
 }
 
@@ -63,12 +82,20 @@ func fromURI(u *url.URL) (*SinkConfig, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to convert size parameter to int")
 	}
+	// the queue size is optional to accept the URIs built before it was configurable
+	var queueSize int
+	if q.Has(paramMaxQueueSize) {
+		if queueSize, err = strconv.Atoi(q.Get(paramMaxQueueSize)); err != nil {
+			return nil, errors.Wrap(err, "failed to convert queue size parameter to int")
+		}
+	}
 	endpoint, err := url.QueryUnescape(q.Get(paramEndpointURL))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to unescape url parameter")
 	}
 	return &SinkConfig{
-		GracePeriod: gracePeriod,
+		GracePeriod:  gracePeriod,
+		MaxQueueSize: queueSize,
 		TelemetryConfiguration: appinsights.TelemetryConfiguration{
 			InstrumentationKey: u.User.Username(),
 			EndpointUrl:        endpoint,
@@ -89,6 +116,7 @@ func toURI(sc *SinkConfig) *url.URL {
 	q.Add(paramGracePeriod, url.QueryEscape(sc.GracePeriod.String()))
 	q.Add(paramMaxBatchInterval, url.QueryEscape(sc.MaxBatchInterval.String()))
 	q.Add(paramMaxBatchSize, url.QueryEscape(strconv.Itoa(sc.MaxBatchSize)))
+	q.Add(paramMaxQueueSize, url.QueryEscape(strconv.Itoa(sc.MaxQueueSize)))
 	u.RawQuery = q.Encode()
 	return u
 }
@@ -113,44 +141,140 @@ var _ zap.Sink = (*Sink)(nil)
 // To conform to the zap.Sink interface, Sink implements Write([]byte), where it expects that the passed []byte is
 // an encoded gob that can be decoded in to a valid appinsights.TraceTelemetry. Passing any other []byte input is
 // an error.
+//
+// The appinsights channel blocks Track while it sends a batch, so the Sink queues the traces and tracks them from its
+// own goroutine, which keeps logging from blocking on the network. The queue is bounded: when it is full, traces are
+// dropped and counted, and the count is reported to appinsights periodically.
 type Sink struct {
 	*SinkConfig
-	cli telemetryTracker
-	dec traceDecoder
+	cli     telemetryTracker
+	dec     traceDecoder
+	queue   chan *appinsights.TraceTelemetry
+	flush   chan chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	closed  atomic.Bool
+	dropped atomic.Uint64
 }
 
-// newSink constructs a Sink from the passed SinkConfig
-func newSink(cfg *SinkConfig) *Sink {
-	return &Sink{
+// newSink constructs a Sink from the passed SinkConfig and starts tracking its queued traces with the cli.
+func newSink(cfg *SinkConfig, cli telemetryTracker) *Sink {
+	queueSize := cfg.MaxQueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultMaxQueueSize
+	}
+	s := &Sink{
 		SinkConfig: cfg,
-		cli:        appinsights.NewTelemetryClientFromConfig(&cfg.TelemetryConfiguration),
+		cli:        cli,
 		dec:        newTraceDecoder(),
+		queue:      make(chan *appinsights.TraceTelemetry, queueSize),
+		flush:      make(chan chan struct{}),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// run tracks the queued traces until the Sink is closed.
+func (s *Sink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(droppedReportInterval)
+	defer ticker.Stop()
+	var reported uint64
+	for {
+		select {
+		case t := <-s.queue:
+			s.cli.Track(t)
+		case done := <-s.flush:
+			s.drain()
+			close(done)
+		case <-ticker.C:
+			reported = s.reportDropped(reported)
+		case <-s.stop:
+			s.drain()
+			s.reportDropped(reported)
+			return
+		}
+	}
+}
+
+// drain tracks the traces currently queued.
+func (s *Sink) drain() {
+	for {
+		select {
+		case t := <-s.queue:
+			s.cli.Track(t)
+		default:
+			return
+		}
+	}
+}
+
+// reportDropped tracks a warning with the number of traces dropped since the last report, if any, and returns the
+// new total to report from.
+func (s *Sink) reportDropped(reported uint64) uint64 {
+	total := s.dropped.Load()
+	if total == reported {
+		return total
 	}
+	t := appinsights.NewTraceTelemetry("zapai sink dropped traces", contracts.Warning)
+	t.Properties["dropped"] = strconv.FormatUint(total-reported, 10)
+	s.cli.Track(t)
+	return total
 }
 
-// Write accepts a gob []byte that must be Decodable to an appinsights.TraceTelemetry{}, which is then sent
-// to appinsights via the telemetryTracker.
+// Write accepts a gob []byte that must be Decodable to an appinsights.TraceTelemetry{}, which is then queued to be
+// sent to appinsights via the telemetryTracker. The trace is dropped if the queue is full or the Sink is closed.
 func (s *Sink) Write(b []byte) (int, error) {
 	t, err := s.dec.decode(b)
 	if err != nil {
 		return 0, errors.Wrap(err, "sink failed to decode trace")
 	}
-	s.cli.Track(t)
-	return 0, nil
+	if s.closed.Load() {
+		s.drop()
+		return len(b), nil
+	}
+	select {
+	case s.queue <- t:
+	default:
+		s.drop()
+	}
+	return len(b), nil
+}
+
+func (s *Sink) drop() {
+	s.dropped.Add(1)
+	dropped.Add(1)
 }
 
-// Sync flushes the current channel queue.
+// Sync tracks the queued traces and flushes the current channel queue.
 func (s *Sink) Sync() error {
+	done := make(chan struct{})
+	select {
+	case s.flush <- done:
+		<-done
+	case <-s.stopped:
+	}
 	s.cli.Channel().Flush()
 	return nil
 }
 
-// Close flushes and tears down the appinsights channel.
+// Close tracks the queued traces, then flushes and tears down the appinsights channel.
 // Waits up to the GracePeriod duration for sends and retries to complete.
 func (s *Sink) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	close(s.stop)
 	ctx, cancel := context.WithTimeout(context.Background(), s.GracePeriod)
 	defer cancel()
 	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "sink close context timeout")
+	case <-s.stopped:
+	}
+	select {
 	case <-ctx.Done():
 		return errors.Wrap(ctx.Err(), "sink close context timeout")
 	case <-s.cli.Channel().Close(s.GracePeriod):
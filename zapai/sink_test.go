@@ -0,0 +1,105 @@
+package zapai
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microsoft/ApplicationInsights-Go/appinsights"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChannel struct {
+	appinsights.TelemetryChannel
+}
+
+func (fakeChannel) Flush() {}
+
+func (fakeChannel) Close(...time.Duration) <-chan struct{} {
+	closed := make(chan struct{})
+	close(closed)
+	return closed
+}
+
+// fakeTracker records the tracked traces, and blocks Track until the gate is opened like a sending channel.
+type fakeTracker struct {
+	sync.Mutex
+	gate    chan struct{}
+	blocked chan struct{}
+	traces  []*appinsights.TraceTelemetry
+}
+
+func (f *fakeTracker) Track(t appinsights.Telemetry) {
+	select {
+	case f.blocked <- struct{}{}:
+	default:
+	}
+	<-f.gate
+	f.Lock()
+	defer f.Unlock()
+	f.traces = append(f.traces, t.(*appinsights.TraceTelemetry))
+}
+
+func (f *fakeTracker) Channel() appinsights.TelemetryChannel {
+	return fakeChannel{}
+}
+
+func TestSinkConfigURI(t *testing.T) {
+	cfg := &SinkConfig{
+		GracePeriod:  time.Second,
+		MaxQueueSize: 10,
+		TelemetryConfiguration: appinsights.TelemetryConfiguration{
+			InstrumentationKey: "key",
+			EndpointUrl:        "https://dc.services.visualstudio.com/v2/track",
+			MaxBatchInterval:   time.Minute,
+			MaxBatchSize:       100,
+		},
+	}
+	got, err := fromURI(toURI(cfg))
+	require.NoError(t, err)
+	assert.Equal(t, cfg, got)
+
+	// URIs without a queue size get the default queue size
+	u := toURI(cfg)
+	q := u.Query()
+	q.Del(paramMaxQueueSize)
+	u.RawQuery = q.Encode()
+	got, err = fromURI(u)
+	require.NoError(t, err)
+	assert.Zero(t, got.MaxQueueSize)
+}
+
+func TestSinkDropsWhenQueueFull(t *testing.T) {
+	tracker := &fakeTracker{gate: make(chan struct{}), blocked: make(chan struct{}, 1)}
+	sink := newSink(&SinkConfig{GracePeriod: time.Second, MaxQueueSize: 1}, tracker)
+	enc := newTraceEncoder()
+	write := func(msg string) {
+		b, err := enc.encode(appinsights.NewTraceTelemetry(msg, 0))
+		require.NoError(t, err)
+		n, err := sink.Write(b)
+		require.NoError(t, err)
+		assert.Equal(t, len(b), n)
+	}
+	droppedBefore := Dropped()
+
+	// the first trace is tracked while the channel is busy, the second one is queued and the third one is dropped
+	write("tracked")
+	<-tracker.blocked
+	write("queued")
+	write("dropped")
+	assert.Equal(t, uint64(1), sink.dropped.Load())
+	assert.Equal(t, uint64(1), Dropped()-droppedBefore)
+
+	close(tracker.gate)
+	require.NoError(t, sink.Close())
+	write("closed")
+	assert.Equal(t, uint64(2), sink.dropped.Load())
+
+	tracker.Lock()
+	defer tracker.Unlock()
+	require.Len(t, tracker.traces, 3)
+	assert.Equal(t, "tracked", tracker.traces[0].Message)
+	assert.Equal(t, "queued", tracker.traces[1].Message)
+	assert.Equal(t, "1", tracker.traces[2].Properties["dropped"])
+}